@@ -11,3 +11,10 @@ CREATE TABLE _vt.reparent_log (
   new_position varchar(255),
   wait_position varchar(255),
   index (last_position));
+
+CREATE TABLE _vt.reparent_journal (
+  time_created_ns bigint unsigned not null,
+  action_name varchar(250) not null,
+  master_alias varchar(32) not null,
+  replication_position varchar(1000) default null,
+  primary key (time_created_ns));
@@ -0,0 +1,12 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mytype
+
+type MyType struct {
+	Custom1
+	*pkg.Custom2
+	custom3
+	Val int64
+}
@@ -0,0 +1,63 @@
+// Copyright 2012, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mytype
+
+import (
+	"github.com/youtube/vitess/go/bytes2"
+
+	"bytes"
+
+	"github.com/youtube/vitess/go/bson"
+)
+
+// DO NOT EDIT.
+// FILE GENERATED BY BSONGEN.
+
+// MarshalBson bson-encodes MyType.
+func (myType *MyType) MarshalBson(buf *bytes2.ChunkedWriter, key string) {
+	bson.EncodeOptionalPrefix(buf, bson.Object, key)
+	lenWriter := bson.NewLenWriter(buf)
+
+	myType.Custom1.MarshalBson(buf, "Custom1")
+	// *pkg.Custom2
+	if myType.Custom2 == nil {
+		bson.EncodePrefix(buf, bson.Null, "Custom2")
+	} else {
+		(*myType.Custom2).MarshalBson(buf, "Custom2")
+	}
+	bson.EncodeInt64(buf, "Val", myType.Val)
+
+	lenWriter.Close()
+}
+
+// UnmarshalBson bson-decodes into MyType.
+func (myType *MyType) UnmarshalBson(buf *bytes.Buffer, kind byte) {
+	switch kind {
+	case bson.EOO, bson.Object:
+		// valid
+	case bson.Null:
+		return
+	default:
+		panic(bson.NewBsonError("unexpected kind %v for MyType", kind))
+	}
+	bson.Next(buf, 4)
+
+	for kind := bson.NextByte(buf); kind != bson.EOO; kind = bson.NextByte(buf) {
+		switch bson.ReadCString(buf) {
+		case "Custom1":
+			myType.Custom1.UnmarshalBson(buf, kind)
+		case "Custom2":
+			// *pkg.Custom2
+			if kind != bson.Null {
+				myType.Custom2 = new(pkg.Custom2)
+				(*myType.Custom2).UnmarshalBson(buf, kind)
+			}
+		case "Val":
+			myType.Val = bson.DecodeInt64(buf, kind)
+		default:
+			bson.Skip(buf, kind)
+		}
+	}
+}
@@ -12,3 +12,6 @@ select * from t where :. = 2#syntax error at position 24 near :
 select * from t where ::1 = 2#syntax error at position 25 near ::
 select * from t where ::. = 2#syntax error at position 25 near ::
 select /* aa#syntax error at position 13 near /* aa
+savepoint#syntax error at position 10 near savepoint
+rollback to#syntax error at position 9 near rollback
+release a#syntax error at position 8 near release
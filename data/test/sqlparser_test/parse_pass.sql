@@ -185,3 +185,8 @@ analyze table a#alter table a
 show foobar#other
 describe foobar#other
 explain foobar#other
+savepoint a
+SAVEPOINT `select`#savepoint `select`
+rollback to savepoint a
+rollback work to a#rollback to savepoint a
+release savepoint a
@@ -343,6 +343,89 @@ func TestSkipUnknownFields(t *testing.T) {
 	}
 }
 
+type keyType string
+
+func TestMapKeys(t *testing.T) {
+	type mapKeys struct {
+		Named  map[keyType][]string
+		Int    map[int]string
+		Int8   map[int8]bool
+		Uint32 map[uint32]int64
+	}
+	want := mapKeys{
+		Named:  map[keyType][]string{"master": []string{"a", "b"}, "replica": []string{"c"}},
+		Int:    map[int]string{-1: "minus one", 0: "zero", 1 << 40: "large"},
+		Int8:   map[int8]bool{-128: true, 127: false},
+		Uint32: map[uint32]int64{0xFFFFFFFF: 1},
+	}
+	b, err := Marshal(&want)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var got mapKeys
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got \n%+v, want \n%+v", got, want)
+	}
+
+	// integer keys are encoded in base 10
+	var generic map[string]interface{}
+	if err := Unmarshal(b, &generic); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if _, ok := generic["Int"].(map[string]interface{})["-1"]; !ok {
+		t.Errorf("unexpected integer keys: %v", generic["Int"])
+	}
+
+	// keys out of range are errors
+	b, err = Marshal(map[string]int{"256": 1})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var small map[uint8]int
+	if err := Unmarshal(b, &small); err == nil || err.Error() != "invalid map index for uint8: 256" {
+		t.Errorf("Unmarshal of an out of range key returned: %v", err)
+	}
+}
+
+func TestTimeRoundTrip(t *testing.T) {
+	for _, want := range []time.Time{
+		time.Time{},
+		time.Unix(1136243045, 123000000).UTC(),
+		time.Unix(-1136243045, 456000000).UTC(),
+		time.Date(1500, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(3000, 12, 31, 23, 59, 59, 999000000, time.UTC),
+	} {
+		b, err := Marshal(struct{ Val time.Time }{want})
+		if err != nil {
+			t.Fatalf("Marshal(%v) failed: %v", want, err)
+		}
+		var got struct{ Val time.Time }
+		if err := Unmarshal(b, &got); err != nil {
+			t.Fatalf("Unmarshal(%v) failed: %v", want, err)
+		}
+		if !got.Val.Equal(want) {
+			t.Errorf("got %v, want %v", got.Val, want)
+		}
+	}
+
+	// precision is the millisecond, and the location is lost
+	want := time.Date(2015, 6, 1, 12, 0, 0, 1234567, time.FixedZone("PDT", -7*3600))
+	b, err := Marshal(struct{ Val time.Time }{want})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var got struct{ Val time.Time }
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !got.Val.Equal(want.Truncate(time.Millisecond)) || got.Val.Location() != time.UTC {
+		t.Errorf("got %v, want %v in UTC", got.Val, want.Truncate(time.Millisecond))
+	}
+}
+
 func TestEncodeFieldNil(t *testing.T) {
 	buf := bytes2.NewChunkedWriter(DefaultBufferSize)
 	EncodeField(buf, "Val", nil)
@@ -383,6 +466,32 @@ func TestStream(t *testing.T) {
 	}
 }
 
+// panickyUnmarshaler fails with a runtime panic, like a decoder
+// reading a malformed document can.
+type panickyUnmarshaler struct{}
+
+func (pu *panickyUnmarshaler) UnmarshalBson(buf *bytes.Buffer, kind byte) {
+	var values []int
+	_ = values[len(buf.Bytes())]
+}
+
+func TestMalformedStream(t *testing.T) {
+	err := UnmarshalFromStream(bytes.NewBufferString("\x02\x00\x00\x00"), new(int64))
+	want := "invalid document length: 2"
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %v", err, want)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := MarshalToStream(buf, 1); err != nil {
+		t.Fatal(err)
+	}
+	err = UnmarshalFromStream(buf, &panickyUnmarshaler{})
+	if _, ok := err.(BsonError); !ok {
+		t.Errorf("got %v, want a BsonError", err)
+	}
+}
+
 var testMap map[string]interface{}
 var testBlob []byte
 
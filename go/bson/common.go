@@ -3,6 +3,14 @@
 // license that can be found in the LICENSE file.
 
 // Package bson implements encoding and decoding of BSON objects.
+//
+// Decoding does not copy binary values: the decoded []byte (and
+// so the values of query results, see sqltypes.Value) are slices
+// of the buffer that is being decoded, and so are the keys of the
+// decoded maps. The decoded object thus shares the buffer, which
+// must not be modified or reused while the object is in use.
+// UnmarshalFromStream allocates a new buffer for each document, so
+// the decoded objects own it. Other strings are copied.
 package bson
 
 import (
@@ -70,8 +78,16 @@ func (err BsonError) Error() string {
 	return err.Message
 }
 
+// handleError turns a panic during encoding or decoding into an
+// error. Besides the BsonErrors, malformed input can cause runtime
+// panics, like an index out of range, that are returned as
+// BsonErrors too.
 func handleError(err *error) {
 	if x := recover(); x != nil {
-		*err = x.(BsonError)
+		if berr, ok := x.(BsonError); ok {
+			*err = berr
+			return
+		}
+		*err = NewBsonError("bson panic: %v", x)
 	}
 }
@@ -0,0 +1,195 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"math"
+	"time"
+)
+
+// Encoder encodes bson into a contiguous buffer, that grows as
+// needed. It is an alternative to the ChunkedWriter and LenWriter
+// functions, that write through many small Reserve calls and patch
+// the length of each document as soon as it is closed: Begin only
+// records the offset of a length slot, and End its end, and Bytes
+// writes all the lengths in a single pass.
+//
+// The methods have the same encoding as the Encode functions.
+type Encoder struct {
+	buf []byte
+	// slots are the documents, in the order they were begun.
+	slots []lenSlot
+	// open are the indexes in slots of the documents not ended
+	// yet, the innermost last.
+	open []int
+}
+
+// lenSlot is the length slot of a document, at off. end is the
+// offset after its EOO byte.
+type lenSlot struct {
+	off, end int
+}
+
+// NewEncoder returns an Encoder with a buffer of size bytes
+// preallocated.
+func NewEncoder(size int) *Encoder {
+	return &Encoder{buf: make([]byte, 0, size)}
+}
+
+// Reset empties the encoder, and keeps its memory.
+func (enc *Encoder) Reset() {
+	enc.buf = enc.buf[:0]
+	enc.slots = enc.slots[:0]
+	enc.open = enc.open[:0]
+}
+
+// Len returns the number of bytes encoded.
+func (enc *Encoder) Len() int {
+	return len(enc.buf)
+}
+
+// Bytes writes the lengths of the documents, and returns the
+// encoded bytes. They are only valid until the next Reset. All the
+// documents must be ended.
+func (enc *Encoder) Bytes() []byte {
+	if len(enc.open) != 0 {
+		panic(NewBsonError("%v documents not ended", len(enc.open)))
+	}
+	for _, slot := range enc.slots {
+		Pack.PutUint32(enc.buf[slot.off:], uint32(slot.end-slot.off))
+	}
+	enc.slots = enc.slots[:0]
+	return enc.buf
+}
+
+// Begin starts a document, by reserving the slot of its length.
+func (enc *Encoder) Begin() {
+	enc.open = append(enc.open, len(enc.slots))
+	enc.slots = append(enc.slots, lenSlot{off: len(enc.buf)})
+	enc.buf = append(enc.buf, 0, 0, 0, 0)
+}
+
+// End ends the innermost document, with bson's EOO byte.
+func (enc *Encoder) End() {
+	enc.buf = append(enc.buf, EOO)
+	last := len(enc.open) - 1
+	enc.slots[enc.open[last]].end = len(enc.buf)
+	enc.open = enc.open[:last]
+}
+
+// BeginObject starts a document with the key. If the key is empty,
+// the document is the top level object.
+func (enc *Encoder) BeginObject(key string) {
+	enc.OptionalPrefix(Object, key)
+	enc.Begin()
+}
+
+// BeginArray starts an array with the key. The keys of its elements
+// are their index, see Itoa.
+func (enc *Encoder) BeginArray(key string) {
+	enc.Prefix(Array, key)
+	enc.Begin()
+}
+
+// OptionalPrefix encodes the key as prefix if it's not empty, like
+// EncodeOptionalPrefix.
+func (enc *Encoder) OptionalPrefix(etype byte, key string) {
+	if key == "" {
+		return
+	}
+	enc.Prefix(etype, key)
+}
+
+// Prefix encodes key as prefix for the next object or value.
+func (enc *Encoder) Prefix(etype byte, key string) {
+	enc.buf = append(enc.buf, etype)
+	enc.buf = append(enc.buf, key...)
+	enc.buf = append(enc.buf, 0)
+}
+
+// Null encodes a null value.
+func (enc *Encoder) Null(key string) {
+	enc.Prefix(Null, key)
+}
+
+// String encodes a string, as binary like EncodeString.
+func (enc *Encoder) String(key string, val string) {
+	enc.Prefix(Binary, key)
+	enc.putUint32(uint32(len(val)))
+	enc.buf = append(enc.buf, 0)
+	enc.buf = append(enc.buf, val...)
+}
+
+// Binary encodes a []byte.
+func (enc *Encoder) Binary(key string, val []byte) {
+	enc.Prefix(Binary, key)
+	enc.putUint32(uint32(len(val)))
+	enc.buf = append(enc.buf, 0)
+	enc.buf = append(enc.buf, val...)
+}
+
+// Int64 encodes an int64.
+func (enc *Encoder) Int64(key string, val int64) {
+	enc.Prefix(Long, key)
+	enc.putUint64(uint64(val))
+}
+
+// Int32 encodes an int32.
+func (enc *Encoder) Int32(key string, val int32) {
+	enc.Prefix(Int, key)
+	enc.putUint32(uint32(val))
+}
+
+// Int encodes an int.
+func (enc *Encoder) Int(key string, val int) {
+	enc.Int64(key, int64(val))
+}
+
+// Uint64 encodes an uint64.
+func (enc *Encoder) Uint64(key string, val uint64) {
+	enc.Prefix(Ulong, key)
+	enc.putUint64(val)
+}
+
+// Uint32 encodes an uint32.
+func (enc *Encoder) Uint32(key string, val uint32) {
+	enc.Uint64(key, uint64(val))
+}
+
+// Uint encodes an uint.
+func (enc *Encoder) Uint(key string, val uint) {
+	enc.Uint64(key, uint64(val))
+}
+
+// Float64 encodes a float64.
+func (enc *Encoder) Float64(key string, val float64) {
+	enc.Prefix(Number, key)
+	enc.putUint64(math.Float64bits(val))
+}
+
+// Bool encodes a bool.
+func (enc *Encoder) Bool(key string, val bool) {
+	enc.Prefix(Boolean, key)
+	if val {
+		enc.buf = append(enc.buf, 1)
+	} else {
+		enc.buf = append(enc.buf, 0)
+	}
+}
+
+// Time encodes a time.Time.
+func (enc *Encoder) Time(key string, val time.Time) {
+	enc.Prefix(Datetime, key)
+	enc.putUint64(uint64(val.Unix()*1e3 + int64(val.Nanosecond())/1e6))
+}
+
+func (enc *Encoder) putUint32(val uint32) {
+	enc.buf = append(enc.buf, byte(val), byte(val>>8), byte(val>>16), byte(val>>24))
+}
+
+func (enc *Encoder) putUint64(val uint64) {
+	enc.buf = append(enc.buf, byte(val), byte(val>>8), byte(val>>16), byte(val>>24),
+		byte(val>>32), byte(val>>40), byte(val>>48), byte(val>>56))
+}
@@ -0,0 +1,85 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/bytes2"
+)
+
+func TestEncoder(t *testing.T) {
+	now := time.Unix(1425000000, 123000000)
+
+	buf := bytes2.NewChunkedWriter(16)
+	lenWriter := NewLenWriter(buf)
+	EncodeString(buf, "String", "value")
+	EncodeBinary(buf, "Binary", []byte{1, 2, 3})
+	EncodeInt64(buf, "Int64", -64)
+	EncodeInt32(buf, "Int32", -32)
+	EncodeInt(buf, "Int", 1)
+	EncodeUint64(buf, "Uint64", 64)
+	EncodeUint32(buf, "Uint32", 32)
+	EncodeUint(buf, "Uint", 2)
+	EncodeFloat64(buf, "Float64", 1.5)
+	EncodeBool(buf, "True", true)
+	EncodeBool(buf, "False", false)
+	EncodeTime(buf, "Time", now)
+	EncodePrefix(buf, Null, "Null")
+	EncodePrefix(buf, Object, "Object")
+	subWriter := NewLenWriter(buf)
+	EncodePrefix(buf, Array, "Array")
+	arrayWriter := NewLenWriter(buf)
+	EncodeString(buf, Itoa(0), "a")
+	EncodeString(buf, Itoa(1), "b")
+	arrayWriter.Close()
+	subWriter.Close()
+	lenWriter.Close()
+	want := buf.Bytes()
+
+	// A small buffer also checks the offsets of the slots are
+	// still right after the buffer grew.
+	enc := NewEncoder(16)
+	for i := 0; i < 2; i++ {
+		enc.BeginObject("")
+		enc.String("String", "value")
+		enc.Binary("Binary", []byte{1, 2, 3})
+		enc.Int64("Int64", -64)
+		enc.Int32("Int32", -32)
+		enc.Int("Int", 1)
+		enc.Uint64("Uint64", 64)
+		enc.Uint32("Uint32", 32)
+		enc.Uint("Uint", 2)
+		enc.Float64("Float64", 1.5)
+		enc.Bool("True", true)
+		enc.Bool("False", false)
+		enc.Time("Time", now)
+		enc.Null("Null")
+		enc.BeginObject("Object")
+		enc.BeginArray("Array")
+		enc.String(Itoa(0), "a")
+		enc.String(Itoa(1), "b")
+		enc.End()
+		enc.End()
+		enc.End()
+		if got := enc.Bytes(); !bytes.Equal(got, want) {
+			t.Errorf("Encoder pass %v:\n%q, want\n%q", i, got, want)
+		}
+		enc.Reset()
+	}
+}
+
+func TestEncoderNotEnded(t *testing.T) {
+	defer func() {
+		if x := recover(); x == nil {
+			t.Errorf("Bytes with an open document didn't panic")
+		}
+	}()
+	enc := NewEncoder(16)
+	enc.BeginObject("")
+	enc.Bytes()
+}
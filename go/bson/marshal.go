@@ -9,6 +9,7 @@ import (
 	"math"
 	"reflect"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/youtube/vitess/go/bytes2"
@@ -72,9 +73,20 @@ func canMarshal(val reflect.Value) Marshaler {
 // DefaultBufferSize is the default allocation size for ChunkedWriter.
 const DefaultBufferSize = 1024
 
+// streamBuffers recycles the buffers of MarshalToStream.
+var streamBuffers = sync.Pool{
+	New: func() interface{} {
+		return bytes2.NewChunkedWriter(DefaultBufferSize)
+	},
+}
+
 // MarshalToStream marshals val into writer.
 func MarshalToStream(writer io.Writer, val interface{}) (err error) {
-	buf := bytes2.NewChunkedWriter(DefaultBufferSize)
+	buf := streamBuffers.Get().(*bytes2.ChunkedWriter)
+	defer func() {
+		buf.Reset()
+		streamBuffers.Put(buf)
+	}()
 	if err = MarshalToBuffer(buf, val); err != nil {
 		return err
 	}
@@ -287,7 +299,9 @@ func EncodeBool(buf *bytes2.ChunkedWriter, key string, val bool) {
 // EncodeTime encodes a time.Time.
 func EncodeTime(buf *bytes2.ChunkedWriter, key string, val time.Time) {
 	EncodePrefix(buf, Datetime, key)
-	mtime := val.UnixNano() / 1e6
+	// UnixNano overflows outside of years 1678-2262, compute the
+	// milliseconds from the seconds instead.
+	mtime := val.Unix()*1e3 + int64(val.Nanosecond())/1e6
 	putUint64(buf, uint64(mtime))
 }
 
@@ -328,17 +342,40 @@ func encodeMap(buf *bytes2.ChunkedWriter, key string, val reflect.Value) {
 func encodeMapContent(buf *bytes2.ChunkedWriter, val reflect.Value) {
 	lenWriter := NewLenWriter(buf)
 	mt := val.Type()
-	if mt.Key().Kind() != reflect.String {
-		panic(NewBsonError("can't marshall maps with non-string key types"))
+	if !isValidMapKey(mt.Key().Kind()) {
+		panic(NewBsonError("can't marshall maps with %v key types", mt.Key()))
 	}
 	keys := val.MapKeys()
 	for _, k := range keys {
-		key := k.String()
-		encodeField(buf, key, val.MapIndex(k))
+		encodeField(buf, mapKeyString(k), val.MapIndex(k))
 	}
 	lenWriter.Close()
 }
 
+// isValidMapKey returns true if maps with keys of that kind can be
+// encoded: strings (or types based on them) and integers.
+func isValidMapKey(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// mapKeyString returns the bson key for a map key: strings are used
+// as is, integers are written in base 10.
+func mapKeyString(k reflect.Value) string {
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(k.Uint(), 10)
+	}
+	return k.String()
+}
+
 func encodeSlice(buf *bytes2.ChunkedWriter, key string, val reflect.Value) {
 	EncodePrefix(buf, Array, key)
 	encodeSliceContent(buf, val)
@@ -216,9 +216,9 @@ var marshalErrorCases = []struct {
 	struct{ Val chan int }{},
 	"don't know how to marshal chan int",
 }, {
-	"map with int key",
-	map[int]int{},
-	"can't marshall maps with non-string key types",
+	"map with float key",
+	map[float64]int{},
+	"can't marshall maps with float64 key types",
 }}
 
 func TestMarshalErrors(t *testing.T) {
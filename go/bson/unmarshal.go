@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"strconv"
 	"time"
 )
 
@@ -36,12 +37,14 @@ func (builder *valueBuilder) canUnMarshal() Unmarshaler {
 	return nil
 }
 
-// Unmarshal unmarshals b into val.
+// Unmarshal unmarshals b into val. The decoded binary values
+// are slices of b (see the package documentation).
 func Unmarshal(b []byte, val interface{}) (err error) {
 	return UnmarshalFromBuffer(bytes.NewBuffer(b), val)
 }
 
-// UnmarshalFromStream unmarshals from reader into val.
+// UnmarshalFromStream unmarshals from reader into val. The document
+// is read in a new buffer that the decoded binary values share.
 func UnmarshalFromStream(reader io.Reader, val interface{}) (err error) {
 	lenbuf := make([]byte, 4)
 	var n int
@@ -53,6 +56,9 @@ func UnmarshalFromStream(reader io.Reader, val interface{}) (err error) {
 		return io.ErrUnexpectedEOF
 	}
 	length := Pack.Uint32(lenbuf)
+	if length < 5 {
+		return NewBsonError("invalid document length: %v", length)
+	}
 	b := make([]byte, length)
 	Pack.PutUint32(b, length)
 	n, err = io.ReadFull(reader, b[4:])
@@ -68,7 +74,8 @@ func UnmarshalFromStream(reader io.Reader, val interface{}) (err error) {
 	return UnmarshalFromBuffer(bytes.NewBuffer(b), val)
 }
 
-// UnmarshalFromBuffer unmarshals from buf into val.
+// UnmarshalFromBuffer unmarshals from buf into val. The decoded
+// binary values are slices of the content of buf.
 func UnmarshalFromBuffer(buf *bytes.Buffer, val interface{}) (err error) {
 	defer handleError(&err)
 	if val == nil {
@@ -245,10 +252,7 @@ func (builder *valueBuilder) initField(k string, kind byte) *valueBuilder {
 		return nil
 	case reflect.Map:
 		t := builder.val.Type()
-		if t.Key().Kind() != reflect.String {
-			panic(NewBsonError("map index is not a string: %s", k))
-		}
-		key := reflect.ValueOf(k)
+		key := mapKeyValue(t.Key(), k)
 		if kind == Null {
 			zero := reflect.Zero(t.Elem())
 			builder.val.SetMapIndex(key, zero)
@@ -278,6 +282,31 @@ func (builder *valueBuilder) initField(k string, kind byte) *valueBuilder {
 	panic(NewBsonError("internal error: unindexable type %v", builder.val.Type()))
 }
 
+// mapKeyValue converts a bson key into a map key of type typ. It is
+// the reverse of mapKeyString.
+func mapKeyValue(typ reflect.Type, k string) reflect.Value {
+	key := reflect.New(typ).Elem()
+	switch typ.Kind() {
+	case reflect.String:
+		key.SetString(k)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(k, 10, typ.Bits())
+		if err != nil {
+			panic(NewBsonError("invalid map index for %v: %s", typ, k))
+		}
+		key.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(k, 10, typ.Bits())
+		if err != nil {
+			panic(NewBsonError("invalid map index for %v: %s", typ, k))
+		}
+		key.SetUint(u)
+	default:
+		panic(NewBsonError("map index is not a string or an integer: %s", k))
+	}
+	return key
+}
+
 func setZero(v reflect.Value) {
 	v.Set(reflect.Zero(v.Type()))
 }
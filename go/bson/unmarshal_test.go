@@ -455,10 +455,15 @@ var unmarshalErrorCases = []struct {
 	&struct{ Val struct{ Val2 int } }{},
 	"unexpected kind: 16",
 }, {
-	"map with int key",
+	"map with float key",
+	"\x0e\x00\x00\x00\x10Val\x00\x01\x00\x00\x00\x00",
+	&map[float64]int{},
+	"map index is not a string or an integer: Val",
+}, {
+	"map with invalid int key",
 	"\x0e\x00\x00\x00\x10Val\x00\x01\x00\x00\x00\x00",
 	&map[int]int{},
-	"map index is not a string: Val",
+	"invalid map index for int: Val",
 }, {
 	"small array",
 	"\x1f\x00\x00\x00\x050\x00\x05\x00\x00\x00\x00test1\x051\x00\x05\x00\x00\x00\x00test2\x00",
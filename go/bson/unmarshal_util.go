@@ -178,8 +178,8 @@ func DecodeBool(buf *bytes.Buffer, kind byte) bool {
 func DecodeTime(buf *bytes.Buffer, kind byte) time.Time {
 	switch kind {
 	case Datetime:
-		ui64 := Pack.Uint64(Next(buf, 8))
-		return time.Unix(0, int64(ui64)*1e6).UTC()
+		mtime := int64(Pack.Uint64(Next(buf, 8)))
+		return time.Unix(mtime/1e3, (mtime%1e3)*1e6).UTC()
 	case Null:
 		return time.Time{}
 	}
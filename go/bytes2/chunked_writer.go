@@ -9,6 +9,7 @@ package bytes2
 import (
 	"fmt"
 	"io"
+	"sync"
 	"unicode/utf8"
 
 	"github.com/youtube/vitess/go/hack"
@@ -47,10 +48,16 @@ func (cw *ChunkedWriter) Len() int {
 	return l
 }
 
+// Reset empties the writer. The chunks beyond the first one are
+// recycled, so the slices previously returned by Reserve must not be
+// used after it.
 func (cw *ChunkedWriter) Reset() {
-	b := cw.bufs[0][:0]
-	cw.bufs = make([][]byte, 1)
-	cw.bufs[0] = b
+	for i := 1; i < len(cw.bufs); i++ {
+		putChunk(cw.bufs[i])
+		cw.bufs[i] = nil
+	}
+	cw.bufs[0] = cw.bufs[0][:0]
+	cw.bufs = cw.bufs[:1]
 }
 
 func (cw *ChunkedWriter) Truncate(n int) {
@@ -82,7 +89,7 @@ func (cw *ChunkedWriter) WriteString(p string) (n int, err error) {
 		}
 		cw.bufs[len(cw.bufs)-1] = append(lastbuf, p[:available]...)
 		p = p[available:]
-		lastbuf = make([]byte, 0, cap(cw.bufs[0]))
+		lastbuf = getChunk(cap(cw.bufs[0]))
 		cw.bufs = append(cw.bufs, lastbuf)
 	}
 }
@@ -93,7 +100,7 @@ func (cw *ChunkedWriter) Reserve(n int) (b []byte) {
 	}
 	lastbuf := cw.bufs[len(cw.bufs)-1]
 	if n > cap(lastbuf)-len(lastbuf) {
-		b = make([]byte, n, cap(cw.bufs[0]))
+		b = getChunk(cap(cw.bufs[0]))[:n]
 		cw.bufs = append(cw.bufs, b)
 		return b
 	}
@@ -127,3 +134,33 @@ func (cw *ChunkedWriter) WriteTo(w io.Writer) (n int64, err error) {
 	cw.Reset()
 	return n, nil
 }
+
+var (
+	// chunkPools recycles the chunks of the writers by size, so
+	// a writer that is reused doesn't allocate them again.
+	chunkPoolsMu sync.Mutex
+	chunkPools   = make(map[int]*sync.Pool)
+)
+
+func chunkPool(size int) *sync.Pool {
+	chunkPoolsMu.Lock()
+	defer chunkPoolsMu.Unlock()
+	pool, ok := chunkPools[size]
+	if !ok {
+		pool = &sync.Pool{}
+		chunkPools[size] = pool
+	}
+	return pool
+}
+
+// getChunk returns an empty chunk of capacity size.
+func getChunk(size int) []byte {
+	if b, ok := chunkPool(size).Get().([]byte); ok {
+		return b[:0]
+	}
+	return make([]byte, 0, size)
+}
+
+func putChunk(b []byte) {
+	chunkPool(cap(b)).Put(b)
+}
@@ -8,6 +8,7 @@
 package bytes2
 
 import (
+	"io/ioutil"
 	"testing"
 )
 
@@ -70,3 +71,27 @@ func TestWriteTo(t *testing.T) {
 		t.Errorf("Expecting 123456789, received %s", cw2.Bytes())
 	}
 }
+
+func TestResetReuse(t *testing.T) {
+	cw := NewChunkedWriter(4)
+	cw.WriteString("123456789")
+	cw.Reset()
+	cw.WriteString("abcdefghij")
+	b := cw.Reserve(3)
+	copy(b, "klm")
+	if string(cw.Bytes()) != "abcdefghijklm" {
+		t.Errorf("Expecting abcdefghijklm, received %s", cw.Bytes())
+	}
+}
+
+func BenchmarkChunkedWriterReuse(b *testing.B) {
+	b.ReportAllocs()
+	data := make([]byte, 100)
+	cw := NewChunkedWriter(1024)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 300; j++ {
+			cw.Write(data)
+		}
+		cw.WriteTo(ioutil.Discard)
+	}
+}
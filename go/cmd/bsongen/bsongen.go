@@ -8,6 +8,15 @@ a given go type. It uses goimports to fix the import
 statetments post-generation. It assumes goimports is
 in the path. If you specify a GOIMPORTS environment
 variable, it will use that instead.
+
+It is meant to be run by go generate, with a comment like this one
+next to the type:
+
+	//go:generate bsongen -file $GOFILE -type MyType -o my_type_bson.go
+
+'make bson' installs it and regenerates all the files. Embedded
+fields are encoded as fields named after their type, like the
+reflection encoder of the bson package does.
 */
 package main
 
@@ -272,10 +281,17 @@ var (
 // buildFields builds the fields of a struct into a list.
 func buildFields(structType *ast.StructType, varName string) (fields []*FieldInfo, err error) {
 	for _, field := range structType.Fields.List {
-		if field.Names == nil {
-			return nil, fmt.Errorf("anonymous embeds not supported: %+v", field.Type)
+		names := field.Names
+		if names == nil {
+			// Embedded fields are encoded like the reflection
+			// encoder does, as a field named after their type.
+			name, err := embeddedName(field.Type)
+			if err != nil {
+				return nil, err
+			}
+			names = []*ast.Ident{name}
 		}
-		for _, name := range field.Names {
+		for _, name := range names {
 			var tag string
 			if field.Tag != nil {
 				values := tagRE.FindStringSubmatch(field.Tag.Value)
@@ -301,6 +317,20 @@ func buildFields(structType *ast.StructType, varName string) (fields []*FieldInf
 	return fields, nil
 }
 
+// embeddedName returns the implicit name of an embedded field,
+// which is the name of its type.
+func embeddedName(fieldType ast.Expr) (*ast.Ident, error) {
+	switch ident := fieldType.(type) {
+	case *ast.Ident:
+		return ident, nil
+	case *ast.StarExpr:
+		return embeddedName(ident.X)
+	case *ast.SelectorExpr:
+		return ident.Sel, nil
+	}
+	return nil, fmt.Errorf("unsupported anonymous embed: %+v", fieldType)
+}
+
 // buildField builds an individual field of a struct. It populates the info
 // such that it goes hand-in-hand with the code generation templates. For example,
 // the tag for an array type is bson.Itoa(_i), because it knows that the template
@@ -119,10 +119,6 @@ var invalidInputs = []struct{ title, input, err string }{
 		"slice type",
 		`package a; type MyType []Custom;`,
 		"MyType is not a struct or a simple type",
-	}, {
-		"anonymous embed",
-		`package a; type MyType struct{Custom};`,
-		"anonymous embeds not supported: Custom",
 	}, {
 		"interface with methods",
 		`package a; type MyType struct{Val interface{Custom}};`,
@@ -42,6 +42,18 @@ func initCmd(mysqld *mysqlctl.Mysqld, subFlags *flag.FlagSet, args []string) err
 	return nil
 }
 
+func reinitCmd(mysqld *mysqlctl.Mysqld, subFlags *flag.FlagSet, args []string) error {
+	waitTime := subFlags.Duration("wait_time", mysqlctl.MysqlWaitTime, "how long to wait for shutdown and startup")
+	bootstrapArchive := subFlags.String("bootstrap_archive", "mysql-db-dir.tbz", "name of bootstrap archive within vitess/data/bootstrap directory")
+	skipSchema := subFlags.Bool("skip_schema", false, "don't apply initial schema")
+	subFlags.Parse(args)
+
+	if err := mysqld.Reinit(*waitTime, *bootstrapArchive, *skipSchema); err != nil {
+		return fmt.Errorf("failed reinit mysql: %v", err)
+	}
+	return nil
+}
+
 func restoreCmd(mysqld *mysqlctl.Mysqld, subFlags *flag.FlagSet, args []string) error {
 	dontWaitForSlaveStart := subFlags.Bool("dont_wait_for_slave_start", false, "won't wait for replication to start (useful when restoring from master server)")
 	fetchConcurrency := subFlags.Int("fetch_concurrency", 3, "how many files to fetch simultaneously")
@@ -55,7 +67,7 @@ func restoreCmd(mysqld *mysqlctl.Mysqld, subFlags *flag.FlagSet, args []string)
 	if err != nil {
 		return fmt.Errorf("restore failed: ReadSnapshotManifest: %v", err)
 	}
-	err = mysqld.RestoreFromSnapshot(logutil.NewConsoleLogger(), rs, *fetchConcurrency, *fetchRetryCount, *dontWaitForSlaveStart, nil)
+	err = mysqld.RestoreFromSnapshot(logutil.NewConsoleLogger(), rs, *fetchConcurrency, *fetchRetryCount, 1, *dontWaitForSlaveStart, nil)
 	if err != nil {
 		return fmt.Errorf("restore failed: RestoreFromSnapshot: %v", err)
 	}
@@ -148,6 +160,8 @@ var commands = []command{
 		"Initalizes the directory structure and starts mysqld"},
 	command{"teardown", teardownCmd, "[-force]",
 		"Shuts mysqld down, and removes the directory"},
+	command{"reinit", reinitCmd, "[-wait_time=20s] [-bootstrap_archive=mysql-db-dir.tbz] [-skip_schema]",
+		"Shuts mysqld down, removes the directory, and initializes it again"},
 	command{"start", startCmd, "[-wait_time=20s]",
 		"Starts mysqld on an already 'init'-ed directory"},
 	command{"shutdown", shutdownCmd, "[-wait_time=20s]",
@@ -75,10 +75,14 @@ func main() {
 	// Start or Init mysqld as needed.
 	if _, err = os.Stat(mycnf.DataDir); os.IsNotExist(err) {
 		log.Infof("mysql data dir (%s) doesn't exist, initializing", mycnf.DataDir)
-		mysqld.Init(*waitTime, *bootstrapArchive, *skipSchema)
+		err = mysqld.Init(*waitTime, *bootstrapArchive, *skipSchema)
 	} else {
 		log.Infof("mysql data dir (%s) already exists, starting without init", mycnf.DataDir)
-		mysqld.Start(*waitTime)
+		err = mysqld.Start(*waitTime)
+	}
+	if err != nil {
+		log.Errorf("failed to start mysqld: %v", err)
+		exit.Return(1)
 	}
 
 	servenv.Init()
@@ -0,0 +1,44 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/youtube/vitess/go/vt/servenv"
+)
+
+// mysqlTemplate is about the mysqld instance we manage
+const mysqlTemplate = `
+Data dir: {{.DataDir}}<br>
+Socket: {{.SocketFile}}<br>
+{{if .Error}}<span style="color:red">Cannot reach mysqld: {{.Error}}</span><br>
+{{else}}Listening on port {{.Port}}<br>
+{{end}}
+`
+
+type mysqlStatus struct {
+	DataDir    string
+	SocketFile string
+	Port       int
+	Error      string
+}
+
+func init() {
+	servenv.OnRun(func() {
+		servenv.AddStatusPart("MySQL", mysqlTemplate, func() interface{} {
+			cnf := mysqld.Cnf()
+			status := &mysqlStatus{
+				DataDir:    cnf.DataDir,
+				SocketFile: cnf.SocketFile,
+			}
+			port, err := mysqld.GetMysqlPort()
+			if err != nil {
+				status.Error = err.Error()
+			} else {
+				status.Port = port
+			}
+			return status
+		})
+	})
+}
@@ -0,0 +1,44 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/youtube/vitess/go/vt/servenv"
+)
+
+// cacheTemplate shows the cached values
+const cacheTemplate = `
+<style>
+  table {
+    border-collapse: collapse;
+  }
+  td, th {
+    border: 1px solid #999;
+    padding: 0.5rem;
+  }
+</style>
+<table>
+  <tr>
+    <th>Object</th>
+    <th>Age</th>
+    <th>State</th>
+  </tr>
+  {{range .}}
+  <tr>
+    <td>{{.Key}}</td>
+    <td>{{.Age}}</td>
+    <td>{{if .Error}}<b>{{.Error}}</b>{{else if .FallbackCell}}from cell {{.FallbackCell}}{{else if .Stale}}stale{{else}}fresh{{end}}</td>
+  </tr>
+  {{end}}
+</table>
+`
+
+func init() {
+	servenv.OnRun(func() {
+		servenv.AddStatusPart("Serving Graph Cache", cacheTemplate, func() interface{} {
+			return cache.Status()
+		})
+	})
+}
@@ -0,0 +1,55 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// topocache serves the serving graph from a cache, over RPC (the
+// TopoReader service) and HTTP, so the clients that only read it
+// don't each need a session with the topology server.
+package main
+
+import (
+	"flag"
+	"strings"
+	"time"
+
+	"github.com/youtube/vitess/go/exit"
+	"github.com/youtube/vitess/go/vt/servenv"
+	"github.com/youtube/vitess/go/vt/status"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/topocache"
+)
+
+var (
+	ttl           = flag.Duration("cache_ttl", 1*time.Second, "how long to serve a value before reading it again")
+	maxStaleness  = flag.Duration("max_staleness", 5*time.Minute, "how long to keep serving a value that cannot be read again")
+	readTimeout   = flag.Duration("read_timeout", 5*time.Second, "timeout of the reads to the topology server")
+	fallbackCells = flag.String("fallback_cells", "", "comma-separated list of cells to read from, in order, when a cell cannot be read and its cached value is too stale")
+)
+
+var cache *topocache.Cache
+
+func init() {
+	servenv.RegisterDefaultFlags()
+	servenv.InitServiceMapForBsonRpcService("toporeader")
+}
+
+func main() {
+	defer exit.Recover()
+
+	flag.Parse()
+	servenv.Init()
+
+	ts := topo.GetServer()
+	defer topo.CloseServers()
+
+	var cells []string
+	if *fallbackCells != "" {
+		cells = strings.Split(*fallbackCells, ",")
+	}
+	cache = topocache.NewCache(ts, *ttl, *maxStaleness, *readTimeout, cells, "TopoCache")
+	servenv.Register("toporeader", topocache.NewTopoReader(cache))
+	topocache.HandleHTTP(cache)
+
+	status.AddTopoStatusPart(ts)
+	servenv.RunDefault()
+}
@@ -0,0 +1,12 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// Imports the vtgate vindexes to register them as sharding schemes,
+// for SetKeyspaceShardingScheme.
+
+import (
+	_ "github.com/youtube/vitess/go/vt/vtgate/vindexes"
+)
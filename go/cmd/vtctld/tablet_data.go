@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"sync"
 
+	"github.com/youtube/vitess/go/vt/servenv"
 	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
 	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
 	"github.com/youtube/vitess/go/vt/topo"
@@ -47,6 +48,7 @@ func newTabletHealth(thc *tabletHealthCache, tabletAlias topo.TabletAlias) (*Tab
 
 func (th *TabletHealth) update(thc *tabletHealthCache, tabletAlias topo.TabletAlias) {
 	defer thc.delete(tabletAlias)
+	defer servenv.LogPanic("vtctld tablet health")
 
 	ti, err := thc.ts.GetTablet(tabletAlias)
 	if err != nil {
@@ -11,6 +11,7 @@ import (
 	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/acl"
 	"github.com/youtube/vitess/go/vt/servenv"
+	"github.com/youtube/vitess/go/vt/status"
 	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
 	"github.com/youtube/vitess/go/vt/topo"
 	"github.com/youtube/vitess/go/vt/topotools"
@@ -24,6 +25,7 @@ var (
 
 func init() {
 	servenv.RegisterDefaultFlags()
+	servenv.RegisterDefaultSecureFlags()
 	servenv.InitServiceMapForBsonRpcService("vtctl")
 }
 
@@ -61,6 +63,7 @@ func main() {
 
 	ts = topo.GetServer()
 	defer topo.CloseServers()
+	status.AddTopoStatusPart(ts)
 
 	actionRepo = NewActionRepository(ts)
 
@@ -72,7 +75,7 @@ func main() {
 
 	actionRepo.RegisterKeyspaceAction("ValidateSchemaKeyspace",
 		func(ctx context.Context, wr *wrangler.Wrangler, keyspace string, r *http.Request) (string, error) {
-			return "", wr.ValidateSchemaKeyspace(ctx, keyspace, nil, false)
+			return "", wr.ValidateSchemaKeyspace(ctx, keyspace, "", nil, false, false)
 		})
 
 	actionRepo.RegisterKeyspaceAction("ValidateVersionKeyspace",
@@ -11,7 +11,9 @@ import (
 	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/exit"
 	"github.com/youtube/vitess/go/vt/servenv"
+	"github.com/youtube/vitess/go/vt/status"
 	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/topocache"
 	"github.com/youtube/vitess/go/vt/vtgate"
 	"github.com/youtube/vitess/go/vt/vtgate/planbuilder"
 )
@@ -28,7 +30,7 @@ var (
 )
 
 var resilientSrvTopoServer *vtgate.ResilientSrvTopoServer
-var topoReader *TopoReader
+var topoReader *topocache.TopoReader
 
 func init() {
 	servenv.RegisterDefaultFlags()
@@ -81,9 +83,10 @@ startServer:
 	// For the initial phase vtgate is exposing
 	// topoReader api. This will be subsumed by
 	// vtgate once vtgate's client functions become active.
-	topoReader = NewTopoReader(resilientSrvTopoServer)
+	topoReader = topocache.NewTopoReader(resilientSrvTopoServer)
 	servenv.Register("toporeader", topoReader)
 
 	vtgate.Init(resilientSrvTopoServer, schema, *cell, *retryDelay, *retryCount, *connTimeoutTotal, *connTimeoutPerConn, *connLife, *maxInFlight)
+	status.AddTopoStatusPart(ts)
 	servenv.RunDefault()
 }
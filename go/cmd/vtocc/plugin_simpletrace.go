@@ -0,0 +1,11 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// This plugin imports simpletrace to record and propagate trace spans.
+
+import (
+	_ "github.com/youtube/vitess/go/trace/simpletrace"
+)
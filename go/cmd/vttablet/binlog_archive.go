@@ -0,0 +1,32 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/vt/mysqlctl"
+)
+
+var (
+	binlogArchiveDir       = flag.String("binlog_archive_dir", "", "if set, the complete binlogs are copied to this directory, for point in time recovery")
+	binlogArchiveInterval  = flag.Duration("binlog_archive_interval", time.Minute, "how often to look for binlogs to archive")
+	binlogArchiveKeepLocal = flag.Int("binlog_archive_keep_local", -1, "if not negative, the archived binlogs are purged locally, except for this many of the most recent ones, and the ones the replicas and binlog players of the shard still need")
+)
+
+func startBinlogArchiver() {
+	if *binlogArchiveDir == "" {
+		return
+	}
+	storage, err := mysqlctl.NewFileBinlogArchiveStorage(*binlogArchiveDir)
+	if err != nil {
+		log.Errorf("cannot start the binlog archiver: %v", err)
+		return
+	}
+	ba := mysqlctl.NewBinlogArchiver(agent.Mysqld, storage, *binlogArchiveKeepLocal, agent.BinlogReaderPositions)
+	go ba.Run(*binlogArchiveInterval, nil)
+}
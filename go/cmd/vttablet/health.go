@@ -2,18 +2,52 @@ package main
 
 import (
 	"flag"
+	"time"
 
+	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/vt/health"
 	"github.com/youtube/vitess/go/vt/mysqlctl"
 	"github.com/youtube/vitess/go/vt/tabletserver"
+	"github.com/youtube/vitess/go/vt/topo"
 )
 
 var (
 	enableReplicationLagCheck = flag.Bool("enable_replication_lag_check", false, "will register the mysql health check module that directly calls mysql")
+	enableHeartbeatLagCheck   = flag.Bool("enable_heartbeat_lag_check", false, "will register the health check module that reports the replication lag measured with the heartbeat, see -queryserver-config-heartbeat-interval")
+	enableMysqlAliveCheck     = flag.Bool("enable_mysql_alive_check", false, "will register the health check module that fails if mysql cannot be queried")
+	diskMonitorInterval       = flag.Duration("disk_monitor_interval", time.Minute, "how often to compute the disk usage of the mysql directories, 0 to disable")
+	snapshotRetention         = flag.Duration("snapshot_retention", 0, "if positive, snapshots older than this are removed by the disk monitor")
+	orphanMaxAge              = flag.Duration("orphan_max_age", 0, "if positive, the files left behind by failed snapshots and restores that are older than this are removed by the disk monitor, it must be at least 1h")
+	minFreeDiskSpaceRatio     = flag.Float64("min_free_disk_space_ratio", 0.0, "if positive, will register the health check module that fails if the free disk space ratio on the mysql data dir falls below this value")
 )
 
 func registerHealthReporter(qsc tabletserver.QueryServiceControl) {
 	if *enableReplicationLagCheck {
 		health.DefaultAggregator.Register("replication_reporter", mysqlctl.MySQLReplicationLag(agent.Mysqld))
 	}
+	if *enableHeartbeatLagCheck {
+		health.DefaultAggregator.Register("heartbeat_reporter", health.FunctionReporter(func(tabletType topo.TabletType, shouldQueryServiceBeRunning bool) (time.Duration, error) {
+			if !topo.IsSlaveType(tabletType) {
+				return 0, nil
+			}
+			return qsc.HeartbeatLag()
+		}))
+	}
+	if *enableMysqlAliveCheck {
+		health.DefaultAggregator.Register("mysql_alive_reporter", mysqlctl.MySQLAlive(agent.Mysqld))
+	}
+	if *minFreeDiskSpaceRatio > 0 {
+		health.DefaultAggregator.Register("disk_space_reporter", mysqlctl.DiskSpace(agent.Mysqld.Cnf().DataDir, *minFreeDiskSpaceRatio))
+	}
+}
+
+func startDiskMonitor() {
+	if *diskMonitorInterval <= 0 {
+		return
+	}
+	if *orphanMaxAge > 0 && *orphanMaxAge < mysqlctl.MinOrphanMaxAge {
+		log.Fatalf("-orphan_max_age must be at least %v, the files of a running snapshot or restore could be removed", mysqlctl.MinOrphanMaxAge)
+	}
+	dm := mysqlctl.NewDiskMonitor(agent.Mysqld, *snapshotRetention, *orphanMaxAge, agent.CanPurgeSnapshot, true)
+	go dm.Run(*diskMonitorInterval, nil)
 }
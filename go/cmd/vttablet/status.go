@@ -4,8 +4,10 @@ import (
 	"html/template"
 
 	"github.com/youtube/vitess/go/vt/health"
+	"github.com/youtube/vitess/go/vt/mysqlctl"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
 	"github.com/youtube/vitess/go/vt/servenv"
-	_ "github.com/youtube/vitess/go/vt/status"
+	"github.com/youtube/vitess/go/vt/status"
 	"github.com/youtube/vitess/go/vt/tabletmanager"
 	"github.com/youtube/vitess/go/vt/tabletserver"
 )
@@ -98,6 +100,36 @@ var (
   <dt><span class="unhealthy">unhealthy</span></dt>
   <dd>will not serve traffic.</dd>
 </dl>
+`
+
+	// typeTransitionsTemplate is the audit log of the tablet type changes
+	typeTransitionsTemplate = `
+<table>
+  <tr>
+    <th class="time">Time</th>
+    <th>Transition</th>
+  </tr>
+  {{range .}}
+  <tr class="{{.Class}}">
+    <td class="time">{{.Time.Format "Jan 2, 2006 at 15:04:05 (MST)"}}</td>
+    <td>{{.HTML}}</td>
+  </tr>
+  {{end}}
+</table>
+`
+
+	// replicationTemplate is about the MySQL replication of the tablet
+	replicationTemplate = `
+{{if .NotSlave}}
+MySQL is not replicating.
+{{else if .Error}}
+<span style="color:red">Cannot get the replication status: {{.Error}}</span>
+{{else}}{{with .Status}}
+Master: {{.MasterHost}}:{{.MasterPort}}<br>
+IO thread running: {{.SlaveIORunning}}, SQL thread running: {{.SlaveSQLRunning}}<br>
+Seconds behind master: {{.SecondsBehindMaster}}<br>
+Position: {{.Position}}<br>
+{{end}}{{end}}
 `
 
 	// binlogTemplate is about the binlog players
@@ -143,6 +175,24 @@ No binlog player is running.
 `
 )
 
+type replicationStatus struct {
+	NotSlave bool
+	Status   *myproto.ReplicationStatus
+	Error    string
+}
+
+func getReplicationStatus() *replicationStatus {
+	status, err := agent.MysqlDaemon.SlaveStatus()
+	switch err {
+	case nil:
+		return &replicationStatus{Status: status}
+	case mysqlctl.ErrNotSlave:
+		return &replicationStatus{NotSlave: true}
+	default:
+		return &replicationStatus{Error: err.Error()}
+	}
+}
+
 type healthStatus struct {
 	Records []interface{}
 	Config  template.HTML
@@ -188,10 +238,17 @@ func addStatusParts(qsc tabletserver.QueryServiceControl) {
 			}
 		})
 	}
+	servenv.AddStatusPart("Tablet Type Transitions", typeTransitionsTemplate, func() interface{} {
+		return agent.TypeTransitions.Records()
+	})
+	servenv.AddStatusPart("Replication", replicationTemplate, func() interface{} {
+		return getReplicationStatus()
+	})
 	qsc.AddStatusPart()
 	servenv.AddStatusPart("Binlog Player", binlogTemplate, func() interface{} {
 		return agent.BinlogPlayerMap.Status()
 	})
+	status.AddTopoStatusPart(agent.TopoServer)
 	if onStatusRegistered != nil {
 		onStatusRegistered()
 	}
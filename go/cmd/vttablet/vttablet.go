@@ -10,6 +10,7 @@ import (
 
 	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/exit"
+	"github.com/youtube/vitess/go/proc"
 	"github.com/youtube/vitess/go/vt/binlog"
 	"github.com/youtube/vitess/go/vt/dbconfigs"
 	"github.com/youtube/vitess/go/vt/mysqlctl"
@@ -106,12 +107,25 @@ func main() {
 	servenv.OnRun(func() {
 		addStatusParts(qsc)
 		registerHealthReporter(qsc)
+		startDiskMonitor()
+		startBinlogArchiver()
 	})
-	servenv.OnTerm(func() {
+	// The tablet keeps serving during the lameduck period, but it
+	// reports itself as unhealthy right away.
+	servenv.OnTerm(agent.EnterLameduck)
+	servenv.OnShutdown(servenv.ShutdownDrain, func(ctx context.Context) {
 		qsc.DisallowQueries()
 		binlog.DisableUpdateStreamService()
+	})
+	servenv.OnShutdown(servenv.ShutdownCheckpoint, func(ctx context.Context) {
+		// The binlog players stop between transactions, with
+		// their position saved in blp_checkpoint.
 		agent.Stop()
 	})
+	servenv.OnShutdown(servenv.ShutdownDeregister, agent.Deregister)
+	// The new process of a SIGUSR2 handover runs the binlog
+	// players and the master work, not both processes.
+	proc.OnHandover(agent.StopForHandover)
 	servenv.OnClose(func() {
 		// We will still use the topo server during lameduck period
 		// to update our state, so closing it in OnClose()
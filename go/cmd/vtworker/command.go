@@ -4,6 +4,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"net/http"
@@ -45,6 +46,11 @@ var commands = []commandGroup{
 		"Workers copying data for backups and clones",
 		[]command{},
 	},
+	commandGroup{
+		"Fixes",
+		"Workers repairing data",
+		[]command{},
+	},
 }
 
 func init() {
@@ -112,6 +118,13 @@ func runCommand(args []string) error {
 			case <-done:
 				log.Infof("Command is done:")
 				log.Info(wrk.StatusAsText())
+				if reporter, ok := wrk.(worker.Reporter); ok {
+					if data, err := json.MarshalIndent(reporter.Report(), "", "  "); err != nil {
+						log.Errorf("Cannot marshal the report: %v", err)
+					} else {
+						log.Infof("Report:\n%s", data)
+					}
+				}
 				if wrk.Error() != nil {
 					os.Exit(1)
 				}
@@ -73,6 +73,7 @@ const splitCloneHTML2 = `
       <li><b>populateBlpCheckpoint</b>: creates (if necessary) and populates the blp_checkpoint table in the destination. Required for filtered replication to start.</li>
       <li><b>dontStartBinlogPlayer</b>: (requires populateBlpCheckpoint) will setup, but not start binlog replication on the destination. The flag has to be manually cleared from the _vt.blp_checkpoint table.</li>
       <li><b>skipSetSourceShards</b>: we won't set SourceShards on the destination shards, disabling filtered replication. Useful for worker tests.</li>
+      <li><b>copyCheckpoints</b>: creates (if necessary) the copy_state table in the destination, records each copied table chunk in it, and resumes an interrupted copy from it.</li>
     </ul>
   </body>
 `
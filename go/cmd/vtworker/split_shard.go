@@ -0,0 +1,131 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/servenv"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/worker"
+	"github.com/youtube/vitess/go/vt/wrangler"
+)
+
+const (
+	defaultSplitShardStrategy          = "-populate_blp_checkpoint"
+	defaultMaxReplicationLag           = 10 * time.Second
+	defaultFilteredReplicationWaitTime = 30 * time.Second
+)
+
+const splitShardHTML = `
+<!DOCTYPE html>
+<head>
+  <title>Split Shard Action</title>
+</head>
+<body>
+  <h1>Split Shard Action</h1>
+    <form action="/Clones/SplitShard" method="post">
+      <LABEL for="keyspace">Keyspace: </LABEL>
+        <INPUT type="text" id="keyspace" name="keyspace" value=""></BR>
+      <LABEL for="shard">Shard: </LABEL>
+        <INPUT type="text" id="shard" name="shard" value=""></BR>
+      <LABEL for="destinations">Destination Shards: </LABEL>
+        <INPUT type="text" id="destinations" name="destinations" value="-80,80-"></BR>
+      <LABEL for="excludeTables">Exclude Tables: </LABEL>
+        <INPUT type="text" id="excludeTables" name="excludeTables" value=""></BR>
+      <LABEL for="strategy">Strategy: </LABEL>
+        <INPUT type="text" id="strategy" name="strategy" value="{{.DefaultStrategy}}"></BR>
+      <INPUT type="submit" name="submit" value="Split Shard"/>
+    </form>
+
+  <h1>Help</h1>
+    <p>Creates the destination shards, checks their tablets are provisioned, clones the data, waits for filtered replication, diffs the destination shards, and migrates the rdonly, replica and master traffic to them. The progress is saved in the source shard: if the action is interrupted or fails, run it again with the same shards to resume it.</p>
+  </body>
+`
+
+var splitShardTemplate = mustParseTemplate("splitShard", splitShardHTML)
+
+func commandSplitShard(wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) (worker.Worker, error) {
+	excludeTables := subFlags.String("exclude_tables", "", "comma separated list of tables to exclude")
+	strategy := subFlags.String("strategy", defaultSplitShardStrategy, "which strategy to use for restore, use 'mysqlctl multirestore -strategy=-help' for more info")
+	sourceReaderCount := subFlags.Int("source_reader_count", defaultSourceReaderCount, "number of concurrent streaming queries to use on the source")
+	destinationPackCount := subFlags.Int("destination_pack_count", defaultDestinationPackCount, "number of packets to pack in one destination insert")
+	minTableSizeForSplit := subFlags.Int("min_table_size_for_split", defaultMinTableSizeForSplit, "tables bigger than this size on disk in bytes will be split into source_reader_count chunks if possible")
+	destinationWriterCount := subFlags.Int("destination_writer_count", defaultDestinationWriterCount, "number of concurrent RPCs to execute on the destination")
+	maxReplicationLag := subFlags.Duration("max_replication_lag", defaultMaxReplicationLag, "filtered replication lag to wait for before the diffs")
+	filteredReplicationWaitTime := subFlags.Duration("filtered_replication_wait_time", defaultFilteredReplicationWaitTime, "maximum time to wait for filtered replication to catch up on the master migration")
+	subFlags.Parse(args)
+	if subFlags.NArg() != 2 {
+		return nil, fmt.Errorf("command SplitShard requires <keyspace/shard> <destination shards>")
+	}
+
+	keyspace, shard, err := topo.ParseKeyspaceShardString(subFlags.Arg(0))
+	if err != nil {
+		return nil, err
+	}
+	var excludeTableArray []string
+	if *excludeTables != "" {
+		excludeTableArray = strings.Split(*excludeTables, ",")
+	}
+	worker, err := worker.NewSplitShardWorker(wr, *cell, keyspace, shard, strings.Split(subFlags.Arg(1), ","), excludeTableArray, *strategy, *sourceReaderCount, *destinationPackCount, uint64(*minTableSizeForSplit), *destinationWriterCount, *maxReplicationLag, *filteredReplicationWaitTime)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create split shard worker: %v", err)
+	}
+	return worker, nil
+}
+
+func interactiveSplitShard(wr *wrangler.Wrangler, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		httpError(w, "cannot parse form: %s", err)
+		return
+	}
+
+	submitButtonValue := r.FormValue("submit")
+	if submitButtonValue == "" {
+		// display the input form
+		result := make(map[string]interface{})
+		result["DefaultStrategy"] = defaultSplitShardStrategy
+		executeTemplate(w, splitShardTemplate, result)
+		return
+	}
+
+	// Process input form.
+	keyspace := r.FormValue("keyspace")
+	shard := r.FormValue("shard")
+	destinations := r.FormValue("destinations")
+	if keyspace == "" || shard == "" || destinations == "" {
+		httpError(w, "keyspace, shard and destination shards are required", nil)
+		return
+	}
+	excludeTables := r.FormValue("excludeTables")
+	var excludeTableArray []string
+	if excludeTables != "" {
+		excludeTableArray = strings.Split(excludeTables, ",")
+	}
+
+	// start the workflow
+	wrk, err := worker.NewSplitShardWorker(wr, *cell, keyspace, shard, strings.Split(destinations, ","), excludeTableArray, r.FormValue("strategy"), defaultSourceReaderCount, defaultDestinationPackCount, defaultMinTableSizeForSplit, defaultDestinationWriterCount, defaultMaxReplicationLag, defaultFilteredReplicationWaitTime)
+	if err != nil {
+		httpError(w, "cannot create worker: %v", err)
+		return
+	}
+	if _, err := setAndStartWorker(wrk); err != nil {
+		httpError(w, "cannot set worker: %s", err)
+		return
+	}
+
+	http.Redirect(w, r, servenv.StatusURLPath(), http.StatusTemporaryRedirect)
+}
+
+func init() {
+	addCommand("Clones", command{"SplitShard",
+		commandSplitShard, interactiveSplitShard,
+		"[--exclude_tables=''] [--strategy='" + defaultSplitShardStrategy + "'] [--max_replication_lag=10s] [--filtered_replication_wait_time=30s] <keyspace/shard> <destination shards>",
+		"Splits a shard into the comma separated destination shards, and migrates its traffic to them. Resumes from its checkpoint when run again."})
+}
@@ -5,12 +5,14 @@
 package main
 
 import (
+	"encoding/json"
 	"html/template"
 	"net/http"
 	"strings"
 
 	"github.com/youtube/vitess/go/acl"
 	"github.com/youtube/vitess/go/vt/servenv"
+	"github.com/youtube/vitess/go/vt/worker"
 )
 
 const workerStatusPartHTML = servenv.JQueryIncludes + `
@@ -45,6 +47,9 @@ const workerStatusHTML = `
     {{.Logs}}
   </blockquote>
   {{if .Done}}
+  {{if .Report}}
+  <p><a href="/report">Job Report</a></p>
+  {{end}}
   <p><a href="/reset">Reset Job</a></p>
   {{else}}
   <p><a href="/cancel">Cancel Job</a></p>
@@ -77,6 +82,7 @@ func initStatusHandling() {
 			select {
 			case <-done:
 				data["Done"] = true
+				_, data["Report"] = wrk.(worker.Reporter)
 			default:
 			}
 			if logger != nil {
@@ -93,6 +99,37 @@ func initStatusHandling() {
 		return nil
 	})
 
+	// report handler, returns the report of a finished worker in JSON
+	http.HandleFunc("/report", func(w http.ResponseWriter, r *http.Request) {
+		if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		currentWorkerMutex.Lock()
+		wrk := currentWorker
+		done := currentDone
+		currentWorkerMutex.Unlock()
+
+		reporter, ok := wrk.(worker.Reporter)
+		if !ok {
+			http.Error(w, "the current worker has no report", http.StatusNotFound)
+			return
+		}
+		select {
+		case <-done:
+		default:
+			httpError(w, "worker still executing", nil)
+			return
+		}
+		data, err := json.MarshalIndent(reporter.Report(), "", "  ")
+		if err != nil {
+			httpError(w, "cannot marshal report: %s", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+
 	// reset handler
 	http.HandleFunc("/reset", func(w http.ResponseWriter, r *http.Request) {
 		if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
@@ -0,0 +1,130 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/youtube/vitess/go/vt/servenv"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/worker"
+	"github.com/youtube/vitess/go/vt/wrangler"
+)
+
+const tableFixHTML = `
+<!DOCTYPE html>
+<head>
+  <title>Table Fix Action</title>
+</head>
+<body>
+  <h1>Table Fix Action</h1>
+
+    {{if .Error}}
+      <b>Error:</b> {{.Error}}</br>
+    {{else}}
+      {{range $i, $si := .Shards}}
+        <li><a href="/Fixes/TableFix?keyspace={{$si.Keyspace}}&shard={{$si.Shard}}">{{$si.Keyspace}}/{{$si.Shard}}</a></li>
+      {{end}}
+    {{end}}
+</body>
+`
+const tableFixHTML2 = `
+<!DOCTYPE html>
+<head>
+  <title>Table Fix Action</title>
+</head>
+<body>
+  <p>Shard involved: {{.Keyspace}}/{{.Shard}}</p>
+  <h1>Table Fix Action</h1>
+    <form action="/Fixes/TableFix" method="post">
+      <LABEL for="tables">Tables: </LABEL>
+        <INPUT type="text" id="tables" name="tables" value=""></BR>
+      <INPUT type="hidden" name="keyspace" value="{{.Keyspace}}"/>
+      <INPUT type="hidden" name="shard" value="{{.Shard}}"/>
+      <INPUT type="submit" name="submit" value="Table Fix"/>
+    </form>
+
+  <h1>Help</h1>
+    <p>Compares the tables with the source shard, and fixes the rows that differ on the destination master. Filtered replication is paused on the destination master while the tables are fixed.</p>
+  </body>
+`
+
+var tableFixTemplate = mustParseTemplate("tableFix", tableFixHTML)
+var tableFixTemplate2 = mustParseTemplate("tableFix2", tableFixHTML2)
+
+func commandTableFix(wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) (worker.Worker, error) {
+	tables := subFlags.String("tables", "", "comma separated list of tables to fix")
+	subFlags.Parse(args)
+	if subFlags.NArg() != 1 {
+		return nil, fmt.Errorf("command TableFix requires <keyspace/shard>")
+	}
+	keyspace, shard, err := topo.ParseKeyspaceShardString(subFlags.Arg(0))
+	if err != nil {
+		return nil, err
+	}
+	if *tables == "" {
+		return nil, fmt.Errorf("command TableFix requires -tables")
+	}
+	return worker.NewTableFixWorker(wr, *cell, keyspace, shard, strings.Split(*tables, ",")), nil
+}
+
+func interactiveTableFix(wr *wrangler.Wrangler, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		httpError(w, "cannot parse form: %s", err)
+		return
+	}
+	keyspace := r.FormValue("keyspace")
+	shard := r.FormValue("shard")
+
+	if keyspace == "" || shard == "" {
+		// display the list of possible shards to chose from
+		result := make(map[string]interface{})
+		shards, err := shardsWithTablesSources(wr)
+		if err != nil {
+			result["Error"] = err.Error()
+		} else {
+			result["Shards"] = shards
+		}
+
+		executeTemplate(w, tableFixTemplate, result)
+		return
+	}
+
+	submitButtonValue := r.FormValue("submit")
+	if submitButtonValue == "" {
+		// display the input form
+		result := make(map[string]interface{})
+		result["Keyspace"] = keyspace
+		result["Shard"] = shard
+		executeTemplate(w, tableFixTemplate2, result)
+		return
+	}
+
+	// Process input form.
+	tables := r.FormValue("tables")
+	if tables == "" {
+		httpError(w, "no table to fix", nil)
+		return
+	}
+
+	// start the fix job
+	wrk := worker.NewTableFixWorker(wr, *cell, keyspace, shard, strings.Split(tables, ","))
+	if _, err := setAndStartWorker(wrk); err != nil {
+		httpError(w, "cannot set worker: %s", err)
+		return
+	}
+
+	http.Redirect(w, r, servenv.StatusURLPath(), http.StatusTemporaryRedirect)
+}
+
+func init() {
+	addCommand("Fixes", command{"TableFix",
+		commandTableFix, interactiveTableFix,
+		"--tables=<table1>,<table2>,... <keyspace/shard>",
+		"Fixes the rows of some tables of a destination shard that differ from its SourceShard, for a vertical split"})
+}
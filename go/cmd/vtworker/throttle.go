@@ -0,0 +1,100 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/youtube/vitess/go/acl"
+	"github.com/youtube/vitess/go/vt/worker"
+)
+
+var (
+	copyMaxReplicationLag = flag.Duration("copy_max_replication_lag", 0, "initial replication lag of the destination slaves above which a copy slows down its inserts (0 for no limit)")
+	copyMaxThreadsRunning = flag.Int("copy_max_threads_running", 0, "initial number of running threads of a destination master above which a copy slows down its inserts (0 for no limit)")
+	copyMaxInsertRate     = flag.Int("copy_max_insert_rate", 0, "initial maximum number of inserts per second a copy runs on each destination shard (0 for no limit)")
+)
+
+// setInitialThrottlerLimits applies the limits of the command line
+// to a worker that can be throttled.
+func setInitialThrottlerLimits(wrk worker.Worker) {
+	throttled, ok := wrk.(worker.Throttled)
+	if !ok {
+		return
+	}
+	if *copyMaxReplicationLag == 0 && *copyMaxThreadsRunning == 0 && *copyMaxInsertRate == 0 {
+		return
+	}
+	throttled.Throttler().SetLimits(worker.ThrottlerLimits{
+		MaxReplicationLag: *copyMaxReplicationLag,
+		MaxThreadsRunning: *copyMaxThreadsRunning,
+		MaxInsertRate:     *copyMaxInsertRate,
+	})
+}
+
+// initThrottleHandling installs the /throttle handler. Without
+// parameters it returns the throttler state of the current worker.
+// With any of the max_replication_lag, max_threads_running or
+// max_insert_rate parameters, it changes these limits first.
+func initThrottleHandling() {
+	http.HandleFunc("/throttle", func(w http.ResponseWriter, r *http.Request) {
+		if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			httpError(w, "cannot parse form: %s", err)
+			return
+		}
+
+		currentWorkerMutex.Lock()
+		wrk := currentWorker
+		currentWorkerMutex.Unlock()
+		throttled, ok := wrk.(worker.Throttled)
+		if !ok {
+			http.Error(w, "the current worker cannot be throttled", http.StatusBadRequest)
+			return
+		}
+		throttler := throttled.Throttler()
+
+		limits := throttler.Limits()
+		changed := false
+		if v := r.FormValue("max_replication_lag"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				httpError(w, "cannot parse max_replication_lag: %s", err)
+				return
+			}
+			limits.MaxReplicationLag = d
+			changed = true
+		}
+		if v := r.FormValue("max_threads_running"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				httpError(w, "cannot parse max_threads_running: %s", err)
+				return
+			}
+			limits.MaxThreadsRunning = n
+			changed = true
+		}
+		if v := r.FormValue("max_insert_rate"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				httpError(w, "cannot parse max_insert_rate: %s", err)
+				return
+			}
+			limits.MaxInsertRate = n
+			changed = true
+		}
+		if changed {
+			throttler.SetLimits(limits)
+		}
+		fmt.Fprintf(w, "%v\n", throttler)
+	})
+}
@@ -77,6 +77,7 @@ func setAndStartWorker(wrk worker.Worker) (chan struct{}, error) {
 	currentMemoryLogger = logutil.NewMemoryLogger()
 	currentDone = make(chan struct{})
 	wr.SetLogger(logutil.NewTeeLogger(currentMemoryLogger, logutil.NewConsoleLogger()))
+	setInitialThrottlerLimits(wrk)
 
 	// one go function runs the worker, closes 'done' when done
 	go func() {
@@ -114,6 +115,7 @@ func main() {
 	}
 	installSignalHandlers()
 	initStatusHandling()
+	initThrottleHandling()
 
 	servenv.RunDefault()
 }
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/exit"
@@ -21,7 +22,7 @@ import (
 var usage = `
 Commands:
 
-	init | start | shutdown | teardown
+	init | start | shutdown | teardown | wait
 `
 
 var (
@@ -29,7 +30,8 @@ var (
 		"zkid@server1:leaderPort1:electionPort1:clientPort1,...)")
 	myId = flag.Uint("zk.myid", 0,
 		"which server do you want to be? only needed when running multiple instance on one box, otherwise myid is implied by hostname")
-	force = flag.Bool("force", false, "force action, no prompting")
+	force    = flag.Bool("force", false, "force action, no prompting")
+	waitTime = flag.Duration("wait_time", 1*time.Minute, "how long to wait for the quorum to be formed, for the wait action")
 
 	stdin *bufio.Reader
 )
@@ -79,6 +81,8 @@ func main() {
 		err = zkd.Start()
 	case "teardown":
 		err = zkd.Teardown()
+	case "wait":
+		err = zkd.WaitForQuorum(*waitTime)
 	default:
 		log.Errorf("invalid action: %v", action)
 		exit.Return(1)
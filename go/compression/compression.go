@@ -0,0 +1,306 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package compression has the codecs that compress the snapshot
+// transfers and the RPC messages, and the negotiation of the codec
+// between the two ends of a connection.
+//
+// Each end has a list of the codecs it supports, in order of
+// preference. The client sends its list, and the server picks the
+// first codec of its own list that the client supports.
+//
+// The compressors and decompressors of this package export the bytes
+// they process and save, and the time they spend compressing.
+package compression
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/cgzip"
+	"github.com/youtube/vitess/go/stats"
+)
+
+// None is the name of the codec that doesn't compress.
+const None = "none"
+
+// Codec is a compression format.
+type Codec interface {
+	// Name is the name of the codec in the negotiation, and in
+	// the Content-Encoding of HTTP transfers.
+	Name() string
+
+	// NewWriter returns a writer that compresses to w. Closing
+	// it flushes the data, it doesn't close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+
+	// NewReader returns a reader that decompresses r. Closing it
+	// doesn't close r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	mu     sync.Mutex
+	codecs = make(map[string]Codec)
+
+	// The stats are per codec and direction, for instance
+	// "gzip.compress" or "gzip.decompress".
+	rawBytes        = stats.NewCounters("CompressionRawBytes")
+	compressedBytes = stats.NewCounters("CompressionCompressedBytes")
+	savedBytes      = stats.NewCounters("CompressionSavedBytes")
+	cpuTime         = stats.NewTimings("CompressionTime")
+)
+
+// Register makes a codec available. Codecs are registered by
+// init functions, for instance in plugins.
+func Register(codec Codec) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := codecs[codec.Name()]; ok {
+		log.Fatalf("compression codec %v is already registered", codec.Name())
+	}
+	codecs[codec.Name()] = codec
+}
+
+// Get returns the codec registered with the name, or nil.
+func Get(name string) Codec {
+	mu.Lock()
+	defer mu.Unlock()
+	return codecs[name]
+}
+
+// Names returns the names of the registered codecs, sorted.
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	result := make([]string, 0, len(codecs))
+	for name := range codecs {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ParseList returns the codecs of a comma-separated list of names.
+func ParseList(list string) ([]Codec, error) {
+	var result []Codec
+	for _, name := range splitList(list) {
+		codec := Get(name)
+		if codec == nil {
+			return nil, fmt.Errorf("unknown compression codec %q, the codecs are: %v", name, strings.Join(Names(), ", "))
+		}
+		result = append(result, codec)
+	}
+	return result, nil
+}
+
+// FormatList returns the comma-separated list of the codec names,
+// that the client sends to the server.
+func FormatList(list []Codec) string {
+	names := make([]string, len(list))
+	for i, codec := range list {
+		names[i] = codec.Name()
+	}
+	return strings.Join(names, ", ")
+}
+
+// Negotiate returns the first codec of local that is in the list
+// sent by the other end, or nil if there is none. The list has the
+// syntax of an HTTP Accept-Encoding header, the parameters of the
+// names are ignored.
+func Negotiate(local []Codec, remote string) Codec {
+	accepted := make(map[string]bool)
+	for _, name := range splitList(remote) {
+		accepted[name] = true
+	}
+	for _, codec := range local {
+		if accepted[codec.Name()] {
+			return codec
+		}
+	}
+	return nil
+}
+
+// splitList returns the names of a comma-separated list, without the
+// parameters after ';'.
+func splitList(list string) []string {
+	var result []string
+	for _, name := range strings.Split(list, ",") {
+		if i := strings.Index(name, ";"); i != -1 {
+			name = name[:i]
+		}
+		if name = strings.TrimSpace(name); name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// NewWriter returns a writer that compresses to w with the codec,
+// and exports stats. It must be closed to flush the data and the
+// stats.
+func NewWriter(codec Codec, w io.Writer) (io.WriteCloser, error) {
+	out := &timedWriter{writer: w}
+	writer, err := codec.NewWriter(out)
+	if err != nil {
+		return nil, err
+	}
+	return &statsWriter{codec: codec, writer: writer, out: out}, nil
+}
+
+// NewReader returns a reader that decompresses r with the codec,
+// and exports stats. It must be closed to flush the stats.
+func NewReader(codec Codec, r io.Reader) (io.ReadCloser, error) {
+	in := &timedReader{reader: r}
+	reader, err := codec.NewReader(in)
+	if err != nil {
+		return nil, err
+	}
+	return &statsReader{codec: codec, reader: reader, in: in}, nil
+}
+
+// timedWriter counts the bytes and the time spent writing to the
+// underlying writer, so it is not counted as compression time.
+type timedWriter struct {
+	writer  io.Writer
+	bytes   int64
+	elapsed time.Duration
+}
+
+func (tw *timedWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := tw.writer.Write(p)
+	tw.elapsed += time.Now().Sub(start)
+	tw.bytes += int64(n)
+	return n, err
+}
+
+type statsWriter struct {
+	codec   Codec
+	writer  io.WriteCloser
+	out     *timedWriter
+	bytes   int64
+	elapsed time.Duration
+}
+
+func (sw *statsWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := sw.writer.Write(p)
+	sw.elapsed += time.Now().Sub(start)
+	sw.bytes += int64(n)
+	return n, err
+}
+
+func (sw *statsWriter) Close() error {
+	start := time.Now()
+	err := sw.writer.Close()
+	sw.elapsed += time.Now().Sub(start)
+	recordStats(sw.codec.Name()+".compress", sw.bytes, sw.out.bytes, sw.elapsed-sw.out.elapsed)
+	return err
+}
+
+// timedReader counts the bytes and the time spent reading from the
+// underlying reader, so it is not counted as decompression time.
+type timedReader struct {
+	reader  io.Reader
+	bytes   int64
+	elapsed time.Duration
+}
+
+func (tr *timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := tr.reader.Read(p)
+	tr.elapsed += time.Now().Sub(start)
+	tr.bytes += int64(n)
+	return n, err
+}
+
+type statsReader struct {
+	codec   Codec
+	reader  io.ReadCloser
+	in      *timedReader
+	bytes   int64
+	elapsed time.Duration
+	closed  bool
+}
+
+func (sr *statsReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := sr.reader.Read(p)
+	sr.elapsed += time.Now().Sub(start)
+	sr.bytes += int64(n)
+	return n, err
+}
+
+func (sr *statsReader) Close() error {
+	if sr.closed {
+		return nil
+	}
+	sr.closed = true
+	recordStats(sr.codec.Name()+".decompress", sr.bytes, sr.in.bytes, sr.elapsed-sr.in.elapsed)
+	return sr.reader.Close()
+}
+
+func recordStats(key string, raw, compressed int64, elapsed time.Duration) {
+	rawBytes.Add(key, raw)
+	compressedBytes.Add(key, compressed)
+	savedBytes.Add(key, raw-compressed)
+	cpuTime.Add(key, elapsed)
+}
+
+// noneCodec doesn't compress.
+type noneCodec struct{}
+
+func (noneCodec) Name() string {
+	return None
+}
+
+func (noneCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+func (noneCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return nopReadCloser{r}, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+type nopReadCloser struct {
+	io.Reader
+}
+
+func (nopReadCloser) Close() error {
+	return nil
+}
+
+// gzipCodec uses cgzip, which is much faster than compress/gzip.
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string {
+	return "gzip"
+}
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return cgzip.NewWriterLevel(w, cgzip.Z_BEST_SPEED)
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return cgzip.NewReader(r)
+}
+
+func init() {
+	Register(noneCodec{})
+	Register(gzipCodec{})
+}
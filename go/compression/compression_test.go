@@ -0,0 +1,80 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compression
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	local, err := ParseList("gzip, none")
+	if err != nil {
+		t.Fatalf("ParseList failed: %v", err)
+	}
+	table := []struct {
+		remote, want string
+	}{
+		{"gzip", "gzip"},
+		{"snappy, gzip;q=0.5", "gzip"},
+		{"none, gzip", "gzip"},
+		{"none", "none"},
+		{"snappy", ""},
+		{"", ""},
+	}
+	for _, test := range table {
+		got := ""
+		if codec := Negotiate(local, test.remote); codec != nil {
+			got = codec.Name()
+		}
+		if got != test.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", test.remote, got, test.want)
+		}
+	}
+
+	if _, err := ParseList("gzip,unknown"); err == nil {
+		t.Errorf("ParseList accepted an unknown codec")
+	}
+	if got := FormatList(local); got != "gzip, none" {
+		t.Errorf("FormatList = %q", got)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("vitess "), 10000)
+	for _, name := range Names() {
+		codec := Get(name)
+		saved := savedBytes.Counts()[name+".compress"]
+
+		compressed := new(bytes.Buffer)
+		writer, err := NewWriter(codec, compressed)
+		if err != nil {
+			t.Fatalf("%v: NewWriter failed: %v", name, err)
+		}
+		if _, err := writer.Write(data); err != nil {
+			t.Fatalf("%v: Write failed: %v", name, err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("%v: Close failed: %v", name, err)
+		}
+		if got, want := savedBytes.Counts()[name+".compress"]-saved, int64(len(data)-compressed.Len()); got != want {
+			t.Errorf("%v: saved %v bytes, want %v", name, got, want)
+		}
+
+		reader, err := NewReader(codec, compressed)
+		if err != nil {
+			t.Fatalf("%v: NewReader failed: %v", name, err)
+		}
+		got, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("%v: ReadAll failed: %v", name, err)
+		}
+		reader.Close()
+		if !bytes.Equal(got, data) {
+			t.Errorf("%v: got %v bytes back, want %v", name, len(got), len(data))
+		}
+	}
+}
@@ -4,6 +4,7 @@ package flagutil
 
 import (
 	_ "flag"
+	"fmt"
 	"sort"
 	"strings"
 )
@@ -68,6 +69,9 @@ func (value *StringMapValue) Set(v string) error {
 	pairs := parseListWithEscapes(v, ',')
 	for _, pair := range pairs {
 		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid key:value pair %q", pair)
+		}
 		dict[parts[0]] = parts[1]
 	}
 	*value = dict
@@ -68,3 +68,10 @@ func TestStringMap(t *testing.T) {
 		}
 	}
 }
+
+func TestStringMapInvalid(t *testing.T) {
+	v := StringMapValue(nil)
+	if err := v.Set("tag1:value1,tag2"); err == nil {
+		t.Errorf("v.Set(tag1:value1,tag2) should have failed")
+	}
+}
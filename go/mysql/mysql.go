@@ -29,6 +29,9 @@ const (
 	// NOTE(szopa): maxSize used to be 1 << 30, but that causes
 	// compiler errors in some situations.
 	maxSize = 1 << 20
+
+	// binaryCharsetNumber is the number of the binary character set.
+	binaryCharsetNumber = 63
 )
 
 func init() {
@@ -196,6 +199,13 @@ func (conn *Connection) Fields() (fields []proto.Field) {
 		fname := (*[maxSize]byte)(unsafe.Pointer(cfields[i].name))[:length]
 		fields[i].Name = string(fname)
 		fields[i].Type = int64(cfields[i]._type)
+		// MySQL also sets BINARY_FLAG for the binary collations
+		// of the text columns, only the binary charset tells
+		// them apart.
+		fields[i].Flags = int64(cfields[i].flags) &^ proto.VT_BINARY_FLAG
+		if cfields[i].charsetnr == binaryCharsetNumber {
+			fields[i].Flags |= proto.VT_BINARY_FLAG
+		}
 	}
 	return fields
 }
@@ -217,6 +227,19 @@ func (conn *Connection) fetchAll() (rows [][]sqltypes.Value, err error) {
 
 // FetchNext returns the next row for a query
 func (conn *Connection) FetchNext() (row []sqltypes.Value, err error) {
+	return conn.fetchNext(func(n, size int) ([]sqltypes.Value, []byte) {
+		return make([]sqltypes.Value, n), make([]byte, 0, size)
+	})
+}
+
+// FetchNextInto is part of the sqldb.RowFetcher interface.
+func (conn *Connection) FetchNextInto(rb *sqldb.RowBuffer) (row []sqltypes.Value, err error) {
+	return conn.fetchNext(rb.NewRow)
+}
+
+// fetchNext returns the next row for a query, in the memory returned
+// by alloc for its n values and their size bytes.
+func (conn *Connection) fetchNext(alloc func(n, size int) ([]sqltypes.Value, []byte)) (row []sqltypes.Value, err error) {
 	vtrow := C.vt_fetch_next(&conn.c)
 	if vtrow.has_error != 0 {
 		return nil, conn.lastError("")
@@ -227,13 +250,12 @@ func (conn *Connection) FetchNext() (row []sqltypes.Value, err error) {
 	}
 	colCount := int(conn.c.num_fields)
 	cfields := (*[maxSize]C.MYSQL_FIELD)(unsafe.Pointer(conn.c.fields))
-	row = make([]sqltypes.Value, colCount)
 	lengths := (*[maxSize]uint64)(unsafe.Pointer(vtrow.lengths))
 	totalLength := uint64(0)
 	for i := 0; i < colCount; i++ {
 		totalLength += lengths[i]
 	}
-	arena := make([]byte, 0, int(totalLength))
+	row, arena := alloc(colCount, int(totalLength))
 	for i := 0; i < colCount; i++ {
 		colLength := lengths[i]
 		colPtr := rowPtr[i]
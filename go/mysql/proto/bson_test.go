@@ -13,8 +13,9 @@ import (
 )
 
 type reflectField struct {
-	Name string
-	Type int64
+	Name  string
+	Type  int64
+	Flags int64
 }
 
 type extraQueryResult struct {
@@ -26,9 +27,9 @@ type extraQueryResult struct {
 }
 
 func TestQueryResult(t *testing.T) {
-	want := "\x85\x00\x00\x00\x04Fields\x00*\x00\x00\x00\x030\x00\"\x00\x00\x00\x05Name\x00\x04\x00\x00\x00\x00name\x12Type\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00?RowsAffected\x00\x02\x00\x00\x00\x00\x00\x00\x00?InsertId\x00\x03\x00\x00\x00\x00\x00\x00\x00\x04Rows\x00 \x00\x00\x00\x040\x00\x18\x00\x00\x00\x050\x00\x01\x00\x00\x00\x001\x051\x00\x02\x00\x00\x00\x00aa\x00\x00\x00"
+	want := "\x94\x00\x00\x00\x04Fields\x009\x00\x00\x00\x030\x001\x00\x00\x00\x05Name\x00\x04\x00\x00\x00\x00name\x12Type\x00\x01\x00\x00\x00\x00\x00\x00\x00\x12Flags\x00\x80\x00\x00\x00\x00\x00\x00\x00\x00\x00?RowsAffected\x00\x02\x00\x00\x00\x00\x00\x00\x00?InsertId\x00\x03\x00\x00\x00\x00\x00\x00\x00\x04Rows\x00 \x00\x00\x00\x040\x00\x18\x00\x00\x00\x050\x00\x01\x00\x00\x00\x001\x051\x00\x02\x00\x00\x00\x00aa\x00\x00\x00"
 	custom := QueryResult{
-		Fields:       []Field{{"name", 1}},
+		Fields:       []Field{{"name", 1, 128}},
 		RowsAffected: 2,
 		InsertId:     3,
 		Rows: [][]sqltypes.Value{
@@ -61,6 +62,9 @@ func TestQueryResult(t *testing.T) {
 	if custom.Fields[0].Type != unmarshalled.Fields[0].Type {
 		t.Errorf("want %v, got %#v", custom.Fields[0].Type, unmarshalled.Fields[0].Type)
 	}
+	if custom.Fields[0].Flags != unmarshalled.Fields[0].Flags {
+		t.Errorf("want %v, got %#v", custom.Fields[0].Flags, unmarshalled.Fields[0].Flags)
+	}
 	if !bytes.Equal(custom.Rows[0][0].Raw(), unmarshalled.Rows[0][0].Raw()) {
 		t.Errorf("want %s, got %s", custom.Rows[0][0].Raw(), unmarshalled.Rows[0][0].Raw())
 	}
@@ -5,6 +5,8 @@
 package proto
 
 import (
+	"bytes"
+	"fmt"
 	"testing"
 
 	"github.com/youtube/vitess/go/bson"
@@ -29,7 +31,7 @@ var testcases = []TestCase{
 				{Name: "foo", Type: 1},
 			},
 		},
-		encoded: "i\x00\x00\x00\x04Fields\x00)\x00\x00\x00\x030\x00!\x00\x00\x00\x05Name\x00\x03\x00\x00\x00\x00foo\x12Type\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00?RowsAffected\x00\x00\x00\x00\x00\x00\x00\x00\x00?InsertId\x00\x00\x00\x00\x00\x00\x00\x00\x00\x04Rows\x00\x05\x00\x00\x00\x00\x00",
+		encoded: "x\x00\x00\x00\x04Fields\x008\x00\x00\x00\x030\x000\x00\x00\x00\x05Name\x00\x03\x00\x00\x00\x00foo\x12Type\x00\x01\x00\x00\x00\x00\x00\x00\x00\x12Flags\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00?RowsAffected\x00\x00\x00\x00\x00\x00\x00\x00\x00?InsertId\x00\x00\x00\x00\x00\x00\x00\x00\x00\x04Rows\x00\x05\x00\x00\x00\x00\x00",
 	},
 	// Only rows, no fields
 	{
@@ -126,3 +128,49 @@ func compare(t *testing.T, caseno int, original, newqr QueryResult) {
 mismatch:
 	t.Errorf("mismatch on %d:\n%v\n%v", caseno, original, newqr)
 }
+
+func TestUnmarshalShareBuffer(t *testing.T) {
+	qr := QueryResult{
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeString([]byte("abcd"))},
+		},
+	}
+	encoded, err := bson.Marshal(&qr)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var newqr QueryResult
+	if err := bson.Unmarshal(encoded, &newqr); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	// the values are not copied out of the encoded buffer
+	copy(encoded[bytes.Index(encoded, []byte("abcd")):], "wxyz")
+	if got := newqr.Rows[0][0].String(); got != "wxyz" {
+		t.Errorf("decoded value doesn't share the buffer: %v", got)
+	}
+}
+
+func BenchmarkUnmarshalLargeResult(b *testing.B) {
+	qr := QueryResult{
+		Fields: []Field{{Name: "id", Type: 8}, {Name: "name", Type: 253}, {Name: "value", Type: 5}},
+	}
+	for i := 0; i < 1000; i++ {
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			sqltypes.MakeNumeric([]byte(fmt.Sprintf("%v", i))),
+			sqltypes.MakeString(bytes.Repeat([]byte("x"), 100)),
+			sqltypes.MakeFractional([]byte("1.234")),
+		})
+	}
+	encoded, err := bson.Marshal(&qr)
+	if err != nil {
+		b.Fatalf("Marshal failed: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var newqr QueryResult
+		if err := bson.Unmarshal(encoded, &newqr); err != nil {
+			b.Fatalf("Unmarshal failed: %v", err)
+		}
+	}
+}
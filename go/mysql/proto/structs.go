@@ -41,10 +41,21 @@ const (
 	VT_GEOMETRY    = 255
 )
 
-// Field describes a column returned by MySQL.
+// These numbers should exactly match the column flags defined in
+// dist/mysql-5.1.52/include/mysql/mysql_com.h
+const (
+	VT_NOT_NULL_FLAG = 1
+	VT_UNSIGNED_FLAG = 32
+	VT_BINARY_FLAG   = 128
+)
+
+// Field describes a column returned by MySQL. Flags are the MySQL
+// column flags, VT_BINARY_FLAG is only set for the columns of the
+// binary character set, i.e. the ones that don't hold text.
 type Field struct {
-	Name string
-	Type int64
+	Name  string
+	Type  int64
+	Flags int64
 }
 
 //go:generate bsongen -file $GOFILE -type Field -o field_bson.go
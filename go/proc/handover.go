@@ -0,0 +1,246 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proc
+
+// This file handles the handover of the listening sockets to a new
+// version of the binary. When the process receives SIGUSR2, it
+// starts a copy of itself (using the current binary on disk, with
+// the same arguments), and passes it its listening sockets as extra
+// file descriptors. The new process accepts connections on them
+// right away, while this one goes through its lameduck period and
+// exits. No connection is refused during the upgrade. Once the new
+// process reports it is serving, this one closes its listeners, so
+// the new connections only go to the new process.
+//
+// The background work that can't run in two processes at once,
+// like filtered replication, is stopped by the OnHandover hooks
+// before the new process starts.
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/golang/glog"
+)
+
+// inheritedListenersEnv is the environment variable that describes
+// the listening sockets passed to a new process. It is a comma
+// separated list of port:fd.
+const inheritedListenersEnv = "VT_INHERITED_LISTENERS"
+
+// handoverReadyEnv is the environment variable with the file
+// descriptor a new process writes handoverReady to, once it serves
+// on the listeners it inherited.
+const handoverReadyEnv = "VT_HANDOVER_READY_FD"
+
+const handoverReady = "ready"
+
+var (
+	listenersMu sync.Mutex
+	// listeners has the sockets to hand over, by port.
+	listeners = make(map[string]*net.TCPListener)
+	// inherited has the file descriptors passed by our
+	// predecessor, by port. Each one is used once.
+	inherited map[string]uintptr
+	// readyFile is where we tell our predecessor we're serving.
+	readyFile *os.File
+
+	handoverHooksMu sync.Mutex
+	handoverHooks   []func()
+)
+
+// OnHandover registers f to be run on SIGUSR2, right before the new
+// process is started. It should stop the background work that keeps
+// state, so it only runs in the new process. The hooks are not
+// undone: if the new process can't be started, this one shuts down.
+func OnHandover(f func()) {
+	handoverHooksMu.Lock()
+	defer handoverHooksMu.Unlock()
+	handoverHooks = append(handoverHooks, f)
+}
+
+func fireHandoverHooks() {
+	handoverHooksMu.Lock()
+	defer handoverHooksMu.Unlock()
+	for _, f := range handoverHooks {
+		f()
+	}
+}
+
+func init() {
+	var err error
+	inherited, err = parseInheritedListeners(os.Getenv(inheritedListenersEnv))
+	if err != nil {
+		log.Errorf("ignoring invalid %v: %v", inheritedListenersEnv, err)
+	}
+	if value := os.Getenv(handoverReadyEnv); value != "" {
+		fd, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			log.Errorf("ignoring invalid %v: %v", handoverReadyEnv, err)
+		} else {
+			readyFile = os.NewFile(uintptr(fd), "handover-ready")
+		}
+	}
+	// Our own children shouldn't think they inherit anything.
+	os.Unsetenv(inheritedListenersEnv)
+	os.Unsetenv(handoverReadyEnv)
+}
+
+// notifyReady tells our predecessor, if we have one, that we serve
+// on the listeners it handed over, so it can close its own.
+func notifyReady() {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	if readyFile == nil {
+		return
+	}
+	if _, err := readyFile.Write([]byte(handoverReady)); err != nil {
+		log.Errorf("cannot tell our predecessor we're serving: %v", err)
+	}
+	readyFile.Close()
+	readyFile = nil
+}
+
+// closeListenersWhenReady waits for our successor to report it is
+// serving, then closes our listeners. The connections we already
+// accepted are still served during our lameduck period.
+func closeListenersWhenReady(r io.ReadCloser) {
+	defer r.Close()
+	buf := make([]byte, len(handoverReady))
+	if _, err := io.ReadFull(r, buf); err != nil || string(buf) != handoverReady {
+		log.Warningf("new process didn't report it is serving (%v), still accepting connections", err)
+		return
+	}
+
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	for port, l := range listeners {
+		log.Infof("New process is serving, closing our listener on port %v", port)
+		l.Close()
+	}
+	listeners = make(map[string]*net.TCPListener)
+}
+
+func parseInheritedListeners(value string) (map[string]uintptr, error) {
+	result := make(map[string]uintptr)
+	if value == "" {
+		return result, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid port:fd pair %q", pair)
+		}
+		fd, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fd in %q: %v", pair, err)
+		}
+		result[parts[0]] = uintptr(fd)
+	}
+	return result, nil
+}
+
+// InheritOrListen returns the listening socket for the tcp port
+// passed by our predecessor on a handover, or creates a new one.
+// Either way, the socket will be passed on to our successor on the
+// next handover.
+func InheritOrListen(port string) (net.Listener, error) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+
+	var l net.Listener
+	var err error
+	if fd, ok := inherited[port]; ok {
+		delete(inherited, port)
+		l, err = fileListener(fd, port)
+		if err == nil {
+			log.Infof("Inherited listener on port %v", port)
+		}
+	} else {
+		l, err = net.Listen("tcp", ":"+port)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tl, ok := l.(*net.TCPListener); ok {
+		listeners[port] = tl
+	}
+	return l, nil
+}
+
+// isInherited returns true if our predecessor passed us a listening
+// socket for the port.
+func isInherited(port string) bool {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	_, ok := inherited[port]
+	return ok
+}
+
+func fileListener(fd uintptr, port string) (net.Listener, error) {
+	f := os.NewFile(fd, fmt.Sprintf("listener:%v", port))
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// handover runs the OnHandover hooks, starts a new copy of the
+// process, and passes it our listening sockets. stopped is true if
+// the hooks ran, even if the new process then failed to start.
+func handover() (stopped bool, err error) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+
+	if len(listeners) == 0 {
+		return false, fmt.Errorf("no listener to hand over")
+	}
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	var pairs []string
+	for port, l := range listeners {
+		f, err := l.File()
+		if err != nil {
+			return false, fmt.Errorf("cannot get the socket of port %v: %v", port, err)
+		}
+		// ExtraFiles start at fd 3 in the new process.
+		pairs = append(pairs, fmt.Sprintf("%v:%v", port, 3+len(files)))
+		files = append(files, f)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return false, fmt.Errorf("cannot create the ready pipe: %v", err)
+	}
+	readyFD := 3 + len(files)
+	files = append(files, w)
+
+	cmd := exec.Command(os.Args[0], os.Args[1:]...)
+	cmd.Env = append(os.Environ(),
+		inheritedListenersEnv+"="+strings.Join(pairs, ","),
+		fmt.Sprintf("%v=%v", handoverReadyEnv, readyFD))
+	cmd.ExtraFiles = files
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	log.Infof("Stopping the background work before the handover")
+	fireHandoverHooks()
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		return true, fmt.Errorf("cannot start %v: %v", os.Args[0], err)
+	}
+	log.Infof("Handed over listeners %v to new process %v", strings.Join(pairs, ","), cmd.Process.Pid)
+	go closeListenersWhenReady(r)
+	return true, nil
+}
@@ -24,29 +24,54 @@ import (
 const pidURL = "/debug/pid"
 
 // Listen tries to create a listener on the specified tcp port.
-// Before creating the listener, it checks to see if there is another
-// server already using the port. If there is one, it sends a USR1
-// signal requesting the server to shutdown, and then attempts to
-// to create the listener.
+// If our predecessor handed over its listener for the port, it is
+// used directly. Otherwise, before creating the listener, it checks
+// to see if there is another server already using the port. If there
+// is one, it sends a USR1 signal requesting the server to shutdown,
+// and then attempts to to create the listener.
 func Listen(port string) (l net.Listener, err error) {
-	killPredecessor(port)
+	if !isInherited(port) {
+		killPredecessor(port)
+	}
 	return listen(port)
 }
 
 // Wait creates an HTTP handler on pidURL, and serves the current process
-// pid on it. It then creates a signal handler and waits for SIGTERM or
-// SIGUSR1, and returns when the signal is received. A new server that comes
-// up will query this URL. If it receives a valid response, it will send a
-// SIGUSR1 signal and attempt to bind to the port the current server is using.
+// pid on it. It then creates a signal handler and waits for SIGTERM,
+// SIGUSR1 or SIGUSR2, and returns when the signal is received. A new server
+// that comes up will query this URL. If it receives a valid response, it will
+// send a SIGUSR1 signal and attempt to bind to the port the current server is
+// using. On SIGUSR2, the current server first starts a new version of itself
+// and hands over its listeners to it. If that fails before the OnHandover
+// hooks run, it keeps running. If it fails after, it returns SIGTERM, since
+// its background work is stopped. A process started by a handover tells its
+// predecessor when it calls Wait, so Wait must be called once the process
+// serves on all its listeners.
 func Wait() os.Signal {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGTERM, syscall.SIGUSR1)
+	signal.Notify(c, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2)
 
 	http.HandleFunc(pidURL, func(r http.ResponseWriter, req *http.Request) {
 		r.Write(strconv.AppendInt(nil, int64(os.Getpid()), 10))
 	})
+	notifyReady()
 
-	return <-c
+	for {
+		sig := <-c
+		if sig != syscall.SIGUSR2 {
+			return sig
+		}
+		stopped, err := handover()
+		if err != nil {
+			if stopped {
+				log.Errorf("handover failed after stopping the background work, shutting down: %v", err)
+				return syscall.SIGTERM
+			}
+			log.Errorf("handover failed, still running: %v", err)
+			continue
+		}
+		return sig
+	}
 }
 
 // ListenAndServe combines Listen and Wait to also run an http
@@ -91,7 +116,7 @@ func killPredecessor(port string) {
 
 func listen(port string) (l net.Listener, err error) {
 	for i := 0; i < 100; i++ {
-		l, err = net.Listen("tcp", ":"+port)
+		l, err = InheritOrListen(port)
 		if err != nil {
 			if strings.Contains(err.Error(), "already in use") {
 				time.Sleep(1 * time.Millisecond)
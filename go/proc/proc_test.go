@@ -127,3 +127,216 @@ func testServer(t *testing.T, want syscall.Signal) {
 		t.Errorf("want %v, got %v", want, got)
 	}
 }
+
+func TestHandover(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	switch os.Getenv("SERVER_NUM") {
+	case "":
+		testHandoverLaunch(t)
+	case "handover":
+		// The first server hands over its listener on SIGUSR2,
+		// the one that inherits it stops on SIGTERM. The latter
+		// writes its pid to PIDFILE, so the test can always kill it.
+		if isInherited(os.Getenv("PORT")) {
+			if err := ioutil.WriteFile(os.Getenv("PIDFILE"), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+				t.Fatalf("could not write pid file: %v", err)
+			}
+			testServer(t, syscall.SIGTERM)
+		} else {
+			OnHandover(func() {
+				if err := ioutil.WriteFile(os.Getenv("HOOKFILE"), []byte("stopped"), 0644); err != nil {
+					t.Errorf("could not write hook file: %v", err)
+				}
+			})
+			testServer(t, syscall.SIGUSR2)
+		}
+	}
+}
+
+func testHandoverLaunch(t *testing.T) {
+	l, err := net.Listen("tcp", "")
+	if err != nil {
+		t.Fatalf("could not initialize listener: %v", err)
+	}
+	hostport := l.Addr().String()
+	l.Close()
+	_, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pidFile, err := ioutil.TempFile("", "proc_handover_pid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pidFile.Close()
+	defer os.Remove(pidFile.Name())
+	hookFile, err := ioutil.TempFile("", "proc_handover_hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hookFile.Close()
+	defer os.Remove(hookFile.Name())
+	// The successor is not our child, so we find it by its pid
+	// file, and make sure it doesn't outlive the test.
+	defer func() {
+		data, err := ioutil.ReadFile(pidFile.Name())
+		if err != nil || len(data) == 0 {
+			return
+		}
+		if pid, err := strconv.Atoi(string(data)); err == nil {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHandover$")
+	cmd.Env = []string{
+		"SERVER_NUM=handover",
+		fmt.Sprintf("PORT=%s", port),
+		fmt.Sprintf("PIDFILE=%s", pidFile.Name()),
+		fmt.Sprintf("HOOKFILE=%s", hookFile.Name()),
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	testPid(t, port, cmd.Process.Pid)
+
+	if err := syscall.Kill(cmd.Process.Pid, syscall.SIGUSR2); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Error(err)
+	}
+	if data, err := ioutil.ReadFile(hookFile.Name()); err != nil || string(data) != "stopped" {
+		t.Errorf("OnHandover hook didn't run before the handover: %q, %v", data, err)
+	}
+
+	// The new server serves on the same socket, the port was
+	// never closed. It may not serve its pid yet though.
+	pid := 0
+	for i := 0; i < 20 && pid == 0; i++ {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%s%s", port, pidURL))
+		if err != nil {
+			t.Fatalf("new server is not serving: %v", err)
+		}
+		num, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("could not read pid: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		if pid, err = strconv.Atoi(string(num)); err != nil {
+			t.Fatalf("could not read pid: %v", err)
+		}
+	}
+	if pid == 0 {
+		t.Fatalf("new server never served its pid")
+	}
+	if pid == cmd.Process.Pid {
+		t.Fatalf("old server %v is still serving", pid)
+	}
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHandoverWithoutListeners(t *testing.T) {
+	listenersMu.Lock()
+	saved := listeners
+	listeners = make(map[string]*net.TCPListener)
+	listenersMu.Unlock()
+	defer func() {
+		listenersMu.Lock()
+		listeners = saved
+		listenersMu.Unlock()
+	}()
+	handoverHooksMu.Lock()
+	savedHooks := handoverHooks
+	handoverHooksMu.Unlock()
+	defer func() {
+		handoverHooksMu.Lock()
+		handoverHooks = savedHooks
+		handoverHooksMu.Unlock()
+	}()
+
+	ran := false
+	OnHandover(func() { ran = true })
+	if stopped, err := handover(); stopped || err == nil {
+		t.Errorf("handover() = %v, %v, want false, error", stopped, err)
+	}
+	if ran {
+		t.Errorf("OnHandover hook ran without a handover")
+	}
+}
+
+func TestCloseListenersWhenReady(t *testing.T) {
+	listenersMu.Lock()
+	saved := listeners
+	listeners = make(map[string]*net.TCPListener)
+	listenersMu.Unlock()
+	defer func() {
+		listenersMu.Lock()
+		listeners = saved
+		listenersMu.Unlock()
+	}()
+
+	l, err := InheritOrListen("0")
+	if err != nil {
+		t.Fatalf("InheritOrListen failed: %v", err)
+	}
+	defer l.Close()
+
+	// A successor that dies before serving doesn't close our listeners.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	closeListenersWhenReady(r)
+	listenersMu.Lock()
+	if len(listeners) != 1 {
+		t.Errorf("listeners were closed without a ready successor: %v", listeners)
+	}
+	listenersMu.Unlock()
+
+	r, w, err = os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(handoverReady))
+	w.Close()
+	closeListenersWhenReady(r)
+	if _, err := l.Accept(); err == nil {
+		t.Errorf("listener still accepts connections after the successor is ready")
+	}
+}
+
+func TestParseInheritedListeners(t *testing.T) {
+	got, err := parseInheritedListeners("")
+	if err != nil || len(got) != 0 {
+		t.Errorf("parseInheritedListeners(\"\") = %v, %v, want empty", got, err)
+	}
+
+	got, err = parseInheritedListeners("15001:3,15002:4")
+	if err != nil {
+		t.Fatalf("parseInheritedListeners failed: %v", err)
+	}
+	if len(got) != 2 || got["15001"] != 3 || got["15002"] != 4 {
+		t.Errorf("parseInheritedListeners = %v, want 15001:3 and 15002:4", got)
+	}
+
+	for _, value := range []string{"15001", "15001:x", "15001:3,"} {
+		if _, err := parseInheritedListeners(value); err == nil {
+			t.Errorf("parseInheritedListeners(%q) should have failed", value)
+		}
+	}
+}
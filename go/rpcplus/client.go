@@ -8,12 +8,15 @@ import (
 	"bufio"
 	"encoding/gob"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
 	"reflect"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/youtube/vitess/go/trace"
 	"golang.org/x/net/context"
@@ -27,18 +30,74 @@ func (e ServerError) Error() string {
 	return string(e)
 }
 
+// CodedError is implemented by the errors that have a numeric code.
+// When a method returns one, the code is sent to the client with the
+// error message, and the client gets it back as a CodedServerError.
+type CodedError interface {
+	error
+	ErrorCode() int64
+}
+
+// CodedServerError is a ServerError that came with a code.
+type CodedServerError struct {
+	Code int64
+	Err  string
+}
+
+func (e *CodedServerError) Error() string {
+	return e.Err
+}
+
+// ErrorCode is part of the CodedError interface.
+func (e *CodedServerError) ErrorCode() int64 {
+	return e.Code
+}
+
 // ErrShutdown holds the specific error for closing/closed connections
 var ErrShutdown = errors.New("connection is shut down")
 
+const frameTooLargePrefix = "rpc: frame too large"
+
+// FrameTooLargeError is returned by codecs that limit the size of
+// the messages they read or write, for a message over the limit. The
+// message is skipped, and the connection can still be used.
+type FrameTooLargeError struct {
+	Size int
+	Max  int
+}
+
+func (e *FrameTooLargeError) Error() string {
+	return fmt.Sprintf("%v: %v bytes, the maximum is %v", frameTooLargePrefix, e.Size, e.Max)
+}
+
+// IsFrameTooLarge returns true if err is a FrameTooLargeError, or
+// the error sent by a server that failed to send a reply because of
+// one.
+func IsFrameTooLarge(err error) bool {
+	switch err := err.(type) {
+	case *FrameTooLargeError:
+		return true
+	case ServerError:
+		return strings.Contains(string(err), frameTooLargePrefix)
+	case *CodedServerError:
+		return strings.Contains(err.Err, frameTooLargePrefix)
+	}
+	return false
+}
+
 // Call represents an active RPC.
 type Call struct {
-	ServiceMethod string      // The name of the service and method to call.
-	Args          interface{} // The argument to the function (*struct).
-	Reply         interface{} // The reply from the function (*struct for single, chan * struct for streaming).
-	Error         error       // After completion, the error status.
-	Done          chan *Call  // Strobes when call is complete (nil for streaming RPCs)
-	Stream        bool        // True for a streaming RPC call, false otherwise
-	Subseq        uint64      // The next expected subseq in the packets
+	ServiceMethod string        // The name of the service and method to call.
+	Args          interface{}   // The argument to the function (*struct).
+	Reply         interface{}   // The reply from the function (*struct for single, chan * struct for streaming).
+	Error         error         // After completion, the error status.
+	Done          chan *Call    // Strobes when call is complete (nil for streaming RPCs)
+	Stream        bool          // True for a streaming RPC call, false otherwise
+	Subseq        uint64        // The next expected subseq in the packets
+	trace         string        // The encoded trace span sent with the request
+	timeout       time.Duration // The time left before the caller's deadline, sent with the request
+	seq           uint64        // The sequence number assigned by send
+	finished      chan struct{} // Closed when the call is done, if the caller's context can be canceled
 }
 
 // Client represents an RPC Client.
@@ -86,12 +145,15 @@ func (client *Client) send(call *Call) {
 	}
 	seq := client.seq
 	client.seq++
+	call.seq = seq
 	client.pending[seq] = call
 	client.mutex.Unlock()
 
 	// Encode and send the request.
 	client.request.Seq = seq
 	client.request.ServiceMethod = call.ServiceMethod
+	client.request.Trace = call.trace
+	client.request.Timeout = call.timeout
 	err := client.codec.WriteRequest(&client.request, call.Args)
 	if err != nil {
 		client.mutex.Lock()
@@ -137,7 +199,11 @@ func (client *Client) input() {
 			// We've got an error response. Give this to the request;
 			// any subsequent requests will get the ReadResponseBody
 			// error if there is one.
-			if !(call.Stream && response.Error == lastStreamResponseError) {
+			if call.Stream && call.Error != nil {
+				// keep the error we already found locally
+			} else if response.ErrorCode != 0 {
+				call.Error = &CodedServerError{Code: response.ErrorCode, Err: response.Error}
+			} else if !(call.Stream && response.Error == lastStreamResponseError) {
 				call.Error = ServerError(response.Error)
 			}
 			err = client.codec.ReadResponseBody(nil)
@@ -145,12 +211,24 @@ func (client *Client) input() {
 				err = errors.New("reading error payload: " + err.Error())
 			}
 			client.done(seq)
+		case call.Stream && call.Error != nil:
+			// The stream already failed on our side, and the
+			// server was asked to stop: drop the replies.
+			err = client.codec.ReadResponseBody(nil)
+			if err != nil {
+				err = errors.New("reading body: " + err.Error())
+			}
 		case call.Stream:
 			// call.Reply is a chan *T2
 			// we need to create a T2 and get a *T2 back
 			value := reflect.New(reflect.TypeOf(call.Reply).Elem().Elem()).Interface()
 			err = client.codec.ReadResponseBody(value)
-			if err != nil {
+			if tooLarge, ok := err.(*FrameTooLargeError); ok {
+				// The reply was skipped, the connection is fine.
+				call.Error = tooLarge
+				err = nil
+				go client.cancel(call, tooLarge)
+			} else if err != nil {
 				call.Error = errors.New("reading body " + err.Error())
 			} else {
 				// writing on the channel could block forever. For
@@ -161,7 +239,11 @@ func (client *Client) input() {
 			}
 		default:
 			err = client.codec.ReadResponseBody(call.Reply)
-			if err != nil {
+			if tooLarge, ok := err.(*FrameTooLargeError); ok {
+				// The reply was skipped, the connection is fine.
+				call.Error = tooLarge
+				err = nil
+			} else if err != nil {
 				call.Error = errors.New("reading body " + err.Error())
 			}
 			client.done(seq)
@@ -194,7 +276,50 @@ func (client *Client) done(seq uint64) {
 	}
 }
 
+// cancel tells the server to abandon call. A regular call is
+// completed right away with err. A streaming call is completed when
+// the server sends its final response, so the reply channel is
+// only ever closed by input.
+func (client *Client) cancel(call *Call, err error) {
+	client.sending.Lock()
+	defer client.sending.Unlock()
+
+	client.mutex.Lock()
+	if client.shutdown || client.pending[call.seq] != call {
+		// already done
+		client.mutex.Unlock()
+		return
+	}
+	if !call.Stream {
+		delete(client.pending, call.seq)
+	}
+	client.mutex.Unlock()
+
+	// The server may not know about cancel frames, so it is
+	// sent without a method: old servers will just reply with
+	// an error for the call.
+	if werr := client.codec.WriteRequest(&Request{Seq: call.seq, Cancel: true}, invalidRequest); werr != nil {
+		log.Println("rpc: sending cancel:", werr)
+	}
+	if !call.Stream {
+		call.Error = err
+		call.done()
+	}
+}
+
+// watch cancels call if ctx is done before the call is.
+func (client *Client) watch(ctx context.Context, call *Call) {
+	select {
+	case <-ctx.Done():
+		client.cancel(call, ctx.Err())
+	case <-call.finished:
+	}
+}
+
 func (call *Call) done() {
+	if call.finished != nil {
+		close(call.finished)
+	}
 	if call.Stream {
 		// need to close the channel. Client won't be able to read any more.
 		reflect.ValueOf(call.Reply).Close()
@@ -316,6 +441,22 @@ func (client *Client) Close() error {
 	return client.codec.Close()
 }
 
+// IsShutdown returns true if the client can't be used anymore,
+// because it was closed or its connection failed.
+func (client *Client) IsShutdown() bool {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.shutdown || client.closing
+}
+
+// PendingCalls returns the number of calls waiting for a reply,
+// including running streaming calls.
+func (client *Client) PendingCalls() int {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return len(client.pending)
+}
+
 // Go invokes the function asynchronously.  It returns the Call structure representing
 // the invocation.  The done channel will signal when the call is complete by returning
 // the same Call object.  If done is nil, Go will allocate a new channel.
@@ -327,6 +468,7 @@ func (client *Client) Go(ctx context.Context, serviceMethod string, args interfa
 
 	call := new(Call)
 	call.ServiceMethod = serviceMethod
+	call.trace = trace.EncodeSpan(trace.NewContext(ctx, span))
 	call.Args = args
 	call.Reply = reply
 	if done == nil {
@@ -341,13 +483,43 @@ func (client *Client) Go(ctx context.Context, serviceMethod string, args interfa
 		}
 	}
 	call.Done = done
-	client.send(call)
+	client.start(ctx, call)
 	return call
 }
 
+// start sends call, propagating the deadline and cancellation of ctx
+// to the server.
+func (client *Client) start(ctx context.Context, call *Call) {
+	if err := ctx.Err(); err != nil {
+		call.Error = err
+		call.done()
+		return
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		call.timeout = deadline.Sub(time.Now())
+	}
+	if ctx.Done() != nil {
+		call.finished = make(chan struct{})
+		go client.watch(ctx, call)
+	}
+	client.send(call)
+}
+
 // StreamGo invokes the streaming function asynchronously.  It returns the Call structure representing
 // the invocation.
+// The replies are sent on replyStream as they are read. A reader
+// that falls behind stops the reading of the connection, and so
+// the writes of the server: the frames in flight are bounded by the
+// capacity of replyStream and the socket buffers.
 func (client *Client) StreamGo(serviceMethod string, args interface{}, replyStream interface{}) *Call {
+	return client.StreamGoWithContext(context.Background(), serviceMethod, args, replyStream)
+}
+
+// StreamGoWithContext is like StreamGo, but the deadline of ctx is
+// sent to the server, and the server is asked to stop streaming
+// when ctx is done. The reply channel is closed once the server
+// acknowledges it.
+func (client *Client) StreamGoWithContext(ctx context.Context, serviceMethod string, args interface{}, replyStream interface{}) *Call {
 	// first check the replyStream object is a stream of pointers to a data structure
 	typ := reflect.TypeOf(replyStream)
 	// FIXME: check the direction of the channel, maybe?
@@ -362,7 +534,7 @@ func (client *Client) StreamGo(serviceMethod string, args interface{}, replyStre
 	call.Reply = replyStream
 	call.Stream = true
 	call.Subseq = 0
-	client.send(call)
+	client.start(ctx, call)
 	return call
 }
 
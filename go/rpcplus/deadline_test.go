@@ -0,0 +1,155 @@
+package rpcplus
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type Waiter struct {
+	started chan struct{}
+	ended   chan error
+}
+
+// Deadline returns the time left before the context deadline, or -1.
+func (w *Waiter) Deadline(ctx context.Context, args int, reply *time.Duration) error {
+	*reply = -1
+	if deadline, ok := ctx.Deadline(); ok {
+		*reply = deadline.Sub(time.Now())
+	}
+	return nil
+}
+
+// Wait blocks until the context is done.
+func (w *Waiter) Wait(ctx context.Context, args int, reply *int) error {
+	w.started <- struct{}{}
+	<-ctx.Done()
+	w.ended <- ctx.Err()
+	return ctx.Err()
+}
+
+// Stream sends replies until it is asked to stop.
+func (w *Waiter) Stream(ctx context.Context, args int, sendReply func(reply interface{}) error) error {
+	for i := 0; ; i++ {
+		if err := sendReply(&i); err != nil {
+			w.ended <- err
+			return err
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func makeWaiterLink(t *testing.T) (*Waiter, *Client) {
+	waiter := &Waiter{
+		started: make(chan struct{}, 1),
+		ended:   make(chan error, 1),
+	}
+	server := NewServer()
+	if err := server.Register(waiter); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	l, addr := listenTCP()
+	go server.Accept(l)
+
+	client, err := Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	return waiter, client
+}
+
+func waitEnded(t *testing.T, waiter *Waiter, want error) {
+	select {
+	case err := <-waiter.ended:
+		if err != want {
+			t.Errorf("server side ended with %v, want %v", err, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("server side still running")
+	}
+}
+
+func TestDeadlinePropagation(t *testing.T) {
+	_, client := makeWaiterLink(t)
+	defer client.Close()
+
+	var left time.Duration
+	if err := client.Call(context.Background(), "Waiter.Deadline", 0, &left); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if left != -1 {
+		t.Errorf("got a deadline without one: %v left", left)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Call(ctx, "Waiter.Deadline", 0, &left); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if left <= 5*time.Second || left > 10*time.Second {
+		t.Errorf("unexpected time left on the server: %v", left)
+	}
+}
+
+func TestDeadlineExceeded(t *testing.T) {
+	waiter, client := makeWaiterLink(t)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var reply int
+	if err := client.Call(ctx, "Waiter.Wait", 0, &reply); err != context.DeadlineExceeded {
+		t.Errorf("Call returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	<-waiter.started
+	waitEnded(t, waiter, context.DeadlineExceeded)
+
+	// a call with an expired context is never sent
+	if err := client.Call(ctx, "Waiter.Wait", 0, &reply); err != context.DeadlineExceeded {
+		t.Errorf("Call returned %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCancelCall(t *testing.T) {
+	waiter, client := makeWaiterLink(t)
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var reply int
+	call := client.Go(ctx, "Waiter.Wait", 0, &reply, nil)
+	<-waiter.started
+	cancel()
+	<-call.Done
+	if call.Error != context.Canceled {
+		t.Errorf("call returned %v, want %v", call.Error, context.Canceled)
+	}
+	waitEnded(t, waiter, context.Canceled)
+
+	// the connection is still usable
+	var left time.Duration
+	if err := client.Call(context.Background(), "Waiter.Deadline", 0, &left); err != nil {
+		t.Errorf("Call after cancel failed: %v", err)
+	}
+}
+
+func TestCancelStream(t *testing.T) {
+	waiter, client := makeWaiterLink(t)
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	replies := make(chan *int, 10)
+	call := client.StreamGoWithContext(ctx, "Waiter.Stream", 0, replies)
+	for i := 0; i < 3; i++ {
+		if _, ok := <-replies; !ok {
+			t.Fatalf("stream ended early: %v", call.Error)
+		}
+	}
+	cancel()
+	for _ = range replies {
+	}
+	if call.Error == nil || call.Error.Error() != context.Canceled.Error() {
+		t.Errorf("stream ended with %v, want %v", call.Error, context.Canceled)
+	}
+	waitEnded(t, waiter, context.Canceled)
+}
@@ -50,6 +50,13 @@ The Call method waits for the remote call to complete while the Go method
 launches the call asynchronously and signals completion using the Call
 structure's Done channel. The StreamGo method is always asynchronous.
 
+The deadline of the context passed to Call and Go (or StreamGoWithContext)
+is sent with the request, and the server runs the method with a context
+bounded by it. When the client context is done before the call, the client
+sends a cancel frame for it, and the server cancels the context of the
+method. Streaming methods are also stopped by their sendReply function.
+It is up to other methods to watch their context.
+
 Unless an explicit codec is set up, package encoding/gob is used to
 transport the data.
 
@@ -128,16 +135,20 @@ import (
 	"bufio"
 	"encoding/gob"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
 	"reflect"
+	rtdebug "runtime/debug"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 	"unicode/utf8"
 
+	"github.com/youtube/vitess/go/trace"
 	"golang.org/x/net/context"
 )
 
@@ -178,9 +189,12 @@ type service struct {
 // but documented here as an aid to debugging, such as when analyzing
 // network traffic.
 type Request struct {
-	ServiceMethod string   // format: "Service.Method"
-	Seq           uint64   // sequence number chosen by client
-	next          *Request // for free list in Server
+	ServiceMethod string        // format: "Service.Method"
+	Seq           uint64        // sequence number chosen by client
+	Trace         string        // encoded trace span of the client, if any
+	Timeout       time.Duration // time left before the client's deadline, 0 if none
+	Cancel        bool          // true if this cancels the in-flight call Seq
+	next          *Request      // for free list in Server
 }
 
 // Response is a header written before every RPC return. It is used internally
@@ -190,6 +204,7 @@ type Response struct {
 	ServiceMethod string    // echoes that of the Request
 	Seq           uint64    // echoes that of the request
 	Error         string    // error, if any.
+	ErrorCode     int64     // code of the error, if it has one
 	next          *Response // for free list in Server
 }
 
@@ -377,12 +392,13 @@ func (server *Server) register(rcvr interface{}, name string, useName bool) erro
 // contains an error when it is used.
 var invalidRequest = struct{}{}
 
-func (server *Server) sendResponse(sending *sync.Mutex, req *Request, reply interface{}, codec ServerCodec, errmsg string, last bool) (err error) {
+func (server *Server) sendResponse(sending *sync.Mutex, req *Request, reply interface{}, codec ServerCodec, errmsg string, errcode int64, last bool) (err error) {
 	resp := server.getResponse()
 	// Encode the response header
 	resp.ServiceMethod = req.ServiceMethod
 	if errmsg != "" {
 		resp.Error = errmsg
+		resp.ErrorCode = errcode
 		reply = invalidRequest
 	}
 	resp.Seq = req.Seq
@@ -396,6 +412,14 @@ func (server *Server) sendResponse(sending *sync.Mutex, req *Request, reply inte
 	return err
 }
 
+// errorCode returns the code of err if it is a CodedError, 0 otherwise.
+func errorCode(err error) int64 {
+	if coded, ok := err.(CodedError); ok {
+		return coded.ErrorCode()
+	}
+	return 0
+}
+
 func (m *methodType) NumCalls() (n uint) {
 	m.Lock()
 	n = m.numCalls
@@ -410,22 +434,43 @@ func (s *service) call(ctx context.Context, server *Server, sending *sync.Mutex,
 	function := mtype.method.Func
 	var returnValues []reflect.Value
 
+	// Continue the trace of the client, if any.
+	ctx = trace.NewContextFromEncodedSpan(ctx, req.Trace)
+	span := trace.NewSpanFromContext(ctx)
+	span.StartServer(req.ServiceMethod)
+	defer span.Finish()
+	ctx = trace.NewContext(ctx, span)
+
+	// Don't start work the client has already given up on.
+	if err := ctx.Err(); err != nil {
+		server.sendResponse(sending, req, invalidRequest, codec, err.Error(), 0, true)
+		server.freeRequest(req)
+		return
+	}
+
 	if !mtype.stream {
 
 		// Invoke the method, providing a new value for the reply.
 		if mtype.TakesContext() {
-			returnValues = function.Call([]reflect.Value{s.rcvr, mtype.prepareContext(ctx), argv, replyv})
+			returnValues = invoke(req.ServiceMethod, function, []reflect.Value{s.rcvr, mtype.prepareContext(ctx), argv, replyv})
 		} else {
-			returnValues = function.Call([]reflect.Value{s.rcvr, argv, replyv})
+			returnValues = invoke(req.ServiceMethod, function, []reflect.Value{s.rcvr, argv, replyv})
 		}
 
 		// The return value for the method is an error.
 		errInter := returnValues[0].Interface()
 		errmsg := ""
+		var errcode int64
 		if errInter != nil {
 			errmsg = errInter.(error).Error()
+			errcode = errorCode(errInter.(error))
+		}
+		if err := server.sendResponse(sending, req, replyv.Interface(), codec, errmsg, errcode, true); err != nil {
+			if _, ok := err.(*FrameTooLargeError); ok {
+				// the reply was not sent, tell the client why
+				server.sendResponse(sending, req, invalidRequest, codec, err.Error(), 0, true)
+			}
 		}
-		server.sendResponse(sending, req, replyv.Interface(), codec, errmsg, true)
 		server.freeRequest(req)
 		return
 	}
@@ -443,6 +488,12 @@ func (s *service) call(ctx context.Context, server *Server, sending *sync.Mutex,
 			return lastError
 		}
 
+		// the client went away or the deadline expired, stop streaming
+		if err := ctx.Err(); err != nil {
+			lastError = err
+			return lastError
+		}
+
 		// check the oneReply has the right type using reflection
 		typ := reflect.TypeOf(oneReply)
 		if firstType == nil {
@@ -456,7 +507,7 @@ func (s *service) call(ctx context.Context, server *Server, sending *sync.Mutex,
 			}
 		}
 
-		lastError = server.sendResponse(sending, req, oneReply, codec, "", false)
+		lastError = server.sendResponse(sending, req, oneReply, codec, "", 0, false)
 		if lastError != nil {
 			return lastError
 		}
@@ -467,18 +518,21 @@ func (s *service) call(ctx context.Context, server *Server, sending *sync.Mutex,
 
 	// Invoke the method, providing a new value for the reply.
 	if mtype.TakesContext() {
-		returnValues = function.Call([]reflect.Value{s.rcvr, mtype.prepareContext(ctx), argv, reflect.ValueOf(sendReply)})
+		returnValues = invoke(req.ServiceMethod, function, []reflect.Value{s.rcvr, mtype.prepareContext(ctx), argv, reflect.ValueOf(sendReply)})
 	} else {
-		returnValues = function.Call([]reflect.Value{s.rcvr, argv, reflect.ValueOf(sendReply)})
+		returnValues = invoke(req.ServiceMethod, function, []reflect.Value{s.rcvr, argv, reflect.ValueOf(sendReply)})
 	}
 	errInter := returnValues[0].Interface()
 	errmsg := ""
+	var errcode int64
 	if errInter != nil {
 		// the function returned an error, we use that
 		errmsg = errInter.(error).Error()
+		errcode = errorCode(errInter.(error))
 	} else if lastError != nil {
 		// we had an error inside sendReply, we use that
 		errmsg = lastError.Error()
+		errcode = errorCode(lastError)
 	} else {
 		// no error, we send the special EOS error
 		errmsg = lastStreamResponseError
@@ -487,10 +541,24 @@ func (s *service) call(ctx context.Context, server *Server, sending *sync.Mutex,
 	// this is the last packet, we don't do anything with
 	// the error here (well sendStreamResponse will log it
 	// already)
-	server.sendResponse(sending, req, nil, codec, errmsg, true)
+	server.sendResponse(sending, req, nil, codec, errmsg, errcode, true)
 	server.freeRequest(req)
 }
 
+// invoke calls the function of a method. A panic in the method is
+// logged and returned as its error, so one bad request doesn't take
+// the whole server down.
+func invoke(serviceMethod string, function reflect.Value, args []reflect.Value) (returnValues []reflect.Value) {
+	defer func() {
+		if x := recover(); x != nil {
+			log.Printf("rpc: panic in %v: %v\n%s", serviceMethod, x, rtdebug.Stack())
+			err := fmt.Errorf("rpc: uncaught panic in %v: %v", serviceMethod, x)
+			returnValues = []reflect.Value{reflect.ValueOf(&err).Elem()}
+		}
+	}()
+	return function.Call(args)
+}
+
 type gobServerCodec struct {
 	rwc    io.ReadWriteCloser
 	dec    *gob.Decoder
@@ -547,6 +615,7 @@ func (server *Server) ServeCodec(codec ServerCodec) {
 // to pass a connection context to the RPC methods.
 func (server *Server) ServeCodecWithContext(ctx context.Context, codec ServerCodec) {
 	sending := new(sync.Mutex)
+	calls := newInflightCalls()
 	for {
 		service, mtype, req, argv, replyv, keepReading, err := server.readRequest(codec)
 		if err != nil {
@@ -558,16 +627,83 @@ func (server *Server) ServeCodecWithContext(ctx context.Context, codec ServerCod
 			}
 			// send a response if we actually managed to read a header.
 			if req != nil {
-				server.sendResponse(sending, req, invalidRequest, codec, err.Error(), true)
+				server.sendResponse(sending, req, invalidRequest, codec, err.Error(), 0, true)
 				server.freeRequest(req)
 			}
 			continue
 		}
-		go service.call(ctx, server, sending, mtype, req, argv, replyv, codec)
-	}
+		if req.Cancel {
+			calls.cancel(req.Seq)
+			server.freeRequest(req)
+			continue
+		}
+		seq := req.Seq
+		callCtx := calls.start(ctx, seq, req.Timeout)
+		go func() {
+			service.call(callCtx, server, sending, mtype, req, argv, replyv, codec)
+			calls.finish(seq)
+		}()
+	}
+	// the connection is gone, nobody is waiting for the results anymore
+	calls.cancelAll()
 	codec.Close()
 }
 
+// inflightCalls tracks the calls running on a single connection, so
+// they can be canceled by the client or when the connection goes away.
+type inflightCalls struct {
+	mu      sync.Mutex
+	cancels map[uint64]context.CancelFunc
+}
+
+func newInflightCalls() *inflightCalls {
+	return &inflightCalls{cancels: make(map[uint64]context.CancelFunc)}
+}
+
+// start returns the context for call seq, bounded by timeout if
+// it is not zero.
+func (ic *inflightCalls) start(ctx context.Context, seq uint64, timeout time.Duration) context.Context {
+	var cancel context.CancelFunc
+	if timeout != 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	ic.mu.Lock()
+	ic.cancels[seq] = cancel
+	ic.mu.Unlock()
+	return ctx
+}
+
+// finish releases the context of call seq once it has returned.
+func (ic *inflightCalls) finish(seq uint64) {
+	ic.mu.Lock()
+	cancel, ok := ic.cancels[seq]
+	delete(ic.cancels, seq)
+	ic.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// cancel cancels the context of call seq, if it is still running.
+func (ic *inflightCalls) cancel(seq uint64) {
+	ic.mu.Lock()
+	cancel, ok := ic.cancels[seq]
+	ic.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+func (ic *inflightCalls) cancelAll() {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	for _, cancel := range ic.cancels {
+		cancel()
+	}
+}
+
 func (m *methodType) prepareContext(ctx context.Context) reflect.Value {
 	if contextv := reflect.ValueOf(ctx); contextv.IsValid() {
 		return contextv
@@ -592,11 +728,21 @@ func (server *Server) ServeRequestWithContext(ctx context.Context, codec ServerC
 		}
 		// send a response if we actually managed to read a header.
 		if req != nil {
-			server.sendResponse(sending, req, invalidRequest, codec, err.Error(), true)
+			server.sendResponse(sending, req, invalidRequest, codec, err.Error(), 0, true)
 			server.freeRequest(req)
 		}
 		return err
 	}
+	if req.Cancel {
+		// nothing is running concurrently on this codec
+		server.freeRequest(req)
+		return nil
+	}
+	if req.Timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+		defer cancel()
+	}
 	service.call(ctx, server, sending, mtype, req, argv, replyv, codec)
 	return nil
 }
@@ -651,6 +797,11 @@ func (server *Server) readRequest(codec ServerCodec) (service *service, mtype *m
 		codec.ReadRequestBody(nil)
 		return
 	}
+	if req.Cancel {
+		// a cancel frame has no useful body
+		err = codec.ReadRequestBody(nil)
+		return
+	}
 
 	// Decode the argument value.
 	argIsValue := false // if true, need to indirect before calling.
@@ -690,6 +841,9 @@ func (server *Server) readRequestHeader(codec ServerCodec) (service *service, mt
 	// We read the header successfully. If we see an error now,
 	// we can still recover and move on to the next request.
 	keepReading = true
+	if req.Cancel {
+		return
+	}
 
 	serviceMethod := strings.Split(req.ServiceMethod, ".")
 	if len(serviceMethod) != 2 {
@@ -188,6 +188,21 @@ func testRPC(ctx context.Context, t *testing.T, addr string) {
 		t.Errorf("Mul: expected %d got %d", mulReply.C, args.A*args.B)
 	}
 
+	// Panic test: the panic is returned as an error, and the
+	// server keeps serving
+	args = &Args{7, 8}
+	reply = new(Reply)
+	err = client.Call(ctx, "Arith.Error", args, reply)
+	if err == nil {
+		t.Error("Error: expected error")
+	} else if err.Error() != "rpc: uncaught panic in Arith.Error: ERROR" {
+		t.Error("Error: expected uncaught panic error; got", err)
+	}
+	err = client.Call(ctx, "Arith.Add", args, reply)
+	if err != nil {
+		t.Errorf("Add after panic: expected no error but got string %q", err.Error())
+	}
+
 	// Error test
 	args = &Args{7, 0}
 	reply = new(Reply)
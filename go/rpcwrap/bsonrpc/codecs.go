@@ -6,13 +6,16 @@
 package bsonrpc
 
 import (
+	"bytes"
 	"crypto/tls"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"time"
 
 	"github.com/youtube/vitess/go/bson"
 	"github.com/youtube/vitess/go/bytes2"
+	"github.com/youtube/vitess/go/compression"
 	rpc "github.com/youtube/vitess/go/rpcplus"
 	"github.com/youtube/vitess/go/rpcwrap"
 )
@@ -24,12 +27,27 @@ const (
 // ClientCodec holds required parameters for providing a client codec for
 // bsonrpc
 type ClientCodec struct {
-	rwc io.ReadWriteCloser
+	rwc          io.ReadWriteCloser
+	maxFrameSize int
+	compressor   *compressor
+	// message is where the body of the current response is read
+	// from: rwc, or the decompressed message.
+	message io.Reader
 }
 
 // NewClientCodec creates a new client codec for bsonrpc communication
 func NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
-	return &ClientCodec{conn}
+	return &ClientCodec{rwc: conn, message: conn}
+}
+
+// SetMaxFrameSize is part of the rpcwrap.FrameSizeLimiter interface.
+func (cc *ClientCodec) SetMaxFrameSize(maxFrameSize int) {
+	cc.maxFrameSize = maxFrameSize
+}
+
+// SetCompression is part of the rpcwrap.Compressor interface.
+func (cc *ClientCodec) SetCompression(codec compression.Codec, minSize int) {
+	cc.compressor = &compressor{codec: codec, minSize: minSize}
 }
 
 // DefaultBufferSize holds the default value for buffer size
@@ -41,21 +59,35 @@ func (cc *ClientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
 	if err := bson.MarshalToBuffer(buf, &RequestBson{r}); err != nil {
 		return err
 	}
+	headerLen := buf.Len()
 	if err := bson.MarshalToBuffer(buf, body); err != nil {
 		return err
 	}
+	if err := checkFrameSize(buf.Len()-headerLen, cc.maxFrameSize); err != nil {
+		return err
+	}
+	if cc.compressor != nil {
+		return cc.compressor.writeMessage(cc.rwc, buf)
+	}
 	_, err := buf.WriteTo(cc.rwc)
 	return err
 }
 
 // ReadResponseHeader reads the header of server response
 func (cc *ClientCodec) ReadResponseHeader(r *rpc.Response) error {
-	return bson.UnmarshalFromStream(cc.rwc, &ResponseBson{r})
+	if cc.compressor != nil {
+		message, err := cc.compressor.readMessage(cc.rwc, cc.maxFrameSize)
+		if err != nil {
+			return err
+		}
+		cc.message = message
+	}
+	return unmarshalFrame(cc.message, &ResponseBson{r}, cc.maxFrameSize)
 }
 
 // ReadResponseBody reads the body of server response
 func (cc *ClientCodec) ReadResponseBody(body interface{}) error {
-	return bson.UnmarshalFromStream(cc.rwc, body)
+	return unmarshalFrame(cc.message, body, cc.maxFrameSize)
 }
 
 // Close closes the codec
@@ -66,34 +98,69 @@ func (cc *ClientCodec) Close() error {
 // ServerCodec holds required parameters for providing a server codec for
 // bsonrpc
 type ServerCodec struct {
-	rwc io.ReadWriteCloser
-	cw  *bytes2.ChunkedWriter
+	rwc          io.ReadWriteCloser
+	cw           *bytes2.ChunkedWriter
+	maxFrameSize int
+	compressor   *compressor
+	// message is where the body of the current request is read
+	// from: rwc, or the decompressed message.
+	message io.Reader
 }
 
 // NewServerCodec creates a new server codec for bsonrpc communication
 func NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
-	return &ServerCodec{conn, bytes2.NewChunkedWriter(DefaultBufferSize)}
+	return &ServerCodec{rwc: conn, cw: bytes2.NewChunkedWriter(DefaultBufferSize), message: conn}
+}
+
+// SetMaxFrameSize is part of the rpcwrap.FrameSizeLimiter interface.
+func (sc *ServerCodec) SetMaxFrameSize(maxFrameSize int) {
+	sc.maxFrameSize = maxFrameSize
+}
+
+// SetCompression is part of the rpcwrap.Compressor interface.
+func (sc *ServerCodec) SetCompression(codec compression.Codec, minSize int) {
+	sc.compressor = &compressor{codec: codec, minSize: minSize}
 }
 
 // ReadRequestHeader reads the header of the request
 func (sc *ServerCodec) ReadRequestHeader(r *rpc.Request) error {
-	return bson.UnmarshalFromStream(sc.rwc, &RequestBson{r})
+	if sc.compressor != nil {
+		message, err := sc.compressor.readMessage(sc.rwc, sc.maxFrameSize)
+		if err != nil {
+			return err
+		}
+		sc.message = message
+	}
+	return unmarshalFrame(sc.message, &RequestBson{r}, sc.maxFrameSize)
 }
 
 // ReadRequestBody reads the body of the request
 func (sc *ServerCodec) ReadRequestBody(body interface{}) error {
-	return bson.UnmarshalFromStream(sc.rwc, body)
+	return unmarshalFrame(sc.message, body, sc.maxFrameSize)
 }
 
-// WriteResponse send the response of the request to the client
+// WriteResponse send the response of the request to the client.
+// A body larger than the maximum frame size is not sent.
 func (sc *ServerCodec) WriteResponse(r *rpc.Response, body interface{}, last bool) error {
 	if err := bson.MarshalToBuffer(sc.cw, &ResponseBson{r}); err != nil {
+		sc.cw.Reset()
 		return err
 	}
+	headerLen := sc.cw.Len()
 	if err := bson.MarshalToBuffer(sc.cw, body); err != nil {
+		sc.cw.Reset()
 		return err
 	}
-	_, err := sc.cw.WriteTo(sc.rwc)
+	if err := checkFrameSize(sc.cw.Len()-headerLen, sc.maxFrameSize); err != nil {
+		sc.cw.Reset()
+		return err
+	}
+	var err error
+	if sc.compressor != nil {
+		err = sc.compressor.writeMessage(sc.rwc, sc.cw)
+	} else {
+		_, err = sc.cw.WriteTo(sc.rwc)
+	}
 	sc.cw.Reset()
 	return err
 }
@@ -103,6 +170,43 @@ func (sc *ServerCodec) Close() error {
 	return sc.rwc.Close()
 }
 
+// checkFrameSize returns a FrameTooLargeError if size is over
+// maxFrameSize. 0 means no limit.
+func checkFrameSize(size, maxFrameSize int) error {
+	if maxFrameSize != 0 && size > maxFrameSize {
+		return &rpc.FrameTooLargeError{Size: size, Max: maxFrameSize}
+	}
+	return nil
+}
+
+// unmarshalFrame reads the next message from reader into val, like
+// bson.UnmarshalFromStream. A message larger than maxFrameSize is
+// skipped without being buffered, and a FrameTooLargeError is
+// returned, unless val is nil.
+func unmarshalFrame(reader io.Reader, val interface{}, maxFrameSize int) error {
+	if maxFrameSize == 0 {
+		return bson.UnmarshalFromStream(reader, val)
+	}
+	lenbuf := make([]byte, 4)
+	if _, err := io.ReadFull(reader, lenbuf); err != nil {
+		return err
+	}
+	length := int(bson.Pack.Uint32(lenbuf))
+	if err := checkFrameSize(length, maxFrameSize); err != nil {
+		if _, cerr := io.CopyN(ioutil.Discard, reader, int64(length-4)); cerr != nil {
+			if cerr == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return cerr
+		}
+		if val == nil {
+			return nil
+		}
+		return err
+	}
+	return bson.UnmarshalFromStream(io.MultiReader(bytes.NewReader(lenbuf), reader), val)
+}
+
 // DialHTTP dials a HTTP endpoint with bsonrpc codec
 func DialHTTP(network, address string, connectTimeout time.Duration, config *tls.Config) (*rpc.Client, error) {
 	return rpcwrap.DialHTTP(network, address, codecName, NewClientCodec, connectTimeout, config)
@@ -0,0 +1,141 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bsonrpc
+
+import (
+	"net"
+	"testing"
+
+	rpc "github.com/youtube/vitess/go/rpcplus"
+	"golang.org/x/net/context"
+)
+
+type BlobArgs struct {
+	Sizes []int
+}
+
+type Blob struct {
+	Data []byte
+}
+
+type Blobs struct{}
+
+func (b *Blobs) Get(args *BlobArgs, reply *Blob) error {
+	reply.Data = make([]byte, args.Sizes[0])
+	return nil
+}
+
+func (b *Blobs) Stream(ctx context.Context, args *BlobArgs, sendReply func(interface{}) error) error {
+	for _, size := range args.Sizes {
+		if err := sendReply(&Blob{Data: make([]byte, size)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Blobs) Fail(args *BlobArgs, reply *Blob) error {
+	return codedError{int64(args.Sizes[0])}
+}
+
+type codedError struct {
+	code int64
+}
+
+func (e codedError) Error() string {
+	return "coded error"
+}
+
+func (e codedError) ErrorCode() int64 {
+	return e.code
+}
+
+// newLimitedClient returns a client talking to a Blobs server over a
+// pipe, with the given frame size limits on each side.
+func newLimitedClient(t *testing.T, clientMax, serverMax int) *rpc.Client {
+	server := rpc.NewServer()
+	if err := server.Register(new(Blobs)); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	clientConn, serverConn := net.Pipe()
+	sc := NewServerCodec(serverConn)
+	sc.(*ServerCodec).SetMaxFrameSize(serverMax)
+	go server.ServeCodec(sc)
+
+	cc := NewClientCodec(clientConn)
+	cc.(*ClientCodec).SetMaxFrameSize(clientMax)
+	return rpc.NewClientWithCodec(cc)
+}
+
+func testFrameSize(t *testing.T, client *rpc.Client) {
+	ctx := context.Background()
+	reply := &Blob{}
+	if err := client.Call(ctx, "Blobs.Get", &BlobArgs{Sizes: []int{10}}, reply); err != nil {
+		t.Errorf("small Get failed: %v", err)
+	}
+	err := client.Call(ctx, "Blobs.Get", &BlobArgs{Sizes: []int{10000}}, reply)
+	if !rpc.IsFrameTooLarge(err) {
+		t.Errorf("large Get returned %v, want a frame too large error", err)
+	}
+
+	replies := make(chan *Blob, 10)
+	call := client.StreamGo("Blobs.Stream", &BlobArgs{Sizes: []int{10, 10000, 10}}, replies)
+	count := 0
+	for _ = range replies {
+		count++
+	}
+	if count != 1 || !rpc.IsFrameTooLarge(call.Error) {
+		t.Errorf("stream returned %v replies and %v, want 1 reply and a frame too large error", count, call.Error)
+	}
+
+	// the connection can still be used
+	if err := client.Call(ctx, "Blobs.Get", &BlobArgs{Sizes: []int{10}}, reply); err != nil {
+		t.Errorf("Get after a large frame failed: %v", err)
+	}
+}
+
+func TestServerFrameSize(t *testing.T) {
+	client := newLimitedClient(t, 0, 1000)
+	defer client.Close()
+	testFrameSize(t, client)
+
+	// requests are limited too
+	reply := &Blob{}
+	err := client.Call(context.Background(), "Blobs.Get", &BlobArgs{Sizes: make([]int, 1000)}, reply)
+	if !rpc.IsFrameTooLarge(err) {
+		t.Errorf("large request returned %v, want a frame too large error", err)
+	}
+}
+
+func TestClientFrameSize(t *testing.T) {
+	client := newLimitedClient(t, 1000, 0)
+	defer client.Close()
+	testFrameSize(t, client)
+
+	// large requests are not sent
+	reply := &Blob{}
+	err := client.Call(context.Background(), "Blobs.Get", &BlobArgs{Sizes: make([]int, 1000)}, reply)
+	if _, ok := err.(*rpc.FrameTooLargeError); !ok {
+		t.Errorf("large request returned %v, want a FrameTooLargeError", err)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	client := newLimitedClient(t, 0, 0)
+	defer client.Close()
+
+	reply := &Blob{}
+	err := client.Call(context.Background(), "Blobs.Fail", &BlobArgs{Sizes: []int{12}}, reply)
+	coded, ok := err.(*rpc.CodedServerError)
+	if !ok || coded.Code != 12 || coded.Err != "coded error" {
+		t.Errorf("Fail returned %#v, want a CodedServerError with code 12", err)
+	}
+
+	// errors without a code are still ServerErrors
+	err = client.Call(context.Background(), "Blobs.Fail", &BlobArgs{Sizes: []int{0}}, reply)
+	if _, ok := err.(rpc.ServerError); !ok {
+		t.Errorf("Fail returned %#v, want a ServerError", err)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bsonrpc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/youtube/vitess/go/bson"
+	"github.com/youtube/vitess/go/bytes2"
+	"github.com/youtube/vitess/go/compression"
+)
+
+// The flags of the compressed messages.
+const (
+	messageRaw        = 0
+	messageCompressed = 1
+)
+
+// messageHeaderRoom is the room left for the header of a message,
+// on top of its body, when checking the size of a whole message
+// against the maximum frame size.
+const messageHeaderRoom = 64 * 1024
+
+// maxMessageSize returns the maximum size of a message, header and
+// body, for maxFrameSize. 0 means no limit.
+func maxMessageSize(maxFrameSize int) int {
+	if maxFrameSize == 0 {
+		return 0
+	}
+	return maxFrameSize + messageHeaderRoom
+}
+
+// compressor sends and receives the messages of a codec once
+// compression was negotiated. Each message, header and body, is
+// preceded by a flag byte and the length of the payload. The
+// payload is compressed if the message has at least minSize bytes,
+// and if it makes it smaller.
+type compressor struct {
+	codec   compression.Codec
+	minSize int
+}
+
+// writeMessage sends the message in cw to w.
+func (c *compressor) writeMessage(w io.Writer, cw *bytes2.ChunkedWriter) error {
+	flag := byte(messageRaw)
+	payload := cw.Bytes()
+	if len(payload) >= c.minSize {
+		compressed := new(bytes.Buffer)
+		writer, err := compression.NewWriter(c.codec, compressed)
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(payload); err != nil {
+			writer.Close()
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		if compressed.Len() < len(payload) {
+			flag = messageCompressed
+			payload = compressed.Bytes()
+		}
+	}
+
+	envelope := make([]byte, 5)
+	envelope[0] = flag
+	bson.Pack.PutUint32(envelope[1:], uint32(len(payload)))
+	if _, err := w.Write(envelope); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readMessage reads the next message from r, and returns a reader
+// on its uncompressed content. Both the payload and the uncompressed
+// content are limited by maxMessageSize(maxFrameSize), so a peer
+// can't make us allocate more than that.
+func (c *compressor) readMessage(r io.Reader, maxFrameSize int) (io.Reader, error) {
+	envelope := make([]byte, 5)
+	if _, err := io.ReadFull(r, envelope); err != nil {
+		return nil, err
+	}
+	maxSize := maxMessageSize(maxFrameSize)
+	length := int(bson.Pack.Uint32(envelope[1:]))
+	if err := checkFrameSize(length, maxSize); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	switch envelope[0] {
+	case messageRaw:
+		return bytes.NewReader(payload), nil
+	case messageCompressed:
+		reader, err := compression.NewReader(c.codec, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		var src io.Reader = reader
+		if maxSize != 0 {
+			// one more byte, to know if there are too many
+			src = io.LimitReader(reader, int64(maxSize)+1)
+		}
+		message, err := ioutil.ReadAll(src)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkFrameSize(len(message), maxSize); err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(message), nil
+	}
+	return nil, fmt.Errorf("invalid RPC message flag %v", envelope[0])
+}
@@ -0,0 +1,145 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bsonrpc
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/youtube/vitess/go/bson"
+	"github.com/youtube/vitess/go/compression"
+	rpc "github.com/youtube/vitess/go/rpcplus"
+	"golang.org/x/net/context"
+)
+
+// flateCodec is a pure Go codec, to test the codecs registered
+// outside of the compression package.
+type flateCodec struct{}
+
+func (flateCodec) Name() string {
+	return "flate"
+}
+
+func (flateCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return flate.NewWriter(w, flate.BestSpeed)
+}
+
+func (flateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
+
+func init() {
+	compression.Register(flateCodec{})
+}
+
+// newCompressedClient returns a client talking to a Blobs server over
+// a pipe, with compression on both sides.
+func newCompressedClient(t *testing.T, clientMax, serverMax int) *rpc.Client {
+	server := rpc.NewServer()
+	if err := server.Register(new(Blobs)); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	codec := compression.Get("flate")
+	clientConn, serverConn := net.Pipe()
+	sc := NewServerCodec(serverConn)
+	sc.(*ServerCodec).SetMaxFrameSize(serverMax)
+	sc.(*ServerCodec).SetCompression(codec, 100)
+	go server.ServeCodec(sc)
+
+	cc := NewClientCodec(clientConn)
+	cc.(*ClientCodec).SetMaxFrameSize(clientMax)
+	cc.(*ClientCodec).SetCompression(codec, 100)
+	return rpc.NewClientWithCodec(cc)
+}
+
+func TestCompression(t *testing.T) {
+	client := newCompressedClient(t, 0, 0)
+	defer client.Close()
+	ctx := context.Background()
+
+	for _, size := range []int{10, 100000} {
+		reply := &Blob{}
+		if err := client.Call(ctx, "Blobs.Get", &BlobArgs{Sizes: []int{size}}, reply); err != nil {
+			t.Fatalf("Get(%v) failed: %v", size, err)
+		}
+		if len(reply.Data) != size {
+			t.Errorf("Get(%v) returned %v bytes", size, len(reply.Data))
+		}
+	}
+
+	// large requests are compressed too
+	reply := &Blob{}
+	if err := client.Call(ctx, "Blobs.Get", &BlobArgs{Sizes: make([]int, 1000)}, reply); err != nil {
+		t.Errorf("large request failed: %v", err)
+	}
+
+	replies := make(chan *Blob, 10)
+	call := client.StreamGo("Blobs.Stream", &BlobArgs{Sizes: []int{10, 100000, 10}}, replies)
+	var sizes []int
+	for r := range replies {
+		sizes = append(sizes, len(r.Data))
+	}
+	if call.Error != nil || len(sizes) != 3 || sizes[1] != 100000 {
+		t.Errorf("stream returned %v and %v, want 3 replies", sizes, call.Error)
+	}
+}
+
+func TestCompressionFrameSize(t *testing.T) {
+	client := newCompressedClient(t, 1000, 0)
+	defer client.Close()
+	testFrameSize(t, client)
+}
+
+func TestCompressionMessageSize(t *testing.T) {
+	c := &compressor{codec: compression.Get("flate"), minSize: 100}
+	maxSize := maxMessageSize(1000)
+
+	// a payload length over the limit is refused before allocating
+	envelope := make([]byte, 5)
+	envelope[0] = messageRaw
+	bson.Pack.PutUint32(envelope[1:], 0xffffffff)
+	_, err := c.readMessage(bytes.NewReader(envelope), 1000)
+	if fe, ok := err.(*rpc.FrameTooLargeError); !ok || fe.Max != maxSize {
+		t.Errorf("readMessage of a large payload = %v, want FrameTooLargeError", err)
+	}
+
+	// a small payload that expands over the limit is refused
+	compressed := new(bytes.Buffer)
+	writer, err := c.codec.NewWriter(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write(make([]byte, 10*maxSize))
+	writer.Close()
+	message := new(bytes.Buffer)
+	envelope[0] = messageCompressed
+	bson.Pack.PutUint32(envelope[1:], uint32(compressed.Len()))
+	message.Write(envelope)
+	message.Write(compressed.Bytes())
+	if compressed.Len() > maxSize {
+		t.Fatalf("compressed payload is %v bytes, want less than %v", compressed.Len(), maxSize)
+	}
+	_, err = c.readMessage(message, 1000)
+	if fe, ok := err.(*rpc.FrameTooLargeError); !ok || fe.Max != maxSize {
+		t.Errorf("readMessage of a decompression bomb = %v, want FrameTooLargeError", err)
+	}
+
+	// without a limit, it is read
+	bson.Pack.PutUint32(envelope[1:], uint32(compressed.Len()))
+	message.Reset()
+	message.Write(envelope)
+	message.Write(compressed.Bytes())
+	r, err := c.readMessage(message, 0)
+	if err != nil {
+		t.Fatalf("readMessage without a limit failed: %v", err)
+	}
+	if n, _ := io.Copy(ioutil.Discard, r); n != int64(10*maxSize) {
+		t.Errorf("readMessage without a limit returned %v bytes, want %v", n, 10*maxSize)
+	}
+}
@@ -6,6 +6,7 @@ package bsonrpc
 
 import (
 	"bytes"
+	"time"
 
 	"github.com/youtube/vitess/go/bson"
 	"github.com/youtube/vitess/go/bytes2"
@@ -24,6 +25,15 @@ func (req *RequestBson) MarshalBson(buf *bytes2.ChunkedWriter, key string) {
 
 	bson.EncodeString(buf, "ServiceMethod", req.ServiceMethod)
 	bson.EncodeUint64(buf, "Seq", req.Seq)
+	if req.Trace != "" {
+		bson.EncodeString(buf, "Trace", req.Trace)
+	}
+	if req.Timeout != 0 {
+		bson.EncodeInt64(buf, "Timeout", int64(req.Timeout))
+	}
+	if req.Cancel {
+		bson.EncodeBool(buf, "Cancel", req.Cancel)
+	}
 
 	lenWriter.Close()
 }
@@ -42,6 +52,12 @@ func (req *RequestBson) UnmarshalBson(buf *bytes.Buffer, kind byte) {
 			req.ServiceMethod = bson.DecodeString(buf, kind)
 		case "Seq":
 			req.Seq = bson.DecodeUint64(buf, kind)
+		case "Trace":
+			req.Trace = bson.DecodeString(buf, kind)
+		case "Timeout":
+			req.Timeout = time.Duration(bson.DecodeInt64(buf, kind))
+		case "Cancel":
+			req.Cancel = bson.DecodeBool(buf, kind)
 		default:
 			bson.Skip(buf, kind)
 		}
@@ -62,6 +78,9 @@ func (resp *ResponseBson) MarshalBson(buf *bytes2.ChunkedWriter, key string) {
 	bson.EncodeString(buf, "ServiceMethod", resp.ServiceMethod)
 	bson.EncodeUint64(buf, "Seq", resp.Seq)
 	bson.EncodeString(buf, "Error", resp.Error)
+	if resp.ErrorCode != 0 {
+		bson.EncodeInt64(buf, "ErrorCode", resp.ErrorCode)
+	}
 
 	lenWriter.Close()
 }
@@ -82,6 +101,8 @@ func (resp *ResponseBson) UnmarshalBson(buf *bytes.Buffer, kind byte) {
 			resp.Seq = bson.DecodeUint64(buf, kind)
 		case "Error":
 			resp.Error = bson.DecodeString(buf, kind)
+		case "ErrorCode":
+			resp.ErrorCode = bson.DecodeInt64(buf, kind)
 		default:
 			bson.Skip(buf, kind)
 		}
@@ -6,6 +6,7 @@ package bsonrpc
 
 import (
 	"testing"
+	"time"
 
 	"github.com/youtube/vitess/go/bson"
 	rpc "github.com/youtube/vitess/go/rpcplus"
@@ -69,6 +70,98 @@ func TestRequestBson(t *testing.T) {
 	}
 }
 
+type reflectTraceRequestBson struct {
+	ServiceMethod string
+	Seq           uint64
+	Trace         string
+}
+
+func TestRequestBsonTrace(t *testing.T) {
+	reflected, err := bson.Marshal(&reflectTraceRequestBson{
+		ServiceMethod: "aa",
+		Seq:           1,
+		Trace:         "trace",
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	want := string(reflected)
+
+	custom := RequestBson{
+		&rpc.Request{
+			ServiceMethod: "aa",
+			Seq:           1,
+			Trace:         "trace",
+		},
+	}
+	encoded, err := bson.Marshal(&custom)
+	if err != nil {
+		t.Error(err)
+	}
+	got := string(encoded)
+	if want != got {
+		t.Errorf("want\n%#v, got\n%#v", want, got)
+	}
+
+	unmarshalled := RequestBson{Request: new(rpc.Request)}
+	err = bson.Unmarshal(encoded, &unmarshalled)
+	if err != nil {
+		t.Error(err)
+	}
+	if custom.Trace != unmarshalled.Trace {
+		t.Errorf("want %v, got %#v", custom.Trace, unmarshalled.Trace)
+	}
+}
+
+type reflectDeadlineRequestBson struct {
+	ServiceMethod string
+	Seq           uint64
+	Timeout       int64
+	Cancel        bool
+}
+
+func TestRequestBsonDeadline(t *testing.T) {
+	reflected, err := bson.Marshal(&reflectDeadlineRequestBson{
+		ServiceMethod: "aa",
+		Seq:           1,
+		Timeout:       int64(time.Second),
+		Cancel:        true,
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	want := string(reflected)
+
+	custom := RequestBson{
+		&rpc.Request{
+			ServiceMethod: "aa",
+			Seq:           1,
+			Timeout:       time.Second,
+			Cancel:        true,
+		},
+	}
+	encoded, err := bson.Marshal(&custom)
+	if err != nil {
+		t.Error(err)
+	}
+	got := string(encoded)
+	if want != got {
+		t.Errorf("want\n%#v, got\n%#v", want, got)
+	}
+
+	unmarshalled := RequestBson{Request: new(rpc.Request)}
+	err = bson.Unmarshal(encoded, &unmarshalled)
+	if err != nil {
+		t.Error(err)
+	}
+	if custom.Timeout != unmarshalled.Timeout {
+		t.Errorf("want %v, got %#v", custom.Timeout, unmarshalled.Timeout)
+	}
+	if custom.Cancel != unmarshalled.Cancel {
+		t.Errorf("want %v, got %#v", custom.Cancel, unmarshalled.Cancel)
+	}
+}
+
 type reflectResponseBson struct {
 	ServiceMethod string
 	Seq           uint64
@@ -0,0 +1,236 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpcwrap
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	rpc "github.com/youtube/vitess/go/rpcplus"
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/timer"
+	"golang.org/x/net/context"
+)
+
+const (
+	minDialBackoff = 100 * time.Millisecond
+	maxDialBackoff = 10 * time.Second
+)
+
+// DialFunc connects to the RPC server at addr.
+// Use 0 as connectTimeout for no timeout.
+type DialFunc func(addr string, connectTimeout time.Duration) (*rpc.Client, error)
+
+// PingFunc checks a pooled client still works.
+type PingFunc func(ctx context.Context, client *rpc.Client) error
+
+// ClientPool shares one client per address between callers, instead
+// of dialing for every call. A client is re-dialed when its connection
+// fails, with an exponential backoff between failed dials. If a
+// keepalive interval is set, the pooled clients are pinged on that
+// interval, and the ones that were not used for the idle timeout are
+// closed. Clients returned by Get must not be closed by the caller.
+type ClientPool struct {
+	dial        DialFunc
+	ping        PingFunc
+	keepalive   time.Duration
+	idleTimeout time.Duration
+	ticks       *timer.Timer
+
+	dials        *stats.Counters
+	pingFailures *stats.Int
+
+	mu      sync.Mutex
+	entries map[string]*poolEntry
+	closed  bool
+}
+
+// poolEntry is the state of the pool for one address.
+type poolEntry struct {
+	client   *rpc.Client
+	lastUsed time.Time
+
+	// failures is the number of failed dials since the last
+	// successful one, and lastErr the error of the last one.
+	// No dial is attempted before retryAt.
+	failures int
+	lastErr  error
+	retryAt  time.Time
+}
+
+// NewClientPool creates a ClientPool, and starts its keepalive loop if
+// keepalive is not 0. ping may be nil, to only check the clients for
+// idleness. If statsPrefix is set, the pool exports the counters
+// <statsPrefix>Dials (by outcome) and <statsPrefix>PingFailures.
+// They are not broken down by address, which would make their
+// cardinality grow with the number of servers.
+func NewClientPool(statsPrefix string, dial DialFunc, ping PingFunc, keepalive, idleTimeout time.Duration) *ClientPool {
+	var dials, pingFailures string
+	if statsPrefix != "" {
+		dials = statsPrefix + "Dials"
+		pingFailures = statsPrefix + "PingFailures"
+	}
+	cp := &ClientPool{
+		dial:         dial,
+		ping:         ping,
+		keepalive:    keepalive,
+		idleTimeout:  idleTimeout,
+		dials:        stats.NewCounters(dials),
+		pingFailures: new(stats.Int),
+		entries:      make(map[string]*poolEntry),
+	}
+	if pingFailures != "" {
+		stats.Publish(pingFailures, cp.pingFailures)
+	}
+	if keepalive != 0 {
+		cp.ticks = timer.NewTimer(keepalive)
+		cp.ticks.Start(cp.keepaliveClients)
+	}
+	return cp
+}
+
+// dialBackoff returns how long to wait before dialing again after
+// the given number of consecutive failures.
+func dialBackoff(failures int) time.Duration {
+	backoff := minDialBackoff
+	for i := 1; i < failures && backoff < maxDialBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxDialBackoff {
+		backoff = maxDialBackoff
+	}
+	return backoff
+}
+
+// Get returns the client for addr, dialing it if there is none or if
+// its connection failed. If the last dials to addr failed, Get fails
+// without dialing until the backoff has expired. The deadline of ctx,
+// if any, is used as the connect timeout.
+func (cp *ClientPool) Get(ctx context.Context, addr string) (*rpc.Client, error) {
+	cp.mu.Lock()
+	if cp.closed {
+		cp.mu.Unlock()
+		return nil, rpc.ErrShutdown
+	}
+	e, ok := cp.entries[addr]
+	if !ok {
+		e = &poolEntry{}
+		cp.entries[addr] = e
+	}
+	now := time.Now()
+	if e.client != nil {
+		if !e.client.IsShutdown() {
+			e.lastUsed = now
+			client := e.client
+			cp.mu.Unlock()
+			return client, nil
+		}
+		e.client = nil
+	}
+	if now.Before(e.retryAt) {
+		err := fmt.Errorf("not dialing %v for %v after %v failed dials, last error: %v", addr, e.retryAt.Sub(now), e.failures, e.lastErr)
+		cp.mu.Unlock()
+		cp.dials.Add("Backoff", 1)
+		return nil, err
+	}
+	cp.mu.Unlock()
+
+	var connectTimeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		connectTimeout = deadline.Sub(now)
+		if connectTimeout <= 0 {
+			return nil, fmt.Errorf("timeout connecting to %v", addr)
+		}
+	}
+	client, err := cp.dial(addr, connectTimeout)
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	now = time.Now()
+	if err != nil {
+		e.failures++
+		e.lastErr = err
+		e.retryAt = now.Add(dialBackoff(e.failures))
+		cp.dials.Add("Failure", 1)
+		return nil, err
+	}
+	cp.dials.Add("Success", 1)
+	if cp.closed {
+		client.Close()
+		return nil, rpc.ErrShutdown
+	}
+	if e.client != nil && !e.client.IsShutdown() {
+		// Someone else dialed addr at the same time, use theirs.
+		client.Close()
+		client = e.client
+	} else {
+		e.client = client
+	}
+	e.failures = 0
+	e.lastErr = nil
+	e.retryAt = time.Time{}
+	e.lastUsed = now
+	return client, nil
+}
+
+// keepaliveClients closes the idle clients, and pings the other ones.
+func (cp *ClientPool) keepaliveClients() {
+	now := time.Now()
+	clients := make(map[string]*rpc.Client)
+	cp.mu.Lock()
+	for addr, e := range cp.entries {
+		if e.client == nil {
+			if now.Sub(e.lastUsed) > cp.idleTimeout && !now.Before(e.retryAt) {
+				delete(cp.entries, addr)
+			}
+			continue
+		}
+		if e.client.IsShutdown() || (cp.idleTimeout != 0 && now.Sub(e.lastUsed) > cp.idleTimeout && e.client.PendingCalls() == 0) {
+			e.client.Close()
+			delete(cp.entries, addr)
+			continue
+		}
+		clients[addr] = e.client
+	}
+	cp.mu.Unlock()
+
+	if cp.ping == nil {
+		return
+	}
+	for addr, client := range clients {
+		ctx, cancel := context.WithTimeout(context.Background(), cp.keepalive)
+		err := cp.ping(ctx, client)
+		cancel()
+		if err == nil {
+			continue
+		}
+		log.Warningf("closing RPC client to %v after failed ping: %v", addr, err)
+		cp.pingFailures.Add(1)
+		client.Close()
+		cp.mu.Lock()
+		if e, ok := cp.entries[addr]; ok && e.client == client {
+			e.client = nil
+		}
+		cp.mu.Unlock()
+	}
+}
+
+// Close stops the keepalive loop and closes all the clients.
+func (cp *ClientPool) Close() {
+	if cp.ticks != nil {
+		cp.ticks.Stop()
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.closed = true
+	for addr, e := range cp.entries {
+		if e.client != nil {
+			e.client.Close()
+		}
+		delete(cp.entries, addr)
+	}
+}
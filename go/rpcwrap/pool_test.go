@@ -0,0 +1,194 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpcwrap
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	rpc "github.com/youtube/vitess/go/rpcplus"
+	"golang.org/x/net/context"
+)
+
+type PoolService struct{}
+
+func (ps *PoolService) Ping(args string, reply *string) error {
+	*reply = args
+	return nil
+}
+
+// pipeDialer dials in-memory connections to a PoolService, or
+// fails with err if it is set.
+type pipeDialer struct {
+	server *rpc.Server
+	dials  int
+	err    error
+}
+
+func newPipeDialer(t *testing.T) *pipeDialer {
+	server := rpc.NewServer()
+	if err := server.Register(new(PoolService)); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	return &pipeDialer{server: server}
+}
+
+func (pd *pipeDialer) dial(addr string, connectTimeout time.Duration) (*rpc.Client, error) {
+	pd.dials++
+	if pd.err != nil {
+		return nil, pd.err
+	}
+	clientConn, serverConn := net.Pipe()
+	go pd.server.ServeConn(serverConn)
+	return rpc.NewClient(clientConn), nil
+}
+
+func poolPing(ctx context.Context, client *rpc.Client) error {
+	var reply string
+	return client.Call(ctx, "PoolService.Ping", "ping", &reply)
+}
+
+func TestClientPoolReuse(t *testing.T) {
+	pd := newPipeDialer(t)
+	cp := NewClientPool("", pd.dial, nil, 0, 0)
+	defer cp.Close()
+	ctx := context.Background()
+
+	c1, err := cp.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	c2, err := cp.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if c1 != c2 || pd.dials != 1 {
+		t.Errorf("client was not reused, %v dials", pd.dials)
+	}
+	if err := poolPing(ctx, c2); err != nil {
+		t.Errorf("ping failed: %v", err)
+	}
+
+	// another address gets its own client
+	if c3, err := cp.Get(ctx, "b"); err != nil || c3 == c1 {
+		t.Errorf("Get(b) = %v, %v", c3, err)
+	}
+
+	// a failed client is replaced
+	c1.Close()
+	c4, err := cp.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if c4 == c1 || pd.dials != 3 {
+		t.Errorf("client was not re-dialed, %v dials", pd.dials)
+	}
+	if err := poolPing(ctx, c4); err != nil {
+		t.Errorf("ping failed: %v", err)
+	}
+
+	// dial failures are counted
+	if got := cp.dials.Counts()["Success"]; got != 3 {
+		t.Errorf("got %v successful dials, want 3", got)
+	}
+}
+
+func TestClientPoolBackoff(t *testing.T) {
+	pd := newPipeDialer(t)
+	cp := NewClientPool("", pd.dial, nil, 0, 0)
+	defer cp.Close()
+	ctx := context.Background()
+
+	pd.err = errors.New("connection refused")
+	if _, err := cp.Get(ctx, "a"); err != pd.err {
+		t.Errorf("Get returned %v, want %v", err, pd.err)
+	}
+	// no dial during the backoff
+	if _, err := cp.Get(ctx, "a"); err == nil || pd.dials != 1 {
+		t.Errorf("Get returned %v after %v dials", err, pd.dials)
+	}
+	if got := cp.dials.Counts()["Failure"]; got != 1 {
+		t.Errorf("got %v dial failures, want 1", got)
+	}
+	if got := cp.dials.Counts()["Backoff"]; got != 1 {
+		t.Errorf("got %v backoffs, want 1", got)
+	}
+
+	time.Sleep(dialBackoff(1))
+	pd.err = nil
+	if _, err := cp.Get(ctx, "a"); err != nil || pd.dials != 2 {
+		t.Errorf("Get returned %v after %v dials", err, pd.dials)
+	}
+}
+
+func TestDialBackoff(t *testing.T) {
+	testcases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, minDialBackoff},
+		{2, 2 * minDialBackoff},
+		{4, 8 * minDialBackoff},
+		{100, maxDialBackoff},
+	}
+	for _, tc := range testcases {
+		if got := dialBackoff(tc.failures); got != tc.want {
+			t.Errorf("dialBackoff(%v) = %v, want %v", tc.failures, got, tc.want)
+		}
+	}
+}
+
+func TestClientPoolKeepalive(t *testing.T) {
+	pd := newPipeDialer(t)
+	pingErr := errors.New("ping failed")
+	var failPing bool
+	ping := func(ctx context.Context, client *rpc.Client) error {
+		if failPing {
+			return pingErr
+		}
+		return poolPing(ctx, client)
+	}
+	// the keepalive loop is run by hand below
+	cp := NewClientPool("", pd.dial, ping, 0, time.Hour)
+	cp.keepalive = time.Second
+	defer cp.Close()
+	ctx := context.Background()
+
+	c1, err := cp.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	cp.keepaliveClients()
+	if c1.IsShutdown() {
+		t.Errorf("working client was closed")
+	}
+
+	failPing = true
+	cp.keepaliveClients()
+	if !c1.IsShutdown() {
+		t.Errorf("client with a failed ping was not closed")
+	}
+	if got := cp.pingFailures.Get(); got != 1 {
+		t.Errorf("got %v ping failures, want 1", got)
+	}
+
+	// idle clients are closed
+	failPing = false
+	c2, err := cp.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	cp.idleTimeout = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	cp.keepaliveClients()
+	if !c2.IsShutdown() {
+		t.Errorf("idle client was not closed")
+	}
+	if len(cp.entries) != 0 {
+		t.Errorf("idle entries were not removed: %v", cp.entries)
+	}
+}
@@ -9,14 +9,18 @@ import (
 	"bufio"
 	"crypto/tls"
 	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"golang.org/x/net/context"
 
 	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/compression"
 	rpc "github.com/youtube/vitess/go/rpcplus"
 	"github.com/youtube/vitess/go/rpcwrap/auth"
 	"github.com/youtube/vitess/go/rpcwrap/proto"
@@ -25,13 +29,76 @@ import (
 
 const (
 	connected = "200 Connected to Go RPC"
+
+	// maxFrameSizeHeader is sent by both sides of the CONNECT
+	// handshake, with the largest message they accept.
+	maxFrameSizeHeader = "Max-Frame-Size"
+
+	// compressionHeader is sent by the client with the codecs it
+	// supports, and by the server with the codec it picked.
+	compressionHeader = "Rpc-Compression"
 )
 
 var (
 	connCount    = stats.NewInt("connection-count")
 	connAccepted = stats.NewInt("connection-accepted")
+
+	maxFrameSize = flag.Int("rpc_max_frame_size", 0, "maximum size in bytes of a single RPC message, for the codecs that support it (0 for no limit). Both ends of a connection use the smaller of their limits.")
+
+	rpcCompression        = flag.String("rpc_compression", "", "comma-separated list of the compression codecs of the RPC messages, in order of preference, for the codecs that support it. The server uses the first one the client supports. Empty for no compression.")
+	rpcCompressionMinSize = flag.Int("rpc_compression_min_size", 16*1024, "RPC messages smaller than this are not compressed")
 )
 
+// Compressor is implemented by the codecs that can compress their
+// messages. Only the messages of at least minSize bytes are
+// compressed.
+type Compressor interface {
+	SetCompression(codec compression.Codec, minSize int)
+}
+
+// compressionCodecs returns the codecs of -rpc_compression, if the
+// RPC codec supports compression.
+func compressionCodecs(codec interface{}) ([]compression.Codec, error) {
+	if _, ok := codec.(Compressor); !ok {
+		return nil, nil
+	}
+	return compression.ParseList(*rpcCompression)
+}
+
+// setCompression makes codec compress its messages with
+// compressionCodec, if it's not nil.
+func setCompression(codec interface{}, compressionCodec compression.Codec) {
+	if compressor, ok := codec.(Compressor); ok && compressionCodec != nil {
+		compressor.SetCompression(compressionCodec, *rpcCompressionMinSize)
+	}
+}
+
+// FrameSizeLimiter is implemented by the codecs that can limit the
+// size of the messages they read and write.
+type FrameSizeLimiter interface {
+	SetMaxFrameSize(maxFrameSize int)
+}
+
+// negotiateFrameSize returns the smaller of the local limit and the
+// limit sent by the peer in header, 0 meaning no limit.
+func negotiateFrameSize(local int, header http.Header) int {
+	remote, err := strconv.Atoi(header.Get(maxFrameSizeHeader))
+	if err != nil || remote <= 0 {
+		return local
+	}
+	if local == 0 || remote < local {
+		return remote
+	}
+	return local
+}
+
+// setMaxFrameSize limits the frame size of codec, if it supports it.
+func setMaxFrameSize(codec interface{}, maxFrameSize int) {
+	if limiter, ok := codec.(FrameSizeLimiter); ok && maxFrameSize != 0 {
+		limiter.SetMaxFrameSize(maxFrameSize)
+	}
+}
+
 // ClientCodecFactory holds pattern for other client codec factories
 type ClientCodecFactory func(conn io.ReadWriteCloser) rpc.ClientCodec
 
@@ -105,17 +172,40 @@ func dialHTTP(network, address, codecName string, cFactory ClientCodecFactory, a
 		conn = tls.Client(conn, config)
 	}
 
-	_, err = io.WriteString(conn, "CONNECT "+GetRpcPath(codecName, auth)+" HTTP/1.0\n\n")
+	buffered := NewBufferedConnection(conn)
+	codec := cFactory(buffered)
+	codecs, err := compressionCodecs(codec)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	connect := "CONNECT " + GetRpcPath(codecName, auth) + " HTTP/1.0\n"
+	if *maxFrameSize != 0 {
+		connect += fmt.Sprintf("%v: %v\n", maxFrameSizeHeader, *maxFrameSize)
+	}
+	if len(codecs) > 0 {
+		connect += fmt.Sprintf("%v: %v\n", compressionHeader, compression.FormatList(codecs))
+	}
+	_, err = io.WriteString(conn, connect+"\n")
 	if err != nil {
 		return nil, err
 	}
 
 	// Require successful HTTP response
 	// before switching to RPC protocol.
-	buffered := NewBufferedConnection(conn)
 	resp, err := http.ReadResponse(buffered.Reader, &http.Request{Method: "CONNECT"})
 	if err == nil && resp.Status == connected {
-		return rpc.NewClientWithCodec(cFactory(buffered)), nil
+		setMaxFrameSize(codec, negotiateFrameSize(*maxFrameSize, resp.Header))
+		if name := resp.Header.Get(compressionHeader); name != "" {
+			compressionCodec := compression.Negotiate(codecs, name)
+			if compressionCodec == nil {
+				conn.Close()
+				return nil, fmt.Errorf("server picked the unsupported RPC compression %q", name)
+			}
+			setCompression(codec, compressionCodec)
+		}
+		return rpc.NewClientWithCodec(codec), nil
 	}
 	if err == nil {
 		err = errors.New("unexpected HTTP response: " + resp.Status)
@@ -168,9 +258,31 @@ func (h *rpcHandler) ServeHTTP(c http.ResponseWriter, req *http.Request) {
 		log.Errorf("rpc hijacking %s: %v", req.RemoteAddr, err)
 		return
 	}
-	io.WriteString(conn, "HTTP/1.0 "+connected+"\n\n")
 	codec := h.cFactory(NewBufferedConnection(conn))
+	codecs, err := compressionCodecs(codec)
+	if err != nil {
+		log.Errorf("rpc compression for %s: %v", req.RemoteAddr, err)
+	}
+	compressionCodec := compression.Negotiate(codecs, req.Header.Get(compressionHeader))
+	if compressionCodec != nil && compressionCodec.Name() == compression.None {
+		compressionCodec = nil
+	}
+
+	frameSize := negotiateFrameSize(*maxFrameSize, req.Header)
+	response := "HTTP/1.0 " + connected + "\n"
+	if frameSize != 0 {
+		response += fmt.Sprintf("%v: %v\n", maxFrameSizeHeader, frameSize)
+	}
+	if compressionCodec != nil {
+		response += fmt.Sprintf("%v: %v\n", compressionHeader, compressionCodec.Name())
+	}
+	io.WriteString(conn, response+"\n")
+	setMaxFrameSize(codec, frameSize)
+	setCompression(codec, compressionCodec)
 	ctx := proto.NewContext(req.RemoteAddr)
+	if username := usernameFromTLS(req.TLS); username != "" {
+		proto.SetUsername(ctx, username)
+	}
 	if h.useAuth {
 		if authenticated, err := auth.Authenticate(ctx, codec); !authenticated {
 			if err != nil {
@@ -183,6 +295,17 @@ func (h *rpcHandler) ServeHTTP(c http.ResponseWriter, req *http.Request) {
 	h.server.ServeCodecWithContext(ctx, codec)
 }
 
+// usernameFromTLS returns the common name of the client certificate
+// of a TLS connection, if it was verified by the server. Otherwise it
+// returns "". With an authenticated server, the username from the
+// authentication takes precedence.
+func usernameFromTLS(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return state.VerifiedChains[0][0].Subject.CommonName
+}
+
 // GetRpcPath returns the toplevel path used for serving RPCs over HTTP
 func GetRpcPath(codecName string, auth bool) string {
 	path := "/_" + codecName + "_rpc_"
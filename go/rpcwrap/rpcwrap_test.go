@@ -0,0 +1,56 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpcwrap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"testing"
+)
+
+func TestUsernameFromTLS(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "vtgate"}}
+	testcases := []struct {
+		state *tls.ConnectionState
+		want  string
+	}{
+		{nil, ""},
+		// a certificate that was not verified is not trusted
+		{&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}, ""},
+		{&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}, "vtgate"},
+	}
+	for _, tc := range testcases {
+		if got := usernameFromTLS(tc.state); got != tc.want {
+			t.Errorf("usernameFromTLS(%v) = %q, want %q", tc.state, got, tc.want)
+		}
+	}
+}
+
+func TestNegotiateFrameSize(t *testing.T) {
+	testcases := []struct {
+		local  int
+		remote string
+		want   int
+	}{
+		{0, "", 0},
+		{100, "", 100},
+		{0, "100", 100},
+		{100, "50", 50},
+		{50, "100", 50},
+		{100, "invalid", 100},
+		{100, "-1", 100},
+	}
+	for _, tc := range testcases {
+		header := http.Header{}
+		if tc.remote != "" {
+			header.Set(maxFrameSizeHeader, tc.remote)
+		}
+		if got := negotiateFrameSize(tc.local, header); got != tc.want {
+			t.Errorf("negotiateFrameSize(%v, %q) = %v, want %v", tc.local, tc.remote, got, tc.want)
+		}
+	}
+}
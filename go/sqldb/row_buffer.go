@@ -0,0 +1,108 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqldb
+
+import (
+	"sync"
+
+	"github.com/youtube/vitess/go/sqltypes"
+)
+
+// The initial and maximum sizes of the memory of a RowBuffer. A
+// buffer that grew beyond the maximum sizes is not recycled.
+const (
+	minRowBufferValues = 256
+	minRowBufferArena  = 16 * 1024
+	maxRowBufferValues = 64 * 1024
+	maxRowBufferArena  = 1024 * 1024
+)
+
+// RowFetcher is implemented by the Conns that can fetch the rows of
+// a streaming query into a RowBuffer, instead of allocating memory
+// for each row.
+type RowFetcher interface {
+	// FetchNextInto is like FetchNext, but the values of the row
+	// and their bytes are in the memory of rb.
+	FetchNextInto(rb *RowBuffer) ([]sqltypes.Value, error)
+}
+
+// RowBuffer holds the memory of a batch of streamed rows: the rows,
+// their values and the bytes of the values. Once the batch is sent,
+// Reset makes the memory available to the next batch, so the rows
+// must not be used after it.
+type RowBuffer struct {
+	// Rows are the rows of the batch, appended by the caller.
+	Rows [][]sqltypes.Value
+
+	values []sqltypes.Value
+	arena  []byte
+}
+
+var rowBuffers = sync.Pool{
+	New: func() interface{} {
+		return &RowBuffer{}
+	},
+}
+
+// GetRowBuffer returns an empty RowBuffer from the pool.
+func GetRowBuffer() *RowBuffer {
+	return rowBuffers.Get().(*RowBuffer)
+}
+
+// Release returns rb to the pool. Neither rb nor its rows can be
+// used after it.
+func (rb *RowBuffer) Release() {
+	rb.Reset()
+	if cap(rb.values) > maxRowBufferValues || cap(rb.arena) > maxRowBufferArena {
+		return
+	}
+	rowBuffers.Put(rb)
+}
+
+// Reset empties rb, and keeps its memory for the next rows.
+func (rb *RowBuffer) Reset() {
+	for i := range rb.Rows {
+		rb.Rows[i] = nil
+	}
+	rb.Rows = rb.Rows[:0]
+	rb.values = rb.values[:0]
+	rb.arena = rb.arena[:0]
+}
+
+// NewRow returns a row of n NULL values, and an empty arena of
+// capacity size for the bytes of the values. Appending more than
+// size bytes to the arena doesn't overwrite the other rows.
+func (rb *RowBuffer) NewRow(n, size int) (row []sqltypes.Value, arena []byte) {
+	if cap(rb.values)-len(rb.values) < n {
+		// The rows already returned keep the old memory.
+		rb.values = make([]sqltypes.Value, 0, grow(cap(rb.values), n, minRowBufferValues))
+	}
+	start := len(rb.values)
+	rb.values = rb.values[:start+n]
+	row = rb.values[start : start+n : start+n]
+	for i := range row {
+		row[i] = sqltypes.Value{}
+	}
+
+	if cap(rb.arena)-len(rb.arena) < size {
+		rb.arena = make([]byte, 0, grow(cap(rb.arena), size, minRowBufferArena))
+	}
+	start = len(rb.arena)
+	rb.arena = rb.arena[:start+size]
+	return row, rb.arena[start : start : start+size]
+}
+
+// grow returns the capacity to allocate for n more elements, when
+// the current capacity is old.
+func grow(old, n, min int) int {
+	size := 2 * old
+	if size < min {
+		size = min
+	}
+	if size < n {
+		size = n
+	}
+	return size
+}
@@ -0,0 +1,55 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqldb
+
+import (
+	"testing"
+
+	"github.com/youtube/vitess/go/sqltypes"
+)
+
+func TestRowBuffer(t *testing.T) {
+	rb := GetRowBuffer()
+	defer rb.Release()
+
+	row1, arena := rb.NewRow(2, 3)
+	if len(row1) != 2 || cap(arena) != 3 {
+		t.Fatalf("NewRow(2, 3) returned %v values and %v bytes", len(row1), cap(arena))
+	}
+	arena = append(arena, "abc"...)
+	row1[0] = sqltypes.MakeString(arena)
+
+	// Values and bytes of the next row don't overlap the first one.
+	row2, arena := rb.NewRow(2, 3)
+	arena = append(arena, "xyz"...)
+	row2[1] = sqltypes.MakeString(arena)
+	if row1[0].String() != "abc" || !row1[1].IsNull() {
+		t.Errorf("row1 was changed: %v", row1)
+	}
+	if !row2[0].IsNull() || row2[1].String() != "xyz" {
+		t.Errorf("unexpected row2: %v", row2)
+	}
+
+	// Growing the buffer keeps the rows already returned.
+	row3, arena := rb.NewRow(2*minRowBufferValues, 2*minRowBufferArena)
+	if len(row3) != 2*minRowBufferValues || cap(arena) != 2*minRowBufferArena {
+		t.Errorf("NewRow returned %v values and %v bytes", len(row3), cap(arena))
+	}
+	if row1[0].String() != "abc" || row2[1].String() != "xyz" {
+		t.Errorf("rows were changed: %v %v", row1, row2)
+	}
+
+	// After Reset, the memory is reused, and the values are NULL.
+	rb.Reset()
+	row4, _ := rb.NewRow(2, 3)
+	if &row4[0] != &row3[0] {
+		t.Errorf("NewRow after Reset didn't reuse the memory")
+	}
+	for i, value := range row4 {
+		if !value.IsNull() {
+			t.Errorf("row4[%v] is %v, want NULL", i, value)
+		}
+	}
+}
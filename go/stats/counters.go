@@ -86,6 +86,36 @@ func (f CountersFunc) String() string {
 	return counterToString(m)
 }
 
+// Gauges is similar to Counters, except that its values are
+// point-in-time values that can go down, like the number of running
+// requests. The monitoring backends export them as gauges.
+type Gauges struct {
+	Counters
+}
+
+// NewGauges creates a new Gauges instance, and publishes it if name
+// is set.
+func NewGauges(name string) *Gauges {
+	g := &Gauges{Counters: Counters{counts: make(map[string]int64)}}
+	if name != "" {
+		Publish(name, g)
+	}
+	return g
+}
+
+// GaugesFunc is the CountersFunc of point-in-time values, like Gauges.
+type GaugesFunc func() map[string]int64
+
+// Counts returns a copy of the Gauges' map.
+func (f GaugesFunc) Counts() map[string]int64 {
+	return f()
+}
+
+// String is used by expvar.
+func (f GaugesFunc) String() string {
+	return CountersFunc(f).String()
+}
+
 func counterToString(m map[string]int64) string {
 	b := bytes.NewBuffer(make([]byte, 0, 4096))
 	fmt.Fprintf(b, "{")
@@ -38,6 +38,31 @@ func TestCounters(t *testing.T) {
 	}
 }
 
+func TestGauges(t *testing.T) {
+	clear()
+	g := NewGauges("gauges1")
+	g.Add("g1", 2)
+	g.Add("g1", -1)
+	g.Set("g2", 3)
+	want1 := `{"g1": 1, "g2": 3}`
+	want2 := `{"g2": 3, "g1": 1}`
+	if s := g.String(); s != want1 && s != want2 {
+		t.Errorf("want %s or %s, got %s", want1, want2, s)
+	}
+	if s := expvar.Get("gauges1").String(); s != want1 && s != want2 {
+		t.Errorf("want %s or %s, got %s", want1, want2, s)
+	}
+	f := GaugesFunc(func() map[string]int64 {
+		return map[string]int64{
+			"g1": 1,
+			"g2": 3,
+		}
+	})
+	if s := f.String(); s != want1 && s != want2 {
+		t.Errorf("want %s or %s, got %s", want1, want2, s)
+	}
+}
+
 func TestMultiCounters(t *testing.T) {
 	clear()
 	c := NewMultiCounters("mapCounter1", []string{"aaa", "bbb"})
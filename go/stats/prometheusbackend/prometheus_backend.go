@@ -0,0 +1,223 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package prometheusbackend exports all the stats variables in the
+// Prometheus text exposition format, on the /metrics URL.
+//
+// Variable names are converted to snake case and prefixed with
+// 'vitess_'. Single dimension Counters, Gauges and Timings use the
+// 'category' label, while multi-dimensional ones use their own label
+// names, also converted to snake case. Gauges hold point-in-time
+// values, and are exported as such. Durations and Timings are
+// exported in seconds. Variables that are not numerical (like String or Rates)
+// are not exported.
+package prometheusbackend
+
+import (
+	"bytes"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/youtube/vitess/go/acl"
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/vt/servenv"
+)
+
+var metricsHandler = flag.String("prometheus_metrics_handler", "/metrics", "URL handler for the Prometheus metrics, empty to disable")
+
+const metricPrefix = "vitess_"
+
+func init() {
+	servenv.OnRun(func() {
+		if *metricsHandler == "" {
+			return
+		}
+		http.HandleFunc(*metricsHandler, func(w http.ResponseWriter, r *http.Request) {
+			if err := acl.CheckAccessHTTP(r, acl.MONITORING); err != nil {
+				acl.SendError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			WriteMetrics(w)
+		})
+	})
+}
+
+// WriteMetrics writes all the exported stats variables to w.
+func WriteMetrics(w io.Writer) {
+	buf := bytes.NewBuffer(nil)
+	expvar.Do(func(kv expvar.KeyValue) {
+		writeVar(buf, metricPrefix+toSnakeCase(kv.Key), kv.Value)
+	})
+	w.Write(buf.Bytes())
+}
+
+func writeVar(w io.Writer, name string, v expvar.Var) {
+	switch v := v.(type) {
+	case *stats.Int:
+		writeGauge(w, name, float64(v.Get()))
+	case stats.IntFunc:
+		writeGauge(w, name, float64(v()))
+	case *stats.Float:
+		writeGauge(w, name, v.Get())
+	case stats.FloatFunc:
+		writeGauge(w, name, v())
+	case *stats.Duration:
+		writeGauge(w, name+"_seconds", v.Get().Seconds())
+	case stats.DurationFunc:
+		writeGauge(w, name+"_seconds", v().Seconds())
+	case *stats.MultiCounters:
+		writeCounters(w, name, v.Labels(), v.Counts())
+	case *stats.MultiCountersFunc:
+		writeCounters(w, name, v.Labels(), v.Counts())
+	case *stats.Counters:
+		writeCounters(w, name, []string{"category"}, v.Counts())
+	case stats.CountersFunc:
+		writeCounters(w, name, []string{"category"}, v.Counts())
+	case *stats.Gauges:
+		writeGauges(w, name, []string{"category"}, v.Counts())
+	case stats.GaugesFunc:
+		writeGauges(w, name, []string{"category"}, v.Counts())
+	case *stats.MultiTimings:
+		writeTimings(w, name, v.Labels(), &v.Timings)
+	case *stats.Timings:
+		writeTimings(w, name, []string{"category"}, v)
+	case *stats.Histogram:
+		fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+		writeHistogram(w, name, "", v, 1)
+	}
+}
+
+func writeGauge(w io.Writer, name string, value float64) {
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(value))
+}
+
+func writeCounters(w io.Writer, name string, labels []string, counts map[string]int64) {
+	writeValues(w, name, "counter", labels, counts)
+}
+
+func writeGauges(w io.Writer, name string, labels []string, values map[string]int64) {
+	writeValues(w, name, "gauge", labels, values)
+}
+
+func writeValues(w io.Writer, name, metricType string, labels []string, values map[string]int64) {
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s%s %d\n", name, formatLabels(labels, key), values[key])
+	}
+}
+
+func writeTimings(w io.Writer, name string, labels []string, t *stats.Timings) {
+	name += "_seconds"
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	histograms := t.Histograms()
+	keys := make([]string, 0, len(histograms))
+	for key := range histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		writeHistogram(w, name, formatLabels(labels, key), histograms[key], float64(time.Second))
+	}
+}
+
+// writeHistogram writes the cumulative buckets, the sum and the count
+// of a Histogram. labels is either empty, or of the form
+// '{label="value",...}'. The bucket limits and the sum are divided by
+// scale.
+func writeHistogram(w io.Writer, name, labels string, h *stats.Histogram, scale float64) {
+	bucketLabels := "{"
+	if labels != "" {
+		bucketLabels = labels[:len(labels)-1] + ","
+	}
+	counts := h.Counts()
+	var cumulative int64
+	for _, label := range h.Labels() {
+		cumulative += counts[label]
+		le := "+Inf"
+		if label != "inf" {
+			if limit, err := strconv.ParseFloat(label, 64); err == nil {
+				le = formatFloat(limit / scale)
+			}
+		}
+		fmt.Fprintf(w, "%s_bucket%sle=\"%s\"} %d\n", name, bucketLabels, le, cumulative)
+	}
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, formatFloat(float64(h.Total())/scale))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, cumulative)
+}
+
+// formatLabels returns the label set for a key of a multi-dimensional
+// variable, where the values are joined with '.'. If the key doesn't
+// have the right number of parts, it is used as a single value for
+// the first label.
+func formatLabels(labels []string, key string) string {
+	values := strings.Split(key, ".")
+	if len(values) != len(labels) {
+		values = []string{key}
+		labels = labels[:1]
+	}
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = fmt.Sprintf("%s=\"%s\"", toSnakeCase(label), escapeLabelValue(values[i]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escapeLabelValue(value string) string {
+	value = strings.Replace(value, "\\", "\\\\", -1)
+	value = strings.Replace(value, "\"", "\\\"", -1)
+	return strings.Replace(value, "\n", "\\n", -1)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// toSnakeCase converts a CamelCase name to snake_case, and replaces
+// characters that are not allowed in metric names with '_'.
+// Consecutive upper case letters are kept together: 'TabletQPS'
+// becomes 'tablet_qps'.
+func toSnakeCase(name string) string {
+	buf := bytes.NewBuffer(make([]byte, 0, len(name)+8))
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'A' && c <= 'Z':
+			if i > 0 && (isLower(name[i-1]) || (i+1 < len(name) && isLower(name[i+1]) && isUpper(name[i-1]))) {
+				buf.WriteByte('_')
+			}
+			buf.WriteByte(c - 'A' + 'a')
+		case isLower(c) || (c >= '0' && c <= '9'):
+			buf.WriteByte(c)
+		default:
+			buf.WriteByte('_')
+		}
+	}
+	return buf.String()
+}
+
+func isLower(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
+
+func isUpper(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
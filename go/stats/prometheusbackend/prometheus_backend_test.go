@@ -0,0 +1,65 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package prometheusbackend
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/stats"
+)
+
+func TestToSnakeCase(t *testing.T) {
+	testCases := map[string]string{
+		"TabletType":         "tablet_type",
+		"QPS":                "qps",
+		"TabletQPS":          "tablet_qps",
+		"MysqlDba":           "mysql_dba",
+		"DDLQueries":         "ddl_queries",
+		"Streamlog-Send":     "streamlog_send",
+		"already_snake_case": "already_snake_case",
+	}
+	for input, want := range testCases {
+		if got := toSnakeCase(input); got != want {
+			t.Errorf("toSnakeCase(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	stats.NewInt("PromTestInt").Set(12)
+	stats.NewDuration("PromTestDuration").Set(1500 * time.Millisecond)
+	stats.NewCounters("PromTestCounters").Add("Select", 3)
+	stats.NewGauges("PromTestGauges").Set("Updates", 2)
+	stats.Publish("PromTestGaugesFunc", stats.GaugesFunc(func() map[string]int64 {
+		return map[string]int64{"data": 5}
+	}))
+	stats.NewMultiCounters("PromTestMultiCounters", []string{"Keyspace", "ShardName"}).Add([]string{"ks", "0"}, 4)
+	stats.NewTimings("PromTestTimings").Add("Exec", 2*time.Millisecond)
+
+	buf := bytes.NewBuffer(nil)
+	WriteMetrics(buf)
+	output := buf.String()
+	for _, want := range []string{
+		"# TYPE vitess_prom_test_int gauge\nvitess_prom_test_int 12\n",
+		"vitess_prom_test_duration_seconds 1.5\n",
+		"# TYPE vitess_prom_test_counters counter\nvitess_prom_test_counters{category=\"Select\"} 3\n",
+		"# TYPE vitess_prom_test_gauges gauge\nvitess_prom_test_gauges{category=\"Updates\"} 2\n",
+		"# TYPE vitess_prom_test_gauges_func gauge\nvitess_prom_test_gauges_func{category=\"data\"} 5\n",
+		"vitess_prom_test_multi_counters{keyspace=\"ks\",shard_name=\"0\"} 4\n",
+		"# TYPE vitess_prom_test_timings_seconds histogram\n",
+		"vitess_prom_test_timings_seconds_bucket{category=\"Exec\",le=\"0.001\"} 0\n",
+		"vitess_prom_test_timings_seconds_bucket{category=\"Exec\",le=\"0.005\"} 1\n",
+		"vitess_prom_test_timings_seconds_bucket{category=\"Exec\",le=\"+Inf\"} 1\n",
+		"vitess_prom_test_timings_seconds_sum{category=\"Exec\"} 0.002\n",
+		"vitess_prom_test_timings_seconds_count{category=\"Exec\"} 1\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output is missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cluster brings up a local Vitess cluster for end-to-end
+// tests: a ZooKeeper topology server, a mysqld and a vttablet for
+// each tablet of a keyspace, and a vtgate. It uses the binaries in
+// $VTROOT/bin, and keeps its files under $VTDATAROOT, like the
+// python tests in test/.
+//
+// A typical test does:
+//
+//	c := cluster.New(cluster.Config{Shards: []string{"-80", "80-"}})
+//	if err := c.Start(); err != nil {
+//	  t.Fatalf("cannot start the cluster: %v", err)
+//	}
+//	defer c.Teardown()
+//	// send queries to localhost:c.Vtgate.Port
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/golang/glog"
+)
+
+// Config describes the cluster to bring up. The zero values are
+// replaced by defaults.
+type Config struct {
+	// Cell is the only cell of the cluster. Defaults to "test_nj".
+	Cell string
+
+	// Keyspace is the keyspace to create. Defaults to
+	// "test_keyspace".
+	Keyspace string
+
+	// Shards are the shards of the keyspace. Defaults to a single
+	// "0" shard.
+	Shards []string
+
+	// TabletsPerShard is the number of tablets of each shard,
+	// including the master. Defaults to 2.
+	TabletsPerShard int
+
+	// BaseUID is the uid of the first tablet. Defaults to 62344.
+	BaseUID uint32
+
+	// BasePort is the first port to use. Defaults to
+	// $VTPORTSTART, or 6700.
+	BasePort int
+
+	// BootstrapArchive, if set, is the bootstrap archive used to
+	// initialize the mysqlds, for the MySQL flavors that need
+	// another one than the mysqlctl default.
+	BootstrapArchive string
+
+	// ExtraMyCnf are the extra my.cnf files of the mysqlds.
+	// Defaults to config/mycnf/default-fast.cnf.
+	ExtraMyCnf []string
+
+	// Timeout is how long to wait for each step. Defaults to 60s.
+	Timeout time.Duration
+}
+
+// Cluster is a local Vitess cluster.
+type Cluster struct {
+	Config
+
+	// VtRoot and VtDataRoot come from the environment.
+	VtRoot     string
+	VtDataRoot string
+
+	// LogDir has the logs of all the processes.
+	LogDir string
+
+	Topo    *ZkTopo
+	Tablets []*Tablet
+	Vtgate  *Vtgate
+
+	mu       sync.Mutex
+	nextPort int
+}
+
+// New returns a Cluster for the config. It doesn't start anything.
+func New(config Config) *Cluster {
+	if config.Cell == "" {
+		config.Cell = "test_nj"
+	}
+	if config.Keyspace == "" {
+		config.Keyspace = "test_keyspace"
+	}
+	if len(config.Shards) == 0 {
+		config.Shards = []string{"0"}
+	}
+	if config.TabletsPerShard == 0 {
+		config.TabletsPerShard = 2
+	}
+	if config.BaseUID == 0 {
+		config.BaseUID = 62344
+	}
+	if config.BasePort == 0 {
+		config.BasePort = 6700
+		if p, err := strconv.Atoi(os.Getenv("VTPORTSTART")); err == nil {
+			config.BasePort = p
+		}
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 60 * time.Second
+	}
+
+	c := &Cluster{
+		Config:     config,
+		VtRoot:     os.Getenv("VTROOT"),
+		VtDataRoot: os.Getenv("VTDATAROOT"),
+		nextPort:   config.BasePort,
+	}
+	if c.VtDataRoot == "" {
+		c.VtDataRoot = "/vt"
+	}
+	c.LogDir = path.Join(c.VtDataRoot, "tmp")
+	if len(c.ExtraMyCnf) == 0 {
+		c.ExtraMyCnf = []string{path.Join(c.VtRoot, "src/github.com/youtube/vitess/config/mycnf/default-fast.cnf")}
+	}
+	c.Topo = newZkTopo(c)
+
+	uid := config.BaseUID
+	for _, shard := range config.Shards {
+		for i := 0; i < config.TabletsPerShard; i++ {
+			c.Tablets = append(c.Tablets, newTablet(c, uid, shard))
+			uid++
+		}
+	}
+	c.Vtgate = newVtgate(c)
+	return c
+}
+
+// Start brings up the whole cluster, and waits until all the
+// tablets are serving. The first tablet of each shard is the master.
+// If Start fails, Teardown cleans up what was started.
+func (c *Cluster) Start() error {
+	if c.VtRoot == "" {
+		return fmt.Errorf("VTROOT is not set")
+	}
+	if err := os.MkdirAll(c.LogDir, 0755); err != nil {
+		return err
+	}
+
+	if err := c.Topo.Setup(); err != nil {
+		return fmt.Errorf("cannot start the topology server: %v", err)
+	}
+	if _, err := c.Vtctl("CreateKeyspace", c.Keyspace); err != nil {
+		return err
+	}
+
+	if err := c.forEachTablet((*Tablet).InitMysql); err != nil {
+		return err
+	}
+	if err := c.forEachTablet((*Tablet).StartVttablet); err != nil {
+		return err
+	}
+	if err := c.forEachTablet((*Tablet).WaitForVars); err != nil {
+		return err
+	}
+	for _, shard := range c.Shards {
+		master := c.Master(shard)
+		if _, err := c.Vtctl("ReparentShard", "-force", c.Keyspace+"/"+shard, master.Alias()); err != nil {
+			return err
+		}
+	}
+	if err := c.forEachTablet(func(t *Tablet) error {
+		return t.WaitForState("SERVING")
+	}); err != nil {
+		return err
+	}
+	if _, err := c.Vtctl("RebuildKeyspaceGraph", c.Keyspace); err != nil {
+		return err
+	}
+
+	return c.Vtgate.Start()
+}
+
+// Teardown stops all the processes, and removes their data. It goes
+// on after an error, and returns the first one.
+func (c *Cluster) Teardown() error {
+	var errs []error
+	record := func(err error) {
+		if err != nil {
+			log.Warningf("cluster teardown: %v", err)
+			errs = append(errs, err)
+		}
+	}
+	record(c.Vtgate.Stop())
+	record(c.forEachTablet((*Tablet).Stop))
+	record(c.forEachTablet((*Tablet).TeardownMysql))
+	record(c.Topo.Teardown())
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// Master returns the master tablet of the shard.
+func (c *Cluster) Master(shard string) *Tablet {
+	for _, t := range c.Tablets {
+		if t.Shard == shard {
+			return t
+		}
+	}
+	return nil
+}
+
+// Vtctl runs a vtctl command, and returns its output.
+func (c *Cluster) Vtctl(args ...string) (string, error) {
+	return c.run("vtctl", append(append([]string{"-log_dir", c.LogDir}, c.Topo.Flags()...), args...)...)
+}
+
+// forEachTablet runs f on all the tablets in parallel, and returns
+// the first error.
+func (c *Cluster) forEachTablet(f func(*Tablet) error) error {
+	wg := sync.WaitGroup{}
+	errs := make(chan error, len(c.Tablets))
+	for _, t := range c.Tablets {
+		wg.Add(1)
+		go func(t *Tablet) {
+			defer wg.Done()
+			if err := f(t); err != nil {
+				errs <- fmt.Errorf("tablet %v: %v", t.Alias(), err)
+			}
+		}(t)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// reservePorts returns the first of count consecutive ports.
+func (c *Cluster) reservePorts(count int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := c.nextPort
+	c.nextPort += count
+	return result
+}
+
+// binary returns the path of a Vitess binary.
+func (c *Cluster) binary(name string) string {
+	return path.Join(c.VtRoot, "bin", name)
+}
+
+// env returns the environment of the processes we start.
+func (c *Cluster) env() []string {
+	return append(os.Environ(),
+		"VTDATAROOT="+c.VtDataRoot,
+		"EXTRA_MY_CNF="+strings.Join(c.ExtraMyCnf, ":"),
+		"ZK_CLIENT_CONFIG="+c.Topo.ClientConfig())
+}
+
+// run runs a binary until it exits, and returns its output.
+func (c *Cluster) run(name string, args ...string) (string, error) {
+	log.Infof("cluster: running %v %v", name, strings.Join(args, " "))
+	cmd := exec.Command(c.binary(name), args...)
+	cmd.Env = c.env()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("%v %v failed: %v: %s", name, strings.Join(args, " "), err, out)
+	}
+	return string(out), nil
+}
+
+// startProcess starts a binary in the background. Its stderr goes
+// to <logName>.stderr in the log directory.
+func (c *Cluster) startProcess(name, logName string, args ...string) (*exec.Cmd, error) {
+	log.Infof("cluster: starting %v %v", name, strings.Join(args, " "))
+	stderr, err := os.Create(path.Join(c.LogDir, logName+".stderr"))
+	if err != nil {
+		return nil, err
+	}
+	defer stderr.Close()
+	cmd := exec.Command(c.binary(name), args...)
+	cmd.Env = c.env()
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cannot start %v: %v", name, err)
+	}
+	return cmd, nil
+}
+
+// stopProcess sends SIGTERM to a process started by startProcess,
+// and waits for it to exit. It kills it after the timeout.
+func (c *Cluster) stopProcess(cmd *exec.Cmd) error {
+	if cmd == nil {
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		return err
+	}
+	select {
+	case <-done:
+		return nil
+	case <-time.After(c.Timeout):
+		cmd.Process.Kill()
+		return fmt.Errorf("process %v didn't stop after %v, killed it", cmd.Process.Pid, c.Timeout)
+	}
+}
+
+// waitFor calls f until it returns true, an error, or the timeout
+// expires.
+func (c *Cluster) waitFor(what string, f func() (bool, error)) error {
+	deadline := time.Now().Add(c.Timeout)
+	for {
+		done, err := f()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %v waiting for %v", c.Timeout, what)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// getVars returns the exported variables of the process listening
+// on the port, or nil if it doesn't answer yet.
+func getVars(port int) map[string]interface{} {
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%v/debug/vars", port))
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	var vars map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		return nil
+	}
+	return vars
+}
@@ -0,0 +1,65 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCluster(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the local cluster in short mode")
+	}
+	c := New(Config{Shards: []string{"-80", "80-"}})
+	if _, err := os.Stat(c.binary("vttablet")); err != nil {
+		t.Skipf("skipping the local cluster, the binaries are not built: %v", err)
+	}
+	if err := c.Start(); err != nil {
+		c.Teardown()
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer func() {
+		if err := c.Teardown(); err != nil {
+			t.Errorf("Teardown failed: %v", err)
+		}
+	}()
+
+	for _, tablet := range c.Tablets {
+		if vars := getVars(tablet.Port); vars["TabletStateName"] != "SERVING" {
+			t.Errorf("tablet %v is %v, want SERVING", tablet.Alias(), vars["TabletStateName"])
+		}
+	}
+	if _, err := c.Vtctl("GetSrvKeyspace", c.Cell, c.Keyspace); err != nil {
+		t.Errorf("GetSrvKeyspace failed: %v", err)
+	}
+	if getVars(c.Vtgate.Port) == nil {
+		t.Errorf("vtgate doesn't answer on port %v", c.Vtgate.Port)
+	}
+}
+
+func TestNew(t *testing.T) {
+	c := New(Config{Shards: []string{"-80", "80-"}, TabletsPerShard: 3, BasePort: 10000})
+	if len(c.Tablets) != 6 {
+		t.Fatalf("got %v tablets, want 6", len(c.Tablets))
+	}
+	if m := c.Master("80-"); m == nil || m.UID != 62347 {
+		t.Errorf("Master(80-) = %+v, want uid 62347", m)
+	}
+	ports := make(map[int]bool)
+	for _, p := range []int{c.Topo.ClientPort - 2, c.Topo.ClientPort - 1, c.Topo.ClientPort, c.Vtgate.Port} {
+		ports[p] = true
+	}
+	for _, tablet := range c.Tablets {
+		ports[tablet.Port] = true
+		ports[tablet.MysqlPort] = true
+	}
+	if len(ports) != 3+1+2*6 {
+		t.Errorf("ports are not all distinct: %v", ports)
+	}
+	if got, want := c.Tablets[0].Alias(), "test_nj-0000062344"; got != want {
+		t.Errorf("Alias() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"strconv"
+
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+// Tablet is a mysqld and the vttablet in front of it.
+type Tablet struct {
+	cluster *Cluster
+
+	UID      uint32
+	Keyspace string
+	Shard    string
+
+	// Port is the vttablet port, MysqlPort the mysqld port.
+	Port      int
+	MysqlPort int
+
+	cmd *exec.Cmd
+}
+
+func newTablet(c *Cluster, uid uint32, shard string) *Tablet {
+	port := c.reservePorts(2)
+	return &Tablet{
+		cluster:   c,
+		UID:       uid,
+		Keyspace:  c.Keyspace,
+		Shard:     shard,
+		Port:      port,
+		MysqlPort: port + 1,
+	}
+}
+
+// Alias returns the tablet alias, as vtctl expects it.
+func (t *Tablet) Alias() string {
+	return topo.TabletAlias{Cell: t.cluster.Cell, Uid: t.UID}.String()
+}
+
+// Dir returns the directory of the tablet, with the mysqld data.
+func (t *Tablet) Dir() string {
+	return path.Join(t.cluster.VtDataRoot, fmt.Sprintf("vt_%010d", t.UID))
+}
+
+// DbName returns the name of the database of the tablet.
+func (t *Tablet) DbName() string {
+	return "vt_" + t.Keyspace
+}
+
+// dbConfigFlags returns the flags for the users created by the
+// bootstrap archive.
+func (t *Tablet) dbConfigFlags() []string {
+	var flags []string
+	for _, name := range []string{"app", "dba", "filtered", "repl"} {
+		flags = append(flags,
+			"-db-config-"+name+"-uname", "vt_"+name,
+			"-db-config-"+name+"-charset", "utf8")
+	}
+	return append(flags,
+		"-db-config-app-dbname", t.DbName(),
+		"-db-config-repl-dbname", t.DbName())
+}
+
+// mysqlctl runs a mysqlctl command for the tablet.
+func (t *Tablet) mysqlctl(args ...string) error {
+	flags := []string{
+		"-log_dir", t.cluster.LogDir,
+		"-tablet_uid", strconv.FormatUint(uint64(t.UID), 10),
+		"-mysql_port", strconv.Itoa(t.MysqlPort),
+	}
+	flags = append(flags, t.dbConfigFlags()...)
+	_, err := t.cluster.run("mysqlctl", append(flags, args...)...)
+	return err
+}
+
+// InitMysql creates and starts the mysqld of the tablet.
+func (t *Tablet) InitMysql() error {
+	args := []string{"init"}
+	if t.cluster.BootstrapArchive != "" {
+		args = append(args, "-bootstrap_archive", t.cluster.BootstrapArchive)
+	}
+	return t.mysqlctl(args...)
+}
+
+// TeardownMysql stops the mysqld of the tablet, and removes its data.
+func (t *Tablet) TeardownMysql() error {
+	return t.mysqlctl("teardown", "-force")
+}
+
+// StartVttablet starts the vttablet in the background. It creates
+// the tablet record in the topology as a replica, so it only serves
+// after a master is elected.
+func (t *Tablet) StartVttablet() error {
+	args := []string{
+		"-port", strconv.Itoa(t.Port),
+		"-log_dir", t.cluster.LogDir,
+		"-pid_file", path.Join(t.Dir(), "vttablet.pid"),
+		"-tablet-path", t.Alias(),
+		"-init_keyspace", t.Keyspace,
+		"-init_shard", t.Shard,
+		"-init_tablet_type", "replica",
+	}
+	args = append(args, t.cluster.Topo.Flags()...)
+	args = append(args, t.dbConfigFlags()...)
+	cmd, err := t.cluster.startProcess("vttablet", fmt.Sprintf("vttablet-%v", t.UID), args...)
+	if err != nil {
+		return err
+	}
+	t.cmd = cmd
+	return nil
+}
+
+// WaitForVars waits until the vttablet answers on its port.
+func (t *Tablet) WaitForVars() error {
+	return t.cluster.waitFor("vttablet to answer", func() (bool, error) {
+		return getVars(t.Port) != nil, nil
+	})
+}
+
+// WaitForState waits until the query service of the vttablet is in
+// the given state, like "SERVING" or "NOT_SERVING".
+func (t *Tablet) WaitForState(state string) error {
+	return t.cluster.waitFor("vttablet state "+state, func() (bool, error) {
+		vars := getVars(t.Port)
+		return vars != nil && vars["TabletStateName"] == state, nil
+	})
+}
+
+// Stop stops the vttablet, if it was started.
+func (t *Tablet) Stop() error {
+	err := t.cluster.stopProcess(t.cmd)
+	t.cmd = nil
+	return err
+}
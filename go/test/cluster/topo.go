@@ -0,0 +1,86 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	"github.com/youtube/vitess/go/netutil"
+	"github.com/youtube/vitess/go/zk/zkctl"
+)
+
+// ZkTopo is a single-node ZooKeeper server, used as the topology
+// server of the cluster.
+type ZkTopo struct {
+	cluster *Cluster
+	zkd     *zkctl.Zkd
+
+	// ClientPort is the port the clients connect to. The two ports
+	// before it are the leader and election ports.
+	ClientPort int
+}
+
+func newZkTopo(c *Cluster) *ZkTopo {
+	return &ZkTopo{
+		cluster:    c,
+		ClientPort: c.reservePorts(3) + 2,
+	}
+}
+
+// ClientConfig returns the path of the file that maps the cells to
+// the server, for ZK_CLIENT_CONFIG.
+func (zt *ZkTopo) ClientConfig() string {
+	return path.Join(zt.cluster.LogDir, "test-zk-client-conf.json")
+}
+
+// Flags returns the flags that make a binary use this topology
+// server.
+func (zt *ZkTopo) Flags() []string {
+	return []string{"-topo_implementation", "zookeeper"}
+}
+
+// Setup starts the server, and creates the global and cell
+// directories.
+func (zt *ZkTopo) Setup() error {
+	hostname, err := netutil.FullyQualifiedHostname()
+	if err != nil {
+		return err
+	}
+	config := zkctl.MakeZkConfigFromString(fmt.Sprintf("1@%v:%v:%v:%v", hostname, zt.ClientPort-2, zt.ClientPort-1, zt.ClientPort), 1)
+	zt.zkd = zkctl.NewZkd(config)
+	if err := zt.zkd.Init(); err != nil {
+		return err
+	}
+
+	addr := fmt.Sprintf("localhost:%v", zt.ClientPort)
+	data, err := json.Marshal(map[string]string{
+		zt.cluster.Cell: addr,
+		"global":        addr,
+	})
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(zt.ClientConfig(), data, 0644); err != nil {
+		return err
+	}
+
+	for _, cell := range []string{"global", zt.cluster.Cell} {
+		if _, err := zt.cluster.run("zk", "touch", "-p", "/zk/"+cell+"/vt"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Teardown stops the server, and removes its data.
+func (zt *ZkTopo) Teardown() error {
+	if zt.zkd == nil {
+		return nil
+	}
+	return zt.zkd.Teardown()
+}
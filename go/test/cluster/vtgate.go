@@ -0,0 +1,52 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cluster
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// Vtgate is the vtgate of the cluster.
+type Vtgate struct {
+	cluster *Cluster
+
+	// Port serves both the RPCs and the http pages.
+	Port int
+
+	cmd *exec.Cmd
+}
+
+func newVtgate(c *Cluster) *Vtgate {
+	return &Vtgate{
+		cluster: c,
+		Port:    c.reservePorts(1),
+	}
+}
+
+// Start starts the vtgate, and waits until it answers.
+func (vtg *Vtgate) Start() error {
+	args := []string{
+		"-port", strconv.Itoa(vtg.Port),
+		"-log_dir", vtg.cluster.LogDir,
+		"-cell", vtg.cluster.Cell,
+	}
+	args = append(args, vtg.cluster.Topo.Flags()...)
+	cmd, err := vtg.cluster.startProcess("vtgate", "vtgate", args...)
+	if err != nil {
+		return err
+	}
+	vtg.cmd = cmd
+	return vtg.cluster.waitFor("vtgate to answer", func() (bool, error) {
+		return getVars(vtg.Port) != nil, nil
+	})
+}
+
+// Stop stops the vtgate, if it was started.
+func (vtg *Vtgate) Stop() error {
+	err := vtg.cluster.stopProcess(vtg.cmd)
+	vtg.cmd = nil
+	return err
+}
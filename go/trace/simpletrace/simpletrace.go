@@ -0,0 +1,260 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package simpletrace is a tracing plugin that records the timing of
+// every finished Span. Traces are propagated across processes, so all
+// the Spans created for a single client request share the same trace
+// ID. Finished Spans are sent to the SpanLogger stream log, and can
+// also be sent as JSON over UDP to an external collector.
+//
+// To use it, import this package in a plugin file of the binary.
+package simpletrace
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/streamlog"
+	"github.com/youtube/vitess/go/trace"
+	"github.com/youtube/vitess/go/vt/servenv"
+	"golang.org/x/net/context"
+)
+
+var (
+	traceLogHandler = flag.String("trace-log-stream-handler", "/debug/tracelog", "URL handler for streaming finished trace spans")
+	collectorAddr   = flag.String("trace_collector", "", "if set, finished trace spans are sent as JSON over UDP to this host:port")
+
+	// SpanLogger receives a *SpanRecord for each finished Span.
+	SpanLogger = streamlog.New("Span", 50)
+)
+
+func init() {
+	rand.Seed(time.Now().UnixNano())
+	trace.RegisterSpanFactory(factory{})
+
+	servenv.OnRun(func() {
+		SpanLogger.ServeLogs(*traceLogHandler, func(params url.Values, val interface{}) string {
+			record, ok := val.(*SpanRecord)
+			if !ok {
+				return fmt.Sprintf("Error: unexpected value of type %T in %s!", val, SpanLogger.Name())
+			}
+			return record.Format(params)
+		})
+		if *collectorAddr != "" {
+			startCollector(*collectorAddr)
+		}
+	})
+}
+
+// SpanRecord is the timing information of a finished Span.
+type SpanRecord struct {
+	TraceID     string
+	SpanID      string
+	ParentID    string
+	Kind        string
+	Label       string
+	StartTime   time.Time
+	EndTime     time.Time
+	Annotations map[string]interface{}
+}
+
+// Duration returns how long the Span took.
+func (sr *SpanRecord) Duration() time.Duration {
+	return sr.EndTime.Sub(sr.StartTime)
+}
+
+// Format returns a tab separated list of the record fields.
+func (sr *SpanRecord) Format(params url.Values) string {
+	annotations, err := json.Marshal(sr.Annotations)
+	if err != nil {
+		annotations = []byte(err.Error())
+	}
+	return fmt.Sprintf("%v\t%v\t%v\t%v\t%q\t%v\t%.6f\t%s\n",
+		sr.TraceID,
+		sr.SpanID,
+		sr.ParentID,
+		sr.Kind,
+		sr.Label,
+		sr.StartTime.Format(time.StampMicro),
+		sr.Duration().Seconds(),
+		annotations,
+	)
+}
+
+// span implements trace.Span.
+type span struct {
+	traceID  uint64
+	spanID   uint64
+	parentID uint64
+
+	mu          sync.Mutex
+	kind        string
+	label       string
+	startTime   time.Time
+	annotations map[string]interface{}
+}
+
+func newID() uint64 {
+	return uint64(rand.Int63())
+}
+
+func (s *span) start(kind, label string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kind = kind
+	s.label = label
+	s.startTime = time.Now()
+	s.annotations = nil
+}
+
+// StartLocal is part of the trace.Span interface.
+func (s *span) StartLocal(label string) {
+	s.start("local", label)
+}
+
+// StartClient is part of the trace.Span interface.
+func (s *span) StartClient(label string) {
+	s.start("client", label)
+}
+
+// StartServer is part of the trace.Span interface.
+func (s *span) StartServer(label string) {
+	s.start("server", label)
+}
+
+// Finish is part of the trace.Span interface.
+func (s *span) Finish() {
+	s.mu.Lock()
+	if s.startTime.IsZero() {
+		// never started, or a remote parent
+		s.mu.Unlock()
+		return
+	}
+	record := &SpanRecord{
+		TraceID:     formatID(s.traceID),
+		SpanID:      formatID(s.spanID),
+		Kind:        s.kind,
+		Label:       s.label,
+		StartTime:   s.startTime,
+		EndTime:     time.Now(),
+		Annotations: s.annotations,
+	}
+	s.annotations = nil
+	s.mu.Unlock()
+	if s.parentID != 0 {
+		record.ParentID = formatID(s.parentID)
+	}
+	SpanLogger.Send(record)
+}
+
+// Annotate is part of the trace.Span interface.
+func (s *span) Annotate(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.annotations == nil {
+		s.annotations = make(map[string]interface{})
+	}
+	s.annotations[key] = value
+}
+
+func formatID(id uint64) string {
+	return fmt.Sprintf("%016x", id)
+}
+
+// factory implements trace.RemoteSpanFactory.
+type factory struct{}
+
+type key int
+
+var spanKey key
+
+// New is part of the trace.SpanFactory interface.
+func (factory) New(parent trace.Span) trace.Span {
+	s := &span{spanID: newID()}
+	if p, ok := parent.(*span); ok {
+		s.traceID = p.traceID
+		s.parentID = p.spanID
+	} else {
+		s.traceID = newID()
+	}
+	return s
+}
+
+// FromContext is part of the trace.SpanFactory interface.
+func (factory) FromContext(ctx context.Context) (trace.Span, bool) {
+	s, ok := ctx.Value(spanKey).(*span)
+	return s, ok
+}
+
+// NewContext is part of the trace.SpanFactory interface.
+func (factory) NewContext(parent context.Context, s trace.Span) context.Context {
+	return context.WithValue(parent, spanKey, s)
+}
+
+// TraceID is part of the trace.RemoteSpanFactory interface.
+func (factory) TraceID(s trace.Span) string {
+	if s, ok := s.(*span); ok {
+		return formatID(s.traceID)
+	}
+	return ""
+}
+
+// Encode is part of the trace.RemoteSpanFactory interface.
+// The encoded form is '<trace id>:<span id>', in hexadecimal.
+func (factory) Encode(s trace.Span) string {
+	if s, ok := s.(*span); ok {
+		return formatID(s.traceID) + ":" + formatID(s.spanID)
+	}
+	return ""
+}
+
+// Decode is part of the trace.RemoteSpanFactory interface.
+func (factory) Decode(encoded string) (trace.Span, error) {
+	parts := strings.Split(encoded, ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid encoded span: %q", encoded)
+	}
+	traceID, err := strconv.ParseUint(parts[0], 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace id in %q: %v", encoded, err)
+	}
+	spanID, err := strconv.ParseUint(parts[1], 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid span id in %q: %v", encoded, err)
+	}
+	return &span{traceID: traceID, spanID: spanID}, nil
+}
+
+// startCollector sends all the finished Spans to addr, one JSON
+// encoded SpanRecord per UDP packet. Spans are dropped if the
+// collector cannot keep up.
+func startCollector(addr string) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		log.Errorf("cannot connect to trace collector %v: %v", addr, err)
+		return
+	}
+	ch := SpanLogger.Subscribe("TraceCollector")
+	go func() {
+		for val := range ch {
+			data, err := json.Marshal(val)
+			if err != nil {
+				log.Warningf("cannot marshal span: %v", err)
+				continue
+			}
+			if _, err := conn.Write(data); err != nil {
+				log.V(2).Infof("cannot send span to trace collector %v: %v", addr, err)
+			}
+		}
+	}()
+}
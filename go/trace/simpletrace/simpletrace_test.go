@@ -0,0 +1,92 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package simpletrace
+
+import (
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/trace"
+	"golang.org/x/net/context"
+)
+
+func nextRecord(t *testing.T, ch chan interface{}) *SpanRecord {
+	select {
+	case val := <-ch:
+		return val.(*SpanRecord)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for a span")
+	}
+	return nil
+}
+
+func TestSpans(t *testing.T) {
+	ch := SpanLogger.Subscribe("test")
+	defer SpanLogger.Unsubscribe(ch)
+
+	ctx := context.Background()
+	root := trace.NewSpanFromContext(ctx)
+	root.StartServer("root")
+	ctx = trace.NewContext(ctx, root)
+	traceID := trace.TraceID(ctx)
+	if traceID == "" {
+		t.Fatalf("TraceID() returned nothing")
+	}
+
+	child := trace.NewSpanFromContext(ctx)
+	child.StartClient("child")
+	child.Annotate("key", "value")
+	child.Finish()
+	root.Finish()
+
+	childRecord := nextRecord(t, ch)
+	rootRecord := nextRecord(t, ch)
+	if childRecord.Label != "child" || childRecord.Kind != "client" || childRecord.Annotations["key"] != "value" {
+		t.Errorf("unexpected child span: %#v", childRecord)
+	}
+	if rootRecord.Label != "root" || rootRecord.Kind != "server" || rootRecord.ParentID != "" {
+		t.Errorf("unexpected root span: %#v", rootRecord)
+	}
+	if childRecord.TraceID != traceID || rootRecord.TraceID != traceID {
+		t.Errorf("spans are not in trace %v: %v %v", traceID, childRecord.TraceID, rootRecord.TraceID)
+	}
+	if childRecord.ParentID != rootRecord.SpanID {
+		t.Errorf("child parent is %v, want %v", childRecord.ParentID, rootRecord.SpanID)
+	}
+}
+
+func TestRemoteSpan(t *testing.T) {
+	ch := SpanLogger.Subscribe("test")
+	defer SpanLogger.Unsubscribe(ch)
+
+	client := trace.NewSpan(nil)
+	client.StartClient("client")
+	clientCtx := trace.NewContext(context.Background(), client)
+	encoded := trace.EncodeSpan(clientCtx)
+
+	// this is what the remote process does
+	serverCtx := trace.NewContextFromEncodedSpan(context.Background(), encoded)
+	server := trace.NewSpanFromContext(serverCtx)
+	server.StartServer("server")
+	server.Finish()
+	client.Finish()
+
+	serverRecord := nextRecord(t, ch)
+	clientRecord := nextRecord(t, ch)
+	if serverRecord.TraceID != clientRecord.TraceID {
+		t.Errorf("server trace is %v, want %v", serverRecord.TraceID, clientRecord.TraceID)
+	}
+	if serverRecord.ParentID != clientRecord.SpanID {
+		t.Errorf("server parent is %v, want %v", serverRecord.ParentID, clientRecord.SpanID)
+	}
+
+	if _, err := (factory{}).Decode("not a span"); err == nil {
+		t.Errorf("Decode accepted an invalid span")
+	}
+	ctx := context.Background()
+	if got := trace.NewContextFromEncodedSpan(ctx, "zz:yy"); got != ctx {
+		t.Errorf("NewContextFromEncodedSpan used an invalid span")
+	}
+}
@@ -67,6 +67,55 @@ func CopySpan(parentCtx, spanCtx context.Context) context.Context {
 	return parentCtx
 }
 
+// TraceID returns the ID of the trace the Span from the given Context
+// belongs to, or "" if there is none, or the installed plugin doesn't
+// support remote spans.
+func TraceID(ctx context.Context) string {
+	span, ok := FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	rsf, ok := spanFactory.(RemoteSpanFactory)
+	if !ok {
+		return ""
+	}
+	return rsf.TraceID(span)
+}
+
+// EncodeSpan returns the Span from the given Context in a form that
+// can be sent to another process, or "" if there is none, or the
+// installed plugin doesn't support remote spans.
+func EncodeSpan(ctx context.Context) string {
+	span, ok := FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	rsf, ok := spanFactory.(RemoteSpanFactory)
+	if !ok {
+		return ""
+	}
+	return rsf.Encode(span)
+}
+
+// NewContextFromEncodedSpan returns a context based on parent, with a
+// Span representing the remote Span encoded by EncodeSpan. New Spans
+// created from the returned context are part of the remote trace.
+// If encoded is empty or cannot be decoded, parent is returned.
+func NewContextFromEncodedSpan(parent context.Context, encoded string) context.Context {
+	if encoded == "" {
+		return parent
+	}
+	rsf, ok := spanFactory.(RemoteSpanFactory)
+	if !ok {
+		return parent
+	}
+	span, err := rsf.Decode(encoded)
+	if err != nil {
+		return parent
+	}
+	return NewContext(parent, span)
+}
+
 // SpanFactory is an interface for creating spans or extracting them from Contexts.
 type SpanFactory interface {
 	New(parent Span) Span
@@ -74,6 +123,20 @@ type SpanFactory interface {
 	NewContext(parent context.Context, span Span) context.Context
 }
 
+// RemoteSpanFactory is implemented by the SpanFactory of plugins that
+// can propagate a trace across processes. The encoded form of a Span
+// is sent along with the RPCs, so the remote process can create Spans
+// that are part of the same trace.
+type RemoteSpanFactory interface {
+	SpanFactory
+	// TraceID returns the ID of the trace span belongs to.
+	TraceID(span Span) string
+	// Encode returns span in a form that can be sent to another process.
+	Encode(span Span) string
+	// Decode returns a Span representing an encoded remote Span.
+	Decode(encoded string) (Span, error)
+}
+
 var spanFactory SpanFactory = fakeSpanFactory{}
 
 // RegisterSpanFactory should be called by a plugin during init() to install a
@@ -24,3 +24,19 @@ func TestFakeSpan(t *testing.T) {
 	NewContext(ctx, span)
 	CopySpan(ctx, ctx)
 }
+
+func TestFakeSpanRemote(t *testing.T) {
+	ctx := context.Background()
+	RegisterSpanFactory(fakeSpanFactory{})
+
+	// The fake factory doesn't support remote spans.
+	if got := TraceID(ctx); got != "" {
+		t.Errorf("TraceID() = %q, want \"\"", got)
+	}
+	if got := EncodeSpan(ctx); got != "" {
+		t.Errorf("EncodeSpan() = %q, want \"\"", got)
+	}
+	if got := NewContextFromEncodedSpan(ctx, "1:2"); got != ctx {
+		t.Errorf("NewContextFromEncodedSpan() returned a new context")
+	}
+}
@@ -405,3 +405,10 @@ func UpdateBlpCheckpoint(uid uint32, pos myproto.ReplicationPosition, timeUpdate
 func QueryBlpCheckpoint(index uint32) string {
 	return fmt.Sprintf("SELECT pos, flags FROM _vt.blp_checkpoint WHERE source_shard_uid=%v", index)
 }
+
+// QueryBlpTransactionTimes returns a statement to query the current
+// time of the database, and the time of the last transaction applied
+// for all the shards of the _vt.blp_checkpoint table.
+func QueryBlpTransactionTimes() string {
+	return "SELECT UNIX_TIMESTAMP(), transaction_timestamp FROM _vt.blp_checkpoint"
+}
@@ -29,7 +29,7 @@ var usStateNames = map[int64]string{
 }
 
 var (
-	streamCount          = stats.NewCounters("UpdateStreamStreamCount")
+	streamCount          = stats.NewGauges("UpdateStreamStreamCount")
 	updateStreamErrors   = stats.NewCounters("UpdateStreamErrors")
 	updateStreamEvents   = stats.NewCounters("UpdateStreamEvents")
 	keyrangeStatements   = stats.NewInt("UpdateStreamKeyRangeStatements")
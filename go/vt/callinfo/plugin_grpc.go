@@ -0,0 +1,55 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package callinfo
+
+import (
+	"fmt"
+	"html/template"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// GRPCCallInfo takes a context generated by gRPC, and returns one
+// that has CallInfo filled in. The username is the common name of
+// the client certificate, if the server verified it.
+func GRPCCallInfo(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	ci := &gRPCCallInfoImpl{}
+	if p.Addr != nil {
+		ci.remoteAddr = p.Addr.String()
+	}
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		state := tlsInfo.State
+		if len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+			ci.username = state.VerifiedChains[0][0].Subject.CommonName
+		}
+	}
+	return NewContext(ctx, ci)
+}
+
+type gRPCCallInfoImpl struct {
+	remoteAddr, username string
+}
+
+func (gci *gRPCCallInfoImpl) RemoteAddr() string {
+	return gci.remoteAddr
+}
+
+func (gci *gRPCCallInfoImpl) Username() string {
+	return gci.username
+}
+
+func (gci *gRPCCallInfoImpl) Text() string {
+	return fmt.Sprintf("%s@%s", gci.username, gci.remoteAddr)
+}
+
+func (gci *gRPCCallInfoImpl) HTML() template.HTML {
+	return template.HTML("<b>RemoteAddr:</b> " + gci.remoteAddr + "</br>\n" + "<b>Username:</b> " + gci.username + "</br>\n")
+}
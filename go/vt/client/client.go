@@ -33,6 +33,9 @@ type drv struct {
 // For example "replica" means eventually consistent reads, while
 // "master" supports transactions and gives you read-after-write consistency.
 // timeout is specified in nanoseconds. It applies for all operations.
+// The optional read_your_writes and max_replication_lag (in
+// nanoseconds) set the vtgateconn.SessionOptions of the queries
+// outside of the transactions.
 func (d drv) Open(name string) (driver.Conn, error) {
 	c := &conn{TabletType: "master"}
 	err := json.Unmarshal([]byte(name), c)
@@ -52,8 +55,13 @@ type conn struct {
 	TabletType topo.TabletType `json:"tablet_type"`
 	Streaming  bool
 	Timeout    time.Duration
-	vtgateConn vtgateconn.VTGateConn
-	tx         vtgateconn.VTGateTx
+	// ReadYourWrites and MaxReplicationLag are the options of the
+	// session.
+	ReadYourWrites    bool          `json:"read_your_writes"`
+	MaxReplicationLag time.Duration `json:"max_replication_lag"`
+	vtgateConn        vtgateconn.VTGateConn
+	session           vtgateconn.VTGateSession
+	tx                vtgateconn.VTGateTx
 }
 
 func (c *conn) dial() error {
@@ -63,7 +71,17 @@ func (c *conn) dial() error {
 	}
 	var err error
 	c.vtgateConn, err = dialer(context.Background(), c.Address, c.Timeout)
-	return err
+	if err != nil {
+		return err
+	}
+	// the queries outside of the transactions share a session, so
+	// that vtgate can give them the read-your-writes and the session
+	// functions.
+	c.session = c.vtgateConn.NewSession(vtgateconn.SessionOptions{
+		ReadYourWrites:    c.ReadYourWrites,
+		MaxReplicationLag: c.MaxReplicationLag,
+	})
+	return nil
 }
 
 func (c *conn) Prepare(query string) (driver.Stmt, error) {
@@ -135,7 +153,7 @@ func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
 		return nil, errors.New("Exec not allowed for streaming connections")
 	}
 	if s.c.tx == nil {
-		qr, err = s.c.vtgateConn.Execute(ctx, s.query, makeBindVars(args), s.c.TabletType)
+		qr, err = s.c.session.Execute(ctx, s.query, makeBindVars(args), s.c.TabletType)
 	} else {
 		qr, err = s.c.tx.Execute(ctx, s.query, makeBindVars(args), s.c.TabletType)
 	}
@@ -155,7 +173,7 @@ func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
 	var qr *mproto.QueryResult
 	var err error
 	if s.c.tx == nil {
-		qr, err = s.c.vtgateConn.Execute(ctx, s.query, makeBindVars(args), s.c.TabletType)
+		qr, err = s.c.session.Execute(ctx, s.query, makeBindVars(args), s.c.TabletType)
 	} else {
 		qr, err = s.c.tx.Execute(ctx, s.query, makeBindVars(args), s.c.TabletType)
 	}
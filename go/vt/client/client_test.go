@@ -88,6 +88,7 @@ func TestDial(t *testing.T) {
 	newc := *(c.(*conn))
 	newc.Address = ""
 	newc.vtgateConn = nil
+	newc.session = nil
 	if !reflect.DeepEqual(&newc, wantc) {
 		t.Errorf("conn: %+v, want %+v", &newc, wantc)
 	}
@@ -23,6 +23,16 @@ func (f *fakeVTGateService) Execute(ctx context.Context, query *proto.Query, rep
 	if !ok {
 		return fmt.Errorf("no match for: %s", query.Sql)
 	}
+	// the queries outside of the transactions have the session of
+	// the connection, the streaming ones have none.
+	if query.Session != nil && !query.Session.InTransaction {
+		if !reflect.DeepEqual(query.Session, sessionOutsideTx) {
+			return fmt.Errorf("session mismatch: got %+v, want %+v", query.Session, sessionOutsideTx)
+		}
+		q := *query
+		q.Session = nil
+		query = &q
+	}
 	if !reflect.DeepEqual(query, execCase.execQuery) {
 		return fmt.Errorf("request mismatch: got %+v, want %+v", query, execCase.execQuery)
 	}
@@ -131,6 +141,11 @@ func (f *fakeVTGateService) SplitQuery(ctx context.Context, req *proto.SplitQuer
 	return nil
 }
 
+// Explain is part of the VTGateService interface
+func (f *fakeVTGateService) Explain(ctx context.Context, query *proto.Query, reply *proto.ExplainResult) error {
+	return nil
+}
+
 // HandlePanic is part of the VTGateService interface
 func (f *fakeVTGateService) HandlePanic(err *error) {
 	if x := recover(); x != nil {
@@ -222,9 +237,15 @@ var result1 = mproto.QueryResult{
 	},
 }
 
+var sessionOutsideTx = &proto.Session{
+	ShardSessions: []*proto.ShardSession{},
+	Savepoints:    []string{},
+}
+
 var session1 = &proto.Session{
 	InTransaction: true,
 	ShardSessions: []*proto.ShardSession{},
+	Savepoints:    []string{},
 }
 
 var session2 = &proto.Session{
@@ -237,4 +258,5 @@ var session2 = &proto.Session{
 			TransactionId: 1,
 		},
 	},
+	Savepoints: []string{},
 }
@@ -17,6 +17,7 @@ import (
 	"github.com/youtube/vitess/go/vt/client2/tablet"
 	"github.com/youtube/vitess/go/vt/key"
 	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vtgate"
 	"github.com/youtube/vitess/go/vt/zktopo"
 	"github.com/youtube/vitess/go/zk"
 )
@@ -70,6 +71,10 @@ type ShardedConn struct {
 	// Sorted list of the max keys for each shard.
 	shardMaxKeys []key.KeyspaceId
 	conns        []*tablet.VtConn
+	// balancers pick the tablet to connect to for each shard, and
+	// connEndPoints are the tablets of conns.
+	balancers     []*vtgate.Balancer
+	connEndPoints []topo.EndPoint
 
 	timeout time.Duration // How long should we wait for a given operation?
 
@@ -105,12 +110,15 @@ func (sc *ShardedConn) Close() error {
 		sc.rollback()
 	}
 
-	for _, conn := range sc.conns {
+	for i, conn := range sc.conns {
 		if conn != nil {
+			sc.balancers[i].Disconnected(sc.connEndPoints[i])
 			conn.Close()
 		}
 	}
 	sc.conns = nil
+	sc.balancers = nil
+	sc.connEndPoints = nil
 	sc.srvKeyspace = nil
 	sc.shardMaxKeys = nil
 	return nil
@@ -125,6 +133,11 @@ func (sc *ShardedConn) readKeyspace() error {
 	}
 
 	sc.conns = make([]*tablet.VtConn, len(sc.srvKeyspace.Partitions[sc.tabletType].ShardReferences))
+	sc.connEndPoints = make([]topo.EndPoint, len(sc.srvKeyspace.Partitions[sc.tabletType].ShardReferences))
+	sc.balancers = make([]*vtgate.Balancer, len(sc.srvKeyspace.Partitions[sc.tabletType].ShardReferences))
+	for i, shardReference := range sc.srvKeyspace.Partitions[sc.tabletType].ShardReferences {
+		sc.balancers[i] = sc.newBalancer(shardReference.Name)
+	}
 	sc.shardMaxKeys = make([]key.KeyspaceId, len(sc.srvKeyspace.Partitions[sc.tabletType].ShardReferences))
 
 	for i, shardReference := range sc.srvKeyspace.Partitions[sc.tabletType].ShardReferences {
@@ -525,25 +538,58 @@ func (sc *ShardedConn) ExecuteBatch(queryList []ClientQuery, keyVal interface{})
 }
 */
 
-func (sc *ShardedConn) dial(shardIdx int) (conn *tablet.VtConn, err error) {
-	shardReference := &(sc.srvKeyspace.Partitions[sc.tabletType].ShardReferences[shardIdx])
-	addrs, err := sc.ts.GetEndPoints(sc.cell, sc.keyspace, shardReference.Name, sc.tabletType)
-	if err != nil {
-		return nil, fmt.Errorf("vt: GetEndPoints failed %v", err)
+// markDownDelay is how long a tablet we could not connect to is not
+// used.
+const markDownDelay = time.Second
+
+// newBalancer returns the Balancer of a shard. It uses the policy of
+// the -balancer_policy flag, like vtgate.
+func (sc *ShardedConn) newBalancer(shard string) *vtgate.Balancer {
+	getEndPoints := func() (*topo.EndPoints, error) {
+		addrs, err := sc.ts.GetEndPoints(sc.cell, sc.keyspace, shard, sc.tabletType)
+		if err != nil {
+			return nil, fmt.Errorf("vt: GetEndPoints failed %v", err)
+		}
+		return addrs, nil
 	}
+	blc := vtgate.NewBalancer(getEndPoints, markDownDelay)
+	for _, cell := range vtgate.BalancerFallbackCells(sc.cell) {
+		cell := cell
+		blc.AddFallbackCell(cell, func() (*topo.EndPoints, error) {
+			return sc.ts.GetEndPoints(cell, sc.keyspace, shard, sc.tabletType)
+		})
+	}
+	return blc
+}
 
-	srvs, err := topo.SrvEntries(addrs, "")
+func (sc *ShardedConn) dial(shardIdx int) (conn *tablet.VtConn, err error) {
+	shardReference := &(sc.srvKeyspace.Partitions[sc.tabletType].ShardReferences[shardIdx])
+	blc := sc.balancers[shardIdx]
+	endPoints, err := blc.Get()
 	if err != nil {
 		return nil, err
 	}
+	if len(endPoints) == 0 {
+		return nil, fmt.Errorf("vt: no valid endpoint for %v/%v", sc.keyspace, shardReference.Name)
+	}
 
-	// Try to connect to any address.
-	for _, srv := range srvs {
-		name := topo.SrvAddr(srv) + "/" + sc.keyspace + "/" + shardReference.Name
+	// Try to connect to any address, in the order of the balancer.
+	for _, endPoint := range endPoints {
+		port := endPoint.NamedPortMap[topo.DefaultPortName]
+		if port == 0 {
+			continue
+		}
+		name := fmt.Sprintf("%v:%v/%v/%v", endPoint.Host, port, sc.keyspace, shardReference.Name)
 		conn, err = tablet.DialVtdb(name, sc.stream, tablet.DefaultTimeout)
 		if err == nil {
+			blc.Connected(endPoint)
+			sc.connEndPoints[shardIdx] = endPoint
 			return conn, nil
 		}
+		blc.MarkDown(endPoint.Uid, err.Error())
+	}
+	if err == nil {
+		err = fmt.Errorf("vt: no endpoint with a %v port for %v/%v", topo.DefaultPortName, sc.keyspace, shardReference.Name)
 	}
 	return nil, err
 }
@@ -133,7 +133,7 @@ func (conn *Conn) Close() error {
 
 func (conn *Conn) Exec(query string, bindVars map[string]interface{}) (db.Result, error) {
 	if conn.stream {
-		sr, errFunc, err := conn.tabletConn.StreamExecute(context.TODO(), query, bindVars, conn.TransactionId)
+		sr, errFunc, err := conn.tabletConn.StreamExecute(context.TODO(), query, bindVars, conn.TransactionId, nil)
 		if err != nil {
 			return nil, conn.fmtErr(err)
 		}
@@ -145,7 +145,7 @@ func (conn *Conn) Exec(query string, bindVars map[string]interface{}) (db.Result
 		return &StreamResult{errFunc, sr, cols, nil, 0, nil}, nil
 	}
 
-	qr, err := conn.tabletConn.Execute(context.TODO(), query, bindVars, conn.TransactionId)
+	qr, err := conn.tabletConn.Execute(context.TODO(), query, bindVars, conn.TransactionId, nil)
 	if err != nil {
 		return nil, conn.fmtErr(err)
 	}
@@ -156,7 +156,7 @@ func (conn *Conn) Begin() (db.Tx, error) {
 	if conn.TransactionId != 0 {
 		return &Tx{}, ErrNoNestedTxn
 	}
-	if transactionId, err := conn.tabletConn.Begin(context.TODO()); err != nil {
+	if transactionId, err := conn.tabletConn.Begin(context.TODO(), nil); err != nil {
 		return &Tx{}, conn.fmtErr(err)
 	} else {
 		conn.TransactionId = transactionId
@@ -10,9 +10,16 @@ package dbconfigs
 // link with this library, so we should be safe.
 
 import (
+	"bytes"
 	"errors"
 	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/jscfg"
@@ -20,11 +27,19 @@ import (
 
 var (
 	// generic flags
-	dbCredentialsServer = flag.String("db-credentials-server", "file", "db credentials server type (use 'file' for the file implementation)")
+	dbCredentialsServer = flag.String("db-credentials-server", "file", "db credentials server type (use 'file' for the file implementation, 'env' for the environment implementation, 'command' for the external command implementation)")
 
 	// 'file' implementation flags
 	dbCredentialsFile = flag.String("db-credentials-file", "", "db credentials file")
 
+	// 'env' implementation flags
+	dbCredentialsEnvPrefix = flag.String("db-credentials-env-prefix", "VT_DB_PASSWORD_", "prefix of the environment variables containing the db passwords")
+
+	// 'command' implementation flags
+	dbCredentialsCommand        = flag.String("db-credentials-command", "", "command to run to get the password of a db user, the user is passed as its only argument")
+	dbCredentialsCommandTTL     = flag.Duration("db-credentials-command-ttl", time.Minute, "how long the passwords returned by db-credentials-command are cached")
+	dbCredentialsCommandTimeout = flag.Duration("db-credentials-command-timeout", 10*time.Second, "how long db-credentials-command can run before it is killed")
+
 	// ErrUnknownUser is returned by credential server when the
 	// user doesn't exist
 	ErrUnknownUser = errors.New("unknown user")
@@ -56,10 +71,12 @@ func GetCredentialsServer() CredentialsServer {
 }
 
 // FileCredentialsServer is a simple implementation of CredentialsServer using
-// a json file. Protected by mu.
+// a json file. The file is read again when it changes, so passwords
+// can be rotated without a restart. Protected by mu.
 type FileCredentialsServer struct {
 	mu            sync.Mutex
 	dbCredentials map[string][]string
+	modTime       time.Time
 }
 
 // GetUserAndPassword is part of the CredentialsServer interface
@@ -71,22 +88,186 @@ func (fcs *FileCredentialsServer) GetUserAndPassword(user string) (string, strin
 		return "", "", ErrUnknownUser
 	}
 
-	// read the json file only once
-	if fcs.dbCredentials == nil {
-		fcs.dbCredentials = make(map[string][]string)
-		if err := jscfg.ReadJson(*dbCredentialsFile, &fcs.dbCredentials); err != nil {
-			log.Warningf("Failed to read dbCredentials file: %v", *dbCredentialsFile)
+	// read the json file the first time, and when it changes
+	fi, err := os.Stat(*dbCredentialsFile)
+	if err != nil {
+		if fcs.dbCredentials == nil {
+			log.Warningf("Failed to stat dbCredentials file: %v", *dbCredentialsFile)
 			return "", "", err
 		}
+		// keep using the last version we read
+	} else if fcs.dbCredentials == nil || !fi.ModTime().Equal(fcs.modTime) {
+		dbCredentials := make(map[string][]string)
+		if err := jscfg.ReadJson(*dbCredentialsFile, &dbCredentials); err != nil {
+			log.Warningf("Failed to read dbCredentials file: %v", *dbCredentialsFile)
+			if fcs.dbCredentials == nil {
+				return "", "", err
+			}
+		} else {
+			fcs.dbCredentials = dbCredentials
+			fcs.modTime = fi.ModTime()
+		}
 	}
 
 	passwd, ok := fcs.dbCredentials[user]
-	if !ok {
+	if !ok || len(passwd) == 0 {
 		return "", "", ErrUnknownUser
 	}
 	return user, passwd[0], nil
 }
 
+// EnvCredentialsServer is an implementation of CredentialsServer that
+// reads the password of a user from the environment variable named
+// after the user: the db-credentials-env-prefix followed by the user
+// name in upper case, with characters other than letters and digits
+// replaced by '_'. If the variable is not set or empty, the user is
+// unknown.
+type EnvCredentialsServer struct{}
+
+// envVariable returns the name of the environment variable for user.
+func envVariable(user string) string {
+	return *dbCredentialsEnvPrefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			return r
+		}
+		return '_'
+	}, user)
+}
+
+// GetUserAndPassword is part of the CredentialsServer interface
+func (ecs *EnvCredentialsServer) GetUserAndPassword(user string) (string, string, error) {
+	passwd := os.Getenv(envVariable(user))
+	if passwd == "" {
+		return "", "", ErrUnknownUser
+	}
+	return user, passwd, nil
+}
+
+// CommandCredentialsServer is an implementation of CredentialsServer
+// that runs an external command to get the password of a user, with
+// the user name as its only argument. The first line of the output
+// is the password. An exit status of 2 means the user is unknown.
+// The command is killed after db-credentials-command-timeout. The
+// passwords are cached for db-credentials-command-ttl, so they can
+// be rotated without a restart. Concurrent lookups of the same user
+// share one run of the command. Protected by mu, which is not held
+// while the command runs.
+type CommandCredentialsServer struct {
+	mu       sync.Mutex
+	cache    map[string]cachedPassword
+	inFlight map[string]*commandLookup
+}
+
+type cachedPassword struct {
+	passwd  string
+	expires time.Time
+}
+
+// commandLookup is a run of the command for a user. done is closed
+// when passwd and err are set.
+type commandLookup struct {
+	done   chan struct{}
+	passwd string
+	err    error
+}
+
+// GetUserAndPassword is part of the CredentialsServer interface
+func (ccs *CommandCredentialsServer) GetUserAndPassword(user string) (string, string, error) {
+	if *dbCredentialsCommand == "" {
+		return "", "", ErrUnknownUser
+	}
+
+	ccs.mu.Lock()
+	if cp, ok := ccs.cache[user]; ok && time.Now().Before(cp.expires) {
+		ccs.mu.Unlock()
+		return user, cp.passwd, nil
+	}
+	if lookup, ok := ccs.inFlight[user]; ok {
+		ccs.mu.Unlock()
+		<-lookup.done
+		if lookup.err != nil {
+			return "", "", lookup.err
+		}
+		return user, lookup.passwd, nil
+	}
+	lookup := &commandLookup{done: make(chan struct{})}
+	if ccs.inFlight == nil {
+		ccs.inFlight = make(map[string]*commandLookup)
+	}
+	ccs.inFlight[user] = lookup
+	ccs.mu.Unlock()
+
+	passwd, err := runCredentialsCommand(user)
+
+	ccs.mu.Lock()
+	delete(ccs.inFlight, user)
+	switch {
+	case err == nil:
+		if ccs.cache == nil {
+			ccs.cache = make(map[string]cachedPassword)
+		}
+		ccs.cache[user] = cachedPassword{
+			passwd:  passwd,
+			expires: time.Now().Add(*dbCredentialsCommandTTL),
+		}
+	case err == ErrUnknownUser:
+	default:
+		if cp, ok := ccs.cache[user]; ok {
+			// keep using the last password we got
+			log.Warningf("Failed to run dbCredentials command %v, using the cached password: %v", *dbCredentialsCommand, err)
+			passwd, err = cp.passwd, nil
+		}
+	}
+	ccs.mu.Unlock()
+
+	lookup.passwd, lookup.err = passwd, err
+	close(lookup.done)
+	if err != nil {
+		return "", "", err
+	}
+	return user, passwd, nil
+}
+
+// runCredentialsCommand runs the command for user, and returns the
+// first line of its output. The command runs in its own process
+// group, which is killed after db-credentials-command-timeout.
+func runCredentialsCommand(user string) (string, error) {
+	var output bytes.Buffer
+	cmd := exec.Command(*dbCredentialsCommand, user)
+	cmd.Stdout = &output
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("dbCredentials command %v failed: %v", *dbCredentialsCommand, err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(*dbCredentialsCommandTimeout):
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return "", fmt.Errorf("dbCredentials command %v timed out after %v", *dbCredentialsCommand, *dbCredentialsCommandTimeout)
+	}
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.ExitStatus() == 2 {
+				return "", ErrUnknownUser
+			}
+		}
+		return "", fmt.Errorf("dbCredentials command %v failed: %v", *dbCredentialsCommand, err)
+	}
+	return strings.SplitN(output.String(), "\n", 2)[0], nil
+}
+
 func init() {
 	AllCredentialsServers["file"] = &FileCredentialsServer{}
+	AllCredentialsServers["env"] = &EnvCredentialsServer{}
+	AllCredentialsServers["command"] = &CommandCredentialsServer{}
 }
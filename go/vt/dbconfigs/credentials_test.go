@@ -0,0 +1,149 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dbconfigs
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func checkPassword(t *testing.T, cs CredentialsServer, user, want string) {
+	_, passwd, err := cs.GetUserAndPassword(user)
+	if err != nil {
+		t.Fatalf("GetUserAndPassword(%v) failed: %v", user, err)
+	}
+	if passwd != want {
+		t.Errorf("GetUserAndPassword(%v) = %v, want %v", user, passwd, want)
+	}
+}
+
+func TestFileCredentialsServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	*dbCredentialsFile = path.Join(dir, "credentials.json")
+	defer func() { *dbCredentialsFile = "" }()
+
+	if err := ioutil.WriteFile(*dbCredentialsFile, []byte(`{"vt_app": ["pass1"]}`), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	fcs := &FileCredentialsServer{}
+	checkPassword(t, fcs, "vt_app", "pass1")
+	if _, _, err := fcs.GetUserAndPassword("vt_dba"); err != ErrUnknownUser {
+		t.Errorf("GetUserAndPassword(vt_dba) returned %v, want ErrUnknownUser", err)
+	}
+
+	// rotate the password, the file is read again
+	if err := ioutil.WriteFile(*dbCredentialsFile, []byte(`{"vt_app": ["pass2"]}`), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(*dbCredentialsFile, later, later); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	checkPassword(t, fcs, "vt_app", "pass2")
+
+	// a broken file keeps the last version
+	if err := ioutil.WriteFile(*dbCredentialsFile, []byte(`{"vt_app"`), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	checkPassword(t, fcs, "vt_app", "pass2")
+}
+
+func TestEnvCredentialsServer(t *testing.T) {
+	os.Setenv("VT_DB_PASSWORD_VT_APP", "secret")
+	defer os.Setenv("VT_DB_PASSWORD_VT_APP", "")
+
+	ecs := &EnvCredentialsServer{}
+	checkPassword(t, ecs, "vt_app", "secret")
+	if _, _, err := ecs.GetUserAndPassword("vt_dba"); err != ErrUnknownUser {
+		t.Errorf("GetUserAndPassword(vt_dba) returned %v, want ErrUnknownUser", err)
+	}
+}
+
+func TestCommandCredentialsServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	passwordFile := path.Join(dir, "password")
+	*dbCredentialsCommand = path.Join(dir, "get_password.sh")
+	defer func() { *dbCredentialsCommand = "" }()
+	script := "#!/bin/sh\nif [ \"$1\" != vt_app ]; then exit 2; fi\ncat " + passwordFile + "\n"
+	if err := ioutil.WriteFile(*dbCredentialsCommand, []byte(script), 0700); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := ioutil.WriteFile(passwordFile, []byte("pass1\n"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	ccs := &CommandCredentialsServer{}
+	checkPassword(t, ccs, "vt_app", "pass1")
+	if _, _, err := ccs.GetUserAndPassword("vt_dba"); err != ErrUnknownUser {
+		t.Errorf("GetUserAndPassword(vt_dba) returned %v, want ErrUnknownUser", err)
+	}
+
+	// the password is cached
+	if err := ioutil.WriteFile(passwordFile, []byte("pass2\n"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	checkPassword(t, ccs, "vt_app", "pass1")
+
+	// until it expires
+	*dbCredentialsCommandTTL = 0
+	defer func() { *dbCredentialsCommandTTL = time.Minute }()
+	ccs.cache["vt_app"] = cachedPassword{passwd: "pass1"}
+	checkPassword(t, ccs, "vt_app", "pass2")
+
+	// a failing command keeps the last password
+	os.Remove(passwordFile)
+	checkPassword(t, ccs, "vt_app", "pass2")
+}
+
+func TestCommandCredentialsServerTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	*dbCredentialsCommand = path.Join(dir, "get_password.sh")
+	defer func() { *dbCredentialsCommand = "" }()
+	*dbCredentialsCommandTimeout = 100 * time.Millisecond
+	defer func() { *dbCredentialsCommandTimeout = 10 * time.Second }()
+	// each run of the command appends to the runs file
+	script := "#!/bin/sh\necho run >> " + path.Join(dir, "runs") + "\nsleep 10\necho pass1\n"
+	if err := ioutil.WriteFile(*dbCredentialsCommand, []byte(script), 0700); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// the concurrent lookups share the run, and all time out
+	ccs := &CommandCredentialsServer{}
+	start := time.Now()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := ccs.GetUserAndPassword("vt_app"); err == nil || !strings.Contains(err.Error(), "timed out") {
+				t.Errorf("GetUserAndPassword returned %v, want a timeout", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("GetUserAndPassword took %v, the command should have been killed", d)
+	}
+	if runs, err := ioutil.ReadFile(path.Join(dir, "runs")); err != nil || strings.Count(string(runs), "run") != 1 {
+		t.Errorf("command runs: %q %v, want one run", runs, err)
+	}
+}
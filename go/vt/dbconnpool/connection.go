@@ -14,6 +14,7 @@ import (
 	"github.com/youtube/vitess/go/sqltypes"
 	"github.com/youtube/vitess/go/stats"
 	"github.com/youtube/vitess/go/vt/dbconfigs"
+	"github.com/youtube/vitess/go/vt/faultinject"
 )
 
 // DBConnection re-exposes sqldb.Conn with some wrapping to implement
@@ -35,9 +36,26 @@ func (dbc *DBConnection) handleError(err error) {
 	}
 }
 
+// injectFault returns the error to return for a query, if any. A
+// dropped query closes the connection, like a mysqld that went away.
+func (dbc *DBConnection) injectFault(query string) error {
+	switch faultinject.Inject(faultinject.Mysql) {
+	case faultinject.Drop:
+		err := &sqldb.SqlError{Num: 2013, Message: "Lost connection to MySQL server during query (injected)", Query: query}
+		dbc.handleError(err)
+		return err
+	case faultinject.Error:
+		return &sqldb.SqlError{Num: 1105, Message: "injected error", Query: query}
+	}
+	return nil
+}
+
 // ExecuteFetch is part of PoolConnection interface.
 func (dbc *DBConnection) ExecuteFetch(query string, maxrows int, wantfields bool) (*proto.QueryResult, error) {
 	defer dbc.mysqlStats.Record("Exec", time.Now())
+	if err := dbc.injectFault(query); err != nil {
+		return nil, err
+	}
 	mqr, err := dbc.Conn.ExecuteFetch(query, maxrows, wantfields)
 	if err != nil {
 		dbc.handleError(err)
@@ -46,9 +64,14 @@ func (dbc *DBConnection) ExecuteFetch(query string, maxrows int, wantfields bool
 	return mqr, nil
 }
 
-// ExecuteStreamFetch is part of PoolConnection interface.
+// ExecuteStreamFetch is part of PoolConnection interface. The
+// results passed to the callback, and their rows, are only valid
+// until it returns: their memory is reused for the next ones.
 func (dbc *DBConnection) ExecuteStreamFetch(query string, callback func(*proto.QueryResult) error, streamBufferSize int) error {
 	defer dbc.mysqlStats.Record("ExecStream", time.Now())
+	if err := dbc.injectFault(query); err != nil {
+		return err
+	}
 
 	err := dbc.Conn.ExecuteStreamFetch(query)
 	if err != nil {
@@ -63,36 +86,48 @@ func (dbc *DBConnection) ExecuteStreamFetch(query string, callback func(*proto.Q
 		return fmt.Errorf("stream send error: %v", err)
 	}
 
-	// then get all the rows, sending them as we reach a decent packet size
-	// start with a pre-allocated array of 256 rows capacity
-	qr := &proto.QueryResult{Rows: make([][]sqltypes.Value, 0, 256)}
+	// then get all the rows, sending them as we reach a decent packet
+	// size. If the connection can fetch into a RowBuffer, the memory
+	// of the rows is reused from batch to batch, and from query to
+	// query.
+	fetcher, _ := dbc.Conn.(sqldb.RowFetcher)
+	rb := sqldb.GetRowBuffer()
+	defer rb.Release()
+	qr := &proto.QueryResult{}
 	byteCount := 0
 	for {
-		row, err := dbc.FetchNext()
+		var row []sqltypes.Value
+		if fetcher != nil {
+			row, err = fetcher.FetchNextInto(rb)
+		} else {
+			row, err = dbc.FetchNext()
+		}
 		if err != nil {
 			return err
 		}
 		if row == nil {
 			break
 		}
-		qr.Rows = append(qr.Rows, row)
+		rb.Rows = append(rb.Rows, row)
 		for _, s := range row {
 			byteCount += len(s.Raw())
 		}
 
 		if byteCount >= streamBufferSize {
+			qr.Rows = rb.Rows
 			err = callback(qr)
 			if err != nil {
 				return err
 			}
 			// empty the rows so we start over, but we keep the
-			// same capacity
-			qr.Rows = qr.Rows[:0]
+			// same memory
+			rb.Reset()
 			byteCount = 0
 		}
 	}
 
-	if len(qr.Rows) > 0 {
+	if len(rb.Rows) > 0 {
+		qr.Rows = rb.Rows
 		err = callback(qr)
 		if err != nil {
 			return err
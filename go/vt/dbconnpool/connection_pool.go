@@ -29,6 +29,9 @@ var (
 // PoolConnection is the interface implemented by users of this specialized pool.
 type PoolConnection interface {
 	ExecuteFetch(query string, maxrows int, wantfields bool) (*proto.QueryResult, error)
+	// ExecuteStreamFetch calls callback with the fields, then with
+	// batches of about streamBufferSize bytes of rows. The results
+	// are only valid until the callback returns.
 	ExecuteStreamFetch(query string, callback func(*proto.QueryResult) error, streamBufferSize int) error
 	ID() int64
 	Close()
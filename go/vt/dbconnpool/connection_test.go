@@ -0,0 +1,143 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dbconnpool
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/youtube/vitess/go/bson"
+	"github.com/youtube/vitess/go/bytes2"
+	"github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqldb"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/stats"
+)
+
+// streamConn is a sqldb.Conn that streams generated rows, allocating
+// each of them like the mysql Connection does.
+type streamConn struct {
+	sqldb.Conn
+	rows  int
+	index int
+}
+
+func (sc *streamConn) ExecuteStreamFetch(query string) error {
+	sc.index = 0
+	return nil
+}
+
+func (sc *streamConn) Fields() []proto.Field {
+	return []proto.Field{{Name: "id", Type: 8}, {Name: "name", Type: 253}}
+}
+
+func (sc *streamConn) CloseResult() {
+}
+
+func (sc *streamConn) FetchNext() ([]sqltypes.Value, error) {
+	return sc.fetch(func(n, size int) ([]sqltypes.Value, []byte) {
+		return make([]sqltypes.Value, n), make([]byte, 0, size)
+	})
+}
+
+func (sc *streamConn) fetch(alloc func(n, size int) ([]sqltypes.Value, []byte)) ([]sqltypes.Value, error) {
+	if sc.index == sc.rows {
+		return nil, nil
+	}
+	row, arena := alloc(2, 64)
+	arena = strconv.AppendInt(arena, int64(sc.index), 10)
+	row[0] = sqltypes.MakeNumeric(arena)
+	start := len(arena)
+	arena = append(arena, "name of row "...)
+	arena = strconv.AppendInt(arena, int64(sc.index), 10)
+	row[1] = sqltypes.MakeString(arena[start:])
+	sc.index++
+	return row, nil
+}
+
+// pooledStreamConn also implements sqldb.RowFetcher.
+type pooledStreamConn struct {
+	streamConn
+}
+
+func (psc *pooledStreamConn) FetchNextInto(rb *sqldb.RowBuffer) ([]sqltypes.Value, error) {
+	return psc.fetch(rb.NewRow)
+}
+
+func TestExecuteStreamFetch(t *testing.T) {
+	want := &streamConn{rows: 1000}
+	want.ExecuteStreamFetch("")
+	var wantRows [][]sqltypes.Value
+	for {
+		row, _ := want.FetchNext()
+		if row == nil {
+			break
+		}
+		wantRows = append(wantRows, row)
+	}
+
+	for _, conn := range []sqldb.Conn{&streamConn{rows: 1000}, &pooledStreamConn{streamConn{rows: 1000}}} {
+		dbc := &DBConnection{conn, stats.NewTimings("")}
+		var rows [][]sqltypes.Value
+		batches := 0
+		err := dbc.ExecuteStreamFetch("select", func(qr *proto.QueryResult) error {
+			if qr.Fields != nil {
+				return nil
+			}
+			batches++
+			// The rows are reused after the callback, copy them.
+			for _, row := range qr.Rows {
+				copied := make([]sqltypes.Value, len(row))
+				for i, value := range row {
+					copied[i] = sqltypes.MakeString(append([]byte(nil), value.Raw()...))
+					if value.IsNumeric() {
+						copied[i] = sqltypes.MakeNumeric(append([]byte(nil), value.Raw()...))
+					}
+				}
+				rows = append(rows, copied)
+			}
+			return nil
+		}, 1024)
+		if err != nil {
+			t.Fatalf("ExecuteStreamFetch failed: %v", err)
+		}
+		if batches < 2 {
+			t.Errorf("%T: got %v batches, want several", conn, batches)
+		}
+		if !reflect.DeepEqual(rows, wantRows) {
+			t.Errorf("%T: got different rows than FetchNext", conn)
+		}
+	}
+}
+
+// benchmarkExecuteStreamFetch streams rows from conn, and encodes
+// them to BSON like the streaming RPCs.
+func benchmarkExecuteStreamFetch(b *testing.B, conn sqldb.Conn) {
+	b.ReportAllocs()
+	dbc := &DBConnection{conn, stats.NewTimings("")}
+	buf := bytes2.NewChunkedWriter(bson.DefaultBufferSize)
+	for i := 0; i < b.N; i++ {
+		err := dbc.ExecuteStreamFetch("select", func(qr *proto.QueryResult) error {
+			if err := bson.MarshalToBuffer(buf, qr); err != nil {
+				return err
+			}
+			_, err := buf.WriteTo(ioutil.Discard)
+			return err
+		}, 32*1024)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExecuteStreamFetch(b *testing.B) {
+	benchmarkExecuteStreamFetch(b, &streamConn{rows: 10000})
+}
+
+func BenchmarkExecuteStreamFetchRowBuffer(b *testing.B) {
+	benchmarkExecuteStreamFetch(b, &pooledStreamConn{streamConn{rows: 10000}})
+}
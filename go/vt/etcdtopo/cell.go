@@ -93,6 +93,16 @@ func (s *Server) getCellAddrs(cell string) ([]string, int64, error) {
 	return strings.Split(resp.Node.Value, ","), int64(resp.Node.ModifiedIndex), nil
 }
 
+// CreateCell implements topo.CellCreator. It saves the addresses of
+// the cell etcd servers in the global cluster.
+func (s *Server) CreateCell(cell string, addrs []string) error {
+	if len(addrs) == 0 {
+		return fmt.Errorf("the addresses of the etcd servers of cell %v are required", cell)
+	}
+	_, err := s.getGlobal().Create(cellFilePath(cell), strings.Join(addrs, ","), 0 /* ttl */)
+	return convertError(err)
+}
+
 func (s *Server) getGlobal() Client {
 	s._globalOnce.Do(func() {
 		if len(globalAddrs) == 0 {
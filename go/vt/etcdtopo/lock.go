@@ -5,9 +5,12 @@
 package etcdtopo
 
 import (
+	"flag"
 	"fmt"
 	"path"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/coreos/go-etcd/etcd"
 	log "github.com/golang/glog"
@@ -24,6 +27,104 @@ const (
 	openLockContents = "<open>"
 )
 
+var lockTTL = flag.Duration("etcd_lock_ttl", 30*time.Second, "lease duration of the etcd topology locks. The lease is refreshed while the lock is held, so a lock is only released by expiration if its holder died. 0 disables the leases.")
+
+// lockLease keeps a held lock alive, by refreshing its TTL.
+type lockLease struct {
+	stop chan struct{}
+	done chan struct{}
+
+	// mu protects index, the ModifiedIndex of the lock file.
+	mu    sync.Mutex
+	index uint64
+}
+
+// leaseKey identifies a held lock.
+type leaseKey struct {
+	client     Client
+	actionPath string
+}
+
+var (
+	leasesMutex sync.Mutex
+	leases      = make(map[leaseKey]*lockLease)
+)
+
+// lockTTLSeconds returns the TTL to use for lock files, in seconds.
+func lockTTLSeconds() uint64 {
+	if *lockTTL <= 0 {
+		return 0
+	}
+	if *lockTTL < time.Second {
+		return 1
+	}
+	return uint64(*lockTTL / time.Second)
+}
+
+// startLease starts refreshing the TTL of the lock file at lockPath,
+// until stopLease is called.
+func startLease(client Client, lockPath, actionPath, contents string, index uint64) {
+	ttl := lockTTLSeconds()
+	if ttl == 0 {
+		return
+	}
+	refreshInterval := *lockTTL / 3
+	l := &lockLease{
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+		index: index,
+	}
+	leasesMutex.Lock()
+	leases[leaseKey{client, actionPath}] = l
+	leasesMutex.Unlock()
+
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+			}
+
+			l.mu.Lock()
+			resp, err := client.CompareAndSwap(lockPath, contents, ttl, "" /* prevValue */, l.index)
+			if err == nil && resp.Node == nil {
+				err = ErrBadResponse
+			}
+			if err != nil {
+				l.mu.Unlock()
+				log.Errorf("cannot refresh the lease of lock %v, it may be lost: %v", actionPath, convertError(err))
+				return
+			}
+			l.index = resp.Node.ModifiedIndex
+			l.mu.Unlock()
+		}
+	}()
+}
+
+// stopLease stops refreshing a lock, and returns the current
+// ModifiedIndex of the lock file. It returns false if there is no
+// lease for the lock.
+func stopLease(client Client, actionPath string) (uint64, bool) {
+	key := leaseKey{client, actionPath}
+	leasesMutex.Lock()
+	l, ok := leases[key]
+	delete(leases, key)
+	leasesMutex.Unlock()
+	if !ok {
+		return 0, false
+	}
+
+	close(l.stop)
+	<-l.done
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.index, true
+}
+
 func initLockFile(client Client, dirPath string) error {
 	_, err := client.Set(path.Join(dirPath, lockFilename), openLockContents, 0 /* ttl */)
 	return convertError(err)
@@ -43,6 +144,11 @@ func initLockFile(client Client, dirPath string) error {
 // directories. That means any directory that might be locked with mustExist
 // should have a _Lock file created with initLockFile() as soon as the directory
 // is created.
+//
+// The lock file has a TTL (see the etcd_lock_ttl flag), refreshed
+// until unlock() is called, so the lock is released if its holder
+// dies. If the _Lock file of a mustExist lock expired, it is
+// created again, as long as the directory still exists.
 func lock(ctx context.Context, client Client, dirPath, contents string, mustExist bool) (string, error) {
 	lockPath := path.Join(dirPath, lockFilename)
 	var err, lockHeldErr error
@@ -67,11 +173,18 @@ func lock(ctx context.Context, client Client, dirPath, contents string, mustExis
 		var resp *etcd.Response
 		if mustExist {
 			// CAS will fail if the lock file isn't the magic "empty" value.
-			resp, err = client.CompareAndSwap(lockPath, contents, 0, /* ttl */
+			resp, err = client.CompareAndSwap(lockPath, contents, lockTTLSeconds(),
 				openLockContents /* prevValue */, 0 /* prevIndex */)
+			if convertError(err) == topo.ErrNoNode {
+				// The lock file may have expired.
+				if err = recreateLockFile(client, dirPath); err != nil {
+					return "", err
+				}
+				continue
+			}
 		} else {
 			// Create will fail if the lock file already exists.
-			resp, err = client.Create(lockPath, contents, 0 /* ttl */)
+			resp, err = client.Create(lockPath, contents, lockTTLSeconds())
 		}
 		if err == nil {
 			if resp.Node == nil {
@@ -82,7 +195,9 @@ func lock(ctx context.Context, client Client, dirPath, contents string, mustExis
 			// verify during unlock() that we only delete our own lock.
 			// Add the index at the end of the lockPath to form the actionPath.
 			lockID := strconv.FormatUint(resp.Node.ModifiedIndex, 10)
-			return path.Join(lockPath, lockID), nil
+			actionPath := path.Join(lockPath, lockID)
+			startLease(client, lockPath, actionPath, contents, resp.Node.ModifiedIndex)
+			return actionPath, nil
 		}
 
 		// If it fails for any reason other than lockHeldErr
@@ -103,6 +218,20 @@ func lock(ctx context.Context, client Client, dirPath, contents string, mustExis
 	}
 }
 
+// recreateLockFile creates the _Lock file of a mustExist lock again,
+// after it expired. It returns topo.ErrNoNode if the directory
+// doesn't exist anymore.
+func recreateLockFile(client Client, dirPath string) error {
+	if _, err := client.Get(dirPath, false /* sort */, false /* recursive */); err != nil {
+		return convertError(err)
+	}
+	_, err := client.Create(path.Join(dirPath, lockFilename), openLockContents, 0 /* ttl */)
+	if err := convertError(err); err != nil && err != topo.ErrNodeExists {
+		return err
+	}
+	return nil
+}
+
 // unlock releases a lock acquired by lock() on the given directory.
 // The string returned by lock() should be passed as the actionPath.
 //
@@ -121,6 +250,10 @@ func unlock(client Client, dirPath, actionPath string, mustExist bool) error {
 	if err != nil {
 		return fmt.Errorf("unlock: can't parse lock ID (%v) in actionPath (%v): %v", lockID, actionPath, err)
 	}
+	// The lease changed the index of the lock file if it refreshed it.
+	if index, ok := stopLease(client, actionPath); ok {
+		prevIndex = index
+	}
 	if mustExist {
 		_, err = client.CompareAndSwap(lockPath, openLockContents, /* value */
 			0 /* ttl */, "" /* prevValue */, prevIndex)
@@ -135,7 +268,7 @@ func unlock(client Client, dirPath, actionPath string, mustExist bool) error {
 }
 
 // waitForLock will start a watch on the lockPath and return nil iff the watch
-// returns an event saying the file was deleted. The waitIndex should be one
+// returns an event saying the file was deleted or expired. The waitIndex should be one
 // plus the index at which you last found that the lock was held, to ensure that
 // no delete actions are missed.
 //
@@ -160,6 +293,9 @@ func waitForLock(ctx context.Context, client Client, lockPath string, waitIndex
 		case err := <-watchErr:
 			return convertError(err)
 		case resp := <-watch:
+			if resp.Action == "expire" {
+				return nil
+			}
 			if mustExist {
 				if resp.Node != nil && resp.Node.Value == openLockContents {
 					return nil
@@ -0,0 +1,94 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package etcdtopo
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestLockLease(t *testing.T) {
+	*lockTTL = 30 * time.Millisecond
+	defer func() { *lockTTL = 30 * time.Second }()
+
+	client := newTestClient([]string{"global"})
+	ctx := context.Background()
+	dirPath := "/keyspaces/test_keyspace"
+	lockPath := path.Join(dirPath, lockFilename)
+	if err := initLockFile(client, dirPath); err != nil {
+		t.Fatalf("initLockFile failed: %v", err)
+	}
+
+	actionPath, err := lock(ctx, client, dirPath, "test", true /* mustExist */)
+	if err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	resp, err := client.Get(lockPath, false, false)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	index := resp.Node.ModifiedIndex
+
+	// the lease refreshes the lock file
+	time.Sleep(50 * time.Millisecond)
+	resp, err = client.Get(lockPath, false, false)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if resp.Node.ModifiedIndex == index {
+		t.Errorf("lock file was not refreshed")
+	}
+
+	// and unlock still works after the refreshes
+	if err := unlock(client, dirPath, actionPath, true /* mustExist */); err != nil {
+		t.Fatalf("unlock failed: %v", err)
+	}
+	resp, err = client.Get(lockPath, false, false)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if resp.Node.Value != openLockContents {
+		t.Errorf("lock file not released: %v", resp.Node.Value)
+	}
+}
+
+func TestLockExpired(t *testing.T) {
+	client := newTestClient([]string{"global"})
+	ctx := context.Background()
+	dirPath := "/keyspaces/test_keyspace"
+	if err := initLockFile(client, dirPath); err != nil {
+		t.Fatalf("initLockFile failed: %v", err)
+	}
+	if _, err := lock(ctx, client, dirPath, "dead holder", true /* mustExist */); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+
+	// the holder dies, and the lock file expires
+	if _, err := client.Delete(path.Join(dirPath, lockFilename), false); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// the lock can be taken again
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	actionPath, err := lock(ctx, client, dirPath, "test", true /* mustExist */)
+	if err != nil {
+		t.Fatalf("lock after expiration failed: %v", err)
+	}
+	if err := unlock(client, dirPath, actionPath, true /* mustExist */); err != nil {
+		t.Fatalf("unlock failed: %v", err)
+	}
+
+	// but not if the directory is gone
+	if _, err := client.Delete(dirPath, true); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := lock(ctx, client, dirPath, "test", true /* mustExist */); err == nil {
+		t.Errorf("lock on a deleted directory worked")
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package faultinject delays, drops or fails a percentage of the
+// topology reads, tablet RPCs and MySQL queries, to test the retry
+// and failover logic under controlled failures. It does nothing
+// unless the -fault_injection flag is set, which only tests should
+// do.
+//
+// The flag is a comma-separated list of rules
+// target:action:percent[:delay], for instance:
+//
+//	topo:error:5,tabletconn:delay:10:200ms,mysql:drop:1:1s
+//
+// The targets are topo, tabletconn and mysql. The actions are:
+//   - delay: the operation is delayed, and then runs normally.
+//   - drop: after the delay, if any, the operation fails as if the
+//     connection was lost.
+//   - error: after the delay, if any, the operation fails with an
+//     error.
+package faultinject
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/sync2"
+)
+
+var faultInjection = flag.String("fault_injection", "", "faults to inject, for tests only: a comma-separated list of target:action:percent[:delay], with target in topo, tabletconn, mysql and action in delay, drop, error")
+
+// The targets of the rules.
+const (
+	Topo       = "topo"
+	TabletConn = "tabletconn"
+	Mysql      = "mysql"
+)
+
+// Action is what happens to an operation.
+type Action int
+
+const (
+	// None means the operation runs normally.
+	None Action = iota
+
+	// Delay means the operation was delayed, and then runs
+	// normally. Inject already slept, so callers treat it like None.
+	Delay
+
+	// Drop means the operation fails as if the connection was
+	// lost.
+	Drop
+
+	// Error means the operation fails with an error.
+	Error
+)
+
+var actionNames = map[Action]string{
+	None:  "none",
+	Delay: "delay",
+	Drop:  "drop",
+	Error: "error",
+}
+
+func (a Action) String() string {
+	return actionNames[a]
+}
+
+// rule is one entry of the flag.
+type rule struct {
+	action  Action
+	percent float64
+	delay   time.Duration
+}
+
+// The values of state, so Inject doesn't take the mutex when there
+// are no rules.
+const (
+	stateUnknown int32 = iota
+	stateOff
+	stateOn
+)
+
+var (
+	state sync2.AtomicInt32
+
+	// mu protects the variables below.
+	mu     sync.Mutex
+	loaded bool
+	rules  map[string][]rule
+	random = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	injected = stats.NewCounters("FaultsInjected")
+)
+
+// parseRules parses the rules in the syntax of the flag.
+func parseRules(spec string) (map[string][]rule, error) {
+	result := make(map[string][]rule)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 && len(parts) != 4 {
+			return nil, fmt.Errorf("invalid fault injection rule %q, expected target:action:percent[:delay]", entry)
+		}
+		switch parts[0] {
+		case Topo, TabletConn, Mysql:
+		default:
+			return nil, fmt.Errorf("invalid fault injection target %q in %q", parts[0], entry)
+		}
+		r := rule{}
+		for a, name := range actionNames {
+			if a != None && name == parts[1] {
+				r.action = a
+			}
+		}
+		if r.action == None {
+			return nil, fmt.Errorf("invalid fault injection action %q in %q", parts[1], entry)
+		}
+		percent, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid fault injection percentage %q in %q", parts[2], entry)
+		}
+		r.percent = percent
+		if len(parts) == 4 {
+			if r.delay, err = time.ParseDuration(parts[3]); err != nil {
+				return nil, fmt.Errorf("invalid fault injection delay %q in %q: %v", parts[3], entry, err)
+			}
+		}
+		if r.action == Delay && r.delay == 0 {
+			return nil, fmt.Errorf("fault injection rule %q needs a delay", entry)
+		}
+		result[parts[0]] = append(result[parts[0]], r)
+	}
+	return result, nil
+}
+
+// Set replaces the rules, with the syntax of the flag. An empty
+// spec turns fault injection off.
+func Set(spec string) error {
+	r, err := parseRules(spec)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	setRules(r)
+	return nil
+}
+
+// setRules installs the rules. mu must be held.
+func setRules(r map[string][]rule) {
+	loaded = true
+	rules = r
+	if len(r) > 0 {
+		state.Set(stateOn)
+	} else {
+		state.Set(stateOff)
+	}
+}
+
+// load parses the flag the first time it's needed. Until the flags
+// are parsed, there are no rules. mu must be held.
+func load() {
+	if loaded || !flag.Parsed() {
+		return
+	}
+	r, err := parseRules(*faultInjection)
+	if err != nil {
+		log.Fatalf("invalid -fault_injection: %v", err)
+	}
+	if len(r) > 0 {
+		log.Warningf("fault injection is on: %v", *faultInjection)
+	}
+	setRules(r)
+}
+
+// Enabled returns true if there are rules for the target. Callers
+// use it to only wrap their objects when faults are injected.
+func Enabled(target string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	load()
+	return len(rules[target]) > 0
+}
+
+// Inject picks what happens to an operation on the target. It
+// sleeps for the delay of the rule itself, so callers only have to
+// turn Drop and Error into the errors their own clients expect.
+func Inject(target string) Action {
+	if state.Get() == stateOff {
+		return None
+	}
+	mu.Lock()
+	load()
+	var picked *rule
+	for i, r := range rules[target] {
+		if random.Float64()*100 < r.percent {
+			picked = &rules[target][i]
+			break
+		}
+	}
+	mu.Unlock()
+
+	if picked == nil {
+		return None
+	}
+	injected.Add(target+"."+picked.action.String(), 1)
+	if picked.delay > 0 {
+		time.Sleep(picked.delay)
+	}
+	return picked.action
+}
@@ -0,0 +1,75 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package faultinject
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRules(t *testing.T) {
+	got, err := parseRules("topo:error:5, tabletconn:delay:10:200ms,tabletconn:drop:1.5")
+	if err != nil {
+		t.Fatalf("parseRules failed: %v", err)
+	}
+	want := map[string][]rule{
+		Topo: {{action: Error, percent: 5}},
+		TabletConn: {
+			{action: Delay, percent: 10, delay: 200 * time.Millisecond},
+			{action: Drop, percent: 1.5},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRules = %v, want %v", got, want)
+	}
+
+	for _, spec := range []string{
+		"topo:error",
+		"memcache:error:5",
+		"topo:explode:5",
+		"topo:error:101",
+		"topo:error:5:soon",
+		"topo:delay:5",
+	} {
+		if _, err := parseRules(spec); err == nil {
+			t.Errorf("parseRules(%q) should have failed", spec)
+		}
+	}
+}
+
+func TestInject(t *testing.T) {
+	defer Set("")
+
+	if err := Set("mysql:error:100,topo:delay:100:10ms,tabletconn:drop:0"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if !Enabled(Mysql) || !Enabled(TabletConn) {
+		t.Errorf("Enabled should be true for mysql and tabletconn")
+	}
+	if got := Inject(Mysql); got != Error {
+		t.Errorf("Inject(mysql) = %v, want error", got)
+	}
+	if got := Inject(TabletConn); got != None {
+		t.Errorf("Inject(tabletconn) = %v, want none", got)
+	}
+	start := time.Now()
+	if got := Inject(Topo); got != Delay {
+		t.Errorf("Inject(topo) = %v, want delay", got)
+	}
+	if d := time.Now().Sub(start); d < 10*time.Millisecond {
+		t.Errorf("Inject(topo) only waited %v", d)
+	}
+	if got := injected.Counts()["mysql.error"]; got != 1 {
+		t.Errorf("FaultsInjected[mysql.error] = %v, want 1", got)
+	}
+
+	if err := Set(""); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if Enabled(Mysql) || Inject(Mysql) != None {
+		t.Errorf("fault injection should be off")
+	}
+}
@@ -0,0 +1,247 @@
+package janitor
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"golang.org/x/net/context"
+)
+
+var (
+	backupInterval    = flag.Duration("backup_interval", 24*time.Hour, "backup janitor: how often to take a backup of the shard")
+	backupRetention   = flag.Int("backup_retention", 7, "backup janitor: how many backups to keep, the snapshots of the older ones are deleted")
+	backupTabletType  = flag.String("backup_tablet_type", string(topo.TYPE_RDONLY), "backup janitor: the type of tablet to take backups from")
+	backupConcurrency = flag.Int("backup_concurrency", 4, "backup janitor: how many compression/checksum jobs to run simultaneously")
+	backupTimeout     = flag.Duration("backup_timeout", 2*time.Hour, "backup janitor: how long a single backup can take")
+)
+
+func init() {
+	Register("backup", &BackupJanitor{})
+}
+
+// BackupRecord describes a backup taken by the BackupJanitor.
+type BackupRecord struct {
+	Time         time.Time
+	Duration     time.Duration
+	TabletAlias  topo.TabletAlias
+	ParentAlias  topo.TabletAlias
+	ManifestPath string
+	Error        error
+}
+
+// BackupJanitor periodically takes a snapshot of a tablet in the
+// shard. Tablets of the configured type are used in turn, the one
+// with the oldest backup first, so each of them holds a recent
+// snapshot. The successful backups are recorded in the shard record,
+// and only the last backup_retention ones are kept: the snapshots of
+// the older ones are deleted from their tablets. The last records,
+// including the failed attempts, are also kept in memory for
+// inspection.
+type BackupJanitor struct {
+	wr       *wrangler.Wrangler
+	keyspace string
+	shard    string
+
+	mu      sync.Mutex
+	records []*BackupRecord
+}
+
+// Configure is part of the Janitor interface.
+func (bj *BackupJanitor) Configure(wr *wrangler.Wrangler, keyspace, shard string) error {
+	tabletType := topo.TabletType(*backupTabletType)
+	if !topo.IsTypeInList(tabletType, topo.SlaveTabletTypes) || tabletType == topo.TYPE_RESTORE {
+		return fmt.Errorf("invalid backup_tablet_type: %v", tabletType)
+	}
+	bj.wr = wr
+	bj.keyspace = keyspace
+	bj.shard = shard
+	return nil
+}
+
+// Run is part of the Janitor interface. It takes a backup if
+// the last successful one is older than backup_interval.
+func (bj *BackupJanitor) Run(active bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), *backupTimeout)
+	defer cancel()
+	backups, err := bj.wr.ListBackups(ctx, bj.keyspace, bj.shard)
+	if err != nil {
+		return err
+	}
+	if len(backups) > 0 && time.Since(backups[len(backups)-1].Time) < *backupInterval {
+		return nil
+	}
+
+	tablets, err := bj.wr.GetTabletsInShard(ctx, bj.keyspace, bj.shard)
+	if err != nil {
+		return err
+	}
+	alias, err := bj.pickTablet(tablets, topo.TabletType(*backupTabletType), backups)
+	if err != nil {
+		return err
+	}
+
+	if !active {
+		log.Infof("backup janitor: would take a backup of %v/%v on tablet %v", bj.keyspace, bj.shard, alias)
+		return nil
+	}
+
+	log.Infof("backup janitor: taking a backup of %v/%v on tablet %v", bj.keyspace, bj.shard, alias)
+	record := &BackupRecord{
+		Time:        time.Now(),
+		TabletAlias: alias,
+	}
+	reply, _, err := bj.wr.Snapshot(ctx, alias, false, *backupConcurrency, false)
+	record.Duration = time.Since(record.Time)
+	if err != nil {
+		record.Error = err
+	} else {
+		record.ParentAlias = reply.ParentAlias
+		record.ManifestPath = reply.ManifestPath
+	}
+	bj.addRecord(record, *backupRetention)
+	if err != nil {
+		return err
+	}
+	return bj.saveBackup(ctx, &topo.ShardBackup{
+		Time:         record.Time,
+		Duration:     record.Duration,
+		TabletAlias:  record.TabletAlias,
+		ParentAlias:  record.ParentAlias,
+		ManifestPath: record.ManifestPath,
+	}, *backupRetention)
+}
+
+// saveBackup records a backup in the shard, and deletes the snapshots
+// of the backups beyond retention. The previous backups of the same
+// tablet are dropped, the new snapshot replaced them. A backup whose
+// snapshot could not be deleted stays in the shard, so the deletion
+// is retried after the next backup.
+func (bj *BackupJanitor) saveBackup(ctx context.Context, backup *topo.ShardBackup, retention int) error {
+	si, err := topo.UpdateShardFields(ctx, bj.wr.TopoServer(), bj.keyspace, bj.shard, func(shard *topo.Shard) error {
+		var backups []*topo.ShardBackup
+		for _, b := range shard.Backups {
+			if b.TabletAlias != backup.TabletAlias {
+				backups = append(backups, b)
+			}
+		}
+		shard.Backups = append(backups, backup)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot record the backup in the shard: %v", err)
+	}
+
+	overwritten, expired := expiredBackups(si.Backups, retention)
+	removed := make(map[backupKey]bool)
+	for _, b := range overwritten {
+		removed[newBackupKey(b)] = true
+	}
+	for _, b := range expired {
+		if err := bj.wr.DeleteSnapshot(ctx, b.TabletAlias, b.ManifestPath); err != nil {
+			log.Warningf("backup janitor: cannot delete the snapshot %v of tablet %v, will retry: %v", b.ManifestPath, b.TabletAlias, err)
+			continue
+		}
+		log.Infof("backup janitor: deleted the expired snapshot %v of tablet %v", b.ManifestPath, b.TabletAlias)
+		removed[newBackupKey(b)] = true
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	_, err = topo.UpdateShardFields(ctx, bj.wr.TopoServer(), bj.keyspace, bj.shard, func(shard *topo.Shard) error {
+		var backups []*topo.ShardBackup
+		for _, b := range shard.Backups {
+			if !removed[newBackupKey(b)] {
+				backups = append(backups, b)
+			}
+		}
+		shard.Backups = backups
+		return nil
+	})
+	return err
+}
+
+// backupKey identifies a backup across reads of the shard record.
+type backupKey struct {
+	time        int64
+	tabletAlias topo.TabletAlias
+}
+
+func newBackupKey(b *topo.ShardBackup) backupKey {
+	return backupKey{time: b.Time.UnixNano(), tabletAlias: b.TabletAlias}
+}
+
+// expiredBackups splits the backups beyond retention in two: the ones
+// whose snapshot was overwritten by a more recent backup of the same
+// tablet (a tablet only holds its last snapshot), and the ones whose
+// snapshot is still on their tablet, and has to be deleted.
+func expiredBackups(backups []*topo.ShardBackup, retention int) (overwritten, expired []*topo.ShardBackup) {
+	if retention <= 0 || len(backups) <= retention {
+		return nil, nil
+	}
+	newer := make(map[topo.TabletAlias]bool)
+	for i := len(backups) - 1; i >= 0; i-- {
+		b := backups[i]
+		if i < len(backups)-retention {
+			if newer[b.TabletAlias] {
+				overwritten = append(overwritten, b)
+			} else {
+				expired = append(expired, b)
+			}
+		}
+		newer[b.TabletAlias] = true
+	}
+	return overwritten, expired
+}
+
+// Records returns the kept backup records, most recent last.
+func (bj *BackupJanitor) Records() []*BackupRecord {
+	bj.mu.Lock()
+	defer bj.mu.Unlock()
+	result := make([]*BackupRecord, len(bj.records))
+	copy(result, bj.records)
+	return result
+}
+
+// addRecord saves a record in memory, and only keeps the last
+// retention ones.
+func (bj *BackupJanitor) addRecord(record *BackupRecord, retention int) {
+	bj.mu.Lock()
+	defer bj.mu.Unlock()
+	bj.records = append(bj.records, record)
+	if retention > 0 && len(bj.records) > retention {
+		bj.records = bj.records[len(bj.records)-retention:]
+	}
+}
+
+// pickTablet returns the tablet of the provided type that has gone
+// the longest without a backup. Ties are broken by alias.
+func (bj *BackupJanitor) pickTablet(tablets []*topo.TabletInfo, tabletType topo.TabletType, backups []*topo.ShardBackup) (topo.TabletAlias, error) {
+	var candidates topo.TabletAliasList
+	for _, ti := range tablets {
+		if ti.Type == tabletType {
+			candidates = append(candidates, ti.Alias)
+		}
+	}
+	if len(candidates) == 0 {
+		return topo.TabletAlias{}, fmt.Errorf("no %v tablet in %v/%v to take a backup from", tabletType, bj.keyspace, bj.shard)
+	}
+	sort.Sort(candidates)
+
+	lastBackup := make(map[topo.TabletAlias]time.Time)
+	for _, b := range backups {
+		lastBackup[b.TabletAlias] = b.Time
+	}
+	result := candidates[0]
+	for _, alias := range candidates[1:] {
+		if lastBackup[alias].Before(lastBackup[result]) {
+			result = alias
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,173 @@
+package janitor
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
+	"github.com/youtube/vitess/go/vt/tabletmanager/faketmclient"
+	_ "github.com/youtube/vitess/go/vt/tabletmanager/gorpctmclient"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+	"golang.org/x/net/context"
+)
+
+func newTestTabletInfo(uid uint32, tabletType topo.TabletType) *topo.TabletInfo {
+	return topo.NewTabletInfo(&topo.Tablet{
+		Alias: topo.TabletAlias{Cell: "cell1", Uid: uid},
+		Type:  tabletType,
+	}, 0)
+}
+
+func TestBackupJanitorPickTablet(t *testing.T) {
+	bj := &BackupJanitor{keyspace: "ks", shard: "0"}
+	tablets := []*topo.TabletInfo{
+		newTestTabletInfo(1, topo.TYPE_MASTER),
+		newTestTabletInfo(3, topo.TYPE_RDONLY),
+		newTestTabletInfo(2, topo.TYPE_RDONLY),
+		newTestTabletInfo(4, topo.TYPE_REPLICA),
+	}
+
+	if _, err := bj.pickTablet(tablets, topo.TYPE_BATCH, nil); err == nil {
+		t.Errorf("pickTablet should have failed with no batch tablet")
+	}
+
+	// no backup yet, lowest alias first
+	alias, err := bj.pickTablet(tablets, topo.TYPE_RDONLY, nil)
+	if err != nil || alias.Uid != 2 {
+		t.Fatalf("pickTablet returned %v %v, expected uid 2", alias, err)
+	}
+
+	// then the tablet without a backup
+	backups := []*topo.ShardBackup{{Time: time.Now(), TabletAlias: alias}}
+	alias, err = bj.pickTablet(tablets, topo.TYPE_RDONLY, backups)
+	if err != nil || alias.Uid != 3 {
+		t.Fatalf("pickTablet returned %v %v, expected uid 3", alias, err)
+	}
+
+	// then the tablet with the oldest backup
+	backups = append(backups, &topo.ShardBackup{Time: time.Now(), TabletAlias: alias})
+	alias, err = bj.pickTablet(tablets, topo.TYPE_RDONLY, backups)
+	if err != nil || alias.Uid != 2 {
+		t.Fatalf("pickTablet returned %v %v, expected uid 2", alias, err)
+	}
+}
+
+func TestBackupJanitorRetention(t *testing.T) {
+	bj := &BackupJanitor{}
+	for i := 0; i < 5; i++ {
+		bj.addRecord(&BackupRecord{TabletAlias: topo.TabletAlias{Cell: "cell1", Uid: uint32(i)}}, 3)
+	}
+	records := bj.Records()
+	if len(records) != 3 || records[0].TabletAlias.Uid != 2 || records[2].TabletAlias.Uid != 4 {
+		t.Errorf("unexpected records after retention: %v", records)
+	}
+}
+
+func newTestShardBackup(uid uint32, hours int) *topo.ShardBackup {
+	return &topo.ShardBackup{
+		Time:         time.Unix(int64(hours)*3600, 0),
+		TabletAlias:  topo.TabletAlias{Cell: "cell1", Uid: uid},
+		ManifestPath: fmt.Sprintf("/snapshot/snapshot_manifest_%v.json", hours),
+	}
+}
+
+func TestExpiredBackups(t *testing.T) {
+	backups := []*topo.ShardBackup{
+		newTestShardBackup(1, 1),
+		newTestShardBackup(2, 2),
+		newTestShardBackup(1, 3),
+		newTestShardBackup(3, 4),
+		newTestShardBackup(2, 5),
+	}
+	if overwritten, expired := expiredBackups(backups, 5); overwritten != nil || expired != nil {
+		t.Errorf("expiredBackups within retention returned %v %v", overwritten, expired)
+	}
+
+	// Tablet 1 holds the backup of hour 3 only, tablet 2 has a
+	// kept backup.
+	overwritten, expired := expiredBackups(backups, 2)
+	if want := []*topo.ShardBackup{backups[1], backups[0]}; !reflect.DeepEqual(overwritten, want) {
+		t.Errorf("overwritten backups: %v, want %v", overwritten, want)
+	}
+	if want := []*topo.ShardBackup{backups[2]}; !reflect.DeepEqual(expired, want) {
+		t.Errorf("expired backups: %v, want %v", expired, want)
+	}
+}
+
+// deleteSnapshotClient records the DeleteSnapshot calls.
+type deleteSnapshotClient struct {
+	tmclient.TabletManagerClient
+	deleted []topo.TabletAlias
+}
+
+func (client *deleteSnapshotClient) DeleteSnapshot(ctx context.Context, tablet *topo.TabletInfo, args *actionnode.DeleteSnapshotArgs) error {
+	client.deleted = append(client.deleted, tablet.Alias)
+	return nil
+}
+
+func TestBackupJanitorSaveBackup(t *testing.T) {
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	tmc := &deleteSnapshotClient{TabletManagerClient: faketmclient.NewFakeTabletManagerClient()}
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmc, time.Second)
+	ctx := context.Background()
+	if err := ts.CreateKeyspace("ks", &topo.Keyspace{}); err != nil {
+		t.Fatalf("CreateKeyspace failed: %v", err)
+	}
+	if err := topo.CreateShard(ts, "ks", "0"); err != nil {
+		t.Fatalf("CreateShard failed: %v", err)
+	}
+	for _, uid := range []uint32{1, 2} {
+		if err := topo.CreateTablet(ctx, ts, &topo.Tablet{
+			Alias:    topo.TabletAlias{Cell: "cell1", Uid: uid},
+			Hostname: "localhost",
+			Keyspace: "ks",
+			Shard:    "0",
+			Type:     topo.TYPE_RDONLY,
+		}); err != nil {
+			t.Fatalf("CreateTablet failed: %v", err)
+		}
+	}
+
+	bj := &BackupJanitor{wr: wr, keyspace: "ks", shard: "0"}
+	for _, b := range []*topo.ShardBackup{
+		newTestShardBackup(1, 1),
+		newTestShardBackup(2, 2),
+		newTestShardBackup(1, 3),
+	} {
+		if err := bj.saveBackup(ctx, b, 2); err != nil {
+			t.Fatalf("saveBackup failed: %v", err)
+		}
+	}
+	// the first backup of tablet 1 was overwritten
+	if len(tmc.deleted) != 0 {
+		t.Errorf("unexpected deleted snapshots: %v", tmc.deleted)
+	}
+	backups, err := wr.ListBackups(ctx, "ks", "0")
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(backups) != 2 || backups[0].TabletAlias.Uid != 2 || backups[1].TabletAlias.Uid != 1 {
+		t.Errorf("unexpected backups: %v", backups)
+	}
+
+	// the backup of tablet 2 is deleted from the tablet
+	if err := bj.saveBackup(ctx, newTestShardBackup(3, 4), 2); err != nil {
+		t.Fatalf("saveBackup failed: %v", err)
+	}
+	if want := []topo.TabletAlias{{Cell: "cell1", Uid: 2}}; !reflect.DeepEqual(tmc.deleted, want) {
+		t.Errorf("deleted snapshots: %v, want %v", tmc.deleted, want)
+	}
+	backups, err = wr.ListBackups(ctx, "ks", "0")
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(backups) != 2 || backups[0].TabletAlias.Uid != 1 || backups[1].TabletAlias.Uid != 3 {
+		t.Errorf("unexpected backups: %v", backups)
+	}
+}
@@ -156,6 +156,79 @@ func ParseKeyRangeParts(start, end string) (KeyRange, error) {
 	return KeyRange{Start: s, End: e}, nil
 }
 
+// ParseShardName parses a shard name and returns its KeyRange. The
+// names without a '-' are the names of unsharded keyspaces, like "0",
+// and cover the entire space. The others are the hex start and end of
+// the range, like "-80" or "80-c0".
+func ParseShardName(name string) (KeyRange, error) {
+	if !strings.Contains(name, "-") {
+		return KeyRange{}, nil
+	}
+	parts := strings.Split(name, "-")
+	if len(parts) != 2 {
+		return KeyRange{}, fmt.Errorf("invalid shard name, can only contain one '-': %v", name)
+	}
+	kr, err := ParseKeyRangeParts(parts[0], parts[1])
+	if err != nil {
+		return KeyRange{}, err
+	}
+	if kr.End != MaxKey && kr.Start >= kr.End {
+		return KeyRange{}, fmt.Errorf("out of order keys: %v is not strictly smaller than %v", kr.Start.Hex(), kr.End.Hex())
+	}
+	return kr, nil
+}
+
+// ShardName returns the canonical shard name of the KeyRange: its
+// start and end in lower case hex, separated by a '-'.
+func (kr KeyRange) ShardName() string {
+	return string(kr.Start.Hex()) + "-" + string(kr.End.Hex())
+}
+
+// GenerateShardRanges returns the n KeyRanges of an n-way split of the
+// entire space. The boundaries are on one byte up to 256 shards, and
+// on two bytes up to 65536 shards. When n doesn't divide the space,
+// the shards differ in size by at most one unit.
+func GenerateShardRanges(n int) ([]KeyRange, error) {
+	var size, width int
+	switch {
+	case n <= 0:
+		return nil, fmt.Errorf("invalid number of shards: %v", n)
+	case n <= 1<<8:
+		size, width = 1<<8, 1
+	case n <= 1<<16:
+		size, width = 1<<16, 2
+	default:
+		return nil, fmt.Errorf("too many shards: %v, the maximum is %v", n, 1<<16)
+	}
+	boundary := func(i int) KeyspaceId {
+		if i == 0 || i == n {
+			return MinKey
+		}
+		b := make([]byte, 2)
+		binary.BigEndian.PutUint16(b, uint16(i*size/n))
+		return KeyspaceId(b[2-width:])
+	}
+	ranges := make([]KeyRange, n)
+	for i := range ranges {
+		ranges[i] = KeyRange{Start: boundary(i), End: boundary(i + 1)}
+	}
+	return ranges, nil
+}
+
+// GenerateShardNames returns the canonical shard names of an n-way
+// split of the entire space. See GenerateShardRanges.
+func GenerateShardNames(n int) ([]string, error) {
+	ranges, err := GenerateShardRanges(n)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(ranges))
+	for i, kr := range ranges {
+		names[i] = kr.ShardName()
+	}
+	return names, nil
+}
+
 // Returns true if the KeyRange does not cover the entire space.
 func (kr KeyRange) IsPartial() bool {
 	return !(kr.Start == MinKey && kr.End == MaxKey)
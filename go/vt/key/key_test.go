@@ -6,6 +6,7 @@ package key
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
 )
 
@@ -234,3 +235,69 @@ func TestIntersectOverlap(t *testing.T) {
 		}
 	}
 }
+
+func TestParseShardName(t *testing.T) {
+	goodTable := map[string]string{
+		"0":     "-",
+		"-":     "-",
+		"-80":   "-80",
+		"80-":   "80-",
+		"80-C0": "80-c0",
+		"-4000": "-4000",
+	}
+	for name, wanted := range goodTable {
+		kr, err := ParseShardName(name)
+		if err != nil {
+			t.Errorf("ParseShardName(%v) failed: %v", name, err)
+			continue
+		}
+		if got := kr.ShardName(); got != wanted {
+			t.Errorf("ParseShardName(%v).ShardName() = %v, want %v", name, got, wanted)
+		}
+	}
+	badTable := []string{
+		"-80-",
+		"80-40",
+		"80-80",
+		"8-",
+		"zz-",
+	}
+	for _, name := range badTable {
+		if _, err := ParseShardName(name); err == nil {
+			t.Errorf("ParseShardName(%v) didn't fail", name)
+		}
+	}
+}
+
+func TestGenerateShardNames(t *testing.T) {
+	table := map[int][]string{
+		1: {"-"},
+		2: {"-80", "80-"},
+		3: {"-55", "55-aa", "aa-"},
+		4: {"-40", "40-80", "80-c0", "c0-"},
+	}
+	for n, wanted := range table {
+		names, err := GenerateShardNames(n)
+		if err != nil {
+			t.Errorf("GenerateShardNames(%v) failed: %v", n, err)
+			continue
+		}
+		if !reflect.DeepEqual(names, wanted) {
+			t.Errorf("GenerateShardNames(%v) = %v, want %v", n, names, wanted)
+		}
+	}
+
+	names, err := GenerateShardNames(512)
+	if err != nil {
+		t.Fatalf("GenerateShardNames(512) failed: %v", err)
+	}
+	if len(names) != 512 || names[0] != "-0080" || names[1] != "0080-0100" || names[511] != "ff80-" {
+		t.Errorf("GenerateShardNames(512) = %v...%v", names[:2], names[511])
+	}
+
+	for _, n := range []int{0, -1, 65537} {
+		if _, err := GenerateShardRanges(n); err == nil {
+			t.Errorf("GenerateShardRanges(%v) didn't fail", n)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package key
+
+import "fmt"
+
+// ShardingSchemeValidator returns an error if params are not valid
+// parameters for a sharding scheme.
+type ShardingSchemeValidator func(params map[string]string) error
+
+var shardingSchemes = make(map[string]ShardingSchemeValidator)
+
+// RegisterShardingScheme registers a sharding scheme, the vindex
+// type that computes the keyspace ids of a keyspace. The vtgate
+// vindexes are registered by planbuilder.Register, so a binary that
+// validates the schemes imports them.
+// A duplicate scheme will generate a panic.
+func RegisterShardingScheme(scheme string, validate ShardingSchemeValidator) {
+	if _, ok := shardingSchemes[scheme]; ok {
+		panic(fmt.Sprintf("sharding scheme %s is already registered", scheme))
+	}
+	shardingSchemes[scheme] = validate
+}
+
+// ValidateShardingScheme returns an error if scheme is not a
+// registered sharding scheme, or params are not valid for it.
+func ValidateShardingScheme(scheme string, params map[string]string) error {
+	validate, ok := shardingSchemes[scheme]
+	if !ok {
+		return fmt.Errorf("sharding scheme %s not found", scheme)
+	}
+	return validate(params)
+}
@@ -0,0 +1,28 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package key
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestValidateShardingScheme(t *testing.T) {
+	RegisterShardingScheme("test_scheme", func(params map[string]string) error {
+		if params["Table"] == "" {
+			return fmt.Errorf("missing Table")
+		}
+		return nil
+	})
+	if err := ValidateShardingScheme("test_scheme", map[string]string{"Table": "t"}); err != nil {
+		t.Errorf("ValidateShardingScheme failed: %v", err)
+	}
+	if err := ValidateShardingScheme("test_scheme", nil); err == nil {
+		t.Errorf("ValidateShardingScheme without Table worked")
+	}
+	if err := ValidateShardingScheme("unknown_scheme", nil); err == nil {
+		t.Errorf("ValidateShardingScheme(unknown_scheme) worked")
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/golang/glog"
+)
+
+var logFormat = flag.String("log_format", "text", "format of the messages of the module loggers (mysqlctl, tabletserver, topo): 'text' logs them through glog, with their fields as key=value, 'json' logs them through glog as JSON objects")
+
+// Fields are the structured key/value pairs attached to the messages
+// of a ModuleLogger.
+type Fields map[string]interface{}
+
+var (
+	// modulesMu protects modules, the level of each module, as
+	// one of the LOGGER_INFO, LOGGER_WARNING or LOGGER_ERROR
+	// values, read and written atomically.
+	modulesMu sync.Mutex
+	modules   = make(map[string]*int32)
+
+	// the messages are logged with the file and line of the caller
+	// of the ModuleLogger methods.
+	infoDepth    = log.InfoDepth
+	warningDepth = log.WarningDepth
+	errorDepth   = log.ErrorDepth
+)
+
+// ModuleLogger is a Logger for the messages of a module, whose level
+// can be changed at runtime with SetModuleLevel. The messages below
+// the level of the module are dropped.
+type ModuleLogger struct {
+	module string
+	level  *int32
+	fields Fields
+}
+
+// NewModuleLogger returns the logger of a module. All the loggers of
+// a module share its level, which is LOGGER_INFO until changed.
+func NewModuleLogger(module string) *ModuleLogger {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	level, ok := modules[module]
+	if !ok {
+		level = new(int32)
+		*level = LOGGER_INFO
+		modules[module] = level
+	}
+	return &ModuleLogger{
+		module: module,
+		level:  level,
+	}
+}
+
+// With returns a logger that adds fields to all the messages, on
+// top of the fields of ml.
+func (ml *ModuleLogger) With(fields Fields) *ModuleLogger {
+	merged := make(Fields, len(ml.fields)+len(fields))
+	for k, v := range ml.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &ModuleLogger{
+		module: ml.module,
+		level:  ml.level,
+		fields: merged,
+	}
+}
+
+// Infof is part of the Logger interface.
+func (ml *ModuleLogger) Infof(format string, v ...interface{}) {
+	ml.log(LOGGER_INFO, format, v...)
+}
+
+// Warningf is part of the Logger interface.
+func (ml *ModuleLogger) Warningf(format string, v ...interface{}) {
+	ml.log(LOGGER_WARNING, format, v...)
+}
+
+// Errorf is part of the Logger interface.
+func (ml *ModuleLogger) Errorf(format string, v ...interface{}) {
+	ml.log(LOGGER_ERROR, format, v...)
+}
+
+// Printf is part of the Logger interface. The messages are logged
+// as info.
+func (ml *ModuleLogger) Printf(format string, v ...interface{}) {
+	ml.log(LOGGER_INFO, format, v...)
+}
+
+func (ml *ModuleLogger) log(level int, format string, v ...interface{}) {
+	if int32(level) < atomic.LoadInt32(ml.level) {
+		return
+	}
+	msg := fmt.Sprintf(format, v...)
+	if *logFormat == "json" {
+		msg = ml.formatJSON(level, msg)
+	} else {
+		msg = ml.formatText(msg)
+	}
+
+	// skip log and the ModuleLogger method that called it
+	switch level {
+	case LOGGER_INFO:
+		infoDepth(2, msg)
+	case LOGGER_WARNING:
+		warningDepth(2, msg)
+	default:
+		errorDepth(2, msg)
+	}
+}
+
+func (ml *ModuleLogger) formatText(msg string) string {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "%v: %v", ml.module, msg)
+	keys := make([]string, 0, len(ml.fields))
+	for k := range ml.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if s, ok := ml.fields[k].(string); ok {
+			fmt.Fprintf(buf, " %v=%q", k, s)
+		} else {
+			fmt.Fprintf(buf, " %v=%v", k, ml.fields[k])
+		}
+	}
+	return buf.String()
+}
+
+// jsonMessage is how a message is logged with -log_format json. glog
+// adds the time, file and line in front of it.
+type jsonMessage struct {
+	Level   string `json:"level"`
+	Module  string `json:"module"`
+	Message string `json:"msg"`
+	Fields  Fields `json:"fields,omitempty"`
+}
+
+func (ml *ModuleLogger) formatJSON(level int, msg string) string {
+	data, err := json.Marshal(&jsonMessage{
+		Level:   levelNames[level],
+		Module:  ml.module,
+		Message: msg,
+		Fields:  ml.fields,
+	})
+	if err != nil {
+		// a field can't be encoded, keep the message
+		data, _ = json.Marshal(&jsonMessage{
+			Level:   levelNames[level],
+			Module:  ml.module,
+			Message: fmt.Sprintf("%v (cannot encode fields: %v)", msg, err),
+		})
+	}
+	return string(data)
+}
+
+// levelNames are the names of the levels of the module loggers.
+var levelNames = map[int]string{
+	LOGGER_INFO:    "info",
+	LOGGER_WARNING: "warning",
+	LOGGER_ERROR:   "error",
+}
+
+// SetModuleLevel changes the level of a module: 'info', 'warning'
+// or 'error'.
+func SetModuleLevel(module, level string) error {
+	value := -1
+	for l, name := range levelNames {
+		if name == level {
+			value = l
+		}
+	}
+	if value == -1 {
+		return fmt.Errorf("unknown log level %q, want info, warning or error", level)
+	}
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	l, ok := modules[module]
+	if !ok {
+		return fmt.Errorf("unknown log module %q", module)
+	}
+	atomic.StoreInt32(l, int32(value))
+	return nil
+}
+
+// ModuleLevels returns the level of each module.
+func ModuleLevels() map[string]string {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	result := make(map[string]string, len(modules))
+	for module, l := range modules {
+		result[module] = levelNames[int(atomic.LoadInt32(l))]
+	}
+	return result
+}
@@ -0,0 +1,92 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// captureModuleLogs replaces the glog functions of the module loggers
+// with ones that record the messages, prefixed by their level. They
+// must be called to skip the ModuleLogger internals.
+func captureModuleLogs(t *testing.T) (logged *[]string, restore func()) {
+	oldInfo, oldWarning, oldError := infoDepth, warningDepth, errorDepth
+	logged = new([]string)
+	capture := func(prefix string) func(int, ...interface{}) {
+		return func(depth int, args ...interface{}) {
+			if depth != 2 {
+				t.Errorf("logged at depth %v, want 2", depth)
+			}
+			*logged = append(*logged, prefix+fmt.Sprint(args...))
+		}
+	}
+	infoDepth, warningDepth, errorDepth = capture("I "), capture("W "), capture("E ")
+	return logged, func() {
+		infoDepth, warningDepth, errorDepth = oldInfo, oldWarning, oldError
+	}
+}
+
+func TestModuleLogger(t *testing.T) {
+	logged, restore := captureModuleLogs(t)
+	defer restore()
+
+	ml := NewModuleLogger("testmodule")
+	ml.With(Fields{"table": "t 1", "rows": 3}).Infof("message %v", 1)
+	if err := SetModuleLevel("testmodule", "warning"); err != nil {
+		t.Fatalf("SetModuleLevel failed: %v", err)
+	}
+	defer SetModuleLevel("testmodule", "info")
+	// the other loggers of the module share its level
+	NewModuleLogger("testmodule").Infof("dropped")
+	ml.Warningf("message %v", 2)
+
+	want := []string{
+		`I testmodule: message 1 rows=3 table="t 1"`,
+		`W testmodule: message 2`,
+	}
+	if !reflect.DeepEqual(*logged, want) {
+		t.Errorf("logged %q, want %q", *logged, want)
+	}
+	if got := ModuleLevels()["testmodule"]; got != "warning" {
+		t.Errorf("level of testmodule is %v, want warning", got)
+	}
+
+	if err := SetModuleLevel("testmodule", "verbose"); err == nil {
+		t.Errorf("SetModuleLevel(verbose) worked, want an error")
+	}
+	if err := SetModuleLevel("unknownmodule", "info"); err == nil {
+		t.Errorf("SetModuleLevel(unknownmodule) worked, want an error")
+	}
+}
+
+func TestModuleLoggerJSON(t *testing.T) {
+	oldFormat := *logFormat
+	defer func() { *logFormat = oldFormat }()
+	*logFormat = "json"
+	logged, restore := captureModuleLogs(t)
+	defer restore()
+
+	NewModuleLogger("testjson").With(Fields{"table": "t1"}).Errorf("message %v", 1)
+	if len(*logged) != 1 || !strings.HasPrefix((*logged)[0], "E ") {
+		t.Fatalf("logged %q, want one error", *logged)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix((*logged)[0], "E ")), &got); err != nil {
+		t.Fatalf("cannot decode %q: %v", (*logged)[0], err)
+	}
+	want := map[string]interface{}{
+		"level":  "error",
+		"module": "testjson",
+		"msg":    "message 1",
+		"fields": map[string]interface{}{"table": "t1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("logged %v, want %v", got, want)
+	}
+}
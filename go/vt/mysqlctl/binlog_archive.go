@@ -0,0 +1,449 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlctl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	blproto "github.com/youtube/vitess/go/vt/binlog/proto"
+	"github.com/youtube/vitess/go/vt/mysqlctl/proto"
+)
+
+// BinlogArchiveIndexFile is the name under which the index of the
+// archived binlogs is stored.
+const BinlogArchiveIndexFile = "binlog_archive_index.json"
+
+// BinlogArchiveStorage is where the BinlogArchiver copies the binlogs.
+type BinlogArchiveStorage interface {
+	// Put stores the content of r under name, replacing any
+	// previous version. A partial copy must never be visible.
+	Put(name string, r io.Reader) error
+
+	// Get returns the content stored under name. If there is
+	// none, the error satisfies os.IsNotExist.
+	Get(name string) (io.ReadCloser, error)
+}
+
+// fileBinlogArchiveStorage is a BinlogArchiveStorage in a local
+// directory, usually a mounted network filesystem.
+type fileBinlogArchiveStorage struct {
+	dir string
+}
+
+// NewFileBinlogArchiveStorage returns a BinlogArchiveStorage that
+// stores the files in dir, creating it if needed.
+func NewFileBinlogArchiveStorage(dir string) (BinlogArchiveStorage, error) {
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		return nil, err
+	}
+	return &fileBinlogArchiveStorage{dir: dir}, nil
+}
+
+// Put is part of the BinlogArchiveStorage interface. The content is
+// written to a temporary file, renamed once complete.
+func (fs *fileBinlogArchiveStorage) Put(name string, r io.Reader) error {
+	f, err := ioutil.TempFile(fs.dir, name+tempFileMarker)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path.Join(fs.dir, name))
+}
+
+// Get is part of the BinlogArchiveStorage interface.
+func (fs *fileBinlogArchiveStorage) Get(name string) (io.ReadCloser, error) {
+	return os.Open(path.Join(fs.dir, name))
+}
+
+// ArchivedBinlog describes a binlog file in the archive. As it is
+// complete, its last position is Name:Size, and ModTime is the time
+// of its last event, which is enough to find where to stop a point
+// in time recovery.
+//
+// The binlog names are reused after a RESET MASTER, or when a tablet
+// is restored, so a binlog is identified by its name, and the server
+// id and creation time of its FORMAT_DESCRIPTION_EVENT.
+type ArchivedBinlog struct {
+	Name     string
+	ServerID uint32
+	Created  int64 // unix time
+
+	// StorageName is the name of the file in the storage.
+	StorageName string
+
+	Size    int64
+	ModTime int64 // unix time
+
+	// FirstGTID is the GTID of the first transaction of the file,
+	// and End the position after its last one. A file without
+	// transactions has neither.
+	FirstGTID proto.GTIDField
+	End       proto.ReplicationPosition
+}
+
+// BinlogArchiveIndex lists the archived binlogs, in order.
+type BinlogArchiveIndex struct {
+	Files []*ArchivedBinlog
+}
+
+// ReadBinlogArchiveIndex returns the index stored in the storage,
+// or an empty one if there is none yet.
+func ReadBinlogArchiveIndex(storage BinlogArchiveStorage) (*BinlogArchiveIndex, error) {
+	r, err := storage.Get(BinlogArchiveIndexFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &BinlogArchiveIndex{}, nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+	index := &BinlogArchiveIndex{}
+	if err := json.NewDecoder(r).Decode(index); err != nil {
+		return nil, fmt.Errorf("cannot decode binlog archive index: %v", err)
+	}
+	return index, nil
+}
+
+// binlogKey identifies a binlog file, see ArchivedBinlog.
+type binlogKey struct {
+	name     string
+	serverID uint32
+	created  int64
+}
+
+// BinlogReaderPositions returns the positions of the servers reading
+// the binlogs of a Mysqld: its replicas and binlog players. The
+// binlogs they haven't read yet are never purged.
+type BinlogReaderPositions func() ([]proto.ReplicationPosition, error)
+
+// BinlogArchiver copies the binlogs mysqld is done writing to a
+// BinlogArchiveStorage, along with an index, so they can be used
+// for point in time recovery. It can then purge the archived
+// binlogs locally, so they don't need to be kept as long.
+type BinlogArchiver struct {
+	mysqld  *Mysqld
+	storage BinlogArchiveStorage
+
+	// keepLocal is how many of the complete binlogs are kept
+	// locally once archived. Negative means they are never
+	// purged by the archiver.
+	keepLocal int
+
+	// readers returns the positions to check before purging,
+	// nil if there are no readers.
+	readers BinlogReaderPositions
+
+	// mu protects index, loaded from the storage on the first
+	// Archive.
+	mu    sync.Mutex
+	index *BinlogArchiveIndex
+}
+
+// NewBinlogArchiver returns a BinlogArchiver for the provided
+// Mysqld. The returned object is not running, call Run or Archive.
+func NewBinlogArchiver(mysqld *Mysqld, storage BinlogArchiveStorage, keepLocal int, readers BinlogReaderPositions) *BinlogArchiver {
+	return &BinlogArchiver{
+		mysqld:    mysqld,
+		storage:   storage,
+		keepLocal: keepLocal,
+		readers:   readers,
+	}
+}
+
+// Run archives the binlogs every interval, until stop is closed.
+func (ba *BinlogArchiver) Run(interval time.Duration, stop <-chan struct{}) {
+	for {
+		if err := ba.Archive(); err != nil {
+			mlog.Warningf("cannot archive binlogs: %v", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Archive copies the complete binlogs that are not archived yet,
+// and purges the old ones if configured to.
+func (ba *BinlogArchiver) Archive() error {
+	if ba.mysqld.config.BinLogPath == "" {
+		return fmt.Errorf("no binlog path configured")
+	}
+	flavor, err := ba.mysqld.flavor()
+	if err != nil {
+		return err
+	}
+	binlogs, err := ba.mysqld.binaryLogs()
+	if err != nil {
+		return err
+	}
+	var positions []proto.ReplicationPosition
+	if ba.keepLocal >= 0 && ba.readers != nil {
+		if positions, err = ba.readers(); err != nil {
+			return fmt.Errorf("cannot get the positions of the binlog readers: %v", err)
+		}
+	}
+	purgeTo, err := ba.archive(path.Dir(ba.mysqld.config.BinLogPath), binlogs, flavor, positions)
+	if err != nil {
+		return err
+	}
+	if purgeTo == "" {
+		return nil
+	}
+	mlog.Infof("purging the archived binlogs before %v", purgeTo)
+	return ba.mysqld.ExecuteSuperQuery(fmt.Sprintf("PURGE BINARY LOGS TO '%v'", purgeTo))
+}
+
+// archive copies the binlogs from dir that are not in the index,
+// except the last one mysqld is still writing to. It returns the
+// binlog to purge to, if any: the binlogs before it are archived,
+// and all the positions are past their end.
+func (ba *BinlogArchiver) archive(dir string, binlogs []string, flavor MysqlFlavor, positions []proto.ReplicationPosition) (string, error) {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+
+	if ba.index == nil {
+		index, err := ReadBinlogArchiveIndex(ba.storage)
+		if err != nil {
+			return "", err
+		}
+		ba.index = index
+	}
+	archived := make(map[binlogKey]*ArchivedBinlog, len(ba.index.Files))
+	for _, f := range ba.index.Files {
+		archived[binlogKey{f.Name, f.ServerID, f.Created}] = f
+	}
+
+	if len(binlogs) == 0 {
+		return "", nil
+	}
+	complete := binlogs[:len(binlogs)-1]
+	files := make([]*ArchivedBinlog, len(complete))
+	for i, binlog := range complete {
+		key, err := readBinlogKey(dir, binlog)
+		if err != nil {
+			return "", fmt.Errorf("cannot read binlog %v: %v", binlog, err)
+		}
+		if f, ok := archived[key]; ok {
+			files[i] = f
+			continue
+		}
+		f, err := ba.archiveFile(dir, key, flavor)
+		if err != nil {
+			return "", fmt.Errorf("cannot archive binlog %v: %v", binlog, err)
+		}
+		mlog.Infof("archived binlog %v (%v bytes, up to %v)", f.Name, f.Size, f.End)
+		ba.index.Files = append(ba.index.Files, f)
+		if err := ba.writeIndex(); err != nil {
+			return "", err
+		}
+		files[i] = f
+	}
+
+	if ba.keepLocal < 0 || len(complete) <= ba.keepLocal {
+		return "", nil
+	}
+	purge := len(complete) - ba.keepLocal
+	for i, f := range files[:purge] {
+		if !positionsReached(positions, f.End) {
+			mlog.Infof("not purging binlog %v, a reader is not past %v yet", f.Name, f.End)
+			purge = i
+			break
+		}
+	}
+	if purge == 0 {
+		return "", nil
+	}
+	return binlogs[purge], nil
+}
+
+// positionsReached returns true if all the positions are at least end.
+func positionsReached(positions []proto.ReplicationPosition, end proto.ReplicationPosition) bool {
+	if end.IsZero() {
+		return true
+	}
+	for _, pos := range positions {
+		if !pos.AtLeast(end) {
+			return false
+		}
+	}
+	return true
+}
+
+// archiveFile copies a binlog to the storage.
+func (ba *BinlogArchiver) archiveFile(dir string, key binlogKey, flavor MysqlFlavor) (*ArchivedBinlog, error) {
+	f, err := os.Open(path.Join(dir, key.name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	first, end, err := scanBinlog(f, flavor)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	// the index only grows, so its length makes the storage
+	// names unique
+	storageName := fmt.Sprintf("%08d-%v", len(ba.index.Files), key.name)
+	if err := ba.storage.Put(storageName, f); err != nil {
+		return nil, err
+	}
+	return &ArchivedBinlog{
+		Name:        key.name,
+		ServerID:    key.serverID,
+		Created:     key.created,
+		StorageName: storageName,
+		Size:        fi.Size(),
+		ModTime:     fi.ModTime().Unix(),
+		FirstGTID:   proto.GTIDField{Value: first},
+		End:         end,
+	}, nil
+}
+
+// writeIndex stores the index. It is called with mu held.
+func (ba *BinlogArchiver) writeIndex() error {
+	data, err := json.MarshalIndent(ba.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ba.storage.Put(BinlogArchiveIndexFile, bytes.NewReader(data))
+}
+
+// binlogMagic starts all the binlog files.
+var binlogMagic = []byte{0xfe, 'b', 'i', 'n'}
+
+// readBinlogKey reads the header of the FORMAT_DESCRIPTION_EVENT
+// that starts a binlog file, to identify it.
+func readBinlogKey(dir, name string) (binlogKey, error) {
+	f, err := os.Open(path.Join(dir, name))
+	if err != nil {
+		return binlogKey{}, err
+	}
+	defer f.Close()
+	buf := make([]byte, len(binlogMagic)+19)
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return binlogKey{}, err
+	}
+	if !bytes.Equal(buf[:len(binlogMagic)], binlogMagic) {
+		return binlogKey{}, fmt.Errorf("not a binlog file")
+	}
+	ev := binlogEvent(buf[len(binlogMagic):])
+	if !ev.IsFormatDescription() {
+		return binlogKey{}, fmt.Errorf("binlog doesn't start with a FORMAT_DESCRIPTION_EVENT")
+	}
+	return binlogKey{
+		name:     name,
+		serverID: ev.ServerID(),
+		created:  int64(ev.Timestamp()),
+	}, nil
+}
+
+// scanBinlog reads the events of a binlog file, and returns the GTID
+// of its first transaction and the position after its last one.
+func scanBinlog(r io.Reader, flavor MysqlFlavor) (first proto.GTID, end proto.ReplicationPosition, err error) {
+	br := bufio.NewReader(r)
+	buf := make([]byte, len(binlogMagic))
+	if _, err := io.ReadFull(br, buf); err != nil {
+		return nil, end, err
+	}
+	if !bytes.Equal(buf, binlogMagic) {
+		return nil, end, fmt.Errorf("not a binlog file")
+	}
+
+	var format blproto.BinlogFormat
+	header := make([]byte, 19)
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			if err == io.EOF {
+				return first, end, nil
+			}
+			return nil, end, fmt.Errorf("cannot read binlog event header: %v", err)
+		}
+		length := binlogEvent(header).Length()
+		if length < 19 {
+			return nil, end, fmt.Errorf("invalid binlog event length %v", length)
+		}
+		if uint32(cap(buf)) < length {
+			buf = make([]byte, length)
+		}
+		buf = buf[:length]
+		copy(buf, header)
+		if _, err := io.ReadFull(br, buf[19:]); err != nil {
+			return nil, end, fmt.Errorf("cannot read binlog event: %v", err)
+		}
+
+		ev := flavor.MakeBinlogEvent(buf)
+		if !ev.IsValid() {
+			return nil, end, fmt.Errorf("can't parse binlog event, invalid data: %#v", ev)
+		}
+		if ev.IsFormatDescription() {
+			if format, err = ev.Format(); err != nil {
+				return nil, end, fmt.Errorf("can't parse FORMAT_DESCRIPTION_EVENT: %v", err)
+			}
+			continue
+		}
+		if format.IsZero() {
+			return nil, end, fmt.Errorf("got a real event before FORMAT_DESCRIPTION_EVENT: %#v", ev)
+		}
+		ev, _ = ev.StripChecksum(format)
+		if !ev.HasGTID(format) {
+			continue
+		}
+		gtid, err := ev.GTID(format)
+		if err != nil {
+			return nil, end, fmt.Errorf("can't get GTID from binlog event: %v", err)
+		}
+		if first == nil {
+			first = gtid
+		}
+		end = proto.AppendGTID(end, gtid)
+	}
+}
+
+// binaryLogs returns the names of the binlogs listed by SHOW BINARY
+// LOGS, the last one being the binlog mysqld is writing to.
+func (mysqld *Mysqld) binaryLogs() ([]string, error) {
+	qr, err := mysqld.fetchSuperQuery("SHOW BINARY LOGS")
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(qr.Rows))
+	for _, row := range qr.Rows {
+		if len(row) == 0 {
+			return nil, fmt.Errorf("unexpected SHOW BINARY LOGS row: %v", row)
+		}
+		result = append(result, row[0].String())
+	}
+	return result, nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlctl
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/youtube/vitess/go/vt/mysqlctl/proto"
+)
+
+// testBinlog returns the content of a binlog file created at the
+// provided time, with a transaction for each sequence number.
+func testBinlog(created uint32, sequences ...uint64) []byte {
+	data := append([]byte{}, binlogMagic...)
+	format := append([]byte{}, mariadbFormatEvent...)
+	binary.LittleEndian.PutUint32(format, created)
+	data = append(data, format...)
+	for _, seq := range sequences {
+		gtid := append([]byte{}, mariadbBeginGTIDEvent...)
+		binary.LittleEndian.PutUint64(gtid[19:], seq)
+		data = append(data, gtid...)
+		data = append(data, mariadbInsertEvent...)
+	}
+	return data
+}
+
+func TestBinlogArchiver(t *testing.T) {
+	root, err := ioutil.TempDir("", "binlog_archive")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(root)
+	binlogDir := path.Join(root, "bin-logs")
+	archiveDir := path.Join(root, "archive")
+	if err := os.MkdirAll(binlogDir, 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	writeBinlog := func(name string, content []byte) {
+		if err := ioutil.WriteFile(path.Join(binlogDir, name), content, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	storage, err := NewFileBinlogArchiveStorage(archiveDir)
+	if err != nil {
+		t.Fatalf("NewFileBinlogArchiveStorage failed: %v", err)
+	}
+	checkArchive := func(want ...string) []*ArchivedBinlog {
+		index, err := ReadBinlogArchiveIndex(storage)
+		if err != nil {
+			t.Fatalf("ReadBinlogArchiveIndex failed: %v", err)
+		}
+		if len(index.Files) != len(want) {
+			t.Fatalf("archive has %v files, want %v", len(index.Files), want)
+		}
+		for i, f := range index.Files {
+			if f.Name != want[i] {
+				t.Errorf("archived file %v is %v, want %v", i, f.Name, want[i])
+			}
+			data, err := ioutil.ReadFile(path.Join(archiveDir, f.StorageName))
+			if err != nil {
+				t.Errorf("cannot read archived file: %v", err)
+			}
+			if int64(len(data)) != f.Size {
+				t.Errorf("archived file %v has %v bytes, the index says %v", f.Name, len(data), f.Size)
+			}
+		}
+		return index.Files
+	}
+	flavor := &mariaDB10{}
+	position := func(seq uint64) proto.ReplicationPosition {
+		return proto.ReplicationPosition{GTIDSet: proto.MariadbGTID{Domain: 0, Server: 62344, Sequence: seq}}
+	}
+
+	// the binlog being written to is not archived
+	writeBinlog("vt-bin.000001", testBinlog(1000, 1))
+	writeBinlog("vt-bin.000002", testBinlog(2000, 2, 3))
+	writeBinlog("vt-bin.000003", testBinlog(3000))
+	ba := NewBinlogArchiver(nil, storage, 1, nil)
+	purgeTo, err := ba.archive(binlogDir, []string{"vt-bin.000001", "vt-bin.000002", "vt-bin.000003"}, flavor, []proto.ReplicationPosition{position(3)})
+	if err != nil {
+		t.Fatalf("archive failed: %v", err)
+	}
+	if purgeTo != "vt-bin.000002" {
+		t.Errorf("archive returned purgeTo=%v, want vt-bin.000002", purgeTo)
+	}
+	files := checkArchive("vt-bin.000001", "vt-bin.000002")
+	if got, want := files[1].FirstGTID.Value, (proto.MariadbGTID{Domain: 0, Server: 62344, Sequence: 2}); got != want {
+		t.Errorf("first GTID of vt-bin.000002 is %v, want %v", got, want)
+	}
+	if !files[1].End.Equal(position(3)) {
+		t.Errorf("vt-bin.000002 ends at %v, want %v", files[1].End, position(3))
+	}
+	if files[1].Created != 2000 || files[1].ServerID != 62344 {
+		t.Errorf("vt-bin.000002 was created at %v by %v, want 2000 by 62344", files[1].Created, files[1].ServerID)
+	}
+
+	// the binlogs a reader hasn't read yet are not purged
+	ba = NewBinlogArchiver(nil, storage, 0, nil)
+	binlogs := []string{"vt-bin.000001", "vt-bin.000002", "vt-bin.000003"}
+	for _, test := range []struct {
+		positions []proto.ReplicationPosition
+		purgeTo   string
+	}{
+		{[]proto.ReplicationPosition{position(3)}, "vt-bin.000003"},
+		{[]proto.ReplicationPosition{position(3), position(2)}, "vt-bin.000002"},
+		{[]proto.ReplicationPosition{position(3), proto.ReplicationPosition{}}, ""},
+	} {
+		purgeTo, err := ba.archive(binlogDir, binlogs, flavor, test.positions)
+		if err != nil {
+			t.Fatalf("archive failed: %v", err)
+		}
+		if purgeTo != test.purgeTo {
+			t.Errorf("archive with the readers at %v returned purgeTo=%v, want %v", test.positions, purgeTo, test.purgeTo)
+		}
+	}
+
+	// after a purge and a rotation, a new archiver only archives
+	// the new complete binlog
+	os.Remove(path.Join(binlogDir, "vt-bin.000001"))
+	writeBinlog("vt-bin.000003", testBinlog(3000, 4))
+	ba = NewBinlogArchiver(nil, storage, -1, nil)
+	purgeTo, err = ba.archive(binlogDir, []string{"vt-bin.000002", "vt-bin.000003", "vt-bin.000004"}, flavor, nil)
+	if err != nil {
+		t.Fatalf("archive failed: %v", err)
+	}
+	if purgeTo != "" {
+		t.Errorf("archive returned purgeTo=%v with purges disabled", purgeTo)
+	}
+	checkArchive("vt-bin.000001", "vt-bin.000002", "vt-bin.000003")
+
+	// after a RESET MASTER, the reused names are archived again,
+	// without replacing the previous files
+	writeBinlog("vt-bin.000001", testBinlog(5000, 1))
+	purgeTo, err = ba.archive(binlogDir, []string{"vt-bin.000001", "vt-bin.000002"}, flavor, nil)
+	if err != nil {
+		t.Fatalf("archive failed: %v", err)
+	}
+	files = checkArchive("vt-bin.000001", "vt-bin.000002", "vt-bin.000003", "vt-bin.000001")
+	if files[0].StorageName == files[3].StorageName {
+		t.Errorf("both vt-bin.000001 are stored as %v", files[0].StorageName)
+	}
+
+	// a binlog that cannot be read stops the archival
+	if _, err := ba.archive(binlogDir, []string{"vt-bin.000005", "vt-bin.000006"}, flavor, nil); err == nil {
+		t.Errorf("archive of a missing binlog should have failed")
+	}
+	writeBinlog("vt-bin.000005", []byte("not a binlog"))
+	if _, err := ba.archive(binlogDir, []string{"vt-bin.000005", "vt-bin.000006"}, flavor, nil); err == nil {
+		t.Errorf("archive of an invalid binlog should have failed")
+	}
+	checkArchive("vt-bin.000001", "vt-bin.000002", "vt-bin.000003", "vt-bin.000001")
+}
@@ -13,8 +13,8 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/ioutil2"
 	"github.com/youtube/vitess/go/vt/hook"
 	"github.com/youtube/vitess/go/vt/logutil"
@@ -30,8 +30,21 @@ const (
 const (
 	SnapshotManifestFile = "snapshot_manifest.json"
 	SnapshotURLPath      = "/snapshot"
+
+	// SnapshotFanOutParameter is the URL parameter a restoring
+	// tablet uses to tell the snapshot source how many tablets
+	// are fetching the same files at the same time.
+	SnapshotFanOutParameter = "fanout"
 )
 
+// snapshotIDManifestFile returns the name of the copy of the manifest
+// identifying the snapshot with the provided id. The SnapshotDir only
+// holds the last snapshot: its SnapshotManifestFile is overwritten by
+// the next snapshot, the id manifest is removed with it.
+func snapshotIDManifestFile(id string) string {
+	return fmt.Sprintf("snapshot_manifest_%v.json", id)
+}
+
 // Validate that this instance is a reasonable source of data.
 func (mysqld *Mysqld) validateCloneSource(serverMode bool, hookExtraEnv map[string]string) error {
 	// NOTE(msolomon) Removing this check for now - I don't see the value of validating this.
@@ -315,8 +328,14 @@ func (mysqld *Mysqld) CreateSnapshot(logger logutil.Logger, dbName, sourceAddr s
 		if snapshotErr != nil {
 			logger.Errorf("CreateSnapshot failed: %v", snapshotErr)
 		} else {
-			smFile = path.Join(mysqld.SnapshotDir, SnapshotManifestFile)
-			if snapshotErr = writeJson(smFile, sm); snapshotErr != nil {
+			// the id manifest is the one returned, so the snapshot
+			// can be told apart from the next ones
+			id := fmt.Sprintf("%v", time.Now().UnixNano())
+			smFile = path.Join(mysqld.SnapshotDir, snapshotIDManifestFile(id))
+			if snapshotErr = writeJson(smFile, sm); snapshotErr == nil {
+				snapshotErr = writeJson(path.Join(mysqld.SnapshotDir, SnapshotManifestFile), sm)
+			}
+			if snapshotErr != nil {
 				logger.Errorf("CreateSnapshot failed: %v", snapshotErr)
 			}
 		}
@@ -344,9 +363,9 @@ func (mysqld *Mysqld) CreateSnapshot(logger logutil.Logger, dbName, sourceAddr s
 func (mysqld *Mysqld) SnapshotSourceEnd(slaveStartRequired, readOnly, deleteSnapshot bool, hookExtraEnv map[string]string) error {
 	if deleteSnapshot {
 		// clean out our files
-		log.Infof("removing snapshot links: %v", mysqld.SnapshotDir)
+		mlog.Infof("removing snapshot links: %v", mysqld.SnapshotDir)
 		if err := os.RemoveAll(mysqld.SnapshotDir); err != nil {
-			log.Warningf("failed to remove old snapshot: %v", err)
+			mlog.Warningf("failed to remove old snapshot: %v", err)
 			return err
 		}
 	}
@@ -376,6 +395,33 @@ func (mysqld *Mysqld) SnapshotSourceEnd(slaveStartRequired, readOnly, deleteSnap
 	return nil
 }
 
+// DeleteSnapshot removes the snapshot whose manifest has the provided
+// URL path (as returned by CreateSnapshot). It is a no-op if the
+// snapshot was already replaced by a more recent one. A path to the
+// SnapshotManifestFile doesn't identify a snapshot, these are left to
+// the DiskMonitor.
+func (mysqld *Mysqld) DeleteSnapshot(manifestPath string) error {
+	if !strings.HasPrefix(manifestPath, SnapshotURLPath+"/") {
+		return fmt.Errorf("invalid snapshot manifest path: %v", manifestPath)
+	}
+	manifestFile := path.Join(mysqld.SnapshotDir, strings.TrimPrefix(manifestPath, SnapshotURLPath))
+	if !strings.HasPrefix(manifestFile, mysqld.SnapshotDir+"/") {
+		return fmt.Errorf("invalid snapshot manifest path: %v", manifestPath)
+	}
+	if path.Base(manifestFile) == SnapshotManifestFile {
+		mlog.Warningf("snapshot manifest %v has no id, not removing the snapshot", manifestPath)
+		return nil
+	}
+	if _, err := os.Stat(manifestFile); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	mlog.Infof("removing snapshot %v: %v", manifestPath, mysqld.SnapshotDir)
+	return os.RemoveAll(mysqld.SnapshotDir)
+}
+
 func writeJson(filename string, x interface{}) error {
 	data, err := json.MarshalIndent(x, "  ", "  ")
 	if err != nil {
@@ -407,7 +453,7 @@ func ReadSnapshotManifest(filename string) (*SnapshotManifest, error) {
 // uncompress into /vt/vt_<target-uid>/data/vt_<keyspace>
 // start_mysql()
 // clean up compressed files
-func (mysqld *Mysqld) RestoreFromSnapshot(logger logutil.Logger, snapshotManifest *SnapshotManifest, fetchConcurrency, fetchRetryCount int, dontWaitForSlaveStart bool, hookExtraEnv map[string]string) error {
+func (mysqld *Mysqld) RestoreFromSnapshot(logger logutil.Logger, snapshotManifest *SnapshotManifest, fetchConcurrency, fetchRetryCount, fanOut int, dontWaitForSlaveStart bool, hookExtraEnv map[string]string) error {
 	if snapshotManifest == nil {
 		return errors.New("RestoreFromSnapshot: nil snapshotManifest")
 	}
@@ -423,7 +469,7 @@ func (mysqld *Mysqld) RestoreFromSnapshot(logger logutil.Logger, snapshotManifes
 	}
 
 	logger.Infof("Fetch snapshot")
-	if err := mysqld.fetchSnapshot(snapshotManifest, fetchConcurrency, fetchRetryCount); err != nil {
+	if err := mysqld.fetchSnapshot(snapshotManifest, fetchConcurrency, fetchRetryCount, fanOut); err != nil {
 		return err
 	}
 
@@ -455,7 +501,7 @@ func (mysqld *Mysqld) RestoreFromSnapshot(logger logutil.Logger, snapshotManifes
 	return nil
 }
 
-func (mysqld *Mysqld) fetchSnapshot(snapshotManifest *SnapshotManifest, fetchConcurrency, fetchRetryCount int) error {
+func (mysqld *Mysqld) fetchSnapshot(snapshotManifest *SnapshotManifest, fetchConcurrency, fetchRetryCount, fanOut int) error {
 	replicaDbPath := path.Join(mysqld.config.DataDir, snapshotManifest.DbName)
 
 	cleanDirs := []string{mysqld.SnapshotDir, replicaDbPath,
@@ -473,5 +519,5 @@ func (mysqld *Mysqld) fetchSnapshot(snapshotManifest *SnapshotManifest, fetchCon
 		}
 	}
 
-	return fetchFiles(snapshotManifest, mysqld.TabletDir, fetchConcurrency, fetchRetryCount)
+	return fetchFiles(snapshotManifest, mysqld.TabletDir, fetchConcurrency, fetchRetryCount, fanOut)
 }
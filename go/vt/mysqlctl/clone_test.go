@@ -0,0 +1,54 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlctl
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestDeleteSnapshot(t *testing.T) {
+	root, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	mysqld := &Mysqld{SnapshotDir: path.Join(root, "snapshot")}
+	if err := os.MkdirAll(path.Join(mysqld.SnapshotDir, "data"), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	for _, name := range []string{SnapshotManifestFile, snapshotIDManifestFile("2"), "data/t1.ibd.gz"} {
+		if err := ioutil.WriteFile(path.Join(mysqld.SnapshotDir, name), []byte("{}"), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	for _, manifestPath := range []string{"/other/snapshot_manifest_2.json", "/snapshot/../snapshot_manifest_2.json"} {
+		if err := mysqld.DeleteSnapshot(manifestPath); err == nil {
+			t.Errorf("DeleteSnapshot(%v) should have failed", manifestPath)
+		}
+	}
+
+	// the snapshot 1 was replaced by the snapshot 2, and a path
+	// without an id could be any snapshot
+	for _, manifestPath := range []string{"/snapshot/snapshot_manifest_1.json", "/snapshot/snapshot_manifest.json"} {
+		if err := mysqld.DeleteSnapshot(manifestPath); err != nil {
+			t.Errorf("DeleteSnapshot(%v) failed: %v", manifestPath, err)
+		}
+		if _, err := os.Stat(path.Join(mysqld.SnapshotDir, "data/t1.ibd.gz")); err != nil {
+			t.Errorf("DeleteSnapshot(%v) removed the snapshot 2: %v", manifestPath, err)
+		}
+	}
+
+	if err := mysqld.DeleteSnapshot("/snapshot/snapshot_manifest_2.json"); err != nil {
+		t.Errorf("DeleteSnapshot failed: %v", err)
+	}
+	if _, err := os.Stat(mysqld.SnapshotDir); !os.IsNotExist(err) {
+		t.Errorf("DeleteSnapshot didn't remove the snapshot 2: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlctl
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/youtube/vitess/go/stats"
+)
+
+// The categories of disk usage tracked by DiskMonitor.
+const (
+	DiskUsageDataDir     = "DataDir"
+	DiskUsageRelayLogs   = "RelayLogs"
+	DiskUsageBinLogs     = "BinLogs"
+	DiskUsageSnapshotDir = "SnapshotDir"
+)
+
+// DiskMonitor periodically computes the disk usage of the mysql
+// directories, purges expired snapshots, and removes the files left
+// behind by failed snapshots and restores. The disk usage is
+// exported as the DiskUsageBytes stats, and the free space ratio
+// of the data dir filesystem as DiskFreeRatio.
+type DiskMonitor struct {
+	mysqld *Mysqld
+
+	// snapshotRetention is how long we keep a snapshot
+	// around. 0 means forever.
+	snapshotRetention time.Duration
+
+	// orphanMaxAge is how long the files left behind by failed
+	// snapshots and restores are kept around (see CleanOrphans).
+	// 0 means forever.
+	orphanMaxAge time.Duration
+
+	// canPurgeSnapshot is called before purging a snapshot,
+	// it can veto the purge. vttablet vetoes it while the snapshot
+	// is served, or recorded in the Backups of the shard.
+	canPurgeSnapshot func() bool
+
+	mu        sync.Mutex
+	usage     map[string]int64
+	freeRatio float64
+}
+
+// NewDiskMonitor returns a DiskMonitor for the provided Mysqld.
+// canPurgeSnapshot can be nil. The returned object is not running,
+// call Run or Refresh. If publishStats is true, the stats are
+// exported. Only one DiskMonitor should publish stats in a process.
+func NewDiskMonitor(mysqld *Mysqld, snapshotRetention, orphanMaxAge time.Duration, canPurgeSnapshot func() bool, publishStats bool) *DiskMonitor {
+	dm := &DiskMonitor{
+		mysqld:            mysqld,
+		snapshotRetention: snapshotRetention,
+		orphanMaxAge:      orphanMaxAge,
+		canPurgeSnapshot:  canPurgeSnapshot,
+		usage:             make(map[string]int64),
+	}
+	if publishStats {
+		stats.Publish("DiskUsageBytes", stats.GaugesFunc(dm.Usage))
+		stats.Publish("DiskFreeRatio", stats.FloatFunc(dm.FreeRatio))
+	}
+	return dm
+}
+
+// Usage returns a copy of the last computed disk usage, in bytes.
+func (dm *DiskMonitor) Usage() map[string]int64 {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	result := make(map[string]int64, len(dm.usage))
+	for k, v := range dm.usage {
+		result[k] = v
+	}
+	return result
+}
+
+// FreeRatio returns the last computed ratio of free space on the
+// data dir filesystem.
+func (dm *DiskMonitor) FreeRatio() float64 {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	return dm.freeRatio
+}
+
+// Run refreshes the disk usage every interval, until stop is closed.
+func (dm *DiskMonitor) Run(interval time.Duration, stop <-chan struct{}) {
+	for {
+		dm.Refresh()
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Refresh purges expired snapshots and orphaned files, and computes
+// the disk usage.
+func (dm *DiskMonitor) Refresh() {
+	if err := dm.purgeExpiredSnapshot(); err != nil {
+		mlog.Warningf("cannot purge expired snapshot: %v", err)
+	}
+	if dm.orphanMaxAge > 0 {
+		report, err := dm.mysqld.CleanOrphans(dm.orphanMaxAge, false)
+		if err != nil {
+			mlog.Warningf("cannot clean orphaned files: %v", err)
+		}
+		if len(report.Files) > 0 {
+			mlog.Infof("removed %v orphaned files, reclaimed %v bytes", len(report.Files), report.ReclaimedBytes)
+		}
+	}
+
+	cnf := dm.mysqld.config
+	usage := map[string]int64{
+		DiskUsageDataDir:     dirSize(cnf.DataDir, ""),
+		DiskUsageSnapshotDir: dirSize(dm.mysqld.SnapshotDir, ""),
+	}
+	if cnf.RelayLogPath != "" {
+		usage[DiskUsageRelayLogs] = dirSize(path.Dir(cnf.RelayLogPath), path.Base(cnf.RelayLogPath))
+	}
+	if cnf.BinLogPath != "" {
+		usage[DiskUsageBinLogs] = dirSize(path.Dir(cnf.BinLogPath), path.Base(cnf.BinLogPath))
+	}
+	freeRatio, err := freeDiskSpaceRatio(cnf.DataDir)
+	if err != nil {
+		mlog.Warningf("cannot get free disk space: %v", err)
+	}
+
+	dm.mu.Lock()
+	dm.usage = usage
+	dm.freeRatio = freeRatio
+	dm.mu.Unlock()
+}
+
+// purgeExpiredSnapshot removes the SnapshotDir if its manifest is
+// older than the retention, and canPurgeSnapshot doesn't veto it. A
+// snapshot with no manifest is either in progress or failed, and is
+// left alone.
+func (dm *DiskMonitor) purgeExpiredSnapshot() error {
+	if dm.snapshotRetention <= 0 {
+		return nil
+	}
+	fi, err := os.Stat(path.Join(dm.mysqld.SnapshotDir, SnapshotManifestFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if time.Since(fi.ModTime()) < dm.snapshotRetention {
+		return nil
+	}
+	if dm.canPurgeSnapshot != nil && !dm.canPurgeSnapshot() {
+		return nil
+	}
+	mlog.Infof("removing expired snapshot: %v", dm.mysqld.SnapshotDir)
+	return os.RemoveAll(dm.mysqld.SnapshotDir)
+}
+
+// dirSize returns the total size of the regular files under dir
+// whose name start with prefix. Errors are ignored, as files may
+// come and go while we walk.
+func dirSize(dir, prefix string) int64 {
+	var result int64
+	filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.Mode().IsRegular() && strings.HasPrefix(info.Name(), prefix) {
+			result += info.Size()
+		}
+		return nil
+	})
+	return result
+}
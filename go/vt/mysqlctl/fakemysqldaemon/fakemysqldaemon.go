@@ -0,0 +1,193 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fakemysqldaemon has a fake implementation of
+// mysqlctl.MysqlDaemon, kept in memory, for unit tests of the
+// tabletmanager and wrangler code that drives a mysqld.
+package fakemysqldaemon
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/dbconfigs"
+	"github.com/youtube/vitess/go/vt/dbconnpool"
+	"github.com/youtube/vitess/go/vt/mysqlctl"
+	"github.com/youtube/vitess/go/vt/mysqlctl/proto"
+)
+
+// FakeMysqlDaemon implements MysqlDaemon and allows the user to fake
+// everything.
+type FakeMysqlDaemon struct {
+	// Running is updated by Start and Shutdown.
+	Running bool
+
+	// StartError and ShutdownError, if set, are returned by Start
+	// and Shutdown, which then don't change Running.
+	StartError    error
+	ShutdownError error
+
+	// MasterAddr will be returned by GetMasterAddr(). Set to "" to return
+	// mysqlctl.ErrNotSlave, or to "ERROR" to return an error.
+	MasterAddr string
+
+	// MysqlPort will be returned by GetMysqlPort(). Set to -1 to
+	// return an error.
+	MysqlPort int
+
+	// ReadOnly is returned by IsReadOnly, and updated by SetReadOnly.
+	ReadOnly bool
+
+	// Replicating is updated when calling StopSlave
+	Replicating bool
+
+	// CurrentSlaveStatus is returned by SlaveStatus
+	CurrentSlaveStatus *proto.ReplicationStatus
+
+	// CurrentMasterPosition is returned by MasterPosition
+	CurrentMasterPosition proto.ReplicationPosition
+
+	// Schema that will be returned by GetSchema. If nil we'll
+	// return an error.
+	Schema *proto.SchemaDefinition
+
+	// DbaConnectionFactory is the factory for making fake dba connections
+	DbaConnectionFactory func() (dbconnpool.PoolConnection, error)
+
+	// DbAppConnectionFactory is the factory for making fake db app connections
+	DbAppConnectionFactory func() (dbconnpool.PoolConnection, error)
+}
+
+// Start is part of the MysqlDaemon interface
+func (fmd *FakeMysqlDaemon) Start(mysqlWaitTime time.Duration) error {
+	if fmd.StartError != nil {
+		return fmd.StartError
+	}
+	if fmd.Running {
+		return fmt.Errorf("fake mysql daemon already running")
+	}
+	fmd.Running = true
+	return nil
+}
+
+// Shutdown is part of the MysqlDaemon interface
+func (fmd *FakeMysqlDaemon) Shutdown(waitForMysqld bool, mysqlWaitTime time.Duration) error {
+	if fmd.ShutdownError != nil {
+		return fmd.ShutdownError
+	}
+	if !fmd.Running {
+		return fmt.Errorf("fake mysql daemon not running")
+	}
+	fmd.Running = false
+	return nil
+}
+
+// GetMasterAddr is part of the MysqlDaemon interface
+func (fmd *FakeMysqlDaemon) GetMasterAddr() (string, error) {
+	if fmd.MasterAddr == "" {
+		return "", mysqlctl.ErrNotSlave
+	}
+	if fmd.MasterAddr == "ERROR" {
+		return "", fmt.Errorf("FakeMysqlDaemon.GetMasterAddr returns an error")
+	}
+	return fmd.MasterAddr, nil
+}
+
+// GetMysqlPort is part of the MysqlDaemon interface
+func (fmd *FakeMysqlDaemon) GetMysqlPort() (int, error) {
+	if fmd.MysqlPort == -1 {
+		return 0, fmt.Errorf("FakeMysqlDaemon.GetMysqlPort returns an error")
+	}
+	return fmd.MysqlPort, nil
+}
+
+// IsReadOnly is part of the MysqlDaemon interface
+func (fmd *FakeMysqlDaemon) IsReadOnly() (bool, error) {
+	return fmd.ReadOnly, nil
+}
+
+// SetReadOnly is part of the MysqlDaemon interface
+func (fmd *FakeMysqlDaemon) SetReadOnly(on bool) error {
+	fmd.ReadOnly = on
+	return nil
+}
+
+// StartSlave is part of the MysqlDaemon interface
+func (fmd *FakeMysqlDaemon) StartSlave(hookExtraEnv map[string]string) error {
+	fmd.Replicating = true
+	return nil
+}
+
+// StopSlave is part of the MysqlDaemon interface
+func (fmd *FakeMysqlDaemon) StopSlave(hookExtraEnv map[string]string) error {
+	fmd.Replicating = false
+	return nil
+}
+
+// SlaveStatus is part of the MysqlDaemon interface
+func (fmd *FakeMysqlDaemon) SlaveStatus() (*proto.ReplicationStatus, error) {
+	if fmd.CurrentSlaveStatus == nil {
+		return nil, fmt.Errorf("no slave status defined")
+	}
+	return fmd.CurrentSlaveStatus, nil
+}
+
+// WaitMasterPos is part of the MysqlDaemon interface. It doesn't
+// wait: it fails if CurrentSlaveStatus is not at targetPos yet.
+func (fmd *FakeMysqlDaemon) WaitMasterPos(targetPos proto.ReplicationPosition, waitTimeout time.Duration) error {
+	if fmd.CurrentSlaveStatus == nil {
+		return fmt.Errorf("no slave status defined")
+	}
+	if !fmd.CurrentSlaveStatus.Position.AtLeast(targetPos) {
+		return fmt.Errorf("slave position %v is not at %v", fmd.CurrentSlaveStatus.Position, targetPos)
+	}
+	return nil
+}
+
+// StartSlaveUntilAfter is part of the MysqlDaemon interface. It
+// moves CurrentSlaveStatus to targetPos, and leaves the slave stopped.
+func (fmd *FakeMysqlDaemon) StartSlaveUntilAfter(targetPos proto.ReplicationPosition, waitTimeout time.Duration) error {
+	if fmd.CurrentSlaveStatus == nil {
+		return fmt.Errorf("no slave status defined")
+	}
+	if !targetPos.AtLeast(fmd.CurrentSlaveStatus.Position) {
+		return fmt.Errorf("slave position %v is already past %v", fmd.CurrentSlaveStatus.Position, targetPos)
+	}
+	fmd.CurrentSlaveStatus.Position = targetPos
+	fmd.Replicating = false
+	return nil
+}
+
+// MasterPosition is part of the MysqlDaemon interface
+func (fmd *FakeMysqlDaemon) MasterPosition() (proto.ReplicationPosition, error) {
+	return fmd.CurrentMasterPosition, nil
+}
+
+// GetSchema is part of the MysqlDaemon interface
+func (fmd *FakeMysqlDaemon) GetSchema(dbName string, tables, excludeTables []string, includeViews bool) (*proto.SchemaDefinition, error) {
+	if fmd.Schema == nil {
+		return nil, fmt.Errorf("no schema defined")
+	}
+	return fmd.Schema.FilterTables(tables, excludeTables, includeViews)
+}
+
+// GetDbConnection is part of the MysqlDaemon interface
+func (fmd *FakeMysqlDaemon) GetDbConnection(dbconfigName dbconfigs.DbConfigName) (dbconnpool.PoolConnection, error) {
+	switch dbconfigName {
+	case dbconfigs.DbaConfigName:
+		if fmd.DbaConnectionFactory == nil {
+			return nil, fmt.Errorf("no DbaConnectionFactory set in this FakeMysqlDaemon")
+		}
+		return fmd.DbaConnectionFactory()
+	case dbconfigs.AppConfigName:
+		if fmd.DbAppConnectionFactory == nil {
+			return nil, fmt.Errorf("no DbAppConnectionFactory set in this FakeMysqlDaemon")
+		}
+		return fmd.DbAppConnectionFactory()
+	}
+	return nil, fmt.Errorf("unknown dbconfigName: %v", dbconfigName)
+}
+
+// make sure FakeMysqlDaemon implements mysqlctl.MysqlDaemon
+var _ mysqlctl.MysqlDaemon = (*FakeMysqlDaemon)(nil)
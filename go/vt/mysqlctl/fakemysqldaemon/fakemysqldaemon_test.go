@@ -0,0 +1,45 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fakemysqldaemon
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/youtube/vitess/go/vt/mysqlctl"
+)
+
+func TestStartShutdown(t *testing.T) {
+	fmd := &FakeMysqlDaemon{}
+	if err := fmd.Shutdown(true, 0); err == nil {
+		t.Errorf("Shutdown of a stopped daemon should have failed")
+	}
+	if err := fmd.Start(0); err != nil || !fmd.Running {
+		t.Errorf("Start = %v, Running = %v, want nil, true", err, fmd.Running)
+	}
+	if err := fmd.Start(0); err == nil {
+		t.Errorf("Start of a running daemon should have failed")
+	}
+
+	fmd.ShutdownError = fmt.Errorf("cannot stop")
+	if err := fmd.Shutdown(true, 0); err != fmd.ShutdownError || !fmd.Running {
+		t.Errorf("Shutdown = %v, Running = %v, want scripted error, true", err, fmd.Running)
+	}
+	fmd.ShutdownError = nil
+	if err := fmd.Shutdown(true, 0); err != nil || fmd.Running {
+		t.Errorf("Shutdown = %v, Running = %v, want nil, false", err, fmd.Running)
+	}
+}
+
+func TestGetMasterAddr(t *testing.T) {
+	fmd := &FakeMysqlDaemon{}
+	if _, err := fmd.GetMasterAddr(); err != mysqlctl.ErrNotSlave {
+		t.Errorf("GetMasterAddr on a master = %v, want ErrNotSlave", err)
+	}
+	fmd.MasterAddr = "1.2.3.4:3306"
+	if addr, err := fmd.GetMasterAddr(); err != nil || addr != fmd.MasterAddr {
+		t.Errorf("GetMasterAddr = %v, %v, want %v", addr, err, fmd.MasterAddr)
+	}
+}
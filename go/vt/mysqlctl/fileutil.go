@@ -8,6 +8,7 @@ import (
 	"bufio"
 	//	"crypto/md5"
 	"encoding/hex"
+	"flag"
 	"fmt"
 	"hash"
 	//	"hash/crc64"
@@ -21,8 +22,8 @@ import (
 	"strings"
 	"sync"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/cgzip"
+	"github.com/youtube/vitess/go/compression"
 	"github.com/youtube/vitess/go/vt/mysqlctl/proto"
 )
 
@@ -32,6 +33,14 @@ var (
 	failureCounter   = 0
 )
 
+var snapshotCompression = flag.String("snapshot_transfer_compression", "gzip", "comma-separated list of the compression codecs of the snapshot transfers, in order of preference, 'none' for no compression. The tablet serving a file uses the first one the restoring tablet supports.")
+
+// SnapshotCompressionCodecs returns the codecs of the
+// -snapshot_transfer_compression flag.
+func SnapshotCompressionCodecs() ([]compression.Codec, error) {
+	return compression.ParseList(*snapshotCompression)
+}
+
 func init() {
 	_, statErr := os.Stat("/tmp/vtSimulateFetchFailures")
 	simulateFailures = statErr == nil
@@ -132,11 +141,11 @@ func newSnapshotFile(srcPath, dstPath, root string, compress bool) (*SnapshotFil
 	var hash string
 	var size int64
 	if compress {
-		log.Infof("newSnapshotFile: starting to compress %v into %v", srcPath, dstPath)
+		mlog.Infof("newSnapshotFile: starting to compress %v into %v", srcPath, dstPath)
 
 		// open the temporary destination file
 		dir, filePrefix := path.Split(dstPath)
-		dstFile, err := ioutil.TempFile(dir, filePrefix)
+		dstFile, err := ioutil.TempFile(dir, filePrefix+tempFileMarker)
 		if err != nil {
 			return nil, err
 		}
@@ -189,7 +198,7 @@ func newSnapshotFile(srcPath, dstPath, root string, compress bool) (*SnapshotFil
 		}
 		size = fi.Size()
 	} else {
-		log.Infof("newSnapshotFile: starting to hash and symlinking %v to %v", srcPath, dstPath)
+		mlog.Infof("newSnapshotFile: starting to hash and symlinking %v to %v", srcPath, dstPath)
 
 		// get the hash
 		hasher := newHasher()
@@ -213,7 +222,7 @@ func newSnapshotFile(srcPath, dstPath, root string, compress bool) (*SnapshotFil
 		size = fi.Size()
 	}
 
-	log.Infof("clone data ready %v:%v", dstPath, hash)
+	mlog.Infof("clone data ready %v:%v", dstPath, hash)
 	relativeDst, err := filepath.Rel(root, dstPath)
 	if err != nil {
 		return nil, err
@@ -243,10 +252,13 @@ func newSnapshotFiles(sources, destinations []string, root string, concurrency i
 	for i := 0; i < concurrency; i++ {
 		go func() {
 			for i := range workQueue {
-				sf, err := newSnapshotFile(sources[i], destinations[i], root, compress)
-				if err == nil {
-					snapshotFiles[i] = *sf
-				}
+				err := WithTransferPriority(func() error {
+					sf, err := newSnapshotFile(sources[i], destinations[i], root, compress)
+					if err == nil {
+						snapshotFiles[i] = *sf
+					}
+					return err
+				})
 				resultQueue <- err
 			}
 		}()
@@ -265,7 +277,7 @@ func newSnapshotFiles(sources, destinations []string, root string, concurrency i
 	// its destination when it worked, we could assume if the file
 	// already exists it's good, and re-compute its hash.
 	if err != nil {
-		log.Infof("Error happened, deleting all the files we already compressed")
+		mlog.Infof("Error happened, deleting all the files we already compressed")
 		for _, dest := range destinations {
 			os.Remove(dest)
 		}
@@ -307,19 +319,28 @@ func newSnapshotManifest(addr, mysqlAddr, masterAddr, dbName string, files []Sna
 // fetchFile fetches data from the web server.  It then sends it to a
 // tee, which on one side has an hash checksum reader, and on the other
 // a gunzip reader writing to a file.  It will compare the hash
-// checksum after the copy is done.
-func fetchFile(srcUrl, srcHash, dstFilename string) error {
-	log.Infof("fetchFile: starting to fetch %v from %v", dstFilename, srcUrl)
+// checksum after the copy is done. If fanOut is more than one, the
+// server is told how many tablets fetch the file at the same time.
+func fetchFile(srcUrl, srcHash, dstFilename string, fanOut int) error {
+	mlog.Infof("fetchFile: starting to fetch %v from %v", dstFilename, srcUrl)
 
 	// open the URL
-	req, err := http.NewRequest("GET", srcUrl, nil)
+	reqUrl := srcUrl
+	if fanOut > 1 {
+		reqUrl = fmt.Sprintf("%v?%v=%v", srcUrl, SnapshotFanOutParameter, fanOut)
+	}
+	req, err := http.NewRequest("GET", reqUrl, nil)
 	if err != nil {
 		return fmt.Errorf("NewRequest failed for %v: %v", srcUrl, err)
 	}
-	// we set the 'gzip' encoding ourselves so the library doesn't
+	// we set the encodings ourselves so the library doesn't
 	// do it for us and ends up using go gzip (we want to use our own
 	// cgzip which is much faster)
-	req.Header.Set("Accept-Encoding", "gzip")
+	codecs, err := SnapshotCompressionCodecs()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept-Encoding", compression.FormatList(codecs))
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
@@ -330,19 +351,19 @@ func fetchFile(srcUrl, srcHash, dstFilename string) error {
 	defer resp.Body.Close()
 
 	// see if we need some uncompression
-	var reader io.Reader = resp.Body
+	reader := NewThrottledReader(resp.Body)
 	ce := resp.Header.Get("Content-Encoding")
 	if ce != "" {
-		if ce == "gzip" {
-			gz, err := cgzip.NewReader(reader)
-			if err != nil {
-				return err
-			}
-			defer gz.Close()
-			reader = gz
-		} else {
+		codec := compression.Negotiate(codecs, ce)
+		if codec == nil {
 			return fmt.Errorf("unsupported Content-Encoding: %v", ce)
 		}
+		decompressor, err := compression.NewReader(codec, reader)
+		if err != nil {
+			return err
+		}
+		defer decompressor.Close()
+		reader = decompressor
 	}
 
 	return uncompressAndCheck(reader, srcHash, dstFilename, strings.HasSuffix(srcUrl, ".gz"))
@@ -360,7 +381,7 @@ func uncompressAndCheck(reader io.Reader, srcHash, dstFilename string, needsUnco
 	}
 
 	// create a temporary file to uncompress to
-	dstFile, err := ioutil.TempFile(dir, filePrefix)
+	dstFile, err := ioutil.TempFile(dir, filePrefix+tempFileMarker)
 	if err != nil {
 		return err
 	}
@@ -415,7 +436,7 @@ func uncompressAndCheck(reader io.Reader, srcHash, dstFilename string, needsUnco
 	}
 
 	// we're good
-	log.Infof("processed snapshot file: %v", dstFilename)
+	mlog.Infof("processed snapshot file: %v", dstFilename)
 	dst.Flush()
 	dstFile.Close()
 
@@ -427,17 +448,20 @@ func uncompressAndCheck(reader io.Reader, srcHash, dstFilename string, needsUnco
 }
 
 // fetchFileWithRetry fetches data from the web server, retrying a few
-// times.
-func fetchFileWithRetry(srcUrl, srcHash, dstFilename string, fetchRetryCount int) (err error) {
+// times. If fanOut is more than one, the first try asks the server
+// to send the file to all the fetching tablets at once. The retries
+// don't, as the other tablets have moved on.
+func fetchFileWithRetry(srcUrl, srcHash, dstFilename string, fetchRetryCount, fanOut int) (err error) {
 	for i := 0; i < fetchRetryCount; i++ {
-		err = fetchFile(srcUrl, srcHash, dstFilename)
+		err = fetchFile(srcUrl, srcHash, dstFilename, fanOut)
+		fanOut = 1
 		if err == nil {
 			return nil
 		}
-		log.Warningf("fetching snapshot file %v failed (try=%v): %v", dstFilename, i, err)
+		mlog.Warningf("fetching snapshot file %v failed (try=%v): %v", dstFilename, i, err)
 	}
 
-	log.Errorf("fetching snapshot file %v failed too many times", dstFilename)
+	mlog.Errorf("fetching snapshot file %v failed too many times", dstFilename)
 	return err
 }
 
@@ -463,7 +487,7 @@ func fetchFileWithRetry(srcUrl, srcHash, dstFilename string, fetchRetryCount int
 // For each fileChunk, compare checksum:
 //   - if single file, compare snapshotFile.hash with observedCrc32
 //   - if multiple chunks and first chunk, merge observedCrc32, and compare
-func fetchFiles(snapshotManifest *SnapshotManifest, destinationPath string, fetchConcurrency, fetchRetryCount int) (err error) {
+func fetchFiles(snapshotManifest *SnapshotManifest, destinationPath string, fetchConcurrency, fetchRetryCount, fanOut int) (err error) {
 	// create a workQueue, a resultQueue, and the go routines
 	// to process entries out of workQueue into resultQueue
 	// the mutex protects the error response
@@ -485,7 +509,9 @@ func fetchFiles(snapshotManifest *SnapshotManifest, destinationPath string, fetc
 				// do our fetch, save the error
 				filename := sf.getLocalFilename(destinationPath)
 				furl := "http://" + snapshotManifest.Addr + path.Join(SnapshotURLPath, sf.Path)
-				fetchErr := fetchFileWithRetry(furl, sf.Hash, filename, fetchRetryCount)
+				fetchErr := WithTransferPriority(func() error {
+					return fetchFileWithRetry(furl, sf.Hash, filename, fetchRetryCount, fanOut)
+				})
 				if fetchErr != nil {
 					mutex.Lock()
 					err = fetchErr
@@ -515,7 +541,7 @@ func fetchFiles(snapshotManifest *SnapshotManifest, destinationPath string, fetc
 	// the last one failed. Maybe we shouldn't, and if a file already
 	// exists, we hash it before retransmitting.
 	if err != nil {
-		log.Infof("Error happened, deleting all the files we already got")
+		mlog.Infof("Error happened, deleting all the files we already got")
 		for _, fi := range snapshotManifest.Files {
 			filename := fi.getLocalFilename(destinationPath)
 			os.Remove(filename)
@@ -14,6 +14,7 @@ import (
 
 	rpc "github.com/youtube/vitess/go/rpcplus"
 	"github.com/youtube/vitess/go/rpcwrap/bsonrpc"
+	"github.com/youtube/vitess/go/vt/mysqlctl/gorpcproto"
 	"github.com/youtube/vitess/go/vt/mysqlctl/mysqlctlclient"
 )
 
@@ -44,6 +45,15 @@ func (c *goRpcMysqlctlClient) Shutdown(waitForMysqld bool, mysqlWaitTime time.Du
 	return c.rpcClient.Call(context.TODO(), "MysqlctlServer.Shutdown", &mysqlWaitTime, nil)
 }
 
+// Reinit is part of the MysqlctlClient interface.
+func (c *goRpcMysqlctlClient) Reinit(mysqlWaitTime time.Duration, bootstrapArchive string, skipSchema bool) error {
+	return c.rpcClient.Call(context.TODO(), "MysqlctlServer.Reinit", &gorpcproto.ReinitArgs{
+		WaitTime:         mysqlWaitTime,
+		BootstrapArchive: bootstrapArchive,
+		SkipSchema:       skipSchema,
+	}, nil)
+}
+
 // Close is part of the MysqlctlClient interface.
 func (client *goRpcMysqlctlClient) Close() {
 	client.rpcClient.Close()
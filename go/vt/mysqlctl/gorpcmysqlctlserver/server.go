@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/youtube/vitess/go/vt/mysqlctl"
+	"github.com/youtube/vitess/go/vt/mysqlctl/gorpcproto"
 	"github.com/youtube/vitess/go/vt/servenv"
 	"golang.org/x/net/context"
 )
@@ -31,6 +32,11 @@ func (s *MysqlctlServer) Shutdown(ctx context.Context, args *time.Duration, repl
 	return s.mysqld.Shutdown(*args > 0, *args)
 }
 
+// Reinit implements the server side of the MysqlctlClient interface.
+func (s *MysqlctlServer) Reinit(ctx context.Context, args *gorpcproto.ReinitArgs, reply *int) error {
+	return s.mysqld.Reinit(args.WaitTime, args.BootstrapArchive, args.SkipSchema)
+}
+
 // StartServer registers the Server for RPCs.
 func StartServer(mysqld *mysqlctl.Mysqld) {
 	servenv.Register("mysqlctl", &MysqlctlServer{mysqld})
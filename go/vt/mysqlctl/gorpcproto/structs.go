@@ -0,0 +1,20 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorpcproto contains the Go RPC definitions of the structures used to
+execute remote mysqlctl commands.
+*/
+package gorpcproto
+
+import (
+	"time"
+)
+
+// ReinitArgs contains the parameters for the Reinit RPC call.
+type ReinitArgs struct {
+	WaitTime         time.Duration
+	BootstrapArchive string
+	SkipSchema       bool
+}
@@ -3,6 +3,7 @@ package mysqlctl
 import (
 	"fmt"
 	"html/template"
+	"syscall"
 	"time"
 
 	"github.com/youtube/vitess/go/vt/health"
@@ -40,3 +41,72 @@ func (mrl *mysqlReplicationLag) HTMLName() template.HTML {
 func MySQLReplicationLag(mysqld *Mysqld) health.Reporter {
 	return &mysqlReplicationLag{mysqld}
 }
+
+// mysqlAlive implements health.Reporter
+type mysqlAlive struct {
+	mysqld *Mysqld
+}
+
+// Report is part of the health.Reporter interface
+func (ma *mysqlAlive) Report(tabletType topo.TabletType, shouldQueryServiceBeRunning bool) (time.Duration, error) {
+	if _, err := ma.mysqld.fetchSuperQuery("SELECT 1"); err != nil {
+		return 0, fmt.Errorf("MySQL is not reachable: %v", err)
+	}
+	return 0, nil
+}
+
+// HTMLName is part of the health.Reporter interface
+func (ma *mysqlAlive) HTMLName() template.HTML {
+	return template.HTML("MySQLAlive")
+}
+
+// MySQLAlive returns a reporter that fails if MySQL cannot be
+// queried.
+func MySQLAlive(mysqld *Mysqld) health.Reporter {
+	return &mysqlAlive{mysqld}
+}
+
+// diskSpace implements health.Reporter
+type diskSpace struct {
+	dir          string
+	minFreeRatio float64
+}
+
+// Report is part of the health.Reporter interface
+func (ds *diskSpace) Report(tabletType topo.TabletType, shouldQueryServiceBeRunning bool) (time.Duration, error) {
+	ratio, err := freeDiskSpaceRatio(ds.dir)
+	if err != nil {
+		return 0, err
+	}
+	if ratio < ds.minFreeRatio {
+		return 0, fmt.Errorf("free disk space on %v is %.2f%%, below %.2f%%", ds.dir, ratio*100, ds.minFreeRatio*100)
+	}
+	return 0, nil
+}
+
+// HTMLName is part of the health.Reporter interface
+func (ds *diskSpace) HTMLName() template.HTML {
+	return template.HTML("DiskSpace")
+}
+
+// freeDiskSpaceRatio returns the ratio of available blocks
+// on the filesystem that contains dir.
+func freeDiskSpaceRatio(dir string) (float64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, fmt.Errorf("cannot stat filesystem for %v: %v", dir, err)
+	}
+	if stat.Blocks == 0 {
+		return 0, fmt.Errorf("filesystem for %v reports no blocks", dir)
+	}
+	return float64(stat.Bavail) / float64(stat.Blocks), nil
+}
+
+// DiskSpace returns a reporter that fails if the ratio of free disk
+// space on the filesystem containing dir falls below minFreeRatio.
+func DiskSpace(dir string, minFreeRatio float64) health.Reporter {
+	return &diskSpace{
+		dir:          dir,
+		minFreeRatio: minFreeRatio,
+	}
+}
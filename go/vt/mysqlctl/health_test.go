@@ -0,0 +1,101 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlctl
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+func TestDiskSpace(t *testing.T) {
+	dir := os.TempDir()
+	if _, err := DiskSpace(dir, 0.0).Report(topo.TYPE_REPLICA, true); err != nil {
+		t.Errorf("DiskSpace(0.0) failed: %v", err)
+	}
+	if _, err := DiskSpace(dir, 1.1).Report(topo.TYPE_REPLICA, true); err == nil {
+		t.Errorf("DiskSpace(1.1) should have failed")
+	}
+	if _, err := DiskSpace("/nonexistent/dir", 0.0).Report(topo.TYPE_REPLICA, true); err == nil {
+		t.Errorf("DiskSpace on a nonexistent dir should have failed")
+	}
+}
+
+func TestDiskMonitor(t *testing.T) {
+	root, err := ioutil.TempDir("", "disk_monitor")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	mysqld := &Mysqld{
+		config: &Mycnf{
+			DataDir:      path.Join(root, "data"),
+			RelayLogPath: path.Join(root, "relay-logs", "vt-relay-bin"),
+			BinLogPath:   path.Join(root, "bin-logs", "vt-bin"),
+		},
+		SnapshotDir: path.Join(root, "snapshot"),
+	}
+	files := map[string]int{
+		"data/vt_test/t1.ibd":              100,
+		"relay-logs/vt-relay-bin.0001":     20,
+		"relay-logs/other":                 5,
+		"bin-logs/vt-bin.0001":             30,
+		"bin-logs/vt-bin.0002":             30,
+		"snapshot/data/vt_test.gz":         10,
+		"snapshot/" + SnapshotManifestFile: 2,
+	}
+	for name, size := range files {
+		p := path.Join(root, name)
+		if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := ioutil.WriteFile(p, make([]byte, size), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	// the snapshot is recent, it is kept
+	dm := NewDiskMonitor(mysqld, time.Hour, 0, nil, false)
+	dm.Refresh()
+	want := map[string]int64{
+		DiskUsageDataDir:     100,
+		DiskUsageRelayLogs:   20,
+		DiskUsageBinLogs:     60,
+		DiskUsageSnapshotDir: 12,
+	}
+	if got := dm.Usage(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Usage() = %v, want %v", got, want)
+	}
+	if dm.FreeRatio() <= 0.0 {
+		t.Errorf("FreeRatio() = %v", dm.FreeRatio())
+	}
+
+	// the snapshot is expired, but the purge is vetoed
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path.Join(mysqld.SnapshotDir, SnapshotManifestFile), old, old); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	dm = NewDiskMonitor(mysqld, time.Hour, 0, func() bool { return false }, false)
+	dm.Refresh()
+	if got := dm.Usage()[DiskUsageSnapshotDir]; got != 12 {
+		t.Errorf("snapshot should not have been purged: %v", got)
+	}
+
+	// and now it is purged
+	dm = NewDiskMonitor(mysqld, time.Hour, 0, nil, false)
+	dm.Refresh()
+	if got := dm.Usage()[DiskUsageSnapshotDir]; got != 0 {
+		t.Errorf("snapshot should have been purged: %v", got)
+	}
+	if _, err := os.Stat(mysqld.SnapshotDir); !os.IsNotExist(err) {
+		t.Errorf("SnapshotDir should be gone: %v", err)
+	}
+}
@@ -108,9 +108,9 @@ func NewMycnfFromFlags(uid uint32) (mycnf *Mycnf, err error) {
 			log.Fatalf("No mycnf_server_id, no mycnf-file, and no backup server id to use")
 		}
 		*flagMycnfFile = mycnfFile(uid)
-		log.Infof("No mycnf_server_id, no mycnf-file specified, using default config for server id %v: %v", uid, *flagMycnfFile)
+		mlog.Infof("No mycnf_server_id, no mycnf-file specified, using default config for server id %v: %v", uid, *flagMycnfFile)
 	} else {
-		log.Infof("No mycnf_server_id specified, using mycnf-file file %v", *flagMycnfFile)
+		mlog.Infof("No mycnf_server_id specified, using mycnf-file file %v", *flagMycnfFile)
 	}
 	return ReadMycnf(*flagMycnfFile)
 }
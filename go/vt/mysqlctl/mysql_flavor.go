@@ -9,7 +9,6 @@ import (
 	"os"
 	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/sqldb"
 	blproto "github.com/youtube/vitess/go/vt/binlog/proto"
 	"github.com/youtube/vitess/go/vt/mysqlctl/proto"
@@ -59,6 +58,10 @@ type MysqlFlavor interface {
 	// WaitMasterPos waits until slave replication reaches at least targetPos.
 	WaitMasterPos(mysqld *Mysqld, targetPos proto.ReplicationPosition, waitTimeout time.Duration) error
 
+	// StartSlaveUntilAfterCommand returns the command to start slave
+	// replication, and stop it right after targetPos is applied.
+	StartSlaveUntilAfterCommand(targetPos proto.ReplicationPosition) (string, error)
+
 	// EnableBinlogPlayback prepares the server to play back events from a binlog stream.
 	// Whatever it does for a given flavor, it must be idempotent.
 	EnableBinlogPlayback(mysqld *Mysqld) error
@@ -95,14 +98,14 @@ func (mysqld *Mysqld) detectFlavor() (MysqlFlavor, error) {
 	// Check environment variable, which overrides auto-detect.
 	if env := os.Getenv("MYSQL_FLAVOR"); env != "" {
 		if flavor, ok := mysqlFlavors[env]; ok {
-			log.Infof("Using MySQL flavor %v (set by MYSQL_FLAVOR)", env)
+			mlog.Infof("Using MySQL flavor %v (set by MYSQL_FLAVOR)", env)
 			return flavor, nil
 		}
 		return nil, fmt.Errorf("Unknown flavor (MYSQL_FLAVOR=%v)", env)
 	}
 
 	// If no environment variable set, fall back to auto-detect.
-	log.Infof("MYSQL_FLAVOR empty or unset, attempting to auto-detect...")
+	mlog.Infof("MYSQL_FLAVOR empty or unset, attempting to auto-detect...")
 	qr, err := mysqld.fetchSuperQuery("SELECT VERSION()")
 	if err != nil {
 		return nil, fmt.Errorf("couldn't SELECT VERSION(): %v", err)
@@ -111,11 +114,11 @@ func (mysqld *Mysqld) detectFlavor() (MysqlFlavor, error) {
 		return nil, fmt.Errorf("unexpected result for SELECT VERSION(): %#v", qr)
 	}
 	version := qr.Rows[0][0].String()
-	log.Infof("SELECT VERSION() = %s", version)
+	mlog.Infof("SELECT VERSION() = %s", version)
 
 	for name, flavor := range mysqlFlavors {
 		if flavor.VersionMatch(version) {
-			log.Infof("Using MySQL flavor %v (auto-detect match)", name)
+			mlog.Infof("Using MySQL flavor %v (auto-detect match)", name)
 			return flavor, nil
 		}
 	}
@@ -112,12 +112,19 @@ func (*googleMysql51) WaitMasterPos(mysqld *Mysqld, targetPos proto.ReplicationP
 			return fmt.Errorf("slave not running during WaitMasterPos and no timeout is set, status = %+v", status)
 		}
 
-		log.Infof("WaitMasterPos got position %v, sleeping for 1s waiting for position %v", status.Position, targetPos)
+		mlog.Infof("WaitMasterPos got position %v, sleeping for 1s waiting for position %v", status.Position, targetPos)
 		time.Sleep(time.Second)
 	}
 	return fmt.Errorf("timed out waiting for position %v", targetPos)
 }
 
+// StartSlaveUntilAfterCommand implements MysqlFlavor.StartSlaveUntilAfterCommand().
+// START SLAVE UNTIL only takes binlog file positions in this flavor,
+// not group IDs.
+func (*googleMysql51) StartSlaveUntilAfterCommand(targetPos proto.ReplicationPosition) (string, error) {
+	return "", fmt.Errorf("StartSlaveUntilAfter is not supported by the GoogleMysql flavor")
+}
+
 // PromoteSlaveCommands implements MysqlFlavor.PromoteSlaveCommands().
 func (*googleMysql51) PromoteSlaveCommands() []string {
 	return []string{
@@ -169,7 +176,7 @@ func (*googleMysql51) EnableBinlogPlayback(mysqld *Mysqld) error {
 	// The Google-specific option super_to_set_timestamp is on by default.
 	// We need to turn it off when we're about to start binlog streamer.
 	if err := mysqld.ExecuteSuperQuery("SET @@global.super_to_set_timestamp = 0"); err != nil {
-		log.Errorf("Cannot set super_to_set_timestamp=0: %v", err)
+		mlog.Errorf("Cannot set super_to_set_timestamp=0: %v", err)
 		return fmt.Errorf("EnableBinlogPlayback: can't set super_to_timestamp=0: %v", err)
 	}
 
@@ -181,7 +188,7 @@ func (*googleMysql51) EnableBinlogPlayback(mysqld *Mysqld) error {
 func (*googleMysql51) DisableBinlogPlayback(mysqld *Mysqld) error {
 	// Re-enable super_to_set_timestamp when we're done streaming.
 	if err := mysqld.ExecuteSuperQuery("SET @@global.super_to_set_timestamp = 1"); err != nil {
-		log.Warningf("Cannot set super_to_set_timestamp=1: %v", err)
+		mlog.Warningf("Cannot set super_to_set_timestamp=1: %v", err)
 		return fmt.Errorf("DisableBinlogPlayback: can't set super_to_timestamp=1: %v", err)
 	}
 
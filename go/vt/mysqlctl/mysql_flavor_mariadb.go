@@ -10,7 +10,6 @@ import (
 	"strings"
 	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/sqldb"
 	blproto "github.com/youtube/vitess/go/vt/binlog/proto"
 	"github.com/youtube/vitess/go/vt/mysqlctl/proto"
@@ -68,7 +67,7 @@ func (*mariaDB10) WaitMasterPos(mysqld *Mysqld, targetPos proto.ReplicationPosit
 		query = fmt.Sprintf("SELECT MASTER_GTID_WAIT('%s', %.6f)", targetPos, waitTimeout.Seconds())
 	}
 
-	log.Infof("Waiting for minimum replication position with query: %v", query)
+	mlog.Infof("Waiting for minimum replication position with query: %v", query)
 	qr, err := mysqld.fetchSuperQuery(query)
 	if err != nil {
 		return fmt.Errorf("MASTER_GTID_WAIT() failed: %v", err)
@@ -83,6 +82,11 @@ func (*mariaDB10) WaitMasterPos(mysqld *Mysqld, targetPos proto.ReplicationPosit
 	return nil
 }
 
+// StartSlaveUntilAfterCommand implements MysqlFlavor.StartSlaveUntilAfterCommand().
+func (*mariaDB10) StartSlaveUntilAfterCommand(targetPos proto.ReplicationPosition) (string, error) {
+	return fmt.Sprintf("START SLAVE UNTIL master_gtid_pos = '%s'", targetPos), nil
+}
+
 // PromoteSlaveCommands implements MysqlFlavor.PromoteSlaveCommands().
 func (*mariaDB10) PromoteSlaveCommands() []string {
 	return []string{
@@ -288,6 +288,18 @@ func TestMariadbPromoteSlaveCommands(t *testing.T) {
 	}
 }
 
+func TestMariadbStartSlaveUntilAfterCommand(t *testing.T) {
+	pos := proto.ReplicationPosition{GTIDSet: proto.MariadbGTID{Domain: 1, Server: 41983, Sequence: 12345}}
+	want := "START SLAVE UNTIL master_gtid_pos = '1-41983-12345'"
+	got, err := (&mariaDB10{}).StartSlaveUntilAfterCommand(pos)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != want {
+		t.Errorf("(&mariaDB10{}).StartSlaveUntilAfterCommand(%v) = %#v, want %#v", pos, got, want)
+	}
+}
+
 func TestMariadbVersionMatch(t *testing.T) {
 	table := map[string]bool{
 		"10.0.13-MariaDB-1~precise-log": true,
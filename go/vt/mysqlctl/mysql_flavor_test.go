@@ -29,6 +29,9 @@ func (fakeMysqlFlavor) SendBinlogDumpCommand(mysqld *Mysqld, conn *SlaveConnecti
 func (fakeMysqlFlavor) WaitMasterPos(mysqld *Mysqld, targetPos proto.ReplicationPosition, waitTimeout time.Duration) error {
 	return nil
 }
+func (fakeMysqlFlavor) StartSlaveUntilAfterCommand(targetPos proto.ReplicationPosition) (string, error) {
+	return "", nil
+}
 func (fakeMysqlFlavor) MasterPosition(mysqld *Mysqld) (proto.ReplicationPosition, error) {
 	return proto.ReplicationPosition{}, nil
 }
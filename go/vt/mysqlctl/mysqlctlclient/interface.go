@@ -22,6 +22,8 @@ type MysqlctlClient interface {
 	Start(mysqlWaitTime time.Duration) error
 	// Shutdown calls Mysqld.Shutdown remotely.
 	Shutdown(waitForMysqld bool, mysqlWaitTime time.Duration) error
+	// Reinit calls Mysqld.Reinit remotely.
+	Reinit(mysqlWaitTime time.Duration, bootstrapArchive string, skipSchema bool) error
 
 	// Close will terminate the connection. This object won't be used anymore.
 	Close()
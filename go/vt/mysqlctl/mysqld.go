@@ -25,7 +25,6 @@ import (
 	"sync"
 	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/netutil"
 	"github.com/youtube/vitess/go/sqldb"
 	"github.com/youtube/vitess/go/stats"
@@ -33,6 +32,7 @@ import (
 	"github.com/youtube/vitess/go/vt/dbconnpool"
 	vtenv "github.com/youtube/vitess/go/vt/env"
 	"github.com/youtube/vitess/go/vt/hook"
+	"github.com/youtube/vitess/go/vt/logutil"
 	"github.com/youtube/vitess/go/vt/mysqlctl/mysqlctlclient"
 )
 
@@ -50,6 +50,10 @@ var (
 	appIdleTimeout = flag.Duration("app_idle_timeout", time.Minute, "Idle timeout for app connections")
 
 	socketFile = flag.String("mysqlctl_socket", "", "socket file to use for remote mysqlctl actions (empty for local actions)")
+
+	// mlog is the logger of the package, its level can be changed
+	// at runtime (see /debug/loglevel).
+	mlog = logutil.NewModuleLogger("mysqlctl")
 )
 
 // Mysqld is the object that represents a mysqld daemon running on this server.
@@ -111,7 +115,7 @@ func (mysqld *Mysqld) Cnf() *Mycnf {
 func (mysqld *Mysqld) Start(mysqlWaitTime time.Duration) error {
 	// Execute as remote action on mysqlctld if requested.
 	if *socketFile != "" {
-		log.Infof("executing Mysqld.Start() remotely via mysqlctld server: %v", *socketFile)
+		mlog.Infof("executing Mysqld.Start() remotely via mysqlctld server: %v", *socketFile)
 		client, err := mysqlctlclient.New("unix", *socketFile, mysqlWaitTime)
 		if err != nil {
 			return fmt.Errorf("can't dial mysqlctld: %v", err)
@@ -130,7 +134,7 @@ func (mysqld *Mysqld) Start(mysqlWaitTime time.Duration) error {
 		name = "mysqld_start hook"
 	case hook.HOOK_DOES_NOT_EXIST:
 		// hook doesn't exist, run mysqld_safe ourselves
-		log.Infof("%v: No mysqld_start hook, running mysqld_safe directly", ts)
+		mlog.Infof("%v: No mysqld_start hook, running mysqld_safe directly", ts)
 		dir, err := vtenv.VtMysqlRoot()
 		if err != nil {
 			return err
@@ -143,7 +147,7 @@ func (mysqld *Mysqld) Start(mysqlWaitTime time.Duration) error {
 		cmd := exec.Command(name, arg...)
 		cmd.Dir = dir
 		cmd.Env = env
-		log.Infof("%v mysqlWaitTime:%v %#v", ts, mysqlWaitTime, cmd)
+		mlog.Infof("%v mysqlWaitTime:%v %#v", ts, mysqlWaitTime, cmd)
 		stderr, err := cmd.StderrPipe()
 		if err != nil {
 			return nil
@@ -155,13 +159,13 @@ func (mysqld *Mysqld) Start(mysqlWaitTime time.Duration) error {
 		go func() {
 			scanner := bufio.NewScanner(stderr)
 			for scanner.Scan() {
-				log.Infof("%v stderr: %v", ts, scanner.Text())
+				mlog.Infof("%v stderr: %v", ts, scanner.Text())
 			}
 		}()
 		go func() {
 			scanner := bufio.NewScanner(stdout)
 			for scanner.Scan() {
-				log.Infof("%v stdout: %v", ts, scanner.Text())
+				mlog.Infof("%v stdout: %v", ts, scanner.Text())
 			}
 		}()
 		err = cmd.Start()
@@ -174,7 +178,7 @@ func (mysqld *Mysqld) Start(mysqlWaitTime time.Duration) error {
 		go func(cancel <-chan struct{}) {
 			// Wait regardless of cancel, so we don't generate defunct processes.
 			err := cmd.Wait()
-			log.Infof("%v exit: %v", ts, err)
+			mlog.Infof("%v exit: %v", ts, err)
 
 			// The process exited. Trigger OnTerm callbacks, unless we were cancelled.
 			select {
@@ -207,7 +211,7 @@ func (mysqld *Mysqld) Start(mysqlWaitTime time.Duration) error {
 		} else if !os.IsNotExist(statErr) {
 			return statErr
 		}
-		log.Infof("%v: sleeping for 1s waiting for socket file %v", ts, mysqld.config.SocketFile)
+		mlog.Infof("%v: sleeping for 1s waiting for socket file %v", ts, mysqld.config.SocketFile)
 		time.Sleep(time.Second)
 	}
 	return errors.New(name + ": deadline exceeded waiting for " + mysqld.config.SocketFile)
@@ -221,11 +225,11 @@ func (mysqld *Mysqld) Start(mysqlWaitTime time.Duration) error {
 //
 // If a mysqlctld address is provided in a flag, Shutdown will run remotely.
 func (mysqld *Mysqld) Shutdown(waitForMysqld bool, mysqlWaitTime time.Duration) error {
-	log.Infof("Mysqld.Shutdown")
+	mlog.Infof("Mysqld.Shutdown")
 
 	// Execute as remote action on mysqlctld if requested.
 	if *socketFile != "" {
-		log.Infof("executing Mysqld.Shutdown() remotely via mysqlctld server: %v", *socketFile)
+		mlog.Infof("executing Mysqld.Shutdown() remotely via mysqlctld server: %v", *socketFile)
 		client, err := mysqlctlclient.New("unix", *socketFile, mysqlWaitTime)
 		if err != nil {
 			return fmt.Errorf("can't dial mysqlctld: %v", err)
@@ -247,7 +251,7 @@ func (mysqld *Mysqld) Shutdown(waitForMysqld bool, mysqlWaitTime time.Duration)
 	_, socketPathErr := os.Stat(mysqld.config.SocketFile)
 	_, pidPathErr := os.Stat(mysqld.config.PidFile)
 	if socketPathErr != nil && pidPathErr != nil {
-		log.Warningf("assuming mysqld already shut down - no socket, no pid file found")
+		mlog.Warningf("assuming mysqld already shut down - no socket, no pid file found")
 		return nil
 	}
 
@@ -259,7 +263,7 @@ func (mysqld *Mysqld) Shutdown(waitForMysqld bool, mysqlWaitTime time.Duration)
 		// hook exists and worked, we can keep going
 	case hook.HOOK_DOES_NOT_EXIST:
 		// hook doesn't exist, try mysqladmin
-		log.Infof("No mysqld_shutdown hook, running mysqladmin directly")
+		mlog.Infof("No mysqld_shutdown hook, running mysqladmin directly")
 		dir, err := vtenv.VtMysqlRoot()
 		if err != nil {
 			return err
@@ -288,7 +292,7 @@ func (mysqld *Mysqld) Shutdown(waitForMysqld bool, mysqlWaitTime time.Duration)
 			if statErr != nil && os.IsNotExist(statErr) {
 				return nil
 			}
-			log.Infof("Mysqld.Shutdown: sleeping for 1s waiting for socket file %v", mysqld.config.SocketFile)
+			mlog.Infof("Mysqld.Shutdown: sleeping for 1s waiting for socket file %v", mysqld.config.SocketFile)
 			time.Sleep(time.Second)
 		}
 		return errors.New("gave up waiting for mysqld to stop")
@@ -299,7 +303,7 @@ func (mysqld *Mysqld) Shutdown(waitForMysqld bool, mysqlWaitTime time.Duration)
 /* exec and wait for a return code. look for name in $PATH. */
 func execCmd(name string, args, env []string, dir string) (cmd *exec.Cmd, err error) {
 	cmdPath, _ := exec.LookPath(name)
-	log.Infof("execCmd: %v %v %v", name, cmdPath, args)
+	mlog.Infof("execCmd: %v %v %v", name, cmdPath, args)
 
 	cmd = exec.Command(cmdPath, args...)
 	cmd.Env = env
@@ -308,7 +312,7 @@ func execCmd(name string, args, env []string, dir string) (cmd *exec.Cmd, err er
 	if err != nil {
 		err = errors.New(name + ": " + string(output))
 	}
-	log.Infof("execCmd: command returned: %v", string(output))
+	mlog.Infof("execCmd: command returned: %v", string(output))
 	return cmd, err
 }
 
@@ -316,36 +320,36 @@ func execCmd(name string, args, env []string, dir string) (cmd *exec.Cmd, err er
 // generate / configure a my.cnf file, unpack a skeleton database,
 // and create some management tables.
 func (mysqld *Mysqld) Init(mysqlWaitTime time.Duration, bootstrapArchive string, skipSchema bool) error {
-	log.Infof("mysqlctl.Init")
+	mlog.Infof("mysqlctl.Init")
 	err := mysqld.createDirs()
 	if err != nil {
-		log.Errorf("%s", err.Error())
+		mlog.Errorf("%s", err.Error())
 		return err
 	}
 	root, err := vtenv.VtRoot()
 	if err != nil {
-		log.Errorf("%s", err.Error())
+		mlog.Errorf("%s", err.Error())
 		return err
 	}
 
 	// Set up config files.
 	if err = mysqld.initConfig(root); err != nil {
-		log.Errorf("failed creating %v: %v", mysqld.config.path, err)
+		mlog.Errorf("failed creating %v: %v", mysqld.config.path, err)
 		return err
 	}
 
 	// Unpack bootstrap DB files.
 	dbTbzPath := path.Join(root, "data/bootstrap/"+bootstrapArchive)
-	log.Infof("decompress bootstrap db %v", dbTbzPath)
+	mlog.Infof("decompress bootstrap db %v", dbTbzPath)
 	args := []string{"-xj", "-C", mysqld.TabletDir, "-f", dbTbzPath}
 	if _, err = execCmd("tar", args, []string{}, ""); err != nil {
-		log.Errorf("failed unpacking %v: %v", dbTbzPath, err)
+		mlog.Errorf("failed unpacking %v: %v", dbTbzPath, err)
 		return err
 	}
 
 	// Start mysqld.
 	if err = mysqld.Start(mysqlWaitTime); err != nil {
-		log.Errorf("failed starting, check %v", mysqld.config.ErrorLogPath)
+		mlog.Errorf("failed starting, check %v", mysqld.config.ErrorLogPath)
 		return err
 	}
 
@@ -360,7 +364,7 @@ func (mysqld *Mysqld) Init(mysqlWaitTime time.Duration, bootstrapArchive string,
 	}
 
 	sqlCmds := make([]string, 0, 10)
-	log.Infof("initial schema: %v", string(schema))
+	mlog.Infof("initial schema: %v", string(schema))
 	for _, cmd := range strings.Split(string(schema), ";") {
 		cmd = strings.TrimSpace(cmd)
 		if cmd == "" {
@@ -372,13 +376,36 @@ func (mysqld *Mysqld) Init(mysqlWaitTime time.Duration, bootstrapArchive string,
 	return mysqld.ExecuteSuperQueryList(sqlCmds)
 }
 
+// Reinit shuts mysqld down, removes all its files, and initializes it
+// again, as Init does. This is used to recover from a broken mysqld.
+// If a mysqlctld address is provided in a flag, Reinit will run
+// remotely, so mysqlctld keeps owning the new mysqld process.
+func (mysqld *Mysqld) Reinit(mysqlWaitTime time.Duration, bootstrapArchive string, skipSchema bool) error {
+	// Execute as remote action on mysqlctld if requested.
+	if *socketFile != "" {
+		mlog.Infof("executing Mysqld.Reinit() remotely via mysqlctld server: %v", *socketFile)
+		client, err := mysqlctlclient.New("unix", *socketFile, mysqlWaitTime)
+		if err != nil {
+			return fmt.Errorf("can't dial mysqlctld: %v", err)
+		}
+		defer client.Close()
+		return client.Reinit(mysqlWaitTime, bootstrapArchive, skipSchema)
+	}
+
+	mlog.Infof("mysqlctl.Reinit")
+	if err := mysqld.Teardown(true); err != nil {
+		return fmt.Errorf("failed teardown before reinit: %v", err)
+	}
+	return mysqld.Init(mysqlWaitTime, bootstrapArchive, skipSchema)
+}
+
 func (mysqld *Mysqld) initConfig(root string) error {
 	var err error
 	var configData string
 
 	switch hr := hook.NewSimpleHook("make_mycnf").Execute(); hr.ExitStatus {
 	case hook.HOOK_DOES_NOT_EXIST:
-		log.Infof("make_mycnf hook doesn't exist, reading default template files")
+		mlog.Infof("make_mycnf hook doesn't exist, reading default template files")
 		cnfTemplatePaths := []string{
 			path.Join(root, "config/mycnf/default.cnf"),
 			path.Join(root, "config/mycnf/master.cnf"),
@@ -404,7 +431,7 @@ func (mysqld *Mysqld) initConfig(root string) error {
 }
 
 func (mysqld *Mysqld) createDirs() error {
-	log.Infof("creating directory %s", mysqld.TabletDir)
+	mlog.Infof("creating directory %s", mysqld.TabletDir)
 	if err := os.MkdirAll(mysqld.TabletDir, 0775); err != nil {
 		return err
 	}
@@ -414,7 +441,7 @@ func (mysqld *Mysqld) createDirs() error {
 		}
 	}
 	for _, dir := range mysqld.config.directoryList() {
-		log.Infof("creating directory %s", dir)
+		mlog.Infof("creating directory %s", dir)
 		if err := os.MkdirAll(dir, 0775); err != nil {
 			return err
 		}
@@ -437,27 +464,27 @@ func (mysqld *Mysqld) createTopDir(dir string) error {
 	if err != nil {
 		if os.IsNotExist(err) {
 			topdir := path.Join(mysqld.TabletDir, dir)
-			log.Infof("creating directory %s", topdir)
+			mlog.Infof("creating directory %s", topdir)
 			return os.MkdirAll(topdir, 0775)
 		}
 		return err
 	}
 	linkto := path.Join(target, vtname)
 	source := path.Join(mysqld.TabletDir, dir)
-	log.Infof("creating directory %s", linkto)
+	mlog.Infof("creating directory %s", linkto)
 	err = os.MkdirAll(linkto, 0775)
 	if err != nil {
 		return err
 	}
-	log.Infof("creating symlink %s -> %s", source, linkto)
+	mlog.Infof("creating symlink %s -> %s", source, linkto)
 	return os.Symlink(linkto, source)
 }
 
 // Teardown will shutdown the running daemon, and delete the root directory.
 func (mysqld *Mysqld) Teardown(force bool) error {
-	log.Infof("mysqlctl.Teardown")
+	mlog.Infof("mysqlctl.Teardown")
 	if err := mysqld.Shutdown(true, MysqlWaitTime); err != nil {
-		log.Warningf("failed mysqld shutdown: %v", err.Error())
+		mlog.Warningf("failed mysqld shutdown: %v", err.Error())
 		if !force {
 			return err
 		}
@@ -475,23 +502,23 @@ func (mysqld *Mysqld) Teardown(force bool) error {
 func deleteTopDir(dir string) (removalErr error) {
 	fi, err := os.Lstat(dir)
 	if err != nil {
-		log.Errorf("error deleting dir %v: %v", dir, err.Error())
+		mlog.Errorf("error deleting dir %v: %v", dir, err.Error())
 		removalErr = err
 	} else if fi.Mode()&os.ModeSymlink != 0 {
 		target, err := filepath.EvalSymlinks(dir)
 		if err != nil {
-			log.Errorf("could not resolve symlink %v: %v", dir, err.Error())
+			mlog.Errorf("could not resolve symlink %v: %v", dir, err.Error())
 			removalErr = err
 		}
-		log.Infof("remove data dir (symlinked) %v", target)
+		mlog.Infof("remove data dir (symlinked) %v", target)
 		if err = os.RemoveAll(target); err != nil {
-			log.Errorf("failed removing %v: %v", target, err.Error())
+			mlog.Errorf("failed removing %v: %v", target, err.Error())
 			removalErr = err
 		}
 	}
-	log.Infof("remove data dir %v", dir)
+	mlog.Infof("remove data dir %v", dir)
 	if err = os.RemoveAll(dir); err != nil {
-		log.Errorf("failed removing %v: %v", dir, err.Error())
+		mlog.Errorf("failed removing %v: %v", dir, err.Error())
 		removalErr = err
 	}
 	return
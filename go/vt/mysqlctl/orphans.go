@@ -0,0 +1,149 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlctl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/concurrency"
+	"github.com/youtube/vitess/go/vt/mysqlctl/proto"
+)
+
+// tempFileMarker is in the name of the temporary files written by
+// the snapshots and restores, so the ones left behind by a crash can
+// be found by CleanOrphans.
+const tempFileMarker = ".vttmp"
+
+// MinOrphanMaxAge is the lowest maxAge accepted by CleanOrphans: the
+// files of a snapshot or restore that is still running are modified
+// more recently than that.
+const MinOrphanMaxAge = time.Hour
+
+// CleanOrphans removes the files left behind by failed snapshots and
+// restores, that haven't been modified for maxAge. These are the
+// content of the SnapshotDir when it has no manifest (the complete
+// snapshots are purged by the DiskMonitor instead), and the temporary
+// files of the snapshots and restores in the data directories. With
+// dryRun, the files are only reported.
+func (mysqld *Mysqld) CleanOrphans(maxAge time.Duration, dryRun bool) (*proto.OrphanCleanupReport, error) {
+	if maxAge < MinOrphanMaxAge {
+		return nil, fmt.Errorf("max age %v is below %v, it could remove the files of a running snapshot or restore", maxAge, MinOrphanMaxAge)
+	}
+	cutoff := time.Now().Add(-maxAge)
+	rec := concurrency.AllErrorRecorder{}
+
+	orphans, err := findOrphanedSnapshot(mysqld.SnapshotDir, cutoff)
+	if err != nil {
+		rec.RecordError(err)
+	}
+	dirs := []string{mysqld.config.DataDir, mysqld.config.InnodbDataHomeDir, mysqld.config.InnodbLogGroupHomeDir}
+	if len(orphans) == 0 {
+		dirs = append(dirs, mysqld.SnapshotDir)
+	}
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		if dir == "" || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		files, err := findOrphanedTempFiles(dir, cutoff)
+		if err != nil {
+			rec.RecordError(err)
+		}
+		orphans = append(orphans, files...)
+	}
+
+	report := &proto.OrphanCleanupReport{DryRun: dryRun}
+	for _, orphan := range orphans {
+		if !dryRun {
+			mlog.Infof("removing orphaned file: %v", orphan.Path)
+			if err := os.RemoveAll(orphan.Path); err != nil {
+				rec.RecordError(err)
+				continue
+			}
+		}
+		report.Files = append(report.Files, orphan)
+		report.ReclaimedBytes += orphan.Size
+	}
+	return report, rec.Error()
+}
+
+// findOrphanedSnapshot returns the entries of a snapshot directory
+// without a manifest, if nothing in it was modified since cutoff.
+// A more recent one may be in progress.
+func findOrphanedSnapshot(dir string, cutoff time.Time) ([]*proto.OrphanedFile, error) {
+	if _, err := os.Stat(path.Join(dir, SnapshotManifestFile)); err == nil || !os.IsNotExist(err) {
+		return nil, err
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if _, modTime := treeInfo(dir); !modTime.Before(cutoff) {
+		return nil, nil
+	}
+	result := make([]*proto.OrphanedFile, 0, len(entries))
+	for _, entry := range entries {
+		p := path.Join(dir, entry.Name())
+		size, modTime := treeInfo(p)
+		result = append(result, &proto.OrphanedFile{
+			Path:    p,
+			Size:    size,
+			ModTime: modTime.Unix(),
+		})
+	}
+	return result, nil
+}
+
+// findOrphanedTempFiles returns the temporary files under dir that
+// weren't modified since cutoff.
+func findOrphanedTempFiles(dir string, cutoff time.Time) ([]*proto.OrphanedFile, error) {
+	var result []*proto.OrphanedFile
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() && strings.Contains(info.Name(), tempFileMarker) && info.ModTime().Before(cutoff) {
+			result = append(result, &proto.OrphanedFile{
+				Path:    p,
+				Size:    info.Size(),
+				ModTime: info.ModTime().Unix(),
+			})
+		}
+		return nil
+	})
+	return result, err
+}
+
+// treeInfo returns the total size of the regular files under p, and
+// the last modification time of the tree. Errors are ignored, like
+// in dirSize.
+func treeInfo(p string) (size int64, modTime time.Time) {
+	filepath.Walk(p, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+		return nil
+	})
+	return size, modTime
+}
@@ -0,0 +1,136 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlctl
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCleanOrphans(t *testing.T) {
+	root, err := ioutil.TempDir("", "orphans")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	mysqld := &Mysqld{
+		config: &Mycnf{
+			DataDir:               path.Join(root, "data"),
+			InnodbDataHomeDir:     path.Join(root, "innodb", "data"),
+			InnodbLogGroupHomeDir: path.Join(root, "innodb", "log"),
+		},
+		SnapshotDir: path.Join(root, "snapshot"),
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	files := []struct {
+		name string
+		size int
+		old  bool
+	}{
+		{"data/vt_test/t1.ibd", 100, true},
+		{"data/vt_test/t2.ibd" + tempFileMarker + "123", 40, true},
+		{"data/vt_test/t3.ibd" + tempFileMarker + "456", 30, false},
+		{"innodb/data/ibdata1" + tempFileMarker + "789", 20, true},
+		{"snapshot/data/vt_test/t1.ibd.gz", 10, true},
+		{"snapshot/innodb_data/ibdata1.gz", 5, true},
+	}
+	for _, f := range files {
+		p := path.Join(root, f.name)
+		if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := ioutil.WriteFile(p, make([]byte, f.size), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		if f.old {
+			if err := os.Chtimes(p, old, old); err != nil {
+				t.Fatalf("Chtimes failed: %v", err)
+			}
+		}
+	}
+	for _, dir := range []string{"snapshot", "snapshot/data", "snapshot/data/vt_test", "snapshot/innodb_data"} {
+		if err := os.Chtimes(path.Join(root, dir), old, old); err != nil {
+			t.Fatalf("Chtimes failed: %v", err)
+		}
+	}
+	if _, err := mysqld.CleanOrphans(time.Minute, true); err == nil {
+		t.Errorf("CleanOrphans(%v) worked, want an error", time.Minute)
+	}
+	orphanPaths := func(dryRun bool) ([]string, int64) {
+		report, err := mysqld.CleanOrphans(time.Hour, dryRun)
+		if err != nil {
+			t.Fatalf("CleanOrphans failed: %v", err)
+		}
+		var paths []string
+		for _, f := range report.Files {
+			rel, _ := filepath.Rel(root, f.Path)
+			paths = append(paths, rel)
+		}
+		sort.Strings(paths)
+		return paths, report.ReclaimedBytes
+	}
+
+	// the snapshot without manifest and the old temporary files are
+	// orphans
+	paths, reclaimed := orphanPaths(true)
+	want := []string{
+		"data/vt_test/t2.ibd" + tempFileMarker + "123",
+		"innodb/data/ibdata1" + tempFileMarker + "789",
+		"snapshot/data",
+		"snapshot/innodb_data",
+	}
+	if !reflect.DeepEqual(paths, want) || reclaimed != 75 {
+		t.Errorf("dry run found %v (%v bytes), want %v (75 bytes)", paths, reclaimed, want)
+	}
+	if _, err := os.Stat(path.Join(root, want[0])); err != nil {
+		t.Errorf("a dry run shouldn't remove anything: %v", err)
+	}
+
+	// they are removed
+	if paths, _ := orphanPaths(false); !reflect.DeepEqual(paths, want) {
+		t.Errorf("CleanOrphans removed %v, want %v", paths, want)
+	}
+	for _, p := range want {
+		if _, err := os.Stat(path.Join(root, p)); !os.IsNotExist(err) {
+			t.Errorf("%v should be gone: %v", p, err)
+		}
+	}
+	if paths, _ := orphanPaths(false); len(paths) != 0 {
+		t.Errorf("CleanOrphans removed %v the second time", paths)
+	}
+
+	// a complete snapshot is not an orphan, only its old temporary
+	// files are
+	snapshotFiles := map[string]bool{
+		"snapshot/" + SnapshotManifestFile:                 false,
+		"snapshot/data/vt_test/t1.ibd.gz":                  false,
+		"snapshot/data/vt_test/t2.ibd.gz" + tempFileMarker: true,
+	}
+	for name, isOld := range snapshotFiles {
+		p := path.Join(root, name)
+		if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := ioutil.WriteFile(p, make([]byte, 10), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		if isOld {
+			if err := os.Chtimes(p, old, old); err != nil {
+				t.Fatalf("Chtimes failed: %v", err)
+			}
+		}
+	}
+	want = []string{"snapshot/data/vt_test/t2.ibd.gz" + tempFileMarker}
+	if paths, _ := orphanPaths(false); !reflect.DeepEqual(paths, want) {
+		t.Errorf("CleanOrphans removed %v, want %v", paths, want)
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto
+
+// OrphanedFile is a file or directory left behind by a failed
+// snapshot or restore.
+type OrphanedFile struct {
+	Path string
+
+	// Size is the total size of the regular files, in bytes.
+	Size int64
+
+	// ModTime is the last modification time in the tree, in
+	// seconds since the epoch.
+	ModTime int64
+}
+
+// OrphanCleanupReport lists the orphaned files that were removed, or
+// that would be removed in a dry run.
+type OrphanCleanupReport struct {
+	DryRun         bool
+	Files          []*OrphanedFile
+	ReclaimedBytes int64
+}
@@ -186,6 +186,24 @@ func (sd *SchemaDefinition) ToSQLStrings() []string {
 	return append(sqlStrings, createViewSql...)
 }
 
+var autoIncrementRegexp = regexp.MustCompile(` AUTO_INCREMENT=\d+`)
+
+// StripAutoIncrement returns a copy of the SchemaDefinition with the
+// AUTO_INCREMENT table option removed from all the tables. That option
+// reflects the data of the source, and shouldn't be copied to a new shard.
+func (sd *SchemaDefinition) StripAutoIncrement() *SchemaDefinition {
+	copy := *sd
+	copy.TableDefinitions = make([]*TableDefinition, len(sd.TableDefinitions))
+	for i, td := range sd.TableDefinitions {
+		tdCopy := *td
+		if td.Type == TABLE_BASE_TABLE {
+			tdCopy.Schema = autoIncrementRegexp.ReplaceAllString(td.Schema, "")
+		}
+		copy.TableDefinitions[i] = &tdCopy
+	}
+	return &copy
+}
+
 // generates a report on what's different between two SchemaDefinition
 // for now, we skip the VIEW entirely.
 func DiffSchema(leftName string, left *SchemaDefinition, rightName string, right *SchemaDefinition, er concurrency.ErrorRecorder) {
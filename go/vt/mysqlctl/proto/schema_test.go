@@ -310,3 +310,27 @@ func TestFilterTables(t *testing.T) {
 		}
 	}
 }
+
+func TestStripAutoIncrement(t *testing.T) {
+	sd := &SchemaDefinition{
+		TableDefinitions: []*TableDefinition{
+			&TableDefinition{
+				Name:   "table1",
+				Schema: "CREATE TABLE `table1` (\n  `id` bigint(20) NOT NULL AUTO_INCREMENT,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8",
+				Type:   TABLE_BASE_TABLE,
+			},
+			view1,
+		},
+	}
+	got := sd.StripAutoIncrement()
+	want := "CREATE TABLE `table1` (\n  `id` bigint(20) NOT NULL AUTO_INCREMENT,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8"
+	if got.TableDefinitions[0].Schema != want {
+		t.Errorf("StripAutoIncrement: got %v, want %v", got.TableDefinitions[0].Schema, want)
+	}
+	if got.TableDefinitions[1].Schema != view1.Schema {
+		t.Errorf("StripAutoIncrement changed a view: %v", got.TableDefinitions[1].Schema)
+	}
+	if sd.TableDefinitions[0].Schema == want {
+		t.Errorf("StripAutoIncrement modified the original")
+	}
+}
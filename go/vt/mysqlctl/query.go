@@ -25,7 +25,7 @@ func (mysqld *Mysqld) ExecuteSuperQueryList(queryList []string) error {
 	}
 	defer conn.Recycle()
 	for _, query := range queryList {
-		log.Infof("exec %v", redactMasterPassword(query))
+		mlog.Infof("exec %v", redactMasterPassword(query))
 		if _, err := conn.ExecuteFetch(query, 10000, false); err != nil {
 			return fmt.Errorf("ExecuteFetch(%v) failed: %v", redactMasterPassword(query), err.Error())
 		}
@@ -8,10 +8,43 @@ import (
 	"fmt"
 	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
 )
 
+// reparentJournalPollInterval is how often a restarted slave checks
+// if it received the reparent journal row of its new master.
+var reparentJournalPollInterval = 100 * time.Millisecond
+
+// CreateReparentJournal returns the commands to execute to create
+// the _vt.reparent_journal table. It is safe to run these commands
+// even if the table already exists.
+func CreateReparentJournal() []string {
+	return []string{
+		"CREATE DATABASE IF NOT EXISTS _vt",
+		`CREATE TABLE IF NOT EXISTS _vt.reparent_journal (
+  time_created_ns BIGINT UNSIGNED NOT NULL,
+  action_name VARCHAR(250) NOT NULL,
+  master_alias VARCHAR(32) NOT NULL,
+  replication_position VARCHAR(1000) DEFAULT NULL,
+  PRIMARY KEY (time_created_ns)) ENGINE=InnoDB`,
+	}
+}
+
+// PopulateReparentJournal returns the SQL command to use to populate
+// the _vt.reparent_journal table with the row of a reparent.
+func PopulateReparentJournal(timeCreatedNS int64, actionName string, masterAlias topo.TabletAlias, pos proto.ReplicationPosition) string {
+	return fmt.Sprintf("INSERT INTO _vt.reparent_journal (time_created_ns, action_name, master_alias, replication_position) VALUES (%v, '%v', '%v', '%v')",
+		timeCreatedNS, actionName, masterAlias, pos)
+}
+
+// queryReparentJournal returns the SQL query to use to look for the
+// row of a reparent in the _vt.reparent_journal table.
+func queryReparentJournal(timeCreatedNS int64) string {
+	return fmt.Sprintf("SELECT action_name, master_alias, replication_position FROM _vt.reparent_journal WHERE time_created_ns=%v", timeCreatedNS)
+}
+
 // DemoteMaster will gracefully demote a master mysql instance to read only.
 // If the master is still alive, then we need to demote it gracefully
 // make it read-only, flush the writes and get the position
@@ -33,8 +66,9 @@ func (mysqld *Mysqld) DemoteMaster() (rp proto.ReplicationPosition, err error) {
 //
 // replicationState: info slaves need to reparent themselves
 // waitPosition: slaves can wait for this position when restarting replication
-// timePromoted: this timestamp (unix nanoseconds) is inserted into _vt.replication_log to verify the replication config
-func (mysqld *Mysqld) PromoteSlave(setReadWrite bool, hookExtraEnv map[string]string) (replicationStatus *proto.ReplicationStatus, waitPosition proto.ReplicationPosition, timePromoted int64, err error) {
+// timePromoted: this timestamp (unix nanoseconds) is the key of the row inserted into _vt.reparent_journal,
+// slaves wait for this row to verify the replication config
+func (mysqld *Mysqld) PromoteSlave(masterAlias topo.TabletAlias, setReadWrite bool, hookExtraEnv map[string]string) (replicationStatus *proto.ReplicationStatus, waitPosition proto.ReplicationPosition, timePromoted int64, err error) {
 	if err = mysqld.StopSlave(hookExtraEnv); err != nil {
 		return
 	}
@@ -83,10 +117,10 @@ func (mysqld *Mysqld) PromoteSlave(setReadWrite bool, hookExtraEnv map[string]st
 	}
 	replicationStatus.Position = replicationPosition
 	timePromoted = time.Now().UnixNano()
-	// write a row to verify that replication is functioning
-	cmds = []string{
-		fmt.Sprintf("INSERT INTO _vt.replication_log (time_created_ns, note) VALUES (%v, 'reparent check')", timePromoted),
-	}
+	// write the reparent journal row, slaves wait for it to verify
+	// that replication is functioning
+	cmds = CreateReparentJournal()
+	cmds = append(cmds, PopulateReparentJournal(timePromoted, "PromoteSlave", masterAlias, replicationPosition))
 	if err = mysqld.ExecuteSuperQueryList(cmds); err != nil {
 		return
 	}
@@ -116,9 +150,11 @@ func (mysqld *Mysqld) PromoteSlave(setReadWrite bool, hookExtraEnv map[string]st
 	return
 }
 
-// RestartSlave tells a mysql slave that is has a new master
-func (mysqld *Mysqld) RestartSlave(replicationStatus *proto.ReplicationStatus, waitPosition proto.ReplicationPosition, timeCheck int64) error {
-	log.Infof("Restart Slave")
+// RestartSlave tells a mysql slave that is has a new master, and waits
+// until it received the reparent journal row the new master inserted
+// at timePromoted.
+func (mysqld *Mysqld) RestartSlave(ctx context.Context, replicationStatus *proto.ReplicationStatus, timePromoted int64) error {
+	mlog.Infof("Restart Slave")
 	cmds, err := mysqld.StartReplicationCommands(replicationStatus)
 	if err != nil {
 		return err
@@ -131,24 +167,29 @@ func (mysqld *Mysqld) RestartSlave(replicationStatus *proto.ReplicationStatus, w
 		return err
 	}
 
-	if err := mysqld.WaitMasterPos(waitPosition, 0); err != nil {
-		return err
-	}
-
-	return mysqld.CheckReplication(timeCheck)
+	return mysqld.WaitForReparentJournal(ctx, timePromoted)
 }
 
-// CheckReplication checks for the magic row inserted under controlled reparenting.
-func (mysqld *Mysqld) CheckReplication(timeCheck int64) error {
-	log.Infof("Check replication restarted")
-	checkQuery := fmt.Sprintf("SELECT * FROM _vt.replication_log WHERE time_created_ns = %v",
-		timeCheck)
-	qr, err := mysqld.fetchSuperQuery(checkQuery)
-	if err != nil {
-		return err
-	}
-	if len(qr.Rows) != 1 {
-		return fmt.Errorf("replication failed - unexpected row count %v", len(qr.Rows))
+// WaitForReparentJournal waits until the row the new master inserted
+// into _vt.reparent_journal at timeCreatedNS was replicated, or ctx
+// is done. Once the row is there, the slave is known to replicate
+// from the new master.
+func (mysqld *Mysqld) WaitForReparentJournal(ctx context.Context, timeCreatedNS int64) error {
+	mlog.Infof("Waiting for reparent journal row %v", timeCreatedNS)
+	query := queryReparentJournal(timeCreatedNS)
+	for {
+		qr, err := mysqld.fetchSuperQuery(query)
+		if err == nil && len(qr.Rows) == 1 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("replication failed - reparent journal row %v not found: %v", timeCreatedNS, err)
+			}
+			return fmt.Errorf("replication failed - reparent journal row %v not found: %v", timeCreatedNS, ctx.Err())
+		case <-time.After(reparentJournalPollInterval):
+		}
 	}
-	return nil
 }
@@ -0,0 +1,27 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlctl
+
+import (
+	"testing"
+
+	"github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+func TestReparentJournal(t *testing.T) {
+	pos := proto.ReplicationPosition{GTIDSet: proto.GoogleGTID{ServerID: 41983, GroupID: 12345}}
+	alias := topo.TabletAlias{Cell: "cell1", Uid: 62344}
+
+	want := "INSERT INTO _vt.reparent_journal (time_created_ns, action_name, master_alias, replication_position) VALUES (1234567, 'PromoteSlave', 'cell1-0000062344', '41983-12345')"
+	if got := PopulateReparentJournal(1234567, "PromoteSlave", alias, pos); got != want {
+		t.Errorf("PopulateReparentJournal:\ngot:  %v\nwant: %v", got, want)
+	}
+
+	want = "SELECT action_name, master_alias, replication_position FROM _vt.reparent_journal WHERE time_created_ns=1234567"
+	if got := queryReparentJournal(1234567); got != want {
+		t.Errorf("queryReparentJournal:\ngot:  %v\nwant: %v", got, want)
+	}
+}
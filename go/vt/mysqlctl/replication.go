@@ -19,7 +19,6 @@ import (
 	"text/template"
 	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/mysql"
 	"github.com/youtube/vitess/go/netutil"
 	"github.com/youtube/vitess/go/sqldb"
@@ -245,6 +244,24 @@ func (mysqld *Mysqld) WaitMasterPos(targetPos proto.ReplicationPosition, waitTim
 	return flavor.WaitMasterPos(mysqld, targetPos, waitTimeout)
 }
 
+// StartSlaveUntilAfter starts the slave replication, and waits until
+// it stops, right after targetPos. Unlike StopSlaveMinimum, the slave
+// is left exactly at targetPos.
+func (mysqld *Mysqld) StartSlaveUntilAfter(targetPos proto.ReplicationPosition, waitTimeout time.Duration) error {
+	flavor, err := mysqld.flavor()
+	if err != nil {
+		return fmt.Errorf("StartSlaveUntilAfter needs flavor: %v", err)
+	}
+	cmd, err := flavor.StartSlaveUntilAfterCommand(targetPos)
+	if err != nil {
+		return err
+	}
+	if err := mysqld.ExecuteSuperQuery(cmd); err != nil {
+		return err
+	}
+	return flavor.WaitMasterPos(mysqld, targetPos, waitTimeout)
+}
+
 // SlaveStatus returns the slave replication statuses
 func (mysqld *Mysqld) SlaveStatus() (*proto.ReplicationStatus, error) {
 	flavor, err := mysqld.flavor()
@@ -453,7 +470,7 @@ func (mysqld *Mysqld) WaitBlpPosition(bp *blproto.BlpPosition, waitTimeout time.
 			return nil
 		}
 
-		log.Infof("Sleeping 1 second waiting for binlog replication(%v) to catch up: %v != %v", bp.Uid, pos, bp.Position)
+		mlog.Infof("Sleeping 1 second waiting for binlog replication(%v) to catch up: %v != %v", bp.Uid, pos, bp.Position)
 		time.Sleep(1 * time.Second)
 	}
 
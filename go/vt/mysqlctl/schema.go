@@ -9,7 +9,6 @@ import (
 	"regexp"
 	"strings"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/vt/mysqlctl/proto"
 )
 
@@ -255,7 +254,7 @@ func (mysqld *Mysqld) ApplySchemaChange(dbName string, change *proto.SchemaChang
 		schemaDiffs := proto.DiffSchemaToArray("actual", beforeSchema, "expected", change.BeforeSchema)
 		if len(schemaDiffs) > 0 {
 			for _, msg := range schemaDiffs {
-				log.Warningf("BeforeSchema differs: %v", msg)
+				mlog.Warningf("BeforeSchema differs: %v", msg)
 			}
 
 			// let's see if the schema was already applied
@@ -270,7 +269,7 @@ func (mysqld *Mysqld) ApplySchemaChange(dbName string, change *proto.SchemaChang
 			}
 
 			if change.Force {
-				log.Warningf("BeforeSchema differs, applying anyway")
+				mlog.Warningf("BeforeSchema differs, applying anyway")
 			} else {
 				return nil, fmt.Errorf("BeforeSchema differs")
 			}
@@ -302,10 +301,10 @@ func (mysqld *Mysqld) ApplySchemaChange(dbName string, change *proto.SchemaChang
 		schemaDiffs := proto.DiffSchemaToArray("actual", afterSchema, "expected", change.AfterSchema)
 		if len(schemaDiffs) > 0 {
 			for _, msg := range schemaDiffs {
-				log.Warningf("AfterSchema differs: %v", msg)
+				mlog.Warningf("AfterSchema differs: %v", msg)
 			}
 			if change.Force {
-				log.Warningf("AfterSchema differs, not reporting error")
+				mlog.Warningf("AfterSchema differs, not reporting error")
 			} else {
 				return nil, fmt.Errorf("AfterSchema differs")
 			}
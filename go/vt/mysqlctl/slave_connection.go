@@ -9,7 +9,6 @@ import (
 	"encoding/binary"
 	"fmt"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/pools"
 	"github.com/youtube/vitess/go/sqldb"
 	"github.com/youtube/vitess/go/sync2"
@@ -52,7 +51,7 @@ func NewSlaveConnection(mysqld *Mysqld) (*SlaveConnection, error) {
 		mysqld:  mysqld,
 		slaveID: slaveIDPool.Get(),
 	}
-	log.Infof("new slave connection: slaveID=%d", sc.slaveID)
+	mlog.Infof("new slave connection: slaveID=%d", sc.slaveID)
 	return sc, nil
 }
 
@@ -70,16 +69,16 @@ func (sc *SlaveConnection) StartBinlogDump(startPos proto.ReplicationPosition) (
 		return nil, fmt.Errorf("StartBinlogDump needs flavor: %v", err)
 	}
 
-	log.Infof("sending binlog dump command: startPos=%v, slaveID=%v", startPos, sc.slaveID)
+	mlog.Infof("sending binlog dump command: startPos=%v, slaveID=%v", startPos, sc.slaveID)
 	if err = flavor.SendBinlogDumpCommand(sc.mysqld, sc, startPos); err != nil {
-		log.Errorf("couldn't send binlog dump command: %v", err)
+		mlog.Errorf("couldn't send binlog dump command: %v", err)
 		return nil, err
 	}
 
 	// Read the first packet to see if it's an error response to our dump command.
 	buf, err := sc.Conn.ReadPacket()
 	if err != nil {
-		log.Errorf("couldn't start binlog dump: %v", err)
+		mlog.Errorf("couldn't start binlog dump: %v", err)
 		return nil, err
 	}
 
@@ -92,7 +91,7 @@ func (sc *SlaveConnection) StartBinlogDump(startPos proto.ReplicationPosition) (
 		for svc.IsRunning() {
 			if buf[0] == 254 {
 				// The master is telling us to stop.
-				log.Infof("received EOF packet in binlog dump: %#v", buf)
+				mlog.Infof("received EOF packet in binlog dump: %#v", buf)
 				return nil
 			}
 
@@ -109,10 +108,10 @@ func (sc *SlaveConnection) StartBinlogDump(startPos proto.ReplicationPosition) (
 					// errno 2013 = Lost connection to MySQL server during query
 					// This is not necessarily an error. It could just be that we closed
 					// the connection from outside.
-					log.Infof("connection closed during binlog stream (possibly intentional): %v", err)
+					mlog.Infof("connection closed during binlog stream (possibly intentional): %v", err)
 					return err
 				}
-				log.Errorf("read error while streaming binlog events: %v", err)
+				mlog.Errorf("read error while streaming binlog events: %v", err)
 				return err
 			}
 		}
@@ -127,13 +126,13 @@ func (sc *SlaveConnection) StartBinlogDump(startPos proto.ReplicationPosition) (
 // The ID for the slave connection is recycled back into the pool.
 func (sc *SlaveConnection) Close() {
 	if sc.Conn != nil {
-		log.Infof("shutting down slave socket to unblock reads")
+		mlog.Infof("shutting down slave socket to unblock reads")
 		sc.Conn.Shutdown()
 
-		log.Infof("waiting for slave dump thread to end")
+		mlog.Infof("waiting for slave dump thread to end")
 		sc.svm.Stop()
 
-		log.Infof("closing slave MySQL client, recycling slaveID %v", sc.slaveID)
+		mlog.Infof("closing slave MySQL client, recycling slaveID %v", sc.slaveID)
 		sc.Conn.Close()
 		sc.Conn = nil
 		slaveIDPool.Put(sc.slaveID)
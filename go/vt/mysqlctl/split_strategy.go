@@ -22,6 +22,11 @@ type SplitStrategy struct {
 
 	// SkipSetSourceShards will not set the source shards at the end of restore
 	SkipSetSourceShards bool
+
+	// CopyCheckpoints will record each copied table chunk in the
+	// copy_state table of the destinations, and resume an
+	// interrupted copy from it
+	CopyCheckpoints bool
 }
 
 func NewSplitStrategy(logger logutil.Logger, argsStr string) (*SplitStrategy, error) {
@@ -38,6 +43,7 @@ func NewSplitStrategy(logger logutil.Logger, argsStr string) (*SplitStrategy, er
 	populateBlpCheckpoint := flagSet.Bool("populate_blp_checkpoint", false, "populates the blp checkpoint table")
 	dontStartBinlogPlayer := flagSet.Bool("dont_start_binlog_player", false, "do not start the binlog player after restore is complete")
 	skipSetSourceShards := flagSet.Bool("skip_set_source_shards", false, "do not set the SourceShar field on destination shards")
+	copyCheckpoints := flagSet.Bool("copy_checkpoints", false, "records each copied table chunk in the copy_state table, and resumes an interrupted copy from it")
 	if err := flagSet.Parse(args); err != nil {
 		return nil, fmt.Errorf("cannot parse strategy: %v", err)
 	}
@@ -49,6 +55,7 @@ func NewSplitStrategy(logger logutil.Logger, argsStr string) (*SplitStrategy, er
 		PopulateBlpCheckpoint: *populateBlpCheckpoint,
 		DontStartBinlogPlayer: *dontStartBinlogPlayer,
 		SkipSetSourceShards:   *skipSetSourceShards,
+		CopyCheckpoints:       *copyCheckpoints,
 	}, nil
 }
 
@@ -63,5 +70,8 @@ func (strategy *SplitStrategy) String() string {
 	if strategy.SkipSetSourceShards {
 		result = append(result, "-skip_set_source_shards")
 	}
+	if strategy.CopyCheckpoints {
+		result = append(result, "-copy_checkpoints")
+	}
 	return strings.Join(result, " ")
 }
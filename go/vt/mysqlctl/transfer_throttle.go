@@ -0,0 +1,162 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlctl
+
+// This file throttles the snapshot and restore transfers, so they
+// don't saturate the network and the disks of a shard that is
+// serving: the transfers are limited to a rate, and run with a lower
+// CPU and I/O priority.
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	transferRate   = flag.Float64("snapshot_transfer_rate", 0, "maximum rate of the snapshot and restore transfers of this process, in MB/s, 0 for no limit")
+	transferNice   = flag.Int("snapshot_transfer_nice", 0, "nice value of the snapshot and restore transfers, from 0 (unchanged) to 19")
+	transferIonice = flag.String("snapshot_transfer_ionice", "", "I/O scheduling class of the snapshot and restore transfers: idle, or best-effort[:level] with level from 0 to 7, empty for unchanged")
+)
+
+// transferChunkSize is the most data a throttled reader returns at
+// once, so rate changes apply quickly.
+const transferChunkSize = 64 * 1024
+
+// The I/O scheduling classes of ioprio_set.
+const (
+	ioprioClassBestEffort = 2
+	ioprioClassIdle       = 3
+)
+
+var (
+	// transferMu protects the flags above, that can be changed at
+	// runtime, and nextTransfer.
+	transferMu sync.Mutex
+	// nextTransfer is when the next throttled bytes can go.
+	nextTransfer time.Time
+)
+
+// TransferSettings are the throttling settings of the transfers.
+type TransferSettings struct {
+	// Rate is in MB/s, 0 means no limit.
+	Rate float64
+	// Nice is the nice value, 0 means unchanged.
+	Nice int
+	// Ionice is the I/O scheduling class, empty means unchanged.
+	Ionice string
+}
+
+// GetTransferSettings returns the current throttling settings.
+func GetTransferSettings() TransferSettings {
+	transferMu.Lock()
+	defer transferMu.Unlock()
+	return TransferSettings{
+		Rate:   *transferRate,
+		Nice:   *transferNice,
+		Ionice: *transferIonice,
+	}
+}
+
+// SetTransferSettings changes the throttling settings. The rate
+// applies to the running transfers, the priorities to the files
+// transferred next.
+func SetTransferSettings(settings TransferSettings) error {
+	if settings.Rate < 0 {
+		return fmt.Errorf("invalid transfer rate %v", settings.Rate)
+	}
+	if settings.Nice < 0 || settings.Nice > 19 {
+		return fmt.Errorf("invalid transfer nice value %v, must be between 0 and 19", settings.Nice)
+	}
+	if _, _, err := parseIonice(settings.Ionice); err != nil {
+		return err
+	}
+	transferMu.Lock()
+	defer transferMu.Unlock()
+	*transferRate = settings.Rate
+	*transferNice = settings.Nice
+	*transferIonice = settings.Ionice
+	return nil
+}
+
+// parseIonice parses the I/O scheduling class of the
+// -snapshot_transfer_ionice flag. class is 0 if it's empty.
+func parseIonice(ionice string) (class, level int, err error) {
+	if ionice == "" {
+		return 0, 0, nil
+	}
+	parts := strings.SplitN(ionice, ":", 2)
+	switch parts[0] {
+	case "idle":
+		if len(parts) > 1 {
+			return 0, 0, fmt.Errorf("invalid ionice %q, the idle class has no level", ionice)
+		}
+		return ioprioClassIdle, 0, nil
+	case "best-effort":
+		level = 7
+		if len(parts) > 1 {
+			level, err = strconv.Atoi(parts[1])
+			if err != nil || level < 0 || level > 7 {
+				return 0, 0, fmt.Errorf("invalid ionice level in %q, must be between 0 and 7", ionice)
+			}
+		}
+		return ioprioClassBestEffort, level, nil
+	}
+	return 0, 0, fmt.Errorf("invalid ionice %q, must be idle or best-effort[:level]", ionice)
+}
+
+// waitTransfer waits until n bytes can be transferred. All the
+// transfers of the process share the rate.
+func waitTransfer(n int) {
+	transferMu.Lock()
+	rate := *transferRate
+	if rate <= 0 {
+		transferMu.Unlock()
+		return
+	}
+	now := time.Now()
+	if nextTransfer.Before(now) {
+		nextTransfer = now
+	}
+	wait := nextTransfer.Sub(now)
+	nextTransfer = nextTransfer.Add(time.Duration(float64(n) / (rate * 1024 * 1024) * float64(time.Second)))
+	transferMu.Unlock()
+	time.Sleep(wait)
+}
+
+type throttledReader struct {
+	reader io.Reader
+}
+
+// NewThrottledReader returns a reader that doesn't read faster than
+// the -snapshot_transfer_rate.
+func NewThrottledReader(reader io.Reader) io.Reader {
+	return &throttledReader{reader: reader}
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > transferChunkSize {
+		p = p[:transferChunkSize]
+	}
+	n, err := tr.reader.Read(p)
+	waitTransfer(n)
+	return n, err
+}
+
+// WithTransferPriority runs f with the CPU and I/O priorities of the
+// -snapshot_transfer_nice and -snapshot_transfer_ionice flags. If
+// they can't be applied, f runs with the normal priorities.
+func WithTransferPriority(f func() error) error {
+	settings := GetTransferSettings()
+	class, level, _ := parseIonice(settings.Ionice)
+	if settings.Nice == 0 && class == 0 {
+		return f()
+	}
+	return withThreadPriority(settings.Nice, class, level, f)
+}
@@ -0,0 +1,73 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlctl
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// ioprioWhoProcess is the IOPRIO_WHO_PROCESS of ioprio_set, which
+// applies to a single thread when given a thread id.
+const ioprioWhoProcess = 1
+
+// withThreadPriority runs f in a new goroutine, locked to its own
+// thread, with the given priorities. The previous priorities of the
+// thread are restored before it is unlocked, as the runtime runs
+// other goroutines on it afterwards. If they can't be (raising the
+// priority back may need CAP_SYS_NICE), the goroutine never unlocks
+// the thread, so no other goroutine runs with the lower priorities.
+func withThreadPriority(nice, ioprioClass, ioprioLevel int, f func() error) error {
+	result := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		tid := syscall.Gettid()
+
+		var restores []func() error
+		if nice != 0 {
+			// The raw getpriority returns 20 - nice.
+			prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, tid)
+			if err != nil {
+				mlog.Warningf("cannot get the nice value of the transfer thread: %v", err)
+			} else if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, nice); err != nil {
+				mlog.Warningf("cannot set the nice value of the transfer to %v: %v", nice, err)
+			} else {
+				restores = append(restores, func() error {
+					return syscall.Setpriority(syscall.PRIO_PROCESS, tid, 20-prio)
+				})
+			}
+		}
+		if ioprioClass != 0 {
+			prev, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(tid), 0)
+			if errno != 0 {
+				mlog.Warningf("cannot get the I/O priority of the transfer thread: %v", errno)
+			} else if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(ioprioClass<<13|ioprioLevel)); errno != 0 {
+				mlog.Warningf("cannot set the I/O priority of the transfer: %v", errno)
+			} else {
+				restores = append(restores, func() error {
+					if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), prev); errno != 0 {
+						return errno
+					}
+					return nil
+				})
+			}
+		}
+
+		err := f()
+		restored := true
+		for _, restore := range restores {
+			if rerr := restore(); rerr != nil {
+				mlog.Warningf("cannot restore the priorities of the transfer thread, it won't be used again: %v", rerr)
+				restored = false
+			}
+		}
+		result <- err
+		if !restored {
+			select {}
+		}
+		runtime.UnlockOSThread()
+	}()
+	return <-result
+}
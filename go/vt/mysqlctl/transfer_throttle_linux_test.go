@@ -0,0 +1,45 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlctl
+
+import (
+	"syscall"
+	"testing"
+)
+
+// threadNice returns the nice value of a thread. The raw getpriority
+// returns 20 - nice.
+func threadNice(t *testing.T, tid int) int {
+	prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, tid)
+	if err != nil {
+		t.Fatalf("Getpriority failed: %v", err)
+	}
+	return 20 - prio
+}
+
+func TestWithThreadPriority(t *testing.T) {
+	base := threadNice(t, syscall.Getpid())
+	var tid, nice int
+	if err := withThreadPriority(base+5, 0, 0, func() error {
+		tid = syscall.Gettid()
+		nice = threadNice(t, tid)
+		return nil
+	}); err != nil {
+		t.Fatalf("withThreadPriority failed: %v", err)
+	}
+	if nice != base+5 {
+		t.Errorf("nice value of the transfer is %v, want %v", nice, base+5)
+	}
+
+	// The thread goes back to its nice value, unless we can't
+	// raise its priority.
+	switch after := threadNice(t, tid); after {
+	case base:
+	case base + 5:
+		t.Skipf("cannot restore the nice value of the thread without CAP_SYS_NICE")
+	default:
+		t.Errorf("nice value of the thread after the transfer is %v, want %v", after, base)
+	}
+}
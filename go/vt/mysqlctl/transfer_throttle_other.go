@@ -0,0 +1,14 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package mysqlctl
+
+// withThreadPriority runs f with the normal priorities: thread
+// priorities are only supported on Linux.
+func withThreadPriority(nice, ioprioClass, ioprioLevel int, f func() error) error {
+	mlog.Warningf("the priorities of the transfers can only be changed on Linux")
+	return f()
+}
@@ -0,0 +1,105 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlctl
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestParseIonice(t *testing.T) {
+	table := []struct {
+		ionice       string
+		class, level int
+		ok           bool
+	}{
+		{"", 0, 0, true},
+		{"idle", ioprioClassIdle, 0, true},
+		{"best-effort", ioprioClassBestEffort, 7, true},
+		{"best-effort:3", ioprioClassBestEffort, 3, true},
+		{"best-effort:8", 0, 0, false},
+		{"idle:1", 0, 0, false},
+		{"realtime", 0, 0, false},
+	}
+	for _, test := range table {
+		class, level, err := parseIonice(test.ionice)
+		if (err == nil) != test.ok || class != test.class || level != test.level {
+			t.Errorf("parseIonice(%q) = (%v, %v, %v), want (%v, %v, ok=%v)", test.ionice, class, level, err, test.class, test.level, test.ok)
+		}
+	}
+}
+
+func TestSetTransferSettings(t *testing.T) {
+	defer SetTransferSettings(GetTransferSettings())
+
+	for _, settings := range []TransferSettings{
+		{Rate: -1},
+		{Nice: 20},
+		{Ionice: "fast"},
+	} {
+		if err := SetTransferSettings(settings); err == nil {
+			t.Errorf("SetTransferSettings(%+v) succeeded", settings)
+		}
+	}
+	want := TransferSettings{Rate: 10, Nice: 5, Ionice: "idle"}
+	if err := SetTransferSettings(want); err != nil {
+		t.Fatalf("SetTransferSettings failed: %v", err)
+	}
+	if got := GetTransferSettings(); got != want {
+		t.Errorf("GetTransferSettings: got %+v, want %+v", got, want)
+	}
+}
+
+func TestThrottledReader(t *testing.T) {
+	defer SetTransferSettings(GetTransferSettings())
+
+	data := make([]byte, 4*transferChunkSize)
+	if err := SetTransferSettings(TransferSettings{}); err != nil {
+		t.Fatalf("SetTransferSettings failed: %v", err)
+	}
+	start := time.Now()
+	if _, err := ioutil.ReadAll(NewThrottledReader(bytes.NewReader(data))); err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if elapsed := time.Now().Sub(start); elapsed > 50*time.Millisecond {
+		t.Errorf("unthrottled read took %v", elapsed)
+	}
+
+	// At 1 MB/s, each 64k chunk after the first one waits 62.5ms.
+	if err := SetTransferSettings(TransferSettings{Rate: 1}); err != nil {
+		t.Fatalf("SetTransferSettings failed: %v", err)
+	}
+	start = time.Now()
+	got, err := ioutil.ReadAll(NewThrottledReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if len(got) != len(data) {
+		t.Errorf("ReadAll returned %v bytes, want %v", len(got), len(data))
+	}
+	if elapsed := time.Now().Sub(start); elapsed < 150*time.Millisecond {
+		t.Errorf("throttled read took %v, want at least 150ms", elapsed)
+	}
+}
+
+func TestWithTransferPriority(t *testing.T) {
+	defer SetTransferSettings(GetTransferSettings())
+
+	want := errors.New("transfer error")
+	for _, settings := range []TransferSettings{
+		{},
+		{Nice: 10, Ionice: "best-effort:7"},
+	} {
+		if err := SetTransferSettings(settings); err != nil {
+			t.Fatalf("SetTransferSettings failed: %v", err)
+		}
+		if got := WithTransferPriority(func() error { return want }); got != want {
+			t.Errorf("WithTransferPriority(%+v): got %v, want %v", settings, got, want)
+		}
+	}
+}
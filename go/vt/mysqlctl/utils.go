@@ -1,9 +1,5 @@
 package mysqlctl
 
-import (
-	log "github.com/golang/glog"
-)
-
 type MapFunc func(index int) error
 
 // ConcurrentMap applies fun in a concurrent manner on integers from 0
@@ -32,7 +28,7 @@ func ConcurrentMap(concurrency, n int, fun MapFunc) error {
 	for i := 0; i < n; i++ {
 		if e := <-errors; e != nil {
 			if err != nil {
-				log.Errorf("multiple errors, this one happened but it won't be returned: %v", err)
+				mlog.Errorf("multiple errors, this one happened but it won't be returned: %v", err)
 			}
 			err = e
 		}
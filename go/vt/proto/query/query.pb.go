@@ -0,0 +1,1297 @@
+// Code generated by protoc-gen-go.
+// source: query.proto
+// DO NOT EDIT!
+
+/*
+Package query is a generated protocol buffer package.
+
+It is generated from these files:
+	query.proto
+
+It has these top-level messages:
+	RPCError
+	BindVariable
+	BoundQuery
+	Field
+	Row
+	QueryResult
+	GetSessionIdRequest
+	GetSessionIdResponse
+	ExecuteRequest
+	ExecuteResponse
+	ExecuteBatchRequest
+	ExecuteBatchResponse
+	StreamExecuteRequest
+	StreamExecuteResponse
+	TransactionOptions
+	BeginRequest
+	BeginResponse
+	CommitRequest
+	CommitResponse
+	RollbackRequest
+	RollbackResponse
+	PrepareRequest
+	PrepareResponse
+	ExecutePreparedRequest
+	ExecutePreparedResponse
+	ClosePreparedRequest
+	ClosePreparedResponse
+	ExplainRequest
+	ExplainResponse
+	SplitQueryRequest
+	QuerySplit
+	SplitQueryResponse
+	MessageStreamRequest
+	MessageStreamResponse
+	MessageAckRequest
+	MessageAckResponse
+	ExportTableRequest
+	ExportHeader
+	ExportTableResponse
+*/
+package query
+
+import proto "github.com/golang/protobuf/proto"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+
+type BindVariable_Type int32
+
+const (
+	BindVariable_TYPE_NULL  BindVariable_Type = 0
+	BindVariable_TYPE_BYTES BindVariable_Type = 1
+	BindVariable_TYPE_INT   BindVariable_Type = 2
+	BindVariable_TYPE_UINT  BindVariable_Type = 3
+	BindVariable_TYPE_FLOAT BindVariable_Type = 4
+	BindVariable_TYPE_LIST  BindVariable_Type = 5
+)
+
+var BindVariable_Type_name = map[int32]string{
+	0: "TYPE_NULL",
+	1: "TYPE_BYTES",
+	2: "TYPE_INT",
+	3: "TYPE_UINT",
+	4: "TYPE_FLOAT",
+	5: "TYPE_LIST",
+}
+var BindVariable_Type_value = map[string]int32{
+	"TYPE_NULL":  0,
+	"TYPE_BYTES": 1,
+	"TYPE_INT":   2,
+	"TYPE_UINT":  3,
+	"TYPE_FLOAT": 4,
+	"TYPE_LIST":  5,
+}
+
+func (x BindVariable_Type) String() string {
+	return proto.EnumName(BindVariable_Type_name, int32(x))
+}
+
+// RPCError is the error of a call. It is returned in the responses,
+// so the client gets the canonical code of the error along with its
+// message. An empty code and message means the call succeeded.
+type RPCError struct {
+	// code is a vterrors.Code.
+	Code    int64  `protobuf:"varint,1,opt,name=code" json:"code,omitempty"`
+	Message string `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+}
+
+func (m *RPCError) Reset()         { *m = RPCError{} }
+func (m *RPCError) String() string { return proto.CompactTextString(m) }
+func (*RPCError) ProtoMessage()    {}
+
+// BindVariable is the value of a bind variable. A list is used for
+// the 'in' clauses, its values can't be lists.
+type BindVariable struct {
+	Type       BindVariable_Type `protobuf:"varint,1,opt,name=type,enum=query.BindVariable_Type" json:"type,omitempty"`
+	ValueBytes []byte            `protobuf:"bytes,2,opt,name=value_bytes" json:"value_bytes,omitempty"`
+	ValueInt   int64             `protobuf:"varint,3,opt,name=value_int" json:"value_int,omitempty"`
+	ValueUint  uint64            `protobuf:"varint,4,opt,name=value_uint" json:"value_uint,omitempty"`
+	ValueFloat float64           `protobuf:"fixed64,5,opt,name=value_float" json:"value_float,omitempty"`
+	ValueList  []*BindVariable   `protobuf:"bytes,6,rep,name=value_list" json:"value_list,omitempty"`
+}
+
+func (m *BindVariable) Reset()         { *m = BindVariable{} }
+func (m *BindVariable) String() string { return proto.CompactTextString(m) }
+func (*BindVariable) ProtoMessage()    {}
+
+func (m *BindVariable) GetValueList() []*BindVariable {
+	if m != nil {
+		return m.ValueList
+	}
+	return nil
+}
+
+// BoundQuery is a query with its bind variables.
+type BoundQuery struct {
+	Sql           string                   `protobuf:"bytes,1,opt,name=sql" json:"sql,omitempty"`
+	BindVariables map[string]*BindVariable `protobuf:"bytes,2,rep,name=bind_variables" json:"bind_variables,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *BoundQuery) Reset()         { *m = BoundQuery{} }
+func (m *BoundQuery) String() string { return proto.CompactTextString(m) }
+func (*BoundQuery) ProtoMessage()    {}
+
+func (m *BoundQuery) GetBindVariables() map[string]*BindVariable {
+	if m != nil {
+		return m.BindVariables
+	}
+	return nil
+}
+
+// Field describes a column of a result.
+type Field struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// type is a mysql type, as in mproto.VT_*.
+	Type int64 `protobuf:"varint,2,opt,name=type" json:"type,omitempty"`
+	// flags are the mysql column flags, as in mproto.VT_*_FLAG.
+	Flags int64 `protobuf:"varint,3,opt,name=flags" json:"flags,omitempty"`
+}
+
+func (m *Field) Reset()         { *m = Field{} }
+func (m *Field) String() string { return proto.CompactTextString(m) }
+func (*Field) ProtoMessage()    {}
+
+// Row is a row of a result. The values are concatenated, lengths
+// has the length of each of them, or -1 for NULL.
+type Row struct {
+	Lengths []int64 `protobuf:"zigzag64,1,rep,name=lengths" json:"lengths,omitempty"`
+	Values  []byte  `protobuf:"bytes,2,opt,name=values" json:"values,omitempty"`
+}
+
+func (m *Row) Reset()         { *m = Row{} }
+func (m *Row) String() string { return proto.CompactTextString(m) }
+func (*Row) ProtoMessage()    {}
+
+// QueryResult is the result of a query.
+type QueryResult struct {
+	Fields       []*Field `protobuf:"bytes,1,rep,name=fields" json:"fields,omitempty"`
+	RowsAffected uint64   `protobuf:"varint,2,opt,name=rows_affected" json:"rows_affected,omitempty"`
+	InsertId     uint64   `protobuf:"varint,3,opt,name=insert_id" json:"insert_id,omitempty"`
+	Rows         []*Row   `protobuf:"bytes,4,rep,name=rows" json:"rows,omitempty"`
+}
+
+func (m *QueryResult) Reset()         { *m = QueryResult{} }
+func (m *QueryResult) String() string { return proto.CompactTextString(m) }
+func (*QueryResult) ProtoMessage()    {}
+
+func (m *QueryResult) GetFields() []*Field {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+func (m *QueryResult) GetRows() []*Row {
+	if m != nil {
+		return m.Rows
+	}
+	return nil
+}
+
+type GetSessionIdRequest struct {
+	Keyspace        string `protobuf:"bytes,1,opt,name=keyspace" json:"keyspace,omitempty"`
+	Shard           string `protobuf:"bytes,2,opt,name=shard" json:"shard,omitempty"`
+	ProtocolVersion int64  `protobuf:"varint,3,opt,name=protocol_version" json:"protocol_version,omitempty"`
+}
+
+func (m *GetSessionIdRequest) Reset()         { *m = GetSessionIdRequest{} }
+func (m *GetSessionIdRequest) String() string { return proto.CompactTextString(m) }
+func (*GetSessionIdRequest) ProtoMessage()    {}
+
+type GetSessionIdResponse struct {
+	SessionId       int64     `protobuf:"varint,1,opt,name=session_id" json:"session_id,omitempty"`
+	Error           *RPCError `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	ProtocolVersion int64     `protobuf:"varint,3,opt,name=protocol_version" json:"protocol_version,omitempty"`
+	Features        []string  `protobuf:"bytes,4,rep,name=features" json:"features,omitempty"`
+}
+
+func (m *GetSessionIdResponse) Reset()         { *m = GetSessionIdResponse{} }
+func (m *GetSessionIdResponse) String() string { return proto.CompactTextString(m) }
+func (*GetSessionIdResponse) ProtoMessage()    {}
+
+func (m *GetSessionIdResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ExecuteRequest struct {
+	Query          *BoundQuery `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	SessionId      int64       `protobuf:"varint,2,opt,name=session_id" json:"session_id,omitempty"`
+	TransactionId  int64       `protobuf:"varint,3,opt,name=transaction_id" json:"transaction_id,omitempty"`
+	IdempotencyKey string      `protobuf:"bytes,4,opt,name=idempotency_key" json:"idempotency_key,omitempty"`
+	FieldsOnly     bool        `protobuf:"varint,5,opt,name=fields_only" json:"fields_only,omitempty"`
+}
+
+func (m *ExecuteRequest) Reset()         { *m = ExecuteRequest{} }
+func (m *ExecuteRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecuteRequest) ProtoMessage()    {}
+
+func (m *ExecuteRequest) GetQuery() *BoundQuery {
+	if m != nil {
+		return m.Query
+	}
+	return nil
+}
+
+type ExecuteResponse struct {
+	Result *QueryResult `protobuf:"bytes,1,opt,name=result" json:"result,omitempty"`
+	Error  *RPCError    `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ExecuteResponse) Reset()         { *m = ExecuteResponse{} }
+func (m *ExecuteResponse) String() string { return proto.CompactTextString(m) }
+func (*ExecuteResponse) ProtoMessage()    {}
+
+func (m *ExecuteResponse) GetResult() *QueryResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (m *ExecuteResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ExecuteBatchRequest struct {
+	Queries       []*BoundQuery `protobuf:"bytes,1,rep,name=queries" json:"queries,omitempty"`
+	SessionId     int64         `protobuf:"varint,2,opt,name=session_id" json:"session_id,omitempty"`
+	TransactionId int64         `protobuf:"varint,3,opt,name=transaction_id" json:"transaction_id,omitempty"`
+}
+
+func (m *ExecuteBatchRequest) Reset()         { *m = ExecuteBatchRequest{} }
+func (m *ExecuteBatchRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecuteBatchRequest) ProtoMessage()    {}
+
+func (m *ExecuteBatchRequest) GetQueries() []*BoundQuery {
+	if m != nil {
+		return m.Queries
+	}
+	return nil
+}
+
+type ExecuteBatchResponse struct {
+	Results []*QueryResult `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
+	Error   *RPCError      `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ExecuteBatchResponse) Reset()         { *m = ExecuteBatchResponse{} }
+func (m *ExecuteBatchResponse) String() string { return proto.CompactTextString(m) }
+func (*ExecuteBatchResponse) ProtoMessage()    {}
+
+func (m *ExecuteBatchResponse) GetResults() []*QueryResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+func (m *ExecuteBatchResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type StreamExecuteRequest struct {
+	Query         *BoundQuery `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	SessionId     int64       `protobuf:"varint,2,opt,name=session_id" json:"session_id,omitempty"`
+	TransactionId int64       `protobuf:"varint,3,opt,name=transaction_id" json:"transaction_id,omitempty"`
+	FieldsOnly    bool        `protobuf:"varint,4,opt,name=fields_only" json:"fields_only,omitempty"`
+}
+
+func (m *StreamExecuteRequest) Reset()         { *m = StreamExecuteRequest{} }
+func (m *StreamExecuteRequest) String() string { return proto.CompactTextString(m) }
+func (*StreamExecuteRequest) ProtoMessage()    {}
+
+func (m *StreamExecuteRequest) GetQuery() *BoundQuery {
+	if m != nil {
+		return m.Query
+	}
+	return nil
+}
+
+// StreamExecuteResponse is streamed by StreamExecute. If the query
+// fails, the last response only has the error.
+type StreamExecuteResponse struct {
+	Result *QueryResult `protobuf:"bytes,1,opt,name=result" json:"result,omitempty"`
+	Error  *RPCError    `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *StreamExecuteResponse) Reset()         { *m = StreamExecuteResponse{} }
+func (m *StreamExecuteResponse) String() string { return proto.CompactTextString(m) }
+func (*StreamExecuteResponse) ProtoMessage()    {}
+
+func (m *StreamExecuteResponse) GetResult() *QueryResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (m *StreamExecuteResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+// TransactionOptions are the options of the transaction Begin starts.
+type TransactionOptions struct {
+	IsolationLevel string `protobuf:"bytes,1,opt,name=isolation_level" json:"isolation_level,omitempty"`
+	ReadOnly       bool   `protobuf:"varint,2,opt,name=read_only" json:"read_only,omitempty"`
+}
+
+func (m *TransactionOptions) Reset()         { *m = TransactionOptions{} }
+func (m *TransactionOptions) String() string { return proto.CompactTextString(m) }
+func (*TransactionOptions) ProtoMessage()    {}
+
+type BeginRequest struct {
+	SessionId int64               `protobuf:"varint,1,opt,name=session_id" json:"session_id,omitempty"`
+	Options   *TransactionOptions `protobuf:"bytes,2,opt,name=options" json:"options,omitempty"`
+}
+
+func (m *BeginRequest) Reset()         { *m = BeginRequest{} }
+func (m *BeginRequest) String() string { return proto.CompactTextString(m) }
+func (*BeginRequest) ProtoMessage()    {}
+
+func (m *BeginRequest) GetOptions() *TransactionOptions {
+	if m != nil {
+		return m.Options
+	}
+	return nil
+}
+
+type BeginResponse struct {
+	TransactionId int64     `protobuf:"varint,1,opt,name=transaction_id" json:"transaction_id,omitempty"`
+	Error         *RPCError `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *BeginResponse) Reset()         { *m = BeginResponse{} }
+func (m *BeginResponse) String() string { return proto.CompactTextString(m) }
+func (*BeginResponse) ProtoMessage()    {}
+
+func (m *BeginResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type CommitRequest struct {
+	SessionId     int64 `protobuf:"varint,1,opt,name=session_id" json:"session_id,omitempty"`
+	TransactionId int64 `protobuf:"varint,2,opt,name=transaction_id" json:"transaction_id,omitempty"`
+}
+
+func (m *CommitRequest) Reset()         { *m = CommitRequest{} }
+func (m *CommitRequest) String() string { return proto.CompactTextString(m) }
+func (*CommitRequest) ProtoMessage()    {}
+
+type CommitResponse struct {
+	Error *RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *CommitResponse) Reset()         { *m = CommitResponse{} }
+func (m *CommitResponse) String() string { return proto.CompactTextString(m) }
+func (*CommitResponse) ProtoMessage()    {}
+
+func (m *CommitResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type RollbackRequest struct {
+	SessionId     int64 `protobuf:"varint,1,opt,name=session_id" json:"session_id,omitempty"`
+	TransactionId int64 `protobuf:"varint,2,opt,name=transaction_id" json:"transaction_id,omitempty"`
+}
+
+func (m *RollbackRequest) Reset()         { *m = RollbackRequest{} }
+func (m *RollbackRequest) String() string { return proto.CompactTextString(m) }
+func (*RollbackRequest) ProtoMessage()    {}
+
+type RollbackResponse struct {
+	Error *RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *RollbackResponse) Reset()         { *m = RollbackResponse{} }
+func (m *RollbackResponse) String() string { return proto.CompactTextString(m) }
+func (*RollbackResponse) ProtoMessage()    {}
+
+func (m *RollbackResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type PrepareRequest struct {
+	Sql       string `protobuf:"bytes,1,opt,name=sql" json:"sql,omitempty"`
+	SessionId int64  `protobuf:"varint,2,opt,name=session_id" json:"session_id,omitempty"`
+}
+
+func (m *PrepareRequest) Reset()         { *m = PrepareRequest{} }
+func (m *PrepareRequest) String() string { return proto.CompactTextString(m) }
+func (*PrepareRequest) ProtoMessage()    {}
+
+type PrepareResponse struct {
+	StatementId int64     `protobuf:"varint,1,opt,name=statement_id" json:"statement_id,omitempty"`
+	Error       *RPCError `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *PrepareResponse) Reset()         { *m = PrepareResponse{} }
+func (m *PrepareResponse) String() string { return proto.CompactTextString(m) }
+func (*PrepareResponse) ProtoMessage()    {}
+
+func (m *PrepareResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ExecutePreparedRequest struct {
+	StatementId   int64                    `protobuf:"varint,1,opt,name=statement_id" json:"statement_id,omitempty"`
+	BindVariables map[string]*BindVariable `protobuf:"bytes,2,rep,name=bind_variables" json:"bind_variables,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	SessionId     int64                    `protobuf:"varint,3,opt,name=session_id" json:"session_id,omitempty"`
+	TransactionId int64                    `protobuf:"varint,4,opt,name=transaction_id" json:"transaction_id,omitempty"`
+}
+
+func (m *ExecutePreparedRequest) Reset()         { *m = ExecutePreparedRequest{} }
+func (m *ExecutePreparedRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecutePreparedRequest) ProtoMessage()    {}
+
+func (m *ExecutePreparedRequest) GetBindVariables() map[string]*BindVariable {
+	if m != nil {
+		return m.BindVariables
+	}
+	return nil
+}
+
+type ExecutePreparedResponse struct {
+	Result *QueryResult `protobuf:"bytes,1,opt,name=result" json:"result,omitempty"`
+	Error  *RPCError    `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ExecutePreparedResponse) Reset()         { *m = ExecutePreparedResponse{} }
+func (m *ExecutePreparedResponse) String() string { return proto.CompactTextString(m) }
+func (*ExecutePreparedResponse) ProtoMessage()    {}
+
+func (m *ExecutePreparedResponse) GetResult() *QueryResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (m *ExecutePreparedResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ClosePreparedRequest struct {
+	StatementId int64 `protobuf:"varint,1,opt,name=statement_id" json:"statement_id,omitempty"`
+	SessionId   int64 `protobuf:"varint,2,opt,name=session_id" json:"session_id,omitempty"`
+}
+
+func (m *ClosePreparedRequest) Reset()         { *m = ClosePreparedRequest{} }
+func (m *ClosePreparedRequest) String() string { return proto.CompactTextString(m) }
+func (*ClosePreparedRequest) ProtoMessage()    {}
+
+type ClosePreparedResponse struct {
+	Error *RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ClosePreparedResponse) Reset()         { *m = ClosePreparedResponse{} }
+func (m *ClosePreparedResponse) String() string { return proto.CompactTextString(m) }
+func (*ClosePreparedResponse) ProtoMessage()    {}
+
+func (m *ClosePreparedResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ExplainRequest struct {
+	Query     *BoundQuery `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	SessionId int64       `protobuf:"varint,2,opt,name=session_id" json:"session_id,omitempty"`
+}
+
+func (m *ExplainRequest) Reset()         { *m = ExplainRequest{} }
+func (m *ExplainRequest) String() string { return proto.CompactTextString(m) }
+func (*ExplainRequest) ProtoMessage()    {}
+
+func (m *ExplainRequest) GetQuery() *BoundQuery {
+	if m != nil {
+		return m.Query
+	}
+	return nil
+}
+
+type ExplainResponse struct {
+	PlanType   string    `protobuf:"bytes,1,opt,name=plan_type" json:"plan_type,omitempty"`
+	Reason     string    `protobuf:"bytes,2,opt,name=reason" json:"reason,omitempty"`
+	TableName  string    `protobuf:"bytes,3,opt,name=table_name" json:"table_name,omitempty"`
+	IndexUsed  string    `protobuf:"bytes,4,opt,name=index_used" json:"index_used,omitempty"`
+	PkValues   []string  `protobuf:"bytes,5,rep,name=pk_values" json:"pk_values,omitempty"`
+	FieldQuery string    `protobuf:"bytes,6,opt,name=field_query" json:"field_query,omitempty"`
+	FullQuery  string    `protobuf:"bytes,7,opt,name=full_query" json:"full_query,omitempty"`
+	OuterQuery string    `protobuf:"bytes,8,opt,name=outer_query" json:"outer_query,omitempty"`
+	Subquery   string    `protobuf:"bytes,9,opt,name=subquery" json:"subquery,omitempty"`
+	Error      *RPCError `protobuf:"bytes,10,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ExplainResponse) Reset()         { *m = ExplainResponse{} }
+func (m *ExplainResponse) String() string { return proto.CompactTextString(m) }
+func (*ExplainResponse) ProtoMessage()    {}
+
+func (m *ExplainResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type SplitQueryRequest struct {
+	Query      *BoundQuery `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	SplitCount int64       `protobuf:"varint,2,opt,name=split_count" json:"split_count,omitempty"`
+	SessionId  int64       `protobuf:"varint,3,opt,name=session_id" json:"session_id,omitempty"`
+}
+
+func (m *SplitQueryRequest) Reset()         { *m = SplitQueryRequest{} }
+func (m *SplitQueryRequest) String() string { return proto.CompactTextString(m) }
+func (*SplitQueryRequest) ProtoMessage()    {}
+
+func (m *SplitQueryRequest) GetQuery() *BoundQuery {
+	if m != nil {
+		return m.Query
+	}
+	return nil
+}
+
+// QuerySplit is a part of the query of a SplitQueryRequest. row_count
+// is only approximate.
+type QuerySplit struct {
+	Query    *BoundQuery `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	RowCount int64       `protobuf:"varint,2,opt,name=row_count" json:"row_count,omitempty"`
+}
+
+func (m *QuerySplit) Reset()         { *m = QuerySplit{} }
+func (m *QuerySplit) String() string { return proto.CompactTextString(m) }
+func (*QuerySplit) ProtoMessage()    {}
+
+func (m *QuerySplit) GetQuery() *BoundQuery {
+	if m != nil {
+		return m.Query
+	}
+	return nil
+}
+
+type SplitQueryResponse struct {
+	Queries []*QuerySplit `protobuf:"bytes,1,rep,name=queries" json:"queries,omitempty"`
+	Error   *RPCError     `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SplitQueryResponse) Reset()         { *m = SplitQueryResponse{} }
+func (m *SplitQueryResponse) String() string { return proto.CompactTextString(m) }
+func (*SplitQueryResponse) ProtoMessage()    {}
+
+func (m *SplitQueryResponse) GetQueries() []*QuerySplit {
+	if m != nil {
+		return m.Queries
+	}
+	return nil
+}
+
+func (m *SplitQueryResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type MessageStreamRequest struct {
+	Name      string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	SessionId int64  `protobuf:"varint,2,opt,name=session_id" json:"session_id,omitempty"`
+}
+
+func (m *MessageStreamRequest) Reset()         { *m = MessageStreamRequest{} }
+func (m *MessageStreamRequest) String() string { return proto.CompactTextString(m) }
+func (*MessageStreamRequest) ProtoMessage()    {}
+
+// MessageStreamResponse is streamed by MessageStream. If the stream
+// fails, the last response only has the error.
+type MessageStreamResponse struct {
+	Result *QueryResult `protobuf:"bytes,1,opt,name=result" json:"result,omitempty"`
+	Error  *RPCError    `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *MessageStreamResponse) Reset()         { *m = MessageStreamResponse{} }
+func (m *MessageStreamResponse) String() string { return proto.CompactTextString(m) }
+func (*MessageStreamResponse) ProtoMessage()    {}
+
+func (m *MessageStreamResponse) GetResult() *QueryResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (m *MessageStreamResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type MessageAckRequest struct {
+	Name      string          `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Ids       []*BindVariable `protobuf:"bytes,2,rep,name=ids" json:"ids,omitempty"`
+	SessionId int64           `protobuf:"varint,3,opt,name=session_id" json:"session_id,omitempty"`
+}
+
+func (m *MessageAckRequest) Reset()         { *m = MessageAckRequest{} }
+func (m *MessageAckRequest) String() string { return proto.CompactTextString(m) }
+func (*MessageAckRequest) ProtoMessage()    {}
+
+func (m *MessageAckRequest) GetIds() []*BindVariable {
+	if m != nil {
+		return m.Ids
+	}
+	return nil
+}
+
+type MessageAckResponse struct {
+	Count int64     `protobuf:"varint,1,opt,name=count" json:"count,omitempty"`
+	Error *RPCError `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *MessageAckResponse) Reset()         { *m = MessageAckResponse{} }
+func (m *MessageAckResponse) String() string { return proto.CompactTextString(m) }
+func (*MessageAckResponse) ProtoMessage()    {}
+
+func (m *MessageAckResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ExportTableRequest struct {
+	Table            string `protobuf:"bytes,1,opt,name=table" json:"table,omitempty"`
+	KeyspaceIdColumn string `protobuf:"bytes,2,opt,name=keyspace_id_column" json:"keyspace_id_column,omitempty"`
+	KeyspaceIdType   string `protobuf:"bytes,3,opt,name=keyspace_id_type" json:"keyspace_id_type,omitempty"`
+	KeyRangeStart    []byte `protobuf:"bytes,4,opt,name=key_range_start" json:"key_range_start,omitempty"`
+	KeyRangeEnd      []byte `protobuf:"bytes,5,opt,name=key_range_end" json:"key_range_end,omitempty"`
+	SessionId        int64  `protobuf:"varint,6,opt,name=session_id" json:"session_id,omitempty"`
+}
+
+func (m *ExportTableRequest) Reset()         { *m = ExportTableRequest{} }
+func (m *ExportTableRequest) String() string { return proto.CompactTextString(m) }
+func (*ExportTableRequest) ProtoMessage()    {}
+
+// ExportHeader describes the rows of an export. position is an
+// encoded replication position.
+type ExportHeader struct {
+	Fields            []*Field `protobuf:"bytes,1,rep,name=fields" json:"fields,omitempty"`
+	PrimaryKeyColumns []int64  `protobuf:"varint,2,rep,name=primary_key_columns" json:"primary_key_columns,omitempty"`
+	Position          string   `protobuf:"bytes,3,opt,name=position" json:"position,omitempty"`
+}
+
+func (m *ExportHeader) Reset()         { *m = ExportHeader{} }
+func (m *ExportHeader) String() string { return proto.CompactTextString(m) }
+func (*ExportHeader) ProtoMessage()    {}
+
+func (m *ExportHeader) GetFields() []*Field {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+// ExportTableResponse is streamed by ExportTable. The first one only
+// has the header, the next ones a batch of rows. If the export
+// fails, the last response only has the error.
+type ExportTableResponse struct {
+	Header   *ExportHeader `protobuf:"bytes,1,opt,name=header" json:"header,omitempty"`
+	RowCount int64         `protobuf:"varint,2,opt,name=row_count" json:"row_count,omitempty"`
+	Columns  [][]byte      `protobuf:"bytes,3,rep,name=columns" json:"columns,omitempty"`
+	Error    *RPCError     `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ExportTableResponse) Reset()         { *m = ExportTableResponse{} }
+func (m *ExportTableResponse) String() string { return proto.CompactTextString(m) }
+func (*ExportTableResponse) ProtoMessage()    {}
+
+func (m *ExportTableResponse) GetHeader() *ExportHeader {
+	if m != nil {
+		return m.Header
+	}
+	return nil
+}
+
+func (m *ExportTableResponse) GetError() *RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("query.BindVariable_Type", BindVariable_Type_name, BindVariable_Type_value)
+}
+
+// Client API for Query service
+
+type QueryClient interface {
+	GetSessionId(ctx context.Context, in *GetSessionIdRequest, opts ...grpc.CallOption) (*GetSessionIdResponse, error)
+	Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*ExecuteResponse, error)
+	ExecuteBatch(ctx context.Context, in *ExecuteBatchRequest, opts ...grpc.CallOption) (*ExecuteBatchResponse, error)
+	StreamExecute(ctx context.Context, in *StreamExecuteRequest, opts ...grpc.CallOption) (Query_StreamExecuteClient, error)
+	Begin(ctx context.Context, in *BeginRequest, opts ...grpc.CallOption) (*BeginResponse, error)
+	Commit(ctx context.Context, in *CommitRequest, opts ...grpc.CallOption) (*CommitResponse, error)
+	Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*RollbackResponse, error)
+	Prepare(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*PrepareResponse, error)
+	ExecutePrepared(ctx context.Context, in *ExecutePreparedRequest, opts ...grpc.CallOption) (*ExecutePreparedResponse, error)
+	ClosePrepared(ctx context.Context, in *ClosePreparedRequest, opts ...grpc.CallOption) (*ClosePreparedResponse, error)
+	Explain(ctx context.Context, in *ExplainRequest, opts ...grpc.CallOption) (*ExplainResponse, error)
+	SplitQuery(ctx context.Context, in *SplitQueryRequest, opts ...grpc.CallOption) (*SplitQueryResponse, error)
+	MessageStream(ctx context.Context, in *MessageStreamRequest, opts ...grpc.CallOption) (Query_MessageStreamClient, error)
+	MessageAck(ctx context.Context, in *MessageAckRequest, opts ...grpc.CallOption) (*MessageAckResponse, error)
+	ExportTable(ctx context.Context, in *ExportTableRequest, opts ...grpc.CallOption) (Query_ExportTableClient, error)
+}
+
+type queryClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewQueryClient(cc *grpc.ClientConn) QueryClient {
+	return &queryClient{cc}
+}
+
+func (c *queryClient) GetSessionId(ctx context.Context, in *GetSessionIdRequest, opts ...grpc.CallOption) (*GetSessionIdResponse, error) {
+	out := new(GetSessionIdResponse)
+	err := grpc.Invoke(ctx, "/query.Query/GetSessionId", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*ExecuteResponse, error) {
+	out := new(ExecuteResponse)
+	err := grpc.Invoke(ctx, "/query.Query/Execute", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) ExecuteBatch(ctx context.Context, in *ExecuteBatchRequest, opts ...grpc.CallOption) (*ExecuteBatchResponse, error) {
+	out := new(ExecuteBatchResponse)
+	err := grpc.Invoke(ctx, "/query.Query/ExecuteBatch", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) StreamExecute(ctx context.Context, in *StreamExecuteRequest, opts ...grpc.CallOption) (Query_StreamExecuteClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Query_serviceDesc.Streams[0], c.cc, "/query.Query/StreamExecute", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &queryStreamExecuteClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Query_StreamExecuteClient interface {
+	Recv() (*StreamExecuteResponse, error)
+	grpc.ClientStream
+}
+
+type queryStreamExecuteClient struct {
+	grpc.ClientStream
+}
+
+func (x *queryStreamExecuteClient) Recv() (*StreamExecuteResponse, error) {
+	m := new(StreamExecuteResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *queryClient) Begin(ctx context.Context, in *BeginRequest, opts ...grpc.CallOption) (*BeginResponse, error) {
+	out := new(BeginResponse)
+	err := grpc.Invoke(ctx, "/query.Query/Begin", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) Commit(ctx context.Context, in *CommitRequest, opts ...grpc.CallOption) (*CommitResponse, error) {
+	out := new(CommitResponse)
+	err := grpc.Invoke(ctx, "/query.Query/Commit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*RollbackResponse, error) {
+	out := new(RollbackResponse)
+	err := grpc.Invoke(ctx, "/query.Query/Rollback", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) Prepare(ctx context.Context, in *PrepareRequest, opts ...grpc.CallOption) (*PrepareResponse, error) {
+	out := new(PrepareResponse)
+	err := grpc.Invoke(ctx, "/query.Query/Prepare", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) ExecutePrepared(ctx context.Context, in *ExecutePreparedRequest, opts ...grpc.CallOption) (*ExecutePreparedResponse, error) {
+	out := new(ExecutePreparedResponse)
+	err := grpc.Invoke(ctx, "/query.Query/ExecutePrepared", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) ClosePrepared(ctx context.Context, in *ClosePreparedRequest, opts ...grpc.CallOption) (*ClosePreparedResponse, error) {
+	out := new(ClosePreparedResponse)
+	err := grpc.Invoke(ctx, "/query.Query/ClosePrepared", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) Explain(ctx context.Context, in *ExplainRequest, opts ...grpc.CallOption) (*ExplainResponse, error) {
+	out := new(ExplainResponse)
+	err := grpc.Invoke(ctx, "/query.Query/Explain", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) SplitQuery(ctx context.Context, in *SplitQueryRequest, opts ...grpc.CallOption) (*SplitQueryResponse, error) {
+	out := new(SplitQueryResponse)
+	err := grpc.Invoke(ctx, "/query.Query/SplitQuery", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) MessageStream(ctx context.Context, in *MessageStreamRequest, opts ...grpc.CallOption) (Query_MessageStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Query_serviceDesc.Streams[1], c.cc, "/query.Query/MessageStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &queryMessageStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Query_MessageStreamClient interface {
+	Recv() (*MessageStreamResponse, error)
+	grpc.ClientStream
+}
+
+type queryMessageStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *queryMessageStreamClient) Recv() (*MessageStreamResponse, error) {
+	m := new(MessageStreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *queryClient) MessageAck(ctx context.Context, in *MessageAckRequest, opts ...grpc.CallOption) (*MessageAckResponse, error) {
+	out := new(MessageAckResponse)
+	err := grpc.Invoke(ctx, "/query.Query/MessageAck", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queryClient) ExportTable(ctx context.Context, in *ExportTableRequest, opts ...grpc.CallOption) (Query_ExportTableClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Query_serviceDesc.Streams[2], c.cc, "/query.Query/ExportTable", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &queryExportTableClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Query_ExportTableClient interface {
+	Recv() (*ExportTableResponse, error)
+	grpc.ClientStream
+}
+
+type queryExportTableClient struct {
+	grpc.ClientStream
+}
+
+func (x *queryExportTableClient) Recv() (*ExportTableResponse, error) {
+	m := new(ExportTableResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for Query service
+
+type QueryServer interface {
+	GetSessionId(context.Context, *GetSessionIdRequest) (*GetSessionIdResponse, error)
+	Execute(context.Context, *ExecuteRequest) (*ExecuteResponse, error)
+	ExecuteBatch(context.Context, *ExecuteBatchRequest) (*ExecuteBatchResponse, error)
+	StreamExecute(*StreamExecuteRequest, Query_StreamExecuteServer) error
+	Begin(context.Context, *BeginRequest) (*BeginResponse, error)
+	Commit(context.Context, *CommitRequest) (*CommitResponse, error)
+	Rollback(context.Context, *RollbackRequest) (*RollbackResponse, error)
+	Prepare(context.Context, *PrepareRequest) (*PrepareResponse, error)
+	ExecutePrepared(context.Context, *ExecutePreparedRequest) (*ExecutePreparedResponse, error)
+	ClosePrepared(context.Context, *ClosePreparedRequest) (*ClosePreparedResponse, error)
+	Explain(context.Context, *ExplainRequest) (*ExplainResponse, error)
+	SplitQuery(context.Context, *SplitQueryRequest) (*SplitQueryResponse, error)
+	MessageStream(*MessageStreamRequest, Query_MessageStreamServer) error
+	MessageAck(context.Context, *MessageAckRequest) (*MessageAckResponse, error)
+	ExportTable(*ExportTableRequest, Query_ExportTableServer) error
+}
+
+func RegisterQueryServer(s *grpc.Server, srv QueryServer) {
+	s.RegisterService(&_Query_serviceDesc, srv)
+}
+
+func _Query_GetSessionId_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(GetSessionIdRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QueryServer).GetSessionId(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Query_Execute_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExecuteRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QueryServer).Execute(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Query_ExecuteBatch_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExecuteBatchRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QueryServer).ExecuteBatch(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Query_StreamExecute_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamExecuteRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(QueryServer).StreamExecute(m, &queryStreamExecuteServer{stream})
+}
+
+type Query_StreamExecuteServer interface {
+	Send(*StreamExecuteResponse) error
+	grpc.ServerStream
+}
+
+type queryStreamExecuteServer struct {
+	grpc.ServerStream
+}
+
+func (x *queryStreamExecuteServer) Send(m *StreamExecuteResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Query_Begin_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(BeginRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QueryServer).Begin(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Query_Commit_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(CommitRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QueryServer).Commit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Query_Rollback_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(RollbackRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QueryServer).Rollback(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Query_Prepare_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(PrepareRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QueryServer).Prepare(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Query_ExecutePrepared_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExecutePreparedRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QueryServer).ExecutePrepared(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Query_ClosePrepared_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ClosePreparedRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QueryServer).ClosePrepared(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Query_Explain_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExplainRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QueryServer).Explain(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Query_SplitQuery_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(SplitQueryRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QueryServer).SplitQuery(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Query_MessageStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(MessageStreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(QueryServer).MessageStream(m, &queryMessageStreamServer{stream})
+}
+
+type Query_MessageStreamServer interface {
+	Send(*MessageStreamResponse) error
+	grpc.ServerStream
+}
+
+type queryMessageStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *queryMessageStreamServer) Send(m *MessageStreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Query_MessageAck_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(MessageAckRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(QueryServer).MessageAck(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Query_ExportTable_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportTableRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(QueryServer).ExportTable(m, &queryExportTableServer{stream})
+}
+
+type Query_ExportTableServer interface {
+	Send(*ExportTableResponse) error
+	grpc.ServerStream
+}
+
+type queryExportTableServer struct {
+	grpc.ServerStream
+}
+
+func (x *queryExportTableServer) Send(m *ExportTableResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Query_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "query.Query",
+	HandlerType: (*QueryServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetSessionId",
+			Handler:    _Query_GetSessionId_Handler,
+		},
+		{
+			MethodName: "Execute",
+			Handler:    _Query_Execute_Handler,
+		},
+		{
+			MethodName: "ExecuteBatch",
+			Handler:    _Query_ExecuteBatch_Handler,
+		},
+		{
+			MethodName: "Begin",
+			Handler:    _Query_Begin_Handler,
+		},
+		{
+			MethodName: "Commit",
+			Handler:    _Query_Commit_Handler,
+		},
+		{
+			MethodName: "Rollback",
+			Handler:    _Query_Rollback_Handler,
+		},
+		{
+			MethodName: "Prepare",
+			Handler:    _Query_Prepare_Handler,
+		},
+		{
+			MethodName: "ExecutePrepared",
+			Handler:    _Query_ExecutePrepared_Handler,
+		},
+		{
+			MethodName: "ClosePrepared",
+			Handler:    _Query_ClosePrepared_Handler,
+		},
+		{
+			MethodName: "Explain",
+			Handler:    _Query_Explain_Handler,
+		},
+		{
+			MethodName: "SplitQuery",
+			Handler:    _Query_SplitQuery_Handler,
+		},
+		{
+			MethodName: "MessageAck",
+			Handler:    _Query_MessageAck_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamExecute",
+			Handler:       _Query_StreamExecute_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "MessageStream",
+			Handler:       _Query_MessageStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportTable",
+			Handler:       _Query_ExportTable_Handler,
+			ServerStreams: true,
+		},
+	},
+}
@@ -9,11 +9,111 @@ It is generated from these files:
 	tabletmanager.proto
 
 It has these top-level messages:
-	SnapshotArgs
+	TabletAlias
+	KeyRange
+	Tablet
+	TableDefinition
+	SchemaDefinition
+	SchemaChange
+	SchemaChangeResult
+	UserPermission
+	DbPermission
+	HostPermission
+	Permissions
+	ReplicationStatus
+	BlpPosition
+	RestartSlaveData
+	OrphanedFile
+	SnapshotReply
+	PingRequest
+	PingResponse
+	SleepRequest
+	SleepResponse
+	ExecuteHookRequest
+	ExecuteHookResponse
+	GetSchemaRequest
+	GetSchemaResponse
+	GetPermissionsRequest
+	GetPermissionsResponse
+	SetReadOnlyRequest
+	SetReadOnlyResponse
+	SetReadWriteRequest
+	SetReadWriteResponse
+	ChangeTypeRequest
+	ChangeTypeResponse
+	ScrapRequest
+	ScrapResponse
+	RefreshStateRequest
+	RefreshStateResponse
+	RunHealthCheckRequest
+	RunHealthCheckResponse
+	HealthStreamRequest
+	HealthStreamResponse
+	ReloadSchemaRequest
+	ReloadSchemaResponse
+	PreflightSchemaRequest
+	PreflightSchemaResponse
+	ApplySchemaRequest
+	ApplySchemaResponse
+	ExecuteFetchRequest
+	ExecuteFetchResponse
+	SlaveStatusRequest
+	SlaveStatusResponse
+	WaitSlavePositionRequest
+	WaitSlavePositionResponse
+	MasterPositionRequest
+	MasterPositionResponse
+	ReparentPositionRequest
+	ReparentPositionResponse
+	StopSlaveRequest
+	StopSlaveResponse
+	StopSlaveMinimumRequest
+	StopSlaveMinimumResponse
+	StartSlaveRequest
+	StartSlaveResponse
+	StartSlaveUntilAfterRequest
+	StartSlaveUntilAfterResponse
+	TabletExternallyReparentedRequest
+	TabletExternallyReparentedResponse
+	GetSlavesRequest
+	GetSlavesResponse
+	WaitBlpPositionRequest
+	WaitBlpPositionResponse
+	StopBlpRequest
+	StopBlpResponse
+	StartBlpRequest
+	StartBlpResponse
+	RunBlpUntilRequest
+	RunBlpUntilResponse
+	DemoteMasterRequest
+	DemoteMasterResponse
+	PromoteSlaveRequest
+	PromoteSlaveResponse
+	SlaveWasPromotedRequest
+	SlaveWasPromotedResponse
+	RestartSlaveRequest
+	RestartSlaveResponse
+	SlaveWasRestartedRequest
+	SlaveWasRestartedResponse
+	BreakSlavesRequest
+	BreakSlavesResponse
+	SnapshotRequest
+	SnapshotResponse
+	SnapshotSourceEndRequest
+	SnapshotSourceEndResponse
+	ReserveForRestoreRequest
+	ReserveForRestoreResponse
+	RestoreRequest
+	RestoreResponse
+	DeleteSnapshotRequest
+	DeleteSnapshotResponse
+	CleanOrphansRequest
+	CleanOrphansResponse
 */
 package tabletmanager
 
 import proto "github.com/golang/protobuf/proto"
+import query "github.com/youtube/vitess/go/vt/proto/query"
 import vtctl "github.com/youtube/vitess/go/vt/proto/vtctl"
 
 import (
@@ -28,79 +128,2541 @@ var _ grpc.ClientConn
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
 
-type SnapshotArgs struct {
+type TabletAlias struct {
+	Cell string `protobuf:"bytes,1,opt,name=cell" json:"cell,omitempty"`
+	Uid  uint32 `protobuf:"varint,2,opt,name=uid" json:"uid,omitempty"`
+}
+
+func (m *TabletAlias) Reset()         { *m = TabletAlias{} }
+func (m *TabletAlias) String() string { return proto.CompactTextString(m) }
+func (*TabletAlias) ProtoMessage()    {}
+
+type KeyRange struct {
+	Start []byte `protobuf:"bytes,1,opt,name=start" json:"start,omitempty"`
+	End   []byte `protobuf:"bytes,2,opt,name=end" json:"end,omitempty"`
+}
+
+func (m *KeyRange) Reset()         { *m = KeyRange{} }
+func (m *KeyRange) String() string { return proto.CompactTextString(m) }
+func (*KeyRange) ProtoMessage()    {}
+
+type Tablet struct {
+	Alias          *TabletAlias      `protobuf:"bytes,1,opt,name=alias" json:"alias,omitempty"`
+	Hostname       string            `protobuf:"bytes,2,opt,name=hostname" json:"hostname,omitempty"`
+	IpAddr         string            `protobuf:"bytes,3,opt,name=ip_addr" json:"ip_addr,omitempty"`
+	Portmap        map[string]int32  `protobuf:"bytes,4,rep,name=portmap" json:"portmap,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Tags           map[string]string `protobuf:"bytes,5,rep,name=tags" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Health         map[string]string `protobuf:"bytes,6,rep,name=health" json:"health,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Keyspace       string            `protobuf:"bytes,7,opt,name=keyspace" json:"keyspace,omitempty"`
+	Shard          string            `protobuf:"bytes,8,opt,name=shard" json:"shard,omitempty"`
+	Type           string            `protobuf:"bytes,9,opt,name=type" json:"type,omitempty"`
+	DbNameOverride string            `protobuf:"bytes,10,opt,name=db_name_override" json:"db_name_override,omitempty"`
+	KeyRange       *KeyRange         `protobuf:"bytes,11,opt,name=key_range" json:"key_range,omitempty"`
+	LastHeartbeat  int64             `protobuf:"varint,12,opt,name=last_heartbeat" json:"last_heartbeat,omitempty"`
+	MasterTerm     int64             `protobuf:"varint,13,opt,name=master_term" json:"master_term,omitempty"`
+	SchemaVersion  string            `protobuf:"bytes,14,opt,name=schema_version" json:"schema_version,omitempty"`
+}
+
+func (m *Tablet) Reset()         { *m = Tablet{} }
+func (m *Tablet) String() string { return proto.CompactTextString(m) }
+func (*Tablet) ProtoMessage()    {}
+
+func (m *Tablet) GetAlias() *TabletAlias {
+	if m != nil {
+		return m.Alias
+	}
+	return nil
+}
+
+func (m *Tablet) GetPortmap() map[string]int32 {
+	if m != nil {
+		return m.Portmap
+	}
+	return nil
+}
+
+func (m *Tablet) GetTags() map[string]string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *Tablet) GetHealth() map[string]string {
+	if m != nil {
+		return m.Health
+	}
+	return nil
+}
+
+func (m *Tablet) GetKeyRange() *KeyRange {
+	if m != nil {
+		return m.KeyRange
+	}
+	return nil
+}
+
+type TableDefinition struct {
+	Name              string   `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Schema            string   `protobuf:"bytes,2,opt,name=schema" json:"schema,omitempty"`
+	Columns           []string `protobuf:"bytes,3,rep,name=columns" json:"columns,omitempty"`
+	PrimaryKeyColumns []string `protobuf:"bytes,4,rep,name=primary_key_columns" json:"primary_key_columns,omitempty"`
+	Type              string   `protobuf:"bytes,5,opt,name=type" json:"type,omitempty"`
+	DataLength        uint64   `protobuf:"varint,6,opt,name=data_length" json:"data_length,omitempty"`
+	RowCount          uint64   `protobuf:"varint,7,opt,name=row_count" json:"row_count,omitempty"`
+}
+
+func (m *TableDefinition) Reset()         { *m = TableDefinition{} }
+func (m *TableDefinition) String() string { return proto.CompactTextString(m) }
+func (*TableDefinition) ProtoMessage()    {}
+
+type SchemaDefinition struct {
+	DatabaseSchema   string             `protobuf:"bytes,1,opt,name=database_schema" json:"database_schema,omitempty"`
+	TableDefinitions []*TableDefinition `protobuf:"bytes,2,rep,name=table_definitions" json:"table_definitions,omitempty"`
+	Version          string             `protobuf:"bytes,3,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *SchemaDefinition) Reset()         { *m = SchemaDefinition{} }
+func (m *SchemaDefinition) String() string { return proto.CompactTextString(m) }
+func (*SchemaDefinition) ProtoMessage()    {}
+
+func (m *SchemaDefinition) GetTableDefinitions() []*TableDefinition {
+	if m != nil {
+		return m.TableDefinitions
+	}
+	return nil
+}
+
+type SchemaChange struct {
+	Sql              string            `protobuf:"bytes,1,opt,name=sql" json:"sql,omitempty"`
+	Force            bool              `protobuf:"varint,2,opt,name=force" json:"force,omitempty"`
+	AllowReplication bool              `protobuf:"varint,3,opt,name=allow_replication" json:"allow_replication,omitempty"`
+	BeforeSchema     *SchemaDefinition `protobuf:"bytes,4,opt,name=before_schema" json:"before_schema,omitempty"`
+	AfterSchema      *SchemaDefinition `protobuf:"bytes,5,opt,name=after_schema" json:"after_schema,omitempty"`
+}
+
+func (m *SchemaChange) Reset()         { *m = SchemaChange{} }
+func (m *SchemaChange) String() string { return proto.CompactTextString(m) }
+func (*SchemaChange) ProtoMessage()    {}
+
+func (m *SchemaChange) GetBeforeSchema() *SchemaDefinition {
+	if m != nil {
+		return m.BeforeSchema
+	}
+	return nil
+}
+
+func (m *SchemaChange) GetAfterSchema() *SchemaDefinition {
+	if m != nil {
+		return m.AfterSchema
+	}
+	return nil
+}
+
+type SchemaChangeResult struct {
+	BeforeSchema *SchemaDefinition `protobuf:"bytes,1,opt,name=before_schema" json:"before_schema,omitempty"`
+	AfterSchema  *SchemaDefinition `protobuf:"bytes,2,opt,name=after_schema" json:"after_schema,omitempty"`
+}
+
+func (m *SchemaChangeResult) Reset()         { *m = SchemaChangeResult{} }
+func (m *SchemaChangeResult) String() string { return proto.CompactTextString(m) }
+func (*SchemaChangeResult) ProtoMessage()    {}
+
+func (m *SchemaChangeResult) GetBeforeSchema() *SchemaDefinition {
+	if m != nil {
+		return m.BeforeSchema
+	}
+	return nil
+}
+
+func (m *SchemaChangeResult) GetAfterSchema() *SchemaDefinition {
+	if m != nil {
+		return m.AfterSchema
+	}
+	return nil
+}
+
+type UserPermission struct {
+	Host             string            `protobuf:"bytes,1,opt,name=host" json:"host,omitempty"`
+	User             string            `protobuf:"bytes,2,opt,name=user" json:"user,omitempty"`
+	PasswordChecksum uint64            `protobuf:"varint,3,opt,name=password_checksum" json:"password_checksum,omitempty"`
+	Privileges       map[string]string `protobuf:"bytes,4,rep,name=privileges" json:"privileges,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *UserPermission) Reset()         { *m = UserPermission{} }
+func (m *UserPermission) String() string { return proto.CompactTextString(m) }
+func (*UserPermission) ProtoMessage()    {}
+
+func (m *UserPermission) GetPrivileges() map[string]string {
+	if m != nil {
+		return m.Privileges
+	}
+	return nil
+}
+
+type DbPermission struct {
+	Host       string            `protobuf:"bytes,1,opt,name=host" json:"host,omitempty"`
+	Db         string            `protobuf:"bytes,2,opt,name=db" json:"db,omitempty"`
+	User       string            `protobuf:"bytes,3,opt,name=user" json:"user,omitempty"`
+	Privileges map[string]string `protobuf:"bytes,4,rep,name=privileges" json:"privileges,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *DbPermission) Reset()         { *m = DbPermission{} }
+func (m *DbPermission) String() string { return proto.CompactTextString(m) }
+func (*DbPermission) ProtoMessage()    {}
+
+func (m *DbPermission) GetPrivileges() map[string]string {
+	if m != nil {
+		return m.Privileges
+	}
+	return nil
+}
+
+type HostPermission struct {
+	Host       string            `protobuf:"bytes,1,opt,name=host" json:"host,omitempty"`
+	Db         string            `protobuf:"bytes,2,opt,name=db" json:"db,omitempty"`
+	Privileges map[string]string `protobuf:"bytes,3,rep,name=privileges" json:"privileges,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *HostPermission) Reset()         { *m = HostPermission{} }
+func (m *HostPermission) String() string { return proto.CompactTextString(m) }
+func (*HostPermission) ProtoMessage()    {}
+
+func (m *HostPermission) GetPrivileges() map[string]string {
+	if m != nil {
+		return m.Privileges
+	}
+	return nil
+}
+
+type Permissions struct {
+	UserPermissions []*UserPermission `protobuf:"bytes,1,rep,name=user_permissions" json:"user_permissions,omitempty"`
+	DbPermissions   []*DbPermission   `protobuf:"bytes,2,rep,name=db_permissions" json:"db_permissions,omitempty"`
+	HostPermissions []*HostPermission `protobuf:"bytes,3,rep,name=host_permissions" json:"host_permissions,omitempty"`
+}
+
+func (m *Permissions) Reset()         { *m = Permissions{} }
+func (m *Permissions) String() string { return proto.CompactTextString(m) }
+func (*Permissions) ProtoMessage()    {}
+
+func (m *Permissions) GetUserPermissions() []*UserPermission {
+	if m != nil {
+		return m.UserPermissions
+	}
+	return nil
+}
+
+func (m *Permissions) GetDbPermissions() []*DbPermission {
+	if m != nil {
+		return m.DbPermissions
+	}
+	return nil
+}
+
+func (m *Permissions) GetHostPermissions() []*HostPermission {
+	if m != nil {
+		return m.HostPermissions
+	}
+	return nil
+}
+
+type ReplicationStatus struct {
+	Position            string `protobuf:"bytes,1,opt,name=position" json:"position,omitempty"`
+	SlaveIoRunning      bool   `protobuf:"varint,2,opt,name=slave_io_running" json:"slave_io_running,omitempty"`
+	SlaveSqlRunning     bool   `protobuf:"varint,3,opt,name=slave_sql_running" json:"slave_sql_running,omitempty"`
+	SecondsBehindMaster uint64 `protobuf:"varint,4,opt,name=seconds_behind_master" json:"seconds_behind_master,omitempty"`
+	MasterHost          string `protobuf:"bytes,5,opt,name=master_host" json:"master_host,omitempty"`
+	MasterPort          int64  `protobuf:"varint,6,opt,name=master_port" json:"master_port,omitempty"`
+	MasterConnectRetry  int64  `protobuf:"varint,7,opt,name=master_connect_retry" json:"master_connect_retry,omitempty"`
+}
+
+func (m *ReplicationStatus) Reset()         { *m = ReplicationStatus{} }
+func (m *ReplicationStatus) String() string { return proto.CompactTextString(m) }
+func (*ReplicationStatus) ProtoMessage()    {}
+
+type BlpPosition struct {
+	Uid      uint32 `protobuf:"varint,1,opt,name=uid" json:"uid,omitempty"`
+	Position string `protobuf:"bytes,2,opt,name=position" json:"position,omitempty"`
+}
+
+func (m *BlpPosition) Reset()         { *m = BlpPosition{} }
+func (m *BlpPosition) String() string { return proto.CompactTextString(m) }
+func (*BlpPosition) ProtoMessage()    {}
+
+type RestartSlaveData struct {
+	ReplicationStatus *ReplicationStatus `protobuf:"bytes,1,opt,name=replication_status" json:"replication_status,omitempty"`
+	WaitPosition      string             `protobuf:"bytes,2,opt,name=wait_position" json:"wait_position,omitempty"`
+	TimePromoted      int64              `protobuf:"varint,3,opt,name=time_promoted" json:"time_promoted,omitempty"`
+	Parent            *TabletAlias       `protobuf:"bytes,4,opt,name=parent" json:"parent,omitempty"`
+	Force             bool               `protobuf:"varint,5,opt,name=force" json:"force,omitempty"`
+}
+
+func (m *RestartSlaveData) Reset()         { *m = RestartSlaveData{} }
+func (m *RestartSlaveData) String() string { return proto.CompactTextString(m) }
+func (*RestartSlaveData) ProtoMessage()    {}
+
+func (m *RestartSlaveData) GetReplicationStatus() *ReplicationStatus {
+	if m != nil {
+		return m.ReplicationStatus
+	}
+	return nil
+}
+
+func (m *RestartSlaveData) GetParent() *TabletAlias {
+	if m != nil {
+		return m.Parent
+	}
+	return nil
+}
+
+type OrphanedFile struct {
+	Path    string `protobuf:"bytes,1,opt,name=path" json:"path,omitempty"`
+	Size    int64  `protobuf:"varint,2,opt,name=size" json:"size,omitempty"`
+	ModTime int64  `protobuf:"varint,3,opt,name=mod_time" json:"mod_time,omitempty"`
+}
+
+func (m *OrphanedFile) Reset()         { *m = OrphanedFile{} }
+func (m *OrphanedFile) String() string { return proto.CompactTextString(m) }
+func (*OrphanedFile) ProtoMessage()    {}
+
+type SnapshotReply struct {
+	ParentAlias        *TabletAlias `protobuf:"bytes,1,opt,name=parent_alias" json:"parent_alias,omitempty"`
+	ManifestPath       string       `protobuf:"bytes,2,opt,name=manifest_path" json:"manifest_path,omitempty"`
+	SlaveStartRequired bool         `protobuf:"varint,3,opt,name=slave_start_required" json:"slave_start_required,omitempty"`
+	ReadOnly           bool         `protobuf:"varint,4,opt,name=read_only" json:"read_only,omitempty"`
+}
+
+func (m *SnapshotReply) Reset()         { *m = SnapshotReply{} }
+func (m *SnapshotReply) String() string { return proto.CompactTextString(m) }
+func (*SnapshotReply) ProtoMessage()    {}
+
+func (m *SnapshotReply) GetParentAlias() *TabletAlias {
+	if m != nil {
+		return m.ParentAlias
+	}
+	return nil
+}
+
+type PingRequest struct {
+	Payload string `protobuf:"bytes,1,opt,name=payload" json:"payload,omitempty"`
+}
+
+func (m *PingRequest) Reset()         { *m = PingRequest{} }
+func (m *PingRequest) String() string { return proto.CompactTextString(m) }
+func (*PingRequest) ProtoMessage()    {}
+
+type PingResponse struct {
+	Payload string          `protobuf:"bytes,1,opt,name=payload" json:"payload,omitempty"`
+	Error   *query.RPCError `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *PingResponse) Reset()         { *m = PingResponse{} }
+func (m *PingResponse) String() string { return proto.CompactTextString(m) }
+func (*PingResponse) ProtoMessage()    {}
+
+func (m *PingResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type SleepRequest struct {
+	// duration is in nanoseconds
+	Duration int64 `protobuf:"varint,1,opt,name=duration" json:"duration,omitempty"`
+}
+
+func (m *SleepRequest) Reset()         { *m = SleepRequest{} }
+func (m *SleepRequest) String() string { return proto.CompactTextString(m) }
+func (*SleepRequest) ProtoMessage()    {}
+
+type SleepResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SleepResponse) Reset()         { *m = SleepResponse{} }
+func (m *SleepResponse) String() string { return proto.CompactTextString(m) }
+func (*SleepResponse) ProtoMessage()    {}
+
+func (m *SleepResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ExecuteHookRequest struct {
+	Name       string            `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Parameters []string          `protobuf:"bytes,2,rep,name=parameters" json:"parameters,omitempty"`
+	ExtraEnv   map[string]string `protobuf:"bytes,3,rep,name=extra_env" json:"extra_env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *ExecuteHookRequest) Reset()         { *m = ExecuteHookRequest{} }
+func (m *ExecuteHookRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecuteHookRequest) ProtoMessage()    {}
+
+func (m *ExecuteHookRequest) GetExtraEnv() map[string]string {
+	if m != nil {
+		return m.ExtraEnv
+	}
+	return nil
+}
+
+type ExecuteHookResponse struct {
+	ExitStatus int64           `protobuf:"varint,1,opt,name=exit_status" json:"exit_status,omitempty"`
+	Stdout     string          `protobuf:"bytes,2,opt,name=stdout" json:"stdout,omitempty"`
+	Stderr     string          `protobuf:"bytes,3,opt,name=stderr" json:"stderr,omitempty"`
+	Error      *query.RPCError `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ExecuteHookResponse) Reset()         { *m = ExecuteHookResponse{} }
+func (m *ExecuteHookResponse) String() string { return proto.CompactTextString(m) }
+func (*ExecuteHookResponse) ProtoMessage()    {}
+
+func (m *ExecuteHookResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type GetSchemaRequest struct {
+	Tables        []string `protobuf:"bytes,1,rep,name=tables" json:"tables,omitempty"`
+	ExcludeTables []string `protobuf:"bytes,2,rep,name=exclude_tables" json:"exclude_tables,omitempty"`
+	IncludeViews  bool     `protobuf:"varint,3,opt,name=include_views" json:"include_views,omitempty"`
+}
+
+func (m *GetSchemaRequest) Reset()         { *m = GetSchemaRequest{} }
+func (m *GetSchemaRequest) String() string { return proto.CompactTextString(m) }
+func (*GetSchemaRequest) ProtoMessage()    {}
+
+type GetSchemaResponse struct {
+	SchemaDefinition *SchemaDefinition `protobuf:"bytes,1,opt,name=schema_definition" json:"schema_definition,omitempty"`
+	Error            *query.RPCError   `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *GetSchemaResponse) Reset()         { *m = GetSchemaResponse{} }
+func (m *GetSchemaResponse) String() string { return proto.CompactTextString(m) }
+func (*GetSchemaResponse) ProtoMessage()    {}
+
+func (m *GetSchemaResponse) GetSchemaDefinition() *SchemaDefinition {
+	if m != nil {
+		return m.SchemaDefinition
+	}
+	return nil
+}
+
+func (m *GetSchemaResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type GetPermissionsRequest struct {
+}
+
+func (m *GetPermissionsRequest) Reset()         { *m = GetPermissionsRequest{} }
+func (m *GetPermissionsRequest) String() string { return proto.CompactTextString(m) }
+func (*GetPermissionsRequest) ProtoMessage()    {}
+
+type GetPermissionsResponse struct {
+	Permissions *Permissions    `protobuf:"bytes,1,opt,name=permissions" json:"permissions,omitempty"`
+	Error       *query.RPCError `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *GetPermissionsResponse) Reset()         { *m = GetPermissionsResponse{} }
+func (m *GetPermissionsResponse) String() string { return proto.CompactTextString(m) }
+func (*GetPermissionsResponse) ProtoMessage()    {}
+
+func (m *GetPermissionsResponse) GetPermissions() *Permissions {
+	if m != nil {
+		return m.Permissions
+	}
+	return nil
+}
+
+func (m *GetPermissionsResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type SetReadOnlyRequest struct {
+}
+
+func (m *SetReadOnlyRequest) Reset()         { *m = SetReadOnlyRequest{} }
+func (m *SetReadOnlyRequest) String() string { return proto.CompactTextString(m) }
+func (*SetReadOnlyRequest) ProtoMessage()    {}
+
+type SetReadOnlyResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SetReadOnlyResponse) Reset()         { *m = SetReadOnlyResponse{} }
+func (m *SetReadOnlyResponse) String() string { return proto.CompactTextString(m) }
+func (*SetReadOnlyResponse) ProtoMessage()    {}
+
+func (m *SetReadOnlyResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type SetReadWriteRequest struct {
+}
+
+func (m *SetReadWriteRequest) Reset()         { *m = SetReadWriteRequest{} }
+func (m *SetReadWriteRequest) String() string { return proto.CompactTextString(m) }
+func (*SetReadWriteRequest) ProtoMessage()    {}
+
+type SetReadWriteResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SetReadWriteResponse) Reset()         { *m = SetReadWriteResponse{} }
+func (m *SetReadWriteResponse) String() string { return proto.CompactTextString(m) }
+func (*SetReadWriteResponse) ProtoMessage()    {}
+
+func (m *SetReadWriteResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ChangeTypeRequest struct {
+	TabletType string `protobuf:"bytes,1,opt,name=tablet_type" json:"tablet_type,omitempty"`
+}
+
+func (m *ChangeTypeRequest) Reset()         { *m = ChangeTypeRequest{} }
+func (m *ChangeTypeRequest) String() string { return proto.CompactTextString(m) }
+func (*ChangeTypeRequest) ProtoMessage()    {}
+
+type ChangeTypeResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ChangeTypeResponse) Reset()         { *m = ChangeTypeResponse{} }
+func (m *ChangeTypeResponse) String() string { return proto.CompactTextString(m) }
+func (*ChangeTypeResponse) ProtoMessage()    {}
+
+func (m *ChangeTypeResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ScrapRequest struct {
+}
+
+func (m *ScrapRequest) Reset()         { *m = ScrapRequest{} }
+func (m *ScrapRequest) String() string { return proto.CompactTextString(m) }
+func (*ScrapRequest) ProtoMessage()    {}
+
+type ScrapResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ScrapResponse) Reset()         { *m = ScrapResponse{} }
+func (m *ScrapResponse) String() string { return proto.CompactTextString(m) }
+func (*ScrapResponse) ProtoMessage()    {}
+
+func (m *ScrapResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type RefreshStateRequest struct {
+}
+
+func (m *RefreshStateRequest) Reset()         { *m = RefreshStateRequest{} }
+func (m *RefreshStateRequest) String() string { return proto.CompactTextString(m) }
+func (*RefreshStateRequest) ProtoMessage()    {}
+
+type RefreshStateResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *RefreshStateResponse) Reset()         { *m = RefreshStateResponse{} }
+func (m *RefreshStateResponse) String() string { return proto.CompactTextString(m) }
+func (*RefreshStateResponse) ProtoMessage()    {}
+
+func (m *RefreshStateResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type RunHealthCheckRequest struct {
+	TabletType string `protobuf:"bytes,1,opt,name=tablet_type" json:"tablet_type,omitempty"`
+}
+
+func (m *RunHealthCheckRequest) Reset()         { *m = RunHealthCheckRequest{} }
+func (m *RunHealthCheckRequest) String() string { return proto.CompactTextString(m) }
+func (*RunHealthCheckRequest) ProtoMessage()    {}
+
+type RunHealthCheckResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *RunHealthCheckResponse) Reset()         { *m = RunHealthCheckResponse{} }
+func (m *RunHealthCheckResponse) String() string { return proto.CompactTextString(m) }
+func (*RunHealthCheckResponse) ProtoMessage()    {}
+
+func (m *RunHealthCheckResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type HealthStreamRequest struct {
+}
+
+func (m *HealthStreamRequest) Reset()         { *m = HealthStreamRequest{} }
+func (m *HealthStreamRequest) String() string { return proto.CompactTextString(m) }
+func (*HealthStreamRequest) ProtoMessage()    {}
+
+// HealthStreamResponse is streamed by HealthStream. The last message
+// of the stream may only contain an error.
+type HealthStreamResponse struct {
+	Tablet              *Tablet `protobuf:"bytes,1,opt,name=tablet" json:"tablet,omitempty"`
+	BinlogPlayerMapSize int64   `protobuf:"varint,2,opt,name=binlog_player_map_size" json:"binlog_player_map_size,omitempty"`
+	HealthError         string  `protobuf:"bytes,3,opt,name=health_error" json:"health_error,omitempty"`
+	// replication_delay is in nanoseconds
+	ReplicationDelay int64           `protobuf:"varint,4,opt,name=replication_delay" json:"replication_delay,omitempty"`
+	Error            *query.RPCError `protobuf:"bytes,5,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *HealthStreamResponse) Reset()         { *m = HealthStreamResponse{} }
+func (m *HealthStreamResponse) String() string { return proto.CompactTextString(m) }
+func (*HealthStreamResponse) ProtoMessage()    {}
+
+func (m *HealthStreamResponse) GetTablet() *Tablet {
+	if m != nil {
+		return m.Tablet
+	}
+	return nil
+}
+
+func (m *HealthStreamResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ReloadSchemaRequest struct {
+}
+
+func (m *ReloadSchemaRequest) Reset()         { *m = ReloadSchemaRequest{} }
+func (m *ReloadSchemaRequest) String() string { return proto.CompactTextString(m) }
+func (*ReloadSchemaRequest) ProtoMessage()    {}
+
+type ReloadSchemaResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ReloadSchemaResponse) Reset()         { *m = ReloadSchemaResponse{} }
+func (m *ReloadSchemaResponse) String() string { return proto.CompactTextString(m) }
+func (*ReloadSchemaResponse) ProtoMessage()    {}
+
+func (m *ReloadSchemaResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type PreflightSchemaRequest struct {
+	Change string `protobuf:"bytes,1,opt,name=change" json:"change,omitempty"`
+}
+
+func (m *PreflightSchemaRequest) Reset()         { *m = PreflightSchemaRequest{} }
+func (m *PreflightSchemaRequest) String() string { return proto.CompactTextString(m) }
+func (*PreflightSchemaRequest) ProtoMessage()    {}
+
+type PreflightSchemaResponse struct {
+	Result *SchemaChangeResult `protobuf:"bytes,1,opt,name=result" json:"result,omitempty"`
+	Error  *query.RPCError     `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *PreflightSchemaResponse) Reset()         { *m = PreflightSchemaResponse{} }
+func (m *PreflightSchemaResponse) String() string { return proto.CompactTextString(m) }
+func (*PreflightSchemaResponse) ProtoMessage()    {}
+
+func (m *PreflightSchemaResponse) GetResult() *SchemaChangeResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (m *PreflightSchemaResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ApplySchemaRequest struct {
+	Change *SchemaChange `protobuf:"bytes,1,opt,name=change" json:"change,omitempty"`
+}
+
+func (m *ApplySchemaRequest) Reset()         { *m = ApplySchemaRequest{} }
+func (m *ApplySchemaRequest) String() string { return proto.CompactTextString(m) }
+func (*ApplySchemaRequest) ProtoMessage()    {}
+
+func (m *ApplySchemaRequest) GetChange() *SchemaChange {
+	if m != nil {
+		return m.Change
+	}
+	return nil
+}
+
+type ApplySchemaResponse struct {
+	Result *SchemaChangeResult `protobuf:"bytes,1,opt,name=result" json:"result,omitempty"`
+	Error  *query.RPCError     `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ApplySchemaResponse) Reset()         { *m = ApplySchemaResponse{} }
+func (m *ApplySchemaResponse) String() string { return proto.CompactTextString(m) }
+func (*ApplySchemaResponse) ProtoMessage()    {}
+
+func (m *ApplySchemaResponse) GetResult() *SchemaChangeResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (m *ApplySchemaResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ExecuteFetchRequest struct {
+	Query          string `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	MaxRows        int64  `protobuf:"varint,2,opt,name=max_rows" json:"max_rows,omitempty"`
+	WantFields     bool   `protobuf:"varint,3,opt,name=want_fields" json:"want_fields,omitempty"`
+	DisableBinlogs bool   `protobuf:"varint,4,opt,name=disable_binlogs" json:"disable_binlogs,omitempty"`
+	// db_config_name is either "app" or "dba"
+	DbConfigName string `protobuf:"bytes,5,opt,name=db_config_name" json:"db_config_name,omitempty"`
+}
+
+func (m *ExecuteFetchRequest) Reset()         { *m = ExecuteFetchRequest{} }
+func (m *ExecuteFetchRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecuteFetchRequest) ProtoMessage()    {}
+
+type ExecuteFetchResponse struct {
+	Result *query.QueryResult `protobuf:"bytes,1,opt,name=result" json:"result,omitempty"`
+	Error  *query.RPCError    `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ExecuteFetchResponse) Reset()         { *m = ExecuteFetchResponse{} }
+func (m *ExecuteFetchResponse) String() string { return proto.CompactTextString(m) }
+func (*ExecuteFetchResponse) ProtoMessage()    {}
+
+func (m *ExecuteFetchResponse) GetResult() *query.QueryResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (m *ExecuteFetchResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type SlaveStatusRequest struct {
+}
+
+func (m *SlaveStatusRequest) Reset()         { *m = SlaveStatusRequest{} }
+func (m *SlaveStatusRequest) String() string { return proto.CompactTextString(m) }
+func (*SlaveStatusRequest) ProtoMessage()    {}
+
+type SlaveStatusResponse struct {
+	Status *ReplicationStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Error  *query.RPCError    `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SlaveStatusResponse) Reset()         { *m = SlaveStatusResponse{} }
+func (m *SlaveStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*SlaveStatusResponse) ProtoMessage()    {}
+
+func (m *SlaveStatusResponse) GetStatus() *ReplicationStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *SlaveStatusResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type WaitSlavePositionRequest struct {
+	Position string `protobuf:"bytes,1,opt,name=position" json:"position,omitempty"`
+	// wait_timeout is in nanoseconds, zero to wait indefinitely
+	WaitTimeout int64 `protobuf:"varint,2,opt,name=wait_timeout" json:"wait_timeout,omitempty"`
+}
+
+func (m *WaitSlavePositionRequest) Reset()         { *m = WaitSlavePositionRequest{} }
+func (m *WaitSlavePositionRequest) String() string { return proto.CompactTextString(m) }
+func (*WaitSlavePositionRequest) ProtoMessage()    {}
+
+type WaitSlavePositionResponse struct {
+	Status *ReplicationStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Error  *query.RPCError    `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *WaitSlavePositionResponse) Reset()         { *m = WaitSlavePositionResponse{} }
+func (m *WaitSlavePositionResponse) String() string { return proto.CompactTextString(m) }
+func (*WaitSlavePositionResponse) ProtoMessage()    {}
+
+func (m *WaitSlavePositionResponse) GetStatus() *ReplicationStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *WaitSlavePositionResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type MasterPositionRequest struct {
+}
+
+func (m *MasterPositionRequest) Reset()         { *m = MasterPositionRequest{} }
+func (m *MasterPositionRequest) String() string { return proto.CompactTextString(m) }
+func (*MasterPositionRequest) ProtoMessage()    {}
+
+type MasterPositionResponse struct {
+	Position string          `protobuf:"bytes,1,opt,name=position" json:"position,omitempty"`
+	Error    *query.RPCError `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *MasterPositionResponse) Reset()         { *m = MasterPositionResponse{} }
+func (m *MasterPositionResponse) String() string { return proto.CompactTextString(m) }
+func (*MasterPositionResponse) ProtoMessage()    {}
+
+func (m *MasterPositionResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ReparentPositionRequest struct {
+	Position string `protobuf:"bytes,1,opt,name=position" json:"position,omitempty"`
+}
+
+func (m *ReparentPositionRequest) Reset()         { *m = ReparentPositionRequest{} }
+func (m *ReparentPositionRequest) String() string { return proto.CompactTextString(m) }
+func (*ReparentPositionRequest) ProtoMessage()    {}
+
+type ReparentPositionResponse struct {
+	RestartSlaveData *RestartSlaveData `protobuf:"bytes,1,opt,name=restart_slave_data" json:"restart_slave_data,omitempty"`
+	Error            *query.RPCError   `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ReparentPositionResponse) Reset()         { *m = ReparentPositionResponse{} }
+func (m *ReparentPositionResponse) String() string { return proto.CompactTextString(m) }
+func (*ReparentPositionResponse) ProtoMessage()    {}
+
+func (m *ReparentPositionResponse) GetRestartSlaveData() *RestartSlaveData {
+	if m != nil {
+		return m.RestartSlaveData
+	}
+	return nil
+}
+
+func (m *ReparentPositionResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type StopSlaveRequest struct {
+}
+
+func (m *StopSlaveRequest) Reset()         { *m = StopSlaveRequest{} }
+func (m *StopSlaveRequest) String() string { return proto.CompactTextString(m) }
+func (*StopSlaveRequest) ProtoMessage()    {}
+
+type StopSlaveResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *StopSlaveResponse) Reset()         { *m = StopSlaveResponse{} }
+func (m *StopSlaveResponse) String() string { return proto.CompactTextString(m) }
+func (*StopSlaveResponse) ProtoMessage()    {}
+
+func (m *StopSlaveResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type StopSlaveMinimumRequest struct {
+	Position string `protobuf:"bytes,1,opt,name=position" json:"position,omitempty"`
+	// wait_time is in nanoseconds
+	WaitTime int64 `protobuf:"varint,2,opt,name=wait_time" json:"wait_time,omitempty"`
+}
+
+func (m *StopSlaveMinimumRequest) Reset()         { *m = StopSlaveMinimumRequest{} }
+func (m *StopSlaveMinimumRequest) String() string { return proto.CompactTextString(m) }
+func (*StopSlaveMinimumRequest) ProtoMessage()    {}
+
+type StopSlaveMinimumResponse struct {
+	Status *ReplicationStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Error  *query.RPCError    `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *StopSlaveMinimumResponse) Reset()         { *m = StopSlaveMinimumResponse{} }
+func (m *StopSlaveMinimumResponse) String() string { return proto.CompactTextString(m) }
+func (*StopSlaveMinimumResponse) ProtoMessage()    {}
+
+func (m *StopSlaveMinimumResponse) GetStatus() *ReplicationStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *StopSlaveMinimumResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type StartSlaveRequest struct {
+}
+
+func (m *StartSlaveRequest) Reset()         { *m = StartSlaveRequest{} }
+func (m *StartSlaveRequest) String() string { return proto.CompactTextString(m) }
+func (*StartSlaveRequest) ProtoMessage()    {}
+
+type StartSlaveResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *StartSlaveResponse) Reset()         { *m = StartSlaveResponse{} }
+func (m *StartSlaveResponse) String() string { return proto.CompactTextString(m) }
+func (*StartSlaveResponse) ProtoMessage()    {}
+
+func (m *StartSlaveResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type StartSlaveUntilAfterRequest struct {
+	Position string `protobuf:"bytes,1,opt,name=position" json:"position,omitempty"`
+	// wait_time is in nanoseconds
+	WaitTime int64 `protobuf:"varint,2,opt,name=wait_time" json:"wait_time,omitempty"`
+}
+
+func (m *StartSlaveUntilAfterRequest) Reset()         { *m = StartSlaveUntilAfterRequest{} }
+func (m *StartSlaveUntilAfterRequest) String() string { return proto.CompactTextString(m) }
+func (*StartSlaveUntilAfterRequest) ProtoMessage()    {}
+
+type StartSlaveUntilAfterResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *StartSlaveUntilAfterResponse) Reset()         { *m = StartSlaveUntilAfterResponse{} }
+func (m *StartSlaveUntilAfterResponse) String() string { return proto.CompactTextString(m) }
+func (*StartSlaveUntilAfterResponse) ProtoMessage()    {}
+
+func (m *StartSlaveUntilAfterResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type TabletExternallyReparentedRequest struct {
+	ExternalId string `protobuf:"bytes,1,opt,name=external_id" json:"external_id,omitempty"`
+}
+
+func (m *TabletExternallyReparentedRequest) Reset()         { *m = TabletExternallyReparentedRequest{} }
+func (m *TabletExternallyReparentedRequest) String() string { return proto.CompactTextString(m) }
+func (*TabletExternallyReparentedRequest) ProtoMessage()    {}
+
+type TabletExternallyReparentedResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *TabletExternallyReparentedResponse) Reset()         { *m = TabletExternallyReparentedResponse{} }
+func (m *TabletExternallyReparentedResponse) String() string { return proto.CompactTextString(m) }
+func (*TabletExternallyReparentedResponse) ProtoMessage()    {}
+
+func (m *TabletExternallyReparentedResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type GetSlavesRequest struct {
+}
+
+func (m *GetSlavesRequest) Reset()         { *m = GetSlavesRequest{} }
+func (m *GetSlavesRequest) String() string { return proto.CompactTextString(m) }
+func (*GetSlavesRequest) ProtoMessage()    {}
+
+type GetSlavesResponse struct {
+	Addrs []string        `protobuf:"bytes,1,rep,name=addrs" json:"addrs,omitempty"`
+	Error *query.RPCError `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *GetSlavesResponse) Reset()         { *m = GetSlavesResponse{} }
+func (m *GetSlavesResponse) String() string { return proto.CompactTextString(m) }
+func (*GetSlavesResponse) ProtoMessage()    {}
+
+func (m *GetSlavesResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type WaitBlpPositionRequest struct {
+	BlpPosition *BlpPosition `protobuf:"bytes,1,opt,name=blp_position" json:"blp_position,omitempty"`
+	// wait_timeout is in nanoseconds
+	WaitTimeout int64 `protobuf:"varint,2,opt,name=wait_timeout" json:"wait_timeout,omitempty"`
+}
+
+func (m *WaitBlpPositionRequest) Reset()         { *m = WaitBlpPositionRequest{} }
+func (m *WaitBlpPositionRequest) String() string { return proto.CompactTextString(m) }
+func (*WaitBlpPositionRequest) ProtoMessage()    {}
+
+func (m *WaitBlpPositionRequest) GetBlpPosition() *BlpPosition {
+	if m != nil {
+		return m.BlpPosition
+	}
+	return nil
+}
+
+type WaitBlpPositionResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *WaitBlpPositionResponse) Reset()         { *m = WaitBlpPositionResponse{} }
+func (m *WaitBlpPositionResponse) String() string { return proto.CompactTextString(m) }
+func (*WaitBlpPositionResponse) ProtoMessage()    {}
+
+func (m *WaitBlpPositionResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type StopBlpRequest struct {
+}
+
+func (m *StopBlpRequest) Reset()         { *m = StopBlpRequest{} }
+func (m *StopBlpRequest) String() string { return proto.CompactTextString(m) }
+func (*StopBlpRequest) ProtoMessage()    {}
+
+type StopBlpResponse struct {
+	BlpPositions []*BlpPosition  `protobuf:"bytes,1,rep,name=blp_positions" json:"blp_positions,omitempty"`
+	Error        *query.RPCError `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *StopBlpResponse) Reset()         { *m = StopBlpResponse{} }
+func (m *StopBlpResponse) String() string { return proto.CompactTextString(m) }
+func (*StopBlpResponse) ProtoMessage()    {}
+
+func (m *StopBlpResponse) GetBlpPositions() []*BlpPosition {
+	if m != nil {
+		return m.BlpPositions
+	}
+	return nil
+}
+
+func (m *StopBlpResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type StartBlpRequest struct {
+}
+
+func (m *StartBlpRequest) Reset()         { *m = StartBlpRequest{} }
+func (m *StartBlpRequest) String() string { return proto.CompactTextString(m) }
+func (*StartBlpRequest) ProtoMessage()    {}
+
+type StartBlpResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *StartBlpResponse) Reset()         { *m = StartBlpResponse{} }
+func (m *StartBlpResponse) String() string { return proto.CompactTextString(m) }
+func (*StartBlpResponse) ProtoMessage()    {}
+
+func (m *StartBlpResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type RunBlpUntilRequest struct {
+	BlpPositions []*BlpPosition `protobuf:"bytes,1,rep,name=blp_positions" json:"blp_positions,omitempty"`
+	// wait_timeout is in nanoseconds
+	WaitTimeout int64 `protobuf:"varint,2,opt,name=wait_timeout" json:"wait_timeout,omitempty"`
+}
+
+func (m *RunBlpUntilRequest) Reset()         { *m = RunBlpUntilRequest{} }
+func (m *RunBlpUntilRequest) String() string { return proto.CompactTextString(m) }
+func (*RunBlpUntilRequest) ProtoMessage()    {}
+
+func (m *RunBlpUntilRequest) GetBlpPositions() []*BlpPosition {
+	if m != nil {
+		return m.BlpPositions
+	}
+	return nil
+}
+
+type RunBlpUntilResponse struct {
+	Position string          `protobuf:"bytes,1,opt,name=position" json:"position,omitempty"`
+	Error    *query.RPCError `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *RunBlpUntilResponse) Reset()         { *m = RunBlpUntilResponse{} }
+func (m *RunBlpUntilResponse) String() string { return proto.CompactTextString(m) }
+func (*RunBlpUntilResponse) ProtoMessage()    {}
+
+func (m *RunBlpUntilResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type DemoteMasterRequest struct {
+}
+
+func (m *DemoteMasterRequest) Reset()         { *m = DemoteMasterRequest{} }
+func (m *DemoteMasterRequest) String() string { return proto.CompactTextString(m) }
+func (*DemoteMasterRequest) ProtoMessage()    {}
+
+type DemoteMasterResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *DemoteMasterResponse) Reset()         { *m = DemoteMasterResponse{} }
+func (m *DemoteMasterResponse) String() string { return proto.CompactTextString(m) }
+func (*DemoteMasterResponse) ProtoMessage()    {}
+
+func (m *DemoteMasterResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type PromoteSlaveRequest struct {
+}
+
+func (m *PromoteSlaveRequest) Reset()         { *m = PromoteSlaveRequest{} }
+func (m *PromoteSlaveRequest) String() string { return proto.CompactTextString(m) }
+func (*PromoteSlaveRequest) ProtoMessage()    {}
+
+type PromoteSlaveResponse struct {
+	RestartSlaveData *RestartSlaveData `protobuf:"bytes,1,opt,name=restart_slave_data" json:"restart_slave_data,omitempty"`
+	Error            *query.RPCError   `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *PromoteSlaveResponse) Reset()         { *m = PromoteSlaveResponse{} }
+func (m *PromoteSlaveResponse) String() string { return proto.CompactTextString(m) }
+func (*PromoteSlaveResponse) ProtoMessage()    {}
+
+func (m *PromoteSlaveResponse) GetRestartSlaveData() *RestartSlaveData {
+	if m != nil {
+		return m.RestartSlaveData
+	}
+	return nil
+}
+
+func (m *PromoteSlaveResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type SlaveWasPromotedRequest struct {
+}
+
+func (m *SlaveWasPromotedRequest) Reset()         { *m = SlaveWasPromotedRequest{} }
+func (m *SlaveWasPromotedRequest) String() string { return proto.CompactTextString(m) }
+func (*SlaveWasPromotedRequest) ProtoMessage()    {}
+
+type SlaveWasPromotedResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SlaveWasPromotedResponse) Reset()         { *m = SlaveWasPromotedResponse{} }
+func (m *SlaveWasPromotedResponse) String() string { return proto.CompactTextString(m) }
+func (*SlaveWasPromotedResponse) ProtoMessage()    {}
+
+func (m *SlaveWasPromotedResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type RestartSlaveRequest struct {
+	RestartSlaveData *RestartSlaveData `protobuf:"bytes,1,opt,name=restart_slave_data" json:"restart_slave_data,omitempty"`
+}
+
+func (m *RestartSlaveRequest) Reset()         { *m = RestartSlaveRequest{} }
+func (m *RestartSlaveRequest) String() string { return proto.CompactTextString(m) }
+func (*RestartSlaveRequest) ProtoMessage()    {}
+
+func (m *RestartSlaveRequest) GetRestartSlaveData() *RestartSlaveData {
+	if m != nil {
+		return m.RestartSlaveData
+	}
+	return nil
+}
+
+type RestartSlaveResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *RestartSlaveResponse) Reset()         { *m = RestartSlaveResponse{} }
+func (m *RestartSlaveResponse) String() string { return proto.CompactTextString(m) }
+func (*RestartSlaveResponse) ProtoMessage()    {}
+
+func (m *RestartSlaveResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type SlaveWasRestartedRequest struct {
+	Parent *TabletAlias `protobuf:"bytes,1,opt,name=parent" json:"parent,omitempty"`
+}
+
+func (m *SlaveWasRestartedRequest) Reset()         { *m = SlaveWasRestartedRequest{} }
+func (m *SlaveWasRestartedRequest) String() string { return proto.CompactTextString(m) }
+func (*SlaveWasRestartedRequest) ProtoMessage()    {}
+
+func (m *SlaveWasRestartedRequest) GetParent() *TabletAlias {
+	if m != nil {
+		return m.Parent
+	}
+	return nil
+}
+
+type SlaveWasRestartedResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SlaveWasRestartedResponse) Reset()         { *m = SlaveWasRestartedResponse{} }
+func (m *SlaveWasRestartedResponse) String() string { return proto.CompactTextString(m) }
+func (*SlaveWasRestartedResponse) ProtoMessage()    {}
+
+func (m *SlaveWasRestartedResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type BreakSlavesRequest struct {
+}
+
+func (m *BreakSlavesRequest) Reset()         { *m = BreakSlavesRequest{} }
+func (m *BreakSlavesRequest) String() string { return proto.CompactTextString(m) }
+func (*BreakSlavesRequest) ProtoMessage()    {}
+
+type BreakSlavesResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *BreakSlavesResponse) Reset()         { *m = BreakSlavesResponse{} }
+func (m *BreakSlavesResponse) String() string { return proto.CompactTextString(m) }
+func (*BreakSlavesResponse) ProtoMessage()    {}
+
+func (m *BreakSlavesResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type SnapshotRequest struct {
 	Concurrency         int64 `protobuf:"varint,1,opt,name=concurrency" json:"concurrency,omitempty"`
 	ServerMode          bool  `protobuf:"varint,2,opt,name=server_mode" json:"server_mode,omitempty"`
 	ForceMasterSnapshot bool  `protobuf:"varint,3,opt,name=force_master_snapshot" json:"force_master_snapshot,omitempty"`
 }
 
-func (m *SnapshotArgs) Reset()         { *m = SnapshotArgs{} }
-func (m *SnapshotArgs) String() string { return proto.CompactTextString(m) }
-func (*SnapshotArgs) ProtoMessage()    {}
+func (m *SnapshotRequest) Reset()         { *m = SnapshotRequest{} }
+func (m *SnapshotRequest) String() string { return proto.CompactTextString(m) }
+func (*SnapshotRequest) ProtoMessage()    {}
+
+// SnapshotResponse is streamed by Snapshot: first the log events, then
+// a last message with either the result or the error.
+type SnapshotResponse struct {
+	LogEvent *vtctl.LoggerEvent `protobuf:"bytes,1,opt,name=log_event" json:"log_event,omitempty"`
+	Result   *SnapshotReply     `protobuf:"bytes,2,opt,name=result" json:"result,omitempty"`
+	Error    *query.RPCError    `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SnapshotResponse) Reset()         { *m = SnapshotResponse{} }
+func (m *SnapshotResponse) String() string { return proto.CompactTextString(m) }
+func (*SnapshotResponse) ProtoMessage()    {}
+
+func (m *SnapshotResponse) GetLogEvent() *vtctl.LoggerEvent {
+	if m != nil {
+		return m.LogEvent
+	}
+	return nil
+}
+
+func (m *SnapshotResponse) GetResult() *SnapshotReply {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (m *SnapshotResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type SnapshotSourceEndRequest struct {
+	SlaveStartRequired bool   `protobuf:"varint,1,opt,name=slave_start_required" json:"slave_start_required,omitempty"`
+	ReadOnly           bool   `protobuf:"varint,2,opt,name=read_only" json:"read_only,omitempty"`
+	OriginalType       string `protobuf:"bytes,3,opt,name=original_type" json:"original_type,omitempty"`
+}
+
+func (m *SnapshotSourceEndRequest) Reset()         { *m = SnapshotSourceEndRequest{} }
+func (m *SnapshotSourceEndRequest) String() string { return proto.CompactTextString(m) }
+func (*SnapshotSourceEndRequest) ProtoMessage()    {}
+
+type SnapshotSourceEndResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SnapshotSourceEndResponse) Reset()         { *m = SnapshotSourceEndResponse{} }
+func (m *SnapshotSourceEndResponse) String() string { return proto.CompactTextString(m) }
+func (*SnapshotSourceEndResponse) ProtoMessage()    {}
+
+func (m *SnapshotSourceEndResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ReserveForRestoreRequest struct {
+	SrcTabletAlias *TabletAlias `protobuf:"bytes,1,opt,name=src_tablet_alias" json:"src_tablet_alias,omitempty"`
+}
+
+func (m *ReserveForRestoreRequest) Reset()         { *m = ReserveForRestoreRequest{} }
+func (m *ReserveForRestoreRequest) String() string { return proto.CompactTextString(m) }
+func (*ReserveForRestoreRequest) ProtoMessage()    {}
+
+func (m *ReserveForRestoreRequest) GetSrcTabletAlias() *TabletAlias {
+	if m != nil {
+		return m.SrcTabletAlias
+	}
+	return nil
+}
+
+type ReserveForRestoreResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ReserveForRestoreResponse) Reset()         { *m = ReserveForRestoreResponse{} }
+func (m *ReserveForRestoreResponse) String() string { return proto.CompactTextString(m) }
+func (*ReserveForRestoreResponse) ProtoMessage()    {}
+
+func (m *ReserveForRestoreResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type RestoreRequest struct {
+	SrcTabletAlias        *TabletAlias `protobuf:"bytes,1,opt,name=src_tablet_alias" json:"src_tablet_alias,omitempty"`
+	SrcFilePath           string       `protobuf:"bytes,2,opt,name=src_file_path" json:"src_file_path,omitempty"`
+	ParentAlias           *TabletAlias `protobuf:"bytes,3,opt,name=parent_alias" json:"parent_alias,omitempty"`
+	FetchConcurrency      int64        `protobuf:"varint,4,opt,name=fetch_concurrency" json:"fetch_concurrency,omitempty"`
+	FetchRetryCount       int64        `protobuf:"varint,5,opt,name=fetch_retry_count" json:"fetch_retry_count,omitempty"`
+	WasReserved           bool         `protobuf:"varint,6,opt,name=was_reserved" json:"was_reserved,omitempty"`
+	DontWaitForSlaveStart bool         `protobuf:"varint,7,opt,name=dont_wait_for_slave_start" json:"dont_wait_for_slave_start,omitempty"`
+	FanOut                int64        `protobuf:"varint,8,opt,name=fan_out" json:"fan_out,omitempty"`
+}
+
+func (m *RestoreRequest) Reset()         { *m = RestoreRequest{} }
+func (m *RestoreRequest) String() string { return proto.CompactTextString(m) }
+func (*RestoreRequest) ProtoMessage()    {}
+
+func (m *RestoreRequest) GetSrcTabletAlias() *TabletAlias {
+	if m != nil {
+		return m.SrcTabletAlias
+	}
+	return nil
+}
+
+func (m *RestoreRequest) GetParentAlias() *TabletAlias {
+	if m != nil {
+		return m.ParentAlias
+	}
+	return nil
+}
+
+// RestoreResponse is streamed by Restore: first the log events, then
+// a last message with the error, if any.
+type RestoreResponse struct {
+	LogEvent *vtctl.LoggerEvent `protobuf:"bytes,1,opt,name=log_event" json:"log_event,omitempty"`
+	Error    *query.RPCError    `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *RestoreResponse) Reset()         { *m = RestoreResponse{} }
+func (m *RestoreResponse) String() string { return proto.CompactTextString(m) }
+func (*RestoreResponse) ProtoMessage()    {}
+
+func (m *RestoreResponse) GetLogEvent() *vtctl.LoggerEvent {
+	if m != nil {
+		return m.LogEvent
+	}
+	return nil
+}
+
+func (m *RestoreResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type DeleteSnapshotRequest struct {
+	ManifestPath string `protobuf:"bytes,1,opt,name=manifest_path" json:"manifest_path,omitempty"`
+}
+
+func (m *DeleteSnapshotRequest) Reset()         { *m = DeleteSnapshotRequest{} }
+func (m *DeleteSnapshotRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteSnapshotRequest) ProtoMessage()    {}
+
+type DeleteSnapshotResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *DeleteSnapshotResponse) Reset()         { *m = DeleteSnapshotResponse{} }
+func (m *DeleteSnapshotResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteSnapshotResponse) ProtoMessage()    {}
+
+func (m *DeleteSnapshotResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type CleanOrphansRequest struct {
+	// max_age is in nanoseconds
+	MaxAge int64 `protobuf:"varint,1,opt,name=max_age" json:"max_age,omitempty"`
+	DryRun bool  `protobuf:"varint,2,opt,name=dry_run" json:"dry_run,omitempty"`
+}
+
+func (m *CleanOrphansRequest) Reset()         { *m = CleanOrphansRequest{} }
+func (m *CleanOrphansRequest) String() string { return proto.CompactTextString(m) }
+func (*CleanOrphansRequest) ProtoMessage()    {}
+
+type CleanOrphansResponse struct {
+	DryRun         bool            `protobuf:"varint,1,opt,name=dry_run" json:"dry_run,omitempty"`
+	Files          []*OrphanedFile `protobuf:"bytes,2,rep,name=files" json:"files,omitempty"`
+	ReclaimedBytes int64           `protobuf:"varint,3,opt,name=reclaimed_bytes" json:"reclaimed_bytes,omitempty"`
+	Error          *query.RPCError `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *CleanOrphansResponse) Reset()         { *m = CleanOrphansResponse{} }
+func (m *CleanOrphansResponse) String() string { return proto.CompactTextString(m) }
+func (*CleanOrphansResponse) ProtoMessage()    {}
+
+func (m *CleanOrphansResponse) GetFiles() []*OrphanedFile {
+	if m != nil {
+		return m.Files
+	}
+	return nil
+}
+
+func (m *CleanOrphansResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+func init() {
+}
+
+// Client API for TabletManager service
+
+type TabletManagerClient interface {
+	//
+	// Various read-only methods
+	//
+	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
+	Sleep(ctx context.Context, in *SleepRequest, opts ...grpc.CallOption) (*SleepResponse, error)
+	ExecuteHook(ctx context.Context, in *ExecuteHookRequest, opts ...grpc.CallOption) (*ExecuteHookResponse, error)
+	GetSchema(ctx context.Context, in *GetSchemaRequest, opts ...grpc.CallOption) (*GetSchemaResponse, error)
+	GetPermissions(ctx context.Context, in *GetPermissionsRequest, opts ...grpc.CallOption) (*GetPermissionsResponse, error)
+	//
+	// Various read-write methods
+	//
+	SetReadOnly(ctx context.Context, in *SetReadOnlyRequest, opts ...grpc.CallOption) (*SetReadOnlyResponse, error)
+	SetReadWrite(ctx context.Context, in *SetReadWriteRequest, opts ...grpc.CallOption) (*SetReadWriteResponse, error)
+	ChangeType(ctx context.Context, in *ChangeTypeRequest, opts ...grpc.CallOption) (*ChangeTypeResponse, error)
+	Scrap(ctx context.Context, in *ScrapRequest, opts ...grpc.CallOption) (*ScrapResponse, error)
+	RefreshState(ctx context.Context, in *RefreshStateRequest, opts ...grpc.CallOption) (*RefreshStateResponse, error)
+	RunHealthCheck(ctx context.Context, in *RunHealthCheckRequest, opts ...grpc.CallOption) (*RunHealthCheckResponse, error)
+	HealthStream(ctx context.Context, in *HealthStreamRequest, opts ...grpc.CallOption) (TabletManager_HealthStreamClient, error)
+	ReloadSchema(ctx context.Context, in *ReloadSchemaRequest, opts ...grpc.CallOption) (*ReloadSchemaResponse, error)
+	PreflightSchema(ctx context.Context, in *PreflightSchemaRequest, opts ...grpc.CallOption) (*PreflightSchemaResponse, error)
+	ApplySchema(ctx context.Context, in *ApplySchemaRequest, opts ...grpc.CallOption) (*ApplySchemaResponse, error)
+	ExecuteFetch(ctx context.Context, in *ExecuteFetchRequest, opts ...grpc.CallOption) (*ExecuteFetchResponse, error)
+	//
+	// Replication related methods
+	//
+	SlaveStatus(ctx context.Context, in *SlaveStatusRequest, opts ...grpc.CallOption) (*SlaveStatusResponse, error)
+	WaitSlavePosition(ctx context.Context, in *WaitSlavePositionRequest, opts ...grpc.CallOption) (*WaitSlavePositionResponse, error)
+	MasterPosition(ctx context.Context, in *MasterPositionRequest, opts ...grpc.CallOption) (*MasterPositionResponse, error)
+	ReparentPosition(ctx context.Context, in *ReparentPositionRequest, opts ...grpc.CallOption) (*ReparentPositionResponse, error)
+	StopSlave(ctx context.Context, in *StopSlaveRequest, opts ...grpc.CallOption) (*StopSlaveResponse, error)
+	StopSlaveMinimum(ctx context.Context, in *StopSlaveMinimumRequest, opts ...grpc.CallOption) (*StopSlaveMinimumResponse, error)
+	StartSlave(ctx context.Context, in *StartSlaveRequest, opts ...grpc.CallOption) (*StartSlaveResponse, error)
+	StartSlaveUntilAfter(ctx context.Context, in *StartSlaveUntilAfterRequest, opts ...grpc.CallOption) (*StartSlaveUntilAfterResponse, error)
+	TabletExternallyReparented(ctx context.Context, in *TabletExternallyReparentedRequest, opts ...grpc.CallOption) (*TabletExternallyReparentedResponse, error)
+	GetSlaves(ctx context.Context, in *GetSlavesRequest, opts ...grpc.CallOption) (*GetSlavesResponse, error)
+	WaitBlpPosition(ctx context.Context, in *WaitBlpPositionRequest, opts ...grpc.CallOption) (*WaitBlpPositionResponse, error)
+	StopBlp(ctx context.Context, in *StopBlpRequest, opts ...grpc.CallOption) (*StopBlpResponse, error)
+	StartBlp(ctx context.Context, in *StartBlpRequest, opts ...grpc.CallOption) (*StartBlpResponse, error)
+	RunBlpUntil(ctx context.Context, in *RunBlpUntilRequest, opts ...grpc.CallOption) (*RunBlpUntilResponse, error)
+	//
+	// Reparenting related functions
+	//
+	DemoteMaster(ctx context.Context, in *DemoteMasterRequest, opts ...grpc.CallOption) (*DemoteMasterResponse, error)
+	PromoteSlave(ctx context.Context, in *PromoteSlaveRequest, opts ...grpc.CallOption) (*PromoteSlaveResponse, error)
+	SlaveWasPromoted(ctx context.Context, in *SlaveWasPromotedRequest, opts ...grpc.CallOption) (*SlaveWasPromotedResponse, error)
+	RestartSlave(ctx context.Context, in *RestartSlaveRequest, opts ...grpc.CallOption) (*RestartSlaveResponse, error)
+	SlaveWasRestarted(ctx context.Context, in *SlaveWasRestartedRequest, opts ...grpc.CallOption) (*SlaveWasRestartedResponse, error)
+	BreakSlaves(ctx context.Context, in *BreakSlavesRequest, opts ...grpc.CallOption) (*BreakSlavesResponse, error)
+	//
+	// Backup related methods
+	//
+	Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (TabletManager_SnapshotClient, error)
+	SnapshotSourceEnd(ctx context.Context, in *SnapshotSourceEndRequest, opts ...grpc.CallOption) (*SnapshotSourceEndResponse, error)
+	ReserveForRestore(ctx context.Context, in *ReserveForRestoreRequest, opts ...grpc.CallOption) (*ReserveForRestoreResponse, error)
+	Restore(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (TabletManager_RestoreClient, error)
+	DeleteSnapshot(ctx context.Context, in *DeleteSnapshotRequest, opts ...grpc.CallOption) (*DeleteSnapshotResponse, error)
+	CleanOrphans(ctx context.Context, in *CleanOrphansRequest, opts ...grpc.CallOption) (*CleanOrphansResponse, error)
+}
+
+type tabletManagerClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewTabletManagerClient(cc *grpc.ClientConn) TabletManagerClient {
+	return &tabletManagerClient{cc}
+}
+
+func (c *tabletManagerClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
+	out := new(PingResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/Ping", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) Sleep(ctx context.Context, in *SleepRequest, opts ...grpc.CallOption) (*SleepResponse, error) {
+	out := new(SleepResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/Sleep", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) ExecuteHook(ctx context.Context, in *ExecuteHookRequest, opts ...grpc.CallOption) (*ExecuteHookResponse, error) {
+	out := new(ExecuteHookResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/ExecuteHook", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) GetSchema(ctx context.Context, in *GetSchemaRequest, opts ...grpc.CallOption) (*GetSchemaResponse, error) {
+	out := new(GetSchemaResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/GetSchema", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) GetPermissions(ctx context.Context, in *GetPermissionsRequest, opts ...grpc.CallOption) (*GetPermissionsResponse, error) {
+	out := new(GetPermissionsResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/GetPermissions", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) SetReadOnly(ctx context.Context, in *SetReadOnlyRequest, opts ...grpc.CallOption) (*SetReadOnlyResponse, error) {
+	out := new(SetReadOnlyResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/SetReadOnly", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) SetReadWrite(ctx context.Context, in *SetReadWriteRequest, opts ...grpc.CallOption) (*SetReadWriteResponse, error) {
+	out := new(SetReadWriteResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/SetReadWrite", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) ChangeType(ctx context.Context, in *ChangeTypeRequest, opts ...grpc.CallOption) (*ChangeTypeResponse, error) {
+	out := new(ChangeTypeResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/ChangeType", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) Scrap(ctx context.Context, in *ScrapRequest, opts ...grpc.CallOption) (*ScrapResponse, error) {
+	out := new(ScrapResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/Scrap", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) RefreshState(ctx context.Context, in *RefreshStateRequest, opts ...grpc.CallOption) (*RefreshStateResponse, error) {
+	out := new(RefreshStateResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/RefreshState", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) RunHealthCheck(ctx context.Context, in *RunHealthCheckRequest, opts ...grpc.CallOption) (*RunHealthCheckResponse, error) {
+	out := new(RunHealthCheckResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/RunHealthCheck", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) HealthStream(ctx context.Context, in *HealthStreamRequest, opts ...grpc.CallOption) (TabletManager_HealthStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TabletManager_serviceDesc.Streams[0], c.cc, "/tabletmanager.TabletManager/HealthStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tabletManagerHealthStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TabletManager_HealthStreamClient interface {
+	Recv() (*HealthStreamResponse, error)
+	grpc.ClientStream
+}
+
+type tabletManagerHealthStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *tabletManagerHealthStreamClient) Recv() (*HealthStreamResponse, error) {
+	m := new(HealthStreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *tabletManagerClient) ReloadSchema(ctx context.Context, in *ReloadSchemaRequest, opts ...grpc.CallOption) (*ReloadSchemaResponse, error) {
+	out := new(ReloadSchemaResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/ReloadSchema", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) PreflightSchema(ctx context.Context, in *PreflightSchemaRequest, opts ...grpc.CallOption) (*PreflightSchemaResponse, error) {
+	out := new(PreflightSchemaResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/PreflightSchema", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) ApplySchema(ctx context.Context, in *ApplySchemaRequest, opts ...grpc.CallOption) (*ApplySchemaResponse, error) {
+	out := new(ApplySchemaResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/ApplySchema", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) ExecuteFetch(ctx context.Context, in *ExecuteFetchRequest, opts ...grpc.CallOption) (*ExecuteFetchResponse, error) {
+	out := new(ExecuteFetchResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/ExecuteFetch", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) SlaveStatus(ctx context.Context, in *SlaveStatusRequest, opts ...grpc.CallOption) (*SlaveStatusResponse, error) {
+	out := new(SlaveStatusResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/SlaveStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) WaitSlavePosition(ctx context.Context, in *WaitSlavePositionRequest, opts ...grpc.CallOption) (*WaitSlavePositionResponse, error) {
+	out := new(WaitSlavePositionResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/WaitSlavePosition", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) MasterPosition(ctx context.Context, in *MasterPositionRequest, opts ...grpc.CallOption) (*MasterPositionResponse, error) {
+	out := new(MasterPositionResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/MasterPosition", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) ReparentPosition(ctx context.Context, in *ReparentPositionRequest, opts ...grpc.CallOption) (*ReparentPositionResponse, error) {
+	out := new(ReparentPositionResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/ReparentPosition", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) StopSlave(ctx context.Context, in *StopSlaveRequest, opts ...grpc.CallOption) (*StopSlaveResponse, error) {
+	out := new(StopSlaveResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/StopSlave", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) StopSlaveMinimum(ctx context.Context, in *StopSlaveMinimumRequest, opts ...grpc.CallOption) (*StopSlaveMinimumResponse, error) {
+	out := new(StopSlaveMinimumResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/StopSlaveMinimum", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) StartSlave(ctx context.Context, in *StartSlaveRequest, opts ...grpc.CallOption) (*StartSlaveResponse, error) {
+	out := new(StartSlaveResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/StartSlave", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) StartSlaveUntilAfter(ctx context.Context, in *StartSlaveUntilAfterRequest, opts ...grpc.CallOption) (*StartSlaveUntilAfterResponse, error) {
+	out := new(StartSlaveUntilAfterResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/StartSlaveUntilAfter", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) TabletExternallyReparented(ctx context.Context, in *TabletExternallyReparentedRequest, opts ...grpc.CallOption) (*TabletExternallyReparentedResponse, error) {
+	out := new(TabletExternallyReparentedResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/TabletExternallyReparented", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) GetSlaves(ctx context.Context, in *GetSlavesRequest, opts ...grpc.CallOption) (*GetSlavesResponse, error) {
+	out := new(GetSlavesResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/GetSlaves", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) WaitBlpPosition(ctx context.Context, in *WaitBlpPositionRequest, opts ...grpc.CallOption) (*WaitBlpPositionResponse, error) {
+	out := new(WaitBlpPositionResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/WaitBlpPosition", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) StopBlp(ctx context.Context, in *StopBlpRequest, opts ...grpc.CallOption) (*StopBlpResponse, error) {
+	out := new(StopBlpResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/StopBlp", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) StartBlp(ctx context.Context, in *StartBlpRequest, opts ...grpc.CallOption) (*StartBlpResponse, error) {
+	out := new(StartBlpResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/StartBlp", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) RunBlpUntil(ctx context.Context, in *RunBlpUntilRequest, opts ...grpc.CallOption) (*RunBlpUntilResponse, error) {
+	out := new(RunBlpUntilResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/RunBlpUntil", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) DemoteMaster(ctx context.Context, in *DemoteMasterRequest, opts ...grpc.CallOption) (*DemoteMasterResponse, error) {
+	out := new(DemoteMasterResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/DemoteMaster", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) PromoteSlave(ctx context.Context, in *PromoteSlaveRequest, opts ...grpc.CallOption) (*PromoteSlaveResponse, error) {
+	out := new(PromoteSlaveResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/PromoteSlave", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) SlaveWasPromoted(ctx context.Context, in *SlaveWasPromotedRequest, opts ...grpc.CallOption) (*SlaveWasPromotedResponse, error) {
+	out := new(SlaveWasPromotedResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/SlaveWasPromoted", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) RestartSlave(ctx context.Context, in *RestartSlaveRequest, opts ...grpc.CallOption) (*RestartSlaveResponse, error) {
+	out := new(RestartSlaveResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/RestartSlave", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) SlaveWasRestarted(ctx context.Context, in *SlaveWasRestartedRequest, opts ...grpc.CallOption) (*SlaveWasRestartedResponse, error) {
+	out := new(SlaveWasRestartedResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/SlaveWasRestarted", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) BreakSlaves(ctx context.Context, in *BreakSlavesRequest, opts ...grpc.CallOption) (*BreakSlavesResponse, error) {
+	out := new(BreakSlavesResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/BreakSlaves", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (TabletManager_SnapshotClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TabletManager_serviceDesc.Streams[1], c.cc, "/tabletmanager.TabletManager/Snapshot", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tabletManagerSnapshotClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TabletManager_SnapshotClient interface {
+	Recv() (*SnapshotResponse, error)
+	grpc.ClientStream
+}
+
+type tabletManagerSnapshotClient struct {
+	grpc.ClientStream
+}
+
+func (x *tabletManagerSnapshotClient) Recv() (*SnapshotResponse, error) {
+	m := new(SnapshotResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *tabletManagerClient) SnapshotSourceEnd(ctx context.Context, in *SnapshotSourceEndRequest, opts ...grpc.CallOption) (*SnapshotSourceEndResponse, error) {
+	out := new(SnapshotSourceEndResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/SnapshotSourceEnd", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) ReserveForRestore(ctx context.Context, in *ReserveForRestoreRequest, opts ...grpc.CallOption) (*ReserveForRestoreResponse, error) {
+	out := new(ReserveForRestoreResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/ReserveForRestore", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) Restore(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (TabletManager_RestoreClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TabletManager_serviceDesc.Streams[2], c.cc, "/tabletmanager.TabletManager/Restore", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tabletManagerRestoreClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TabletManager_RestoreClient interface {
+	Recv() (*RestoreResponse, error)
+	grpc.ClientStream
+}
+
+type tabletManagerRestoreClient struct {
+	grpc.ClientStream
+}
+
+func (x *tabletManagerRestoreClient) Recv() (*RestoreResponse, error) {
+	m := new(RestoreResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *tabletManagerClient) DeleteSnapshot(ctx context.Context, in *DeleteSnapshotRequest, opts ...grpc.CallOption) (*DeleteSnapshotResponse, error) {
+	out := new(DeleteSnapshotResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/DeleteSnapshot", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tabletManagerClient) CleanOrphans(ctx context.Context, in *CleanOrphansRequest, opts ...grpc.CallOption) (*CleanOrphansResponse, error) {
+	out := new(CleanOrphansResponse)
+	err := grpc.Invoke(ctx, "/tabletmanager.TabletManager/CleanOrphans", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for TabletManager service
+
+type TabletManagerServer interface {
+	//
+	// Various read-only methods
+	//
+	Ping(context.Context, *PingRequest) (*PingResponse, error)
+	Sleep(context.Context, *SleepRequest) (*SleepResponse, error)
+	ExecuteHook(context.Context, *ExecuteHookRequest) (*ExecuteHookResponse, error)
+	GetSchema(context.Context, *GetSchemaRequest) (*GetSchemaResponse, error)
+	GetPermissions(context.Context, *GetPermissionsRequest) (*GetPermissionsResponse, error)
+	//
+	// Various read-write methods
+	//
+	SetReadOnly(context.Context, *SetReadOnlyRequest) (*SetReadOnlyResponse, error)
+	SetReadWrite(context.Context, *SetReadWriteRequest) (*SetReadWriteResponse, error)
+	ChangeType(context.Context, *ChangeTypeRequest) (*ChangeTypeResponse, error)
+	Scrap(context.Context, *ScrapRequest) (*ScrapResponse, error)
+	RefreshState(context.Context, *RefreshStateRequest) (*RefreshStateResponse, error)
+	RunHealthCheck(context.Context, *RunHealthCheckRequest) (*RunHealthCheckResponse, error)
+	HealthStream(*HealthStreamRequest, TabletManager_HealthStreamServer) error
+	ReloadSchema(context.Context, *ReloadSchemaRequest) (*ReloadSchemaResponse, error)
+	PreflightSchema(context.Context, *PreflightSchemaRequest) (*PreflightSchemaResponse, error)
+	ApplySchema(context.Context, *ApplySchemaRequest) (*ApplySchemaResponse, error)
+	ExecuteFetch(context.Context, *ExecuteFetchRequest) (*ExecuteFetchResponse, error)
+	//
+	// Replication related methods
+	//
+	SlaveStatus(context.Context, *SlaveStatusRequest) (*SlaveStatusResponse, error)
+	WaitSlavePosition(context.Context, *WaitSlavePositionRequest) (*WaitSlavePositionResponse, error)
+	MasterPosition(context.Context, *MasterPositionRequest) (*MasterPositionResponse, error)
+	ReparentPosition(context.Context, *ReparentPositionRequest) (*ReparentPositionResponse, error)
+	StopSlave(context.Context, *StopSlaveRequest) (*StopSlaveResponse, error)
+	StopSlaveMinimum(context.Context, *StopSlaveMinimumRequest) (*StopSlaveMinimumResponse, error)
+	StartSlave(context.Context, *StartSlaveRequest) (*StartSlaveResponse, error)
+	StartSlaveUntilAfter(context.Context, *StartSlaveUntilAfterRequest) (*StartSlaveUntilAfterResponse, error)
+	TabletExternallyReparented(context.Context, *TabletExternallyReparentedRequest) (*TabletExternallyReparentedResponse, error)
+	GetSlaves(context.Context, *GetSlavesRequest) (*GetSlavesResponse, error)
+	WaitBlpPosition(context.Context, *WaitBlpPositionRequest) (*WaitBlpPositionResponse, error)
+	StopBlp(context.Context, *StopBlpRequest) (*StopBlpResponse, error)
+	StartBlp(context.Context, *StartBlpRequest) (*StartBlpResponse, error)
+	RunBlpUntil(context.Context, *RunBlpUntilRequest) (*RunBlpUntilResponse, error)
+	//
+	// Reparenting related functions
+	//
+	DemoteMaster(context.Context, *DemoteMasterRequest) (*DemoteMasterResponse, error)
+	PromoteSlave(context.Context, *PromoteSlaveRequest) (*PromoteSlaveResponse, error)
+	SlaveWasPromoted(context.Context, *SlaveWasPromotedRequest) (*SlaveWasPromotedResponse, error)
+	RestartSlave(context.Context, *RestartSlaveRequest) (*RestartSlaveResponse, error)
+	SlaveWasRestarted(context.Context, *SlaveWasRestartedRequest) (*SlaveWasRestartedResponse, error)
+	BreakSlaves(context.Context, *BreakSlavesRequest) (*BreakSlavesResponse, error)
+	//
+	// Backup related methods
+	//
+	Snapshot(*SnapshotRequest, TabletManager_SnapshotServer) error
+	SnapshotSourceEnd(context.Context, *SnapshotSourceEndRequest) (*SnapshotSourceEndResponse, error)
+	ReserveForRestore(context.Context, *ReserveForRestoreRequest) (*ReserveForRestoreResponse, error)
+	Restore(*RestoreRequest, TabletManager_RestoreServer) error
+	DeleteSnapshot(context.Context, *DeleteSnapshotRequest) (*DeleteSnapshotResponse, error)
+	CleanOrphans(context.Context, *CleanOrphansRequest) (*CleanOrphansResponse, error)
+}
+
+func RegisterTabletManagerServer(s *grpc.Server, srv TabletManagerServer) {
+	s.RegisterService(&_TabletManager_serviceDesc, srv)
+}
+
+func _TabletManager_Ping_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(PingRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).Ping(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_Sleep_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(SleepRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).Sleep(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_ExecuteHook_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExecuteHookRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).ExecuteHook(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_GetSchema_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(GetSchemaRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).GetSchema(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_GetPermissions_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(GetPermissionsRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).GetPermissions(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_SetReadOnly_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(SetReadOnlyRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).SetReadOnly(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_SetReadWrite_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(SetReadWriteRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).SetReadWrite(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_ChangeType_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ChangeTypeRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).ChangeType(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_Scrap_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ScrapRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).Scrap(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_RefreshState_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(RefreshStateRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).RefreshState(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_RunHealthCheck_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(RunHealthCheckRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).RunHealthCheck(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_HealthStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(HealthStreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TabletManagerServer).HealthStream(m, &tabletManagerHealthStreamServer{stream})
+}
+
+type TabletManager_HealthStreamServer interface {
+	Send(*HealthStreamResponse) error
+	grpc.ServerStream
+}
+
+type tabletManagerHealthStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *tabletManagerHealthStreamServer) Send(m *HealthStreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TabletManager_ReloadSchema_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ReloadSchemaRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).ReloadSchema(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_PreflightSchema_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(PreflightSchemaRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).PreflightSchema(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_ApplySchema_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ApplySchemaRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).ApplySchema(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_ExecuteFetch_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExecuteFetchRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).ExecuteFetch(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_SlaveStatus_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(SlaveStatusRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).SlaveStatus(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
 
-func init() {
+func _TabletManager_WaitSlavePosition_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(WaitSlavePositionRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).WaitSlavePosition(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-// Client API for TabletManager service
+func _TabletManager_MasterPosition_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(MasterPositionRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).MasterPosition(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
 
-type TabletManagerClient interface {
-	// FIXME(alainjobart) need to also return SnapshotReply
-	Snapshot(ctx context.Context, in *SnapshotArgs, opts ...grpc.CallOption) (TabletManager_SnapshotClient, error)
+func _TabletManager_ReparentPosition_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ReparentPositionRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).ReparentPosition(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-type tabletManagerClient struct {
-	cc *grpc.ClientConn
+func _TabletManager_StopSlave_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(StopSlaveRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).StopSlave(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func NewTabletManagerClient(cc *grpc.ClientConn) TabletManagerClient {
-	return &tabletManagerClient{cc}
+func _TabletManager_StopSlaveMinimum_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(StopSlaveMinimumRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).StopSlaveMinimum(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func (c *tabletManagerClient) Snapshot(ctx context.Context, in *SnapshotArgs, opts ...grpc.CallOption) (TabletManager_SnapshotClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_TabletManager_serviceDesc.Streams[0], c.cc, "/tabletmanager.TabletManager/Snapshot", opts...)
+func _TabletManager_StartSlave_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(StartSlaveRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).StartSlave(ctx, in)
 	if err != nil {
 		return nil, err
 	}
-	x := &tabletManagerSnapshotClient{stream}
-	if err := x.ClientStream.SendMsg(in); err != nil {
+	return out, nil
+}
+
+func _TabletManager_StartSlaveUntilAfter_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(StartSlaveUntilAfterRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
 		return nil, err
 	}
-	if err := x.ClientStream.CloseSend(); err != nil {
+	out, err := srv.(TabletManagerServer).StartSlaveUntilAfter(ctx, in)
+	if err != nil {
 		return nil, err
 	}
-	return x, nil
+	return out, nil
 }
 
-type TabletManager_SnapshotClient interface {
-	Recv() (*vtctl.LoggerEvent, error)
-	grpc.ClientStream
+func _TabletManager_TabletExternallyReparented_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(TabletExternallyReparentedRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).TabletExternallyReparented(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-type tabletManagerSnapshotClient struct {
-	grpc.ClientStream
+func _TabletManager_GetSlaves_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(GetSlavesRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).GetSlaves(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func (x *tabletManagerSnapshotClient) Recv() (*vtctl.LoggerEvent, error) {
-	m := new(vtctl.LoggerEvent)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
+func _TabletManager_WaitBlpPosition_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(WaitBlpPositionRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
 		return nil, err
 	}
-	return m, nil
+	out, err := srv.(TabletManagerServer).WaitBlpPosition(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-// Server API for TabletManager service
+func _TabletManager_StopBlp_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(StopBlpRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).StopBlp(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
 
-type TabletManagerServer interface {
-	// FIXME(alainjobart) need to also return SnapshotReply
-	Snapshot(*SnapshotArgs, TabletManager_SnapshotServer) error
+func _TabletManager_StartBlp_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(StartBlpRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).StartBlp(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func RegisterTabletManagerServer(s *grpc.Server, srv TabletManagerServer) {
-	s.RegisterService(&_TabletManager_serviceDesc, srv)
+func _TabletManager_RunBlpUntil_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(RunBlpUntilRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).RunBlpUntil(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_DemoteMaster_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(DemoteMasterRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).DemoteMaster(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_PromoteSlave_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(PromoteSlaveRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).PromoteSlave(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_SlaveWasPromoted_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(SlaveWasPromotedRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).SlaveWasPromoted(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_RestartSlave_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(RestartSlaveRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).RestartSlave(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_SlaveWasRestarted_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(SlaveWasRestartedRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).SlaveWasRestarted(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_BreakSlaves_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(BreakSlavesRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).BreakSlaves(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
 func _TabletManager_Snapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(SnapshotArgs)
+	m := new(SnapshotRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
@@ -108,7 +2670,7 @@ func _TabletManager_Snapshot_Handler(srv interface{}, stream grpc.ServerStream)
 }
 
 type TabletManager_SnapshotServer interface {
-	Send(*vtctl.LoggerEvent) error
+	Send(*SnapshotResponse) error
 	grpc.ServerStream
 }
 
@@ -116,19 +2678,255 @@ type tabletManagerSnapshotServer struct {
 	grpc.ServerStream
 }
 
-func (x *tabletManagerSnapshotServer) Send(m *vtctl.LoggerEvent) error {
+func (x *tabletManagerSnapshotServer) Send(m *SnapshotResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TabletManager_SnapshotSourceEnd_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(SnapshotSourceEndRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).SnapshotSourceEnd(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_ReserveForRestore_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ReserveForRestoreRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).ReserveForRestore(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_Restore_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RestoreRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TabletManagerServer).Restore(m, &tabletManagerRestoreServer{stream})
+}
+
+type TabletManager_RestoreServer interface {
+	Send(*RestoreResponse) error
+	grpc.ServerStream
+}
+
+type tabletManagerRestoreServer struct {
+	grpc.ServerStream
+}
+
+func (x *tabletManagerRestoreServer) Send(m *RestoreResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _TabletManager_DeleteSnapshot_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(DeleteSnapshotRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).DeleteSnapshot(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _TabletManager_CleanOrphans_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(CleanOrphansRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TabletManagerServer).CleanOrphans(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _TabletManager_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "tabletmanager.TabletManager",
 	HandlerType: (*TabletManagerServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Ping",
+			Handler:    _TabletManager_Ping_Handler,
+		},
+		{
+			MethodName: "Sleep",
+			Handler:    _TabletManager_Sleep_Handler,
+		},
+		{
+			MethodName: "ExecuteHook",
+			Handler:    _TabletManager_ExecuteHook_Handler,
+		},
+		{
+			MethodName: "GetSchema",
+			Handler:    _TabletManager_GetSchema_Handler,
+		},
+		{
+			MethodName: "GetPermissions",
+			Handler:    _TabletManager_GetPermissions_Handler,
+		},
+		{
+			MethodName: "SetReadOnly",
+			Handler:    _TabletManager_SetReadOnly_Handler,
+		},
+		{
+			MethodName: "SetReadWrite",
+			Handler:    _TabletManager_SetReadWrite_Handler,
+		},
+		{
+			MethodName: "ChangeType",
+			Handler:    _TabletManager_ChangeType_Handler,
+		},
+		{
+			MethodName: "Scrap",
+			Handler:    _TabletManager_Scrap_Handler,
+		},
+		{
+			MethodName: "RefreshState",
+			Handler:    _TabletManager_RefreshState_Handler,
+		},
+		{
+			MethodName: "RunHealthCheck",
+			Handler:    _TabletManager_RunHealthCheck_Handler,
+		},
+		{
+			MethodName: "ReloadSchema",
+			Handler:    _TabletManager_ReloadSchema_Handler,
+		},
+		{
+			MethodName: "PreflightSchema",
+			Handler:    _TabletManager_PreflightSchema_Handler,
+		},
+		{
+			MethodName: "ApplySchema",
+			Handler:    _TabletManager_ApplySchema_Handler,
+		},
+		{
+			MethodName: "ExecuteFetch",
+			Handler:    _TabletManager_ExecuteFetch_Handler,
+		},
+		{
+			MethodName: "SlaveStatus",
+			Handler:    _TabletManager_SlaveStatus_Handler,
+		},
+		{
+			MethodName: "WaitSlavePosition",
+			Handler:    _TabletManager_WaitSlavePosition_Handler,
+		},
+		{
+			MethodName: "MasterPosition",
+			Handler:    _TabletManager_MasterPosition_Handler,
+		},
+		{
+			MethodName: "ReparentPosition",
+			Handler:    _TabletManager_ReparentPosition_Handler,
+		},
+		{
+			MethodName: "StopSlave",
+			Handler:    _TabletManager_StopSlave_Handler,
+		},
+		{
+			MethodName: "StopSlaveMinimum",
+			Handler:    _TabletManager_StopSlaveMinimum_Handler,
+		},
+		{
+			MethodName: "StartSlave",
+			Handler:    _TabletManager_StartSlave_Handler,
+		},
+		{
+			MethodName: "StartSlaveUntilAfter",
+			Handler:    _TabletManager_StartSlaveUntilAfter_Handler,
+		},
+		{
+			MethodName: "TabletExternallyReparented",
+			Handler:    _TabletManager_TabletExternallyReparented_Handler,
+		},
+		{
+			MethodName: "GetSlaves",
+			Handler:    _TabletManager_GetSlaves_Handler,
+		},
+		{
+			MethodName: "WaitBlpPosition",
+			Handler:    _TabletManager_WaitBlpPosition_Handler,
+		},
+		{
+			MethodName: "StopBlp",
+			Handler:    _TabletManager_StopBlp_Handler,
+		},
+		{
+			MethodName: "StartBlp",
+			Handler:    _TabletManager_StartBlp_Handler,
+		},
+		{
+			MethodName: "RunBlpUntil",
+			Handler:    _TabletManager_RunBlpUntil_Handler,
+		},
+		{
+			MethodName: "DemoteMaster",
+			Handler:    _TabletManager_DemoteMaster_Handler,
+		},
+		{
+			MethodName: "PromoteSlave",
+			Handler:    _TabletManager_PromoteSlave_Handler,
+		},
+		{
+			MethodName: "SlaveWasPromoted",
+			Handler:    _TabletManager_SlaveWasPromoted_Handler,
+		},
+		{
+			MethodName: "RestartSlave",
+			Handler:    _TabletManager_RestartSlave_Handler,
+		},
+		{
+			MethodName: "SlaveWasRestarted",
+			Handler:    _TabletManager_SlaveWasRestarted_Handler,
+		},
+		{
+			MethodName: "BreakSlaves",
+			Handler:    _TabletManager_BreakSlaves_Handler,
+		},
+		{
+			MethodName: "SnapshotSourceEnd",
+			Handler:    _TabletManager_SnapshotSourceEnd_Handler,
+		},
+		{
+			MethodName: "ReserveForRestore",
+			Handler:    _TabletManager_ReserveForRestore_Handler,
+		},
+		{
+			MethodName: "DeleteSnapshot",
+			Handler:    _TabletManager_DeleteSnapshot_Handler,
+		},
+		{
+			MethodName: "CleanOrphans",
+			Handler:    _TabletManager_CleanOrphans_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "HealthStream",
+			Handler:       _TabletManager_HealthStream_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "Snapshot",
 			Handler:       _TabletManager_Snapshot_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "Restore",
+			Handler:       _TabletManager_Restore_Handler,
+			ServerStreams: true,
+		},
 	},
 }
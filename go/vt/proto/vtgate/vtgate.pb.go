@@ -0,0 +1,1200 @@
+// Code generated by protoc-gen-go.
+// source: vtgate.proto
+// DO NOT EDIT!
+
+/*
+Package vtgate is a generated protocol buffer package.
+
+It is generated from these files:
+	vtgate.proto
+
+It has these top-level messages:
+	ShardSession
+	Session
+	KeyRange
+	EntityId
+	ExecuteRequest
+	ExecuteResponse
+	ExecuteShardRequest
+	ExecuteKeyspaceIdsRequest
+	ExecuteKeyRangesRequest
+	ExecuteEntityIdsRequest
+	ExecuteBatchShardRequest
+	ExecuteBatchKeyspaceIdsRequest
+	ExecuteBatchResponse
+	StreamExecuteResponse
+	BeginRequest
+	BeginResponse
+	CommitRequest
+	CommitResponse
+	RollbackRequest
+	RollbackResponse
+	SplitQueryRequest
+	SplitQueryPart
+	SplitQueryResponse
+	ExplainResponse
+*/
+package vtgate
+
+import proto "github.com/golang/protobuf/proto"
+import query "github.com/youtube/vitess/go/vt/proto/query"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+
+// ShardSession is the transaction a session has open on a shard.
+type ShardSession struct {
+	Keyspace      string `protobuf:"bytes,1,opt,name=keyspace" json:"keyspace,omitempty"`
+	Shard         string `protobuf:"bytes,2,opt,name=shard" json:"shard,omitempty"`
+	TabletType    string `protobuf:"bytes,3,opt,name=tablet_type" json:"tablet_type,omitempty"`
+	TransactionId int64  `protobuf:"varint,4,opt,name=transaction_id" json:"transaction_id,omitempty"`
+}
+
+func (m *ShardSession) Reset()         { *m = ShardSession{} }
+func (m *ShardSession) String() string { return proto.CompactTextString(m) }
+func (*ShardSession) ProtoMessage()    {}
+
+// Session is the state of a client session. vtgate returns it with
+// every response, and the client sends it back with the next request.
+type Session struct {
+	InTransaction      bool                      `protobuf:"varint,1,opt,name=in_transaction" json:"in_transaction,omitempty"`
+	ShardSessions      []*ShardSession           `protobuf:"bytes,2,rep,name=shard_sessions" json:"shard_sessions,omitempty"`
+	ReadYourWrites     bool                      `protobuf:"varint,3,opt,name=read_your_writes" json:"read_your_writes,omitempty"`
+	LastWriteTime      int64                     `protobuf:"varint,4,opt,name=last_write_time" json:"last_write_time,omitempty"`
+	MaxReplicationLag  int64                     `protobuf:"varint,5,opt,name=max_replication_lag" json:"max_replication_lag,omitempty"`
+	TransactionOptions *query.TransactionOptions `protobuf:"bytes,6,opt,name=transaction_options" json:"transaction_options,omitempty"`
+	Savepoints         []string                  `protobuf:"bytes,7,rep,name=savepoints" json:"savepoints,omitempty"`
+	LastInsertId       uint64                    `protobuf:"varint,8,opt,name=last_insert_id" json:"last_insert_id,omitempty"`
+	RowCount           int64                     `protobuf:"varint,9,opt,name=row_count" json:"row_count,omitempty"`
+	Charset            string                    `protobuf:"bytes,10,opt,name=charset" json:"charset,omitempty"`
+}
+
+func (m *Session) Reset()         { *m = Session{} }
+func (m *Session) String() string { return proto.CompactTextString(m) }
+func (*Session) ProtoMessage()    {}
+
+func (m *Session) GetShardSessions() []*ShardSession {
+	if m != nil {
+		return m.ShardSessions
+	}
+	return nil
+}
+
+func (m *Session) GetTransactionOptions() *query.TransactionOptions {
+	if m != nil {
+		return m.TransactionOptions
+	}
+	return nil
+}
+
+// KeyRange is a range of keyspace ids, start is inclusive and end
+// exclusive. An empty start or end is unbounded.
+type KeyRange struct {
+	Start []byte `protobuf:"bytes,1,opt,name=start" json:"start,omitempty"`
+	End   []byte `protobuf:"bytes,2,opt,name=end" json:"end,omitempty"`
+}
+
+func (m *KeyRange) Reset()         { *m = KeyRange{} }
+func (m *KeyRange) String() string { return proto.CompactTextString(m) }
+func (*KeyRange) ProtoMessage()    {}
+
+// EntityId maps an entity value to its keyspace id.
+type EntityId struct {
+	ExternalId *query.BindVariable `protobuf:"bytes,1,opt,name=external_id" json:"external_id,omitempty"`
+	KeyspaceId []byte              `protobuf:"bytes,2,opt,name=keyspace_id" json:"keyspace_id,omitempty"`
+}
+
+func (m *EntityId) Reset()         { *m = EntityId{} }
+func (m *EntityId) String() string { return proto.CompactTextString(m) }
+func (*EntityId) ProtoMessage()    {}
+
+func (m *EntityId) GetExternalId() *query.BindVariable {
+	if m != nil {
+		return m.ExternalId
+	}
+	return nil
+}
+
+type ExecuteRequest struct {
+	Query      *query.BoundQuery `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	TabletType string            `protobuf:"bytes,2,opt,name=tablet_type" json:"tablet_type,omitempty"`
+	Session    *Session          `protobuf:"bytes,3,opt,name=session" json:"session,omitempty"`
+}
+
+func (m *ExecuteRequest) Reset()         { *m = ExecuteRequest{} }
+func (m *ExecuteRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecuteRequest) ProtoMessage()    {}
+
+func (m *ExecuteRequest) GetQuery() *query.BoundQuery {
+	if m != nil {
+		return m.Query
+	}
+	return nil
+}
+
+func (m *ExecuteRequest) GetSession() *Session {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+// ExecuteResponse is the response of all the Execute* calls. The
+// session is returned even if the query failed.
+type ExecuteResponse struct {
+	Result  *query.QueryResult `protobuf:"bytes,1,opt,name=result" json:"result,omitempty"`
+	Session *Session           `protobuf:"bytes,2,opt,name=session" json:"session,omitempty"`
+	Error   *query.RPCError    `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ExecuteResponse) Reset()         { *m = ExecuteResponse{} }
+func (m *ExecuteResponse) String() string { return proto.CompactTextString(m) }
+func (*ExecuteResponse) ProtoMessage()    {}
+
+func (m *ExecuteResponse) GetResult() *query.QueryResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (m *ExecuteResponse) GetSession() *Session {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+func (m *ExecuteResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ExecuteShardRequest struct {
+	Query          *query.BoundQuery `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	Keyspace       string            `protobuf:"bytes,2,opt,name=keyspace" json:"keyspace,omitempty"`
+	Shards         []string          `protobuf:"bytes,3,rep,name=shards" json:"shards,omitempty"`
+	TabletType     string            `protobuf:"bytes,4,opt,name=tablet_type" json:"tablet_type,omitempty"`
+	Session        *Session          `protobuf:"bytes,5,opt,name=session" json:"session,omitempty"`
+	FieldsOnly     bool              `protobuf:"varint,6,opt,name=fields_only" json:"fields_only,omitempty"`
+	IdempotencyKey string            `protobuf:"bytes,7,opt,name=idempotency_key" json:"idempotency_key,omitempty"`
+}
+
+func (m *ExecuteShardRequest) Reset()         { *m = ExecuteShardRequest{} }
+func (m *ExecuteShardRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecuteShardRequest) ProtoMessage()    {}
+
+func (m *ExecuteShardRequest) GetQuery() *query.BoundQuery {
+	if m != nil {
+		return m.Query
+	}
+	return nil
+}
+
+func (m *ExecuteShardRequest) GetSession() *Session {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+type ExecuteKeyspaceIdsRequest struct {
+	Query       *query.BoundQuery `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	Keyspace    string            `protobuf:"bytes,2,opt,name=keyspace" json:"keyspace,omitempty"`
+	KeyspaceIds [][]byte          `protobuf:"bytes,3,rep,name=keyspace_ids" json:"keyspace_ids,omitempty"`
+	TabletType  string            `protobuf:"bytes,4,opt,name=tablet_type" json:"tablet_type,omitempty"`
+	Session     *Session          `protobuf:"bytes,5,opt,name=session" json:"session,omitempty"`
+}
+
+func (m *ExecuteKeyspaceIdsRequest) Reset()         { *m = ExecuteKeyspaceIdsRequest{} }
+func (m *ExecuteKeyspaceIdsRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecuteKeyspaceIdsRequest) ProtoMessage()    {}
+
+func (m *ExecuteKeyspaceIdsRequest) GetQuery() *query.BoundQuery {
+	if m != nil {
+		return m.Query
+	}
+	return nil
+}
+
+func (m *ExecuteKeyspaceIdsRequest) GetSession() *Session {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+type ExecuteKeyRangesRequest struct {
+	Query      *query.BoundQuery `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	Keyspace   string            `protobuf:"bytes,2,opt,name=keyspace" json:"keyspace,omitempty"`
+	KeyRanges  []*KeyRange       `protobuf:"bytes,3,rep,name=key_ranges" json:"key_ranges,omitempty"`
+	TabletType string            `protobuf:"bytes,4,opt,name=tablet_type" json:"tablet_type,omitempty"`
+	Session    *Session          `protobuf:"bytes,5,opt,name=session" json:"session,omitempty"`
+}
+
+func (m *ExecuteKeyRangesRequest) Reset()         { *m = ExecuteKeyRangesRequest{} }
+func (m *ExecuteKeyRangesRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecuteKeyRangesRequest) ProtoMessage()    {}
+
+func (m *ExecuteKeyRangesRequest) GetQuery() *query.BoundQuery {
+	if m != nil {
+		return m.Query
+	}
+	return nil
+}
+
+func (m *ExecuteKeyRangesRequest) GetKeyRanges() []*KeyRange {
+	if m != nil {
+		return m.KeyRanges
+	}
+	return nil
+}
+
+func (m *ExecuteKeyRangesRequest) GetSession() *Session {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+type ExecuteEntityIdsRequest struct {
+	Query             *query.BoundQuery `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	Keyspace          string            `protobuf:"bytes,2,opt,name=keyspace" json:"keyspace,omitempty"`
+	EntityColumnName  string            `protobuf:"bytes,3,opt,name=entity_column_name" json:"entity_column_name,omitempty"`
+	EntityKeyspaceIds []*EntityId       `protobuf:"bytes,4,rep,name=entity_keyspace_ids" json:"entity_keyspace_ids,omitempty"`
+	TabletType        string            `protobuf:"bytes,5,opt,name=tablet_type" json:"tablet_type,omitempty"`
+	Session           *Session          `protobuf:"bytes,6,opt,name=session" json:"session,omitempty"`
+}
+
+func (m *ExecuteEntityIdsRequest) Reset()         { *m = ExecuteEntityIdsRequest{} }
+func (m *ExecuteEntityIdsRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecuteEntityIdsRequest) ProtoMessage()    {}
+
+func (m *ExecuteEntityIdsRequest) GetQuery() *query.BoundQuery {
+	if m != nil {
+		return m.Query
+	}
+	return nil
+}
+
+func (m *ExecuteEntityIdsRequest) GetEntityKeyspaceIds() []*EntityId {
+	if m != nil {
+		return m.EntityKeyspaceIds
+	}
+	return nil
+}
+
+func (m *ExecuteEntityIdsRequest) GetSession() *Session {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+type ExecuteBatchShardRequest struct {
+	Queries    []*query.BoundQuery `protobuf:"bytes,1,rep,name=queries" json:"queries,omitempty"`
+	Keyspace   string              `protobuf:"bytes,2,opt,name=keyspace" json:"keyspace,omitempty"`
+	Shards     []string            `protobuf:"bytes,3,rep,name=shards" json:"shards,omitempty"`
+	TabletType string              `protobuf:"bytes,4,opt,name=tablet_type" json:"tablet_type,omitempty"`
+	Session    *Session            `protobuf:"bytes,5,opt,name=session" json:"session,omitempty"`
+}
+
+func (m *ExecuteBatchShardRequest) Reset()         { *m = ExecuteBatchShardRequest{} }
+func (m *ExecuteBatchShardRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecuteBatchShardRequest) ProtoMessage()    {}
+
+func (m *ExecuteBatchShardRequest) GetQueries() []*query.BoundQuery {
+	if m != nil {
+		return m.Queries
+	}
+	return nil
+}
+
+func (m *ExecuteBatchShardRequest) GetSession() *Session {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+type ExecuteBatchKeyspaceIdsRequest struct {
+	Queries     []*query.BoundQuery `protobuf:"bytes,1,rep,name=queries" json:"queries,omitempty"`
+	Keyspace    string              `protobuf:"bytes,2,opt,name=keyspace" json:"keyspace,omitempty"`
+	KeyspaceIds [][]byte            `protobuf:"bytes,3,rep,name=keyspace_ids" json:"keyspace_ids,omitempty"`
+	TabletType  string              `protobuf:"bytes,4,opt,name=tablet_type" json:"tablet_type,omitempty"`
+	Session     *Session            `protobuf:"bytes,5,opt,name=session" json:"session,omitempty"`
+}
+
+func (m *ExecuteBatchKeyspaceIdsRequest) Reset()         { *m = ExecuteBatchKeyspaceIdsRequest{} }
+func (m *ExecuteBatchKeyspaceIdsRequest) String() string { return proto.CompactTextString(m) }
+func (*ExecuteBatchKeyspaceIdsRequest) ProtoMessage()    {}
+
+func (m *ExecuteBatchKeyspaceIdsRequest) GetQueries() []*query.BoundQuery {
+	if m != nil {
+		return m.Queries
+	}
+	return nil
+}
+
+func (m *ExecuteBatchKeyspaceIdsRequest) GetSession() *Session {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+// ExecuteBatchResponse is the response of the ExecuteBatch* calls.
+type ExecuteBatchResponse struct {
+	Results []*query.QueryResult `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
+	Session *Session             `protobuf:"bytes,2,opt,name=session" json:"session,omitempty"`
+	Error   *query.RPCError      `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ExecuteBatchResponse) Reset()         { *m = ExecuteBatchResponse{} }
+func (m *ExecuteBatchResponse) String() string { return proto.CompactTextString(m) }
+func (*ExecuteBatchResponse) ProtoMessage()    {}
+
+func (m *ExecuteBatchResponse) GetResults() []*query.QueryResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+func (m *ExecuteBatchResponse) GetSession() *Session {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+func (m *ExecuteBatchResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+// StreamExecuteResponse is streamed by the StreamExecute* calls. If
+// the query fails, the last response only has the error.
+type StreamExecuteResponse struct {
+	Result *query.QueryResult `protobuf:"bytes,1,opt,name=result" json:"result,omitempty"`
+	Error  *query.RPCError    `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *StreamExecuteResponse) Reset()         { *m = StreamExecuteResponse{} }
+func (m *StreamExecuteResponse) String() string { return proto.CompactTextString(m) }
+func (*StreamExecuteResponse) ProtoMessage()    {}
+
+func (m *StreamExecuteResponse) GetResult() *query.QueryResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (m *StreamExecuteResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type BeginRequest struct {
+}
+
+func (m *BeginRequest) Reset()         { *m = BeginRequest{} }
+func (m *BeginRequest) String() string { return proto.CompactTextString(m) }
+func (*BeginRequest) ProtoMessage()    {}
+
+type BeginResponse struct {
+	Session *Session        `protobuf:"bytes,1,opt,name=session" json:"session,omitempty"`
+	Error   *query.RPCError `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *BeginResponse) Reset()         { *m = BeginResponse{} }
+func (m *BeginResponse) String() string { return proto.CompactTextString(m) }
+func (*BeginResponse) ProtoMessage()    {}
+
+func (m *BeginResponse) GetSession() *Session {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+func (m *BeginResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type CommitRequest struct {
+	Session *Session `protobuf:"bytes,1,opt,name=session" json:"session,omitempty"`
+}
+
+func (m *CommitRequest) Reset()         { *m = CommitRequest{} }
+func (m *CommitRequest) String() string { return proto.CompactTextString(m) }
+func (*CommitRequest) ProtoMessage()    {}
+
+func (m *CommitRequest) GetSession() *Session {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+type CommitResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *CommitResponse) Reset()         { *m = CommitResponse{} }
+func (m *CommitResponse) String() string { return proto.CompactTextString(m) }
+func (*CommitResponse) ProtoMessage()    {}
+
+func (m *CommitResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type RollbackRequest struct {
+	Session *Session `protobuf:"bytes,1,opt,name=session" json:"session,omitempty"`
+}
+
+func (m *RollbackRequest) Reset()         { *m = RollbackRequest{} }
+func (m *RollbackRequest) String() string { return proto.CompactTextString(m) }
+func (*RollbackRequest) ProtoMessage()    {}
+
+func (m *RollbackRequest) GetSession() *Session {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+type RollbackResponse struct {
+	Error *query.RPCError `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *RollbackResponse) Reset()         { *m = RollbackResponse{} }
+func (m *RollbackResponse) String() string { return proto.CompactTextString(m) }
+func (*RollbackResponse) ProtoMessage()    {}
+
+func (m *RollbackResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type SplitQueryRequest struct {
+	Keyspace   string            `protobuf:"bytes,1,opt,name=keyspace" json:"keyspace,omitempty"`
+	Query      *query.BoundQuery `protobuf:"bytes,2,opt,name=query" json:"query,omitempty"`
+	SplitCount int64             `protobuf:"varint,3,opt,name=split_count" json:"split_count,omitempty"`
+}
+
+func (m *SplitQueryRequest) Reset()         { *m = SplitQueryRequest{} }
+func (m *SplitQueryRequest) String() string { return proto.CompactTextString(m) }
+func (*SplitQueryRequest) ProtoMessage()    {}
+
+func (m *SplitQueryRequest) GetQuery() *query.BoundQuery {
+	if m != nil {
+		return m.Query
+	}
+	return nil
+}
+
+// SplitQueryPart is a part of the query of a SplitQueryRequest, to
+// run on the key ranges. size is only approximate.
+type SplitQueryPart struct {
+	Query      *query.BoundQuery `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	Keyspace   string            `protobuf:"bytes,2,opt,name=keyspace" json:"keyspace,omitempty"`
+	KeyRanges  []*KeyRange       `protobuf:"bytes,3,rep,name=key_ranges" json:"key_ranges,omitempty"`
+	TabletType string            `protobuf:"bytes,4,opt,name=tablet_type" json:"tablet_type,omitempty"`
+	Size       int64             `protobuf:"varint,5,opt,name=size" json:"size,omitempty"`
+}
+
+func (m *SplitQueryPart) Reset()         { *m = SplitQueryPart{} }
+func (m *SplitQueryPart) String() string { return proto.CompactTextString(m) }
+func (*SplitQueryPart) ProtoMessage()    {}
+
+func (m *SplitQueryPart) GetQuery() *query.BoundQuery {
+	if m != nil {
+		return m.Query
+	}
+	return nil
+}
+
+func (m *SplitQueryPart) GetKeyRanges() []*KeyRange {
+	if m != nil {
+		return m.KeyRanges
+	}
+	return nil
+}
+
+type SplitQueryResponse struct {
+	Splits []*SplitQueryPart `protobuf:"bytes,1,rep,name=splits" json:"splits,omitempty"`
+	Error  *query.RPCError   `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SplitQueryResponse) Reset()         { *m = SplitQueryResponse{} }
+func (m *SplitQueryResponse) String() string { return proto.CompactTextString(m) }
+func (*SplitQueryResponse) ProtoMessage()    {}
+
+func (m *SplitQueryResponse) GetSplits() []*SplitQueryPart {
+	if m != nil {
+		return m.Splits
+	}
+	return nil
+}
+
+func (m *SplitQueryResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+// ExplainResponse has the routing of the query, and in tablet_plan
+// the plan of the first shard it's routed to (its error is unused).
+type ExplainResponse struct {
+	PlanType   string                 `protobuf:"bytes,1,opt,name=plan_type" json:"plan_type,omitempty"`
+	Reason     string                 `protobuf:"bytes,2,opt,name=reason" json:"reason,omitempty"`
+	Keyspace   string                 `protobuf:"bytes,3,opt,name=keyspace" json:"keyspace,omitempty"`
+	Table      string                 `protobuf:"bytes,4,opt,name=table" json:"table,omitempty"`
+	Vindex     string                 `protobuf:"bytes,5,opt,name=vindex" json:"vindex,omitempty"`
+	Rewritten  string                 `protobuf:"bytes,6,opt,name=rewritten" json:"rewritten,omitempty"`
+	Shards     []string               `protobuf:"bytes,7,rep,name=shards" json:"shards,omitempty"`
+	TabletPlan *query.ExplainResponse `protobuf:"bytes,8,opt,name=tablet_plan" json:"tablet_plan,omitempty"`
+	Error      *query.RPCError        `protobuf:"bytes,9,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ExplainResponse) Reset()         { *m = ExplainResponse{} }
+func (m *ExplainResponse) String() string { return proto.CompactTextString(m) }
+func (*ExplainResponse) ProtoMessage()    {}
+
+func (m *ExplainResponse) GetTabletPlan() *query.ExplainResponse {
+	if m != nil {
+		return m.TabletPlan
+	}
+	return nil
+}
+
+func (m *ExplainResponse) GetError() *query.RPCError {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+func init() {
+}
+
+// Client API for Vitess service
+
+type VitessClient interface {
+	Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*ExecuteResponse, error)
+	ExecuteShard(ctx context.Context, in *ExecuteShardRequest, opts ...grpc.CallOption) (*ExecuteResponse, error)
+	ExecuteKeyspaceIds(ctx context.Context, in *ExecuteKeyspaceIdsRequest, opts ...grpc.CallOption) (*ExecuteResponse, error)
+	ExecuteKeyRanges(ctx context.Context, in *ExecuteKeyRangesRequest, opts ...grpc.CallOption) (*ExecuteResponse, error)
+	ExecuteEntityIds(ctx context.Context, in *ExecuteEntityIdsRequest, opts ...grpc.CallOption) (*ExecuteResponse, error)
+	ExecuteBatchShard(ctx context.Context, in *ExecuteBatchShardRequest, opts ...grpc.CallOption) (*ExecuteBatchResponse, error)
+	ExecuteBatchKeyspaceIds(ctx context.Context, in *ExecuteBatchKeyspaceIdsRequest, opts ...grpc.CallOption) (*ExecuteBatchResponse, error)
+	StreamExecute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (Vitess_StreamExecuteClient, error)
+	StreamExecuteShard(ctx context.Context, in *ExecuteShardRequest, opts ...grpc.CallOption) (Vitess_StreamExecuteShardClient, error)
+	StreamExecuteKeyspaceIds(ctx context.Context, in *ExecuteKeyspaceIdsRequest, opts ...grpc.CallOption) (Vitess_StreamExecuteKeyspaceIdsClient, error)
+	StreamExecuteKeyRanges(ctx context.Context, in *ExecuteKeyRangesRequest, opts ...grpc.CallOption) (Vitess_StreamExecuteKeyRangesClient, error)
+	Begin(ctx context.Context, in *BeginRequest, opts ...grpc.CallOption) (*BeginResponse, error)
+	Commit(ctx context.Context, in *CommitRequest, opts ...grpc.CallOption) (*CommitResponse, error)
+	Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*RollbackResponse, error)
+	SplitQuery(ctx context.Context, in *SplitQueryRequest, opts ...grpc.CallOption) (*SplitQueryResponse, error)
+	Explain(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*ExplainResponse, error)
+}
+
+type vitessClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewVitessClient(cc *grpc.ClientConn) VitessClient {
+	return &vitessClient{cc}
+}
+
+func (c *vitessClient) Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*ExecuteResponse, error) {
+	out := new(ExecuteResponse)
+	err := grpc.Invoke(ctx, "/vtgate.Vitess/Execute", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vitessClient) ExecuteShard(ctx context.Context, in *ExecuteShardRequest, opts ...grpc.CallOption) (*ExecuteResponse, error) {
+	out := new(ExecuteResponse)
+	err := grpc.Invoke(ctx, "/vtgate.Vitess/ExecuteShard", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vitessClient) ExecuteKeyspaceIds(ctx context.Context, in *ExecuteKeyspaceIdsRequest, opts ...grpc.CallOption) (*ExecuteResponse, error) {
+	out := new(ExecuteResponse)
+	err := grpc.Invoke(ctx, "/vtgate.Vitess/ExecuteKeyspaceIds", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vitessClient) ExecuteKeyRanges(ctx context.Context, in *ExecuteKeyRangesRequest, opts ...grpc.CallOption) (*ExecuteResponse, error) {
+	out := new(ExecuteResponse)
+	err := grpc.Invoke(ctx, "/vtgate.Vitess/ExecuteKeyRanges", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vitessClient) ExecuteEntityIds(ctx context.Context, in *ExecuteEntityIdsRequest, opts ...grpc.CallOption) (*ExecuteResponse, error) {
+	out := new(ExecuteResponse)
+	err := grpc.Invoke(ctx, "/vtgate.Vitess/ExecuteEntityIds", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vitessClient) ExecuteBatchShard(ctx context.Context, in *ExecuteBatchShardRequest, opts ...grpc.CallOption) (*ExecuteBatchResponse, error) {
+	out := new(ExecuteBatchResponse)
+	err := grpc.Invoke(ctx, "/vtgate.Vitess/ExecuteBatchShard", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vitessClient) ExecuteBatchKeyspaceIds(ctx context.Context, in *ExecuteBatchKeyspaceIdsRequest, opts ...grpc.CallOption) (*ExecuteBatchResponse, error) {
+	out := new(ExecuteBatchResponse)
+	err := grpc.Invoke(ctx, "/vtgate.Vitess/ExecuteBatchKeyspaceIds", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vitessClient) StreamExecute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (Vitess_StreamExecuteClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Vitess_serviceDesc.Streams[0], c.cc, "/vtgate.Vitess/StreamExecute", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &vitessStreamExecuteClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Vitess_StreamExecuteClient interface {
+	Recv() (*StreamExecuteResponse, error)
+	grpc.ClientStream
+}
+
+type vitessStreamExecuteClient struct {
+	grpc.ClientStream
+}
+
+func (x *vitessStreamExecuteClient) Recv() (*StreamExecuteResponse, error) {
+	m := new(StreamExecuteResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *vitessClient) StreamExecuteShard(ctx context.Context, in *ExecuteShardRequest, opts ...grpc.CallOption) (Vitess_StreamExecuteShardClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Vitess_serviceDesc.Streams[1], c.cc, "/vtgate.Vitess/StreamExecuteShard", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &vitessStreamExecuteShardClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Vitess_StreamExecuteShardClient interface {
+	Recv() (*StreamExecuteResponse, error)
+	grpc.ClientStream
+}
+
+type vitessStreamExecuteShardClient struct {
+	grpc.ClientStream
+}
+
+func (x *vitessStreamExecuteShardClient) Recv() (*StreamExecuteResponse, error) {
+	m := new(StreamExecuteResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *vitessClient) StreamExecuteKeyspaceIds(ctx context.Context, in *ExecuteKeyspaceIdsRequest, opts ...grpc.CallOption) (Vitess_StreamExecuteKeyspaceIdsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Vitess_serviceDesc.Streams[2], c.cc, "/vtgate.Vitess/StreamExecuteKeyspaceIds", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &vitessStreamExecuteKeyspaceIdsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Vitess_StreamExecuteKeyspaceIdsClient interface {
+	Recv() (*StreamExecuteResponse, error)
+	grpc.ClientStream
+}
+
+type vitessStreamExecuteKeyspaceIdsClient struct {
+	grpc.ClientStream
+}
+
+func (x *vitessStreamExecuteKeyspaceIdsClient) Recv() (*StreamExecuteResponse, error) {
+	m := new(StreamExecuteResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *vitessClient) StreamExecuteKeyRanges(ctx context.Context, in *ExecuteKeyRangesRequest, opts ...grpc.CallOption) (Vitess_StreamExecuteKeyRangesClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Vitess_serviceDesc.Streams[3], c.cc, "/vtgate.Vitess/StreamExecuteKeyRanges", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &vitessStreamExecuteKeyRangesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Vitess_StreamExecuteKeyRangesClient interface {
+	Recv() (*StreamExecuteResponse, error)
+	grpc.ClientStream
+}
+
+type vitessStreamExecuteKeyRangesClient struct {
+	grpc.ClientStream
+}
+
+func (x *vitessStreamExecuteKeyRangesClient) Recv() (*StreamExecuteResponse, error) {
+	m := new(StreamExecuteResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *vitessClient) Begin(ctx context.Context, in *BeginRequest, opts ...grpc.CallOption) (*BeginResponse, error) {
+	out := new(BeginResponse)
+	err := grpc.Invoke(ctx, "/vtgate.Vitess/Begin", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vitessClient) Commit(ctx context.Context, in *CommitRequest, opts ...grpc.CallOption) (*CommitResponse, error) {
+	out := new(CommitResponse)
+	err := grpc.Invoke(ctx, "/vtgate.Vitess/Commit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vitessClient) Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*RollbackResponse, error) {
+	out := new(RollbackResponse)
+	err := grpc.Invoke(ctx, "/vtgate.Vitess/Rollback", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vitessClient) SplitQuery(ctx context.Context, in *SplitQueryRequest, opts ...grpc.CallOption) (*SplitQueryResponse, error) {
+	out := new(SplitQueryResponse)
+	err := grpc.Invoke(ctx, "/vtgate.Vitess/SplitQuery", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *vitessClient) Explain(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (*ExplainResponse, error) {
+	out := new(ExplainResponse)
+	err := grpc.Invoke(ctx, "/vtgate.Vitess/Explain", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Vitess service
+
+type VitessServer interface {
+	Execute(context.Context, *ExecuteRequest) (*ExecuteResponse, error)
+	ExecuteShard(context.Context, *ExecuteShardRequest) (*ExecuteResponse, error)
+	ExecuteKeyspaceIds(context.Context, *ExecuteKeyspaceIdsRequest) (*ExecuteResponse, error)
+	ExecuteKeyRanges(context.Context, *ExecuteKeyRangesRequest) (*ExecuteResponse, error)
+	ExecuteEntityIds(context.Context, *ExecuteEntityIdsRequest) (*ExecuteResponse, error)
+	ExecuteBatchShard(context.Context, *ExecuteBatchShardRequest) (*ExecuteBatchResponse, error)
+	ExecuteBatchKeyspaceIds(context.Context, *ExecuteBatchKeyspaceIdsRequest) (*ExecuteBatchResponse, error)
+	StreamExecute(*ExecuteRequest, Vitess_StreamExecuteServer) error
+	StreamExecuteShard(*ExecuteShardRequest, Vitess_StreamExecuteShardServer) error
+	StreamExecuteKeyspaceIds(*ExecuteKeyspaceIdsRequest, Vitess_StreamExecuteKeyspaceIdsServer) error
+	StreamExecuteKeyRanges(*ExecuteKeyRangesRequest, Vitess_StreamExecuteKeyRangesServer) error
+	Begin(context.Context, *BeginRequest) (*BeginResponse, error)
+	Commit(context.Context, *CommitRequest) (*CommitResponse, error)
+	Rollback(context.Context, *RollbackRequest) (*RollbackResponse, error)
+	SplitQuery(context.Context, *SplitQueryRequest) (*SplitQueryResponse, error)
+	Explain(context.Context, *ExecuteRequest) (*ExplainResponse, error)
+}
+
+func RegisterVitessServer(s *grpc.Server, srv VitessServer) {
+	s.RegisterService(&_Vitess_serviceDesc, srv)
+}
+
+func _Vitess_Execute_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExecuteRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(VitessServer).Execute(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Vitess_ExecuteShard_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExecuteShardRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(VitessServer).ExecuteShard(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Vitess_ExecuteKeyspaceIds_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExecuteKeyspaceIdsRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(VitessServer).ExecuteKeyspaceIds(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Vitess_ExecuteKeyRanges_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExecuteKeyRangesRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(VitessServer).ExecuteKeyRanges(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Vitess_ExecuteEntityIds_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExecuteEntityIdsRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(VitessServer).ExecuteEntityIds(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Vitess_ExecuteBatchShard_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExecuteBatchShardRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(VitessServer).ExecuteBatchShard(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Vitess_ExecuteBatchKeyspaceIds_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExecuteBatchKeyspaceIdsRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(VitessServer).ExecuteBatchKeyspaceIds(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Vitess_StreamExecute_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExecuteRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VitessServer).StreamExecute(m, &vitessStreamExecuteServer{stream})
+}
+
+type Vitess_StreamExecuteServer interface {
+	Send(*StreamExecuteResponse) error
+	grpc.ServerStream
+}
+
+type vitessStreamExecuteServer struct {
+	grpc.ServerStream
+}
+
+func (x *vitessStreamExecuteServer) Send(m *StreamExecuteResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Vitess_StreamExecuteShard_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExecuteShardRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VitessServer).StreamExecuteShard(m, &vitessStreamExecuteShardServer{stream})
+}
+
+type Vitess_StreamExecuteShardServer interface {
+	Send(*StreamExecuteResponse) error
+	grpc.ServerStream
+}
+
+type vitessStreamExecuteShardServer struct {
+	grpc.ServerStream
+}
+
+func (x *vitessStreamExecuteShardServer) Send(m *StreamExecuteResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Vitess_StreamExecuteKeyspaceIds_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExecuteKeyspaceIdsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VitessServer).StreamExecuteKeyspaceIds(m, &vitessStreamExecuteKeyspaceIdsServer{stream})
+}
+
+type Vitess_StreamExecuteKeyspaceIdsServer interface {
+	Send(*StreamExecuteResponse) error
+	grpc.ServerStream
+}
+
+type vitessStreamExecuteKeyspaceIdsServer struct {
+	grpc.ServerStream
+}
+
+func (x *vitessStreamExecuteKeyspaceIdsServer) Send(m *StreamExecuteResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Vitess_StreamExecuteKeyRanges_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExecuteKeyRangesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VitessServer).StreamExecuteKeyRanges(m, &vitessStreamExecuteKeyRangesServer{stream})
+}
+
+type Vitess_StreamExecuteKeyRangesServer interface {
+	Send(*StreamExecuteResponse) error
+	grpc.ServerStream
+}
+
+type vitessStreamExecuteKeyRangesServer struct {
+	grpc.ServerStream
+}
+
+func (x *vitessStreamExecuteKeyRangesServer) Send(m *StreamExecuteResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Vitess_Begin_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(BeginRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(VitessServer).Begin(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Vitess_Commit_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(CommitRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(VitessServer).Commit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Vitess_Rollback_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(RollbackRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(VitessServer).Rollback(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Vitess_SplitQuery_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(SplitQueryRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(VitessServer).SplitQuery(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Vitess_Explain_Handler(srv interface{}, ctx context.Context, codec grpc.Codec, buf []byte) (interface{}, error) {
+	in := new(ExecuteRequest)
+	if err := codec.Unmarshal(buf, in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(VitessServer).Explain(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+var _Vitess_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "vtgate.Vitess",
+	HandlerType: (*VitessServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Execute",
+			Handler:    _Vitess_Execute_Handler,
+		},
+		{
+			MethodName: "ExecuteShard",
+			Handler:    _Vitess_ExecuteShard_Handler,
+		},
+		{
+			MethodName: "ExecuteKeyspaceIds",
+			Handler:    _Vitess_ExecuteKeyspaceIds_Handler,
+		},
+		{
+			MethodName: "ExecuteKeyRanges",
+			Handler:    _Vitess_ExecuteKeyRanges_Handler,
+		},
+		{
+			MethodName: "ExecuteEntityIds",
+			Handler:    _Vitess_ExecuteEntityIds_Handler,
+		},
+		{
+			MethodName: "ExecuteBatchShard",
+			Handler:    _Vitess_ExecuteBatchShard_Handler,
+		},
+		{
+			MethodName: "ExecuteBatchKeyspaceIds",
+			Handler:    _Vitess_ExecuteBatchKeyspaceIds_Handler,
+		},
+		{
+			MethodName: "Begin",
+			Handler:    _Vitess_Begin_Handler,
+		},
+		{
+			MethodName: "Commit",
+			Handler:    _Vitess_Commit_Handler,
+		},
+		{
+			MethodName: "Rollback",
+			Handler:    _Vitess_Rollback_Handler,
+		},
+		{
+			MethodName: "SplitQuery",
+			Handler:    _Vitess_SplitQuery_Handler,
+		},
+		{
+			MethodName: "Explain",
+			Handler:    _Vitess_Explain_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamExecute",
+			Handler:       _Vitess_StreamExecute_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamExecuteShard",
+			Handler:       _Vitess_StreamExecuteShard_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamExecuteKeyspaceIds",
+			Handler:       _Vitess_StreamExecuteKeyspaceIds_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamExecuteKeyRanges",
+			Handler:       _Vitess_StreamExecuteKeyRanges_Handler,
+			ServerStreams: true,
+		},
+	},
+}
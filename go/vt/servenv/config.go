@@ -0,0 +1,235 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package servenv
+
+// This file handles the config file. It is a JSON object that maps
+// flag names to their values, for instance:
+//
+//   {
+//     "queryserver-config-pool-size": 32,
+//     "queryserver-config-query-timeout": 15,
+//     "filecustomrules": "/vt/config/rules.json"
+//   }
+//
+// It is read by Init, after the command line is parsed. The flags
+// passed on the command line take precedence over the file. An
+// unknown flag, or an invalid value, is fatal.
+//
+// The file is read again on SIGHUP, or with /debug/config?reload=1.
+// Only the flags registered with OnConfigReload are changed then, the
+// others need a restart.
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/acl"
+)
+
+var configFile = flag.String("config_file", "", "if set, JSON file with flag values, read at startup and on SIGHUP. The command line flags take precedence")
+
+var (
+	// configMu protects all the following variables, and serializes
+	// the reloads.
+	configMu sync.Mutex
+	// commandLineFlags are the flags set on the command line.
+	commandLineFlags map[string]bool
+	// reloadHooks has the hooks of the flags that can be changed
+	// at runtime, by flag name.
+	reloadHooks = make(map[string]func() error)
+)
+
+func init() {
+	onInit(func() {
+		if *configFile == "" {
+			return
+		}
+		http.HandleFunc("/debug/config", configHandler)
+
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGHUP)
+		go func() {
+			for range c {
+				log.Infof("SIGHUP received, reloading %v", *configFile)
+				if _, err := ReloadConfig(); err != nil {
+					log.Errorf("Config reload failed: %v", err)
+				}
+			}
+		}()
+	})
+}
+
+// OnConfigReload declares that the named flag can be changed at
+// runtime. When a reload of the config file changes the flag, f is
+// called to apply its new value. If f returns an error, the flag
+// gets its previous value back, so f should check the new value
+// before applying it.
+func OnConfigReload(name string, f func() error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	reloadHooks[name] = f
+}
+
+// readConfigFile returns the flag values of the config file, as
+// strings.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("cannot parse %v: %v", path, err)
+	}
+	result := make(map[string]string, len(values))
+	for name, value := range values {
+		if flag.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown flag %v in %v", name, path)
+		}
+		switch v := value.(type) {
+		case string:
+			result[name] = v
+		case float64:
+			result[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			result[name] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("invalid value for flag %v in %v: %v", name, path, value)
+		}
+	}
+	return result, nil
+}
+
+// loadConfigFile applies the config file at startup.
+func loadConfigFile() error {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	commandLineFlags = make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		commandLineFlags[f.Name] = true
+	})
+	if *configFile == "" {
+		return nil
+	}
+
+	values, err := readConfigFile(*configFile)
+	if err != nil {
+		return err
+	}
+	for name, value := range values {
+		if commandLineFlags[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value for flag %v in %v: %v", name, *configFile, err)
+		}
+	}
+	log.Infof("Config loaded from %v", *configFile)
+	return nil
+}
+
+// ReloadConfig reads the config file again, and applies the flags
+// that can be changed at runtime. It returns the names of the flags
+// that were changed. If the file cannot be read, or has unknown
+// flags, nothing is changed.
+func ReloadConfig() ([]string, error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	if *configFile == "" {
+		return nil, fmt.Errorf("no config file")
+	}
+	values, err := readConfigFile(*configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	var errs []string
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := values[name]
+		f := flag.Lookup(name)
+		old := f.Value.String()
+		if old == value {
+			continue
+		}
+		if commandLineFlags[name] {
+			log.Warningf("Flag %v was set on the command line, ignoring its new value %q", name, value)
+			continue
+		}
+		hook, ok := reloadHooks[name]
+		if !ok {
+			log.Warningf("Flag %v cannot be changed at runtime, ignoring its new value %q until the next restart", name, value)
+			continue
+		}
+		if err := f.Value.Set(value); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid value for flag %v: %v", name, err))
+			continue
+		}
+		if err := hook(); err != nil {
+			f.Value.Set(old)
+			errs = append(errs, fmt.Sprintf("cannot change flag %v to %q: %v", name, value, err))
+			continue
+		}
+		log.Infof("Flag %v changed from %q to %q", name, old, value)
+		changed = append(changed, name)
+	}
+	if len(errs) > 0 {
+		return changed, fmt.Errorf("%v", errs)
+	}
+	return changed, nil
+}
+
+// configHandler displays the flags that can be changed at runtime.
+// With the reload parameter, the config file is reloaded first.
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	if r.FormValue("reload") != "" {
+		if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		changed, err := ReloadConfig()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("config reload failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "changed: %v\n\n", changed)
+	}
+
+	configMu.Lock()
+	names := make([]string, 0, len(reloadHooks))
+	for name := range reloadHooks {
+		names = append(names, name)
+	}
+	configMu.Unlock()
+	sort.Strings(names)
+	fmt.Fprintf(w, "config file: %v\n", *configFile)
+	for _, name := range names {
+		if f := flag.Lookup(name); f != nil {
+			fmt.Fprintf(w, "%v=%v\n", name, f.Value.String())
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package servenv
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+var (
+	testReloadable = flag.Int("config_test_reloadable", 1, "test flag that can be reloaded")
+	testStatic     = flag.String("config_test_static", "a", "test flag that cannot be reloaded")
+)
+
+func writeTestConfig(t *testing.T, content string) string {
+	p := path.Join(os.TempDir(), "servenv_config_test.json")
+	if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	*configFile = p
+	return p
+}
+
+func TestConfigFile(t *testing.T) {
+	defer func() { *configFile = "" }()
+	p := writeTestConfig(t, `{"config_test_reloadable": 10, "config_test_static": "b"}`)
+	defer os.Remove(p)
+
+	if err := loadConfigFile(); err != nil {
+		t.Fatalf("loadConfigFile failed: %v", err)
+	}
+	if *testReloadable != 10 || *testStatic != "b" {
+		t.Errorf("flags after load = %v, %v, want 10, b", *testReloadable, *testStatic)
+	}
+
+	applied := 0
+	OnConfigReload("config_test_reloadable", func() error {
+		if *testReloadable > 100 {
+			return fmt.Errorf("too big")
+		}
+		applied = *testReloadable
+		return nil
+	})
+
+	// only the reloadable flag changes
+	writeTestConfig(t, `{"config_test_reloadable": 20, "config_test_static": "c"}`)
+	changed, err := ReloadConfig()
+	if err != nil {
+		t.Fatalf("ReloadConfig failed: %v", err)
+	}
+	if want := []string{"config_test_reloadable"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+	if applied != 20 || *testStatic != "b" {
+		t.Errorf("after reload: applied = %v, static = %v, want 20, b", applied, *testStatic)
+	}
+
+	// a rejected value is rolled back
+	writeTestConfig(t, `{"config_test_reloadable": 200}`)
+	if _, err := ReloadConfig(); err == nil {
+		t.Errorf("ReloadConfig should have failed")
+	}
+	if *testReloadable != 20 || applied != 20 {
+		t.Errorf("after failed reload: flag = %v, applied = %v, want 20, 20", *testReloadable, applied)
+	}
+
+	// an unknown flag is an error, nothing changes
+	writeTestConfig(t, `{"config_test_reloadable": 30, "config_test_unknown": 1}`)
+	if _, err := ReloadConfig(); err == nil {
+		t.Errorf("ReloadConfig should have failed")
+	}
+	if *testReloadable != 20 {
+		t.Errorf("after invalid file: flag = %v, want 20", *testReloadable)
+	}
+}
@@ -7,11 +7,14 @@ package servenv
 import (
 	"flag"
 	"fmt"
-	"net"
 
+	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/proc"
+	"github.com/youtube/vitess/go/vt/vttls"
 )
 
 // This file handles gRPC server, on its own port.
@@ -28,6 +31,14 @@ var (
 	// GRPCPort is the port to listen on for gRPC. If not set or zero, don't listen.
 	GRPCPort *int
 
+	// GRPCCert, GRPCKey and GRPCCA are the TLS files for gRPC. If
+	// GRPCCert is set, the server uses TLS. If GRPCCA is set too,
+	// the clients have to present a certificate signed by it, and
+	// its common name is the username of their calls.
+	GRPCCert *string
+	GRPCKey  *string
+	GRPCCA   *string
+
 	// GRPCServer is the global server to serve gRPC.
 	GRPCServer = grpc.NewServer()
 )
@@ -40,18 +51,44 @@ func serveGRPC() {
 
 	// listen on the port
 	log.Infof("Listening for gRPC calls on port %v", *GRPCPort)
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", *GRPCPort))
+	listener, err := proc.InheritOrListen(fmt.Sprintf("%d", *GRPCPort))
 	if err != nil {
 		log.Fatalf("Cannot listen on port %v for gRPC: %v", *GRPCPort, err)
 	}
 
 	// and serve on it
 	go GRPCServer.Serve(listener)
+	// Closing the listener only stops accepting connections, the
+	// in-flight calls go on during the Drain phase. Stop closes
+	// the connections, so it waits for the Drain phase to be done.
+	OnShutdown(ShutdownStopRPC, func(ctx context.Context) {
+		listener.Close()
+	})
+	OnShutdown(ShutdownCheckpoint, func(ctx context.Context) {
+		GRPCServer.Stop()
+	})
 }
 
 // RegisterGRPCFlags registers the right command line flag to enable gRPC
 func RegisterGRPCFlags() {
 	GRPCPort = flag.Int("grpc_port", 0, "Port to listen on for gRPC calls")
+	GRPCCert = flag.String("grpc_cert", "", "certificate to use for gRPC, enables TLS")
+	GRPCKey = flag.String("grpc_key", "", "key to use for gRPC, with -grpc_cert")
+	GRPCCA = flag.String("grpc_ca", "", "ca to verify the gRPC client certificates, with -grpc_cert")
+	onInit(createGRPCServer)
+}
+
+// createGRPCServer replaces GRPCServer with one that uses TLS, if
+// -grpc_cert is set. It runs before the services are registered.
+func createGRPCServer() {
+	if GRPCPort == nil || *GRPCPort == 0 || *GRPCCert == "" {
+		return
+	}
+	config, err := vttls.ServerConfig(*GRPCCert, *GRPCKey, *GRPCCA)
+	if err != nil {
+		log.Fatalf("Failed to load the gRPC TLS files: %v", err)
+	}
+	GRPCServer = grpc.NewServer(grpc.Creds(credentials.NewTLS(config)))
 }
 
 // GRPCCheckServiceMap returns if we should register a gRPC service
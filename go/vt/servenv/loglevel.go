@@ -0,0 +1,89 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package servenv
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/acl"
+	"github.com/youtube/vitess/go/vt/logutil"
+)
+
+// logLevelFlags are the glog flags that can be changed at runtime
+// with /debug/loglevel. 'v' is the global verbosity, and 'vmodule'
+// the per-file verbosity, as a comma-separated list of pattern=N
+// (for instance 'query_engine=2,mysqld*=1').
+// The level of a module logger (see logutil.ModuleLogger) is changed
+// with the 'module' and 'level' parameters, for instance
+// '?module=tabletserver&level=warning'.
+var logLevelFlags = []string{"v", "vmodule"}
+
+func init() {
+	onInit(func() {
+		http.HandleFunc("/debug/loglevel", logLevelHandler)
+	})
+}
+
+// logLevelHandler displays the current log levels. If any of the
+// logLevelFlags or a module level is passed as a URL parameter, it
+// is changed first.
+func logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, name := range logLevelFlags {
+		if _, ok := r.Form[name]; !ok {
+			continue
+		}
+		if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		f := flag.Lookup(name)
+		if f == nil {
+			http.Error(w, fmt.Sprintf("unknown log flag %v", name), http.StatusInternalServerError)
+			return
+		}
+		value := r.FormValue(name)
+		if err := f.Value.Set(value); err != nil {
+			http.Error(w, fmt.Sprintf("invalid value for %v: %v", name, err), http.StatusBadRequest)
+			return
+		}
+		log.Infof("log level flag %v changed to %q", name, value)
+	}
+	if module := r.FormValue("module"); module != "" {
+		if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		level := r.FormValue("level")
+		if err := logutil.SetModuleLevel(module, level); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Infof("log level of module %v changed to %v", module, level)
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	for _, name := range logLevelFlags {
+		if f := flag.Lookup(name); f != nil {
+			fmt.Fprintf(w, "%v=%v\n", name, f.Value.String())
+		}
+	}
+	levels := logutil.ModuleLevels()
+	modules := make([]string, 0, len(levels))
+	for module := range levels {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	for _, module := range modules {
+		fmt.Fprintf(w, "module %v=%v\n", module, levels[module])
+	}
+}
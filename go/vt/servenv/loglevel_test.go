@@ -0,0 +1,60 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package servenv
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+)
+
+func TestLogLevelHandler(t *testing.T) {
+	v := flag.Lookup("v")
+	oldV := v.Value.String()
+	defer v.Value.Set(oldV)
+
+	request, _ := http.NewRequest("GET", "/debug/loglevel?v=3", nil)
+	response := httptest.NewRecorder()
+	logLevelHandler(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("unexpected response code %v: %v", response.Code, response.Body.String())
+	}
+	if !strings.Contains(response.Body.String(), "v=3\n") {
+		t.Errorf("unexpected response: %v", response.Body.String())
+	}
+	if v.Value.String() != "3" {
+		t.Errorf("v was not changed: %v", v.Value.String())
+	}
+
+	request, _ = http.NewRequest("GET", "/debug/loglevel?v=abc", nil)
+	response = httptest.NewRecorder()
+	logLevelHandler(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("invalid value should have failed: %v %v", response.Code, response.Body.String())
+	}
+
+	logutil.NewModuleLogger("testloglevel")
+	defer logutil.SetModuleLevel("testloglevel", "info")
+	request, _ = http.NewRequest("GET", "/debug/loglevel?module=testloglevel&level=error", nil)
+	response = httptest.NewRecorder()
+	logLevelHandler(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("unexpected response code %v: %v", response.Code, response.Body.String())
+	}
+	if !strings.Contains(response.Body.String(), "module testloglevel=error\n") {
+		t.Errorf("unexpected response: %v", response.Body.String())
+	}
+
+	request, _ = http.NewRequest("GET", "/debug/loglevel?module=testloglevel&level=verbose", nil)
+	response = httptest.NewRecorder()
+	logLevelHandler(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("invalid level should have failed: %v %v", response.Code, response.Body.String())
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package servenv
+
+import (
+	"fmt"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/tb"
+)
+
+// panicCounts counts the panics recovered by the servers, by component.
+var panicCounts = stats.NewCounters("PanicsRecovered")
+
+// RecordPanic logs a panic recovered in component, with the stack
+// trace of the goroutine that panicked, and counts it. It has to be
+// called by the deferred function that recovered the panic.
+func RecordPanic(component string, x interface{}) {
+	log.Errorf("Uncaught panic in %v: %v\n%s", component, x, tb.Stack(5))
+	panicCounts.Add(component, 1)
+}
+
+// HandlePanic recovers from a panic in an RPC handler or an action of
+// component, and returns it as an error in *err, so a bad request
+// doesn't take the whole process down. Use it with defer.
+func HandlePanic(component string, err *error) {
+	if x := recover(); x != nil {
+		RecordPanic(component, x)
+		*err = fmt.Errorf("uncaught panic in %v: %v", component, x)
+	}
+}
+
+// LogPanic recovers from a panic in a background goroutine of
+// component, and logs it. Use it with defer.
+func LogPanic(component string) {
+	if x := recover(); x != nil {
+		RecordPanic(component, x)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package servenv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandlePanic(t *testing.T) {
+	before := panicCounts.Counts()["test"]
+	f := func() (err error) {
+		defer HandlePanic("test", &err)
+		var m map[string]int
+		m["a"] = 1
+		return nil
+	}
+	err := f()
+	if err == nil || !strings.Contains(err.Error(), "uncaught panic in test") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer LogPanic("test")
+		panic("background")
+	}()
+	<-done
+
+	if got := panicCounts.Counts()["test"] - before; got != 2 {
+		t.Errorf("got %v recorded panics, want 2", got)
+	}
+}
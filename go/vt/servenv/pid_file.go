@@ -7,7 +7,10 @@ package servenv
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 
 	log "github.com/golang/glog"
 )
@@ -30,12 +33,22 @@ func init() {
 		file.Close()
 	})
 
-	// Remove pid file on graceful shutdown.
+	// Remove pid file on graceful shutdown, unless the process
+	// that took over our listeners already replaced it.
 	OnClose(func() {
 		if *pidFile == "" {
 			return
 		}
 
+		data, err := ioutil.ReadFile(*pidFile)
+		if err != nil {
+			log.Errorf("Unable to read pid file '%s': %v", *pidFile, err)
+			return
+		}
+		if strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+			log.Infof("Pid file '%s' belongs to another process, leaving it", *pidFile)
+			return
+		}
 		if err := os.Remove(*pidFile); err != nil {
 			log.Errorf("Unable to remove pid file '%s': %v", *pidFile, err)
 		}
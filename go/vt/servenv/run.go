@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"syscall"
 	"time"
 
 	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/event"
 	"github.com/youtube/vitess/go/proc"
+	"golang.org/x/net/context"
 )
 
 var (
@@ -17,6 +19,8 @@ var (
 
 // Run starts listening for RPC and HTTP requests,
 // and blocks until it the process gets a signal.
+// It then runs the OnTerm and OnTermSync hooks, waits for the
+// lameduck period, and runs the OnShutdown and OnClose hooks.
 func Run(port int) {
 	populateListeningURL()
 	onRunHooks.Fire()
@@ -28,10 +32,14 @@ func Run(port int) {
 		log.Fatal(err)
 	}
 	go http.Serve(l, nil)
+	OnShutdown(ShutdownStopRPC, func(ctx context.Context) {
+		l.Close()
+	})
 
-	proc.Wait()
-	l.Close()
+	sig := proc.Wait()
 
+	// We keep serving during the lameduck period, so the clients
+	// have time to notice we're going away.
 	startTime := time.Now()
 	log.Infof("Entering lameduck mode for at least %v", *lameduckPeriod)
 	log.Infof("Firing asynchronous OnTerm hooks")
@@ -44,6 +52,7 @@ func Run(port int) {
 	}
 
 	log.Info("Shutting down gracefully")
+	fireShutdownHooks(*shutdownDrainTimeout, *shutdownTimeout, sig == syscall.SIGUSR2)
 	Close()
 }
 
@@ -6,10 +6,9 @@ package servenv
 
 import (
 	"crypto/tls"
-	"crypto/x509"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"net"
 	"net/http"
 
 	log "github.com/golang/glog"
@@ -17,6 +16,7 @@ import (
 	"github.com/youtube/vitess/go/rpcplus"
 	"github.com/youtube/vitess/go/rpcwrap"
 	"github.com/youtube/vitess/go/rpcwrap/bsonrpc"
+	"github.com/youtube/vitess/go/vt/vttls"
 )
 
 var (
@@ -63,38 +63,20 @@ func ServeSecurePort(securePort int, certFile, keyFile, caCertFile string) {
 		return
 	}
 
-	config := tls.Config{}
-
-	// load the server cert / key
-	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	config, err := vttls.ServerConfig(certFile, keyFile, caCertFile)
 	if err != nil {
-		log.Fatalf("SecureServe.LoadX509KeyPair(%v, %v) failed: %v", certFile, keyFile, err)
-	}
-	config.Certificates = []tls.Certificate{cert}
-
-	// load the ca if necessary
-	// FIXME(alainjobart) this doesn't quite work yet, have
-	// to investigate
-	if caCertFile != "" {
-		config.ClientCAs = x509.NewCertPool()
-
-		pemCerts, err := ioutil.ReadFile(caCertFile)
-		if err != nil {
-			log.Fatalf("SecureServe: cannot read ca file %v: %v", caCertFile, err)
-		}
-		if !config.ClientCAs.AppendCertsFromPEM(pemCerts) {
-			log.Fatalf("SecureServe: AppendCertsFromPEM failed: %v", err)
-		}
-
-		config.ClientAuth = tls.RequireAndVerifyClientCert
+		log.Fatalf("SecureServe: %v", err)
 	}
-	l, err := tls.Listen("tcp", fmt.Sprintf(":%d", securePort), &config)
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", securePort))
 	if err != nil {
 		log.Fatalf("Error listening on secure port %v: %v", securePort, err)
 	}
 	log.Infof("Listening on secure port %v", securePort)
 	throttled := NewThrottledListener(l, *secureThrottle, *secureMaxBuffer)
 	cl := proc.Published(throttled, "SecureConnections", "SecureAccepts")
+	// TLS is the outermost layer, so the http server sees the TLS
+	// state, and the client certificate can identify the caller.
+	tl := tls.NewListener(cl, config)
 
 	// rpc.HandleHTTP registers the default GOB handler at /_goRPC_
 	// and the debug RPC service at /debug/rpc (it displays a list
@@ -114,7 +96,7 @@ func ServeSecurePort(securePort int, certFile, keyFile, caCertFile string) {
 	httpServer := http.Server{
 		Handler: handler,
 	}
-	go httpServer.Serve(cl)
+	go httpServer.Serve(tl)
 }
 
 // RegisterDefaultSecureFlags registers the default flags for
@@ -125,7 +107,7 @@ func RegisterDefaultSecureFlags() {
 	SecurePort = flag.Int("secure-port", 0, "port for the secure server")
 	CertFile = flag.String("cert", "", "cert file")
 	KeyFile = flag.String("key", "", "key file")
-	CACertFile = flag.String("ca_cert", "", "ca cert file")
+	CACertFile = flag.String("ca_cert", "", "ca cert file, if set clients need a cert signed by it to connect to the secure port")
 	OnRun(func() {
 		ServeSecurePort(*SecurePort, *CertFile, *KeyFile, *CACertFile)
 	})
@@ -40,7 +40,7 @@ var (
 	Port *int
 
 	// Flags to alter the behavior of the library.
-	lameduckPeriod = flag.Duration("lameduck-period", 50*time.Millisecond, "keep running at least this long after SIGTERM before stopping")
+	lameduckPeriod = flag.Duration("lameduck-period", 50*time.Millisecond, "keep running and serving at least this long after SIGTERM before stopping, so the clients can move away")
 	onTermTimeout  = flag.Duration("onterm_timeout", 10*time.Second, "wait no more than this for OnTermSync handlers before stopping")
 	memProfileRate = flag.Int("mem-profile-rate", 512*1024, "profile every n bytes allocated")
 
@@ -65,6 +65,11 @@ func Init() {
 	}
 	inited = true
 
+	// The config file can change any of the flags below.
+	if err := loadConfigFile(); err != nil {
+		log.Fatalf("servenv.Init: %v", err)
+	}
+
 	// Once you run as root, you pretty much destroy the chances of a
 	// non-privileged user starting the program correctly.
 	if uid := os.Getuid(); uid == 0 {
@@ -5,9 +5,13 @@
 package servenv
 
 import (
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 
+	"golang.org/x/net/context"
+
 	"github.com/youtube/vitess/go/event"
 )
 
@@ -45,3 +49,72 @@ func TestFireOnTermSyncHooksTimeout(t *testing.T) {
 		t.Errorf("finished = %v, want %v", finished, want)
 	}
 }
+
+func TestFireShutdownHooksOrder(t *testing.T) {
+	shutdownHooks = [shutdownPhaseCount][]func(context.Context){}
+
+	var mu sync.Mutex
+	var phases []ShutdownPhase
+	record := func(phase ShutdownPhase) func(context.Context) {
+		return func(ctx context.Context) {
+			mu.Lock()
+			phases = append(phases, phase)
+			mu.Unlock()
+		}
+	}
+	OnShutdown(ShutdownDeregister, record(ShutdownDeregister))
+	OnShutdown(ShutdownCheckpoint, record(ShutdownCheckpoint))
+	OnShutdown(ShutdownDrain, record(ShutdownDrain))
+	OnShutdown(ShutdownStopRPC, record(ShutdownStopRPC))
+
+	if finished, want := fireShutdownHooks(1*time.Second, 1*time.Second, false), true; finished != want {
+		t.Errorf("finished = %v, want %v", finished, want)
+	}
+	want := []ShutdownPhase{ShutdownStopRPC, ShutdownDrain, ShutdownCheckpoint, ShutdownDeregister}
+	if !reflect.DeepEqual(phases, want) {
+		t.Errorf("phases = %v, want %v", phases, want)
+	}
+}
+
+func TestFireShutdownHooksDrainTimeout(t *testing.T) {
+	shutdownHooks = [shutdownPhaseCount][]func(context.Context){}
+
+	OnShutdown(ShutdownDrain, func(ctx context.Context) {
+		<-ctx.Done()
+	})
+	deregistered := make(chan struct{})
+	OnShutdown(ShutdownDeregister, func(ctx context.Context) {
+		close(deregistered)
+	})
+
+	// the drain times out, but the next phases still run
+	if finished, want := fireShutdownHooks(1*time.Millisecond, 1*time.Second, false), false; finished != want {
+		t.Errorf("finished = %v, want %v", finished, want)
+	}
+	select {
+	case <-deregistered:
+	default:
+		t.Errorf("ShutdownDeregister hook was not run")
+	}
+}
+
+func TestFireShutdownHooksHandover(t *testing.T) {
+	shutdownHooks = [shutdownPhaseCount][]func(context.Context){}
+
+	drained := make(chan struct{})
+	OnShutdown(ShutdownDrain, func(ctx context.Context) {
+		close(drained)
+	})
+	OnShutdown(ShutdownDeregister, func(ctx context.Context) {
+		t.Errorf("ShutdownDeregister hook was run after a handover")
+	})
+
+	if finished, want := fireShutdownHooks(1*time.Second, 1*time.Second, true), true; finished != want {
+		t.Errorf("finished = %v, want %v", finished, want)
+	}
+	select {
+	case <-drained:
+	default:
+		t.Errorf("ShutdownDrain hook was not run")
+	}
+}
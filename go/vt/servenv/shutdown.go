@@ -0,0 +1,140 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package servenv
+
+import (
+	"flag"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+var (
+	shutdownDrainTimeout = flag.Duration("shutdown_drain_timeout", 30*time.Second, "wait no more than this for the ShutdownDrain hooks (in-flight queries to finish) when stopping")
+	shutdownTimeout      = flag.Duration("shutdown_timeout", 10*time.Second, "wait no more than this for the hooks of each of the other shutdown phases when stopping")
+)
+
+// ShutdownPhase is one of the ordered phases of a graceful shutdown.
+// They run after the lameduck period, one after the other.
+type ShutdownPhase int
+
+const (
+	// ShutdownStopRPC stops accepting new RPCs. servenv closes its
+	// own listeners in this phase, but not the connections: the
+	// in-flight RPCs go on.
+	ShutdownStopRPC ShutdownPhase = iota
+
+	// ShutdownDrain waits for the in-flight queries to finish.
+	// Its hooks get -shutdown_drain_timeout.
+	ShutdownDrain
+
+	// ShutdownCheckpoint stops the background work that keeps
+	// state, like binlog players, so it can resume on restart.
+	// servenv closes the remaining gRPC connections in this phase.
+	ShutdownCheckpoint
+
+	// ShutdownDeregister removes the process from the topology.
+	// It is skipped after a SIGUSR2 handover, since the new process
+	// took over the listeners and the place in the topology.
+	ShutdownDeregister
+
+	shutdownPhaseCount
+)
+
+var shutdownPhaseNames = []string{
+	"StopRPC",
+	"Drain",
+	"Checkpoint",
+	"Deregister",
+}
+
+func (p ShutdownPhase) String() string {
+	if p < 0 || p >= shutdownPhaseCount {
+		return "Unknown"
+	}
+	return shutdownPhaseNames[p]
+}
+
+var (
+	shutdownMu    sync.Mutex
+	shutdownHooks [shutdownPhaseCount][]func(context.Context)
+)
+
+// OnShutdown registers f to be run in the given phase of the
+// shutdown, after the lameduck period and before the OnClose hooks.
+//
+// The hooks of a phase are run in parallel, and the next phase
+// starts when they are all done, or when the context they are given
+// expires. A hook should return when its context is done.
+func OnShutdown(phase ShutdownPhase, f func(ctx context.Context)) {
+	if phase < 0 || phase >= shutdownPhaseCount {
+		log.Fatalf("servenv.OnShutdown: invalid phase %v", phase)
+	}
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	shutdownHooks[phase] = append(shutdownHooks[phase], f)
+}
+
+// fireShutdownHooks runs all the phases in order, except
+// ShutdownDeregister if the process handed its listeners over.
+// It returns true iff all the hooks that ran finished in time.
+func fireShutdownHooks(drainTimeout, timeout time.Duration, handover bool) bool {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+
+	finished := true
+	for phase := ShutdownPhase(0); phase < shutdownPhaseCount; phase++ {
+		hooks := shutdownHooks[phase]
+		if len(hooks) == 0 {
+			continue
+		}
+		if phase == ShutdownDeregister && handover {
+			log.Infof("Skipping %v shutdown hooks, the listeners were handed over", phase)
+			continue
+		}
+		t := timeout
+		if phase == ShutdownDrain {
+			t = drainTimeout
+		}
+		if !fireShutdownPhase(phase, hooks, t) {
+			finished = false
+		}
+	}
+	return finished
+}
+
+// fireShutdownPhase runs the hooks of one phase in parallel, and
+// returns true iff they all finish before the timeout.
+func fireShutdownPhase(phase ShutdownPhase, hooks []func(context.Context), timeout time.Duration) bool {
+	log.Infof("Running %v shutdown hooks and waiting up to %v for them", phase, timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	wg := sync.WaitGroup{}
+	for _, f := range hooks {
+		wg.Add(1)
+		go func(f func(context.Context)) {
+			defer wg.Done()
+			defer LogPanic("shutdown")
+			f(ctx)
+		}(f)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Infof("%v shutdown hooks finished", phase)
+		return true
+	case <-ctx.Done():
+		log.Warningf("%v shutdown hooks timed out", phase)
+		return false
+	}
+}
@@ -2,6 +2,7 @@ package servenv
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"html"
 	"html/template"
@@ -82,11 +83,13 @@ text-align: right;
 <div>
 <div class=lefthand>
 Started: {{.StartTime}}<br>
+{{if .Build.Time}}Built: {{.Build.Time}} by {{.Build.User}}@{{.Build.Host}} from {{.Build.GitRev}}<br>{{end}}
 </div>
 <div class=righthand>
 Running on {{.Hostname}}<br>
 View <a href=/debug/vars>variables</a>,
      <a href=/debug/pprof>debugging profiles</a>,
+     <a href="/debug/status?format=json">status as JSON</a>
 </div>
 </div>`
 
@@ -147,6 +150,24 @@ func AddStatusSection(banner string, f func() string) {
 	AddStatusPart(banner, `{{.}}`, func() interface{} { return f() })
 }
 
+// buildInfo describes how the binary was built.
+type buildInfo struct {
+	Host   string
+	User   string
+	Time   string
+	GitRev string
+}
+
+// statusJSON is the status page as JSON: the values of the sections
+// are the data their templates are rendered with, by banner.
+type statusJSON struct {
+	BinaryName string
+	Hostname   string
+	StartTime  string
+	Build      buildInfo
+	Sections   map[string]json.RawMessage
+}
+
 func statusHandler(w http.ResponseWriter, r *http.Request) {
 	if err := acl.CheckAccessHTTP(r, acl.DEBUGGING); err != nil {
 		acl.SendError(w, err)
@@ -155,16 +176,29 @@ func statusHandler(w http.ResponseWriter, r *http.Request) {
 	statusMu.Lock()
 	defer statusMu.Unlock()
 
+	build := buildInfo{
+		Host:   buildHost,
+		User:   buildUser,
+		Time:   buildTime,
+		GitRev: buildGitRev,
+	}
+	if r.FormValue("format") == "json" {
+		writeStatusJSON(w, build)
+		return
+	}
+
 	data := struct {
 		Sections   []section
 		BinaryName string
 		Hostname   string
 		StartTime  string
+		Build      buildInfo
 	}{
 		Sections:   statusSections,
 		BinaryName: binaryName,
 		Hostname:   hostname,
 		StartTime:  serverStart.Format(time.RFC1123),
+		Build:      build,
 	}
 
 	if err := statusTmpl.ExecuteTemplate(w, "status", data); err != nil {
@@ -172,6 +206,33 @@ func statusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// writeStatusJSON writes the status page as JSON. A section whose
+// data cannot be marshaled is replaced by its error. statusMu must
+// be held.
+func writeStatusJSON(w http.ResponseWriter, build buildInfo) {
+	data := statusJSON{
+		BinaryName: binaryName,
+		Hostname:   hostname,
+		StartTime:  serverStart.Format(time.RFC3339),
+		Build:      build,
+		Sections:   make(map[string]json.RawMessage, len(statusSections)),
+	}
+	for _, sec := range statusSections {
+		value, err := json.Marshal(sec.F())
+		if err != nil {
+			value, _ = json.Marshal(map[string]string{"Error": err.Error()})
+		}
+		data.Sections[sec.Banner] = value
+	}
+	result, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot marshal status: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(result)
+}
+
 // StatusURLPath returns the path to the status page.
 func StatusURLPath() string {
 	return "/debug/status"
@@ -1,6 +1,7 @@
 package servenv
 
 import (
+	"encoding/json"
 	"html/template"
 	"io/ioutil"
 	"net/http"
@@ -52,3 +53,28 @@ func TestStatus(t *testing.T) {
 	}
 	t.Logf("body: \n%s", body)
 }
+
+func TestStatusJSON(t *testing.T) {
+	server := httptest.NewServer(nil)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + StatusURLPath() + "?format=json")
+	if err != nil {
+		t.Fatalf("http.Get: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var status statusJSON
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("cannot decode status: %v", err)
+	}
+	if status.BinaryName != binaryName {
+		t.Errorf("BinaryName = %v, want %v", status.BinaryName, binaryName)
+	}
+	if got, want := string(status.Sections["test_part"]), `"this should be uppercase"`; got != want {
+		t.Errorf("test_part = %v, want %v", got, want)
+	}
+	if got, want := string(status.Sections["test_section"]), `"this is a section"`; got != want {
+		t.Errorf("test_section = %v, want %v", got, want)
+	}
+}
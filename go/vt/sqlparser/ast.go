@@ -27,6 +27,9 @@ import (
 // Parse parses the sql and returns a Statement, which
 // is the AST representation of the query.
 func Parse(sql string) (Statement, error) {
+	if savepoint := ParseSavepoint(sql); savepoint != nil {
+		return savepoint, nil
+	}
 	tokenizer := NewStringTokenizer(sql)
 	if yyParse(tokenizer) != 0 {
 		return nil, errors.New(tokenizer.LastError)
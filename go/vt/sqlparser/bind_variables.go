@@ -0,0 +1,183 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlparser
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/youtube/vitess/go/sqltypes"
+)
+
+// BindVarType is the type of a bind variable.
+type BindVarType int
+
+// These are the bind variable types.
+const (
+	BindNull BindVarType = iota
+	BindInt64
+	BindUint64
+	BindFloat
+	BindBytes
+	BindList
+	BindTuple
+)
+
+var bindVarTypeNames = []string{
+	"NULL",
+	"INT64",
+	"UINT64",
+	"FLOAT",
+	"BYTES",
+	"LIST",
+	"TUPLE",
+}
+
+func (t BindVarType) String() string {
+	if t < 0 || int(t) >= len(bindVarTypeNames) {
+		return fmt.Sprintf("BindVarType(%d)", int(t))
+	}
+	return bindVarTypeNames[t]
+}
+
+// BindVariable is a bind variable whose type is known. Scalars
+// are stored in Value. A list stores its elements in List, which
+// can contain scalars or tuples. A tuple stores its elements in
+// List too, and can only contain scalars.
+type BindVariable struct {
+	Type  BindVarType
+	Value sqltypes.Value
+	List  []*BindVariable
+}
+
+// NewBindVariable builds a BindVariable from a go value. Signed
+// integers become INT64, unsigned ones UINT64, strings, []byte and
+// times BYTES, and []interface{} or []sqltypes.Value a LIST.
+// The elements of a LIST that are themselves []interface{} or
+// []sqltypes.Value become a TUPLE, as used by "(a, b) in ::list".
+func NewBindVariable(value interface{}) (*BindVariable, error) {
+	list, ok := toList(value)
+	if !ok {
+		return newScalarBindVariable(value)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("empty list")
+	}
+	bv := &BindVariable{Type: BindList, List: make([]*BindVariable, 0, len(list))}
+	for i, v := range list {
+		var elem *BindVariable
+		var err error
+		if tuple, ok := toList(v); ok {
+			elem, err = newTupleBindVariable(tuple)
+		} else {
+			elem, err = newScalarBindVariable(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list element %d: %v", i, err)
+		}
+		bv.List = append(bv.List, elem)
+	}
+	return bv, nil
+}
+
+// toList returns the elements of value if it's a list.
+func toList(value interface{}) ([]interface{}, bool) {
+	switch value := value.(type) {
+	case []interface{}:
+		return value, true
+	case []sqltypes.Value:
+		list := make([]interface{}, 0, len(value))
+		for _, v := range value {
+			list = append(list, v)
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+func newTupleBindVariable(tuple []interface{}) (*BindVariable, error) {
+	if len(tuple) == 0 {
+		return nil, fmt.Errorf("empty tuple")
+	}
+	bv := &BindVariable{Type: BindTuple, List: make([]*BindVariable, 0, len(tuple))}
+	for i, v := range tuple {
+		if _, ok := toList(v); ok {
+			return nil, fmt.Errorf("tuple element %d: tuples cannot be nested", i)
+		}
+		elem, err := newScalarBindVariable(v)
+		if err != nil {
+			return nil, fmt.Errorf("tuple element %d: %v", i, err)
+		}
+		bv.List = append(bv.List, elem)
+	}
+	return bv, nil
+}
+
+func newScalarBindVariable(value interface{}) (*BindVariable, error) {
+	v, err := sqltypes.BuildValue(value)
+	if err != nil {
+		return nil, err
+	}
+	bv := &BindVariable{Value: v}
+	switch inner := v.Inner.(type) {
+	case nil:
+		bv.Type = BindNull
+	case sqltypes.Numeric:
+		if _, err := strconv.ParseInt(string(inner), 10, 64); err == nil {
+			bv.Type = BindInt64
+		} else if _, err := strconv.ParseUint(string(inner), 10, 64); err == nil {
+			bv.Type = BindUint64
+		} else {
+			return nil, fmt.Errorf("invalid integer %s", inner)
+		}
+	case sqltypes.Fractional:
+		bv.Type = BindFloat
+	default:
+		bv.Type = BindBytes
+	}
+	return bv, nil
+}
+
+// EncodeSql writes the sql representation of the bind variable
+// to buf. Lists and tuples are written parenthesized.
+func (bv *BindVariable) EncodeSql(buf *bytes.Buffer) {
+	if bv.Type != BindList && bv.Type != BindTuple {
+		bv.Value.EncodeSql(buf)
+		return
+	}
+	buf.WriteByte('(')
+	for i, elem := range bv.List {
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		elem.EncodeSql(buf)
+	}
+	buf.WriteByte(')')
+}
+
+// NewBindVariables converts a map of bind variables to their
+// typed representation. The error names the variable that could
+// not be converted.
+func NewBindVariables(bindVariables map[string]interface{}) (map[string]*BindVariable, error) {
+	typed := make(map[string]*BindVariable, len(bindVariables))
+	for name, value := range bindVariables {
+		bv, err := NewBindVariable(value)
+		if err != nil {
+			return nil, fmt.Errorf("bind variable %s: %v", name, err)
+		}
+		typed[name] = bv
+	}
+	return typed, nil
+}
+
+// ValidateBindVariables returns an error naming a bind variable
+// that doesn't have a supported type. Servers call it
+// on the bind variables they receive, so that bad ones fail
+// before the query is planned or sent anywhere.
+func ValidateBindVariables(bindVariables map[string]interface{}) error {
+	_, err := NewBindVariables(bindVariables)
+	return err
+}
@@ -0,0 +1,112 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlparser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/youtube/vitess/go/sqltypes"
+)
+
+func TestNewBindVariable(t *testing.T) {
+	tcases := []struct {
+		desc    string
+		value   interface{}
+		typ     BindVarType
+		encoded string
+	}{
+		{"nil", nil, BindNull, "null"},
+		{"int", 1, BindInt64, "1"},
+		{"negative int64", int64(-1), BindInt64, "-1"},
+		{"uint64", uint64(18446744073709551615), BindUint64, "18446744073709551615"},
+		{"float", 1.5, BindFloat, "1.5"},
+		{"string", "aa", BindBytes, "'aa'"},
+		{"bytes", []byte("aa"), BindBytes, "'aa'"},
+		{"value", sqltypes.MakeNumeric([]byte("2")), BindInt64, "2"},
+		{"list", []interface{}{1, "aa"}, BindList, "(1, 'aa')"},
+		{"value list", []sqltypes.Value{sqltypes.MakeNumeric([]byte("1"))}, BindList, "(1)"},
+		{"tuple list", []interface{}{[]interface{}{1, "aa"}, []sqltypes.Value{sqltypes.MakeNumeric([]byte("2")), sqltypes.MakeString([]byte("bb"))}}, BindList, "((1, 'aa'), (2, 'bb'))"},
+	}
+	for _, tcase := range tcases {
+		bv, err := NewBindVariable(tcase.value)
+		if err != nil {
+			t.Errorf("%s: %v", tcase.desc, err)
+			continue
+		}
+		if bv.Type != tcase.typ {
+			t.Errorf("%s: type %v, want %v", tcase.desc, bv.Type, tcase.typ)
+		}
+		buf := &bytes.Buffer{}
+		bv.EncodeSql(buf)
+		if buf.String() != tcase.encoded {
+			t.Errorf("%s: encoded %s, want %s", tcase.desc, buf.String(), tcase.encoded)
+		}
+	}
+}
+
+func TestValidateBindVariables(t *testing.T) {
+	tcases := []struct {
+		desc     string
+		bindVars map[string]interface{}
+		err      string
+	}{
+		{
+			"valid",
+			map[string]interface{}{
+				"id":   1,
+				"name": "aa",
+				"vals": []interface{}{1, 2},
+			},
+			"",
+		}, {
+			"unsupported type",
+			map[string]interface{}{
+				"id": make([]int, 1),
+			},
+			"bind variable id: unsupported bind variable type []int: [0]",
+		}, {
+			"empty list",
+			map[string]interface{}{
+				"vals": []interface{}{},
+			},
+			"bind variable vals: empty list",
+		}, {
+			"tuple list",
+			map[string]interface{}{
+				"vals": []interface{}{[]interface{}{1, 2}, []interface{}{3, 4}},
+			},
+			"",
+		}, {
+			"nested tuple",
+			map[string]interface{}{
+				"vals": []interface{}{[]interface{}{[]interface{}{1}}},
+			},
+			"bind variable vals: list element 0: tuple element 0: tuples cannot be nested",
+		}, {
+			"empty tuple",
+			map[string]interface{}{
+				"vals": []interface{}{[]interface{}{}},
+			},
+			"bind variable vals: list element 0: empty tuple",
+		}, {
+			"bad list element",
+			map[string]interface{}{
+				"vals": []interface{}{1, true},
+			},
+			"bind variable vals: list element 1: unsupported bind variable type bool: true",
+		},
+	}
+	for _, tcase := range tcases {
+		err := ValidateBindVariables(tcase.bindVars)
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tcase.err {
+			t.Errorf("%s: got %q, want %q", tcase.desc, got, tcase.err)
+		}
+	}
+}
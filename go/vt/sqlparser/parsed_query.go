@@ -85,6 +85,8 @@ func EncodeValue(buf *bytes.Buffer, value interface{}) error {
 			}
 		}
 		buf.WriteByte(')')
+	case *BindVariable:
+		bindVal.EncodeSql(buf)
 	case TupleEqualityList:
 		if err := bindVal.Encode(buf); err != nil {
 			return err
@@ -163,6 +165,12 @@ func FetchBindVar(name string, bindVariables map[string]interface{}) (val interf
 	if !ok {
 		return nil, false, fmt.Errorf("missing bind var %s", name)
 	}
+	if bv, ok := supplied.(*BindVariable); ok {
+		if isList != (bv.Type == BindList) {
+			return nil, false, fmt.Errorf("unexpected %v arg for key %s", bv.Type, name)
+		}
+		return bv, isList, nil
+	}
 	list, gotList := supplied.([]interface{})
 	if isList {
 		if !gotList {
@@ -91,6 +91,33 @@ func TestParsedQuery(t *testing.T) {
 				},
 			},
 			"select * from a where id in (1)",
+		}, {
+			"tuple list bind vars",
+			"select * from a where (id1, id2) in ::vals",
+			map[string]interface{}{
+				"vals": []interface{}{
+					[]interface{}{1, "aa"},
+					[]interface{}{2, "bb"},
+				},
+			},
+			"select * from a where (id1, id2) in ((1, 'aa'), (2, 'bb'))",
+		}, {
+			"typed tuple list bind vars",
+			"select * from a where (id1, id2) in ::vals",
+			map[string]interface{}{
+				"vals": mustNewBindVariable([]interface{}{
+					[]interface{}{1, "aa"},
+					[]interface{}{2, "bb"},
+				}),
+			},
+			"select * from a where (id1, id2) in ((1, 'aa'), (2, 'bb'))",
+		}, {
+			"typed list bind var for non-list",
+			"select * from a where id = :vals",
+			map[string]interface{}{
+				"vals": mustNewBindVariable([]interface{}{1}),
+			},
+			"unexpected LIST arg for key vals",
 		}, {
 			"list bind vars 0 arguments",
 			"select * from a where id in ::vals",
@@ -194,3 +221,11 @@ func TestParsedQuery(t *testing.T) {
 		}
 	}
 }
+
+func mustNewBindVariable(value interface{}) *BindVariable {
+	bv, err := NewBindVariable(value)
+	if err != nil {
+		panic(err)
+	}
+	return bv
+}
@@ -0,0 +1,83 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlparser
+
+import "bytes"
+
+// Savepoint represents a SAVEPOINT, ROLLBACK TO SAVEPOINT or
+// RELEASE SAVEPOINT statement.
+type Savepoint struct {
+	Action string
+	Name   []byte
+}
+
+const (
+	AST_SAVEPOINT   = "savepoint"
+	AST_ROLLBACK_TO = "rollback to"
+	AST_RELEASE     = "release"
+)
+
+func (*Savepoint) IStatement() {}
+
+func (node *Savepoint) Format(buf *TrackedBuffer) {
+	if node.Action != AST_SAVEPOINT {
+		buf.Myprintf("%s ", node.Action)
+	}
+	buf.Myprintf("savepoint ")
+	escape(buf, node.Name)
+}
+
+// ParseSavepoint returns the Savepoint of sql, or nil if it is not a
+// savepoint statement. The grammar has no transaction statements,
+// they are recognized from their tokens before it runs. It stops at
+// the first keyword, so it is cheap to call on any query.
+func ParseSavepoint(sql string) *Savepoint {
+	tokenizer := NewStringTokenizer(sql)
+	var tokens [][]byte
+	var types []int
+	for {
+		typ, val := tokenizer.Scan()
+		if typ == 0 {
+			break
+		}
+		if typ == COMMENT {
+			continue
+		}
+		if typ != ID && typ != TO {
+			return nil
+		}
+		types = append(types, typ)
+		tokens = append(tokens, val)
+	}
+	// is returns true if the i-th token is the identifier word.
+	is := func(i int, word string) bool {
+		return i < len(tokens) && types[i] == ID && bytes.EqualFold(tokens[i], []byte(word))
+	}
+
+	switch {
+	case len(tokens) == 2 && is(0, "savepoint") && types[1] == ID:
+		return &Savepoint{Action: AST_SAVEPOINT, Name: tokens[1]}
+	case len(tokens) == 3 && is(0, "release") && is(1, "savepoint") && types[2] == ID:
+		return &Savepoint{Action: AST_RELEASE, Name: tokens[2]}
+	case is(0, "rollback"):
+		// ROLLBACK [WORK] TO [SAVEPOINT] name
+		i := 1
+		if is(i, "work") {
+			i++
+		}
+		if i >= len(tokens) || types[i] != TO {
+			return nil
+		}
+		i++
+		if is(i, "savepoint") && i+1 < len(tokens) {
+			i++
+		}
+		if i != len(tokens)-1 || types[i] != ID {
+			return nil
+		}
+		return &Savepoint{Action: AST_ROLLBACK_TO, Name: tokens[i]}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlparser
+
+import "strings"
+
+// The session functions whose value depends on the previous queries
+// of the connection.
+const (
+	LastInsertIdFunc = "last_insert_id"
+	RowCountFunc     = "row_count"
+)
+
+// SessionFunction is a query that only selects a session function,
+// like "select last_insert_id()".
+type SessionFunction struct {
+	// Name is the lower case name of the function.
+	Name string
+	// Column is the name of the result column, the alias or the
+	// function call as it was written.
+	Column string
+}
+
+// ParseSessionFunction returns the SessionFunction of sql, or nil if
+// it is not such a query. It accepts an alias and "from dual". Like
+// ParseSavepoint, it stops at the first unexpected token, so it is
+// cheap to call on any query.
+func ParseSessionFunction(sql string) *SessionFunction {
+	tokenizer := NewStringTokenizer(sql)
+	var types []int
+	var tokens []string
+	for {
+		typ, val := tokenizer.Scan()
+		if typ == 0 {
+			break
+		}
+		if typ == COMMENT {
+			continue
+		}
+		switch typ {
+		case SELECT, ID, AS, FROM, '(', ')':
+		default:
+			return nil
+		}
+		if len(types) == 0 && typ != SELECT {
+			return nil
+		}
+		types = append(types, typ)
+		tokens = append(tokens, string(val))
+	}
+	if len(types) < 4 || types[1] != ID || types[2] != '(' || types[3] != ')' {
+		return nil
+	}
+	name := strings.ToLower(tokens[1])
+	if name != LastInsertIdFunc && name != RowCountFunc {
+		return nil
+	}
+	function := &SessionFunction{Name: name, Column: tokens[1] + "()"}
+
+	i := 4
+	if i < len(types) && types[i] == AS {
+		i++
+		if i == len(types) || types[i] != ID {
+			return nil
+		}
+	}
+	if i < len(types) && types[i] == ID {
+		function.Column = tokens[i]
+		i++
+	}
+	if i < len(types) {
+		if i+2 != len(types) || types[i] != FROM || types[i+1] != ID || !strings.EqualFold(tokens[i+1], "dual") {
+			return nil
+		}
+	}
+	return function
+}
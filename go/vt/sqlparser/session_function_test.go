@@ -0,0 +1,34 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSessionFunction(t *testing.T) {
+	tcases := []struct {
+		sql  string
+		want *SessionFunction
+	}{
+		{"select last_insert_id()", &SessionFunction{LastInsertIdFunc, "last_insert_id()"}},
+		{"SELECT /* comment */ LAST_INSERT_ID()", &SessionFunction{LastInsertIdFunc, "LAST_INSERT_ID()"}},
+		{"select row_count() as n", &SessionFunction{RowCountFunc, "n"}},
+		{"select row_count() n from dual", &SessionFunction{RowCountFunc, "n"}},
+		{"select last_insert_id() from DUAL", &SessionFunction{LastInsertIdFunc, "last_insert_id()"}},
+		{"select last_insert_id(1)", nil},
+		{"select last_insert_id() from t", nil},
+		{"select last_insert_id(), row_count()", nil},
+		{"select now()", nil},
+		{"select last_insert_id() as", nil},
+		{"insert into t values (1)", nil},
+	}
+	for _, tcase := range tcases {
+		if got := ParseSessionFunction(tcase.sql); !reflect.DeepEqual(got, tcase.want) {
+			t.Errorf("ParseSessionFunction(%q) = %+v, want %+v", tcase.sql, got, tcase.want)
+		}
+	}
+}
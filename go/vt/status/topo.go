@@ -0,0 +1,66 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package status
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/servenv"
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+// topoCheckTimeout is how long the status page waits for the
+// topology server.
+var topoCheckTimeout = 5 * time.Second
+
+const topoTemplate = `
+{{if .Error}}<span style="color:red">Cannot reach the topology server: {{.Error}}</span><br>
+{{else}}Known cells: {{range $i, $cell := .Cells}}{{if $i}}, {{end}}{{$cell}}{{end}}<br>
+{{end}}Response time: {{.Latency}}<br>
+`
+
+// TopoStatus is the result of a check of the topology server.
+type TopoStatus struct {
+	Cells   []string
+	Latency time.Duration
+	Error   string
+}
+
+// CheckTopo lists the known cells of the topology server, to check
+// we can reach it. It doesn't wait more than topoCheckTimeout.
+func CheckTopo(ts topo.Server) *TopoStatus {
+	type result struct {
+		cells []string
+		err   error
+	}
+	start := time.Now()
+	c := make(chan result, 1)
+	go func() {
+		cells, err := ts.GetKnownCells()
+		c <- result{cells, err}
+	}()
+
+	status := &TopoStatus{}
+	select {
+	case r := <-c:
+		status.Cells = r.cells
+		if r.err != nil {
+			status.Error = r.err.Error()
+		}
+	case <-time.After(topoCheckTimeout):
+		status.Error = fmt.Sprintf("no answer after %v", topoCheckTimeout)
+	}
+	status.Latency = time.Now().Sub(start)
+	return status
+}
+
+// AddTopoStatusPart adds the connectivity to the topology server to
+// the status page.
+func AddTopoStatusPart(ts topo.Server) {
+	servenv.AddStatusPart("Topology Server", topoTemplate, func() interface{} {
+		return CheckTopo(ts)
+	})
+}
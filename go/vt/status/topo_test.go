@@ -0,0 +1,23 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package status
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/youtube/vitess/go/vt/zktopo"
+)
+
+func TestCheckTopo(t *testing.T) {
+	ts := zktopo.NewTestServer(t, []string{"cell1", "cell2"})
+	status := CheckTopo(ts)
+	if status.Error != "" {
+		t.Fatalf("CheckTopo failed: %v", status.Error)
+	}
+	if want := []string{"cell1", "cell2"}; !reflect.DeepEqual(status.Cells, want) {
+		t.Errorf("Cells = %v, want %v", status.Cells, want)
+	}
+}
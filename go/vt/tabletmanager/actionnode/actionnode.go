@@ -4,8 +4,10 @@
 
 // Actions modify the state of a tablet, shard or keyspace.
 //
-// They are currently managed through a series of queues stored in
-// topology server, or RPCs. Switching to RPCs only now.
+// Tablet actions are sent directly to the tablet manager RPC service
+// on vttablet. Shard, keyspace and serving shard actions are only
+// descriptive, and stored in the topology server when the object is
+// locked for the duration of the action.
 
 package actionnode
 
@@ -22,8 +24,8 @@ const (
 	// FIXME(msolomon) why is ActionState a type, but Action is not?
 
 	//
-	// Tablet actions. This first list is RPC only. In the process
-	// of converting them all to RPCs.
+	// Tablet actions. These are the method names of the tablet
+	// manager RPC service.
 	//
 
 	// Ping checks a tablet is alive
@@ -81,6 +83,10 @@ const (
 	// StartSlave will start MySQL replication.
 	TABLET_ACTION_START_SLAVE = "StartSlave"
 
+	// StartSlaveUntilAfter will start MySQL replication, and stop
+	// it right after a position.
+	TABLET_ACTION_START_SLAVE_UNTIL_AFTER = "StartSlaveUntilAfter"
+
 	// TabletExternallyReparented is sent directly to the new master
 	// tablet when it becomes the master. It is functionnaly equivalent
 	// to calling "ShardExternallyReparented" on the topology.
@@ -156,6 +162,13 @@ const (
 	// Restore will restore a backup
 	TABLET_ACTION_RESTORE = "Restore"
 
+	// CleanOrphans removes the files left behind by failed
+	// snapshots and restores
+	TABLET_ACTION_CLEAN_ORPHANS = "CleanOrphans"
+
+	// DeleteSnapshot removes an expired snapshot
+	TABLET_ACTION_DELETE_SNAPSHOT = "DeleteSnapshot"
+
 	//
 	// Shard actions - involve all tablets in a shard.
 	// These are just descriptive and used for locking / logging.
@@ -175,6 +188,13 @@ const (
 	SHARD_ACTION_MIGRATE_SERVED_TYPES = "MigrateServedTypes"
 	// Update the Shard object (Cells, ...)
 	SHARD_ACTION_UPDATE_SHARD = "UpdateShard"
+	// Reconcile the replication graph with MySQL replication
+	SHARD_ACTION_REPAIR_REPLICATION_GRAPH = "RepairReplicationGraph"
+	// Make the shard read-only, or read-write again
+	SHARD_ACTION_FREEZE   = "FreezeShard"
+	SHARD_ACTION_UNFREEZE = "UnfreezeShard"
+	// Take a tablet of the shard out of service gracefully
+	SHARD_ACTION_DRAIN_TABLET = "DrainTablet"
 
 	//
 	// Keyspace actions - require very high level locking for consistency.
@@ -184,9 +204,14 @@ const (
 	KEYSPACE_ACTION_REBUILD             = "RebuildKeyspace"
 	KEYSPACE_ACTION_APPLY_SCHEMA        = "ApplySchemaKeyspace"
 	KEYSPACE_ACTION_SET_SHARDING_INFO   = "SetKeyspaceShardingInfo"
+	KEYSPACE_ACTION_SET_SHARDING_SCHEME = "SetKeyspaceShardingScheme"
 	KEYSPACE_ACTION_MIGRATE_SERVED_FROM = "MigrateServedFrom"
 	KEYSPACE_ACTION_SET_SERVED_FROM     = "SetKeyspaceServedFrom"
 
+	// Hold, or let go, a consistent snapshot across all shards
+	KEYSPACE_ACTION_CREATE_CONSISTENT_SNAPSHOT  = "CreateConsistentSnapshot"
+	KEYSPACE_ACTION_RELEASE_CONSISTENT_SNAPSHOT = "ReleaseConsistentSnapshot"
+
 	//
 	// SrvShard actions - very local locking, for consistency.
 	// These are just descriptive and used for locking / logging.
@@ -196,23 +221,21 @@ const (
 
 	// all the valid states for an action
 
-	ACTION_STATE_QUEUED  = ActionState("")        // All actions are queued initially
-	ACTION_STATE_RUNNING = ActionState("Running") // Running inside vtaction process
-	ACTION_STATE_FAILED  = ActionState("Failed")  // Ended with a failure
-	ACTION_STATE_DONE    = ActionState("Done")    // Ended with no failure
+	ACTION_STATE_QUEUED = ActionState("")       // All actions are queued initially
+	ACTION_STATE_FAILED = ActionState("Failed") // Ended with a failure
+	ACTION_STATE_DONE   = ActionState("Done")   // Ended with no failure
 )
 
 // ActionState is the state an ActionNode
 type ActionState string
 
-// ActionNode describes a long-running action on a tablet, or an action
-// on a shard or keyspace that locks it.
+// ActionNode describes an action on a shard, keyspace or serving
+// shard that locks it.
 type ActionNode struct {
 	Action     string
 	ActionGuid string
 	Error      string
 	State      ActionState
-	Pid        int // only != 0 if State == ACTION_STATE_RUNNING
 
 	// do not serialize the next fields
 	// path in topology server representing this action
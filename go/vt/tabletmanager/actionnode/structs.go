@@ -47,11 +47,13 @@ type HealthStreamReply struct {
 }
 
 // RestartSlaveData is returned by the master, and used to promote or
-// restart slaves
+// restart slaves. WaitPosition is deprecated: RestartSlave waits for
+// the _vt.reparent_journal row of TimePromoted instead. It is still
+// filled in for the slaves that haven't been upgraded yet.
 type RestartSlaveData struct {
 	ReplicationStatus *myproto.ReplicationStatus
 	WaitPosition      myproto.ReplicationPosition
-	TimePromoted      int64 // used to verify replication - a row will be inserted with this timestamp
+	TimePromoted      int64 // used to verify replication - the master inserts a _vt.reparent_journal row with this timestamp
 	Parent            topo.TabletAlias
 	Force             bool
 }
@@ -103,6 +105,21 @@ type RestoreArgs struct {
 	FetchRetryCount       int
 	WasReserved           bool
 	DontWaitForSlaveStart bool
+	// FanOut is the number of tablets restoring from the same
+	// snapshot at the same time. If more than one, the source
+	// sends each file once to all of them.
+	FanOut int
+}
+
+// CleanOrphansArgs is the payload for CleanOrphans
+type CleanOrphansArgs struct {
+	MaxAge time.Duration
+	DryRun bool
+}
+
+// DeleteSnapshotArgs is the payload for DeleteSnapshot
+type DeleteSnapshotArgs struct {
+	ManifestPath string
 }
 
 // shard action node structures
@@ -210,6 +227,35 @@ func UpdateShard() *ActionNode {
 	}).SetGuid()
 }
 
+// RepairReplicationGraph returns an ActionNode
+func RepairReplicationGraph() *ActionNode {
+	return (&ActionNode{
+		Action: SHARD_ACTION_REPAIR_REPLICATION_GRAPH,
+	}).SetGuid()
+}
+
+// FreezeShard returns an ActionNode
+func FreezeShard() *ActionNode {
+	return (&ActionNode{
+		Action: SHARD_ACTION_FREEZE,
+	}).SetGuid()
+}
+
+// UnfreezeShard returns an ActionNode
+func UnfreezeShard() *ActionNode {
+	return (&ActionNode{
+		Action: SHARD_ACTION_UNFREEZE,
+	}).SetGuid()
+}
+
+// DrainTablet returns an ActionNode
+func DrainTablet(tabletAlias topo.TabletAlias) *ActionNode {
+	return (&ActionNode{
+		Action: SHARD_ACTION_DRAIN_TABLET,
+		Args:   &tabletAlias,
+	}).SetGuid()
+}
+
 // methods to build the keyspace action nodes
 
 // RebuildKeyspace returns an ActionNode
@@ -226,6 +272,13 @@ func SetKeyspaceShardingInfo() *ActionNode {
 	}).SetGuid()
 }
 
+// SetKeyspaceShardingScheme returns an ActionNode
+func SetKeyspaceShardingScheme() *ActionNode {
+	return (&ActionNode{
+		Action: KEYSPACE_ACTION_SET_SHARDING_SCHEME,
+	}).SetGuid()
+}
+
 // SetKeyspaceServedFrom returns an ActionNode
 func SetKeyspaceServedFrom() *ActionNode {
 	return (&ActionNode{
@@ -233,6 +286,22 @@ func SetKeyspaceServedFrom() *ActionNode {
 	}).SetGuid()
 }
 
+// CreateConsistentSnapshot returns an ActionNode
+func CreateConsistentSnapshot(name string) *ActionNode {
+	return (&ActionNode{
+		Action: KEYSPACE_ACTION_CREATE_CONSISTENT_SNAPSHOT,
+		Args:   &name,
+	}).SetGuid()
+}
+
+// ReleaseConsistentSnapshot returns an ActionNode
+func ReleaseConsistentSnapshot(name string) *ActionNode {
+	return (&ActionNode{
+		Action: KEYSPACE_ACTION_RELEASE_CONSISTENT_SNAPSHOT,
+		Args:   &name,
+	}).SetGuid()
+}
+
 // ApplySchemaKeyspace returns an ActionNode
 func ApplySchemaKeyspace(change string, simple bool) *ActionNode {
 	return (&ActionNode{
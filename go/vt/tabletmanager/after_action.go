@@ -17,6 +17,7 @@ import (
 	"github.com/youtube/vitess/go/stats"
 	"github.com/youtube/vitess/go/trace"
 	"github.com/youtube/vitess/go/vt/binlog"
+	"github.com/youtube/vitess/go/vt/tableacl"
 	"github.com/youtube/vitess/go/vt/tabletserver"
 	"github.com/youtube/vitess/go/vt/tabletserver/planbuilder"
 	"github.com/youtube/vitess/go/vt/topo"
@@ -40,6 +41,12 @@ const keyrangeQueryRules string = "KeyrangeQueryRules"
 // Query rules from blacklist
 const blacklistQueryRules string = "BlacklistQueryRules"
 
+// Query rules from master term
+const masterTermQueryRules string = "MasterTermQueryRules"
+
+// Query rules from shard freeze
+const freezeQueryRules string = "FreezeQueryRules"
+
 func (agent *ActionAgent) allowQueries(tablet *topo.Tablet, blacklistedTables []string) error {
 	// if the query service is already running, we're not starting it again
 	if agent.QueryServiceControl.IsServing() {
@@ -130,6 +137,76 @@ func (agent *ActionAgent) loadKeyspaceAndBlacklistRules(tablet *topo.Tablet, bla
 	return nil
 }
 
+// loadMasterTermRules loads the query rules that fence a stale master:
+// all DMLs fail with a retry error, so clients find the new master.
+func (agent *ActionAgent) loadMasterTermRules(tablet *topo.Tablet, shardInfo *topo.ShardInfo, fenced bool) {
+	masterTermRules := tabletserver.NewQueryRules()
+	if fenced {
+		log.Warningf("Tablet %v is a stale master (term %v, shard term %v), refusing writes", tablet.Alias, tablet.MasterTerm, shardInfo.MasterTerm)
+		qr := tabletserver.NewQueryRule("refuse writes on a stale master", "stale_master", tabletserver.QR_FAIL_RETRY)
+		for _, plan := range []planbuilder.PlanType{
+			planbuilder.PLAN_PASS_DML,
+			planbuilder.PLAN_DML_PK,
+			planbuilder.PLAN_DML_SUBQUERY,
+			planbuilder.PLAN_INSERT_PK,
+			planbuilder.PLAN_INSERT_SUBQUERY,
+		} {
+			qr.AddPlanCond(plan)
+		}
+		masterTermRules.Add(qr)
+	} else {
+		log.Infof("Tablet %v is not a stale master anymore, accepting writes", tablet.Alias)
+	}
+	if err := agent.QueryServiceControl.SetQueryRules(masterTermQueryRules, masterTermRules); err != nil {
+		log.Warningf("Fail to load query rule set %s: %s", masterTermQueryRules, err)
+	}
+}
+
+// checkMasterTerm re-reads the shard of a tablet that believes it is
+// the master, and fences it if the shard has a newer master term. A
+// master that was partitioned during a reparent is never told to
+// refresh its state, so it has to find out by itself.
+func (agent *ActionAgent) checkMasterTerm(ctx context.Context, tablet *topo.Tablet) {
+	if tablet.Type != topo.TYPE_MASTER {
+		return
+	}
+	shardInfo, err := topo.GetShard(ctx, agent.TopoServer, tablet.Keyspace, tablet.Shard)
+	if err != nil {
+		log.Warningf("Cannot read shard for master tablet %v, cannot check its master term: %v", tablet.Alias, err)
+		return
+	}
+	fenced := shardInfo.IsStaleMaster(tablet)
+	if agent.setFenced(fenced) {
+		agent.loadMasterTermRules(tablet, shardInfo, fenced)
+		writer := agent.QueryServiceControl.IsServing() && !fenced && !agent.isHandedOver()
+		agent.QueryServiceControl.SetHeartbeatWriter(writer)
+		agent.QueryServiceControl.SetIsMaster(writer && !agent.isFrozen())
+	}
+}
+
+// loadFreezeRules loads the query rules of a frozen shard: all the
+// plans that need the WRITER role fail, until the shard is unfrozen.
+// That includes the DMLs, the DDLs, and the NEXTVALs that write the
+// sequence blocks.
+func (agent *ActionAgent) loadFreezeRules(tablet *topo.Tablet, frozen bool) {
+	freezeRules := tabletserver.NewQueryRules()
+	if frozen {
+		log.Warningf("Shard %v/%v of tablet %v is frozen, refusing writes", tablet.Keyspace, tablet.Shard, tablet.Alias)
+		qr := tabletserver.NewQueryRule("refuse writes on a frozen shard", "frozen_shard", tabletserver.QR_FAIL)
+		for plan := planbuilder.PlanType(0); plan < planbuilder.NumPlans; plan++ {
+			if plan.MinRole() >= tableacl.WRITER {
+				qr.AddPlanCond(plan)
+			}
+		}
+		freezeRules.Add(qr)
+	} else {
+		log.Infof("Shard %v/%v of tablet %v is not frozen anymore, accepting writes", tablet.Keyspace, tablet.Shard, tablet.Alias)
+	}
+	if err := agent.QueryServiceControl.SetQueryRules(freezeQueryRules, freezeRules); err != nil {
+		log.Warningf("Fail to load query rule set %s: %s", freezeQueryRules, err)
+	}
+}
+
 func (agent *ActionAgent) disallowQueries() {
 	agent.QueryServiceControl.DisallowQueries()
 }
@@ -141,6 +218,10 @@ func (agent *ActionAgent) changeCallback(ctx context.Context, oldTablet, newTabl
 	span.StartLocal("ActionAgent.changeCallback")
 	defer span.Finish()
 
+	if oldTablet.Type != newTablet.Type {
+		agent.typeTransition(oldTablet, newTablet)
+	}
+
 	allowQuery := newTablet.IsRunningQueryService()
 
 	// Read the shard to get SourceShards / TabletControlMap if
@@ -153,6 +234,20 @@ func (agent *ActionAgent) changeCallback(ctx context.Context, oldTablet, newTabl
 		shardInfo, err = topo.GetShard(ctx, agent.TopoServer, newTablet.Keyspace, newTablet.Shard)
 		if err != nil {
 			log.Errorf("Cannot read shard for this tablet %v, might have inaccurate SourceShards and TabletControls: %v", newTablet.Alias, err)
+
+			// use the TabletControl values saved locally,
+			// if they were saved for the same tablet
+			if ls := agent.localState(); ls != nil && ls.matches(newTablet) {
+				log.Infof("Using the locally saved blacklisted tables %v and disable query service %v", ls.BlacklistedTables, ls.DisableQueryService)
+				if ls.DisableQueryService {
+					allowQuery = false
+				}
+				blacklistedTables = ls.BlacklistedTables
+				tabletControl = &topo.TabletControl{
+					DisableQueryService: ls.DisableQueryService,
+					BlacklistedTables:   ls.BlacklistedTables,
+				}
+			}
 		} else {
 			if newTablet.Type == topo.TYPE_MASTER {
 				allowQuery = len(shardInfo.SourceShards) == 0
@@ -204,6 +299,36 @@ func (agent *ActionAgent) changeCallback(ctx context.Context, oldTablet, newTabl
 		agent.disallowQueries()
 	}
 
+	// tell the query service where the master is, so it can
+	// redirect the clients that still send it master queries
+	var masterHint *topo.EndPoint
+	if allowQuery && newTablet.Type != topo.TYPE_MASTER {
+		masterHint = agent.masterEndPoint(ctx, newTablet, shardInfo)
+	}
+	agent.QueryServiceControl.SetMasterHint(masterHint)
+	agent.QueryServiceControl.SetTabletType(newTablet.Type)
+
+	// a master that missed a reparent refuses writes, the shard
+	// has a newer master term than the one it was promoted in
+	fenced := shardInfo != nil && shardInfo.IsStaleMaster(newTablet)
+	if agent.setFenced(fenced) {
+		agent.loadMasterTermRules(newTablet, shardInfo, fenced)
+	}
+
+	// a frozen shard refuses writes. If we couldn't read the
+	// shard, we keep the rules we have.
+	if shardInfo != nil && agent.setFrozen(shardInfo.ReadOnly) {
+		agent.loadFreezeRules(newTablet, shardInfo.ReadOnly)
+	}
+
+	// only the serving master of a shard that isn't frozen
+	// purges the expired rows. It writes the heartbeat even when
+	// the shard is frozen, the replicas would look lagging
+	// otherwise. After a handover, the new process does.
+	writer := allowQuery && newTablet.Type == topo.TYPE_MASTER && !fenced && !agent.isHandedOver()
+	agent.QueryServiceControl.SetHeartbeatWriter(writer)
+	agent.QueryServiceControl.SetIsMaster(writer && !agent.isFrozen())
+
 	// save the tabletControl we've been using, so the background
 	// healthcheck makes the same decisions as we've been making.
 	agent.setTabletControl(tabletControl)
@@ -225,7 +350,7 @@ func (agent *ActionAgent) changeCallback(ctx context.Context, oldTablet, newTabl
 
 	// See if we need to start or stop any binlog player
 	if agent.BinlogPlayerMap != nil {
-		if newTablet.Type == topo.TYPE_MASTER {
+		if newTablet.Type == topo.TYPE_MASTER && !agent.isHandedOver() {
 			agent.BinlogPlayerMap.RefreshMap(newTablet, keyspaceInfo, shardInfo)
 		} else {
 			agent.BinlogPlayerMap.StopAllPlayersAndReset()
@@ -234,8 +359,29 @@ func (agent *ActionAgent) changeCallback(ctx context.Context, oldTablet, newTabl
 	return nil
 }
 
+// masterEndPoint returns the end point of the master in shardInfo,
+// or nil if it is unknown or if it is tablet itself.
+func (agent *ActionAgent) masterEndPoint(ctx context.Context, tablet *topo.Tablet, shardInfo *topo.ShardInfo) *topo.EndPoint {
+	if shardInfo == nil || shardInfo.MasterAlias.IsZero() || shardInfo.MasterAlias == tablet.Alias {
+		return nil
+	}
+	master, err := topo.GetTablet(ctx, agent.TopoServer, shardInfo.MasterAlias)
+	if err != nil {
+		log.Warningf("Cannot read master tablet %v: %v", shardInfo.MasterAlias, err)
+		return nil
+	}
+	endPoint, err := master.EndPoint()
+	if err != nil {
+		log.Warningf("Cannot get the end point of master tablet %v: %v", shardInfo.MasterAlias, err)
+		return nil
+	}
+	return endPoint
+}
+
 func init() {
 	// Register query rule sources under control of agent
 	tabletserver.QueryRuleSources.RegisterQueryRuleSource(keyrangeQueryRules)
 	tabletserver.QueryRuleSources.RegisterQueryRuleSource(blacklistQueryRules)
+	tabletserver.QueryRuleSources.RegisterQueryRuleSource(masterTermQueryRules)
+	tabletserver.QueryRuleSources.RegisterQueryRuleSource(freezeQueryRules)
 }
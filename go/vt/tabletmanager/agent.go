@@ -26,6 +26,7 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"path"
 	"sync"
 	"time"
 
@@ -40,6 +41,7 @@ import (
 	"github.com/youtube/vitess/go/vt/dbconfigs"
 	"github.com/youtube/vitess/go/vt/health"
 	"github.com/youtube/vitess/go/vt/mysqlctl"
+	"github.com/youtube/vitess/go/vt/servenv"
 	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
 	"github.com/youtube/vitess/go/vt/tabletserver"
 	"github.com/youtube/vitess/go/vt/topo"
@@ -62,6 +64,7 @@ type ActionAgent struct {
 	MysqlDaemon         mysqlctl.MysqlDaemon
 	DBConfigs           *dbconfigs.DBConfigs
 	SchemaOverrides     []tabletserver.SchemaOverride
+	SchemaOverridesFile string
 	BinlogPlayerMap     *BinlogPlayerMap
 	LockTimeout         time.Duration
 	// batchCtx is given to the agent by its creator, and should be used for
@@ -76,6 +79,10 @@ type ActionAgent struct {
 	History            *history.History
 	lastHealthMapCount *stats.Int
 
+	// TypeTransitions is the History of the tablet type changes,
+	// public so status pages can display it.
+	TypeTransitions *history.History
+
 	// actionMutex is there to run only one action at a time. If
 	// both agent.actionMutex and agent.mutex needs to be taken,
 	// take actionMutex first.
@@ -86,6 +93,24 @@ type ActionAgent struct {
 	_tablet          *topo.TabletInfo
 	_tabletControl   *topo.TabletControl
 	_waitingForMysql bool
+	// _fenced is true if we're a master that missed a reparent
+	_fenced bool
+	// _frozen is true if our shard is frozen
+	_frozen bool
+	// _handedOver is true once the listeners are being handed
+	// over to a new process, which runs the background work
+	_handedOver bool
+	// _lameduck is true once the process is shutting down
+	_lameduck bool
+
+	// localStateFile is where the state that survives a restart
+	// is saved. Empty if the state is not saved.
+	localStateFile string
+	// _localState is the last state saved or loaded, nil if
+	// we're not restarting and haven't saved it yet.
+	_localState *LocalState
+	// _slaveStopped is true if replication was stopped on purpose.
+	_slaveStopped bool
 
 	// if the agent is healthy, this is nil. Otherwise it contains
 	// the reason we're not healthy.
@@ -97,24 +122,32 @@ type ActionAgent struct {
 	// replication delay the last time we got it
 	_replicationDelay time.Duration
 
+	// _schemaVersionTime is when the health check last computed the
+	// schema version, zero to compute it at the next check.
+	_schemaVersionTime time.Time
+
 	// healthStreamMutex protects all the following fields
 	healthStreamMutex sync.Mutex
 	healthStreamIndex int
 	healthStreamMap   map[int]chan<- *actionnode.HealthStreamReply
+
+	// replicaLagWatcher streams the lag of the replicas when
+	// we're the master, see refreshReplicaLag. It is only used
+	// by the health check goroutine.
+	replicaLagWatcher *replicaLagWatcher
 }
 
-func loadSchemaOverrides(overridesFile string) []tabletserver.SchemaOverride {
+func loadSchemaOverrides(overridesFile string) ([]tabletserver.SchemaOverride, error) {
 	var schemaOverrides []tabletserver.SchemaOverride
 	if overridesFile == "" {
-		return schemaOverrides
+		return schemaOverrides, nil
 	}
 	if err := jscfg.ReadJson(overridesFile, &schemaOverrides); err != nil {
-		log.Warningf("can't read overridesFile %v: %v", overridesFile, err)
-	} else {
-		data, _ := json.MarshalIndent(schemaOverrides, "", "  ")
-		log.Infof("schemaOverrides: %s\n", data)
+		return nil, err
 	}
-	return schemaOverrides
+	data, _ := json.MarshalIndent(schemaOverrides, "", "  ")
+	log.Infof("schemaOverrides: %s\n", data)
+	return schemaOverrides, nil
 }
 
 // NewActionAgent creates a new ActionAgent and registers all the
@@ -132,7 +165,10 @@ func NewActionAgent(
 	overridesFile string,
 	lockTimeout time.Duration,
 ) (agent *ActionAgent, err error) {
-	schemaOverrides := loadSchemaOverrides(overridesFile)
+	schemaOverrides, overridesErr := loadSchemaOverrides(overridesFile)
+	if overridesErr != nil {
+		log.Warningf("can't read overridesFile %v: %v", overridesFile, overridesErr)
+	}
 
 	topoServer := topo.GetServer()
 	mysqld := mysqlctl.NewMysqld("Dba", "App", mycnf, &dbcfgs.Dba, &dbcfgs.App.ConnParams, &dbcfgs.Repl)
@@ -147,13 +183,20 @@ func NewActionAgent(
 		MysqlDaemon:         mysqld,
 		DBConfigs:           dbcfgs,
 		SchemaOverrides:     schemaOverrides,
+		SchemaOverridesFile: overridesFile,
 		LockTimeout:         lockTimeout,
 		History:             history.New(historyLength),
+		TypeTransitions:     history.New(historyLength),
 		lastHealthMapCount:  stats.NewInt("LastHealthMapCount"),
 		_healthy:            fmt.Errorf("healthcheck not run yet"),
 		healthStreamMap:     make(map[int]chan<- *actionnode.HealthStreamReply),
+		localStateFile:      path.Join(mysqld.TabletDir, localStateFile),
 	}
 
+	// load the state of the previous vttablet process, if any
+	agent.loadLocalState()
+	restarting := agent.localState() != nil
+
 	// try to initialize the tablet if we have to
 	if err := agent.InitTablet(port, securePort); err != nil {
 		return nil, err
@@ -182,12 +225,20 @@ func NewActionAgent(
 		return nil, err
 	}
 
+	// if we were restarted, replication may need to be restarted too
+	if restarting {
+		agent.restartReplication()
+	}
+
 	// register the RPC services from the agent
 	agent.registerQueryService()
 
 	// start health check if needed
 	agent.initHeathCheck()
 
+	// and the heartbeat
+	agent.initHeartbeat()
+
 	return agent, nil
 }
 
@@ -206,6 +257,7 @@ func NewTestActionAgent(batchCtx context.Context, ts topo.Server, tabletAlias to
 		SchemaOverrides:     nil,
 		BinlogPlayerMap:     nil,
 		History:             history.New(historyLength),
+		TypeTransitions:     history.New(historyLength),
 		lastHealthMapCount:  new(stats.Int),
 		_healthy:            fmt.Errorf("healthcheck not run yet"),
 		healthStreamMap:     make(map[int]chan<- *actionnode.HealthStreamReply),
@@ -221,7 +273,11 @@ func (agent *ActionAgent) updateState(ctx context.Context, oldTablet *topo.Table
 	newTablet := agent._tablet.Tablet
 	agent.mutex.Unlock()
 	log.Infof("Running tablet callback because: %v", reason)
-	return agent.changeCallback(ctx, oldTablet, newTablet)
+	if err := agent.changeCallback(ctx, oldTablet, newTablet); err != nil {
+		return err
+	}
+	agent.saveLocalState()
+	return nil
 }
 
 func (agent *ActionAgent) readTablet(ctx context.Context) (*topo.TabletInfo, error) {
@@ -257,6 +313,9 @@ func (agent *ActionAgent) Healthy() (time.Duration, error) {
 	defer agent.mutex.Unlock()
 
 	healthy := agent._healthy
+	if agent._lameduck {
+		healthy = fmt.Errorf("tablet is in lameduck mode")
+	}
 	if healthy == nil {
 		timeSinceLastCheck := time.Now().Sub(agent._healthyTime)
 		if timeSinceLastCheck > *healthCheckInterval*3 {
@@ -297,6 +356,31 @@ func (agent *ActionAgent) setTabletControl(tc *topo.TabletControl) {
 	agent.mutex.Unlock()
 }
 
+// setFenced saves the fenced state, and returns true if it changed.
+func (agent *ActionAgent) setFenced(fenced bool) bool {
+	agent.mutex.Lock()
+	defer agent.mutex.Unlock()
+	changed := agent._fenced != fenced
+	agent._fenced = fenced
+	return changed
+}
+
+// isFrozen returns true if our shard is frozen.
+func (agent *ActionAgent) isFrozen() bool {
+	agent.mutex.Lock()
+	defer agent.mutex.Unlock()
+	return agent._frozen
+}
+
+// setFrozen saves the frozen state, and returns true if it changed.
+func (agent *ActionAgent) setFrozen(frozen bool) bool {
+	agent.mutex.Lock()
+	defer agent.mutex.Unlock()
+	changed := agent._frozen != frozen
+	agent._frozen = frozen
+	return changed
+}
+
 // refreshTablet needs to be run after an action may have changed the current
 // state of the tablet.
 func (agent *ActionAgent) refreshTablet(ctx context.Context, reason string) error {
@@ -399,6 +483,11 @@ func (agent *ActionAgent) Start(mysqlPort, vtPort, vtsPort int) error {
 		} else {
 			delete(tablet.Portmap, "vts")
 		}
+		if servenv.GRPCPort != nil && *servenv.GRPCPort != 0 {
+			tablet.Portmap["grpc"] = *servenv.GRPCPort
+		} else {
+			delete(tablet.Portmap, "grpc")
+		}
 		return nil
 	}
 	if err := agent.TopoServer.UpdateTabletFields(agent.Tablet().Alias, f); err != nil {
@@ -425,6 +514,44 @@ func (agent *ActionAgent) Start(mysqlPort, vtPort, vtsPort int) error {
 	return nil
 }
 
+// EnterLameduck is run as soon as the process starts shutting
+// down. The tablet and its query service keep serving during the
+// lameduck period, but report themselves as unhealthy, so the
+// clients move away.
+func (agent *ActionAgent) EnterLameduck() {
+	agent.mutex.Lock()
+	agent._lameduck = true
+	agent.mutex.Unlock()
+	agent.QueryServiceControl.EnterLameduck()
+}
+
+// isHandedOver returns true if a new process took over our
+// background work.
+func (agent *ActionAgent) isHandedOver() bool {
+	agent.mutex.Lock()
+	defer agent.mutex.Unlock()
+	return agent._handedOver
+}
+
+// StopForHandover stops the background work that can't run in two
+// processes at once: the binlog players, and the master work of the
+// query service (heartbeat, row GC, idempotency key purges and
+// messages). It is run before the listeners are handed over to a
+// new process, and the later state changes don't restart it.
+func (agent *ActionAgent) StopForHandover() {
+	agent.actionMutex.Lock()
+	defer agent.actionMutex.Unlock()
+	agent.mutex.Lock()
+	agent._handedOver = true
+	agent.mutex.Unlock()
+
+	if agent.BinlogPlayerMap != nil {
+		agent.BinlogPlayerMap.StopAllPlayersAndReset()
+	}
+	agent.QueryServiceControl.SetHeartbeatWriter(false)
+	agent.QueryServiceControl.SetIsMaster(false)
+}
+
 // Stop shutdowns this agent.
 func (agent *ActionAgent) Stop() {
 	if agent.BinlogPlayerMap != nil {
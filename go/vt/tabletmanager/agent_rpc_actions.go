@@ -87,6 +87,8 @@ type RPCAgent interface {
 
 	StartSlave(ctx context.Context) error
 
+	StartSlaveUntilAfter(ctx context.Context, position myproto.ReplicationPosition, waitTime time.Duration) error
+
 	TabletExternallyReparented(ctx context.Context, externalID string) error
 
 	GetSlaves(ctx context.Context) ([]string, error)
@@ -123,6 +125,10 @@ type RPCAgent interface {
 
 	Restore(ctx context.Context, args *actionnode.RestoreArgs, logger logutil.Logger) error
 
+	CleanOrphans(ctx context.Context, args *actionnode.CleanOrphansArgs) (*myproto.OrphanCleanupReport, error)
+
+	DeleteSnapshot(ctx context.Context, args *actionnode.DeleteSnapshotArgs) error
+
 	// RPC helpers
 	RPCWrap(ctx context.Context, name string, args, reply interface{}, f func() error) error
 	RPCWrapLock(ctx context.Context, name string, args, reply interface{}, verbose bool, f func() error) error
@@ -157,7 +163,7 @@ func (agent *ActionAgent) GetPermissions(ctx context.Context) (*myproto.Permissi
 // SetReadOnly makes the mysql instance read-only or read-write
 // Should be called under RPCWrapLockAction.
 func (agent *ActionAgent) SetReadOnly(ctx context.Context, rdonly bool) error {
-	return agent.Mysqld.SetReadOnly(rdonly)
+	return agent.MysqlDaemon.SetReadOnly(rdonly)
 }
 
 // ChangeType changes the tablet type
@@ -216,9 +222,25 @@ func (agent *ActionAgent) UnregisterHealthStream(id int) error {
 	return nil
 }
 
-// ReloadSchema will reload the schema
+// ReloadSchema will reload the schema, and the schema overrides file
+// if there is one.
 // Should be called under RPCWrapLockAction.
 func (agent *ActionAgent) ReloadSchema(ctx context.Context) {
+	// the health check publishes the new schema version next time
+	agent.mutex.Lock()
+	agent._schemaVersionTime = time.Time{}
+	agent.mutex.Unlock()
+
+	if agent.SchemaOverridesFile != "" {
+		schemaOverrides, err := loadSchemaOverrides(agent.SchemaOverridesFile)
+		if err != nil {
+			log.Warningf("can't reload overridesFile %v, keeping the current schema overrides: %v", agent.SchemaOverridesFile, err)
+		} else {
+			agent.SchemaOverrides = schemaOverrides
+			agent.QueryServiceControl.SetSchemaOverrides(schemaOverrides)
+		}
+	}
+
 	if agent.DBConfigs == nil {
 		// we skip this for test instances that can't connect to the DB anyway
 		return
@@ -301,17 +323,17 @@ func (agent *ActionAgent) SlaveStatus(ctx context.Context) (*myproto.Replication
 // and returns the current position
 // Should be called under RPCWrapLock.
 func (agent *ActionAgent) WaitSlavePosition(ctx context.Context, position myproto.ReplicationPosition, waitTimeout time.Duration) (*myproto.ReplicationStatus, error) {
-	if err := agent.Mysqld.WaitMasterPos(position, waitTimeout); err != nil {
+	if err := agent.MysqlDaemon.WaitMasterPos(position, waitTimeout); err != nil {
 		return nil, err
 	}
 
-	return agent.Mysqld.SlaveStatus()
+	return agent.MysqlDaemon.SlaveStatus()
 }
 
 // MasterPosition returns the master position
 // Should be called under RPCWrap.
 func (agent *ActionAgent) MasterPosition(ctx context.Context) (myproto.ReplicationPosition, error) {
-	return agent.Mysqld.MasterPosition()
+	return agent.MysqlDaemon.MasterPosition()
 }
 
 // ReparentPosition returns the RestartSlaveData for the provided
@@ -333,25 +355,41 @@ func (agent *ActionAgent) ReparentPosition(ctx context.Context, rp *myproto.Repl
 // StopSlave will stop the replication
 // Should be called under RPCWrapLock.
 func (agent *ActionAgent) StopSlave(ctx context.Context) error {
-	return agent.MysqlDaemon.StopSlave(agent.hookExtraEnv())
+	if err := agent.MysqlDaemon.StopSlave(agent.hookExtraEnv()); err != nil {
+		return err
+	}
+	agent.setSlaveStopped(true)
+	return nil
 }
 
 // StopSlaveMinimum will stop the slave after it reaches at least the
 // provided position.
 func (agent *ActionAgent) StopSlaveMinimum(ctx context.Context, position myproto.ReplicationPosition, waitTime time.Duration) (*myproto.ReplicationStatus, error) {
-	if err := agent.Mysqld.WaitMasterPos(position, waitTime); err != nil {
+	if err := agent.MysqlDaemon.WaitMasterPos(position, waitTime); err != nil {
 		return nil, err
 	}
-	if err := agent.Mysqld.StopSlave(agent.hookExtraEnv()); err != nil {
+	if err := agent.MysqlDaemon.StopSlave(agent.hookExtraEnv()); err != nil {
 		return nil, err
 	}
-	return agent.Mysqld.SlaveStatus()
+	agent.setSlaveStopped(true)
+	return agent.MysqlDaemon.SlaveStatus()
 }
 
 // StartSlave will start the replication
 // Should be called under RPCWrapLock.
 func (agent *ActionAgent) StartSlave(ctx context.Context) error {
-	return agent.MysqlDaemon.StartSlave(agent.hookExtraEnv())
+	if err := agent.MysqlDaemon.StartSlave(agent.hookExtraEnv()); err != nil {
+		return err
+	}
+	agent.setSlaveStopped(false)
+	return nil
+}
+
+// StartSlaveUntilAfter will start the replication, and stop it right
+// after the provided position.
+// Should be called under RPCWrapLock.
+func (agent *ActionAgent) StartSlaveUntilAfter(ctx context.Context, position myproto.ReplicationPosition, waitTime time.Duration) error {
+	return agent.MysqlDaemon.StartSlaveUntilAfter(position, waitTime)
 }
 
 // GetSlaves returns the address of all the slaves
@@ -429,7 +467,7 @@ func (agent *ActionAgent) PromoteSlave(ctx context.Context) (*actionnode.Restart
 		Parent: tablet.Alias,
 		Force:  (tablet.Type == topo.TYPE_MASTER),
 	}
-	rsd.ReplicationStatus, rsd.WaitPosition, rsd.TimePromoted, err = agent.Mysqld.PromoteSlave(false, agent.hookExtraEnv())
+	rsd.ReplicationStatus, rsd.WaitPosition, rsd.TimePromoted, err = agent.Mysqld.PromoteSlave(tablet.Alias, false, agent.hookExtraEnv())
 	if err != nil {
 		return nil, err
 	}
@@ -461,7 +499,7 @@ func (agent *ActionAgent) RestartSlave(ctx context.Context, rsd *actionnode.Rest
 		tablet.Type = topo.TYPE_LAG_ORPHAN
 		return topo.UpdateTablet(ctx, agent.TopoServer, tablet)
 	}
-	if err = agent.Mysqld.RestartSlave(rsd.ReplicationStatus, rsd.WaitPosition, rsd.TimePromoted); err != nil {
+	if err = agent.Mysqld.RestartSlave(ctx, rsd.ReplicationStatus, rsd.TimePromoted); err != nil {
 		return err
 	}
 	// Complete the special orphan accounting.
@@ -509,6 +547,16 @@ func (agent *ActionAgent) BreakSlaves(ctx context.Context) error {
 // updateReplicationGraphForPromotedSlave makes sure the newly promoted slave
 // is correctly represented in the replication graph
 func (agent *ActionAgent) updateReplicationGraphForPromotedSlave(ctx context.Context, tablet *topo.TabletInfo) error {
+	// Stamp the tablet with the next master term of the shard,
+	// the shard record is updated with it at the end of the reparent.
+	// This is called under the shard lock.
+	if si, err := topo.GetShard(ctx, agent.TopoServer, tablet.Keyspace, tablet.Shard); err != nil {
+		log.Warningf("Cannot read shard for tablet %v, it won't have a master term: %v", tablet.Alias, err)
+		tablet.MasterTerm = 0
+	} else {
+		tablet.MasterTerm = si.MasterTerm + 1
+	}
+
 	// Update tablet regardless - trend towards consistency.
 	tablet.Type = topo.TYPE_MASTER
 	tablet.Health = nil
@@ -786,7 +834,7 @@ func (agent *ActionAgent) Restore(ctx context.Context, args *actionnode.RestoreA
 	l := logutil.NewTeeLogger(logutil.NewConsoleLogger(), logger)
 
 	// do the work
-	if err := agent.Mysqld.RestoreFromSnapshot(l, sm, args.FetchConcurrency, args.FetchRetryCount, args.DontWaitForSlaveStart, agent.hookExtraEnv()); err != nil {
+	if err := agent.Mysqld.RestoreFromSnapshot(l, sm, args.FetchConcurrency, args.FetchRetryCount, args.FanOut, args.DontWaitForSlaveStart, agent.hookExtraEnv()); err != nil {
 		log.Errorf("RestoreFromSnapshot failed (%v), scrapping", err)
 		if err := topotools.Scrap(ctx, agent.TopoServer, agent.TabletAlias, false); err != nil {
 			log.Errorf("Failed to Scrap after failed RestoreFromSnapshot: %v", err)
@@ -801,3 +849,17 @@ func (agent *ActionAgent) Restore(ctx context.Context, args *actionnode.RestoreA
 	// change to TYPE_SPARE, we're done!
 	return topotools.ChangeType(ctx, agent.TopoServer, agent.TabletAlias, topo.TYPE_SPARE, nil)
 }
+
+// CleanOrphans removes the files left behind by failed snapshots and
+// restores.
+// Should be called under RPCWrapLock.
+func (agent *ActionAgent) CleanOrphans(ctx context.Context, args *actionnode.CleanOrphansArgs) (*myproto.OrphanCleanupReport, error) {
+	return agent.Mysqld.CleanOrphans(args.MaxAge, args.DryRun)
+}
+
+// DeleteSnapshot removes the snapshot with the provided manifest, if
+// it is still there.
+// Should be called under RPCWrapLock.
+func (agent *ActionAgent) DeleteSnapshot(ctx context.Context, args *actionnode.DeleteSnapshotArgs) error {
+	return agent.Mysqld.DeleteSnapshot(args.ManifestPath)
+}
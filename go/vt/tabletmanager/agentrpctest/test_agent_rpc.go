@@ -798,6 +798,29 @@ func agentRPCTestStartSlavePanic(ctx context.Context, t *testing.T, client tmcli
 	expectRPCWrapLockPanic(t, err)
 }
 
+var testStartSlaveUntilAfterCalled = false
+var testStartSlaveUntilAfterWaitTime = 10 * time.Minute
+
+func (fra *fakeRPCAgent) StartSlaveUntilAfter(ctx context.Context, position myproto.ReplicationPosition, waitTime time.Duration) error {
+	if fra.panics {
+		panic(fmt.Errorf("test-triggered panic"))
+	}
+	compare(fra.t, "StartSlaveUntilAfter position", position.GTIDSet, testReplicationPosition.GTIDSet)
+	compare(fra.t, "StartSlaveUntilAfter waitTime", waitTime, testStartSlaveUntilAfterWaitTime)
+	testStartSlaveUntilAfterCalled = true
+	return nil
+}
+
+func agentRPCTestStartSlaveUntilAfter(ctx context.Context, t *testing.T, client tmclient.TabletManagerClient, ti *topo.TabletInfo) {
+	err := client.StartSlaveUntilAfter(ctx, ti, testReplicationPosition, testStartSlaveUntilAfterWaitTime)
+	compareError(t, "StartSlaveUntilAfter", err, true, testStartSlaveUntilAfterCalled)
+}
+
+func agentRPCTestStartSlaveUntilAfterPanic(ctx context.Context, t *testing.T, client tmclient.TabletManagerClient, ti *topo.TabletInfo) {
+	err := client.StartSlaveUntilAfter(ctx, ti, testReplicationPosition, testStartSlaveUntilAfterWaitTime)
+	expectRPCWrapLockPanic(t, err)
+}
+
 var testTabletExternallyReparentedCalled = false
 
 func (fra *fakeRPCAgent) TabletExternallyReparented(ctx context.Context, externalID string) error {
@@ -1174,6 +1197,7 @@ var testRestoreArgs = &actionnode.RestoreArgs{
 	FetchRetryCount:       678,
 	WasReserved:           true,
 	DontWaitForSlaveStart: true,
+	FanOut:                3,
 }
 var testRestoreCalled = false
 
@@ -1209,6 +1233,64 @@ func agentRPCTestRestorePanic(ctx context.Context, t *testing.T, client tmclient
 	expectRPCWrapLockActionPanic(t, err)
 }
 
+var testCleanOrphansArgs = &actionnode.CleanOrphansArgs{
+	MaxAge: 12 * time.Hour,
+	DryRun: true,
+}
+var testCleanOrphansReply = &myproto.OrphanCleanupReport{
+	DryRun: true,
+	Files: []*myproto.OrphanedFile{
+		&myproto.OrphanedFile{
+			Path:    "/vt/snapshot/vt_0000000001/data",
+			Size:    1234,
+			ModTime: 1440000000,
+		},
+	},
+	ReclaimedBytes: 1234,
+}
+
+func (fra *fakeRPCAgent) CleanOrphans(ctx context.Context, args *actionnode.CleanOrphansArgs) (*myproto.OrphanCleanupReport, error) {
+	if fra.panics {
+		panic(fmt.Errorf("test-triggered panic"))
+	}
+	compare(fra.t, "CleanOrphans args", args, testCleanOrphansArgs)
+	return testCleanOrphansReply, nil
+}
+
+func agentRPCTestCleanOrphans(ctx context.Context, t *testing.T, client tmclient.TabletManagerClient, ti *topo.TabletInfo) {
+	report, err := client.CleanOrphans(ctx, ti, testCleanOrphansArgs)
+	compareError(t, "CleanOrphans", err, report, testCleanOrphansReply)
+}
+
+func agentRPCTestCleanOrphansPanic(ctx context.Context, t *testing.T, client tmclient.TabletManagerClient, ti *topo.TabletInfo) {
+	_, err := client.CleanOrphans(ctx, ti, testCleanOrphansArgs)
+	expectRPCWrapLockPanic(t, err)
+}
+
+var testDeleteSnapshotArgs = &actionnode.DeleteSnapshotArgs{
+	ManifestPath: "/snapshot/vt_test_keyspace/snapshot_manifest.json",
+}
+var testDeleteSnapshotCalled = false
+
+func (fra *fakeRPCAgent) DeleteSnapshot(ctx context.Context, args *actionnode.DeleteSnapshotArgs) error {
+	if fra.panics {
+		panic(fmt.Errorf("test-triggered panic"))
+	}
+	compare(fra.t, "DeleteSnapshot args", args, testDeleteSnapshotArgs)
+	testDeleteSnapshotCalled = true
+	return nil
+}
+
+func agentRPCTestDeleteSnapshot(ctx context.Context, t *testing.T, client tmclient.TabletManagerClient, ti *topo.TabletInfo) {
+	err := client.DeleteSnapshot(ctx, ti, testDeleteSnapshotArgs)
+	compareError(t, "DeleteSnapshot", err, true, testDeleteSnapshotCalled)
+}
+
+func agentRPCTestDeleteSnapshotPanic(ctx context.Context, t *testing.T, client tmclient.TabletManagerClient, ti *topo.TabletInfo) {
+	err := client.DeleteSnapshot(ctx, ti, testDeleteSnapshotArgs)
+	expectRPCWrapLockPanic(t, err)
+}
+
 //
 // RPC helpers
 //
@@ -1279,6 +1361,7 @@ func Run(t *testing.T, client tmclient.TabletManagerClient, ti *topo.TabletInfo,
 	agentRPCTestStopSlave(ctx, t, client, ti)
 	agentRPCTestStopSlaveMinimum(ctx, t, client, ti)
 	agentRPCTestStartSlave(ctx, t, client, ti)
+	agentRPCTestStartSlaveUntilAfter(ctx, t, client, ti)
 	agentRPCTestTabletExternallyReparented(ctx, t, client, ti)
 	agentRPCTestGetSlaves(ctx, t, client, ti)
 	agentRPCTestWaitBlpPosition(ctx, t, client, ti)
@@ -1299,6 +1382,8 @@ func Run(t *testing.T, client tmclient.TabletManagerClient, ti *topo.TabletInfo,
 	agentRPCTestSnapshotSourceEnd(ctx, t, client, ti)
 	agentRPCTestReserveForRestore(ctx, t, client, ti)
 	agentRPCTestRestore(ctx, t, client, ti)
+	agentRPCTestCleanOrphans(ctx, t, client, ti)
+	agentRPCTestDeleteSnapshot(ctx, t, client, ti)
 
 	//
 	// Tests panic handling everywhere now
@@ -1332,6 +1417,7 @@ func Run(t *testing.T, client tmclient.TabletManagerClient, ti *topo.TabletInfo,
 	agentRPCTestStopSlavePanic(ctx, t, client, ti)
 	agentRPCTestStopSlaveMinimumPanic(ctx, t, client, ti)
 	agentRPCTestStartSlavePanic(ctx, t, client, ti)
+	agentRPCTestStartSlaveUntilAfterPanic(ctx, t, client, ti)
 	agentRPCTestTabletExternallyReparentedPanic(ctx, t, client, ti)
 	agentRPCTestGetSlavesPanic(ctx, t, client, ti)
 	agentRPCTestWaitBlpPositionPanic(ctx, t, client, ti)
@@ -1352,4 +1438,6 @@ func Run(t *testing.T, client tmclient.TabletManagerClient, ti *topo.TabletInfo,
 	agentRPCTestSnapshotSourceEndPanic(ctx, t, client, ti)
 	agentRPCTestReserveForRestorePanic(ctx, t, client, ti)
 	agentRPCTestRestorePanic(ctx, t, client, ti)
+	agentRPCTestCleanOrphansPanic(ctx, t, client, ti)
+	agentRPCTestDeleteSnapshotPanic(ctx, t, client, ti)
 }
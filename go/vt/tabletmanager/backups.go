@@ -0,0 +1,49 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletmanager
+
+import (
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
+)
+
+// canPurgeSnapshotTimeout is how long CanPurgeSnapshot waits for the
+// shard record.
+var canPurgeSnapshotTimeout = 30 * time.Second
+
+// CanPurgeSnapshot returns true if the snapshot of the tablet can be
+// purged once it expired: it's not being served, and it's not one of
+// the backups of the shard. If the shard can't be read, the snapshot
+// is kept. It matches the veto of mysqlctl.NewDiskMonitor.
+func (agent *ActionAgent) CanPurgeSnapshot() bool {
+	tablet := agent.Tablet()
+	if tablet.Type == topo.TYPE_SNAPSHOT_SOURCE {
+		// server mode snapshots are being served
+		return false
+	}
+	ctx, cancel := context.WithTimeout(agent.batchCtx, canPurgeSnapshotTimeout)
+	defer cancel()
+	si, err := topo.GetShard(ctx, agent.TopoServer, tablet.Keyspace, tablet.Shard)
+	if err != nil {
+		log.Warningf("cannot read the shard, keeping the snapshot: %v", err)
+		return false
+	}
+	return !isBackupTablet(si, tablet.Alias)
+}
+
+// isBackupTablet returns true if one of the backups of the shard is
+// stored on the tablet. A tablet only holds its last snapshot, so the
+// backup is that snapshot.
+func isBackupTablet(si *topo.ShardInfo, alias topo.TabletAlias) bool {
+	for _, b := range si.Backups {
+		if b.TabletAlias == alias {
+			return true
+		}
+	}
+	return false
+}
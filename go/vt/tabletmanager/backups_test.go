@@ -0,0 +1,34 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletmanager
+
+import (
+	"testing"
+
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+func TestIsBackupTablet(t *testing.T) {
+	si := topo.NewShardInfo("ks", "0", &topo.Shard{
+		Backups: []*topo.ShardBackup{
+			{TabletAlias: topo.TabletAlias{Cell: "cell1", Uid: 1}},
+			{TabletAlias: topo.TabletAlias{Cell: "cell1", Uid: 2}},
+		},
+	}, 0)
+	table := []struct {
+		uid  uint32
+		want bool
+	}{
+		{1, true},
+		{2, true},
+		{3, false},
+	}
+	for _, test := range table {
+		alias := topo.TabletAlias{Cell: "cell1", Uid: test.uid}
+		if got := isBackupTablet(si, alias); got != test.want {
+			t.Errorf("isBackupTablet(%v) = %v, want %v", alias, got, test.want)
+		}
+	}
+}
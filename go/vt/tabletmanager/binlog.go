@@ -25,6 +25,7 @@ import (
 	"github.com/youtube/vitess/go/vt/key"
 	"github.com/youtube/vitess/go/vt/mysqlctl"
 	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/servenv"
 	"github.com/youtube/vitess/go/vt/topo"
 )
 
@@ -205,7 +206,7 @@ func (bpc *BinlogPlayerController) Loop() {
 func (bpc *BinlogPlayerController) Iteration() (err error) {
 	defer func() {
 		if x := recover(); x != nil {
-			log.Errorf("%v: caught panic: %v", bpc, x)
+			servenv.RecordPanic(bpc.String(), x)
 			err = fmt.Errorf("panic: %v", x)
 		}
 	}()
@@ -331,7 +332,7 @@ func RegisterBinlogPlayerMap(blm *BinlogPlayerMap) {
 		blm.mu.Unlock()
 		return sbm
 	}))
-	stats.Publish("BinlogPlayerSecondsBehindMasterMap", stats.CountersFunc(func() map[string]int64 {
+	stats.Publish("BinlogPlayerSecondsBehindMasterMap", stats.GaugesFunc(func() map[string]int64 {
 		blm.mu.Lock()
 		result := make(map[string]int64, len(blm.players))
 		for i, bpc := range blm.players {
@@ -0,0 +1,97 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletmanager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/binlog/binlogplayer"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
+)
+
+// binlogReadersTimeout is how long BinlogReaderPositions waits for
+// all the positions.
+var binlogReadersTimeout = 30 * time.Second
+
+// BinlogReaderPositions returns the positions of the servers that may
+// read our binlogs: the other replicating tablets of our shard, which
+// replicate from us or could after a reparent, and the binlog players
+// of the shards that have ours as a source. It fails if any of them
+// can't be read, as their binlogs can't be purged then.
+// It matches mysqlctl.BinlogReaderPositions.
+func (agent *ActionAgent) BinlogReaderPositions() ([]myproto.ReplicationPosition, error) {
+	ctx, cancel := context.WithTimeout(agent.batchCtx, binlogReadersTimeout)
+	defer cancel()
+	tablet := agent.Tablet()
+	tmc := tmclient.NewTabletManagerClient()
+
+	var positions []myproto.ReplicationPosition
+	tablets, err := topo.GetTabletMapForShard(ctx, agent.TopoServer, tablet.Keyspace, tablet.Shard)
+	if err != nil {
+		return nil, err
+	}
+	for alias, ti := range tablets {
+		if alias == tablet.Alias || !ti.IsSlaveType() {
+			continue
+		}
+		status, err := tmc.SlaveStatus(ctx, ti)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get the position of %v: %v", alias, err)
+		}
+		positions = append(positions, status.Position)
+	}
+
+	keyspaces, err := agent.TopoServer.GetKeyspaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, keyspace := range keyspaces {
+		shards, err := agent.TopoServer.GetShardNames(keyspace)
+		if err != nil {
+			return nil, err
+		}
+		for _, shard := range shards {
+			si, err := agent.TopoServer.GetShard(keyspace, shard)
+			if err != nil {
+				return nil, err
+			}
+			for _, ss := range si.SourceShards {
+				if ss.Keyspace != tablet.Keyspace || ss.Shard != tablet.Shard {
+					continue
+				}
+				pos, err := blpPosition(ctx, agent.TopoServer, tmc, si, ss.Uid)
+				if err != nil {
+					return nil, fmt.Errorf("cannot get the binlog player position of %v/%v: %v", keyspace, shard, err)
+				}
+				positions = append(positions, pos)
+			}
+		}
+	}
+	return positions, nil
+}
+
+// blpPosition returns the position of a binlog player, read from the
+// blp_checkpoint table of the master of its shard.
+func blpPosition(ctx context.Context, ts topo.Server, tmc tmclient.TabletManagerClient, si *topo.ShardInfo, uid uint32) (myproto.ReplicationPosition, error) {
+	if si.MasterAlias.IsZero() {
+		return myproto.ReplicationPosition{}, fmt.Errorf("shard has no master")
+	}
+	ti, err := ts.GetTablet(si.MasterAlias)
+	if err != nil {
+		return myproto.ReplicationPosition{}, err
+	}
+	qr, err := tmc.ExecuteFetchAsDba(ctx, ti, binlogplayer.QueryBlpCheckpoint(uid), 1, false, false)
+	if err != nil {
+		return myproto.ReplicationPosition{}, err
+	}
+	if len(qr.Rows) != 1 || len(qr.Rows[0]) == 0 {
+		return myproto.ReplicationPosition{}, fmt.Errorf("no checkpoint for source shard %v", uid)
+	}
+	return myproto.DecodeReplicationPosition(qr.Rows[0][0].String())
+}
@@ -197,6 +197,11 @@ func (client *FakeTabletManagerClient) StartSlave(ctx context.Context, tablet *t
 	return nil
 }
 
+// StartSlaveUntilAfter is part of the tmclient.TabletManagerClient interface
+func (client *FakeTabletManagerClient) StartSlaveUntilAfter(ctx context.Context, tablet *topo.TabletInfo, position myproto.ReplicationPosition, waitTime time.Duration) error {
+	return nil
+}
+
 // TabletExternallyReparented is part of the tmclient.TabletManagerClient interface
 func (client *FakeTabletManagerClient) TabletExternallyReparented(ctx context.Context, tablet *topo.TabletInfo, externalID string) error {
 	return nil
@@ -304,6 +309,16 @@ func (client *FakeTabletManagerClient) Restore(ctx context.Context, tablet *topo
 	}, nil
 }
 
+// CleanOrphans is part of the tmclient.TabletManagerClient interface
+func (client *FakeTabletManagerClient) CleanOrphans(ctx context.Context, tablet *topo.TabletInfo, args *actionnode.CleanOrphansArgs) (*myproto.OrphanCleanupReport, error) {
+	return &myproto.OrphanCleanupReport{DryRun: args.DryRun}, nil
+}
+
+// DeleteSnapshot is part of the tmclient.TabletManagerClient interface
+func (client *FakeTabletManagerClient) DeleteSnapshot(ctx context.Context, tablet *topo.TabletInfo, args *actionnode.DeleteSnapshotArgs) error {
+	return nil
+}
+
 //
 // RPC related methods
 //
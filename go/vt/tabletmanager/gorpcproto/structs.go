@@ -46,6 +46,11 @@ type StopSlaveMinimumArgs struct {
 	WaitTime time.Duration
 }
 
+type StartSlaveUntilAfterArgs struct {
+	Position myproto.ReplicationPosition
+	WaitTime time.Duration
+}
+
 type GetSlavesReply struct {
 	Addrs []string
 }
@@ -5,10 +5,15 @@
 package gorpctmclient
 
 import (
+	"flag"
 	"fmt"
+	"sync"
 	"time"
 
 	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/netutil"
+	"github.com/youtube/vitess/go/rpcplus"
+	"github.com/youtube/vitess/go/rpcwrap"
 	"github.com/youtube/vitess/go/rpcwrap/bsonrpc"
 	blproto "github.com/youtube/vitess/go/vt/binlog/proto"
 	"github.com/youtube/vitess/go/vt/dbconfigs"
@@ -20,6 +25,7 @@ import (
 	"github.com/youtube/vitess/go/vt/tabletmanager/gorpcproto"
 	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
 	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vttls"
 	"golang.org/x/net/context"
 )
 
@@ -27,6 +33,16 @@ type timeoutError struct {
 	error
 }
 
+var (
+	tabletManagerBsonTLS = vttls.RegisterClientFlags("tablet-manager-bson", "the vttablet tablet manager")
+
+	tabletManagerKeepalive   = flag.Duration("tablet_manager_keepalive", 30*time.Second, "how often to ping the pooled connections to tablet managers, 0 to disable (and to never close idle connections)")
+	tabletManagerIdleTimeout = flag.Duration("tablet_manager_idle_timeout", 5*time.Minute, "how long a pooled connection to a tablet manager can stay unused before it is closed")
+
+	poolOnce sync.Once
+	pool     *rpcwrap.ClientPool
+)
+
 func init() {
 	tmclient.RegisterTabletManagerClientFactory("bson", func() tmclient.TabletManagerClient {
 		return &GoRPCTabletManagerClient{}
@@ -36,24 +52,71 @@ func init() {
 // GoRPCTabletManagerClient implements tmclient.TabletManagerClient
 type GoRPCTabletManagerClient struct{}
 
+// tabletAddr returns the address to connect to, which is the secure
+// port of the tablet if encryption is enabled.
+func tabletAddr(tablet *topo.TabletInfo) (string, error) {
+	config, err := tabletManagerBsonTLS.Config()
+	if err != nil {
+		return "", err
+	}
+	if config == nil {
+		return tablet.Addr(), nil
+	}
+	return netutil.JoinHostPort(tablet.Hostname, tablet.Portmap["vts"]), nil
+}
+
+// dialAddr connects to a tablet address returned by tabletAddr.
+func dialAddr(addr string, connectTimeout time.Duration) (*rpcplus.Client, error) {
+	config, err := tabletManagerBsonTLS.Config()
+	if err != nil {
+		return nil, err
+	}
+	return bsonrpc.DialHTTP("tcp", addr, connectTimeout, config)
+}
+
+// dial opens a new connection to the tablet, for streaming RPCs.
+func dial(tablet *topo.TabletInfo, connectTimeout time.Duration) (*rpcplus.Client, error) {
+	addr, err := tabletAddr(tablet)
+	if err != nil {
+		return nil, err
+	}
+	return dialAddr(addr, connectTimeout)
+}
+
+// ping is used to keep the pooled connections alive.
+func ping(ctx context.Context, rpcClient *rpcplus.Client) error {
+	var result string
+	return rpcClient.Call(ctx, "TabletManager."+actionnode.TABLET_ACTION_PING, "payload", &result)
+}
+
+// clientPool returns the pool of connections shared by all the
+// non-streaming RPCs. It is created on first use, after the flags
+// have been parsed.
+func clientPool() *rpcwrap.ClientPool {
+	poolOnce.Do(func() {
+		pool = rpcwrap.NewClientPool("TabletManagerClient", dialAddr, ping, *tabletManagerKeepalive, *tabletManagerIdleTimeout)
+	})
+	return pool
+}
+
 // rpcCallTablet wil execute the RPC on the remote server.
 func (client *GoRPCTabletManagerClient) rpcCallTablet(ctx context.Context, tablet *topo.TabletInfo, name string, args, reply interface{}) error {
-	// create the RPC client, using ctx.Deadline if set, or no timeout.
-	var connectTimeout time.Duration
-	deadline, ok := ctx.Deadline()
-	if ok {
-		connectTimeout = deadline.Sub(time.Now())
-		if connectTimeout < 0 {
-			return timeoutError{fmt.Errorf("timeout connecting to TabletManager.%v on %v", name, tablet.Alias)}
-		}
+	// get a pooled RPC client, using ctx.Deadline if set, or no
+	// timeout, to connect.
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(time.Now()) {
+		return timeoutError{fmt.Errorf("timeout connecting to TabletManager.%v on %v", name, tablet.Alias)}
+	}
+	addr, err := tabletAddr(tablet)
+	if err != nil {
+		return fmt.Errorf("RPC error for %v: %v", tablet.Alias, err.Error())
 	}
-	rpcClient, err := bsonrpc.DialHTTP("tcp", tablet.Addr(), connectTimeout, nil)
+	rpcClient, err := clientPool().Get(ctx, addr)
 	if err != nil {
 		return fmt.Errorf("RPC error for %v: %v", tablet.Alias, err.Error())
 	}
-	defer rpcClient.Close()
 
 	// use the context Done() channel. Will handle context timeout.
+	// The call is also canceled on the tablet side then.
 	call := rpcClient.Go(ctx, "TabletManager."+name, args, reply, nil)
 	select {
 	case <-ctx.Done():
@@ -162,7 +225,7 @@ func (client *GoRPCTabletManagerClient) HealthStream(ctx context.Context, tablet
 			return nil, nil, timeoutError{fmt.Errorf("timeout connecting to TabletManager.HealthStream on %v", tablet.Alias)}
 		}
 	}
-	rpcClient, err := bsonrpc.DialHTTP("tcp", tablet.Addr(), connectTimeout, nil)
+	rpcClient, err := dial(tablet, connectTimeout)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -317,6 +380,14 @@ func (client *GoRPCTabletManagerClient) StartSlave(ctx context.Context, tablet *
 	return client.rpcCallTablet(ctx, tablet, actionnode.TABLET_ACTION_START_SLAVE, &rpc.Unused{}, &rpc.Unused{})
 }
 
+// StartSlaveUntilAfter is part of the tmclient.TabletManagerClient interface
+func (client *GoRPCTabletManagerClient) StartSlaveUntilAfter(ctx context.Context, tablet *topo.TabletInfo, position myproto.ReplicationPosition, waitTime time.Duration) error {
+	return client.rpcCallTablet(ctx, tablet, actionnode.TABLET_ACTION_START_SLAVE_UNTIL_AFTER, &gorpcproto.StartSlaveUntilAfterArgs{
+		Position: position,
+		WaitTime: waitTime,
+	}, &rpc.Unused{})
+}
+
 // TabletExternallyReparented is part of the tmclient.TabletManagerClient interface
 func (client *GoRPCTabletManagerClient) TabletExternallyReparented(ctx context.Context, tablet *topo.TabletInfo, externalID string) error {
 	return client.rpcCallTablet(ctx, tablet, actionnode.TABLET_ACTION_EXTERNALLY_REPARENTED, &gorpcproto.TabletExternallyReparentedArgs{ExternalID: externalID}, &rpc.Unused{})
@@ -417,7 +488,7 @@ func (client *GoRPCTabletManagerClient) Snapshot(ctx context.Context, tablet *to
 			return nil, nil, timeoutError{fmt.Errorf("timeout connecting to TabletManager.Snapshot on %v", tablet.Alias)}
 		}
 	}
-	rpcClient, err := bsonrpc.DialHTTP("tcp", tablet.Addr(), connectTimeout, nil)
+	rpcClient, err := dial(tablet, connectTimeout)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -481,7 +552,7 @@ func (client *GoRPCTabletManagerClient) Restore(ctx context.Context, tablet *top
 			return nil, nil, timeoutError{fmt.Errorf("timeout connecting to TabletManager.Restore on %v", tablet.Alias)}
 		}
 	}
-	rpcClient, err := bsonrpc.DialHTTP("tcp", tablet.Addr(), connectTimeout, nil)
+	rpcClient, err := dial(tablet, connectTimeout)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -518,6 +589,20 @@ func (client *GoRPCTabletManagerClient) Restore(ctx context.Context, tablet *top
 	}, nil
 }
 
+// CleanOrphans is part of the tmclient.TabletManagerClient interface
+func (client *GoRPCTabletManagerClient) CleanOrphans(ctx context.Context, tablet *topo.TabletInfo, args *actionnode.CleanOrphansArgs) (*myproto.OrphanCleanupReport, error) {
+	var report myproto.OrphanCleanupReport
+	if err := client.rpcCallTablet(ctx, tablet, actionnode.TABLET_ACTION_CLEAN_ORPHANS, args, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// DeleteSnapshot is part of the tmclient.TabletManagerClient interface
+func (client *GoRPCTabletManagerClient) DeleteSnapshot(ctx context.Context, tablet *topo.TabletInfo, args *actionnode.DeleteSnapshotArgs) error {
+	return client.rpcCallTablet(ctx, tablet, actionnode.TABLET_ACTION_DELETE_SNAPSHOT, args, &rpc.Unused{})
+}
+
 //
 // RPC related methods
 //
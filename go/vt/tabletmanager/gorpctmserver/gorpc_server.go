@@ -292,6 +292,14 @@ func (tm *TabletManager) StartSlave(ctx context.Context, args *rpc.Unused, reply
 	})
 }
 
+// StartSlaveUntilAfter wraps RPCAgent.
+func (tm *TabletManager) StartSlaveUntilAfter(ctx context.Context, args *gorpcproto.StartSlaveUntilAfterArgs, reply *rpc.Unused) error {
+	ctx = callinfo.RPCWrapCallInfo(ctx)
+	return tm.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_START_SLAVE_UNTIL_AFTER, args, reply, true, func() error {
+		return tm.agent.StartSlaveUntilAfter(ctx, args.Position, args.WaitTime)
+	})
+}
+
 // TabletExternallyReparented wraps RPCAgent.
 func (tm *TabletManager) TabletExternallyReparented(ctx context.Context, args *gorpcproto.TabletExternallyReparentedArgs, reply *rpc.Unused) error {
 	ctx = callinfo.RPCWrapCallInfo(ctx)
@@ -491,6 +499,26 @@ func (tm *TabletManager) Restore(ctx context.Context, args *actionnode.RestoreAr
 	})
 }
 
+// DeleteSnapshot wraps RPCAgent.
+func (tm *TabletManager) DeleteSnapshot(ctx context.Context, args *actionnode.DeleteSnapshotArgs, reply *rpc.Unused) error {
+	ctx = callinfo.RPCWrapCallInfo(ctx)
+	return tm.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_DELETE_SNAPSHOT, args, reply, true, func() error {
+		return tm.agent.DeleteSnapshot(ctx, args)
+	})
+}
+
+// CleanOrphans wraps RPCAgent.
+func (tm *TabletManager) CleanOrphans(ctx context.Context, args *actionnode.CleanOrphansArgs, reply *myproto.OrphanCleanupReport) error {
+	ctx = callinfo.RPCWrapCallInfo(ctx)
+	return tm.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_CLEAN_ORPHANS, args, reply, true, func() error {
+		report, err := tm.agent.CleanOrphans(ctx, args)
+		if err == nil {
+			*reply = *report
+		}
+		return err
+	})
+}
+
 // registration glue
 
 func init() {
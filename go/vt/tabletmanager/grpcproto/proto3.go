@@ -0,0 +1,528 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package grpcproto contains the conversions between the tablet
+// manager structures and their proto3 version, used by the gRPC
+// transport for tablet manager. It is the counterpart of gorpcproto.
+package grpcproto
+
+import (
+	"time"
+
+	blproto "github.com/youtube/vitess/go/vt/binlog/proto"
+	"github.com/youtube/vitess/go/vt/hook"
+	"github.com/youtube/vitess/go/vt/key"
+	"github.com/youtube/vitess/go/vt/logutil"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
+	"github.com/youtube/vitess/go/vt/topo"
+
+	pb "github.com/youtube/vitess/go/vt/proto/tabletmanager"
+	pbv "github.com/youtube/vitess/go/vt/proto/vtctl"
+)
+
+// TabletAliasToProto3 converts a TabletAlias.
+func TabletAliasToProto3(alias topo.TabletAlias) *pb.TabletAlias {
+	return &pb.TabletAlias{
+		Cell: alias.Cell,
+		Uid:  alias.Uid,
+	}
+}
+
+// Proto3ToTabletAlias converts a proto3 TabletAlias, which may be nil.
+func Proto3ToTabletAlias(alias *pb.TabletAlias) topo.TabletAlias {
+	if alias == nil {
+		return topo.TabletAlias{}
+	}
+	return topo.TabletAlias{
+		Cell: alias.Cell,
+		Uid:  alias.Uid,
+	}
+}
+
+// TabletToProto3 converts a Tablet, which may be nil.
+func TabletToProto3(tablet *topo.Tablet) *pb.Tablet {
+	if tablet == nil {
+		return nil
+	}
+	result := &pb.Tablet{
+		Alias:          TabletAliasToProto3(tablet.Alias),
+		Hostname:       tablet.Hostname,
+		IpAddr:         tablet.IPAddr,
+		Tags:           tablet.Tags,
+		Health:         tablet.Health,
+		Keyspace:       tablet.Keyspace,
+		Shard:          tablet.Shard,
+		Type:           string(tablet.Type),
+		DbNameOverride: tablet.DbNameOverride,
+		KeyRange: &pb.KeyRange{
+			Start: []byte(tablet.KeyRange.Start),
+			End:   []byte(tablet.KeyRange.End),
+		},
+		LastHeartbeat: tablet.LastHeartbeat,
+		MasterTerm:    tablet.MasterTerm,
+		SchemaVersion: tablet.SchemaVersion,
+	}
+	if len(tablet.Portmap) > 0 {
+		result.Portmap = make(map[string]int32, len(tablet.Portmap))
+		for name, port := range tablet.Portmap {
+			result.Portmap[name] = int32(port)
+		}
+	}
+	return result
+}
+
+// Proto3ToTablet converts a proto3 Tablet, which may be nil. The
+// Portmap of the result is never nil.
+func Proto3ToTablet(tablet *pb.Tablet) *topo.Tablet {
+	if tablet == nil {
+		return nil
+	}
+	result := &topo.Tablet{
+		Alias:          Proto3ToTabletAlias(tablet.Alias),
+		Hostname:       tablet.Hostname,
+		IPAddr:         tablet.IpAddr,
+		Portmap:        make(map[string]int, len(tablet.Portmap)),
+		Tags:           tablet.Tags,
+		Health:         tablet.Health,
+		Keyspace:       tablet.Keyspace,
+		Shard:          tablet.Shard,
+		Type:           topo.TabletType(tablet.Type),
+		DbNameOverride: tablet.DbNameOverride,
+		LastHeartbeat:  tablet.LastHeartbeat,
+		MasterTerm:     tablet.MasterTerm,
+		SchemaVersion:  tablet.SchemaVersion,
+	}
+	for name, port := range tablet.Portmap {
+		result.Portmap[name] = int(port)
+	}
+	if tablet.KeyRange != nil {
+		result.KeyRange = key.KeyRange{
+			Start: key.KeyspaceId(tablet.KeyRange.Start),
+			End:   key.KeyspaceId(tablet.KeyRange.End),
+		}
+	}
+	return result
+}
+
+// SchemaDefinitionToProto3 converts a SchemaDefinition, which may be nil.
+func SchemaDefinitionToProto3(sd *myproto.SchemaDefinition) *pb.SchemaDefinition {
+	if sd == nil {
+		return nil
+	}
+	result := &pb.SchemaDefinition{
+		DatabaseSchema: sd.DatabaseSchema,
+		Version:        sd.Version,
+	}
+	if len(sd.TableDefinitions) > 0 {
+		result.TableDefinitions = make([]*pb.TableDefinition, len(sd.TableDefinitions))
+		for i, td := range sd.TableDefinitions {
+			result.TableDefinitions[i] = &pb.TableDefinition{
+				Name:              td.Name,
+				Schema:            td.Schema,
+				Columns:           td.Columns,
+				PrimaryKeyColumns: td.PrimaryKeyColumns,
+				Type:              td.Type,
+				DataLength:        td.DataLength,
+				RowCount:          td.RowCount,
+			}
+		}
+	}
+	return result
+}
+
+// Proto3ToSchemaDefinition converts a proto3 SchemaDefinition, which
+// may be nil.
+func Proto3ToSchemaDefinition(sd *pb.SchemaDefinition) *myproto.SchemaDefinition {
+	if sd == nil {
+		return nil
+	}
+	result := &myproto.SchemaDefinition{
+		DatabaseSchema: sd.DatabaseSchema,
+		Version:        sd.Version,
+	}
+	if len(sd.TableDefinitions) > 0 {
+		result.TableDefinitions = make(myproto.TableDefinitions, len(sd.TableDefinitions))
+		for i, td := range sd.TableDefinitions {
+			result.TableDefinitions[i] = &myproto.TableDefinition{
+				Name:              td.Name,
+				Schema:            td.Schema,
+				Columns:           td.Columns,
+				PrimaryKeyColumns: td.PrimaryKeyColumns,
+				Type:              td.Type,
+				DataLength:        td.DataLength,
+				RowCount:          td.RowCount,
+			}
+		}
+	}
+	return result
+}
+
+// SchemaChangeToProto3 converts a SchemaChange, which may be nil.
+func SchemaChangeToProto3(sc *myproto.SchemaChange) *pb.SchemaChange {
+	if sc == nil {
+		return nil
+	}
+	return &pb.SchemaChange{
+		Sql:              sc.Sql,
+		Force:            sc.Force,
+		AllowReplication: sc.AllowReplication,
+		BeforeSchema:     SchemaDefinitionToProto3(sc.BeforeSchema),
+		AfterSchema:      SchemaDefinitionToProto3(sc.AfterSchema),
+	}
+}
+
+// Proto3ToSchemaChange converts a proto3 SchemaChange, which may be nil.
+func Proto3ToSchemaChange(sc *pb.SchemaChange) *myproto.SchemaChange {
+	if sc == nil {
+		return nil
+	}
+	return &myproto.SchemaChange{
+		Sql:              sc.Sql,
+		Force:            sc.Force,
+		AllowReplication: sc.AllowReplication,
+		BeforeSchema:     Proto3ToSchemaDefinition(sc.BeforeSchema),
+		AfterSchema:      Proto3ToSchemaDefinition(sc.AfterSchema),
+	}
+}
+
+// SchemaChangeResultToProto3 converts a SchemaChangeResult, which may
+// be nil.
+func SchemaChangeResultToProto3(scr *myproto.SchemaChangeResult) *pb.SchemaChangeResult {
+	if scr == nil {
+		return nil
+	}
+	return &pb.SchemaChangeResult{
+		BeforeSchema: SchemaDefinitionToProto3(scr.BeforeSchema),
+		AfterSchema:  SchemaDefinitionToProto3(scr.AfterSchema),
+	}
+}
+
+// Proto3ToSchemaChangeResult converts a proto3 SchemaChangeResult.
+// The result is never nil.
+func Proto3ToSchemaChangeResult(scr *pb.SchemaChangeResult) *myproto.SchemaChangeResult {
+	if scr == nil {
+		return &myproto.SchemaChangeResult{}
+	}
+	return &myproto.SchemaChangeResult{
+		BeforeSchema: Proto3ToSchemaDefinition(scr.BeforeSchema),
+		AfterSchema:  Proto3ToSchemaDefinition(scr.AfterSchema),
+	}
+}
+
+// PermissionsToProto3 converts Permissions, which may be nil.
+func PermissionsToProto3(p *myproto.Permissions) *pb.Permissions {
+	if p == nil {
+		return nil
+	}
+	result := &pb.Permissions{}
+	if len(p.UserPermissions) > 0 {
+		result.UserPermissions = make([]*pb.UserPermission, len(p.UserPermissions))
+		for i, up := range p.UserPermissions {
+			result.UserPermissions[i] = &pb.UserPermission{
+				Host:             up.Host,
+				User:             up.User,
+				PasswordChecksum: up.PasswordChecksum,
+				Privileges:       up.Privileges,
+			}
+		}
+	}
+	if len(p.DbPermissions) > 0 {
+		result.DbPermissions = make([]*pb.DbPermission, len(p.DbPermissions))
+		for i, dp := range p.DbPermissions {
+			result.DbPermissions[i] = &pb.DbPermission{
+				Host:       dp.Host,
+				Db:         dp.Db,
+				User:       dp.User,
+				Privileges: dp.Privileges,
+			}
+		}
+	}
+	if len(p.HostPermissions) > 0 {
+		result.HostPermissions = make([]*pb.HostPermission, len(p.HostPermissions))
+		for i, hp := range p.HostPermissions {
+			result.HostPermissions[i] = &pb.HostPermission{
+				Host:       hp.Host,
+				Db:         hp.Db,
+				Privileges: hp.Privileges,
+			}
+		}
+	}
+	return result
+}
+
+// Proto3ToPermissions converts proto3 Permissions. The result is
+// never nil.
+func Proto3ToPermissions(p *pb.Permissions) *myproto.Permissions {
+	result := &myproto.Permissions{}
+	if p == nil {
+		return result
+	}
+	if len(p.UserPermissions) > 0 {
+		result.UserPermissions = make(myproto.UserPermissionList, len(p.UserPermissions))
+		for i, up := range p.UserPermissions {
+			result.UserPermissions[i] = &myproto.UserPermission{
+				Host:             up.Host,
+				User:             up.User,
+				PasswordChecksum: up.PasswordChecksum,
+				Privileges:       up.Privileges,
+			}
+		}
+	}
+	if len(p.DbPermissions) > 0 {
+		result.DbPermissions = make(myproto.DbPermissionList, len(p.DbPermissions))
+		for i, dp := range p.DbPermissions {
+			result.DbPermissions[i] = &myproto.DbPermission{
+				Host:       dp.Host,
+				Db:         dp.Db,
+				User:       dp.User,
+				Privileges: dp.Privileges,
+			}
+		}
+	}
+	if len(p.HostPermissions) > 0 {
+		result.HostPermissions = make(myproto.HostPermissionList, len(p.HostPermissions))
+		for i, hp := range p.HostPermissions {
+			result.HostPermissions[i] = &myproto.HostPermission{
+				Host:       hp.Host,
+				Db:         hp.Db,
+				Privileges: hp.Privileges,
+			}
+		}
+	}
+	return result
+}
+
+// ReplicationStatusToProto3 converts a ReplicationStatus, which may
+// be nil.
+func ReplicationStatusToProto3(status *myproto.ReplicationStatus) *pb.ReplicationStatus {
+	if status == nil {
+		return nil
+	}
+	return &pb.ReplicationStatus{
+		Position:            myproto.EncodeReplicationPosition(status.Position),
+		SlaveIoRunning:      status.SlaveIORunning,
+		SlaveSqlRunning:     status.SlaveSQLRunning,
+		SecondsBehindMaster: uint64(status.SecondsBehindMaster),
+		MasterHost:          status.MasterHost,
+		MasterPort:          int64(status.MasterPort),
+		MasterConnectRetry:  int64(status.MasterConnectRetry),
+	}
+}
+
+// Proto3ToReplicationStatus converts a proto3 ReplicationStatus,
+// which may be nil.
+func Proto3ToReplicationStatus(status *pb.ReplicationStatus) (*myproto.ReplicationStatus, error) {
+	if status == nil {
+		return nil, nil
+	}
+	position, err := myproto.DecodeReplicationPosition(status.Position)
+	if err != nil {
+		return nil, err
+	}
+	return &myproto.ReplicationStatus{
+		Position:            position,
+		SlaveIORunning:      status.SlaveIoRunning,
+		SlaveSQLRunning:     status.SlaveSqlRunning,
+		SecondsBehindMaster: uint(status.SecondsBehindMaster),
+		MasterHost:          status.MasterHost,
+		MasterPort:          int(status.MasterPort),
+		MasterConnectRetry:  int(status.MasterConnectRetry),
+	}, nil
+}
+
+// BlpPositionToProto3 converts a BlpPosition.
+func BlpPositionToProto3(blpPosition *blproto.BlpPosition) *pb.BlpPosition {
+	return &pb.BlpPosition{
+		Uid:      blpPosition.Uid,
+		Position: myproto.EncodeReplicationPosition(blpPosition.Position),
+	}
+}
+
+// Proto3ToBlpPosition converts a proto3 BlpPosition, which may be nil.
+func Proto3ToBlpPosition(blpPosition *pb.BlpPosition) (*blproto.BlpPosition, error) {
+	if blpPosition == nil {
+		return &blproto.BlpPosition{}, nil
+	}
+	position, err := myproto.DecodeReplicationPosition(blpPosition.Position)
+	if err != nil {
+		return nil, err
+	}
+	return &blproto.BlpPosition{
+		Uid:      blpPosition.Uid,
+		Position: position,
+	}, nil
+}
+
+// BlpPositionListToProto3 converts a BlpPositionList, which may be nil.
+func BlpPositionListToProto3(list *blproto.BlpPositionList) []*pb.BlpPosition {
+	if list == nil || len(list.Entries) == 0 {
+		return nil
+	}
+	result := make([]*pb.BlpPosition, len(list.Entries))
+	for i := range list.Entries {
+		result[i] = BlpPositionToProto3(&list.Entries[i])
+	}
+	return result
+}
+
+// Proto3ToBlpPositionList converts a proto3 list of BlpPosition.
+func Proto3ToBlpPositionList(list []*pb.BlpPosition) (*blproto.BlpPositionList, error) {
+	result := &blproto.BlpPositionList{}
+	if len(list) == 0 {
+		return result, nil
+	}
+	result.Entries = make([]blproto.BlpPosition, len(list))
+	for i, blpPosition := range list {
+		bp, err := Proto3ToBlpPosition(blpPosition)
+		if err != nil {
+			return nil, err
+		}
+		result.Entries[i] = *bp
+	}
+	return result, nil
+}
+
+// RestartSlaveDataToProto3 converts a RestartSlaveData, which may be nil.
+func RestartSlaveDataToProto3(rsd *actionnode.RestartSlaveData) *pb.RestartSlaveData {
+	if rsd == nil {
+		return nil
+	}
+	return &pb.RestartSlaveData{
+		ReplicationStatus: ReplicationStatusToProto3(rsd.ReplicationStatus),
+		WaitPosition:      myproto.EncodeReplicationPosition(rsd.WaitPosition),
+		TimePromoted:      rsd.TimePromoted,
+		Parent:            TabletAliasToProto3(rsd.Parent),
+		Force:             rsd.Force,
+	}
+}
+
+// Proto3ToRestartSlaveData converts a proto3 RestartSlaveData. The
+// result is never nil.
+func Proto3ToRestartSlaveData(rsd *pb.RestartSlaveData) (*actionnode.RestartSlaveData, error) {
+	if rsd == nil {
+		return &actionnode.RestartSlaveData{}, nil
+	}
+	status, err := Proto3ToReplicationStatus(rsd.ReplicationStatus)
+	if err != nil {
+		return nil, err
+	}
+	waitPosition, err := myproto.DecodeReplicationPosition(rsd.WaitPosition)
+	if err != nil {
+		return nil, err
+	}
+	return &actionnode.RestartSlaveData{
+		ReplicationStatus: status,
+		WaitPosition:      waitPosition,
+		TimePromoted:      rsd.TimePromoted,
+		Parent:            Proto3ToTabletAlias(rsd.Parent),
+		Force:             rsd.Force,
+	}, nil
+}
+
+// HookToProto3 converts a Hook into an ExecuteHookRequest.
+func HookToProto3(hk *hook.Hook) *pb.ExecuteHookRequest {
+	return &pb.ExecuteHookRequest{
+		Name:       hk.Name,
+		Parameters: hk.Parameters,
+		ExtraEnv:   hk.ExtraEnv,
+	}
+}
+
+// Proto3ToHook converts an ExecuteHookRequest into a Hook.
+func Proto3ToHook(request *pb.ExecuteHookRequest) *hook.Hook {
+	return &hook.Hook{
+		Name:       request.Name,
+		Parameters: request.Parameters,
+		ExtraEnv:   request.ExtraEnv,
+	}
+}
+
+// SnapshotReplyToProto3 converts a SnapshotReply, which may be nil.
+func SnapshotReplyToProto3(sr *actionnode.SnapshotReply) *pb.SnapshotReply {
+	if sr == nil {
+		return nil
+	}
+	return &pb.SnapshotReply{
+		ParentAlias:        TabletAliasToProto3(sr.ParentAlias),
+		ManifestPath:       sr.ManifestPath,
+		SlaveStartRequired: sr.SlaveStartRequired,
+		ReadOnly:           sr.ReadOnly,
+	}
+}
+
+// Proto3ToSnapshotReply converts a proto3 SnapshotReply. The result
+// is never nil.
+func Proto3ToSnapshotReply(sr *pb.SnapshotReply) *actionnode.SnapshotReply {
+	if sr == nil {
+		return &actionnode.SnapshotReply{}
+	}
+	return &actionnode.SnapshotReply{
+		ParentAlias:        Proto3ToTabletAlias(sr.ParentAlias),
+		ManifestPath:       sr.ManifestPath,
+		SlaveStartRequired: sr.SlaveStartRequired,
+		ReadOnly:           sr.ReadOnly,
+	}
+}
+
+// OrphanedFilesToProto3 converts the files of an OrphanCleanupReport.
+func OrphanedFilesToProto3(files []*myproto.OrphanedFile) []*pb.OrphanedFile {
+	if len(files) == 0 {
+		return nil
+	}
+	result := make([]*pb.OrphanedFile, len(files))
+	for i, f := range files {
+		result[i] = &pb.OrphanedFile{
+			Path:    f.Path,
+			Size:    f.Size,
+			ModTime: f.ModTime,
+		}
+	}
+	return result
+}
+
+// Proto3ToOrphanedFiles converts the proto3 files of an
+// OrphanCleanupReport.
+func Proto3ToOrphanedFiles(files []*pb.OrphanedFile) []*myproto.OrphanedFile {
+	if len(files) == 0 {
+		return nil
+	}
+	result := make([]*myproto.OrphanedFile, len(files))
+	for i, f := range files {
+		result[i] = &myproto.OrphanedFile{
+			Path:    f.Path,
+			Size:    f.Size,
+			ModTime: f.ModTime,
+		}
+	}
+	return result
+}
+
+// LoggerEventToProto3 converts a LoggerEvent.
+func LoggerEventToProto3(e *logutil.LoggerEvent) *pbv.LoggerEvent {
+	return &pbv.LoggerEvent{
+		Time: &pbv.Time{
+			Seconds:     e.Time.Unix(),
+			Nanoseconds: int64(e.Time.Nanosecond()),
+		},
+		Level: int64(e.Level),
+		File:  e.File,
+		Line:  int64(e.Line),
+		Value: e.Value,
+	}
+}
+
+// Proto3ToLoggerEvent converts a proto3 LoggerEvent.
+func Proto3ToLoggerEvent(e *pbv.LoggerEvent) *logutil.LoggerEvent {
+	result := &logutil.LoggerEvent{
+		Level: int(e.Level),
+		File:  e.File,
+		Line:  int(e.Line),
+		Value: e.Value,
+	}
+	if e.Time != nil {
+		result.Time = time.Unix(e.Time.Seconds, e.Time.Nanoseconds)
+	}
+	return result
+}
@@ -0,0 +1,837 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package grpctmclient contains the gRPC implementation of the client
+// side of the tablet manager API, selected with
+// -tablet_manager_protocol grpc. It mirrors gorpctmclient.
+package grpctmclient
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/netutil"
+	blproto "github.com/youtube/vitess/go/vt/binlog/proto"
+	"github.com/youtube/vitess/go/vt/dbconfigs"
+	"github.com/youtube/vitess/go/vt/hook"
+	"github.com/youtube/vitess/go/vt/logutil"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
+	"github.com/youtube/vitess/go/vt/tabletmanager/grpcproto"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vttls"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	pbq "github.com/youtube/vitess/go/vt/proto/query"
+	pb "github.com/youtube/vitess/go/vt/proto/tabletmanager"
+)
+
+var tabletManagerGRPCTLS = vttls.RegisterClientFlags("tablet-manager-grpc", "the vttablet tablet manager over gRPC")
+
+type timeoutError struct {
+	error
+}
+
+func init() {
+	tmclient.RegisterTabletManagerClientFactory("grpc", func() tmclient.TabletManagerClient {
+		return &Client{}
+	})
+}
+
+// Client implements tmclient.TabletManagerClient
+type Client struct{}
+
+// dial returns a client to use, connected to the grpc port of the
+// tablet. The connection has to be closed by the caller.
+func (client *Client) dial(ctx context.Context, tablet *topo.TabletInfo) (*grpc.ClientConn, pb.TabletManagerClient, error) {
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(time.Now()) {
+		return nil, nil, timeoutError{fmt.Errorf("timeout connecting to TabletManager on %v", tablet.Alias)}
+	}
+	port, ok := tablet.Portmap["grpc"]
+	if !ok {
+		return nil, nil, fmt.Errorf("RPC error for %v: no grpc port", tablet.Alias)
+	}
+	config, err := tabletManagerGRPCTLS.Config()
+	if err != nil {
+		return nil, nil, err
+	}
+	var opts []grpc.DialOption
+	if config != nil {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+	}
+	cc, err := grpc.Dial(netutil.JoinHostPort(tablet.Hostname, port), opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("RPC error for %v: %v", tablet.Alias, err)
+	}
+	return cc, pb.NewTabletManagerClient(cc), nil
+}
+
+// callError returns the error of a call, either from gRPC itself or
+// from the tablet, or nil.
+func callError(ctx context.Context, tablet *topo.TabletInfo, err error, rpcErr *pbq.RPCError) error {
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return timeoutError{fmt.Errorf("timeout waiting for TabletManager on %v: %v", tablet.Alias, err)}
+		}
+		return fmt.Errorf("RPC error for %v: %v", tablet.Alias, err)
+	}
+	if rpcErr != nil {
+		return fmt.Errorf("remote error for %v: %v", tablet.Alias, rpcErr.Message)
+	}
+	return nil
+}
+
+//
+// Various read-only methods
+//
+
+// Ping is part of the tmclient.TabletManagerClient interface
+func (client *Client) Ping(ctx context.Context, tablet *topo.TabletInfo) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.Ping(ctx, &pb.PingRequest{
+		Payload: "payload",
+	})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return err
+	}
+	if response.Payload != "payload" {
+		return fmt.Errorf("bad ping result: %v", response.Payload)
+	}
+	return nil
+}
+
+// Sleep is part of the tmclient.TabletManagerClient interface
+func (client *Client) Sleep(ctx context.Context, tablet *topo.TabletInfo, duration time.Duration) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.Sleep(ctx, &pb.SleepRequest{
+		Duration: int64(duration),
+	})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// ExecuteHook is part of the tmclient.TabletManagerClient interface
+func (client *Client) ExecuteHook(ctx context.Context, tablet *topo.TabletInfo, hk *hook.Hook) (*hook.HookResult, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	response, err := c.ExecuteHook(ctx, grpcproto.HookToProto3(hk))
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return nil, err
+	}
+	return &hook.HookResult{
+		ExitStatus: int(response.ExitStatus),
+		Stdout:     response.Stdout,
+		Stderr:     response.Stderr,
+	}, nil
+}
+
+// GetSchema is part of the tmclient.TabletManagerClient interface
+func (client *Client) GetSchema(ctx context.Context, tablet *topo.TabletInfo, tables, excludeTables []string, includeViews bool) (*myproto.SchemaDefinition, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	response, err := c.GetSchema(ctx, &pb.GetSchemaRequest{
+		Tables:        tables,
+		ExcludeTables: excludeTables,
+		IncludeViews:  includeViews,
+	})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return nil, err
+	}
+	sd := grpcproto.Proto3ToSchemaDefinition(response.SchemaDefinition)
+	if sd == nil {
+		sd = &myproto.SchemaDefinition{}
+	}
+	return sd, nil
+}
+
+// GetPermissions is part of the tmclient.TabletManagerClient interface
+func (client *Client) GetPermissions(ctx context.Context, tablet *topo.TabletInfo) (*myproto.Permissions, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	response, err := c.GetPermissions(ctx, &pb.GetPermissionsRequest{})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return nil, err
+	}
+	return grpcproto.Proto3ToPermissions(response.Permissions), nil
+}
+
+//
+// Various read-write methods
+//
+
+// SetReadOnly is part of the tmclient.TabletManagerClient interface
+func (client *Client) SetReadOnly(ctx context.Context, tablet *topo.TabletInfo) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.SetReadOnly(ctx, &pb.SetReadOnlyRequest{})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// SetReadWrite is part of the tmclient.TabletManagerClient interface
+func (client *Client) SetReadWrite(ctx context.Context, tablet *topo.TabletInfo) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.SetReadWrite(ctx, &pb.SetReadWriteRequest{})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// ChangeType is part of the tmclient.TabletManagerClient interface
+func (client *Client) ChangeType(ctx context.Context, tablet *topo.TabletInfo, dbType topo.TabletType) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.ChangeType(ctx, &pb.ChangeTypeRequest{
+		TabletType: string(dbType),
+	})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// Scrap is part of the tmclient.TabletManagerClient interface
+func (client *Client) Scrap(ctx context.Context, tablet *topo.TabletInfo) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.Scrap(ctx, &pb.ScrapRequest{})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// RefreshState is part of the tmclient.TabletManagerClient interface
+func (client *Client) RefreshState(ctx context.Context, tablet *topo.TabletInfo) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.RefreshState(ctx, &pb.RefreshStateRequest{})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// RunHealthCheck is part of the tmclient.TabletManagerClient interface
+func (client *Client) RunHealthCheck(ctx context.Context, tablet *topo.TabletInfo, targetTabletType topo.TabletType) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.RunHealthCheck(ctx, &pb.RunHealthCheckRequest{
+		TabletType: string(targetTabletType),
+	})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// HealthStream is part of the tmclient.TabletManagerClient interface
+func (client *Client) HealthStream(ctx context.Context, tablet *topo.TabletInfo) (<-chan *actionnode.HealthStreamReply, tmclient.ErrFunc, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, nil, err
+	}
+	stream, err := c.HealthStream(ctx, &pb.HealthStreamRequest{})
+	if err != nil {
+		cc.Close()
+		return nil, nil, callError(ctx, tablet, err, nil)
+	}
+
+	logstream := make(chan *actionnode.HealthStreamReply, 10)
+	var finalError error
+	go func() {
+		defer func() {
+			close(logstream)
+			cc.Close()
+		}()
+		for {
+			response, err := stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+				finalError = err
+				return
+			}
+			logstream <- &actionnode.HealthStreamReply{
+				Tablet:              grpcproto.Proto3ToTablet(response.Tablet),
+				BinlogPlayerMapSize: response.BinlogPlayerMapSize,
+				HealthError:         response.HealthError,
+				ReplicationDelay:    time.Duration(response.ReplicationDelay),
+			}
+		}
+	}()
+	return logstream, func() error {
+		// this is only called after streaming is done
+		return finalError
+	}, nil
+}
+
+// ReloadSchema is part of the tmclient.TabletManagerClient interface
+func (client *Client) ReloadSchema(ctx context.Context, tablet *topo.TabletInfo) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.ReloadSchema(ctx, &pb.ReloadSchemaRequest{})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// PreflightSchema is part of the tmclient.TabletManagerClient interface
+func (client *Client) PreflightSchema(ctx context.Context, tablet *topo.TabletInfo, change string) (*myproto.SchemaChangeResult, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	response, err := c.PreflightSchema(ctx, &pb.PreflightSchemaRequest{
+		Change: change,
+	})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return nil, err
+	}
+	return grpcproto.Proto3ToSchemaChangeResult(response.Result), nil
+}
+
+// ApplySchema is part of the tmclient.TabletManagerClient interface
+func (client *Client) ApplySchema(ctx context.Context, tablet *topo.TabletInfo, change *myproto.SchemaChange) (*myproto.SchemaChangeResult, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	response, err := c.ApplySchema(ctx, &pb.ApplySchemaRequest{
+		Change: grpcproto.SchemaChangeToProto3(change),
+	})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return nil, err
+	}
+	return grpcproto.Proto3ToSchemaChangeResult(response.Result), nil
+}
+
+// executeFetch runs ExecuteFetch with the provided db config.
+func (client *Client) executeFetch(ctx context.Context, tablet *topo.TabletInfo, query string, maxRows int, wantFields, disableBinlogs bool, dbConfigName dbconfigs.DbConfigName) (*mproto.QueryResult, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	response, err := c.ExecuteFetch(ctx, &pb.ExecuteFetchRequest{
+		Query:          query,
+		MaxRows:        int64(maxRows),
+		WantFields:     wantFields,
+		DisableBinlogs: disableBinlogs,
+		DbConfigName:   string(dbConfigName),
+	})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return nil, err
+	}
+	return tproto.Proto3ToQueryResult(response.Result)
+}
+
+// ExecuteFetchAsDba is part of the tmclient.TabletManagerClient interface
+func (client *Client) ExecuteFetchAsDba(ctx context.Context, tablet *topo.TabletInfo, query string, maxRows int, wantFields, disableBinlogs bool) (*mproto.QueryResult, error) {
+	return client.executeFetch(ctx, tablet, query, maxRows, wantFields, disableBinlogs, dbconfigs.DbaConfigName)
+}
+
+// ExecuteFetchAsApp is part of the tmclient.TabletManagerClient interface
+func (client *Client) ExecuteFetchAsApp(ctx context.Context, tablet *topo.TabletInfo, query string, maxRows int, wantFields bool) (*mproto.QueryResult, error) {
+	return client.executeFetch(ctx, tablet, query, maxRows, wantFields, false, dbconfigs.AppConfigName)
+}
+
+//
+// Replication related methods
+//
+
+// SlaveStatus is part of the tmclient.TabletManagerClient interface
+func (client *Client) SlaveStatus(ctx context.Context, tablet *topo.TabletInfo) (*myproto.ReplicationStatus, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	response, err := c.SlaveStatus(ctx, &pb.SlaveStatusRequest{})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return nil, err
+	}
+	return replicationStatus(response.Status)
+}
+
+// replicationStatus converts a proto3 ReplicationStatus, and never
+// returns a nil status without an error.
+func replicationStatus(status *pb.ReplicationStatus) (*myproto.ReplicationStatus, error) {
+	rs, err := grpcproto.Proto3ToReplicationStatus(status)
+	if err != nil {
+		return nil, err
+	}
+	if rs == nil {
+		rs = &myproto.ReplicationStatus{}
+	}
+	return rs, nil
+}
+
+// WaitSlavePosition is part of the tmclient.TabletManagerClient interface
+func (client *Client) WaitSlavePosition(ctx context.Context, tablet *topo.TabletInfo, waitPos myproto.ReplicationPosition, waitTime time.Duration) (*myproto.ReplicationStatus, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	response, err := c.WaitSlavePosition(ctx, &pb.WaitSlavePositionRequest{
+		Position:    myproto.EncodeReplicationPosition(waitPos),
+		WaitTimeout: int64(waitTime),
+	})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return nil, err
+	}
+	return replicationStatus(response.Status)
+}
+
+// MasterPosition is part of the tmclient.TabletManagerClient interface
+func (client *Client) MasterPosition(ctx context.Context, tablet *topo.TabletInfo) (myproto.ReplicationPosition, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return myproto.ReplicationPosition{}, err
+	}
+	defer cc.Close()
+	response, err := c.MasterPosition(ctx, &pb.MasterPositionRequest{})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return myproto.ReplicationPosition{}, err
+	}
+	return myproto.DecodeReplicationPosition(response.Position)
+}
+
+// ReparentPosition is part of the tmclient.TabletManagerClient interface
+func (client *Client) ReparentPosition(ctx context.Context, tablet *topo.TabletInfo, rp *myproto.ReplicationPosition) (*actionnode.RestartSlaveData, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	response, err := c.ReparentPosition(ctx, &pb.ReparentPositionRequest{
+		Position: myproto.EncodeReplicationPosition(*rp),
+	})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return nil, err
+	}
+	return grpcproto.Proto3ToRestartSlaveData(response.RestartSlaveData)
+}
+
+// StopSlave is part of the tmclient.TabletManagerClient interface
+func (client *Client) StopSlave(ctx context.Context, tablet *topo.TabletInfo) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.StopSlave(ctx, &pb.StopSlaveRequest{})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// StopSlaveMinimum is part of the tmclient.TabletManagerClient interface
+func (client *Client) StopSlaveMinimum(ctx context.Context, tablet *topo.TabletInfo, minPos myproto.ReplicationPosition, waitTime time.Duration) (*myproto.ReplicationStatus, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	response, err := c.StopSlaveMinimum(ctx, &pb.StopSlaveMinimumRequest{
+		Position: myproto.EncodeReplicationPosition(minPos),
+		WaitTime: int64(waitTime),
+	})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return nil, err
+	}
+	return replicationStatus(response.Status)
+}
+
+// StartSlave is part of the tmclient.TabletManagerClient interface
+func (client *Client) StartSlave(ctx context.Context, tablet *topo.TabletInfo) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.StartSlave(ctx, &pb.StartSlaveRequest{})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// StartSlaveUntilAfter is part of the tmclient.TabletManagerClient interface
+func (client *Client) StartSlaveUntilAfter(ctx context.Context, tablet *topo.TabletInfo, position myproto.ReplicationPosition, waitTime time.Duration) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.StartSlaveUntilAfter(ctx, &pb.StartSlaveUntilAfterRequest{
+		Position: myproto.EncodeReplicationPosition(position),
+		WaitTime: int64(waitTime),
+	})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// TabletExternallyReparented is part of the tmclient.TabletManagerClient interface
+func (client *Client) TabletExternallyReparented(ctx context.Context, tablet *topo.TabletInfo, externalID string) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.TabletExternallyReparented(ctx, &pb.TabletExternallyReparentedRequest{
+		ExternalId: externalID,
+	})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// GetSlaves is part of the tmclient.TabletManagerClient interface
+func (client *Client) GetSlaves(ctx context.Context, tablet *topo.TabletInfo) ([]string, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	response, err := c.GetSlaves(ctx, &pb.GetSlavesRequest{})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return nil, err
+	}
+	return response.Addrs, nil
+}
+
+// WaitBlpPosition is part of the tmclient.TabletManagerClient interface
+func (client *Client) WaitBlpPosition(ctx context.Context, tablet *topo.TabletInfo, blpPosition blproto.BlpPosition, waitTime time.Duration) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.WaitBlpPosition(ctx, &pb.WaitBlpPositionRequest{
+		BlpPosition: grpcproto.BlpPositionToProto3(&blpPosition),
+		WaitTimeout: int64(waitTime),
+	})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// StopBlp is part of the tmclient.TabletManagerClient interface
+func (client *Client) StopBlp(ctx context.Context, tablet *topo.TabletInfo) (*blproto.BlpPositionList, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	response, err := c.StopBlp(ctx, &pb.StopBlpRequest{})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return nil, err
+	}
+	return grpcproto.Proto3ToBlpPositionList(response.BlpPositions)
+}
+
+// StartBlp is part of the tmclient.TabletManagerClient interface
+func (client *Client) StartBlp(ctx context.Context, tablet *topo.TabletInfo) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.StartBlp(ctx, &pb.StartBlpRequest{})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// RunBlpUntil is part of the tmclient.TabletManagerClient interface
+func (client *Client) RunBlpUntil(ctx context.Context, tablet *topo.TabletInfo, positions *blproto.BlpPositionList, waitTime time.Duration) (myproto.ReplicationPosition, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return myproto.ReplicationPosition{}, err
+	}
+	defer cc.Close()
+	response, err := c.RunBlpUntil(ctx, &pb.RunBlpUntilRequest{
+		BlpPositions: grpcproto.BlpPositionListToProto3(positions),
+		WaitTimeout:  int64(waitTime),
+	})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return myproto.ReplicationPosition{}, err
+	}
+	return myproto.DecodeReplicationPosition(response.Position)
+}
+
+//
+// Reparenting related functions
+//
+
+// DemoteMaster is part of the tmclient.TabletManagerClient interface
+func (client *Client) DemoteMaster(ctx context.Context, tablet *topo.TabletInfo) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.DemoteMaster(ctx, &pb.DemoteMasterRequest{})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// PromoteSlave is part of the tmclient.TabletManagerClient interface
+func (client *Client) PromoteSlave(ctx context.Context, tablet *topo.TabletInfo) (*actionnode.RestartSlaveData, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	response, err := c.PromoteSlave(ctx, &pb.PromoteSlaveRequest{})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return nil, err
+	}
+	return grpcproto.Proto3ToRestartSlaveData(response.RestartSlaveData)
+}
+
+// SlaveWasPromoted is part of the tmclient.TabletManagerClient interface
+func (client *Client) SlaveWasPromoted(ctx context.Context, tablet *topo.TabletInfo) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.SlaveWasPromoted(ctx, &pb.SlaveWasPromotedRequest{})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// RestartSlave is part of the tmclient.TabletManagerClient interface
+func (client *Client) RestartSlave(ctx context.Context, tablet *topo.TabletInfo, rsd *actionnode.RestartSlaveData) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.RestartSlave(ctx, &pb.RestartSlaveRequest{
+		RestartSlaveData: grpcproto.RestartSlaveDataToProto3(rsd),
+	})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// SlaveWasRestarted is part of the tmclient.TabletManagerClient interface
+func (client *Client) SlaveWasRestarted(ctx context.Context, tablet *topo.TabletInfo, args *actionnode.SlaveWasRestartedArgs) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.SlaveWasRestarted(ctx, &pb.SlaveWasRestartedRequest{
+		Parent: grpcproto.TabletAliasToProto3(args.Parent),
+	})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// BreakSlaves is part of the tmclient.TabletManagerClient interface
+func (client *Client) BreakSlaves(ctx context.Context, tablet *topo.TabletInfo) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.BreakSlaves(ctx, &pb.BreakSlavesRequest{})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+//
+// Backup related methods
+//
+
+// Snapshot is part of the tmclient.TabletManagerClient interface
+func (client *Client) Snapshot(ctx context.Context, tablet *topo.TabletInfo, sa *actionnode.SnapshotArgs) (<-chan *logutil.LoggerEvent, tmclient.SnapshotReplyFunc, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, nil, err
+	}
+	stream, err := c.Snapshot(ctx, &pb.SnapshotRequest{
+		Concurrency:         int64(sa.Concurrency),
+		ServerMode:          sa.ServerMode,
+		ForceMasterSnapshot: sa.ForceMasterSnapshot,
+	})
+	if err != nil {
+		cc.Close()
+		return nil, nil, callError(ctx, tablet, err, nil)
+	}
+
+	logstream := make(chan *logutil.LoggerEvent, 10)
+	result := &actionnode.SnapshotReply{}
+	var finalError error
+	go func() {
+		defer func() {
+			close(logstream)
+			cc.Close()
+		}()
+		for {
+			response, err := stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+				finalError = err
+				return
+			}
+			if response.LogEvent != nil {
+				logstream <- grpcproto.Proto3ToLoggerEvent(response.LogEvent)
+			}
+			if response.Result != nil {
+				result = grpcproto.Proto3ToSnapshotReply(response.Result)
+			}
+		}
+	}()
+	return logstream, func() (*actionnode.SnapshotReply, error) {
+		// this is only called after streaming is done
+		if finalError != nil {
+			return nil, finalError
+		}
+		return result, nil
+	}, nil
+}
+
+// SnapshotSourceEnd is part of the tmclient.TabletManagerClient interface
+func (client *Client) SnapshotSourceEnd(ctx context.Context, tablet *topo.TabletInfo, args *actionnode.SnapshotSourceEndArgs) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.SnapshotSourceEnd(ctx, &pb.SnapshotSourceEndRequest{
+		SlaveStartRequired: args.SlaveStartRequired,
+		ReadOnly:           args.ReadOnly,
+		OriginalType:       string(args.OriginalType),
+	})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// ReserveForRestore is part of the tmclient.TabletManagerClient interface
+func (client *Client) ReserveForRestore(ctx context.Context, tablet *topo.TabletInfo, args *actionnode.ReserveForRestoreArgs) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.ReserveForRestore(ctx, &pb.ReserveForRestoreRequest{
+		SrcTabletAlias: grpcproto.TabletAliasToProto3(args.SrcTabletAlias),
+	})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// Restore is part of the tmclient.TabletManagerClient interface
+func (client *Client) Restore(ctx context.Context, tablet *topo.TabletInfo, sa *actionnode.RestoreArgs) (<-chan *logutil.LoggerEvent, tmclient.ErrFunc, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, nil, err
+	}
+	stream, err := c.Restore(ctx, &pb.RestoreRequest{
+		SrcTabletAlias:        grpcproto.TabletAliasToProto3(sa.SrcTabletAlias),
+		SrcFilePath:           sa.SrcFilePath,
+		ParentAlias:           grpcproto.TabletAliasToProto3(sa.ParentAlias),
+		FetchConcurrency:      int64(sa.FetchConcurrency),
+		FetchRetryCount:       int64(sa.FetchRetryCount),
+		WasReserved:           sa.WasReserved,
+		DontWaitForSlaveStart: sa.DontWaitForSlaveStart,
+		FanOut:                int64(sa.FanOut),
+	})
+	if err != nil {
+		cc.Close()
+		return nil, nil, callError(ctx, tablet, err, nil)
+	}
+
+	logstream := make(chan *logutil.LoggerEvent, 10)
+	var finalError error
+	go func() {
+		defer func() {
+			close(logstream)
+			cc.Close()
+		}()
+		for {
+			response, err := stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+				finalError = err
+				return
+			}
+			if response.LogEvent != nil {
+				logstream <- grpcproto.Proto3ToLoggerEvent(response.LogEvent)
+			}
+		}
+	}()
+	return logstream, func() error {
+		// this is only called after streaming is done
+		return finalError
+	}, nil
+}
+
+// DeleteSnapshot is part of the tmclient.TabletManagerClient interface
+func (client *Client) DeleteSnapshot(ctx context.Context, tablet *topo.TabletInfo, args *actionnode.DeleteSnapshotArgs) error {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	response, err := c.DeleteSnapshot(ctx, &pb.DeleteSnapshotRequest{
+		ManifestPath: args.ManifestPath,
+	})
+	return callError(ctx, tablet, err, response.GetError())
+}
+
+// CleanOrphans is part of the tmclient.TabletManagerClient interface
+func (client *Client) CleanOrphans(ctx context.Context, tablet *topo.TabletInfo, args *actionnode.CleanOrphansArgs) (*myproto.OrphanCleanupReport, error) {
+	cc, c, err := client.dial(ctx, tablet)
+	if err != nil {
+		return nil, err
+	}
+	defer cc.Close()
+	response, err := c.CleanOrphans(ctx, &pb.CleanOrphansRequest{
+		MaxAge: int64(args.MaxAge),
+		DryRun: args.DryRun,
+	})
+	if err := callError(ctx, tablet, err, response.GetError()); err != nil {
+		return nil, err
+	}
+	return &myproto.OrphanCleanupReport{
+		DryRun:         response.DryRun,
+		Files:          grpcproto.Proto3ToOrphanedFiles(response.Files),
+		ReclaimedBytes: response.ReclaimedBytes,
+	}, nil
+}
+
+//
+// RPC related methods
+//
+
+// IsTimeoutError is part of the tmclient.TabletManagerClient interface
+func (client *Client) IsTimeoutError(err error) bool {
+	switch err.(type) {
+	case timeoutError:
+		return true
+	default:
+		return false
+	}
+}
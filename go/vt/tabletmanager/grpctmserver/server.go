@@ -0,0 +1,740 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package grpctmserver contains the gRPC implementation of the server
+// side of the tablet manager API. It mirrors gorpctmserver.
+package grpctmserver
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/youtube/vitess/go/vt/callinfo"
+	"github.com/youtube/vitess/go/vt/dbconfigs"
+	"github.com/youtube/vitess/go/vt/logutil"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/servenv"
+	"github.com/youtube/vitess/go/vt/tabletmanager"
+	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
+	"github.com/youtube/vitess/go/vt/tabletmanager/grpcproto"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vterrors"
+	"golang.org/x/net/context"
+
+	pbq "github.com/youtube/vitess/go/vt/proto/query"
+	pb "github.com/youtube/vitess/go/vt/proto/tabletmanager"
+)
+
+// server is the gRPC implementation of the RPC server.
+// It implements the pb.TabletManagerServer interface.
+type server struct {
+	// implementation of the agent to call
+	agent tabletmanager.RPCAgent
+}
+
+// rpcError returns the error to send back for err, or nil. The
+// errors are sent in the responses, with their code.
+func rpcError(err error) *pbq.RPCError {
+	if err == nil {
+		return nil
+	}
+	return &pbq.RPCError{
+		Code:    int64(vterrors.RecoverVtErrorCode(err)),
+		Message: err.Error(),
+	}
+}
+
+//
+// Various read-only methods
+//
+
+// Ping is part of the pb.TabletManagerServer interface
+func (s *server) Ping(ctx context.Context, request *pb.PingRequest) (*pb.PingResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.PingResponse{}
+	err := s.agent.RPCWrap(ctx, actionnode.TABLET_ACTION_PING, request, response, func() error {
+		response.Payload = s.agent.Ping(ctx, request.Payload)
+		return nil
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// Sleep is part of the pb.TabletManagerServer interface
+func (s *server) Sleep(ctx context.Context, request *pb.SleepRequest) (*pb.SleepResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.SleepResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_SLEEP, request, response, true, func() error {
+		s.agent.Sleep(ctx, time.Duration(request.Duration))
+		return nil
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// ExecuteHook is part of the pb.TabletManagerServer interface
+func (s *server) ExecuteHook(ctx context.Context, request *pb.ExecuteHookRequest) (*pb.ExecuteHookResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.ExecuteHookResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_EXECUTE_HOOK, request, response, true, func() error {
+		hr := s.agent.ExecuteHook(ctx, grpcproto.Proto3ToHook(request))
+		response.ExitStatus = int64(hr.ExitStatus)
+		response.Stdout = hr.Stdout
+		response.Stderr = hr.Stderr
+		return nil
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// GetSchema is part of the pb.TabletManagerServer interface
+func (s *server) GetSchema(ctx context.Context, request *pb.GetSchemaRequest) (*pb.GetSchemaResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.GetSchemaResponse{}
+	err := s.agent.RPCWrap(ctx, actionnode.TABLET_ACTION_GET_SCHEMA, request, response, func() error {
+		sd, err := s.agent.GetSchema(ctx, request.Tables, request.ExcludeTables, request.IncludeViews)
+		if err == nil {
+			response.SchemaDefinition = grpcproto.SchemaDefinitionToProto3(sd)
+		}
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// GetPermissions is part of the pb.TabletManagerServer interface
+func (s *server) GetPermissions(ctx context.Context, request *pb.GetPermissionsRequest) (*pb.GetPermissionsResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.GetPermissionsResponse{}
+	err := s.agent.RPCWrap(ctx, actionnode.TABLET_ACTION_GET_PERMISSIONS, request, response, func() error {
+		p, err := s.agent.GetPermissions(ctx)
+		if err == nil {
+			response.Permissions = grpcproto.PermissionsToProto3(p)
+		}
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+//
+// Various read-write methods
+//
+
+// SetReadOnly is part of the pb.TabletManagerServer interface
+func (s *server) SetReadOnly(ctx context.Context, request *pb.SetReadOnlyRequest) (*pb.SetReadOnlyResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.SetReadOnlyResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_SET_RDONLY, request, response, true, func() error {
+		return s.agent.SetReadOnly(ctx, true)
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// SetReadWrite is part of the pb.TabletManagerServer interface
+func (s *server) SetReadWrite(ctx context.Context, request *pb.SetReadWriteRequest) (*pb.SetReadWriteResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.SetReadWriteResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_SET_RDWR, request, response, true, func() error {
+		return s.agent.SetReadOnly(ctx, false)
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// ChangeType is part of the pb.TabletManagerServer interface
+func (s *server) ChangeType(ctx context.Context, request *pb.ChangeTypeRequest) (*pb.ChangeTypeResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.ChangeTypeResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_CHANGE_TYPE, request, response, true, func() error {
+		return s.agent.ChangeType(ctx, topo.TabletType(request.TabletType))
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// Scrap is part of the pb.TabletManagerServer interface
+func (s *server) Scrap(ctx context.Context, request *pb.ScrapRequest) (*pb.ScrapResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.ScrapResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_SCRAP, request, response, true, func() error {
+		return s.agent.Scrap(ctx)
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// RefreshState is part of the pb.TabletManagerServer interface
+func (s *server) RefreshState(ctx context.Context, request *pb.RefreshStateRequest) (*pb.RefreshStateResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.RefreshStateResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_REFRESH_STATE, request, response, true, func() error {
+		s.agent.RefreshState(ctx)
+		return nil
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// RunHealthCheck is part of the pb.TabletManagerServer interface
+func (s *server) RunHealthCheck(ctx context.Context, request *pb.RunHealthCheckRequest) (*pb.RunHealthCheckResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.RunHealthCheckResponse{}
+	err := s.agent.RPCWrap(ctx, actionnode.TABLET_ACTION_RUN_HEALTH_CHECK, request, response, func() error {
+		s.agent.RunHealthCheck(ctx, topo.TabletType(request.TabletType))
+		return nil
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// HealthStream is part of the pb.TabletManagerServer interface
+func (s *server) HealthStream(request *pb.HealthStreamRequest, stream pb.TabletManager_HealthStreamServer) error {
+	ctx := callinfo.GRPCCallInfo(stream.Context())
+	err := s.agent.RPCWrap(ctx, actionnode.TABLET_ACTION_HEALTH_STREAM, request, nil, func() error {
+		c := make(chan *actionnode.HealthStreamReply, 10)
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for hsr := range c {
+				// we send until the client disconnects
+				if err := stream.Send(&pb.HealthStreamResponse{
+					Tablet:              grpcproto.TabletToProto3(hsr.Tablet),
+					BinlogPlayerMapSize: hsr.BinlogPlayerMapSize,
+					HealthError:         hsr.HealthError,
+					ReplicationDelay:    int64(hsr.ReplicationDelay),
+				}); err != nil {
+					return
+				}
+			}
+		}()
+
+		id, err := s.agent.RegisterHealthStream(c)
+		if err != nil {
+			close(c)
+			wg.Wait()
+			return err
+		}
+		wg.Wait()
+		return s.agent.UnregisterHealthStream(id)
+	})
+	if err != nil {
+		return stream.Send(&pb.HealthStreamResponse{Error: rpcError(err)})
+	}
+	return nil
+}
+
+// ReloadSchema is part of the pb.TabletManagerServer interface
+func (s *server) ReloadSchema(ctx context.Context, request *pb.ReloadSchemaRequest) (*pb.ReloadSchemaResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.ReloadSchemaResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_RELOAD_SCHEMA, request, response, true, func() error {
+		s.agent.ReloadSchema(ctx)
+		return nil
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// PreflightSchema is part of the pb.TabletManagerServer interface
+func (s *server) PreflightSchema(ctx context.Context, request *pb.PreflightSchemaRequest) (*pb.PreflightSchemaResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.PreflightSchemaResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_PREFLIGHT_SCHEMA, request, response, true, func() error {
+		scr, err := s.agent.PreflightSchema(ctx, request.Change)
+		if err == nil {
+			response.Result = grpcproto.SchemaChangeResultToProto3(scr)
+		}
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// ApplySchema is part of the pb.TabletManagerServer interface
+func (s *server) ApplySchema(ctx context.Context, request *pb.ApplySchemaRequest) (*pb.ApplySchemaResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.ApplySchemaResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_APPLY_SCHEMA, request, response, true, func() error {
+		scr, err := s.agent.ApplySchema(ctx, grpcproto.Proto3ToSchemaChange(request.Change))
+		if err == nil {
+			response.Result = grpcproto.SchemaChangeResultToProto3(scr)
+		}
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// ExecuteFetch is part of the pb.TabletManagerServer interface
+func (s *server) ExecuteFetch(ctx context.Context, request *pb.ExecuteFetchRequest) (*pb.ExecuteFetchResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.ExecuteFetchResponse{}
+	err := s.agent.RPCWrap(ctx, actionnode.TABLET_ACTION_EXECUTE_FETCH, request, response, func() error {
+		qr, err := s.agent.ExecuteFetch(ctx, request.Query, int(request.MaxRows), request.WantFields, request.DisableBinlogs, dbconfigs.DbConfigName(request.DbConfigName))
+		if err == nil {
+			response.Result = tproto.QueryResultToProto3(qr)
+		}
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+//
+// Replication related methods
+//
+
+// SlaveStatus is part of the pb.TabletManagerServer interface
+func (s *server) SlaveStatus(ctx context.Context, request *pb.SlaveStatusRequest) (*pb.SlaveStatusResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.SlaveStatusResponse{}
+	err := s.agent.RPCWrap(ctx, actionnode.TABLET_ACTION_SLAVE_STATUS, request, response, func() error {
+		status, err := s.agent.SlaveStatus(ctx)
+		if err == nil {
+			response.Status = grpcproto.ReplicationStatusToProto3(status)
+		}
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// WaitSlavePosition is part of the pb.TabletManagerServer interface
+func (s *server) WaitSlavePosition(ctx context.Context, request *pb.WaitSlavePositionRequest) (*pb.WaitSlavePositionResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.WaitSlavePositionResponse{}
+	err := s.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_WAIT_SLAVE_POSITION, request, response, true, func() error {
+		position, err := myproto.DecodeReplicationPosition(request.Position)
+		if err != nil {
+			return err
+		}
+		status, err := s.agent.WaitSlavePosition(ctx, position, time.Duration(request.WaitTimeout))
+		if err == nil {
+			response.Status = grpcproto.ReplicationStatusToProto3(status)
+		}
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// MasterPosition is part of the pb.TabletManagerServer interface
+func (s *server) MasterPosition(ctx context.Context, request *pb.MasterPositionRequest) (*pb.MasterPositionResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.MasterPositionResponse{}
+	err := s.agent.RPCWrap(ctx, actionnode.TABLET_ACTION_MASTER_POSITION, request, response, func() error {
+		position, err := s.agent.MasterPosition(ctx)
+		if err == nil {
+			response.Position = myproto.EncodeReplicationPosition(position)
+		}
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// ReparentPosition is part of the pb.TabletManagerServer interface
+func (s *server) ReparentPosition(ctx context.Context, request *pb.ReparentPositionRequest) (*pb.ReparentPositionResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.ReparentPositionResponse{}
+	err := s.agent.RPCWrap(ctx, actionnode.TABLET_ACTION_REPARENT_POSITION, request, response, func() error {
+		position, err := myproto.DecodeReplicationPosition(request.Position)
+		if err != nil {
+			return err
+		}
+		rsd, err := s.agent.ReparentPosition(ctx, &position)
+		if err == nil {
+			response.RestartSlaveData = grpcproto.RestartSlaveDataToProto3(rsd)
+		}
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// StopSlave is part of the pb.TabletManagerServer interface
+func (s *server) StopSlave(ctx context.Context, request *pb.StopSlaveRequest) (*pb.StopSlaveResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.StopSlaveResponse{}
+	err := s.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_STOP_SLAVE, request, response, true, func() error {
+		return s.agent.StopSlave(ctx)
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// StopSlaveMinimum is part of the pb.TabletManagerServer interface
+func (s *server) StopSlaveMinimum(ctx context.Context, request *pb.StopSlaveMinimumRequest) (*pb.StopSlaveMinimumResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.StopSlaveMinimumResponse{}
+	err := s.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_STOP_SLAVE_MINIMUM, request, response, true, func() error {
+		position, err := myproto.DecodeReplicationPosition(request.Position)
+		if err != nil {
+			return err
+		}
+		status, err := s.agent.StopSlaveMinimum(ctx, position, time.Duration(request.WaitTime))
+		if err == nil {
+			response.Status = grpcproto.ReplicationStatusToProto3(status)
+		}
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// StartSlave is part of the pb.TabletManagerServer interface
+func (s *server) StartSlave(ctx context.Context, request *pb.StartSlaveRequest) (*pb.StartSlaveResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.StartSlaveResponse{}
+	err := s.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_START_SLAVE, request, response, true, func() error {
+		return s.agent.StartSlave(ctx)
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// StartSlaveUntilAfter is part of the pb.TabletManagerServer interface
+func (s *server) StartSlaveUntilAfter(ctx context.Context, request *pb.StartSlaveUntilAfterRequest) (*pb.StartSlaveUntilAfterResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.StartSlaveUntilAfterResponse{}
+	err := s.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_START_SLAVE_UNTIL_AFTER, request, response, true, func() error {
+		position, err := myproto.DecodeReplicationPosition(request.Position)
+		if err != nil {
+			return err
+		}
+		return s.agent.StartSlaveUntilAfter(ctx, position, time.Duration(request.WaitTime))
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// TabletExternallyReparented is part of the pb.TabletManagerServer interface
+func (s *server) TabletExternallyReparented(ctx context.Context, request *pb.TabletExternallyReparentedRequest) (*pb.TabletExternallyReparentedResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.TabletExternallyReparentedResponse{}
+	err := s.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_EXTERNALLY_REPARENTED, request, response, false, func() error {
+		return s.agent.TabletExternallyReparented(ctx, request.ExternalId)
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// GetSlaves is part of the pb.TabletManagerServer interface
+func (s *server) GetSlaves(ctx context.Context, request *pb.GetSlavesRequest) (*pb.GetSlavesResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.GetSlavesResponse{}
+	err := s.agent.RPCWrap(ctx, actionnode.TABLET_ACTION_GET_SLAVES, request, response, func() error {
+		var err error
+		response.Addrs, err = s.agent.GetSlaves(ctx)
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// WaitBlpPosition is part of the pb.TabletManagerServer interface
+func (s *server) WaitBlpPosition(ctx context.Context, request *pb.WaitBlpPositionRequest) (*pb.WaitBlpPositionResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.WaitBlpPositionResponse{}
+	err := s.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_WAIT_BLP_POSITION, request, response, true, func() error {
+		blpPosition, err := grpcproto.Proto3ToBlpPosition(request.BlpPosition)
+		if err != nil {
+			return err
+		}
+		return s.agent.WaitBlpPosition(ctx, blpPosition, time.Duration(request.WaitTimeout))
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// StopBlp is part of the pb.TabletManagerServer interface
+func (s *server) StopBlp(ctx context.Context, request *pb.StopBlpRequest) (*pb.StopBlpResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.StopBlpResponse{}
+	err := s.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_STOP_BLP, request, response, true, func() error {
+		positions, err := s.agent.StopBlp(ctx)
+		if err == nil {
+			response.BlpPositions = grpcproto.BlpPositionListToProto3(positions)
+		}
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// StartBlp is part of the pb.TabletManagerServer interface
+func (s *server) StartBlp(ctx context.Context, request *pb.StartBlpRequest) (*pb.StartBlpResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.StartBlpResponse{}
+	err := s.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_START_BLP, request, response, true, func() error {
+		return s.agent.StartBlp(ctx)
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// RunBlpUntil is part of the pb.TabletManagerServer interface
+func (s *server) RunBlpUntil(ctx context.Context, request *pb.RunBlpUntilRequest) (*pb.RunBlpUntilResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.RunBlpUntilResponse{}
+	err := s.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_RUN_BLP_UNTIL, request, response, true, func() error {
+		positions, err := grpcproto.Proto3ToBlpPositionList(request.BlpPositions)
+		if err != nil {
+			return err
+		}
+		position, err := s.agent.RunBlpUntil(ctx, positions, time.Duration(request.WaitTimeout))
+		if err == nil {
+			response.Position = myproto.EncodeReplicationPosition(*position)
+		}
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+//
+// Reparenting related functions
+//
+
+// DemoteMaster is part of the pb.TabletManagerServer interface
+func (s *server) DemoteMaster(ctx context.Context, request *pb.DemoteMasterRequest) (*pb.DemoteMasterResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.DemoteMasterResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_DEMOTE_MASTER, request, response, true, func() error {
+		return s.agent.DemoteMaster(ctx)
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// PromoteSlave is part of the pb.TabletManagerServer interface
+func (s *server) PromoteSlave(ctx context.Context, request *pb.PromoteSlaveRequest) (*pb.PromoteSlaveResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.PromoteSlaveResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_PROMOTE_SLAVE, request, response, true, func() error {
+		rsd, err := s.agent.PromoteSlave(ctx)
+		if err == nil {
+			response.RestartSlaveData = grpcproto.RestartSlaveDataToProto3(rsd)
+		}
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// SlaveWasPromoted is part of the pb.TabletManagerServer interface
+func (s *server) SlaveWasPromoted(ctx context.Context, request *pb.SlaveWasPromotedRequest) (*pb.SlaveWasPromotedResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.SlaveWasPromotedResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_SLAVE_WAS_PROMOTED, request, response, true, func() error {
+		return s.agent.SlaveWasPromoted(ctx)
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// RestartSlave is part of the pb.TabletManagerServer interface
+func (s *server) RestartSlave(ctx context.Context, request *pb.RestartSlaveRequest) (*pb.RestartSlaveResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.RestartSlaveResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_RESTART_SLAVE, request, response, true, func() error {
+		rsd, err := grpcproto.Proto3ToRestartSlaveData(request.RestartSlaveData)
+		if err != nil {
+			return err
+		}
+		return s.agent.RestartSlave(ctx, rsd)
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// SlaveWasRestarted is part of the pb.TabletManagerServer interface
+func (s *server) SlaveWasRestarted(ctx context.Context, request *pb.SlaveWasRestartedRequest) (*pb.SlaveWasRestartedResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.SlaveWasRestartedResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_SLAVE_WAS_RESTARTED, request, response, true, func() error {
+		return s.agent.SlaveWasRestarted(ctx, &actionnode.SlaveWasRestartedArgs{
+			Parent: grpcproto.Proto3ToTabletAlias(request.Parent),
+		})
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// BreakSlaves is part of the pb.TabletManagerServer interface
+func (s *server) BreakSlaves(ctx context.Context, request *pb.BreakSlavesRequest) (*pb.BreakSlavesResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.BreakSlavesResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_BREAK_SLAVES, request, response, true, func() error {
+		return s.agent.BreakSlaves(ctx)
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+//
+// Backup related methods
+//
+
+// Snapshot is part of the pb.TabletManagerServer interface
+func (s *server) Snapshot(request *pb.SnapshotRequest, stream pb.TabletManager_SnapshotServer) error {
+	ctx := callinfo.GRPCCallInfo(stream.Context())
+	var result *actionnode.SnapshotReply
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_SNAPSHOT, request, nil, true, func() error {
+		// create a logger, send the result back to the caller
+		logger := logutil.NewChannelLogger(10)
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			for e := range logger {
+				// Note we don't interrupt the loop here, as
+				// we still need to flush and finish the
+				// command, even if the channel to the client
+				// has been broken. We'll just keep trying to send.
+				stream.Send(&pb.SnapshotResponse{
+					LogEvent: grpcproto.LoggerEventToProto3(&e),
+				})
+			}
+			wg.Done()
+		}()
+
+		var err error
+		result, err = s.agent.Snapshot(ctx, &actionnode.SnapshotArgs{
+			Concurrency:         int(request.Concurrency),
+			ServerMode:          request.ServerMode,
+			ForceMasterSnapshot: request.ForceMasterSnapshot,
+		}, logger)
+		close(logger)
+		wg.Wait()
+		return err
+	})
+	if err != nil {
+		return stream.Send(&pb.SnapshotResponse{Error: rpcError(err)})
+	}
+	return stream.Send(&pb.SnapshotResponse{Result: grpcproto.SnapshotReplyToProto3(result)})
+}
+
+// SnapshotSourceEnd is part of the pb.TabletManagerServer interface
+func (s *server) SnapshotSourceEnd(ctx context.Context, request *pb.SnapshotSourceEndRequest) (*pb.SnapshotSourceEndResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.SnapshotSourceEndResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_SNAPSHOT_SOURCE_END, request, response, true, func() error {
+		return s.agent.SnapshotSourceEnd(ctx, &actionnode.SnapshotSourceEndArgs{
+			SlaveStartRequired: request.SlaveStartRequired,
+			ReadOnly:           request.ReadOnly,
+			OriginalType:       topo.TabletType(request.OriginalType),
+		})
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// ReserveForRestore is part of the pb.TabletManagerServer interface
+func (s *server) ReserveForRestore(ctx context.Context, request *pb.ReserveForRestoreRequest) (*pb.ReserveForRestoreResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.ReserveForRestoreResponse{}
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_RESERVE_FOR_RESTORE, request, response, true, func() error {
+		return s.agent.ReserveForRestore(ctx, &actionnode.ReserveForRestoreArgs{
+			SrcTabletAlias: grpcproto.Proto3ToTabletAlias(request.SrcTabletAlias),
+		})
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// Restore is part of the pb.TabletManagerServer interface
+func (s *server) Restore(request *pb.RestoreRequest, stream pb.TabletManager_RestoreServer) error {
+	ctx := callinfo.GRPCCallInfo(stream.Context())
+	err := s.agent.RPCWrapLockAction(ctx, actionnode.TABLET_ACTION_RESTORE, request, nil, true, func() error {
+		// create a logger, send the result back to the caller
+		logger := logutil.NewChannelLogger(10)
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			for e := range logger {
+				// Note we don't interrupt the loop here, as
+				// we still need to flush and finish the
+				// command, even if the channel to the client
+				// has been broken. We'll just keep trying to send.
+				stream.Send(&pb.RestoreResponse{
+					LogEvent: grpcproto.LoggerEventToProto3(&e),
+				})
+			}
+			wg.Done()
+		}()
+
+		err := s.agent.Restore(ctx, &actionnode.RestoreArgs{
+			SrcTabletAlias:        grpcproto.Proto3ToTabletAlias(request.SrcTabletAlias),
+			SrcFilePath:           request.SrcFilePath,
+			ParentAlias:           grpcproto.Proto3ToTabletAlias(request.ParentAlias),
+			FetchConcurrency:      int(request.FetchConcurrency),
+			FetchRetryCount:       int(request.FetchRetryCount),
+			WasReserved:           request.WasReserved,
+			DontWaitForSlaveStart: request.DontWaitForSlaveStart,
+			FanOut:                int(request.FanOut),
+		}, logger)
+		close(logger)
+		wg.Wait()
+		return err
+	})
+	if err != nil {
+		return stream.Send(&pb.RestoreResponse{Error: rpcError(err)})
+	}
+	return nil
+}
+
+// DeleteSnapshot is part of the pb.TabletManagerServer interface
+func (s *server) DeleteSnapshot(ctx context.Context, request *pb.DeleteSnapshotRequest) (*pb.DeleteSnapshotResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.DeleteSnapshotResponse{}
+	err := s.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_DELETE_SNAPSHOT, request, response, true, func() error {
+		return s.agent.DeleteSnapshot(ctx, &actionnode.DeleteSnapshotArgs{
+			ManifestPath: request.ManifestPath,
+		})
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// CleanOrphans is part of the pb.TabletManagerServer interface
+func (s *server) CleanOrphans(ctx context.Context, request *pb.CleanOrphansRequest) (*pb.CleanOrphansResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response := &pb.CleanOrphansResponse{}
+	err := s.agent.RPCWrapLock(ctx, actionnode.TABLET_ACTION_CLEAN_ORPHANS, request, response, true, func() error {
+		report, err := s.agent.CleanOrphans(ctx, &actionnode.CleanOrphansArgs{
+			MaxAge: time.Duration(request.MaxAge),
+			DryRun: request.DryRun,
+		})
+		if err == nil {
+			response.DryRun = report.DryRun
+			response.Files = grpcproto.OrphanedFilesToProto3(report.Files)
+			response.ReclaimedBytes = report.ReclaimedBytes
+		}
+		return err
+	})
+	response.Error = rpcError(err)
+	return response, nil
+}
+
+// registration glue
+
+func init() {
+	tabletmanager.RegisterQueryServices = append(tabletmanager.RegisterQueryServices, func(agent *tabletmanager.ActionAgent) {
+		if servenv.GRPCCheckServiceMap("tabletmanager") {
+			StartServer(servenv.GRPCServer, agent)
+		}
+	})
+}
+
+// StartServer registers the tablet manager gRPC service on a server,
+// backed by the provided agent.
+func StartServer(s *grpc.Server, agent tabletmanager.RPCAgent) {
+	pb.RegisterTabletManagerServer(s, &server{agent})
+}
@@ -0,0 +1,50 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package grpctmserver
+
+import (
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/youtube/vitess/go/vt/tabletmanager/agentrpctest"
+	"github.com/youtube/vitess/go/vt/tabletmanager/grpctmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+// the test here creates a fake server implementation, a fake client
+// implementation, and runs the test suite against the setup.
+func TestGRPCTMServer(t *testing.T) {
+	// Listen on a random port
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	// Create a gRPC server and listen on the port
+	s := grpc.NewServer()
+	fakeAgent := agentrpctest.NewFakeRPCAgent(t)
+	StartServer(s, fakeAgent)
+	go s.Serve(listener)
+	defer s.Stop()
+
+	// Create a gRPC client to talk to the fake tablet
+	client := &grpctmclient.Client{}
+	ti := topo.NewTabletInfo(&topo.Tablet{
+		Alias: topo.TabletAlias{
+			Cell: "test",
+			Uid:  123,
+		},
+		Hostname: "localhost",
+		Portmap: map[string]int{
+			"grpc": port,
+		},
+	}, 0)
+
+	// and run the test suite
+	agentrpctest.Run(t, client, ti, fakeAgent)
+}
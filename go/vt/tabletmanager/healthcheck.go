@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"html/template"
 	"reflect"
+	"strconv"
 	"time"
 
 	log "github.com/golang/glog"
@@ -30,10 +31,12 @@ const (
 )
 
 var (
-	healthCheckInterval = flag.Duration("health_check_interval", 20*time.Second, "Interval between health checks")
-	targetTabletType    = flag.String("target_tablet_type", "", "The tablet type we are thriving to be when healthy. When not healthy, we'll go to spare.")
-	degradedThreshold   = flag.Duration("degraded_threshold", defaultDegradedThreshold, "replication lag after which a replica is considered degraded")
-	unhealthyThreshold  = flag.Duration("unhealthy_threshold", defaultUnhealthyThreshold, "replication lag  after which a replica is considered unhealthy")
+	healthCheckInterval   = flag.Duration("health_check_interval", 20*time.Second, "Interval between health checks")
+	targetTabletType      = flag.String("target_tablet_type", "", "The tablet type we are thriving to be when healthy. When not healthy, we'll go to spare.")
+	degradedThreshold     = flag.Duration("degraded_threshold", defaultDegradedThreshold, "replication lag after which a replica is considered degraded")
+	unhealthyThreshold    = flag.Duration("unhealthy_threshold", defaultUnhealthyThreshold, "replication lag  after which a replica is considered unhealthy")
+	publishLagGranularity = flag.Duration("publish_replication_lag_granularity", 0, "if set, slaves publish their replication lag in their health, rounded up to this granularity, so vtgate can route the reads with a staleness bound. Each change of the published lag rebuilds the serving graph, so it shouldn't be too fine. 0 to disable")
+	schemaVersionInterval = flag.Duration("schema_version_interval", 5*time.Minute, "interval between the computations of the schema version the health check publishes in the tablet record, 0 to disable")
 )
 
 // HealthRecord records one run of the health checker
@@ -115,7 +118,9 @@ func (agent *ActionAgent) initHeathCheck() {
 		agent.terminateHealthChecks(topo.TabletType(*targetTabletType))
 	})
 	t.Start(func() {
+		defer servenv.LogPanic("healthcheck")
 		agent.runHealthCheck(topo.TabletType(*targetTabletType))
+		agent.refreshReplicaLag()
 	})
 	t.Trigger()
 }
@@ -164,6 +169,9 @@ func (agent *ActionAgent) runHealthCheck(targetTabletType topo.TabletType) {
 		} else if replicationDelay > *degradedThreshold {
 			health[topo.ReplicationLag] = topo.ReplicationLagHigh
 		}
+		if *publishLagGranularity > 0 && typeForHealthCheck != topo.TYPE_MASTER {
+			health[topo.ReplicationLagSeconds] = roundReplicationLag(replicationDelay, *publishLagGranularity)
+		}
 	}
 
 	// Figure out if we should be running QueryService, see if we are,
@@ -186,6 +194,10 @@ func (agent *ActionAgent) runHealthCheck(targetTabletType topo.TabletType) {
 		}
 	}
 
+	// a master that missed a reparent refuses writes as soon as
+	// it sees the new master term
+	agent.checkMasterTerm(agent.batchCtx, tablet.Tablet)
+
 	// save the health record
 	record := &HealthRecord{
 		Error:            err,
@@ -225,6 +237,9 @@ func (agent *ActionAgent) runHealthCheck(targetTabletType topo.TabletType) {
 		}
 	}
 
+	// publish our schema version if it changed
+	schemaVersionChanged := agent.refreshSchemaVersion(tablet)
+
 	// remember our health status
 	agent.mutex.Lock()
 	agent._healthy = err
@@ -272,9 +287,9 @@ func (agent *ActionAgent) runHealthCheck(targetTabletType topo.TabletType) {
 		if tablet.Type == topo.TYPE_SPARE {
 			newTabletType = targetTabletType
 		}
-		if tablet.Type == newTabletType && tablet.IsHealthEqual(health) {
-			// no change in health, not logging anything,
-			// and we're done
+		if tablet.Type == newTabletType && tablet.IsHealthEqual(health) && !schemaVersionChanged {
+			// no change in health or schema, not logging
+			// anything, and we're done
 			return
 		}
 
@@ -304,6 +319,59 @@ func (agent *ActionAgent) runHealthCheck(targetTabletType topo.TabletType) {
 	}
 }
 
+// roundReplicationLag returns the replication lag published in the
+// health map: the lag rounded up to the granularity, in seconds. The
+// rounding keeps the health stable while the lag varies a little, as
+// each change rebuilds the serving graph.
+func roundReplicationLag(lag, granularity time.Duration) string {
+	if lag <= 0 {
+		return "0"
+	}
+	lag = (lag + granularity - 1) / granularity * granularity
+	return strconv.FormatInt(int64((lag+time.Second-1)/time.Second), 10)
+}
+
+// refreshSchemaVersion computes the schema version of the tablet
+// database if the -schema_version_interval elapsed, and saves it in
+// the tablet record if it changed. It returns true if it did, then
+// the serving graph needs to be rebuilt.
+func (agent *ActionAgent) refreshSchemaVersion(tablet *topo.TabletInfo) bool {
+	if *schemaVersionInterval == 0 {
+		return false
+	}
+	agent.mutex.Lock()
+	due := time.Now().Sub(agent._schemaVersionTime) >= *schemaVersionInterval
+	if due {
+		agent._schemaVersionTime = time.Now()
+	}
+	agent.mutex.Unlock()
+	if !due {
+		return false
+	}
+
+	sd, err := agent.MysqlDaemon.GetSchema(tablet.DbName(), nil, nil, true)
+	if err != nil {
+		log.Warningf("Can't compute the schema version, will retry in %v: %v", *schemaVersionInterval, err)
+		return false
+	}
+	if sd.Version == tablet.SchemaVersion {
+		return false
+	}
+
+	log.Infof("Updating tablet schema version %v -> %v", tablet.SchemaVersion, sd.Version)
+	if err := agent.TopoServer.UpdateTabletFields(tablet.Alias, func(tablet *topo.Tablet) error {
+		tablet.SchemaVersion = sd.Version
+		return nil
+	}); err != nil {
+		log.Warningf("Error updating schema version in tablet record: %v", err)
+		return false
+	}
+	agent.mutex.Lock()
+	agent._tablet.SchemaVersion = sd.Version
+	agent.mutex.Unlock()
+	return true
+}
+
 // terminateHealthChecks is called when we enter lame duck mode.
 // We will clean up our state, and shut down query service.
 // We only do something if we are in targetTabletType state, and then
@@ -336,6 +404,7 @@ func (agent *ActionAgent) terminateHealthChecks(targetTabletType topo.TabletType
 	// We've already rebuilt the shard, which is the only reason we registered
 	// ourself as OnTermSync (synchronous). The rest can be done asynchronously.
 	go func() {
+		defer servenv.LogPanic("terminatehealthcheck")
 		// Run the post action callbacks (let them shutdown the query service)
 		if err := agent.refreshTablet(agent.batchCtx, "terminatehealthcheck"); err != nil {
 			log.Warningf("refreshTablet failed: %v", err)
@@ -8,7 +8,8 @@ import (
 	"testing"
 	"time"
 
-	"github.com/youtube/vitess/go/vt/mysqlctl"
+	"github.com/youtube/vitess/go/vt/mysqlctl/fakemysqldaemon"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
 	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
 	"github.com/youtube/vitess/go/vt/tabletserver"
 	"github.com/youtube/vitess/go/vt/topo"
@@ -138,7 +139,7 @@ func createTestAgent(t *testing.T) *ActionAgent {
 		t.Fatalf("CreateTablet failed: %v", err)
 	}
 
-	mysqlDaemon := &mysqlctl.FakeMysqlDaemon{MysqlPort: 3306}
+	mysqlDaemon := &fakemysqldaemon.FakeMysqlDaemon{MysqlPort: 3306}
 	agent := NewTestActionAgent(context.Background(), ts, tabletAlias, port, mysqlDaemon)
 	agent.BinlogPlayerMap = NewBinlogPlayerMap(ts, nil, nil)
 	agent.HealthReporter = &fakeHealthCheck{}
@@ -364,6 +365,172 @@ func TestTabletControl(t *testing.T) {
 	}
 }
 
+// TestHealthCheckSchemaVersion verifies the health check publishes
+// the schema version in the tablet record and the serving graph.
+func TestHealthCheckSchemaVersion(t *testing.T) {
+	agent := createTestAgent(t)
+	targetTabletType := topo.TYPE_REPLICA
+	mysqlDaemon := agent.MysqlDaemon.(*fakemysqldaemon.FakeMysqlDaemon)
+	mysqlDaemon.Schema = &myproto.SchemaDefinition{
+		TableDefinitions: []*myproto.TableDefinition{
+			&myproto.TableDefinition{
+				Name:   "table1",
+				Schema: "CREATE TABLE `table1` (`id` bigint)",
+				Type:   myproto.TABLE_BASE_TABLE,
+			},
+		},
+	}
+	mysqlDaemon.Schema.GenerateSchemaVersion()
+	version1 := mysqlDaemon.Schema.Version
+
+	// the shard needs our cell for the serving graph rebuilds
+	si, err := agent.TopoServer.GetShard(keyspace, shard)
+	if err != nil {
+		t.Fatalf("GetShard failed: %v", err)
+	}
+	si.Cells = []string{cell}
+	if err := topo.UpdateShard(context.Background(), agent.TopoServer, si); err != nil {
+		t.Fatalf("UpdateShard failed: %v", err)
+	}
+
+	checkVersion := func(want string) {
+		ti, err := agent.TopoServer.GetTablet(tabletAlias)
+		if err != nil {
+			t.Fatalf("GetTablet failed: %v", err)
+		}
+		if ti.SchemaVersion != want {
+			t.Errorf("tablet record has schema version %v, want %v", ti.SchemaVersion, want)
+		}
+		addrs, err := agent.TopoServer.GetEndPoints(cell, keyspace, shard, targetTabletType)
+		if err != nil {
+			t.Fatalf("GetEndPoints failed: %v", err)
+		}
+		if len(addrs.Entries) != 1 || addrs.Entries[0].SchemaVersion != want {
+			t.Errorf("serving graph has %v, want schema version %v", addrs.Entries, want)
+		}
+	}
+	agent.runHealthCheck(targetTabletType)
+	checkVersion(version1)
+
+	// the schema changes, the version is updated after a reload
+	mysqlDaemon.Schema.TableDefinitions[0].Schema = "CREATE TABLE `table1` (`id` bigint, `name` varchar(64))"
+	mysqlDaemon.Schema.GenerateSchemaVersion()
+	version2 := mysqlDaemon.Schema.Version
+	if version2 == version1 {
+		t.Fatalf("schema versions should be different")
+	}
+	agent.runHealthCheck(targetTabletType)
+	checkVersion(version1)
+	agent.ReloadSchema(context.Background())
+	agent.runHealthCheck(targetTabletType)
+	checkVersion(version2)
+}
+
+// TestHealthCheckFencesStaleMaster verifies that a master that missed
+// a reparent is fenced by the health check, without a RefreshState.
+func TestHealthCheckFencesStaleMaster(t *testing.T) {
+	agent := createTestAgent(t)
+	ctx := context.Background()
+	tqsc := agent.QueryServiceControl.(*tabletserver.TestQueryServiceControl)
+
+	// we're the master of term 1
+	if _, err := topo.UpdateShardFields(ctx, agent.TopoServer, keyspace, shard, func(shard *topo.Shard) error {
+		shard.SetMaster(tabletAlias)
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateShardFields failed: %v", err)
+	}
+	if err := topo.UpdateTabletFields(ctx, agent.TopoServer, tabletAlias, func(tablet *topo.Tablet) error {
+		tablet.Type = topo.TYPE_MASTER
+		tablet.MasterTerm = 1
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateTabletFields failed: %v", err)
+	}
+	ti, err := agent.TopoServer.GetTablet(tabletAlias)
+	if err != nil {
+		t.Fatalf("GetTablet failed: %v", err)
+	}
+	agent.setTablet(ti)
+	tqsc.IsMaster = true
+	agent.runHealthCheck(topo.TYPE_REPLICA)
+	if agent._fenced || !tqsc.IsMaster {
+		t.Errorf("master of the current term: fenced %v, is master %v, want false, true", agent._fenced, tqsc.IsMaster)
+	}
+
+	// another tablet becomes the master while we're partitioned
+	if _, err := topo.UpdateShardFields(ctx, agent.TopoServer, keyspace, shard, func(shard *topo.Shard) error {
+		shard.SetMaster(topo.TabletAlias{Cell: cell, Uid: 2})
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateShardFields failed: %v", err)
+	}
+	agent.runHealthCheck(topo.TYPE_REPLICA)
+	if !agent._fenced || tqsc.IsMaster {
+		t.Errorf("stale master: fenced %v, is master %v, want true, false", agent._fenced, tqsc.IsMaster)
+	}
+}
+
+// TestEnterLameduck verifies that a tablet is unhealthy as soon as
+// it enters lameduck mode.
+func TestEnterLameduck(t *testing.T) {
+	agent := createTestAgent(t)
+	tqsc := agent.QueryServiceControl.(*tabletserver.TestQueryServiceControl)
+	agent.runHealthCheck(topo.TYPE_REPLICA)
+	if _, err := agent.Healthy(); err != nil {
+		t.Fatalf("Healthy failed: %v", err)
+	}
+
+	agent.EnterLameduck()
+	if _, err := agent.Healthy(); err == nil {
+		t.Errorf("Healthy in lameduck mode should fail")
+	}
+	if !tqsc.Lameduck {
+		t.Errorf("query service was not put in lameduck mode")
+	}
+}
+
+// TestStopForHandover verifies that a master stops its master work
+// before a handover, and doesn't restart it on a state change.
+func TestStopForHandover(t *testing.T) {
+	agent := createTestAgent(t)
+	// the binlog players need MySQL
+	agent.BinlogPlayerMap = nil
+	ctx := context.Background()
+	tqsc := agent.QueryServiceControl.(*tabletserver.TestQueryServiceControl)
+
+	if _, err := topo.UpdateShardFields(ctx, agent.TopoServer, keyspace, shard, func(shard *topo.Shard) error {
+		shard.SetMaster(tabletAlias)
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateShardFields failed: %v", err)
+	}
+	ti, err := agent.TopoServer.GetTablet(tabletAlias)
+	if err != nil {
+		t.Fatalf("GetTablet failed: %v", err)
+	}
+	oldTablet := *ti.Tablet
+	newTablet := *ti.Tablet
+	newTablet.Type = topo.TYPE_MASTER
+	if err := agent.changeCallback(ctx, &oldTablet, &newTablet); err != nil {
+		t.Fatalf("changeCallback failed: %v", err)
+	}
+	if !tqsc.IsMaster || !tqsc.IsHeartbeatWriter {
+		t.Errorf("serving master: is master %v, heartbeat writer %v, want true, true", tqsc.IsMaster, tqsc.IsHeartbeatWriter)
+	}
+
+	agent.StopForHandover()
+	if tqsc.IsMaster || tqsc.IsHeartbeatWriter {
+		t.Errorf("after StopForHandover: is master %v, heartbeat writer %v, want false, false", tqsc.IsMaster, tqsc.IsHeartbeatWriter)
+	}
+	if err := agent.changeCallback(ctx, &newTablet, &newTablet); err != nil {
+		t.Fatalf("changeCallback failed: %v", err)
+	}
+	if tqsc.IsMaster {
+		t.Errorf("state change after StopForHandover: is master true, want false")
+	}
+}
+
 // TestOldHealthCheck verifies that a healthcheck that is too old will
 // return an error
 func TestOldHealthCheck(t *testing.T) {
@@ -389,3 +556,22 @@ func TestOldHealthCheck(t *testing.T) {
 		t.Errorf("Healthy returned wrong error: %v", healthy)
 	}
 }
+
+func TestRoundReplicationLag(t *testing.T) {
+	cases := []struct {
+		lag         time.Duration
+		granularity time.Duration
+		want        string
+	}{
+		{0, 5 * time.Second, "0"},
+		{time.Second, 5 * time.Second, "5"},
+		{5 * time.Second, 5 * time.Second, "5"},
+		{6 * time.Second, 5 * time.Second, "10"},
+		{1500 * time.Millisecond, 500 * time.Millisecond, "2"},
+	}
+	for _, c := range cases {
+		if got := roundReplicationLag(c.lag, c.granularity); got != c.want {
+			t.Errorf("roundReplicationLag(%v, %v) = %v, want %v", c.lag, c.granularity, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletmanager
+
+// This file handles the tablet heartbeat. It is enabled by passing a
+// tablet_heartbeat_interval command line parameter. The tablet will
+// then periodically refresh the LastHeartbeat field of its tablet
+// record, so tablets that are gone can be detected with
+// -tablet_heartbeat_ttl.
+
+import (
+	"flag"
+	"reflect"
+	"time"
+
+	log "github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/youtube/vitess/go/timer"
+	"github.com/youtube/vitess/go/vt/servenv"
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+var tabletHeartbeatInterval = flag.Duration("tablet_heartbeat_interval", 0, "if set, interval between refreshes of the tablet record heartbeat. It should be well below the -tablet_heartbeat_ttl used by the tools.")
+
+// expiredHeartbeat is the LastHeartbeat Deregister sets. 0 means the
+// tablet doesn't heartbeat, so it uses the oldest valid heartbeat
+// instead.
+const expiredHeartbeat = 1
+
+func (agent *ActionAgent) initHeartbeat() {
+	if *tabletHeartbeatInterval == 0 {
+		return
+	}
+
+	// A tablet that heartbeats slower than the TTL would keep going
+	// stale between heartbeats, so we make sure our own staleness
+	// checks use a TTL that covers a few missed heartbeats.
+	if ttl := 3 * *tabletHeartbeatInterval; topo.TabletHeartbeatTTL() < ttl {
+		log.Warningf("-tablet_heartbeat_ttl %v is too short for -tablet_heartbeat_interval %v, using %v. The tools should use the same -tablet_heartbeat_ttl.", topo.TabletHeartbeatTTL(), *tabletHeartbeatInterval, ttl)
+		topo.SetTabletHeartbeatTTL(ttl)
+	}
+
+	log.Infof("Starting tablet heartbeat every %v", *tabletHeartbeatInterval)
+	t := timer.NewTimer(*tabletHeartbeatInterval)
+	servenv.OnTermSync(func() {
+		log.Info("Stopping tablet heartbeat timer")
+		t.Stop()
+	})
+	first := true
+	t.Start(func() {
+		if agent.heartbeat(first) {
+			first = false
+		}
+	})
+	t.Trigger()
+}
+
+// heartbeat refreshes the heartbeat in the tablet record, and returns
+// true if it worked. The serving graph is rebuilt on the first
+// heartbeat of the process (the tablet may have been excluded from
+// it before the restart, InitTablet resets LastHeartbeat), and if
+// the tablet was stale or its heartbeat expired by Deregister.
+func (agent *ActionAgent) heartbeat(first bool) bool {
+	now := time.Now().Unix()
+	var tablet topo.Tablet
+	wasStale := false
+	if err := agent.TopoServer.UpdateTabletFields(agent.TabletAlias, func(t *topo.Tablet) error {
+		wasStale = t.IsStale() || t.LastHeartbeat == expiredHeartbeat
+		t.LastHeartbeat = now
+		tablet = *t
+		return nil
+	}); err != nil {
+		log.Warningf("Cannot update the tablet record heartbeat: %v", err)
+		return false
+	}
+
+	// Other goroutines may hold the current TabletInfo, so we
+	// replace it with the re-read record instead of changing it,
+	// which also has the new version. If something else changed
+	// the record, the agent picks it up when its state is
+	// refreshed, not here.
+	if ti, err := topo.GetTablet(agent.batchCtx, agent.TopoServer, agent.TabletAlias); err != nil {
+		log.Warningf("Cannot re-read the tablet record after the heartbeat: %v", err)
+	} else {
+		agent.mutex.Lock()
+		current := *agent._tablet.Tablet
+		current.LastHeartbeat = ti.LastHeartbeat
+		if reflect.DeepEqual(&current, ti.Tablet) {
+			agent._tablet = ti
+		}
+		agent.mutex.Unlock()
+	}
+
+	if first || wasStale {
+		log.Infof("First heartbeat or tablet was stale, rebuilding the serving graph")
+		if err := agent.rebuildShardIfNeeded(topo.NewTabletInfo(&tablet, 0), tablet.Type); err != nil {
+			log.Warningf("rebuildShardIfNeeded failed, serving graph might be out of date: %v", err)
+		}
+	}
+	return true
+}
+
+// Deregister is called when the process shuts down. If the tablet
+// heartbeats, it expires its heartbeat so it is stale right away,
+// and rebuilds the serving graph to exclude it, instead of waiting
+// for -tablet_heartbeat_ttl. The first heartbeat after a restart
+// brings it back. servenv doesn't call it after a SIGUSR2 handover,
+// as the new process heartbeats the same tablet record.
+func (agent *ActionAgent) Deregister(ctx context.Context) {
+	if *tabletHeartbeatInterval == 0 {
+		return
+	}
+	if topo.TabletHeartbeatTTL() == 0 {
+		log.Infof("-tablet_heartbeat_ttl is 0, tablets are never stale, not expiring the heartbeat")
+		return
+	}
+
+	var tablet topo.Tablet
+	if err := agent.TopoServer.UpdateTabletFields(agent.TabletAlias, func(t *topo.Tablet) error {
+		t.LastHeartbeat = expiredHeartbeat
+		tablet = *t
+		return nil
+	}); err != nil {
+		log.Warningf("Cannot expire the tablet record heartbeat: %v", err)
+		return
+	}
+	if !tablet.IsStale() {
+		return
+	}
+
+	log.Infof("Tablet heartbeat expired, rebuilding the serving graph")
+	if err := agent.rebuildShardIfNeeded(topo.NewTabletInfo(&tablet, 0), tablet.Type); err != nil {
+		log.Warningf("rebuildShardIfNeeded failed, serving graph might be out of date: %v", err)
+	}
+}
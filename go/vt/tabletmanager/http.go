@@ -13,13 +13,16 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	log "github.com/golang/glog"
-	"github.com/youtube/vitess/go/cgzip"
+	"github.com/youtube/vitess/go/acl"
+	"github.com/youtube/vitess/go/compression"
 	vtenv "github.com/youtube/vitess/go/vt/env"
 	"github.com/youtube/vitess/go/vt/mysqlctl"
+	"github.com/youtube/vitess/go/vt/servenv"
 )
 
 // HttpHandleSnapshots handles the serving of files from the local tablet
@@ -37,20 +40,70 @@ func HttpHandleSnapshots(mycnf *mysqlctl.Mycnf, uid uint32) {
 	}
 
 	// NOTE: trailing slash in pattern means we handle all paths with this prefix
+	fanOut := newSnapshotFanOut()
 	http.Handle(mysqlctl.SnapshotURLPath+"/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handleSnapshot(w, r, snapshotDir, allowedPaths)
+		handleSnapshot(w, r, snapshotDir, allowedPaths, fanOut)
 	}))
+	http.HandleFunc("/debug/snapshot_transfer", handleSnapshotTransfer)
+}
+
+// handleSnapshotTransfer displays the throttling settings of the
+// snapshot and restore transfers. The rate, nice and ionice URL
+// parameters change them first.
+func handleSnapshotTransfer(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	settings := mysqlctl.GetTransferSettings()
+	changed := false
+	if value := r.FormValue("rate"); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid rate %q: %v", value, err), http.StatusBadRequest)
+			return
+		}
+		settings.Rate = rate
+		changed = true
+	}
+	if value := r.FormValue("nice"); value != "" {
+		nice, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid nice %q: %v", value, err), http.StatusBadRequest)
+			return
+		}
+		settings.Nice = nice
+		changed = true
+	}
+	if _, ok := r.Form["ionice"]; ok {
+		settings.Ionice = r.FormValue("ionice")
+		changed = true
+	}
+	if changed {
+		if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		if err := mysqlctl.SetTransferSettings(settings); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Infof("snapshot transfer settings changed to %+v", settings)
+	}
 
+	settings = mysqlctl.GetTransferSettings()
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(w, "rate=%v\nnice=%v\nionice=%v\n", settings.Rate, settings.Nice, settings.Ionice)
 }
 
 // serve an individual query
-func handleSnapshot(rw http.ResponseWriter, req *http.Request, snapshotDir string, allowedPaths []string) {
+func handleSnapshot(rw http.ResponseWriter, req *http.Request, snapshotDir string, allowedPaths []string, fanOut *snapshotFanOut) {
 	// if we get any error, we'll try to write a server error
 	// (it will fail if the header has already been written, but at least
 	// we won't crash vttablet)
 	defer func() {
 		if x := recover(); x != nil {
-			log.Errorf("vttablet http server panic: %v", x)
+			servenv.RecordPanic("vttablet snapshot server", x)
 			http.Error(rw, fmt.Sprintf("500 internal server error: %v", x), http.StatusInternalServerError)
 		}
 	}()
@@ -88,7 +141,7 @@ func handleSnapshot(rw http.ResponseWriter, req *http.Request, snapshotDir strin
 			continue
 		}
 		if strings.HasPrefix(realPath, allowedPath) {
-			sendFile(rw, req, realPath)
+			sendFile(rw, req, realPath, fanOut)
 			return
 		}
 	}
@@ -98,7 +151,7 @@ func handleSnapshot(rw http.ResponseWriter, req *http.Request, snapshotDir strin
 }
 
 // custom function to serve files
-func sendFile(rw http.ResponseWriter, req *http.Request, path string) {
+func sendFile(rw http.ResponseWriter, req *http.Request, path string, fanOut *snapshotFanOut) {
 	log.Infof("serve %v %v", req.URL.Path, path)
 	file, err := os.Open(path)
 	if err != nil {
@@ -125,22 +178,29 @@ func sendFile(rw http.ResponseWriter, req *http.Request, path string) {
 		return
 	}
 
+	// several tablets restoring at the same time share the transfer
+	if want, err := strconv.Atoi(req.URL.Query().Get(mysqlctl.SnapshotFanOutParameter)); err == nil && want > 1 && fileinfo.Size() >= *snapshotFanOutMinSize {
+		sendFileFanOut(rw, req, path, file, fileinfo, fanOut, want)
+		return
+	}
+
 	// support Accept-Encoding header
 	var writer io.Writer = rw
 	var reader io.Reader = file
-	if !strings.HasSuffix(path, ".gz") {
-		ae := req.Header.Get("Accept-Encoding")
-
-		if strings.Contains(ae, "gzip") {
-			gz, err := cgzip.NewWriterLevel(rw, cgzip.Z_BEST_SPEED)
-			if err != nil {
-				http.Error(rw, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			rw.Header().Set("Content-Encoding", "gzip")
-			defer gz.Close()
-			writer = gz
+	codec, err := transferCodec(req, path)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if codec != nil {
+		compressor, err := compression.NewWriter(codec, rw)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
 		}
+		rw.Header().Set("Content-Encoding", codec.Name())
+		defer compressor.Close()
+		writer = compressor
 	}
 
 	// add content-length if we know it
@@ -151,7 +211,48 @@ func sendFile(rw http.ResponseWriter, req *http.Request, path string) {
 	// and just copy content out
 	rw.Header().Set("Last-Modified", fileinfo.ModTime().UTC().Format(http.TimeFormat))
 	rw.WriteHeader(http.StatusOK)
-	if _, err := io.Copy(writer, reader); err != nil {
+	if err := mysqlctl.WithTransferPriority(func() error {
+		_, err := io.Copy(writer, mysqlctl.NewThrottledReader(reader))
+		return err
+	}); err != nil {
+		log.Warningf("transfer failed %v: %v", path, err)
+	}
+}
+
+// transferCodec returns the codec to compress a file with, from
+// the Accept-Encoding of the request, or nil. Files that are already
+// compressed are sent as they are.
+func transferCodec(req *http.Request, path string) (compression.Codec, error) {
+	if strings.HasSuffix(path, ".gz") {
+		return nil, nil
+	}
+	codecs, err := mysqlctl.SnapshotCompressionCodecs()
+	if err != nil {
+		return nil, err
+	}
+	codec := compression.Negotiate(codecs, req.Header.Get("Accept-Encoding"))
+	if codec == nil || codec.Name() == compression.None {
+		return nil, nil
+	}
+	return codec, nil
+}
+
+// sendFileFanOut sends a file the way sendFile does, but shares the
+// read and the compression with the other requests for the file.
+func sendFileFanOut(rw http.ResponseWriter, req *http.Request, path string, file *os.File, fileinfo os.FileInfo, fanOut *snapshotFanOut, want int) {
+	codec, err := transferCodec(req, path)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if codec != nil {
+		rw.Header().Set("Content-Encoding", codec.Name())
+	} else {
+		rw.Header().Set("Content-Length", fmt.Sprintf("%v", fileinfo.Size()))
+	}
+	rw.Header().Set("Last-Modified", fileinfo.ModTime().UTC().Format(http.TimeFormat))
+	rw.WriteHeader(http.StatusOK)
+	if err := fanOut.send(rw, file, path, codec, want); err != nil {
 		log.Warningf("transfer failed %v: %v", path, err)
 	}
 }
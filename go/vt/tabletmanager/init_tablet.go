@@ -10,6 +10,7 @@ package tabletmanager
 import (
 	"flag"
 	"fmt"
+	"reflect"
 	"time"
 
 	log "github.com/golang/glog"
@@ -78,6 +79,7 @@ func (agent *ActionAgent) InitTablet(port, securePort int) error {
 
 	// if we're assigned to a shard, make sure it exists, see if
 	// we are its master, and update its cells list if necessary
+	var masterTerm int64
 	if tabletType != topo.TYPE_IDLE {
 		if *initKeyspace == "" || *initShard == "" {
 			log.Fatalf("if init tablet is enabled and the target type is not idle, init_keyspace and init_shard also need to be specified")
@@ -111,8 +113,9 @@ func (agent *ActionAgent) InitTablet(port, securePort int) error {
 		if si.MasterAlias == agent.TabletAlias {
 			// we are the current master for this shard (probably
 			// means the master tablet process was just restarted),
-			// so InitTablet as master.
+			// so InitTablet as master, in the current term.
 			tabletType = topo.TYPE_MASTER
+			masterTerm = si.MasterTerm
 		}
 
 		// See if we need to add the tablet's cell to the shard's cell
@@ -168,6 +171,7 @@ func (agent *ActionAgent) InitTablet(port, securePort int) error {
 		Type:           tabletType,
 		DbNameOverride: *initDbNameOverride,
 		Tags:           initTags,
+		MasterTerm:     masterTerm,
 	}
 	if port != 0 {
 		tablet.Portmap["vt"] = port
@@ -180,6 +184,7 @@ func (agent *ActionAgent) InitTablet(port, securePort int) error {
 	}
 
 	// now try to create the record
+	rebuild := tabletType != topo.TYPE_IDLE
 	err := topo.CreateTablet(ctx, agent.TopoServer, tablet)
 	switch err {
 	case nil:
@@ -195,7 +200,7 @@ func (agent *ActionAgent) InitTablet(port, securePort int) error {
 		// it. So we read it first.
 		oldTablet, err := agent.TopoServer.GetTablet(tablet.Alias)
 		if err != nil {
-			fmt.Errorf("InitTablet failed to read existing tablet record: %v", err)
+			return fmt.Errorf("InitTablet failed to read existing tablet record: %v", err)
 		}
 
 		// Sanity check the keyspace and shard
@@ -203,6 +208,21 @@ func (agent *ActionAgent) InitTablet(port, securePort int) error {
 			return fmt.Errorf("InitTablet failed because existing tablet keyspace and shard %v/%v differ from the provided ones %v/%v", oldTablet.Keyspace, oldTablet.Shard, tablet.Keyspace, tablet.Shard)
 		}
 
+		// If vttablet is just restarting (it found the local
+		// state of the previous process), keep the type the
+		// tablet was changed to since it was initialized.
+		if agent.localState() != nil && keepTypeOnRestart(oldTablet.Type, tabletType) {
+			log.Infof("Restarting tablet, keeping its current type %v", oldTablet.Type)
+			tablet.Type = oldTablet.Type
+		}
+
+		// The serving graph is already correct if the record
+		// didn't change (a new hostname or port changes the
+		// serving addresses too).
+		if reflect.DeepEqual(oldTablet.Tablet, tablet) {
+			rebuild = false
+		}
+
 		// And overwrite the rest
 		*(oldTablet.Tablet) = *tablet
 		if err := topo.UpdateTablet(ctx, agent.TopoServer, oldTablet); err != nil {
@@ -216,9 +236,10 @@ func (agent *ActionAgent) InitTablet(port, securePort int) error {
 		return fmt.Errorf("CreateTablet failed: %v", err)
 	}
 
-	// and now rebuild the serving graph. Note we do that in any case,
-	// to clean any inaccurate record from any part of the serving graph.
-	if tabletType != topo.TYPE_IDLE {
+	// and now rebuild the serving graph. Note we do that in any case
+	// but a restart with the same record, to clean any inaccurate
+	// record from any part of the serving graph.
+	if rebuild {
 		if _, err := topotools.RebuildShard(ctx, logutil.NewConsoleLogger(), agent.TopoServer, tablet.Keyspace, tablet.Shard, []string{tablet.Alias.Cell}, agent.LockTimeout); err != nil {
 			return fmt.Errorf("RebuildShard failed: %v", err)
 		}
@@ -226,3 +247,20 @@ func (agent *ActionAgent) InitTablet(port, securePort int) error {
 
 	return nil
 }
+
+// keepTypeOnRestart returns true if a tablet that already has a
+// record of type oldType should keep it, instead of using the init
+// type newType. This is only done when the type is set with
+// init_tablet_type: with health check, the tablet starts as spare
+// until it is healthy. Types used while an action is running are not
+// kept, as the action was interrupted.
+func keepTypeOnRestart(oldType, newType topo.TabletType) bool {
+	if *initTabletType == "" || oldType == newType {
+		return false
+	}
+	switch oldType {
+	case topo.TYPE_IDLE, topo.TYPE_SCRAP, topo.TYPE_BACKUP, topo.TYPE_SNAPSHOT_SOURCE, topo.TYPE_RESTORE, topo.TYPE_CHECKER:
+		return false
+	}
+	return true
+}
@@ -12,7 +12,7 @@ import (
 
 	"github.com/youtube/vitess/go/history"
 	"github.com/youtube/vitess/go/stats"
-	"github.com/youtube/vitess/go/vt/mysqlctl"
+	"github.com/youtube/vitess/go/vt/mysqlctl/fakemysqldaemon"
 	"github.com/youtube/vitess/go/vt/topo"
 	"github.com/youtube/vitess/go/vt/zktopo"
 	"golang.org/x/net/context"
@@ -31,7 +31,7 @@ func TestInitTablet(t *testing.T) {
 	// start with idle, and a tablet record that doesn't exist
 	port := 1234
 	securePort := 2345
-	mysqlDaemon := &mysqlctl.FakeMysqlDaemon{}
+	mysqlDaemon := &fakemysqldaemon.FakeMysqlDaemon{}
 	agent := &ActionAgent{
 		TopoServer:         ts,
 		TabletAlias:        tabletAlias,
@@ -175,4 +175,60 @@ func TestInitTablet(t *testing.T) {
 	if len(ti.Tags) != 1 || ti.Tags["aaa"] != "bbb" {
 		t.Errorf("wrong tablet tags: %v", ti.Tags)
 	}
+
+	// change the type of the tablet, as a vtctl action would, and
+	// clear the shard master: without local state, this is not a
+	// restart, and the init type is used
+	si.MasterAlias = topo.TabletAlias{}
+	if err := topo.UpdateShard(context.Background(), ts, si); err != nil {
+		t.Fatalf("UpdateShard failed: %v", err)
+	}
+	ti.Type = topo.TYPE_RDONLY
+	if err := topo.UpdateTablet(context.Background(), ts, ti); err != nil {
+		t.Fatalf("UpdateTablet failed: %v", err)
+	}
+	if err := agent.InitTablet(port, securePort); err != nil {
+		t.Fatalf("InitTablet(no local state) failed: %v", err)
+	}
+	ti, err = ts.GetTablet(tabletAlias)
+	if err != nil {
+		t.Fatalf("GetTablet failed: %v", err)
+	}
+	if ti.Type != topo.TYPE_REPLICA {
+		t.Errorf("wrong tablet type without local state: %v", ti.Type)
+	}
+
+	// restarting with local state keeps the current type
+	agent._localState = &LocalState{Keyspace: "test_keyspace", Shard: "-80", Type: topo.TYPE_RDONLY}
+	ti.Type = topo.TYPE_RDONLY
+	if err := topo.UpdateTablet(context.Background(), ts, ti); err != nil {
+		t.Fatalf("UpdateTablet failed: %v", err)
+	}
+	if err := agent.InitTablet(port, securePort); err != nil {
+		t.Fatalf("InitTablet(restart) failed: %v", err)
+	}
+	ti, err = ts.GetTablet(tabletAlias)
+	if err != nil {
+		t.Fatalf("GetTablet failed: %v", err)
+	}
+	if ti.Type != topo.TYPE_RDONLY {
+		t.Errorf("wrong tablet type after restart: %v", ti.Type)
+	}
+
+	// but a tablet interrupted in the middle of a backup goes back
+	// to its init type
+	ti.Type = topo.TYPE_BACKUP
+	if err := topo.UpdateTablet(context.Background(), ts, ti); err != nil {
+		t.Fatalf("UpdateTablet failed: %v", err)
+	}
+	if err := agent.InitTablet(port, securePort); err != nil {
+		t.Fatalf("InitTablet(restart after backup) failed: %v", err)
+	}
+	ti, err = ts.GetTablet(tabletAlias)
+	if err != nil {
+		t.Fatalf("GetTablet failed: %v", err)
+	}
+	if ti.Type != topo.TYPE_REPLICA {
+		t.Errorf("wrong tablet type after restart during backup: %v", ti.Type)
+	}
 }
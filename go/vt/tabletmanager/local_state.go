@@ -0,0 +1,154 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletmanager
+
+// This file handles the state the agent keeps on local disk, so a
+// restarted vttablet can pick up where the previous process left
+// off, even if the topology server is not reachable.
+
+import (
+	"os"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/jscfg"
+	"github.com/youtube/vitess/go/vt/mysqlctl"
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+// localStateFile is the name of the state file, in the tablet directory.
+const localStateFile = "tablet_state.json"
+
+// LocalState is the part of the agent state that survives a restart
+// of vttablet.
+type LocalState struct {
+	Keyspace string
+	Shard    string
+	Type     topo.TabletType
+
+	// BlacklistedTables and DisableQueryService are the values of
+	// the TabletControl the agent was using.
+	BlacklistedTables   []string
+	DisableQueryService bool
+
+	// SlaveStopped is true if replication was stopped on purpose
+	// with StopSlave, and should not be restarted.
+	SlaveStopped bool
+}
+
+// matches returns true if the state was saved for the same keyspace,
+// shard and type as the provided tablet.
+func (ls *LocalState) matches(tablet *topo.Tablet) bool {
+	return ls.Keyspace == tablet.Keyspace && ls.Shard == tablet.Shard && ls.Type == tablet.Type
+}
+
+// readLocalState returns the state saved in filename, or nil if
+// there is none.
+func readLocalState(filename string) (*LocalState, error) {
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil, nil
+	}
+	ls := &LocalState{}
+	if err := jscfg.ReadJson(filename, ls); err != nil {
+		return nil, err
+	}
+	return ls, nil
+}
+
+// loadLocalState reads the state saved by a previous vttablet
+// process, if any.
+func (agent *ActionAgent) loadLocalState() {
+	if agent.localStateFile == "" {
+		return
+	}
+	ls, err := readLocalState(agent.localStateFile)
+	if err != nil {
+		log.Warningf("cannot read local state, ignoring it: %v", err)
+		return
+	}
+	if ls == nil {
+		return
+	}
+	log.Infof("restarting with local state: %+v", *ls)
+	agent.mutex.Lock()
+	agent._localState = ls
+	agent._slaveStopped = ls.SlaveStopped
+	agent.mutex.Unlock()
+}
+
+// saveLocalState writes the current state of the agent to disk.
+// Failures are logged, as the state is only used to speed up
+// restarts.
+func (agent *ActionAgent) saveLocalState() {
+	if agent.localStateFile == "" {
+		return
+	}
+	agent.mutex.Lock()
+	ls := &LocalState{
+		SlaveStopped: agent._slaveStopped,
+	}
+	if agent._tablet != nil {
+		ls.Keyspace = agent._tablet.Keyspace
+		ls.Shard = agent._tablet.Shard
+		ls.Type = agent._tablet.Type
+	}
+	if agent._tabletControl != nil {
+		ls.BlacklistedTables = agent._tabletControl.BlacklistedTables
+		ls.DisableQueryService = agent._tabletControl.DisableQueryService
+	}
+	agent._localState = ls
+	agent.mutex.Unlock()
+
+	if err := jscfg.WriteJson(agent.localStateFile, ls); err != nil {
+		log.Warningf("cannot save local state: %v", err)
+	}
+}
+
+// localState returns the last saved local state, or nil.
+func (agent *ActionAgent) localState() *LocalState {
+	agent.mutex.Lock()
+	defer agent.mutex.Unlock()
+	return agent._localState
+}
+
+// setSlaveStopped records whether replication was stopped on purpose.
+func (agent *ActionAgent) setSlaveStopped(stopped bool) {
+	agent.mutex.Lock()
+	agent._slaveStopped = stopped
+	agent.mutex.Unlock()
+	agent.saveLocalState()
+}
+
+// restartReplication is called after a restart of vttablet. It
+// starts replication if it is not running and it wasn't stopped on
+// purpose. This covers the case where mysqld was restarted too, as
+// it doesn't start replication by itself (skip_slave_start).
+func (agent *ActionAgent) restartReplication() {
+	agent.mutex.Lock()
+	slaveStopped := agent._slaveStopped
+	agent.mutex.Unlock()
+	if slaveStopped {
+		return
+	}
+	tablet := agent.Tablet()
+	if tablet == nil || !topo.IsSlaveType(tablet.Type) {
+		return
+	}
+
+	status, err := agent.MysqlDaemon.SlaveStatus()
+	if err == mysqlctl.ErrNotSlave {
+		return
+	}
+	if err != nil {
+		log.Warningf("cannot get slave status, not restarting replication: %v", err)
+		return
+	}
+	if status.SlaveRunning() || status.MasterHost == "" {
+		return
+	}
+	log.Infof("replication is not running after restart, starting it")
+	if err := agent.MysqlDaemon.StartSlave(agent.hookExtraEnv()); err != nil {
+		log.Warningf("cannot restart replication: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/youtube/vitess/go/vt/mysqlctl/fakemysqldaemon"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+func TestLocalState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "local_state")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tablet := &topo.Tablet{
+		Keyspace: "test_keyspace",
+		Shard:    "-80",
+		Type:     topo.TYPE_REPLICA,
+	}
+	agent := &ActionAgent{
+		localStateFile: path.Join(dir, localStateFile),
+		_tablet:        topo.NewTabletInfo(tablet, 0),
+		_tabletControl: &topo.TabletControl{
+			BlacklistedTables: []string{"t1", "t2"},
+		},
+	}
+
+	// nothing saved yet
+	agent.loadLocalState()
+	if ls := agent.localState(); ls != nil {
+		t.Fatalf("unexpected local state: %v", ls)
+	}
+
+	agent.setSlaveStopped(true)
+
+	// a new agent gets the saved state back
+	agent = &ActionAgent{
+		localStateFile: agent.localStateFile,
+	}
+	agent.loadLocalState()
+	want := &LocalState{
+		Keyspace:          "test_keyspace",
+		Shard:             "-80",
+		Type:              topo.TYPE_REPLICA,
+		BlacklistedTables: []string{"t1", "t2"},
+		SlaveStopped:      true,
+	}
+	ls := agent.localState()
+	if !reflect.DeepEqual(ls, want) {
+		t.Errorf("loadLocalState got %v, want %v", ls, want)
+	}
+	if !ls.matches(tablet) {
+		t.Errorf("local state should match the tablet")
+	}
+}
+
+func TestRestartReplication(t *testing.T) {
+	mysqlDaemon := &fakemysqldaemon.FakeMysqlDaemon{
+		CurrentSlaveStatus: &myproto.ReplicationStatus{
+			MasterHost: "master.host",
+		},
+	}
+	agent := &ActionAgent{
+		MysqlDaemon: mysqlDaemon,
+		_tablet: topo.NewTabletInfo(&topo.Tablet{
+			Type: topo.TYPE_REPLICA,
+		}, 0),
+	}
+
+	// replication was stopped on purpose
+	agent._slaveStopped = true
+	agent.restartReplication()
+	if mysqlDaemon.Replicating {
+		t.Errorf("replication was restarted after StopSlave")
+	}
+
+	// replication was stopped by a mysqld restart
+	agent._slaveStopped = false
+	agent.restartReplication()
+	if !mysqlDaemon.Replicating {
+		t.Errorf("replication was not restarted")
+	}
+}
@@ -85,6 +85,7 @@ func (agent *ActionAgent) fastTabletExternallyReparented(ctx context.Context, ex
 	newTablet := oldTablet
 	newTablet.Type = topo.TYPE_MASTER
 	newTablet.Health = nil
+	newTablet.MasterTerm = si.MasterTerm + 1
 	agent.setTablet(topo.NewTabletInfo(&newTablet, -1))
 	if err := agent.updateState(ctx, &oldTablet, "fastTabletExternallyReparented"); err != nil {
 		return fmt.Errorf("fastTabletExternallyReparented: failed to change tablet state to MASTER: %v", err)
@@ -143,6 +144,7 @@ func (agent *ActionAgent) finalizeTabletExternallyReparented(ctx context.Context
 			func(tablet *topo.Tablet) error {
 				tablet.Type = topo.TYPE_MASTER
 				tablet.Health = nil
+				tablet.MasterTerm = si.MasterTerm + 1
 				return nil
 			})
 		errs.RecordError(err)
@@ -184,16 +186,29 @@ func (agent *ActionAgent) finalizeTabletExternallyReparented(ctx context.Context
 		return errs.Error()
 	}
 
-	// Update the master field in the global shard record. We don't use a lock
-	// here anymore. The lock was only to ensure that the global shard record
-	// didn't get modified between the time when we read it and the time when we
-	// write it back. Now we use an update loop pattern to do that instead.
+	// Update the master field in the global shard record, which starts
+	// a new master term. We stamped our tablet with a term computed from
+	// a shard record read without the lock, so another reparent may have
+	// started a term since: stamp it again with the one we started.
 	event.DispatchUpdate(ev, "updating global shard record")
 	log.Infof("finalizeTabletExternallyReparented: updating global shard record")
-	topo.UpdateShardFields(ctx, agent.TopoServer, tablet.Keyspace, tablet.Shard, func(shard *topo.Shard) error {
-		shard.MasterAlias = tablet.Alias
-		return nil
-	})
+	masterTerm, err := agent.startMasterTerm(ctx, tablet.Tablet)
+	if err != nil {
+		return err
+	}
+	if masterTerm != tablet.MasterTerm {
+		log.Infof("finalizeTabletExternallyReparented: master term is %v, not %v", masterTerm, tablet.MasterTerm)
+		if err := topo.UpdateTabletFields(ctx, agent.TopoServer, agent.TabletAlias, func(tablet *topo.Tablet) error {
+			tablet.MasterTerm = masterTerm
+			return nil
+		}); err != nil {
+			return err
+		}
+		newTablet := *tablet.Tablet
+		newTablet.MasterTerm = masterTerm
+		agent.setTablet(topo.NewTabletInfo(&newTablet, -1))
+		agent.checkMasterTerm(ctx, &newTablet)
+	}
 
 	// Rebuild the shard serving graph in the necessary cells.
 	// If it's a cross-cell reparent, rebuild all cells (by passing nil).
@@ -213,10 +228,60 @@ func (agent *ActionAgent) finalizeTabletExternallyReparented(ctx context.Context
 	return nil
 }
 
+// startMasterTerm makes tablet the master of its shard under the
+// shard lock, and returns the master term it started. If the tablet
+// already is the master, the shard record is not changed, and the
+// current term is returned: the shard is only locked to change it.
+func (agent *ActionAgent) startMasterTerm(ctx context.Context, tablet *topo.Tablet) (int64, error) {
+	shardInfo, err := topo.GetShard(ctx, agent.TopoServer, tablet.Keyspace, tablet.Shard)
+	if err != nil {
+		return 0, err
+	}
+	if shardInfo.MasterAlias == tablet.Alias {
+		return shardInfo.MasterTerm, nil
+	}
+
+	actionNode := actionnode.ShardExternallyReparented(agent.TabletAlias)
+	lockPath, err := actionNode.LockShard(ctx, agent.TopoServer, tablet.Keyspace, tablet.Shard)
+	if err != nil {
+		return 0, fmt.Errorf("cannot lock shard %v/%v: %v", tablet.Keyspace, tablet.Shard, err)
+	}
+	// check again under the lock, another reparent to us may have
+	// started the term since
+	shardInfo, err = topo.GetShard(ctx, agent.TopoServer, tablet.Keyspace, tablet.Shard)
+	if err == nil && shardInfo.MasterAlias != tablet.Alias {
+		shardInfo.SetMaster(tablet.Alias)
+		err = topo.UpdateShard(ctx, agent.TopoServer, shardInfo)
+	}
+	if err = actionNode.UnlockShard(ctx, agent.TopoServer, tablet.Keyspace, tablet.Shard, lockPath, err); err != nil {
+		return 0, err
+	}
+	return shardInfo.MasterTerm, nil
+}
+
+// checkWritable returns an error if mysql is read-only. The external
+// tool must make the new master writable before it tells us about it,
+// or we would send the master traffic to a tablet that refuses writes.
+func (agent *ActionAgent) checkWritable() error {
+	readOnly, err := agent.MysqlDaemon.IsReadOnly()
+	if err != nil {
+		return fmt.Errorf("cannot check if mysql is read-only: %v", err)
+	}
+	if readOnly {
+		return fmt.Errorf("mysql of tablet %v is read-only, it cannot be the master", agent.TabletAlias)
+	}
+	return nil
+}
+
 // TabletExternallyReparented updates all topo records so the current
 // tablet is the new master for this shard.
 // Should be called under RPCWrapLock.
 func (agent *ActionAgent) TabletExternallyReparented(ctx context.Context, externalID string) error {
+	if err := agent.checkWritable(); err != nil {
+		log.Warningf("TabletExternallyReparented: %v", err)
+		return err
+	}
+
 	if *fastReparent {
 		return agent.fastTabletExternallyReparented(ctx, externalID)
 	}
@@ -368,7 +433,7 @@ func (agent *ActionAgent) tabletExternallyReparentedLocked(ctx context.Context,
 	// now update the master record in the shard object
 	event.DispatchUpdate(ev, "updating shard record")
 	log.Infof("Updating Shard's MasterAlias record")
-	shardInfo.MasterAlias = tablet.Alias
+	shardInfo.SetMaster(tablet.Alias)
 	if err = topo.UpdateShard(ctx, agent.TopoServer, shardInfo); err != nil {
 		return true, err
 	}
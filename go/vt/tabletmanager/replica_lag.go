@@ -0,0 +1,166 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletmanager
+
+import (
+	"flag"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
+)
+
+var (
+	watchReplicaLag = flag.Bool("watch_replica_lag", false, "if set, the master streams the health of the replicas of its shard, and gives their replication lag to the query service so it can throttle the transactions (see -queryserver-config-tx-throttle-lag)")
+
+	// replicaLagRetryDelay is how long we wait before streaming
+	// again from a replica after an error.
+	replicaLagRetryDelay = 5 * time.Second
+)
+
+// replicaLag is the last lag streamed by a replica.
+type replicaLag struct {
+	lag  time.Duration
+	time time.Time
+}
+
+// replicaLagWatcher streams the health of the replicas of a shard,
+// to know their replication lag. The rdonly tablets are not watched,
+// as they can be lagging on purpose, for instance during diffs.
+type replicaLagWatcher struct {
+	ts  topo.Server
+	tmc tmclient.TabletManagerClient
+
+	// maxAge is how long a streamed lag is used.
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	streams map[topo.TabletAlias]context.CancelFunc
+	lags    map[topo.TabletAlias]replicaLag
+}
+
+func newReplicaLagWatcher(ts topo.Server, tmc tmclient.TabletManagerClient, maxAge time.Duration) *replicaLagWatcher {
+	return &replicaLagWatcher{
+		ts:      ts,
+		tmc:     tmc,
+		maxAge:  maxAge,
+		streams: make(map[topo.TabletAlias]context.CancelFunc),
+		lags:    make(map[topo.TabletAlias]replicaLag),
+	}
+}
+
+// update starts streaming from the replicas of the shard we don't
+// stream from yet, stops streaming from the tablets that are not
+// replicas any more, and returns the highest recent lag.
+func (w *replicaLagWatcher) update(ctx context.Context, keyspace, shard string) (time.Duration, error) {
+	tablets, err := topo.GetTabletMapForShard(ctx, w.ts, keyspace, shard)
+	if err != nil && err != topo.ErrPartialResult {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for alias, cancel := range w.streams {
+		if ti, ok := tablets[alias]; !ok || ti.Type != topo.TYPE_REPLICA {
+			cancel()
+			delete(w.streams, alias)
+			delete(w.lags, alias)
+		}
+	}
+	for alias, ti := range tablets {
+		if _, ok := w.streams[alias]; ok || ti.Type != topo.TYPE_REPLICA {
+			continue
+		}
+		streamCtx, cancel := context.WithCancel(context.Background())
+		w.streams[alias] = cancel
+		go w.stream(streamCtx, ti)
+	}
+
+	var maxLag time.Duration
+	now := time.Now()
+	for _, rl := range w.lags {
+		if now.Sub(rl.time) <= w.maxAge && rl.lag > maxLag {
+			maxLag = rl.lag
+		}
+	}
+	return maxLag, nil
+}
+
+// stop stops streaming from all the replicas.
+func (w *replicaLagWatcher) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for alias, cancel := range w.streams {
+		cancel()
+		delete(w.streams, alias)
+		delete(w.lags, alias)
+	}
+}
+
+// stream records the lag of a replica until ctx is canceled.
+func (w *replicaLagWatcher) stream(ctx context.Context, ti *topo.TabletInfo) {
+	for {
+		c, errFunc, err := w.tmc.HealthStream(ctx, ti)
+		if err == nil {
+			for hsr := range c {
+				w.record(ti.Alias, hsr)
+			}
+			err = errFunc()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warningf("health stream from %v interrupted, retrying in %v: %v", ti.Alias, replicaLagRetryDelay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(replicaLagRetryDelay):
+		}
+	}
+}
+
+// record saves the lag of a replica. It is saved even if the replica
+// is unhealthy: the health check reports an error when the lag is over
+// -unhealthy_threshold, and that's when throttling matters most.
+func (w *replicaLagWatcher) record(alias topo.TabletAlias, hsr *actionnode.HealthStreamReply) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.streams[alias]; !ok {
+		return
+	}
+	w.lags[alias] = replicaLag{
+		lag:  hsr.ReplicationDelay,
+		time: time.Now(),
+	}
+}
+
+// refreshReplicaLag gives the replication lag of the replicas to the
+// query service when we're the master. It is called after each
+// health check, from the health check goroutine only.
+func (agent *ActionAgent) refreshReplicaLag() {
+	if !*watchReplicaLag {
+		return
+	}
+	tablet := agent.Tablet()
+	if tablet.Type != topo.TYPE_MASTER {
+		if agent.replicaLagWatcher != nil {
+			agent.replicaLagWatcher.stop()
+		}
+		return
+	}
+	if agent.replicaLagWatcher == nil {
+		agent.replicaLagWatcher = newReplicaLagWatcher(agent.TopoServer, tmclient.NewTabletManagerClient(), *healthCheckInterval*3)
+	}
+	lag, err := agent.replicaLagWatcher.update(agent.batchCtx, tablet.Keyspace, tablet.Shard)
+	if err != nil {
+		log.Warningf("cannot watch the replica lag: %v", err)
+		return
+	}
+	agent.QueryServiceControl.SetReplicaLag(lag)
+}
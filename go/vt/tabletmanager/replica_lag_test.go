@@ -0,0 +1,130 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/zktopo"
+	"golang.org/x/net/context"
+)
+
+// lagTabletManagerClient streams the lag of the tablets, by uid. It
+// doesn't implement the other methods.
+type lagTabletManagerClient struct {
+	tmclient.TabletManagerClient
+	lags map[uint32]time.Duration
+}
+
+func (client *lagTabletManagerClient) HealthStream(ctx context.Context, tablet *topo.TabletInfo) (<-chan *actionnode.HealthStreamReply, tmclient.ErrFunc, error) {
+	c := make(chan *actionnode.HealthStreamReply, 1)
+	c <- &actionnode.HealthStreamReply{
+		Tablet:           tablet.Tablet,
+		ReplicationDelay: client.lags[tablet.Alias.Uid],
+	}
+	go func() {
+		<-ctx.Done()
+		close(c)
+	}()
+	return c, func() error { return nil }, nil
+}
+
+func TestReplicaLagWatcher(t *testing.T) {
+	ctx := context.Background()
+	ts := zktopo.NewTestServer(t, []string{cell})
+	if err := ts.CreateKeyspace(keyspace, &topo.Keyspace{}); err != nil {
+		t.Fatalf("CreateKeyspace failed: %v", err)
+	}
+	if err := topo.CreateShard(ts, keyspace, shard); err != nil {
+		t.Fatalf("CreateShard failed: %v", err)
+	}
+	si, err := ts.GetShard(keyspace, shard)
+	if err != nil {
+		t.Fatalf("GetShard failed: %v", err)
+	}
+	si.Cells = []string{cell}
+	if err := topo.UpdateShard(ctx, ts, si); err != nil {
+		t.Fatalf("UpdateShard failed: %v", err)
+	}
+	for uid, tabletType := range map[uint32]topo.TabletType{
+		1: topo.TYPE_MASTER,
+		2: topo.TYPE_REPLICA,
+		3: topo.TYPE_REPLICA,
+		4: topo.TYPE_RDONLY,
+	} {
+		if err := topo.CreateTablet(ctx, ts, &topo.Tablet{
+			Alias:    topo.TabletAlias{Cell: cell, Uid: uid},
+			Hostname: "host",
+			Portmap:  map[string]int{"vt": 1234},
+			IPAddr:   "1.0.0.1",
+			Keyspace: keyspace,
+			Shard:    shard,
+			Type:     tabletType,
+		}); err != nil {
+			t.Fatalf("CreateTablet failed: %v", err)
+		}
+	}
+	tmc := &lagTabletManagerClient{
+		lags: map[uint32]time.Duration{
+			1: 40 * time.Second,
+			2: 5 * time.Second,
+			3: 10 * time.Second,
+			4: 30 * time.Second,
+		},
+	}
+	w := newReplicaLagWatcher(ts, tmc, time.Minute)
+	defer w.stop()
+
+	// only the replicas count
+	waitForReplicaLag(t, w, 10*time.Second)
+
+	// a tablet that isn't a replica any more doesn't count
+	if err := ts.UpdateTabletFields(topo.TabletAlias{Cell: cell, Uid: 3}, func(tablet *topo.Tablet) error {
+		tablet.Type = topo.TYPE_SPARE
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateTabletFields failed: %v", err)
+	}
+	waitForReplicaLag(t, w, 5*time.Second)
+
+	// and lags that are too old don't count either
+	w.maxAge = 0
+	waitForReplicaLag(t, w, 0)
+}
+
+func waitForReplicaLag(t *testing.T, w *replicaLagWatcher, want time.Duration) {
+	var lag time.Duration
+	for i := 0; i < 100; i++ {
+		var err error
+		lag, err = w.update(context.Background(), keyspace, shard)
+		if err != nil {
+			t.Fatalf("update failed: %v", err)
+		}
+		if lag == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("replica lag is %v, want %v", lag, want)
+}
+
+func TestReplicaLagWatcherUnhealthy(t *testing.T) {
+	w := newReplicaLagWatcher(nil, nil, time.Minute)
+	alias := topo.TabletAlias{Cell: cell, Uid: 2}
+	w.streams[alias] = func() {}
+
+	// a replica that is unhealthy because it's too late counts
+	w.record(alias, &actionnode.HealthStreamReply{
+		ReplicationDelay: time.Hour,
+		HealthError:      "reported replication lag: 3600 higher than unhealthy threshold: 1800",
+	})
+	if got := w.lags[alias].lag; got != time.Hour {
+		t.Errorf("recorded lag is %v, want %v", got, time.Hour)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/vt/callinfo"
+	"github.com/youtube/vitess/go/vt/servenv"
 	"golang.org/x/net/context"
 )
 
@@ -31,7 +32,10 @@ const rpcTimeout = time.Second * 30
 func (agent *ActionAgent) rpcWrapper(ctx context.Context, name string, args, reply interface{}, verbose bool, f func() error, lock, runAfterAction bool) (err error) {
 	defer func() {
 		if x := recover(); x != nil {
-			log.Errorf("TabletManager.%v(%v) panic: %v", name, args, x)
+			// the component is a counter key, so the args are
+			// logged separately
+			servenv.RecordPanic("TabletManager."+name, x)
+			log.Errorf("TabletManager.%v panicked with args: %v", name, args)
 			err = fmt.Errorf("caught panic during %v: %v", name, x)
 		}
 	}()
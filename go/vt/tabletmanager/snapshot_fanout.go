@@ -0,0 +1,286 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletmanager
+
+// This file handles sending one snapshot file to several restoring
+// tablets at once.
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/compression"
+	"github.com/youtube/vitess/go/vt/mysqlctl"
+)
+
+var (
+	snapshotFanOutWait    = flag.Duration("snapshot_fanout_wait", 10*time.Second, "how long the first request for a snapshot file waits for the other tablets restoring at the same time before sending the file without them")
+	snapshotFanOutMinSize = flag.Int64("snapshot_fanout_min_size", 1024*1024, "snapshot files smaller than this are sent to each restoring tablet separately")
+	snapshotFanOutStall   = flag.Duration("snapshot_fanout_stall", 5*time.Second, "a restoring tablet that can't take more data of a shared snapshot file for this long, while the others can, is dropped from the transfer")
+)
+
+var (
+	// errAllReceiversFailed is returned by fanOutWriter when none of
+	// its receivers can be written to.
+	errAllReceiversFailed = errors.New("all fan-out receivers failed")
+
+	// errSlowReceiver is returned to the receivers that were dropped
+	// because they couldn't keep up with the others.
+	errSlowReceiver = errors.New("fan-out receiver too slow, dropped")
+)
+
+// fanOutBufferChunks is how many chunks of data each receiver can
+// queue.
+var fanOutBufferChunks = 64
+
+// snapshotFanOut groups the requests for the same snapshot file
+// that arrive at the same time. The file is read, and compressed if
+// needed, once, and the data is written to all the requests of the
+// group.
+type snapshotFanOut struct {
+	mu        sync.Mutex
+	transfers map[string]*fanOutTransfer
+}
+
+func newSnapshotFanOut() *snapshotFanOut {
+	return &snapshotFanOut{
+		transfers: make(map[string]*fanOutTransfer),
+	}
+}
+
+// fanOutTransfer is a group of requests for one file. The first
+// request of the group sends the file to all of them.
+type fanOutTransfer struct {
+	want      int
+	receivers []*fanOutReceiver
+	// full is closed when want requests joined the group.
+	full chan struct{}
+}
+
+// fanOutReceiver is one request of a fanOutTransfer. Its data is
+// queued in chunks, and written by its own goroutine, so a slow
+// request doesn't hold the others back.
+type fanOutReceiver struct {
+	writer io.Writer
+	chunks chan []byte
+	// fullSince is when the queue was first seen full, zero if it
+	// has room. Only used by fanOutWriter.
+	fullSince time.Time
+
+	// mu protects err, the first error of the receiver.
+	mu  sync.Mutex
+	err error
+
+	// done is closed when the receiver is done writing.
+	done chan struct{}
+}
+
+// setErr records err if the receiver has no error yet.
+func (r *fanOutReceiver) setErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *fanOutReceiver) getErr() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// run writes the queued chunks until the queue is closed. Once the
+// receiver has an error, the chunks are discarded. Each dequeued
+// chunk is signaled on progress, without blocking.
+func (r *fanOutReceiver) run(progress chan<- struct{}) {
+	defer close(r.done)
+	for chunk := range r.chunks {
+		select {
+		case progress <- struct{}{}:
+		default:
+		}
+		if r.getErr() != nil {
+			continue
+		}
+		if _, err := r.writer.Write(chunk); err != nil {
+			r.setErr(err)
+		}
+	}
+}
+
+// fanOutWriter queues the data to all the receivers of a transfer. A
+// receiver that fails is dropped, and so is a receiver whose queue
+// stays full for snapshotFanOutStall while another one has room: the
+// others don't wait for it.
+type fanOutWriter struct {
+	receivers []*fanOutReceiver
+	// progress is signaled when a receiver dequeues a chunk.
+	progress chan struct{}
+}
+
+func (fw *fanOutWriter) Write(p []byte) (int, error) {
+	// the caller may reuse p once we return
+	chunk := make([]byte, len(p))
+	copy(chunk, p)
+	for {
+		var alive, full []*fanOutReceiver
+		for _, r := range fw.receivers {
+			if r.getErr() != nil {
+				continue
+			}
+			alive = append(alive, r)
+			if len(r.chunks) == cap(r.chunks) {
+				full = append(full, r)
+			} else {
+				r.fullSince = time.Time{}
+			}
+		}
+		if len(alive) == 0 {
+			return 0, errAllReceiversFailed
+		}
+		if len(full) == 0 {
+			// we're the only sender, the queues can't fill
+			// up in the meantime
+			for _, r := range alive {
+				r.chunks <- chunk
+			}
+			return len(p), nil
+		}
+
+		// wait for the full queues, but not longer than
+		// snapshotFanOutStall if others have room
+		now := time.Now()
+		wait := *snapshotFanOutStall
+		for _, r := range full {
+			if len(full) == len(alive) {
+				// all the receivers are as slow, the
+				// stall counts once one of them has room
+				r.fullSince = time.Time{}
+				continue
+			}
+			if r.fullSince.IsZero() {
+				r.fullSince = now
+			}
+			stalled := now.Sub(r.fullSince)
+			if stalled >= *snapshotFanOutStall {
+				log.Warningf("dropping a fan-out receiver that took no data for %v", stalled)
+				r.setErr(errSlowReceiver)
+				continue
+			}
+			if left := *snapshotFanOutStall - stalled; left < wait {
+				wait = left
+			}
+		}
+		select {
+		case <-fw.progress:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// join adds a request to the group for key, creating it if needed.
+// It returns true if the request is the first one of the group,
+// and has to send the file.
+func (fo *snapshotFanOut) join(key string, want int, writer io.Writer) (*fanOutTransfer, *fanOutReceiver, bool) {
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+
+	r := &fanOutReceiver{
+		writer: writer,
+		chunks: make(chan []byte, fanOutBufferChunks),
+		done:   make(chan struct{}),
+	}
+	t, ok := fo.transfers[key]
+	if !ok {
+		t = &fanOutTransfer{
+			want: want,
+			full: make(chan struct{}),
+		}
+		fo.transfers[key] = t
+	}
+	t.receivers = append(t.receivers, r)
+	if len(t.receivers) == t.want {
+		close(t.full)
+		delete(fo.transfers, key)
+	}
+	return t, r, !ok
+}
+
+// close stops the group for key from accepting requests, and
+// returns its receivers.
+func (fo *snapshotFanOut) close(key string, t *fanOutTransfer) []*fanOutReceiver {
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+	if fo.transfers[key] == t {
+		delete(fo.transfers, key)
+	}
+	return t.receivers
+}
+
+// send writes the file to writer, along with the other requests for
+// the same file that arrive within snapshotFanOutWait. want is the
+// number of requests expected. It returns once the file was sent to
+// writer, or writer was dropped.
+func (fo *snapshotFanOut) send(writer io.Writer, file *os.File, path string, codec compression.Codec, want int) error {
+	key := path
+	if codec != nil {
+		key += ":" + codec.Name()
+	}
+	t, r, first := fo.join(key, want, writer)
+	if !first {
+		<-r.done
+		return r.getErr()
+	}
+
+	select {
+	case <-t.full:
+	case <-time.After(*snapshotFanOutWait):
+	}
+	receivers := fo.close(key, t)
+	log.Infof("sending %v to %v of %v tablets", path, len(receivers), want)
+
+	fw := &fanOutWriter{
+		receivers: receivers,
+		progress:  make(chan struct{}, 1),
+	}
+	for _, rcv := range receivers {
+		go rcv.run(fw.progress)
+	}
+	err := mysqlctl.WithTransferPriority(func() error {
+		return copyFanOut(fw, mysqlctl.NewThrottledReader(file), codec)
+	})
+	for _, rcv := range receivers {
+		if err != nil {
+			rcv.setErr(err)
+		}
+		close(rcv.chunks)
+	}
+	<-r.done
+	return r.getErr()
+}
+
+// copyFanOut copies the file to the fanOutWriter, compressing it
+// with codec if it's not nil.
+func copyFanOut(fw *fanOutWriter, file io.Reader, codec compression.Codec) error {
+	if codec != nil {
+		compressor, err := compression.NewWriter(codec, fw)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(compressor, file); err != nil {
+			compressor.Close()
+			return err
+		}
+		return compressor.Close()
+	}
+	_, err := io.Copy(fw, file)
+	return err
+}
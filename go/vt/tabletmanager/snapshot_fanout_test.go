@@ -0,0 +1,151 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletmanager
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openFanOutFile(t *testing.T, name string) *os.File {
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	return file
+}
+
+func TestSnapshotFanOut(t *testing.T) {
+	content := bytes.Repeat([]byte("snapshot data "), 10000)
+	f, err := ioutil.TempFile("", "fanout")
+	if err != nil {
+		t.Fatalf("TempFile failed: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(content); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	f.Close()
+
+	oldWait := *snapshotFanOutWait
+	defer func() { *snapshotFanOutWait = oldWait }()
+	*snapshotFanOutWait = 10 * time.Second
+
+	// three requests share one transfer
+	fo := newSnapshotFanOut()
+	buffers := make([]*bytes.Buffer, 3)
+	errs := make([]error, 3)
+	wg := sync.WaitGroup{}
+	for i := range buffers {
+		buffers[i] = &bytes.Buffer{}
+		file := openFanOutFile(t, f.Name())
+		defer file.Close()
+		wg.Add(1)
+		go func(i int, file *os.File) {
+			errs[i] = fo.send(buffers[i], file, f.Name(), nil, 3)
+			wg.Done()
+		}(i, file)
+	}
+	wg.Wait()
+	for i, buf := range buffers {
+		if errs[i] != nil {
+			t.Errorf("send %v failed: %v", i, errs[i])
+		}
+		if !bytes.Equal(buf.Bytes(), content) {
+			t.Errorf("send %v got %v bytes, want %v", i, buf.Len(), len(content))
+		}
+	}
+	if len(fo.transfers) != 0 {
+		t.Errorf("transfers were not cleaned up: %v", fo.transfers)
+	}
+
+	// a request alone sends the file after the wait
+	*snapshotFanOutWait = 10 * time.Millisecond
+	buf := &bytes.Buffer{}
+	file := openFanOutFile(t, f.Name())
+	defer file.Close()
+	if err := fo.send(buf, file, f.Name(), nil, 2); err != nil {
+		t.Errorf("send failed: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), content) {
+		t.Errorf("send got %v bytes, want %v", buf.Len(), len(content))
+	}
+	if len(fo.transfers) != 0 {
+		t.Errorf("transfers were not cleaned up: %v", fo.transfers)
+	}
+}
+
+// blockedWriter blocks its writes until release is closed.
+type blockedWriter struct {
+	bytes.Buffer
+	release chan struct{}
+}
+
+func (bw *blockedWriter) Write(p []byte) (int, error) {
+	<-bw.release
+	return bw.Buffer.Write(p)
+}
+
+func TestSnapshotFanOutSlowReceiver(t *testing.T) {
+	content := bytes.Repeat([]byte("snapshot data "), 100000)
+	f, err := ioutil.TempFile("", "fanout")
+	if err != nil {
+		t.Fatalf("TempFile failed: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(content); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	f.Close()
+
+	oldWait, oldStall, oldChunks := *snapshotFanOutWait, *snapshotFanOutStall, fanOutBufferChunks
+	defer func() { *snapshotFanOutWait, *snapshotFanOutStall, fanOutBufferChunks = oldWait, oldStall, oldChunks }()
+	*snapshotFanOutWait = 10 * time.Second
+	*snapshotFanOutStall = 100 * time.Millisecond
+	fanOutBufferChunks = 4
+
+	// the stalled receiver is dropped, the other two get the file
+	fo := newSnapshotFanOut()
+	slow := &blockedWriter{release: make(chan struct{})}
+	writers := []io.Writer{&bytes.Buffer{}, slow, &bytes.Buffer{}}
+	errs := make([]error, 3)
+	fast := sync.WaitGroup{}
+	all := sync.WaitGroup{}
+	for i, w := range writers {
+		file := openFanOutFile(t, f.Name())
+		defer file.Close()
+		all.Add(1)
+		if w != slow {
+			fast.Add(1)
+		}
+		go func(i int, w io.Writer, file *os.File) {
+			errs[i] = fo.send(w, file, f.Name(), nil, 3)
+			if w != slow {
+				fast.Done()
+			}
+			all.Done()
+		}(i, w, file)
+	}
+	fast.Wait()
+	close(slow.release)
+	all.Wait()
+
+	for _, i := range []int{0, 2} {
+		if errs[i] != nil {
+			t.Errorf("send %v failed: %v", i, errs[i])
+		}
+		if buf := writers[i].(*bytes.Buffer); !bytes.Equal(buf.Bytes(), content) {
+			t.Errorf("send %v got %v bytes, want %v", i, buf.Len(), len(content))
+		}
+	}
+	if errs[1] != errSlowReceiver {
+		t.Errorf("slow send got %v, want %v", errs[1], errSlowReceiver)
+	}
+}
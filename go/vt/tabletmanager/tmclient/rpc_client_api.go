@@ -117,6 +117,10 @@ type TabletManagerClient interface {
 	// StartSlave starts the mysql replication
 	StartSlave(ctx context.Context, tablet *topo.TabletInfo) error
 
+	// StartSlaveUntilAfter starts the mysql replication, and stops
+	// it right after the provided position
+	StartSlaveUntilAfter(ctx context.Context, tablet *topo.TabletInfo, position myproto.ReplicationPosition, waitTime time.Duration) error
+
 	// TabletExternallyReparented tells a tablet it is now the master, after an
 	// external tool has already promoted the underlying mysqld to master and
 	// reparented the other mysqld servers to it.
@@ -182,6 +186,13 @@ type TabletManagerClient interface {
 	// Restore restores a database snapshot
 	Restore(ctx context.Context, tablet *topo.TabletInfo, sa *actionnode.RestoreArgs) (<-chan *logutil.LoggerEvent, ErrFunc, error)
 
+	// CleanOrphans removes the files left behind by failed
+	// snapshots and restores
+	CleanOrphans(ctx context.Context, tablet *topo.TabletInfo, args *actionnode.CleanOrphansArgs) (*myproto.OrphanCleanupReport, error)
+
+	// DeleteSnapshot removes an expired snapshot
+	DeleteSnapshot(ctx context.Context, tablet *topo.TabletInfo, args *actionnode.DeleteSnapshotArgs) error
+
 	//
 	// RPC related methods
 	//
@@ -0,0 +1,132 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletmanager
+
+// This file is the tablet type state machine: when the type of the
+// tablet changes, the agent runs the exit hooks of the old type, then
+// the entry hooks of the new type, and records the transition.
+//
+// The transitions themselves are validated by topo.CheckTypeChange for
+// the simple type changes, and by the specialized actions (reparent,
+// restore, scrap, ...) for the other ones: the agent only observes
+// them, once they are in the topology.
+
+import (
+	"fmt"
+	"html/template"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/vt/hook"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/topotools"
+)
+
+var statsTypeChanges = stats.NewCounters("TabletTypeChanges")
+
+// TypeStateHooks are run by the agent when its tablet enters or exits
+// a type. Either can be nil. They run after the type changed in the
+// topology, so they can't veto it: they return an error only to have
+// it recorded with the transition.
+type TypeStateHooks struct {
+	Enter func(agent *ActionAgent, oldTablet, newTablet *topo.Tablet) error
+	Exit  func(agent *ActionAgent, oldTablet, newTablet *topo.Tablet) error
+}
+
+var (
+	typeStateHooksMu sync.Mutex
+	typeStateHooks   = make(map[topo.TabletType][]TypeStateHooks)
+)
+
+// RegisterTypeStateHooks registers hooks to run when a tablet enters
+// or exits tabletType. It should be called in an init() function.
+func RegisterTypeStateHooks(tabletType topo.TabletType, hooks TypeStateHooks) {
+	typeStateHooksMu.Lock()
+	defer typeStateHooksMu.Unlock()
+	typeStateHooks[tabletType] = append(typeStateHooks[tabletType], hooks)
+}
+
+func getTypeStateHooks(tabletType topo.TabletType) []TypeStateHooks {
+	typeStateHooksMu.Lock()
+	defer typeStateHooksMu.Unlock()
+	return typeStateHooks[tabletType]
+}
+
+// TypeTransition is the audit record of a tablet type change.
+type TypeTransition struct {
+	Time    time.Time
+	OldType topo.TabletType
+	NewType topo.TabletType
+	// Errors are the errors of the hooks of the transition.
+	Errors []string
+}
+
+// Class returns a human-readable one word version of the
+// transition state, for the status page.
+func (tt *TypeTransition) Class() string {
+	if len(tt.Errors) > 0 {
+		return "unhappy"
+	}
+	return "healthy"
+}
+
+// HTML returns an HTML version of the transition, for the status page.
+func (tt *TypeTransition) HTML() template.HTML {
+	result := template.HTMLEscapeString(fmt.Sprintf("%v -> %v", tt.OldType, tt.NewType))
+	for _, err := range tt.Errors {
+		result += "<br>" + template.HTMLEscapeString(err)
+	}
+	return template.HTML(result)
+}
+
+// typeTransition is called by changeCallback when the tablet type
+// changed. It runs the exit hooks of the old type and the entry hooks
+// of the new type: first the registered Go hooks, then the optional
+// 'tablet_type_exit' and 'tablet_type_enter' hooks, and finally the
+// optional 'tablet_type_change' hook. Hook failures are only recorded,
+// as the transition has already happened in the topology.
+func (agent *ActionAgent) typeTransition(oldTablet, newTablet *topo.Tablet) {
+	log.Infof("Tablet %v changed type: %v -> %v", newTablet.Alias, oldTablet.Type, newTablet.Type)
+	statsTypeChanges.Add(fmt.Sprintf("%v.%v", oldTablet.Type, newTablet.Type), 1)
+	tt := &TypeTransition{
+		Time:    time.Now(),
+		OldType: oldTablet.Type,
+		NewType: newTablet.Type,
+	}
+	recordErr := func(name string, err error) {
+		if err != nil {
+			log.Warningf("%v hook of tablet type change %v -> %v failed: %v", name, oldTablet.Type, newTablet.Type, err)
+			tt.Errors = append(tt.Errors, fmt.Sprintf("%v: %v", name, err))
+		}
+	}
+
+	for _, h := range getTypeStateHooks(oldTablet.Type) {
+		if h.Exit != nil {
+			recordErr("exit", h.Exit(agent, oldTablet, newTablet))
+		}
+	}
+	recordErr("tablet_type_exit", runTypeHook(newTablet.Alias, "tablet_type_exit", oldTablet.Type, newTablet.Type))
+	for _, h := range getTypeStateHooks(newTablet.Type) {
+		if h.Enter != nil {
+			recordErr("enter", h.Enter(agent, oldTablet, newTablet))
+		}
+	}
+	recordErr("tablet_type_enter", runTypeHook(newTablet.Alias, "tablet_type_enter", oldTablet.Type, newTablet.Type))
+	recordErr("tablet_type_change", runTypeHook(newTablet.Alias, "tablet_type_change", oldTablet.Type, newTablet.Type))
+
+	agent.TypeTransitions.Add(tt)
+}
+
+// runTypeHook runs an optional hook with the old and new types.
+func runTypeHook(alias topo.TabletAlias, name string, oldType, newType topo.TabletType) error {
+	hk := hook.NewHook(name, []string{
+		"--old_type=" + string(oldType),
+		"--new_type=" + string(newType),
+	})
+	topotools.ConfigureTabletHook(hk, alias)
+	return hk.ExecuteOptional()
+}
@@ -0,0 +1,57 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletmanager
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/youtube/vitess/go/history"
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+func TestTypeTransition(t *testing.T) {
+	var calls []string
+	RegisterTypeStateHooks(topo.TYPE_LAG, TypeStateHooks{
+		Enter: func(agent *ActionAgent, oldTablet, newTablet *topo.Tablet) error {
+			calls = append(calls, "enter lag from "+string(oldTablet.Type))
+			return nil
+		},
+		Exit: func(agent *ActionAgent, oldTablet, newTablet *topo.Tablet) error {
+			calls = append(calls, "exit lag to "+string(newTablet.Type))
+			return errors.New("exit failed")
+		},
+	})
+	RegisterTypeStateHooks(topo.TYPE_SPARE, TypeStateHooks{
+		Enter: func(agent *ActionAgent, oldTablet, newTablet *topo.Tablet) error {
+			calls = append(calls, "enter spare")
+			return nil
+		},
+	})
+
+	agent := &ActionAgent{TypeTransitions: history.New(historyLength)}
+	alias := topo.TabletAlias{Cell: "cell1", Uid: 1}
+	agent.typeTransition(&topo.Tablet{Alias: alias, Type: topo.TYPE_REPLICA}, &topo.Tablet{Alias: alias, Type: topo.TYPE_LAG})
+	agent.typeTransition(&topo.Tablet{Alias: alias, Type: topo.TYPE_LAG}, &topo.Tablet{Alias: alias, Type: topo.TYPE_SPARE})
+
+	if want := []string{"enter lag from replica", "exit lag to spare", "enter spare"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("hook calls: %v, want %v", calls, want)
+	}
+
+	// the audit log has the most recent transition first
+	records := agent.TypeTransitions.Records()
+	if len(records) != 2 {
+		t.Fatalf("got %v transitions, want 2", len(records))
+	}
+	tt := records[0].(*TypeTransition)
+	if tt.OldType != topo.TYPE_LAG || tt.NewType != topo.TYPE_SPARE || !reflect.DeepEqual(tt.Errors, []string{"exit: exit failed"}) || tt.Class() != "unhappy" {
+		t.Errorf("unexpected transition: %+v", tt)
+	}
+	tt = records[1].(*TypeTransition)
+	if tt.OldType != topo.TYPE_REPLICA || tt.NewType != topo.TYPE_LAG || len(tt.Errors) != 0 {
+		t.Errorf("unexpected transition: %+v", tt)
+	}
+}
@@ -55,7 +55,7 @@ func NewCachePool(
 				if err != nil {
 					conn.Close()
 					conn = nil
-					log.Errorf("Cannot export memcache %v stats: %v", key, err)
+					mlog.Errorf("Cannot export memcache %v stats: %v", key, err)
 					internalErrors.Add("MemcacheStats", 1)
 					return ""
 				}
@@ -98,7 +98,7 @@ func (cp *CachePool) Open() {
 	}
 	cp.socket = generateFilename(cp.rowCacheConfig.Socket)
 	cp.startCacheService()
-	log.Infof("rowcache is enabled")
+	mlog.Infof("rowcache is enabled")
 	f := func() (pools.Resource, error) {
 		return cacheservice.Connect(cacheservice.Config{
 			Address: cp.socket,
@@ -129,7 +129,7 @@ func generateFilename(hint string) string {
 	if err != nil {
 		panic(NewTabletError(ErrFatal, "error removing socket file: %v", err))
 	}
-	log.Infof("sock filename: %v", name)
+	mlog.Infof("sock filename: %v", name)
 	return name
 }
 
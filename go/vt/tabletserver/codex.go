@@ -10,7 +10,6 @@ import (
 	"fmt"
 	"strings"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/sqltypes"
 	"github.com/youtube/vitess/go/vt/schema"
 	"github.com/youtube/vitess/go/vt/sqlparser"
@@ -291,7 +290,7 @@ func validateKey(tableInfo *TableInfo, key string) (newKey string) {
 		if piece[0] == '\'' {
 			s, err := base64.StdEncoding.DecodeString(piece[1 : len(piece)-1])
 			if err != nil {
-				log.Warningf("Error decoding key %s for table %s: %v", key, tableInfo.Name, err)
+				mlog.Warningf("Error decoding key %s for table %s: %v", key, tableInfo.Name, err)
 				internalErrors.Add("Mismatch", 1)
 				return
 			}
@@ -302,7 +301,7 @@ func validateKey(tableInfo *TableInfo, key string) (newKey string) {
 		} else {
 			n, err := sqltypes.BuildNumeric(piece)
 			if err != nil {
-				log.Warningf("Error decoding key %s for table %s: %v", key, tableInfo.Name, err)
+				mlog.Warningf("Error decoding key %s for table %s: %v", key, tableInfo.Name, err)
 				internalErrors.Add("Mismatch", 1)
 				return
 			}
@@ -310,7 +309,7 @@ func validateKey(tableInfo *TableInfo, key string) (newKey string) {
 		}
 	}
 	if newKey = buildKey(pkValues); newKey != key {
-		log.Warningf("Error: Key mismatch, received: %s, computed: %s", key, newKey)
+		mlog.Warningf("Error: Key mismatch, received: %s, computed: %s", key, newKey)
 		internalErrors.Add("Mismatch", 1)
 	}
 	return newKey
@@ -0,0 +1,58 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/servenv"
+)
+
+// registerConfigReloads lets a reload of the config file change the
+// pool sizes and the timeouts of the query service, like the
+// corresponding 'set vt_xxx' statements.
+func (rqsc *realQueryServiceControl) registerConfigReloads() {
+	qe := rqsc.sqlQueryRPCService.qe
+
+	poolSize := func(name string, size *int, set func(int) error) {
+		servenv.OnConfigReload(name, func() error {
+			if *size < 1 {
+				return fmt.Errorf("%v out of range %v", name, *size)
+			}
+			return set(*size)
+		})
+	}
+	poolSize("queryserver-config-pool-size", &qsConfig.PoolSize, qe.connPool.SetCapacity)
+	poolSize("queryserver-config-stream-pool-size", &qsConfig.StreamPoolSize, qe.streamConnPool.SetCapacity)
+	poolSize("queryserver-config-transaction-cap", &qsConfig.TransactionCap, qe.txPool.pool.SetCapacity)
+
+	timeout := func(name string, seconds *float64, set func(time.Duration)) {
+		servenv.OnConfigReload(name, func() error {
+			if *seconds < 0 {
+				return fmt.Errorf("%v out of range %v", name, *seconds)
+			}
+			set(time.Duration(*seconds * 1e9))
+			return nil
+		})
+	}
+	timeout("queryserver-config-query-timeout", &qsConfig.QueryTimeout, qe.queryTimeout.Set)
+	timeout("queryserver-config-txpool-timeout", &qsConfig.TxPoolTimeout, qe.txPool.SetPoolTimeout)
+	timeout("queryserver-config-idle-timeout", &qsConfig.IdleTimeout, func(t time.Duration) {
+		qe.connPool.SetIdleTimeout(t)
+		qe.streamConnPool.SetIdleTimeout(t)
+		qe.txPool.pool.SetIdleTimeout(t)
+	})
+
+	// The transaction killer runs every tenth of the timeout, so
+	// it cannot be 0.
+	servenv.OnConfigReload("queryserver-config-transaction-timeout", func() error {
+		if qsConfig.TransactionTimeout <= 0 {
+			return fmt.Errorf("queryserver-config-transaction-timeout out of range %v", qsConfig.TransactionTimeout)
+		}
+		qe.txPool.SetTimeout(time.Duration(qsConfig.TransactionTimeout * 1e9))
+		return nil
+	})
+}
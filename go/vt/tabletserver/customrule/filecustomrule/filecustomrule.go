@@ -11,6 +11,7 @@ import (
 	"time"
 
 	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/vt/servenv"
 	"github.com/youtube/vitess/go/vt/tabletserver"
 )
 
@@ -78,6 +79,35 @@ func ActivateFileCustomRules(qsc tabletserver.QueryServiceControl) {
 		tabletserver.QueryRuleSources.RegisterQueryRuleSource(FileCustomRuleSource)
 		fileCustomRule.Open(qsc, *fileRulePath)
 	}
+	servenv.OnConfigReload("filecustomrules", func() error {
+		return fileCustomRule.reopen(qsc, *fileRulePath)
+	})
+}
+
+// reopen switches to the rules of another file. The rule source is
+// only registered while there is a file.
+func (fcr *FileCustomRule) reopen(qsc tabletserver.QueryServiceControl, rulePath string) error {
+	if rulePath == "" {
+		// Clear the rules first, so the cached plans drop them.
+		qsc.SetQueryRules(FileCustomRuleSource, tabletserver.NewQueryRules())
+		tabletserver.QueryRuleSources.UnRegisterQueryRuleSource(FileCustomRuleSource)
+		fcr.path = ""
+		fcr.currentRuleSet = tabletserver.NewQueryRules()
+		log.Infof("Custom rules from file removed")
+		return nil
+	}
+	if fcr.path == "" {
+		tabletserver.QueryRuleSources.RegisterQueryRuleSource(FileCustomRuleSource)
+	}
+	oldPath := fcr.path
+	if err := fcr.Open(qsc, rulePath); err != nil {
+		fcr.path = oldPath
+		if oldPath == "" {
+			tabletserver.QueryRuleSources.UnRegisterQueryRuleSource(FileCustomRuleSource)
+		}
+		return err
+	}
+	return nil
 }
 
 func init() {
@@ -8,10 +8,10 @@ import (
 	"fmt"
 	"time"
 
-	log "github.com/golang/glog"
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/sqldb"
 	"github.com/youtube/vitess/go/sync2"
+	"github.com/youtube/vitess/go/trace"
 	"github.com/youtube/vitess/go/vt/dbconnpool"
 	"golang.org/x/net/context"
 )
@@ -46,6 +46,10 @@ func NewDBConn(cp *ConnPool, appParams, dbaParams *sqldb.ConnParams) (*DBConn, e
 // Exec executes the specified query. If there is a connection error, it will reconnect
 // and retry. A failed reconnect will trigger a CheckMySQL.
 func (dbc *DBConn) Exec(ctx context.Context, query string, maxrows int, wantfields bool) (*mproto.QueryResult, error) {
+	span := trace.NewSpanFromContext(ctx)
+	span.StartClient("DBConn.Exec")
+	defer span.Finish()
+
 	for attempt := 1; attempt <= 2; attempt++ {
 		r, err := dbc.execOnce(ctx, query, maxrows, wantfields)
 		switch {
@@ -130,17 +134,17 @@ func (dbc *DBConn) Recycle() {
 // Kill will also not kill a query more than once.
 func (dbc *DBConn) Kill() {
 	killStats.Add("Queries", 1)
-	log.Infof("killing query %s", dbc.Current())
+	mlog.Infof("killing query %s", dbc.Current())
 	killConn, err := dbc.pool.dbaPool.Get(0)
 	if err != nil {
-		log.Warningf("Failed to get conn from dba pool: %v", err)
+		mlog.Warningf("Failed to get conn from dba pool: %v", err)
 		return
 	}
 	defer killConn.Recycle()
 	sql := fmt.Sprintf("kill %d", dbc.conn.ID())
 	_, err = killConn.ExecuteFetch(sql, 10000, false)
 	if err != nil {
-		log.Errorf("Could not kill query %s: %v", dbc.Current(), err)
+		mlog.Errorf("Could not kill query %s: %v", dbc.Current(), err)
 	}
 }
 
@@ -190,12 +194,12 @@ func (dbc *DBConn) setDeadline(ctx context.Context) (done chan bool, err error)
 		select {
 		case <-tmr2.C:
 			internalErrors.Add("HungQuery", 1)
-			log.Warningf("Query may be hung: %s", dbc.Current())
+			mlog.Warningf("Query may be hung: %s", dbc.Current())
 		case <-done:
 			return
 		}
 		<-done
-		log.Warningf("Hung query returned")
+		mlog.Warningf("Hung query returned")
 	}()
 	return done, nil
 }
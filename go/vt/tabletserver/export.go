@@ -0,0 +1,77 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/youtube/vitess/go/vt/key"
+	"github.com/youtube/vitess/go/vt/tabletserver/proto"
+)
+
+// exportQuery returns the query that reads the rows of an export,
+// and the indexes of the primary key columns in its fields.
+func exportQuery(ti *TableInfo, req *proto.ExportTableRequest) (string, []int, error) {
+	columns := make([]string, len(ti.Columns))
+	for i, c := range ti.Columns {
+		columns[i] = c.Name
+	}
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "select `%s` from `%s`", strings.Join(columns, "`, `"), ti.Name)
+	if req.KeyspaceIdColumn != "" {
+		if ti.FindColumn(req.KeyspaceIdColumn) == -1 {
+			return "", nil, fmt.Errorf("column %s not found in table %s", req.KeyspaceIdColumn, ti.Name)
+		}
+		where, err := keyRangeWhere(req.KeyspaceIdColumn, req.KeyspaceIdType, req.KeyRange)
+		if err != nil {
+			return "", nil, err
+		}
+		if where != "" {
+			fmt.Fprintf(buf, " where %s", where)
+		}
+	}
+	if len(ti.PKColumns) > 0 {
+		pkColumns := make([]string, len(ti.PKColumns))
+		for i, index := range ti.PKColumns {
+			pkColumns[i] = ti.Columns[index].Name
+		}
+		fmt.Fprintf(buf, " order by `%s`", strings.Join(pkColumns, "`, `"))
+	}
+	return buf.String(), ti.PKColumns, nil
+}
+
+// keyRangeWhere returns the condition for the keyspace id column to
+// be in the keyrange, or an empty string if all rows are.
+func keyRangeWhere(column string, kit key.KeyspaceIdType, kr key.KeyRange) (string, error) {
+	var bound func(kid key.KeyspaceId) string
+	column = fmt.Sprintf("`%s`", column)
+	switch kit {
+	case key.KIT_UINT64:
+		bound = func(kid key.KeyspaceId) string {
+			var b [8]byte
+			copy(b[:], kid)
+			return fmt.Sprintf("%d", binary.BigEndian.Uint64(b[:]))
+		}
+	case key.KIT_BYTES:
+		column = fmt.Sprintf("hex(%s)", column)
+		bound = func(kid key.KeyspaceId) string {
+			return fmt.Sprintf("'%s'", kid.Hex())
+		}
+	default:
+		return "", fmt.Errorf("invalid keyspace id type %q", kit)
+	}
+
+	var conditions []string
+	if kr.Start != key.MinKey {
+		conditions = append(conditions, fmt.Sprintf("%s >= %s", column, bound(kr.Start)))
+	}
+	if kr.End != key.MaxKey {
+		conditions = append(conditions, fmt.Sprintf("%s < %s", column, bound(kr.End)))
+	}
+	return strings.Join(conditions, " and "), nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"reflect"
+	"testing"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/key"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/tabletserver/fakesqldb"
+	"github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
+)
+
+func TestKeyRangeWhere(t *testing.T) {
+	table := []struct {
+		kit   key.KeyspaceIdType
+		start string
+		end   string
+		want  string
+	}{
+		{key.KIT_UINT64, "", "", ""},
+		{key.KIT_UINT64, "\x40", "", "`kid` >= 4611686018427387904"},
+		{key.KIT_UINT64, "", "\x80", "`kid` < 9223372036854775808"},
+		{key.KIT_UINT64, "\x40", "\x80", "`kid` >= 4611686018427387904 and `kid` < 9223372036854775808"},
+		{key.KIT_BYTES, "\x40", "\x80", "hex(`kid`) >= '40' and hex(`kid`) < '80'"},
+	}
+	for _, test := range table {
+		kr := key.KeyRange{Start: key.KeyspaceId(test.start), End: key.KeyspaceId(test.end)}
+		got, err := keyRangeWhere("kid", test.kit, kr)
+		if err != nil {
+			t.Errorf("keyRangeWhere(%v, %v) failed: %v", test.kit, kr, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("keyRangeWhere(%v, %v) = %q, want %q", test.kit, kr, got, test.want)
+		}
+	}
+	if _, err := keyRangeWhere("kid", key.KIT_UNSET, key.KeyRange{}); err == nil {
+		t.Errorf("keyRangeWhere with no keyspace id type worked")
+	}
+}
+
+func TestSqlQueryExportTable(t *testing.T) {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	db.AddQuery("SELECT VERSION()", &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{sqltypes.MakeString([]byte("10.0.13-MariaDB-1~precise-log"))},
+		},
+	})
+	db.AddQuery("SELECT @@GLOBAL.gtid_binlog_pos", &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{sqltypes.MakeString([]byte("0-41983-1"))},
+		},
+	})
+	fields := []mproto.Field{{Name: "column_01", Type: mproto.VT_LONG}}
+	rows := [][]sqltypes.Value{
+		[]sqltypes.Value{sqltypes.MakeString([]byte("1"))},
+		[]sqltypes.Value{sqltypes.MakeString([]byte("2"))},
+	}
+	db.AddQuery("select column_01 from test_table where column_01 >= 4611686018427387904 order by column_01 asc", &mproto.QueryResult{
+		Fields:       fields,
+		RowsAffected: 2,
+		Rows:         rows,
+	})
+
+	sqlQuery := getSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	sqlQuery.SetTabletType(topo.TYPE_RDONLY)
+
+	req := &proto.ExportTableRequest{
+		Table:            "test_table",
+		KeyspaceIdColumn: "column_01",
+		KeyspaceIdType:   key.KIT_UINT64,
+		KeyRange:         key.KeyRange{Start: key.KeyspaceId("\x40")},
+		SessionId:        sqlQuery.sessionID,
+	}
+	var chunks []*proto.ExportChunk
+	if err := sqlQuery.ExportTable(context.Background(), req, func(chunk *proto.ExportChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	}); err != nil {
+		t.Fatalf("ExportTable failed: %v", err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("ExportTable sent %v chunks, want 2", len(chunks))
+	}
+	// The fake connection doesn't return the fields of streaming
+	// queries.
+	wantHeader := &proto.ExportHeader{
+		Fields:            []mproto.Field{},
+		PrimaryKeyColumns: []int{0},
+		Position:          myproto.MustParseReplicationPosition("MariaDB", "0-41983-1"),
+	}
+	if !reflect.DeepEqual(chunks[0].Header, wantHeader) {
+		t.Errorf("ExportTable header = %+v, want %+v", chunks[0].Header, wantHeader)
+	}
+	if chunks[1].Header != nil || chunks[1].RowCount != 2 {
+		t.Errorf("ExportTable second chunk = %+v, want 2 rows", chunks[1])
+	}
+	got, err := proto.DecodeExportColumns(chunks[1])
+	if err != nil {
+		t.Fatalf("DecodeExportColumns failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("ExportTable rows = %v, want %v", got, rows)
+	}
+
+	req.KeyspaceIdColumn = "unknown_column"
+	if err := sqlQuery.ExportTable(context.Background(), req, func(*proto.ExportChunk) error { return nil }); err == nil {
+		t.Errorf("ExportTable with an unknown keyspace id column worked")
+	}
+	req.KeyspaceIdColumn = "column_01"
+
+	// the serving tablets don't export their tables
+	for _, tabletType := range []topo.TabletType{topo.TYPE_MASTER, topo.TYPE_REPLICA} {
+		sqlQuery.SetTabletType(tabletType)
+		if err := sqlQuery.ExportTable(context.Background(), req, func(*proto.ExportChunk) error { return nil }); err == nil {
+			t.Errorf("ExportTable on a %v tablet worked", tabletType)
+		}
+	}
+	sqlQuery.SetTabletType(topo.TYPE_RDONLY)
+
+	req.Table = "unknown_table"
+	if err := sqlQuery.ExportTable(context.Background(), req, func(*proto.ExportChunk) error { return nil }); err == nil {
+		t.Errorf("ExportTable of an unknown table worked")
+	}
+}
@@ -32,6 +32,7 @@ type DB struct {
 	isConnFail   bool
 	data         map[string]*proto.QueryResult
 	rejectedData map[string]*proto.QueryResult
+	queryErrors  map[string]error
 	mu           sync.Mutex
 }
 
@@ -81,6 +82,27 @@ func (db *DB) DeleteRejectedQuery(query string) {
 	delete(db.rejectedData, query)
 }
 
+// AddQueryError makes a query fail with err at execution time.
+func (db *DB) AddQueryError(query string, err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.queryErrors[query] = err
+}
+
+// GetQueryError returns the error of a query added with AddQueryError.
+func (db *DB) GetQueryError(query string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.queryErrors[query]
+}
+
+// DeleteQueryError makes the query succeed again.
+func (db *DB) DeleteQueryError(query string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	delete(db.queryErrors, query)
+}
+
 // EnableConnFail makes connection to this fake DB fail.
 func (db *DB) EnableConnFail() {
 	db.mu.Lock()
@@ -119,6 +141,9 @@ func (conn *Conn) ExecuteFetch(query string, maxrows int, wantfields bool) (*pro
 	if conn.db.HasRejectedQuery(query) {
 		return nil, fmt.Errorf("unsupported query, reject query: %s", query)
 	}
+	if err := conn.db.GetQueryError(query); err != nil {
+		return nil, err
+	}
 	result, ok := conn.db.GetQuery(query)
 	if !ok {
 		log.Warningf("unexpected query: %s, will return an empty result", query)
@@ -228,6 +253,37 @@ func (conn *Conn) FetchNext() ([]sqltypes.Value, error) {
 	return row, nil
 }
 
+// FetchNextInto is part of the sqldb.RowFetcher interface. It copies
+// the next row into rb, like a real connection.
+func (conn *Conn) FetchNextInto(rb *sqldb.RowBuffer) ([]sqltypes.Value, error) {
+	row, err := conn.FetchNext()
+	if row == nil || err != nil {
+		return row, err
+	}
+	size := 0
+	for _, value := range row {
+		size += len(value.Raw())
+	}
+	result, arena := rb.NewRow(len(row), size)
+	for i, value := range row {
+		if value.IsNull() {
+			continue
+		}
+		start := len(arena)
+		arena = append(arena, value.Raw()...)
+		raw := arena[start:]
+		switch {
+		case value.IsNumeric():
+			result[i] = sqltypes.MakeNumeric(raw)
+		case value.IsFractional():
+			result[i] = sqltypes.MakeFractional(raw)
+		default:
+			result[i] = sqltypes.MakeString(raw)
+		}
+	}
+	return result, nil
+}
+
 // ReadPacket reads a raw packet from the connection.
 func (conn *Conn) ReadPacket() ([]byte, error) {
 	return []byte{}, nil
@@ -256,6 +312,7 @@ func Register() *DB {
 	db := &DB{
 		data:         make(map[string]*proto.QueryResult),
 		rejectedData: make(map[string]*proto.QueryResult),
+		queryErrors:  make(map[string]error),
 	}
 	sqldb.Register(name, func(sqldb.ConnParams) (sqldb.Conn, error) {
 		if db.IsConnFail() {
@@ -56,11 +56,50 @@ func (sq *SqlQuery) ExecuteBatch(ctx context.Context, queryList *proto.QueryList
 	return sq.server.ExecuteBatch(callinfo.RPCWrapCallInfo(ctx), queryList, reply)
 }
 
+// Prepare is exposing tabletserver.SqlQuery.Prepare
+func (sq *SqlQuery) Prepare(ctx context.Context, req *proto.PrepareRequest, reply *proto.PrepareResult) error {
+	return sq.server.Prepare(callinfo.RPCWrapCallInfo(ctx), req, reply)
+}
+
+// ExecutePrepared is exposing tabletserver.SqlQuery.ExecutePrepared
+func (sq *SqlQuery) ExecutePrepared(ctx context.Context, req *proto.ExecutePreparedRequest, reply *mproto.QueryResult) error {
+	return sq.server.ExecutePrepared(callinfo.RPCWrapCallInfo(ctx), req, reply)
+}
+
+// ClosePrepared is exposing tabletserver.SqlQuery.ClosePrepared
+func (sq *SqlQuery) ClosePrepared(ctx context.Context, req *proto.ClosePreparedRequest, noOutput *string) error {
+	return sq.server.ClosePrepared(callinfo.RPCWrapCallInfo(ctx), req)
+}
+
+// Explain is exposing tabletserver.SqlQuery.Explain
+func (sq *SqlQuery) Explain(ctx context.Context, query *proto.Query, reply *proto.ExplainResult) error {
+	return sq.server.Explain(callinfo.RPCWrapCallInfo(ctx), query, reply)
+}
+
 // SplitQuery is exposing tabletserver.SqlQuery.SplitQuery
 func (sq *SqlQuery) SplitQuery(ctx context.Context, req *proto.SplitQueryRequest, reply *proto.SplitQueryResult) error {
 	return sq.server.SplitQuery(callinfo.RPCWrapCallInfo(ctx), req, reply)
 }
 
+// MessageStream is exposing tabletserver.SqlQuery.MessageStream
+func (sq *SqlQuery) MessageStream(ctx context.Context, req *proto.MessageStreamRequest, sendReply func(reply interface{}) error) error {
+	return sq.server.MessageStream(callinfo.RPCWrapCallInfo(ctx), req, func(reply *mproto.QueryResult) error {
+		return sendReply(reply)
+	})
+}
+
+// MessageAck is exposing tabletserver.SqlQuery.MessageAck
+func (sq *SqlQuery) MessageAck(ctx context.Context, req *proto.MessageAckRequest, reply *proto.MessageAckResult) error {
+	return sq.server.MessageAck(callinfo.RPCWrapCallInfo(ctx), req, reply)
+}
+
+// ExportTable is exposing tabletserver.SqlQuery.ExportTable
+func (sq *SqlQuery) ExportTable(ctx context.Context, req *proto.ExportTableRequest, sendReply func(reply interface{}) error) error {
+	return sq.server.ExportTable(callinfo.RPCWrapCallInfo(ctx), req, func(reply *proto.ExportChunk) error {
+		return sendReply(reply)
+	})
+}
+
 // New returns a new SqlQuery based on the QueryService implementation
 func New(server queryservice.QueryService) *SqlQuery {
 	return &SqlQuery{server}
@@ -5,7 +5,6 @@
 package gorpctabletconn
 
 import (
-	"crypto/tls"
 	"flag"
 	"fmt"
 	"strings"
@@ -15,64 +14,139 @@ import (
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/netutil"
 	"github.com/youtube/vitess/go/rpcplus"
+	"github.com/youtube/vitess/go/rpcwrap"
 	"github.com/youtube/vitess/go/rpcwrap/bsonrpc"
 	"github.com/youtube/vitess/go/vt/rpc"
 	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
 	"github.com/youtube/vitess/go/vt/tabletserver/tabletconn"
 	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vterrors"
+	"github.com/youtube/vitess/go/vt/vttls"
 	"golang.org/x/net/context"
 )
 
 var (
-	tabletBsonUsername  = flag.String("tablet-bson-username", "", "user to use for bson rpc connections")
-	tabletBsonPassword  = flag.String("tablet-bson-password", "", "password to use for bson rpc connections (ignored if username is empty)")
-	tabletBsonEncrypted = flag.Bool("tablet-bson-encrypted", false, "use encryption to talk to vttablet")
+	tabletBsonUsername = flag.String("tablet-bson-username", "", "user to use for bson rpc connections")
+	tabletBsonPassword = flag.String("tablet-bson-password", "", "password to use for bson rpc connections (ignored if username is empty)")
+	tabletBsonTLS      = vttls.RegisterClientFlags("tablet-bson", "vttablet")
+
+	tabletBsonKeepalive   = flag.Duration("tablet-bson-keepalive", 30*time.Second, "how often to check the pooled connections to vttablets, closing the broken and idle ones, 0 to disable")
+	tabletBsonIdleTimeout = flag.Duration("tablet-bson-idle-timeout", 5*time.Minute, "how long a pooled connection to a vttablet can stay unused before it is closed")
+
+	poolOnce sync.Once
+	pool     *rpcwrap.ClientPool
 )
 
 func init() {
 	tabletconn.RegisterDialer("gorpc", DialTablet)
 }
 
-// TabletBson implements a bson rpcplus implementation for TabletConn
+// TabletBson implements a bson rpcplus implementation for TabletConn.
+// The TabletBson to the same vttablet share a pooled RPC client. The
+// streaming RPCs use their own connection, dialed on first use: a
+// slow reader would block the other calls sharing the pooled one.
 type TabletBson struct {
 	mu        sync.RWMutex
 	endPoint  topo.EndPoint
+	addr      string
+	timeout   time.Duration
 	rpcClient *rpcplus.Client
 	sessionID int64
+	// sessionInfo has the version and features of the vttablet.
+	sessionInfo tproto.SessionInfo
+
+	// streamMu protects streamRPCClient.
+	streamMu        sync.Mutex
+	streamRPCClient *rpcplus.Client
+}
+
+// dialAddr connects to a vttablet address.
+func dialAddr(addr string, connectTimeout time.Duration) (*rpcplus.Client, error) {
+	config, err := tabletBsonTLS.Config()
+	if err != nil {
+		return nil, err
+	}
+	if *tabletBsonUsername != "" {
+		return bsonrpc.DialAuthHTTP("tcp", addr, *tabletBsonUsername, *tabletBsonPassword, connectTimeout, config)
+	}
+	return bsonrpc.DialHTTP("tcp", addr, connectTimeout, config)
+}
+
+// clientPool returns the pool of the connections shared by the
+// TabletBson. It is created on first use, after the flags have been
+// parsed. There is no RPC to ping a vttablet with, so the pool only
+// closes the broken and idle connections.
+func clientPool() *rpcwrap.ClientPool {
+	poolOnce.Do(func() {
+		pool = rpcwrap.NewClientPool("TabletBson", dialAddr, nil, *tabletBsonKeepalive, *tabletBsonIdleTimeout)
+	})
+	return pool
 }
 
 // DialTablet creates and initializes TabletBson.
 func DialTablet(ctx context.Context, endPoint topo.EndPoint, keyspace, shard string, timeout time.Duration) (tabletconn.TabletConn, error) {
+	config, err := tabletBsonTLS.Config()
+	if err != nil {
+		return nil, tabletError(err)
+	}
 	var addr string
-	var config *tls.Config
-	if *tabletBsonEncrypted {
+	if config != nil {
 		addr = netutil.JoinHostPort(endPoint.Host, endPoint.NamedPortMap["vts"])
-		config = &tls.Config{}
-		config.InsecureSkipVerify = true
 	} else {
 		addr = netutil.JoinHostPort(endPoint.Host, endPoint.NamedPortMap["vt"])
 	}
 
-	conn := &TabletBson{endPoint: endPoint}
-	var err error
-	if *tabletBsonUsername != "" {
-		conn.rpcClient, err = bsonrpc.DialAuthHTTP("tcp", addr, *tabletBsonUsername, *tabletBsonPassword, timeout, config)
-	} else {
-		conn.rpcClient, err = bsonrpc.DialHTTP("tcp", addr, timeout, config)
-	}
+	conn := &TabletBson{endPoint: endPoint, addr: addr, timeout: timeout}
+	dialCtx := ctx
+	if timeout != 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	conn.rpcClient, err = clientPool().Get(dialCtx, addr)
 	if err != nil {
 		return nil, tabletError(err)
 	}
 
+	sessionParams := tproto.SessionParams{
+		Keyspace:        keyspace,
+		Shard:           shard,
+		ProtocolVersion: tproto.ProtocolVersion,
+	}
 	var sessionInfo tproto.SessionInfo
-	if err = conn.rpcClient.Call(ctx, "SqlQuery.GetSessionId", tproto.SessionParams{Keyspace: keyspace, Shard: shard}, &sessionInfo); err != nil {
-		conn.rpcClient.Close()
+	if err = conn.rpcClient.Call(ctx, "SqlQuery.GetSessionId", sessionParams, &sessionInfo); err != nil {
 		return nil, tabletError(err)
 	}
 	conn.sessionID = sessionInfo.SessionId
+	conn.sessionInfo = sessionInfo
 	return conn, nil
 }
 
+// streamClient returns the connection of the streaming RPCs, dialing
+// it if needed.
+func (conn *TabletBson) streamClient() (*rpcplus.Client, error) {
+	conn.streamMu.Lock()
+	defer conn.streamMu.Unlock()
+	if conn.streamRPCClient != nil && !conn.streamRPCClient.IsShutdown() {
+		return conn.streamRPCClient, nil
+	}
+	client, err := dialAddr(conn.addr, conn.timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.streamRPCClient = client
+	return client, nil
+}
+
+// checkFeature returns an error if the vttablet doesn't support the
+// feature.
+func (conn *TabletBson) checkFeature(feature string) error {
+	if !conn.sessionInfo.HasFeature(feature) {
+		return tabletconn.UnsupportedFeatureError(feature, conn.sessionInfo.ProtocolVersion)
+	}
+	return nil
+}
+
 func (conn *TabletBson) withTimeout(ctx context.Context, action func() error) error {
 	var err error
 	var errAction error
@@ -90,13 +164,35 @@ func (conn *TabletBson) withTimeout(ctx context.Context, action func() error) er
 	return err
 }
 
+// checkExecuteOptions returns an error if the vttablet doesn't
+// support the features the options need.
+func (conn *TabletBson) checkExecuteOptions(options *tproto.ExecuteOptions) error {
+	if options == nil {
+		return nil
+	}
+	if options.FieldsOnly {
+		if err := conn.checkFeature(tproto.FeatureFieldsOnly); err != nil {
+			return err
+		}
+	}
+	if options.IdempotencyKey != "" {
+		if err := conn.checkFeature(tproto.FeatureIdempotencyKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Execute sends the query to VTTablet.
-func (conn *TabletBson) Execute(ctx context.Context, query string, bindVars map[string]interface{}, transactionID int64) (*mproto.QueryResult, error) {
+func (conn *TabletBson) Execute(ctx context.Context, query string, bindVars map[string]interface{}, transactionID int64, options *tproto.ExecuteOptions) (*mproto.QueryResult, error) {
 	conn.mu.RLock()
 	defer conn.mu.RUnlock()
 	if conn.rpcClient == nil {
 		return nil, tabletconn.CONN_CLOSED
 	}
+	if err := conn.checkExecuteOptions(options); err != nil {
+		return nil, err
+	}
 
 	req := &tproto.Query{
 		Sql:           query,
@@ -104,6 +200,10 @@ func (conn *TabletBson) Execute(ctx context.Context, query string, bindVars map[
 		TransactionId: transactionID,
 		SessionId:     conn.sessionID,
 	}
+	if options != nil {
+		req.FieldsOnly = options.FieldsOnly
+		req.IdempotencyKey = options.IdempotencyKey
+	}
 	qr := new(mproto.QueryResult)
 	action := func() error {
 		return conn.rpcClient.Call(ctx, "SqlQuery.Execute", req, qr)
@@ -137,13 +237,116 @@ func (conn *TabletBson) ExecuteBatch(ctx context.Context, queries []tproto.Bound
 	return qrs, nil
 }
 
+// Prepare prepares a statement on VTTablet.
+func (conn *TabletBson) Prepare(ctx context.Context, query string) (int64, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.rpcClient == nil {
+		return 0, tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkFeature(tproto.FeaturePrepare); err != nil {
+		return 0, err
+	}
+
+	req := &tproto.PrepareRequest{
+		Sql:       query,
+		SessionId: conn.sessionID,
+	}
+	reply := new(tproto.PrepareResult)
+	action := func() error {
+		return conn.rpcClient.Call(ctx, "SqlQuery.Prepare", req, reply)
+	}
+	if err := conn.withTimeout(ctx, action); err != nil {
+		return 0, tabletError(err)
+	}
+	return reply.StatementId, nil
+}
+
+// ExecutePrepared executes a prepared statement on VTTablet.
+func (conn *TabletBson) ExecutePrepared(ctx context.Context, statementID int64, bindVars map[string]interface{}, transactionID int64) (*mproto.QueryResult, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.rpcClient == nil {
+		return nil, tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkFeature(tproto.FeaturePrepare); err != nil {
+		return nil, err
+	}
+
+	req := &tproto.ExecutePreparedRequest{
+		StatementId:   statementID,
+		BindVariables: bindVars,
+		TransactionId: transactionID,
+		SessionId:     conn.sessionID,
+	}
+	qr := new(mproto.QueryResult)
+	action := func() error {
+		return conn.rpcClient.Call(ctx, "SqlQuery.ExecutePrepared", req, qr)
+	}
+	if err := conn.withTimeout(ctx, action); err != nil {
+		return nil, tabletError(err)
+	}
+	return qr, nil
+}
+
+// ClosePrepared releases a prepared statement on VTTablet.
+func (conn *TabletBson) ClosePrepared(ctx context.Context, statementID int64) error {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.rpcClient == nil {
+		return tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkFeature(tproto.FeaturePrepare); err != nil {
+		return err
+	}
+
+	req := &tproto.ClosePreparedRequest{
+		StatementId: statementID,
+		SessionId:   conn.sessionID,
+	}
+	action := func() error {
+		return conn.rpcClient.Call(ctx, "SqlQuery.ClosePrepared", req, &rpc.Unused{})
+	}
+	err := conn.withTimeout(ctx, action)
+	return tabletError(err)
+}
+
+// Explain returns the plan of a query on VTTablet.
+func (conn *TabletBson) Explain(ctx context.Context, query string, bindVars map[string]interface{}) (*tproto.ExplainResult, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.rpcClient == nil {
+		return nil, tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkFeature(tproto.FeatureExplain); err != nil {
+		return nil, err
+	}
+
+	req := &tproto.Query{
+		Sql:           query,
+		BindVariables: bindVars,
+		SessionId:     conn.sessionID,
+	}
+	reply := new(tproto.ExplainResult)
+	action := func() error {
+		return conn.rpcClient.Call(ctx, "SqlQuery.Explain", req, reply)
+	}
+	if err := conn.withTimeout(ctx, action); err != nil {
+		return nil, tabletError(err)
+	}
+	return reply, nil
+}
+
 // StreamExecute starts a streaming query to VTTablet.
-func (conn *TabletBson) StreamExecute(ctx context.Context, query string, bindVars map[string]interface{}, transactionID int64) (<-chan *mproto.QueryResult, tabletconn.ErrFunc, error) {
+func (conn *TabletBson) StreamExecute(ctx context.Context, query string, bindVars map[string]interface{}, transactionID int64, options *tproto.ExecuteOptions) (<-chan *mproto.QueryResult, tabletconn.ErrFunc, error) {
 	conn.mu.RLock()
 	defer conn.mu.RUnlock()
 	if conn.rpcClient == nil {
 		return nil, nil, tabletconn.CONN_CLOSED
 	}
+	if err := conn.checkExecuteOptions(options); err != nil {
+		return nil, nil, err
+	}
 
 	req := &tproto.Query{
 		Sql:           query,
@@ -151,8 +354,15 @@ func (conn *TabletBson) StreamExecute(ctx context.Context, query string, bindVar
 		TransactionId: transactionID,
 		SessionId:     conn.sessionID,
 	}
+	if options != nil {
+		req.FieldsOnly = options.FieldsOnly
+	}
 	sr := make(chan *mproto.QueryResult, 10)
-	c := conn.rpcClient.StreamGo("SqlQuery.StreamExecute", req, sr)
+	streamClient, err := conn.streamClient()
+	if err != nil {
+		return nil, nil, tabletError(err)
+	}
+	c := streamClient.StreamGo("SqlQuery.StreamExecute", req, sr)
 	firstResult, ok := <-sr
 	if !ok {
 		return nil, nil, tabletError(c.Error)
@@ -169,15 +379,21 @@ func (conn *TabletBson) StreamExecute(ctx context.Context, query string, bindVar
 }
 
 // Begin starts a transaction.
-func (conn *TabletBson) Begin(ctx context.Context) (transactionID int64, err error) {
+func (conn *TabletBson) Begin(ctx context.Context, options *tproto.TransactionOptions) (transactionID int64, err error) {
 	conn.mu.RLock()
 	defer conn.mu.RUnlock()
 	if conn.rpcClient == nil {
 		return 0, tabletconn.CONN_CLOSED
 	}
+	if options != nil {
+		if err := conn.checkFeature(tproto.FeatureTransactionOptions); err != nil {
+			return 0, err
+		}
+	}
 
 	req := &tproto.Session{
-		SessionId: conn.sessionID,
+		SessionId:          conn.sessionID,
+		TransactionOptions: options,
 	}
 	var txInfo tproto.TransactionInfo
 	action := func() error {
@@ -248,7 +464,103 @@ func (conn *TabletBson) SplitQuery(ctx context.Context, query tproto.BoundQuery,
 	return reply.Queries, nil
 }
 
-// Close closes underlying bsonrpc.
+// MessageStream starts streaming the messages of a message table.
+func (conn *TabletBson) MessageStream(ctx context.Context, name string) (<-chan *mproto.QueryResult, tabletconn.ErrFunc, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.rpcClient == nil {
+		return nil, nil, tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkFeature(tproto.FeatureMessages); err != nil {
+		return nil, nil, err
+	}
+
+	req := &tproto.MessageStreamRequest{
+		Name:      name,
+		SessionId: conn.sessionID,
+	}
+	sr := make(chan *mproto.QueryResult, 10)
+	streamClient, err := conn.streamClient()
+	if err != nil {
+		return nil, nil, tabletError(err)
+	}
+	c := streamClient.StreamGo("SqlQuery.MessageStream", req, sr)
+	firstResult, ok := <-sr
+	if !ok {
+		return nil, nil, tabletError(c.Error)
+	}
+	srout := make(chan *mproto.QueryResult, 1)
+	go func() {
+		defer close(srout)
+		srout <- firstResult
+		for r := range sr {
+			srout <- r
+		}
+	}()
+	return srout, func() error { return tabletError(c.Error) }, nil
+}
+
+// MessageAck acks the messages of a message table.
+func (conn *TabletBson) MessageAck(ctx context.Context, name string, ids []interface{}) (int64, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.rpcClient == nil {
+		return 0, tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkFeature(tproto.FeatureMessages); err != nil {
+		return 0, err
+	}
+
+	req := &tproto.MessageAckRequest{
+		Name:      name,
+		Ids:       ids,
+		SessionId: conn.sessionID,
+	}
+	reply := new(tproto.MessageAckResult)
+	action := func() error {
+		return conn.rpcClient.Call(ctx, "SqlQuery.MessageAck", req, reply)
+	}
+	if err := conn.withTimeout(ctx, action); err != nil {
+		return 0, tabletError(err)
+	}
+	return reply.Count, nil
+}
+
+// ExportTable starts streaming the rows of a table.
+func (conn *TabletBson) ExportTable(ctx context.Context, req *tproto.ExportTableRequest) (<-chan *tproto.ExportChunk, tabletconn.ErrFunc, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.rpcClient == nil {
+		return nil, nil, tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkFeature(tproto.FeatureExportTable); err != nil {
+		return nil, nil, err
+	}
+
+	r := *req
+	r.SessionId = conn.sessionID
+	sr := make(chan *tproto.ExportChunk, 10)
+	streamClient, err := conn.streamClient()
+	if err != nil {
+		return nil, nil, tabletError(err)
+	}
+	c := streamClient.StreamGo("SqlQuery.ExportTable", &r, sr)
+	firstResult, ok := <-sr
+	if !ok {
+		return nil, nil, tabletError(c.Error)
+	}
+	srout := make(chan *tproto.ExportChunk, 1)
+	go func() {
+		defer close(srout)
+		srout <- firstResult
+		for r := range sr {
+			srout <- r
+		}
+	}()
+	return srout, func() error { return tabletError(c.Error) }, nil
+}
+
+// Close releases the pooled bsonrpc, and closes the streaming one.
 func (conn *TabletBson) Close() {
 	conn.mu.Lock()
 	defer conn.mu.Unlock()
@@ -257,9 +569,13 @@ func (conn *TabletBson) Close() {
 	}
 
 	conn.sessionID = 0
-	rpcClient := conn.rpcClient
 	conn.rpcClient = nil
-	rpcClient.Close()
+	conn.streamMu.Lock()
+	defer conn.streamMu.Unlock()
+	if conn.streamRPCClient != nil {
+		conn.streamRPCClient.Close()
+		conn.streamRPCClient = nil
+	}
 }
 
 // EndPoint returns the rpc end point.
@@ -271,22 +587,37 @@ func tabletError(err error) error {
 	if err == nil {
 		return nil
 	}
-	if _, ok := err.(rpcplus.ServerError); ok {
-		var code int
+	var serverCode vterrors.Code
+	switch err.(type) {
+	case *rpcplus.CodedServerError:
+		serverCode = vterrors.RecoverVtErrorCode(err)
+	case rpcplus.ServerError:
+		// older vttablets only send the message
 		errStr := err.Error()
 		switch {
 		case strings.Contains(errStr, "fatal: "):
-			code = tabletconn.ERR_FATAL
+			serverCode = vterrors.InternalError
 		case strings.Contains(errStr, "retry: "):
-			code = tabletconn.ERR_RETRY
+			serverCode = vterrors.QueryNotServed
 		case strings.Contains(errStr, "tx_pool_full: "):
-			code = tabletconn.ERR_TX_POOL_FULL
+			serverCode = vterrors.ResourceExhausted
 		case strings.Contains(errStr, "not_in_tx: "):
-			code = tabletconn.ERR_NOT_IN_TX
+			serverCode = vterrors.NotInTx
+		case strings.Contains(errStr, "row_limit_exceeded: "):
+			serverCode = vterrors.RowLimitExceeded
 		default:
-			code = tabletconn.ERR_NORMAL
+			serverCode = vterrors.UnknownError
 		}
-		return &tabletconn.ServerError{Code: code, Err: fmt.Sprintf("vttablet: %v", err)}
+	default:
+		return tabletconn.OperationalError(fmt.Sprintf("vttablet: %v", err))
+	}
+	serverErr := &tabletconn.ServerError{
+		Code:       tabletconn.ErrCodeFromVtErrorCode(serverCode),
+		Err:        fmt.Sprintf("vttablet: %v", err),
+		ServerCode: serverCode,
+	}
+	if serverCode == vterrors.NotMaster {
+		serverErr.MasterHint = tproto.ParseMasterHint(err.Error())
 	}
-	return tabletconn.OperationalError(fmt.Sprintf("vttablet: %v", err))
+	return serverErr
 }
@@ -7,14 +7,19 @@ package gorpctabletconn
 import (
 	"net"
 	"net/http"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/youtube/vitess/go/rpcplus"
 	"github.com/youtube/vitess/go/rpcwrap/bsonrpc"
 	"github.com/youtube/vitess/go/vt/tabletserver/gorpcqueryservice"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/tabletserver/queryservice"
+	"github.com/youtube/vitess/go/vt/tabletserver/tabletconn"
 	"github.com/youtube/vitess/go/vt/tabletserver/tabletconntest"
 	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vterrors"
 	"golang.org/x/net/context"
 )
 
@@ -22,7 +27,53 @@ import (
 func TestGoRPCTabletConn(t *testing.T) {
 	// fake service
 	service := tabletconntest.CreateFakeServer(t)
+	client := startServerAndDial(t, service)
 
+	// run the test suite
+	tabletconntest.TestSuite(t, client)
+
+	// and clean up
+	client.Close()
+}
+
+// This test makes sure the client doesn't send the calls an older
+// vttablet wouldn't understand.
+func TestGoRPCTabletConnOldServer(t *testing.T) {
+	service := tabletconntest.CreateOldFakeServer(t)
+	client := startServerAndDial(t, service)
+	tabletconntest.TestOldServerSuite(t, client)
+	client.Close()
+}
+
+// This test makes sure the connections to the same vttablet share
+// their RPC client, and that closing one doesn't break the others.
+func TestGoRPCTabletConnPool(t *testing.T) {
+	service := tabletconntest.CreateFakeServer(t)
+	client1 := startServerAndDial(t, service)
+	client2, err := DialTablet(context.Background(), client1.EndPoint(), tabletconntest.TestKeyspace, tabletconntest.TestShard, 30*time.Second)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	conn1, conn2 := client1.(*TabletBson), client2.(*TabletBson)
+	if conn1.rpcClient != conn2.rpcClient {
+		t.Errorf("the connections don't share their RPC client")
+	}
+
+	sharedClient := conn2.rpcClient
+	client1.Close()
+	tabletconntest.TestSuite(t, client2)
+	if conn2.streamRPCClient == nil || conn2.streamRPCClient == sharedClient {
+		t.Errorf("the streaming RPCs didn't use their own connection")
+	}
+	client2.Close()
+	if sharedClient.IsShutdown() {
+		t.Errorf("closing the connections closed the pooled RPC client")
+	}
+}
+
+// startServerAndDial serves service over go rpc, and returns a client
+// connected to it.
+func startServerAndDial(t *testing.T, service queryservice.QueryService) tabletconn.TabletConn {
 	// listen on a random port
 	listener, err := net.Listen("tcp", ":0")
 	if err != nil {
@@ -53,10 +104,44 @@ func TestGoRPCTabletConn(t *testing.T) {
 	if err != nil {
 		t.Fatalf("dial failed: %v", err)
 	}
+	return client
+}
 
-	// run the test suite
-	tabletconntest.TestSuite(t, client)
+func TestTabletError(t *testing.T) {
+	testcases := []struct {
+		err        error
+		code       int
+		serverCode vterrors.Code
+	}{
+		{&rpcplus.CodedServerError{Code: int64(vterrors.QueryNotServed), Err: "not serving"}, tabletconn.ERR_RETRY, vterrors.QueryNotServed},
+		{&rpcplus.CodedServerError{Code: int64(vterrors.ResourceExhausted), Err: "full"}, tabletconn.ERR_TX_POOL_FULL, vterrors.ResourceExhausted},
+		{&rpcplus.CodedServerError{Code: int64(vterrors.NotMaster), Err: "retry: read-only"}, tabletconn.ERR_RETRY, vterrors.NotMaster},
+		// older vttablets don't send a code
+		{rpcplus.ServerError("fatal: mysql is gone"), tabletconn.ERR_FATAL, vterrors.InternalError},
+		{rpcplus.ServerError("not_in_tx: no transaction"), tabletconn.ERR_NOT_IN_TX, vterrors.NotInTx},
+		{rpcplus.ServerError("row_limit_exceeded: Row count exceeded 10000"), tabletconn.ERR_NORMAL, vterrors.RowLimitExceeded},
+		{rpcplus.ServerError("error: syntax"), tabletconn.ERR_NORMAL, vterrors.UnknownError},
+	}
+	for _, tc := range testcases {
+		err := tabletError(tc.err)
+		serverErr, ok := err.(*tabletconn.ServerError)
+		if !ok {
+			t.Errorf("tabletError(%v) = %#v, want a ServerError", tc.err, err)
+			continue
+		}
+		if serverErr.Code != tc.code || serverErr.ServerCode != tc.serverCode {
+			t.Errorf("tabletError(%v) has codes %v and %v, want %v and %v", tc.err, serverErr.Code, serverErr.ServerCode, tc.code, tc.serverCode)
+		}
+	}
 
-	// and clean up
-	client.Close()
+	// NotMaster errors can say where the master is
+	master := &topo.EndPoint{Uid: 1, Host: "master", NamedPortMap: map[string]int{"vt": 15101}}
+	err := tabletError(&rpcplus.CodedServerError{Code: int64(vterrors.NotMaster), Err: tproto.AppendMasterHint("retry: read-only", master)})
+	if hint := err.(*tabletconn.ServerError).MasterHint; !reflect.DeepEqual(hint, master) {
+		t.Errorf("got master hint %#v, want %#v", hint, master)
+	}
+
+	if _, ok := tabletError(rpcplus.ErrShutdown).(tabletconn.OperationalError); !ok {
+		t.Errorf("a connection error is not an OperationalError")
+	}
 }
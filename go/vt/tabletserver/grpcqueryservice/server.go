@@ -0,0 +1,325 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package grpcqueryservice contains the gRPC implementation of the
+// server side of the query service. It mirrors gorpcqueryservice.
+package grpcqueryservice
+
+import (
+	"google.golang.org/grpc"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/vt/callinfo"
+	"github.com/youtube/vitess/go/vt/servenv"
+	"github.com/youtube/vitess/go/vt/tabletserver"
+	"github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/tabletserver/queryservice"
+	"github.com/youtube/vitess/go/vt/vterrors"
+	"golang.org/x/net/context"
+
+	pb "github.com/youtube/vitess/go/vt/proto/query"
+)
+
+// query is the gRPC query service implementation.
+// It implements the pb.QueryServer interface.
+type query struct {
+	server queryservice.QueryService
+}
+
+// rpcError returns the error to send back for err, or nil. The
+// errors are sent in the responses, with their code.
+func rpcError(err error) *pb.RPCError {
+	if err == nil {
+		return nil
+	}
+	return &pb.RPCError{
+		Code:    int64(vterrors.RecoverVtErrorCode(err)),
+		Message: err.Error(),
+	}
+}
+
+// GetSessionId is part of the pb.QueryServer interface
+func (q *query) GetSessionId(ctx context.Context, request *pb.GetSessionIdRequest) (*pb.GetSessionIdResponse, error) {
+	sessionInfo := new(proto.SessionInfo)
+	err := q.server.GetSessionId(&proto.SessionParams{
+		Keyspace:        request.Keyspace,
+		Shard:           request.Shard,
+		ProtocolVersion: request.ProtocolVersion,
+	}, sessionInfo)
+	return &pb.GetSessionIdResponse{
+		SessionId:       sessionInfo.SessionId,
+		Error:           rpcError(err),
+		ProtocolVersion: sessionInfo.ProtocolVersion,
+		Features:        sessionInfo.Features,
+	}, nil
+}
+
+// Execute is part of the pb.QueryServer interface
+func (q *query) Execute(ctx context.Context, request *pb.ExecuteRequest) (*pb.ExecuteResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	bq, err := proto.Proto3ToBoundQuery(request.Query)
+	if err != nil {
+		return &pb.ExecuteResponse{Error: rpcError(err)}, nil
+	}
+	reply := new(mproto.QueryResult)
+	if err := q.server.Execute(ctx, &proto.Query{
+		Sql:            bq.Sql,
+		BindVariables:  bq.BindVariables,
+		SessionId:      request.SessionId,
+		TransactionId:  request.TransactionId,
+		FieldsOnly:     request.FieldsOnly,
+		IdempotencyKey: request.IdempotencyKey,
+	}, reply); err != nil {
+		return &pb.ExecuteResponse{Error: rpcError(err)}, nil
+	}
+	return &pb.ExecuteResponse{Result: proto.QueryResultToProto3(reply)}, nil
+}
+
+// ExecuteBatch is part of the pb.QueryServer interface
+func (q *query) ExecuteBatch(ctx context.Context, request *pb.ExecuteBatchRequest) (*pb.ExecuteBatchResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	queryList := &proto.QueryList{
+		Queries:       make([]proto.BoundQuery, len(request.Queries)),
+		SessionId:     request.SessionId,
+		TransactionId: request.TransactionId,
+	}
+	for i, pbq := range request.Queries {
+		bq, err := proto.Proto3ToBoundQuery(pbq)
+		if err != nil {
+			return &pb.ExecuteBatchResponse{Error: rpcError(err)}, nil
+		}
+		queryList.Queries[i] = *bq
+	}
+	reply := new(proto.QueryResultList)
+	if err := q.server.ExecuteBatch(ctx, queryList, reply); err != nil {
+		return &pb.ExecuteBatchResponse{Error: rpcError(err)}, nil
+	}
+	response := &pb.ExecuteBatchResponse{
+		Results: make([]*pb.QueryResult, len(reply.List)),
+	}
+	for i := range reply.List {
+		response.Results[i] = proto.QueryResultToProto3(&reply.List[i])
+	}
+	return response, nil
+}
+
+// StreamExecute is part of the pb.QueryServer interface
+func (q *query) StreamExecute(request *pb.StreamExecuteRequest, stream pb.Query_StreamExecuteServer) error {
+	ctx := callinfo.GRPCCallInfo(stream.Context())
+	bq, err := proto.Proto3ToBoundQuery(request.Query)
+	if err == nil {
+		err = q.server.StreamExecute(ctx, &proto.Query{
+			Sql:           bq.Sql,
+			BindVariables: bq.BindVariables,
+			SessionId:     request.SessionId,
+			TransactionId: request.TransactionId,
+			FieldsOnly:    request.FieldsOnly,
+		}, func(reply *mproto.QueryResult) error {
+			return stream.Send(&pb.StreamExecuteResponse{
+				Result: proto.QueryResultToProto3(reply),
+			})
+		})
+	}
+	if err != nil {
+		return stream.Send(&pb.StreamExecuteResponse{Error: rpcError(err)})
+	}
+	return nil
+}
+
+// Begin is part of the pb.QueryServer interface
+func (q *query) Begin(ctx context.Context, request *pb.BeginRequest) (*pb.BeginResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	txInfo := new(proto.TransactionInfo)
+	err := q.server.Begin(ctx, &proto.Session{
+		SessionId:          request.SessionId,
+		TransactionOptions: proto.Proto3ToTransactionOptions(request.Options),
+	}, txInfo)
+	return &pb.BeginResponse{
+		TransactionId: txInfo.TransactionId,
+		Error:         rpcError(err),
+	}, nil
+}
+
+// Commit is part of the pb.QueryServer interface
+func (q *query) Commit(ctx context.Context, request *pb.CommitRequest) (*pb.CommitResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	err := q.server.Commit(ctx, &proto.Session{
+		SessionId:     request.SessionId,
+		TransactionId: request.TransactionId,
+	})
+	return &pb.CommitResponse{Error: rpcError(err)}, nil
+}
+
+// Rollback is part of the pb.QueryServer interface
+func (q *query) Rollback(ctx context.Context, request *pb.RollbackRequest) (*pb.RollbackResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	err := q.server.Rollback(ctx, &proto.Session{
+		SessionId:     request.SessionId,
+		TransactionId: request.TransactionId,
+	})
+	return &pb.RollbackResponse{Error: rpcError(err)}, nil
+}
+
+// Prepare is part of the pb.QueryServer interface
+func (q *query) Prepare(ctx context.Context, request *pb.PrepareRequest) (*pb.PrepareResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	reply := new(proto.PrepareResult)
+	err := q.server.Prepare(ctx, &proto.PrepareRequest{
+		Sql:       request.Sql,
+		SessionId: request.SessionId,
+	}, reply)
+	return &pb.PrepareResponse{
+		StatementId: reply.StatementId,
+		Error:       rpcError(err),
+	}, nil
+}
+
+// ExecutePrepared is part of the pb.QueryServer interface
+func (q *query) ExecutePrepared(ctx context.Context, request *pb.ExecutePreparedRequest) (*pb.ExecutePreparedResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	bindVars, err := proto.Proto3ToBindVariables(request.BindVariables)
+	if err != nil {
+		return &pb.ExecutePreparedResponse{Error: rpcError(err)}, nil
+	}
+	reply := new(mproto.QueryResult)
+	if err := q.server.ExecutePrepared(ctx, &proto.ExecutePreparedRequest{
+		StatementId:   request.StatementId,
+		BindVariables: bindVars,
+		SessionId:     request.SessionId,
+		TransactionId: request.TransactionId,
+	}, reply); err != nil {
+		return &pb.ExecutePreparedResponse{Error: rpcError(err)}, nil
+	}
+	return &pb.ExecutePreparedResponse{Result: proto.QueryResultToProto3(reply)}, nil
+}
+
+// ClosePrepared is part of the pb.QueryServer interface
+func (q *query) ClosePrepared(ctx context.Context, request *pb.ClosePreparedRequest) (*pb.ClosePreparedResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	err := q.server.ClosePrepared(ctx, &proto.ClosePreparedRequest{
+		StatementId: request.StatementId,
+		SessionId:   request.SessionId,
+	})
+	return &pb.ClosePreparedResponse{Error: rpcError(err)}, nil
+}
+
+// Explain is part of the pb.QueryServer interface
+func (q *query) Explain(ctx context.Context, request *pb.ExplainRequest) (*pb.ExplainResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	bq, err := proto.Proto3ToBoundQuery(request.Query)
+	if err != nil {
+		return &pb.ExplainResponse{Error: rpcError(err)}, nil
+	}
+	reply := new(proto.ExplainResult)
+	if err := q.server.Explain(ctx, &proto.Query{
+		Sql:           bq.Sql,
+		BindVariables: bq.BindVariables,
+		SessionId:     request.SessionId,
+	}, reply); err != nil {
+		return &pb.ExplainResponse{Error: rpcError(err)}, nil
+	}
+	return &pb.ExplainResponse{
+		PlanType:   reply.PlanType,
+		Reason:     reply.Reason,
+		TableName:  reply.TableName,
+		IndexUsed:  reply.IndexUsed,
+		PkValues:   reply.PKValues,
+		FieldQuery: reply.FieldQuery,
+		FullQuery:  reply.FullQuery,
+		OuterQuery: reply.OuterQuery,
+		Subquery:   reply.Subquery,
+	}, nil
+}
+
+// SplitQuery is part of the pb.QueryServer interface
+func (q *query) SplitQuery(ctx context.Context, request *pb.SplitQueryRequest) (*pb.SplitQueryResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	bq, err := proto.Proto3ToBoundQuery(request.Query)
+	if err != nil {
+		return &pb.SplitQueryResponse{Error: rpcError(err)}, nil
+	}
+	reply := new(proto.SplitQueryResult)
+	if err := q.server.SplitQuery(ctx, &proto.SplitQueryRequest{
+		Query:      *bq,
+		SplitCount: int(request.SplitCount),
+		SessionID:  request.SessionId,
+	}, reply); err != nil {
+		return &pb.SplitQueryResponse{Error: rpcError(err)}, nil
+	}
+	queries, err := proto.QuerySplitsToProto3(reply.Queries)
+	if err != nil {
+		return &pb.SplitQueryResponse{Error: rpcError(err)}, nil
+	}
+	return &pb.SplitQueryResponse{Queries: queries}, nil
+}
+
+// MessageStream is part of the pb.QueryServer interface
+func (q *query) MessageStream(request *pb.MessageStreamRequest, stream pb.Query_MessageStreamServer) error {
+	ctx := callinfo.GRPCCallInfo(stream.Context())
+	err := q.server.MessageStream(ctx, &proto.MessageStreamRequest{
+		Name:      request.Name,
+		SessionId: request.SessionId,
+	}, func(reply *mproto.QueryResult) error {
+		return stream.Send(&pb.MessageStreamResponse{
+			Result: proto.QueryResultToProto3(reply),
+		})
+	})
+	if err != nil {
+		return stream.Send(&pb.MessageStreamResponse{Error: rpcError(err)})
+	}
+	return nil
+}
+
+// MessageAck is part of the pb.QueryServer interface
+func (q *query) MessageAck(ctx context.Context, request *pb.MessageAckRequest) (*pb.MessageAckResponse, error) {
+	ctx = callinfo.GRPCCallInfo(ctx)
+	ids := make([]interface{}, len(request.Ids))
+	for i, id := range request.Ids {
+		var err error
+		if ids[i], err = proto.Proto3ToBindVariable(id); err != nil {
+			return &pb.MessageAckResponse{Error: rpcError(err)}, nil
+		}
+	}
+	reply := new(proto.MessageAckResult)
+	err := q.server.MessageAck(ctx, &proto.MessageAckRequest{
+		Name:      request.Name,
+		Ids:       ids,
+		SessionId: request.SessionId,
+	}, reply)
+	return &pb.MessageAckResponse{
+		Count: reply.Count,
+		Error: rpcError(err),
+	}, nil
+}
+
+// ExportTable is part of the pb.QueryServer interface
+func (q *query) ExportTable(request *pb.ExportTableRequest, stream pb.Query_ExportTableServer) error {
+	ctx := callinfo.GRPCCallInfo(stream.Context())
+	err := q.server.ExportTable(ctx, proto.Proto3ToExportTableRequest(request), func(chunk *proto.ExportChunk) error {
+		return stream.Send(proto.ExportChunkToProto3(chunk))
+	})
+	if err != nil {
+		return stream.Send(&pb.ExportTableResponse{Error: rpcError(err)})
+	}
+	return nil
+}
+
+// New returns the gRPC query service for the QueryService
+// implementation.
+func New(server queryservice.QueryService) pb.QueryServer {
+	return &query{server}
+}
+
+// StartServer registers the query service on the gRPC server.
+func StartServer(s *grpc.Server, server queryservice.QueryService) {
+	pb.RegisterQueryServer(s, New(server))
+}
+
+func init() {
+	tabletserver.QueryServiceControlRegisterFunctions = append(tabletserver.QueryServiceControlRegisterFunctions, func(qsc tabletserver.QueryServiceControl) {
+		if servenv.GRPCCheckServiceMap("queryservice") {
+			StartServer(servenv.GRPCServer, qsc.QueryService())
+		}
+	})
+}
@@ -0,0 +1,654 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package grpctabletconn contains the gRPC implementation of the
+// tabletconn.TabletConn interface, selected with -tablet_protocol grpc.
+// The tablets must serve the grpc-queryservice on their grpc port.
+package grpctabletconn
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/netutil"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/tabletserver/tabletconn"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vterrors"
+	"github.com/youtube/vitess/go/vt/vttls"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	pb "github.com/youtube/vitess/go/vt/proto/query"
+)
+
+var tabletGRPCTLS = vttls.RegisterClientFlags("tablet-grpc", "vttablet over gRPC")
+
+func init() {
+	tabletconn.RegisterDialer("grpc", DialTablet)
+}
+
+// gRPCQueryClient implements a gRPC implementation for TabletConn
+type gRPCQueryClient struct {
+	// endPoint is set at construction time, and never changed
+	endPoint topo.EndPoint
+
+	// mu protects the next fields
+	mu        sync.RWMutex
+	cc        *grpc.ClientConn
+	c         pb.QueryClient
+	sessionID int64
+	// sessionInfo has the version and features of the vttablet.
+	sessionInfo tproto.SessionInfo
+}
+
+// DialTablet creates and initializes gRPCQueryClient.
+func DialTablet(ctx context.Context, endPoint topo.EndPoint, keyspace, shard string, timeout time.Duration) (tabletconn.TabletConn, error) {
+	config, err := tabletGRPCTLS.Config()
+	if err != nil {
+		return nil, tabletError(err)
+	}
+	var opts []grpc.DialOption
+	if config != nil {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+	}
+
+	// create the RPC client
+	addr := netutil.JoinHostPort(endPoint.Host, endPoint.NamedPortMap["grpc"])
+	cc, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, tabletError(err)
+	}
+	c := pb.NewQueryClient(cc)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	response, err := c.GetSessionId(ctx, &pb.GetSessionIdRequest{
+		Keyspace:        keyspace,
+		Shard:           shard,
+		ProtocolVersion: tproto.ProtocolVersion,
+	})
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	if err != nil {
+		cc.Close()
+		return nil, tabletError(err)
+	}
+
+	return &gRPCQueryClient{
+		endPoint:  endPoint,
+		cc:        cc,
+		c:         c,
+		sessionID: response.SessionId,
+		sessionInfo: tproto.SessionInfo{
+			SessionId:       response.SessionId,
+			ProtocolVersion: response.ProtocolVersion,
+			Features:        response.Features,
+		},
+	}, nil
+}
+
+// checkFeature returns an error if the vttablet doesn't support the
+// feature.
+func (conn *gRPCQueryClient) checkFeature(feature string) error {
+	if !conn.sessionInfo.HasFeature(feature) {
+		return tabletconn.UnsupportedFeatureError(feature, conn.sessionInfo.ProtocolVersion)
+	}
+	return nil
+}
+
+// checkExecuteOptions returns an error if the vttablet doesn't
+// support the features the options need.
+func (conn *gRPCQueryClient) checkExecuteOptions(options *tproto.ExecuteOptions) error {
+	if options == nil {
+		return nil
+	}
+	if options.FieldsOnly {
+		if err := conn.checkFeature(tproto.FeatureFieldsOnly); err != nil {
+			return err
+		}
+	}
+	if options.IdempotencyKey != "" {
+		if err := conn.checkFeature(tproto.FeatureIdempotencyKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Execute sends the query to VTTablet.
+func (conn *gRPCQueryClient) Execute(ctx context.Context, query string, bindVars map[string]interface{}, transactionID int64, options *tproto.ExecuteOptions) (*mproto.QueryResult, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.cc == nil {
+		return nil, tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkExecuteOptions(options); err != nil {
+		return nil, err
+	}
+
+	q, err := tproto.BoundQueryToProto3(&tproto.BoundQuery{
+		Sql:           query,
+		BindVariables: bindVars,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req := &pb.ExecuteRequest{
+		Query:         q,
+		SessionId:     conn.sessionID,
+		TransactionId: transactionID,
+	}
+	if options != nil {
+		req.FieldsOnly = options.FieldsOnly
+		req.IdempotencyKey = options.IdempotencyKey
+	}
+	response, err := conn.c.Execute(ctx, req)
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	if err != nil {
+		return nil, tabletError(err)
+	}
+	return tproto.Proto3ToQueryResult(response.Result)
+}
+
+// ExecuteBatch sends a batch query to VTTablet.
+func (conn *gRPCQueryClient) ExecuteBatch(ctx context.Context, queries []tproto.BoundQuery, transactionID int64) (*tproto.QueryResultList, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.cc == nil {
+		return nil, tabletconn.CONN_CLOSED
+	}
+
+	req := &pb.ExecuteBatchRequest{
+		Queries:       make([]*pb.BoundQuery, len(queries)),
+		SessionId:     conn.sessionID,
+		TransactionId: transactionID,
+	}
+	for i := range queries {
+		var err error
+		if req.Queries[i], err = tproto.BoundQueryToProto3(&queries[i]); err != nil {
+			return nil, err
+		}
+	}
+	response, err := conn.c.ExecuteBatch(ctx, req)
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	if err != nil {
+		return nil, tabletError(err)
+	}
+	qrl := &tproto.QueryResultList{
+		List: make([]mproto.QueryResult, len(response.Results)),
+	}
+	for i, result := range response.Results {
+		qr, err := tproto.Proto3ToQueryResult(result)
+		if err != nil {
+			return nil, err
+		}
+		qrl.List[i] = *qr
+	}
+	return qrl, nil
+}
+
+// StreamExecute starts a streaming query to VTTablet.
+func (conn *gRPCQueryClient) StreamExecute(ctx context.Context, query string, bindVars map[string]interface{}, transactionID int64, options *tproto.ExecuteOptions) (<-chan *mproto.QueryResult, tabletconn.ErrFunc, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.cc == nil {
+		return nil, nil, tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkExecuteOptions(options); err != nil {
+		return nil, nil, err
+	}
+
+	q, err := tproto.BoundQueryToProto3(&tproto.BoundQuery{
+		Sql:           query,
+		BindVariables: bindVars,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	req := &pb.StreamExecuteRequest{
+		Query:         q,
+		SessionId:     conn.sessionID,
+		TransactionId: transactionID,
+	}
+	if options != nil {
+		req.FieldsOnly = options.FieldsOnly
+	}
+	stream, err := conn.c.StreamExecute(ctx, req)
+	if err != nil {
+		return nil, nil, tabletError(err)
+	}
+	return streamQueryResults(func() (*pb.QueryResult, *pb.RPCError, error) {
+		response, err := stream.Recv()
+		if err != nil {
+			return nil, nil, err
+		}
+		return response.Result, response.Error, nil
+	})
+}
+
+// Prepare prepares a statement on VTTablet.
+func (conn *gRPCQueryClient) Prepare(ctx context.Context, query string) (int64, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.cc == nil {
+		return 0, tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkFeature(tproto.FeaturePrepare); err != nil {
+		return 0, err
+	}
+
+	response, err := conn.c.Prepare(ctx, &pb.PrepareRequest{
+		Sql:       query,
+		SessionId: conn.sessionID,
+	})
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	if err != nil {
+		return 0, tabletError(err)
+	}
+	return response.StatementId, nil
+}
+
+// ExecutePrepared executes a prepared statement on VTTablet.
+func (conn *gRPCQueryClient) ExecutePrepared(ctx context.Context, statementID int64, bindVars map[string]interface{}, transactionID int64) (*mproto.QueryResult, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.cc == nil {
+		return nil, tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkFeature(tproto.FeaturePrepare); err != nil {
+		return nil, err
+	}
+
+	bv, err := tproto.BindVariablesToProto3(bindVars)
+	if err != nil {
+		return nil, err
+	}
+	response, err := conn.c.ExecutePrepared(ctx, &pb.ExecutePreparedRequest{
+		StatementId:   statementID,
+		BindVariables: bv,
+		SessionId:     conn.sessionID,
+		TransactionId: transactionID,
+	})
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	if err != nil {
+		return nil, tabletError(err)
+	}
+	return tproto.Proto3ToQueryResult(response.Result)
+}
+
+// ClosePrepared releases a prepared statement on VTTablet.
+func (conn *gRPCQueryClient) ClosePrepared(ctx context.Context, statementID int64) error {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.cc == nil {
+		return tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkFeature(tproto.FeaturePrepare); err != nil {
+		return err
+	}
+
+	response, err := conn.c.ClosePrepared(ctx, &pb.ClosePreparedRequest{
+		StatementId: statementID,
+		SessionId:   conn.sessionID,
+	})
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	return tabletError(err)
+}
+
+// Explain returns the plan of a query on VTTablet.
+func (conn *gRPCQueryClient) Explain(ctx context.Context, query string, bindVars map[string]interface{}) (*tproto.ExplainResult, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.cc == nil {
+		return nil, tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkFeature(tproto.FeatureExplain); err != nil {
+		return nil, err
+	}
+
+	q, err := tproto.BoundQueryToProto3(&tproto.BoundQuery{
+		Sql:           query,
+		BindVariables: bindVars,
+	})
+	if err != nil {
+		return nil, err
+	}
+	response, err := conn.c.Explain(ctx, &pb.ExplainRequest{
+		Query:     q,
+		SessionId: conn.sessionID,
+	})
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	if err != nil {
+		return nil, tabletError(err)
+	}
+	return &tproto.ExplainResult{
+		PlanType:   response.PlanType,
+		Reason:     response.Reason,
+		TableName:  response.TableName,
+		IndexUsed:  response.IndexUsed,
+		PKValues:   response.PkValues,
+		FieldQuery: response.FieldQuery,
+		FullQuery:  response.FullQuery,
+		OuterQuery: response.OuterQuery,
+		Subquery:   response.Subquery,
+	}, nil
+}
+
+// Begin starts a transaction.
+func (conn *gRPCQueryClient) Begin(ctx context.Context, options *tproto.TransactionOptions) (transactionID int64, err error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.cc == nil {
+		return 0, tabletconn.CONN_CLOSED
+	}
+	if options != nil {
+		if err := conn.checkFeature(tproto.FeatureTransactionOptions); err != nil {
+			return 0, err
+		}
+	}
+
+	response, err := conn.c.Begin(ctx, &pb.BeginRequest{
+		SessionId: conn.sessionID,
+		Options:   tproto.TransactionOptionsToProto3(options),
+	})
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	if err != nil {
+		return 0, tabletError(err)
+	}
+	return response.TransactionId, nil
+}
+
+// Commit commits the ongoing transaction.
+func (conn *gRPCQueryClient) Commit(ctx context.Context, transactionID int64) error {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.cc == nil {
+		return tabletconn.CONN_CLOSED
+	}
+
+	response, err := conn.c.Commit(ctx, &pb.CommitRequest{
+		SessionId:     conn.sessionID,
+		TransactionId: transactionID,
+	})
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	return tabletError(err)
+}
+
+// Rollback rolls back the ongoing transaction.
+func (conn *gRPCQueryClient) Rollback(ctx context.Context, transactionID int64) error {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.cc == nil {
+		return tabletconn.CONN_CLOSED
+	}
+
+	response, err := conn.c.Rollback(ctx, &pb.RollbackRequest{
+		SessionId:     conn.sessionID,
+		TransactionId: transactionID,
+	})
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	return tabletError(err)
+}
+
+// SplitQuery splits a query into equally sized smaller queries.
+func (conn *gRPCQueryClient) SplitQuery(ctx context.Context, query tproto.BoundQuery, splitCount int) ([]tproto.QuerySplit, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.cc == nil {
+		return nil, tabletconn.CONN_CLOSED
+	}
+
+	q, err := tproto.BoundQueryToProto3(&query)
+	if err != nil {
+		return nil, err
+	}
+	response, err := conn.c.SplitQuery(ctx, &pb.SplitQueryRequest{
+		Query:      q,
+		SplitCount: int64(splitCount),
+		SessionId:  conn.sessionID,
+	})
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	if err != nil {
+		return nil, tabletError(err)
+	}
+	return tproto.Proto3ToQuerySplits(response.Queries)
+}
+
+// MessageStream starts streaming the messages of a message table.
+func (conn *gRPCQueryClient) MessageStream(ctx context.Context, name string) (<-chan *mproto.QueryResult, tabletconn.ErrFunc, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.cc == nil {
+		return nil, nil, tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkFeature(tproto.FeatureMessages); err != nil {
+		return nil, nil, err
+	}
+
+	stream, err := conn.c.MessageStream(ctx, &pb.MessageStreamRequest{
+		Name:      name,
+		SessionId: conn.sessionID,
+	})
+	if err != nil {
+		return nil, nil, tabletError(err)
+	}
+	return streamQueryResults(func() (*pb.QueryResult, *pb.RPCError, error) {
+		response, err := stream.Recv()
+		if err != nil {
+			return nil, nil, err
+		}
+		return response.Result, response.Error, nil
+	})
+}
+
+// MessageAck acks the messages of a message table.
+func (conn *gRPCQueryClient) MessageAck(ctx context.Context, name string, ids []interface{}) (int64, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.cc == nil {
+		return 0, tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkFeature(tproto.FeatureMessages); err != nil {
+		return 0, err
+	}
+
+	req := &pb.MessageAckRequest{
+		Name:      name,
+		Ids:       make([]*pb.BindVariable, len(ids)),
+		SessionId: conn.sessionID,
+	}
+	for i, id := range ids {
+		var err error
+		if req.Ids[i], err = tproto.BindVariableToProto3(id); err != nil {
+			return 0, err
+		}
+	}
+	response, err := conn.c.MessageAck(ctx, req)
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	if err != nil {
+		return 0, tabletError(err)
+	}
+	return response.Count, nil
+}
+
+// ExportTable starts streaming the rows of a table.
+func (conn *gRPCQueryClient) ExportTable(ctx context.Context, req *tproto.ExportTableRequest) (<-chan *tproto.ExportChunk, tabletconn.ErrFunc, error) {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	if conn.cc == nil {
+		return nil, nil, tabletconn.CONN_CLOSED
+	}
+	if err := conn.checkFeature(tproto.FeatureExportTable); err != nil {
+		return nil, nil, err
+	}
+
+	r := tproto.ExportTableRequestToProto3(req)
+	r.SessionId = conn.sessionID
+	stream, err := conn.c.ExportTable(ctx, r)
+	if err != nil {
+		return nil, nil, tabletError(err)
+	}
+
+	// read the first chunk, so the errors of the request are
+	// returned right away
+	recv := func() (*tproto.ExportChunk, error) {
+		response, err := stream.Recv()
+		if err == nil {
+			err = rpcErrorToError(response.Error)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return tproto.Proto3ToExportChunk(response)
+	}
+	firstChunk, err := recv()
+	if err == io.EOF {
+		sr := make(chan *tproto.ExportChunk)
+		close(sr)
+		return sr, func() error { return nil }, nil
+	}
+	if err != nil {
+		return nil, nil, tabletError(err)
+	}
+
+	sr := make(chan *tproto.ExportChunk, 10)
+	var finalError error
+	go func() {
+		defer close(sr)
+		sr <- firstChunk
+		for {
+			chunk, err := recv()
+			if err != nil {
+				if err != io.EOF {
+					finalError = err
+				}
+				return
+			}
+			sr <- chunk
+		}
+	}()
+	return sr, func() error { return tabletError(finalError) }, nil
+}
+
+// Close closes underlying gRPC channel.
+func (conn *gRPCQueryClient) Close() {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.cc == nil {
+		return
+	}
+
+	conn.sessionID = 0
+	cc := conn.cc
+	conn.cc = nil
+	cc.Close()
+}
+
+// EndPoint returns the rpc end point.
+func (conn *gRPCQueryClient) EndPoint() topo.EndPoint {
+	return conn.endPoint
+}
+
+// streamQueryResults reads the results of a stream with recv. The
+// first one is read right away, so the errors of the request are
+// returned by the call. The next ones are sent to the returned
+// channel, until the stream ends.
+func streamQueryResults(recv func() (*pb.QueryResult, *pb.RPCError, error)) (<-chan *mproto.QueryResult, tabletconn.ErrFunc, error) {
+	next := func() (*mproto.QueryResult, error) {
+		result, rpcErr, err := recv()
+		if err == nil {
+			err = rpcErrorToError(rpcErr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return tproto.Proto3ToQueryResult(result)
+	}
+	firstResult, err := next()
+	if err == io.EOF {
+		sr := make(chan *mproto.QueryResult)
+		close(sr)
+		return sr, func() error { return nil }, nil
+	}
+	if err != nil {
+		return nil, nil, tabletError(err)
+	}
+
+	sr := make(chan *mproto.QueryResult, 10)
+	var finalError error
+	go func() {
+		defer close(sr)
+		sr <- firstResult
+		for {
+			qr, err := next()
+			if err != nil {
+				if err != io.EOF {
+					finalError = err
+				}
+				return
+			}
+			sr <- qr
+		}
+	}()
+	return sr, func() error { return tabletError(finalError) }, nil
+}
+
+// rpcErrorToError returns the error sent by the server, or nil.
+func rpcErrorToError(rpcErr *pb.RPCError) error {
+	if rpcErr == nil {
+		return nil
+	}
+	return vterrors.New(vterrors.Code(rpcErr.Code), rpcErr.Message)
+}
+
+// tabletError converts the error of a call to the tabletconn
+// errors: the errors sent by the server become ServerErrors, the
+// others OperationalErrors.
+func tabletError(err error) error {
+	if err == nil {
+		return nil
+	}
+	vtErr, ok := err.(vterrors.VtError)
+	if !ok {
+		return tabletconn.OperationalError(fmt.Sprintf("vttablet: %v", err))
+	}
+	serverCode := vtErr.VtErrorCode()
+	serverErr := &tabletconn.ServerError{
+		Code:       tabletconn.ErrCodeFromVtErrorCode(serverCode),
+		Err:        fmt.Sprintf("vttablet: %v", err),
+		ServerCode: serverCode,
+	}
+	if serverCode == vterrors.NotMaster {
+		serverErr.MasterHint = tproto.ParseMasterHint(err.Error())
+	}
+	return serverErr
+}
@@ -0,0 +1,99 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package grpctabletconn
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/tabletserver/grpcqueryservice"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/tabletserver/tabletconn"
+	"github.com/youtube/vitess/go/vt/tabletserver/tabletconntest"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vterrors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	pb "github.com/youtube/vitess/go/vt/proto/query"
+)
+
+// This test makes sure the gRPC service works
+func TestGRPCTabletConn(t *testing.T) {
+	// fake service
+	service := tabletconntest.CreateFakeServer(t)
+
+	// listen on a random port
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	// Create a gRPC server and listen on the port
+	server := grpc.NewServer()
+	grpcqueryservice.StartServer(server, service)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	// Create a gRPC client connecting to the server
+	ctx := context.Background()
+	client, err := DialTablet(ctx, topo.EndPoint{
+		Host: "localhost",
+		NamedPortMap: map[string]int{
+			"grpc": port,
+		},
+	}, tabletconntest.TestKeyspace, tabletconntest.TestShard, 30*time.Second)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+
+	// run the test suite
+	tabletconntest.TestSuite(t, client)
+
+	// and clean up
+	client.Close()
+}
+
+func TestTabletError(t *testing.T) {
+	testcases := []struct {
+		rpcErr     *pb.RPCError
+		code       int
+		serverCode vterrors.Code
+	}{
+		{&pb.RPCError{Code: int64(vterrors.QueryNotServed), Message: "not serving"}, tabletconn.ERR_RETRY, vterrors.QueryNotServed},
+		{&pb.RPCError{Code: int64(vterrors.ResourceExhausted), Message: "full"}, tabletconn.ERR_TX_POOL_FULL, vterrors.ResourceExhausted},
+		{&pb.RPCError{Code: int64(vterrors.NotInTx), Message: "no transaction"}, tabletconn.ERR_NOT_IN_TX, vterrors.NotInTx},
+		{&pb.RPCError{Code: int64(vterrors.UnknownError), Message: "syntax"}, tabletconn.ERR_NORMAL, vterrors.UnknownError},
+	}
+	for _, tc := range testcases {
+		err := tabletError(rpcErrorToError(tc.rpcErr))
+		serverErr, ok := err.(*tabletconn.ServerError)
+		if !ok {
+			t.Errorf("tabletError(%v) = %#v, want a ServerError", tc.rpcErr, err)
+			continue
+		}
+		if serverErr.Code != tc.code || serverErr.ServerCode != tc.serverCode {
+			t.Errorf("tabletError(%v) has codes %v and %v, want %v and %v", tc.rpcErr, serverErr.Code, serverErr.ServerCode, tc.code, tc.serverCode)
+		}
+	}
+
+	// NotMaster errors can say where the master is
+	master := &topo.EndPoint{Uid: 1, Host: "master", NamedPortMap: map[string]int{"grpc": 15991}}
+	err := tabletError(rpcErrorToError(&pb.RPCError{Code: int64(vterrors.NotMaster), Message: tproto.AppendMasterHint("retry: read-only", master)}))
+	if hint := err.(*tabletconn.ServerError).MasterHint; !reflect.DeepEqual(hint, master) {
+		t.Errorf("got master hint %#v, want %#v", hint, master)
+	}
+
+	if err := tabletError(rpcErrorToError(nil)); err != nil {
+		t.Errorf("tabletError(nil) = %v, want nil", err)
+	}
+	if _, ok := tabletError(errors.New("connection refused")).(tabletconn.OperationalError); !ok {
+		t.Errorf("a connection error is not an OperationalError")
+	}
+}
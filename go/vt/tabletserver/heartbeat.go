@@ -0,0 +1,209 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/sync2"
+	"github.com/youtube/vitess/go/timer"
+	"golang.org/x/net/context"
+)
+
+var (
+	errHeartbeatDisabled = errors.New("heartbeat is disabled")
+	errNoHeartbeat       = errors.New("no heartbeat was replicated yet")
+)
+
+// The heartbeat table has a single row, with the time the master
+// last wrote it.
+var heartbeatCreateQueries = []string{
+	"create database if not exists _vt",
+	`create table if not exists _vt.heartbeat (
+  id int unsigned not null,
+  time_created_ns bigint unsigned not null,
+  primary key (id)
+) engine=InnoDB`,
+}
+
+// heartbeat measures the replication lag independently of
+// Seconds_Behind_Master, which is wrong when the slave is waiting
+// for the relay logs. Every interval, the master writes the current
+// time in the _vt.heartbeat table, and the replicas read it back:
+// their lag is how old the replicated time is. As the time comes
+// from the master, the clocks of the hosts must be in sync.
+type heartbeat struct {
+	qe       *QueryEngine
+	interval time.Duration
+	ticks    *timer.Timer
+	now      func() time.Time
+
+	isMaster sync2.AtomicInt32
+
+	mu     sync.Mutex
+	isOpen bool
+	// tableCreated is set once the master made sure the
+	// heartbeat table exists.
+	tableCreated bool
+	// lag and lastError are the result of the last read.
+	lag       time.Duration
+	lastError error
+
+	// errors counts the failed reads and writes.
+	errors *stats.Counters
+}
+
+func newHeartbeat(qe *QueryEngine, statsPrefix string, interval time.Duration) *heartbeat {
+	hb := &heartbeat{
+		qe:        qe,
+		interval:  interval,
+		ticks:     timer.NewTimer(interval),
+		now:       time.Now,
+		lastError: errNoHeartbeat,
+		errors:    stats.NewCounters(statsPrefix + "HeartbeatErrors"),
+	}
+	stats.Publish(statsPrefix+"HeartbeatLag", stats.DurationFunc(func() time.Duration {
+		lag, _ := hb.Lag()
+		return lag
+	}))
+	return hb
+}
+
+// Open starts writing or reading the heartbeat.
+func (hb *heartbeat) Open() {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	if hb.isOpen {
+		return
+	}
+	hb.isOpen = true
+	hb.lastError = errNoHeartbeat
+	hb.ticks.Start(func() { hb.run() })
+}
+
+// Close stops the heartbeat.
+func (hb *heartbeat) Close() {
+	hb.mu.Lock()
+	if !hb.isOpen {
+		hb.mu.Unlock()
+		return
+	}
+	hb.isOpen = false
+	hb.mu.Unlock()
+	hb.ticks.Stop()
+}
+
+// SetIsMaster makes the heartbeat write the time if isMaster is true,
+// and read it otherwise.
+func (hb *heartbeat) SetIsMaster(isMaster bool) {
+	if isMaster {
+		hb.isMaster.Set(1)
+	} else {
+		hb.isMaster.Set(0)
+	}
+}
+
+// Lag returns the replication lag measured by the last read. It is
+// always 0 on the master.
+func (hb *heartbeat) Lag() (time.Duration, error) {
+	if hb.interval <= 0 {
+		return 0, errHeartbeatDisabled
+	}
+	if hb.isMaster.Get() != 0 {
+		return 0, nil
+	}
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	if !hb.isOpen {
+		return 0, errHeartbeatDisabled
+	}
+	return hb.lag, hb.lastError
+}
+
+// run writes the heartbeat on the master, and reads it on the
+// replicas.
+func (hb *heartbeat) run() {
+	defer func() {
+		if x := recover(); x != nil {
+			internalErrors.Add("Heartbeat", 1)
+			mlog.Errorf("heartbeat error: %v", x)
+		}
+	}()
+	ctx := context.Background()
+	if hb.isMaster.Get() != 0 {
+		if err := hb.write(); err != nil {
+			hb.errors.Add("Write", 1)
+			mlog.Warningf("could not write the heartbeat: %v", err)
+		}
+		return
+	}
+	lag, err := hb.read(ctx)
+	if err != nil {
+		hb.errors.Add("Read", 1)
+	}
+	hb.mu.Lock()
+	hb.lag = lag
+	hb.lastError = err
+	hb.mu.Unlock()
+}
+
+// write stores the current time in the heartbeat table, creating
+// it first if needed. It uses a dba connection: MySQL is read-only
+// while the shard is frozen, and the master keeps writing.
+func (hb *heartbeat) write() error {
+	conn, err := hb.qe.connPool.dbaPool.Get(hb.interval)
+	if err != nil {
+		return NewTabletError(ErrFatal, "cannot get a dba connection: %v", err)
+	}
+	defer conn.Recycle()
+
+	hb.mu.Lock()
+	tableCreated := hb.tableCreated
+	hb.mu.Unlock()
+	if !tableCreated {
+		for _, query := range heartbeatCreateQueries {
+			if _, err := conn.ExecuteFetch(query, 0, false); err != nil {
+				return NewTabletErrorSql(ErrFail, err)
+			}
+		}
+		hb.mu.Lock()
+		hb.tableCreated = true
+		hb.mu.Unlock()
+	}
+
+	query := fmt.Sprintf("insert into _vt.heartbeat (id, time_created_ns) values (0, %d) on duplicate key update time_created_ns = values(time_created_ns)", hb.now().UnixNano())
+	if _, err := conn.ExecuteFetch(query, 0, false); err != nil {
+		return NewTabletErrorSql(ErrFail, err)
+	}
+	return nil
+}
+
+// read returns how old the replicated heartbeat is. A heartbeat from
+// the future, because of clock skew, is no lag.
+func (hb *heartbeat) read(ctx context.Context) (time.Duration, error) {
+	conn := getOrPanic(ctx, hb.qe.connPool)
+	defer conn.Recycle()
+
+	qr, err := conn.Exec(ctx, "select time_created_ns from _vt.heartbeat where id = 0", 1, false)
+	if err != nil {
+		return 0, NewTabletErrorSql(ErrFail, err)
+	}
+	if len(qr.Rows) != 1 || len(qr.Rows[0]) != 1 {
+		return 0, errNoHeartbeat
+	}
+	ts, err := qr.Rows[0][0].ParseInt64()
+	if err != nil {
+		return 0, fmt.Errorf("invalid heartbeat %v: %v", qr.Rows[0][0].String(), err)
+	}
+	lag := hb.now().Sub(time.Unix(0, ts))
+	if lag < 0 {
+		lag = 0
+	}
+	return lag, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/tabletserver/fakesqldb"
+)
+
+const heartbeatReadQuery = "select time_created_ns from _vt.heartbeat where id = 0"
+
+func TestHeartbeatWrite(t *testing.T) {
+	db := setUpHeartbeatTest()
+	sqlQuery, hb := allowHeartbeatQueries(t)
+	defer sqlQuery.disallowQueries()
+	hb.SetIsMaster(true)
+
+	insert := "insert into _vt.heartbeat (id, time_created_ns) values (0, 10000000000000) on duplicate key update time_created_ns = values(time_created_ns)"
+	db.AddRejectedQuery(insert)
+	hb.run()
+	if got := hb.errors.Counts()["Write"]; got != 1 {
+		t.Errorf("write errors: %v, want 1", got)
+	}
+	if !hb.tableCreated {
+		t.Errorf("heartbeat table wasn't created")
+	}
+
+	db.DeleteRejectedQuery(insert)
+	hb.run()
+	if got := hb.errors.Counts()["Write"]; got != 1 {
+		t.Errorf("write errors: %v, want 1", got)
+	}
+	if lag, err := hb.Lag(); lag != 0 || err != nil {
+		t.Errorf("Lag on the master: %v, %v, want 0, nil", lag, err)
+	}
+}
+
+func TestHeartbeatRead(t *testing.T) {
+	db := setUpHeartbeatTest()
+	sqlQuery, hb := allowHeartbeatQueries(t)
+	defer sqlQuery.disallowQueries()
+
+	// Nothing was replicated yet.
+	hb.run()
+	if _, err := hb.Lag(); err != errNoHeartbeat {
+		t.Errorf("Lag without heartbeat: %v, want %v", err, errNoHeartbeat)
+	}
+
+	db.AddQuery(heartbeatReadQuery, heartbeatResult(time.Unix(9995, 0)))
+	hb.run()
+	if lag, err := hb.Lag(); lag != 5*time.Second || err != nil {
+		t.Errorf("Lag: %v, %v, want 5s, nil", lag, err)
+	}
+
+	// Clock skew is no lag.
+	db.AddQuery(heartbeatReadQuery, heartbeatResult(time.Unix(10002, 0)))
+	hb.run()
+	if lag, err := hb.Lag(); lag != 0 || err != nil {
+		t.Errorf("Lag with a heartbeat from the future: %v, %v, want 0, nil", lag, err)
+	}
+
+	db.AddRejectedQuery(heartbeatReadQuery)
+	hb.run()
+	if _, err := hb.Lag(); err == nil {
+		t.Errorf("Lag should have failed")
+	}
+	if got := hb.errors.Counts()["Read"]; got != 2 {
+		t.Errorf("read errors: %v, want 2", got)
+	}
+
+	hb.interval = 0
+	if _, err := hb.Lag(); err != errHeartbeatDisabled {
+		t.Errorf("Lag with the heartbeat disabled: %v, want %v", err, errHeartbeatDisabled)
+	}
+}
+
+func setUpHeartbeatTest() *fakesqldb.DB {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	return db
+}
+
+// allowHeartbeatQueries starts a query service with a heartbeat
+// that doesn't tick, so the tests can run it.
+func allowHeartbeatQueries(t *testing.T) (*SqlQuery, *heartbeat) {
+	randID := rand.Int63()
+	config := DefaultQsConfig
+	config.StatsPrefix = fmt.Sprintf("Stats-%d-", randID)
+	config.DebugURLPrefix = fmt.Sprintf("/debug-%d-", randID)
+	config.RowCache.StatsPrefix = fmt.Sprintf("Stats-%d-", randID)
+	config.PoolNamePrefix = fmt.Sprintf("Pool-%d-", randID)
+	config.MessagePollInterval = 0
+	config.RowGCInterval = 0
+	config.HeartbeatInterval = 0
+	sqlQuery := NewSqlQuery(config)
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, nil, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	hb := sqlQuery.qe.heartbeat
+	hb.interval = time.Second
+	hb.now = func() time.Time { return time.Unix(10000, 0) }
+	return sqlQuery, hb
+}
+
+func heartbeatResult(ts time.Time) *mproto.QueryResult {
+	return &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{sqltypes.MakeNumeric([]byte(fmt.Sprintf("%d", ts.UnixNano())))},
+		},
+	}
+}
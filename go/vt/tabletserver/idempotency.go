@@ -0,0 +1,288 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/youtube/vitess/go/mysql"
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/sync2"
+	"github.com/youtube/vitess/go/timer"
+	"golang.org/x/net/context"
+)
+
+// The idempotency keys table has the keys of the recent writes, with
+// the hash of their statement and the result to return when they're
+// retried.
+var idempotencyCreateQueries = []string{
+	"create database if not exists _vt",
+	`create table if not exists _vt.idempotency_keys (
+  idempotency_key varbinary(255) not null,
+  sql_hash varbinary(64) not null,
+  rows_affected bigint unsigned not null,
+  insert_id bigint unsigned not null,
+  time_created_ns bigint unsigned not null,
+  primary key (idempotency_key),
+  key time_created_ns (time_created_ns)
+) engine=InnoDB`,
+}
+
+const (
+	// maxIdempotencyKeyLen is the size of the idempotency_key
+	// column.
+	maxIdempotencyKeyLen = 255
+
+	// idempotencyPurgeBatchSize is the number of keys purged by
+	// each delete.
+	idempotencyPurgeBatchSize = 500
+)
+
+// idempotencyKeys applies the writes that have an idempotency key only
+// once. A client that loses its connection while committing doesn't
+// know if its write was applied: if it retries it with the same key,
+// the retry returns the result of the first write instead of applying
+// it again. The keys are recorded in the _vt.idempotency_keys table by
+// the transaction of the write, so they are committed or rolled back
+// with it. A key can't be reused for another statement. They're kept
+// for ttl, the master purges the older ones every ttl/4.
+type idempotencyKeys struct {
+	qe    *QueryEngine
+	ttl   time.Duration
+	ticks *timer.Timer
+	now   func() time.Time
+
+	isMaster sync2.AtomicInt32
+
+	mu     sync.Mutex
+	isOpen bool
+	// tableCreated is set once the table is known to exist.
+	tableCreated bool
+
+	// counts counts the recorded and replayed writes, and the
+	// purged keys.
+	counts *stats.Counters
+}
+
+func newIdempotencyKeys(qe *QueryEngine, statsPrefix string, ttl time.Duration) *idempotencyKeys {
+	return &idempotencyKeys{
+		qe:     qe,
+		ttl:    ttl,
+		ticks:  timer.NewTimer(ttl / 4),
+		now:    time.Now,
+		counts: stats.NewCounters(statsPrefix + "IdempotencyKeys"),
+	}
+}
+
+// Open starts purging the old keys.
+func (ik *idempotencyKeys) Open() {
+	ik.mu.Lock()
+	defer ik.mu.Unlock()
+	if ik.isOpen {
+		return
+	}
+	ik.isOpen = true
+	ik.ticks.Start(func() { ik.run() })
+}
+
+// Close stops purging the old keys.
+func (ik *idempotencyKeys) Close() {
+	ik.mu.Lock()
+	if !ik.isOpen {
+		ik.mu.Unlock()
+		return
+	}
+	ik.isOpen = false
+	ik.mu.Unlock()
+	ik.ticks.Stop()
+}
+
+// SetIsMaster enables the purges if isMaster is true.
+func (ik *idempotencyKeys) SetIsMaster(isMaster bool) {
+	if isMaster {
+		ik.isMaster.Set(1)
+	} else {
+		ik.isMaster.Set(0)
+	}
+}
+
+// claim records the key of a write before it is applied, in its
+// transaction. If a write with the key was already committed, it
+// returns its result instead, and the write must not be applied.
+//
+// A concurrent write with the same key waits on the lock of the
+// inserted row, until the transaction ends. Unlike a locking read of a
+// missing key, the insert takes no gap lock, so writes with different
+// keys don't deadlock each other.
+func (ik *idempotencyKeys) claim(ctx context.Context, conn poolConn, key, sqlHash string) *mproto.QueryResult {
+	if ik.ttl <= 0 {
+		panic(NewTabletError(ErrFail, "idempotency keys are disabled"))
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		panic(NewTabletError(ErrFail, "idempotency key is longer than %d bytes", maxIdempotencyKeyLen))
+	}
+	ik.createTable(ctx)
+
+	query := fmt.Sprintf(
+		"insert into _vt.idempotency_keys (idempotency_key, sql_hash, rows_affected, insert_id, time_created_ns) values (%s, '%s', 0, 0, %d)",
+		encodeIdempotencyKey(key),
+		sqlHash,
+		ik.now().UnixNano(),
+	)
+	_, err := conn.Exec(ctx, query, 0, false)
+	if err == nil {
+		return nil
+	}
+	if terr, ok := err.(*TabletError); !ok || terr.SqlError != mysql.ErrDupEntry {
+		panic(err)
+	}
+
+	// The write was committed: a locking read sees its row even if
+	// our snapshot is older.
+	query = fmt.Sprintf("select sql_hash, rows_affected, insert_id from _vt.idempotency_keys where idempotency_key = %s lock in share mode", encodeIdempotencyKey(key))
+	qr, err := conn.Exec(ctx, query, 1, false)
+	if err != nil {
+		panic(err)
+	}
+	if len(qr.Rows) != 1 || len(qr.Rows[0]) != 3 {
+		panic(NewTabletError(ErrFail, "invalid rows for idempotency key %q: %v", key, qr.Rows))
+	}
+	if qr.Rows[0][0].String() != sqlHash {
+		panic(NewTabletError(ErrFail, "idempotency key %q was used for another statement", key))
+	}
+	rowsAffected, err := qr.Rows[0][1].ParseUint64()
+	if err != nil {
+		panic(NewTabletError(ErrFail, "invalid rows_affected for idempotency key %q: %v", key, err))
+	}
+	insertID, err := qr.Rows[0][2].ParseUint64()
+	if err != nil {
+		panic(NewTabletError(ErrFail, "invalid insert_id for idempotency key %q: %v", key, err))
+	}
+	ik.counts.Add("Replayed", 1)
+	return &mproto.QueryResult{
+		RowsAffected: rowsAffected,
+		InsertId:     insertID,
+	}
+}
+
+// record saves the result of the write with the key claimed before
+// it, in its transaction.
+func (ik *idempotencyKeys) record(ctx context.Context, conn poolConn, key string, result *mproto.QueryResult) {
+	query := fmt.Sprintf(
+		"update _vt.idempotency_keys set rows_affected = %d, insert_id = %d where idempotency_key = %s",
+		result.RowsAffected,
+		result.InsertId,
+		encodeIdempotencyKey(key),
+	)
+	if _, err := conn.Exec(ctx, query, 0, false); err != nil {
+		panic(err)
+	}
+	ik.counts.Add("Recorded", 1)
+}
+
+// release forgets the key claimed by a write that failed.
+func (ik *idempotencyKeys) release(ctx context.Context, conn poolConn, key string) {
+	query := fmt.Sprintf("delete from _vt.idempotency_keys where idempotency_key = %s", encodeIdempotencyKey(key))
+	if _, err := conn.Exec(ctx, query, 0, false); err != nil {
+		internalErrors.Add("IdempotencyKeys", 1)
+		mlog.Errorf("could not release idempotency key %q: %v", key, err)
+	}
+}
+
+// createTable creates the idempotency keys table if needed. It uses
+// its own connection, the DDLs would commit the transaction of the
+// write.
+func (ik *idempotencyKeys) createTable(ctx context.Context) {
+	ik.mu.Lock()
+	tableCreated := ik.tableCreated
+	ik.mu.Unlock()
+	if tableCreated {
+		return
+	}
+
+	conn := getOrPanic(ctx, ik.qe.connPool)
+	defer conn.Recycle()
+	for _, query := range idempotencyCreateQueries {
+		if _, err := conn.Exec(ctx, query, 0, false); err != nil {
+			panic(NewTabletErrorSql(ErrFail, err))
+		}
+	}
+	ik.mu.Lock()
+	ik.tableCreated = true
+	ik.mu.Unlock()
+}
+
+// run purges the keys older than ttl, one batch at a time.
+func (ik *idempotencyKeys) run() {
+	defer func() {
+		if x := recover(); x != nil {
+			internalErrors.Add("IdempotencyKeys", 1)
+			mlog.Errorf("idempotency keys error: %v", x)
+		}
+	}()
+	if ik.isMaster.Get() == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	ik.createTable(ctx)
+	cutoff := ik.now().Add(-ik.ttl).UnixNano()
+	query := fmt.Sprintf("delete from _vt.idempotency_keys where time_created_ns < %d limit %d", cutoff, idempotencyPurgeBatchSize)
+	for ik.isMaster.Get() != 0 {
+		count, err := ik.deleteBatch(ctx, query)
+		if err != nil {
+			internalErrors.Add("IdempotencyKeys", 1)
+			mlog.Errorf("could not purge the old idempotency keys: %v", err)
+			return
+		}
+		if count < idempotencyPurgeBatchSize {
+			return
+		}
+	}
+}
+
+func (ik *idempotencyKeys) deleteBatch(ctx context.Context, query string) (int, error) {
+	conn := getOrPanic(ctx, ik.qe.connPool)
+	defer conn.Recycle()
+
+	qr, err := conn.Exec(ctx, query, idempotencyPurgeBatchSize, false)
+	if err != nil {
+		return 0, NewTabletErrorSql(ErrFail, err)
+	}
+	ik.counts.Add("Purged", int64(qr.RowsAffected))
+	return int(qr.RowsAffected), nil
+}
+
+func encodeIdempotencyKey(key string) string {
+	return encodeValues([]sqltypes.Value{sqltypes.MakeString([]byte(key))})
+}
+
+// idempotencySQLHash returns the hash of a statement and its bind
+// variables, stored with its idempotency key. The trailing comment is
+// left out, it may change when the statement is retried, and so are
+// the internal bind variables that start with '#'.
+func idempotencySQLHash(sql string, bindVars map[string]interface{}) string {
+	names := make([]string, 0, len(bindVars))
+	for name := range bindVars {
+		if name != TRAILING_COMMENT && !strings.HasPrefix(name, "#") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", sql)
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%#v\n", name, bindVars[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,135 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/mysql"
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqldb"
+	"github.com/youtube/vitess/go/sqltypes"
+	"golang.org/x/net/context"
+)
+
+const idempotencyReadQuery = "select sql_hash, rows_affected, insert_id from _vt.idempotency_keys where idempotency_key = 'key1' lock in share mode"
+
+func TestIdempotencyKey(t *testing.T) {
+	db := setUpQueryExecutorTest()
+	for _, query := range idempotencyCreateQueries {
+		db.AddQuery(query, &mproto.QueryResult{})
+	}
+	query := "update test_table set name = 2 where pk in (1) /* _stream test_table (pk ) (1 ); */"
+	// fakesqldb returns RowsAffected rows.
+	db.AddQuery(query, &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows:         [][]sqltypes.Value{nil},
+	})
+
+	qre, sqlQuery := newTestQueryExecutor(query, context.Background(), enableRowCache|enableTx|enableStrict)
+	defer sqlQuery.disallowQueries()
+	defer testCommitHelper(t, sqlQuery, qre)
+	ik := sqlQuery.qe.idempotency
+	ik.now = func() time.Time { return time.Unix(0, 1000) }
+	qre.idempotencyKey = "key1"
+	sqlHash := idempotencySQLHash(qre.query, qre.bindVars)
+	claimQuery := fmt.Sprintf("insert into _vt.idempotency_keys (idempotency_key, sql_hash, rows_affected, insert_id, time_created_ns) values ('key1', '%s', 0, 0, 1000)", sqlHash)
+	recordQuery := "update _vt.idempotency_keys set rows_affected = 1, insert_id = 0 where idempotency_key = 'key1'"
+	db.AddQuery(claimQuery, &mproto.QueryResult{})
+	db.AddQuery(recordQuery, &mproto.QueryResult{})
+	want := &mproto.QueryResult{RowsAffected: 1}
+	checkEqual(t, want, qre.Execute())
+
+	// The retry returns the recorded result, without running the
+	// DML again.
+	db.AddRejectedQuery(query)
+	db.AddRejectedQuery(recordQuery)
+	db.AddQueryError(claimQuery, &sqldb.SqlError{Num: mysql.ErrDupEntry, Message: "Duplicate entry 'key1' for key 'PRIMARY'"})
+	db.AddQuery(idempotencyReadQuery, &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows:         [][]sqltypes.Value{{sqltypes.MakeString([]byte(sqlHash)), sqltypes.MakeNumeric([]byte("1")), sqltypes.MakeNumeric([]byte("0"))}},
+	})
+	checkEqual(t, want, qre.Execute())
+
+	if got, want := ik.counts.Counts(), map[string]int64{"Recorded": 1, "Replayed": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("counts: %v, want %v", got, want)
+	}
+
+	// The key can't be reused for another statement.
+	qre.bindVars["other"] = int64(1)
+	db.AddQueryError(fmt.Sprintf("insert into _vt.idempotency_keys (idempotency_key, sql_hash, rows_affected, insert_id, time_created_ns) values ('key1', '%s', 0, 0, 1000)", idempotencySQLHash(qre.query, qre.bindVars)), &sqldb.SqlError{Num: mysql.ErrDupEntry, Message: "Duplicate entry 'key1' for key 'PRIMARY'"})
+	func() {
+		defer handleAndVerifyTabletError(t, "reusing an idempotency key for another statement should fail", ErrFail)
+		qre.Execute()
+	}()
+}
+
+func TestIdempotencyKeyFailedWrite(t *testing.T) {
+	db := setUpQueryExecutorTest()
+	for _, query := range idempotencyCreateQueries {
+		db.AddQuery(query, &mproto.QueryResult{})
+	}
+	query := "update test_table set name = 2 where pk in (1) /* _stream test_table (pk ) (1 ); */"
+	db.AddRejectedQuery(query)
+	// fakesqldb can't tell which queries ran: make the release fail,
+	// so we see it was tried.
+	db.AddRejectedQuery("delete from _vt.idempotency_keys where idempotency_key = 'key1'")
+
+	qre, sqlQuery := newTestQueryExecutor(query, context.Background(), enableRowCache|enableTx|enableStrict)
+	defer sqlQuery.disallowQueries()
+	defer testCommitHelper(t, sqlQuery, qre)
+	qre.idempotencyKey = "key1"
+
+	// The transaction may still be committed after the write failed,
+	// so its key is released.
+	before := internalErrors.Counts()["IdempotencyKeys"]
+	func() {
+		defer handleAndVerifyTabletError(t, "a failed write should fail", ErrFail)
+		qre.Execute()
+	}()
+	if got := internalErrors.Counts()["IdempotencyKeys"] - before; got != 1 {
+		t.Errorf("the key of the failed write wasn't released")
+	}
+}
+
+func TestIdempotencyKeyNotDML(t *testing.T) {
+	setUpQueryExecutorTest()
+	qre, sqlQuery := newTestQueryExecutor("select * from test_table limit 1000", context.Background(), enableTx)
+	defer sqlQuery.disallowQueries()
+	defer testCommitHelper(t, sqlQuery, qre)
+	qre.idempotencyKey = "key1"
+	defer handleAndVerifyTabletError(t, "an idempotency key on a select should fail", ErrFail)
+	qre.Execute()
+}
+
+func TestIdempotencyKeyPurge(t *testing.T) {
+	db := setUpQueryExecutorTest()
+	for _, query := range idempotencyCreateQueries {
+		db.AddQuery(query, &mproto.QueryResult{})
+	}
+	db.AddQuery("delete from _vt.idempotency_keys where time_created_ns < 6400000000000 limit 500", &mproto.QueryResult{
+		RowsAffected: 3,
+		Rows:         make([][]sqltypes.Value, 3),
+	})
+
+	_, sqlQuery := newTestQueryExecutor("select * from test_table limit 1000", context.Background(), noFlags)
+	defer sqlQuery.disallowQueries()
+	ik := sqlQuery.qe.idempotency
+	ik.now = func() time.Time { return time.Unix(10000, 0) }
+
+	// Only the master purges the keys.
+	ik.run()
+	if got := ik.counts.Counts()["Purged"]; got != 0 {
+		t.Errorf("purged %v keys on a replica, want 0", got)
+	}
+	ik.SetIsMaster(true)
+	ik.run()
+	if got := ik.counts.Counts()["Purged"]; got != 3 {
+		t.Errorf("purged %v keys, want 3", got)
+	}
+}
@@ -0,0 +1,388 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/youtube/vitess/go/acl"
+	"golang.org/x/net/context"
+)
+
+// lockWaitsQuery returns the InnoDB transactions waiting for a lock,
+// and the transactions holding it.
+const lockWaitsQuery = "select r.trx_mysql_thread_id, r.trx_query, unix_timestamp(now()) - unix_timestamp(r.trx_wait_started), " +
+	"b.trx_mysql_thread_id, b.trx_query, l.lock_table, l.lock_index, l.lock_mode " +
+	"from information_schema.innodb_lock_waits w " +
+	"join information_schema.innodb_trx r on r.trx_id = w.requesting_trx_id " +
+	"join information_schema.innodb_trx b on b.trx_id = w.blocking_trx_id " +
+	"join information_schema.innodb_locks l on l.lock_id = w.requested_lock_id"
+
+// maxRecentDeadlocks is how many deadlocks are kept by recentDeadlocks.
+const maxRecentDeadlocks = 10
+
+// recentDeadlocks are the last deadlocks reported by InnoDB. As InnoDB
+// only reports its latest deadlock, it is read in the background every
+// -queryserver-config-deadlock-interval, and the ones that happen
+// between two reads are missed.
+var recentDeadlocks = &deadlockHistory{}
+
+// LockTransaction is a MySQL transaction involved in a lock wait or a
+// deadlock, with the Vitess transaction it belongs to, if any.
+type LockTransaction struct {
+	// ThreadID is the MySQL connection of the transaction.
+	ThreadID int64
+
+	// Query is the query the transaction is running, if any, and
+	// Fingerprint its fingerprint, as in the query digests.
+	Query       string
+	Fingerprint string
+
+	// TransactionID is the Vitess transaction running on the MySQL
+	// connection, or 0 if it isn't one (it may have finished since).
+	TransactionID int64
+}
+
+// LockWait is a transaction waiting for a lock held by another one.
+type LockWait struct {
+	Waiting  LockTransaction
+	Blocking LockTransaction
+
+	// WaitTime is how long the transaction has been waiting.
+	WaitTime time.Duration
+
+	// Table, Index and Mode describe the lock.
+	Table string
+	Index string
+	Mode  string
+}
+
+// DeadlockTransaction is a transaction of a deadlock.
+type DeadlockTransaction struct {
+	LockTransaction
+
+	// Holds and WaitsFor describe the locks the transaction held
+	// and the one it waited for, as reported by InnoDB.
+	Holds    string
+	WaitsFor string
+
+	// RolledBack is true for the transaction InnoDB rolled back.
+	RolledBack bool
+}
+
+// Deadlock is a deadlock detected by InnoDB.
+type Deadlock struct {
+	// Time is when it happened, as reported by InnoDB.
+	Time         string
+	Transactions []*DeadlockTransaction
+}
+
+// LockStatus is the current lock waits, and the recent deadlocks,
+// most recent first.
+type LockStatus struct {
+	LockWaits []*LockWait
+	Deadlocks []*Deadlock
+}
+
+// deadlockHistory keeps the last maxRecentDeadlocks deadlocks.
+type deadlockHistory struct {
+	mu        sync.Mutex
+	deadlocks []*Deadlock
+}
+
+// add records a deadlock, unless it is the same as the last one.
+func (dh *deadlockHistory) add(deadlock *Deadlock) {
+	dh.mu.Lock()
+	defer dh.mu.Unlock()
+	if len(dh.deadlocks) > 0 && dh.deadlocks[0].Time == deadlock.Time {
+		return
+	}
+	dh.deadlocks = append([]*Deadlock{deadlock}, dh.deadlocks...)
+	if len(dh.deadlocks) > maxRecentDeadlocks {
+		dh.deadlocks = dh.deadlocks[:maxRecentDeadlocks]
+	}
+}
+
+func (dh *deadlockHistory) get() []*Deadlock {
+	dh.mu.Lock()
+	defer dh.mu.Unlock()
+	return append([]*Deadlock(nil), dh.deadlocks...)
+}
+
+// LockStatus returns the transactions waiting for locks, and the
+// recent deadlocks, with the Vitess transactions involved. The MySQL
+// user of the application needs the PROCESS privilege.
+func (qe *QueryEngine) LockStatus(ctx context.Context) (*LockStatus, error) {
+	conn, err := qe.connPool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Recycle()
+
+	lts := qe.newLockTransactions()
+	qr, err := conn.Exec(ctx, lockWaitsQuery, 10000, false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the lock waits: %v", err)
+	}
+	status := &LockStatus{}
+	for _, row := range qr.Rows {
+		if len(row) != 8 {
+			return nil, fmt.Errorf("unexpected lock wait row: %v", row)
+		}
+		waitingID, err := strconv.ParseInt(row[0].String(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid thread id %v: %v", row[0], err)
+		}
+		blockingID, err := strconv.ParseInt(row[3].String(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid thread id %v: %v", row[3], err)
+		}
+		seconds, _ := strconv.ParseInt(row[2].String(), 10, 64)
+		table := row[5].String()
+		status.LockWaits = append(status.LockWaits, &LockWait{
+			Waiting:  lts.get(waitingID, row[1].String(), table),
+			Blocking: lts.get(blockingID, row[4].String(), table),
+			WaitTime: time.Duration(seconds) * time.Second,
+			Table:    table,
+			Index:    row[6].String(),
+			Mode:     row[7].String(),
+		})
+	}
+
+	if err := recordLatestDeadlock(ctx, conn, lts); err != nil {
+		return nil, err
+	}
+	status.Deadlocks = recentDeadlocks.get()
+	return status, nil
+}
+
+// pollDeadlocks records the latest deadlock, so the ones that happen
+// when /debug/lockz isn't loaded are kept too. It is run by
+// deadlockTicks.
+func (qe *QueryEngine) pollDeadlocks() {
+	defer func() {
+		if x := recover(); x != nil {
+			internalErrors.Add("Deadlocks", 1)
+			mlog.Errorf("deadlock poll error: %v", x)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := qe.connPool.Get(ctx)
+	if err != nil {
+		qe.deadlockLogger.Warningf("cannot read the latest deadlock: %v", err)
+		return
+	}
+	defer conn.Recycle()
+	if err := recordLatestDeadlock(ctx, conn, qe.newLockTransactions()); err != nil {
+		qe.deadlockLogger.Warningf("%v", err)
+	}
+}
+
+// recordLatestDeadlock adds the latest deadlock reported by InnoDB to
+// recentDeadlocks.
+func recordLatestDeadlock(ctx context.Context, conn *DBConn, lts *lockTransactions) error {
+	qr, err := conn.Exec(ctx, "show engine innodb status", 1, false)
+	if err != nil {
+		return fmt.Errorf("cannot read the InnoDB status: %v", err)
+	}
+	if len(qr.Rows) != 1 || len(qr.Rows[0]) != 3 {
+		return fmt.Errorf("unexpected InnoDB status: %v", qr.Rows)
+	}
+	if deadlock := parseLatestDeadlock(qr.Rows[0][2].String()); deadlock != nil {
+		for _, dt := range deadlock.Transactions {
+			dt.LockTransaction = lts.get(dt.ThreadID, dt.Query, dt.Holds, dt.WaitsFor)
+		}
+		recentDeadlocks.add(deadlock)
+	}
+	return nil
+}
+
+// lockTransactions builds the LockTransactions of the lock waits and
+// deadlocks.
+type lockTransactions struct {
+	// transactions are the Vitess transactions, by MySQL
+	// connection.
+	transactions map[int64]int64
+	// sensitive are the names of the sensitive tables.
+	sensitive map[string]bool
+}
+
+func (qe *QueryEngine) newLockTransactions() *lockTransactions {
+	lts := &lockTransactions{
+		transactions: make(map[int64]int64),
+		sensitive:    qe.schemaInfo.GetSensitiveTables(),
+	}
+	for _, v := range qe.txPool.activePool.GetAll() {
+		txc := v.(*TxConnection)
+		lts.transactions[txc.ID()] = txc.TransactionID
+	}
+	return lts
+}
+
+// get returns the LockTransaction of a MySQL connection. As in the
+// query log, the query is redacted if it uses a sensitive table, or
+// if one of the locks, described by InnoDB, is on one. Its
+// fingerprint has no values and is kept.
+func (lts *lockTransactions) get(threadID int64, query string, locks ...string) LockTransaction {
+	lt := LockTransaction{
+		ThreadID:      threadID,
+		Query:         query,
+		TransactionID: lts.transactions[threadID],
+	}
+	if query == "" {
+		return lt
+	}
+	lt.Fingerprint = queryFingerprint(query)
+	for _, text := range append(locks, query) {
+		if usesTable(text, lts.sensitive) {
+			lt.Query = "[REDACTED]"
+			break
+		}
+	}
+	return lt
+}
+
+// usesTable returns true if one of the identifiers of text is one of
+// the tables. The identifiers are split on the dots, so the tables
+// qualified by their database are found. A table name in a string
+// literal is a false positive, which only redacts too much.
+func usesTable(text string, tables map[string]bool) bool {
+	if len(tables) == 0 {
+		return false
+	}
+	start := -1
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && isIdentifierChar(text[i]) {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start != -1 && tables[text[start:i]] {
+			return true
+		}
+		start = -1
+	}
+	return false
+}
+
+// parseLatestDeadlock parses the LATEST DETECTED DEADLOCK section of
+// the output of 'show engine innodb status'. It returns nil if there
+// is none.
+func parseLatestDeadlock(innodbStatus string) *Deadlock {
+	lines := strings.Split(innodbStatus, "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "LATEST DETECTED DEADLOCK" {
+			// skip the dashes below the title
+			start = i + 2
+			break
+		}
+	}
+	if start == -1 || start >= len(lines) {
+		return nil
+	}
+
+	deadlock := &Deadlock{}
+	var dt *DeadlockTransaction
+	// next is the field the following line goes to.
+	var next *string
+	inQuery := false
+	for _, line := range lines[start:] {
+		if isDashLine(line) {
+			// next section
+			break
+		}
+		switch {
+		case deadlock.Time == "":
+			// The first line is the time, followed by the
+			// thread that detected the deadlock.
+			fields := strings.Fields(line)
+			if len(fields) >= 2 {
+				deadlock.Time = fields[0] + " " + fields[1]
+			}
+		case strings.HasPrefix(line, "*** WE ROLL BACK TRANSACTION ("):
+			index, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, "*** WE ROLL BACK TRANSACTION ("), ")"))
+			if err == nil && index >= 1 && index <= len(deadlock.Transactions) {
+				deadlock.Transactions[index-1].RolledBack = true
+			}
+			inQuery = false
+		case strings.HasPrefix(line, "*** ") && strings.HasSuffix(line, " TRANSACTION:"):
+			dt = &DeadlockTransaction{}
+			deadlock.Transactions = append(deadlock.Transactions, dt)
+			inQuery = false
+		case dt == nil:
+		case strings.HasSuffix(line, " HOLDS THE LOCK(S):"):
+			next = &dt.Holds
+			inQuery = false
+		case strings.HasSuffix(line, " WAITING FOR THIS LOCK TO BE GRANTED:"):
+			next = &dt.WaitsFor
+			inQuery = false
+		case next != nil:
+			*next = line
+			next = nil
+		case strings.HasPrefix(line, "MySQL thread id "):
+			// The query follows this line.
+			id := strings.TrimPrefix(line, "MySQL thread id ")
+			if i := strings.Index(id, ","); i != -1 {
+				id = id[:i]
+			}
+			dt.ThreadID, _ = strconv.ParseInt(id, 10, 64)
+			inQuery = true
+		case inQuery:
+			if dt.Query != "" {
+				dt.Query += "\n"
+			}
+			dt.Query += line
+		}
+	}
+	if deadlock.Time == "" {
+		return nil
+	}
+	return deadlock
+}
+
+// isDashLine returns true for the lines of dashes around the titles
+// of the sections of the InnoDB status.
+func isDashLine(line string) bool {
+	line = strings.TrimSpace(line)
+	return line != "" && strings.Trim(line, "-") == ""
+}
+
+// registerLockzHandler serves the lock waits and the recent deadlocks
+// as JSON.
+func (rqsc *realQueryServiceControl) registerLockzHandler() {
+	http.HandleFunc("/debug/lockz", func(w http.ResponseWriter, r *http.Request) {
+		if err := acl.CheckAccessHTTP(r, acl.DEBUGGING); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		if !rqsc.IsServing() {
+			http.Error(w, "query service is not serving", http.StatusServiceUnavailable)
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		status, err := rqsc.sqlQueryRPCService.qe.LockStatus(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		b, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write(b)
+	})
+}
@@ -0,0 +1,243 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/tabletserver/fakesqldb"
+	"golang.org/x/net/context"
+)
+
+const testInnodbStatus = `
+=====================================
+2015-06-01 12:35:10 7f2a3c0e4700 INNODB MONITOR OUTPUT
+=====================================
+------------------------
+LATEST DETECTED DEADLOCK
+------------------------
+2015-06-01 12:34:56 7f2a3c0a2700
+*** (1) TRANSACTION:
+TRANSACTION 1234, ACTIVE 3 sec starting index read
+mysql tables in use 1, locked 1
+LOCK WAIT 3 lock struct(s), heap size 360, 2 row lock(s)
+MySQL thread id 12, OS thread handle 0x7f2a3c0a2700, query id 345 localhost vt_app updating
+update test_table set name = 'a'
+ where pk = 2
+*** (1) WAITING FOR THIS LOCK TO BE GRANTED:
+RECORD LOCKS space id 6 page no 3 n bits 72 index ` + "`PRIMARY`" + ` of table ` + "`vt_db`.`test_table`" + ` trx id 1234 lock_mode X locks rec but not gap waiting
+Record lock, heap no 3 PHYSICAL RECORD: n_fields 4; compact format; info bits 0
+ 0: len 4; hex 80000002; asc     ;;
+*** (2) TRANSACTION:
+TRANSACTION 1235, ACTIVE 2 sec starting index read
+MySQL thread id 13, OS thread handle 0x7f2a3c0e4700, query id 346 localhost vt_app updating
+update test_table set name = 'b' where pk = 1
+*** (2) HOLDS THE LOCK(S):
+RECORD LOCKS space id 6 page no 3 n bits 72 index ` + "`PRIMARY`" + ` of table ` + "`vt_db`.`test_table`" + ` trx id 1235 lock_mode X locks rec but not gap
+*** (2) WAITING FOR THIS LOCK TO BE GRANTED:
+RECORD LOCKS space id 6 page no 3 n bits 72 index ` + "`PRIMARY`" + ` of table ` + "`vt_db`.`test_table`" + ` trx id 1235 lock_mode X locks rec but not gap waiting
+*** WE ROLL BACK TRANSACTION (2)
+------------
+TRANSACTIONS
+------------
+Trx id counter 1240
+`
+
+func TestParseLatestDeadlock(t *testing.T) {
+	want := &Deadlock{
+		Time: "2015-06-01 12:34:56",
+		Transactions: []*DeadlockTransaction{
+			{
+				LockTransaction: LockTransaction{
+					ThreadID: 12,
+					Query:    "update test_table set name = 'a'\n where pk = 2",
+				},
+				WaitsFor: "RECORD LOCKS space id 6 page no 3 n bits 72 index `PRIMARY` of table `vt_db`.`test_table` trx id 1234 lock_mode X locks rec but not gap waiting",
+			},
+			{
+				LockTransaction: LockTransaction{
+					ThreadID: 13,
+					Query:    "update test_table set name = 'b' where pk = 1",
+				},
+				Holds:      "RECORD LOCKS space id 6 page no 3 n bits 72 index `PRIMARY` of table `vt_db`.`test_table` trx id 1235 lock_mode X locks rec but not gap",
+				WaitsFor:   "RECORD LOCKS space id 6 page no 3 n bits 72 index `PRIMARY` of table `vt_db`.`test_table` trx id 1235 lock_mode X locks rec but not gap waiting",
+				RolledBack: true,
+			},
+		},
+	}
+	got := parseLatestDeadlock(testInnodbStatus)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLatestDeadlock:\n%+v\nwant:\n%+v", got, want)
+	}
+
+	if got := parseLatestDeadlock("------------\nTRANSACTIONS\n------------\n"); got != nil {
+		t.Errorf("parseLatestDeadlock without a deadlock = %+v", got)
+	}
+}
+
+func TestQueryEngineLockStatus(t *testing.T) {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	db.AddQuery(lockWaitsQuery, &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{
+				sqltypes.MakeString([]byte("12")),
+				sqltypes.MakeString([]byte("update test_table set name = 'a' where pk = 2")),
+				sqltypes.MakeString([]byte("3")),
+				sqltypes.MakeString([]byte("13")),
+				sqltypes.Value{},
+				sqltypes.MakeString([]byte("`vt_db`.`test_table`")),
+				sqltypes.MakeString([]byte("PRIMARY")),
+				sqltypes.MakeString([]byte("X")),
+			},
+		},
+	})
+	db.AddQuery("show engine innodb status", &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{
+				sqltypes.MakeString([]byte("InnoDB")),
+				sqltypes.MakeString([]byte("")),
+				sqltypes.MakeString([]byte(testInnodbStatus)),
+			},
+		},
+	})
+
+	sqlQuery := getSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+
+	status, err := sqlQuery.qe.LockStatus(context.Background())
+	if err != nil {
+		t.Fatalf("LockStatus failed: %v", err)
+	}
+	wantWait := &LockWait{
+		Waiting: LockTransaction{
+			ThreadID:    12,
+			Query:       "update test_table set name = 'a' where pk = 2",
+			Fingerprint: "update test_table set name = ? where pk = ?",
+		},
+		Blocking: LockTransaction{ThreadID: 13},
+		WaitTime: 3 * time.Second,
+		Table:    "`vt_db`.`test_table`",
+		Index:    "PRIMARY",
+		Mode:     "X",
+	}
+	if len(status.LockWaits) != 1 || !reflect.DeepEqual(status.LockWaits[0], wantWait) {
+		t.Errorf("LockStatus lock waits = %+v, want %+v", status.LockWaits, wantWait)
+	}
+	if len(status.Deadlocks) != 1 || len(status.Deadlocks[0].Transactions) != 2 {
+		t.Fatalf("LockStatus deadlocks = %+v, want 1 deadlock of 2 transactions", status.Deadlocks)
+	}
+	if got, want := status.Deadlocks[0].Transactions[1].Fingerprint, "update test_table set name = ? where pk = ?"; got != want {
+		t.Errorf("deadlock transaction fingerprint = %q, want %q", got, want)
+	}
+
+	// The same deadlock is only recorded once.
+	if status, err = sqlQuery.qe.LockStatus(context.Background()); err != nil {
+		t.Fatalf("LockStatus failed: %v", err)
+	}
+	if len(status.Deadlocks) != 1 {
+		t.Errorf("LockStatus returned %v deadlocks, want 1", len(status.Deadlocks))
+	}
+}
+
+func TestQueryEngineLockStatusSensitive(t *testing.T) {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	db.AddQuery(lockWaitsQuery, &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{
+				sqltypes.MakeString([]byte("12")),
+				sqltypes.MakeString([]byte("update test_table set name = 'a' where pk = 2")),
+				sqltypes.MakeString([]byte("3")),
+				sqltypes.MakeString([]byte("13")),
+				sqltypes.MakeString([]byte("select 1 from dual")),
+				sqltypes.MakeString([]byte("`vt_db`.`test_table`")),
+				sqltypes.MakeString([]byte("PRIMARY")),
+				sqltypes.MakeString([]byte("X")),
+			},
+		},
+	})
+	db.AddQuery("show engine innodb status", &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{
+				sqltypes.MakeString([]byte("InnoDB")),
+				sqltypes.MakeString([]byte("")),
+				sqltypes.MakeString([]byte(testInnodbStatus)),
+			},
+		},
+	})
+
+	sqlQuery := getSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{{Name: "test_table", Sensitive: true}}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+
+	// the deadlocks are read in the background too
+	recentDeadlocks = &deadlockHistory{}
+	sqlQuery.qe.pollDeadlocks()
+	deadlocks := recentDeadlocks.get()
+	if len(deadlocks) != 1 || len(deadlocks[0].Transactions) != 2 {
+		t.Fatalf("pollDeadlocks recorded %+v, want 1 deadlock of 2 transactions", deadlocks)
+	}
+	for _, dt := range deadlocks[0].Transactions {
+		if dt.Query != "[REDACTED]" || dt.Fingerprint == "" {
+			t.Errorf("deadlock transaction on a sensitive table: query %q, fingerprint %q, want a redacted query and a fingerprint", dt.Query, dt.Fingerprint)
+		}
+	}
+
+	// the blocking query doesn't use the table, but it holds a
+	// lock on it
+	status, err := sqlQuery.qe.LockStatus(context.Background())
+	if err != nil {
+		t.Fatalf("LockStatus failed: %v", err)
+	}
+	if len(status.LockWaits) != 1 {
+		t.Fatalf("LockStatus lock waits = %+v, want 1", status.LockWaits)
+	}
+	for _, lt := range []LockTransaction{status.LockWaits[0].Waiting, status.LockWaits[0].Blocking} {
+		if lt.Query != "[REDACTED]" {
+			t.Errorf("lock wait transaction on a sensitive table: query %q, want it redacted", lt.Query)
+		}
+	}
+}
+
+func TestUsesTable(t *testing.T) {
+	tables := map[string]bool{"secrets": true}
+	table := []struct {
+		text string
+		want bool
+	}{
+		{"select * from secrets where id = 1", true},
+		{"update `secrets` set a = 1", true},
+		{"insert into vt_db.secrets values (1)", true},
+		{"RECORD LOCKS space id 6 page no 3 n bits 72 index `PRIMARY` of table `vt_db`.`secrets` trx id 1234", true},
+		{"select * from secrets_archive", false},
+		{"select * from t where a = :secrets", false},
+		{"", false},
+	}
+	for _, test := range table {
+		if got := usesTable(test.text, tables); got != test.want {
+			t.Errorf("usesTable(%q) = %v, want %v", test.text, got, test.want)
+		}
+	}
+}
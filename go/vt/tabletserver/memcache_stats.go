@@ -208,7 +208,7 @@ func (memstats *MemcacheStats) publishMainStats() {
 				defer memstats.mu.Unlock()
 				ival, err := strconv.ParseInt(memstats.main[key], 10, 64)
 				if err != nil {
-					log.Errorf("value '%v' for key %v is not an int", memstats.main[key], key)
+					mlog.Errorf("value '%v' for key %v is not an int", memstats.main[key], key)
 					internalErrors.Add("MemcacheStats", 1)
 					return -1
 				}
@@ -237,7 +237,7 @@ func (memstats *MemcacheStats) publishSlabsStats() {
 				return memstats.slabs[key][""]
 			}))
 		} else {
-			stats.Publish(memstats.statsPrefix+"MemcacheSlabs"+formatKey(key), stats.CountersFunc(func() map[string]int64 {
+			stats.Publish(memstats.statsPrefix+"MemcacheSlabs"+formatKey(key), stats.GaugesFunc(func() map[string]int64 {
 				memstats.mu.Lock()
 				defer memstats.mu.Unlock()
 				return copyMap(memstats.slabs[key])
@@ -257,7 +257,7 @@ func (memstats *MemcacheStats) updateSlabsStats() {
 		if slabsSingleMetrics[sKey] {
 			m, ok := memstats.slabs[sKey]
 			if !ok {
-				log.Errorf("Unknown memcache slabs stats %v: %v", sKey, ival)
+				mlog.Errorf("Unknown memcache slabs stats %v: %v", sKey, ival)
 				internalErrors.Add("MemcacheStats", 1)
 				return
 			}
@@ -272,7 +272,7 @@ func (memstats *MemcacheStats) updateSlabsStats() {
 		}
 		m, ok := memstats.slabs[subkey]
 		if !ok {
-			log.Errorf("Unknown memcache slabs stats %v %v: %v", subkey, slabid, ival)
+			mlog.Errorf("Unknown memcache slabs stats %v %v: %v", subkey, slabid, ival)
 			internalErrors.Add("MemcacheStats", 1)
 			return
 		}
@@ -286,7 +286,7 @@ func (memstats *MemcacheStats) publishItemsStats() {
 	for _, key := range itemsMetrics {
 		key := key // create local var to keep current key
 		memstats.items[key] = make(map[string]int64)
-		stats.Publish(memstats.statsPrefix+"MemcacheItems"+formatKey(key), stats.CountersFunc(func() map[string]int64 {
+		stats.Publish(memstats.statsPrefix+"MemcacheItems"+formatKey(key), stats.GaugesFunc(func() map[string]int64 {
 			memstats.mu.Lock()
 			defer memstats.mu.Unlock()
 			return copyMap(memstats.items[key])
@@ -310,7 +310,7 @@ func (memstats *MemcacheStats) updateItemsStats() {
 		}
 		m, ok := memstats.items[subkey]
 		if !ok {
-			log.Errorf("Unknown memcache items stats %v %v: %v", subkey, slabid, ival)
+			mlog.Errorf("Unknown memcache items stats %v %v: %v", subkey, slabid, ival)
 			internalErrors.Add("MemcacheStats", 1)
 			return
 		}
@@ -323,9 +323,9 @@ func (memstats *MemcacheStats) readStats(k string, proc func(key, value string))
 		if x := recover(); x != nil {
 			_, ok := x.(*TabletError)
 			if !ok {
-				log.Errorf("Uncaught panic when reading memcache stats: %v", x)
+				mlog.Errorf("Uncaught panic when reading memcache stats: %v", x)
 			} else {
-				log.Errorf("Could not read memcache stats: %v", x)
+				mlog.Errorf("Could not read memcache stats: %v", x)
 			}
 			internalErrors.Add("MemcacheStats", 1)
 		}
@@ -347,7 +347,7 @@ func (memstats *MemcacheStats) readStats(k string, proc func(key, value string))
 		//if using apt-get, memcached info would be:STAT version 1.4.14 (Ubuntu)
 		//so less then 3 would be compatible with original memcached
 		if len(items) < 3 {
-			log.Errorf("Unexpected stats: %v", line)
+			mlog.Errorf("Unexpected stats: %v", line)
 			internalErrors.Add("MemcacheStats", 1)
 			continue
 		}
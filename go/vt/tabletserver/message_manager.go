@@ -0,0 +1,393 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/sync2"
+	"github.com/youtube/vitess/go/timer"
+	"golang.org/x/net/context"
+)
+
+// messageColumns are the columns a message table must have.
+// id is the primary key. time_next is the time in nanoseconds at
+// which the message is due to be sent, and should be indexed.
+// epoch is the number of times the message was sent. time_acked
+// is the time the message was acked, null until then.
+var messageColumns = []string{"id", "time_next", "epoch", "time_acked", "message"}
+
+// messageReceiver is a client streaming the messages of a table.
+type messageReceiver struct {
+	mu   sync.Mutex
+	send func(*mproto.QueryResult) error
+	done chan struct{}
+	err  error
+}
+
+func newMessageReceiver(send func(*mproto.QueryResult) error) *messageReceiver {
+	return &messageReceiver{
+		send: send,
+		done: make(chan struct{}),
+	}
+}
+
+// deliver sends the messages to the receiver. If the send fails,
+// the receiver is closed with the error.
+func (rcv *messageReceiver) deliver(qr *mproto.QueryResult) error {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	if rcv.send == nil {
+		return rcv.err
+	}
+	if err := rcv.send(qr); err != nil {
+		rcv.closeLocked(err)
+		return err
+	}
+	return nil
+}
+
+// close closes the receiver. Once it returns, no more
+// messages will be sent to it.
+func (rcv *messageReceiver) close(err error) {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	rcv.closeLocked(err)
+}
+
+func (rcv *messageReceiver) closeLocked(err error) {
+	if rcv.send == nil {
+		return
+	}
+	rcv.send = nil
+	rcv.err = err
+	close(rcv.done)
+}
+
+// messageManager delivers the rows of the message tables to the
+// clients streaming them. Every poll interval, it reads the messages
+// that are due, pushes their time_next out by the ack wait time, and
+// sends them to one of the receivers of the table. Messages that are
+// not acked by then are sent again. Acked messages are purged once
+// they are older than purgeAfter. Only the master serves the
+// messages, all of them are writes.
+type messageManager struct {
+	qe         *QueryEngine
+	ackWait    time.Duration
+	purgeAfter time.Duration
+	batchSize  int
+	ticks      *timer.Timer
+	now        func() time.Time
+
+	isMaster sync2.AtomicInt32
+
+	mu        sync.Mutex
+	isOpen    bool
+	receivers map[string][]*messageReceiver
+	// next is the index of the receiver that gets
+	// the next batch of a table.
+	next map[string]int
+}
+
+func newMessageManager(qe *QueryEngine, pollInterval, ackWait, purgeAfter time.Duration, batchSize int) *messageManager {
+	return &messageManager{
+		qe:         qe,
+		ackWait:    ackWait,
+		purgeAfter: purgeAfter,
+		batchSize:  batchSize,
+		ticks:      timer.NewTimer(pollInterval),
+		now:        time.Now,
+		receivers:  make(map[string][]*messageReceiver),
+		next:       make(map[string]int),
+	}
+}
+
+// Open starts delivering messages.
+func (mm *messageManager) Open() {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	if mm.isOpen {
+		return
+	}
+	mm.isOpen = true
+	mm.ticks.Start(func() { mm.poll() })
+}
+
+// Close closes all receivers and stops delivering messages.
+func (mm *messageManager) Close() {
+	mm.mu.Lock()
+	if !mm.isOpen {
+		mm.mu.Unlock()
+		return
+	}
+	mm.isOpen = false
+	for name, rcvs := range mm.receivers {
+		for _, rcv := range rcvs {
+			rcv.close(NewTabletError(ErrRetry, "message stream for %s closed: query service is shutting down", name))
+		}
+	}
+	mm.receivers = make(map[string][]*messageReceiver)
+	mm.next = make(map[string]int)
+	mm.mu.Unlock()
+	mm.ticks.Stop()
+}
+
+// SetIsMaster starts delivering messages if isMaster is true. If
+// it's false, the receivers are closed, so the clients go to the
+// new master.
+func (mm *messageManager) SetIsMaster(isMaster bool) {
+	if isMaster {
+		mm.isMaster.Set(1)
+		return
+	}
+	mm.isMaster.Set(0)
+
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	for name, rcvs := range mm.receivers {
+		for _, rcv := range rcvs {
+			rcv.close(NewTabletError(ErrRetry, "message stream for %s closed: tablet is not the master anymore", name))
+		}
+	}
+	mm.receivers = make(map[string][]*messageReceiver)
+	mm.next = make(map[string]int)
+}
+
+// checkMaster returns an error if the tablet is not the master.
+func (mm *messageManager) checkMaster() error {
+	if mm.isMaster.Get() == 0 {
+		return NewTabletError(ErrRetry, "messages are only served by the master")
+	}
+	return nil
+}
+
+// subscribe adds a receiver for the messages of the table.
+func (mm *messageManager) subscribe(name string, send func(*mproto.QueryResult) error) (*messageReceiver, error) {
+	if err := mm.checkMaster(); err != nil {
+		return nil, err
+	}
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	if !mm.isOpen {
+		return nil, NewTabletError(ErrRetry, "message manager is not open")
+	}
+	rcv := newMessageReceiver(send)
+	mm.receivers[name] = append(mm.receivers[name], rcv)
+	return rcv, nil
+}
+
+// unsubscribe closes the receiver and removes it from the table.
+func (mm *messageManager) unsubscribe(name string, rcv *messageReceiver) {
+	rcv.close(nil)
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	rcvs := mm.receivers[name]
+	for i, r := range rcvs {
+		if r == rcv {
+			rcvs = append(rcvs[:i], rcvs[i+1:]...)
+			break
+		}
+	}
+	if len(rcvs) == 0 {
+		delete(mm.receivers, name)
+		delete(mm.next, name)
+		return
+	}
+	mm.receivers[name] = rcvs
+}
+
+// nextReceivers returns the receivers of the table, starting
+// with the one whose turn it is.
+func (mm *messageManager) nextReceivers(name string) []*messageReceiver {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	rcvs := mm.receivers[name]
+	if len(rcvs) == 0 {
+		return nil
+	}
+	start := mm.next[name] % len(rcvs)
+	mm.next[name] = start + 1
+	ordered := make([]*messageReceiver, 0, len(rcvs))
+	ordered = append(ordered, rcvs[start:]...)
+	return append(ordered, rcvs[:start]...)
+}
+
+func (mm *messageManager) subscribedTables() []string {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	names := make([]string, 0, len(mm.receivers))
+	for name := range mm.receivers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// poll delivers the due messages of the tables that have
+// receivers, and purges the acked messages of all tables.
+func (mm *messageManager) poll() {
+	defer func() {
+		if x := recover(); x != nil {
+			internalErrors.Add("Messages", 1)
+			mlog.Errorf("message manager error: %v", x)
+		}
+	}()
+	if mm.isMaster.Get() == 0 {
+		return
+	}
+	ctx := context.Background()
+	for _, name := range mm.subscribedTables() {
+		if err := mm.deliver(ctx, name); err != nil {
+			internalErrors.Add("Messages", 1)
+			mlog.Errorf("could not deliver messages for %s: %v", name, err)
+		}
+	}
+	for _, name := range mm.qe.schemaInfo.GetMessageTables() {
+		if err := mm.purge(ctx, name); err != nil {
+			internalErrors.Add("Messages", 1)
+			mlog.Errorf("could not purge messages for %s: %v", name, err)
+		}
+	}
+}
+
+// deliver sends the due messages of the table to one of its
+// receivers. If the send fails, the next receiver is tried. If
+// all of them fail, the messages are sent again after ackWait.
+func (mm *messageManager) deliver(ctx context.Context, name string) error {
+	txid := mm.qe.txPool.Begin(ctx)
+	qr, err := mm.postpone(ctx, txid, name)
+	if err != nil {
+		mm.qe.txPool.Rollback(ctx, txid)
+		return err
+	}
+	if _, err := mm.qe.txPool.SafeCommit(ctx, txid); err != nil {
+		return err
+	}
+	if len(qr.Rows) == 0 {
+		return nil
+	}
+	for _, rcv := range mm.nextReceivers(name) {
+		if err := rcv.deliver(qr); err == nil {
+			return nil
+		}
+		mm.unsubscribe(name, rcv)
+	}
+	return nil
+}
+
+// postpone reads the due messages of the table and pushes their
+// time_next out by ackWait.
+func (mm *messageManager) postpone(ctx context.Context, txid int64, name string) (*mproto.QueryResult, error) {
+	conn := mm.qe.txPool.Get(txid)
+	defer conn.Recycle()
+
+	now := mm.now().UnixNano()
+	query := fmt.Sprintf(
+		"select id, epoch, message from `%s` where time_next <= %d and time_acked is null order by time_next limit %d for update",
+		name, now, mm.batchSize)
+	qr, err := conn.Exec(ctx, query, mm.batchSize, false)
+	if err != nil {
+		return nil, NewTabletErrorSql(ErrFail, err)
+	}
+	if len(qr.Rows) == 0 {
+		return qr, nil
+	}
+	ids := make([]sqltypes.Value, 0, len(qr.Rows))
+	for _, row := range qr.Rows {
+		ids = append(ids, row[0])
+	}
+	query = fmt.Sprintf(
+		"update `%s` set time_next = %d, epoch = epoch+1 where id in (%s)",
+		name, now+mm.ackWait.Nanoseconds(), encodeValues(ids))
+	if _, err := conn.Exec(ctx, query, len(ids), false); err != nil {
+		return nil, NewTabletErrorSql(ErrFail, err)
+	}
+	return qr, nil
+}
+
+// purge deletes the messages of the table that were acked
+// more than purgeAfter ago.
+func (mm *messageManager) purge(ctx context.Context, name string) error {
+	conn := getOrPanic(ctx, mm.qe.connPool)
+	defer conn.Recycle()
+
+	cutoff := mm.now().Add(-mm.purgeAfter).UnixNano()
+	query := fmt.Sprintf("delete from `%s` where time_acked < %d limit %d", name, cutoff, mm.batchSize)
+	if _, err := conn.Exec(ctx, query, mm.batchSize, false); err != nil {
+		return NewTabletErrorSql(ErrFail, err)
+	}
+	return nil
+}
+
+// ack marks the messages of the table as acked, so they're not
+// sent again. It returns the number of messages that were acked.
+func (mm *messageManager) ack(ctx context.Context, name string, ids []interface{}) (int64, error) {
+	if err := mm.checkMaster(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	values := make([]sqltypes.Value, 0, len(ids))
+	for _, id := range ids {
+		v, err := sqltypes.BuildValue(id)
+		if err != nil {
+			return 0, NewTabletError(ErrFail, "invalid message id %v: %v", id, err)
+		}
+		values = append(values, v)
+	}
+	txid := mm.qe.txPool.Begin(ctx)
+	count, err := mm.markAcked(ctx, txid, name, values)
+	if err != nil {
+		mm.qe.txPool.Rollback(ctx, txid)
+		return 0, err
+	}
+	if _, err := mm.qe.txPool.SafeCommit(ctx, txid); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (mm *messageManager) markAcked(ctx context.Context, txid int64, name string, ids []sqltypes.Value) (int64, error) {
+	conn := mm.qe.txPool.Get(txid)
+	defer conn.Recycle()
+
+	query := fmt.Sprintf(
+		"update `%s` set time_acked = %d, time_next = null where id in (%s) and time_acked is null",
+		name, mm.now().UnixNano(), encodeValues(ids))
+	qr, err := conn.Exec(ctx, query, len(ids), false)
+	if err != nil {
+		return 0, NewTabletErrorSql(ErrFail, err)
+	}
+	return int64(qr.RowsAffected), nil
+}
+
+// fields returns the fields of the messages of the table.
+func (mm *messageManager) fields(ctx context.Context, name string) ([]mproto.Field, error) {
+	conn := getOrPanic(ctx, mm.qe.connPool)
+	defer conn.Recycle()
+
+	query := fmt.Sprintf("select id, epoch, message from `%s` where 1 != 1", name)
+	qr, err := conn.Exec(ctx, query, 1, true)
+	if err != nil {
+		return nil, NewTabletErrorSql(ErrFail, err)
+	}
+	return qr.Fields, nil
+}
+
+func encodeValues(values []sqltypes.Value) string {
+	buf := &bytes.Buffer{}
+	for i, v := range values {
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		v.EncodeSql(buf)
+	}
+	return buf.String()
+}
@@ -0,0 +1,331 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/tabletserver/fakesqldb"
+	"github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"golang.org/x/net/context"
+)
+
+func TestMessageStreamAndAck(t *testing.T) {
+	db := setUpMessageTest()
+	sqlQuery := getMessageSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	mm := sqlQuery.qe.messager
+	mm.SetIsMaster(true)
+	mm.now = func() time.Time { return time.Unix(0, 1000) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan *mproto.QueryResult, 10)
+	streamErr := make(chan error, 1)
+	go func() {
+		req := &proto.MessageStreamRequest{Name: "msg", SessionId: sqlQuery.sessionID}
+		streamErr <- sqlQuery.MessageStream(ctx, req, func(qr *mproto.QueryResult) error {
+			results <- qr
+			return nil
+		})
+	}()
+	qr := <-results
+	if !reflect.DeepEqual(qr.Fields, messageFields) {
+		t.Errorf("MessageStream fields: %v, want %v", qr.Fields, messageFields)
+	}
+	waitForReceivers(t, mm, 1)
+
+	messages := &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{
+				sqltypes.MakeNumeric([]byte("1")),
+				sqltypes.MakeNumeric([]byte("0")),
+				sqltypes.MakeString([]byte("hello")),
+			},
+		},
+	}
+	db.AddQuery("select id, epoch, message from `msg` where time_next <= 1000 and time_acked is null order by time_next limit 100 for update", messages)
+	mm.poll()
+	qr = <-results
+	if !reflect.DeepEqual(qr.Rows, messages.Rows) {
+		t.Errorf("MessageStream rows: %v, want %v", qr.Rows, messages.Rows)
+	}
+
+	// fakesqldb returns as many rows as RowsAffected.
+	db.AddQuery("update `msg` set time_acked = 1000, time_next = null where id in (1) and time_acked is null", &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows:         [][]sqltypes.Value{nil},
+	})
+	ackReq := &proto.MessageAckRequest{Name: "msg", Ids: []interface{}{int64(1)}, SessionId: sqlQuery.sessionID}
+	ackReply := &proto.MessageAckResult{}
+	if err := sqlQuery.MessageAck(context.Background(), ackReq, ackReply); err != nil {
+		t.Fatalf("MessageAck failed: %v", err)
+	}
+	if ackReply.Count != 1 {
+		t.Errorf("MessageAck count: %d, want 1", ackReply.Count)
+	}
+
+	cancel()
+	if err := <-streamErr; err != nil {
+		t.Errorf("MessageStream: %v, want nil", err)
+	}
+	waitForReceivers(t, mm, 0)
+}
+
+func TestMessageStreamSendError(t *testing.T) {
+	db := setUpMessageTest()
+	sqlQuery := getMessageSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	mm := sqlQuery.qe.messager
+	mm.SetIsMaster(true)
+	mm.now = func() time.Time { return time.Unix(0, 1000) }
+
+	streamErr := make(chan error, 1)
+	go func() {
+		req := &proto.MessageStreamRequest{Name: "msg", SessionId: sqlQuery.sessionID}
+		streamErr <- sqlQuery.MessageStream(context.Background(), req, func(qr *mproto.QueryResult) error {
+			if qr.Fields != nil {
+				return nil
+			}
+			return fmt.Errorf("client went away")
+		})
+	}()
+	waitForReceivers(t, mm, 1)
+
+	db.AddQuery("select id, epoch, message from `msg` where time_next <= 1000 and time_acked is null order by time_next limit 100 for update", &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{
+				sqltypes.MakeNumeric([]byte("1")),
+				sqltypes.MakeNumeric([]byte("0")),
+				sqltypes.MakeString([]byte("hello")),
+			},
+		},
+	})
+	mm.poll()
+	want := "client went away"
+	if err := <-streamErr; err == nil || err.Error() != want {
+		t.Errorf("MessageStream: %v, want %s", err, want)
+	}
+	waitForReceivers(t, mm, 0)
+}
+
+func TestMessageStreamShutdown(t *testing.T) {
+	setUpMessageTest()
+	sqlQuery := getMessageSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	mm := sqlQuery.qe.messager
+	mm.SetIsMaster(true)
+
+	streamErr := make(chan error, 1)
+	go func() {
+		req := &proto.MessageStreamRequest{Name: "msg", SessionId: sqlQuery.sessionID}
+		streamErr <- sqlQuery.MessageStream(context.Background(), req, func(qr *mproto.QueryResult) error {
+			return nil
+		})
+	}()
+	waitForReceivers(t, mm, 1)
+	sqlQuery.disallowQueries()
+	want := "shutting down"
+	if err := <-streamErr; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("MessageStream: %v, want %s", err, want)
+	}
+}
+
+func TestMessageNotMaster(t *testing.T) {
+	setUpMessageTest()
+	sqlQuery := getMessageSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	mm := sqlQuery.qe.messager
+
+	// a replica doesn't serve the messages
+	want := "only served by the master"
+	req := &proto.MessageStreamRequest{Name: "msg", SessionId: sqlQuery.sessionID}
+	err := sqlQuery.MessageStream(context.Background(), req, func(qr *mproto.QueryResult) error {
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("MessageStream: %v, want %s", err, want)
+	}
+	ackReq := &proto.MessageAckRequest{Name: "msg", Ids: []interface{}{int64(1)}, SessionId: sqlQuery.sessionID}
+	err = sqlQuery.MessageAck(context.Background(), ackReq, &proto.MessageAckResult{})
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("MessageAck: %v, want %s", err, want)
+	}
+
+	// a master that's demoted closes its streams
+	mm.SetIsMaster(true)
+	streamErr := make(chan error, 1)
+	go func() {
+		streamErr <- sqlQuery.MessageStream(context.Background(), req, func(qr *mproto.QueryResult) error {
+			return nil
+		})
+	}()
+	waitForReceivers(t, mm, 1)
+	mm.SetIsMaster(false)
+	want = "not the master anymore"
+	if err := <-streamErr; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("MessageStream: %v, want %s", err, want)
+	}
+}
+
+func TestMessageNotMessageTable(t *testing.T) {
+	setUpMessageTest()
+	sqlQuery := getMessageSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+
+	want := "test_table is not a message table"
+	req := &proto.MessageStreamRequest{Name: "test_table", SessionId: sqlQuery.sessionID}
+	err := sqlQuery.MessageStream(context.Background(), req, func(qr *mproto.QueryResult) error {
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("MessageStream: %v, want %s", err, want)
+	}
+	ackReq := &proto.MessageAckRequest{Name: "test_table", Ids: []interface{}{int64(1)}, SessionId: sqlQuery.sessionID}
+	err = sqlQuery.MessageAck(context.Background(), ackReq, &proto.MessageAckResult{})
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("MessageAck: %v, want %s", err, want)
+	}
+}
+
+func TestMessageTableMissingColumn(t *testing.T) {
+	db := setUpMessageTest()
+	db.AddQuery("describe `msg`", &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{
+			describeColumn("id"),
+		},
+	})
+	sqlQuery := getMessageSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs))
+	want := "message table msg has no time_next column"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("allowQueries: %v, want %s", err, want)
+	}
+}
+
+var messageFields = []mproto.Field{
+	mproto.Field{Name: "id", Type: mproto.VT_LONGLONG},
+	mproto.Field{Name: "epoch", Type: mproto.VT_LONGLONG},
+	mproto.Field{Name: "message", Type: mproto.VT_VAR_STRING},
+}
+
+func setUpMessageTest() *fakesqldb.DB {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	db.AddQuery(baseShowTables, &mproto.QueryResult{
+		RowsAffected: 2,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{
+				sqltypes.MakeString([]byte("test_table")),
+				sqltypes.MakeString([]byte("USER TABLE")),
+				sqltypes.MakeString([]byte("1427325875")),
+				sqltypes.MakeString([]byte("")),
+			},
+			[]sqltypes.Value{
+				sqltypes.MakeString([]byte("msg")),
+				sqltypes.MakeString([]byte("USER TABLE")),
+				sqltypes.MakeString([]byte("1427325875")),
+				sqltypes.MakeString([]byte("vitess_message")),
+			},
+		},
+	})
+	db.AddQuery("describe `msg`", &mproto.QueryResult{
+		RowsAffected: 5,
+		Rows: [][]sqltypes.Value{
+			describeColumn("id"),
+			describeColumn("time_next"),
+			describeColumn("epoch"),
+			describeColumn("time_acked"),
+			describeColumn("message"),
+		},
+	})
+	db.AddQuery("show index from `msg`", &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{
+				sqltypes.MakeString([]byte{}),
+				sqltypes.MakeString([]byte{}),
+				sqltypes.MakeString([]byte("PRIMARY")),
+				sqltypes.MakeString([]byte{}),
+				sqltypes.MakeString([]byte("id")),
+				sqltypes.MakeString([]byte{}),
+				sqltypes.MakeString([]byte("300")),
+			},
+		},
+	})
+	db.AddQuery("select id, epoch, message from `msg` where 1 != 1", &mproto.QueryResult{
+		Fields: messageFields,
+	})
+	return db
+}
+
+func describeColumn(name string) []sqltypes.Value {
+	return []sqltypes.Value{
+		sqltypes.MakeString([]byte(name)),
+		sqltypes.MakeString([]byte("bigint")),
+		sqltypes.MakeString([]byte{}),
+		sqltypes.MakeString([]byte{}),
+		sqltypes.MakeString([]byte("0")),
+		sqltypes.MakeString([]byte{}),
+	}
+}
+
+// getMessageSqlQuery returns a SqlQuery that doesn't poll the message
+// tables on its own, so the tests can call poll when they need it.
+func getMessageSqlQuery() *SqlQuery {
+	randID := rand.Int63()
+	config := DefaultQsConfig
+	config.StatsPrefix = fmt.Sprintf("Stats-%d-", randID)
+	config.DebugURLPrefix = fmt.Sprintf("/debug-%d-", randID)
+	config.RowCache.StatsPrefix = fmt.Sprintf("Stats-%d-", randID)
+	config.PoolNamePrefix = fmt.Sprintf("Pool-%d-", randID)
+	config.StrictMode = true
+	config.MessagePollInterval = 0
+	return NewSqlQuery(config)
+}
+
+func waitForReceivers(t *testing.T, mm *messageManager, want int) {
+	for i := 0; i < 100; i++ {
+		mm.mu.Lock()
+		got := len(mm.receivers["msg"])
+		mm.mu.Unlock()
+		if got == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("message receivers for msg: want %d", want)
+}
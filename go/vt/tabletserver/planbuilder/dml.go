@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/vt/schema"
@@ -18,6 +19,7 @@ func analyzeUpdate(upd *sqlparser.Update, getTable TableGetter) (plan *ExecPlan,
 	plan = &ExecPlan{
 		PlanId:    PLAN_PASS_DML,
 		FullQuery: GenerateFullQuery(upd),
+		dml:       &dmlClauses{where: upd.Where != nil, limit: upd.Limit != nil},
 	}
 
 	tableName := sqlparser.GetTableName(upd.Table)
@@ -68,6 +70,7 @@ func analyzeDelete(del *sqlparser.Delete, getTable TableGetter) (plan *ExecPlan,
 	plan = &ExecPlan{
 		PlanId:    PLAN_PASS_DML,
 		FullQuery: GenerateFullQuery(del),
+		dml:       &dmlClauses{where: del.Where != nil, limit: del.Limit != nil},
 	}
 
 	tableName := sqlparser.GetTableName(del.Table)
@@ -115,6 +118,9 @@ func analyzeSet(set *sqlparser.Set) (plan *ExecPlan) {
 	}
 	updateExpr := set.Exprs[0]
 	plan.SetKey = string(updateExpr.Name.Name)
+	if updateExpr.Name.Qualifier != nil {
+		plan.SetKey = string(updateExpr.Name.Qualifier) + "." + plan.SetKey
+	}
 	numExpr, ok := updateExpr.Expr.(sqlparser.NumVal)
 	if !ok {
 		return plan
@@ -169,6 +175,15 @@ func analyzeSelect(sel *sqlparser.Select, getTable TableGetter) (plan *ExecPlan,
 		return nil, err
 	}
 
+	// Sequence
+	if count, ok := analyzeNextval(sel.SelectExprs); ok {
+		plan.PlanId = PLAN_NEXTVAL
+		plan.FieldQuery = nil
+		plan.FullQuery = nil
+		plan.Limit = count
+		return plan, nil
+	}
+
 	// There are bind variables in the SELECT list
 	if plan.FieldQuery == nil {
 		plan.Reason = REASON_SELECT_LIST
@@ -303,6 +318,38 @@ func analyzeSelectExprs(exprs sqlparser.SelectExprs, table *schema.Table) (selec
 	return selects, nil
 }
 
+// analyzeNextval returns the argument of a select list that is
+// nextval(N), which is how values are requested from a sequence.
+// The argument is an int64 or a bind variable name.
+func analyzeNextval(exprs sqlparser.SelectExprs) (count interface{}, ok bool) {
+	if len(exprs) != 1 {
+		return nil, false
+	}
+	expr, ok := exprs[0].(*sqlparser.NonStarExpr)
+	if !ok {
+		return nil, false
+	}
+	fn, ok := expr.Expr.(*sqlparser.FuncExpr)
+	if !ok || strings.ToLower(string(fn.Name)) != "nextval" || len(fn.Exprs) != 1 {
+		return nil, false
+	}
+	arg, ok := fn.Exprs[0].(*sqlparser.NonStarExpr)
+	if !ok {
+		return nil, false
+	}
+	switch v := arg.Expr.(type) {
+	case sqlparser.NumVal:
+		n, err := strconv.ParseInt(string(v), 0, 64)
+		if err != nil {
+			return nil, false
+		}
+		return n, true
+	case sqlparser.ValArg:
+		return string(v), true
+	}
+	return nil, false
+}
+
 func analyzeFrom(tableExprs sqlparser.TableExprs) (tablename string, hasHints bool) {
 	if len(tableExprs) > 1 {
 		return "", false
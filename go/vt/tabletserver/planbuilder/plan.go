@@ -54,6 +54,10 @@ const (
 	PLAN_SELECT_STREAM
 	// PLAN_OTHER is for SHOW, DESCRIBE & EXPLAIN statements
 	PLAN_OTHER
+	// PLAN_NEXTVAL is for 'select nextval(N) from seq' on sequence tables
+	PLAN_NEXTVAL
+	// PLAN_SAVEPOINT is for SAVEPOINT, ROLLBACK TO and RELEASE statements
+	PLAN_SAVEPOINT
 	// NumPlans stores the total number of plans
 	NumPlans
 )
@@ -73,6 +77,8 @@ var planName = []string{
 	"DDL",
 	"SELECT_STREAM",
 	"OTHER",
+	"NEXTVAL",
+	"SAVEPOINT",
 }
 
 func (pt PlanType) String() string {
@@ -97,6 +103,11 @@ func (pt PlanType) IsSelect() bool {
 	return pt == PLAN_PASS_SELECT || pt == PLAN_PK_IN || pt == PLAN_SELECT_SUBQUERY || pt == PLAN_SELECT_STREAM
 }
 
+// IsDML returns true if PlanType is about a query that changes rows.
+func (pt PlanType) IsDML() bool {
+	return pt == PLAN_PASS_DML || pt == PLAN_DML_PK || pt == PLAN_DML_SUBQUERY || pt == PLAN_INSERT_PK || pt == PLAN_INSERT_SUBQUERY
+}
+
 // MarshalJSON returns a json string for PlanType.
 func (pt PlanType) MarshalJSON() ([]byte, error) {
 	return ([]byte)(fmt.Sprintf("\"%s\"", pt.String())), nil
@@ -120,6 +131,8 @@ var tableAclRoles = map[PlanType]tableacl.Role{
 	PLAN_DDL:             tableacl.ADMIN,
 	PLAN_SELECT_STREAM:   tableacl.READER,
 	PLAN_OTHER:           tableacl.ADMIN,
+	PLAN_NEXTVAL:         tableacl.WRITER,
+	PLAN_SAVEPOINT:       tableacl.READER,
 }
 
 // ReasonType indicates why a query plan fails to build
@@ -206,6 +219,7 @@ type ExecPlan struct {
 	PKValues []interface{}
 
 	// PK_IN. Limit clause value.
+	// NEXTVAL: the number of values to reserve.
 	Limit interface{}
 
 	// For update: set clause if pk is changing
@@ -217,6 +231,32 @@ type ExecPlan struct {
 	// PLAN_SET
 	SetKey   string
 	SetValue interface{}
+
+	// For update & delete: the optional clauses of the statement
+	dml *dmlClauses
+}
+
+// dmlClauses tells which optional clauses an update or delete has.
+type dmlClauses struct {
+	where bool
+	limit bool
+}
+
+// UnboundedDMLReason returns why the plan, if it is an update or a
+// delete, can't be proven to touch a bounded number of rows: it has
+// no where clause, or it has no limit and doesn't use the primary key.
+// It returns "" for all other plans.
+func (node *ExecPlan) UnboundedDMLReason() string {
+	if node.dml == nil {
+		return ""
+	}
+	if !node.dml.where {
+		return "no where clause"
+	}
+	if !node.dml.limit && node.PlanId != PLAN_DML_PK {
+		return "no limit clause"
+	}
+	return ""
 }
 
 func (node *ExecPlan) setTableInfo(tableName string, getTable TableGetter) (*schema.Table, error) {
@@ -301,6 +341,8 @@ func analyzeSQL(statement sqlparser.Statement, getTable TableGetter) (plan *Exec
 		return analyzeDDL(stmt, getTable), nil
 	case *sqlparser.Other:
 		return &ExecPlan{PlanId: PLAN_OTHER}, nil
+	case *sqlparser.Savepoint:
+		return &ExecPlan{PlanId: PLAN_SAVEPOINT, FullQuery: GenerateFullQuery(stmt)}, nil
 	}
 	return nil, errors.New("invalid SQL")
 }
@@ -126,6 +126,38 @@ func TestDDLPlan(t *testing.T) {
 	}
 }
 
+func TestUnboundedDMLReason(t *testing.T) {
+	testSchema := loadSchema("schema_test.json")
+	getTable := func(name string) (*schema.Table, bool) {
+		r, ok := testSchema[name]
+		return r, ok
+	}
+	testcases := []struct {
+		sql  string
+		want string
+	}{
+		{"update a set name='foo'", "no where clause"},
+		{"delete from a limit 10", "no where clause"},
+		{"update a set name='foo' where name='bar'", "no limit clause"},
+		{"delete from a where name='bar'", "no limit clause"},
+		{"update a set name='foo' where name='bar' limit 10", ""},
+		{"update a set name='foo' where eid=1 and id=1", ""},
+		{"delete from a where eid=1 and id=1", ""},
+		{"select * from a", ""},
+		{"insert into a(eid, id) values (1, 2)", ""},
+	}
+	for _, tcase := range testcases {
+		plan, err := GetExecPlan(tcase.sql, getTable)
+		if err != nil {
+			t.Errorf("GetExecPlan(%v) failed: %v", tcase.sql, err)
+			continue
+		}
+		if got := plan.UnboundedDMLReason(); got != tcase.want {
+			t.Errorf("UnboundedDMLReason(%v): %#v, want %#v", tcase.sql, got, tcase.want)
+		}
+	}
+}
+
 func matchString(t *testing.T, line int, expected interface{}, actual string) {
 	if expected != nil {
 		if expected.(string) != actual {
@@ -5,32 +5,39 @@
 package proto
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/youtube/vitess/go/bson"
 )
 
 type reflectQuery struct {
-	Sql           string
-	BindVariables map[string]interface{}
-	SessionId     int64
-	TransactionId int64
+	Sql            string
+	BindVariables  map[string]interface{}
+	SessionId      int64
+	TransactionId  int64
+	FieldsOnly     bool
+	IdempotencyKey string
 }
 
 type extraQuery struct {
-	Extra         int
-	Sql           string
-	BindVariables map[string]interface{}
-	SessionId     int64
-	TransactionId int64
+	Extra          int
+	Sql            string
+	BindVariables  map[string]interface{}
+	SessionId      int64
+	TransactionId  int64
+	FieldsOnly     bool
+	IdempotencyKey string
 }
 
 func TestQuery(t *testing.T) {
 	reflected, err := bson.Marshal(&reflectQuery{
-		Sql:           "query",
-		BindVariables: map[string]interface{}{"val": int64(1)},
-		SessionId:     2,
-		TransactionId: 1,
+		Sql:            "query",
+		BindVariables:  map[string]interface{}{"val": int64(1)},
+		SessionId:      2,
+		TransactionId:  1,
+		FieldsOnly:     true,
+		IdempotencyKey: "key",
 	})
 	if err != nil {
 		t.Error(err)
@@ -38,10 +45,12 @@ func TestQuery(t *testing.T) {
 	want := string(reflected)
 
 	custom := Query{
-		Sql:           "query",
-		BindVariables: map[string]interface{}{"val": int64(1)},
-		SessionId:     2,
-		TransactionId: 1,
+		Sql:            "query",
+		BindVariables:  map[string]interface{}{"val": int64(1)},
+		SessionId:      2,
+		TransactionId:  1,
+		FieldsOnly:     true,
+		IdempotencyKey: "key",
 	}
 	encoded, err := bson.Marshal(&custom)
 	if err != nil {
@@ -66,6 +75,12 @@ func TestQuery(t *testing.T) {
 	if custom.SessionId != unmarshalled.SessionId {
 		t.Errorf("want %v, got %v", custom.SessionId, unmarshalled.SessionId)
 	}
+	if custom.FieldsOnly != unmarshalled.FieldsOnly {
+		t.Errorf("want %v, got %v", custom.FieldsOnly, unmarshalled.FieldsOnly)
+	}
+	if custom.IdempotencyKey != unmarshalled.IdempotencyKey {
+		t.Errorf("want %v, got %v", custom.IdempotencyKey, unmarshalled.IdempotencyKey)
+	}
 	if custom.BindVariables["val"].(int64) != unmarshalled.BindVariables["val"].(int64) {
 		t.Errorf("want %v, got %v", custom.BindVariables["val"], unmarshalled.BindVariables["val"])
 	}
@@ -80,21 +95,32 @@ func TestQuery(t *testing.T) {
 	}
 }
 
+type reflectTransactionOptions struct {
+	IsolationLevel string
+	ReadOnly       bool
+}
+
 type reflectSession struct {
-	SessionId     int64
-	TransactionId int64
+	SessionId          int64
+	TransactionId      int64
+	TransactionOptions *reflectTransactionOptions
 }
 
 type extraSession struct {
-	Extra         int
-	SessionId     int64
-	TransactionId int64
+	Extra              int
+	SessionId          int64
+	TransactionId      int64
+	TransactionOptions *reflectTransactionOptions
 }
 
 func TestSession(t *testing.T) {
 	reflected, err := bson.Marshal(&reflectSession{
 		SessionId:     2,
 		TransactionId: 1,
+		TransactionOptions: &reflectTransactionOptions{
+			IsolationLevel: IsolationReadCommitted,
+			ReadOnly:       true,
+		},
 	})
 	if err != nil {
 		t.Error(err)
@@ -104,6 +130,10 @@ func TestSession(t *testing.T) {
 	custom := Session{
 		SessionId:     2,
 		TransactionId: 1,
+		TransactionOptions: &TransactionOptions{
+			IsolationLevel: IsolationReadCommitted,
+			ReadOnly:       true,
+		},
 	}
 	encoded, err := bson.Marshal(&custom)
 	if err != nil {
@@ -119,7 +149,7 @@ func TestSession(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if custom != unmarshalled {
+	if !reflect.DeepEqual(custom, unmarshalled) {
 		t.Errorf("want %v, got %#v", custom, unmarshalled)
 	}
 
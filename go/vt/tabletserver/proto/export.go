@@ -0,0 +1,114 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/key"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+)
+
+// ExportTableRequest is the request to export all the rows of a
+// table, or only the ones in a keyrange.
+type ExportTableRequest struct {
+	Table string
+
+	// KeyspaceIdColumn is the column with the keyspace id of
+	// the rows. If it is set, only the rows in KeyRange are
+	// exported, KeyspaceIdType being the type of the column.
+	KeyspaceIdColumn string
+	KeyspaceIdType   key.KeyspaceIdType
+	KeyRange         key.KeyRange
+
+	SessionId int64
+}
+
+// ExportHeader describes the rows of an export.
+type ExportHeader struct {
+	// Fields are the columns of the table, in the order of the
+	// Columns of the chunks.
+	Fields []mproto.Field
+
+	// PrimaryKeyColumns are the indexes in Fields of the primary
+	// key columns. The rows are exported in primary key order.
+	PrimaryKeyColumns []int
+
+	// Position is a low watermark of the export: it has all the
+	// changes up to Position, and maybe some of the later ones.
+	// Replaying the binlogs from Position brings it up to date.
+	Position myproto.ReplicationPosition
+}
+
+// ExportChunk is a message of an export stream. The first one only
+// has the Header, the next ones have a batch of rows.
+type ExportChunk struct {
+	Header *ExportHeader
+
+	// RowCount is the number of rows of the chunk.
+	RowCount int
+
+	// Columns has the values of the rows, column by column, as
+	// encoded by EncodeExportColumns.
+	Columns [][]byte
+}
+
+// EncodeExportColumns encodes rows of columnCount values column by
+// column: the values of a column are concatenated, each prefixed
+// with its length plus one as a uvarint, or 0 if it is NULL.
+func EncodeExportColumns(columnCount int, rows [][]sqltypes.Value) [][]byte {
+	columns := make([][]byte, columnCount)
+	var lenBuf [binary.MaxVarintLen64]byte
+	for i := range columns {
+		var column []byte
+		for _, row := range rows {
+			if row[i].IsNull() {
+				column = append(column, 0)
+				continue
+			}
+			raw := row[i].Raw()
+			n := binary.PutUvarint(lenBuf[:], uint64(len(raw))+1)
+			column = append(column, lenBuf[:n]...)
+			column = append(column, raw...)
+		}
+		columns[i] = column
+	}
+	return columns
+}
+
+// DecodeExportColumns decodes the rows of a chunk. The values are
+// returned as strings, like the values of a QueryResult sent over
+// RPC.
+func DecodeExportColumns(chunk *ExportChunk) ([][]sqltypes.Value, error) {
+	rows := make([][]sqltypes.Value, chunk.RowCount)
+	for i := range rows {
+		rows[i] = make([]sqltypes.Value, len(chunk.Columns))
+	}
+	for i, column := range chunk.Columns {
+		for _, row := range rows {
+			l, n := binary.Uvarint(column)
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid value length in column %v", i)
+			}
+			column = column[n:]
+			if l == 0 {
+				continue
+			}
+			l--
+			if uint64(len(column)) < l {
+				return nil, fmt.Errorf("truncated value in column %v", i)
+			}
+			row[i] = sqltypes.MakeString(column[:l])
+			column = column[l:]
+		}
+		if len(column) != 0 {
+			return nil, fmt.Errorf("column %v has %v extra bytes", i, len(column))
+		}
+	}
+	return rows, nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/youtube/vitess/go/sqltypes"
+)
+
+func TestExportColumns(t *testing.T) {
+	rows := [][]sqltypes.Value{
+		{sqltypes.MakeString([]byte("1")), sqltypes.MakeString([]byte("abc")), sqltypes.Value{}},
+		{sqltypes.MakeString([]byte("2")), sqltypes.MakeString([]byte("")), sqltypes.MakeString([]byte(strings.Repeat("x", 200)))},
+	}
+	chunk := &ExportChunk{
+		RowCount: len(rows),
+		Columns:  EncodeExportColumns(3, rows),
+	}
+	if got, want := chunk.Columns[1], []byte("\x04abc\x01"); !reflect.DeepEqual(got, want) {
+		t.Errorf("EncodeExportColumns column 1 = %q, want %q", got, want)
+	}
+	got, err := DecodeExportColumns(chunk)
+	if err != nil {
+		t.Fatalf("DecodeExportColumns failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("DecodeExportColumns = %v, want %v", got, rows)
+	}
+
+	for _, columns := range [][]byte{
+		[]byte("\x04ab"),
+		[]byte("\x01\x01\x01"),
+		[]byte("\xff"),
+	} {
+		chunk := &ExportChunk{RowCount: 2, Columns: [][]byte{columns}}
+		if _, err := DecodeExportColumns(chunk); err == nil {
+			t.Errorf("DecodeExportColumns(%q) worked", columns)
+		}
+	}
+}
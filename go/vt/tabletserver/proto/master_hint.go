@@ -0,0 +1,40 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+// masterHintPrefix starts the master hint in an error message.
+const masterHintPrefix = " (master: "
+
+// AppendMasterHint appends the end point of the current master to an
+// error message, so clients can send the query there. Older clients
+// only see a longer message.
+func AppendMasterHint(message string, master *topo.EndPoint) string {
+	data, err := json.Marshal(master)
+	if err != nil {
+		return message
+	}
+	return message + masterHintPrefix + string(data) + ")"
+}
+
+// ParseMasterHint returns the end point appended to message by
+// AppendMasterHint, or nil if there is none.
+func ParseMasterHint(message string) *topo.EndPoint {
+	i := strings.LastIndex(message, masterHintPrefix)
+	if i == -1 || !strings.HasSuffix(message, ")") {
+		return nil
+	}
+	master := &topo.EndPoint{}
+	if err := json.Unmarshal([]byte(message[i+len(masterHintPrefix):len(message)-1]), master); err != nil {
+		return nil
+	}
+	return master
+}
@@ -0,0 +1,34 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+func TestMasterHint(t *testing.T) {
+	master := &topo.EndPoint{
+		Uid:          12,
+		Host:         "master",
+		NamedPortMap: map[string]int{"vt": 15101, "vts": 15102},
+	}
+	message := AppendMasterHint("retry: read-only (errno 1290)", master)
+	if got := ParseMasterHint(message); !reflect.DeepEqual(got, master) {
+		t.Errorf("ParseMasterHint(%v) = %#v, want %#v", message, got, master)
+	}
+
+	for _, message := range []string{
+		"retry: read-only (errno 1290)",
+		"retry: read-only (master: not json)",
+		"",
+	} {
+		if got := ParseMasterHint(message); got != nil {
+			t.Errorf("ParseMasterHint(%v) = %#v, want nil", message, got)
+		}
+	}
+}
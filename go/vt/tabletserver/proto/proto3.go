@@ -0,0 +1,415 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto
+
+import (
+	"fmt"
+	"strconv"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/key"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+
+	pb "github.com/youtube/vitess/go/vt/proto/query"
+)
+
+// This file contains the conversions between the query service
+// structures and their proto3 version, used by gRPC.
+
+// BindVariableToProto3 converts a bind variable value. The integers
+// become TYPE_INT or TYPE_UINT, the floats TYPE_FLOAT, the lists
+// TYPE_LIST, and everything else TYPE_BYTES.
+func BindVariableToProto3(v interface{}) (*pb.BindVariable, error) {
+	switch v := v.(type) {
+	case nil:
+		return &pb.BindVariable{Type: pb.BindVariable_TYPE_NULL}, nil
+	case int:
+		return &pb.BindVariable{Type: pb.BindVariable_TYPE_INT, ValueInt: int64(v)}, nil
+	case int32:
+		return &pb.BindVariable{Type: pb.BindVariable_TYPE_INT, ValueInt: int64(v)}, nil
+	case int64:
+		return &pb.BindVariable{Type: pb.BindVariable_TYPE_INT, ValueInt: v}, nil
+	case uint:
+		return &pb.BindVariable{Type: pb.BindVariable_TYPE_UINT, ValueUint: uint64(v)}, nil
+	case uint32:
+		return &pb.BindVariable{Type: pb.BindVariable_TYPE_UINT, ValueUint: uint64(v)}, nil
+	case uint64:
+		return &pb.BindVariable{Type: pb.BindVariable_TYPE_UINT, ValueUint: v}, nil
+	case float64:
+		return &pb.BindVariable{Type: pb.BindVariable_TYPE_FLOAT, ValueFloat: v}, nil
+	case []interface{}:
+		bv := &pb.BindVariable{
+			Type:      pb.BindVariable_TYPE_LIST,
+			ValueList: make([]*pb.BindVariable, len(v)),
+		}
+		for i, lv := range v {
+			if _, ok := lv.([]interface{}); ok {
+				return nil, fmt.Errorf("unsupported bind variable: list of lists")
+			}
+			var err error
+			if bv.ValueList[i], err = BindVariableToProto3(lv); err != nil {
+				return nil, err
+			}
+		}
+		return bv, nil
+	}
+
+	// Everything else is converted like sqltypes does it.
+	val, err := sqltypes.BuildValue(v)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case val.IsNull():
+		return &pb.BindVariable{Type: pb.BindVariable_TYPE_NULL}, nil
+	case val.IsNumeric():
+		if i, err := val.ParseInt64(); err == nil {
+			return &pb.BindVariable{Type: pb.BindVariable_TYPE_INT, ValueInt: i}, nil
+		}
+		u, err := val.ParseUint64()
+		if err != nil {
+			return nil, err
+		}
+		return &pb.BindVariable{Type: pb.BindVariable_TYPE_UINT, ValueUint: u}, nil
+	case val.IsFractional():
+		f, err := strconv.ParseFloat(val.String(), 64)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.BindVariable{Type: pb.BindVariable_TYPE_FLOAT, ValueFloat: f}, nil
+	}
+	return &pb.BindVariable{Type: pb.BindVariable_TYPE_BYTES, ValueBytes: val.Raw()}, nil
+}
+
+// Proto3ToBindVariable converts a proto3 bind variable. TYPE_INT
+// becomes an int64, TYPE_UINT an uint64, TYPE_FLOAT a float64,
+// TYPE_BYTES a []byte, and TYPE_LIST a []interface{}.
+func Proto3ToBindVariable(bv *pb.BindVariable) (interface{}, error) {
+	switch bv.Type {
+	case pb.BindVariable_TYPE_NULL:
+		return nil, nil
+	case pb.BindVariable_TYPE_BYTES:
+		return bv.ValueBytes, nil
+	case pb.BindVariable_TYPE_INT:
+		return bv.ValueInt, nil
+	case pb.BindVariable_TYPE_UINT:
+		return bv.ValueUint, nil
+	case pb.BindVariable_TYPE_FLOAT:
+		return bv.ValueFloat, nil
+	case pb.BindVariable_TYPE_LIST:
+		list := make([]interface{}, len(bv.ValueList))
+		for i, lv := range bv.ValueList {
+			if lv.Type == pb.BindVariable_TYPE_LIST {
+				return nil, fmt.Errorf("unsupported bind variable: list of lists")
+			}
+			var err error
+			if list[i], err = Proto3ToBindVariable(lv); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("unknown bind variable type %v", bv.Type)
+}
+
+// BindVariablesToProto3 converts bind variables.
+func BindVariablesToProto3(bindVars map[string]interface{}) (map[string]*pb.BindVariable, error) {
+	if len(bindVars) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]*pb.BindVariable, len(bindVars))
+	for name, v := range bindVars {
+		bv, err := BindVariableToProto3(v)
+		if err != nil {
+			return nil, fmt.Errorf("bind variable %v: %v", name, err)
+		}
+		result[name] = bv
+	}
+	return result, nil
+}
+
+// Proto3ToBindVariables converts proto3 bind variables.
+func Proto3ToBindVariables(bindVars map[string]*pb.BindVariable) (map[string]interface{}, error) {
+	if len(bindVars) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]interface{}, len(bindVars))
+	for name, bv := range bindVars {
+		v, err := Proto3ToBindVariable(bv)
+		if err != nil {
+			return nil, fmt.Errorf("bind variable %v: %v", name, err)
+		}
+		result[name] = v
+	}
+	return result, nil
+}
+
+// BoundQueryToProto3 converts a BoundQuery.
+func BoundQueryToProto3(query *BoundQuery) (*pb.BoundQuery, error) {
+	bindVars, err := BindVariablesToProto3(query.BindVariables)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.BoundQuery{
+		Sql:           query.Sql,
+		BindVariables: bindVars,
+	}, nil
+}
+
+// Proto3ToBoundQuery converts a proto3 BoundQuery.
+func Proto3ToBoundQuery(query *pb.BoundQuery) (*BoundQuery, error) {
+	if query == nil {
+		return &BoundQuery{}, nil
+	}
+	bindVars, err := Proto3ToBindVariables(query.BindVariables)
+	if err != nil {
+		return nil, err
+	}
+	return &BoundQuery{
+		Sql:           query.Sql,
+		BindVariables: bindVars,
+	}, nil
+}
+
+// FieldsToProto3 converts the fields of a result.
+func FieldsToProto3(fields []mproto.Field) []*pb.Field {
+	if len(fields) == 0 {
+		return nil
+	}
+	result := make([]*pb.Field, len(fields))
+	for i, f := range fields {
+		result[i] = &pb.Field{
+			Name:  f.Name,
+			Type:  f.Type,
+			Flags: f.Flags,
+		}
+	}
+	return result
+}
+
+// Proto3ToFields converts proto3 fields.
+func Proto3ToFields(fields []*pb.Field) []mproto.Field {
+	if len(fields) == 0 {
+		return nil
+	}
+	result := make([]mproto.Field, len(fields))
+	for i, f := range fields {
+		result[i] = mproto.Field{
+			Name:  f.Name,
+			Type:  f.Type,
+			Flags: f.Flags,
+		}
+	}
+	return result
+}
+
+// RowToProto3 converts a row. The values are concatenated, and their
+// lengths recorded, -1 meaning NULL.
+func RowToProto3(row []sqltypes.Value) *pb.Row {
+	result := &pb.Row{
+		Lengths: make([]int64, len(row)),
+	}
+	size := 0
+	for _, v := range row {
+		size += len(v.Raw())
+	}
+	result.Values = make([]byte, 0, size)
+	for i, v := range row {
+		if v.IsNull() {
+			result.Lengths[i] = -1
+			continue
+		}
+		result.Lengths[i] = int64(len(v.Raw()))
+		result.Values = append(result.Values, v.Raw()...)
+	}
+	return result
+}
+
+// Proto3ToRow converts a proto3 row. Like with bsonrpc, the values
+// come back as strings, the fields have their types.
+func Proto3ToRow(row *pb.Row) ([]sqltypes.Value, error) {
+	result := make([]sqltypes.Value, len(row.Lengths))
+	values := row.Values
+	for i, length := range row.Lengths {
+		if length < 0 {
+			continue
+		}
+		if length > int64(len(values)) {
+			return nil, fmt.Errorf("invalid row: value %v has length %v, only %v bytes left", i, length, len(values))
+		}
+		result[i] = sqltypes.MakeString(values[:length])
+		values = values[length:]
+	}
+	return result, nil
+}
+
+// QueryResultToProto3 converts a QueryResult.
+func QueryResultToProto3(qr *mproto.QueryResult) *pb.QueryResult {
+	result := &pb.QueryResult{
+		Fields:       FieldsToProto3(qr.Fields),
+		RowsAffected: qr.RowsAffected,
+		InsertId:     qr.InsertId,
+	}
+	if len(qr.Rows) > 0 {
+		result.Rows = make([]*pb.Row, len(qr.Rows))
+		for i, row := range qr.Rows {
+			result.Rows[i] = RowToProto3(row)
+		}
+	}
+	return result
+}
+
+// Proto3ToQueryResult converts a proto3 QueryResult.
+func Proto3ToQueryResult(qr *pb.QueryResult) (*mproto.QueryResult, error) {
+	if qr == nil {
+		return &mproto.QueryResult{}, nil
+	}
+	result := &mproto.QueryResult{
+		Fields:       Proto3ToFields(qr.Fields),
+		RowsAffected: qr.RowsAffected,
+		InsertId:     qr.InsertId,
+	}
+	if len(qr.Rows) > 0 {
+		result.Rows = make([][]sqltypes.Value, len(qr.Rows))
+		for i, row := range qr.Rows {
+			var err error
+			if result.Rows[i], err = Proto3ToRow(row); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// TransactionOptionsToProto3 converts TransactionOptions, which may
+// be nil.
+func TransactionOptionsToProto3(options *TransactionOptions) *pb.TransactionOptions {
+	if options == nil {
+		return nil
+	}
+	return &pb.TransactionOptions{
+		IsolationLevel: options.IsolationLevel,
+		ReadOnly:       options.ReadOnly,
+	}
+}
+
+// Proto3ToTransactionOptions converts proto3 TransactionOptions,
+// which may be nil.
+func Proto3ToTransactionOptions(options *pb.TransactionOptions) *TransactionOptions {
+	if options == nil {
+		return nil
+	}
+	return &TransactionOptions{
+		IsolationLevel: options.IsolationLevel,
+		ReadOnly:       options.ReadOnly,
+	}
+}
+
+// QuerySplitsToProto3 converts the result of a SplitQuery.
+func QuerySplitsToProto3(splits []QuerySplit) ([]*pb.QuerySplit, error) {
+	if len(splits) == 0 {
+		return nil, nil
+	}
+	result := make([]*pb.QuerySplit, len(splits))
+	for i, split := range splits {
+		query, err := BoundQueryToProto3(&split.Query)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = &pb.QuerySplit{
+			Query:    query,
+			RowCount: split.RowCount,
+		}
+	}
+	return result, nil
+}
+
+// Proto3ToQuerySplits converts the proto3 result of a SplitQuery.
+func Proto3ToQuerySplits(splits []*pb.QuerySplit) ([]QuerySplit, error) {
+	if len(splits) == 0 {
+		return nil, nil
+	}
+	result := make([]QuerySplit, len(splits))
+	for i, split := range splits {
+		query, err := Proto3ToBoundQuery(split.Query)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = QuerySplit{
+			Query:    *query,
+			RowCount: split.RowCount,
+		}
+	}
+	return result, nil
+}
+
+// ExportTableRequestToProto3 converts an ExportTableRequest.
+func ExportTableRequestToProto3(req *ExportTableRequest) *pb.ExportTableRequest {
+	return &pb.ExportTableRequest{
+		Table:            req.Table,
+		KeyspaceIdColumn: req.KeyspaceIdColumn,
+		KeyspaceIdType:   string(req.KeyspaceIdType),
+		KeyRangeStart:    []byte(req.KeyRange.Start),
+		KeyRangeEnd:      []byte(req.KeyRange.End),
+		SessionId:        req.SessionId,
+	}
+}
+
+// Proto3ToExportTableRequest converts a proto3 ExportTableRequest.
+func Proto3ToExportTableRequest(req *pb.ExportTableRequest) *ExportTableRequest {
+	return &ExportTableRequest{
+		Table:            req.Table,
+		KeyspaceIdColumn: req.KeyspaceIdColumn,
+		KeyspaceIdType:   key.KeyspaceIdType(req.KeyspaceIdType),
+		KeyRange: key.KeyRange{
+			Start: key.KeyspaceId(req.KeyRangeStart),
+			End:   key.KeyspaceId(req.KeyRangeEnd),
+		},
+		SessionId: req.SessionId,
+	}
+}
+
+// ExportChunkToProto3 converts an ExportChunk.
+func ExportChunkToProto3(chunk *ExportChunk) *pb.ExportTableResponse {
+	result := &pb.ExportTableResponse{
+		RowCount: int64(chunk.RowCount),
+		Columns:  chunk.Columns,
+	}
+	if chunk.Header != nil {
+		result.Header = &pb.ExportHeader{
+			Fields:            FieldsToProto3(chunk.Header.Fields),
+			PrimaryKeyColumns: make([]int64, len(chunk.Header.PrimaryKeyColumns)),
+			Position:          myproto.EncodeReplicationPosition(chunk.Header.Position),
+		}
+		for i, c := range chunk.Header.PrimaryKeyColumns {
+			result.Header.PrimaryKeyColumns[i] = int64(c)
+		}
+	}
+	return result
+}
+
+// Proto3ToExportChunk converts a proto3 ExportChunk.
+func Proto3ToExportChunk(chunk *pb.ExportTableResponse) (*ExportChunk, error) {
+	result := &ExportChunk{
+		RowCount: int(chunk.RowCount),
+		Columns:  chunk.Columns,
+	}
+	if chunk.Header != nil {
+		position, err := myproto.DecodeReplicationPosition(chunk.Header.Position)
+		if err != nil {
+			return nil, err
+		}
+		result.Header = &ExportHeader{
+			Fields:            Proto3ToFields(chunk.Header.Fields),
+			PrimaryKeyColumns: make([]int, len(chunk.Header.PrimaryKeyColumns)),
+			Position:          position,
+		}
+		for i, c := range chunk.Header.PrimaryKeyColumns {
+			result.Header.PrimaryKeyColumns[i] = int(c)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto
+
+import (
+	"reflect"
+	"testing"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+)
+
+func TestBindVariablesProto3(t *testing.T) {
+	bindVars := map[string]interface{}{
+		"null":  nil,
+		"bytes": []byte("abc"),
+		"int":   int64(-1),
+		"uint":  uint64(1 << 63),
+		"float": 1.5,
+		"list":  []interface{}{int64(1), []byte("a")},
+	}
+	pbBindVars, err := BindVariablesToProto3(bindVars)
+	if err != nil {
+		t.Fatalf("BindVariablesToProto3 failed: %v", err)
+	}
+	got, err := Proto3ToBindVariables(pbBindVars)
+	if err != nil {
+		t.Fatalf("Proto3ToBindVariables failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, bindVars) {
+		t.Errorf("got %#v, want %#v", got, bindVars)
+	}
+
+	// the other types are converted like sqltypes does it
+	for _, tc := range []struct {
+		in   interface{}
+		want interface{}
+	}{
+		{1, int64(1)},
+		{uint32(2), uint64(2)},
+		{"abc", []byte("abc")},
+	} {
+		bv, err := BindVariableToProto3(tc.in)
+		if err != nil {
+			t.Errorf("BindVariableToProto3(%#v) failed: %v", tc.in, err)
+			continue
+		}
+		if got, _ := Proto3ToBindVariable(bv); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%#v converted to %#v, want %#v", tc.in, got, tc.want)
+		}
+	}
+
+	if _, err := BindVariableToProto3([]interface{}{[]interface{}{1}}); err == nil {
+		t.Errorf("BindVariableToProto3 accepted a list of lists")
+	}
+}
+
+func TestQueryResultProto3(t *testing.T) {
+	qr := &mproto.QueryResult{
+		Fields: []mproto.Field{
+			{Name: "id", Type: mproto.VT_LONGLONG, Flags: mproto.VT_NOT_NULL_FLAG},
+			{Name: "name", Type: mproto.VT_VAR_STRING, Flags: mproto.VT_BINARY_FLAG},
+		},
+		RowsAffected: 2,
+		InsertId:     3,
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeString([]byte("1")), sqltypes.MakeString([]byte("abc"))},
+			{sqltypes.MakeString([]byte("2")), sqltypes.Value{}},
+		},
+	}
+	got, err := Proto3ToQueryResult(QueryResultToProto3(qr))
+	if err != nil {
+		t.Fatalf("Proto3ToQueryResult failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, qr) {
+		t.Errorf("got %#v, want %#v", got, qr)
+	}
+
+	// a truncated row is an error
+	row := RowToProto3(qr.Rows[0])
+	row.Values = row.Values[:2]
+	if _, err := Proto3ToRow(row); err == nil {
+		t.Errorf("Proto3ToRow accepted a truncated row")
+	}
+}
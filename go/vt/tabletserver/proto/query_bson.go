@@ -31,6 +31,8 @@ func (query *Query) MarshalBson(buf *bytes2.ChunkedWriter, key string) {
 	}
 	bson.EncodeInt64(buf, "SessionId", query.SessionId)
 	bson.EncodeInt64(buf, "TransactionId", query.TransactionId)
+	bson.EncodeBool(buf, "FieldsOnly", query.FieldsOnly)
+	bson.EncodeString(buf, "IdempotencyKey", query.IdempotencyKey)
 
 	lenWriter.Close()
 }
@@ -70,6 +72,10 @@ func (query *Query) UnmarshalBson(buf *bytes.Buffer, kind byte) {
 			query.SessionId = bson.DecodeInt64(buf, kind)
 		case "TransactionId":
 			query.TransactionId = bson.DecodeInt64(buf, kind)
+		case "FieldsOnly":
+			query.FieldsOnly = bson.DecodeBool(buf, kind)
+		case "IdempotencyKey":
+			query.IdempotencyKey = bson.DecodeString(buf, kind)
 		default:
 			bson.Skip(buf, kind)
 		}
@@ -21,6 +21,12 @@ func (session *Session) MarshalBson(buf *bytes2.ChunkedWriter, key string) {
 
 	bson.EncodeInt64(buf, "SessionId", session.SessionId)
 	bson.EncodeInt64(buf, "TransactionId", session.TransactionId)
+	// *TransactionOptions
+	if session.TransactionOptions == nil {
+		bson.EncodePrefix(buf, bson.Null, "TransactionOptions")
+	} else {
+		(*session.TransactionOptions).MarshalBson(buf, "TransactionOptions")
+	}
 
 	lenWriter.Close()
 }
@@ -43,6 +49,12 @@ func (session *Session) UnmarshalBson(buf *bytes.Buffer, kind byte) {
 			session.SessionId = bson.DecodeInt64(buf, kind)
 		case "TransactionId":
 			session.TransactionId = bson.DecodeInt64(buf, kind)
+		case "TransactionOptions":
+			// *TransactionOptions
+			if kind != bson.Null {
+				session.TransactionOptions = new(TransactionOptions)
+				(*session.TransactionOptions).UnmarshalBson(buf, kind)
+			}
 		default:
 			bson.Skip(buf, kind)
 		}
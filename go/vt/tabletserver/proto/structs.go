@@ -14,10 +14,16 @@ import (
 type SessionParams struct {
 	Keyspace string
 	Shard    string
+	// ProtocolVersion is the ProtocolVersion of the client.
+	ProtocolVersion int64
 }
 
 type SessionInfo struct {
 	SessionId int64
+	// ProtocolVersion and Features describe the vttablet. They
+	// are empty if it predates the version negotiation.
+	ProtocolVersion int64
+	Features        []string
 }
 
 type Query struct {
@@ -25,6 +31,14 @@ type Query struct {
 	BindVariables map[string]interface{}
 	SessionId     int64
 	TransactionId int64
+	// FieldsOnly asks for the fields of the result only. The
+	// fields are cached with the query plan, so the query is
+	// not sent to MySQL.
+	FieldsOnly bool
+	// IdempotencyKey makes the write of a transaction apply only
+	// once: if it's retried with the same key, it returns the
+	// result of the first write instead.
+	IdempotencyKey string
 }
 
 //go:generate bsongen -file $GOFILE -type Query -o query_bson.go
@@ -80,10 +94,42 @@ type QueryResultList struct {
 type Session struct {
 	SessionId     int64
 	TransactionId int64
+	// TransactionOptions are the options of the transaction Begin
+	// starts. They are not used by the other calls.
+	TransactionOptions *TransactionOptions
 }
 
 //go:generate bsongen -file $GOFILE -type Session -o session_bson.go
 
+// The isolation levels a transaction can be started with.
+const (
+	// IsolationDefault keeps the isolation level of the MySQL server.
+	IsolationDefault        = ""
+	IsolationReadCommitted  = "READ COMMITTED"
+	IsolationRepeatableRead = "REPEATABLE READ"
+)
+
+// TransactionOptions are the options of a transaction, set when it
+// begins.
+type TransactionOptions struct {
+	// IsolationLevel is one of the Isolation* constants.
+	IsolationLevel string
+	// ReadOnly starts a read-only transaction.
+	ReadOnly bool
+}
+
+//go:generate bsongen -file $GOFILE -type TransactionOptions -o transaction_options_bson.go
+
+// ExecuteOptions are the options a client sets on an Execute or a
+// StreamExecute. They are copied into the Query.
+type ExecuteOptions struct {
+	// FieldsOnly asks for the fields of the result only.
+	FieldsOnly bool
+	// IdempotencyKey makes a write of a transaction safe to retry.
+	// It is only used by Execute.
+	IdempotencyKey string
+}
+
 type TransactionInfo struct {
 	TransactionId int64
 }
@@ -108,3 +154,69 @@ type QuerySplit struct {
 type SplitQueryResult struct {
 	Queries []QuerySplit
 }
+
+// PrepareRequest is the request to prepare a statement.
+type PrepareRequest struct {
+	Sql       string
+	SessionId int64
+}
+
+// PrepareResult is the result of a PrepareRequest. StatementId
+// is the handle of the prepared statement.
+type PrepareResult struct {
+	StatementId int64
+}
+
+// ExecutePreparedRequest is the request to execute a prepared
+// statement with the given bind variables.
+type ExecutePreparedRequest struct {
+	StatementId   int64
+	BindVariables map[string]interface{}
+	SessionId     int64
+	TransactionId int64
+}
+
+// ClosePreparedRequest is the request to release a prepared statement.
+type ClosePreparedRequest struct {
+	StatementId int64
+	SessionId   int64
+}
+
+// ExplainResult is the plan of a query, as returned by Explain.
+// The queries are the ones sent to MySQL, before their bind
+// variables are substituted.
+type ExplainResult struct {
+	PlanType  string
+	Reason    string
+	TableName string
+	// IndexUsed is the index of the subquery of the SELECT_SUBQUERY
+	// plans.
+	IndexUsed string
+	// PKValues are the primary key values of the PK_IN, DML_PK and
+	// INSERT_PK plans, as values or bind variables.
+	PKValues   []string
+	FieldQuery string
+	FullQuery  string
+	OuterQuery string
+	Subquery   string
+}
+
+// MessageStreamRequest is the request to stream the messages
+// of a message table.
+type MessageStreamRequest struct {
+	Name      string
+	SessionId int64
+}
+
+// MessageAckRequest acks the messages of a message table by id.
+type MessageAckRequest struct {
+	Name      string
+	Ids       []interface{}
+	SessionId int64
+}
+
+// MessageAckResult is the result of a MessageAckRequest.
+// Count is the number of messages that were acked.
+type MessageAckResult struct {
+	Count int64
+}
@@ -0,0 +1,50 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto
+
+// DO NOT EDIT.
+// FILE GENERATED BY BSONGEN.
+
+import (
+	"bytes"
+
+	"github.com/youtube/vitess/go/bson"
+	"github.com/youtube/vitess/go/bytes2"
+)
+
+// MarshalBson bson-encodes TransactionOptions.
+func (transactionOptions *TransactionOptions) MarshalBson(buf *bytes2.ChunkedWriter, key string) {
+	bson.EncodeOptionalPrefix(buf, bson.Object, key)
+	lenWriter := bson.NewLenWriter(buf)
+
+	bson.EncodeString(buf, "IsolationLevel", transactionOptions.IsolationLevel)
+	bson.EncodeBool(buf, "ReadOnly", transactionOptions.ReadOnly)
+
+	lenWriter.Close()
+}
+
+// UnmarshalBson bson-decodes into TransactionOptions.
+func (transactionOptions *TransactionOptions) UnmarshalBson(buf *bytes.Buffer, kind byte) {
+	switch kind {
+	case bson.EOO, bson.Object:
+		// valid
+	case bson.Null:
+		return
+	default:
+		panic(bson.NewBsonError("unexpected kind %v for TransactionOptions", kind))
+	}
+	bson.Next(buf, 4)
+
+	for kind := bson.NextByte(buf); kind != bson.EOO; kind = bson.NextByte(buf) {
+		switch bson.ReadCString(buf) {
+		case "IsolationLevel":
+			transactionOptions.IsolationLevel = bson.DecodeString(buf, kind)
+		case "ReadOnly":
+			transactionOptions.ReadOnly = bson.DecodeBool(buf, kind)
+		default:
+			bson.Skip(buf, kind)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto
+
+// ProtocolVersion is the version of the query service protocol. The
+// clients and vttablets exchange their version in GetSessionId, so
+// either side can adapt to an older peer during a rolling upgrade.
+// The vttablets that predate the negotiation don't send one, and are
+// version 0. Bump it when a change needs more than a new feature.
+const ProtocolVersion = 1
+
+// The optional features of the query service. A vttablet lists the
+// ones it supports in its SessionInfo, and the clients return an
+// error instead of sending a request it wouldn't understand. The
+// names are sent on the wire.
+const (
+	// FeaturePrepare is Prepare, ExecutePrepared and ClosePrepared.
+	FeaturePrepare = "Prepare"
+
+	// FeatureExplain is Explain.
+	FeatureExplain = "Explain"
+
+	// FeatureTransactionOptions is the TransactionOptions of Begin.
+	// An older vttablet would silently ignore them.
+	FeatureTransactionOptions = "TransactionOptions"
+
+	// FeatureMessages is MessageStream and MessageAck.
+	FeatureMessages = "Messages"
+
+	// FeatureExportTable is ExportTable.
+	FeatureExportTable = "ExportTable"
+
+	// FeatureIdempotencyKeys is the IdempotencyKey of Execute.
+	// An older vttablet would apply the retried writes again.
+	FeatureIdempotencyKeys = "IdempotencyKeys"
+
+	// FeatureFieldsOnly is the FieldsOnly of Execute and
+	// StreamExecute. An older vttablet would run the query and
+	// return all its rows.
+	FeatureFieldsOnly = "FieldsOnly"
+)
+
+// SupportedFeatures returns the features of this version.
+func SupportedFeatures() []string {
+	return []string{
+		FeaturePrepare,
+		FeatureExplain,
+		FeatureTransactionOptions,
+		FeatureMessages,
+		FeatureExportTable,
+		FeatureIdempotencyKeys,
+		FeatureFieldsOnly,
+	}
+}
+
+// HasFeature returns true if the vttablet supports the feature.
+func (sessionInfo *SessionInfo) HasFeature(feature string) bool {
+	for _, f := range sessionInfo.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
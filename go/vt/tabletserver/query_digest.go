@@ -0,0 +1,324 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"container/heap"
+	"encoding/json"
+	"flag"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/youtube/vitess/go/acl"
+)
+
+var (
+	queryDigestSize    = flag.Int("query-digest-size", 1000, "how many distinct query fingerprints to keep stats for")
+	queryDigestHandler = flag.String("query-digest-handler", "/debug/query_digests", "URL handler for the top query digests")
+
+	// queryDigests aggregates all the queries sent to SqlQueryLogger.
+	queryDigests = NewQueryDigests(1000)
+)
+
+// QueryDigest is the aggregated stats for all queries with the
+// same fingerprint.
+type QueryDigest struct {
+	Fingerprint string
+	Count       int64
+	// CountError is an upper bound for the overestimation of Count,
+	// when this digest replaced another one in a full QueryDigests.
+	CountError int64
+	TotalTime  time.Duration
+	MaxTime    time.Duration
+	Rows       int64
+	Errors     int64
+
+	// index is the position of the digest in the heap of its shard.
+	index int
+}
+
+const (
+	// queryDigestMaxShards is the maximum number of shards of a
+	// QueryDigests.
+	queryDigestMaxShards = 16
+
+	// queryDigestMinShardSize is the minimum capacity of a shard:
+	// the smaller the shards, the less accurate the counts.
+	queryDigestMinShardSize = 64
+)
+
+// QueryDigests keeps a QueryDigest for a bounded number of query
+// fingerprints. When it is full, a new fingerprint replaces the
+// digest with the lowest count, and inherits its count (this is
+// the space-saving algorithm): frequent queries are never evicted,
+// and their counts are overestimated by at most CountError.
+//
+// Record is called for every query, so the fingerprints are split
+// in shards that each have their own lock, and each shard keeps its
+// digests in a min-heap to find the lowest count one in O(1).
+type QueryDigests struct {
+	// shards is a []*queryDigestShard, replaced by SetCapacity.
+	shards atomic.Value
+}
+
+// queryDigestShard is the space-saving algorithm for a subset of the
+// fingerprints.
+type queryDigestShard struct {
+	mu       sync.Mutex
+	capacity int
+	digests  map[string]*QueryDigest
+	heap     queryDigestHeap
+}
+
+// NewQueryDigests returns a QueryDigests with the provided capacity.
+func NewQueryDigests(capacity int) *QueryDigests {
+	qd := &QueryDigests{}
+	qd.SetCapacity(capacity)
+	return qd
+}
+
+// SetCapacity changes the capacity, and resets the digests.
+func (qd *QueryDigests) SetCapacity(capacity int) {
+	if capacity <= 0 {
+		qd.shards.Store([]*queryDigestShard(nil))
+		return
+	}
+	count := capacity / queryDigestMinShardSize
+	if count > queryDigestMaxShards {
+		count = queryDigestMaxShards
+	}
+	if count < 1 {
+		count = 1
+	}
+	shards := make([]*queryDigestShard, count)
+	for i := range shards {
+		shards[i] = &queryDigestShard{
+			capacity: capacity / count,
+			digests:  make(map[string]*QueryDigest),
+		}
+		if i < capacity%count {
+			shards[i].capacity++
+		}
+	}
+	qd.shards.Store(shards)
+}
+
+// Record adds a query to its digest. It is a no-op if the capacity is 0.
+func (qd *QueryDigests) Record(stats *SQLQueryStats) {
+	if stats.OriginalSql == "" {
+		return
+	}
+	shards := qd.shards.Load().([]*queryDigestShard)
+	if len(shards) == 0 {
+		return
+	}
+	fingerprint := queryFingerprint(stats.OriginalSql)
+	h := fnv.New32a()
+	h.Write([]byte(fingerprint))
+	shards[h.Sum32()%uint32(len(shards))].record(fingerprint, stats)
+}
+
+func (s *queryDigestShard) record(fingerprint string, stats *SQLQueryStats) {
+	duration := stats.TotalTime()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	digest, ok := s.digests[fingerprint]
+	if !ok {
+		digest = &QueryDigest{Fingerprint: fingerprint}
+		if len(s.heap) >= s.capacity {
+			min := s.heap[0]
+			delete(s.digests, min.Fingerprint)
+			digest.Count = min.Count
+			digest.CountError = min.Count
+			digest.index = 0
+			s.heap[0] = digest
+		} else {
+			heap.Push(&s.heap, digest)
+		}
+		s.digests[fingerprint] = digest
+	}
+	digest.Count++
+	heap.Fix(&s.heap, digest.index)
+	digest.TotalTime += duration
+	if duration > digest.MaxTime {
+		digest.MaxTime = duration
+	}
+	digest.Rows += int64(len(stats.Rows)) + int64(stats.RowsAffected)
+	if stats.Error != nil {
+		digest.Errors++
+	}
+}
+
+// queryDigestHeap is a min-heap of digests, on their count.
+type queryDigestHeap []*QueryDigest
+
+func (h queryDigestHeap) Len() int {
+	return len(h)
+}
+
+func (h queryDigestHeap) Less(i, j int) bool {
+	return h[i].Count < h[j].Count
+}
+
+func (h queryDigestHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *queryDigestHeap) Push(x interface{}) {
+	digest := x.(*QueryDigest)
+	digest.index = len(*h)
+	*h = append(*h, digest)
+}
+
+func (h *queryDigestHeap) Pop() interface{} {
+	old := *h
+	digest := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return digest
+}
+
+// queryDigestSorters are the orders supported by Top.
+var queryDigestSorters = map[string]func(d1, d2 *QueryDigest) bool{
+	"count": func(d1, d2 *QueryDigest) bool { return d1.Count > d2.Count },
+	"time":  func(d1, d2 *QueryDigest) bool { return d1.TotalTime > d2.TotalTime },
+	"max":   func(d1, d2 *QueryDigest) bool { return d1.MaxTime > d2.MaxTime },
+	"rows":  func(d1, d2 *QueryDigest) bool { return d1.Rows > d2.Rows },
+}
+
+// Top returns copies of the n first digests, in the order given by
+// sortBy ("count", "time", "max" or "rows"). n <= 0 returns all of them.
+func (qd *QueryDigests) Top(n int, sortBy string) []QueryDigest {
+	less, ok := queryDigestSorters[sortBy]
+	if !ok {
+		less = queryDigestSorters["time"]
+	}
+
+	result := make([]QueryDigest, 0)
+	for _, s := range qd.shards.Load().([]*queryDigestShard) {
+		s.mu.Lock()
+		for _, digest := range s.digests {
+			result = append(result, *digest)
+		}
+		s.mu.Unlock()
+	}
+
+	sort.Sort(&queryDigestSorter{digests: result, less: less})
+	if n > 0 && n < len(result) {
+		result = result[:n]
+	}
+	return result
+}
+
+type queryDigestSorter struct {
+	digests []QueryDigest
+	less    func(d1, d2 *QueryDigest) bool
+}
+
+func (sorter *queryDigestSorter) Len() int {
+	return len(sorter.digests)
+}
+
+func (sorter *queryDigestSorter) Swap(i, j int) {
+	sorter.digests[i], sorter.digests[j] = sorter.digests[j], sorter.digests[i]
+}
+
+func (sorter *queryDigestSorter) Less(i, j int) bool {
+	return sorter.less(&sorter.digests[i], &sorter.digests[j])
+}
+
+// queryFingerprint returns the query with its literal strings and
+// numbers replaced by '?', and its whitespaces collapsed.
+func queryFingerprint(sql string) string {
+	result := make([]byte, 0, len(sql))
+	space := false
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			continue
+		case c == '\'' || c == '"':
+			// skip to the end of the string, handling escapes
+			for i++; i < len(sql) && sql[i] != c; i++ {
+				if sql[i] == '\\' {
+					i++
+				}
+			}
+			c = '?'
+		case c >= '0' && c <= '9' && (len(result) == 0 || !isIdentifierChar(result[len(result)-1]) || space):
+			for i+1 < len(sql) && (isIdentifierChar(sql[i+1]) || sql[i+1] == '.') {
+				i++
+			}
+			c = '?'
+		}
+		if space && len(result) > 0 {
+			result = append(result, ' ')
+		}
+		space = false
+		result = append(result, c)
+	}
+	return string(result)
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// LogQueryDigests writes the top n digests to the log.
+func LogQueryDigests(n int, sortBy string) {
+	for _, digest := range queryDigests.Top(n, sortBy) {
+		mlog.Infof("QueryDigest: count=%v(+/-%v) time=%v max=%v rows=%v errors=%v: %v", digest.Count, digest.CountError, digest.TotalTime, digest.MaxTime, digest.Rows, digest.Errors, digest.Fingerprint)
+	}
+}
+
+// registerQueryDigestHandler sets the digests capacity from the
+// flags, and serves the top digests as JSON. The URL parameters are:
+// - n: how many digests to return (default 20).
+// - sort: count, time (the default), max or rows.
+// - log: if set, the digests are also written to the log.
+func registerQueryDigestHandler() {
+	queryDigests.SetCapacity(*queryDigestSize)
+	if *queryDigestHandler == "" {
+		return
+	}
+	http.HandleFunc(*queryDigestHandler, func(w http.ResponseWriter, r *http.Request) {
+		if err := acl.CheckAccessHTTP(r, acl.DEBUGGING); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		n := 20
+		if value := r.FormValue("n"); value != "" {
+			var err error
+			if n, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "invalid n: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		sortBy := r.FormValue("sort")
+		if _, ok := r.Form["log"]; ok {
+			LogQueryDigests(n, sortBy)
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		b, err := json.MarshalIndent(queryDigests.Top(n, sortBy), "", "  ")
+		if err != nil {
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write(b)
+	})
+}
@@ -0,0 +1,96 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestQueryFingerprint(t *testing.T) {
+	testCases := []struct {
+		sql  string
+		want string
+	}{
+		{"select * from t1 where id = 10", "select * from t1 where id = ?"},
+		{"select *  from t1\n where id=10 and  name = 'a\\'b'", "select * from t1 where id=? and name = ?"},
+		{"select a1, b from t2 where c in (1, 2.5, \"x\")", "select a1, b from t2 where c in (?, ?, ?)"},
+		{"select * from t3 where id = :id", "select * from t3 where id = :id"},
+	}
+	for _, tc := range testCases {
+		if got := queryFingerprint(tc.sql); got != tc.want {
+			t.Errorf("queryFingerprint(%q) = %q, want %q", tc.sql, got, tc.want)
+		}
+	}
+}
+
+func newTestSQLQueryStats(sql string, duration time.Duration, err error) *SQLQueryStats {
+	now := time.Now()
+	return &SQLQueryStats{
+		OriginalSql:  sql,
+		StartTime:    now,
+		EndTime:      now.Add(duration),
+		RowsAffected: 1,
+		Error:        err,
+	}
+}
+
+func TestQueryDigests(t *testing.T) {
+	qd := NewQueryDigests(2)
+	for i := 0; i < 3; i++ {
+		qd.Record(newTestSQLQueryStats(fmt.Sprintf("select * from t1 where id = %v", i), time.Duration(i+1)*time.Millisecond, nil))
+	}
+	qd.Record(newTestSQLQueryStats("update t2 set a = 1", 10*time.Millisecond, fmt.Errorf("error")))
+
+	top := qd.Top(0, "count")
+	if len(top) != 2 {
+		t.Fatalf("Top returned %v digests: %v", len(top), top)
+	}
+	d := top[0]
+	if d.Fingerprint != "select * from t1 where id = ?" || d.Count != 3 || d.TotalTime != 6*time.Millisecond || d.MaxTime != 3*time.Millisecond || d.Rows != 3 || d.Errors != 0 {
+		t.Errorf("unexpected first digest: %+v", d)
+	}
+	d = top[1]
+	if d.Fingerprint != "update t2 set a = ?" || d.Count != 1 || d.Errors != 1 {
+		t.Errorf("unexpected second digest: %+v", d)
+	}
+
+	if top := qd.Top(1, "max"); len(top) != 1 || top[0].Fingerprint != "update t2 set a = ?" {
+		t.Errorf("unexpected Top(1, max): %v", top)
+	}
+
+	// a new fingerprint replaces the lowest count one, and inherits it
+	qd.Record(newTestSQLQueryStats("delete from t3", time.Millisecond, nil))
+	top = qd.Top(0, "count")
+	if len(top) != 2 || top[1].Fingerprint != "delete from t3" || top[1].Count != 2 || top[1].CountError != 1 {
+		t.Errorf("unexpected digests after eviction: %+v", top)
+	}
+
+	// capacity 0 disables the digests
+	qd.SetCapacity(0)
+	qd.Record(newTestSQLQueryStats("delete from t3", time.Millisecond, nil))
+	if top := qd.Top(0, "count"); len(top) != 0 {
+		t.Errorf("unexpected digests with capacity 0: %v", top)
+	}
+}
+
+func TestQueryDigestsShards(t *testing.T) {
+	qd := NewQueryDigests(200)
+	if got := len(qd.shards.Load().([]*queryDigestShard)); got != 3 {
+		t.Errorf("got %v shards, want 3", got)
+	}
+	for i := 0; i < 1000; i++ {
+		qd.Record(newTestSQLQueryStats("select * from t1 where id = 1", time.Millisecond, nil))
+		qd.Record(newTestSQLQueryStats(fmt.Sprintf("select * from t_%v", i), time.Millisecond, nil))
+	}
+	top := qd.Top(0, "count")
+	if len(top) != 200 {
+		t.Errorf("Top returned %v digests, want 200", len(top))
+	}
+	if d := top[0]; d.Fingerprint != "select * from t1 where id = ?" || d.Count != 1000 || d.CountError != 0 {
+		t.Errorf("unexpected first digest: %+v", d)
+	}
+}
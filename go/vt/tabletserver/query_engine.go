@@ -9,11 +9,11 @@ import (
 	"sync"
 	"time"
 
-	log "github.com/golang/glog"
 	"golang.org/x/net/context"
 
 	"github.com/youtube/vitess/go/stats"
 	"github.com/youtube/vitess/go/sync2"
+	"github.com/youtube/vitess/go/timer"
 	"github.com/youtube/vitess/go/vt/dbconfigs"
 	"github.com/youtube/vitess/go/vt/dbconnpool"
 	"github.com/youtube/vitess/go/vt/logutil"
@@ -42,30 +42,46 @@ const spotCheckMultiplier = 1e6
 type QueryEngine struct {
 	schemaInfo *SchemaInfo
 	dbconfigs  *dbconfigs.DBConfigs
+	mysqld     *mysqlctl.Mysqld
 
 	// Pools
 	cachePool      *CachePool
 	connPool       *ConnPool
 	streamConnPool *ConnPool
+	olapConnPool   *ConnPool
 
 	// Services
 	txPool       *TxPool
 	consolidator *sync2.Consolidator
 	invalidator  *RowcacheInvalidator
 	streamQList  *QueryList
+	messager     *messageManager
+	rowGC        *rowGC
+	heartbeat    *heartbeat
+	txThrottler  *txThrottler
+	workload     *workloadClassifier
+	idempotency  *idempotencyKeys
 	tasks        sync.WaitGroup
 
+	// deadlockTicks reads the latest deadlock in the background.
+	deadlockTicks *timer.Timer
+
 	// Vars
 	queryTimeout     sync2.AtomicDuration
+	olapQueryTimeout sync2.AtomicDuration
 	spotCheckFreq    sync2.AtomicInt64
 	strictMode       sync2.AtomicInt64
+	safeUpdates      sync2.AtomicInt64
 	maxResultSize    sync2.AtomicInt64
+	maxAffectedRows  sync2.AtomicInt64
+	tableRowLimits   *tableRowLimits
 	maxDMLRows       sync2.AtomicInt64
 	streamBufferSize sync2.AtomicInt64
 	strictTableAcl   bool
 
 	// loggers
 	accessCheckerLogger *logutil.ThrottledLogger
+	deadlockLogger      *logutil.ThrottledLogger
 }
 
 type compiledPlan struct {
@@ -118,6 +134,7 @@ func NewQueryEngine(config Config) *QueryEngine {
 	qe := &QueryEngine{}
 	qe.schemaInfo = NewSchemaInfo(
 		config.QueryCacheSize,
+		config.StatementCacheSize,
 		config.StatsPrefix,
 		map[string]string{
 			debugQueryPlansKey: config.DebugURLPrefix + "/query_plans",
@@ -148,6 +165,11 @@ func NewQueryEngine(config Config) *QueryEngine {
 		config.StreamPoolSize,
 		time.Duration(config.IdleTimeout*1e9),
 	)
+	qe.olapConnPool = NewConnPool(
+		config.PoolNamePrefix+"OlapConnPool",
+		config.OlapPoolSize,
+		time.Duration(config.IdleTimeout*1e9),
+	)
 
 	// Services
 	qe.txPool = NewTxPool(
@@ -162,26 +184,71 @@ func NewQueryEngine(config Config) *QueryEngine {
 	http.Handle(config.DebugURLPrefix+"/consolidations", qe.consolidator)
 	qe.invalidator = NewRowcacheInvalidator(config.StatsPrefix, qe)
 	qe.streamQList = NewQueryList()
+	qe.messager = newMessageManager(
+		qe,
+		time.Duration(config.MessagePollInterval*1e9),
+		time.Duration(config.MessageAckWait*1e9),
+		time.Duration(config.MessagePurgeAfter*1e9),
+		config.MessageBatchSize,
+	)
+	qe.rowGC = newRowGC(
+		qe,
+		config.StatsPrefix,
+		time.Duration(config.RowGCInterval*1e9),
+		config.RowGCBatchSize,
+		time.Duration(config.RowGCBatchInterval*1e9),
+	)
+	http.Handle(config.DebugURLPrefix+"/rowgc", qe.rowGC)
+	http.HandleFunc(config.DebugURLPrefix+"/safe_updates", qe.serveSafeUpdates)
+	qe.heartbeat = newHeartbeat(
+		qe,
+		config.StatsPrefix,
+		time.Duration(config.HeartbeatInterval*1e9),
+	)
+	qe.txThrottler = newTxThrottler(
+		config.StatsPrefix,
+		time.Duration(config.TxThrottleLag*1e9),
+		time.Duration(config.TxThrottleMaxLag*1e9),
+		time.Duration(config.TxThrottleDelay*1e9),
+		config.TxThrottleExempt,
+	)
+	qe.workload = newWorkloadClassifier(config.StatsPrefix, config.OlapUsers)
+	qe.idempotency = newIdempotencyKeys(
+		qe,
+		config.StatsPrefix,
+		time.Duration(config.IdempotencyKeyTTL*1e9),
+	)
+
+	qe.deadlockTicks = timer.NewTimer(time.Duration(config.DeadlockInterval * 1e9))
 
 	// Vars
 	qe.queryTimeout.Set(time.Duration(config.QueryTimeout * 1e9))
+	qe.olapQueryTimeout.Set(time.Duration(config.OlapQueryTimeout * 1e9))
 	qe.spotCheckFreq = sync2.AtomicInt64(config.SpotCheckRatio * spotCheckMultiplier)
 	if config.StrictMode {
 		qe.strictMode.Set(1)
 	}
+	if config.SafeUpdates {
+		qe.safeUpdates.Set(1)
+	}
 	qe.strictTableAcl = config.StrictTableAcl
 	qe.maxResultSize = sync2.AtomicInt64(config.MaxResultSize)
+	qe.maxAffectedRows = sync2.AtomicInt64(config.MaxAffectedRows)
+	qe.tableRowLimits = newTableRowLimits()
 	qe.maxDMLRows = sync2.AtomicInt64(config.MaxDMLRows)
 	qe.streamBufferSize = sync2.AtomicInt64(config.StreamBufferSize)
 
 	// loggers
 	qe.accessCheckerLogger = logutil.NewThrottledLogger("accessChecker", 1*time.Second)
+	qe.deadlockLogger = logutil.NewThrottledLogger("deadlocks", 1*time.Minute)
 
 	// Stats
 	stats.Publish(config.StatsPrefix+"MaxResultSize", stats.IntFunc(qe.maxResultSize.Get))
+	stats.Publish(config.StatsPrefix+"MaxAffectedRows", stats.IntFunc(qe.maxAffectedRows.Get))
 	stats.Publish(config.StatsPrefix+"MaxDMLRows", stats.IntFunc(qe.maxDMLRows.Get))
 	stats.Publish(config.StatsPrefix+"StreamBufferSize", stats.IntFunc(qe.streamBufferSize.Get))
 	stats.Publish(config.StatsPrefix+"QueryTimeout", stats.DurationFunc(qe.queryTimeout.Get))
+	stats.Publish(config.StatsPrefix+"OlapQueryTimeout", stats.DurationFunc(qe.olapQueryTimeout.Get))
 	queryStats = stats.NewTimings(config.StatsPrefix + "Queries")
 	qpsRates = stats.NewRates(config.StatsPrefix+"QPS", queryStats, 15, 60*time.Second)
 	waitStats = stats.NewTimings(config.StatsPrefix + "Waits")
@@ -201,6 +268,7 @@ func NewQueryEngine(config Config) *QueryEngine {
 // Open must be called before sending requests to QueryEngine.
 func (qe *QueryEngine) Open(dbconfigs *dbconfigs.DBConfigs, schemaOverrides []SchemaOverride, mysqld *mysqlctl.Mysqld) {
 	qe.dbconfigs = dbconfigs
+	qe.mysqld = mysqld
 	appParams := dbconfigs.App.ConnParams
 	// Create dba params based on App connection params
 	// and Dba credentials.
@@ -217,20 +285,25 @@ func (qe *QueryEngine) Open(dbconfigs *dbconfigs.DBConfigs, schemaOverrides []Sc
 	if !strictMode && dbconfigs.App.EnableRowcache {
 		panic(NewTabletError(ErrFatal, "Rowcache cannot be enabled when queryserver-config-strict-mode is false"))
 	}
+	// Any client can select the OLAP pool, its queries must have a
+	// timeout.
+	if qe.olapConnPool.capacity > 0 && qe.olapQueryTimeout.Get() <= 0 {
+		panic(NewTabletError(ErrFatal, "queryserver-config-olap-query-timeout must be positive when the olap pool is enabled"))
+	}
 	if dbconfigs.App.EnableRowcache {
 		qe.cachePool.Open()
-		log.Infof("rowcache is enabled")
+		mlog.Infof("rowcache is enabled")
 	} else {
 		// Invalidator should not be enabled if rowcache is not enabled.
 		dbconfigs.App.EnableInvalidator = false
-		log.Infof("rowcache is not enabled")
+		mlog.Infof("rowcache is not enabled")
 	}
 
 	start := time.Now()
 	// schemaInfo depends on cachePool. Every table that has a rowcache
 	// points to the cachePool.
 	qe.schemaInfo.Open(&appParams, &dbaParams, schemaOverrides, qe.cachePool, strictMode)
-	log.Infof("Time taken to load the schema: %v", time.Now().Sub(start))
+	mlog.Infof("Time taken to load the schema: %v", time.Now().Sub(start))
 
 	// Start the invalidator only after schema is loaded.
 	// This will allow qe to find the table info
@@ -241,7 +314,16 @@ func (qe *QueryEngine) Open(dbconfigs *dbconfigs.DBConfigs, schemaOverrides []Sc
 	}
 	qe.connPool.Open(&appParams, &dbaParams)
 	qe.streamConnPool.Open(&appParams, &dbaParams)
+	// The OLAP pool is disabled if it has no connections.
+	if qe.olapConnPool.capacity > 0 {
+		qe.olapConnPool.Open(&appParams, &dbaParams)
+	}
 	qe.txPool.Open(&appParams, &dbaParams)
+	qe.messager.Open()
+	qe.rowGC.Open()
+	qe.heartbeat.Open()
+	qe.idempotency.Open()
+	qe.deadlockTicks.Start(qe.pollDeadlocks)
 }
 
 // Launch launches the specified function inside a goroutine.
@@ -256,7 +338,7 @@ func (qe *QueryEngine) Launch(f func()) {
 			qe.tasks.Done()
 			if x := recover(); x != nil {
 				internalErrors.Add("Task", 1)
-				log.Errorf("task error: %v", x)
+				mlog.Errorf("task error: %v", x)
 			}
 		}()
 		f()
@@ -270,7 +352,7 @@ func (qe *QueryEngine) CheckMySQL() bool {
 		if IsConnErr(err) {
 			return false
 		}
-		log.Warningf("checking MySQL, unexpected error: %v", err)
+		mlog.Warningf("checking MySQL, unexpected error: %v", err)
 		return true
 	}
 	conn.Close()
@@ -290,7 +372,13 @@ func (qe *QueryEngine) WaitForTxEmpty() {
 func (qe *QueryEngine) Close() {
 	qe.tasks.Wait()
 	// Close in reverse order of Open.
+	qe.deadlockTicks.Stop()
+	qe.idempotency.Close()
+	qe.heartbeat.Close()
+	qe.rowGC.Close()
+	qe.messager.Close()
 	qe.txPool.Close()
+	qe.olapConnPool.Close()
 	qe.streamConnPool.Close()
 	qe.connPool.Close()
 	qe.invalidator.Close()
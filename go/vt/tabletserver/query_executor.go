@@ -6,9 +6,10 @@ package tabletserver
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/hack"
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/sqltypes"
@@ -28,6 +29,20 @@ type QueryExecutor struct {
 	ctx           context.Context
 	logStats      *SQLQueryStats
 	qe            *QueryEngine
+	// fieldsOnly makes the executor return the fields of the
+	// result from the plan, without running the query.
+	fieldsOnly bool
+	// olap makes the executor use the OLAP pool, see
+	// workloadClassifier.
+	olap bool
+	// idempotencyKey makes a DML apply only once, see
+	// idempotencyKeys.
+	idempotencyKey string
+	// typedBindVars are the client bind variables converted by
+	// typeBindVars. They're used instead of bindVars to generate
+	// the sql, bindVars keeps the go values for the query rules
+	// and the pk resolution.
+	typedBindVars map[string]*sqlparser.BindVariable
 }
 
 // poolConn is the interface implemented by users of this specialized pool.
@@ -42,6 +57,9 @@ func (qre *QueryExecutor) Execute() (reply *mproto.QueryResult) {
 	qre.logStats.TransactionID = qre.transactionID
 	planName := qre.plan.PlanId.String()
 	qre.logStats.PlanType = planName
+	if qre.plan.TableInfo != nil {
+		qre.logStats.Sensitive = qre.plan.TableInfo.Sensitive
+	}
 	defer func(start time.Time) {
 		duration := time.Now().Sub(start)
 		queryStats.Add(planName, duration)
@@ -57,8 +75,18 @@ func (qre *QueryExecutor) Execute() (reply *mproto.QueryResult) {
 
 	qre.checkPermissions()
 
-	if qre.plan.PlanId == planbuilder.PLAN_DDL {
+	if qre.idempotencyKey != "" && (qre.transactionID == 0 || !qre.plan.PlanId.IsDML() || qre.fieldsOnly) {
+		panic(NewTabletError(ErrFail, "idempotency keys are only supported for DMLs in transactions"))
+	}
+	if qre.fieldsOnly {
+		return qre.execFields()
+	}
+
+	switch qre.plan.PlanId {
+	case planbuilder.PLAN_DDL:
 		return qre.execDDL()
+	case planbuilder.PLAN_NEXTVAL:
+		return qre.execNextval()
 	}
 
 	if qre.transactionID != 0 {
@@ -70,6 +98,28 @@ func (qre *QueryExecutor) Execute() (reply *mproto.QueryResult) {
 		if qre.plan.TableInfo != nil && qre.plan.TableInfo.CacheType != schema.CACHE_NONE {
 			invalidator = conn.DirtyKeys(qre.plan.TableName)
 		}
+		if qre.plan.PlanId.IsDML() && qre.plan.TableInfo != nil && qre.plan.TableInfo.ReadOnly {
+			panic(NewTabletError(ErrFail, "table %s is read-only", qre.plan.TableName))
+		}
+		if qre.qe.safeUpdates.Get() != 0 && !conn.UnsafeUpdates {
+			if reason := qre.plan.UnboundedDMLReason(); reason != "" {
+				panic(NewTabletError(ErrFail, "unsafe update or delete, %s: set vt_safe_updates = 0 in the transaction to allow it", reason))
+			}
+		}
+		if qre.idempotencyKey != "" {
+			sqlHash := idempotencySQLHash(qre.query, qre.bindVars)
+			if result := qre.qe.idempotency.claim(qre.ctx, conn, qre.idempotencyKey, sqlHash); result != nil {
+				return result
+			}
+			// If the write fails, the transaction may still be
+			// committed: the key must not be.
+			defer func() {
+				if x := recover(); x != nil {
+					qre.qe.idempotency.release(qre.ctx, conn, qre.idempotencyKey)
+					panic(x)
+				}
+			}()
+		}
 		switch qre.plan.PlanId {
 		case planbuilder.PLAN_PASS_DML:
 			if qre.qe.strictMode.Get() != 0 {
@@ -86,9 +136,24 @@ func (qre *QueryExecutor) Execute() (reply *mproto.QueryResult) {
 			reply = qre.execDMLSubquery(conn, invalidator)
 		case planbuilder.PLAN_OTHER:
 			reply = qre.execSQL(conn, qre.query, true)
-		default: // select or set in a transaction, just count as select
+		case planbuilder.PLAN_SAVEPOINT:
+			// The dirty keys of the rolled back statements are
+			// kept: invalidating them on commit is harmless.
+			reply = qre.directFetch(conn, qre.plan.FullQuery, qre.bindVars, nil)
+		case planbuilder.PLAN_SET:
+			if qre.plan.SetKey == "vt_safe_updates" {
+				// only for this transaction
+				conn.UnsafeUpdates = getInt64(qre.plan.SetValue) == 0
+				reply = &mproto.QueryResult{}
+				break
+			}
+			reply = qre.execDirect(conn)
+		default: // select in a transaction, just count as select
 			reply = qre.execDirect(conn)
 		}
+		if qre.idempotencyKey != "" {
+			qre.qe.idempotency.record(qre.ctx, conn, qre.idempotencyKey, reply)
+		}
 	} else {
 		switch qre.plan.PlanId {
 		case planbuilder.PLAN_PASS_SELECT:
@@ -103,9 +168,11 @@ func (qre *QueryExecutor) Execute() (reply *mproto.QueryResult) {
 		case planbuilder.PLAN_SET:
 			reply = qre.execSet()
 		case planbuilder.PLAN_OTHER:
-			conn := qre.getConn(qre.qe.connPool)
+			conn := qre.getConn(qre.connPool())
 			defer conn.Recycle()
 			reply = qre.execSQL(conn, qre.query, true)
+		case planbuilder.PLAN_SAVEPOINT:
+			panic(NewTabletError(ErrFail, "savepoints are only allowed in transactions"))
 		default:
 			panic(NewTabletError(ErrNotInTx, "DMLs not allowed outside of transactions"))
 		}
@@ -117,10 +184,20 @@ func (qre *QueryExecutor) Execute() (reply *mproto.QueryResult) {
 func (qre *QueryExecutor) Stream(sendReply func(*mproto.QueryResult) error) {
 	qre.logStats.OriginalSql = qre.query
 	qre.logStats.PlanType = qre.plan.PlanId.String()
+	if qre.plan.TableInfo != nil {
+		qre.logStats.Sensitive = qre.plan.TableInfo.Sensitive
+	}
 	defer queryStats.Record(qre.plan.PlanId.String(), time.Now())
 
 	qre.checkPermissions()
 
+	if qre.fieldsOnly {
+		if err := sendReply(qre.execFields()); err != nil {
+			panic(NewTabletError(ErrFail, "%v", err))
+		}
+		return
+	}
+
 	conn := qre.getConn(qre.qe.streamConnPool)
 	defer conn.Recycle()
 
@@ -188,6 +265,108 @@ func (qre *QueryExecutor) execDDL() *mproto.QueryResult {
 	return result
 }
 
+// execNextval hands out values from a sequence table. The values
+// are reserved from the table in blocks of 'cache' values, so most
+// calls don't need to go to MySQL. The sequence table must have a
+// single row with id = 0, next_id and cache columns.
+func (qre *QueryExecutor) execNextval() *mproto.QueryResult {
+	seq := qre.plan.TableInfo.Sequence
+	if seq == nil {
+		panic(NewTabletError(ErrFail, "%s is not a sequence table", qre.plan.TableName))
+	}
+	inc := getLimit(qre.plan.Limit, qre.bindVars)
+	if inc < 1 {
+		panic(NewTabletError(ErrFail, "invalid increment for sequence %s: %d", qre.plan.TableName, inc))
+	}
+
+	seq.mu.Lock()
+	defer seq.mu.Unlock()
+	if seq.NextVal == 0 || seq.NextVal+inc > seq.LastVal {
+		qre.reserveSequenceBlock(seq, inc)
+	}
+	ret := seq.NextVal
+	seq.NextVal += inc
+	return &mproto.QueryResult{
+		Fields:       nextvalFields,
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{sqltypes.MakeNumeric(strconv.AppendInt(nil, ret, 10))},
+		},
+	}
+}
+
+var nextvalFields = []mproto.Field{{Name: "nextval", Type: mproto.VT_LONGLONG}}
+
+// execFields returns the fields of the result of the query, as
+// cached in its plan. Statements that don't return rows have
+// no fields.
+func (qre *QueryExecutor) execFields() *mproto.QueryResult {
+	switch {
+	case qre.plan.Fields != nil:
+		return &mproto.QueryResult{Fields: qre.plan.Fields}
+	case qre.plan.PlanId.IsSelect(), qre.plan.PlanId == planbuilder.PLAN_OTHER:
+		panic(NewTabletError(ErrFail, "fields are not known without executing the query"))
+	}
+	return &mproto.QueryResult{}
+}
+
+// reserveSequenceBlock reserves enough values from the sequence
+// table to hand out inc more values. It must be called with the
+// sequence locked. The block is only used once it's committed.
+func (qre *QueryExecutor) reserveSequenceBlock(seq *SequenceInfo, inc int64) {
+	txid := qre.qe.txPool.Begin(qre.ctx)
+	nextVal, lastVal, err := qre.advanceSequence(txid, seq.NextVal, seq.LastVal, inc)
+	if err != nil {
+		qre.qe.txPool.Rollback(qre.ctx, txid)
+		panic(err)
+	}
+	if _, err := qre.qe.txPool.SafeCommit(qre.ctx, txid); err != nil {
+		panic(err)
+	}
+	seq.NextVal, seq.LastVal = nextVal, lastVal
+}
+
+// advanceSequence moves next_id of the sequence table forward by
+// a multiple of its cache value, and returns the new block.
+func (qre *QueryExecutor) advanceSequence(txid, nextVal, lastVal, inc int64) (int64, int64, error) {
+	conn := qre.qe.txPool.Get(txid)
+	defer conn.Recycle()
+
+	query := fmt.Sprintf("select next_id, cache from `%s` where id = 0 for update", qre.plan.TableName)
+	qr, err := qre.execSQLNoPanic(conn, query, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(qr.Rows) != 1 {
+		return 0, 0, NewTabletError(ErrFail, "unexpected rows from reading sequence %s: %d", qre.plan.TableName, len(qr.Rows))
+	}
+	nextID, err := qr.Rows[0][0].ParseInt64()
+	if err != nil {
+		return 0, 0, NewTabletError(ErrFail, "error loading sequence %s: %v", qre.plan.TableName, err)
+	}
+	cache, err := qr.Rows[0][1].ParseInt64()
+	if err != nil {
+		return 0, 0, NewTabletError(ErrFail, "error loading sequence %s: %v", qre.plan.TableName, err)
+	}
+	if cache < 1 {
+		return 0, 0, NewTabletError(ErrFail, "invalid cache value for sequence %s: %d", qre.plan.TableName, cache)
+	}
+	// If next_id moved since our last block, the values in
+	// between were handed out by another tablet.
+	if nextVal == 0 || nextID != lastVal {
+		nextVal = nextID
+	}
+	lastVal = nextID + cache
+	for lastVal < nextVal+inc {
+		lastVal += cache
+	}
+	query = fmt.Sprintf("update `%s` set next_id = %d where id = 0", qre.plan.TableName, lastVal)
+	if _, err := qre.execSQLNoPanic(conn, query, false); err != nil {
+		return 0, 0, err
+	}
+	return nextVal, lastVal, nil
+}
+
 func (qre *QueryExecutor) execPKIN() (result *mproto.QueryResult) {
 	pkRows, err := buildValueList(qre.plan.TableInfo, qre.plan.PKValues, qre.bindVars)
 	if err != nil {
@@ -298,8 +477,8 @@ func (qre *QueryExecutor) recheckLater(rcresult RCResult, dbrow []sqltypes.Value
 	if reloaded.Row == nil || reloaded.Cas != rcresult.Cas {
 		return
 	}
-	log.Warningf("query: %v", qre.plan.FullQuery)
-	log.Warningf("mismatch for: %v\ncache: %v\ndb:    %v", pk, rcresult.Row, dbrow)
+	mlog.Warningf("query: %v", qre.plan.FullQuery)
+	mlog.Warningf("mismatch for: %v\ncache: %v\ndb:    %v", pk, rcresult.Row, dbrow)
 	internalErrors.Add("Mismatch", 1)
 }
 
@@ -322,7 +501,7 @@ func (qre *QueryExecutor) execSelect() (result *mproto.QueryResult) {
 		result.Fields = qre.plan.Fields
 		return
 	}
-	conn := qre.getConn(qre.qe.connPool)
+	conn := qre.getConn(qre.connPool())
 	defer conn.Recycle()
 	return qre.fullFetch(conn, qre.plan.FullQuery, qre.bindVars, nil)
 }
@@ -424,6 +603,19 @@ func (qre *QueryExecutor) execSet() (result *mproto.QueryResult) {
 	switch qre.plan.SetKey {
 	case "vt_pool_size":
 		qre.qe.connPool.SetCapacity(int(getInt64(qre.plan.SetValue)))
+	case "vt_olap_pool_size":
+		val := getInt64(qre.plan.SetValue)
+		// The olap pool is only opened at startup, if it has
+		// connections: it can't be enabled afterwards.
+		if qre.qe.olapConnPool.Capacity() == 0 {
+			panic(NewTabletError(ErrFail, "vt_olap_pool_size cannot be set, the olap pool is disabled (-queryserver-config-olap-pool-size)"))
+		}
+		if val < 1 {
+			panic(NewTabletError(ErrFail, "vt_olap_pool_size out of range %v", val))
+		}
+		if err := qre.qe.olapConnPool.SetCapacity(int(val)); err != nil {
+			panic(NewTabletError(ErrFail, "%v", err))
+		}
 	case "vt_stream_pool_size":
 		qre.qe.streamConnPool.SetCapacity(int(getInt64(qre.plan.SetValue)))
 	case "vt_transaction_cap":
@@ -434,12 +626,20 @@ func (qre *QueryExecutor) execSet() (result *mproto.QueryResult) {
 		qre.qe.schemaInfo.SetReloadTime(getDuration(qre.plan.SetValue))
 	case "vt_query_cache_size":
 		qre.qe.schemaInfo.SetQueryCacheSize(int(getInt64(qre.plan.SetValue)))
+	case "vt_statement_cache_size":
+		qre.qe.schemaInfo.SetStatementCacheSize(int(getInt64(qre.plan.SetValue)))
 	case "vt_max_result_size":
 		val := getInt64(qre.plan.SetValue)
 		if val < 1 {
 			panic(NewTabletError(ErrFail, "vt_max_result_size out of range %v", val))
 		}
 		qre.qe.maxResultSize.Set(val)
+	case "vt_max_affected_rows":
+		val := getInt64(qre.plan.SetValue)
+		if val < 1 {
+			panic(NewTabletError(ErrFail, "vt_max_affected_rows out of range %v", val))
+		}
+		qre.qe.maxAffectedRows.Set(val)
 	case "vt_max_dml_rows":
 		val := getInt64(qre.plan.SetValue)
 		if val < 1 {
@@ -454,19 +654,33 @@ func (qre *QueryExecutor) execSet() (result *mproto.QueryResult) {
 		qre.qe.streamBufferSize.Set(val)
 	case "vt_query_timeout":
 		qre.qe.queryTimeout.Set(getDuration(qre.plan.SetValue))
+	case "vt_olap_query_timeout":
+		val := getDuration(qre.plan.SetValue)
+		if val <= 0 {
+			panic(NewTabletError(ErrFail, "vt_olap_query_timeout out of range %v", val))
+		}
+		qre.qe.olapQueryTimeout.Set(val)
 	case "vt_idle_timeout":
 		t := getDuration(qre.plan.SetValue)
 		qre.qe.connPool.SetIdleTimeout(t)
 		qre.qe.streamConnPool.SetIdleTimeout(t)
+		qre.qe.olapConnPool.SetIdleTimeout(t)
 		qre.qe.txPool.pool.SetIdleTimeout(t)
 	case "vt_spot_check_ratio":
 		qre.qe.spotCheckFreq.Set(int64(getFloat64(qre.plan.SetValue) * spotCheckMultiplier))
 	case "vt_strict_mode":
 		qre.qe.strictMode.Set(getInt64(qre.plan.SetValue))
+	case "vt_safe_updates":
+		// The tablet-wide mode is only changed by admins, through
+		// the safe_updates debug page.
+		panic(NewTabletError(ErrFail, "vt_safe_updates can only be set in a transaction"))
 	case "vt_txpool_timeout":
 		t := getDuration(qre.plan.SetValue)
 		qre.qe.txPool.SetPoolTimeout(t)
 	default:
+		if qre.execSetTableRowLimit() {
+			break
+		}
 		conn := qre.getConn(qre.qe.connPool)
 		defer conn.Recycle()
 		return qre.directFetch(conn, qre.plan.FullQuery, qre.bindVars, nil)
@@ -474,6 +688,26 @@ func (qre *QueryExecutor) execSet() (result *mproto.QueryResult) {
 	return &mproto.QueryResult{}
 }
 
+// execSetTableRowLimit handles 'set <table>.vt_max_result_size = N'
+// and 'set <table>.vt_max_affected_rows = N'. Setting a limit to 0
+// makes the table use the global limit again. It returns false if
+// the statement is not setting a row limit.
+func (qre *QueryExecutor) execSetTableRowLimit() bool {
+	dot := strings.Index(qre.plan.SetKey, ".")
+	if dot == -1 {
+		return false
+	}
+	tableName, key := qre.plan.SetKey[:dot], qre.plan.SetKey[dot+1:]
+	if key != "vt_max_result_size" && key != "vt_max_affected_rows" {
+		return false
+	}
+	val := getInt64(qre.plan.SetValue)
+	if val < 0 {
+		panic(NewTabletError(ErrFail, "%s out of range %v", key, val))
+	}
+	return qre.qe.tableRowLimits.set(tableName, key, val)
+}
+
 func getInt64(v interface{}) int64 {
 	if ival, ok := v.(int64); ok {
 		return ival
@@ -510,6 +744,14 @@ func rowsAreEqual(row1, row2 []sqltypes.Value) bool {
 	return true
 }
 
+// connPool returns the pool the query runs in.
+func (qre *QueryExecutor) connPool() *ConnPool {
+	if qre.olap {
+		return qre.qe.olapConnPool
+	}
+	return qre.qe.connPool
+}
+
 func (qre *QueryExecutor) getConn(pool *ConnPool) *DBConn {
 	start := time.Now()
 	conn, err := pool.Get(qre.ctx)
@@ -529,7 +771,7 @@ func (qre *QueryExecutor) qFetch(logStats *SQLQueryStats, parsedQuery *sqlparser
 	if ok {
 		defer q.Broadcast()
 		waitingForConnectionStart := time.Now()
-		conn, err := qre.qe.connPool.Get(qre.ctx)
+		conn, err := qre.connPool().Get(qre.ctx)
 		logStats.WaitingForConnection += time.Now().Sub(waitingForConnectionStart)
 		if err != nil {
 			q.Err = NewTabletErrorSql(ErrFatal, err)
@@ -566,8 +808,20 @@ func (qre *QueryExecutor) fullStreamFetch(conn *DBConn, parsedQuery *sqlparser.P
 }
 
 func (qre *QueryExecutor) generateFinalSql(parsedQuery *sqlparser.ParsedQuery, bindVars map[string]interface{}, buildStreamComment []byte) string {
-	bindVars["#maxLimit"] = qre.qe.maxResultSize.Get() + 1
-	sql, err := parsedQuery.GenerateQuery(bindVars)
+	bindVars["#maxLimit"] = qre.rowLimit() + 1
+	genVars := bindVars
+	if len(qre.typedBindVars) != 0 {
+		genVars = make(map[string]interface{}, len(bindVars))
+		for name, value := range bindVars {
+			// the internal bind variables always win
+			if bv, ok := qre.typedBindVars[name]; ok && !strings.HasPrefix(name, "#") {
+				genVars[name] = bv
+			} else {
+				genVars[name] = value
+			}
+		}
+	}
+	sql, err := parsedQuery.GenerateQuery(genVars)
 	if err != nil {
 		panic(NewTabletError(ErrFail, "%s", err))
 	}
@@ -589,7 +843,33 @@ func (qre *QueryExecutor) execSQL(conn poolConn, sql string, wantfields bool) *m
 
 func (qre *QueryExecutor) execSQLNoPanic(conn poolConn, sql string, wantfields bool) (*mproto.QueryResult, error) {
 	defer qre.logStats.AddRewrittenSql(sql, time.Now())
-	return conn.Exec(qre.ctx, sql, int(qre.qe.maxResultSize.Get()), wantfields)
+	limit := qre.rowLimit()
+	result, err := conn.Exec(qre.ctx, sql, int(limit), wantfields)
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "row count exceeded") {
+		return nil, NewTabletError(ErrRowLimitExceeded, "Row count exceeded %d", limit)
+	}
+	return result, err
+}
+
+// rowLimit returns the maximum number of rows the query can fetch.
+// For DMLs, this is the number of rows they can affect, which
+// bounds the rows their subqueries can return. Per-table limits
+// take precedence over the global ones.
+func (qre *QueryExecutor) rowLimit() int64 {
+	if qre.plan == nil {
+		return qre.qe.maxResultSize.Get()
+	}
+	limit := qre.qe.tableRowLimits.get(qre.plan.TableName)
+	if qre.plan.PlanId.IsDML() {
+		if limit.maxAffectedRows != 0 {
+			return limit.maxAffectedRows
+		}
+		return qre.qe.maxAffectedRows.Get()
+	}
+	if limit.maxResultSize != 0 {
+		return limit.maxResultSize
+	}
+	return qre.qe.maxResultSize.Get()
 }
 
 func (qre *QueryExecutor) execStreamSQL(conn *DBConn, sql string, callback func(*mproto.QueryResult) error) {
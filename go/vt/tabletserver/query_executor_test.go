@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"html/template"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 	"time"
@@ -170,6 +172,167 @@ func TestQueryExecutorPlanDmlSubQuery(t *testing.T) {
 	checkEqual(t, expected, qre.Execute())
 }
 
+func TestQueryExecutorSafeUpdates(t *testing.T) {
+	db := setUpQueryExecutorTest()
+	query := "update test_table set addr = 3 where name = 1"
+	expandedQuery := "select pk from test_table where name = 1 limit 10001 for update"
+	expected := &mproto.QueryResult{}
+	db.AddQuery(query, expected)
+	db.AddQuery(expandedQuery, expected)
+	qre, sqlQuery := newTestQueryExecutor(
+		query, context.Background(), enableRowCache|enableTx|enableStrict|enableSafeUpdates)
+	defer sqlQuery.disallowQueries()
+	defer testCommitHelper(t, sqlQuery, qre)
+	checkPlanID(t, planbuilder.PLAN_DML_SUBQUERY, qre.plan.PlanId)
+
+	// updates without a limit are rejected
+	func() {
+		defer handleAndVerifyTabletError(t, "update without a limit should fail in safe updates mode", ErrFail)
+		qre.Execute()
+	}()
+
+	// but they can be allowed for the transaction
+	logStats := newSqlQueryStats("TestQueryExecutor", qre.ctx)
+	setQre := &QueryExecutor{
+		query:         "set vt_safe_updates = 0",
+		bindVars:      make(map[string]interface{}),
+		transactionID: qre.transactionID,
+		plan:          sqlQuery.qe.schemaInfo.GetPlan(qre.ctx, logStats, "set vt_safe_updates = 0"),
+		ctx:           qre.ctx,
+		logStats:      logStats,
+		qe:            sqlQuery.qe,
+	}
+	checkEqual(t, &mproto.QueryResult{}, setQre.Execute())
+	checkEqual(t, expected, qre.Execute())
+}
+
+func TestQueryExecutorSetSafeUpdates(t *testing.T) {
+	setUpQueryExecutorTest()
+	qre, sqlQuery := newTestQueryExecutor(
+		"set vt_safe_updates = 0", context.Background(), enableRowCache|enableStrict|enableSafeUpdates)
+	defer sqlQuery.disallowQueries()
+	checkPlanID(t, planbuilder.PLAN_SET, qre.plan.PlanId)
+
+	// outside a transaction, the set would change the mode for every client
+	func() {
+		defer handleAndVerifyTabletError(t, "set vt_safe_updates outside a transaction should fail", ErrFail)
+		qre.Execute()
+	}()
+	if qre.qe.safeUpdates.Get() == 0 {
+		t.Fatalf("safe updates were turned off by a client")
+	}
+
+	request, _ := http.NewRequest("GET", "/debug/safe_updates?enable=false", nil)
+	response := httptest.NewRecorder()
+	qre.qe.serveSafeUpdates(response, request)
+	if qre.qe.safeUpdates.Get() != 0 {
+		t.Errorf("safe updates weren't turned off")
+	}
+	if got, want := response.Body.String(), "safe updates: false\n"; got != want {
+		t.Errorf("safe_updates page: %q, want %q", got, want)
+	}
+
+	request, _ = http.NewRequest("GET", "/debug/safe_updates?enable=maybe", nil)
+	response = httptest.NewRecorder()
+	qre.qe.serveSafeUpdates(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("invalid enable value returned %v, want %v", response.Code, http.StatusBadRequest)
+	}
+}
+
+func TestQueryExecutorReadOnlyTable(t *testing.T) {
+	setUpQueryExecutorTest()
+	query := "update test_table set name = 2 where pk in (1)"
+	qre, sqlQuery := newTestQueryExecutor(
+		query, context.Background(), enableTx|enableStrict)
+	defer sqlQuery.disallowQueries()
+	defer testCommitHelper(t, sqlQuery, qre)
+	checkPlanID(t, planbuilder.PLAN_DML_PK, qre.plan.PlanId)
+	qre.plan.TableInfo.ReadOnly = true
+	defer handleAndVerifyTabletError(t, "DML on a read-only table should fail", ErrFail)
+	qre.Execute()
+}
+
+func TestQueryExecutorNextval(t *testing.T) {
+	db := setUpQueryExecutorTest()
+	db.AddQuery(baseShowTables, &mproto.QueryResult{
+		RowsAffected: 2,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{
+				sqltypes.MakeString([]byte("test_table")),
+				sqltypes.MakeString([]byte("USER TABLE")),
+				sqltypes.MakeString([]byte("1427325875")),
+				sqltypes.MakeString([]byte("")),
+			},
+			[]sqltypes.Value{
+				sqltypes.MakeString([]byte("seq")),
+				sqltypes.MakeString([]byte("USER TABLE")),
+				sqltypes.MakeString([]byte("1427325875")),
+				sqltypes.MakeString([]byte("vitess_sequence")),
+			},
+		},
+	})
+	db.AddQuery("describe `seq`", &mproto.QueryResult{
+		RowsAffected: 3,
+		Rows: [][]sqltypes.Value{
+			createTestTableDescribe("id"),
+			createTestTableDescribe("next_id"),
+			createTestTableDescribe("cache"),
+		},
+	})
+	db.AddQuery("show index from `seq`", &mproto.QueryResult{})
+	selectQuery := "select next_id, cache from `seq` where id = 0 for update"
+	addSequenceRow := func(nextID, cache string) {
+		db.AddQuery(selectQuery, &mproto.QueryResult{
+			RowsAffected: 1,
+			Rows: [][]sqltypes.Value{
+				[]sqltypes.Value{
+					sqltypes.MakeNumeric([]byte(nextID)),
+					sqltypes.MakeNumeric([]byte(cache)),
+				},
+			},
+		})
+	}
+	addSequenceRow("1", "3")
+	db.AddQuery("update `seq` set next_id = 4 where id = 0", &mproto.QueryResult{})
+
+	qre, sqlQuery := newTestQueryExecutor(
+		"select nextval(1) from seq", context.Background(), enableStrict)
+	defer sqlQuery.disallowQueries()
+	checkPlanID(t, planbuilder.PLAN_NEXTVAL, qre.plan.PlanId)
+	want := func(val string) *mproto.QueryResult {
+		return &mproto.QueryResult{
+			Fields:       []mproto.Field{{Name: "nextval", Type: mproto.VT_LONGLONG}},
+			RowsAffected: 1,
+			Rows: [][]sqltypes.Value{
+				[]sqltypes.Value{sqltypes.MakeNumeric([]byte(val))},
+			},
+		}
+	}
+	// the first call reserves [1, 4), the next two use it
+	checkEqual(t, want("1"), qre.Execute())
+	db.DeleteQuery(selectQuery)
+	checkEqual(t, want("2"), qre.Execute())
+	checkEqual(t, want("3"), qre.Execute())
+
+	// another tablet moved the sequence forward
+	addSequenceRow("10", "3")
+	db.AddQuery("update `seq` set next_id = 13 where id = 0", &mproto.QueryResult{})
+	checkEqual(t, want("10"), qre.Execute())
+
+	// the rest of the block is used before the new one
+	addSequenceRow("13", "3")
+	db.AddQuery("update `seq` set next_id = 16 where id = 0", &mproto.QueryResult{})
+	qre.plan = sqlQuery.qe.schemaInfo.GetPlan(qre.ctx, qre.logStats, "select nextval(:n) from seq")
+	qre.bindVars = map[string]interface{}{"n": int64(5)}
+	checkEqual(t, want("11"), qre.Execute())
+
+	// increments larger than the cache reserve more than one block
+	addSequenceRow("16", "3")
+	db.AddQuery("update `seq` set next_id = 22 where id = 0", &mproto.QueryResult{})
+	checkEqual(t, want("16"), qre.Execute())
+}
+
 func TestQueryExecutorPlanOtherWithinATransaction(t *testing.T) {
 	db := setUpQueryExecutorTest()
 	query := "show test_table"
@@ -187,6 +350,37 @@ func TestQueryExecutorPlanOtherWithinATransaction(t *testing.T) {
 	checkEqual(t, expected, qre.Execute())
 }
 
+func TestQueryExecutorPlanSavepointWithinATransaction(t *testing.T) {
+	db := setUpQueryExecutorTest()
+	query := "rollback to savepoint a"
+	expected := &mproto.QueryResult{
+		Fields: []mproto.Field{},
+		Rows:   [][]sqltypes.Value{},
+	}
+	db.AddQuery(query, expected)
+	qre, sqlQuery := newTestQueryExecutor(
+		query, context.Background(), enableTx|enableRowCache|enableSchemaOverrides|enableStrict)
+	defer sqlQuery.disallowQueries()
+	defer testCommitHelper(t, sqlQuery, qre)
+	checkPlanID(t, planbuilder.PLAN_SAVEPOINT, qre.plan.PlanId)
+	checkEqual(t, expected, qre.Execute())
+}
+
+func TestQueryExecutorPlanSavepointOutsideATransaction(t *testing.T) {
+	setUpQueryExecutorTest()
+	qre, sqlQuery := newTestQueryExecutor(
+		"savepoint a",
+		context.Background(),
+		enableRowCache|enableStrict)
+	defer sqlQuery.disallowQueries()
+	checkPlanID(t, planbuilder.PLAN_SAVEPOINT, qre.plan.PlanId)
+	defer handleAndVerifyTabletError(
+		t,
+		"savepoint should fail because it is outside a transaction",
+		ErrFail)
+	qre.Execute()
+}
+
 func TestQueryExecutorPlanPassSelectWithInATransaction(t *testing.T) {
 	db := setUpQueryExecutorTest()
 	fields := []mproto.Field{
@@ -253,6 +447,45 @@ func TestQueryExecutorPlanPassSelect(t *testing.T) {
 	checkEqual(t, expected, qre.Execute())
 }
 
+func TestQueryExecutorFieldsOnly(t *testing.T) {
+	db := setUpQueryExecutorTest()
+	query := "select * from test_table limit 1000"
+	// The query must not reach MySQL.
+	db.AddRejectedQuery(query)
+	db.AddQuery("select * from test_table where 1 != 1", &mproto.QueryResult{
+		Fields: getTestTableFields(),
+	})
+	expected := &mproto.QueryResult{
+		Fields: getTestTableFields(),
+	}
+
+	qre, sqlQuery := newTestQueryExecutor(
+		query, context.Background(), enableRowCache|enableSchemaOverrides|enableStrict)
+	defer sqlQuery.disallowQueries()
+	qre.fieldsOnly = true
+	checkEqual(t, expected, qre.Execute())
+
+	var streamed []*mproto.QueryResult
+	qre.Stream(func(qr *mproto.QueryResult) error {
+		streamed = append(streamed, qr)
+		return nil
+	})
+	checkEqual(t, []*mproto.QueryResult{expected}, streamed)
+}
+
+func TestQueryExecutorFieldsOnlyDML(t *testing.T) {
+	db := setUpQueryExecutorTest()
+	query := "update test_table set pk = foo()"
+	db.AddRejectedQuery(query)
+
+	qre, sqlQuery := newTestQueryExecutor(query, context.Background(), enableTx)
+	defer sqlQuery.disallowQueries()
+	defer testCommitHelper(t, sqlQuery, qre)
+	checkPlanID(t, planbuilder.PLAN_PASS_DML, qre.plan.PlanId)
+	qre.fieldsOnly = true
+	checkEqual(t, &mproto.QueryResult{}, qre.Execute())
+}
+
 func TestQueryExecutorPlanPKIn(t *testing.T) {
 	db := setUpQueryExecutorTest()
 	query := "select * from test_table where pk in (1, 2, 3) limit 1000"
@@ -412,6 +645,18 @@ func TestQueryExecutorPlanSet(t *testing.T) {
 	}
 	sqlQuery.disallowQueries()
 
+	// set vt_statement_cache_size
+	vtStatementCacheSize := int64(53)
+	setQuery = fmt.Sprintf("set vt_statement_cache_size = %d", vtStatementCacheSize)
+	qre, sqlQuery = newTestQueryExecutor(
+		setQuery, context.Background(), enableRowCache|enableStrict)
+	checkPlanID(t, planbuilder.PLAN_SET, qre.plan.PlanId)
+	checkEqual(t, expected, qre.Execute())
+	if int64(qre.qe.schemaInfo.statements.Capacity()) != vtStatementCacheSize {
+		t.Fatalf("set query failed, expected to have vt_statement_cache_size: %d, but got: %d", vtStatementCacheSize, qre.qe.schemaInfo.statements.Capacity())
+	}
+	sqlQuery.disallowQueries()
+
 	// set vt_query_timeout
 	vtQueryTimeout := int64(61)
 	setQuery = fmt.Sprintf("set vt_query_timeout = %d", vtQueryTimeout)
@@ -529,6 +774,101 @@ func TestQueryExecutorPlanSetMaxDmlRows(t *testing.T) {
 	qre.Execute()
 }
 
+func TestQueryExecutorPlanSetMaxAffectedRows(t *testing.T) {
+	setUpQueryExecutorTest()
+	expected := &mproto.QueryResult{}
+	vtMaxAffectedRows := int64(64)
+	setQuery := fmt.Sprintf("set vt_max_affected_rows = %d", vtMaxAffectedRows)
+	qre, sqlQuery := newTestQueryExecutor(
+		setQuery, context.Background(), enableRowCache|enableStrict)
+	defer sqlQuery.disallowQueries()
+	checkPlanID(t, planbuilder.PLAN_SET, qre.plan.PlanId)
+	checkEqual(t, expected, qre.Execute())
+	if qre.qe.maxAffectedRows.Get() != vtMaxAffectedRows {
+		t.Fatalf("set query failed, expected to have vt_max_affected_rows: %d, but got: %d", vtMaxAffectedRows, qre.qe.maxAffectedRows.Get())
+	}
+	// set vt_max_affected_rows fail
+	setQuery = "set vt_max_affected_rows = 0"
+	qre, sqlQuery = newTestQueryExecutor(
+		setQuery, context.Background(), enableRowCache|enableStrict)
+	defer sqlQuery.disallowQueries()
+	checkPlanID(t, planbuilder.PLAN_SET, qre.plan.PlanId)
+	defer handleAndVerifyTabletError(t, "vt_max_affected_rows out of range, should always larger than 0", ErrFail)
+	qre.Execute()
+}
+
+func TestQueryExecutorTableRowLimits(t *testing.T) {
+	db := setUpQueryExecutorTest()
+	setQuery := "set test_table.vt_max_result_size = 1"
+	qre, sqlQuery := newTestQueryExecutor(
+		setQuery, context.Background(), enableRowCache|enableStrict)
+	defer sqlQuery.disallowQueries()
+	checkPlanID(t, planbuilder.PLAN_SET, qre.plan.PlanId)
+	checkEqual(t, &mproto.QueryResult{}, qre.Execute())
+	if got := sqlQuery.qe.tableRowLimits.get("test_table").maxResultSize; got != 1 {
+		t.Fatalf("set query failed, expected test_table max result size: 1, but got: %d", got)
+	}
+
+	newQre := func(sql string) *QueryExecutor {
+		logStats := newSqlQueryStats("TestQueryExecutor", qre.ctx)
+		return &QueryExecutor{
+			query:    sql,
+			bindVars: make(map[string]interface{}),
+			plan:     sqlQuery.qe.schemaInfo.GetPlan(qre.ctx, logStats, sql),
+			ctx:      qre.ctx,
+			logStats: logStats,
+			qe:       sqlQuery.qe,
+		}
+	}
+
+	// the limit is appended to the query, and a typed error
+	// is returned when the table returns more rows
+	db.AddQuery("select * from test_table where 1 != 1", &mproto.QueryResult{
+		Fields: getTestTableFields(),
+	})
+	db.AddQuery("select * from test_table limit 2", &mproto.QueryResult{
+		Fields:       getTestTableFields(),
+		RowsAffected: 2,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{sqltypes.MakeNumeric([]byte("1")), sqltypes.MakeNumeric([]byte("1")), sqltypes.MakeNumeric([]byte("1"))},
+			[]sqltypes.Value{sqltypes.MakeNumeric([]byte("2")), sqltypes.MakeNumeric([]byte("2")), sqltypes.MakeNumeric([]byte("2"))},
+		},
+	})
+	func() {
+		defer handleAndVerifyTabletError(t, "select should exceed the row limit of test_table", ErrRowLimitExceeded)
+		newQre("select * from test_table").Execute()
+	}()
+
+	// setting the limit to 0 restores the global limit
+	checkEqual(t, &mproto.QueryResult{}, newQre("set test_table.vt_max_result_size = 0").Execute())
+	if got := sqlQuery.qe.tableRowLimits.get("test_table"); got != (rowLimit{}) {
+		t.Fatalf("test_table row limits should have been cleared, but got: %v", got)
+	}
+
+	// other qualified variables are passed through
+	db.AddQuery("set test_table.vt_max_dml_rows = 1", &mproto.QueryResult{})
+	newQre("set test_table.vt_max_dml_rows = 1").Execute()
+	if got := sqlQuery.qe.tableRowLimits.get("test_table"); got != (rowLimit{}) {
+		t.Fatalf("test_table row limits should not have changed, but got: %v", got)
+	}
+}
+
+func TestQueryExecutorMaxAffectedRows(t *testing.T) {
+	db := setUpQueryExecutorTest()
+	query := "update test_table set addr = 3 where name = 1"
+	expandedQuery := "select pk from test_table where name = 1 limit 6 for update"
+	expected := &mproto.QueryResult{}
+	db.AddQuery(query, expected)
+	db.AddQuery(expandedQuery, expected)
+	qre, sqlQuery := newTestQueryExecutor(
+		query, context.Background(), enableRowCache|enableTx|enableStrict)
+	defer sqlQuery.disallowQueries()
+	defer testCommitHelper(t, sqlQuery, qre)
+	checkPlanID(t, planbuilder.PLAN_DML_SUBQUERY, qre.plan.PlanId)
+	sqlQuery.qe.maxAffectedRows.Set(5)
+	checkEqual(t, expected, qre.Execute())
+}
+
 func TestQueryExecutorPlanSetStreamBufferSize(t *testing.T) {
 	setUpQueryExecutorTest()
 	expected := &mproto.QueryResult{}
@@ -726,6 +1066,7 @@ const (
 	enableSchemaOverrides
 	enableStrict
 	enableStrictTableAcl
+	enableSafeUpdates
 )
 
 // newTestQueryExecutor uses a package level variable testSqlQuery defined in sqlquery_test.go
@@ -754,6 +1095,11 @@ func newTestQueryExecutor(sql string, ctx context.Context, flags executorFlags)
 	} else {
 		config.StrictTableAcl = false
 	}
+	if flags&enableSafeUpdates > 0 {
+		config.SafeUpdates = true
+	} else {
+		config.SafeUpdates = false
+	}
 	sqlQuery := NewSqlQuery(config)
 	txID := int64(0)
 	keyspace := "test_keyspace"
@@ -0,0 +1,200 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/sqlparser"
+	"golang.org/x/net/context"
+)
+
+// warmupRowLimit is the max number of rows a warm-up probe reads.
+const warmupRowLimit = 1000
+
+// queryWarmer remembers the most used selects when the query
+// service stops, and replays them when it starts again, before
+// it serves. This fills the plan cache, and the MySQL buffer pool
+// for the queries that can run without bind variables. If there's
+// a warm-up file, the queries are saved in it, so they survive a
+// restart. The queries are saved normalized, with their literals
+// replaced by bind variables, so no data is kept. A client query
+// that isn't cached yet reuses the field info of the warmed plan
+// of its normalized form, see SchemaInfo.GetPlan.
+type queryWarmer struct {
+	maxQueries int
+	file       string
+	timeout    time.Duration
+
+	mu      sync.Mutex
+	queries []string
+	// total and done are the progress of the current warm-up.
+	total   int
+	done    int
+	warming bool
+}
+
+func newQueryWarmer(maxQueries int, file string, timeout time.Duration) *queryWarmer {
+	return &queryWarmer{
+		maxQueries: maxQueries,
+		file:       file,
+		timeout:    timeout,
+	}
+}
+
+// save remembers the most used selects of the plan cache.
+func (qw *queryWarmer) save(si *SchemaInfo) {
+	if qw.maxQueries <= 0 {
+		return
+	}
+	queries := si.getTopQueries(qw.maxQueries)
+	qw.mu.Lock()
+	qw.queries = queries
+	qw.mu.Unlock()
+	if qw.file == "" {
+		return
+	}
+	data, err := json.Marshal(queries)
+	if err != nil {
+		mlog.Errorf("Could not encode the warm-up queries: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(qw.file, data, 0600); err != nil {
+		mlog.Errorf("Could not save the warm-up queries: %v", err)
+	}
+}
+
+// load returns the queries to replay. The ones saved by this
+// process win over the ones of the warm-up file.
+func (qw *queryWarmer) load() []string {
+	qw.mu.Lock()
+	queries := qw.queries
+	qw.mu.Unlock()
+	if queries != nil || qw.file == "" {
+		return queries
+	}
+	data, err := ioutil.ReadFile(qw.file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			mlog.Errorf("Could not read the warm-up queries: %v", err)
+		}
+		return nil
+	}
+	if err := json.Unmarshal(data, &queries); err != nil {
+		mlog.Errorf("Could not decode the warm-up queries: %v", err)
+		return nil
+	}
+	if len(queries) > qw.maxQueries {
+		queries = queries[:qw.maxQueries]
+	}
+	return queries
+}
+
+// warm replays the saved queries. It stops once its timeout
+// has passed, and does nothing if a warm-up is already going on.
+func (qw *queryWarmer) warm(qe *QueryEngine) {
+	if qw.maxQueries <= 0 {
+		return
+	}
+	queries := qw.load()
+	if len(queries) == 0 {
+		return
+	}
+	qw.mu.Lock()
+	if qw.warming {
+		qw.mu.Unlock()
+		return
+	}
+	qw.total = len(queries)
+	qw.done = 0
+	qw.warming = true
+	qw.mu.Unlock()
+	defer func() {
+		qw.mu.Lock()
+		qw.warming = false
+		qw.mu.Unlock()
+	}()
+
+	mlog.Infof("Warming up with %d queries", len(queries))
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), qw.timeout)
+	defer cancel()
+	for _, sql := range queries {
+		if ctx.Err() != nil {
+			mlog.Warningf("Warm-up timed out after %v", qw.timeout)
+			break
+		}
+		qw.warmQuery(ctx, qe, sql)
+		qw.mu.Lock()
+		qw.done++
+		qw.mu.Unlock()
+	}
+	mlog.Infof("Warm-up took %v", time.Now().Sub(start))
+}
+
+// warmQuery builds the plan of the query. If the query doesn't
+// need bind variables, it also reads up to warmupRowLimit rows.
+// The queries whose plan is already cached are skipped.
+func (qw *queryWarmer) warmQuery(ctx context.Context, qe *QueryEngine, sql string) {
+	defer func() {
+		if x := recover(); x != nil {
+			mlog.Warningf("Could not warm up %s: %v", sql, x)
+		}
+	}()
+	if qe.schemaInfo.getQuery(sql) != nil {
+		return
+	}
+	plan := qe.schemaInfo.GetPlan(ctx, newSqlQueryStats("Warmup", ctx), sql)
+	if !plan.PlanId.IsSelect() || plan.FullQuery == nil {
+		return
+	}
+	query, err := plan.FullQuery.GenerateQuery(map[string]interface{}{"#maxLimit": warmupRowLimit})
+	if err != nil {
+		// The query needs bind variables.
+		return
+	}
+	conn := getOrPanic(ctx, qe.connPool)
+	defer conn.Recycle()
+	if _, err := conn.Exec(ctx, string(query), warmupRowLimit, false); err != nil {
+		mlog.Warningf("Could not warm up %s: %v", sql, err)
+	}
+}
+
+// status returns the progress of the warm-up, or "" if there's
+// none going on.
+func (qw *queryWarmer) status() string {
+	qw.mu.Lock()
+	defer qw.mu.Unlock()
+	if !qw.warming {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d queries", qw.done, qw.total)
+}
+
+// normalizeQuery returns sql with its string and number literals
+// replaced by bind variables, or "" if sql can't be parsed.
+func normalizeQuery(sql string) string {
+	statement, err := sqlparser.Parse(sql)
+	if err != nil {
+		return ""
+	}
+	argCount := 0
+	buf := sqlparser.NewTrackedBuffer(func(buf *sqlparser.TrackedBuffer, node sqlparser.SQLNode) {
+		switch node.(type) {
+		case sqlparser.StrVal, sqlparser.NumVal:
+			argCount++
+			buf.WriteArg(fmt.Sprintf(":_warmup%d", argCount))
+		default:
+			node.Format(buf)
+		}
+	})
+	buf.Myprintf("%v", statement)
+	return buf.String()
+}
@@ -0,0 +1,228 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/tabletserver/fakesqldb"
+	"github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"golang.org/x/net/context"
+)
+
+func TestQueryWarmer(t *testing.T) {
+	db := setUpWarmupTest()
+	dir, err := ioutil.TempDir("", "warmup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := path.Join(dir, "queries.json")
+
+	sqlQuery := getWarmupSqlQuery(file)
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	ctx := context.Background()
+	execute := func(sql string, bindVars map[string]interface{}) {
+		query := proto.Query{Sql: sql, BindVariables: bindVars, SessionId: sqlQuery.sessionID}
+		if err := sqlQuery.Execute(ctx, &query, &mproto.QueryResult{}); err != nil {
+			t.Fatalf("Execute(%s) failed: %v", sql, err)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		execute("select * from test_table where name = :name", map[string]interface{}{"name": "a"})
+	}
+	// The queries that only differ by their literals add up.
+	execute("select addr from test_table where name = 'x'", nil)
+	execute("select addr from test_table where name = 'y'", nil)
+	execute("select addr from test_table where name = 'z'", nil)
+	for i := 0; i < 2; i++ {
+		execute("select * from test_table", nil)
+	}
+	sqlQuery.disallowQueries()
+
+	// Only the 2 most used queries are kept, without their literals.
+	want := []string{
+		"select * from test_table where name = :name",
+		"select addr from test_table where name = :_warmup1",
+	}
+	if got := sqlQuery.warmer.load(); !reflect.DeepEqual(got, want) {
+		t.Errorf("saved warm-up queries: %v, want %v", got, want)
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0600 {
+		t.Errorf("warm-up file mode: %v, want 0600", mode)
+	}
+
+	// A new process finds them in the warm-up file, and has
+	// their plans cached once it serves.
+	sqlQuery = getWarmupSqlQuery(file)
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	if state := sqlQuery.GetState(); state != "SERVING" {
+		t.Errorf("state: %s, want SERVING", state)
+	}
+	for _, sql := range want {
+		if sqlQuery.qe.schemaInfo.getQuery(sql) == nil {
+			t.Errorf("plan of %s is not cached", sql)
+		}
+	}
+
+	// The clients hit the warmed plan: it serves their query
+	// without being built again.
+	plan := sqlQuery.qe.schemaInfo.getQuery(want[0])
+	execute(want[0], map[string]interface{}{"name": "b"})
+	if got := sqlQuery.qe.schemaInfo.getQuery(want[0]); got != plan {
+		t.Errorf("plan of %s was built again", want[0])
+	}
+	if queryCount, _, _, _ := plan.Stats(); queryCount != 1 {
+		t.Errorf("warmed plan of %s served %v queries, want 1", want[0], queryCount)
+	}
+	// A client query with literals finds the warmed plan of its
+	// normalized form, and doesn't fetch the field info again.
+	db.AddRejectedQuery("select addr from test_table where 1 != 1")
+	execute("select addr from test_table where name = 'b'", nil)
+	client := sqlQuery.qe.schemaInfo.getQuery("select addr from test_table where name = 'b'")
+	if client == nil {
+		t.Fatalf("plan of the client query is not cached")
+	}
+	if warmed := sqlQuery.qe.schemaInfo.getQuery(want[1]); !reflect.DeepEqual(client.Fields, warmed.Fields) {
+		t.Errorf("client plan fields: %v, want the warmed ones %v", client.Fields, warmed.Fields)
+	}
+	if status := sqlQuery.warmer.status(); status != "" {
+		t.Errorf("warm-up status: %s, want empty", status)
+	}
+}
+
+func TestQueryWarmerDisabled(t *testing.T) {
+	setUpWarmupTest()
+	sqlQuery := getWarmupSqlQuery("")
+	sqlQuery.warmer.maxQueries = 0
+	sqlQuery.warmer.queries = []string{"select * from test_table"}
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	if sqlQuery.qe.schemaInfo.getQuery("select * from test_table") != nil {
+		t.Errorf("warm-up ran while disabled")
+	}
+}
+
+func TestQueryWarmerPromotion(t *testing.T) {
+	setUpWarmupTest()
+	sqlQuery := getWarmupSqlQuery("")
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	sqlQuery.warmer.queries = []string{"select * from test_table"}
+
+	sqlQuery.setIsMaster(false)
+	sqlQuery.requests.Wait()
+	if sqlQuery.qe.schemaInfo.getQuery("select * from test_table") != nil {
+		t.Errorf("warm-up ran without a promotion")
+	}
+	sqlQuery.setIsMaster(true)
+	sqlQuery.requests.Wait()
+	if sqlQuery.qe.schemaInfo.getQuery("select * from test_table") == nil {
+		t.Errorf("warm-up didn't run after the promotion")
+	}
+}
+
+func TestQueryWarmerPromotionNotServing(t *testing.T) {
+	setUpWarmupTest()
+	sqlQuery := getWarmupSqlQuery("")
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+
+	// The promotion comes before the query service serves, the
+	// warm-up waits for it.
+	sqlQuery.setIsMaster(true)
+	if !sqlQuery.warmPending {
+		t.Errorf("warm-up of the promotion is not pending")
+	}
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	sqlQuery.requests.Wait()
+	if sqlQuery.warmPending {
+		t.Errorf("warm-up of the promotion didn't run once serving")
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	testcases := []struct {
+		sql  string
+		want string
+	}{{
+		sql:  "select * from a where b = 'x' and c = 1",
+		want: "select * from a where b = :_warmup1 and c = :_warmup2",
+	}, {
+		sql:  "select * from a where b = :b",
+		want: "select * from a where b = :b",
+	}, {
+		sql:  "select * from",
+		want: "",
+	}}
+	for _, tcase := range testcases {
+		if got := normalizeQuery(tcase.sql); got != tcase.want {
+			t.Errorf("normalizeQuery(%q): %q, want %q", tcase.sql, got, tcase.want)
+		}
+	}
+}
+
+func setUpWarmupTest() *fakesqldb.DB {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	db.AddQuery("select * from test_table where 1 != 1", &mproto.QueryResult{
+		Fields: getTestTableFields(),
+	})
+	db.AddQuery("select addr from test_table where 1 != 1", &mproto.QueryResult{
+		Fields: getTestTableFields()[2:],
+	})
+	db.AddQuery("select * from test_table limit 1000", &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{
+				sqltypes.MakeNumeric([]byte("1")),
+				sqltypes.MakeNumeric([]byte("2")),
+				sqltypes.MakeNumeric([]byte("3")),
+			},
+		},
+	})
+	return db
+}
+
+func getWarmupSqlQuery(file string) *SqlQuery {
+	randID := rand.Int63()
+	config := DefaultQsConfig
+	config.StatsPrefix = fmt.Sprintf("Stats-%d-", randID)
+	config.DebugURLPrefix = fmt.Sprintf("/debug-%d-", randID)
+	config.RowCache.StatsPrefix = fmt.Sprintf("Stats-%d-", randID)
+	config.PoolNamePrefix = fmt.Sprintf("Pool-%d-", randID)
+	config.StrictMode = true
+	config.WarmupQueries = 2
+	config.WarmupFile = file
+	return NewSqlQuery(config)
+}
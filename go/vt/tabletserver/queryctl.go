@@ -12,15 +12,16 @@ import (
 	"strconv"
 	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/acl"
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/streamlog"
 	"github.com/youtube/vitess/go/sync2"
 	"github.com/youtube/vitess/go/vt/dbconfigs"
+	"github.com/youtube/vitess/go/vt/logutil"
 	"github.com/youtube/vitess/go/vt/mysqlctl"
 	"github.com/youtube/vitess/go/vt/tabletserver/proto"
 	"github.com/youtube/vitess/go/vt/tabletserver/queryservice"
+	"github.com/youtube/vitess/go/vt/topo"
 	"golang.org/x/net/context"
 )
 
@@ -29,6 +30,10 @@ var (
 	txLogHandler    = flag.String("transaction-log-stream-handler", "/debug/txlog", "URL handler for streaming transactions log")
 
 	checkMySLQThrottler = sync2.NewSemaphore(1, 0)
+
+	// mlog is the logger of the package, its level can be changed
+	// at runtime (see /debug/loglevel).
+	mlog = logutil.NewModuleLogger("tabletserver")
 )
 
 func init() {
@@ -37,9 +42,11 @@ func init() {
 	flag.IntVar(&qsConfig.TransactionCap, "queryserver-config-transaction-cap", DefaultQsConfig.TransactionCap, "query server transaction cap")
 	flag.Float64Var(&qsConfig.TransactionTimeout, "queryserver-config-transaction-timeout", DefaultQsConfig.TransactionTimeout, "query server transaction timeout")
 	flag.IntVar(&qsConfig.MaxResultSize, "queryserver-config-max-result-size", DefaultQsConfig.MaxResultSize, "query server max result size")
+	flag.IntVar(&qsConfig.MaxAffectedRows, "queryserver-config-max-affected-rows", DefaultQsConfig.MaxAffectedRows, "query server max rows an update or delete can affect")
 	flag.IntVar(&qsConfig.MaxDMLRows, "queryserver-config-max-dml-rows", DefaultQsConfig.MaxDMLRows, "query server max dml rows per statement")
 	flag.IntVar(&qsConfig.StreamBufferSize, "queryserver-config-stream-buffer-size", DefaultQsConfig.StreamBufferSize, "query server stream buffer size")
 	flag.IntVar(&qsConfig.QueryCacheSize, "queryserver-config-query-cache-size", DefaultQsConfig.QueryCacheSize, "query server query cache size")
+	flag.IntVar(&qsConfig.StatementCacheSize, "queryserver-config-statement-cache-size", DefaultQsConfig.StatementCacheSize, "query server max number of prepared statements")
 	flag.Float64Var(&qsConfig.SchemaReloadTime, "queryserver-config-schema-reload-time", DefaultQsConfig.SchemaReloadTime, "query server schema reload time")
 	flag.Float64Var(&qsConfig.QueryTimeout, "queryserver-config-query-timeout", DefaultQsConfig.QueryTimeout, "query server query timeout")
 	flag.Float64Var(&qsConfig.TxPoolTimeout, "queryserver-config-txpool-timeout", DefaultQsConfig.TxPoolTimeout, "query server transaction pool timeout")
@@ -47,6 +54,27 @@ func init() {
 	flag.Float64Var(&qsConfig.SpotCheckRatio, "queryserver-config-spot-check-ratio", DefaultQsConfig.SpotCheckRatio, "query server rowcache spot check frequency")
 	flag.BoolVar(&qsConfig.StrictMode, "queryserver-config-strict-mode", DefaultQsConfig.StrictMode, "allow only predictable DMLs and enforces MySQL's STRICT_TRANS_TABLES")
 	flag.BoolVar(&qsConfig.StrictTableAcl, "queryserver-config-strict-table-acl", DefaultQsConfig.StrictTableAcl, "only allow queries that pass table acl checks")
+	flag.BoolVar(&qsConfig.SafeUpdates, "queryserver-config-safe-updates", DefaultQsConfig.SafeUpdates, "reject updates and deletes without a where clause, or without a limit if they don't use the primary key")
+	flag.Float64Var(&qsConfig.MessagePollInterval, "queryserver-config-message-poll-interval", DefaultQsConfig.MessagePollInterval, "query server interval at which message tables are polled for messages to send")
+	flag.Float64Var(&qsConfig.MessageAckWait, "queryserver-config-message-ack-wait", DefaultQsConfig.MessageAckWait, "query server time after which an unacked message is sent again")
+	flag.Float64Var(&qsConfig.MessagePurgeAfter, "queryserver-config-message-purge-after", DefaultQsConfig.MessagePurgeAfter, "query server time after which acked messages are purged")
+	flag.IntVar(&qsConfig.MessageBatchSize, "queryserver-config-message-batch-size", DefaultQsConfig.MessageBatchSize, "query server max number of messages sent or purged at a time per table")
+	flag.Float64Var(&qsConfig.DeadlockInterval, "queryserver-config-deadlock-interval", DefaultQsConfig.DeadlockInterval, "query server interval at which the latest InnoDB deadlock is read, to keep the recent ones for /debug/lockz, 0 only reads it when /debug/lockz is loaded")
+	flag.Float64Var(&qsConfig.RowGCInterval, "queryserver-config-row-gc-interval", DefaultQsConfig.RowGCInterval, "query server interval at which the expired rows of the tables with a ttl are purged, 0 disables the row gc")
+	flag.IntVar(&qsConfig.RowGCBatchSize, "queryserver-config-row-gc-batch-size", DefaultQsConfig.RowGCBatchSize, "query server max number of expired rows deleted at a time")
+	flag.Float64Var(&qsConfig.RowGCBatchInterval, "queryserver-config-row-gc-batch-interval", DefaultQsConfig.RowGCBatchInterval, "query server pause between two deletes of expired rows, to throttle the row gc")
+	flag.Float64Var(&qsConfig.HeartbeatInterval, "queryserver-config-heartbeat-interval", DefaultQsConfig.HeartbeatInterval, "query server interval at which the master writes the heartbeat, and the replicas read it to measure their replication lag, 0 disables the heartbeat")
+	flag.Float64Var(&qsConfig.TxThrottleLag, "queryserver-config-tx-throttle-lag", DefaultQsConfig.TxThrottleLag, "query server replica lag above which the master starts throttling transactions, 0 disables the throttler")
+	flag.Float64Var(&qsConfig.TxThrottleMaxLag, "queryserver-config-tx-throttle-max-lag", DefaultQsConfig.TxThrottleMaxLag, "query server replica lag at which all the transactions are throttled, the throttled fraction grows linearly from the threshold")
+	flag.Float64Var(&qsConfig.TxThrottleDelay, "queryserver-config-tx-throttle-delay", DefaultQsConfig.TxThrottleDelay, "query server delay of the throttled transactions, 0 rejects them instead")
+	flag.StringVar(&qsConfig.TxThrottleExempt, "queryserver-config-tx-throttle-exempt", DefaultQsConfig.TxThrottleExempt, "comma separated list of users whose transactions are never throttled")
+	flag.IntVar(&qsConfig.OlapPoolSize, "queryserver-config-olap-pool-size", DefaultQsConfig.OlapPoolSize, "query server pool size for the analytic queries, selected with a '/* vt_workload=olap */' trailing comment or by user, so they can't starve the other queries of connections, 0 disables the olap pool")
+	flag.Float64Var(&qsConfig.OlapQueryTimeout, "queryserver-config-olap-query-timeout", DefaultQsConfig.OlapQueryTimeout, "query server query timeout for the analytic queries, in seconds, it must be positive: any client can select the olap pool, which would otherwise escape the query killer")
+	flag.StringVar(&qsConfig.OlapUsers, "queryserver-config-olap-users", DefaultQsConfig.OlapUsers, "comma separated list of users whose queries run in the olap pool")
+	flag.Float64Var(&qsConfig.IdempotencyKeyTTL, "queryserver-config-idempotency-key-ttl", DefaultQsConfig.IdempotencyKeyTTL, "query server time the idempotency keys of the writes are kept, a write retried later with the same key is applied again, 0 disables the idempotency keys")
+	flag.IntVar(&qsConfig.WarmupQueries, "queryserver-config-warmup-queries", DefaultQsConfig.WarmupQueries, "query server number of most used queries replayed to warm up before serving, 0 disables warm-up")
+	flag.StringVar(&qsConfig.WarmupFile, "queryserver-config-warmup-file", DefaultQsConfig.WarmupFile, "query server file where the warm-up queries are saved, so they survive a restart")
+	flag.Float64Var(&qsConfig.WarmupTimeout, "queryserver-config-warmup-timeout", DefaultQsConfig.WarmupTimeout, "query server max time spent warming up before serving")
 	flag.BoolVar(&qsConfig.TerseErrors, "queryserver-config-terse-errors", DefaultQsConfig.TerseErrors, "prevent bind vars from escaping in returned errors")
 	flag.StringVar(&qsConfig.RowCache.Binary, "rowcache-bin", DefaultQsConfig.RowCache.Binary, "rowcache binary file")
 	flag.IntVar(&qsConfig.RowCache.Memory, "rowcache-memory", DefaultQsConfig.RowCache.Memory, "rowcache max memory usage in MB")
@@ -97,26 +125,49 @@ func (c *RowCacheConfig) GetSubprocessFlags(socket string) []string {
 
 // Config contains all the configuration for query service
 type Config struct {
-	PoolSize           int
-	StreamPoolSize     int
-	TransactionCap     int
-	TransactionTimeout float64
-	MaxResultSize      int
-	MaxDMLRows         int
-	StreamBufferSize   int
-	QueryCacheSize     int
-	SchemaReloadTime   float64
-	QueryTimeout       float64
-	TxPoolTimeout      float64
-	IdleTimeout        float64
-	RowCache           RowCacheConfig
-	SpotCheckRatio     float64
-	StrictMode         bool
-	StrictTableAcl     bool
-	TerseErrors        bool
-	StatsPrefix        string
-	DebugURLPrefix     string
-	PoolNamePrefix     string
+	PoolSize            int
+	StreamPoolSize      int
+	TransactionCap      int
+	TransactionTimeout  float64
+	MaxResultSize       int
+	MaxAffectedRows     int
+	MaxDMLRows          int
+	StreamBufferSize    int
+	QueryCacheSize      int
+	StatementCacheSize  int
+	SchemaReloadTime    float64
+	QueryTimeout        float64
+	TxPoolTimeout       float64
+	IdleTimeout         float64
+	RowCache            RowCacheConfig
+	SpotCheckRatio      float64
+	StrictMode          bool
+	StrictTableAcl      bool
+	SafeUpdates         bool
+	MessagePollInterval float64
+	MessageAckWait      float64
+	MessagePurgeAfter   float64
+	MessageBatchSize    int
+	RowGCInterval       float64
+	DeadlockInterval    float64
+	RowGCBatchSize      int
+	RowGCBatchInterval  float64
+	HeartbeatInterval   float64
+	TxThrottleLag       float64
+	TxThrottleMaxLag    float64
+	TxThrottleDelay     float64
+	TxThrottleExempt    string
+	OlapPoolSize        int
+	OlapQueryTimeout    float64
+	OlapUsers           string
+	IdempotencyKeyTTL   float64
+	WarmupQueries       int
+	WarmupFile          string
+	WarmupTimeout       float64
+	TerseErrors         bool
+	StatsPrefix         string
+	DebugURLPrefix      string
+	PoolNamePrefix      string
 }
 
 // DefaultQSConfig is the default value for the query service config.
@@ -128,26 +179,49 @@ type Config struct {
 // great (the overhead makes the final packets on the wire about twice
 // bigger than this).
 var DefaultQsConfig = Config{
-	PoolSize:           16,
-	StreamPoolSize:     750,
-	TransactionCap:     20,
-	TransactionTimeout: 30,
-	MaxResultSize:      10000,
-	MaxDMLRows:         500,
-	QueryCacheSize:     5000,
-	SchemaReloadTime:   30 * 60,
-	QueryTimeout:       0,
-	TxPoolTimeout:      1,
-	IdleTimeout:        30 * 60,
-	StreamBufferSize:   32 * 1024,
-	RowCache:           RowCacheConfig{Memory: -1, Connections: -1, Threads: -1},
-	SpotCheckRatio:     0,
-	StrictMode:         true,
-	StrictTableAcl:     false,
-	TerseErrors:        false,
-	StatsPrefix:        "",
-	DebugURLPrefix:     "/debug",
-	PoolNamePrefix:     "",
+	PoolSize:            16,
+	StreamPoolSize:      750,
+	TransactionCap:      20,
+	TransactionTimeout:  30,
+	MaxResultSize:       10000,
+	MaxAffectedRows:     10000,
+	MaxDMLRows:          500,
+	QueryCacheSize:      5000,
+	StatementCacheSize:  5000,
+	SchemaReloadTime:    30 * 60,
+	QueryTimeout:        0,
+	TxPoolTimeout:       1,
+	IdleTimeout:         30 * 60,
+	StreamBufferSize:    32 * 1024,
+	RowCache:            RowCacheConfig{Memory: -1, Connections: -1, Threads: -1},
+	SpotCheckRatio:      0,
+	StrictMode:          true,
+	StrictTableAcl:      false,
+	SafeUpdates:         false,
+	MessagePollInterval: 1,
+	MessageAckWait:      30,
+	MessagePurgeAfter:   24 * 60 * 60,
+	MessageBatchSize:    100,
+	RowGCInterval:       60,
+	DeadlockInterval:    10,
+	RowGCBatchSize:      500,
+	RowGCBatchInterval:  0.1,
+	HeartbeatInterval:   0,
+	TxThrottleLag:       0,
+	TxThrottleMaxLag:    60,
+	TxThrottleDelay:     0,
+	TxThrottleExempt:    "",
+	OlapPoolSize:        0,
+	OlapQueryTimeout:    300,
+	OlapUsers:           "",
+	IdempotencyKeyTTL:   60 * 60,
+	WarmupQueries:       0,
+	WarmupFile:          "",
+	WarmupTimeout:       30,
+	TerseErrors:         false,
+	StatsPrefix:         "",
+	DebugURLPrefix:      "/debug",
+	PoolNamePrefix:      "",
 }
 
 var qsConfig Config
@@ -173,12 +247,50 @@ type QueryServiceControl interface {
 	// IsHealthy returns the health status of the QueryService
 	IsHealthy() error
 
+	// EnterLameduck makes the query service report itself as
+	// unhealthy, while it keeps serving, so the clients move away
+	// before it shuts down.
+	EnterLameduck()
+
 	// ReloadSchema makes the quey service reload its schema cache
 	ReloadSchema()
 
+	// SetSchemaOverrides changes the schema overrides of a
+	// running query service
+	SetSchemaOverrides([]SchemaOverride)
+
 	// SetQueryRules sets the query rules for this QueryService
 	SetQueryRules(ruleSource string, qrs *QueryRules) error
 
+	// SetMasterHint sets the current master of the shard, or nil
+	// if this tablet is the master or the master is unknown.
+	SetMasterHint(master *topo.EndPoint)
+
+	// SetTabletType tells the query service the type of this
+	// tablet. Only the rdonly tablets export their tables.
+	SetTabletType(tabletType topo.TabletType)
+
+	// SetIsMaster tells the query service if this tablet is the
+	// serving master of its shard. Only the master purges the
+	// expired rows and the old idempotency keys, the deletes are
+	// replicated. It also serves the messages, and throttles the
+	// transactions.
+	SetIsMaster(isMaster bool)
+
+	// SetHeartbeatWriter tells the query service to write the
+	// heartbeat if isWriter is true, and to read it otherwise.
+	// Unlike SetIsMaster, it stays true while the shard is frozen,
+	// so the replicas don't see their lag grow.
+	SetHeartbeatWriter(isWriter bool)
+
+	// HeartbeatLag returns the replication lag measured with the
+	// heartbeat, or an error if it is disabled or can't be read.
+	HeartbeatLag() (time.Duration, error)
+
+	// SetReplicaLag sets the replication lag of the replicas of
+	// the shard, so the master can throttle the transactions.
+	SetReplicaLag(lag time.Duration)
+
 	// QueryService returns the QueryService object used by this
 	// QueryServiceControl
 	QueryService() queryservice.QueryService
@@ -197,6 +309,33 @@ type TestQueryServiceControl struct {
 
 	// ReloadSchemaCount counts how many times ReloadSchema was called
 	ReloadSchemaCount int
+
+	// SchemaOverrides is the last value passed to SetSchemaOverrides
+	SchemaOverrides []SchemaOverride
+
+	// MasterHint is the last value passed to SetMasterHint
+	MasterHint *topo.EndPoint
+
+	// TabletType is the last value passed to SetTabletType
+	TabletType topo.TabletType
+
+	// IsMaster is the last value passed to SetIsMaster
+	IsMaster bool
+
+	// IsHeartbeatWriter is the last value passed to
+	// SetHeartbeatWriter
+	IsHeartbeatWriter bool
+
+	// HeartbeatLagValue and HeartbeatLagError are returned by
+	// HeartbeatLag
+	HeartbeatLagValue time.Duration
+	HeartbeatLagError error
+
+	// ReplicaLag is the last value passed to SetReplicaLag
+	ReplicaLag time.Duration
+
+	// Lameduck is true once EnterLameduck was called
+	Lameduck bool
 }
 
 // NewTestQueryServiceControl returns an implementation of QueryServiceControl
@@ -239,16 +378,56 @@ func (tqsc *TestQueryServiceControl) IsHealthy() error {
 	return tqsc.IsHealthyError
 }
 
+// EnterLameduck is part of the QueryServiceControl interface
+func (tqsc *TestQueryServiceControl) EnterLameduck() {
+	tqsc.Lameduck = true
+}
+
 // ReloadSchema is part of the QueryServiceControl interface
 func (tqsc *TestQueryServiceControl) ReloadSchema() {
 	tqsc.ReloadSchemaCount++
 }
 
+// SetSchemaOverrides is part of the QueryServiceControl interface
+func (tqsc *TestQueryServiceControl) SetSchemaOverrides(schemaOverrides []SchemaOverride) {
+	tqsc.SchemaOverrides = schemaOverrides
+}
+
 // SetQueryRules is part of the QueryServiceControl interface
 func (tqsc *TestQueryServiceControl) SetQueryRules(ruleSource string, qrs *QueryRules) error {
 	return nil
 }
 
+// SetMasterHint is part of the QueryServiceControl interface
+func (tqsc *TestQueryServiceControl) SetMasterHint(master *topo.EndPoint) {
+	tqsc.MasterHint = master
+}
+
+// SetTabletType is part of the QueryServiceControl interface
+func (tqsc *TestQueryServiceControl) SetTabletType(tabletType topo.TabletType) {
+	tqsc.TabletType = tabletType
+}
+
+// SetIsMaster is part of the QueryServiceControl interface
+func (tqsc *TestQueryServiceControl) SetIsMaster(isMaster bool) {
+	tqsc.IsMaster = isMaster
+}
+
+// SetHeartbeatWriter is part of the QueryServiceControl interface
+func (tqsc *TestQueryServiceControl) SetHeartbeatWriter(isWriter bool) {
+	tqsc.IsHeartbeatWriter = isWriter
+}
+
+// HeartbeatLag is part of the QueryServiceControl interface
+func (tqsc *TestQueryServiceControl) HeartbeatLag() (time.Duration, error) {
+	return tqsc.HeartbeatLagValue, tqsc.HeartbeatLagError
+}
+
+// SetReplicaLag is part of the QueryServiceControl interface
+func (tqsc *TestQueryServiceControl) SetReplicaLag(lag time.Duration) {
+	tqsc.ReplicaLag = lag
+}
+
 // QueryService is part of the QueryServiceControl interface
 func (tqsc *TestQueryServiceControl) QueryService() queryservice.QueryService {
 	return nil
@@ -280,6 +459,7 @@ var QueryServiceControlRegisterFunctions []QueryServiceControlRegisterFunction
 // Register is part of the QueryServiceControl interface
 func (rqsc *realQueryServiceControl) Register() {
 	rqsc.registerCheckMySQL()
+	rqsc.registerConfigReloads()
 	for _, f := range QueryServiceControlRegisterFunctions {
 		f(rqsc)
 	}
@@ -287,6 +467,8 @@ func (rqsc *realQueryServiceControl) Register() {
 	rqsc.registerQueryzHandler()
 	rqsc.registerSchemazHandler()
 	rqsc.registerStreamQueryzHandlers()
+	rqsc.registerLockzHandler()
+	registerQueryDigestHandler()
 }
 
 // AllowQueries starts the query service.
@@ -313,6 +495,17 @@ func (rqsc *realQueryServiceControl) ReloadSchema() {
 	rqsc.sqlQueryRPCService.qe.schemaInfo.triggerReload()
 }
 
+// SetSchemaOverrides is part of the QueryServiceControl interface.
+// If the query service is not running, nothing will happen: the
+// overrides are passed to AllowQueries instead.
+func (rqsc *realQueryServiceControl) SetSchemaOverrides(schemaOverrides []SchemaOverride) {
+	defer logError()
+	if !rqsc.IsServing() {
+		return
+	}
+	rqsc.sqlQueryRPCService.qe.schemaInfo.SetOverrides(context.Background(), schemaOverrides)
+}
+
 // checkMySQL verifies that MySQL is still reachable by connecting to it.
 // If it's not reachable, it shuts down the query service.
 // This function rate-limits the check to no more than once per second.
@@ -333,7 +526,7 @@ func (rqsc *realQueryServiceControl) registerCheckMySQL() {
 		if rqsc.sqlQueryRPCService.checkMySQL() {
 			return
 		}
-		log.Infof("Check MySQL failed. Shutting down query service")
+		mlog.Infof("Check MySQL failed. Shutting down query service")
 		rqsc.DisallowQueries()
 	}
 }
@@ -348,6 +541,45 @@ func (rqsc *realQueryServiceControl) SetQueryRules(ruleSource string, qrs *Query
 	return nil
 }
 
+// SetMasterHint is part of the QueryServiceControl interface
+func (rqsc *realQueryServiceControl) SetMasterHint(master *topo.EndPoint) {
+	rqsc.sqlQueryRPCService.SetMasterHint(master)
+}
+
+// SetTabletType is part of the QueryServiceControl interface
+func (rqsc *realQueryServiceControl) SetTabletType(tabletType topo.TabletType) {
+	rqsc.sqlQueryRPCService.SetTabletType(tabletType)
+}
+
+// SetIsMaster is part of the QueryServiceControl interface
+func (rqsc *realQueryServiceControl) SetIsMaster(isMaster bool) {
+	rqsc.sqlQueryRPCService.qe.rowGC.SetIsMaster(isMaster)
+	rqsc.sqlQueryRPCService.qe.idempotency.SetIsMaster(isMaster)
+	rqsc.sqlQueryRPCService.qe.messager.SetIsMaster(isMaster)
+	rqsc.sqlQueryRPCService.qe.txThrottler.SetIsMaster(isMaster)
+	rqsc.sqlQueryRPCService.setIsMaster(isMaster)
+}
+
+// SetHeartbeatWriter is part of the QueryServiceControl interface
+func (rqsc *realQueryServiceControl) SetHeartbeatWriter(isWriter bool) {
+	rqsc.sqlQueryRPCService.qe.heartbeat.SetIsMaster(isWriter)
+}
+
+// EnterLameduck is part of the QueryServiceControl interface
+func (rqsc *realQueryServiceControl) EnterLameduck() {
+	rqsc.sqlQueryRPCService.enterLameduck()
+}
+
+// HeartbeatLag is part of the QueryServiceControl interface
+func (rqsc *realQueryServiceControl) HeartbeatLag() (time.Duration, error) {
+	return rqsc.sqlQueryRPCService.qe.heartbeat.Lag()
+}
+
+// SetReplicaLag is part of the QueryServiceControl interface
+func (rqsc *realQueryServiceControl) SetReplicaLag(lag time.Duration) {
+	rqsc.sqlQueryRPCService.qe.txThrottler.SetReplicaLag(lag)
+}
+
 // QueryService is part of the QueryServiceControl interface
 func (rqsc *realQueryServiceControl) QueryService() queryservice.QueryService {
 	return rqsc.sqlQueryRPCService
@@ -357,6 +589,9 @@ func (rqsc *realQueryServiceControl) QueryService() queryservice.QueryService {
 // connect to the database and serving traffic) or an error explaining
 // the unhealthiness otherwise.
 func (rqsc *realQueryServiceControl) IsHealthy() error {
+	if rqsc.sqlQueryRPCService.isLameduck() {
+		return NewTabletError(ErrRetry, "query service is in lameduck mode")
+	}
 	return rqsc.sqlQueryRPCService.Execute(
 		context.Background(),
 		&proto.Query{
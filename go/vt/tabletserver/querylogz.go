@@ -55,7 +55,7 @@ var (
 			<td>{{.MysqlResponseTime.Seconds}}</td>
 			<td>{{.WaitingForConnection.Seconds}}</td>
 			<td>{{.PlanType}}</td>
-			<td>{{.OriginalSql | unquote | cssWrappable}}</td>
+			<td>{{.FmtOriginalSql | unquote | cssWrappable}}</td>
 			<td>{{.NumberOfQueries}}</td>
 			<td>{{.FmtQuerySources}}</td>
 			<td>{{.RowsAffected}}</td>
@@ -114,7 +114,7 @@ func querylogzHandler(w http.ResponseWriter, r *http.Request) {
 				ColorLevel string
 			}{stats, level}
 			if err := querylogzTmpl.Execute(w, tmplData); err != nil {
-				log.Errorf("querylogz: couldn't execute template: %v", err)
+				mlog.Errorf("querylogz: couldn't execute template: %v", err)
 			}
 		case <-tmr.C:
 			return
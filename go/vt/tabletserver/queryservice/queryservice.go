@@ -29,8 +29,23 @@ type QueryService interface {
 	StreamExecute(ctx context.Context, query *proto.Query, sendReply func(*mproto.QueryResult) error) error
 	ExecuteBatch(ctx context.Context, queryList *proto.QueryList, reply *proto.QueryResultList) error
 
+	// Prepared statements
+	Prepare(ctx context.Context, req *proto.PrepareRequest, reply *proto.PrepareResult) error
+	ExecutePrepared(ctx context.Context, req *proto.ExecutePreparedRequest, reply *mproto.QueryResult) error
+	ClosePrepared(ctx context.Context, req *proto.ClosePreparedRequest) error
+
+	// Plan inspection
+	Explain(ctx context.Context, query *proto.Query, reply *proto.ExplainResult) error
+
 	// Map reduce helper
 	SplitQuery(ctx context.Context, req *proto.SplitQueryRequest, reply *proto.SplitQueryResult) error
+
+	// Message tables
+	MessageStream(ctx context.Context, req *proto.MessageStreamRequest, sendReply func(*mproto.QueryResult) error) error
+	MessageAck(ctx context.Context, req *proto.MessageAckRequest, reply *proto.MessageAckResult) error
+
+	// Bulk exports
+	ExportTable(ctx context.Context, req *proto.ExportTableRequest, sendReply func(*proto.ExportChunk) error) error
 }
 
 // ErrorQueryService is an implementation of QueryService that returns a
@@ -74,7 +89,42 @@ func (e *ErrorQueryService) ExecuteBatch(ctx context.Context, queryList *proto.Q
 	return fmt.Errorf("ErrorQueryService does not implement any method")
 }
 
+// Prepare is part of QueryService interface
+func (e *ErrorQueryService) Prepare(ctx context.Context, req *proto.PrepareRequest, reply *proto.PrepareResult) error {
+	return fmt.Errorf("ErrorQueryService does not implement any method")
+}
+
+// ExecutePrepared is part of QueryService interface
+func (e *ErrorQueryService) ExecutePrepared(ctx context.Context, req *proto.ExecutePreparedRequest, reply *mproto.QueryResult) error {
+	return fmt.Errorf("ErrorQueryService does not implement any method")
+}
+
+// ClosePrepared is part of QueryService interface
+func (e *ErrorQueryService) ClosePrepared(ctx context.Context, req *proto.ClosePreparedRequest) error {
+	return fmt.Errorf("ErrorQueryService does not implement any method")
+}
+
+// Explain is part of QueryService interface
+func (e *ErrorQueryService) Explain(ctx context.Context, query *proto.Query, reply *proto.ExplainResult) error {
+	return fmt.Errorf("ErrorQueryService does not implement any method")
+}
+
 // SplitQuery is part of QueryService interface
 func (e *ErrorQueryService) SplitQuery(ctx context.Context, req *proto.SplitQueryRequest, reply *proto.SplitQueryResult) error {
 	return fmt.Errorf("ErrorQueryService does not implement any method")
 }
+
+// MessageStream is part of QueryService interface
+func (e *ErrorQueryService) MessageStream(ctx context.Context, req *proto.MessageStreamRequest, sendReply func(*mproto.QueryResult) error) error {
+	return fmt.Errorf("ErrorQueryService does not implement any method")
+}
+
+// MessageAck is part of QueryService interface
+func (e *ErrorQueryService) MessageAck(ctx context.Context, req *proto.MessageAckRequest, reply *proto.MessageAckResult) error {
+	return fmt.Errorf("ErrorQueryService does not implement any method")
+}
+
+// ExportTable is part of QueryService interface
+func (e *ErrorQueryService) ExportTable(ctx context.Context, req *proto.ExportTableRequest, sendReply func(*proto.ExportChunk) error) error {
+	return fmt.Errorf("ErrorQueryService does not implement any method")
+}
@@ -11,7 +11,6 @@ import (
 	"sort"
 	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/acl"
 	"github.com/youtube/vitess/go/vt/tabletserver/planbuilder"
 )
@@ -152,7 +151,7 @@ func (rqsc *realQueryServiceControl) registerQueryzHandler() {
 		sort.Sort(&sorter)
 		for _, Value := range sorter.rows {
 			if err := queryzTmpl.Execute(w, Value); err != nil {
-				log.Errorf("queryz: couldn't execute template: %v", err)
+				mlog.Errorf("queryz: couldn't execute template: %v", err)
 			}
 		}
 	})
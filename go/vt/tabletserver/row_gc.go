@@ -0,0 +1,224 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/youtube/vitess/go/acl"
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/sync2"
+	"github.com/youtube/vitess/go/timer"
+	"golang.org/x/net/context"
+)
+
+// rowGC purges the expired rows of the tables that have a TTL, see
+// SchemaOverride. Every interval, it deletes the expired rows of each
+// table batchSize at a time, pausing batchInterval between batches so
+// it doesn't overload MySQL or the replicas. It only runs on the
+// master, the replicas get the deletes through replication. It can
+// be paused by an admin.
+type rowGC struct {
+	qe            *QueryEngine
+	batchSize     int
+	batchInterval time.Duration
+	ticks         *timer.Timer
+	now           func() time.Time
+
+	isMaster sync2.AtomicInt32
+	paused   sync2.AtomicInt32
+
+	mu     sync.Mutex
+	isOpen bool
+	// done is closed by Close, to interrupt the pauses
+	// between batches.
+	done chan struct{}
+
+	// purged counts the rows purged, by table.
+	purged *stats.Counters
+	// batches times the deletes, by table.
+	batches *stats.Timings
+}
+
+func newRowGC(qe *QueryEngine, statsPrefix string, interval time.Duration, batchSize int, batchInterval time.Duration) *rowGC {
+	gc := &rowGC{
+		qe:            qe,
+		batchSize:     batchSize,
+		batchInterval: batchInterval,
+		ticks:         timer.NewTimer(interval),
+		now:           time.Now,
+		purged:        stats.NewCounters(statsPrefix + "RowGCPurged"),
+		batches:       stats.NewTimings(statsPrefix + "RowGCBatches"),
+	}
+	stats.Publish(statsPrefix+"RowGCPaused", stats.IntFunc(func() int64 {
+		return int64(gc.paused.Get())
+	}))
+	return gc
+}
+
+// Open starts purging the expired rows.
+func (gc *rowGC) Open() {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if gc.isOpen {
+		return
+	}
+	gc.isOpen = true
+	gc.done = make(chan struct{})
+	gc.ticks.Start(func() { gc.run() })
+}
+
+// Close stops purging the expired rows. It interrupts the
+// purge in progress, if any.
+func (gc *rowGC) Close() {
+	gc.mu.Lock()
+	if !gc.isOpen {
+		gc.mu.Unlock()
+		return
+	}
+	gc.isOpen = false
+	close(gc.done)
+	gc.mu.Unlock()
+	gc.ticks.Stop()
+}
+
+// SetIsMaster enables the purges if isMaster is true.
+func (gc *rowGC) SetIsMaster(isMaster bool) {
+	if isMaster {
+		gc.isMaster.Set(1)
+	} else {
+		gc.isMaster.Set(0)
+	}
+}
+
+// SetPaused pauses or resumes the purges. A purge in progress
+// stops after its current batch.
+func (gc *rowGC) SetPaused(paused bool) {
+	if paused {
+		gc.paused.Set(1)
+	} else {
+		gc.paused.Set(0)
+	}
+}
+
+// isActive returns true if the purges can run.
+func (gc *rowGC) isActive() bool {
+	return gc.isMaster.Get() != 0 && gc.paused.Get() == 0
+}
+
+// run purges the expired rows of all the tables that have a TTL.
+func (gc *rowGC) run() {
+	defer func() {
+		if x := recover(); x != nil {
+			internalErrors.Add("RowGC", 1)
+			mlog.Errorf("row gc error: %v", x)
+		}
+	}()
+	gc.mu.Lock()
+	done := gc.done
+	gc.mu.Unlock()
+
+	ctx := context.Background()
+	for name, ttl := range gc.qe.schemaInfo.GetTTLTables() {
+		if !gc.isActive() {
+			return
+		}
+		if err := gc.purge(ctx, done, name, ttl); err != nil {
+			internalErrors.Add("RowGC", 1)
+			mlog.Errorf("could not purge the expired rows of %s: %v", name, err)
+		}
+	}
+}
+
+// purge deletes the rows of the table that were expired when it
+// started, one batch at a time. It returns when they're all gone,
+// or when the purges are paused or done is closed.
+func (gc *rowGC) purge(ctx context.Context, done chan struct{}, name string, ttl TTLInfo) error {
+	cutoff := gc.now().Add(-ttl.Duration).Unix()
+	expired := fmt.Sprintf("from_unixtime(%d)", cutoff)
+	if ttl.IsNumber {
+		expired = fmt.Sprintf("%d", cutoff)
+	}
+	query := fmt.Sprintf("delete from `%s` where `%s` < %s order by `%s` limit %d", name, ttl.Column, expired, strings.Join(ttl.PKColumns, "`, `"), gc.batchSize)
+	for {
+		count, err := gc.deleteBatch(ctx, name, query)
+		if err != nil {
+			return err
+		}
+		if count < gc.batchSize {
+			return nil
+		}
+		select {
+		case <-done:
+			return nil
+		case <-time.After(gc.batchInterval):
+		}
+		if !gc.isActive() {
+			return nil
+		}
+	}
+}
+
+func (gc *rowGC) deleteBatch(ctx context.Context, name, query string) (int, error) {
+	conn := getOrPanic(ctx, gc.qe.connPool)
+	defer conn.Recycle()
+
+	start := time.Now()
+	qr, err := conn.Exec(ctx, query, gc.batchSize, false)
+	gc.batches.Record(name, start)
+	if err != nil {
+		return 0, NewTabletErrorSql(ErrFail, err)
+	}
+	gc.purged.Add(name, int64(qr.RowsAffected))
+	return int(qr.RowsAffected), nil
+}
+
+// ServeHTTP shows the state of the row GC, and the TTL of the tables.
+// Admins can pause it with pause=true, and resume it with pause=false.
+func (gc *rowGC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := acl.CheckAccessHTTP(r, acl.DEBUGGING); err != nil {
+		acl.SendError(w, err)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := r.Form["pause"]; ok {
+		if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		switch value := r.FormValue("pause"); value {
+		case "true":
+			gc.SetPaused(true)
+		case "false":
+			gc.SetPaused(false)
+		default:
+			http.Error(w, fmt.Sprintf("invalid value for pause: %v", value), http.StatusBadRequest)
+			return
+		}
+		mlog.Infof("row gc paused: %v", gc.paused.Get() != 0)
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(w, "master: %v\n", gc.isMaster.Get() != 0)
+	fmt.Fprintf(w, "paused: %v\n", gc.paused.Get() != 0)
+	ttls := gc.qe.schemaInfo.GetTTLTables()
+	names := make([]string, 0, len(ttls))
+	for name := range ttls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	purged := gc.purged.Counts()
+	for _, name := range names {
+		fmt.Fprintf(w, "%v: %v older than %v, %v rows purged\n", name, ttls[name].Column, ttls[name].Duration, purged[name])
+	}
+}
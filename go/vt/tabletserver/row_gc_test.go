@@ -0,0 +1,248 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/schema"
+	"github.com/youtube/vitess/go/vt/tabletserver/fakesqldb"
+)
+
+// rowGCOverrides gives a ttl to test_table, that has an int
+// column, and to events, that has a datetime column.
+const rowGCOverrides = `[
+	{"Name": "test_table", "TTL": {"Column": "column_01", "Seconds": 3600}},
+	{"Name": "events", "TTL": {"Column": "time_created", "Seconds": 60}}
+]`
+
+func TestRowGCPurge(t *testing.T) {
+	db := setUpRowGCTest()
+	sqlQuery, gc := allowRowGCQueries(t, rowGCOverrides, 100)
+	defer sqlQuery.disallowQueries()
+
+	db.AddQuery("delete from `test_table` where `column_01` < 6400 order by `column_01` limit 100", rowGCDeleted(3))
+	db.AddQuery("delete from `events` where `time_created` < from_unixtime(9940) order by `id` limit 100", rowGCDeleted(1))
+
+	// Replicas don't purge.
+	gc.run()
+	if got := gc.purged.Counts(); len(got) != 0 {
+		t.Errorf("purged on a replica: %v", got)
+	}
+
+	gc.SetIsMaster(true)
+	gc.SetPaused(true)
+	gc.run()
+	if got := gc.purged.Counts(); len(got) != 0 {
+		t.Errorf("purged while paused: %v", got)
+	}
+
+	gc.SetPaused(false)
+	gc.run()
+	got := gc.purged.Counts()
+	if got["test_table"] != 3 || got["events"] != 1 {
+		t.Errorf("purged: %v, want 3 test_table and 1 events rows", got)
+	}
+}
+
+func TestRowGCBatches(t *testing.T) {
+	db := setUpRowGCTest()
+	sqlQuery, gc := allowRowGCQueries(t, `[{"Name": "test_table", "TTL": {"Column": "column_01", "Seconds": 3600}}]`, 2)
+	defer sqlQuery.disallowQueries()
+	gc.batchInterval = time.Millisecond
+	gc.SetIsMaster(true)
+
+	// Every batch is full, so the purge goes on until it's paused.
+	db.AddQuery("delete from `test_table` where `column_01` < 6400 order by `column_01` limit 2", rowGCDeleted(2))
+	done := make(chan struct{})
+	go func() {
+		gc.run()
+		close(done)
+	}()
+	for i := 0; gc.purged.Counts()["test_table"] < 6; i++ {
+		if i == 100 {
+			t.Fatalf("purged %v rows, want at least 6", gc.purged.Counts()["test_table"])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	gc.SetPaused(true)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("purge didn't stop after it was paused")
+	}
+}
+
+func TestRowGCInvalidTTL(t *testing.T) {
+	setUpRowGCTest()
+	sqlQuery, _ := allowRowGCQueries(t, `[
+		{"Name": "test_table", "TTL": {"Column": "unknown", "Seconds": 3600}},
+		{"Name": "events", "TTL": {"Column": "time_created", "Seconds": 0}}
+	]`, 100)
+	defer sqlQuery.disallowQueries()
+
+	if got := sqlQuery.qe.schemaInfo.GetTTLTables(); len(got) != 0 {
+		t.Errorf("GetTTLTables: %v, want none", got)
+	}
+}
+
+func TestRowGCSetTTL(t *testing.T) {
+	newTable := func() *TableInfo {
+		ti := &TableInfo{Table: schema.NewTable("t")}
+		ti.AddColumn("id", "int", sqltypes.Value{}, "")
+		ti.AddColumn("time_created", "int", sqltypes.Value{}, "")
+		return ti
+	}
+
+	ti := newTable()
+	want := "table t has no primary key"
+	if err := ti.SetTTL("time_created", time.Hour); err == nil || err.Error() != want {
+		t.Errorf("SetTTL without a primary key: %v, want %s", err, want)
+	}
+
+	// the purges would leave the rows in the rowcache
+	ti = newTable()
+	if err := ti.SetPK([]string{"id"}); err != nil {
+		t.Fatalf("SetPK failed: %v", err)
+	}
+	ti.CacheType = schema.CACHE_RW
+	want = "table t has a rowcache"
+	if err := ti.SetTTL("time_created", time.Hour); err == nil || err.Error() != want {
+		t.Errorf("SetTTL with a rowcache: %v, want %s", err, want)
+	}
+
+	ti.CacheType = schema.CACHE_NONE
+	if err := ti.SetTTL("time_created", time.Hour); err != nil {
+		t.Fatalf("SetTTL failed: %v", err)
+	}
+	if got, want := ti.TTL.PKColumns, []string{"id"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TTL pk columns: %v, want %v", got, want)
+	}
+}
+
+func TestRowGCHTTP(t *testing.T) {
+	setUpRowGCTest()
+	sqlQuery, gc := allowRowGCQueries(t, rowGCOverrides, 100)
+	defer sqlQuery.disallowQueries()
+
+	request, _ := http.NewRequest("GET", "/debug/rowgc?pause=true", nil)
+	response := httptest.NewRecorder()
+	gc.ServeHTTP(response, request)
+	if gc.paused.Get() == 0 {
+		t.Errorf("row gc wasn't paused")
+	}
+	body := response.Body.String()
+	for _, want := range []string{
+		"paused: true\n",
+		"events: time_created older than 1m0s, 0 rows purged\n",
+		"test_table: column_01 older than 1h0m0s, 0 rows purged\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("rowgc page doesn't contain %q:\n%s", want, body)
+		}
+	}
+
+	request, _ = http.NewRequest("GET", "/debug/rowgc?pause=maybe", nil)
+	response = httptest.NewRecorder()
+	gc.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("invalid pause value returned %v, want %v", response.Code, http.StatusBadRequest)
+	}
+}
+
+func setUpRowGCTest() *fakesqldb.DB {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	db.AddQuery(baseShowTables, &mproto.QueryResult{
+		RowsAffected: 2,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{
+				sqltypes.MakeString([]byte("test_table")),
+				sqltypes.MakeString([]byte("USER TABLE")),
+				sqltypes.MakeString([]byte("1427325875")),
+				sqltypes.MakeString([]byte("")),
+			},
+			[]sqltypes.Value{
+				sqltypes.MakeString([]byte("events")),
+				sqltypes.MakeString([]byte("USER TABLE")),
+				sqltypes.MakeString([]byte("1427325875")),
+				sqltypes.MakeString([]byte("")),
+			},
+		},
+	})
+	timeCreated := describeColumn("time_created")
+	timeCreated[1] = sqltypes.MakeString([]byte("datetime"))
+	db.AddQuery("describe `events`", &mproto.QueryResult{
+		RowsAffected: 2,
+		Rows: [][]sqltypes.Value{
+			describeColumn("id"),
+			timeCreated,
+		},
+	})
+	db.AddQuery("show index from `events`", &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{
+			[]sqltypes.Value{
+				sqltypes.MakeString([]byte{}),
+				sqltypes.MakeString([]byte{}),
+				sqltypes.MakeString([]byte("PRIMARY")),
+				sqltypes.MakeString([]byte{}),
+				sqltypes.MakeString([]byte("id")),
+				sqltypes.MakeString([]byte{}),
+				sqltypes.MakeString([]byte("300")),
+			},
+		},
+	})
+	return db
+}
+
+// allowRowGCQueries starts a SqlQuery with the schema overrides,
+// whose row gc doesn't run on its own, so the tests can call run
+// when they need it. Its clock is at 10000s.
+func allowRowGCQueries(t *testing.T, overrides string, batchSize int) (*SqlQuery, *rowGC) {
+	var schemaOverrides []SchemaOverride
+	if err := json.Unmarshal([]byte(overrides), &schemaOverrides); err != nil {
+		t.Fatalf("invalid schema overrides: %v", err)
+	}
+	randID := rand.Int63()
+	config := DefaultQsConfig
+	config.StatsPrefix = fmt.Sprintf("Stats-%d-", randID)
+	config.DebugURLPrefix = fmt.Sprintf("/debug-%d-", randID)
+	config.RowCache.StatsPrefix = fmt.Sprintf("Stats-%d-", randID)
+	config.PoolNamePrefix = fmt.Sprintf("Pool-%d-", randID)
+	config.StrictMode = true
+	config.MessagePollInterval = 0
+	config.RowGCInterval = 0
+	config.RowGCBatchSize = batchSize
+	sqlQuery := NewSqlQuery(config)
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, schemaOverrides, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	gc := sqlQuery.qe.rowGC
+	gc.now = func() time.Time { return time.Unix(10000, 0) }
+	return sqlQuery, gc
+}
+
+// rowGCDeleted returns the result of a delete. fakesqldb returns
+// as many rows as RowsAffected.
+func rowGCDeleted(count int) *mproto.QueryResult {
+	return &mproto.QueryResult{
+		RowsAffected: uint64(count),
+		Rows:         make([][]sqltypes.Value, count),
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"sync"
+)
+
+// rowLimit is the per-table override of the global row limits.
+// A zero value means the global limit applies.
+type rowLimit struct {
+	maxResultSize   int64
+	maxAffectedRows int64
+}
+
+// tableRowLimits holds the per-table row limits that were set at
+// runtime. They are kept separately from the schema so they
+// survive schema reloads.
+type tableRowLimits struct {
+	mu     sync.Mutex
+	limits map[string]rowLimit
+}
+
+func newTableRowLimits() *tableRowLimits {
+	return &tableRowLimits{limits: make(map[string]rowLimit)}
+}
+
+// get returns the limits for the table.
+func (trl *tableRowLimits) get(tableName string) rowLimit {
+	trl.mu.Lock()
+	defer trl.mu.Unlock()
+	return trl.limits[tableName]
+}
+
+// set changes one of the limits for the table. The key is the
+// name of the corresponding global limit. It returns false if the
+// key is not a row limit.
+func (trl *tableRowLimits) set(tableName, key string, val int64) bool {
+	trl.mu.Lock()
+	defer trl.mu.Unlock()
+	limit := trl.limits[tableName]
+	switch key {
+	case "vt_max_result_size":
+		limit.maxResultSize = val
+	case "vt_max_affected_rows":
+		limit.maxAffectedRows = val
+	default:
+		return false
+	}
+	if limit == (rowLimit{}) {
+		delete(trl.limits, tableName)
+	} else {
+		trl.limits[tableName] = limit
+	}
+	return true
+}
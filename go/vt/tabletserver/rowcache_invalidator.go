@@ -9,7 +9,6 @@ import (
 	"sync"
 	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/sqltypes"
 	"github.com/youtube/vitess/go/stats"
 	"github.com/youtube/vitess/go/sync2"
@@ -91,9 +90,9 @@ func (rci *RowcacheInvalidator) Open(dbname string, mysqld *mysqlctl.Mysqld) {
 
 	ok := rci.svm.Go(rci.run)
 	if ok {
-		log.Infof("Rowcache invalidator starting, dbname: %s, path: %s, position: %v", dbname, mysqld.Cnf().BinLogPath, rp)
+		mlog.Infof("Rowcache invalidator starting, dbname: %s, path: %s, position: %v", dbname, mysqld.Cnf().BinLogPath, rp)
 	} else {
-		log.Infof("Rowcache invalidator already running")
+		mlog.Infof("Rowcache invalidator already running")
 	}
 }
 
@@ -123,11 +122,11 @@ func (rci *RowcacheInvalidator) run(ctx *sync2.ServiceContext) error {
 		if IsConnErr(err) {
 			go checkMySQL()
 		}
-		log.Errorf("binlog.ServeUpdateStream returned err '%v', retrying in 1 second.", err.Error())
+		mlog.Errorf("binlog.ServeUpdateStream returned err '%v', retrying in 1 second.", err.Error())
 		internalErrors.Add("Invalidation", 1)
 		time.Sleep(1 * time.Second)
 	}
-	log.Infof("Rowcache invalidator stopped")
+	mlog.Infof("Rowcache invalidator stopped")
 	return nil
 }
 
@@ -135,11 +134,11 @@ func handleInvalidationError(event *blproto.StreamEvent) {
 	if x := recover(); x != nil {
 		terr, ok := x.(*TabletError)
 		if !ok {
-			log.Errorf("Uncaught panic for %+v:\n%v\n%s", event, x, tb.Stack(4))
+			mlog.Errorf("Uncaught panic for %+v:\n%v\n%s", event, x, tb.Stack(4))
 			internalErrors.Add("Panic", 1)
 			return
 		}
-		log.Errorf("%v: %+v", terr, event)
+		mlog.Errorf("%v: %+v", terr, event)
 		internalErrors.Add("Invalidation", 1)
 	}
 }
@@ -148,7 +147,7 @@ func (rci *RowcacheInvalidator) processEvent(event *blproto.StreamEvent) error {
 	defer handleInvalidationError(event)
 	switch event.Category {
 	case "DDL":
-		log.Infof("DDL invalidation: %s", event.Sql)
+		mlog.Infof("DDL invalidation: %s", event.Sql)
 		rci.handleDDLEvent(event.Sql)
 	case "DML":
 		rci.handleDMLEvent(event)
@@ -157,7 +156,7 @@ func (rci *RowcacheInvalidator) processEvent(event *blproto.StreamEvent) error {
 	case "POS":
 		rci.AppendGTID(event.GTIDField.Value)
 	default:
-		log.Errorf("unknown event: %#v", event)
+		mlog.Errorf("unknown event: %#v", event)
 		internalErrors.Add("Invalidation", 1)
 		return nil
 	}
@@ -181,7 +180,7 @@ func (rci *RowcacheInvalidator) handleDMLEvent(event *blproto.StreamEvent) {
 		for _, pkVal := range pkTuple {
 			key, err := sqltypes.BuildValue(pkVal)
 			if err != nil {
-				log.Errorf("Error building invalidation key for %#v: '%v'", event, err)
+				mlog.Errorf("Error building invalidation key for %#v: '%v'", event, err)
 				internalErrors.Add("Invalidation", 1)
 				return
 			}
@@ -214,7 +213,7 @@ func (rci *RowcacheInvalidator) handleDDLEvent(ddl string) {
 func (rci *RowcacheInvalidator) handleUnrecognizedEvent(sql string) {
 	statement, err := sqlparser.Parse(sql)
 	if err != nil {
-		log.Errorf("Error: %v: %s", err, sql)
+		mlog.Errorf("Error: %v: %s", err, sql)
 		internalErrors.Add("Invalidation", 1)
 		return
 	}
@@ -228,7 +227,7 @@ func (rci *RowcacheInvalidator) handleUnrecognizedEvent(sql string) {
 	case *sqlparser.Delete:
 		table = stmt.Table
 	default:
-		log.Errorf("Unrecognized: %s", sql)
+		mlog.Errorf("Unrecognized: %s", sql)
 		internalErrors.Add("Invalidation", 1)
 		return
 	}
@@ -242,7 +241,7 @@ func (rci *RowcacheInvalidator) handleUnrecognizedEvent(sql string) {
 	tableName := string(table.Name)
 	tableInfo := rci.qe.schemaInfo.GetTable(tableName)
 	if tableInfo == nil {
-		log.Errorf("Table %s not found: %s", tableName, sql)
+		mlog.Errorf("Table %s not found: %s", tableName, sql)
 		internalErrors.Add("Invalidation", 1)
 		return
 	}
@@ -252,6 +251,6 @@ func (rci *RowcacheInvalidator) handleUnrecognizedEvent(sql string) {
 
 	// Treat the statement as a DDL.
 	// It will conservatively invalidate all rows of the table.
-	log.Warningf("Treating '%s' as DDL for table %s", sql, tableName)
+	mlog.Warningf("Treating '%s' as DDL for table %s", sql, tableName)
 	rci.qe.schemaInfo.CreateOrUpdateTable(context.Background(), tableName)
 }
@@ -0,0 +1,46 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/youtube/vitess/go/acl"
+)
+
+// serveSafeUpdates shows if the safe updates mode is on for the
+// tablet. Admins can change it with enable=true or enable=false.
+// Clients can only turn it off for one transaction, with
+// 'set vt_safe_updates = 0'.
+func (qe *QueryEngine) serveSafeUpdates(w http.ResponseWriter, r *http.Request) {
+	if err := acl.CheckAccessHTTP(r, acl.DEBUGGING); err != nil {
+		acl.SendError(w, err)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := r.Form["enable"]; ok {
+		if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		switch value := r.FormValue("enable"); value {
+		case "true":
+			qe.safeUpdates.Set(1)
+		case "false":
+			qe.safeUpdates.Set(0)
+		default:
+			http.Error(w, fmt.Sprintf("invalid value for enable: %v", value), http.StatusBadRequest)
+			return
+		}
+		mlog.Infof("safe updates: %v", qe.safeUpdates.Get() != 0)
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(w, "safe updates: %v\n", qe.safeUpdates.Get() != 0)
+}
@@ -0,0 +1,86 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// columnCharsetsQuery lists the character sets of the text columns.
+const columnCharsetsQuery = "select table_name, column_name, character_set_name from information_schema.columns where table_schema = database() and character_set_name is not null"
+
+// unicodeCharsets are the character sets that hold characters
+// outside of the Basic Multilingual Plane, which the MySQL utf8
+// can't represent.
+var unicodeCharsets = map[string]bool{
+	"utf8mb4": true,
+	"utf16":   true,
+	"utf16le": true,
+	"utf32":   true,
+	"gb18030": true,
+}
+
+// charsetCovers returns true if all the characters of the column
+// charset can be represented in the connection charset, i.e. if the
+// connection can read and write the column without losing data. It
+// returns true for the connection charsets it doesn't know, since it
+// can't tell.
+func charsetCovers(connCharset, columnCharset string) bool {
+	connCharset, columnCharset = strings.ToLower(connCharset), strings.ToLower(columnCharset)
+	if connCharset == columnCharset {
+		return true
+	}
+	switch connCharset {
+	case "binary", "utf8mb4":
+		return true
+	case "utf8":
+		return !unicodeCharsets[columnCharset]
+	case "latin1":
+		return columnCharset == "ascii"
+	case "ascii":
+		return false
+	}
+	return true
+}
+
+// checkCharsets validates the character set of the connections against
+// the text columns of the tables. The columns that hold characters
+// the connections can't represent are logged, and counted in the
+// CharsetMismatches variable: the clients would read and write '?'
+// instead. Errors are only logged, they don't prevent serving.
+func (si *SchemaInfo) checkCharsets(ctx context.Context, conn *DBConn, connCharset string) {
+	if connCharset == "" {
+		return
+	}
+	columns, err := conn.Exec(ctx, columnCharsetsQuery, maxTableCount*100, false)
+	if err != nil {
+		mlog.Warningf("Could not check the column character sets: %v", err)
+		return
+	}
+	mismatches := make(map[string]string)
+	for _, row := range columns.Rows {
+		columnCharset := row[2].String()
+		if charsetCovers(connCharset, columnCharset) {
+			continue
+		}
+		column := fmt.Sprintf("%s.%s", row[0].String(), row[1].String())
+		mismatches[column] = columnCharset
+		mlog.Warningf("Column %s uses the %s character set, which can't be represented in the %s character set of the connections", column, columnCharset, connCharset)
+	}
+	si.mu.Lock()
+	si.charsetMismatches = mismatches
+	si.mu.Unlock()
+}
+
+// getCharsetMismatches returns the number of text columns whose
+// character set can't be represented in the one of the connections.
+func (si *SchemaInfo) getCharsetMismatches() int64 {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	return int64(len(si.charsetMismatches))
+}
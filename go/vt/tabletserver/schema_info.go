@@ -9,16 +9,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/acl"
 	"github.com/youtube/vitess/go/cache"
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/sqldb"
 	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/sync2"
 	"github.com/youtube/vitess/go/timer"
 	"github.com/youtube/vitess/go/vt/schema"
 	"github.com/youtube/vitess/go/vt/tableacl"
@@ -87,6 +88,11 @@ func (ep *ExecPlan) Stats() (queryCount int64, duration time.Duration, rowCount,
 // Table specifies the rowcache table to operate on.
 // The purpose of this override is mainly to allow views to benefit from
 // the rowcache. It has its downsides. Use carefully.
+// Sensitive keeps the bind variables and rewritten queries of the
+// table out of the query logs. ReadOnly rejects all DMLs on the table.
+// TTL makes the row GC purge the rows of the table whose TTL.Column,
+// a date or a unix timestamp, is more than TTL.Seconds old. The table
+// must have a primary key, and no rowcache.
 type SchemaOverride struct {
 	Name      string
 	PKColumns []string
@@ -94,6 +100,26 @@ type SchemaOverride struct {
 		Type  string
 		Table string
 	}
+	Sensitive bool
+	ReadOnly  bool
+	TTL       *struct {
+		Column  string
+		Seconds float64
+	}
+}
+
+// PreparedStatement is a statement prepared by a client, along
+// with its plan. Prepared statements are evicted when the schema
+// changes, because their plans may be obsolete.
+type PreparedStatement struct {
+	Sql     string
+	Comment string
+	Plan    *ExecPlan
+}
+
+// Size allows PreparedStatement to be in cache.LRUCache.
+func (*PreparedStatement) Size() int {
+	return 1
 }
 
 // SchemaInfo stores the schema info and performs operations that
@@ -109,11 +135,22 @@ type SchemaInfo struct {
 	ticks      *timer.Timer
 	reloadTime time.Duration
 	endpoints  map[string]string
+
+	// statements are the prepared statements. planGeneration
+	// is incremented every time the plans are cleared.
+	statements      *cache.LRUCache
+	planGeneration  int64
+	lastStatementID sync2.AtomicInt64
+
+	// charsetMismatches are the text columns whose character
+	// set the connections can't represent, see checkCharsets.
+	charsetMismatches map[string]string
 }
 
 // NewSchemaInfo creates a new SchemaInfo.
 func NewSchemaInfo(
 	queryCacheSize int,
+	statementCacheSize int,
 	statsPrefix string,
 	endpoints map[string]string,
 	reloadTime time.Duration,
@@ -124,6 +161,9 @@ func NewSchemaInfo(
 		ticks:      timer.NewTimer(reloadTime),
 		endpoints:  endpoints,
 		reloadTime: reloadTime,
+
+		statements:      cache.NewLRUCache(int64(statementCacheSize)),
+		lastStatementID: sync2.AtomicInt64(time.Now().UnixNano()),
 	}
 	stats.Publish(statsPrefix+"QueryCacheLength", stats.IntFunc(si.queries.Length))
 	stats.Publish(statsPrefix+"QueryCacheSize", stats.IntFunc(si.queries.Size))
@@ -131,7 +171,10 @@ func NewSchemaInfo(
 	stats.Publish(statsPrefix+"QueryCacheOldest", stats.StringFunc(func() string {
 		return fmt.Sprintf("%v", si.queries.Oldest())
 	}))
+	stats.Publish(statsPrefix+"StatementCacheLength", stats.IntFunc(si.statements.Length))
+	stats.Publish(statsPrefix+"StatementCacheCapacity", stats.IntFunc(si.statements.Capacity))
 	stats.Publish(statsPrefix+"SchemaReloadTime", stats.DurationFunc(si.ticks.Interval))
+	stats.Publish(statsPrefix+"CharsetMismatches", stats.IntFunc(si.getCharsetMismatches))
 	_ = stats.NewMultiCountersFunc(statsPrefix+"RowcacheStats", []string{"Table", "Stats"}, si.getRowcacheStats)
 	_ = stats.NewMultiCountersFunc(statsPrefix+"RowcacheInvalidations", []string{"Table"}, si.getRowcacheInvalidations)
 	_ = stats.NewMultiCountersFunc(statsPrefix+"QueryCounts", []string{"Table", "Plan"}, si.getQueryCount)
@@ -183,13 +226,14 @@ func (si *SchemaInfo) Open(appParams, dbaParams *sqldb.ConnParams, schemaOverrid
 		}
 		si.tables[tableName] = tableInfo
 	}
+	si.checkCharsets(ctx, conn, appParams.Charset)
 	if schemaOverrides != nil {
 		si.overrides = schemaOverrides
 		si.override()
 	}
 	si.lastChange = curTime
 	// Clear is not really needed. Doing it for good measure.
-	si.queries.Clear()
+	si.clearPlans()
 	si.ticks.Start(func() { si.Reload() })
 }
 
@@ -197,12 +241,14 @@ func (si *SchemaInfo) override() {
 	for _, override := range si.overrides {
 		table, ok := si.tables[override.Name]
 		if !ok {
-			log.Warningf("Table not found for override: %v", override)
+			mlog.Warningf("Table not found for override: %v", override)
 			continue
 		}
+		table.Sensitive = override.Sensitive
+		table.ReadOnly = override.ReadOnly
 		if override.PKColumns != nil {
 			if err := table.SetPK(override.PKColumns); err != nil {
-				log.Warningf("%v: %v", err, override)
+				mlog.Warningf("%v: %v", err, override)
 				continue
 			}
 		}
@@ -216,23 +262,53 @@ func (si *SchemaInfo) override() {
 		case "W":
 			table.CacheType = schema.CACHE_W
 			if override.Cache.Table == "" {
-				log.Warningf("Incomplete cache specs: %v", override)
+				mlog.Warningf("Incomplete cache specs: %v", override)
 				continue
 			}
 			totable, ok := si.tables[override.Cache.Table]
 			if !ok {
-				log.Warningf("Table not found: %v", override)
+				mlog.Warningf("Table not found: %v", override)
 				continue
 			}
 			if totable.Cache == nil {
-				log.Warningf("Table has no cache: %v", override)
+				mlog.Warningf("Table has no cache: %v", override)
 				continue
 			}
 			table.Cache = totable.Cache
 		default:
-			log.Warningf("Ignoring cache override: %v", override)
+			mlog.Warningf("Ignoring cache override: %v", override)
 		}
 	}
+	// The TTLs are set once the caches are, the tables that have a
+	// rowcache can't have one.
+	for _, override := range si.overrides {
+		table, ok := si.tables[override.Name]
+		if !ok || override.TTL == nil {
+			continue
+		}
+		if err := table.SetTTL(override.TTL.Column, time.Duration(override.TTL.Seconds*1e9)); err != nil {
+			mlog.Warningf("%v: %v", err, override)
+		}
+	}
+}
+
+// SetOverrides replaces the schema overrides. The tables that had
+// or have an override are reloaded so the changes take effect.
+func (si *SchemaInfo) SetOverrides(ctx context.Context, schemaOverrides []SchemaOverride) {
+	si.mu.Lock()
+	tableNames := make(map[string]bool)
+	for _, override := range si.overrides {
+		tableNames[override.Name] = true
+	}
+	for _, override := range schemaOverrides {
+		tableNames[override.Name] = true
+	}
+	si.overrides = schemaOverrides
+	si.mu.Unlock()
+
+	for tableName := range tableNames {
+		si.CreateOrUpdateTable(ctx, tableName)
+	}
 }
 
 // Close shuts down SchemaInfo. It can be re-opened after Close.
@@ -241,7 +317,7 @@ func (si *SchemaInfo) Close() {
 	si.connPool.Close()
 	si.tables = nil
 	si.overrides = nil
-	si.queries.Clear()
+	si.clearPlans()
 }
 
 // Reload reloads the schema info from the db. Any tables that have changed
@@ -260,13 +336,13 @@ func (si *SchemaInfo) Reload() {
 		tables, err = conn.Exec(ctx, fmt.Sprintf("%s and unix_timestamp(create_time) >= %v", baseShowTables, si.lastChange.Unix()), maxTableCount, false)
 	}()
 	if err != nil {
-		log.Warningf("Could not get table list for reload: %v", err)
+		mlog.Warningf("Could not get table list for reload: %v", err)
 		return
 	}
-	log.Infof("Reloading schema")
+	mlog.Infof("Reloading schema")
 	for _, row := range tables.Rows {
 		tableName := row[0].String()
-		log.Infof("Reloading: %s", tableName)
+		mlog.Infof("Reloading: %s", tableName)
 		si.CreateOrUpdateTable(ctx, tableName)
 	}
 	si.lastChange = curTime
@@ -299,7 +375,15 @@ func (si *SchemaInfo) triggerReload() {
 func (si *SchemaInfo) ClearQueryPlanCache() {
 	si.mu.Lock()
 	defer si.mu.Unlock()
+	si.clearPlans()
+}
+
+// clearPlans clears the query plans and evicts the prepared
+// statements. It requires the caller to hold a lock on mu.
+func (si *SchemaInfo) clearPlans() {
 	si.queries.Clear()
+	si.statements.Clear()
+	si.planGeneration++
 }
 
 // CreateOrUpdateTable must be called if a DDL was applied to that table.
@@ -333,15 +417,15 @@ func (si *SchemaInfo) CreateOrUpdateTable(ctx context.Context, tableName string)
 		// If the table already exists, we overwrite it with the latest info.
 		// This also means that the query cache needs to be cleared.
 		// Otherwise, the query plans may not be in sync with the schema.
-		si.queries.Clear()
-		log.Infof("Updating table %s", tableName)
+		si.clearPlans()
+		mlog.Infof("Updating table %s", tableName)
 	}
 	si.tables[tableName] = tableInfo
 
 	if tableInfo.CacheType == schema.CACHE_NONE {
-		log.Infof("Initialized table: %s", tableName)
+		mlog.Infof("Initialized table: %s", tableName)
 	} else {
-		log.Infof("Initialized cached table: %s, prefix: %s", tableName, tableInfo.Cache.prefix)
+		mlog.Infof("Initialized cached table: %s, prefix: %s", tableName, tableInfo.Cache.prefix)
 	}
 
 	// If the table has an override, re-apply all overrides.
@@ -359,8 +443,8 @@ func (si *SchemaInfo) DropTable(tableName string) {
 	defer si.mu.Unlock()
 
 	delete(si.tables, tableName)
-	si.queries.Clear()
-	log.Infof("Table %s forgotten", tableName)
+	si.clearPlans()
+	mlog.Infof("Table %s forgotten", tableName)
 }
 
 // GetPlan returns the ExecPlan that for the query. Plans are cached in a cache.LRUCache.
@@ -394,7 +478,9 @@ func (si *SchemaInfo) GetPlan(ctx context.Context, logStats *SQLQueryStats, sql
 	plan.Authorized = tableacl.Authorized(plan.TableName, plan.PlanId.MinRole())
 	if plan.PlanId.IsSelect() {
 		if plan.FieldQuery == nil {
-			log.Warningf("Cannot cache field info: %s", sql)
+			mlog.Warningf("Cannot cache field info: %s", sql)
+		} else if fields := si.normalizedFields(sql, plan); fields != nil {
+			plan.Fields = fields
 		} else {
 			conn := getOrPanic(ctx, si.connPool)
 			defer conn.Recycle()
@@ -407,6 +493,8 @@ func (si *SchemaInfo) GetPlan(ctx context.Context, logStats *SQLQueryStats, sql
 			}
 			plan.Fields = r.Fields
 		}
+	} else if plan.PlanId == planbuilder.PLAN_NEXTVAL {
+		plan.Fields = nextvalFields
 	} else if plan.PlanId == planbuilder.PLAN_DDL || plan.PlanId == planbuilder.PLAN_SET {
 		return plan
 	}
@@ -414,6 +502,66 @@ func (si *SchemaInfo) GetPlan(ctx context.Context, logStats *SQLQueryStats, sql
 	return plan
 }
 
+// normalizedFields returns the field info of the cached plan of the
+// normalized form of sql, the one the warm-up builds, if it has the
+// same field query as plan. It returns nil otherwise.
+func (si *SchemaInfo) normalizedFields(sql string, plan *ExecPlan) []mproto.Field {
+	normalized := normalizeQuery(sql)
+	if normalized == "" || normalized == sql {
+		return nil
+	}
+	warmed := si.getQuery(normalized)
+	if warmed == nil || warmed.FieldQuery == nil || warmed.FieldQuery.Query != plan.FieldQuery.Query {
+		return nil
+	}
+	return warmed.Fields
+}
+
+// Prepare builds the plan for the query, and returns the id of a
+// prepared statement that can be executed without parsing and
+// planning it again. comment is the trailing comment of the query.
+// The statement belongs to owner: its id means nothing to the other
+// clients.
+func (si *SchemaInfo) Prepare(ctx context.Context, logStats *SQLQueryStats, owner, sql, comment string) int64 {
+	for {
+		si.mu.Lock()
+		generation := si.planGeneration
+		si.mu.Unlock()
+
+		plan := si.GetPlan(ctx, logStats, sql)
+
+		si.mu.Lock()
+		// If the plans were cleared while we were building
+		// ours, it may already be obsolete.
+		if generation != si.planGeneration {
+			si.mu.Unlock()
+			continue
+		}
+		statementID := si.lastStatementID.Add(1)
+		si.statements.Set(statementKey(owner, statementID), &PreparedStatement{Sql: sql, Comment: comment, Plan: plan})
+		si.mu.Unlock()
+		return statementID
+	}
+}
+
+// GetPrepared returns the prepared statement of owner, or nil if it
+// was closed or evicted.
+func (si *SchemaInfo) GetPrepared(owner string, statementID int64) *PreparedStatement {
+	if v, ok := si.statements.Get(statementKey(owner, statementID)); ok {
+		return v.(*PreparedStatement)
+	}
+	return nil
+}
+
+// ClosePrepared forgets the prepared statement of owner.
+func (si *SchemaInfo) ClosePrepared(owner string, statementID int64) {
+	si.statements.Delete(statementKey(owner, statementID))
+}
+
+func statementKey(owner string, statementID int64) string {
+	return owner + "/" + strconv.FormatInt(statementID, 10)
+}
+
 // GetStreamPlan is similar to GetPlan, but doesn't use the cache
 // and doesn't enforce a limit. It just returns the parsed query.
 func (si *SchemaInfo) GetStreamPlan(sql string) *ExecPlan {
@@ -444,6 +592,46 @@ func (si *SchemaInfo) GetTable(tableName string) *TableInfo {
 	return si.tables[tableName]
 }
 
+// GetMessageTables returns the names of the message tables.
+func (si *SchemaInfo) GetMessageTables() []string {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	var names []string
+	for name, ti := range si.tables {
+		if ti.IsMessage {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// GetSensitiveTables returns the names of the sensitive tables.
+func (si *SchemaInfo) GetSensitiveTables() map[string]bool {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	names := make(map[string]bool)
+	for name, ti := range si.tables {
+		if ti.Sensitive {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// GetTTLTables returns the expiration of the rows of the tables
+// that have one, by table name.
+func (si *SchemaInfo) GetTTLTables() map[string]TTLInfo {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	ttls := make(map[string]TTLInfo)
+	for name, ti := range si.tables {
+		if ti.TTL != nil {
+			ttls[name] = *ti.TTL
+		}
+	}
+	return ttls
+}
+
 // GetSchema returns a copy of the schema.
 func (si *SchemaInfo) GetSchema() []*schema.Table {
 	si.mu.Lock()
@@ -470,6 +658,14 @@ func (si *SchemaInfo) SetQueryCacheSize(size int) {
 	si.queries.SetCapacity(int64(size))
 }
 
+// SetStatementCacheSize sets the max number of prepared statements.
+func (si *SchemaInfo) SetStatementCacheSize(size int) {
+	if size <= 0 {
+		panic(NewTabletError(ErrFail, "cache size %v out of range", size))
+	}
+	si.statements.SetCapacity(int64(size))
+}
+
 // SetReloadTime changes how often the schema is reloaded. This
 // call also triggers an immediate reload.
 func (si *SchemaInfo) SetReloadTime(reloadTime time.Duration) {
@@ -566,6 +762,56 @@ func (si *SchemaInfo) getQueryStats(f queryStatsFunc) map[string]int64 {
 	return qstats
 }
 
+// getTopQueries returns the n selects of the plan cache that
+// were executed the most, most used first. The queries are
+// normalized by normalizeQuery, and the counts of the ones that
+// only differ by their literals are added up.
+func (si *SchemaInfo) getTopQueries(n int) []string {
+	var counts queryCounts
+	index := make(map[string]int)
+	for _, sql := range si.queries.Keys() {
+		plan := si.getQuery(sql)
+		if plan == nil || !plan.PlanId.IsSelect() {
+			continue
+		}
+		queryCount, _, _, _ := plan.Stats()
+		if queryCount == 0 {
+			continue
+		}
+		normalized := normalizeQuery(sql)
+		if normalized == "" {
+			continue
+		}
+		if i, ok := index[normalized]; ok {
+			counts[i].count += queryCount
+			continue
+		}
+		index[normalized] = len(counts)
+		counts = append(counts, queryCountEntry{sql: normalized, count: queryCount})
+	}
+	sort.Sort(counts)
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	queries := make([]string, 0, len(counts))
+	for _, entry := range counts {
+		queries = append(queries, entry.sql)
+	}
+	return queries
+}
+
+type queryCountEntry struct {
+	sql   string
+	count int64
+}
+
+// queryCounts sorts the queries by decreasing count.
+type queryCounts []queryCountEntry
+
+func (qc queryCounts) Len() int           { return len(qc) }
+func (qc queryCounts) Less(i, j int) bool { return qc[i].count > qc[j].count }
+func (qc queryCounts) Swap(i, j int)      { qc[i], qc[j] = qc[j], qc[i] }
+
 type perQueryStats struct {
 	Query      string
 	Table      string
@@ -328,6 +328,47 @@ func TestSchemaInfoCreateOrUpdateTable(t *testing.T) {
 	schemaInfo.Close()
 }
 
+func TestSchemaInfoSetOverrides(t *testing.T) {
+	fakecacheservice.Register()
+	db := fakesqldb.Register()
+	for query, result := range getSchemaInfoTestSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	existingTable := "test_table_01"
+	createOrDropTableQuery := fmt.Sprintf("%s and table_name = '%s'", baseShowTables, existingTable)
+	db.AddQuery(createOrDropTableQuery, &mproto.QueryResult{
+		RowsAffected: 1,
+		Rows:         [][]sqltypes.Value{createTestTableDescribe("pk")},
+	})
+	schemaInfo := newTestSchemaInfo(10, []string{}, 1*time.Second, 1*time.Second)
+	appParams := sqldb.ConnParams{}
+	dbaParams := sqldb.ConnParams{}
+	cachePool := newTestSchemaInfoCachePool()
+	cachePool.Open()
+	defer cachePool.Close()
+	schemaInfo.Open(&appParams, &dbaParams, nil, cachePool, false)
+	defer schemaInfo.Close()
+
+	schemaInfo.SetOverrides(context.Background(), []SchemaOverride{
+		SchemaOverride{
+			Name:      existingTable,
+			Sensitive: true,
+			ReadOnly:  true,
+		},
+	})
+	testTableInfo := schemaInfo.GetTable(existingTable)
+	if !testTableInfo.Sensitive || !testTableInfo.ReadOnly {
+		t.Fatalf("%s should be sensitive and read-only", existingTable)
+	}
+
+	// removing the override reloads the table without it
+	schemaInfo.SetOverrides(context.Background(), nil)
+	testTableInfo = schemaInfo.GetTable(existingTable)
+	if testTableInfo.Sensitive || testTableInfo.ReadOnly {
+		t.Fatalf("%s should not be sensitive or read-only anymore", existingTable)
+	}
+}
+
 func TestSchemaInfoDropTable(t *testing.T) {
 	fakecacheservice.Register()
 	db := fakesqldb.Register()
@@ -513,6 +554,7 @@ func newTestSchemaInfo(
 	idleTimeout time.Duration) *SchemaInfo {
 	randID := rand.Int63()
 	return NewSchemaInfo(
+		queryCacheSize,
 		queryCacheSize,
 		fmt.Sprintf("TestSchemaInfo-%d-", randID),
 		map[string]string{
@@ -828,6 +870,30 @@ func getTabletErrorString(tabletErrorType int) string {
 		return "ErrTxPoolFull"
 	case ErrNotInTx:
 		return "ErrNotInTx"
+	case ErrRowLimitExceeded:
+		return "ErrRowLimitExceeded"
 	}
 	return ""
 }
+
+func TestCharsetCovers(t *testing.T) {
+	tcases := []struct {
+		conn, column string
+		want         bool
+	}{
+		{"utf8", "utf8", true},
+		{"utf8", "latin1", true},
+		{"utf8", "utf8mb4", false},
+		{"UTF8MB4", "utf16", true},
+		{"latin1", "ascii", true},
+		{"latin1", "utf8", false},
+		{"ascii", "latin1", false},
+		{"binary", "utf8mb4", true},
+		{"cp1251", "utf8mb4", true},
+	}
+	for _, tcase := range tcases {
+		if got := charsetCovers(tcase.conn, tcase.column); got != tcase.want {
+			t.Errorf("charsetCovers(%v, %v) = %v, want %v", tcase.conn, tcase.column, got, tcase.want)
+		}
+	}
+}
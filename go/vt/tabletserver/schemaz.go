@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"sort"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/acl"
 	"github.com/youtube/vitess/go/vt/schema"
 )
@@ -79,7 +78,7 @@ func (rqsc *realQueryServiceControl) registerSchemazHandler() {
 		for _, Value := range sorter.rows {
 			envelope.Table = Value
 			if err := schemazTmpl.Execute(w, envelope); err != nil {
-				log.Errorf("schemaz: couldn't execute template: %v", err)
+				mlog.Errorf("schemaz: couldn't execute template: %v", err)
 			}
 		}
 	})
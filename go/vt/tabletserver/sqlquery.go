@@ -16,15 +16,19 @@ import (
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/stats"
 	"github.com/youtube/vitess/go/tb"
+	"github.com/youtube/vitess/go/vt/callinfo"
 	"github.com/youtube/vitess/go/vt/dbconfigs"
 	"github.com/youtube/vitess/go/vt/dbconnpool"
 	"github.com/youtube/vitess/go/vt/mysqlctl"
+	"github.com/youtube/vitess/go/vt/sqlparser"
 	"github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/topo"
 	"golang.org/x/net/context"
 )
 
 // Allowed state transitions:
 // StateNotServing -> StateInitializing -> StateServing/StateNotServing,
+// StateInitializing -> StateWarmingUp -> StateServing/StateNotServing,
 // StateServing -> StateShuttingTx
 // StateShuttingTx -> StateShuttingQueries
 // StateShuttingQueries -> StateNotServing
@@ -50,6 +54,11 @@ const (
 	// state until all existing queries are completed.
 	// The next state after this is StateNotServing.
 	StateShuttingQueries
+	// StateWarmingUp comes after StateInitializing, if warm-up
+	// is enabled. The query service replays the most used queries
+	// of its previous run before it starts serving. This is a
+	// transient state. It's only informational.
+	StateWarmingUp
 )
 
 // stateName names every state. The number of elements must
@@ -60,6 +69,7 @@ var stateName = []string{
 	"SERVING",
 	"SHUTTING_TX",
 	"SHUTTING_QUERIES",
+	"WARMING_UP",
 }
 
 // SqlQuery implements the RPC interface for the query service.
@@ -77,11 +87,29 @@ type SqlQuery struct {
 	state    int64
 	requests sync.WaitGroup
 
+	// masterHint is the current master of the shard, if this
+	// tablet is not it. It's protected by mu.
+	masterHint *topo.EndPoint
+
+	// tabletType is the type of this tablet. It's protected by mu.
+	tabletType topo.TabletType
+
+	// isMaster is set by setIsMaster. warmPending is true if a
+	// promotion waits for the query service to serve to warm up.
+	// They're protected by mu.
+	isMaster    bool
+	warmPending bool
+	// lameduck is set once the process is shutting down. It's
+	// protected by mu.
+	lameduck bool
+
 	// The following variables should only be accessed within
 	// the context of a startRequest-endRequest.
 	qe        *QueryEngine
 	sessionID int64
 	dbconfig  *dbconfigs.DBConfig
+
+	warmer *queryWarmer
 }
 
 // NewSqlQuery creates an instance of SqlQuery. Only one instance
@@ -89,6 +117,7 @@ type SqlQuery struct {
 func NewSqlQuery(config Config) *SqlQuery {
 	sq := &SqlQuery{
 		config: config,
+		warmer: newQueryWarmer(config.WarmupQueries, config.WarmupFile, time.Duration(config.WarmupTimeout*1e9)),
 	}
 	sq.qe = NewQueryEngine(config)
 	stats.Publish(config.StatsPrefix+"TabletState", stats.IntFunc(func() int64 {
@@ -98,6 +127,7 @@ func NewSqlQuery(config Config) *SqlQuery {
 		return state
 	}))
 	stats.Publish(config.StatsPrefix+"TabletStateName", stats.StringFunc(sq.GetState))
+	stats.Publish(config.StatsPrefix+"WarmupProgress", stats.StringFunc(sq.warmer.status))
 	return sq
 }
 
@@ -109,10 +139,49 @@ func (sq *SqlQuery) GetState() string {
 	return name
 }
 
+// SetMasterHint sets the current master of the shard, that is sent
+// back to the clients whose queries need a master. Use nil if this
+// tablet is the master, or the master is not known.
+func (sq *SqlQuery) SetMasterHint(master *topo.EndPoint) {
+	sq.mu.Lock()
+	sq.masterHint = master
+	sq.mu.Unlock()
+}
+
+// SetTabletType sets the type of this tablet.
+func (sq *SqlQuery) SetTabletType(tabletType topo.TabletType) {
+	sq.mu.Lock()
+	sq.tabletType = tabletType
+	sq.mu.Unlock()
+}
+
+// addMasterHint adds the master hint, if there is one, to the errors
+// MySQL returns when it is read-only, like it is on the old master
+// after a reparent. The clients can then send the query to the new
+// master without waiting for the serving graph to be updated.
+func (sq *SqlQuery) addMasterHint(err *error) {
+	terr, ok := (*err).(*TabletError)
+	if !ok || terr.MasterHint != nil || terr.ErrorType != ErrRetry || terr.SqlError != mysql.ErrOptionPreventsStatement {
+		return
+	}
+	sq.mu.Lock()
+	master := sq.masterHint
+	sq.mu.Unlock()
+	if master == nil {
+		return
+	}
+	*err = &TabletError{
+		ErrorType:  terr.ErrorType,
+		Message:    proto.AppendMasterHint(terr.Message, master),
+		SqlError:   terr.SqlError,
+		MasterHint: master,
+	}
+}
+
 // setState changes the state and logs the event.
 // It requires the caller to hold a lock on mu.
 func (sq *SqlQuery) setState(state int64) {
-	log.Infof("SqlQuery state: %v -> %v", stateName[sq.state], stateName[state])
+	mlog.Infof("SqlQuery state: %v -> %v", stateName[sq.state], stateName[state])
 	sq.state = state
 }
 
@@ -141,7 +210,7 @@ func (sq *SqlQuery) allowQueries(dbconfigs *dbconfigs.DBConfigs, schemaOverrides
 
 	c, err := dbconnpool.NewDBConnection(&dbconfigs.App.ConnParams, mysqlStats)
 	if err != nil {
-		log.Infof("allowQueries failed: %v", err)
+		mlog.Infof("allowQueries failed: %v", err)
 		sq.mu.Lock()
 		sq.setState(StateNotServing)
 		sq.mu.Unlock()
@@ -153,19 +222,26 @@ func (sq *SqlQuery) allowQueries(dbconfigs *dbconfigs.DBConfigs, schemaOverrides
 		state := int64(StateServing)
 		if x := recover(); x != nil {
 			err = x.(*TabletError)
-			log.Errorf("Could not start query service: %v", err)
+			mlog.Errorf("Could not start query service: %v", err)
 			sq.qe.Close()
 			state = StateNotServing
 		}
 		sq.mu.Lock()
 		sq.setState(state)
 		sq.mu.Unlock()
+		sq.warmIfPending()
 	}()
 
 	sq.qe.Open(dbconfigs, schemaOverrides, mysqld)
+	if sq.config.WarmupQueries > 0 {
+		sq.mu.Lock()
+		sq.setState(StateWarmingUp)
+		sq.mu.Unlock()
+		sq.warmer.warm(sq.qe)
+	}
 	sq.dbconfig = &dbconfigs.App
 	sq.sessionID = Rand()
-	log.Infof("Session id: %d", sq.sessionID)
+	mlog.Infof("Session id: %d", sq.sessionID)
 	return nil
 }
 
@@ -211,8 +287,9 @@ func (sq *SqlQuery) disallowQueries() {
 	sq.mu.Lock()
 	sq.setState(StateShuttingQueries)
 	sq.mu.Unlock()
-	// Terminate all streaming queries
+	// Terminate all streaming queries and message streams
 	sq.qe.streamQList.TerminateAll()
+	sq.qe.messager.Close()
 	// Wait for outstanding requests to finish.
 	sq.requests.Wait()
 
@@ -222,12 +299,61 @@ func (sq *SqlQuery) disallowQueries() {
 		sq.setState(StateNotServing)
 		sq.mu.Unlock()
 	}()
-	log.Infof("Stopping query service. Session id: %d", sq.sessionID)
+	mlog.Infof("Stopping query service. Session id: %d", sq.sessionID)
+	sq.warmer.save(sq.qe.schemaInfo)
 	sq.qe.Close()
 	sq.sessionID = 0
 	sq.dbconfig = &dbconfigs.DBConfig{}
 }
 
+// enterLameduck marks the query service as going away. It keeps
+// serving, but reports itself as unhealthy.
+func (sq *SqlQuery) enterLameduck() {
+	sq.mu.Lock()
+	sq.lameduck = true
+	sq.mu.Unlock()
+	mlog.Infof("Query service entering lameduck mode")
+}
+
+func (sq *SqlQuery) isLameduck() bool {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+	return sq.lameduck
+}
+
+// setIsMaster replays the warm-up queries in the background when
+// the tablet becomes the master, to build the plans of the queries
+// it didn't serve as a replica. If the query service isn't serving
+// yet, the warm-up starts once it is.
+func (sq *SqlQuery) setIsMaster(isMaster bool) {
+	sq.mu.Lock()
+	sq.warmPending = isMaster && !sq.isMaster
+	sq.isMaster = isMaster
+	sq.mu.Unlock()
+	sq.warmIfPending()
+}
+
+// warmIfPending starts the warm-up of a promotion in the background,
+// if there's one pending and the query service is serving.
+func (sq *SqlQuery) warmIfPending() {
+	if sq.config.WarmupQueries <= 0 {
+		return
+	}
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+	if !sq.warmPending || sq.state != StateServing {
+		return
+	}
+	sq.warmPending = false
+	// The warm-up counts as a request, so the query service can't
+	// shut down under it.
+	sq.requests.Add(1)
+	go func() {
+		defer sq.endRequest()
+		sq.warmer.warm(sq.qe)
+	}()
+}
+
 // checkMySQL returns true if we can connect to MySQL.
 // The function returns false only if the query service is running
 // and we're unable to make a connection.
@@ -238,7 +364,7 @@ func (sq *SqlQuery) checkMySQL() bool {
 	defer sq.endRequest()
 	defer func() {
 		if x := recover(); x != nil {
-			log.Errorf("Checking MySQL, unexpected error: %v", x)
+			mlog.Errorf("Checking MySQL, unexpected error: %v", x)
 		}
 	}()
 	return sq.qe.CheckMySQL()
@@ -258,6 +384,8 @@ func (sq *SqlQuery) GetSessionId(sessionParams *proto.SessionParams, sessionInfo
 		return NewTabletError(ErrFatal, "Shard mismatch, expecting %v, received %v", sq.dbconfig.Shard, sessionParams.Shard)
 	}
 	sessionInfo.SessionId = sq.sessionID
+	sessionInfo.ProtocolVersion = proto.ProtocolVersion
+	sessionInfo.Features = proto.SupportedFeatures()
 	return nil
 }
 
@@ -277,7 +405,12 @@ func (sq *SqlQuery) Begin(ctx context.Context, session *proto.Session, txInfo *p
 		sq.endRequest()
 	}()
 
-	txInfo.TransactionId = sq.qe.txPool.Begin(ctx)
+	if session.TransactionOptions == nil || !session.TransactionOptions.ReadOnly {
+		if err = sq.qe.txThrottler.Throttle(ctx); err != nil {
+			return err
+		}
+	}
+	txInfo.TransactionId = sq.qe.txPool.BeginWithOptions(ctx, session.TransactionOptions)
 	logStats.TransactionID = txInfo.TransactionId
 	return nil
 }
@@ -330,7 +463,7 @@ func (sq *SqlQuery) handleExecError(query *proto.Query, err *error, logStats *SQ
 	if x := recover(); x != nil {
 		terr, ok := x.(*TabletError)
 		if !ok {
-			log.Errorf("Uncaught panic for %v:\n%v\n%s", query, x, tb.Stack(4))
+			mlog.Errorf("Uncaught panic for %v:\n%v\n%s", query, x, tb.Stack(4))
 			*err = NewTabletError(ErrFail, "%v: uncaught panic for %v", x, query)
 			internalErrors.Add("Panic", 1)
 			return
@@ -350,9 +483,9 @@ func (sq *SqlQuery) handleExecError(query *proto.Query, err *error, logStats *SQ
 			return
 		}
 		if terr.ErrorType == ErrFatal {
-			log.Errorf("%v: %v", terr, query)
+			mlog.Errorf("%v: %v", terr, query)
 		} else {
-			log.Warningf("%v: %v", terr, query)
+			mlog.Warningf("%v: %v", terr, query)
 		}
 	}
 	if logStats != nil {
@@ -361,41 +494,185 @@ func (sq *SqlQuery) handleExecError(query *proto.Query, err *error, logStats *SQ
 	}
 }
 
+// typeBindVars returns the typed representation of bindVars,
+// which the executor uses to generate the sql. It panics with an
+// error naming the bind variable that has an unsupported type,
+// if any.
+func typeBindVars(bindVars map[string]interface{}) map[string]*sqlparser.BindVariable {
+	typed, err := sqlparser.NewBindVariables(bindVars)
+	if err != nil {
+		panic(NewTabletError(ErrFail, "%v", err))
+	}
+	return typed
+}
+
 // Execute executes the query and returns the result as response.
 func (sq *SqlQuery) Execute(ctx context.Context, query *proto.Query, reply *mproto.QueryResult) (err error) {
-	logStats := newSqlQueryStats("Execute", ctx)
+	return sq.execute(ctx, "Execute", query, nil, reply)
+}
+
+// execute runs the query of Execute and ExecutePrepared. A prepared
+// statement supplies the plan, otherwise it comes from the cache.
+func (sq *SqlQuery) execute(ctx context.Context, method string, query *proto.Query, stmt *PreparedStatement, reply *mproto.QueryResult) (err error) {
+	logStats := newSqlQueryStats(method, ctx)
+	defer sq.addMasterHint(&err)
 	defer sq.handleExecError(query, &err, logStats)
 
 	allowShutdown := (query.TransactionId != 0)
 	if err = sq.startRequest(query.SessionId, false, allowShutdown); err != nil {
 		return err
 	}
+	defer sq.endRequest()
+
+	if query.BindVariables == nil {
+		query.BindVariables = make(map[string]interface{})
+	}
+	typedBindVars := typeBindVars(query.BindVariables)
+	stripTrailing(query)
+	// The queries of a transaction run on its connection, they are
+	// never OLAP.
+	olap := query.TransactionId == 0 && sq.qe.olapConnPool.Capacity() > 0 && sq.qe.workload.isOLAP(ctx, query.BindVariables)
+	timeout := sq.qe.queryTimeout.Get()
+	if olap {
+		timeout = sq.qe.olapQueryTimeout.Get()
+	}
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+
+	var plan *ExecPlan
+	if stmt != nil {
+		plan = stmt.Plan
+	} else {
+		plan = sq.qe.schemaInfo.GetPlan(ctx, logStats, query.Sql)
+	}
+	qre := &QueryExecutor{
+		query:          query.Sql,
+		bindVars:       query.BindVariables,
+		typedBindVars:  typedBindVars,
+		transactionID:  query.TransactionId,
+		plan:           plan,
+		ctx:            ctx,
+		logStats:       logStats,
+		qe:             sq.qe,
+		fieldsOnly:     query.FieldsOnly,
+		olap:           olap,
+		idempotencyKey: query.IdempotencyKey,
+	}
+	*reply = *qre.Execute()
+	return nil
+}
+
+// Prepare plans the query and returns the id of a prepared statement
+// for it. The statement can then be run with ExecutePrepared without
+// being parsed and planned again. Statements are evicted when the
+// schema changes, after which they must be prepared again.
+func (sq *SqlQuery) Prepare(ctx context.Context, req *proto.PrepareRequest, reply *proto.PrepareResult) (err error) {
+	logStats := newSqlQueryStats("Prepare", ctx)
+	query := &proto.Query{
+		Sql:           req.Sql,
+		BindVariables: make(map[string]interface{}),
+		SessionId:     req.SessionId,
+	}
+	defer sq.handleExecError(query, &err, logStats)
+
+	if err = sq.startRequest(req.SessionId, false, false); err != nil {
+		return err
+	}
 	ctx, cancel := withTimeout(ctx, sq.qe.queryTimeout.Get())
 	defer func() {
 		cancel()
 		sq.endRequest()
 	}()
 
+	stripTrailing(query)
+	comment, _ := query.BindVariables[TRAILING_COMMENT].(string)
+	logStats.OriginalSql = query.Sql
+	reply.StatementId = sq.qe.schemaInfo.Prepare(ctx, logStats, statementOwner(ctx), query.Sql, comment)
+	return nil
+}
+
+// ExecutePrepared executes a prepared statement. It works like
+// Execute, except that it reuses the plan of the statement. Only the
+// client that prepared a statement can execute it.
+func (sq *SqlQuery) ExecutePrepared(ctx context.Context, req *proto.ExecutePreparedRequest, reply *mproto.QueryResult) (err error) {
+	stmt := sq.qe.schemaInfo.GetPrepared(statementOwner(ctx), req.StatementId)
+	if stmt == nil {
+		return NewTabletError(ErrFail, "prepared statement %d not found, it may have been evicted by a schema change", req.StatementId)
+	}
+	query := &proto.Query{
+		Sql:           stmt.Sql + stmt.Comment,
+		BindVariables: req.BindVariables,
+		SessionId:     req.SessionId,
+		TransactionId: req.TransactionId,
+	}
+	return sq.execute(ctx, "ExecutePrepared", query, stmt, reply)
+}
+
+// ClosePrepared releases a prepared statement. It's not an error
+// to close a statement that was already evicted.
+func (sq *SqlQuery) ClosePrepared(ctx context.Context, req *proto.ClosePreparedRequest) (err error) {
+	if err = sq.startRequest(req.SessionId, false, true); err != nil {
+		return err
+	}
+	defer sq.endRequest()
+	sq.qe.schemaInfo.ClosePrepared(statementOwner(ctx), req.StatementId)
+	return nil
+}
+
+// statementOwner returns the client a prepared statement belongs to:
+// the user and the address of its connection.
+func statementOwner(ctx context.Context) string {
+	ci, ok := callinfo.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return ci.Username() + "@" + ci.RemoteAddr()
+}
+
+// Explain returns the plan of a query without executing it. The plan
+// comes from the same cache as the one of Execute.
+func (sq *SqlQuery) Explain(ctx context.Context, query *proto.Query, reply *proto.ExplainResult) (err error) {
+	logStats := newSqlQueryStats("Explain", ctx)
+	defer sq.handleExecError(query, &err, logStats)
+
+	if err = sq.startRequest(query.SessionId, false, false); err != nil {
+		return err
+	}
+	defer sq.endRequest()
+
 	if query.BindVariables == nil {
 		query.BindVariables = make(map[string]interface{})
 	}
 	stripTrailing(query)
-	qre := &QueryExecutor{
-		query:         query.Sql,
-		bindVars:      query.BindVariables,
-		transactionID: query.TransactionId,
-		plan:          sq.qe.schemaInfo.GetPlan(ctx, logStats, query.Sql),
-		ctx:           ctx,
-		logStats:      logStats,
-		qe:            sq.qe,
+	logStats.OriginalSql = query.Sql
+	plan := sq.qe.schemaInfo.GetPlan(ctx, logStats, query.Sql)
+	*reply = proto.ExplainResult{
+		PlanType:   plan.PlanId.String(),
+		Reason:     plan.Reason.String(),
+		TableName:  plan.TableName,
+		IndexUsed:  plan.IndexUsed,
+		FieldQuery: parsedQueryString(plan.FieldQuery),
+		FullQuery:  parsedQueryString(plan.FullQuery),
+		OuterQuery: parsedQueryString(plan.OuterQuery),
+		Subquery:   parsedQueryString(plan.Subquery),
+	}
+	for _, value := range plan.PKValues {
+		reply.PKValues = append(reply.PKValues, fmt.Sprintf("%v", value))
 	}
-	*reply = *qre.Execute()
 	return nil
 }
 
+func parsedQueryString(pq *sqlparser.ParsedQuery) string {
+	if pq == nil {
+		return ""
+	}
+	return pq.Query
+}
+
 // StreamExecute executes the query and streams the result.
 // The first QueryResult will have Fields set (and Rows nil).
 // The subsequent QueryResult will have Rows set (and Fields nil).
+// sendReply must not keep the QueryResults: their memory is reused.
 func (sq *SqlQuery) StreamExecute(ctx context.Context, query *proto.Query, sendReply func(*mproto.QueryResult) error) (err error) {
 	// check cases we don't handle yet
 	if query.TransactionId != 0 {
@@ -413,15 +690,25 @@ func (sq *SqlQuery) StreamExecute(ctx context.Context, query *proto.Query, sendR
 	if query.BindVariables == nil {
 		query.BindVariables = make(map[string]interface{})
 	}
+	typedBindVars := typeBindVars(query.BindVariables)
 	stripTrailing(query)
+	var plan *ExecPlan
+	if query.FieldsOnly {
+		// The fields are cached with the regular plan.
+		plan = sq.qe.schemaInfo.GetPlan(ctx, logStats, query.Sql)
+	} else {
+		plan = sq.qe.schemaInfo.GetStreamPlan(query.Sql)
+	}
 	qre := &QueryExecutor{
 		query:         query.Sql,
 		bindVars:      query.BindVariables,
+		typedBindVars: typedBindVars,
 		transactionID: query.TransactionId,
-		plan:          sq.qe.schemaInfo.GetStreamPlan(query.Sql),
+		plan:          plan,
 		ctx:           ctx,
 		logStats:      logStats,
 		qe:            sq.qe,
+		fieldsOnly:    query.FieldsOnly,
 	}
 	qre.Stream(sendReply)
 	return nil
@@ -440,6 +727,7 @@ func (sq *SqlQuery) ExecuteBatch(ctx context.Context, queryList *proto.QueryList
 		return err
 	}
 	defer sq.endRequest()
+	defer sq.addMasterHint(&err)
 	defer handleError(&err, nil)
 
 	beginCalled := false
@@ -534,6 +822,136 @@ func (sq *SqlQuery) SplitQuery(ctx context.Context, req *proto.SplitQueryRequest
 	return nil
 }
 
+// MessageStream streams the messages of a message table. The first
+// QueryResult has the Fields, the subsequent ones have the Rows of
+// the messages as they're sent. Every message must be acked with
+// MessageAck, or it's sent again.
+func (sq *SqlQuery) MessageStream(ctx context.Context, req *proto.MessageStreamRequest, sendReply func(*mproto.QueryResult) error) (err error) {
+	logStats := newSqlQueryStats("MessageStream", ctx)
+	defer handleError(&err, logStats)
+	if err = sq.startRequest(req.SessionId, false, false); err != nil {
+		return err
+	}
+	defer sq.endRequest()
+
+	if err = sq.checkMessageTable(req.Name); err != nil {
+		return err
+	}
+	fields, err := sq.qe.messager.fields(ctx, req.Name)
+	if err != nil {
+		return err
+	}
+	if err = sendReply(&mproto.QueryResult{Fields: fields}); err != nil {
+		return err
+	}
+	rcv, err := sq.qe.messager.subscribe(req.Name, sendReply)
+	if err != nil {
+		return err
+	}
+	defer sq.qe.messager.unsubscribe(req.Name, rcv)
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-rcv.done:
+		return rcv.err
+	}
+}
+
+// MessageAck acks the messages of a message table, so they're not
+// sent again.
+func (sq *SqlQuery) MessageAck(ctx context.Context, req *proto.MessageAckRequest, reply *proto.MessageAckResult) (err error) {
+	logStats := newSqlQueryStats("MessageAck", ctx)
+	defer handleError(&err, logStats)
+	if err = sq.startRequest(req.SessionId, false, false); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, sq.qe.queryTimeout.Get())
+	defer func() {
+		cancel()
+		sq.endRequest()
+	}()
+
+	if err = sq.checkMessageTable(req.Name); err != nil {
+		return err
+	}
+	reply.Count, err = sq.qe.messager.ack(ctx, req.Name, req.Ids)
+	return err
+}
+
+// ExportTable streams all the rows of a table, or only the ones in a
+// keyrange, for bulk exports. It is meant to be used on rdonly
+// tablets. The first chunk has the header of the export, with a
+// replication position read before the rows, and the next ones the
+// rows, encoded column by column.
+func (sq *SqlQuery) ExportTable(ctx context.Context, req *proto.ExportTableRequest, sendReply func(*proto.ExportChunk) error) (err error) {
+	logStats := newSqlQueryStats("ExportTable", ctx)
+	defer handleError(&err, logStats)
+	if err = sq.startRequest(req.SessionId, false, false); err != nil {
+		return err
+	}
+	defer sq.endRequest()
+
+	// An export is a full table scan, it would hurt the serving
+	// tablets.
+	sq.mu.Lock()
+	tabletType := sq.tabletType
+	sq.mu.Unlock()
+	if tabletType != topo.TYPE_RDONLY {
+		return NewTabletError(ErrFail, "exportTable: only the rdonly tablets export their tables, this one is %v", tabletType)
+	}
+	ti := sq.qe.schemaInfo.GetTable(req.Table)
+	if ti == nil {
+		return NewTabletError(ErrFail, "table %s not found in schema", req.Table)
+	}
+	sql, pkColumns, err := exportQuery(ti, req)
+	if err != nil {
+		return NewTabletError(ErrFail, "exportTable: %v", err)
+	}
+	position, err := sq.qe.mysqld.MasterPosition()
+	if err != nil {
+		return NewTabletError(ErrFail, "exportTable: cannot read the replication position: %v", err)
+	}
+
+	qre := &QueryExecutor{
+		query:    sql,
+		bindVars: make(map[string]interface{}),
+		plan:     sq.qe.schemaInfo.GetStreamPlan(sql),
+		ctx:      ctx,
+		logStats: logStats,
+		qe:       sq.qe,
+	}
+	headerSent := false
+	qre.Stream(func(qr *mproto.QueryResult) error {
+		// The first result has the fields.
+		if !headerSent {
+			headerSent = true
+			return sendReply(&proto.ExportChunk{
+				Header: &proto.ExportHeader{
+					Fields:            qr.Fields,
+					PrimaryKeyColumns: pkColumns,
+					Position:          position,
+				},
+			})
+		}
+		if len(qr.Rows) == 0 {
+			return nil
+		}
+		return sendReply(&proto.ExportChunk{
+			RowCount: len(qr.Rows),
+			Columns:  proto.EncodeExportColumns(len(ti.Columns), qr.Rows),
+		})
+	})
+	return nil
+}
+
+func (sq *SqlQuery) checkMessageTable(name string) error {
+	ti := sq.qe.schemaInfo.GetTable(name)
+	if ti == nil || !ti.IsMessage {
+		return NewTabletError(ErrFail, "%s is not a message table", name)
+	}
+	return nil
+}
+
 // startRequest validates the current state and sessionID and registers
 // the request (a waitgroup) as started. Every startRequest requires one
 // and only one corresponding endRequest. When the service shuts down,
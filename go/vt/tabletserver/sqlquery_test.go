@@ -7,16 +7,22 @@ package tabletserver
 import (
 	"fmt"
 	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/youtube/vitess/go/mysql"
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/sqldb"
 	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/callinfo"
 	"github.com/youtube/vitess/go/vt/dbconfigs"
 	"github.com/youtube/vitess/go/vt/mysqlctl"
 	"github.com/youtube/vitess/go/vt/tabletserver/fakesqldb"
 	"github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vterrors"
 	"golang.org/x/net/context"
 )
 
@@ -69,6 +75,30 @@ func TestCheckMysql(t *testing.T) {
 	sqlQuery.checkMySQL()
 }
 
+func TestLameduckIsUnhealthy(t *testing.T) {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	sqlQuery := getSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	qsc := &realQueryServiceControl{sqlQueryRPCService: sqlQuery}
+	if err := qsc.IsHealthy(); err != nil {
+		t.Fatalf("IsHealthy failed: %v", err)
+	}
+	qsc.EnterLameduck()
+	if err := qsc.IsHealthy(); err == nil {
+		t.Errorf("IsHealthy in lameduck mode should fail")
+	}
+	if state := sqlQuery.GetState(); state != "SERVING" {
+		t.Errorf("state in lameduck mode: %s, want SERVING", state)
+	}
+}
+
 func TestGetSessionId(t *testing.T) {
 	db := fakesqldb.Register()
 	for query, result := range getSupportedQueries() {
@@ -99,6 +129,11 @@ func TestGetSessionId(t *testing.T) {
 			"expect seesion id: %d but got %d", sqlQuery.sessionID,
 			sessionInfo.SessionId)
 	}
+	if sessionInfo.ProtocolVersion != proto.ProtocolVersion || !sessionInfo.HasFeature(proto.FeatureExplain) || !sessionInfo.HasFeature(proto.FeatureFieldsOnly) {
+		t.Fatalf("call GetSessionId returns protocol version %v and features %v, "+
+			"expect version %v and all the features", sessionInfo.ProtocolVersion,
+			sessionInfo.Features, proto.ProtocolVersion)
+	}
 	err = sqlQuery.GetSessionId(
 		&proto.SessionParams{Keyspace: keyspace},
 		&sessionInfo,
@@ -266,6 +301,44 @@ func TestStreamExecute(t *testing.T) {
 	}
 }
 
+func TestStreamExecuteFieldsOnly(t *testing.T) {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	executeSql := "select * from test_table"
+	db.AddRejectedQuery(executeSql)
+	db.AddRejectedQuery("select * from test_table limit 10001")
+	db.AddQuery("select * from test_table where 1 != 1", &mproto.QueryResult{
+		Fields: getTestTableFields(),
+	})
+
+	sqlQuery := getSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs))
+	if err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	ctx := context.Background()
+	query := proto.Query{
+		Sql:        executeSql,
+		SessionId:  sqlQuery.sessionID,
+		FieldsOnly: true,
+	}
+	var results []*mproto.QueryResult
+	sendReply := func(qr *mproto.QueryResult) error {
+		results = append(results, qr)
+		return nil
+	}
+	if err := sqlQuery.StreamExecute(ctx, &query, sendReply); err != nil {
+		t.Fatalf("SqlQuery.StreamExecute failed: %v", err)
+	}
+	if len(results) != 1 || len(results[0].Fields) != len(getTestTableFields()) || len(results[0].Rows) != 0 {
+		t.Errorf("SqlQuery.StreamExecute: %v, want only the fields", results)
+	}
+}
+
 func TestExecuteBatch(t *testing.T) {
 	sql := "INSERT INTO test_table VALUES(1, 2)"
 	sqlResult := &mproto.QueryResult{
@@ -379,6 +452,205 @@ func TestExecuteBatchNestedTransaction(t *testing.T) {
 	sqlQuery.qe.txPool.SetTimeout(10)
 }
 
+func TestExecuteBadBindVar(t *testing.T) {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	sqlQuery := getSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs))
+	if err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	ctx := context.Background()
+
+	query := proto.Query{
+		Sql:           "select * from test_table where pk = :pk",
+		BindVariables: map[string]interface{}{"pk": struct{}{}},
+		SessionId:     sqlQuery.sessionID,
+	}
+	err = sqlQuery.Execute(ctx, &query, &mproto.QueryResult{})
+	want := "error: bind variable pk: unsupported bind variable type struct {}: {}"
+	if err == nil || err.Error() != want {
+		t.Errorf("Execute: %v, want %s", err, want)
+	}
+	err = sqlQuery.StreamExecute(ctx, &query, func(*mproto.QueryResult) error { return nil })
+	if err == nil || err.Error() != want {
+		t.Errorf("StreamExecute: %v, want %s", err, want)
+	}
+}
+
+func TestExecuteTupleListBindVar(t *testing.T) {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	executeSql := "select * from test_table where (pk1, pk2) in ((1, 'aa'), (2, 'bb')) limit 10001"
+	db.AddQuery(executeSql, &mproto.QueryResult{})
+	db.AddQuery("select * from test_table where 1 != 1", &mproto.QueryResult{})
+	sqlQuery := getSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs))
+	if err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	ctx := context.Background()
+
+	query := proto.Query{
+		Sql: "select * from test_table where (pk1, pk2) in ::list",
+		BindVariables: map[string]interface{}{
+			"list": []interface{}{
+				[]interface{}{1, "aa"},
+				[]interface{}{2, []byte("bb")},
+			},
+		},
+		SessionId: sqlQuery.sessionID,
+	}
+	// fakesqldb fails the queries it doesn't know, so this
+	// only succeeds if executeSql was generated.
+	if err := sqlQuery.Execute(ctx, &query, &mproto.QueryResult{}); err != nil {
+		t.Errorf("Execute: %v", err)
+	}
+}
+
+func TestExecuteInternalBindVar(t *testing.T) {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	executeSql := "select * from test_table limit 10001"
+	db.AddQuery(executeSql, &mproto.QueryResult{})
+	db.AddQuery("select * from test_table where 1 != 1", &mproto.QueryResult{})
+	sqlQuery := getSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs))
+	if err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	ctx := context.Background()
+
+	// the client can't lift the row limit
+	query := proto.Query{
+		Sql:           "select * from test_table",
+		BindVariables: map[string]interface{}{"#maxLimit": 1000000000},
+		SessionId:     sqlQuery.sessionID,
+	}
+	if err := sqlQuery.Execute(ctx, &query, &mproto.QueryResult{}); err != nil {
+		t.Errorf("Execute: %v", err)
+	}
+}
+
+func TestPreparedStatement(t *testing.T) {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	sql := "select * from test_table limit 1000"
+	sqlResult := &mproto.QueryResult{
+		Fields: getTestTableFields(),
+		Rows:   [][]sqltypes.Value{},
+	}
+	db.AddQuery(sql, sqlResult)
+	db.AddQuery("select * from test_table where 1 != 1", &mproto.QueryResult{
+		Fields: getTestTableFields(),
+	})
+
+	sqlQuery := getSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs))
+	if err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	ctx := context.Background()
+
+	prepareReply := proto.PrepareResult{}
+	prepareReq := proto.PrepareRequest{Sql: sql, SessionId: sqlQuery.sessionID}
+	if err := sqlQuery.Prepare(ctx, &prepareReq, &prepareReply); err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	executeReq := proto.ExecutePreparedRequest{
+		StatementId: prepareReply.StatementId,
+		SessionId:   sqlQuery.sessionID,
+	}
+	reply := mproto.QueryResult{}
+	if err := sqlQuery.ExecutePrepared(ctx, &executeReq, &reply); err != nil {
+		t.Fatalf("ExecutePrepared failed: %v", err)
+	}
+	if len(reply.Fields) != len(sqlResult.Fields) {
+		t.Errorf("ExecutePrepared fields: got %v, want %v", reply.Fields, sqlResult.Fields)
+	}
+
+	// The statement belongs to the client that prepared it.
+	otherCtx := callinfo.NewContext(ctx, &fakeCallInfo{remoteAddr: "1.2.3.4:5678", username: "other"})
+	err = sqlQuery.ExecutePrepared(otherCtx, &executeReq, &reply)
+	want := fmt.Sprintf("prepared statement %d not found", prepareReply.StatementId)
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("ExecutePrepared from another client: %v, want %s", err, want)
+	}
+
+	// A schema change evicts the prepared statements.
+	sqlQuery.qe.schemaInfo.ClearQueryPlanCache()
+	err = sqlQuery.ExecutePrepared(ctx, &executeReq, &reply)
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("ExecutePrepared: %v, want %s", err, want)
+	}
+	closeReq := proto.ClosePreparedRequest{
+		StatementId: prepareReply.StatementId,
+		SessionId:   sqlQuery.sessionID,
+	}
+	if err := sqlQuery.ClosePrepared(ctx, &closeReq); err != nil {
+		t.Fatalf("ClosePrepared failed: %v", err)
+	}
+}
+
+func TestSqlQueryExplain(t *testing.T) {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	db.AddQuery("select * from test_table where 1 != 1", &mproto.QueryResult{
+		Fields: getTestTableFields(),
+	})
+	sqlQuery := getSqlQuery()
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs))
+	if err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	defer sqlQuery.disallowQueries()
+	ctx := context.Background()
+
+	query := proto.Query{
+		Sql:       "select * from test_table where pk in (1, :pk) /* trailing */",
+		SessionId: sqlQuery.sessionID,
+	}
+	reply := proto.ExplainResult{}
+	if err := sqlQuery.Explain(ctx, &query, &reply); err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	// test_table isn't cached, its primary key isn't used.
+	want := proto.ExplainResult{
+		PlanType:   "PASS_SELECT",
+		Reason:     "NOCACHE",
+		TableName:  "test_table",
+		FieldQuery: "select * from test_table where 1 != 1",
+		FullQuery:  "select * from test_table where pk in (1, :pk) limit :#maxLimit",
+	}
+	if !reflect.DeepEqual(reply, want) {
+		t.Errorf("Explain: got %#v, want %#v", reply, want)
+	}
+
+	query = proto.Query{Sql: "select * from", SessionId: sqlQuery.sessionID}
+	if err := sqlQuery.Explain(ctx, &query, &reply); err == nil {
+		t.Errorf("Explain of an invalid query succeeded")
+	}
+}
+
 func TestSqlQuerySplitQuery(t *testing.T) {
 	sql := "INSERT INTO test_table VALUES(1, 2)"
 	sqlResult := &mproto.QueryResult{
@@ -501,6 +773,47 @@ func TestTerseErrors2(t *testing.T) {
 	})
 }
 
+func TestMasterHint(t *testing.T) {
+	readOnlyErr := func() error {
+		return &TabletError{
+			ErrorType: ErrRetry,
+			Message:   "read-only",
+			SqlError:  mysql.ErrOptionPreventsStatement,
+		}
+	}
+	sq := &SqlQuery{}
+	err := readOnlyErr()
+	sq.addMasterHint(&err)
+	if err.(*TabletError).MasterHint != nil {
+		t.Errorf("got a master hint without one set: %v", err)
+	}
+
+	master := &topo.EndPoint{Uid: 1, Host: "master", NamedPortMap: map[string]int{"vt": 15101}}
+	sq.SetMasterHint(master)
+	err = readOnlyErr()
+	sq.addMasterHint(&err)
+	terr := err.(*TabletError)
+	if terr.MasterHint != master || terr.VtErrorCode() != vterrors.NotMaster {
+		t.Errorf("got %#v, want a NotMaster error", terr)
+	}
+	if got := proto.ParseMasterHint(err.Error()); got == nil || got.Host != "master" {
+		t.Errorf("error %v doesn't have the master hint", err)
+	}
+	// the hint is only added once
+	message := terr.Message
+	sq.addMasterHint(&err)
+	if err.(*TabletError).Message != message {
+		t.Errorf("hint added twice: %v", err)
+	}
+
+	// other errors are left alone
+	err = NewTabletError(ErrRetry, "not serving")
+	sq.addMasterHint(&err)
+	if err.(*TabletError).MasterHint != nil {
+		t.Errorf("got a master hint for %v", err)
+	}
+}
+
 func getSqlQuery() *SqlQuery {
 	randID := rand.Int63()
 	config := DefaultQsConfig
@@ -1,6 +1,8 @@
 package tabletserver
 
 import (
+	"time"
+
 	"github.com/youtube/vitess/go/vt/servenv"
 )
 
@@ -8,6 +10,12 @@ import (
 
 var queryserviceStatusTemplate = `
 State: {{.State}}<br>
+{{if .Warmup}}Warm-up: {{.Warmup}}<br>{{end}}
+<table>
+  <tr><th>Pool</th><th>Capacity</th><th>Available</th><th>Waits</th><th>Wait time</th></tr>
+  {{range .Pools}}<tr><td>{{.Name}}</td><td>{{.Capacity}}</td><td>{{.Available}}</td><td>{{.WaitCount}}</td><td>{{.WaitTime}}</td></tr>
+  {{end}}
+</table>
 <div id="qps_chart">QPS: {{.CurrentQPS}}</div>
 <script type="text/javascript" src="https://www.google.com/jsapi"></script>
 <script type="text/javascript">
@@ -80,14 +88,42 @@ google.setOnLoadCallback(drawQPSChart);
 
 type queryserviceStatus struct {
 	State      string
+	Warmup     string
 	CurrentQPS float64
+	Pools      []poolStatus
+}
+
+// poolStatus is the usage of a connection pool.
+type poolStatus struct {
+	Name      string
+	Capacity  int64
+	Available int64
+	WaitCount int64
+	WaitTime  time.Duration
+}
+
+func newPoolStatus(name string, cp *ConnPool) poolStatus {
+	return poolStatus{
+		Name:      name,
+		Capacity:  cp.Capacity(),
+		Available: cp.Available(),
+		WaitCount: cp.WaitCount(),
+		WaitTime:  cp.WaitTime(),
+	}
 }
 
 // AddStatusPart registers the status part for the status page.
 func (rqsc *realQueryServiceControl) AddStatusPart() {
 	servenv.AddStatusPart("Queryservice", queryserviceStatusTemplate, func() interface{} {
 		status := queryserviceStatus{
-			State: rqsc.sqlQueryRPCService.GetState(),
+			State:  rqsc.sqlQueryRPCService.GetState(),
+			Warmup: rqsc.sqlQueryRPCService.warmer.status(),
+		}
+		qe := rqsc.sqlQueryRPCService.qe
+		status.Pools = []poolStatus{
+			newPoolStatus("Query", qe.connPool),
+			newPoolStatus("Stream", qe.streamConnPool),
+			newPoolStatus("Transaction", qe.txPool.pool),
 		}
 		rates := qpsRates.Get()
 		if qps, ok := rates["All"]; ok && len(qps) > 0 {
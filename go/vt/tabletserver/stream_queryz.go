@@ -7,7 +7,6 @@ import (
 	"strconv"
 	"text/template"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/acl"
 )
 
@@ -62,7 +61,7 @@ func (rqsc *realQueryServiceControl) registerStreamQueryzHandlers() {
 		w.Write(streamqueryzHeader)
 		for i := range rows {
 			if err := streamqueryzTmpl.Execute(w, rows[i]); err != nil {
-				log.Errorf("streamlogz: couldn't execute template: %v", err)
+				mlog.Errorf("streamlogz: couldn't execute template: %v", err)
 			}
 		}
 	}
@@ -12,9 +12,9 @@ import (
 	"strings"
 	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/sqltypes"
 	"github.com/youtube/vitess/go/streamlog"
+	"github.com/youtube/vitess/go/trace"
 	"github.com/youtube/vitess/go/vt/callinfo"
 	"golang.org/x/net/context"
 )
@@ -48,6 +48,7 @@ type SQLQueryStats struct {
 	QuerySources         byte
 	Rows                 [][]sqltypes.Value
 	TransactionID        int64
+	Sensitive            bool
 	context              context.Context
 	Error                error
 }
@@ -63,6 +64,7 @@ func newSqlQueryStats(methodName string, context context.Context) *SQLQueryStats
 // Send finalizes a record and sends it
 func (stats *SQLQueryStats) Send() {
 	stats.EndTime = time.Now()
+	queryDigests.Record(stats)
 	SqlQueryLogger.Send(stats)
 }
 
@@ -79,9 +81,22 @@ func (stats *SQLQueryStats) TotalTime() time.Duration {
 	return stats.EndTime.Sub(stats.StartTime)
 }
 
+// FmtOriginalSql returns the query sent by the client. It can have
+// values in it, so it is not reported for sensitive tables.
+func (stats *SQLQueryStats) FmtOriginalSql() string {
+	if stats.Sensitive {
+		return "[REDACTED]"
+	}
+	return stats.OriginalSql
+}
+
 // RewrittenSql returns a semicolon separated list of SQL statements
-// that were executed.
+// that were executed. The statements contain the values of the bind
+// variables, so they are not reported for sensitive tables.
 func (stats *SQLQueryStats) RewrittenSql() string {
+	if stats.Sensitive {
+		return "[REDACTED]"
+	}
 	return strings.Join(stats.rewrittenSqls, "; ")
 }
 
@@ -103,10 +118,16 @@ func (stats *SQLQueryStats) SizeOfResponse() int {
 
 // FmtBindVariables returns the map of bind variables as JSON. For
 // values that are strings or byte slices it only reports their type
-// and length.
+// and length. For sensitive tables, it only reports the names of the
+// bind variables.
 func (stats *SQLQueryStats) FmtBindVariables(full bool) string {
 	var out map[string]interface{}
-	if full {
+	if stats.Sensitive {
+		out = make(map[string]interface{})
+		for k := range stats.BindVariables {
+			out[k] = "[REDACTED]"
+		}
+	} else if full {
 		out = stats.BindVariables
 	} else {
 		// NOTE(szopa): I am getting rid of potentially large bind
@@ -125,7 +146,7 @@ func (stats *SQLQueryStats) FmtBindVariables(full bool) string {
 	}
 	b, err := json.Marshal(out)
 	if err != nil {
-		log.Warningf("could not marshal %q", stats.BindVariables)
+		mlog.Warningf("could not marshal %q", stats.BindVariables)
 		return ""
 	}
 	return string(b)
@@ -179,13 +200,18 @@ func (stats *SQLQueryStats) RemoteAddrUsername() (string, string) {
 	return ci.RemoteAddr(), ci.Username()
 }
 
+// TraceID returns the ID of the trace the query is part of, or "".
+func (stats *SQLQueryStats) TraceID() string {
+	return trace.TraceID(stats.context)
+}
+
 // Format returns a tab separated list of logged fields.
 func (stats *SQLQueryStats) Format(params url.Values) string {
 	_, fullBindParams := params["full"]
 
 	remoteAddr, username := stats.RemoteAddrUsername()
 	return fmt.Sprintf(
-		"%v\t%v\t%v\t%v\t%v\t%.6f\t%v\t%q\t%v\t%v\t%q\t%v\t%.6f\t%.6f\t%v\t%v\t%v\t%v\t%v\t%v\t%q\t\n",
+		"%v\t%v\t%v\t%v\t%v\t%.6f\t%v\t%q\t%v\t%v\t%q\t%v\t%.6f\t%.6f\t%v\t%v\t%v\t%v\t%v\t%v\t%q\t%v\t\n",
 		stats.Method,
 		remoteAddr,
 		username,
@@ -193,7 +219,7 @@ func (stats *SQLQueryStats) Format(params url.Values) string {
 		stats.EndTime.Format(time.StampMicro),
 		stats.TotalTime().Seconds(),
 		stats.PlanType,
-		stats.OriginalSql,
+		stats.FmtOriginalSql(),
 		stats.FmtBindVariables(fullBindParams),
 		stats.NumberOfQueries,
 		stats.RewrittenSql(),
@@ -207,5 +233,6 @@ func (stats *SQLQueryStats) Format(params url.Values) string {
 		stats.CacheAbsent,
 		stats.CacheInvalidations,
 		stats.ErrorStr(),
+		stats.TraceID(),
 	)
 }
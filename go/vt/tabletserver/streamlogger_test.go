@@ -0,0 +1,34 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestSQLQueryStatsSensitive(t *testing.T) {
+	stats := newSqlQueryStats("Execute", context.Background())
+	stats.OriginalSql = "select * from test_table where name = 'secret'"
+	stats.BindVariables = map[string]interface{}{"id": "secret"}
+	stats.AddRewrittenSql("select * from test_table where name = 'secret' and id = 'secret'", time.Now())
+	stats.EndTime = time.Now()
+
+	if got := stats.Format(url.Values{"full": nil}); !strings.Contains(got, "secret") {
+		t.Errorf("Format of a query = %q, want the query and its bind variables", got)
+	}
+
+	stats.Sensitive = true
+	if got := stats.Format(url.Values{"full": nil}); strings.Contains(got, "secret") {
+		t.Errorf("Format of a query on a sensitive table = %q, want no values", got)
+	}
+	if got := stats.FmtOriginalSql(); got != "[REDACTED]" {
+		t.Errorf("FmtOriginalSql of a query on a sensitive table = %q, want [REDACTED]", got)
+	}
+}
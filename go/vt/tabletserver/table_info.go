@@ -8,8 +8,9 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/sqltypes"
 	"github.com/youtube/vitess/go/sync2"
 	"github.com/youtube/vitess/go/vt/schema"
@@ -19,15 +20,63 @@ import (
 type TableInfo struct {
 	*schema.Table
 	Cache *RowCache
+	// Sensitive and ReadOnly are set by schema overrides
+	Sensitive bool
+	ReadOnly  bool
+	// Sequence is set if the table is a sequence table
+	Sequence *SequenceInfo
+	// IsMessage is set if the table is a message table
+	IsMessage bool
+	// TTL is set by schema overrides, if the rows of the
+	// table are purged by the row GC
+	TTL *TTLInfo
 	// stats updated by sqlquery.go
 	hits, absent, misses, invalidations sync2.AtomicInt64
 }
 
+// SequenceInfo contains the block of values that was reserved
+// from a sequence table and not handed out yet. NextVal is the
+// next value to hand out, LastVal is the end of the block.
+type SequenceInfo struct {
+	mu      sync.Mutex
+	NextVal int64
+	LastVal int64
+}
+
+// TTLInfo is the expiration of the rows of a table: the rows whose
+// Column is older than Duration are purged. IsNumber is set if Column
+// is a unix timestamp in seconds, rather than a date. PKColumns are
+// the names of the primary key columns, the purges delete the rows in
+// their order so they're safe for statement based replication.
+type TTLInfo struct {
+	Column    string
+	Duration  time.Duration
+	IsNumber  bool
+	PKColumns []string
+}
+
 func NewTableInfo(conn *DBConn, tableName string, tableType string, createTime sqltypes.Value, comment string, cachePool *CachePool) (ti *TableInfo, err error) {
 	ti, err = loadTableInfo(conn, tableName)
 	if err != nil {
 		return nil, err
 	}
+	if strings.Contains(comment, "vitess_sequence") {
+		// Sequence tables are only changed through nextval,
+		// they are never cached.
+		ti.Sequence = &SequenceInfo{}
+		return ti, nil
+	}
+	if strings.Contains(comment, "vitess_message") {
+		// Message tables are changed by the message manager
+		// outside of the rowcache, so they're never cached.
+		for _, col := range messageColumns {
+			if ti.FindColumn(col) == -1 {
+				return nil, fmt.Errorf("message table %s has no %s column", tableName, col)
+			}
+		}
+		ti.IsMessage = true
+		return ti, nil
+	}
 	ti.initRowCache(conn, tableType, createTime, comment, cachePool)
 	return ti, nil
 }
@@ -78,6 +127,40 @@ func (ti *TableInfo) SetPK(colnames []string) error {
 	return nil
 }
 
+// SetTTL makes the row GC purge the rows whose column is older
+// than ttl.
+func (ti *TableInfo) SetTTL(column string, ttl time.Duration) error {
+	index := ti.FindColumn(column)
+	if index == -1 {
+		return fmt.Errorf("column %s not found", column)
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("invalid ttl %v", ttl)
+	}
+	if ti.IsMessage || ti.Sequence != nil {
+		return fmt.Errorf("table %s is not a regular table", ti.Name)
+	}
+	// The purges bypass the rowcache, the purged rows would still be
+	// served from it.
+	if ti.CacheType != schema.CACHE_NONE {
+		return fmt.Errorf("table %s has a rowcache", ti.Name)
+	}
+	if len(ti.PKColumns) == 0 {
+		return fmt.Errorf("table %s has no primary key", ti.Name)
+	}
+	pkColumns := make([]string, len(ti.PKColumns))
+	for i, index := range ti.PKColumns {
+		pkColumns[i] = ti.Columns[index].Name
+	}
+	ti.TTL = &TTLInfo{
+		Column:    column,
+		Duration:  ttl,
+		IsNumber:  ti.Columns[index].Category == schema.CAT_NUMBER,
+		PKColumns: pkColumns,
+	}
+	return nil
+}
+
 func (ti *TableInfo) fetchIndexes(conn *DBConn) error {
 	indexes, err := conn.Exec(context.Background(), fmt.Sprintf("show index from `%s`", ti.Name), 10000, false)
 	if err != nil {
@@ -95,7 +178,7 @@ func (ti *TableInfo) fetchIndexes(conn *DBConn) error {
 		if !row[6].IsNull() {
 			cardinality, err = strconv.ParseUint(row[6].String(), 0, 64)
 			if err != nil {
-				log.Warningf("%s", err)
+				mlog.Warningf("%s", err)
 			}
 		}
 		currentIndex.AddColumn(row[4].String(), cardinality)
@@ -138,22 +221,22 @@ func (ti *TableInfo) initRowCache(conn *DBConn, tableType string, createTime sql
 	}
 
 	if strings.Contains(comment, "vtocc_nocache") {
-		log.Infof("%s commented as vtocc_nocache. Will not be cached.", ti.Name)
+		mlog.Infof("%s commented as vtocc_nocache. Will not be cached.", ti.Name)
 		return
 	}
 
 	if tableType == "VIEW" {
-		log.Infof("%s is a view. Will not be cached.", ti.Name)
+		mlog.Infof("%s is a view. Will not be cached.", ti.Name)
 		return
 	}
 
 	if ti.PKColumns == nil {
-		log.Infof("Table %s has no primary key. Will not be cached.", ti.Name)
+		mlog.Infof("Table %s has no primary key. Will not be cached.", ti.Name)
 		return
 	}
 	for _, col := range ti.PKColumns {
 		if ti.Columns[col].Category == schema.CAT_OTHER {
-			log.Infof("Table %s pk has unsupported column types. Will not be cached.", ti.Name)
+			mlog.Infof("Table %s pk has unsupported column types. Will not be cached.", ti.Name)
 			return
 		}
 	}
@@ -11,10 +11,11 @@ import (
 	"strings"
 	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/mysql"
-	"github.com/youtube/vitess/go/tb"
 	"github.com/youtube/vitess/go/vt/logutil"
+	"github.com/youtube/vitess/go/vt/servenv"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vterrors"
 )
 
 const (
@@ -32,6 +33,10 @@ const (
 
 	// ErrNotInTx is returned when we're not in a transaction but should be
 	ErrNotInTx
+
+	// ErrRowLimitExceeded is returned when a query returns, or a DML
+	// would affect, more rows than allowed
+	ErrRowLimitExceeded
 )
 
 const (
@@ -45,6 +50,9 @@ type TabletError struct {
 	ErrorType int
 	Message   string
 	SqlError  int
+	// MasterHint is the current master, for the errors of
+	// queries that need one sent to a tablet that isn't.
+	MasterHint *topo.EndPoint
 }
 
 // This is how go-mysql exports its error number
@@ -133,10 +141,39 @@ func (te *TabletError) Prefix() string {
 		prefix = "tx_pool_full: "
 	case ErrNotInTx:
 		prefix = "not_in_tx: "
+	case ErrRowLimitExceeded:
+		prefix = "row_limit_exceeded: "
 	}
 	return prefix
 }
 
+// VtErrorCode returns the canonical code of the error type. It is
+// part of the vterrors.VtError interface.
+func (te *TabletError) VtErrorCode() vterrors.Code {
+	if te.MasterHint != nil {
+		return vterrors.NotMaster
+	}
+	switch te.ErrorType {
+	case ErrRetry:
+		return vterrors.QueryNotServed
+	case ErrFatal:
+		return vterrors.InternalError
+	case ErrTxPoolFull:
+		return vterrors.ResourceExhausted
+	case ErrNotInTx:
+		return vterrors.NotInTx
+	case ErrRowLimitExceeded:
+		return vterrors.RowLimitExceeded
+	}
+	return vterrors.UnknownError
+}
+
+// ErrorCode is part of the rpcplus.CodedError interface, so the code
+// is sent to the clients.
+func (te *TabletError) ErrorCode() int64 {
+	return int64(te.VtErrorCode())
+}
+
 // RecordStats will record the error in the proper stat bucket
 func (te *TabletError) RecordStats() {
 	switch te.ErrorType {
@@ -148,6 +185,8 @@ func (te *TabletError) RecordStats() {
 		errorStats.Add("TxPoolFull", 1)
 	case ErrNotInTx:
 		errorStats.Add("NotInTx", 1)
+	case ErrRowLimitExceeded:
+		errorStats.Add("RowLimitExceeded", 1)
 	default:
 		switch te.SqlError {
 		case mysql.ErrDupEntry:
@@ -164,7 +203,7 @@ func handleError(err *error, logStats *SQLQueryStats) {
 	if x := recover(); x != nil {
 		terr, ok := x.(*TabletError)
 		if !ok {
-			log.Errorf("Uncaught panic:\n%v\n%s", x, tb.Stack(4))
+			servenv.RecordPanic("tabletserver", x)
 			*err = NewTabletError(ErrFail, "%v: uncaught panic", x)
 			internalErrors.Add("Panic", 1)
 			return
@@ -177,7 +216,7 @@ func handleError(err *error, logStats *SQLQueryStats) {
 		if terr.ErrorType == ErrTxPoolFull {
 			logTxPoolFull.Errorf("%v", terr)
 		} else {
-			log.Errorf("%v", terr)
+			mlog.Errorf("%v", terr)
 		}
 	}
 	if logStats != nil {
@@ -190,14 +229,14 @@ func logError() {
 	if x := recover(); x != nil {
 		terr, ok := x.(*TabletError)
 		if !ok {
-			log.Errorf("Uncaught panic:\n%v\n%s", x, tb.Stack(4))
+			servenv.RecordPanic("tabletserver", x)
 			internalErrors.Add("Panic", 1)
 			return
 		}
 		if terr.ErrorType == ErrTxPoolFull {
 			logTxPoolFull.Errorf("%v", terr)
 		} else {
-			log.Errorf("%v", terr)
+			mlog.Errorf("%v", terr)
 		}
 	}
 }
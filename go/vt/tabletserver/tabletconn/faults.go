@@ -0,0 +1,114 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletconn
+
+import (
+	"time"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/vt/faultinject"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vterrors"
+	"golang.org/x/net/context"
+)
+
+// injectFault returns the error to return for an RPC, if any. A
+// dropped RPC fails like a lost connection, an error looks like a
+// tablet that doesn't serve, so clients retry it elsewhere.
+func injectFault() error {
+	switch faultinject.Inject(faultinject.TabletConn) {
+	case faultinject.Drop:
+		return OperationalError("vttablet: injected connection drop")
+	case faultinject.Error:
+		return &ServerError{Code: ERR_RETRY, Err: "retry: injected error", ServerCode: vterrors.QueryNotServed}
+	}
+	return nil
+}
+
+// faultyDialer injects faults in the dial, and in the RPCs of the
+// connections it returns.
+func faultyDialer(dialer TabletDialer) TabletDialer {
+	return func(ctx context.Context, endPoint topo.EndPoint, keyspace, shard string, timeout time.Duration) (TabletConn, error) {
+		if err := injectFault(); err != nil {
+			return nil, err
+		}
+		conn, err := dialer(ctx, endPoint, keyspace, shard, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return faultyConn{conn}, nil
+	}
+}
+
+// faultyConn injects faults in the RPCs that run queries and
+// transactions. The other ones go straight to the TabletConn.
+type faultyConn struct {
+	TabletConn
+}
+
+// Execute is part of the TabletConn interface
+func (fc faultyConn) Execute(ctx context.Context, query string, bindVars map[string]interface{}, transactionID int64, options *tproto.ExecuteOptions) (*mproto.QueryResult, error) {
+	if err := injectFault(); err != nil {
+		return nil, err
+	}
+	return fc.TabletConn.Execute(ctx, query, bindVars, transactionID, options)
+}
+
+// ExecuteBatch is part of the TabletConn interface
+func (fc faultyConn) ExecuteBatch(ctx context.Context, queries []tproto.BoundQuery, transactionID int64) (*tproto.QueryResultList, error) {
+	if err := injectFault(); err != nil {
+		return nil, err
+	}
+	return fc.TabletConn.ExecuteBatch(ctx, queries, transactionID)
+}
+
+// StreamExecute is part of the TabletConn interface
+func (fc faultyConn) StreamExecute(ctx context.Context, query string, bindVars map[string]interface{}, transactionID int64, options *tproto.ExecuteOptions) (<-chan *mproto.QueryResult, ErrFunc, error) {
+	if err := injectFault(); err != nil {
+		return nil, nil, err
+	}
+	return fc.TabletConn.StreamExecute(ctx, query, bindVars, transactionID, options)
+}
+
+// ExecutePrepared is part of the TabletConn interface
+func (fc faultyConn) ExecutePrepared(ctx context.Context, statementID int64, bindVars map[string]interface{}, transactionID int64) (*mproto.QueryResult, error) {
+	if err := injectFault(); err != nil {
+		return nil, err
+	}
+	return fc.TabletConn.ExecutePrepared(ctx, statementID, bindVars, transactionID)
+}
+
+// Begin is part of the TabletConn interface
+func (fc faultyConn) Begin(ctx context.Context, options *tproto.TransactionOptions) (int64, error) {
+	if err := injectFault(); err != nil {
+		return 0, err
+	}
+	return fc.TabletConn.Begin(ctx, options)
+}
+
+// Commit is part of the TabletConn interface
+func (fc faultyConn) Commit(ctx context.Context, transactionID int64) error {
+	if err := injectFault(); err != nil {
+		return err
+	}
+	return fc.TabletConn.Commit(ctx, transactionID)
+}
+
+// Rollback is part of the TabletConn interface
+func (fc faultyConn) Rollback(ctx context.Context, transactionID int64) error {
+	if err := injectFault(); err != nil {
+		return err
+	}
+	return fc.TabletConn.Rollback(ctx, transactionID)
+}
+
+// SplitQuery is part of the TabletConn interface
+func (fc faultyConn) SplitQuery(ctx context.Context, query tproto.BoundQuery, splitCount int) ([]tproto.QuerySplit, error) {
+	if err := injectFault(); err != nil {
+		return nil, err
+	}
+	return fc.TabletConn.SplitQuery(ctx, query, splitCount)
+}
@@ -6,12 +6,15 @@ package tabletconn
 
 import (
 	"flag"
+	"fmt"
 	"time"
 
 	log "github.com/golang/glog"
 	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/vt/faultinject"
 	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
 	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vterrors"
 	"golang.org/x/net/context"
 )
 
@@ -36,10 +39,45 @@ var (
 type ServerError struct {
 	Code int
 	Err  string
+	// ServerCode is the canonical code of the error.
+	ServerCode vterrors.Code
+	// MasterHint is the current master of the shard, if the
+	// vttablet sent one with a NotMaster error.
+	MasterHint *topo.EndPoint
 }
 
 func (e *ServerError) Error() string { return e.Err }
 
+// VtErrorCode is part of the vterrors.VtError interface.
+func (e *ServerError) VtErrorCode() vterrors.Code { return e.ServerCode }
+
+// ErrCodeFromVtErrorCode returns the ERR_ code to use for an error
+// with the given canonical code.
+func ErrCodeFromVtErrorCode(code vterrors.Code) int {
+	switch code {
+	case vterrors.QueryNotServed, vterrors.NotMaster:
+		return ERR_RETRY
+	case vterrors.InternalError:
+		return ERR_FATAL
+	case vterrors.ResourceExhausted:
+		return ERR_TX_POOL_FULL
+	case vterrors.NotInTx:
+		return ERR_NOT_IN_TX
+	}
+	return ERR_NORMAL
+}
+
+// UnsupportedFeatureError returns the error of a call that needs a
+// feature the vttablet doesn't support, instead of sending it a
+// request it wouldn't understand.
+func UnsupportedFeatureError(feature string, protocolVersion int64) error {
+	return &ServerError{
+		Code:       ErrCodeFromVtErrorCode(vterrors.Unimplemented),
+		Err:        fmt.Sprintf("vttablet: %v is not supported by the vttablet (protocol version %v), it may run an older version", feature, protocolVersion),
+		ServerCode: vterrors.Unimplemented,
+	}
+}
+
 // OperationalError represents an error due to a failure to
 // communicate with vttablet.
 type OperationalError string
@@ -59,7 +97,8 @@ type TabletDialer func(context context.Context, endPoint topo.EndPoint, keyspace
 // not be concurrently used across goroutines.
 type TabletConn interface {
 	// Execute executes a non-streaming query on vttablet.
-	Execute(context context.Context, query string, bindVars map[string]interface{}, transactionId int64) (*mproto.QueryResult, error)
+	// The options may be nil.
+	Execute(context context.Context, query string, bindVars map[string]interface{}, transactionId int64, options *tproto.ExecuteOptions) (*mproto.QueryResult, error)
 
 	// ExecuteBatch executes a group of queries.
 	ExecuteBatch(context context.Context, queries []tproto.BoundQuery, transactionId int64) (*tproto.QueryResultList, error)
@@ -68,10 +107,27 @@ type TabletConn interface {
 	// If error is non-nil, it means that the StreamExecute failed to send the request. Otherwise,
 	// you can pull values from the channel till it's closed. Following this, you can call ErrFunc
 	// to see if the stream ended normally or due to a failure.
-	StreamExecute(context context.Context, query string, bindVars map[string]interface{}, transactionId int64) (<-chan *mproto.QueryResult, ErrFunc, error)
+	// The options may be nil.
+	StreamExecute(context context.Context, query string, bindVars map[string]interface{}, transactionId int64, options *tproto.ExecuteOptions) (<-chan *mproto.QueryResult, ErrFunc, error)
 
-	// Transaction support
-	Begin(context context.Context) (transactionId int64, err error)
+	// Prepare prepares a statement on vttablet, and returns its id.
+	// The statement can then be executed without being parsed and
+	// planned again. It is evicted when the schema changes, after
+	// which ExecutePrepared fails and it must be prepared again.
+	Prepare(context context.Context, query string) (statementId int64, err error)
+
+	// ExecutePrepared executes a prepared statement.
+	ExecutePrepared(context context.Context, statementId int64, bindVars map[string]interface{}, transactionId int64) (*mproto.QueryResult, error)
+
+	// ClosePrepared releases a prepared statement.
+	ClosePrepared(context context.Context, statementId int64) error
+
+	// Explain returns the plan of a query on vttablet, without
+	// executing it.
+	Explain(context context.Context, query string, bindVars map[string]interface{}) (*tproto.ExplainResult, error)
+
+	// Transaction support. The options of Begin may be nil.
+	Begin(context context.Context, options *tproto.TransactionOptions) (transactionId int64, err error)
 	Commit(context context.Context, transactionId int64) error
 	Rollback(context context.Context, transactionId int64) error
 
@@ -84,6 +140,22 @@ type TabletConn interface {
 	// SplitQuery splits a query into equally sized smaller queries by
 	// appending primary key range clauses to the original query
 	SplitQuery(context context.Context, query tproto.BoundQuery, splitCount int) ([]tproto.QuerySplit, error)
+
+	// MessageStream streams the messages of a message table. The
+	// first result has the Fields, the subsequent ones have the
+	// Rows of the messages. It works like StreamExecute, but the
+	// stream only ends on error or when the context is done.
+	MessageStream(context context.Context, name string) (<-chan *mproto.QueryResult, ErrFunc, error)
+
+	// MessageAck acks the messages of a message table by id, and
+	// returns the number of messages that were acked.
+	MessageAck(context context.Context, name string, ids []interface{}) (int64, error)
+
+	// ExportTable streams the rows of a table, or of its rows in
+	// a keyrange, for bulk exports. The first chunk has the
+	// header of the export, the subsequent ones the rows. The
+	// SessionId of the request is set by the connection.
+	ExportTable(context context.Context, req *tproto.ExportTableRequest) (<-chan *tproto.ExportChunk, ErrFunc, error)
 }
 
 type ErrFunc func() error
@@ -99,11 +171,16 @@ func RegisterDialer(name string, dialer TabletDialer) {
 	dialers[name] = dialer
 }
 
-// GetDialer returns the dialer to use, described by the command line flag
+// GetDialer returns the dialer to use, described by the command line flag.
+// When faults are injected in the tablet RPCs, the dialer and its
+// connections fail or are delayed at random.
 func GetDialer() TabletDialer {
 	td, ok := dialers[*tabletProtocol]
 	if !ok {
 		log.Fatalf("No dialer registered for tablet protocol %s", *tabletProtocol)
 	}
+	if faultinject.Enabled(faultinject.TabletConn) {
+		return faultyDialer(td)
+	}
 	return td
 }
@@ -12,9 +12,12 @@ import (
 
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/key"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
 	"github.com/youtube/vitess/go/vt/tabletserver/proto"
 	"github.com/youtube/vitess/go/vt/tabletserver/queryservice"
 	"github.com/youtube/vitess/go/vt/tabletserver/tabletconn"
+	"github.com/youtube/vitess/go/vt/vterrors"
 	"golang.org/x/net/context"
 )
 
@@ -39,7 +42,12 @@ func (f *fakeQueryService) GetSessionId(sessionParams *proto.SessionParams, sess
 	if sessionParams.Shard != TestShard {
 		f.t.Errorf("invalid shard: got %v expected %v", sessionParams.Shard, TestShard)
 	}
+	if sessionParams.ProtocolVersion != proto.ProtocolVersion {
+		f.t.Errorf("invalid protocol version: got %v expected %v", sessionParams.ProtocolVersion, proto.ProtocolVersion)
+	}
 	sessionInfo.SessionId = testSessionId
+	sessionInfo.ProtocolVersion = proto.ProtocolVersion
+	sessionInfo.Features = proto.SupportedFeatures()
 	return nil
 }
 
@@ -51,16 +59,24 @@ func (f *fakeQueryService) Begin(ctx context.Context, session *proto.Session, tx
 	if session.TransactionId != 0 {
 		f.t.Errorf("Begin: invalid TransactionId: got %v expected 0", session.TransactionId)
 	}
+	if !reflect.DeepEqual(session.TransactionOptions, beginTransactionOptions) {
+		f.t.Errorf("Begin: invalid TransactionOptions: got %v expected %v", session.TransactionOptions, beginTransactionOptions)
+	}
 	txInfo.TransactionId = beginTransactionId
 	return nil
 }
 
 const beginTransactionId int64 = 9990
 
+var beginTransactionOptions = &proto.TransactionOptions{
+	IsolationLevel: proto.IsolationReadCommitted,
+	ReadOnly:       true,
+}
+
 func testBegin(t *testing.T, conn tabletconn.TabletConn) {
 	t.Log("testBegin")
 	ctx := context.Background()
-	transactionId, err := conn.Begin(ctx)
+	transactionId, err := conn.Begin(ctx, beginTransactionOptions)
 	if err != nil {
 		t.Fatalf("Begin failed: %v", err)
 	}
@@ -127,6 +143,13 @@ func (f *fakeQueryService) Execute(ctx context.Context, query *proto.Query, repl
 	if query.TransactionId != executeTransactionId {
 		f.t.Errorf("invalid Execute.Query.TransactionId: got %v expected %v", query.TransactionId, executeTransactionId)
 	}
+	if query.IdempotencyKey != "" && query.IdempotencyKey != executeIdempotencyKey {
+		f.t.Errorf("invalid Execute.Query.IdempotencyKey: got %v expected %v", query.IdempotencyKey, executeIdempotencyKey)
+	}
+	if query.FieldsOnly {
+		*reply = mproto.QueryResult{Fields: executeQueryResult.Fields}
+		return nil
+	}
 	*reply = executeQueryResult
 	return nil
 }
@@ -167,7 +190,7 @@ var executeQueryResult = mproto.QueryResult{
 func testExecute(t *testing.T, conn tabletconn.TabletConn) {
 	t.Log("testExecute")
 	ctx := context.Background()
-	qr, err := conn.Execute(ctx, executeQuery, executeBindVars, executeTransactionId)
+	qr, err := conn.Execute(ctx, executeQuery, executeBindVars, executeTransactionId, nil)
 	if err != nil {
 		t.Fatalf("Execute failed: %v", err)
 	}
@@ -176,6 +199,152 @@ func testExecute(t *testing.T, conn tabletconn.TabletConn) {
 	}
 }
 
+var fieldsOnlyOptions = &proto.ExecuteOptions{FieldsOnly: true}
+
+const executeIdempotencyKey = "executeIdempotencyKey"
+
+var idempotencyKeyOptions = &proto.ExecuteOptions{IdempotencyKey: executeIdempotencyKey}
+
+func testExecuteFieldsOnly(t *testing.T, conn tabletconn.TabletConn) {
+	t.Log("testExecuteFieldsOnly")
+	ctx := context.Background()
+	qr, err := conn.Execute(ctx, executeQuery, executeBindVars, executeTransactionId, fieldsOnlyOptions)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !reflect.DeepEqual(qr.Fields, executeQueryResult.Fields) || len(qr.Rows) != 0 {
+		t.Errorf("Unexpected result from fields only Execute: got %v wanted the fields of %v", qr, executeQueryResult)
+	}
+}
+
+func testExecuteIdempotencyKey(t *testing.T, conn tabletconn.TabletConn) {
+	t.Log("testExecuteIdempotencyKey")
+	ctx := context.Background()
+	qr, err := conn.Execute(ctx, executeQuery, executeBindVars, executeTransactionId, idempotencyKeyOptions)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !reflect.DeepEqual(*qr, executeQueryResult) {
+		t.Errorf("Unexpected result from Execute: got %v wanted %v", qr, executeQueryResult)
+	}
+}
+
+// Prepare is part of the queryservice.QueryService interface
+func (f *fakeQueryService) Prepare(ctx context.Context, req *proto.PrepareRequest, reply *proto.PrepareResult) error {
+	if req.Sql != prepareQuery {
+		f.t.Errorf("invalid Prepare.Sql: got %v expected %v", req.Sql, prepareQuery)
+	}
+	if req.SessionId != testSessionId {
+		f.t.Errorf("invalid Prepare.SessionId: got %v expected %v", req.SessionId, testSessionId)
+	}
+	reply.StatementId = prepareStatementId
+	return nil
+}
+
+const prepareQuery = "prepareQuery"
+
+const prepareStatementId int64 = 4321
+
+func testPrepare(t *testing.T, conn tabletconn.TabletConn) {
+	t.Log("testPrepare")
+	ctx := context.Background()
+	statementID, err := conn.Prepare(ctx, prepareQuery)
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if statementID != prepareStatementId {
+		t.Errorf("Unexpected result from Prepare: got %v wanted %v", statementID, prepareStatementId)
+	}
+}
+
+// ExecutePrepared is part of the queryservice.QueryService interface
+func (f *fakeQueryService) ExecutePrepared(ctx context.Context, req *proto.ExecutePreparedRequest, reply *mproto.QueryResult) error {
+	if req.StatementId != prepareStatementId {
+		f.t.Errorf("invalid ExecutePrepared.StatementId: got %v expected %v", req.StatementId, prepareStatementId)
+	}
+	if !reflect.DeepEqual(req.BindVariables, executeBindVars) {
+		f.t.Errorf("invalid ExecutePrepared.BindVariables: got %v expected %v", req.BindVariables, executeBindVars)
+	}
+	if req.SessionId != testSessionId {
+		f.t.Errorf("invalid ExecutePrepared.SessionId: got %v expected %v", req.SessionId, testSessionId)
+	}
+	if req.TransactionId != executeTransactionId {
+		f.t.Errorf("invalid ExecutePrepared.TransactionId: got %v expected %v", req.TransactionId, executeTransactionId)
+	}
+	*reply = executeQueryResult
+	return nil
+}
+
+func testExecutePrepared(t *testing.T, conn tabletconn.TabletConn) {
+	t.Log("testExecutePrepared")
+	ctx := context.Background()
+	qr, err := conn.ExecutePrepared(ctx, prepareStatementId, executeBindVars, executeTransactionId)
+	if err != nil {
+		t.Fatalf("ExecutePrepared failed: %v", err)
+	}
+	if !reflect.DeepEqual(*qr, executeQueryResult) {
+		t.Errorf("Unexpected result from ExecutePrepared: got %v wanted %v", qr, executeQueryResult)
+	}
+}
+
+// ClosePrepared is part of the queryservice.QueryService interface
+func (f *fakeQueryService) ClosePrepared(ctx context.Context, req *proto.ClosePreparedRequest) error {
+	if req.StatementId != prepareStatementId {
+		f.t.Errorf("invalid ClosePrepared.StatementId: got %v expected %v", req.StatementId, prepareStatementId)
+	}
+	if req.SessionId != testSessionId {
+		f.t.Errorf("invalid ClosePrepared.SessionId: got %v expected %v", req.SessionId, testSessionId)
+	}
+	return nil
+}
+
+func testClosePrepared(t *testing.T, conn tabletconn.TabletConn) {
+	t.Log("testClosePrepared")
+	ctx := context.Background()
+	if err := conn.ClosePrepared(ctx, prepareStatementId); err != nil {
+		t.Fatalf("ClosePrepared failed: %v", err)
+	}
+}
+
+// Explain is part of the queryservice.QueryService interface
+func (f *fakeQueryService) Explain(ctx context.Context, query *proto.Query, reply *proto.ExplainResult) error {
+	if query.Sql != explainQuery {
+		f.t.Errorf("invalid Explain.Query.Sql: got %v expected %v", query.Sql, explainQuery)
+	}
+	if !reflect.DeepEqual(query.BindVariables, executeBindVars) {
+		f.t.Errorf("invalid Explain.Query.BindVariables: got %v expected %v", query.BindVariables, executeBindVars)
+	}
+	if query.SessionId != testSessionId {
+		f.t.Errorf("invalid Explain.Query.SessionId: got %v expected %v", query.SessionId, testSessionId)
+	}
+	*reply = explainResult
+	return nil
+}
+
+const explainQuery = "explainQuery"
+
+var explainResult = proto.ExplainResult{
+	PlanType:   "PK_IN",
+	Reason:     "DEFAULT",
+	TableName:  "t",
+	PKValues:   []string{"1", ":bind1"},
+	FieldQuery: "select * from t where 1 != 1",
+	FullQuery:  "select * from t where id in (1, :bind1) limit :#maxLimit",
+	OuterQuery: "select * from t where :#pk",
+}
+
+func testExplain(t *testing.T, conn tabletconn.TabletConn) {
+	t.Log("testExplain")
+	ctx := context.Background()
+	result, err := conn.Explain(ctx, explainQuery, executeBindVars)
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if !reflect.DeepEqual(*result, explainResult) {
+		t.Errorf("Unexpected result from Explain: got %+v wanted %+v", result, explainResult)
+	}
+}
+
 // StreamExecute is part of the queryservice.QueryService interface
 func (f *fakeQueryService) StreamExecute(ctx context.Context, query *proto.Query, sendReply func(*mproto.QueryResult) error) error {
 	if query.Sql != streamExecuteQuery {
@@ -190,6 +359,9 @@ func (f *fakeQueryService) StreamExecute(ctx context.Context, query *proto.Query
 	if query.TransactionId != streamExecuteTransactionId {
 		f.t.Errorf("invalid StreamExecute.Query.TransactionId: got %v expected %v", query.TransactionId, streamExecuteTransactionId)
 	}
+	if query.FieldsOnly {
+		return sendReply(&streamExecuteQueryResult1)
+	}
 	if err := sendReply(&streamExecuteQueryResult1); err != nil {
 		f.t.Errorf("sendReply1 failed: %v", err)
 	}
@@ -236,7 +408,7 @@ var streamExecuteQueryResult2 = mproto.QueryResult{
 func testStreamExecute(t *testing.T, conn tabletconn.TabletConn) {
 	t.Log("testStreamExecute")
 	ctx := context.Background()
-	stream, errFunc, err := conn.StreamExecute(ctx, streamExecuteQuery, streamExecuteBindVars, streamExecuteTransactionId)
+	stream, errFunc, err := conn.StreamExecute(ctx, streamExecuteQuery, streamExecuteBindVars, streamExecuteTransactionId, nil)
 	if err != nil {
 		t.Fatalf("StreamExecute failed: %v", err)
 	}
@@ -269,6 +441,28 @@ func testStreamExecute(t *testing.T, conn tabletconn.TabletConn) {
 	}
 }
 
+func testStreamExecuteFieldsOnly(t *testing.T, conn tabletconn.TabletConn) {
+	t.Log("testStreamExecuteFieldsOnly")
+	ctx := context.Background()
+	stream, errFunc, err := conn.StreamExecute(ctx, streamExecuteQuery, streamExecuteBindVars, streamExecuteTransactionId, fieldsOnlyOptions)
+	if err != nil {
+		t.Fatalf("StreamExecute failed: %v", err)
+	}
+	qr, ok := <-stream
+	if !ok {
+		t.Fatalf("StreamExecute failed: cannot read the fields")
+	}
+	if !reflect.DeepEqual(qr.Fields, streamExecuteQueryResult1.Fields) || len(qr.Rows) != 0 {
+		t.Errorf("Unexpected fields from StreamExecute: got %v wanted %v", qr, streamExecuteQueryResult1)
+	}
+	if qr, ok = <-stream; ok {
+		t.Fatalf("fields only StreamExecute returned rows: %v", qr)
+	}
+	if err := errFunc(); err != nil {
+		t.Fatalf("StreamExecute errFunc failed: %v", err)
+	}
+}
+
 // ExecuteBatch is part of the queryservice.QueryService interface
 func (f *fakeQueryService) ExecuteBatch(ctx context.Context, queryList *proto.QueryList, reply *proto.QueryResultList) error {
 	if !reflect.DeepEqual(queryList.Queries, executeBatchQueries) {
@@ -398,18 +592,280 @@ func testSplitQuery(t *testing.T, conn tabletconn.TabletConn) {
 	}
 }
 
+// MessageStream is part of the queryservice.QueryService interface
+func (f *fakeQueryService) MessageStream(ctx context.Context, req *proto.MessageStreamRequest, sendReply func(*mproto.QueryResult) error) error {
+	if req.Name != messageName {
+		f.t.Errorf("invalid MessageStream.Name: got %v expected %v", req.Name, messageName)
+	}
+	if req.SessionId != testSessionId {
+		f.t.Errorf("invalid MessageStream.SessionId: got %v expected %v", req.SessionId, testSessionId)
+	}
+	if err := sendReply(&messageStreamResult1); err != nil {
+		f.t.Errorf("sendReply1 failed: %v", err)
+	}
+	if err := sendReply(&messageStreamResult2); err != nil {
+		f.t.Errorf("sendReply2 failed: %v", err)
+	}
+	return nil
+}
+
+const messageName = "messageName"
+
+var messageStreamResult1 = mproto.QueryResult{
+	Fields: []mproto.Field{
+		mproto.Field{
+			Name: "id",
+			Type: 8,
+		},
+		mproto.Field{
+			Name: "epoch",
+			Type: 8,
+		},
+		mproto.Field{
+			Name: "message",
+			Type: 253,
+		},
+	},
+}
+
+var messageStreamResult2 = mproto.QueryResult{
+	Rows: [][]sqltypes.Value{
+		[]sqltypes.Value{
+			sqltypes.MakeString([]byte("1")),
+			sqltypes.MakeString([]byte("0")),
+			sqltypes.MakeString([]byte("message1")),
+		},
+	},
+}
+
+func testMessageStream(t *testing.T, conn tabletconn.TabletConn) {
+	t.Log("testMessageStream")
+	ctx := context.Background()
+	stream, errFunc, err := conn.MessageStream(ctx, messageName)
+	if err != nil {
+		t.Fatalf("MessageStream failed: %v", err)
+	}
+	qr, ok := <-stream
+	if !ok {
+		t.Fatalf("MessageStream failed: cannot read result1")
+	}
+	if len(qr.Rows) == 0 {
+		qr.Rows = nil
+	}
+	if !reflect.DeepEqual(*qr, messageStreamResult1) {
+		t.Errorf("Unexpected result1 from MessageStream: got %v wanted %v", qr, messageStreamResult1)
+	}
+	qr, ok = <-stream
+	if !ok {
+		t.Fatalf("MessageStream failed: cannot read result2")
+	}
+	if len(qr.Fields) == 0 {
+		qr.Fields = nil
+	}
+	if !reflect.DeepEqual(*qr, messageStreamResult2) {
+		t.Errorf("Unexpected result2 from MessageStream: got %v wanted %v", qr, messageStreamResult2)
+	}
+	qr, ok = <-stream
+	if ok {
+		t.Fatalf("MessageStream channel wasn't closed")
+	}
+	if err := errFunc(); err != nil {
+		t.Fatalf("MessageStream errFunc failed: %v", err)
+	}
+}
+
+// MessageAck is part of the queryservice.QueryService interface
+func (f *fakeQueryService) MessageAck(ctx context.Context, req *proto.MessageAckRequest, reply *proto.MessageAckResult) error {
+	if req.Name != messageName {
+		f.t.Errorf("invalid MessageAck.Name: got %v expected %v", req.Name, messageName)
+	}
+	if !reflect.DeepEqual(req.Ids, messageAckIds) {
+		f.t.Errorf("invalid MessageAck.Ids: got %v expected %v", req.Ids, messageAckIds)
+	}
+	if req.SessionId != testSessionId {
+		f.t.Errorf("invalid MessageAck.SessionId: got %v expected %v", req.SessionId, testSessionId)
+	}
+	reply.Count = messageAckCount
+	return nil
+}
+
+var messageAckIds = []interface{}{int64(1), int64(2)}
+
+const messageAckCount int64 = 2
+
+func testMessageAck(t *testing.T, conn tabletconn.TabletConn) {
+	t.Log("testMessageAck")
+	ctx := context.Background()
+	count, err := conn.MessageAck(ctx, messageName, messageAckIds)
+	if err != nil {
+		t.Fatalf("MessageAck failed: %v", err)
+	}
+	if count != messageAckCount {
+		t.Errorf("Unexpected result from MessageAck: got %v wanted %v", count, messageAckCount)
+	}
+}
+
+// ExportTable is part of the queryservice.QueryService interface
+func (f *fakeQueryService) ExportTable(ctx context.Context, req *proto.ExportTableRequest, sendReply func(*proto.ExportChunk) error) error {
+	want := exportTableRequest
+	want.SessionId = testSessionId
+	if !reflect.DeepEqual(*req, want) {
+		f.t.Errorf("invalid ExportTable request: got %+v expected %+v", *req, want)
+	}
+	if err := sendReply(&exportTableChunk1); err != nil {
+		f.t.Errorf("sendReply1 failed: %v", err)
+	}
+	if err := sendReply(&exportTableChunk2); err != nil {
+		f.t.Errorf("sendReply2 failed: %v", err)
+	}
+	return nil
+}
+
+var exportTableRequest = proto.ExportTableRequest{
+	Table:            "exportTable",
+	KeyspaceIdColumn: "keyspace_id",
+	KeyspaceIdType:   key.KIT_UINT64,
+	KeyRange: key.KeyRange{
+		Start: key.KeyspaceId("\x40"),
+		End:   key.KeyspaceId("\x80"),
+	},
+}
+
+var exportTableChunk1 = proto.ExportChunk{
+	Header: &proto.ExportHeader{
+		Fields: []mproto.Field{
+			mproto.Field{
+				Name: "id",
+				Type: 8,
+			},
+			mproto.Field{
+				Name: "name",
+				Type: 253,
+			},
+		},
+		PrimaryKeyColumns: []int{0},
+		Position:          myproto.MustParseReplicationPosition("MariaDB", "0-41983-1"),
+	},
+}
+
+var exportTableChunk2 = proto.ExportChunk{
+	RowCount: 2,
+	Columns:  [][]byte{[]byte("\x021\x022"), []byte("\x04abc\x00")},
+}
+
+func testExportTable(t *testing.T, conn tabletconn.TabletConn) {
+	t.Log("testExportTable")
+	ctx := context.Background()
+	req := exportTableRequest
+	stream, errFunc, err := conn.ExportTable(ctx, &req)
+	if err != nil {
+		t.Fatalf("ExportTable failed: %v", err)
+	}
+	chunk, ok := <-stream
+	if !ok {
+		t.Fatalf("ExportTable failed: cannot read chunk1")
+	}
+	if len(chunk.Columns) == 0 {
+		chunk.Columns = nil
+	}
+	if !reflect.DeepEqual(*chunk, exportTableChunk1) {
+		t.Errorf("Unexpected chunk1 from ExportTable: got %+v wanted %+v", chunk, exportTableChunk1)
+	}
+	chunk, ok = <-stream
+	if !ok {
+		t.Fatalf("ExportTable failed: cannot read chunk2")
+	}
+	if !reflect.DeepEqual(*chunk, exportTableChunk2) {
+		t.Errorf("Unexpected chunk2 from ExportTable: got %+v wanted %+v", chunk, exportTableChunk2)
+	}
+	chunk, ok = <-stream
+	if ok {
+		t.Fatalf("ExportTable channel wasn't closed")
+	}
+	if err := errFunc(); err != nil {
+		t.Fatalf("ExportTable errFunc failed: %v", err)
+	}
+}
+
 // CreateFakeServer returns the fake server for the tests
 func CreateFakeServer(t *testing.T) queryservice.QueryService {
 	return &fakeQueryService{t}
 }
 
+// oldFakeQueryService is a fakeQueryService that predates the
+// protocol version negotiation.
+type oldFakeQueryService struct {
+	fakeQueryService
+}
+
+// GetSessionId is part of the queryservice.QueryService interface
+func (f *oldFakeQueryService) GetSessionId(sessionParams *proto.SessionParams, sessionInfo *proto.SessionInfo) error {
+	if err := f.fakeQueryService.GetSessionId(sessionParams, sessionInfo); err != nil {
+		return err
+	}
+	sessionInfo.ProtocolVersion = 0
+	sessionInfo.Features = nil
+	return nil
+}
+
+// CreateOldFakeServer returns a fake server that doesn't send its
+// protocol version and features, like the vttablets that predate the
+// negotiation.
+func CreateOldFakeServer(t *testing.T) queryservice.QueryService {
+	return &oldFakeQueryService{fakeQueryService{t}}
+}
+
 // TestSuite runs all the tests
 func TestSuite(t *testing.T, conn tabletconn.TabletConn) {
 	testBegin(t, conn)
 	testCommit(t, conn)
 	testRollback(t, conn)
 	testExecute(t, conn)
+	testExecuteFieldsOnly(t, conn)
+	testExecuteIdempotencyKey(t, conn)
+	testPrepare(t, conn)
+	testExecutePrepared(t, conn)
+	testClosePrepared(t, conn)
+	testExplain(t, conn)
 	testStreamExecute(t, conn)
+	testStreamExecuteFieldsOnly(t, conn)
 	testExecuteBatch(t, conn)
 	testSplitQuery(t, conn)
+	testMessageStream(t, conn)
+	testMessageAck(t, conn)
+	testExportTable(t, conn)
+}
+
+// TestOldServerSuite makes sure a client connected to the server of
+// CreateOldFakeServer still runs the queries, but refuses the calls
+// that need a feature.
+func TestOldServerSuite(t *testing.T, conn tabletconn.TabletConn) {
+	testExecute(t, conn)
+	testStreamExecute(t, conn)
+	testExecuteBatch(t, conn)
+
+	ctx := context.Background()
+	checkUnimplemented := func(name string, err error) {
+		if vterrors.RecoverVtErrorCode(err) != vterrors.Unimplemented {
+			t.Errorf("%v on an old server returned %v, want an Unimplemented error", name, err)
+		}
+	}
+	_, err := conn.Execute(ctx, executeQuery, executeBindVars, executeTransactionId, fieldsOnlyOptions)
+	checkUnimplemented("fields only Execute", err)
+	_, _, err = conn.StreamExecute(ctx, streamExecuteQuery, streamExecuteBindVars, streamExecuteTransactionId, fieldsOnlyOptions)
+	checkUnimplemented("fields only StreamExecute", err)
+	_, err = conn.Execute(ctx, executeQuery, executeBindVars, executeTransactionId, idempotencyKeyOptions)
+	checkUnimplemented("Execute with an idempotency key", err)
+	_, err = conn.Begin(ctx, beginTransactionOptions)
+	checkUnimplemented("Begin", err)
+	_, err = conn.Prepare(ctx, prepareQuery)
+	checkUnimplemented("Prepare", err)
+	_, err = conn.Explain(ctx, explainQuery, executeBindVars)
+	checkUnimplemented("Explain", err)
+	_, _, err = conn.MessageStream(ctx, messageName)
+	checkUnimplemented("MessageStream", err)
+	_, err = conn.MessageAck(ctx, messageName, messageAckIds)
+	checkUnimplemented("MessageAck", err)
+	_, _, err = conn.ExportTable(ctx, &exportTableRequest)
+	checkUnimplemented("ExportTable", err)
 }
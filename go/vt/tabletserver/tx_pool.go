@@ -11,7 +11,6 @@ import (
 	"sync"
 	"time"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/pools"
 	"github.com/youtube/vitess/go/sqldb"
@@ -19,6 +18,7 @@ import (
 	"github.com/youtube/vitess/go/streamlog"
 	"github.com/youtube/vitess/go/sync2"
 	"github.com/youtube/vitess/go/timer"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
 	"golang.org/x/net/context"
 )
 
@@ -85,7 +85,7 @@ func NewTxPool(
 // Open makes the TxPool operational. This also starts the transaction killer
 // that will kill long-running transactions.
 func (axp *TxPool) Open(appParams, dbaParams *sqldb.ConnParams) {
-	log.Infof("Starting transaction id: %d", axp.lastID)
+	mlog.Infof("Starting transaction id: %d", axp.lastID)
 	axp.pool.Open(appParams, dbaParams)
 	axp.ticks.Start(func() { axp.transactionKiller() })
 }
@@ -95,7 +95,7 @@ func (axp *TxPool) Close() {
 	axp.ticks.Stop()
 	for _, v := range axp.activePool.GetOutdated(time.Duration(0), "for closing") {
 		conn := v.(*TxConnection)
-		log.Warningf("killing transaction for shutdown: %s", conn.Format(nil))
+		mlog.Warningf("killing transaction for shutdown: %s", conn.Format(nil))
 		internalErrors.Add("StrayTransactions", 1)
 		conn.Close()
 		conn.discard(TxClose)
@@ -112,7 +112,7 @@ func (axp *TxPool) transactionKiller() {
 	defer logError()
 	for _, v := range axp.activePool.GetOutdated(time.Duration(axp.Timeout()), "for rollback") {
 		conn := v.(*TxConnection)
-		log.Warningf("killing transaction (exceeded timeout: %v): %s", axp.Timeout(), conn.Format(nil))
+		mlog.Warningf("killing transaction (exceeded timeout: %v): %s", axp.Timeout(), conn.Format(nil))
 		killStats.Add("Transactions", 1)
 		conn.Close()
 		conn.discard(TxKill)
@@ -122,6 +122,17 @@ func (axp *TxPool) transactionKiller() {
 // Begin begins a transaction, and returns the associated transaction id.
 // Subsequent statements can access the connection through the transaction id.
 func (axp *TxPool) Begin(ctx context.Context) int64 {
+	return axp.BeginWithOptions(ctx, nil)
+}
+
+// BeginWithOptions begins a transaction with the given options, which
+// may be nil. The options only apply to this transaction: the
+// connection goes back to the pool with the server defaults.
+func (axp *TxPool) BeginWithOptions(ctx context.Context, options *tproto.TransactionOptions) int64 {
+	setTransaction, err := setTransactionQuery(options)
+	if err != nil {
+		panic(NewTabletError(ErrFail, "%v", err))
+	}
 	conn, err := axp.pool.Get(ctx)
 	if err != nil {
 		switch err {
@@ -133,7 +144,18 @@ func (axp *TxPool) Begin(ctx context.Context) int64 {
 		}
 		panic(NewTabletErrorSql(ErrFatal, err))
 	}
+	if setTransaction != "" {
+		if _, err := conn.Exec(ctx, setTransaction, 1, false); err != nil {
+			conn.Recycle()
+			panic(NewTabletErrorSql(ErrFail, err))
+		}
+	}
 	if _, err := conn.Exec(ctx, "begin", 1, false); err != nil {
+		if setTransaction != "" {
+			// The options would apply to the next user of the
+			// connection.
+			conn.Close()
+		}
 		conn.Recycle()
 		panic(NewTabletErrorSql(ErrFail, err))
 	}
@@ -142,6 +164,30 @@ func (axp *TxPool) Begin(ctx context.Context) int64 {
 	return transactionID
 }
 
+// setTransactionQuery returns the statement that sets the options of
+// the next transaction of a connection, or "" if there are none. As
+// it has no SESSION scope, the transactions after it are not changed.
+func setTransactionQuery(options *tproto.TransactionOptions) (string, error) {
+	if options == nil {
+		return "", nil
+	}
+	var characteristics []string
+	switch options.IsolationLevel {
+	case tproto.IsolationDefault:
+	case tproto.IsolationReadCommitted, tproto.IsolationRepeatableRead:
+		characteristics = append(characteristics, "isolation level "+strings.ToLower(options.IsolationLevel))
+	default:
+		return "", fmt.Errorf("unsupported isolation level %q", options.IsolationLevel)
+	}
+	if options.ReadOnly {
+		characteristics = append(characteristics, "read only")
+	}
+	if len(characteristics) == 0 {
+		return "", nil
+	}
+	return "set transaction " + strings.Join(characteristics, ", "), nil
+}
+
 // SafeCommit commits the specified transaction. Unlike other functions, it
 // returns an error on failure instead of panic. The connection becomes free
 // and can be reused in the future.
@@ -233,6 +279,9 @@ type TxConnection struct {
 	Queries       []string
 	Conclusion    string
 	LogToFile     sync2.AtomicInt32
+	// UnsafeUpdates is true if the safe updates mode was disabled
+	// for this transaction with 'set vt_safe_updates = 0'.
+	UnsafeUpdates bool
 }
 
 func newTxConnection(conn *DBConn, transactionID int64, pool *TxPool) *TxConnection {
@@ -293,7 +342,7 @@ func (txc *TxConnection) discard(conclusion string) {
 	// Ensure PoolConnection won't be accessed after Recycle.
 	txc.DBConn = nil
 	if txc.LogToFile.Get() != 0 {
-		log.Infof("Logged transaction: %s", txc.Format(nil))
+		mlog.Infof("Logged transaction: %s", txc.Format(nil))
 	}
 	TxLogger.Send(txc)
 }
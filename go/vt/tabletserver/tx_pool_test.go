@@ -13,6 +13,7 @@ import (
 	"github.com/youtube/vitess/go/sqldb"
 	"github.com/youtube/vitess/go/stats"
 	"github.com/youtube/vitess/go/vt/tabletserver/fakesqldb"
+	"github.com/youtube/vitess/go/vt/tabletserver/proto"
 	"golang.org/x/net/context"
 )
 
@@ -141,6 +142,50 @@ func TestBeginWithPoolTimeout(t *testing.T) {
 	txPool.Begin(ctx)
 }
 
+func TestSetTransactionQuery(t *testing.T) {
+	table := []struct {
+		options *proto.TransactionOptions
+		query   string
+	}{
+		{nil, ""},
+		{&proto.TransactionOptions{}, ""},
+		{&proto.TransactionOptions{IsolationLevel: proto.IsolationReadCommitted}, "set transaction isolation level read committed"},
+		{&proto.TransactionOptions{ReadOnly: true}, "set transaction read only"},
+		{&proto.TransactionOptions{IsolationLevel: proto.IsolationRepeatableRead, ReadOnly: true}, "set transaction isolation level repeatable read, read only"},
+	}
+	for _, test := range table {
+		query, err := setTransactionQuery(test.options)
+		if err != nil || query != test.query {
+			t.Errorf("setTransactionQuery(%+v) = (%q, %v), want %q", test.options, query, err, test.query)
+		}
+	}
+	if _, err := setTransactionQuery(&proto.TransactionOptions{IsolationLevel: "SERIALIZABLE"}); err == nil {
+		t.Errorf("setTransactionQuery(SERIALIZABLE) worked")
+	}
+}
+
+func TestBeginWithOptions(t *testing.T) {
+	db := fakesqldb.Register()
+	txPool := newTxPool()
+	appParams := sqldb.ConnParams{}
+	dbaParams := sqldb.ConnParams{}
+	txPool.Open(&appParams, &dbaParams)
+	defer txPool.Close()
+	ctx := context.Background()
+	transactionID := txPool.BeginWithOptions(ctx, &proto.TransactionOptions{ReadOnly: true})
+	txPool.Rollback(ctx, transactionID)
+
+	// the options are set before the transaction begins
+	db.AddRejectedQuery("set transaction read only")
+	defer func() {
+		err, ok := recover().(*TabletError)
+		if !ok || err.ErrorType != ErrFail {
+			t.Fatalf("got error: %v, want error type: %v", err, ErrFail)
+		}
+	}()
+	txPool.BeginWithOptions(ctx, &proto.TransactionOptions{ReadOnly: true})
+}
+
 func newTxPool() *TxPool {
 	randID := rand.Int63()
 	poolName := fmt.Sprintf("TestTransactionPool-%d", randID)
@@ -0,0 +1,135 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/sync2"
+	"github.com/youtube/vitess/go/vt/callinfo"
+	"golang.org/x/net/context"
+)
+
+// txThrottlerLagMaxAge is how long a replica lag is used. If the
+// tablet manager stops updating it, the throttler stops throttling.
+const txThrottlerLagMaxAge = time.Minute
+
+// txThrottler throttles the transactions on the master when the
+// replicas lag, so bulk loads don't push them out of their SLA. The
+// lag is set by the tablet manager from the health of the replicas.
+// Above threshold, a fraction of the transactions is throttled,
+// growing linearly to all of them at maxLag. A throttled transaction
+// is delayed by delay, or rejected if delay is 0. The transactions
+// of the exempt users are never throttled.
+type txThrottler struct {
+	threshold   time.Duration
+	maxLag      time.Duration
+	delay       time.Duration
+	exemptUsers map[string]bool
+	random      func() float64
+	now         func() time.Time
+
+	isMaster sync2.AtomicInt32
+
+	mu      sync.Mutex
+	lag     time.Duration
+	lagTime time.Time
+
+	// throttled counts the throttled transactions, by outcome:
+	// Delayed, Rejected, or Exempt.
+	throttled *stats.Counters
+}
+
+func newTxThrottler(statsPrefix string, threshold, maxLag, delay time.Duration, exemptUsers string) *txThrottler {
+	tt := &txThrottler{
+		threshold:   threshold,
+		maxLag:      maxLag,
+		delay:       delay,
+		exemptUsers: make(map[string]bool),
+		random:      rand.Float64,
+		now:         time.Now,
+		throttled:   stats.NewCounters(statsPrefix + "TxThrottled"),
+	}
+	for _, user := range strings.Split(exemptUsers, ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			tt.exemptUsers[user] = true
+		}
+	}
+	stats.Publish(statsPrefix+"TxThrottlerReplicaLag", stats.DurationFunc(tt.ReplicaLag))
+	return tt
+}
+
+// SetIsMaster enables the throttling if isMaster is true.
+func (tt *txThrottler) SetIsMaster(isMaster bool) {
+	if isMaster {
+		tt.isMaster.Set(1)
+	} else {
+		tt.isMaster.Set(0)
+	}
+}
+
+// SetReplicaLag sets the current replication lag of the replicas.
+func (tt *txThrottler) SetReplicaLag(lag time.Duration) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	tt.lag = lag
+	tt.lagTime = tt.now()
+}
+
+// ReplicaLag returns the last replication lag set, or 0 if it is
+// too old.
+func (tt *txThrottler) ReplicaLag() time.Duration {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	if tt.now().Sub(tt.lagTime) > txThrottlerLagMaxAge {
+		return 0
+	}
+	return tt.lag
+}
+
+// throttledRatio returns the fraction of the transactions to
+// throttle for this replication lag.
+func (tt *txThrottler) throttledRatio(lag time.Duration) float64 {
+	if lag <= tt.threshold {
+		return 0
+	}
+	if lag >= tt.maxLag {
+		return 1
+	}
+	return float64(lag-tt.threshold) / float64(tt.maxLag-tt.threshold)
+}
+
+// Throttle is called before a transaction starts. It returns an
+// error if the transaction is rejected, and blocks while it is
+// delayed.
+func (tt *txThrottler) Throttle(ctx context.Context) error {
+	if tt.threshold <= 0 || tt.isMaster.Get() == 0 {
+		return nil
+	}
+	lag := tt.ReplicaLag()
+	ratio := tt.throttledRatio(lag)
+	if ratio == 0 || tt.random() >= ratio {
+		return nil
+	}
+	if ci, ok := callinfo.FromContext(ctx); ok && tt.exemptUsers[ci.Username()] {
+		tt.throttled.Add("Exempt", 1)
+		return nil
+	}
+	if tt.delay <= 0 {
+		tt.throttled.Add("Rejected", 1)
+		return NewTabletError(ErrTxPoolFull, "Transaction throttled: replica lag is %v", lag)
+	}
+	tt.throttled.Add("Delayed", 1)
+	select {
+	case <-ctx.Done():
+		return NewTabletError(ErrTxPoolFull, "Transaction throttled: replica lag is %v, timed out while delayed", lag)
+	case <-time.After(tt.delay):
+		return nil
+	}
+}
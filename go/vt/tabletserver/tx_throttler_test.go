@@ -0,0 +1,115 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/callinfo"
+	"golang.org/x/net/context"
+)
+
+func newTestTxThrottler(delay time.Duration, random float64) *txThrottler {
+	tt := newTxThrottler(fmt.Sprintf("Stats-%d-", rand.Int63()), 10*time.Second, 30*time.Second, delay, "batch, loader")
+	tt.random = func() float64 { return random }
+	tt.SetIsMaster(true)
+	return tt
+}
+
+func TestTxThrottlerRatio(t *testing.T) {
+	tt := newTestTxThrottler(0, 0)
+	for _, tc := range []struct {
+		lag  time.Duration
+		want float64
+	}{
+		{5 * time.Second, 0},
+		{10 * time.Second, 0},
+		{15 * time.Second, 0.25},
+		{20 * time.Second, 0.5},
+		{30 * time.Second, 1},
+		{time.Minute, 1},
+	} {
+		if got := tt.throttledRatio(tc.lag); got != tc.want {
+			t.Errorf("throttledRatio(%v) = %v, want %v", tc.lag, got, tc.want)
+		}
+	}
+}
+
+func TestTxThrottlerReject(t *testing.T) {
+	ctx := context.Background()
+	tt := newTestTxThrottler(0, 0.4)
+
+	// no lag, or a lag under the threshold
+	if err := tt.Throttle(ctx); err != nil {
+		t.Errorf("Throttle without lag failed: %v", err)
+	}
+	tt.SetReplicaLag(5 * time.Second)
+	if err := tt.Throttle(ctx); err != nil {
+		t.Errorf("Throttle under the threshold failed: %v", err)
+	}
+
+	// 25% of the transactions are throttled, this one isn't
+	tt.SetReplicaLag(15 * time.Second)
+	if err := tt.Throttle(ctx); err != nil {
+		t.Errorf("Throttle of an unlucky transaction failed: %v", err)
+	}
+
+	// 50% of the transactions are throttled, this one is
+	tt.SetReplicaLag(20 * time.Second)
+	err := tt.Throttle(ctx)
+	if terr, ok := err.(*TabletError); !ok || terr.ErrorType != ErrTxPoolFull {
+		t.Errorf("Throttle returned %v, want a ErrTxPoolFull error", err)
+	}
+
+	// unless it comes from an exempt user
+	exemptCtx := callinfo.NewContext(ctx, &fakeCallInfo{username: "loader"})
+	if err := tt.Throttle(exemptCtx); err != nil {
+		t.Errorf("Throttle of an exempt user failed: %v", err)
+	}
+
+	// replicas don't throttle
+	tt.SetIsMaster(false)
+	if err := tt.Throttle(ctx); err != nil {
+		t.Errorf("Throttle on a replica failed: %v", err)
+	}
+	tt.SetIsMaster(true)
+
+	// and an old lag is ignored
+	tt.now = func() time.Time { return time.Now().Add(txThrottlerLagMaxAge + time.Second) }
+	if err := tt.Throttle(ctx); err != nil {
+		t.Errorf("Throttle with an old lag failed: %v", err)
+	}
+
+	want := map[string]int64{"Rejected": 1, "Exempt": 1}
+	got := tt.throttled.Counts()
+	if len(got) != len(want) || got["Rejected"] != 1 || got["Exempt"] != 1 {
+		t.Errorf("throttled: %v, want %v", got, want)
+	}
+}
+
+func TestTxThrottlerDelay(t *testing.T) {
+	tt := newTestTxThrottler(10*time.Millisecond, 0)
+	tt.SetReplicaLag(time.Minute)
+	start := time.Now()
+	if err := tt.Throttle(context.Background()); err != nil {
+		t.Errorf("Throttle failed: %v", err)
+	}
+	if elapsed := time.Now().Sub(start); elapsed < 10*time.Millisecond {
+		t.Errorf("Throttle returned after %v, want at least 10ms", elapsed)
+	}
+
+	tt.delay = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tt.Throttle(ctx); err == nil {
+		t.Errorf("Throttle past the deadline should have failed")
+	}
+	if got := tt.throttled.Counts()["Delayed"]; got != 2 {
+		t.Errorf("delayed: %v, want 2", got)
+	}
+}
@@ -123,7 +123,7 @@ func txlogzHandler(w http.ResponseWriter, req *http.Request) {
 				ColorLevel string
 			}{txc, duration, level}
 			if err := txlogzTmpl.Execute(w, tmplData); err != nil {
-				log.Errorf("txlogz: couldn't execute template: %v", err)
+				mlog.Errorf("txlogz: couldn't execute template: %v", err)
 			}
 		case <-tmr.C:
 			return
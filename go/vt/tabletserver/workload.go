@@ -0,0 +1,66 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"strings"
+
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/vt/callinfo"
+	"golang.org/x/net/context"
+)
+
+// OlapHint is the trailing comment that makes a query run in the
+// OLAP pool, for instance 'select ... /* vt_workload=olap */'.
+const OlapHint = "vt_workload=olap"
+
+// The workload classes of the queries.
+const (
+	workloadOLTP = "OLTP"
+	workloadOLAP = "OLAP"
+)
+
+// workloadClassifier decides which queries are analytic (OLAP), so
+// they run in their own pool and with their own timeout, and a heavy
+// scan can't starve the transactional (OLTP) queries of connections.
+// A query is OLAP if it has the OlapHint in its trailing comments, or
+// if it comes from one of the OLAP users. Queries are only classified
+// outside of transactions, and when the OLAP pool is enabled.
+type workloadClassifier struct {
+	olapUsers map[string]bool
+
+	// queries counts the classified queries, by workload.
+	queries *stats.Counters
+}
+
+func newWorkloadClassifier(statsPrefix, olapUsers string) *workloadClassifier {
+	wc := &workloadClassifier{
+		olapUsers: make(map[string]bool),
+		queries:   stats.NewCounters(statsPrefix + "WorkloadQueries"),
+	}
+	for _, user := range strings.Split(olapUsers, ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			wc.olapUsers[user] = true
+		}
+	}
+	return wc
+}
+
+// isOLAP returns true if the query is analytic. The trailing comments
+// must have been stripped into the bind variables already.
+func (wc *workloadClassifier) isOLAP(ctx context.Context, bindVars map[string]interface{}) bool {
+	olap := false
+	if comment, ok := bindVars[TRAILING_COMMENT].(string); ok && strings.Contains(comment, OlapHint) {
+		olap = true
+	} else if ci, ok := callinfo.FromContext(ctx); ok && wc.olapUsers[ci.Username()] {
+		olap = true
+	}
+	if olap {
+		wc.queries.Add(workloadOLAP, 1)
+	} else {
+		wc.queries.Add(workloadOLTP, 1)
+	}
+	return olap
+}
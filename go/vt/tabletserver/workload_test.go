@@ -0,0 +1,159 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tabletserver
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/vt/callinfo"
+	"github.com/youtube/vitess/go/vt/tabletserver/fakesqldb"
+	"github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"golang.org/x/net/context"
+)
+
+func TestWorkloadClassifier(t *testing.T) {
+	wc := newWorkloadClassifier(fmt.Sprintf("Stats-%d-", rand.Int63()), "reports, etl")
+	for _, tc := range []struct {
+		comment  string
+		username string
+		want     bool
+	}{
+		{"", "", false},
+		{"", "app", false},
+		{" /* vt_workload=olap */", "app", true},
+		{" /* other */", "app", false},
+		{"", "reports", true},
+		{"", "etl", true},
+	} {
+		ctx := context.Background()
+		if tc.username != "" {
+			ctx = callinfo.NewContext(ctx, &fakeCallInfo{username: tc.username})
+		}
+		bindVars := make(map[string]interface{})
+		if tc.comment != "" {
+			bindVars[TRAILING_COMMENT] = tc.comment
+		}
+		if got := wc.isOLAP(ctx, bindVars); got != tc.want {
+			t.Errorf("isOLAP(%q, %q) = %v, want %v", tc.comment, tc.username, got, tc.want)
+		}
+	}
+	if got := wc.queries.Counts(); got[workloadOLAP] != 3 || got[workloadOLTP] != 3 {
+		t.Errorf("queries: %v, want 3 OLAP and 3 OLTP", got)
+	}
+}
+
+func TestWorkloadOlapPool(t *testing.T) {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	sqlQuery := allowWorkloadQueries(t, 1)
+	defer sqlQuery.disallowQueries()
+
+	// Take the only OLTP connection, as a heavy scan would.
+	conn, err := sqlQuery.qe.connPool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("connPool.Get failed: %v", err)
+	}
+	defer conn.Recycle()
+
+	execute := func(sql string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		query := &proto.Query{
+			Sql:       sql,
+			SessionId: sqlQuery.sessionID,
+		}
+		return sqlQuery.Execute(ctx, query, &mproto.QueryResult{})
+	}
+	if err := execute("select * from test_table /* vt_workload=olap */"); err != nil {
+		t.Errorf("OLAP query failed with the OLTP pool exhausted: %v", err)
+	}
+	if err := execute("select * from test_table"); err == nil {
+		t.Errorf("OLTP query succeeded with the OLTP pool exhausted")
+	}
+}
+
+func TestWorkloadOlapPoolDisabled(t *testing.T) {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	sqlQuery := allowWorkloadQueries(t, 0)
+	defer sqlQuery.disallowQueries()
+
+	query := &proto.Query{
+		Sql:       "select * from test_table /* vt_workload=olap */",
+		SessionId: sqlQuery.sessionID,
+	}
+	if err := sqlQuery.Execute(context.Background(), query, &mproto.QueryResult{}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got := sqlQuery.qe.workload.queries.Counts(); len(got) != 0 {
+		t.Errorf("queries classified with the OLAP pool disabled: %v", got)
+	}
+}
+
+func TestWorkloadOlapSet(t *testing.T) {
+	db := fakesqldb.Register()
+	for query, result := range getSupportedQueries() {
+		db.AddQuery(query, result)
+	}
+	execute := func(sqlQuery *SqlQuery, sql string) error {
+		query := &proto.Query{
+			Sql:       sql,
+			SessionId: sqlQuery.sessionID,
+		}
+		return sqlQuery.Execute(context.Background(), query, &mproto.QueryResult{})
+	}
+
+	// A pool disabled at startup is never opened.
+	sqlQuery := allowWorkloadQueries(t, 0)
+	if err := execute(sqlQuery, "set vt_olap_pool_size = 1"); err == nil {
+		t.Errorf("set vt_olap_pool_size worked with the OLAP pool disabled")
+	}
+	if got := sqlQuery.qe.olapConnPool.Capacity(); got != 0 {
+		t.Errorf("OLAP pool capacity: %v, want 0", got)
+	}
+	sqlQuery.disallowQueries()
+
+	sqlQuery = allowWorkloadQueries(t, 1)
+	defer sqlQuery.disallowQueries()
+	for _, sql := range []string{
+		"set vt_olap_pool_size = 0",
+		"set vt_olap_query_timeout = 0",
+	} {
+		if err := execute(sqlQuery, sql); err == nil {
+			t.Errorf("%q worked, want an error", sql)
+		}
+	}
+	if err := execute(sqlQuery, "set vt_olap_query_timeout = 60"); err != nil {
+		t.Errorf("set vt_olap_query_timeout failed: %v", err)
+	}
+	if got := sqlQuery.qe.olapQueryTimeout.Get(); got != time.Minute {
+		t.Errorf("OLAP query timeout: %v, want 1m", got)
+	}
+}
+
+func allowWorkloadQueries(t *testing.T, olapPoolSize int) *SqlQuery {
+	randID := rand.Int63()
+	config := DefaultQsConfig
+	config.StatsPrefix = fmt.Sprintf("Stats-%d-", randID)
+	config.DebugURLPrefix = fmt.Sprintf("/debug-%d-", randID)
+	config.RowCache.StatsPrefix = fmt.Sprintf("Stats-%d-", randID)
+	config.PoolNamePrefix = fmt.Sprintf("Pool-%d-", randID)
+	config.PoolSize = 1
+	config.OlapPoolSize = olapPoolSize
+	sqlQuery := NewSqlQuery(config)
+	dbconfigs := getTestDBConfigs("test_keyspace", "0")
+	if err := sqlQuery.allowQueries(&dbconfigs, []SchemaOverride{}, newMysqld(&dbconfigs)); err != nil {
+		t.Fatalf("allowQueries failed: %v", err)
+	}
+	return sqlQuery
+}
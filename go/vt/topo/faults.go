@@ -0,0 +1,168 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package topo
+
+import (
+	"fmt"
+
+	"github.com/youtube/vitess/go/vt/faultinject"
+)
+
+// faultyServer injects faults in the reads of a Server, when the
+// faultinject package has rules for the topo. The writes and locks
+// go straight to the underlying Server.
+type faultyServer struct {
+	Server
+}
+
+func (fs faultyServer) underlying() Server {
+	return fs.Server
+}
+
+// inject returns the error to return for a read, if any. A dropped
+// read looks like a read that timed out.
+func (fs faultyServer) inject() error {
+	switch faultinject.Inject(faultinject.Topo) {
+	case faultinject.Drop:
+		return ErrTimeout
+	case faultinject.Error:
+		return fmt.Errorf("injected topology error")
+	}
+	return nil
+}
+
+// GetKnownCells is part of the Server interface
+func (fs faultyServer) GetKnownCells() ([]string, error) {
+	if err := fs.inject(); err != nil {
+		return nil, err
+	}
+	return fs.Server.GetKnownCells()
+}
+
+// GetKeyspace is part of the Server interface
+func (fs faultyServer) GetKeyspace(keyspace string) (*KeyspaceInfo, error) {
+	if err := fs.inject(); err != nil {
+		return nil, err
+	}
+	return fs.Server.GetKeyspace(keyspace)
+}
+
+// GetKeyspaces is part of the Server interface
+func (fs faultyServer) GetKeyspaces() ([]string, error) {
+	if err := fs.inject(); err != nil {
+		return nil, err
+	}
+	return fs.Server.GetKeyspaces()
+}
+
+// GetShard is part of the Server interface
+func (fs faultyServer) GetShard(keyspace, shard string) (*ShardInfo, error) {
+	if err := fs.inject(); err != nil {
+		return nil, err
+	}
+	return fs.Server.GetShard(keyspace, shard)
+}
+
+// GetShardNames is part of the Server interface
+func (fs faultyServer) GetShardNames(keyspace string) ([]string, error) {
+	if err := fs.inject(); err != nil {
+		return nil, err
+	}
+	return fs.Server.GetShardNames(keyspace)
+}
+
+// GetTablet is part of the Server interface
+func (fs faultyServer) GetTablet(alias TabletAlias) (*TabletInfo, error) {
+	if err := fs.inject(); err != nil {
+		return nil, err
+	}
+	return fs.Server.GetTablet(alias)
+}
+
+// GetTabletsByCell is part of the Server interface
+func (fs faultyServer) GetTabletsByCell(cell string) ([]TabletAlias, error) {
+	if err := fs.inject(); err != nil {
+		return nil, err
+	}
+	return fs.Server.GetTabletsByCell(cell)
+}
+
+// GetShardReplication is part of the Server interface
+func (fs faultyServer) GetShardReplication(cell, keyspace, shard string) (*ShardReplicationInfo, error) {
+	if err := fs.inject(); err != nil {
+		return nil, err
+	}
+	return fs.Server.GetShardReplication(cell, keyspace, shard)
+}
+
+// GetSrvTabletTypesPerShard is part of the Server interface
+func (fs faultyServer) GetSrvTabletTypesPerShard(cell, keyspace, shard string) ([]TabletType, error) {
+	if err := fs.inject(); err != nil {
+		return nil, err
+	}
+	return fs.Server.GetSrvTabletTypesPerShard(cell, keyspace, shard)
+}
+
+// GetEndPoints is part of the Server interface
+func (fs faultyServer) GetEndPoints(cell, keyspace, shard string, tabletType TabletType) (*EndPoints, error) {
+	if err := fs.inject(); err != nil {
+		return nil, err
+	}
+	return fs.Server.GetEndPoints(cell, keyspace, shard, tabletType)
+}
+
+// GetSrvShard is part of the Server interface
+func (fs faultyServer) GetSrvShard(cell, keyspace, shard string) (*SrvShard, error) {
+	if err := fs.inject(); err != nil {
+		return nil, err
+	}
+	return fs.Server.GetSrvShard(cell, keyspace, shard)
+}
+
+// GetSrvKeyspace is part of the Server interface
+func (fs faultyServer) GetSrvKeyspace(cell, keyspace string) (*SrvKeyspace, error) {
+	if err := fs.inject(); err != nil {
+		return nil, err
+	}
+	return fs.Server.GetSrvKeyspace(cell, keyspace)
+}
+
+// GetSrvKeyspaceNames is part of the Server interface
+func (fs faultyServer) GetSrvKeyspaceNames(cell string) ([]string, error) {
+	if err := fs.inject(); err != nil {
+		return nil, err
+	}
+	return fs.Server.GetSrvKeyspaceNames(cell)
+}
+
+// SaveVSchema is part of the Schemafier interface
+func (fs faultyServer) SaveVSchema(vschema string) error {
+	schemafier, ok := fs.Server.(Schemafier)
+	if !ok {
+		return fmt.Errorf("topo server doesn't support vschema")
+	}
+	return schemafier.SaveVSchema(vschema)
+}
+
+// GetVSchema is part of the Schemafier interface
+func (fs faultyServer) GetVSchema() (string, error) {
+	schemafier, ok := fs.Server.(Schemafier)
+	if !ok {
+		return "", fmt.Errorf("topo server doesn't support vschema")
+	}
+	if err := fs.inject(); err != nil {
+		return "", err
+	}
+	return schemafier.GetVSchema()
+}
+
+// CreateCell is part of the CellCreator interface
+func (fs faultyServer) CreateCell(cell string, addrs []string) error {
+	cc, ok := fs.Server.(CellCreator)
+	if !ok {
+		return fmt.Errorf("topo server cannot create cells")
+	}
+	return cc.CreateCell(cell, addrs)
+}
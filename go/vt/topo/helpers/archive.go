@@ -0,0 +1,299 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package helpers
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+// TopologyArchive is a snapshot of the topology data that cannot be
+// derived from other data: keyspaces, shards, tablets, the
+// replication graph and the vschema. The serving graph is not
+// included, it can be rebuilt after an import with RebuildKeyspaceGraph.
+type TopologyArchive struct {
+	Keyspaces map[string]*KeyspaceArchive
+	Cells     map[string]*CellArchive
+	VSchema   string
+}
+
+// KeyspaceArchive is the global data of a keyspace.
+type KeyspaceArchive struct {
+	Keyspace *topo.Keyspace
+	Shards   map[string]*topo.Shard
+}
+
+// CellArchive is the data of a cell.
+type CellArchive struct {
+	Tablets []*topo.Tablet
+
+	// ShardReplications are indexed by "<keyspace>/<shard>".
+	ShardReplications map[string]*topo.ShardReplication
+}
+
+// ExportTopology reads all the topology data into an archive.
+func ExportTopology(ts topo.Server) (*TopologyArchive, error) {
+	archive := &TopologyArchive{
+		Keyspaces: make(map[string]*KeyspaceArchive),
+		Cells:     make(map[string]*CellArchive),
+	}
+
+	cells, err := ts.GetKnownCells()
+	if err != nil {
+		return nil, fmt.Errorf("GetKnownCells: %v", err)
+	}
+	for _, cell := range cells {
+		ca := &CellArchive{
+			ShardReplications: make(map[string]*topo.ShardReplication),
+		}
+		tabletAliases, err := ts.GetTabletsByCell(cell)
+		if err != nil {
+			return nil, fmt.Errorf("GetTabletsByCell(%v): %v", cell, err)
+		}
+		for _, tabletAlias := range tabletAliases {
+			ti, err := ts.GetTablet(tabletAlias)
+			if err != nil {
+				return nil, fmt.Errorf("GetTablet(%v): %v", tabletAlias, err)
+			}
+			ca.Tablets = append(ca.Tablets, ti.Tablet)
+		}
+		archive.Cells[cell] = ca
+	}
+
+	keyspaces, err := ts.GetKeyspaces()
+	if err != nil {
+		return nil, fmt.Errorf("GetKeyspaces: %v", err)
+	}
+	for _, keyspace := range keyspaces {
+		ki, err := ts.GetKeyspace(keyspace)
+		if err != nil {
+			return nil, fmt.Errorf("GetKeyspace(%v): %v", keyspace, err)
+		}
+		ka := &KeyspaceArchive{
+			Keyspace: ki.Keyspace,
+			Shards:   make(map[string]*topo.Shard),
+		}
+		shards, err := ts.GetShardNames(keyspace)
+		if err != nil {
+			return nil, fmt.Errorf("GetShardNames(%v): %v", keyspace, err)
+		}
+		for _, shard := range shards {
+			si, err := ts.GetShard(keyspace, shard)
+			if err != nil {
+				return nil, fmt.Errorf("GetShard(%v, %v): %v", keyspace, shard, err)
+			}
+			ka.Shards[shard] = si.Shard
+
+			for _, cell := range si.Cells {
+				ca, ok := archive.Cells[cell]
+				if !ok {
+					return nil, fmt.Errorf("shard %v/%v is in unknown cell %v", keyspace, shard, cell)
+				}
+				sri, err := ts.GetShardReplication(cell, keyspace, shard)
+				switch err {
+				case nil:
+					ca.ShardReplications[keyspace+"/"+shard] = sri.ShardReplication
+				case topo.ErrNoNode:
+				default:
+					return nil, fmt.Errorf("GetShardReplication(%v, %v, %v): %v", cell, keyspace, shard, err)
+				}
+			}
+		}
+		archive.Keyspaces[keyspace] = ka
+	}
+
+	if schemafier, ok := ts.(topo.Schemafier); ok {
+		archive.VSchema, err = schemafier.GetVSchema()
+		if err != nil {
+			return nil, fmt.Errorf("GetVSchema: %v", err)
+		}
+	}
+	return archive, nil
+}
+
+// splitKeyspaceShard splits a "<keyspace>/<shard>" index.
+func splitKeyspaceShard(keyspaceShard string) (string, string, error) {
+	parts := strings.Split(keyspaceShard, "/")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid keyspace/shard: %v", keyspaceShard)
+	}
+	return parts[0], parts[1], nil
+}
+
+// Conflicts returns the objects of the archive that already exist in
+// ts with a different value. Objects that exist with the same value
+// are not conflicts.
+func (archive *TopologyArchive) Conflicts(ts topo.Server) ([]string, error) {
+	var conflicts []string
+	for keyspace, ka := range archive.Keyspaces {
+		ki, err := ts.GetKeyspace(keyspace)
+		switch err {
+		case nil:
+			if !reflect.DeepEqual(ki.Keyspace, ka.Keyspace) {
+				conflicts = append(conflicts, "keyspace "+keyspace)
+			}
+		case topo.ErrNoNode:
+		default:
+			return nil, fmt.Errorf("GetKeyspace(%v): %v", keyspace, err)
+		}
+
+		for shard, value := range ka.Shards {
+			si, err := ts.GetShard(keyspace, shard)
+			switch err {
+			case nil:
+				if !reflect.DeepEqual(si.Shard, value) {
+					conflicts = append(conflicts, "shard "+keyspace+"/"+shard)
+				}
+			case topo.ErrNoNode:
+			default:
+				return nil, fmt.Errorf("GetShard(%v, %v): %v", keyspace, shard, err)
+			}
+		}
+	}
+
+	for cell, ca := range archive.Cells {
+		for _, tablet := range ca.Tablets {
+			ti, err := ts.GetTablet(tablet.Alias)
+			switch err {
+			case nil:
+				if !reflect.DeepEqual(ti.Tablet, tablet) {
+					conflicts = append(conflicts, "tablet "+tablet.Alias.String())
+				}
+			case topo.ErrNoNode:
+			default:
+				return nil, fmt.Errorf("GetTablet(%v): %v", tablet.Alias, err)
+			}
+		}
+
+		for keyspaceShard, value := range ca.ShardReplications {
+			keyspace, shard, err := splitKeyspaceShard(keyspaceShard)
+			if err != nil {
+				return nil, err
+			}
+			sri, err := ts.GetShardReplication(cell, keyspace, shard)
+			switch err {
+			case nil:
+				if !reflect.DeepEqual(sri.ShardReplication, value) {
+					conflicts = append(conflicts, "shard replication "+cell+"/"+keyspaceShard)
+				}
+			case topo.ErrNoNode:
+			default:
+				return nil, fmt.Errorf("GetShardReplication(%v, %v, %v): %v", cell, keyspace, shard, err)
+			}
+		}
+	}
+
+	if archive.VSchema != "" {
+		if schemafier, ok := ts.(topo.Schemafier); ok {
+			vschema, err := schemafier.GetVSchema()
+			if err != nil {
+				return nil, fmt.Errorf("GetVSchema: %v", err)
+			}
+			if vschema != "" && vschema != "{}" && vschema != archive.VSchema {
+				conflicts = append(conflicts, "vschema")
+			}
+		}
+	}
+
+	sort.Strings(conflicts)
+	return conflicts, nil
+}
+
+// ImportTopology writes the content of the archive to ts. Objects
+// that already exist with a different value are conflicts: if there
+// is any, nothing is written, unless force is set, in which case they
+// are overwritten.
+func ImportTopology(ts topo.Server, archive *TopologyArchive, force bool) error {
+	conflicts, err := archive.Conflicts(ts)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		if !force {
+			return fmt.Errorf("the archive conflicts with existing objects: %v", strings.Join(conflicts, ", "))
+		}
+		log.Warningf("overwriting existing objects: %v", strings.Join(conflicts, ", "))
+	}
+
+	for keyspace, ka := range archive.Keyspaces {
+		if err := importKeyspace(ts, keyspace, ka); err != nil {
+			return err
+		}
+	}
+
+	for cell, ca := range archive.Cells {
+		for _, tablet := range ca.Tablets {
+			err := ts.CreateTablet(tablet)
+			if err == topo.ErrNodeExists {
+				err = ts.UpdateTabletFields(tablet.Alias, func(t *topo.Tablet) error {
+					*t = *tablet
+					return nil
+				})
+			}
+			if err != nil {
+				return fmt.Errorf("CreateTablet(%v): %v", tablet.Alias, err)
+			}
+		}
+
+		for keyspaceShard, value := range ca.ShardReplications {
+			keyspace, shard, err := splitKeyspaceShard(keyspaceShard)
+			if err != nil {
+				return err
+			}
+			if err := ts.UpdateShardReplicationFields(cell, keyspace, shard, func(sr *topo.ShardReplication) error {
+				*sr = *value
+				return nil
+			}); err != nil {
+				return fmt.Errorf("UpdateShardReplicationFields(%v, %v, %v): %v", cell, keyspace, shard, err)
+			}
+		}
+	}
+
+	if archive.VSchema != "" {
+		schemafier, ok := ts.(topo.Schemafier)
+		if !ok {
+			return fmt.Errorf("%T does not support vschema operations", ts)
+		}
+		if err := schemafier.SaveVSchema(archive.VSchema); err != nil {
+			return fmt.Errorf("SaveVSchema: %v", err)
+		}
+	}
+	return nil
+}
+
+// importKeyspace creates or overwrites a keyspace and its shards.
+func importKeyspace(ts topo.Server, keyspace string, ka *KeyspaceArchive) error {
+	err := ts.CreateKeyspace(keyspace, ka.Keyspace)
+	if err == topo.ErrNodeExists {
+		var ki *topo.KeyspaceInfo
+		ki, err = ts.GetKeyspace(keyspace)
+		if err == nil {
+			_, err = ts.UpdateKeyspace(topo.NewKeyspaceInfo(keyspace, ka.Keyspace, ki.Version()), ki.Version())
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("CreateKeyspace(%v): %v", keyspace, err)
+	}
+
+	for shard, value := range ka.Shards {
+		err := ts.CreateShard(keyspace, shard, value)
+		if err == topo.ErrNodeExists {
+			var si *topo.ShardInfo
+			si, err = ts.GetShard(keyspace, shard)
+			if err == nil {
+				_, err = ts.UpdateShard(topo.NewShardInfo(keyspace, shard, value, si.Version()), si.Version())
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("CreateShard(%v, %v): %v", keyspace, shard, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package helpers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+func TestExportImportTopology(t *testing.T) {
+	fromTS, toTS := createSetup(t)
+
+	archive, err := ExportTopology(fromTS)
+	if err != nil {
+		t.Fatalf("ExportTopology failed: %v", err)
+	}
+	if len(archive.Keyspaces["test_keyspace"].Shards) != 1 {
+		t.Fatalf("unexpected archive keyspaces: %v", archive.Keyspaces)
+	}
+	if len(archive.Cells["test_cell"].Tablets) != 2 {
+		t.Fatalf("unexpected archive tablets: %v", archive.Cells["test_cell"].Tablets)
+	}
+
+	// go through JSON, as the vtctl commands do
+	data, err := json.Marshal(archive)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	archive = &TopologyArchive{}
+	if err := json.Unmarshal(data, archive); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+
+	if err := ImportTopology(toTS, archive, false); err != nil {
+		t.Fatalf("ImportTopology failed: %v", err)
+	}
+	ti, err := toTS.GetTablet(topo.TabletAlias{Cell: "test_cell", Uid: 123})
+	if err != nil {
+		t.Fatalf("GetTablet failed: %v", err)
+	}
+	if ti.Hostname != "masterhost" || ti.Type != topo.TYPE_MASTER {
+		t.Errorf("unexpected imported tablet: %v", ti)
+	}
+	sri, err := toTS.GetShardReplication("test_cell", "test_keyspace", "0")
+	if err != nil {
+		t.Fatalf("GetShardReplication failed: %v", err)
+	}
+	if len(sri.ReplicationLinks) != 2 {
+		t.Errorf("unexpected imported replication links: %v", sri.ReplicationLinks)
+	}
+
+	// importing the same data again is not a conflict
+	if err := ImportTopology(toTS, archive, false); err != nil {
+		t.Fatalf("ImportTopology(again) failed: %v", err)
+	}
+
+	// a different tablet is
+	if err := toTS.UpdateTabletFields(ti.Alias, func(tablet *topo.Tablet) error {
+		tablet.Hostname = "otherhost"
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateTabletFields failed: %v", err)
+	}
+	err = ImportTopology(toTS, archive, false)
+	if err == nil || !strings.Contains(err.Error(), "tablet test_cell-0000000123") {
+		t.Fatalf("ImportTopology with a conflict returned: %v", err)
+	}
+
+	// unless forced
+	if err := ImportTopology(toTS, archive, true); err != nil {
+		t.Fatalf("ImportTopology(force) failed: %v", err)
+	}
+	ti, err = toTS.GetTablet(ti.Alias)
+	if err != nil {
+		t.Fatalf("GetTablet failed: %v", err)
+	}
+	if ti.Hostname != "masterhost" {
+		t.Errorf("tablet was not overwritten: %v", ti)
+	}
+}
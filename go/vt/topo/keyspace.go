@@ -8,8 +8,6 @@ import (
 	"fmt"
 	"sync"
 
-	log "github.com/golang/glog"
-
 	"github.com/youtube/vitess/go/vt/concurrency"
 	"github.com/youtube/vitess/go/vt/key"
 )
@@ -37,6 +35,15 @@ type Keyspace struct {
 	// KIT_UNSET if the keyspace is not sharded
 	ShardingColumnType key.KeyspaceIdType
 
+	// name of the vindex type that computes the keyspace ids from
+	// the values of the sharding column, like hash, numeric or
+	// lookup_hash_unique. Installations can register their own with
+	// planbuilder.Register. Empty if the values are the keyspace ids.
+	ShardingScheme string
+
+	// parameters of the ShardingScheme vindex
+	ShardingSchemeParams map[string]string
+
 	// ServedFromMap will redirect the appropriate traffic to
 	// another keyspace
 	ServedFromMap map[TabletType]*KeyspaceServedFrom
@@ -130,7 +137,7 @@ func (ki *KeyspaceInfo) UpdateServedFromMap(tabletType TabletType, cells []strin
 			if len(ki.ServedFromMap) == 0 {
 				ki.ServedFromMap = nil
 			}
-			log.Warningf("Trying to remove KeyspaceServedFrom for missing type %v in keyspace %v", tabletType, ki.keyspace)
+			mlog.Warningf("Trying to remove KeyspaceServedFrom for missing type %v in keyspace %v", tabletType, ki.keyspace)
 		} else {
 			ki.ServedFromMap[tabletType] = &KeyspaceServedFrom{
 				Cells:    cells,
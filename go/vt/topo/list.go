@@ -0,0 +1,231 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package topo
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/net/context"
+)
+
+// TabletFilter selects the tablets returned by FindTablets. Its empty
+// fields match all the tablets.
+type TabletFilter struct {
+	// Cells are the cells of the tablets.
+	Cells []string
+
+	// Keyspace and Shard are the keyspace and shard of the
+	// tablets. Shard can only be used with Keyspace.
+	Keyspace string
+	Shard    string
+
+	// Types are the types of the tablets.
+	Types []TabletType
+
+	// Tags are tags the tablets must all have, see MatchTags.
+	Tags map[string]string
+}
+
+// Match returns true if the tablet matches the filter.
+func (filter *TabletFilter) Match(tablet *Tablet) bool {
+	if len(filter.Cells) > 0 && !InCellList(tablet.Alias.Cell, filter.Cells) {
+		return false
+	}
+	if filter.Keyspace != "" && tablet.Keyspace != filter.Keyspace {
+		return false
+	}
+	if filter.Shard != "" && tablet.Shard != filter.Shard {
+		return false
+	}
+	if len(filter.Types) > 0 && !IsTypeInList(tablet.Type, filter.Types) {
+		return false
+	}
+	return MatchTags(tablet.Tags, filter.Tags)
+}
+
+// FindTablets returns the tablets that match the filter, sorted by
+// alias, one page at a time. A page has at most pageSize tablets,
+// or all of them if pageSize is 0, and starts after the tablet
+// pageToken, or at the first tablet if it's empty. It also returns
+// the token of the next page, or an empty token after the last page.
+// As the tablets are only read a page at a time, the last page may
+// be empty.
+//
+// It only reads the records of the candidate tablets: the tablets of
+// the cells of the filter, or of its keyspace or shard, as found in
+// the replication graph. It can return ErrPartialResult if some
+// cells or tablets couldn't be read, in which case the page only
+// contains the tablets that were read.
+func FindTablets(ctx context.Context, ts Server, filter *TabletFilter, pageToken string, pageSize int) ([]*TabletInfo, string, error) {
+	if filter.Shard != "" && filter.Keyspace == "" {
+		return nil, "", fmt.Errorf("a tablet filter with a shard needs a keyspace")
+	}
+	aliases, partial, err := findTabletCandidates(ctx, ts, filter)
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Sort(TabletAliasList(aliases))
+	if pageToken != "" {
+		after, err := ParseTabletAliasString(pageToken)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid page token %q: %v", pageToken, err)
+		}
+		aliases = aliases[sort.Search(len(aliases), func(i int) bool {
+			return after.Cell < aliases[i].Cell || (after.Cell == aliases[i].Cell && after.Uid < aliases[i].Uid)
+		}):]
+	}
+
+	// Read the records a page worth at a time, until the page
+	// is full or we run out of candidates.
+	var result []*TabletInfo
+	for len(aliases) > 0 {
+		batch := aliases
+		if pageSize > 0 && len(batch) > pageSize-len(result) {
+			batch = batch[:pageSize-len(result)]
+		}
+		aliases = aliases[len(batch):]
+		tabletMap, err := GetTabletMap(ctx, ts, batch)
+		switch err {
+		case nil:
+		case ErrPartialResult:
+			partial = true
+		default:
+			return nil, "", err
+		}
+		for _, alias := range batch {
+			if ti, ok := tabletMap[alias]; ok && filter.Match(ti.Tablet) {
+				result = append(result, ti)
+			}
+		}
+		if pageSize > 0 && len(result) == pageSize {
+			break
+		}
+	}
+
+	nextPageToken := ""
+	if len(aliases) > 0 {
+		nextPageToken = result[len(result)-1].Alias.String()
+	}
+	if partial {
+		return result, nextPageToken, ErrPartialResult
+	}
+	return result, nextPageToken, nil
+}
+
+// findTabletCandidates returns the aliases of the tablets that may
+// match the filter, and whether some of them couldn't be found.
+func findTabletCandidates(ctx context.Context, ts Server, filter *TabletFilter) ([]TabletAlias, bool, error) {
+	partial := false
+	if filter.Keyspace != "" {
+		shards := []string{filter.Shard}
+		if filter.Shard == "" {
+			var err error
+			shards, err = ts.GetShardNames(filter.Keyspace)
+			if err != nil {
+				return nil, false, fmt.Errorf("GetShardNames(%v) failed: %v", filter.Keyspace, err)
+			}
+		}
+		var result []TabletAlias
+		for _, shard := range shards {
+			aliases, err := FindAllTabletAliasesInShardByCell(ctx, ts, filter.Keyspace, shard, filter.Cells)
+			switch err {
+			case nil:
+			case ErrPartialResult:
+				partial = true
+			default:
+				return nil, false, err
+			}
+			result = append(result, aliases...)
+		}
+		return result, partial, nil
+	}
+
+	cells := filter.Cells
+	if len(cells) == 0 {
+		var err error
+		cells, err = ts.GetKnownCells()
+		if err != nil {
+			return nil, false, fmt.Errorf("GetKnownCells failed: %v", err)
+		}
+	}
+	var result []TabletAlias
+	for _, cell := range cells {
+		aliases, err := ts.GetTabletsByCell(cell)
+		if err != nil {
+			return nil, false, fmt.Errorf("GetTabletsByCell(%v) failed: %v", cell, err)
+		}
+		result = append(result, aliases...)
+	}
+	return result, partial, nil
+}
+
+// FindShards returns the shards of a keyspace, or of all keyspaces
+// if keyspace is empty, that have tablets in one of the cells, or in
+// any cell if cells is empty. They are sorted by keyspace, then by
+// shard name, and paginated like FindTablets: pageToken is the
+// keyspace/shard of the last shard of the previous page.
+func FindShards(ctx context.Context, ts Server, keyspace string, cells []string, pageToken string, pageSize int) ([]*ShardInfo, string, error) {
+	keyspaces := []string{keyspace}
+	if keyspace == "" {
+		var err error
+		keyspaces, err = ts.GetKeyspaces()
+		if err != nil {
+			return nil, "", fmt.Errorf("GetKeyspaces failed: %v", err)
+		}
+		sort.Strings(keyspaces)
+	}
+	afterKeyspace, afterShard := "", ""
+	if pageToken != "" {
+		var err error
+		afterKeyspace, afterShard, err = ParseKeyspaceShardString(pageToken)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid page token %q: %v", pageToken, err)
+		}
+	}
+
+	var result []*ShardInfo
+	for _, ks := range keyspaces {
+		if ks < afterKeyspace {
+			continue
+		}
+		shards, err := ts.GetShardNames(ks)
+		if err != nil {
+			return nil, "", fmt.Errorf("GetShardNames(%v) failed: %v", ks, err)
+		}
+		sort.Strings(shards)
+		for _, shard := range shards {
+			if ks == afterKeyspace && shard <= afterShard {
+				continue
+			}
+			if pageSize > 0 && len(result) == pageSize {
+				last := result[len(result)-1]
+				return result, last.Keyspace() + "/" + last.ShardName(), nil
+			}
+			si, err := GetShard(ctx, ts, ks, shard)
+			if err != nil {
+				return nil, "", err
+			}
+			if shardHasAnyCell(si, cells) {
+				result = append(result, si)
+			}
+		}
+	}
+	return result, "", nil
+}
+
+// shardHasAnyCell returns true if the shard has tablets in one of the
+// cells, or if cells is empty.
+func shardHasAnyCell(si *ShardInfo, cells []string) bool {
+	if len(cells) == 0 {
+		return true
+	}
+	for _, cell := range cells {
+		if si.HasCell(cell) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package topo
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// listServer serves the tablets it is built with, and the shards and
+// replication graphs derived from them. The other methods are not
+// implemented.
+type listServer struct {
+	Server
+	tablets map[TabletAlias]*Tablet
+}
+
+func newListServer(tablets ...*Tablet) listServer {
+	ls := listServer{tablets: make(map[TabletAlias]*Tablet)}
+	for _, tablet := range tablets {
+		ls.tablets[tablet.Alias] = tablet
+	}
+	return ls
+}
+
+func (ls listServer) GetKnownCells() ([]string, error) {
+	return []string{"cell1", "cell2"}, nil
+}
+
+func (ls listServer) GetTabletsByCell(cell string) ([]TabletAlias, error) {
+	var result []TabletAlias
+	for alias := range ls.tablets {
+		if alias.Cell == cell {
+			result = append(result, alias)
+		}
+	}
+	return result, nil
+}
+
+func (ls listServer) GetTablet(alias TabletAlias) (*TabletInfo, error) {
+	tablet, ok := ls.tablets[alias]
+	if !ok {
+		return nil, ErrNoNode
+	}
+	return NewTabletInfo(tablet, 1), nil
+}
+
+func (ls listServer) GetKeyspaces() ([]string, error) {
+	keyspaces := make(map[string]bool)
+	for _, tablet := range ls.tablets {
+		keyspaces[tablet.Keyspace] = true
+	}
+	var result []string
+	for keyspace := range keyspaces {
+		result = append(result, keyspace)
+	}
+	return result, nil
+}
+
+func (ls listServer) GetShardNames(keyspace string) ([]string, error) {
+	shards := make(map[string]bool)
+	for _, tablet := range ls.tablets {
+		if tablet.Keyspace == keyspace {
+			shards[tablet.Shard] = true
+		}
+	}
+	var result []string
+	for shard := range shards {
+		result = append(result, shard)
+	}
+	return result, nil
+}
+
+func (ls listServer) GetShard(keyspace, shard string) (*ShardInfo, error) {
+	cells := make(map[string]bool)
+	for _, tablet := range ls.tablets {
+		if tablet.Keyspace == keyspace && tablet.Shard == shard {
+			cells[tablet.Alias.Cell] = true
+		}
+	}
+	if len(cells) == 0 {
+		return nil, ErrNoNode
+	}
+	value := &Shard{}
+	for cell := range cells {
+		value.Cells = append(value.Cells, cell)
+	}
+	sort.Strings(value.Cells)
+	return NewShardInfo(keyspace, shard, value, 1), nil
+}
+
+func (ls listServer) GetShardReplication(cell, keyspace, shard string) (*ShardReplicationInfo, error) {
+	sr := &ShardReplication{}
+	for alias, tablet := range ls.tablets {
+		if alias.Cell == cell && tablet.Keyspace == keyspace && tablet.Shard == shard {
+			sr.ReplicationLinks = append(sr.ReplicationLinks, ReplicationLink{TabletAlias: alias})
+		}
+	}
+	return NewShardReplicationInfo(sr, cell, keyspace, shard), nil
+}
+
+func listTestTablets() listServer {
+	return newListServer(
+		&Tablet{Alias: TabletAlias{"cell1", 1}, Keyspace: "ks1", Shard: "0", Type: TYPE_MASTER},
+		&Tablet{Alias: TabletAlias{"cell1", 2}, Keyspace: "ks1", Shard: "0", Type: TYPE_REPLICA, Tags: map[string]string{"rack": "r1"}},
+		&Tablet{Alias: TabletAlias{"cell2", 3}, Keyspace: "ks1", Shard: "0", Type: TYPE_RDONLY, Tags: map[string]string{"rack": "r1"}},
+		&Tablet{Alias: TabletAlias{"cell1", 4}, Keyspace: "ks2", Shard: "-80", Type: TYPE_MASTER},
+		&Tablet{Alias: TabletAlias{"cell2", 5}, Keyspace: "ks2", Shard: "80-", Type: TYPE_MASTER},
+		&Tablet{Alias: TabletAlias{"cell2", 6}, Keyspace: "ks2", Shard: "80-", Type: TYPE_REPLICA, Tags: map[string]string{"rack": "r2"}},
+	)
+}
+
+func tabletUids(tablets []*TabletInfo) []uint32 {
+	var result []uint32
+	for _, ti := range tablets {
+		result = append(result, ti.Alias.Uid)
+	}
+	return result
+}
+
+func TestFindTablets(t *testing.T) {
+	ctx := context.Background()
+	ts := listTestTablets()
+
+	table := []struct {
+		filter TabletFilter
+		want   []uint32
+	}{
+		{TabletFilter{}, []uint32{1, 2, 4, 3, 5, 6}},
+		{TabletFilter{Cells: []string{"cell2"}}, []uint32{3, 5, 6}},
+		{TabletFilter{Keyspace: "ks1"}, []uint32{1, 2, 3}},
+		{TabletFilter{Keyspace: "ks2", Shard: "80-"}, []uint32{5, 6}},
+		{TabletFilter{Keyspace: "ks1", Cells: []string{"cell1"}}, []uint32{1, 2}},
+		{TabletFilter{Types: []TabletType{TYPE_MASTER}}, []uint32{1, 4, 5}},
+		{TabletFilter{Tags: map[string]string{"rack": "r1"}}, []uint32{2, 3}},
+		{TabletFilter{Keyspace: "ks2", Types: []TabletType{TYPE_REPLICA, TYPE_RDONLY}}, []uint32{6}},
+	}
+	for _, test := range table {
+		tablets, nextPageToken, err := FindTablets(ctx, ts, &test.filter, "", 0)
+		if err != nil {
+			t.Errorf("FindTablets(%+v) failed: %v", test.filter, err)
+			continue
+		}
+		if got := tabletUids(tablets); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("FindTablets(%+v) = %v, want %v", test.filter, got, test.want)
+		}
+		if nextPageToken != "" {
+			t.Errorf("FindTablets(%+v) returned next page token %q", test.filter, nextPageToken)
+		}
+	}
+
+	if _, _, err := FindTablets(ctx, ts, &TabletFilter{Shard: "0"}, "", 0); err == nil {
+		t.Errorf("FindTablets with a shard but no keyspace worked")
+	}
+}
+
+func TestFindTabletsPages(t *testing.T) {
+	ctx := context.Background()
+	ts := listTestTablets()
+
+	// Pages only count the tablets that match the filter. The last
+	// page is empty, as tablet 6 is a candidate, but doesn't match.
+	filter := &TabletFilter{Types: []TabletType{TYPE_MASTER, TYPE_RDONLY}}
+	var got [][]uint32
+	pageToken := ""
+	for {
+		tablets, nextPageToken, err := FindTablets(ctx, ts, filter, pageToken, 2)
+		if err != nil {
+			t.Fatalf("FindTablets(%q) failed: %v", pageToken, err)
+		}
+		got = append(got, tabletUids(tablets))
+		if nextPageToken == "" {
+			break
+		}
+		pageToken = nextPageToken
+	}
+	want := [][]uint32{{1, 4}, {3, 5}, nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindTablets pages = %v, want %v", got, want)
+	}
+
+	if _, _, err := FindTablets(ctx, ts, filter, "bad", 2); err == nil {
+		t.Errorf("FindTablets with an invalid page token worked")
+	}
+}
+
+func TestFindShards(t *testing.T) {
+	ctx := context.Background()
+	ts := listTestTablets()
+
+	shardNames := func(shards []*ShardInfo) []string {
+		var result []string
+		for _, si := range shards {
+			result = append(result, si.Keyspace()+"/"+si.ShardName())
+		}
+		return result
+	}
+
+	shards, nextPageToken, err := FindShards(ctx, ts, "", nil, "", 0)
+	if err != nil {
+		t.Fatalf("FindShards failed: %v", err)
+	}
+	if got, want := shardNames(shards), []string{"ks1/0", "ks2/-80", "ks2/80-"}; !reflect.DeepEqual(got, want) || nextPageToken != "" {
+		t.Errorf("FindShards = %v, %q, want %v", got, nextPageToken, want)
+	}
+
+	shards, _, err = FindShards(ctx, ts, "ks2", []string{"cell2"}, "", 0)
+	if err != nil {
+		t.Fatalf("FindShards(ks2, cell2) failed: %v", err)
+	}
+	if got, want := shardNames(shards), []string{"ks2/80-"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindShards(ks2, cell2) = %v, want %v", got, want)
+	}
+
+	shards, nextPageToken, err = FindShards(ctx, ts, "", nil, "", 2)
+	if err != nil {
+		t.Fatalf("FindShards first page failed: %v", err)
+	}
+	if got, want := shardNames(shards), []string{"ks1/0", "ks2/-80"}; !reflect.DeepEqual(got, want) || nextPageToken != "ks2/-80" {
+		t.Errorf("FindShards first page = %v, %q, want %v, %q", got, nextPageToken, want, "ks2/-80")
+	}
+	shards, nextPageToken, err = FindShards(ctx, ts, "", nil, nextPageToken, 2)
+	if err != nil {
+		t.Fatalf("FindShards second page failed: %v", err)
+	}
+	if got, want := shardNames(shards), []string{"ks2/80-"}; !reflect.DeepEqual(got, want) || nextPageToken != "" {
+		t.Errorf("FindShards second page = %v, %q, want %v", got, nextPageToken, want)
+	}
+}
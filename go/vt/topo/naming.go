@@ -22,7 +22,6 @@ import (
 	"fmt"
 	"net"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/netutil"
 )
 
@@ -40,6 +39,13 @@ type EndPoint struct {
 	Host         string            `json:"host"`
 	NamedPortMap map[string]int    `json:"named_port_map"`
 	Health       map[string]string `json:"health"`
+
+	// SchemaVersion is the schema version of the tablet, see
+	// Tablet.SchemaVersion.
+	SchemaVersion string `json:"schema_version,omitempty"`
+
+	// Tags are the tags of the tablet, see Tablet.Tags.
+	Tags map[string]string `json:"tags,omitempty"`
 }
 
 // EndPoints is a list of EndPoint objects, all of the same type.
@@ -84,6 +90,21 @@ func EndPointEquality(left, right *EndPoint) bool {
 			return false
 		}
 	}
+	if left.SchemaVersion != right.SchemaVersion {
+		return false
+	}
+	if len(left.Tags) != len(right.Tags) {
+		return false
+	}
+	for key, lvalue := range left.Tags {
+		rvalue, ok := right.Tags[key]
+		if !ok {
+			return false
+		}
+		if lvalue != rvalue {
+			return false
+		}
+	}
 	return true
 }
 
@@ -119,7 +140,7 @@ func SrvEntries(addrs *EndPoints, namedPort string) (srvs []*net.SRV, err error)
 		}
 		port = entry.NamedPortMap[namedPort]
 		if port == 0 {
-			log.Warningf("vtns: bad port %v %v", namedPort, entry)
+			mlog.Warningf("vtns: bad port %v %v", namedPort, entry)
 			continue
 		}
 		srvs = append(srvs, &net.SRV{Target: host, Port: uint16(port)})
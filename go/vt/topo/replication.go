@@ -5,7 +5,6 @@
 package topo
 
 import (
-	log "github.com/golang/glog"
 	"golang.org/x/net/context"
 
 	"github.com/youtube/vitess/go/trace"
@@ -87,7 +86,7 @@ func UpdateShardReplicationRecord(ctx context.Context, ts Server, keyspace, shar
 		for _, link := range sr.ReplicationLinks {
 			if link.TabletAlias == tabletAlias {
 				if found {
-					log.Warningf("Found a second ReplicationLink for tablet %v, deleting it", tabletAlias)
+					mlog.Warningf("Found a second ReplicationLink for tablet %v, deleting it", tabletAlias)
 					continue
 				}
 				found = true
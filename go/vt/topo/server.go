@@ -10,10 +10,16 @@ import (
 	"fmt"
 
 	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/vt/faultinject"
+	"github.com/youtube/vitess/go/vt/logutil"
 	"golang.org/x/net/context"
 )
 
 var (
+	// mlog is the logger of the package, its level can be changed
+	// at runtime (see /debug/loglevel).
+	mlog = logutil.NewModuleLogger("topo")
+
 	// ErrNodeExists is returned by functions to specify the
 	// requested resource already exists.
 	ErrNodeExists = errors.New("node already exists")
@@ -294,6 +300,17 @@ type Schemafier interface {
 	GetVSchema() (string, error)
 }
 
+// CellCreator is a temporary interface for the implementations that
+// can create the topology of a new cell. It will eventually be
+// merged into Server.
+type CellCreator interface {
+	// CreateCell creates the root of the topology of a cell.
+	// addrs are the addresses of the topology servers of the cell,
+	// for the implementations that keep them in the global
+	// topology. It returns ErrNodeExists if the cell exists.
+	CreateCell(cell string, addrs []string) error
+}
+
 // Registry for Server implementations.
 var serverImpls = make(map[string]Server)
 
@@ -320,11 +337,15 @@ func GetServerByName(name string) Server {
 // - If more than one are registered, use the 'topo_implementation' flag
 //   (which defaults to zookeeper).
 // - Then panics.
+//
+// The calls to the returned Server time out after -topo_timeout.
+// When faults are injected in the topology, its reads also fail or
+// are delayed at random.
 func GetServer() Server {
 	if len(serverImpls) == 1 {
 		for name, ts := range serverImpls {
 			log.V(6).Infof("Using only topo.Server: %v", name)
-			return ts
+			return withTimeout(withFaults(ts))
 		}
 	}
 
@@ -333,7 +354,15 @@ func GetServer() Server {
 		panic(fmt.Errorf("No topo.Server named %v", *topoImplementation))
 	}
 	log.V(6).Infof("Using topo.Server: %v", *topoImplementation)
-	return result
+	return withTimeout(withFaults(result))
+}
+
+// withFaults wraps ts in a faultyServer if needed.
+func withFaults(ts Server) Server {
+	if faultinject.Enabled(faultinject.Topo) {
+		return faultyServer{ts}
+	}
+	return ts
 }
 
 // CloseServers closes all registered Server.
@@ -10,10 +10,10 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 
-	log "github.com/golang/glog"
 
 	"github.com/youtube/vitess/go/trace"
 	"github.com/youtube/vitess/go/vt/concurrency"
@@ -151,6 +151,12 @@ type Shard struct {
 	// There can be only at most one master, but there may be none. (0)
 	MasterAlias TabletAlias
 
+	// MasterTerm is incremented every time a new master is set for
+	// the shard (see SetMaster). The master tablet is stamped with
+	// the term it was promoted in, so a master that missed a
+	// reparent can tell it is not the master anymore.
+	MasterTerm int64
+
 	// This must match the shard name based on our other conventions, but
 	// helpful to have it decomposed here.
 	KeyRange key.KeyRange
@@ -174,34 +180,134 @@ type Shard struct {
 	// TabletControlMap is a map of TabletControl to apply specific
 	// configurations to tablets by type.
 	TabletControlMap map[TabletType]*TabletControl
+
+	// ReadOnly is set while the shard is frozen (see
+	// wrangler.FreezeShard): its tablets refuse writes, and the
+	// MySQL of its master is read-only.
+	ReadOnly bool
+
+	// SplitShard is the checkpoint of the workflow splitting this
+	// shard (see worker.SplitShardWorker), nil if there is none.
+	SplitShard *SplitShardState
+
+	// Backups are the kept backups of the shard, oldest first (see
+	// janitor.BackupJanitor).
+	Backups []*ShardBackup
+}
+
+// SplitShardState is the progress of a shard split workflow. It is
+// saved in the source shard after each step, so an interrupted
+// workflow can resume where it stopped.
+type SplitShardState struct {
+	// Destinations are the names of the destination shards.
+	Destinations []string
+
+	// Step is the last step that completed.
+	Step string
+}
+
+// ShardBackup describes a backup of the shard: a snapshot stored on
+// one of its tablets. A tablet only holds its last snapshot, so the
+// shard only records the last backup of each tablet. ManifestPath
+// identifies the snapshot (see mysqlctl.Mysqld.CreateSnapshot).
+type ShardBackup struct {
+	Time         time.Time
+	Duration     time.Duration
+	TabletAlias  TabletAlias
+	ParentAlias  TabletAlias
+	ManifestPath string
 }
 
 func newShard() *Shard {
 	return &Shard{}
 }
 
+// SetMaster makes alias the master of the shard, and starts a new
+// master term. The promoted tablet should have been stamped with
+// the new term, i.e. the previous term + 1, under the same shard lock.
+func (shard *Shard) SetMaster(alias TabletAlias) {
+	shard.MasterAlias = alias
+	shard.MasterTerm++
+}
+
+// IsStaleMaster returns true if tablet is a master that was promoted
+// in an older master term than the current one of the shard: it
+// missed a reparent, and shouldn't accept writes anymore.
+// Masters that were never stamped with a term are not fenced.
+func (shard *Shard) IsStaleMaster(tablet *Tablet) bool {
+	return tablet.Type == TYPE_MASTER && tablet.MasterTerm != 0 && tablet.MasterTerm < shard.MasterTerm
+}
+
 // ValidateShardName takes a shard name and sanitizes it, and also returns
-// the KeyRange.
+// the KeyRange. See key.ParseShardName for the accepted names.
 func ValidateShardName(shard string) (string, key.KeyRange, error) {
+	keyRange, err := key.ParseShardName(shard)
+	if err != nil {
+		return "", key.KeyRange{}, err
+	}
 	if !strings.Contains(shard, "-") {
-		return shard, key.KeyRange{}, nil
+		return shard, keyRange, nil
 	}
+	return keyRange.ShardName(), keyRange, nil
+}
 
-	parts := strings.Split(shard, "-")
-	if len(parts) != 2 {
-		return "", key.KeyRange{}, fmt.Errorf("invalid shardId, can only contain one '-': %v", shard)
+// ValidateShardPartition returns an error if the shards don't cover
+// the full keyrange exactly once. It reports all the invalid names,
+// gaps and overlaps at once.
+func ValidateShardPartition(shards []string) error {
+	if len(shards) == 0 {
+		return fmt.Errorf("partition has no shard")
+	}
+	var problems []string
+	refs := make(ShardReferenceArray, 0, len(shards))
+	for _, shard := range shards {
+		_, keyRange, err := ValidateShardName(shard)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid shard %v: %v", shard, err))
+			continue
+		}
+		refs = append(refs, ShardReference{Name: shard, KeyRange: keyRange})
+	}
+	if len(refs) > 0 {
+		problems = append(problems, checkShardReferencesCoverage(refs)...)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%v", strings.Join(problems, ", "))
 	}
+	return nil
+}
 
-	keyRange, err := key.ParseKeyRangeParts(parts[0], parts[1])
+// ValidateShardSplit returns an error if the destination shards don't
+// cover the key range of the source shard exactly once.
+func ValidateShardSplit(source string, destinations []string) error {
+	_, sourceRange, err := ValidateShardName(source)
 	if err != nil {
-		return "", key.KeyRange{}, err
+		return fmt.Errorf("invalid shard %v: %v", source, err)
 	}
-
-	if keyRange.End != key.MaxKey && keyRange.Start >= keyRange.End {
-		return "", key.KeyRange{}, fmt.Errorf("out of order keys: %v is not strictly smaller than %v", keyRange.Start.Hex(), keyRange.End.Hex())
+	if len(destinations) < 2 {
+		return fmt.Errorf("shard %v must be split into at least two shards", source)
 	}
-
-	return strings.ToLower(shard), keyRange, nil
+	refs := make(ShardReferenceArray, len(destinations))
+	for i, shard := range destinations {
+		_, keyRange, err := ValidateShardName(shard)
+		if err != nil {
+			return fmt.Errorf("invalid shard %v: %v", shard, err)
+		}
+		refs[i] = ShardReference{Name: shard, KeyRange: keyRange}
+	}
+	refs.Sort()
+	if refs[0].KeyRange.Start != sourceRange.Start {
+		return fmt.Errorf("shard %v doesn't start at the start of shard %v", refs[0].Name, source)
+	}
+	for i := 1; i < len(refs); i++ {
+		if refs[i-1].KeyRange.End == key.MaxKey || refs[i].KeyRange.Start != refs[i-1].KeyRange.End {
+			return fmt.Errorf("shard %v doesn't start at the end of shard %v", refs[i].Name, refs[i-1].Name)
+		}
+	}
+	if last := refs[len(refs)-1]; last.KeyRange.End != sourceRange.End {
+		return fmt.Errorf("shard %v doesn't end at the end of shard %v", last.Name, source)
+	}
+	return nil
 }
 
 // HasCell returns true if the cell is listed in the Cells for the shard.
@@ -358,7 +464,7 @@ func (si *ShardInfo) UpdateSourceBlacklistedTables(tabletType TabletType, cells
 			}
 			// we try to remove from something that doesn't exist,
 			// log, but we're done.
-			log.Warningf("Trying to remove TabletControl.BlacklistedTables for missing type %v in shard %v/%v", tabletType, si.keyspace, si.shardName)
+			mlog.Warningf("Trying to remove TabletControl.BlacklistedTables for missing type %v in shard %v/%v", tabletType, si.keyspace, si.shardName)
 			return nil
 		}
 
@@ -411,7 +517,7 @@ func (si *ShardInfo) UpdateDisableQueryService(tabletType TabletType, cells []st
 			if len(si.TabletControlMap) == 0 {
 				si.TabletControlMap = nil
 			}
-			log.Warningf("Trying to remove TabletControl.DisableQueryService for missing type: %v", tabletType)
+			mlog.Warningf("Trying to remove TabletControl.DisableQueryService for missing type: %v", tabletType)
 		}
 		return nil
 	}
@@ -496,7 +602,7 @@ func (si *ShardInfo) UpdateServedTypesMap(tabletType TabletType, cells []string,
 			if len(si.ServedTypesMap) == 0 {
 				si.ServedTypesMap = nil
 			}
-			log.Warningf("Trying to remove ShardServedType for missing type %v in shard %v/%v", tabletType, si.keyspace, si.shardName)
+			mlog.Warningf("Trying to remove ShardServedType for missing type %v in shard %v/%v", tabletType, si.keyspace, si.shardName)
 		} else {
 			si.ServedTypesMap[tabletType] = &ShardServedType{
 				Cells: cells,
@@ -601,7 +707,7 @@ func FindAllTabletAliasesInShardByCell(ctx context.Context, ts Server, keyspace,
 	wg.Wait()
 	err = nil
 	if rec.HasErrors() {
-		log.Warningf("FindAllTabletAliasesInShard(%v,%v): got partial result: %v", keyspace, shard, rec.Error())
+		mlog.Warningf("FindAllTabletAliasesInShard(%v,%v): got partial result: %v", keyspace, shard, rec.Error())
 		err = ErrPartialResult
 	}
 
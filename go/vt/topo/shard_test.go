@@ -82,6 +82,73 @@ func TestParseKeyspaceShardString(t *testing.T) {
 	}
 }
 
+func TestValidateShardName(t *testing.T) {
+	table := map[string]string{
+		"0":     "0",
+		"-80":   "-80",
+		"80-C0": "80-c0",
+	}
+	for shard, wanted := range table {
+		name, _, err := ValidateShardName(shard)
+		if err != nil || name != wanted {
+			t.Errorf("ValidateShardName(%v) = (%v, %v), want %v", shard, name, err, wanted)
+		}
+	}
+	if _, _, err := ValidateShardName("80-40"); err == nil {
+		t.Errorf("ValidateShardName(80-40) didn't fail")
+	}
+}
+
+func TestValidateShardPartition(t *testing.T) {
+	table := []struct {
+		shards []string
+		err    string
+	}{
+		{[]string{"0"}, ""},
+		{[]string{"c0-", "-80", "80-c0"}, ""},
+		{nil, "partition has no shard"},
+		{[]string{"-80", "c0-"}, "gap between shards -80 and c0-: [80-c0]"},
+		{[]string{"0", "-80", "80-"}, "shards 0 and -80 overlap, shards 0 and 80- overlap"},
+		{[]string{"-80", "80-40"}, "invalid shard 80-40: out of order keys: 80 is not strictly smaller than 40, gap after shard -80: [80-]"},
+	}
+	for _, tc := range table {
+		err := ValidateShardPartition(tc.shards)
+		if tc.err == "" {
+			if err != nil {
+				t.Errorf("ValidateShardPartition(%v) returned %v", tc.shards, err)
+			}
+		} else if err == nil || err.Error() != tc.err {
+			t.Errorf("ValidateShardPartition(%v) returned %v, expected %v", tc.shards, err, tc.err)
+		}
+	}
+}
+
+func TestValidateShardSplit(t *testing.T) {
+	table := []struct {
+		source       string
+		destinations []string
+		err          string
+	}{
+		{"0", []string{"-80", "80-"}, ""},
+		{"40-80", []string{"60-80", "40-60"}, ""},
+		{"0", []string{"-80"}, "shard 0 must be split into at least two shards"},
+		{"-80", []string{"-40", "40-c0"}, "shard 40-c0 doesn't end at the end of shard -80"},
+		{"40-80", []string{"-60", "60-80"}, "shard -60 doesn't start at the start of shard 40-80"},
+		{"0", []string{"-40", "80-"}, "shard 80- doesn't start at the end of shard -40"},
+		{"0", []string{"-80", "-c0", "80-"}, "shard -c0 doesn't start at the end of shard -80"},
+	}
+	for _, tc := range table {
+		err := ValidateShardSplit(tc.source, tc.destinations)
+		if tc.err == "" {
+			if err != nil {
+				t.Errorf("ValidateShardSplit(%v, %v) returned %v", tc.source, tc.destinations, err)
+			}
+		} else if err == nil || err.Error() != tc.err {
+			t.Errorf("ValidateShardSplit(%v, %v) returned %v, expected %v", tc.source, tc.destinations, err, tc.err)
+		}
+	}
+}
+
 func TestUpdateSourceBlacklistedTables(t *testing.T) {
 	si := NewShardInfo("ks", "sh", &Shard{
 		Cells: []string{"first", "second", "third"},
@@ -306,3 +373,42 @@ func TestUpdateServedTypesMap(t *testing.T) {
 		t.Fatalf("expected empty map after removing all")
 	}
 }
+
+func TestMasterTerm(t *testing.T) {
+	shard := newShard()
+	oldMaster := &Tablet{Alias: TabletAlias{Cell: "cell1", Uid: 1}, Type: TYPE_MASTER}
+	newMaster := &Tablet{Alias: TabletAlias{Cell: "cell1", Uid: 2}, Type: TYPE_REPLICA}
+
+	// promote the old master
+	oldMaster.MasterTerm = shard.MasterTerm + 1
+	shard.SetMaster(oldMaster.Alias)
+	if shard.MasterAlias != oldMaster.Alias || shard.MasterTerm != 1 {
+		t.Errorf("SetMaster(%v) failed: %v", oldMaster.Alias, shard)
+	}
+	if shard.IsStaleMaster(oldMaster) {
+		t.Errorf("current master is stale")
+	}
+
+	// reparent to the new master, the old master missed it
+	newMaster.Type = TYPE_MASTER
+	newMaster.MasterTerm = shard.MasterTerm + 1
+	shard.SetMaster(newMaster.Alias)
+	if shard.MasterTerm != 2 {
+		t.Errorf("SetMaster(%v) didn't start a new term: %v", newMaster.Alias, shard)
+	}
+	if !shard.IsStaleMaster(oldMaster) {
+		t.Errorf("old master is not stale")
+	}
+	if shard.IsStaleMaster(newMaster) {
+		t.Errorf("new master is stale")
+	}
+
+	// only masters with a term are fenced
+	oldMaster.Type = TYPE_SPARE
+	if shard.IsStaleMaster(oldMaster) {
+		t.Errorf("spare is stale")
+	}
+	if shard.IsStaleMaster(&Tablet{Type: TYPE_MASTER}) {
+		t.Errorf("master without a term is stale")
+	}
+}
@@ -44,12 +44,22 @@ func (srvKeyspace *SrvKeyspace) MarshalBson(buf *bytes2.ChunkedWriter, key strin
 	}
 	bson.EncodeString(buf, "ShardingColumnName", srvKeyspace.ShardingColumnName)
 	srvKeyspace.ShardingColumnType.MarshalBson(buf, "ShardingColumnType")
+	bson.EncodeString(buf, "ShardingScheme", srvKeyspace.ShardingScheme)
+	// map[string]string
+	{
+		bson.EncodePrefix(buf, bson.Object, "ShardingSchemeParams")
+		lenWriter := bson.NewLenWriter(buf)
+		for _k, _v3 := range srvKeyspace.ShardingSchemeParams {
+			bson.EncodeString(buf, _k, _v3)
+		}
+		lenWriter.Close()
+	}
 	// map[TabletType]string
 	{
 		bson.EncodePrefix(buf, bson.Object, "ServedFrom")
 		lenWriter := bson.NewLenWriter(buf)
-		for _k, _v3 := range srvKeyspace.ServedFrom {
-			bson.EncodeString(buf, string(_k), _v3)
+		for _k, _v4 := range srvKeyspace.ServedFrom {
+			bson.EncodeString(buf, string(_k), _v4)
 		}
 		lenWriter.Close()
 	}
@@ -110,6 +120,23 @@ func (srvKeyspace *SrvKeyspace) UnmarshalBson(buf *bytes.Buffer, kind byte) {
 			srvKeyspace.ShardingColumnName = bson.DecodeString(buf, kind)
 		case "ShardingColumnType":
 			srvKeyspace.ShardingColumnType.UnmarshalBson(buf, kind)
+		case "ShardingScheme":
+			srvKeyspace.ShardingScheme = bson.DecodeString(buf, kind)
+		case "ShardingSchemeParams":
+			// map[string]string
+			if kind != bson.Null {
+				if kind != bson.Object {
+					panic(bson.NewBsonError("unexpected kind %v for srvKeyspace.ShardingSchemeParams", kind))
+				}
+				bson.Next(buf, 4)
+				srvKeyspace.ShardingSchemeParams = make(map[string]string)
+				for kind := bson.NextByte(buf); kind != bson.EOO; kind = bson.NextByte(buf) {
+					_k := bson.ReadCString(buf)
+					var _v3 string
+					_v3 = bson.DecodeString(buf, kind)
+					srvKeyspace.ShardingSchemeParams[_k] = _v3
+				}
+			}
 		case "ServedFrom":
 			// map[TabletType]string
 			if kind != bson.Null {
@@ -120,9 +147,9 @@ func (srvKeyspace *SrvKeyspace) UnmarshalBson(buf *bytes.Buffer, kind byte) {
 				srvKeyspace.ServedFrom = make(map[TabletType]string)
 				for kind := bson.NextByte(buf); kind != bson.EOO; kind = bson.NextByte(buf) {
 					_k := TabletType(bson.ReadCString(buf))
-					var _v3 string
-					_v3 = bson.DecodeString(buf, kind)
-					srvKeyspace.ServedFrom[_k] = _v3
+					var _v4 string
+					_v4 = bson.DecodeString(buf, kind)
+					srvKeyspace.ServedFrom[_k] = _v4
 				}
 			}
 		case "SplitShardCount":
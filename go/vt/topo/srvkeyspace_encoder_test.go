@@ -0,0 +1,161 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package topo
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/youtube/vitess/go/bson"
+	"github.com/youtube/vitess/go/bytes2"
+	"github.com/youtube/vitess/go/vt/key"
+)
+
+// The encode* functions are what the generated marshalers of
+// srvkeyspace_bson.go and its dependencies do, with a bson.Encoder.
+
+func encodeSrvKeyspace(enc *bson.Encoder, key string, srvKeyspace *SrvKeyspace) {
+	enc.BeginObject(key)
+	enc.BeginObject("Partitions")
+	for _k, _v1 := range srvKeyspace.Partitions {
+		if _v1 == nil {
+			enc.Null(string(_k))
+		} else {
+			encodeKeyspacePartition(enc, string(_k), _v1)
+		}
+	}
+	enc.End()
+	encodeTabletTypes(enc, "TabletTypes", srvKeyspace.TabletTypes)
+	enc.String("ShardingColumnName", srvKeyspace.ShardingColumnName)
+	enc.String("ShardingColumnType", string(srvKeyspace.ShardingColumnType))
+	enc.String("ShardingScheme", srvKeyspace.ShardingScheme)
+	enc.BeginObject("ShardingSchemeParams")
+	for _k, _v2 := range srvKeyspace.ShardingSchemeParams {
+		enc.String(_k, _v2)
+	}
+	enc.End()
+	enc.BeginObject("ServedFrom")
+	for _k, _v3 := range srvKeyspace.ServedFrom {
+		enc.String(string(_k), _v3)
+	}
+	enc.End()
+	enc.Int32("SplitShardCount", srvKeyspace.SplitShardCount)
+	enc.End()
+}
+
+func encodeKeyspacePartition(enc *bson.Encoder, key string, keyspacePartition *KeyspacePartition) {
+	enc.BeginObject(key)
+	enc.BeginArray("Shards")
+	for _i := range keyspacePartition.Shards {
+		encodeSrvShard(enc, bson.Itoa(_i), &keyspacePartition.Shards[_i])
+	}
+	enc.End()
+	enc.BeginArray("ShardReferences")
+	for _i, _v1 := range keyspacePartition.ShardReferences {
+		enc.BeginObject(bson.Itoa(_i))
+		enc.String("Name", _v1.Name)
+		encodeKeyRange(enc, "KeyRange", _v1.KeyRange)
+		enc.End()
+	}
+	enc.End()
+	enc.End()
+}
+
+func encodeSrvShard(enc *bson.Encoder, key string, srvShard *SrvShard) {
+	enc.BeginObject(key)
+	enc.String("Name", srvShard.Name)
+	encodeKeyRange(enc, "KeyRange", srvShard.KeyRange)
+	encodeTabletTypes(enc, "ServedTypes", srvShard.ServedTypes)
+	enc.String("MasterCell", srvShard.MasterCell)
+	encodeTabletTypes(enc, "TabletTypes", srvShard.TabletTypes)
+	enc.End()
+}
+
+func encodeKeyRange(enc *bson.Encoder, key string, keyRange key.KeyRange) {
+	enc.BeginObject(key)
+	enc.String("Start", string(keyRange.Start))
+	enc.String("End", string(keyRange.End))
+	enc.End()
+}
+
+func encodeTabletTypes(enc *bson.Encoder, key string, tabletTypes []TabletType) {
+	enc.BeginArray(key)
+	for _i, _v1 := range tabletTypes {
+		enc.String(bson.Itoa(_i), string(_v1))
+	}
+	enc.End()
+}
+
+// benchmarkSrvKeyspace returns a SrvKeyspace with 64 shards, served
+// for 3 tablet types.
+func benchmarkSrvKeyspace() *SrvKeyspace {
+	tabletTypes := []TabletType{TYPE_MASTER, TYPE_REPLICA, TYPE_RDONLY}
+	kp := &KeyspacePartition{}
+	for i := 0; i < 64; i++ {
+		kr := key.KeyRange{Start: key.KeyspaceId([]byte{byte(i * 4)})}
+		if i < 63 {
+			kr.End = key.KeyspaceId([]byte{byte(i*4 + 4)})
+		}
+		name := fmt.Sprintf("%v", kr)
+		kp.Shards = append(kp.Shards, SrvShard{
+			Name:        name,
+			KeyRange:    kr,
+			ServedTypes: tabletTypes,
+			MasterCell:  "test_cell",
+			TabletTypes: tabletTypes,
+		})
+		kp.ShardReferences = append(kp.ShardReferences, ShardReference{Name: name, KeyRange: kr})
+	}
+	srvKeyspace := &SrvKeyspace{
+		Partitions:           make(map[TabletType]*KeyspacePartition),
+		TabletTypes:          tabletTypes,
+		ShardingColumnName:   "user_id",
+		ShardingColumnType:   key.KIT_UINT64,
+		ShardingScheme:       "lookup_hash_unique",
+		ShardingSchemeParams: map[string]string{"Table": "user_idx", "From": "name", "To": "user_id"},
+		ServedFrom:           map[TabletType]string{TYPE_BATCH: "other_keyspace"},
+	}
+	for _, tabletType := range tabletTypes {
+		srvKeyspace.Partitions[tabletType] = kp
+	}
+	return srvKeyspace
+}
+
+func TestSrvKeyspaceEncoder(t *testing.T) {
+	want := benchmarkSrvKeyspace()
+	enc := bson.NewEncoder(bson.DefaultBufferSize)
+	encodeSrvKeyspace(enc, "", want)
+
+	var got SrvKeyspace
+	if err := bson.Unmarshal(enc.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("got\n%#v, want\n%#v", got, want)
+	}
+}
+
+func BenchmarkSrvKeyspaceMarshalBson(b *testing.B) {
+	b.ReportAllocs()
+	srvKeyspace := benchmarkSrvKeyspace()
+	buf := bytes2.NewChunkedWriter(bson.DefaultBufferSize)
+	for i := 0; i < b.N; i++ {
+		srvKeyspace.MarshalBson(buf, "")
+		b.SetBytes(int64(buf.Len()))
+		buf.Reset()
+	}
+}
+
+func BenchmarkSrvKeyspaceEncoder(b *testing.B) {
+	b.ReportAllocs()
+	srvKeyspace := benchmarkSrvKeyspace()
+	enc := bson.NewEncoder(bson.DefaultBufferSize)
+	for i := 0; i < b.N; i++ {
+		encodeSrvKeyspace(enc, "", srvKeyspace)
+		b.SetBytes(int64(len(enc.Bytes())))
+		enc.Reset()
+	}
+}
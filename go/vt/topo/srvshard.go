@@ -5,7 +5,9 @@
 package topo
 
 import (
+	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/youtube/vitess/go/vt/key"
 )
@@ -33,6 +35,10 @@ type SrvShard struct {
 	// for, in this cell only.
 	TabletTypes []TabletType
 
+	// ReadOnly is copied from Shard, it is true while the shard
+	// is frozen and refuses writes.
+	ReadOnly bool
+
 	// For atomic updates
 	version int64
 }
@@ -117,6 +123,68 @@ func (kp *KeyspacePartition) HasShard(name string) bool {
 	return false
 }
 
+// CheckCoverage returns an error if the ShardReferences of the
+// partition don't cover the full keyrange exactly once, or if they
+// don't match the Shards. It reports all the gaps and overlaps at
+// once.
+func (kp *KeyspacePartition) CheckCoverage() error {
+	if len(kp.ShardReferences) == 0 {
+		return fmt.Errorf("partition has no shard")
+	}
+	problems := checkShardReferencesCoverage(kp.ShardReferences)
+
+	// the Shards and ShardReferences lists should match
+	names := make(map[string]bool, len(kp.ShardReferences))
+	for _, ref := range kp.ShardReferences {
+		names[ref.Name] = true
+	}
+	for _, srvShard := range kp.Shards {
+		if !names[srvShard.Name] {
+			problems = append(problems, fmt.Sprintf("shard %v is not in the shard references", srvShard.Name))
+		}
+		delete(names, srvShard.Name)
+	}
+	for name := range names {
+		problems = append(problems, fmt.Sprintf("shard reference %v is not in the shards", name))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%v", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// checkShardReferencesCoverage returns the gaps and overlaps of a non
+// empty list of shard references.
+func checkShardReferencesCoverage(shardReferences []ShardReference) []string {
+	refs := make(ShardReferenceArray, len(shardReferences))
+	copy(refs, shardReferences)
+	refs.Sort()
+
+	var problems []string
+	if refs[0].KeyRange.Start != key.MinKey {
+		problems = append(problems, fmt.Sprintf("gap before shard %v: [%v-%v]", refs[0].Name, key.MinKey.Hex(), refs[0].KeyRange.Start.Hex()))
+	}
+
+	// last is the shard that ends the furthest so far
+	last := refs[0]
+	for _, ref := range refs[1:] {
+		switch {
+		case last.KeyRange.End == key.MaxKey || ref.KeyRange.Start < last.KeyRange.End:
+			problems = append(problems, fmt.Sprintf("shards %v and %v overlap", last.Name, ref.Name))
+		case ref.KeyRange.Start > last.KeyRange.End:
+			problems = append(problems, fmt.Sprintf("gap between shards %v and %v: [%v-%v]", last.Name, ref.Name, last.KeyRange.End.Hex(), ref.KeyRange.Start.Hex()))
+		}
+		if last.KeyRange.End != key.MaxKey && (ref.KeyRange.End == key.MaxKey || ref.KeyRange.End > last.KeyRange.End) {
+			last = ref
+		}
+	}
+	if last.KeyRange.End != key.MaxKey {
+		problems = append(problems, fmt.Sprintf("gap after shard %v: [%v-%v]", last.Name, last.KeyRange.End.Hex(), key.MaxKey.Hex()))
+	}
+	return problems
+}
+
 // SrvKeyspace is a distilled serving copy of keyspace detail stored
 // in the local cell for fast access. Derived from the global
 // keyspace, shards and local details.
@@ -135,10 +203,12 @@ type SrvKeyspace struct {
 	TabletTypes []TabletType
 
 	// Copied from Keyspace
-	ShardingColumnName string
-	ShardingColumnType key.KeyspaceIdType
-	ServedFrom         map[TabletType]string
-	SplitShardCount    int32
+	ShardingColumnName   string
+	ShardingColumnType   key.KeyspaceIdType
+	ShardingScheme       string
+	ShardingSchemeParams map[string]string
+	ServedFrom           map[TabletType]string
+	SplitShardCount      int32
 
 	// For atomic updates
 	version int64
@@ -40,6 +40,7 @@ func (srvShard *SrvShard) MarshalBson(buf *bytes2.ChunkedWriter, key string) {
 		}
 		lenWriter.Close()
 	}
+	bson.EncodeBool(buf, "ReadOnly", srvShard.ReadOnly)
 
 	lenWriter.Close()
 }
@@ -94,6 +95,8 @@ func (srvShard *SrvShard) UnmarshalBson(buf *bytes.Buffer, kind byte) {
 					srvShard.TabletTypes = append(srvShard.TabletTypes, _v2)
 				}
 			}
+		case "ReadOnly":
+			srvShard.ReadOnly = bson.DecodeBool(buf, kind)
 		default:
 			bson.Skip(buf, kind)
 		}
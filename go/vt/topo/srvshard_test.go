@@ -13,13 +13,15 @@ import (
 )
 
 type reflectSrvKeyspace struct {
-	Partitions         map[string]*KeyspacePartition
-	TabletTypes        []TabletType
-	ShardingColumnName string
-	ShardingColumnType key.KeyspaceIdType
-	ServedFrom         map[string]string
-	SplitShardCount    int32
-	version            int64
+	Partitions           map[string]*KeyspacePartition
+	TabletTypes          []TabletType
+	ShardingColumnName   string
+	ShardingColumnType   key.KeyspaceIdType
+	ShardingScheme       string
+	ShardingSchemeParams map[string]string
+	ServedFrom           map[string]string
+	SplitShardCount      int32
+	version              int64
 }
 
 type extraSrvKeyspace struct {
@@ -53,6 +55,10 @@ func TestSrvKeySpace(t *testing.T) {
 		TabletTypes:        []TabletType{TYPE_MASTER},
 		ShardingColumnName: "video_id",
 		ShardingColumnType: key.KIT_UINT64,
+		ShardingScheme:     "lookup_hash_unique",
+		ShardingSchemeParams: map[string]string{
+			"Table": "video_idx",
+		},
 		ServedFrom: map[string]string{
 			string(TYPE_REPLICA): "other_keyspace",
 		},
@@ -84,6 +90,10 @@ func TestSrvKeySpace(t *testing.T) {
 		TabletTypes:        []TabletType{TYPE_MASTER},
 		ShardingColumnName: "video_id",
 		ShardingColumnType: key.KIT_UINT64,
+		ShardingScheme:     "lookup_hash_unique",
+		ShardingSchemeParams: map[string]string{
+			"Table": "video_idx",
+		},
 		ServedFrom: map[TabletType]string{
 			TYPE_REPLICA: "other_keyspace",
 		},
@@ -117,3 +127,52 @@ func TestSrvKeySpace(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestCheckCoverage(t *testing.T) {
+	table := []struct {
+		shards []string
+		err    string
+	}{
+		{[]string{"-"}, ""},
+		{[]string{"-80", "80-"}, ""},
+		{[]string{"80-", "-40", "40-80"}, ""},
+		{nil, "partition has no shard"},
+		{[]string{"-80"}, "gap after shard -80: [80-]"},
+		{[]string{"40-"}, "gap before shard 40-: [-40]"},
+		{[]string{"-40", "80-"}, "gap between shards -40 and 80-: [40-80]"},
+		{[]string{"-", "-80", "80-"}, "shards - and -80 overlap, shards - and 80- overlap"},
+		{[]string{"-80", "40-c0", "c0-"}, "shards -80 and 40-c0 overlap"},
+	}
+	for _, tc := range table {
+		kp := &KeyspacePartition{}
+		for _, shard := range tc.shards {
+			_, kr, err := ValidateShardName(shard)
+			if err != nil {
+				t.Fatalf("ValidateShardName(%v) failed: %v", shard, err)
+			}
+			kp.Shards = append(kp.Shards, SrvShard{Name: shard, KeyRange: kr})
+			kp.ShardReferences = append(kp.ShardReferences, ShardReference{Name: shard, KeyRange: kr})
+		}
+		err := kp.CheckCoverage()
+		if tc.err == "" {
+			if err != nil {
+				t.Errorf("CheckCoverage(%v) returned %v", tc.shards, err)
+			}
+		} else if err == nil || err.Error() != tc.err {
+			t.Errorf("CheckCoverage(%v) returned %v, expected %v", tc.shards, err, tc.err)
+		}
+	}
+
+	// Shards and ShardReferences have to match
+	kp := &KeyspacePartition{
+		Shards: []SrvShard{
+			SrvShard{Name: "0"},
+		},
+		ShardReferences: []ShardReference{
+			ShardReference{Name: "-"},
+		},
+	}
+	if err := kp.CheckCoverage(); err == nil || err.Error() != "shard 0 is not in the shard references, shard reference - is not in the shards" {
+		t.Errorf("CheckCoverage with mismatched shards returned %v", err)
+	}
+}
@@ -5,16 +5,17 @@
 package topo
 
 import (
+	"flag"
 	"fmt"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 
-	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/jscfg"
 	"github.com/youtube/vitess/go/netutil"
 	"github.com/youtube/vitess/go/trace"
@@ -38,8 +39,26 @@ const (
 	// ReplicationLagHigh is the value in the health map to indicate high
 	// replication lag
 	ReplicationLagHigh = "high"
+
+	// ReplicationLagSeconds is the key in the health map of the
+	// replication lag of a slave, in seconds, rounded up. It is only
+	// published by the tablets running with
+	// -publish_replication_lag_granularity.
+	ReplicationLagSeconds = "replication_lag_seconds"
 )
 
+var tabletHeartbeatTTL = flag.Duration("tablet_heartbeat_ttl", time.Minute, "tablets whose last heartbeat is older than this are stale: they are reported by validation and excluded from the serving graph rebuilds. Tablets that don't heartbeat are never stale. 0 disables the check.")
+
+// TabletHeartbeatTTL returns the value of -tablet_heartbeat_ttl.
+func TabletHeartbeatTTL() time.Duration {
+	return *tabletHeartbeatTTL
+}
+
+// SetTabletHeartbeatTTL overrides -tablet_heartbeat_ttl in this process.
+func SetTabletHeartbeatTTL(ttl time.Duration) {
+	*tabletHeartbeatTTL = ttl
+}
+
 // TabletAlias is the minimum required information to locate a tablet.
 //
 // Tablets are really globally unique, but crawling every cell to find
@@ -284,6 +303,26 @@ func IsValidTypeChange(oldTabletType, newTabletType TabletType) bool {
 	return true
 }
 
+// CheckTypeChange returns an error if a tablet cannot go from
+// oldTabletType to newTabletType without a specialized action.
+// The allowed transitions are:
+// - between any two slave types except RESTORE (replica, rdonly,
+//   spare, lag, backup, ...).
+// - scrap -> idle.
+// - restore -> spare, restore -> idle.
+// Other transitions (like idle -> restore, or anything to master)
+// go through dedicated actions that also maintain the replication
+// graph, and cannot be done with a simple type change.
+func CheckTypeChange(oldTabletType, newTabletType TabletType) error {
+	if !IsTrivialTypeChange(oldTabletType, newTabletType) {
+		return fmt.Errorf("tablet type change %v -> %v requires changes to the replication graph", oldTabletType, newTabletType)
+	}
+	if !IsValidTypeChange(oldTabletType, newTabletType) {
+		return fmt.Errorf("tablet type change %v -> %v is not allowed", oldTabletType, newTabletType)
+	}
+	return nil
+}
+
 // IsInServingGraph returns if a tablet appears in the serving graph
 func IsInServingGraph(tt TabletType) bool {
 	switch tt {
@@ -352,7 +391,9 @@ type Tablet struct {
 	// mysql.
 	Portmap map[string]int
 
-	// Tags contain freeform information about the tablet.
+	// Tags contain freeform information about the tablet, like
+	// its rack, machine type, or maintenance window. They are
+	// published in the serving graph, see EndPoint.Tags.
 	Tags map[string]string
 
 	// Health tracks how healthy the tablet is. Clients may decide
@@ -370,6 +411,21 @@ type Tablet struct {
 	// hard to rename.
 	DbNameOverride string
 	KeyRange       key.KeyRange
+
+	// LastHeartbeat is the time (in seconds since the epoch) the
+	// tablet last refreshed its record. It is 0 for tablets that
+	// don't heartbeat.
+	LastHeartbeat int64
+
+	// MasterTerm is the master term of the shard this tablet was
+	// last promoted to master in (see Shard.MasterTerm). It is 0
+	// for tablets that were never stamped.
+	MasterTerm int64
+
+	// SchemaVersion is the checksum of the CREATE TABLE statements
+	// of the tablet database, as computed by its health check (see
+	// SchemaDefinition.Version). It is empty if unknown.
+	SchemaVersion string
 }
 
 // ValidatePortmap returns an error if the tablet's portmap doesn't
@@ -401,9 +457,27 @@ func (tablet *Tablet) EndPoint() (*EndPoint, error) {
 			entry.Health[k] = v
 		}
 	}
+	entry.SchemaVersion = tablet.SchemaVersion
+	if len(tablet.Tags) > 0 {
+		entry.Tags = make(map[string]string, len(tablet.Tags))
+		for k, v := range tablet.Tags {
+			entry.Tags[k] = v
+		}
+	}
 	return entry, nil
 }
 
+// MatchTags returns true if tags has all the key/value pairs of
+// want. Any tags match an empty want.
+func MatchTags(tags, want map[string]string) bool {
+	for k, v := range want {
+		if value, ok := tags[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
 // Addr returns hostname:vt port.
 func (tablet *Tablet) Addr() string {
 	return netutil.JoinHostPort(tablet.Hostname, tablet.Portmap["vt"])
@@ -452,6 +526,15 @@ func (tablet *Tablet) IsSlaveType() bool {
 	return IsSlaveType(tablet.Type)
 }
 
+// IsStale returns true if the tablet heartbeats, and its last
+// heartbeat is older than -tablet_heartbeat_ttl.
+func (tablet *Tablet) IsStale() bool {
+	if *tabletHeartbeatTTL == 0 || tablet.LastHeartbeat == 0 {
+		return false
+	}
+	return time.Now().Sub(time.Unix(tablet.LastHeartbeat, 0)) > *tabletHeartbeatTTL
+}
+
 // IsAssigned returns if this tablet ever assigned data? A "scrap" node will
 // show up as assigned even though its data cannot be used for serving.
 func (tablet *Tablet) IsAssigned() bool {
@@ -554,6 +637,11 @@ func Validate(ts Server, tabletAlias TabletAlias) error {
 		return err
 	}
 
+	// make sure it is still alive, if it heartbeats
+	if tablet.IsStale() {
+		return fmt.Errorf("tablet %v is stale, last heartbeat at %v", tabletAlias, time.Unix(tablet.LastHeartbeat, 0))
+	}
+
 	// Some tablets have no information to generate valid replication paths.
 	// We have three cases to handle:
 	// - we are a tablet in the replication graph, and should have
@@ -647,7 +735,7 @@ func GetTabletMap(ctx context.Context, ts Server, tabletAliases []TabletAlias) (
 			tabletInfo, err := ts.GetTablet(tabletAlias)
 			mutex.Lock()
 			if err != nil {
-				log.Warningf("%v: %v", tabletAlias, err)
+				mlog.Warningf("%v: %v", tabletAlias, err)
 				// There can be data races removing nodes - ignore them for now.
 				if err != ErrNoNode {
 					someError = ErrPartialResult
@@ -0,0 +1,87 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package topo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckTypeChange(t *testing.T) {
+	table := []struct {
+		oldType TabletType
+		newType TabletType
+		ok      bool
+	}{
+		{TYPE_REPLICA, TYPE_SPARE, true},
+		{TYPE_SPARE, TYPE_REPLICA, true},
+		{TYPE_RDONLY, TYPE_BACKUP, true},
+		{TYPE_SCRAP, TYPE_IDLE, true},
+		{TYPE_RESTORE, TYPE_SPARE, true},
+		{TYPE_IDLE, TYPE_REPLICA, false},
+		{TYPE_REPLICA, TYPE_MASTER, false},
+		{TYPE_MASTER, TYPE_REPLICA, false},
+		{TYPE_SCRAP, TYPE_REPLICA, false},
+		{TYPE_SNAPSHOT_SOURCE, TYPE_BACKUP, false},
+	}
+	for _, tc := range table {
+		err := CheckTypeChange(tc.oldType, tc.newType)
+		if (err == nil) != tc.ok {
+			t.Errorf("CheckTypeChange(%v, %v) returned %v, expected ok=%v", tc.oldType, tc.newType, err, tc.ok)
+		}
+	}
+}
+
+func TestIsStale(t *testing.T) {
+	defer SetTabletHeartbeatTTL(TabletHeartbeatTTL())
+	SetTabletHeartbeatTTL(time.Minute)
+
+	now := time.Now()
+	table := []struct {
+		lastHeartbeat int64
+		stale         bool
+	}{
+		{0, false},
+		{now.Unix(), false},
+		{now.Add(-30 * time.Second).Unix(), false},
+		{now.Add(-2 * time.Minute).Unix(), true},
+	}
+	for _, tc := range table {
+		tablet := &Tablet{LastHeartbeat: tc.lastHeartbeat}
+		if got := tablet.IsStale(); got != tc.stale {
+			t.Errorf("IsStale(%v) = %v, expected %v", tc.lastHeartbeat, got, tc.stale)
+		}
+	}
+
+	// no TTL means no stale tablet
+	SetTabletHeartbeatTTL(0)
+	tablet := &Tablet{LastHeartbeat: now.Add(-time.Hour).Unix()}
+	if tablet.IsStale() {
+		t.Errorf("IsStale() = true without a TTL")
+	}
+}
+
+func TestMatchTags(t *testing.T) {
+	tags := map[string]string{"rack": "r1", "machine": "ssd"}
+	table := []struct {
+		want  map[string]string
+		match bool
+	}{
+		{nil, true},
+		{map[string]string{"rack": "r1"}, true},
+		{map[string]string{"rack": "r1", "machine": "ssd"}, true},
+		{map[string]string{"rack": "r2"}, false},
+		{map[string]string{"rack": "r1", "window": "sunday"}, false},
+		{map[string]string{"machine": ""}, false},
+	}
+	for _, tc := range table {
+		if got := MatchTags(tags, tc.want); got != tc.match {
+			t.Errorf("MatchTags(%v, %v) = %v, expected %v", tags, tc.want, got, tc.match)
+		}
+	}
+	if MatchTags(nil, map[string]string{"rack": ""}) {
+		t.Errorf("MatchTags(nil, rack) = true")
+	}
+}
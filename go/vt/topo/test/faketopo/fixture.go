@@ -7,7 +7,7 @@ import (
 
 	"github.com/youtube/vitess/go/vt/key"
 	"github.com/youtube/vitess/go/vt/logutil"
-	"github.com/youtube/vitess/go/vt/mysqlctl"
+	"github.com/youtube/vitess/go/vt/mysqlctl/fakemysqldaemon"
 	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
 	"github.com/youtube/vitess/go/vt/topo"
 	"github.com/youtube/vitess/go/vt/wrangler"
@@ -32,7 +32,7 @@ func newKeyRange(value string) key.KeyRange {
 
 type tabletPack struct {
 	*topo.Tablet
-	mysql *mysqlctl.FakeMysqlDaemon
+	mysql *fakemysqldaemon.FakeMysqlDaemon
 }
 
 // Fixture is a fixture that provides a fresh topology, to which you
@@ -102,7 +102,7 @@ func (fix *Fixture) AddTablet(uid int, cell string, tabletType topo.TabletType,
 	if err := fix.Wrangler.InitTablet(context.Background(), tablet, true, true, false); err != nil {
 		fix.Fatalf("CreateTablet: %v", err)
 	}
-	mysqlDaemon := &mysqlctl.FakeMysqlDaemon{}
+	mysqlDaemon := &fakemysqldaemon.FakeMysqlDaemon{}
 	if master != nil {
 		mysqlDaemon.MasterAddr = master.MysqlIPAddr()
 	}
@@ -0,0 +1,561 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package topo
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/youtube/vitess/go/stats"
+	"golang.org/x/net/context"
+)
+
+var (
+	topoTimeout = flag.Duration("topo_timeout", 30*time.Second, "how long to wait for a topology server call, unless the caller sets its own deadline")
+
+	topoTimings  = stats.NewTimings("TopoOperations")
+	topoTimeouts = stats.NewCounters("TopoTimeouts")
+)
+
+// timeoutServer bounds the duration of the calls to a Server, and
+// records their latency per method. When a call times out, it
+// returns ErrTimeout right away, but the call goes on in the
+// background, and may still complete.
+//
+// The lock methods wait for the lock until the deadline of their
+// context, or the timeout if the context has no deadline. A lock
+// that is taken after that is released right away.
+type timeoutServer struct {
+	Server
+	timeout time.Duration
+}
+
+// WithTimeout returns a Server that waits at most timeout for each
+// call to ts, instead of the -topo_timeout default. Use it for the
+// calls that need a different bound, for instance:
+//
+//	topo.WithTimeout(ts, 5*time.Second).GetSrvKeyspace(cell, keyspace)
+func WithTimeout(ts Server, timeout time.Duration) Server {
+	if t, ok := ts.(timeoutServer); ok {
+		ts = t.Server
+	}
+	return timeoutServer{ts, timeout}
+}
+
+// withTimeout wraps ts with the default timeout, if there is one.
+func withTimeout(ts Server) Server {
+	if *topoTimeout <= 0 {
+		return ts
+	}
+	return WithTimeout(ts, *topoTimeout)
+}
+
+// wrapper is implemented by the Servers that wrap another one.
+type wrapper interface {
+	underlying() Server
+}
+
+// UnwrapServer returns the actual implementation behind a Server
+// returned by GetServer or WithTimeout, for the code that needs a
+// specific implementation.
+func UnwrapServer(ts Server) Server {
+	for {
+		w, ok := ts.(wrapper)
+		if !ok {
+			return ts
+		}
+		ts = w.underlying()
+	}
+}
+
+func (ts timeoutServer) underlying() Server {
+	return ts.Server
+}
+
+// withDeadline returns ctx with the timeout of the server, unless it
+// already has a deadline.
+func (ts timeoutServer) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, ts.timeout)
+}
+
+// run calls f, and waits for it until the deadline.
+func (ts timeoutServer) run(ctx context.Context, op string, f func() (interface{}, error)) (interface{}, error) {
+	defer topoTimings.Record(op, time.Now())
+	ctx, cancel := ts.withDeadline(ctx)
+	defer cancel()
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := f()
+		done <- result{value, err}
+	}()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		topoTimeouts.Add(op, 1)
+		if ctx.Err() == context.Canceled {
+			return nil, ErrInterrupted
+		}
+		return nil, ErrTimeout
+	}
+}
+
+// lock calls f to take a lock, and waits for it until the deadline.
+// The context passed to f is cancelled then, but if f still takes
+// the lock, unlock releases it, since nobody holds it.
+func (ts timeoutServer) lock(ctx context.Context, op string, f func(context.Context) (string, error), unlock func(lockPath string) error) (string, error) {
+	defer topoTimings.Record(op, time.Now())
+	ctx, cancel := ts.withDeadline(ctx)
+	defer cancel()
+
+	type result struct {
+		lockPath string
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		lockPath, err := f(ctx)
+		done <- result{lockPath, err}
+	}()
+	select {
+	case r := <-done:
+		return r.lockPath, r.err
+	case <-ctx.Done():
+		topoTimeouts.Add(op, 1)
+		go func() {
+			r := <-done
+			if r.err != nil {
+				return
+			}
+			mlog.Warningf("%v took %v after it timed out, releasing it", op, r.lockPath)
+			if err := unlock(r.lockPath); err != nil {
+				mlog.Errorf("Could not release %v: %v", r.lockPath, err)
+			}
+		}()
+		if ctx.Err() == context.Canceled {
+			return "", ErrInterrupted
+		}
+		return "", ErrTimeout
+	}
+}
+
+// GetKnownCells is part of the Server interface
+func (ts timeoutServer) GetKnownCells() ([]string, error) {
+	r, err := ts.run(context.Background(), "GetKnownCells", func() (interface{}, error) {
+		return ts.Server.GetKnownCells()
+	})
+	if r == nil {
+		return nil, err
+	}
+	return r.([]string), err
+}
+
+// CreateKeyspace is part of the Server interface
+func (ts timeoutServer) CreateKeyspace(keyspace string, value *Keyspace) error {
+	_, err := ts.run(context.Background(), "CreateKeyspace", func() (interface{}, error) {
+		return nil, ts.Server.CreateKeyspace(keyspace, value)
+	})
+	return err
+}
+
+// UpdateKeyspace is part of the Server interface
+func (ts timeoutServer) UpdateKeyspace(ki *KeyspaceInfo, existingVersion int64) (int64, error) {
+	r, err := ts.run(context.Background(), "UpdateKeyspace", func() (interface{}, error) {
+		return ts.Server.UpdateKeyspace(ki, existingVersion)
+	})
+	if r == nil {
+		return 0, err
+	}
+	return r.(int64), err
+}
+
+// GetKeyspace is part of the Server interface
+func (ts timeoutServer) GetKeyspace(keyspace string) (*KeyspaceInfo, error) {
+	r, err := ts.run(context.Background(), "GetKeyspace", func() (interface{}, error) {
+		return ts.Server.GetKeyspace(keyspace)
+	})
+	if r == nil {
+		return nil, err
+	}
+	return r.(*KeyspaceInfo), err
+}
+
+// GetKeyspaces is part of the Server interface
+func (ts timeoutServer) GetKeyspaces() ([]string, error) {
+	r, err := ts.run(context.Background(), "GetKeyspaces", func() (interface{}, error) {
+		return ts.Server.GetKeyspaces()
+	})
+	if r == nil {
+		return nil, err
+	}
+	return r.([]string), err
+}
+
+// DeleteKeyspaceShards is part of the Server interface
+func (ts timeoutServer) DeleteKeyspaceShards(keyspace string) error {
+	_, err := ts.run(context.Background(), "DeleteKeyspaceShards", func() (interface{}, error) {
+		return nil, ts.Server.DeleteKeyspaceShards(keyspace)
+	})
+	return err
+}
+
+// CreateShard is part of the Server interface
+func (ts timeoutServer) CreateShard(keyspace, shard string, value *Shard) error {
+	_, err := ts.run(context.Background(), "CreateShard", func() (interface{}, error) {
+		return nil, ts.Server.CreateShard(keyspace, shard, value)
+	})
+	return err
+}
+
+// UpdateShard is part of the Server interface
+func (ts timeoutServer) UpdateShard(si *ShardInfo, existingVersion int64) (int64, error) {
+	r, err := ts.run(context.Background(), "UpdateShard", func() (interface{}, error) {
+		return ts.Server.UpdateShard(si, existingVersion)
+	})
+	if r == nil {
+		return 0, err
+	}
+	return r.(int64), err
+}
+
+// ValidateShard is part of the Server interface
+func (ts timeoutServer) ValidateShard(keyspace, shard string) error {
+	_, err := ts.run(context.Background(), "ValidateShard", func() (interface{}, error) {
+		return nil, ts.Server.ValidateShard(keyspace, shard)
+	})
+	return err
+}
+
+// GetShard is part of the Server interface
+func (ts timeoutServer) GetShard(keyspace, shard string) (*ShardInfo, error) {
+	r, err := ts.run(context.Background(), "GetShard", func() (interface{}, error) {
+		return ts.Server.GetShard(keyspace, shard)
+	})
+	if r == nil {
+		return nil, err
+	}
+	return r.(*ShardInfo), err
+}
+
+// GetShardNames is part of the Server interface
+func (ts timeoutServer) GetShardNames(keyspace string) ([]string, error) {
+	r, err := ts.run(context.Background(), "GetShardNames", func() (interface{}, error) {
+		return ts.Server.GetShardNames(keyspace)
+	})
+	if r == nil {
+		return nil, err
+	}
+	return r.([]string), err
+}
+
+// DeleteShard is part of the Server interface
+func (ts timeoutServer) DeleteShard(keyspace, shard string) error {
+	_, err := ts.run(context.Background(), "DeleteShard", func() (interface{}, error) {
+		return nil, ts.Server.DeleteShard(keyspace, shard)
+	})
+	return err
+}
+
+// CreateTablet is part of the Server interface
+func (ts timeoutServer) CreateTablet(tablet *Tablet) error {
+	_, err := ts.run(context.Background(), "CreateTablet", func() (interface{}, error) {
+		return nil, ts.Server.CreateTablet(tablet)
+	})
+	return err
+}
+
+// UpdateTablet is part of the Server interface
+func (ts timeoutServer) UpdateTablet(tablet *TabletInfo, existingVersion int64) (int64, error) {
+	r, err := ts.run(context.Background(), "UpdateTablet", func() (interface{}, error) {
+		return ts.Server.UpdateTablet(tablet, existingVersion)
+	})
+	if r == nil {
+		return 0, err
+	}
+	return r.(int64), err
+}
+
+// UpdateTabletFields is part of the Server interface
+func (ts timeoutServer) UpdateTabletFields(tabletAlias TabletAlias, update func(*Tablet) error) error {
+	_, err := ts.run(context.Background(), "UpdateTabletFields", func() (interface{}, error) {
+		return nil, ts.Server.UpdateTabletFields(tabletAlias, update)
+	})
+	return err
+}
+
+// DeleteTablet is part of the Server interface
+func (ts timeoutServer) DeleteTablet(alias TabletAlias) error {
+	_, err := ts.run(context.Background(), "DeleteTablet", func() (interface{}, error) {
+		return nil, ts.Server.DeleteTablet(alias)
+	})
+	return err
+}
+
+// GetTablet is part of the Server interface
+func (ts timeoutServer) GetTablet(alias TabletAlias) (*TabletInfo, error) {
+	r, err := ts.run(context.Background(), "GetTablet", func() (interface{}, error) {
+		return ts.Server.GetTablet(alias)
+	})
+	if r == nil {
+		return nil, err
+	}
+	return r.(*TabletInfo), err
+}
+
+// GetTabletsByCell is part of the Server interface
+func (ts timeoutServer) GetTabletsByCell(cell string) ([]TabletAlias, error) {
+	r, err := ts.run(context.Background(), "GetTabletsByCell", func() (interface{}, error) {
+		return ts.Server.GetTabletsByCell(cell)
+	})
+	if r == nil {
+		return nil, err
+	}
+	return r.([]TabletAlias), err
+}
+
+// UpdateShardReplicationFields is part of the Server interface
+func (ts timeoutServer) UpdateShardReplicationFields(cell, keyspace, shard string, update func(*ShardReplication) error) error {
+	_, err := ts.run(context.Background(), "UpdateShardReplicationFields", func() (interface{}, error) {
+		return nil, ts.Server.UpdateShardReplicationFields(cell, keyspace, shard, update)
+	})
+	return err
+}
+
+// GetShardReplication is part of the Server interface
+func (ts timeoutServer) GetShardReplication(cell, keyspace, shard string) (*ShardReplicationInfo, error) {
+	r, err := ts.run(context.Background(), "GetShardReplication", func() (interface{}, error) {
+		return ts.Server.GetShardReplication(cell, keyspace, shard)
+	})
+	if r == nil {
+		return nil, err
+	}
+	return r.(*ShardReplicationInfo), err
+}
+
+// DeleteShardReplication is part of the Server interface
+func (ts timeoutServer) DeleteShardReplication(cell, keyspace, shard string) error {
+	_, err := ts.run(context.Background(), "DeleteShardReplication", func() (interface{}, error) {
+		return nil, ts.Server.DeleteShardReplication(cell, keyspace, shard)
+	})
+	return err
+}
+
+// LockSrvShardForAction is part of the Server interface
+func (ts timeoutServer) LockSrvShardForAction(ctx context.Context, cell, keyspace, shard, contents string) (string, error) {
+	return ts.lock(ctx, "LockSrvShardForAction", func(ctx context.Context) (string, error) {
+		return ts.Server.LockSrvShardForAction(ctx, cell, keyspace, shard, contents)
+	}, func(lockPath string) error {
+		return ts.Server.UnlockSrvShardForAction(cell, keyspace, shard, lockPath, "{}")
+	})
+}
+
+// UnlockSrvShardForAction is part of the Server interface
+func (ts timeoutServer) UnlockSrvShardForAction(cell, keyspace, shard, lockPath, results string) error {
+	_, err := ts.run(context.Background(), "UnlockSrvShardForAction", func() (interface{}, error) {
+		return nil, ts.Server.UnlockSrvShardForAction(cell, keyspace, shard, lockPath, results)
+	})
+	return err
+}
+
+// GetSrvTabletTypesPerShard is part of the Server interface
+func (ts timeoutServer) GetSrvTabletTypesPerShard(cell, keyspace, shard string) ([]TabletType, error) {
+	r, err := ts.run(context.Background(), "GetSrvTabletTypesPerShard", func() (interface{}, error) {
+		return ts.Server.GetSrvTabletTypesPerShard(cell, keyspace, shard)
+	})
+	if r == nil {
+		return nil, err
+	}
+	return r.([]TabletType), err
+}
+
+// UpdateEndPoints is part of the Server interface
+func (ts timeoutServer) UpdateEndPoints(cell, keyspace, shard string, tabletType TabletType, addrs *EndPoints) error {
+	_, err := ts.run(context.Background(), "UpdateEndPoints", func() (interface{}, error) {
+		return nil, ts.Server.UpdateEndPoints(cell, keyspace, shard, tabletType, addrs)
+	})
+	return err
+}
+
+// GetEndPoints is part of the Server interface
+func (ts timeoutServer) GetEndPoints(cell, keyspace, shard string, tabletType TabletType) (*EndPoints, error) {
+	r, err := ts.run(context.Background(), "GetEndPoints", func() (interface{}, error) {
+		return ts.Server.GetEndPoints(cell, keyspace, shard, tabletType)
+	})
+	if r == nil {
+		return nil, err
+	}
+	return r.(*EndPoints), err
+}
+
+// DeleteEndPoints is part of the Server interface
+func (ts timeoutServer) DeleteEndPoints(cell, keyspace, shard string, tabletType TabletType) error {
+	_, err := ts.run(context.Background(), "DeleteEndPoints", func() (interface{}, error) {
+		return nil, ts.Server.DeleteEndPoints(cell, keyspace, shard, tabletType)
+	})
+	return err
+}
+
+// WatchEndPoints is part of the Server interface
+func (ts timeoutServer) WatchEndPoints(cell, keyspace, shard string, tabletType TabletType) (notifications <-chan *EndPoints, stopWatching chan<- struct{}, err error) {
+	type result struct {
+		notifications <-chan *EndPoints
+		stopWatching  chan<- struct{}
+	}
+	r, err := ts.run(context.Background(), "WatchEndPoints", func() (interface{}, error) {
+		notifications, stopWatching, err := ts.Server.WatchEndPoints(cell, keyspace, shard, tabletType)
+		return result{notifications, stopWatching}, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.(result).notifications, r.(result).stopWatching, nil
+}
+
+// UpdateSrvShard is part of the Server interface
+func (ts timeoutServer) UpdateSrvShard(cell, keyspace, shard string, srvShard *SrvShard) error {
+	_, err := ts.run(context.Background(), "UpdateSrvShard", func() (interface{}, error) {
+		return nil, ts.Server.UpdateSrvShard(cell, keyspace, shard, srvShard)
+	})
+	return err
+}
+
+// GetSrvShard is part of the Server interface
+func (ts timeoutServer) GetSrvShard(cell, keyspace, shard string) (*SrvShard, error) {
+	r, err := ts.run(context.Background(), "GetSrvShard", func() (interface{}, error) {
+		return ts.Server.GetSrvShard(cell, keyspace, shard)
+	})
+	if r == nil {
+		return nil, err
+	}
+	return r.(*SrvShard), err
+}
+
+// DeleteSrvShard is part of the Server interface
+func (ts timeoutServer) DeleteSrvShard(cell, keyspace, shard string) error {
+	_, err := ts.run(context.Background(), "DeleteSrvShard", func() (interface{}, error) {
+		return nil, ts.Server.DeleteSrvShard(cell, keyspace, shard)
+	})
+	return err
+}
+
+// UpdateSrvKeyspace is part of the Server interface
+func (ts timeoutServer) UpdateSrvKeyspace(cell, keyspace string, srvKeyspace *SrvKeyspace) error {
+	_, err := ts.run(context.Background(), "UpdateSrvKeyspace", func() (interface{}, error) {
+		return nil, ts.Server.UpdateSrvKeyspace(cell, keyspace, srvKeyspace)
+	})
+	return err
+}
+
+// GetSrvKeyspace is part of the Server interface
+func (ts timeoutServer) GetSrvKeyspace(cell, keyspace string) (*SrvKeyspace, error) {
+	r, err := ts.run(context.Background(), "GetSrvKeyspace", func() (interface{}, error) {
+		return ts.Server.GetSrvKeyspace(cell, keyspace)
+	})
+	if r == nil {
+		return nil, err
+	}
+	return r.(*SrvKeyspace), err
+}
+
+// GetSrvKeyspaceNames is part of the Server interface
+func (ts timeoutServer) GetSrvKeyspaceNames(cell string) ([]string, error) {
+	r, err := ts.run(context.Background(), "GetSrvKeyspaceNames", func() (interface{}, error) {
+		return ts.Server.GetSrvKeyspaceNames(cell)
+	})
+	if r == nil {
+		return nil, err
+	}
+	return r.([]string), err
+}
+
+// UpdateTabletEndpoint is part of the Server interface
+func (ts timeoutServer) UpdateTabletEndpoint(cell, keyspace, shard string, tabletType TabletType, addr *EndPoint) error {
+	_, err := ts.run(context.Background(), "UpdateTabletEndpoint", func() (interface{}, error) {
+		return nil, ts.Server.UpdateTabletEndpoint(cell, keyspace, shard, tabletType, addr)
+	})
+	return err
+}
+
+// LockKeyspaceForAction is part of the Server interface
+func (ts timeoutServer) LockKeyspaceForAction(ctx context.Context, keyspace, contents string) (string, error) {
+	return ts.lock(ctx, "LockKeyspaceForAction", func(ctx context.Context) (string, error) {
+		return ts.Server.LockKeyspaceForAction(ctx, keyspace, contents)
+	}, func(lockPath string) error {
+		return ts.Server.UnlockKeyspaceForAction(keyspace, lockPath, "{}")
+	})
+}
+
+// UnlockKeyspaceForAction is part of the Server interface
+func (ts timeoutServer) UnlockKeyspaceForAction(keyspace, lockPath, results string) error {
+	_, err := ts.run(context.Background(), "UnlockKeyspaceForAction", func() (interface{}, error) {
+		return nil, ts.Server.UnlockKeyspaceForAction(keyspace, lockPath, results)
+	})
+	return err
+}
+
+// LockShardForAction is part of the Server interface
+func (ts timeoutServer) LockShardForAction(ctx context.Context, keyspace, shard, contents string) (string, error) {
+	return ts.lock(ctx, "LockShardForAction", func(ctx context.Context) (string, error) {
+		return ts.Server.LockShardForAction(ctx, keyspace, shard, contents)
+	}, func(lockPath string) error {
+		return ts.Server.UnlockShardForAction(keyspace, shard, lockPath, "{}")
+	})
+}
+
+// UnlockShardForAction is part of the Server interface
+func (ts timeoutServer) UnlockShardForAction(keyspace, shard, lockPath, results string) error {
+	_, err := ts.run(context.Background(), "UnlockShardForAction", func() (interface{}, error) {
+		return nil, ts.Server.UnlockShardForAction(keyspace, shard, lockPath, results)
+	})
+	return err
+}
+
+// SaveVSchema is part of the Schemafier interface
+func (ts timeoutServer) SaveVSchema(vschema string) error {
+	schemafier, ok := ts.Server.(Schemafier)
+	if !ok {
+		return fmt.Errorf("topo server doesn't support vschema")
+	}
+	_, err := ts.run(context.Background(), "SaveVSchema", func() (interface{}, error) {
+		return nil, schemafier.SaveVSchema(vschema)
+	})
+	return err
+}
+
+// GetVSchema is part of the Schemafier interface
+func (ts timeoutServer) GetVSchema() (string, error) {
+	schemafier, ok := ts.Server.(Schemafier)
+	if !ok {
+		return "", fmt.Errorf("topo server doesn't support vschema")
+	}
+	r, err := ts.run(context.Background(), "GetVSchema", func() (interface{}, error) {
+		return schemafier.GetVSchema()
+	})
+	if r == nil {
+		return "", err
+	}
+	return r.(string), err
+}
+
+// CreateCell is part of the CellCreator interface
+func (ts timeoutServer) CreateCell(cell string, addrs []string) error {
+	cc, ok := ts.Server.(CellCreator)
+	if !ok {
+		return fmt.Errorf("topo server cannot create cells")
+	}
+	_, err := ts.run(context.Background(), "CreateCell", func() (interface{}, error) {
+		return nil, cc.CreateCell(cell, addrs)
+	})
+	return err
+}
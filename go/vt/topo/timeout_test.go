@@ -0,0 +1,116 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package topo
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// slowServer answers GetKnownCells after delay, and waits for its
+// context in LockKeyspaceForAction. The other methods are not
+// implemented.
+type slowServer struct {
+	Server
+	delay time.Duration
+}
+
+func (ss slowServer) GetKnownCells() ([]string, error) {
+	time.Sleep(ss.delay)
+	return []string{"cell1"}, nil
+}
+
+func (ss slowServer) LockKeyspaceForAction(ctx context.Context, keyspace, contents string) (string, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		return "", ErrInterrupted
+	}
+	<-ctx.Done()
+	return "", ErrTimeout
+}
+
+func TestTimeoutServer(t *testing.T) {
+	slow := slowServer{delay: 100 * time.Millisecond}
+
+	before := topoTimeouts.Counts()["GetKnownCells"]
+	ts := WithTimeout(slow, 10*time.Millisecond)
+	if _, err := ts.GetKnownCells(); err != ErrTimeout {
+		t.Errorf("GetKnownCells = %v, want ErrTimeout", err)
+	}
+	if got := topoTimeouts.Counts()["GetKnownCells"]; got != before+1 {
+		t.Errorf("TopoTimeouts[GetKnownCells] = %v, want %v", got, before+1)
+	}
+
+	// a longer timeout for this call only
+	cells, err := WithTimeout(ts, time.Second).GetKnownCells()
+	if err != nil || len(cells) != 1 {
+		t.Errorf("GetKnownCells = %v, %v, want [cell1], nil", cells, err)
+	}
+	if UnwrapServer(ts) != Server(slow) {
+		t.Errorf("UnwrapServer didn't return the slow server")
+	}
+
+	// the lock gets a deadline from the timeout
+	if _, err := ts.LockKeyspaceForAction(context.Background(), "ks", "contents"); err != ErrTimeout {
+		t.Errorf("LockKeyspaceForAction = %v, want ErrTimeout", err)
+	}
+
+	// a cancelled lock fails right away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if _, err := WithTimeout(slow, time.Second).LockKeyspaceForAction(ctx, "ks", "contents"); err == nil {
+		t.Errorf("LockKeyspaceForAction of a cancelled context should have failed")
+	}
+	if d := time.Now().Sub(start); d > 500*time.Millisecond {
+		t.Errorf("LockKeyspaceForAction of a cancelled context took %v", d)
+	}
+}
+
+// lateLockServer takes the shard lock after delay, whatever its
+// context says, and reports the unlocks on unlocked.
+type lateLockServer struct {
+	Server
+	delay    time.Duration
+	unlocked chan string
+}
+
+func (ls lateLockServer) LockShardForAction(ctx context.Context, keyspace, shard, contents string) (string, error) {
+	time.Sleep(ls.delay)
+	return "lock1", nil
+}
+
+func (ls lateLockServer) UnlockShardForAction(keyspace, shard, lockPath, results string) error {
+	ls.unlocked <- lockPath
+	return nil
+}
+
+func TestTimeoutServerLateLock(t *testing.T) {
+	late := lateLockServer{delay: 50 * time.Millisecond, unlocked: make(chan string, 1)}
+	ts := WithTimeout(late, 10*time.Millisecond)
+	if _, err := ts.LockShardForAction(context.Background(), "ks", "0", "contents"); err != ErrTimeout {
+		t.Fatalf("LockShardForAction = %v, want ErrTimeout", err)
+	}
+	select {
+	case lockPath := <-late.unlocked:
+		if lockPath != "lock1" {
+			t.Errorf("released %v, want lock1", lockPath)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("the lock taken after the timeout was not released")
+	}
+
+	// a lock taken in time is kept
+	lockPath, err := WithTimeout(late, time.Second).LockShardForAction(context.Background(), "ks", "0", "contents")
+	if err != nil || lockPath != "lock1" {
+		t.Fatalf("LockShardForAction = %v, %v, want lock1, nil", lockPath, err)
+	}
+	select {
+	case lockPath := <-late.unlocked:
+		t.Errorf("released %v, which was taken in time", lockPath)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package topocache
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
+)
+
+// HandleHTTP serves the reads of ts as JSON, for the clients that
+// don't speak RPC:
+//
+//	/topocache/srv_keyspace_names?cell=...
+//	/topocache/srv_keyspace?cell=...&keyspace=...
+//	/topocache/srv_shard?cell=...&keyspace=...&shard=...
+//	/topocache/end_points?cell=...&keyspace=...&shard=...&tablet_type=...
+//
+// A missing object is a 404, a failed read a 503.
+func HandleHTTP(ts SrvTopoServer) {
+	http.Handle("/topocache/", httpHandler{ts})
+}
+
+var errUnknownObject = errors.New("unknown object type")
+
+type httpHandler struct {
+	ts SrvTopoServer
+}
+
+func (h httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	value, err := h.read(context.Background(), strings.TrimPrefix(r.URL.Path, "/topocache/"), r)
+	switch {
+	case err == topo.ErrNoNode, err == errUnknownObject:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// read runs the read for the object type, with the parameters of
+// the request.
+func (h httpHandler) read(ctx context.Context, objectType string, r *http.Request) (interface{}, error) {
+	cell := r.FormValue("cell")
+	keyspace := r.FormValue("keyspace")
+	shard := r.FormValue("shard")
+	switch objectType {
+	case "srv_keyspace_names":
+		return h.ts.GetSrvKeyspaceNames(ctx, cell)
+	case "srv_keyspace":
+		return h.ts.GetSrvKeyspace(ctx, cell, keyspace)
+	case "srv_shard":
+		return h.ts.GetSrvShard(ctx, cell, keyspace, shard)
+	case "end_points":
+		return h.ts.GetEndPoints(ctx, cell, keyspace, shard, topo.TabletType(r.FormValue("tablet_type")))
+	}
+	return nil, errUnknownObject
+}
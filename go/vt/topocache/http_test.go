@@ -0,0 +1,45 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package topocache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/zktopo"
+)
+
+func TestHTTP(t *testing.T) {
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	if err := ts.UpdateSrvKeyspace("cell1", "ks", &topo.SrvKeyspace{ShardingColumnName: "col1"}); err != nil {
+		t.Fatalf("UpdateSrvKeyspace failed: %v", err)
+	}
+	h := httpHandler{NewCache(ts, time.Second, time.Minute, time.Second, nil, "TestHTTPCache")}
+
+	for _, c := range []struct {
+		url      string
+		code     int
+		contains string
+	}{
+		{"/topocache/srv_keyspace?cell=cell1&keyspace=ks", http.StatusOK, `"col1"`},
+		{"/topocache/srv_keyspace_names?cell=cell1", http.StatusOK, `"ks"`},
+		{"/topocache/srv_keyspace?cell=cell1&keyspace=missing", http.StatusNotFound, ""},
+		{"/topocache/unknown", http.StatusNotFound, ""},
+	} {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatalf("NewRequest failed: %v", err)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.code || !strings.Contains(w.Body.String(), c.contains) {
+			t.Errorf("%v returned %v %v, want %v with %v", c.url, w.Code, w.Body.String(), c.code, c.contains)
+		}
+	}
+}
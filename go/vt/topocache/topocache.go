@@ -0,0 +1,240 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package topocache is a read-only cache of the serving graph, for
+// processes that serve topology reads to many clients, so each
+// client doesn't need its own session with the topology server.
+//
+// A value is read again from the topology server when it is older
+// than the TTL. If that read fails, the cache keeps serving the last
+// value it got, until it is older than the max staleness. After
+// that, it reads the value from the fallback cells, in order.
+package topocache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
+)
+
+// The categories of the Counts stats of a Cache.
+const (
+	queryCategory    = "query"
+	cachedCategory   = "cached"
+	staleCategory    = "stale"
+	fallbackCategory = "fallback"
+	errorCategory    = "error"
+)
+
+// SrvTopoServer is the read-only serving graph interface the Cache
+// implements. vtgate has the same interface, for its own cache.
+type SrvTopoServer interface {
+	GetSrvKeyspaceNames(ctx context.Context, cell string) ([]string, error)
+
+	GetSrvKeyspace(ctx context.Context, cell, keyspace string) (*topo.SrvKeyspace, error)
+
+	GetSrvShard(ctx context.Context, cell, keyspace, shard string) (*topo.SrvShard, error)
+
+	GetEndPoints(ctx context.Context, cell, keyspace, shard string, tabletType topo.TabletType) (*topo.EndPoints, error)
+}
+
+// Cache implements SrvTopoServer with a cache of the reads to a
+// topo.Server.
+type Cache struct {
+	ts            topo.Server
+	ttl           time.Duration
+	maxStaleness  time.Duration
+	fallbackCells []string
+	counts        *stats.Counters
+
+	// mu protects the entries map itself, not the entries.
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// entry is a cached value.
+type entry struct {
+	// the mutex protects all the fields, and is held during the
+	// reads, so concurrent clients only cause one read.
+	mu sync.Mutex
+
+	value interface{}
+	err   error
+
+	// fetchTime is when value was read, checkTime is when we last
+	// tried to read it.
+	fetchTime time.Time
+	checkTime time.Time
+
+	// fallbackCell is set if value comes from another cell.
+	fallbackCell string
+}
+
+// NewCache returns a Cache for ts. readTimeout bounds each read to
+// the topology server. The stats are exported with the
+// counterPrefix.
+func NewCache(ts topo.Server, ttl, maxStaleness, readTimeout time.Duration, fallbackCells []string, counterPrefix string) *Cache {
+	return &Cache{
+		ts:            topo.WithTimeout(ts, readTimeout),
+		ttl:           ttl,
+		maxStaleness:  maxStaleness,
+		fallbackCells: fallbackCells,
+		counts:        stats.NewCounters(counterPrefix + "Counts"),
+		entries:       make(map[string]*entry),
+	}
+}
+
+// get returns the value for key, which is read from cell by fetch.
+func (c *Cache) get(key, cell string, fetch func(cell string) (interface{}, error)) (interface{}, error) {
+	c.counts.Add(queryCategory, 1)
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		e = &entry{}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(e.checkTime) < c.ttl {
+		c.counts.Add(cachedCategory, 1)
+		return e.value, e.err
+	}
+	e.checkTime = now
+
+	// ErrNoNode is an answer, not a failure: the object is gone.
+	value, err := fetch(cell)
+	if err == nil || err == topo.ErrNoNode {
+		e.value, e.err, e.fetchTime, e.fallbackCell = value, err, now, ""
+		return value, err
+	}
+
+	if e.err == nil && !e.fetchTime.IsZero() && now.Sub(e.fetchTime) < c.maxStaleness {
+		c.counts.Add(staleCategory, 1)
+		log.Warningf("topocache: reading %v failed: %v (returning the value from %v ago)", key, err, now.Sub(e.fetchTime))
+		return e.value, nil
+	}
+
+	for _, fallbackCell := range c.fallbackCells {
+		if fallbackCell == cell {
+			continue
+		}
+		value, ferr := fetch(fallbackCell)
+		if ferr != nil {
+			log.Warningf("topocache: reading %v from fallback cell %v failed: %v", key, fallbackCell, ferr)
+			continue
+		}
+		c.counts.Add(fallbackCategory, 1)
+		log.Warningf("topocache: reading %v failed: %v (returning the value of cell %v)", key, err, fallbackCell)
+		e.value, e.err, e.fetchTime, e.fallbackCell = value, nil, now, fallbackCell
+		return value, nil
+	}
+
+	c.counts.Add(errorCategory, 1)
+	log.Errorf("topocache: reading %v failed: %v", key, err)
+	e.value, e.err, e.fallbackCell = nil, err, ""
+	return nil, err
+}
+
+// GetSrvKeyspaceNames is part of the SrvTopoServer interface.
+func (c *Cache) GetSrvKeyspaceNames(ctx context.Context, cell string) ([]string, error) {
+	value, err := c.get("SrvKeyspaceNames/"+cell, cell, func(cell string) (interface{}, error) {
+		return c.ts.GetSrvKeyspaceNames(cell)
+	})
+	if value == nil {
+		return nil, err
+	}
+	return value.([]string), err
+}
+
+// GetSrvKeyspace is part of the SrvTopoServer interface.
+func (c *Cache) GetSrvKeyspace(ctx context.Context, cell, keyspace string) (*topo.SrvKeyspace, error) {
+	value, err := c.get("SrvKeyspace/"+cell+"/"+keyspace, cell, func(cell string) (interface{}, error) {
+		return c.ts.GetSrvKeyspace(cell, keyspace)
+	})
+	if value == nil {
+		return nil, err
+	}
+	return value.(*topo.SrvKeyspace), err
+}
+
+// GetSrvShard is part of the SrvTopoServer interface.
+func (c *Cache) GetSrvShard(ctx context.Context, cell, keyspace, shard string) (*topo.SrvShard, error) {
+	shard = strings.ToLower(shard)
+	value, err := c.get("SrvShard/"+cell+"/"+keyspace+"/"+shard, cell, func(cell string) (interface{}, error) {
+		return c.ts.GetSrvShard(cell, keyspace, shard)
+	})
+	if value == nil {
+		return nil, err
+	}
+	return value.(*topo.SrvShard), err
+}
+
+// GetEndPoints is part of the SrvTopoServer interface.
+func (c *Cache) GetEndPoints(ctx context.Context, cell, keyspace, shard string, tabletType topo.TabletType) (*topo.EndPoints, error) {
+	shard = strings.ToLower(shard)
+	value, err := c.get(fmt.Sprintf("EndPoints/%v/%v/%v/%v", cell, keyspace, shard, tabletType), cell, func(cell string) (interface{}, error) {
+		return c.ts.GetEndPoints(cell, keyspace, shard, tabletType)
+	})
+	if value == nil {
+		return nil, err
+	}
+	return value.(*topo.EndPoints), err
+}
+
+// EntryStatus describes a cached value, for the status page.
+type EntryStatus struct {
+	Key          string
+	Age          time.Duration
+	Stale        bool
+	FallbackCell string
+	Error        string
+}
+
+// Status returns the state of the cached values, sorted by key.
+func (c *Cache) Status() []*EntryStatus {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+	sort.Strings(keys)
+
+	now := time.Now()
+	result := make([]*EntryStatus, 0, len(keys))
+	for _, key := range keys {
+		c.mu.Lock()
+		e := c.entries[key]
+		c.mu.Unlock()
+
+		e.mu.Lock()
+		status := &EntryStatus{
+			Key:          key,
+			Age:          now.Sub(e.fetchTime),
+			Stale:        now.Sub(e.fetchTime) >= c.ttl,
+			FallbackCell: e.fallbackCell,
+		}
+		if e.err != nil {
+			status.Error = e.err.Error()
+		}
+		if e.fetchTime.IsZero() {
+			status.Age = 0
+		}
+		e.mu.Unlock()
+		result = append(result, status)
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package topocache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/zktopo"
+	"golang.org/x/net/context"
+)
+
+// flakyServer counts the GetSrvKeyspace reads, and fails them for
+// the cells in down.
+type flakyServer struct {
+	topo.Server
+
+	mu    sync.Mutex
+	reads int
+	down  map[string]bool
+}
+
+func (fs *flakyServer) GetSrvKeyspace(cell, keyspace string) (*topo.SrvKeyspace, error) {
+	fs.mu.Lock()
+	fs.reads++
+	down := fs.down[cell]
+	fs.mu.Unlock()
+	if down {
+		return nil, fmt.Errorf("cell %v is down", cell)
+	}
+	return fs.Server.GetSrvKeyspace(cell, keyspace)
+}
+
+func (fs *flakyServer) setDown(cell string, down bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.down[cell] = down
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	ts := &flakyServer{
+		Server: zktopo.NewTestServer(t, []string{"cell1", "cell2"}),
+		down:   make(map[string]bool),
+	}
+	for i, cell := range []string{"cell1", "cell2"} {
+		if err := ts.UpdateSrvKeyspace(cell, "ks", &topo.SrvKeyspace{ShardingColumnName: fmt.Sprintf("col%v", i+1)}); err != nil {
+			t.Fatalf("UpdateSrvKeyspace failed: %v", err)
+		}
+	}
+	cache := NewCache(ts, 20*time.Millisecond, 100*time.Millisecond, time.Second, []string{"cell2"}, "TestCache")
+
+	// two reads within the TTL only read once
+	for i := 0; i < 2; i++ {
+		sk, err := cache.GetSrvKeyspace(ctx, "cell1", "ks")
+		if err != nil || sk.ShardingColumnName != "col1" {
+			t.Fatalf("GetSrvKeyspace = %v, %v, want col1", sk, err)
+		}
+	}
+	if ts.reads != 1 {
+		t.Errorf("got %v reads, want 1", ts.reads)
+	}
+
+	// cell1 goes down, we serve the stale value for a while
+	ts.setDown("cell1", true)
+	time.Sleep(30 * time.Millisecond)
+	if sk, err := cache.GetSrvKeyspace(ctx, "cell1", "ks"); err != nil || sk.ShardingColumnName != "col1" {
+		t.Errorf("stale GetSrvKeyspace = %v, %v, want col1", sk, err)
+	}
+
+	// after the max staleness, we read from the fallback cell
+	time.Sleep(100 * time.Millisecond)
+	if sk, err := cache.GetSrvKeyspace(ctx, "cell1", "ks"); err != nil || sk.ShardingColumnName != "col2" {
+		t.Errorf("fallback GetSrvKeyspace = %v, %v, want col2", sk, err)
+	}
+	if status := cache.Status(); len(status) != 1 || status[0].FallbackCell != "cell2" {
+		t.Errorf("Status = %v, want one entry from cell2", status)
+	}
+
+	// all cells are down, we return the error
+	ts.setDown("cell2", true)
+	time.Sleep(130 * time.Millisecond)
+	if _, err := cache.GetSrvKeyspace(ctx, "cell1", "ks"); err == nil {
+		t.Errorf("GetSrvKeyspace should have failed")
+	}
+
+	// a missing keyspace is not a failure, it doesn't fall back
+	ts.setDown("cell1", false)
+	ts.setDown("cell2", false)
+	if _, err := cache.GetSrvKeyspace(ctx, "cell1", "missing"); err != topo.ErrNoNode {
+		t.Errorf("GetSrvKeyspace(missing) = %v, want ErrNoNode", err)
+	}
+}
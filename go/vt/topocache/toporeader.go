@@ -1,17 +1,21 @@
-package main
+// Copyright 2012, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package topocache
 
 import (
 	log "github.com/golang/glog"
 	"github.com/youtube/vitess/go/stats"
 	"github.com/youtube/vitess/go/vt/topo"
-	"github.com/youtube/vitess/go/vt/vtgate"
 	"golang.org/x/net/context"
 )
 
-// TopoReader implements topo.TopoReader.
+// TopoReader implements topo.TopoReader, the RPC service for the
+// serving graph, on top of a SrvTopoServer.
 type TopoReader struct {
 	// the server to get data from
-	ts vtgate.SrvTopoServer
+	ts SrvTopoServer
 
 	// stats
 	queryCount *stats.Counters
@@ -19,7 +23,7 @@ type TopoReader struct {
 }
 
 // NewTopoReader creates a new TopoReader.
-func NewTopoReader(ts vtgate.SrvTopoServer) *TopoReader {
+func NewTopoReader(ts SrvTopoServer) *TopoReader {
 	return &TopoReader{
 		ts:         ts,
 		queryCount: stats.NewCounters("TopoReaderRpcQueryCount"),
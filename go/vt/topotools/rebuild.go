@@ -146,6 +146,12 @@ func rebuildCellSrvShard(ctx context.Context, log logutil.Logger, ts topo.Server
 			continue
 		}
 
+		// Skip the tablets that stopped heartbeating
+		if tablet.IsStale() {
+			log.Warningf("Tablet %v is stale (last heartbeat at %v), it is being ignored in the rebuild", tablet.Alias, time.Unix(tablet.LastHeartbeat, 0))
+			continue
+		}
+
 		// Check the Keyspace and Shard for the tablet are right
 		if tablet.Keyspace != shardInfo.Keyspace() || tablet.Shard != shardInfo.ShardName() {
 			return fmt.Errorf("CRITICAL: tablet %v is in replication graph for shard %v/%v but belongs to shard %v:%v", tablet.Alias, shardInfo.Keyspace(), shardInfo.ShardName(), tablet.Keyspace, tablet.Shard)
@@ -217,6 +223,7 @@ func rebuildCellSrvShard(ctx context.Context, log logutil.Logger, ts topo.Server
 			ServedTypes: shardInfo.GetServedTypesPerCell(cell),
 			MasterCell:  shardInfo.MasterAlias.Cell,
 			TabletTypes: make([]topo.TabletType, 0, len(locationAddrsMap)),
+			ReadOnly:    shardInfo.ReadOnly,
 		}
 		for tabletType := range locationAddrsMap {
 			srvShard.TabletTypes = append(srvShard.TabletTypes, tabletType)
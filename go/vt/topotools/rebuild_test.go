@@ -111,3 +111,47 @@ func TestRebuildShardRace(t *testing.T) {
 		t.Errorf("second change was overwritten by first rebuild finishing late")
 	}
 }
+
+func TestRebuildShardStaleTablet(t *testing.T) {
+	ctx := context.Background()
+	cells := []string{"test_cell"}
+	logger := logutil.NewMemoryLogger()
+
+	defer topo.SetTabletHeartbeatTTL(topo.TabletHeartbeatTTL())
+	topo.SetTabletHeartbeatTTL(time.Minute)
+
+	// Set up topology, with a replica that stopped heartbeating.
+	ts := zktopo.NewTestServer(t, cells)
+	f := faketopo.New(t, logger, ts, cells)
+	defer f.TearDown()
+
+	keyspace := faketopo.TestKeyspace
+	shard := faketopo.TestShard
+	master := f.AddTablet(1, "test_cell", topo.TYPE_MASTER, nil)
+	f.AddTablet(2, "test_cell", topo.TYPE_REPLICA, master)
+	f.AddTablet(3, "test_cell", topo.TYPE_REPLICA, master)
+	if err := ts.UpdateTabletFields(f.GetTablet(3).Alias, func(tablet *topo.Tablet) error {
+		tablet.LastHeartbeat = time.Now().Add(-time.Hour).Unix()
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateTabletFields: %v", err)
+	}
+
+	if _, err := RebuildShard(ctx, logger, f.Topo, keyspace, shard, cells, time.Minute); err != nil {
+		t.Fatalf("RebuildShard: %v", err)
+	}
+
+	// Only the live replica is served.
+	ep, err := ts.GetEndPoints(cells[0], keyspace, shard, topo.TYPE_REPLICA)
+	if err != nil {
+		t.Fatalf("GetEndPoints: %v", err)
+	}
+	if len(ep.Entries) != 1 || ep.Entries[0].Uid != 2 {
+		t.Errorf("unexpected replica endpoints: %v", ep.Entries)
+	}
+
+	// And validation reports the stale one.
+	if err := topo.Validate(ts, f.GetTablet(3).Alias); err == nil || !strings.Contains(err.Error(), "is stale") {
+		t.Errorf("Validate of a stale tablet returned: %v", err)
+	}
+}
@@ -105,8 +105,8 @@ func ChangeType(ctx context.Context, ts topo.Server, tabletAlias topo.TabletAlia
 		return err
 	}
 
-	if !topo.IsTrivialTypeChange(tablet.Type, newType) || !topo.IsValidTypeChange(tablet.Type, newType) {
-		return fmt.Errorf("cannot change tablet type %v -> %v %v", tablet.Type, newType, tabletAlias)
+	if err := topo.CheckTypeChange(tablet.Type, newType); err != nil {
+		return fmt.Errorf("cannot change type of tablet %v: %v", tabletAlias, err)
 	}
 
 	tablet.Type = newType
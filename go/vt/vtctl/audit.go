@@ -0,0 +1,150 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtctl
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/acl"
+	"github.com/youtube/vitess/go/vt/callinfo"
+	"golang.org/x/net/context"
+)
+
+var auditLog = flag.String("vtctl_audit_log", "", "if set, admin commands are appended to this file, one JSON record per line")
+
+// readOnlyCommands are the commands that only read the cluster
+// state. Every other command, including the ones added later, is an
+// admin command: when run on behalf of a remote caller, it requires
+// the acl.ADMIN role, and it is recorded in the audit log.
+var readOnlyCommands = map[string]bool{
+	"gettablet":                   true,
+	"ping":                        true,
+	"runhealthcheck":              true,
+	"healthstream":                true,
+	"getshard":                    true,
+	"validateshard":               true,
+	"shardreplicationpositions":   true,
+	"listshardtablets":            true,
+	"listshards":                  true,
+	"listbackups":                 true,
+	"verifybackups":               true,
+	"getkeyspace":                 true,
+	"validatekeyspace":            true,
+	"migrateservedtypesdryrun":    true,
+	"findallshardsinkeyspace":     true,
+	"getconsistentsnapshot":       true,
+	"resolve":                     true,
+	"validate":                    true,
+	"listalltablets":              true,
+	"listtablets":                 true,
+	"findtablets":                 true,
+	"exporttopology":              true,
+	"getschema":                   true,
+	"getschemaversions":           true,
+	"validateschemashard":         true,
+	"validateschemakeyspace":      true,
+	"validateversionshard":        true,
+	"validateversionkeyspace":     true,
+	"getpermissions":              true,
+	"validatepermissionsshard":    true,
+	"validatepermissionskeyspace": true,
+	"getvschema":                  true,
+	"getsrvkeyspace":              true,
+	"getsrvkeyspacenames":         true,
+	"getsrvshard":                 true,
+	"getendpoints":                true,
+	"getshardreplication":         true,
+}
+
+// AuditRecord is an entry of the audit log.
+type AuditRecord struct {
+	Time     time.Time
+	Caller   string
+	Command  string
+	Args     []string
+	Duration time.Duration
+	Error    string
+}
+
+// auditMu serializes the writes to the audit log.
+var auditMu sync.Mutex
+
+// isAdminCommand returns true if the command requires the acl.ADMIN
+// role, i.e. if it's not known to be read-only.
+func isAdminCommand(name string) bool {
+	return !readOnlyCommands[strings.ToLower(name)]
+}
+
+// caller returns a description of who runs a command: the remote
+// caller if any, or the local user.
+func caller(ctx context.Context) string {
+	if ci, ok := callinfo.FromContext(ctx); ok {
+		return ci.Text()
+	}
+	return "local:" + os.Getenv("USER")
+}
+
+// checkAdminAccess returns an error if the remote caller in ctx
+// doesn't have the acl.ADMIN role. Local commands are always allowed.
+func checkAdminAccess(ctx context.Context) error {
+	ci, ok := callinfo.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if err := acl.CheckAccessActor(ci.Username(), acl.ADMIN); err != nil {
+		return fmt.Errorf("access denied for %v: %v", ci.Text(), err)
+	}
+	return nil
+}
+
+// auditCommand writes the record of an admin command to the audit
+// log, if enabled. Errors are logged, but don't fail the command.
+func auditCommand(ctx context.Context, args []string, start time.Time, err error) {
+	record := &AuditRecord{
+		Time:     start,
+		Caller:   caller(ctx),
+		Command:  args[0],
+		Args:     args[1:],
+		Duration: time.Now().Sub(start),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	log.Infof("vtctl audit: %v ran %v %v in %v, error: %v", record.Caller, record.Command, record.Args, record.Duration, record.Error)
+	if *auditLog == "" {
+		return
+	}
+	if err := writeAuditRecord(*auditLog, record); err != nil {
+		log.Errorf("cannot write to audit log %v: %v", *auditLog, err)
+	}
+}
+
+// writeAuditRecord appends a JSON encoded record to the file.
+func writeAuditRecord(filename string, record *AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
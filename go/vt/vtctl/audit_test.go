@@ -0,0 +1,91 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtctl
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestIsAdminCommand(t *testing.T) {
+	if !isAdminCommand("ReparentShard") {
+		t.Errorf("ReparentShard should be an admin command")
+	}
+	if isAdminCommand("GetTablet") {
+		t.Errorf("GetTablet should not be an admin command")
+	}
+	// make sure all read-only commands exist, in case one is renamed
+	for name := range readOnlyCommands {
+		found := false
+		for _, group := range commands {
+			for _, cmd := range group.commands {
+				if strings.ToLower(cmd.name) == name {
+					found = true
+				}
+			}
+		}
+		if !found {
+			t.Errorf("read-only command %v doesn't exist", name)
+		}
+	}
+	// walk every registered command: the ones named after a change
+	// can't be read-only
+	writeVerbs := []string{"Set", "Delete", "Remove", "Apply", "Create", "Release", "Freeze", "Unfreeze", "Load", "Fix", "Repair", "Clean", "Scrap", "Rebuild", "Init", "Reparent", "Import", "Copy", "Execute", "Drain", "Restore", "Clone"}
+	for _, group := range commands {
+		for _, cmd := range group.commands {
+			if isAdminCommand(cmd.name) {
+				continue
+			}
+			for _, verb := range writeVerbs {
+				if strings.HasPrefix(cmd.name, verb) {
+					t.Errorf("command %v is read-only, but looks like it changes the cluster", cmd.name)
+				}
+			}
+		}
+	}
+	for _, name := range []string{"LoadTable", "FreezeShard", "UnfreezeShard", "RepairReplicationGraph", "FixSrvKeyspace", "SetKeyspaceShardingScheme", "CleanOrphans", "SetTabletTags", "CreateConsistentSnapshot", "ReleaseConsistentSnapshot"} {
+		if !isAdminCommand(name) {
+			t.Errorf("%v should be an admin command", name)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vtctl_audit")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	*auditLog = path.Join(dir, "audit.log")
+	defer func() { *auditLog = "" }()
+
+	ctx := context.Background()
+	auditCommand(ctx, []string{"ReparentShard", "ks/0", "cell-1"}, time.Now(), nil)
+	auditCommand(ctx, []string{"DeleteShard", "ks/1"}, time.Now(), errors.New("shard not empty"))
+
+	data, err := ioutil.ReadFile(*auditLog)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %v audit records, want 2: %v", len(lines), string(data))
+	}
+	var record AuditRecord
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatalf("invalid record %v: %v", lines[1], err)
+	}
+	if record.Command != "DeleteShard" || len(record.Args) != 1 || record.Args[0] != "ks/1" || record.Error != "shard not empty" || !strings.HasPrefix(record.Caller, "local:") {
+		t.Errorf("unexpected record: %#v", record)
+	}
+}
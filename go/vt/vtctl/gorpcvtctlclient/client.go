@@ -14,16 +14,24 @@ import (
 	"github.com/youtube/vitess/go/vt/logutil"
 	"github.com/youtube/vitess/go/vt/vtctl/gorpcproto"
 	"github.com/youtube/vitess/go/vt/vtctl/vtctlclient"
+	"github.com/youtube/vitess/go/vt/vttls"
 	"golang.org/x/net/context"
 )
 
+var vtctlBsonTLS = vttls.RegisterClientFlags("vtctl-bson", "vtctld")
+
 type goRPCVtctlClient struct {
 	rpcClient *rpc.Client
 }
 
 func goRPCVtctlClientFactory(addr string, dialTimeout time.Duration) (vtctlclient.VtctlClient, error) {
+	config, err := vtctlBsonTLS.Config()
+	if err != nil {
+		return nil, err
+	}
+
 	// create the RPC client
-	rpcClient, err := bsonrpc.DialHTTP("tcp", addr, dialTimeout, nil)
+	rpcClient, err := bsonrpc.DialHTTP("tcp", addr, dialTimeout, config)
 	if err != nil {
 		return nil, fmt.Errorf("RPC error for %v: %v", addr, err)
 	}
@@ -11,6 +11,7 @@ package gorpcvtctlserver
 import (
 	"sync"
 
+	"github.com/youtube/vitess/go/vt/callinfo"
 	"github.com/youtube/vitess/go/vt/logutil"
 	"github.com/youtube/vitess/go/vt/servenv"
 	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
@@ -51,8 +52,8 @@ func (s *VtctlServer) ExecuteVtctlCommand(ctx context.Context, query *gorpcproto
 
 	// create the wrangler
 	wr := wrangler.New(logger, s.ts, tmclient.NewTabletManagerClient(), query.LockTimeout)
-	// FIXME(alainjobart) use a single context, copy the source info from it
-	ctx, cancel := context.WithTimeout(context.TODO(), query.ActionTimeout)
+	// keep the caller info, for authorization and audit
+	ctx, cancel := context.WithTimeout(callinfo.RPCWrapCallInfo(ctx), query.ActionTimeout)
 
 	// execute the command
 	err = vtctl.RunCommand(ctx, wr, query.Args)
@@ -14,6 +14,7 @@ import (
 	"sync"
 
 	"github.com/youtube/vitess/go/sync2"
+	"github.com/youtube/vitess/go/vt/topo"
 	"github.com/youtube/vitess/go/vt/wrangler"
 	"github.com/youtube/vitess/go/vt/zktopo"
 	"github.com/youtube/vitess/go/zk"
@@ -43,7 +44,7 @@ func init() {
 }
 
 func zkResolveWildcards(wr *wrangler.Wrangler, args []string) ([]string, error) {
-	zkts, ok := wr.TopoServer().(*zktopo.Server)
+	zkts, ok := topo.UnwrapServer(wr.TopoServer()).(*zktopo.Server)
 	if !ok {
 		return args, nil
 	}
@@ -65,7 +66,7 @@ func commandPruneActionLogs(ctx context.Context, wr *wrangler.Wrangler, subFlags
 		return err
 	}
 
-	zkts, ok := wr.TopoServer().(*zktopo.Server)
+	zkts, ok := topo.UnwrapServer(wr.TopoServer()).(*zktopo.Server)
 	if !ok {
 		return fmt.Errorf("PruneActionLogs requires a zktopo.Server")
 	}
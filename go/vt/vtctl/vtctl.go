@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -23,9 +24,11 @@ import (
 	hk "github.com/youtube/vitess/go/vt/hook"
 	"github.com/youtube/vitess/go/vt/key"
 	"github.com/youtube/vitess/go/vt/logutil"
+	"github.com/youtube/vitess/go/vt/mysqlctl"
 	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
-	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
+	"github.com/youtube/vitess/go/vt/servenv"
 	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/topo/helpers"
 	"github.com/youtube/vitess/go/vt/topotools"
 	"github.com/youtube/vitess/go/vt/wrangler"
 	"golang.org/x/net/context"
@@ -62,6 +65,9 @@ var commands = []commandGroup{
 			command{"UpdateTabletAddrs", commandUpdateTabletAddrs,
 				"[-hostname <hostname>] [-ip-addr <ip addr>] [-mysql-port <mysql port>] [-vt-port <vt port>] [-vts-port <vts port>] <tablet alias> ",
 				"Updates the addresses of a tablet."},
+			command{"SetTabletTags", commandSetTabletTags,
+				"<tablet alias> <key:value,...>",
+				"Sets tags of a tablet, like its rack or maintenance window. A tag with an empty value (key:) is removed, the other tags are kept."},
 			command{"ScrapTablet", commandScrapTablet,
 				"[-force] [-skip-rebuild] <tablet alias>",
 				"Scraps a tablet."},
@@ -80,6 +86,9 @@ var commands = []commandGroup{
 					"NOTE: This will automatically update the serving graph.\n" +
 					"Valid <tablet type>:\n" +
 					"  " + strings.Join(topo.MakeStringTypeList(topo.SlaveTabletTypes), " ")},
+			command{"DrainTablet", commandDrainTablet,
+				"[-lame_duck_period=<duration>] [-drained_type=spare] <tablet alias>",
+				"Gracefully takes a serving tablet out of service: removes it from the serving graph, waits for the lame duck period, then changes its type to the drained type, waiting for in-flight queries to finish. Returns once the tablet is fully drained."},
 			command{"Ping", commandPing,
 				"<tablet alias>",
 				"Check that the agent is awake and responding to RPCs. Can be blocked by other in-flight operations."},
@@ -110,9 +119,13 @@ var commands = []commandGroup{
 				"[-fetch-concurrency=3] [-fetch-retry-count=3] [-dont-wait-for-slave-start] <src tablet alias> <src manifest file> <dst tablet alias> [<new master tablet alias>]",
 				"Copy the given snaphot from the source tablet and restart replication to the new master path (or uses the <src tablet path> if not specified). If <src manifest file> is 'default', uses the default value.\n" +
 					"NOTE: This does not wait for replication to catch up. The destination tablet must be 'idle' to begin with. It will transition to 'spare' once the restore is complete."},
+			command{"CleanOrphans", commandCleanOrphans,
+				"[-max_age=24h] [-dry_run] <tablet alias>",
+				"Removes the files left behind by failed snapshots and restores on the tablet, that weren't modified for max_age (at least 1h), and prints them with the reclaimed space."},
 			command{"Clone", commandClone,
-				"[-force] [-concurrency=4] [-fetch-concurrency=3] [-fetch-retry-count=3] [-server-mode] <src tablet alias> <dst tablet alias> ...",
-				"This performs Snapshot and then Restore on all the targets in parallel. The advantage of having separate actions is that one snapshot can be used for many restores, and it's then easier to spread them over time."},
+				"[-force] [-concurrency=4] [-fetch-concurrency=3] [-fetch-retry-count=3] [-server-mode] [-source-shard=<keyspace/shard>] [-source-tags=<key:value,...>] <src tablet alias>|<dst tablet alias> <dst tablet alias> ...",
+				"This performs Snapshot and then Restore on all the targets in parallel. The advantage of having separate actions is that one snapshot can be used for many restores, and it's then easier to spread them over time.\n" +
+					"With -source-shard, all the arguments are targets, and the source is chosen among the tablets of the shard: rdonly first, then in the cell of the first target, then with the lowest replication lag. With -source-tags, only the tablets that have all these tags are candidates."},
 			command{"ExecuteHook", commandExecuteHook,
 				"<tablet alias> <hook name> [<param1=value1> <param2=value2> ...]",
 				"This runs the specified hook on the given tablet."},
@@ -138,12 +151,24 @@ var commands = []commandGroup{
 			command{"ValidateShard", commandValidateShard,
 				"[-ping-tablets] <keyspace/shard>",
 				"Validate all nodes reachable from this shard are consistent."},
+			command{"RollingRestartShard", commandRollingRestartShard,
+				"[-hook=restart] [-min_serving=1] [-lame_duck_period=<duration>] [-max_replication_lag=<duration>] [-wait_time=<duration>] [-health_timeout=<duration>] <keyspace/shard>",
+				"Restarts the serving slaves of a shard one at a time: each tablet is drained, restarted by running the hook locally, waited on until it reports it is healthy and caught up, and put back in service. The tablets need to run the health check."},
 			command{"ShardReplicationPositions", commandShardReplicationPositions,
 				"<keyspace/shard>",
 				"Show slave status on all machines in the shard graph."},
 			command{"ListShardTablets", commandListShardTablets,
-				"<keyspace/shard>)",
-				"List all tablets in a given shard."},
+				"[-tags=<key:value,...>] <keyspace/shard>)",
+				"List all tablets in a given shard. With -tags, only lists the tablets that have all these tags."},
+			command{"ListShards", commandListShards,
+				"[-keyspace=<keyspace>] [-cells=a,b] [-page_size=<size>] [-page_token=<token>]",
+				"Lists the shards of a keyspace, or of all keyspaces, that have tablets in one of the cells, one page at a time. The token of the next page, if any, is logged after the shards."},
+			command{"ListBackups", commandListBackups,
+				"<keyspace/shard>",
+				"Lists the kept backups of a shard, taken by the backup janitor, oldest first."},
+			command{"VerifyBackups", commandVerifyBackups,
+				"<keyspace/shard>",
+				"Checks the tablets of the kept backups of a shard still serve their snapshot manifest. Fails if one of them doesn't."},
 			command{"SetShardServedTypes", commandSetShardServedTypes,
 				"<keyspace/shard> [<served type1>,<served type2>,...]",
 				"Sets a given shard's served types. Does not rebuild any serving graph."},
@@ -165,6 +190,21 @@ var commands = []commandGroup{
 			command{"ShardReplicationFix", commandShardReplicationFix,
 				"<cell> <keyspace/shard>",
 				"Walks through a ShardReplication object and fixes the first error it encrounters."},
+			command{"RepairReplicationGraph", commandRepairReplicationGraph,
+				"[-dry-run] <keyspace/shard>",
+				"Compares the replication graph and the master records of a shard with the actual MySQL replication of its tablets, and fixes them. With -dry-run, only displays the problems."},
+			command{"FreezeShard", commandFreezeShard,
+				"<keyspace/shard>",
+				"Makes a shard read-only, before a risky maintenance or an external repair of its data: its tablets refuse writes, the MySQL of its master is made read-only, and its serving graph is marked read-only."},
+			command{"UnfreezeShard", commandUnfreezeShard,
+				"<keyspace/shard>",
+				"Makes a frozen shard accept writes again, after verifying its master is still the master of its MySQL replication."},
+			command{"DumpTable", commandDumpTable,
+				"[-format=csv|sql] [-key_range=<keyrange>] [-snapshot=<name>] <keyspace/shard> <table> <output file>",
+				"Dumps the rows of a table, or only the ones in a keyrange, to a CSV file or to INSERT statements. The rows are read with a single query from a rdonly tablet of the shard, or from its tablet in a consistent snapshot, and the replication position of the dump is displayed."},
+			command{"LoadTable", commandLoadTable,
+				"[-format=csv|sql] [-batch_size=N] [-max_rows_per_second=N] <keyspace/shard> <table> <input file>",
+				"Loads a file written by DumpTable into a table, through the query service of the master of the shard, a batch of rows per transaction."},
 			command{"RemoveShardCell", commandRemoveShardCell,
 				"[-force] <keyspace/shard> <cell>",
 				"Removes the cell in the shard's Cells list."},
@@ -184,6 +224,9 @@ var commands = []commandGroup{
 			command{"SetKeyspaceShardingInfo", commandSetKeyspaceShardingInfo,
 				"[-force] [-split_shard_count=N] <keyspace name> [<column name>] [<column type>]",
 				"Updates the sharding info for a keyspace."},
+			command{"SetKeyspaceShardingScheme", commandSetKeyspaceShardingScheme,
+				"[-force] [-params=name1:value1,name2:value2,...] <keyspace name> [<scheme>]",
+				"Sets the vindex type that computes the keyspace ids from the sharding column values of a keyspace, like hash or lookup_hash_unique. Without a scheme, the values are the keyspace ids."},
 			command{"SetKeyspaceServedFrom", commandSetKeyspaceServedFrom,
 				"[-source=<source keyspace name>] [-remove] [-cells=c1,c2,...] <keyspace name> <tablet type>",
 				"Manually change the ServedFromMap. Only use this for an emergency fix. MigrateServedFrom will set this field appropriately already. Does not rebuild the serving graph."},
@@ -193,15 +236,30 @@ var commands = []commandGroup{
 			command{"ValidateKeyspace", commandValidateKeyspace,
 				"[-ping-tablets] <keyspace name>",
 				"Validate all nodes reachable from this keyspace are consistent."},
+			command{"FixSrvKeyspace", commandFixSrvKeyspace,
+				"<keyspace name>",
+				"Rebuild the serving graph of the keyspace in the cells where its partitions have gaps or overlaps (as reported by ValidateKeyspace)."},
 			command{"MigrateServedTypes", commandMigrateServedTypes,
 				"[-cells=c1,c2,...] [-reverse] [-skip-refresh-state] <keyspace/shard> <served type>",
 				"Migrates a serving type from the source shard to the shards it replicates to. Will also rebuild the serving graph. keyspace/shard can be any of the involved shards in the migration."},
+			command{"MigrateServedTypesDryRun", commandMigrateServedTypesDryRun,
+				"[-cells=c1,c2,...] [-reverse] <keyspace/shard> <served type>",
+				"Reports what MigrateServedTypes would change, without changing it: the rewritten serving graph partitions per cell, the tablets that stop or start serving, the filtered replication lag of the destination masters, and the estimated unavailability of a master migration."},
 			command{"MigrateServedFrom", commandMigrateServedFrom,
 				"[-cells=c1,c2,...] [-reverse] <destination keyspace/shard> <served type>",
 				"Makes the destination keyspace/shard serve the given type. Will also rebuild the serving graph."},
 			command{"FindAllShardsInKeyspace", commandFindAllShardsInKeyspace,
 				"<keyspace>",
 				"Displays all the shards in a keyspace."},
+			command{"CreateConsistentSnapshot", commandCreateConsistentSnapshot,
+				"[-wait_time=<duration>] <keyspace> <name>",
+				"Stops one rdonly tablet per shard of the keyspace at a common point, for analytics jobs to read all the shards as of that point. The tablets are changed to checker and tagged with the name of the snapshot. Outputs the tablets and their positions. Needs a MySQL flavor with START SLAVE UNTIL a GTID position (MariaDB)."},
+			command{"GetConsistentSnapshot", commandGetConsistentSnapshot,
+				"<keyspace> <name>",
+				"Outputs the tablets of a consistent snapshot and their positions."},
+			command{"ReleaseConsistentSnapshot", commandReleaseConsistentSnapshot,
+				"<keyspace> <name>",
+				"Restarts replication on the tablets of a consistent snapshot, and changes them back to rdonly."},
 		},
 	},
 	commandGroup{
@@ -216,11 +274,26 @@ var commands = []commandGroup{
 				"<cell1>,<cell2>... <keyspace1>,<keyspace2>,...",
 				"HIDDEN This takes the Thor's hammer approach of recovery and should only be used in emergencies.  cell1,cell2,... are the canonical source of data for the system. This function uses that canonical data to recover the replication graph, at which point further auditing with Validate can reveal any remaining issues."},
 			command{"ListAllTablets", commandListAllTablets,
-				"<cell name>",
-				"List all tablets in an awk-friendly way."},
+				"[-tags=<key:value,...>] <cell name>",
+				"List all tablets in an awk-friendly way. With -tags, only lists the tablets that have all these tags."},
 			command{"ListTablets", commandListTablets,
-				"<tablet alias> ...",
-				"List specified tablets in an awk-friendly way."},
+				"[-tags=<key:value,...>] <tablet alias> ...",
+				"List specified tablets in an awk-friendly way. With -tags, only lists the tablets that have all these tags."},
+			command{"FindTablets", commandFindTablets,
+				"[-cells=a,b] [-keyspace=<keyspace>] [-shard=<shard>] [-types=<type1>,<type2>,...] [-tags=<key:value,...>] [-page_size=<size>] [-page_token=<token>]",
+				"List the tablets that match all the filters in an awk-friendly way, one page at a time. The token of the next page, if any, is logged after the tablets."},
+			command{"AddCell", commandAddCell,
+				"[-addrs=<addr1>,<addr2>,...] <cell>",
+				"Creates the topology of a new cell, checks it is reachable, and rebuilds the serving graph of all keyspaces there. -addrs are the addresses of the cell topology servers, for the implementations that store them in the global topology."},
+			command{"RemoveCell", commandRemoveCell,
+				"[-force] <cell>",
+				"Removes a cell from all shards and deletes its serving graph, after checking no tablet there is in the serving graph. With -force, a cell whose topology server is down is removed anyway."},
+			command{"ExportTopology", commandExportTopology,
+				"<file>",
+				"Saves the keyspaces, shards, tablets, replication graph and vschema of all cells to a JSON file."},
+			command{"ImportTopology", commandImportTopology,
+				"[-force] <file>",
+				"Loads a file saved by ExportTopology into the topology. Fails without writing anything if some objects already exist with different values, unless -force is specified. The serving graph needs to be rebuilt afterwards."},
 			command{"Panic", commandPanic,
 				"",
 				"HIDDEN Triggers a panic on the server side, to test the handling."},
@@ -234,12 +307,18 @@ var commands = []commandGroup{
 			command{"ReloadSchema", commandReloadSchema,
 				"<tablet alias>",
 				"Asks a remote tablet to reload its schema."},
+			command{"ReloadSchemaShard", commandReloadSchemaShard,
+				"[-concurrency=16] <keyspace/shard>",
+				"Asks all the serving tablets in a shard to reload their schema, and reports the result for each tablet."},
+			command{"GetSchemaVersions", commandGetSchemaVersions,
+				"[-stale-only] <keyspace name|keyspace/shard>",
+				"Display the schema version each tablet of the keyspace or shard last published, and flag the ones that are different from their shard master (or unknown) as stale. With -stale-only, only the stale tablets are displayed. Fails if any tablet is stale."},
 			command{"ValidateSchemaShard", commandValidateSchemaShard,
 				"[-exclude_tables=''] [-include-views] <keyspace/shard>",
 				"Validate the master schema matches all the slaves."},
 			command{"ValidateSchemaKeyspace", commandValidateSchemaKeyspace,
-				"[-exclude_tables=''] [-include-views] <keyspace name>",
-				"Validate the master schema from shard 0 matches all the other tablets in the keyspace."},
+				"[-exclude_tables=''] [-include-views] [-reference_shard=<shard>] [-skip-non-master] <keyspace name>",
+				"Validate the master schema from the reference shard (shard 0 by default) matches all the other tablets in the keyspace. With -skip-non-master, only the shard masters are compared."},
 			command{"PreflightSchema", commandPreflightSchema,
 				"{-sql=<sql> || -sql-file=<filename>} <tablet alias>",
 				"Apply the schema change to a temporary database to gather before and after schema and validate the change. The sql can be inlined or read from a file."},
@@ -253,8 +332,8 @@ var commands = []commandGroup{
 				"[-force] {-sql=<sql> || -sql-file=<filename>} [-simple] <keyspace>",
 				"Apply the schema change to the specified keyspace. If simple is specified, we just apply on the live masters. Otherwise we will need to do the shell game on each shard. So we will apply the schema change to every single slave (running in parallel on all shards, but on one host at a time in a given shard). We will not reparent at the end, so the masters won't be touched at all. Using the force flag will cause a bunch of checks to be ignored, use with care."},
 			command{"CopySchemaShard", commandCopySchemaShard,
-				"[-tables=<table1>,<table2>,...] [-exclude_tables=<table1>,<table2>,...] [-include-views] <src tablet alias> <dest keyspace/shard>",
-				"Copy the schema from a source tablet to the specified shard. The schema is applied directly on the master of the destination shard, and is propogated to the replicas through binlogs."},
+				"[-tables=<table1>,<table2>,...] [-exclude_tables=<table1>,<table2>,...] [-include-views] [-strip-auto-increment] <src tablet alias> <dest keyspace/shard>",
+				"Copy the schema from a source tablet to the specified shard. The schema is applied directly on the master of the destination shard, and is propogated to the replicas through binlogs. With -strip-auto-increment, the AUTO_INCREMENT value of the source tables is not copied."},
 
 			command{"ValidateVersionShard", commandValidateVersionShard,
 				"<keyspace/shard>",
@@ -348,35 +427,32 @@ func fmtTabletAwkable(ti *topo.TabletInfo) string {
 	return fmt.Sprintf("%v %v %v %v %v %v %v", ti.Alias, keyspace, shard, ti.Type, ti.Addr(), ti.MysqlAddr(), fmtMapAwkable(ti.Tags))
 }
 
-func fmtAction(action *actionnode.ActionNode) string {
-	state := string(action.State)
-	// FIXME(msolomon) The default state should really just have the value "queued".
-	if action.State == actionnode.ACTION_STATE_QUEUED {
-		state = "queued"
-	}
-	return fmt.Sprintf("%v %v %v %v %v", action.Path, action.Action, state, action.ActionGuid, action.Error)
-}
-
-func listTabletsByShard(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string) error {
+// listTabletsByShard lists the tablets of a shard that have all
+// the tags.
+func listTabletsByShard(ctx context.Context, wr *wrangler.Wrangler, keyspace, shard string, tags map[string]string) error {
 	tabletAliases, err := topo.FindAllTabletAliasesInShard(ctx, wr.TopoServer(), keyspace, shard)
 	if err != nil {
 		return err
 	}
-	return dumpTablets(ctx, wr, tabletAliases)
+	return dumpTablets(ctx, wr, tabletAliases, tags)
 }
 
-func dumpAllTablets(ctx context.Context, wr *wrangler.Wrangler, zkVtPath string) error {
+// dumpAllTablets lists the tablets of a cell that have all the tags.
+func dumpAllTablets(ctx context.Context, wr *wrangler.Wrangler, zkVtPath string, tags map[string]string) error {
 	tablets, err := topotools.GetAllTablets(ctx, wr.TopoServer(), zkVtPath)
 	if err != nil {
 		return err
 	}
 	for _, ti := range tablets {
-		wr.Logger().Printf("%v\n", fmtTabletAwkable(ti))
+		if topo.MatchTags(ti.Tags, tags) {
+			wr.Logger().Printf("%v\n", fmtTabletAwkable(ti))
+		}
 	}
 	return nil
 }
 
-func dumpTablets(ctx context.Context, wr *wrangler.Wrangler, tabletAliases []topo.TabletAlias) error {
+// dumpTablets lists the tablets that have all the tags.
+func dumpTablets(ctx context.Context, wr *wrangler.Wrangler, tabletAliases []topo.TabletAlias, tags map[string]string) error {
 	tabletMap, err := topo.GetTabletMap(ctx, wr.TopoServer(), tabletAliases)
 	if err != nil {
 		return err
@@ -385,7 +461,7 @@ func dumpTablets(ctx context.Context, wr *wrangler.Wrangler, tabletAliases []top
 		ti, ok := tabletMap[tabletAlias]
 		if !ok {
 			log.Warningf("failed to load tablet %v", tabletAlias)
-		} else {
+		} else if topo.MatchTags(ti.Tags, tags) {
 			wr.Logger().Printf("%v\n", fmtTabletAwkable(ti))
 		}
 	}
@@ -640,6 +716,25 @@ func commandUpdateTabletAddrs(ctx context.Context, wr *wrangler.Wrangler, subFla
 	})
 }
 
+func commandSetTabletTags(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 2 {
+		return fmt.Errorf("action SetTabletTags requires <tablet alias> <key:value,...>")
+	}
+
+	tabletAlias, err := topo.ParseTabletAliasString(subFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var tags flagutil.StringMapValue
+	if err := tags.Set(subFlags.Arg(1)); err != nil {
+		return err
+	}
+	return wr.SetTabletTags(ctx, tabletAlias, tags)
+}
+
 func commandScrapTablet(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
 	force := subFlags.Bool("force", false, "writes the scrap state in to zk, no questions asked, if a tablet is offline")
 	skipRebuild := subFlags.Bool("skip-rebuild", false, "do not rebuild the shard and keyspace graph after scrapping")
@@ -739,8 +834,8 @@ func commandChangeSlaveType(ctx context.Context, wr *wrangler.Wrangler, subFlags
 		if err != nil {
 			return fmt.Errorf("failed reading tablet %v: %v", tabletAlias, err)
 		}
-		if !topo.IsTrivialTypeChange(ti.Type, newType) || !topo.IsValidTypeChange(ti.Type, newType) {
-			return fmt.Errorf("invalid type transition %v: %v -> %v", tabletAlias, ti.Type, newType)
+		if err := topo.CheckTypeChange(ti.Type, newType); err != nil {
+			return fmt.Errorf("invalid type transition for %v: %v", tabletAlias, err)
 		}
 		wr.Logger().Printf("- %v\n", fmtTabletAwkable(ti))
 		ti.Type = newType
@@ -750,6 +845,27 @@ func commandChangeSlaveType(ctx context.Context, wr *wrangler.Wrangler, subFlags
 	return wr.ChangeType(ctx, tabletAlias, newType, *force)
 }
 
+func commandDrainTablet(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	lameDuckPeriod := subFlags.Duration("lame_duck_period", 5*time.Second, "how long to keep the tablet serving after removing it from the serving graph")
+	drainedType := subFlags.String("drained_type", string(topo.TYPE_SPARE), "the non-serving type the tablet will end up with")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action DrainTablet requires <tablet alias>")
+	}
+
+	tabletAlias, err := topo.ParseTabletAliasString(subFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+	newType, err := parseTabletType(*drainedType, topo.SlaveTabletTypes)
+	if err != nil {
+		return err
+	}
+	return wr.DrainTablet(ctx, tabletAlias, newType, *lameDuckPeriod)
+}
+
 func commandPing(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
 	if err := subFlags.Parse(args); err != nil {
 		return err
@@ -939,7 +1055,30 @@ func commandRestore(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.F
 			return err
 		}
 	}
-	return wr.Restore(ctx, srcTabletAlias, subFlags.Arg(1), dstTabletAlias, parentAlias, *fetchConcurrency, *fetchRetryCount, false, *dontWaitForSlaveStart)
+	return wr.Restore(ctx, srcTabletAlias, subFlags.Arg(1), dstTabletAlias, parentAlias, *fetchConcurrency, *fetchRetryCount, 1, false, *dontWaitForSlaveStart)
+}
+
+func commandCleanOrphans(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	maxAge := subFlags.Duration("max_age", 24*time.Hour, "only remove the files that weren't modified for this long")
+	dryRun := subFlags.Bool("dry_run", false, "only print the files that would be removed")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action CleanOrphans requires <tablet alias>")
+	}
+	if *maxAge < mysqlctl.MinOrphanMaxAge {
+		return fmt.Errorf("action CleanOrphans requires a -max_age of at least %v, the files of a running snapshot or restore could be removed", mysqlctl.MinOrphanMaxAge)
+	}
+	tabletAlias, err := topo.ParseTabletAliasString(subFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+	report, err := wr.CleanOrphans(ctx, tabletAlias, *maxAge, *dryRun)
+	if err == nil {
+		wr.Logger().Printf("%v\n", jscfg.ToJson(report))
+	}
+	return err
 }
 
 func commandClone(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
@@ -948,23 +1087,43 @@ func commandClone(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.Fla
 	fetchConcurrency := subFlags.Int("fetch-concurrency", 3, "how many files to fetch simultaneously")
 	fetchRetryCount := subFlags.Int("fetch-retry-count", 3, "how many times to retry a failed transfer")
 	serverMode := subFlags.Bool("server-mode", false, "will keep the snapshot server offline to serve DB files directly")
+	sourceShard := subFlags.String("source-shard", "", "if specified, the source tablet is chosen in this keyspace/shard, and all arguments are targets")
+	var sourceTags flagutil.StringMapValue
+	subFlags.Var(&sourceTags, "source-tags", "with -source-shard, only choose a source tablet that has all these comma separated key:value tags")
 	if err := subFlags.Parse(args); err != nil {
 		return err
 	}
-	if subFlags.NArg() < 2 {
+	if *sourceShard != "" {
+		if subFlags.NArg() < 1 {
+			return fmt.Errorf("action Clone -source-shard requires <dst tablet alias> [...]")
+		}
+	} else if subFlags.NArg() < 2 {
 		return fmt.Errorf("action Clone requires <src tablet alias> <dst tablet alias> [...]")
 	}
 
-	srcTabletAlias, err := topo.ParseTabletAliasString(subFlags.Arg(0))
-	if err != nil {
-		return err
+	var tabletAliases []topo.TabletAlias
+	for _, arg := range subFlags.Args() {
+		tabletAlias, err := topo.ParseTabletAliasString(arg)
+		if err != nil {
+			return err
+		}
+		tabletAliases = append(tabletAliases, tabletAlias)
 	}
-	dstTabletAliases := make([]topo.TabletAlias, subFlags.NArg()-1)
-	for i := 1; i < subFlags.NArg(); i++ {
-		dstTabletAliases[i-1], err = topo.ParseTabletAliasString(subFlags.Arg(i))
+	var srcTabletAlias topo.TabletAlias
+	var dstTabletAliases []topo.TabletAlias
+	if *sourceShard != "" {
+		keyspace, shard, err := topo.ParseKeyspaceShardString(*sourceShard)
+		if err != nil {
+			return err
+		}
+		dstTabletAliases = tabletAliases
+		srcTabletAlias, err = wr.ChooseSnapshotSource(ctx, keyspace, shard, dstTabletAliases[0].Cell, sourceTags)
 		if err != nil {
 			return err
 		}
+	} else {
+		srcTabletAlias = tabletAliases[0]
+		dstTabletAliases = tabletAliases[1:]
 	}
 	return wr.Clone(ctx, srcTabletAlias, dstTabletAliases, *force, *concurrency, *fetchConcurrency, *fetchRetryCount, *serverMode)
 }
@@ -1120,6 +1279,34 @@ func commandValidateShard(ctx context.Context, wr *wrangler.Wrangler, subFlags *
 	return wr.ValidateShard(ctx, keyspace, shard, *pingTablets)
 }
 
+func commandRollingRestartShard(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	hookName := subFlags.String("hook", "restart", "the local hook to run to restart each tablet, with its --tablet_alias and --tablet_hostname")
+	minServing := subFlags.Int("min_serving", 1, "abort if restarting a tablet would leave fewer healthy serving tablets of its type in the shard")
+	lameDuckPeriod := subFlags.Duration("lame_duck_period", 5*time.Second, "how long to keep a tablet serving after removing it from the serving graph")
+	maxReplicationLag := subFlags.Duration("max_replication_lag", 10*time.Second, "how far behind a restarted tablet can be before it is put back in service")
+	waitTime := subFlags.Duration("wait_time", 10*time.Minute, "how long to wait for a restarted tablet to be healthy")
+	healthTimeout := subFlags.Duration("health_timeout", time.Minute, "how long to wait for the health report of a tablet, above the tablets -health_check_interval")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action RollingRestartShard requires <keyspace/shard>")
+	}
+
+	keyspace, shard, err := topo.ParseKeyspaceShardString(subFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return wr.RollingRestartShard(ctx, keyspace, shard, &wrangler.RollingRestartOptions{
+		HookName:          *hookName,
+		MinServing:        *minServing,
+		LameDuckPeriod:    *lameDuckPeriod,
+		MaxReplicationLag: *maxReplicationLag,
+		WaitTime:          *waitTime,
+		HealthTimeout:     *healthTimeout,
+	})
+}
+
 func commandShardReplicationPositions(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
 	if err := subFlags.Parse(args); err != nil {
 		return err
@@ -1153,6 +1340,8 @@ func commandShardReplicationPositions(ctx context.Context, wr *wrangler.Wrangler
 }
 
 func commandListShardTablets(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	var tags flagutil.StringMapValue
+	subFlags.Var(&tags, "tags", "only list the tablets that have all these comma separated key:value tags")
 	if err := subFlags.Parse(args); err != nil {
 		return err
 	}
@@ -1163,7 +1352,87 @@ func commandListShardTablets(ctx context.Context, wr *wrangler.Wrangler, subFlag
 	if err != nil {
 		return err
 	}
-	return listTabletsByShard(ctx, wr, keyspace, shard)
+	return listTabletsByShard(ctx, wr, keyspace, shard, tags)
+}
+
+func commandListShards(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	keyspace := subFlags.String("keyspace", "", "only list the shards of this keyspace")
+	cells := subFlags.String("cells", "", "comma separated list of cells, only list the shards that have tablets there")
+	pageSize := subFlags.Int("page_size", 0, "maximum number of shards to list, 0 for all of them")
+	pageToken := subFlags.String("page_token", "", "token of the page to list, as returned by the previous page")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 0 {
+		return fmt.Errorf("action ListShards doesn't take any parameter")
+	}
+
+	var cellList []string
+	if *cells != "" {
+		cellList = strings.Split(*cells, ",")
+	}
+	shards, nextPageToken, err := topo.FindShards(ctx, wr.TopoServer(), *keyspace, cellList, *pageToken, *pageSize)
+	if err != nil {
+		return err
+	}
+	for _, si := range shards {
+		wr.Logger().Printf("%v/%v\n", si.Keyspace(), si.ShardName())
+	}
+	if nextPageToken != "" {
+		wr.Logger().Infof("next page token: %v", nextPageToken)
+	}
+	return nil
+}
+
+func commandListBackups(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action ListBackups requires <keyspace/shard>")
+	}
+	keyspace, shard, err := topo.ParseKeyspaceShardString(subFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+	backups, err := wr.ListBackups(ctx, keyspace, shard)
+	if err != nil {
+		return err
+	}
+	for _, b := range backups {
+		wr.Logger().Printf("%v %v %v %v\n", b.Time.Format(time.RFC3339), b.TabletAlias, b.ManifestPath, b.Duration)
+	}
+	return nil
+}
+
+func commandVerifyBackups(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action VerifyBackups requires <keyspace/shard>")
+	}
+	keyspace, shard, err := topo.ParseKeyspaceShardString(subFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+	backups, err := wr.ListBackups(ctx, keyspace, shard)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, b := range backups {
+		if err := wr.VerifyBackup(ctx, b); err != nil {
+			wr.Logger().Errorf("backup %v of tablet %v: %v", b.Time.Format(time.RFC3339), b.TabletAlias, err)
+			failed++
+			continue
+		}
+		wr.Logger().Printf("backup %v of tablet %v: ok\n", b.Time.Format(time.RFC3339), b.TabletAlias)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%v of the %v backups of %v/%v failed verification", failed, len(backups), keyspace, shard)
+	}
+	return nil
 }
 
 func commandSetShardServedTypes(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
@@ -1329,6 +1598,123 @@ func commandShardReplicationFix(ctx context.Context, wr *wrangler.Wrangler, subF
 	return topo.FixShardReplication(wr.TopoServer(), wr.Logger(), cell, keyspace, shard)
 }
 
+func commandRepairReplicationGraph(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	dryRun := subFlags.Bool("dry-run", false, "only display the problems, don't fix them")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action RepairReplicationGraph requires <keyspace/shard>")
+	}
+
+	keyspace, shard, err := topo.ParseKeyspaceShardString(subFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+	problems, err := wr.RepairReplicationGraph(ctx, keyspace, shard, *dryRun)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		wr.Logger().Printf("%v\n", p)
+	}
+	if len(problems) == 0 {
+		wr.Logger().Printf("the replication graph of %v/%v matches MySQL replication\n", keyspace, shard)
+	}
+	return nil
+}
+
+func commandFreezeShard(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action FreezeShard requires <keyspace/shard>")
+	}
+
+	keyspace, shard, err := topo.ParseKeyspaceShardString(subFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return wr.FreezeShard(ctx, keyspace, shard)
+}
+
+func commandUnfreezeShard(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action UnfreezeShard requires <keyspace/shard>")
+	}
+
+	keyspace, shard, err := topo.ParseKeyspaceShardString(subFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return wr.UnfreezeShard(ctx, keyspace, shard)
+}
+
+func commandDumpTable(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	format := subFlags.String("format", wrangler.DumpFormatCSV, "format of the dump, csv or sql")
+	keyRange := subFlags.String("key_range", "", "only dump the rows in this keyrange")
+	snapshot := subFlags.String("snapshot", "", "dump from the tablet of the shard in this consistent snapshot")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 3 {
+		return fmt.Errorf("action DumpTable requires <keyspace/shard> <table> <output file>")
+	}
+
+	keyspace, shard, err := topo.ParseKeyspaceShardString(subFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var kr key.KeyRange
+	if *keyRange != "" {
+		if _, kr, err = topo.ValidateShardName(*keyRange); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(subFlags.Arg(2))
+	if err != nil {
+		return err
+	}
+	position, rowCount, err := wr.DumpTable(ctx, keyspace, shard, subFlags.Arg(1), *snapshot, kr, *format, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	wr.Logger().Printf("Dumped %v rows at position %v\n", rowCount, position)
+	return nil
+}
+
+func commandLoadTable(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	format := subFlags.String("format", wrangler.DumpFormatCSV, "format of the dump, csv or sql")
+	batchSize := subFlags.Int("batch_size", 100, "number of rows inserted by each statement")
+	maxRowsPerSecond := subFlags.Int("max_rows_per_second", 0, "maximum number of rows inserted per second, 0 for no limit")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 3 {
+		return fmt.Errorf("action LoadTable requires <keyspace/shard> <table> <input file>")
+	}
+
+	keyspace, shard, err := topo.ParseKeyspaceShardString(subFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(subFlags.Arg(2))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rowCount, err := wr.LoadTable(ctx, keyspace, shard, subFlags.Arg(1), *format, f, *batchSize, *maxRowsPerSecond)
+	wr.Logger().Printf("Loaded %v rows\n", rowCount)
+	return err
+}
+
 func commandRemoveShardCell(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
 	force := subFlags.Bool("force", false, "will keep going even we can't reach the cell's topology server to check for tablets")
 	if err := subFlags.Parse(args); err != nil {
@@ -1457,6 +1843,24 @@ func commandSetKeyspaceShardingInfo(ctx context.Context, wr *wrangler.Wrangler,
 	return wr.SetKeyspaceShardingInfo(ctx, keyspace, columnName, kit, int32(*splitShardCount), *force)
 }
 
+func commandSetKeyspaceShardingScheme(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	force := subFlags.Bool("force", false, "will update the scheme even if it's already set, use with care")
+	var params flagutil.StringMapValue
+	subFlags.Var(&params, "params", "comma separated list of name:value parameters of the scheme")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() > 2 || subFlags.NArg() < 1 {
+		return fmt.Errorf("action SetKeyspaceShardingScheme requires <keyspace name> [<scheme>]")
+	}
+
+	scheme := ""
+	if subFlags.NArg() == 2 {
+		scheme = subFlags.Arg(1)
+	}
+	return wr.SetKeyspaceShardingScheme(ctx, subFlags.Arg(0), scheme, params, *force)
+}
+
 func commandSetKeyspaceServedFrom(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
 	source := subFlags.String("source", "", "source keyspace name")
 	remove := subFlags.Bool("remove", false, "remove the served from record instead of adding it")
@@ -1519,6 +1923,17 @@ func commandValidateKeyspace(ctx context.Context, wr *wrangler.Wrangler, subFlag
 	return wr.ValidateKeyspace(ctx, keyspace, *pingTablets)
 }
 
+func commandFixSrvKeyspace(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action FixSrvKeyspace requires <keyspace name>")
+	}
+
+	return wr.FixSrvKeyspace(ctx, subFlags.Arg(0))
+}
+
 func commandMigrateServedTypes(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
 	cellsStr := subFlags.String("cells", "", "comma separated list of cells to update")
 	reverse := subFlags.Bool("reverse", false, "move the served type back instead of forward, use in case of trouble")
@@ -1549,6 +1964,36 @@ func commandMigrateServedTypes(ctx context.Context, wr *wrangler.Wrangler, subFl
 	return wr.MigrateServedTypes(ctx, keyspace, shard, cells, servedType, *reverse, *skipReFreshState, *filteredReplicationWaitTime)
 }
 
+func commandMigrateServedTypesDryRun(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	cellsStr := subFlags.String("cells", "", "comma separated list of cells to update")
+	reverse := subFlags.Bool("reverse", false, "move the served type back instead of forward")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 2 {
+		return fmt.Errorf("action MigrateServedTypesDryRun requires <source keyspace/shard> <served type>")
+	}
+
+	keyspace, shard, err := topo.ParseKeyspaceShardString(subFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+	servedType, err := parseTabletType(subFlags.Arg(1), []topo.TabletType{topo.TYPE_MASTER, topo.TYPE_REPLICA, topo.TYPE_RDONLY})
+	if err != nil {
+		return err
+	}
+	var cells []string
+	if *cellsStr != "" {
+		cells = strings.Split(*cellsStr, ",")
+	}
+	report, err := wr.MigrateServedTypesDryRun(ctx, keyspace, shard, cells, servedType, *reverse)
+	if err != nil {
+		return err
+	}
+	wr.Logger().Printf("%v\n", jscfg.ToJson(report))
+	return nil
+}
+
 func commandMigrateServedFrom(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
 	reverse := subFlags.Bool("reverse", false, "move the served from back instead of forward, use in case of trouble")
 	cellsStr := subFlags.String("cells", "", "comma separated list of cells to update")
@@ -1592,6 +2037,48 @@ func commandFindAllShardsInKeyspace(ctx context.Context, wr *wrangler.Wrangler,
 
 }
 
+func commandCreateConsistentSnapshot(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	waitTime := subFlags.Duration("wait_time", 30*time.Second, "maximum time to wait for each rdonly tablet to catch up with its master")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 2 {
+		return fmt.Errorf("action CreateConsistentSnapshot requires <keyspace> <name>")
+	}
+
+	snapshot, err := wr.CreateConsistentSnapshot(ctx, subFlags.Arg(0), subFlags.Arg(1), *waitTime)
+	if err == nil {
+		wr.Logger().Printf("%v\n", jscfg.ToJson(snapshot))
+	}
+	return err
+}
+
+func commandGetConsistentSnapshot(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 2 {
+		return fmt.Errorf("action GetConsistentSnapshot requires <keyspace> <name>")
+	}
+
+	snapshot, err := wr.GetConsistentSnapshot(ctx, subFlags.Arg(0), subFlags.Arg(1))
+	if err == nil {
+		wr.Logger().Printf("%v\n", jscfg.ToJson(snapshot))
+	}
+	return err
+}
+
+func commandReleaseConsistentSnapshot(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 2 {
+		return fmt.Errorf("action ReleaseConsistentSnapshot requires <keyspace> <name>")
+	}
+
+	return wr.ReleaseConsistentSnapshot(ctx, subFlags.Arg(0), subFlags.Arg(1))
+}
+
 func commandResolve(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
 	if err := subFlags.Parse(args); err != nil {
 		return err
@@ -1655,6 +2142,8 @@ func commandRebuildReplicationGraph(ctx context.Context, wr *wrangler.Wrangler,
 }
 
 func commandListAllTablets(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	var tags flagutil.StringMapValue
+	subFlags.Var(&tags, "tags", "only list the tablets that have all these comma separated key:value tags")
 	if err := subFlags.Parse(args); err != nil {
 		return err
 	}
@@ -1663,10 +2152,121 @@ func commandListAllTablets(ctx context.Context, wr *wrangler.Wrangler, subFlags
 	}
 
 	cell := subFlags.Arg(0)
-	return dumpAllTablets(ctx, wr, cell)
+	return dumpAllTablets(ctx, wr, cell, tags)
+}
+
+func commandFindTablets(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	cells := subFlags.String("cells", "", "comma separated list of cells, only list the tablets there")
+	keyspace := subFlags.String("keyspace", "", "only list the tablets of this keyspace")
+	shard := subFlags.String("shard", "", "only list the tablets of this shard, requires -keyspace")
+	types := subFlags.String("types", "", "comma separated list of tablet types, only list the tablets of these types")
+	var tags flagutil.StringMapValue
+	subFlags.Var(&tags, "tags", "only list the tablets that have all these comma separated key:value tags")
+	pageSize := subFlags.Int("page_size", 0, "maximum number of tablets to list, 0 for all of them")
+	pageToken := subFlags.String("page_token", "", "token of the page to list, as returned by the previous page")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 0 {
+		return fmt.Errorf("action FindTablets doesn't take any parameter")
+	}
+
+	filter := &topo.TabletFilter{
+		Keyspace: *keyspace,
+		Shard:    *shard,
+		Tags:     tags,
+	}
+	if *cells != "" {
+		filter.Cells = strings.Split(*cells, ",")
+	}
+	if *types != "" {
+		for _, t := range strings.Split(*types, ",") {
+			tabletType, err := parseTabletType(t, topo.AllTabletTypes)
+			if err != nil {
+				return err
+			}
+			filter.Types = append(filter.Types, tabletType)
+		}
+	}
+	tablets, nextPageToken, err := topo.FindTablets(ctx, wr.TopoServer(), filter, *pageToken, *pageSize)
+	switch err {
+	case nil:
+	case topo.ErrPartialResult:
+		wr.Logger().Warningf("got partial result, some tablets may be missing")
+	default:
+		return err
+	}
+	for _, ti := range tablets {
+		wr.Logger().Printf("%v\n", fmtTabletAwkable(ti))
+	}
+	if nextPageToken != "" {
+		wr.Logger().Infof("next page token: %v", nextPageToken)
+	}
+	return nil
+}
+
+func commandAddCell(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	addrs := subFlags.String("addrs", "", "comma separated list of the addresses of the cell topology servers")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action AddCell requires <cell>")
+	}
+
+	var addrList []string
+	if *addrs != "" {
+		addrList = strings.Split(*addrs, ",")
+	}
+	return wr.AddCell(ctx, subFlags.Arg(0), addrList)
+}
+
+func commandRemoveCell(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	force := subFlags.Bool("force", false, "remove the cell even if its topology server cannot be reached")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action RemoveCell requires <cell>")
+	}
+
+	return wr.RemoveCell(ctx, subFlags.Arg(0), *force)
+}
+
+func commandExportTopology(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action ExportTopology requires <file>")
+	}
+
+	archive, err := helpers.ExportTopology(wr.TopoServer())
+	if err != nil {
+		return err
+	}
+	return jscfg.WriteJson(subFlags.Arg(0), archive)
+}
+
+func commandImportTopology(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	force := subFlags.Bool("force", false, "overwrite the existing objects that have a different value")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action ImportTopology requires <file>")
+	}
+
+	archive := &helpers.TopologyArchive{}
+	if err := jscfg.ReadJson(subFlags.Arg(0), archive); err != nil {
+		return err
+	}
+	return helpers.ImportTopology(wr.TopoServer(), archive, *force)
 }
 
 func commandListTablets(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	var tags flagutil.StringMapValue
+	subFlags.Var(&tags, "tags", "only list the tablets that have all these comma separated key:value tags")
 	if err := subFlags.Parse(args); err != nil {
 		return err
 	}
@@ -1683,7 +2283,7 @@ func commandListTablets(ctx context.Context, wr *wrangler.Wrangler, subFlags *fl
 			return err
 		}
 	}
-	return dumpTablets(ctx, wr, aliases)
+	return dumpTablets(ctx, wr, aliases, tags)
 }
 
 func commandGetSchema(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
@@ -1737,6 +2337,83 @@ func commandReloadSchema(ctx context.Context, wr *wrangler.Wrangler, subFlags *f
 	return wr.ReloadSchema(ctx, tabletAlias)
 }
 
+func commandReloadSchemaShard(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	concurrency := subFlags.Int("concurrency", wrangler.DefaultBulkConcurrency, "how many tablets to reload at the same time")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action ReloadSchemaShard requires <keyspace/shard>")
+	}
+
+	keyspace, shard, err := topo.ParseKeyspaceShardString(subFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+	br, err := wr.ReloadSchemaShard(ctx, keyspace, shard, *concurrency)
+	if err != nil {
+		return err
+	}
+	wr.Logger().Printf("%v\n", br)
+	return br.Error()
+}
+
+func commandGetSchemaVersions(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
+	staleOnly := subFlags.Bool("stale-only", false, "only display the stale tablets")
+	if err := subFlags.Parse(args); err != nil {
+		return err
+	}
+	if subFlags.NArg() != 1 {
+		return fmt.Errorf("action GetSchemaVersions requires <keyspace name|keyspace/shard>")
+	}
+
+	var keyspace string
+	var shards []string
+	if strings.Contains(subFlags.Arg(0), "/") {
+		k, shard, err := topo.ParseKeyspaceShardString(subFlags.Arg(0))
+		if err != nil {
+			return err
+		}
+		keyspace, shards = k, []string{shard}
+	} else {
+		keyspace = subFlags.Arg(0)
+		var err error
+		shards, err = wr.TopoServer().GetShardNames(keyspace)
+		if err != nil {
+			return err
+		}
+		sort.Strings(shards)
+	}
+
+	stale := 0
+	for _, shard := range shards {
+		versions, err := wr.GetSchemaVersions(ctx, keyspace, shard)
+		if err != nil {
+			return err
+		}
+		for _, v := range versions {
+			if v.Stale {
+				stale++
+			} else if *staleOnly {
+				continue
+			}
+			version := v.SchemaVersion
+			if version == "" {
+				version = "<unknown>"
+			}
+			line := fmt.Sprintf("%v %v/%v %v %v", v.Alias, keyspace, shard, v.Type, version)
+			if v.Stale {
+				line += " stale"
+			}
+			wr.Logger().Printf("%v\n", line)
+		}
+	}
+	if stale > 0 {
+		return fmt.Errorf("%v tablets have a stale schema version", stale)
+	}
+	return nil
+}
+
 func commandValidateSchemaShard(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
 	excludeTables := subFlags.String("exclude_tables", "", "comma separated list of regexps for tables to exclude")
 	includeViews := subFlags.Bool("include-views", false, "include views in the validation")
@@ -1761,6 +2438,8 @@ func commandValidateSchemaShard(ctx context.Context, wr *wrangler.Wrangler, subF
 func commandValidateSchemaKeyspace(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
 	excludeTables := subFlags.String("exclude_tables", "", "comma separated list of regexps for tables to exclude")
 	includeViews := subFlags.Bool("include-views", false, "include views in the validation")
+	referenceShard := subFlags.String("reference_shard", "", "shard whose master schema is the reference (defaults to the first shard)")
+	skipNonMaster := subFlags.Bool("skip-non-master", false, "only compare the shard masters, not their replicas")
 	if err := subFlags.Parse(args); err != nil {
 		return err
 	}
@@ -1773,7 +2452,7 @@ func commandValidateSchemaKeyspace(ctx context.Context, wr *wrangler.Wrangler, s
 	if *excludeTables != "" {
 		excludeTableArray = strings.Split(*excludeTables, ",")
 	}
-	return wr.ValidateSchemaKeyspace(ctx, keyspace, excludeTableArray, *includeViews)
+	return wr.ValidateSchemaKeyspace(ctx, keyspace, *referenceShard, excludeTableArray, *includeViews, *skipNonMaster)
 }
 
 func commandPreflightSchema(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
@@ -1916,6 +2595,7 @@ func commandCopySchemaShard(ctx context.Context, wr *wrangler.Wrangler, subFlags
 	tables := subFlags.String("tables", "", "comma separated list of regexps for tables to gather schema information for")
 	excludeTables := subFlags.String("exclude_tables", "", "comma separated list of regexps for tables to exclude")
 	includeViews := subFlags.Bool("include-views", true, "include views in the output")
+	stripAutoIncrement := subFlags.Bool("strip-auto-increment", false, "do not copy the AUTO_INCREMENT table option from the source tables")
 	if err := subFlags.Parse(args); err != nil {
 		return err
 	}
@@ -1941,7 +2621,7 @@ func commandCopySchemaShard(ctx context.Context, wr *wrangler.Wrangler, subFlags
 		return err
 	}
 
-	return wr.CopySchemaShard(ctx, tabletAlias, tableArray, excludeTableArray, *includeViews, keyspace, shard)
+	return wr.CopySchemaShard(ctx, tabletAlias, tableArray, excludeTableArray, *includeViews, *stripAutoIncrement, keyspace, shard)
 }
 
 func commandValidateVersionShard(ctx context.Context, wr *wrangler.Wrangler, subFlags *flag.FlagSet, args []string) error {
@@ -2223,8 +2903,6 @@ func sortReplicatingTablets(tablets []*topo.TabletInfo, stats []*myproto.Replica
 }
 
 // RunCommand will execute the command using the provided wrangler.
-// It will return the actionPath to wait on for long remote actions if
-// applicable.
 func RunCommand(ctx context.Context, wr *wrangler.Wrangler, args []string) error {
 	if len(args) == 0 {
 		wr.Logger().Printf("No command specified. Please see the list below:\n\n")
@@ -2244,7 +2922,16 @@ func RunCommand(ctx context.Context, wr *wrangler.Wrangler, args []string) error
 					wr.Logger().Printf("%s\n\n", cmd.help)
 					subFlags.PrintDefaults()
 				}
-				return cmd.method(ctx, wr, subFlags, args[1:])
+				if !isAdminCommand(cmd.name) {
+					return cmd.method(ctx, wr, subFlags, args[1:])
+				}
+				start := time.Now()
+				err := checkAdminAccess(ctx)
+				if err == nil {
+					err = cmd.method(ctx, wr, subFlags, args[1:])
+				}
+				auditCommand(ctx, args, start, err)
+				return err
 			}
 		}
 	}
@@ -2271,6 +2958,7 @@ func PrintAllCommands(logger logutil.Logger) {
 // the command
 func HandlePanic(err *error) {
 	if x := recover(); x != nil {
+		servenv.RecordPanic("vtctl", x)
 		*err = fmt.Errorf("uncaught vtctl panic: %v", x)
 	}
 }
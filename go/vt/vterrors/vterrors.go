@@ -0,0 +1,151 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package vterrors defines canonical error codes, that are sent along
+// with the error messages through the RPC layer. Clients can then
+// classify failures without parsing the messages.
+//
+// An RPC method returns a coded error by returning an error that
+// implements rpcplus.CodedError, like the ones created here. The
+// client gets back an rpcplus.CodedServerError, and
+// RecoverVtErrorCode works on both.
+package vterrors
+
+import (
+	"fmt"
+
+	"github.com/youtube/vitess/go/rpcplus"
+	"golang.org/x/net/context"
+)
+
+// Code is a canonical error code. The values are sent on the wire,
+// so new codes can only be appended.
+type Code int64
+
+const (
+	// UnknownError is the code of the errors that don't have one.
+	UnknownError Code = iota
+
+	// Cancelled means the operation was canceled, usually by
+	// the caller.
+	Cancelled
+
+	// DeadlineExceeded means the operation didn't complete before
+	// its deadline.
+	DeadlineExceeded
+
+	// ResourceExhausted means a resource, like a transaction pool,
+	// is full. The request can be retried later.
+	ResourceExhausted
+
+	// QueryNotServed means the server can't serve the query in its
+	// current state, but another one may.
+	QueryNotServed
+
+	// NotInTx means the transaction used by the request doesn't
+	// exist, or doesn't exist anymore.
+	NotInTx
+
+	// InternalError means the server failed, or a system it
+	// depends on did. The request should not be retried on the
+	// same server.
+	InternalError
+
+	// NotMaster means the query needs a master, and the server is
+	// not one anymore. The error may say which server is.
+	NotMaster
+
+	// RowLimitExceeded means the query returned, or would have
+	// affected, more rows than the server allows. The query
+	// should not be retried as is.
+	RowLimitExceeded
+
+	// Unimplemented means the server doesn't support the request,
+	// usually because it runs an older version.
+	Unimplemented
+)
+
+var codeNames = map[Code]string{
+	UnknownError:      "UNKNOWN_ERROR",
+	Cancelled:         "CANCELLED",
+	DeadlineExceeded:  "DEADLINE_EXCEEDED",
+	ResourceExhausted: "RESOURCE_EXHAUSTED",
+	QueryNotServed:    "QUERY_NOT_SERVED",
+	NotInTx:           "NOT_IN_TX",
+	InternalError:     "INTERNAL_ERROR",
+	NotMaster:         "NOT_MASTER",
+	RowLimitExceeded:  "ROW_LIMIT_EXCEEDED",
+	Unimplemented:     "UNIMPLEMENTED",
+}
+
+func (code Code) String() string {
+	if name, ok := codeNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN_CODE_%d", int64(code))
+}
+
+// VtError is implemented by the errors that have a Code.
+type VtError interface {
+	error
+	VtErrorCode() Code
+}
+
+// codedError is the VtError returned by New, Errorf and FromError.
+type codedError struct {
+	code Code
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+// VtErrorCode is part of the VtError interface.
+func (e *codedError) VtErrorCode() Code {
+	return e.code
+}
+
+// ErrorCode is part of the rpcplus.CodedError interface.
+func (e *codedError) ErrorCode() int64 {
+	return int64(e.code)
+}
+
+// New returns an error with the given code and message.
+func New(code Code, message string) error {
+	return &codedError{code, fmt.Errorf("%s", message)}
+}
+
+// Errorf returns an error with the given code and formatted message.
+func Errorf(code Code, format string, args ...interface{}) error {
+	return &codedError{code, fmt.Errorf(format, args...)}
+}
+
+// FromError returns an error with the given code and the message of
+// err, or nil if err is nil.
+func FromError(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code, err}
+}
+
+// RecoverVtErrorCode returns the code of err: its own code if it has
+// one, or the code received with it from an RPC. Context errors get
+// Cancelled and DeadlineExceeded, anything else UnknownError.
+func RecoverVtErrorCode(err error) Code {
+	switch err := err.(type) {
+	case VtError:
+		return err.VtErrorCode()
+	case rpcplus.CodedError:
+		return Code(err.ErrorCode())
+	}
+	switch err {
+	case context.Canceled:
+		return Cancelled
+	case context.DeadlineExceeded:
+		return DeadlineExceeded
+	}
+	return UnknownError
+}
@@ -0,0 +1,57 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vterrors
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/youtube/vitess/go/rpcplus"
+	"golang.org/x/net/context"
+)
+
+func TestRecoverVtErrorCode(t *testing.T) {
+	testcases := []struct {
+		err  error
+		want Code
+	}{
+		{New(ResourceExhausted, "pool full"), ResourceExhausted},
+		{Errorf(NotInTx, "transaction %v", 12), NotInTx},
+		{FromError(QueryNotServed, errors.New("not serving")), QueryNotServed},
+		{&rpcplus.CodedServerError{Code: int64(InternalError), Err: "internal"}, InternalError},
+		{rpcplus.ServerError("no code"), UnknownError},
+		{context.Canceled, Cancelled},
+		{context.DeadlineExceeded, DeadlineExceeded},
+		{errors.New("plain"), UnknownError},
+	}
+	for _, tc := range testcases {
+		if got := RecoverVtErrorCode(tc.err); got != tc.want {
+			t.Errorf("RecoverVtErrorCode(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestCodedError(t *testing.T) {
+	err := Errorf(QueryNotServed, "state %v", "NOT_SERVING")
+	if got, want := err.Error(), "state NOT_SERVING"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	coded, ok := err.(rpcplus.CodedError)
+	if !ok || coded.ErrorCode() != int64(QueryNotServed) {
+		t.Errorf("%#v is not a CodedError with the right code", err)
+	}
+	if FromError(InternalError, nil) != nil {
+		t.Errorf("FromError(nil) is not nil")
+	}
+}
+
+func TestCodeString(t *testing.T) {
+	if got, want := QueryNotServed.String(), "QUERY_NOT_SERVED"; got != want {
+		t.Errorf("String() = %v, want %v", got, want)
+	}
+	if got, want := Code(100).String(), "UNKNOWN_CODE_100"; got != want {
+		t.Errorf("String() = %v, want %v", got, want)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"math/rand"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,32 +17,78 @@ import (
 	"github.com/youtube/vitess/go/vt/topo"
 )
 
-var resetDownConnDelay = flag.Duration("reset-down-conn-delay", 10*time.Minute, "delay to reset a marked down tabletconn")
+var (
+	resetDownConnDelay = flag.Duration("reset-down-conn-delay", 10*time.Minute, "delay to reset a marked down tabletconn")
+
+	balancerPolicyName    = flag.String("balancer_policy", randomPolicy, "how to order the tablets to connect to: random, round_robin, least_connections, latency_weighted or cell_local_first")
+	balancerFallbackCells = flag.String("balancer_fallback_cells", "", "comma-separated list of cells whose tablets are used after the ones of the local cell, with -balancer_policy cell_local_first")
+	circuitFailures       = flag.Int("balancer_circuit_failures", 5, "number of consecutive failures after which a tablet is not used for -balancer_circuit_open_time, 0 to disable")
+	circuitOpenTime       = flag.Duration("balancer_circuit_open_time", time.Minute, "how long a tablet is not used after -balancer_circuit_failures consecutive failures")
+)
+
+// The names of the balancer policies.
+const (
+	randomPolicy           = "random"
+	roundRobinPolicy       = "round_robin"
+	leastConnectionsPolicy = "least_connections"
+	latencyWeightedPolicy  = "latency_weighted"
+	cellLocalFirstPolicy   = "cell_local_first"
+)
+
+// balancerPolicy orders the available nodes of a Balancer in place,
+// the first one is tried first. Get calls it with the Balancer mutex
+// held, and the nodes shuffled.
+type balancerPolicy func(blc *Balancer, nodes []*addressStatus)
+
+var balancerPolicies = map[string]balancerPolicy{
+	randomPolicy:           orderRandom,
+	roundRobinPolicy:       orderRoundRobin,
+	leastConnectionsPolicy: orderLeastConnections,
+	latencyWeightedPolicy:  orderLatencyWeighted,
+	cellLocalFirstPolicy:   orderCellLocalFirst,
+}
 
 // GetEndPointsFunc defines the callback to topo server.
 type GetEndPointsFunc func() (*topo.EndPoints, error)
 
-// Balancer is a simple round-robin load balancer.
-// It allows you to temporarily mark down nodes that
-// are non-functional.
+// Balancer is a load balancer, with pluggable policies to order
+// the nodes. It allows you to temporarily mark down nodes that
+// are non-functional, and stops using the nodes that keep failing
+// for a while (circuit breaking).
 type Balancer struct {
 	mu                 sync.Mutex
 	addressNodes       []*addressStatus
 	index              int
 	getEndPoints       GetEndPointsFunc
+	fallbackCells      []fallbackCell
 	retryDelay         time.Duration
 	resetDownConnDelay time.Duration
+	policyName         string
+	policy             balancerPolicy
+	circuitFailures    int
+	circuitOpenTime    time.Duration
 }
 
 type addressStatus struct {
 	endPoint  topo.EndPoint
 	timeRetry time.Time
 	balancer  *Balancer
+	// cell is empty for the nodes of the local cell.
+	cell string
+	// failures is the number of consecutive failures.
+	failures int
+}
+
+// fallbackCell is a cell whose nodes are used after the local ones.
+type fallbackCell struct {
+	cell         string
+	getEndPoints GetEndPointsFunc
 }
 
 // NewBalancer creates a Balancer. getAddresses is the function
 // it will use to refresh the list of addresses if one of the
-// nodes has been marked down. The list of addresses is shuffled.
+// nodes has been marked down. The nodes are ordered by the policy
+// of the -balancer_policy flag.
 // retryDelay specifies the minimum time a node will be marked down
 // before it will be cleared for a retry.
 func NewBalancer(getEndPoints GetEndPointsFunc, retryDelay time.Duration) *Balancer {
@@ -49,9 +96,50 @@ func NewBalancer(getEndPoints GetEndPointsFunc, retryDelay time.Duration) *Balan
 	blc.getEndPoints = getEndPoints
 	blc.retryDelay = retryDelay
 	blc.resetDownConnDelay = *resetDownConnDelay
+	blc.circuitFailures = *circuitFailures
+	blc.circuitOpenTime = *circuitOpenTime
+	if err := blc.SetPolicy(*balancerPolicyName); err != nil {
+		log.Errorf("%v, using the %v policy", err, randomPolicy)
+		blc.SetPolicy(randomPolicy)
+	}
 	return blc
 }
 
+// BalancerFallbackCells returns the cells of the
+// -balancer_fallback_cells flag, without cell.
+func BalancerFallbackCells(cell string) []string {
+	var result []string
+	for _, c := range strings.Split(*balancerFallbackCells, ",") {
+		c = strings.TrimSpace(c)
+		if c != "" && c != cell {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// SetPolicy changes the policy used to order the nodes.
+func (blc *Balancer) SetPolicy(name string) error {
+	policy, ok := balancerPolicies[name]
+	if !ok {
+		return fmt.Errorf("unknown balancer policy %q", name)
+	}
+	blc.mu.Lock()
+	defer blc.mu.Unlock()
+	blc.policyName = name
+	blc.policy = policy
+	return nil
+}
+
+// AddFallbackCell adds a cell whose nodes are returned after the
+// ones of the local cell. Only the cell_local_first policy uses
+// them, other policies ignore the fallback cells.
+func (blc *Balancer) AddFallbackCell(cell string, getEndPoints GetEndPointsFunc) {
+	blc.mu.Lock()
+	defer blc.mu.Unlock()
+	blc.fallbackCells = append(blc.fallbackCells, fallbackCell{cell: cell, getEndPoints: getEndPoints})
+}
+
 // Get returns a single endpoint that was not recently marked down.
 // If it finds an address that was down for longer than retryDelay,
 // it refreshes the list of addresses and returns the next available
@@ -76,26 +164,81 @@ func (blc *Balancer) Get() (endPoints []topo.EndPoint, err error) {
 
 	// Return all endpoints without markdown and timeRetry < now(),
 	// so endpoints just marked down (within retryDelay) are ignored.
-	validEndPoints := make([]topo.EndPoint, 0, 1)
+	available := 0
 	for _, addrNode := range blc.addressNodes {
 		if addrNode.timeRetry.IsZero() || addrNode.timeRetry.Before(time.Now()) {
-			validEndPoints = append(validEndPoints, addrNode.endPoint)
+			available++
 			continue
 		}
 		break
 	}
+	blc.policy(blc, blc.addressNodes[:available])
 
+	validEndPoints := make([]topo.EndPoint, 0, available)
+	for _, addrNode := range blc.addressNodes[:available] {
+		validEndPoints = append(validEndPoints, addrNode.endPoint)
+	}
 	return validEndPoints, nil
 }
 
 // MarkDown marks the specified address down. Such addresses
 // will not be used by Balancer for the duration of retryDelay.
+// After circuitFailures consecutive markdowns, the address is not
+// used for circuitOpenTime instead, until it succeeds again. The
+// circuit of the last available address is never opened, so a shard
+// doesn't become unreachable for that long.
 func (blc *Balancer) MarkDown(uid uint32, reason string) {
 	blc.mu.Lock()
 	defer blc.mu.Unlock()
 	if index := findAddrNode(blc.addressNodes, uid); index != -1 {
-		log.Infof("Marking down %v at %+v (%v)", uid, blc.addressNodes[index].endPoint, reason)
-		blc.addressNodes[index].timeRetry = time.Now().Add(blc.retryDelay)
+		addrNode := blc.addressNodes[index]
+		addrNode.failures++
+		if blc.circuitFailures > 0 && addrNode.failures >= blc.circuitFailures && blc.hasOtherAvailable(index) {
+			log.Warningf("Marking down %v at %+v for %v after %v consecutive failures (%v)", uid, addrNode.endPoint, blc.circuitOpenTime, addrNode.failures, reason)
+			addrNode.timeRetry = time.Now().Add(blc.circuitOpenTime)
+			return
+		}
+		log.Infof("Marking down %v at %+v (%v)", uid, addrNode.endPoint, reason)
+		addrNode.timeRetry = time.Now().Add(blc.retryDelay)
+	}
+}
+
+// hasOtherAvailable returns true if a node other than the one at
+// index can be used now. mu must be held.
+func (blc *Balancer) hasOtherAvailable(index int) bool {
+	now := time.Now()
+	for i, addrNode := range blc.addressNodes {
+		if i != index && (addrNode.timeRetry.IsZero() || addrNode.timeRetry.Before(now)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Connected records a new connection to the address, for the
+// least_connections policy. Disconnected must be called when the
+// connection is closed.
+func (blc *Balancer) Connected(endPoint topo.EndPoint) {
+	endPointLoads.connected(endPoint, 1)
+}
+
+// Disconnected records the end of a connection recorded by Connected.
+func (blc *Balancer) Disconnected(endPoint topo.EndPoint) {
+	endPointLoads.connected(endPoint, -1)
+}
+
+// RecordSuccess records a successful request to the address, which
+// resets its consecutive failures. latency is used by the
+// latency_weighted policy, 0 if it's unknown (e.g. for streaming
+// requests).
+func (blc *Balancer) RecordSuccess(endPoint topo.EndPoint, latency time.Duration) {
+	if latency > 0 {
+		endPointLoads.recordLatency(endPoint, latency)
+	}
+	blc.mu.Lock()
+	defer blc.mu.Unlock()
+	if index := findAddrNode(blc.addressNodes, endPoint.Uid); index != -1 {
+		blc.addressNodes[index].failures = 0
 	}
 }
 
@@ -104,6 +247,25 @@ func (blc *Balancer) refresh() error {
 	if err != nil {
 		return err
 	}
+	// Add the endpoints of the fallback cells, the local ones win
+	cells := make(map[uint32]string)
+	if blc.policyName == cellLocalFirstPolicy && endPoints != nil && len(blc.fallbackCells) > 0 {
+		merged := &topo.EndPoints{Entries: append([]topo.EndPoint(nil), endPoints.Entries...)}
+		for _, fc := range blc.fallbackCells {
+			fallbackEndPoints, err := fc.getEndPoints()
+			if err != nil {
+				log.Warningf("Cannot get the endpoints of fallback cell %v: %v", fc.cell, err)
+				continue
+			}
+			for _, endPoint := range fallbackEndPoints.Entries {
+				if findAddress(merged, endPoint.Uid) == -1 {
+					merged.Entries = append(merged.Entries, endPoint)
+					cells[endPoint.Uid] = fc.cell
+				}
+			}
+		}
+		endPoints = merged
+	}
 	// Add new addressNodes
 	if endPoints != nil {
 		for _, endPoint := range endPoints.Entries {
@@ -111,10 +273,12 @@ func (blc *Balancer) refresh() error {
 				addrNode := &addressStatus{
 					endPoint: endPoint,
 					balancer: blc,
+					cell:     cells[endPoint.Uid],
 				}
 				blc.addressNodes = append(blc.addressNodes, addrNode)
 			} else {
 				blc.addressNodes[index].endPoint = endPoint
+				blc.addressNodes[index].cell = cells[endPoint.Uid]
 			}
 		}
 	}
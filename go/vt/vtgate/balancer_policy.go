@@ -0,0 +1,182 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtgate
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/youtube/vitess/go/netutil"
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+// latencyDecay is the weight of a new latency sample in the moving
+// average of the latency of an address.
+const latencyDecay = 0.2
+
+// endPointLoad is what this process knows about an address, across
+// all its Balancers.
+type endPointLoad struct {
+	connections int
+	// latency is the exponentially weighted moving average of the
+	// request latency, 0 if unknown.
+	latency time.Duration
+}
+
+// endPointLoadMap tracks the endPointLoad of the addresses. They are
+// keyed by endPointAddress, since the Balancers of different cells
+// share it and uids are only unique within a cell.
+type endPointLoadMap struct {
+	mu    sync.Mutex
+	loads map[string]*endPointLoad
+}
+
+var endPointLoads = &endPointLoadMap{loads: make(map[string]*endPointLoad)}
+
+// endPointAddress returns the address of the tablet of an endpoint.
+func endPointAddress(endPoint topo.EndPoint) string {
+	return netutil.JoinHostPort(endPoint.Host, endPoint.NamedPortMap["vt"])
+}
+
+// get returns the endPointLoad of an address. mu must be held.
+func (m *endPointLoadMap) get(addr string) *endPointLoad {
+	load, ok := m.loads[addr]
+	if !ok {
+		load = &endPointLoad{}
+		m.loads[addr] = load
+	}
+	return load
+}
+
+func (m *endPointLoadMap) connected(endPoint topo.EndPoint, delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(endPointAddress(endPoint)).connections += delta
+}
+
+func (m *endPointLoadMap) recordLatency(endPoint topo.EndPoint, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	load := m.get(endPointAddress(endPoint))
+	if load.latency == 0 {
+		load.latency = latency
+		return
+	}
+	load.latency = time.Duration((1-latencyDecay)*float64(load.latency) + latencyDecay*float64(latency))
+}
+
+// snapshot returns a copy of the endPointLoad of the nodes, by uid.
+func (m *endPointLoadMap) snapshot(nodes []*addressStatus) map[uint32]endPointLoad {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make(map[uint32]endPointLoad, len(nodes))
+	for _, node := range nodes {
+		if load, ok := m.loads[endPointAddress(node.endPoint)]; ok {
+			result[node.endPoint.Uid] = *load
+		}
+	}
+	return result
+}
+
+// orderRandom keeps the nodes shuffled by refresh.
+func orderRandom(blc *Balancer, nodes []*addressStatus) {
+}
+
+// orderRoundRobin sorts the nodes by uid, and starts one further
+// at each call.
+func orderRoundRobin(blc *Balancer, nodes []*addressStatus) {
+	if len(nodes) == 0 {
+		return
+	}
+	sort.Sort(addressesByUID(nodes))
+	start := blc.index % len(nodes)
+	blc.index++
+	rotated := append(append([]*addressStatus(nil), nodes[start:]...), nodes[:start]...)
+	copy(nodes, rotated)
+}
+
+// orderLeastConnections puts the nodes this process has the fewest
+// connections to first. The nodes with as many connections stay
+// shuffled.
+func orderLeastConnections(blc *Balancer, nodes []*addressStatus) {
+	loads := endPointLoads.snapshot(nodes)
+	sort.Stable(addressesByKey{nodes, func(node *addressStatus) int64 {
+		return int64(loads[node.endPoint.Uid].connections)
+	}})
+}
+
+// orderLatencyWeighted shuffles the nodes, with the faster ones more
+// likely to come first: the weight of a node is the inverse of its
+// average latency. The nodes without a known latency get the weight
+// of the fastest node, so they are tried too.
+func orderLatencyWeighted(blc *Balancer, nodes []*addressStatus) {
+	loads := endPointLoads.snapshot(nodes)
+	var fastest time.Duration
+	for _, load := range loads {
+		if load.latency > 0 && (fastest == 0 || load.latency < fastest) {
+			fastest = load.latency
+		}
+	}
+	if fastest == 0 {
+		// Nothing is known yet, keep the shuffled order.
+		return
+	}
+	weights := make([]float64, len(nodes))
+	total := 0.0
+	for i, node := range nodes {
+		latency := loads[node.endPoint.Uid].latency
+		if latency == 0 {
+			latency = fastest
+		}
+		weights[i] = 1 / float64(latency)
+		total += weights[i]
+	}
+	// Weighted sampling without replacement: pick the next node
+	// among the remaining ones, in proportion to their weights.
+	for i := range nodes {
+		r := rand.Float64() * total
+		j := i
+		for ; j < len(nodes)-1; j++ {
+			r -= weights[j]
+			if r < 0 {
+				break
+			}
+		}
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+		weights[i], weights[j] = weights[j], weights[i]
+		total -= weights[i]
+	}
+}
+
+// orderCellLocalFirst puts the nodes of the local cell first, then
+// the nodes of the fallback cells, in the order of the cells. The
+// nodes of a cell stay shuffled.
+func orderCellLocalFirst(blc *Balancer, nodes []*addressStatus) {
+	rank := make(map[string]int64, len(blc.fallbackCells))
+	for i, fc := range blc.fallbackCells {
+		rank[fc.cell] = int64(i + 1)
+	}
+	sort.Stable(addressesByKey{nodes, func(node *addressStatus) int64 {
+		return rank[node.cell]
+	}})
+}
+
+type addressesByUID []*addressStatus
+
+func (a addressesByUID) Len() int           { return len(a) }
+func (a addressesByUID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a addressesByUID) Less(i, j int) bool { return a[i].endPoint.Uid < a[j].endPoint.Uid }
+
+// addressesByKey sorts the nodes by increasing key.
+type addressesByKey struct {
+	nodes []*addressStatus
+	key   func(*addressStatus) int64
+}
+
+func (a addressesByKey) Len() int           { return len(a.nodes) }
+func (a addressesByKey) Swap(i, j int)      { a.nodes[i], a.nodes[j] = a.nodes[j], a.nodes[i] }
+func (a addressesByKey) Less(i, j int) bool { return a.key(a.nodes[i]) < a.key(a.nodes[j]) }
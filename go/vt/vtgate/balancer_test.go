@@ -246,3 +246,177 @@ func TestRefresh(t *testing.T) {
 		t.Errorf("want 12, got %v", portNew)
 	}
 }
+
+func endPointsCell(uids ...uint32) GetEndPointsFunc {
+	return func() (*topo.EndPoints, error) {
+		result := &topo.EndPoints{}
+		for _, uid := range uids {
+			result.Entries = append(result.Entries, cellEndPoint(uid))
+		}
+		return result, nil
+	}
+}
+
+func cellEndPoint(uid uint32) topo.EndPoint {
+	return topo.EndPoint{
+		Uid:          uid,
+		Host:         fmt.Sprintf("%d", uid),
+		NamedPortMap: map[string]int{"vt": int(uid)},
+	}
+}
+
+func newPolicyBalancer(t *testing.T, policy string, getEndPoints GetEndPointsFunc) *Balancer {
+	b := NewBalancer(getEndPoints, RetryDelay)
+	if err := b.SetPolicy(policy); err != nil {
+		t.Fatalf("SetPolicy(%v) failed: %v", policy, err)
+	}
+	return b
+}
+
+func uids(endPoints []topo.EndPoint) []uint32 {
+	result := make([]uint32, len(endPoints))
+	for i, endPoint := range endPoints {
+		result[i] = endPoint.Uid
+	}
+	return result
+}
+
+func TestSetPolicy(t *testing.T) {
+	b := NewBalancer(endPoints3, RetryDelay)
+	if err := b.SetPolicy("unknown"); err == nil {
+		t.Errorf("SetPolicy(unknown) succeeded")
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	b := newPolicyBalancer(t, roundRobinPolicy, endPointsCell(100, 101, 102))
+	for i, want := range []uint32{100, 101, 102, 100} {
+		endPoints, err := b.Get()
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if got := uids(endPoints); got[0] != want || len(got) != 3 {
+			t.Errorf("Get #%v: got %v, want %v first", i, got, want)
+		}
+	}
+}
+
+func TestLeastConnections(t *testing.T) {
+	b := newPolicyBalancer(t, leastConnectionsPolicy, endPointsCell(110, 111, 112))
+	b.Connected(cellEndPoint(110))
+	b.Connected(cellEndPoint(110))
+	b.Connected(cellEndPoint(112))
+	defer func() {
+		b.Disconnected(cellEndPoint(110))
+		b.Disconnected(cellEndPoint(110))
+		b.Disconnected(cellEndPoint(112))
+	}()
+	for i := 0; i < 10; i++ {
+		endPoints, _ := b.Get()
+		if got, want := fmt.Sprint(uids(endPoints)), "[111 112 110]"; got != want {
+			t.Fatalf("Get: got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLeastConnectionsOtherCell(t *testing.T) {
+	// Uids are only unique within a cell: the connections to a tablet
+	// of another cell with the same uid don't count.
+	b := newPolicyBalancer(t, leastConnectionsPolicy, endPointsCell(115, 116))
+	other := cellEndPoint(115)
+	other.Host = "other_cell_host"
+	b.Connected(other)
+	b.Connected(cellEndPoint(116))
+	defer func() {
+		b.Disconnected(other)
+		b.Disconnected(cellEndPoint(116))
+	}()
+	for i := 0; i < 10; i++ {
+		endPoints, _ := b.Get()
+		if got, want := fmt.Sprint(uids(endPoints)), "[115 116]"; got != want {
+			t.Fatalf("Get: got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLatencyWeighted(t *testing.T) {
+	b := newPolicyBalancer(t, latencyWeightedPolicy, endPointsCell(120, 121))
+	b.Get()
+	b.RecordSuccess(cellEndPoint(120), time.Millisecond)
+	b.RecordSuccess(cellEndPoint(121), 100*time.Millisecond)
+	first := 0
+	for i := 0; i < 100; i++ {
+		endPoints, _ := b.Get()
+		if len(endPoints) != 2 {
+			t.Fatalf("Get: got %v, want 2 endpoints", endPoints)
+		}
+		if endPoints[0].Uid == 120 {
+			first++
+		}
+	}
+	// 120 is 100 times faster, it should almost always be first.
+	if first < 80 {
+		t.Errorf("the fastest endpoint was first %v times out of 100", first)
+	}
+}
+
+func TestCellLocalFirst(t *testing.T) {
+	b := newPolicyBalancer(t, cellLocalFirstPolicy, endPointsCell(130, 131))
+	b.AddFallbackCell("cell2", endPointsCell(140, 131))
+	b.AddFallbackCell("cell3", endPointsCell(150))
+	for i := 0; i < 10; i++ {
+		endPoints, _ := b.Get()
+		got := uids(endPoints)
+		if len(got) != 4 || got[0] == 140 || got[1] == 140 || got[2] != 140 || got[3] != 150 {
+			t.Fatalf("Get: got %v, want 130 and 131 first, then 140 and 150", got)
+		}
+	}
+
+	// Other policies ignore the fallback cells.
+	b.SetPolicy(randomPolicy)
+	b.addressNodes = nil
+	if endPoints, _ := b.Get(); len(endPoints) != 2 {
+		t.Errorf("Get: got %v, want only the local endpoints", endPoints)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b := NewBalancer(endPointsCell(160, 161), time.Millisecond)
+	b.circuitFailures = 3
+	b.circuitOpenTime = time.Hour
+
+	// Two failures in a row only mark it down for the retry delay,
+	// and a success resets the count.
+	b.Get()
+	b.MarkDown(160, "")
+	b.MarkDown(160, "")
+	b.RecordSuccess(cellEndPoint(160), 0)
+	b.MarkDown(160, "")
+	time.Sleep(2 * time.Millisecond)
+	if endPoints, _ := b.Get(); len(endPoints) != 2 {
+		t.Fatalf("Get: got %v, want 2 endpoints", endPoints)
+	}
+
+	// Three failures in a row open the circuit.
+	b.MarkDown(160, "")
+	b.MarkDown(160, "")
+	time.Sleep(2 * time.Millisecond)
+	endPoints, _ := b.Get()
+	if got := uids(endPoints); len(got) != 1 || got[0] != 161 {
+		t.Errorf("Get: got %v, want only 161", got)
+	}
+}
+
+func TestCircuitBreakerLastEndPoint(t *testing.T) {
+	b := NewBalancer(endPointsCell(170), time.Millisecond)
+	b.circuitFailures = 2
+	b.circuitOpenTime = time.Hour
+	b.Get()
+	for i := 0; i < 3; i++ {
+		b.MarkDown(170, "")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if endPoints, _ := b.Get(); len(endPoints) != 1 {
+		t.Errorf("Get: got %v, want the only endpoint back", endPoints)
+	}
+}
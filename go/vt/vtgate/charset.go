@@ -0,0 +1,410 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtgate
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
+)
+
+// defaultCharset is the character set of the tablet connections of
+// the keyspaces that are not in -keyspace_charsets.
+const defaultCharset = "utf8"
+
+var (
+	keyspaceCharsetsFlag = flag.String("keyspace_charsets", "", "comma separated list of keyspace:charset, the character sets of the tablet connections of the keyspaces that don't use "+defaultCharset)
+
+	// keyspaceCharsets maps the keyspaces to the character set of
+	// their tablet connections, if it is not defaultCharset.
+	keyspaceCharsets map[string]string
+)
+
+// charset converts between the characters and the bytes of a MySQL
+// character set.
+type charset interface {
+	// decode returns the first character of b and its length.
+	// Like utf8.DecodeRune, it returns (utf8.RuneError, 1) for the
+	// bytes that are not valid.
+	decode(b []byte) (rune, int)
+	// encode appends the bytes of r to b. It returns false if r
+	// can't be represented.
+	encode(b []byte, r rune) ([]byte, bool)
+}
+
+// charsets are the supported character sets. The binary one, which
+// is never converted, is handled separately.
+var charsets = map[string]charset{
+	"utf8":    utf8Charset{maxRune: 0xFFFF},
+	"utf8mb4": utf8Charset{maxRune: unicode.MaxRune},
+	"latin1":  latin1Charset{},
+	"ascii":   asciiCharset{},
+}
+
+const binaryCharset = "binary"
+
+type utf8Charset struct {
+	// maxRune is 0xFFFF for the MySQL utf8, which only stores up
+	// to 3 bytes per character.
+	maxRune rune
+}
+
+func (cs utf8Charset) decode(b []byte) (rune, int) {
+	return utf8.DecodeRune(b)
+}
+
+func (cs utf8Charset) encode(b []byte, r rune) ([]byte, bool) {
+	if r > cs.maxRune {
+		return b, false
+	}
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	return append(b, buf[:n]...), true
+}
+
+// latin1Charset is the MySQL latin1, which is cp1252 with the 5
+// undefined bytes mapped to the matching control characters.
+type latin1Charset struct{}
+
+var cp1252 = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+func (latin1Charset) decode(b []byte) (rune, int) {
+	if b[0] >= 0x80 && b[0] < 0xA0 {
+		return cp1252[b[0]-0x80], 1
+	}
+	return rune(b[0]), 1
+}
+
+func (latin1Charset) encode(b []byte, r rune) ([]byte, bool) {
+	if r < 0x80 || (r >= 0xA0 && r <= 0xFF) {
+		return append(b, byte(r)), true
+	}
+	for i, c := range cp1252 {
+		if c == r {
+			return append(b, byte(0x80+i)), true
+		}
+	}
+	return b, false
+}
+
+type asciiCharset struct{}
+
+func (asciiCharset) decode(b []byte) (rune, int) {
+	if b[0] >= 0x80 {
+		return utf8.RuneError, 1
+	}
+	return rune(b[0]), 1
+}
+
+func (asciiCharset) encode(b []byte, r rune) ([]byte, bool) {
+	if r >= 0x80 {
+		return b, false
+	}
+	return append(b, byte(r)), true
+}
+
+// initKeyspaceCharsets parses the value of -keyspace_charsets.
+func initKeyspaceCharsets(value string) error {
+	keyspaceCharsets = make(map[string]string)
+	if value == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.Split(entry, ":")
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid keyspace charset %q, want keyspace:charset", entry)
+		}
+		name := strings.ToLower(parts[1])
+		if _, ok := charsets[name]; !ok && name != binaryCharset {
+			return fmt.Errorf("unsupported character set %v for keyspace %v", parts[1], parts[0])
+		}
+		keyspaceCharsets[parts[0]] = name
+	}
+	return nil
+}
+
+// keyspaceCharset returns the character set of the tablet connections
+// of a keyspace.
+func keyspaceCharset(keyspace string) string {
+	if name, ok := keyspaceCharsets[keyspace]; ok {
+		return name
+	}
+	return defaultCharset
+}
+
+// charsetConverter converts the queries of a session from its
+// character set to the one of a keyspace, and the results back. A nil
+// charsetConverter does nothing.
+type charsetConverter struct {
+	keyspace       string
+	client, tablet charset
+}
+
+// newCharsetConverter returns the converter of the queries of a session
+// to a keyspace, nil if none is needed.
+func newCharsetConverter(session *SafeSession, keyspace string) (*charsetConverter, error) {
+	clientName := strings.ToLower(session.Charset())
+	if clientName == "" {
+		return nil, nil
+	}
+	client, ok := charsets[clientName]
+	if !ok && clientName != binaryCharset {
+		return nil, fmt.Errorf("unsupported character set %v", session.Charset())
+	}
+	tabletName := keyspaceCharset(keyspace)
+	if clientName == tabletName || clientName == binaryCharset || tabletName == binaryCharset {
+		return nil, nil
+	}
+	return &charsetConverter{keyspace: keyspace, client: client, tablet: charsets[tabletName]}, nil
+}
+
+// convert converts b from one character set to another. The characters
+// that can't be represented are replaced by '?', like MySQL does, or
+// make it fail if strict is set.
+func convert(b []byte, from, to charset, strict bool) ([]byte, rune, bool) {
+	result := make([]byte, 0, len(b))
+	for len(b) > 0 {
+		r, n := from.decode(b)
+		b = b[n:]
+		var ok bool
+		if result, ok = to.encode(result, r); !ok {
+			if strict {
+				return nil, r, false
+			}
+			result = append(result, '?')
+		}
+	}
+	return result, 0, true
+}
+
+// convertQuery converts a query and its string bind variables to the
+// character set of the keyspace. It fails if they contain characters
+// the keyspace can't store: storing '?' instead would lose data. The
+// []byte bind variables and the _binary string literals are binary,
+// they are not converted.
+func (cc *charsetConverter) convertQuery(sql string, bindVars map[string]interface{}) (string, map[string]interface{}, error) {
+	if cc == nil {
+		return sql, bindVars, nil
+	}
+	convertString := func(s string) (string, error) {
+		b, r, ok := convert([]byte(s), cc.client, cc.tablet, true)
+		if !ok {
+			return "", fmt.Errorf("character %q can't be represented in the %v character set of keyspace %v", r, keyspaceCharset(cc.keyspace), cc.keyspace)
+		}
+		return string(b), nil
+	}
+	sql, err := convertSQL(sql, convertString)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(bindVars) == 0 {
+		return sql, bindVars, nil
+	}
+	converted := make(map[string]interface{}, len(bindVars))
+	for k, v := range bindVars {
+		switch v := v.(type) {
+		case string:
+			if converted[k], err = convertString(v); err != nil {
+				return "", nil, err
+			}
+		case []interface{}:
+			list := make([]interface{}, len(v))
+			for i, item := range v {
+				if s, ok := item.(string); ok {
+					if item, err = convertString(s); err != nil {
+						return "", nil, err
+					}
+				}
+				list[i] = item
+			}
+			converted[k] = list
+		default:
+			converted[k] = v
+		}
+	}
+	return sql, converted, nil
+}
+
+// convertSQL converts the text of a query with convertString, except
+// for the string literals introduced by _binary, which are copied
+// as they are.
+func convertSQL(sql string, convertString func(string) (string, error)) (string, error) {
+	var result []string
+	start := 0
+	for i := 0; i < len(sql); i++ {
+		quote := sql[i]
+		if quote != '\'' && quote != '"' {
+			continue
+		}
+		end := stringLiteralEnd(sql, i)
+		if !hasBinaryIntroducer(sql[:i]) {
+			i = end - 1
+			continue
+		}
+		converted, err := convertString(sql[start:i])
+		if err != nil {
+			return "", err
+		}
+		result = append(result, converted, sql[i:end])
+		start = end
+		i = end - 1
+	}
+	if start == 0 {
+		return convertString(sql)
+	}
+	converted, err := convertString(sql[start:])
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(append(result, converted), ""), nil
+}
+
+// stringLiteralEnd returns the index following the string literal
+// that starts at sql[start], or len(sql) if it's not terminated.
+// Quotes are escaped by a backslash, or by doubling them.
+func stringLiteralEnd(sql string, start int) int {
+	quote := sql[start]
+	for i := start + 1; i < len(sql); i++ {
+		switch sql[i] {
+		case '\\':
+			i++
+		case quote:
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+// hasBinaryIntroducer returns true if prefix ends with the _binary
+// introducer, optionally followed by spaces.
+func hasBinaryIntroducer(prefix string) bool {
+	prefix = strings.TrimRight(prefix, " \t\r\n")
+	const introducer = "_binary"
+	if len(prefix) < len(introducer) || !strings.EqualFold(prefix[len(prefix)-len(introducer):], introducer) {
+		return false
+	}
+	// _binary must be a whole word
+	if len(prefix) == len(introducer) {
+		return true
+	}
+	c := prefix[len(prefix)-len(introducer)-1]
+	return !(c == '_' || c == '$' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'))
+}
+
+// convertBoundQueries is convertQuery for a batch.
+func (cc *charsetConverter) convertBoundQueries(queries []tproto.BoundQuery) ([]tproto.BoundQuery, error) {
+	if cc == nil {
+		return queries, nil
+	}
+	converted := make([]tproto.BoundQuery, len(queries))
+	for i, query := range queries {
+		sql, bindVars, err := cc.convertQuery(query.Sql, query.BindVariables)
+		if err != nil {
+			return nil, err
+		}
+		converted[i] = tproto.BoundQuery{Sql: sql, BindVariables: bindVars}
+	}
+	return converted, nil
+}
+
+// isTextField returns true for the fields that hold text. The string
+// and BLOB types hold text unless the field has the binary flag:
+// VARBINARY, BINARY and BLOB columns are never converted.
+func isTextField(field mproto.Field) bool {
+	switch field.Type {
+	case mproto.VT_ENUM, mproto.VT_SET:
+		return true
+	case mproto.VT_VARCHAR, mproto.VT_VAR_STRING, mproto.VT_STRING,
+		mproto.VT_TINY_BLOB, mproto.VT_MEDIUM_BLOB, mproto.VT_LONG_BLOB, mproto.VT_BLOB:
+		return field.Flags&mproto.VT_BINARY_FLAG == 0
+	}
+	return false
+}
+
+// convertResult converts the text values of a result to the character
+// set of the session. The rows are copied, the results of the tablets
+// may be shared.
+func (cc *charsetConverter) convertResult(qr *mproto.QueryResult) {
+	if cc == nil || qr == nil {
+		return
+	}
+	var text []int
+	for i, field := range qr.Fields {
+		if isTextField(field) {
+			text = append(text, i)
+		}
+	}
+	if len(text) == 0 {
+		return
+	}
+	rows := make([][]sqltypes.Value, len(qr.Rows))
+	for i, row := range qr.Rows {
+		row = append([]sqltypes.Value(nil), row...)
+		for _, j := range text {
+			if j >= len(row) || row[j].IsNull() {
+				continue
+			}
+			b, _, _ := convert(row[j].Raw(), cc.tablet, cc.client, false)
+			row[j] = sqltypes.MakeString(b)
+		}
+		rows[i] = row
+	}
+	qr.Rows = rows
+}
+
+// convertShardQueries is convertQuery for queries that have different
+// sqls or bind variables per shard. sqls may be nil.
+func (cc *charsetConverter) convertShardQueries(sqls map[string]string, shardVars map[string]map[string]interface{}) (map[string]string, map[string]map[string]interface{}, error) {
+	if cc == nil {
+		return sqls, shardVars, nil
+	}
+	var convertedSqls map[string]string
+	if sqls != nil {
+		convertedSqls = make(map[string]string, len(sqls))
+		for shard, sql := range sqls {
+			sql, _, err := cc.convertQuery(sql, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			convertedSqls[shard] = sql
+		}
+	}
+	convertedVars := make(map[string]map[string]interface{}, len(shardVars))
+	for shard, bindVars := range shardVars {
+		_, bindVars, err := cc.convertQuery("", bindVars)
+		if err != nil {
+			return nil, nil, err
+		}
+		convertedVars[shard] = bindVars
+	}
+	return convertedSqls, convertedVars, nil
+}
+
+// convertSendReply returns a sendReply function that converts the
+// results before sending them.
+func (cc *charsetConverter) convertSendReply(sendReply func(*mproto.QueryResult) error) func(*mproto.QueryResult) error {
+	if cc == nil {
+		return sendReply
+	}
+	return func(qr *mproto.QueryResult) error {
+		cc.convertResult(qr)
+		return sendReply(qr)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtgate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/vtgate/proto"
+	"golang.org/x/net/context"
+)
+
+func TestConvertCharset(t *testing.T) {
+	utf8, latin1, ascii := charsets["utf8"], charsets["latin1"], charsets["ascii"]
+	tcases := []struct {
+		in       string
+		from, to charset
+		strict   bool
+		out      string
+		ok       bool
+	}{
+		{"caf\xe9 \x80", latin1, utf8, true, "café €", true},
+		{"café €", utf8, latin1, true, "caf\xe9 \x80", true},
+		{"café", utf8, ascii, false, "caf?", true},
+		{"café", utf8, ascii, true, "", false},
+		{"😀", charsets["utf8mb4"], utf8, false, "?", true},
+		{"a\xffb", utf8, latin1, false, "a?b", true},
+	}
+	for _, tcase := range tcases {
+		out, _, ok := convert([]byte(tcase.in), tcase.from, tcase.to, tcase.strict)
+		if ok != tcase.ok || string(out) != tcase.out {
+			t.Errorf("convert(%q) = %q, %v, want %q, %v", tcase.in, out, ok, tcase.out, tcase.ok)
+		}
+	}
+}
+
+func TestConvertSQL(t *testing.T) {
+	upper := func(s string) (string, error) { return strings.ToUpper(s), nil }
+	tcases := []struct {
+		in, out string
+	}{
+		{"select 'a'", "SELECT 'A'"},
+		{"select _binary'a', 'b'", "SELECT _BINARY'a', 'B'"},
+		{"select _BINARY 'a''b', x", "SELECT _BINARY 'a''b', X"},
+		{"select _binary'a\\'b', 'c'", "SELECT _BINARY'a\\'b', 'C'"},
+		{"select my_binary'a'", "SELECT MY_BINARY'A'"},
+		{"select _binary\"a", "SELECT _BINARY\"a"},
+	}
+	for _, tcase := range tcases {
+		out, err := convertSQL(tcase.in, upper)
+		if err != nil || out != tcase.out {
+			t.Errorf("convertSQL(%q) = %q, %v, want %q", tcase.in, out, err, tcase.out)
+		}
+	}
+}
+
+func TestInitKeyspaceCharsets(t *testing.T) {
+	defer initKeyspaceCharsets("")
+	if err := initKeyspaceCharsets("ks1:latin1,ks2:BINARY"); err != nil {
+		t.Fatalf("initKeyspaceCharsets failed: %v", err)
+	}
+	want := map[string]string{"ks1": "latin1", "ks2": "binary"}
+	if !reflect.DeepEqual(keyspaceCharsets, want) {
+		t.Errorf("keyspaceCharsets = %v, want %v", keyspaceCharsets, want)
+	}
+	if got := keyspaceCharset("ks3"); got != defaultCharset {
+		t.Errorf("keyspaceCharset(ks3) = %v, want %v", got, defaultCharset)
+	}
+	for _, value := range []string{"ks1", "ks1:latin2", ":latin1"} {
+		if err := initKeyspaceCharsets(value); err == nil {
+			t.Errorf("initKeyspaceCharsets(%q) succeeded", value)
+		}
+	}
+}
+
+func TestScatterConnCharset(t *testing.T) {
+	s := createSandbox("TestScatterConnCharset")
+	sbc := &sandboxConn{}
+	s.MapTestConn("0", sbc)
+	stc := NewScatterConn(new(sandboxTopo), "", "aa", 1*time.Millisecond, 3, 2*time.Millisecond, 1*time.Millisecond, 24*time.Hour)
+	execute := func(sql string, bindVars map[string]interface{}, charset string) (*mproto.QueryResult, error) {
+		session := NewSafeSession(&proto.Session{Charset: charset})
+		return stc.Execute(context.Background(), sql, bindVars, "TestScatterConnCharset", []string{"0"}, "", session, nil)
+	}
+
+	// a latin1 client of a utf8 keyspace
+	sbc.setResults([]*mproto.QueryResult{{
+		Fields: []mproto.Field{
+			{Name: "name", Type: mproto.VT_VAR_STRING},
+			{Name: "data", Type: mproto.VT_BLOB, Flags: mproto.VT_BINARY_FLAG},
+			{Name: "description", Type: mproto.VT_BLOB},
+			{Name: "hash", Type: mproto.VT_VAR_STRING, Flags: mproto.VT_BINARY_FLAG},
+		},
+		RowsAffected: 1,
+		Rows: [][]sqltypes.Value{{
+			sqltypes.MakeString([]byte("café")),
+			sqltypes.MakeString([]byte("café")),
+			sqltypes.MakeString([]byte("café")),
+			sqltypes.MakeString([]byte{0xff, 0xe9}),
+		}},
+	}})
+	qr, err := execute("select name, data, description, hash from t where name = 'caf\xe9' and hash = _binary'\xff\xe9'", map[string]interface{}{"v": "\xe9t\xe9", "b": []byte{0xe9}}, "latin1")
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got, want := sbc.Queries[0].Sql, "select name, data, description, hash from t where name = 'café' and hash = _binary'\xff\xe9'"; got != want {
+		t.Errorf("query = %q, want %q", got, want)
+	}
+	wantVars := map[string]interface{}{"v": "été", "b": []byte{0xe9}}
+	if !reflect.DeepEqual(sbc.Queries[0].BindVariables, wantVars) {
+		t.Errorf("bind variables = %v, want %v", sbc.Queries[0].BindVariables, wantVars)
+	}
+	if got := qr.Rows[0][0].String(); got != "caf\xe9" {
+		t.Errorf("text value = %q, want %q", got, "caf\xe9")
+	}
+	if got := qr.Rows[0][1].String(); got != "café" {
+		t.Errorf("blob value = %q, want it unchanged", got)
+	}
+	if got := qr.Rows[0][2].String(); got != "caf\xe9" {
+		t.Errorf("text value = %q, want %q", got, "caf\xe9")
+	}
+	if got := qr.Rows[0][3].String(); got != "\xff\xe9" {
+		t.Errorf("varbinary value = %q, want it unchanged", got)
+	}
+
+	// the characters a keyspace can't store are rejected
+	keyspaceCharsets = map[string]string{"TestScatterConnCharset": "latin1"}
+	defer initKeyspaceCharsets("")
+	if _, err := execute("insert into t values ('😀')", nil, "utf8mb4"); err == nil || !strings.Contains(err.Error(), "can't be represented") {
+		t.Errorf("want a conversion error, got %v", err)
+	}
+
+	if _, err := execute("select 1 from t", nil, "latin2"); err == nil || !strings.Contains(err.Error(), "unsupported character set") {
+		t.Errorf("want an unsupported character set error, got %v", err)
+	}
+	if len(sbc.Queries) != 1 {
+		t.Errorf("want 1 query, got %v", sbc.Queries)
+	}
+}
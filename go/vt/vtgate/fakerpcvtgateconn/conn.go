@@ -173,14 +173,25 @@ func (conn *FakeVTGateConn) StreamExecute(ctx context.Context, query string, bin
 
 // Begin please see vtgateconn.VTGateConn.Begin
 func (conn *FakeVTGateConn) Begin(ctx context.Context) (vtgateconn.VTGateTx, error) {
+	return conn.BeginWithOptions(ctx, nil)
+}
+
+// BeginWithOptions please see vtgateconn.VTGateConn.BeginWithOptions
+func (conn *FakeVTGateConn) BeginWithOptions(ctx context.Context, options *tproto.TransactionOptions) (vtgateconn.VTGateTx, error) {
 	tx := &fakeVTGateTx{
 		conn: conn,
 		session: &proto.Session{
-			InTransaction: true,
+			InTransaction:      true,
+			TransactionOptions: options,
 		}}
 	return tx, nil
 }
 
+// NewSession please see vtgateconn.VTGateConn.NewSession
+func (conn *FakeVTGateConn) NewSession(options vtgateconn.SessionOptions) vtgateconn.VTGateSession {
+	return &fakeVTGateSession{conn: conn, session: options.Session()}
+}
+
 // SplitQuery please see vtgateconn.VTGateConn.SplitQuery
 func (conn *FakeVTGateConn) SplitQuery(ctx context.Context, keyspace string, query tproto.BoundQuery, splitCount int) ([]proto.SplitQueryPart, error) {
 	response, ok := conn.splitQueryMap[getSplitQueryKey(keyspace, &query, splitCount)]
@@ -194,10 +205,47 @@ func (conn *FakeVTGateConn) SplitQuery(ctx context.Context, keyspace string, que
 	return reply, nil
 }
 
+// Explain please see vtgateconn.VTGateConn.Explain
+func (conn *FakeVTGateConn) Explain(ctx context.Context, query string, bindVars map[string]interface{}, tabletType topo.TabletType) (*proto.ExplainResult, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
 // Close please see vtgateconn.VTGateConn.Close
 func (conn *FakeVTGateConn) Close() {
 }
 
+type fakeVTGateSession struct {
+	conn    *FakeVTGateConn
+	session *proto.Session
+}
+
+// fakeVTGateSession has to implement vtgateconn.VTGateSession interface
+var _ vtgateconn.VTGateSession = (*fakeVTGateSession)(nil)
+
+func (s *fakeVTGateSession) Execute(ctx context.Context, query string, bindVars map[string]interface{}, tabletType topo.TabletType) (*mproto.QueryResult, error) {
+	return s.conn.execute(
+		ctx,
+		&proto.Query{
+			Sql:           query,
+			BindVariables: bindVars,
+			TabletType:    tabletType,
+			Session:       s.session,
+		})
+}
+
+func (s *fakeVTGateSession) ExecuteShard(ctx context.Context, query string, keyspace string, shards []string, bindVars map[string]interface{}, tabletType topo.TabletType) (*mproto.QueryResult, error) {
+	return s.conn.executeShard(
+		ctx,
+		&proto.QueryShard{
+			Sql:           query,
+			BindVariables: bindVars,
+			TabletType:    tabletType,
+			Keyspace:      keyspace,
+			Shards:        shards,
+			Session:       s.session,
+		})
+}
+
 type fakeVTGateTx struct {
 	conn    *FakeVTGateConn
 	session *proto.Session
@@ -6,6 +6,7 @@
 package gorpcvtgateconn
 
 import (
+	"crypto/tls"
 	"errors"
 	"strings"
 	"time"
@@ -18,9 +19,12 @@ import (
 	"github.com/youtube/vitess/go/vt/topo"
 	"github.com/youtube/vitess/go/vt/vtgate/proto"
 	"github.com/youtube/vitess/go/vt/vtgate/vtgateconn"
+	"github.com/youtube/vitess/go/vt/vttls"
 	"golang.org/x/net/context"
 )
 
+var vtgateBsonTLS = vttls.RegisterClientFlags("vtgate-bson", "vtgate")
+
 func init() {
 	vtgateconn.RegisterDialer("gorpc", dial)
 }
@@ -34,7 +38,15 @@ func dial(ctx context.Context, address string, timeout time.Duration) (vtgatecon
 	if strings.Contains(address, "/") {
 		network = "unix"
 	}
-	rpcConn, err := bsonrpc.DialHTTP(network, address, timeout, nil)
+	// unix sockets are local, they are not encrypted
+	var config *tls.Config
+	if network == "tcp" {
+		var err error
+		if config, err = vtgateBsonTLS.Config(); err != nil {
+			return nil, err
+		}
+	}
+	rpcConn, err := bsonrpc.DialHTTP(network, address, timeout, config)
 	if err != nil {
 		return nil, err
 	}
@@ -107,10 +119,17 @@ func (conn *vtgateConn) StreamExecute(ctx context.Context, query string, bindVar
 }
 
 func (conn *vtgateConn) Begin(ctx context.Context) (vtgateconn.VTGateTx, error) {
+	return conn.BeginWithOptions(ctx, nil)
+}
+
+func (conn *vtgateConn) BeginWithOptions(ctx context.Context, options *tproto.TransactionOptions) (vtgateconn.VTGateTx, error) {
 	tx := &vtgateTx{conn: conn, session: &proto.Session{}}
 	if err := conn.rpcConn.Call(ctx, "VTGate.Begin", &rpc.Unused{}, tx.session); err != nil {
 		return nil, err
 	}
+	// vtgate begins the transactions on the shards with the first
+	// query, it gets the options from the session.
+	tx.session.TransactionOptions = options
 	return tx, nil
 }
 
@@ -127,10 +146,48 @@ func (conn *vtgateConn) SplitQuery(ctx context.Context, keyspace string, query t
 	return result.Splits, nil
 }
 
+func (conn *vtgateConn) Explain(ctx context.Context, query string, bindVars map[string]interface{}, tabletType topo.TabletType) (*proto.ExplainResult, error) {
+	request := &proto.Query{
+		Sql:           query,
+		BindVariables: bindVars,
+		TabletType:    tabletType,
+	}
+	result := &proto.ExplainResult{}
+	if err := conn.rpcConn.Call(ctx, "VTGate.Explain", request, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func (conn *vtgateConn) Close() {
 	conn.rpcConn.Close()
 }
 
+func (conn *vtgateConn) NewSession(options vtgateconn.SessionOptions) vtgateconn.VTGateSession {
+	return &vtgateSession{conn: conn, session: options.Session()}
+}
+
+type vtgateSession struct {
+	conn    *vtgateConn
+	session *proto.Session
+}
+
+func (s *vtgateSession) Execute(ctx context.Context, query string, bindVars map[string]interface{}, tabletType topo.TabletType) (*mproto.QueryResult, error) {
+	r, session, err := s.conn.execute(ctx, query, bindVars, tabletType, s.session)
+	if session != nil {
+		s.session = session
+	}
+	return r, err
+}
+
+func (s *vtgateSession) ExecuteShard(ctx context.Context, query string, keyspace string, shards []string, bindVars map[string]interface{}, tabletType topo.TabletType) (*mproto.QueryResult, error) {
+	r, session, err := s.conn.executeShard(ctx, query, keyspace, shards, bindVars, tabletType, s.session)
+	if session != nil {
+		s.session = session
+	}
+	return r, err
+}
+
 type vtgateTx struct {
 	conn    *vtgateConn
 	session *proto.Session
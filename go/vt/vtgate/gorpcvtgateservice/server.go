@@ -150,6 +150,14 @@ func (vtg *VTGate) SplitQuery(ctx context.Context, req *proto.SplitQueryRequest,
 	return vtg.server.SplitQuery(callinfo.RPCWrapCallInfo(ctx), req, reply)
 }
 
+// Explain is the RPC version of vtgateservice.VTGateService method
+func (vtg *VTGate) Explain(ctx context.Context, query *proto.Query, reply *proto.ExplainResult) (err error) {
+	defer vtg.server.HandlePanic(&err)
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(*rpcTimeout))
+	defer cancel()
+	return vtg.server.Explain(callinfo.RPCWrapCallInfo(ctx), query, reply)
+}
+
 // New returns a new VTGate service
 func New(vtGate vtgateservice.VTGateService) *VTGate {
 	return &VTGate{vtGate}
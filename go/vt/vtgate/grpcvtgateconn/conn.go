@@ -0,0 +1,307 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package grpcvtgateconn provides gRPC connectivity for VTGate,
+// selected with -vtgate_protocol grpc. It mirrors gorpcvtgateconn.
+package grpcvtgateconn
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vtgate/proto"
+	"github.com/youtube/vitess/go/vt/vtgate/vtgateconn"
+	"github.com/youtube/vitess/go/vt/vttls"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	pbq "github.com/youtube/vitess/go/vt/proto/query"
+	pb "github.com/youtube/vitess/go/vt/proto/vtgate"
+)
+
+var vtgateGRPCTLS = vttls.RegisterClientFlags("vtgate-grpc", "vtgate over gRPC")
+
+func init() {
+	vtgateconn.RegisterDialer("grpc", dial)
+}
+
+type vtgateConn struct {
+	cc *grpc.ClientConn
+	c  pb.VitessClient
+}
+
+func dial(ctx context.Context, address string, timeout time.Duration) (vtgateconn.VTGateConn, error) {
+	config, err := vtgateGRPCTLS.Config()
+	if err != nil {
+		return nil, err
+	}
+	var opts []grpc.DialOption
+	if config != nil {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+	}
+	cc, err := grpc.Dial(address, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &vtgateConn{
+		cc: cc,
+		c:  pb.NewVitessClient(cc),
+	}, nil
+}
+
+func (conn *vtgateConn) Execute(ctx context.Context, query string, bindVars map[string]interface{}, tabletType topo.TabletType) (*mproto.QueryResult, error) {
+	r, _, err := conn.execute(ctx, query, bindVars, tabletType, nil)
+	return r, err
+}
+
+func (conn *vtgateConn) execute(ctx context.Context, query string, bindVars map[string]interface{}, tabletType topo.TabletType, session *proto.Session) (*mproto.QueryResult, *proto.Session, error) {
+	q, err := proto.BoundQueryToProto3(query, bindVars)
+	if err != nil {
+		return nil, session, err
+	}
+	response, err := conn.c.Execute(ctx, &pb.ExecuteRequest{
+		Query:      q,
+		TabletType: string(tabletType),
+		Session:    proto.SessionToProto3(session),
+	})
+	if err != nil {
+		return nil, session, err
+	}
+	return executeResult(response)
+}
+
+func (conn *vtgateConn) ExecuteShard(ctx context.Context, query string, keyspace string, shards []string, bindVars map[string]interface{}, tabletType topo.TabletType) (*mproto.QueryResult, error) {
+	r, _, err := conn.executeShard(ctx, query, keyspace, shards, bindVars, tabletType, nil)
+	return r, err
+}
+
+func (conn *vtgateConn) executeShard(ctx context.Context, query string, keyspace string, shards []string, bindVars map[string]interface{}, tabletType topo.TabletType, session *proto.Session) (*mproto.QueryResult, *proto.Session, error) {
+	q, err := proto.BoundQueryToProto3(query, bindVars)
+	if err != nil {
+		return nil, session, err
+	}
+	response, err := conn.c.ExecuteShard(ctx, &pb.ExecuteShardRequest{
+		Query:      q,
+		Keyspace:   keyspace,
+		Shards:     shards,
+		TabletType: string(tabletType),
+		Session:    proto.SessionToProto3(session),
+	})
+	if err != nil {
+		return nil, session, err
+	}
+	return executeResult(response)
+}
+
+// executeResult returns the result, session and error of an Execute*
+// response.
+func executeResult(response *pb.ExecuteResponse) (*mproto.QueryResult, *proto.Session, error) {
+	session := proto.Proto3ToSession(response.Session)
+	if err := rpcErrorToError(response.Error); err != nil {
+		return nil, session, err
+	}
+	if response.Result == nil {
+		return nil, session, nil
+	}
+	qr, err := tproto.Proto3ToQueryResult(response.Result)
+	if err != nil {
+		return nil, session, err
+	}
+	return qr, session, nil
+}
+
+func (conn *vtgateConn) StreamExecute(ctx context.Context, query string, bindVars map[string]interface{}, tabletType topo.TabletType) (<-chan *mproto.QueryResult, vtgateconn.ErrFunc) {
+	srout := make(chan *mproto.QueryResult, 10)
+	q, err := proto.BoundQueryToProto3(query, bindVars)
+	if err != nil {
+		close(srout)
+		return srout, func() error { return err }
+	}
+	stream, err := conn.c.StreamExecute(ctx, &pb.ExecuteRequest{
+		Query:      q,
+		TabletType: string(tabletType),
+	})
+	if err != nil {
+		close(srout)
+		return srout, func() error { return err }
+	}
+	var finalError error
+	go func() {
+		defer close(srout)
+		for {
+			response, err := stream.Recv()
+			if err == nil {
+				err = rpcErrorToError(response.Error)
+			}
+			if err != nil {
+				if err != io.EOF {
+					finalError = err
+				}
+				return
+			}
+			if response.Result == nil {
+				continue
+			}
+			qr, err := tproto.Proto3ToQueryResult(response.Result)
+			if err != nil {
+				finalError = err
+				return
+			}
+			srout <- qr
+		}
+	}()
+	return srout, func() error { return finalError }
+}
+
+func (conn *vtgateConn) Begin(ctx context.Context) (vtgateconn.VTGateTx, error) {
+	return conn.BeginWithOptions(ctx, nil)
+}
+
+func (conn *vtgateConn) BeginWithOptions(ctx context.Context, options *tproto.TransactionOptions) (vtgateconn.VTGateTx, error) {
+	response, err := conn.c.Begin(ctx, &pb.BeginRequest{})
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	if err != nil {
+		return nil, err
+	}
+	session := proto.Proto3ToSession(response.Session)
+	if session == nil {
+		session = &proto.Session{}
+	}
+	// vtgate begins the transactions on the shards with the first
+	// query, it gets the options from the session.
+	session.TransactionOptions = options
+	return &vtgateTx{conn: conn, session: session}, nil
+}
+
+func (conn *vtgateConn) SplitQuery(ctx context.Context, keyspace string, query tproto.BoundQuery, splitCount int) ([]proto.SplitQueryPart, error) {
+	q, err := tproto.BoundQueryToProto3(&query)
+	if err != nil {
+		return nil, err
+	}
+	response, err := conn.c.SplitQuery(ctx, &pb.SplitQueryRequest{
+		Keyspace:   keyspace,
+		Query:      q,
+		SplitCount: int64(splitCount),
+	})
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return proto.Proto3ToSplitQueryParts(response.Splits)
+}
+
+func (conn *vtgateConn) Explain(ctx context.Context, query string, bindVars map[string]interface{}, tabletType topo.TabletType) (*proto.ExplainResult, error) {
+	q, err := proto.BoundQueryToProto3(query, bindVars)
+	if err != nil {
+		return nil, err
+	}
+	response, err := conn.c.Explain(ctx, &pb.ExecuteRequest{
+		Query:      q,
+		TabletType: string(tabletType),
+	})
+	if err == nil {
+		err = rpcErrorToError(response.Error)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return proto.Proto3ToExplainResult(response), nil
+}
+
+func (conn *vtgateConn) Close() {
+	conn.cc.Close()
+}
+
+func (conn *vtgateConn) NewSession(options vtgateconn.SessionOptions) vtgateconn.VTGateSession {
+	return &vtgateSession{conn: conn, session: options.Session()}
+}
+
+type vtgateSession struct {
+	conn    *vtgateConn
+	session *proto.Session
+}
+
+func (s *vtgateSession) Execute(ctx context.Context, query string, bindVars map[string]interface{}, tabletType topo.TabletType) (*mproto.QueryResult, error) {
+	r, session, err := s.conn.execute(ctx, query, bindVars, tabletType, s.session)
+	if session != nil {
+		s.session = session
+	}
+	return r, err
+}
+
+func (s *vtgateSession) ExecuteShard(ctx context.Context, query string, keyspace string, shards []string, bindVars map[string]interface{}, tabletType topo.TabletType) (*mproto.QueryResult, error) {
+	r, session, err := s.conn.executeShard(ctx, query, keyspace, shards, bindVars, tabletType, s.session)
+	if session != nil {
+		s.session = session
+	}
+	return r, err
+}
+
+type vtgateTx struct {
+	conn    *vtgateConn
+	session *proto.Session
+}
+
+func (tx *vtgateTx) Execute(ctx context.Context, query string, bindVars map[string]interface{}, tabletType topo.TabletType) (*mproto.QueryResult, error) {
+	if tx.session == nil {
+		return nil, errors.New("execute: not in transaction")
+	}
+	r, session, err := tx.conn.execute(ctx, query, bindVars, tabletType, tx.session)
+	tx.session = session
+	return r, err
+}
+
+func (tx *vtgateTx) ExecuteShard(ctx context.Context, query string, keyspace string, shards []string, bindVars map[string]interface{}, tabletType topo.TabletType) (*mproto.QueryResult, error) {
+	if tx.session == nil {
+		return nil, errors.New("executeShard: not in transaction")
+	}
+	r, session, err := tx.conn.executeShard(ctx, query, keyspace, shards, bindVars, tabletType, tx.session)
+	tx.session = session
+	return r, err
+}
+
+func (tx *vtgateTx) Commit(ctx context.Context) error {
+	if tx.session == nil {
+		return errors.New("commit: not in transaction")
+	}
+	defer func() { tx.session = nil }()
+	response, err := tx.conn.c.Commit(ctx, &pb.CommitRequest{
+		Session: proto.SessionToProto3(tx.session),
+	})
+	if err != nil {
+		return err
+	}
+	return rpcErrorToError(response.Error)
+}
+
+func (tx *vtgateTx) Rollback(ctx context.Context) error {
+	if tx.session == nil {
+		return nil
+	}
+	defer func() { tx.session = nil }()
+	response, err := tx.conn.c.Rollback(ctx, &pb.RollbackRequest{
+		Session: proto.SessionToProto3(tx.session),
+	})
+	if err != nil {
+		return err
+	}
+	return rpcErrorToError(response.Error)
+}
+
+// rpcErrorToError returns the error sent by vtgate, or nil.
+func rpcErrorToError(rpcErr *pbq.RPCError) error {
+	if rpcErr == nil {
+		return nil
+	}
+	return errors.New(rpcErr.Message)
+}
@@ -0,0 +1,406 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package grpcvtgateservice contains the gRPC implementation of the
+// server side of the vtgate service. It mirrors gorpcvtgateservice.
+package grpcvtgateservice
+
+import (
+	"errors"
+
+	"google.golang.org/grpc"
+
+	"github.com/youtube/vitess/go/vt/callinfo"
+	"github.com/youtube/vitess/go/vt/servenv"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vterrors"
+	"github.com/youtube/vitess/go/vt/vtgate"
+	"github.com/youtube/vitess/go/vt/vtgate/proto"
+	"github.com/youtube/vitess/go/vt/vtgate/vtgateservice"
+	"golang.org/x/net/context"
+
+	pbq "github.com/youtube/vitess/go/vt/proto/query"
+	pb "github.com/youtube/vitess/go/vt/proto/vtgate"
+)
+
+// vtgateServer is the gRPC vtgate service implementation.
+// It implements the pb.VitessServer interface.
+type vtgateServer struct {
+	server vtgateservice.VTGateService
+}
+
+// rpcError returns the error to send back, or nil. vtgate returns
+// the errors of the queries in the replies, they are sent like the
+// errors of the calls.
+func rpcError(err error, replyError string) *pbq.RPCError {
+	if err == nil {
+		if replyError == "" {
+			return nil
+		}
+		err = errors.New(replyError)
+	}
+	return &pbq.RPCError{
+		Code:    int64(vterrors.RecoverVtErrorCode(err)),
+		Message: err.Error(),
+	}
+}
+
+// executeResponse returns the response of the Execute* calls.
+func executeResponse(reply *proto.QueryResult, err error) *pb.ExecuteResponse {
+	response := &pb.ExecuteResponse{
+		Session: proto.SessionToProto3(reply.Session),
+		Error:   rpcError(err, reply.Error),
+	}
+	if err == nil && reply.Result != nil {
+		response.Result = tproto.QueryResultToProto3(reply.Result)
+	}
+	return response
+}
+
+// executeBatchResponse returns the response of the ExecuteBatch* calls.
+func executeBatchResponse(reply *proto.QueryResultList, err error) *pb.ExecuteBatchResponse {
+	response := &pb.ExecuteBatchResponse{
+		Session: proto.SessionToProto3(reply.Session),
+		Error:   rpcError(err, reply.Error),
+	}
+	if err == nil && len(reply.List) > 0 {
+		response.Results = make([]*pbq.QueryResult, len(reply.List))
+		for i := range reply.List {
+			response.Results[i] = tproto.QueryResultToProto3(&reply.List[i])
+		}
+	}
+	return response
+}
+
+// sendReply returns the function that streams the results to the
+// client.
+func sendReply(send func(*pb.StreamExecuteResponse) error) func(*proto.QueryResult) error {
+	return func(reply *proto.QueryResult) error {
+		response := &pb.StreamExecuteResponse{
+			Error: rpcError(nil, reply.Error),
+		}
+		if reply.Result != nil {
+			response.Result = tproto.QueryResultToProto3(reply.Result)
+		}
+		return send(response)
+	}
+}
+
+// Execute is part of the pb.VitessServer interface
+func (v *vtgateServer) Execute(ctx context.Context, request *pb.ExecuteRequest) (response *pb.ExecuteResponse, err error) {
+	defer v.server.HandlePanic(&err)
+	ctx = callinfo.GRPCCallInfo(ctx)
+	bq, err := proto.Proto3ToBoundQuery(request.Query)
+	if err != nil {
+		return &pb.ExecuteResponse{Error: rpcError(err, "")}, nil
+	}
+	reply := new(proto.QueryResult)
+	err = v.server.Execute(ctx, &proto.Query{
+		Sql:           bq.Sql,
+		BindVariables: bq.BindVariables,
+		TabletType:    topo.TabletType(request.TabletType),
+		Session:       proto.Proto3ToSession(request.Session),
+	}, reply)
+	return executeResponse(reply, err), nil
+}
+
+// ExecuteShard is part of the pb.VitessServer interface
+func (v *vtgateServer) ExecuteShard(ctx context.Context, request *pb.ExecuteShardRequest) (response *pb.ExecuteResponse, err error) {
+	defer v.server.HandlePanic(&err)
+	ctx = callinfo.GRPCCallInfo(ctx)
+	query, err := proto3ToQueryShard(request)
+	if err != nil {
+		return &pb.ExecuteResponse{Error: rpcError(err, "")}, nil
+	}
+	reply := new(proto.QueryResult)
+	err = v.server.ExecuteShard(ctx, query, reply)
+	return executeResponse(reply, err), nil
+}
+
+// ExecuteKeyspaceIds is part of the pb.VitessServer interface
+func (v *vtgateServer) ExecuteKeyspaceIds(ctx context.Context, request *pb.ExecuteKeyspaceIdsRequest) (response *pb.ExecuteResponse, err error) {
+	defer v.server.HandlePanic(&err)
+	ctx = callinfo.GRPCCallInfo(ctx)
+	query, err := proto3ToKeyspaceIdQuery(request)
+	if err != nil {
+		return &pb.ExecuteResponse{Error: rpcError(err, "")}, nil
+	}
+	reply := new(proto.QueryResult)
+	err = v.server.ExecuteKeyspaceIds(ctx, query, reply)
+	return executeResponse(reply, err), nil
+}
+
+// ExecuteKeyRanges is part of the pb.VitessServer interface
+func (v *vtgateServer) ExecuteKeyRanges(ctx context.Context, request *pb.ExecuteKeyRangesRequest) (response *pb.ExecuteResponse, err error) {
+	defer v.server.HandlePanic(&err)
+	ctx = callinfo.GRPCCallInfo(ctx)
+	query, err := proto3ToKeyRangeQuery(request)
+	if err != nil {
+		return &pb.ExecuteResponse{Error: rpcError(err, "")}, nil
+	}
+	reply := new(proto.QueryResult)
+	err = v.server.ExecuteKeyRanges(ctx, query, reply)
+	return executeResponse(reply, err), nil
+}
+
+// ExecuteEntityIds is part of the pb.VitessServer interface
+func (v *vtgateServer) ExecuteEntityIds(ctx context.Context, request *pb.ExecuteEntityIdsRequest) (response *pb.ExecuteResponse, err error) {
+	defer v.server.HandlePanic(&err)
+	ctx = callinfo.GRPCCallInfo(ctx)
+	bq, err := proto.Proto3ToBoundQuery(request.Query)
+	if err != nil {
+		return &pb.ExecuteResponse{Error: rpcError(err, "")}, nil
+	}
+	entityIds, err := proto.Proto3ToEntityIds(request.EntityKeyspaceIds)
+	if err != nil {
+		return &pb.ExecuteResponse{Error: rpcError(err, "")}, nil
+	}
+	reply := new(proto.QueryResult)
+	err = v.server.ExecuteEntityIds(ctx, &proto.EntityIdsQuery{
+		Sql:               bq.Sql,
+		BindVariables:     bq.BindVariables,
+		Keyspace:          request.Keyspace,
+		EntityColumnName:  request.EntityColumnName,
+		EntityKeyspaceIDs: entityIds,
+		TabletType:        topo.TabletType(request.TabletType),
+		Session:           proto.Proto3ToSession(request.Session),
+	}, reply)
+	return executeResponse(reply, err), nil
+}
+
+// ExecuteBatchShard is part of the pb.VitessServer interface
+func (v *vtgateServer) ExecuteBatchShard(ctx context.Context, request *pb.ExecuteBatchShardRequest) (response *pb.ExecuteBatchResponse, err error) {
+	defer v.server.HandlePanic(&err)
+	ctx = callinfo.GRPCCallInfo(ctx)
+	queries, err := proto.Proto3ToBoundQueries(request.Queries)
+	if err != nil {
+		return &pb.ExecuteBatchResponse{Error: rpcError(err, "")}, nil
+	}
+	reply := new(proto.QueryResultList)
+	err = v.server.ExecuteBatchShard(ctx, &proto.BatchQueryShard{
+		Queries:    queries,
+		Keyspace:   request.Keyspace,
+		Shards:     proto.Proto3ToStrings(request.Shards),
+		TabletType: topo.TabletType(request.TabletType),
+		Session:    proto.Proto3ToSession(request.Session),
+	}, reply)
+	return executeBatchResponse(reply, err), nil
+}
+
+// ExecuteBatchKeyspaceIds is part of the pb.VitessServer interface
+func (v *vtgateServer) ExecuteBatchKeyspaceIds(ctx context.Context, request *pb.ExecuteBatchKeyspaceIdsRequest) (response *pb.ExecuteBatchResponse, err error) {
+	defer v.server.HandlePanic(&err)
+	ctx = callinfo.GRPCCallInfo(ctx)
+	queries, err := proto.Proto3ToBoundQueries(request.Queries)
+	if err != nil {
+		return &pb.ExecuteBatchResponse{Error: rpcError(err, "")}, nil
+	}
+	reply := new(proto.QueryResultList)
+	err = v.server.ExecuteBatchKeyspaceIds(ctx, &proto.KeyspaceIdBatchQuery{
+		Queries:     queries,
+		Keyspace:    request.Keyspace,
+		KeyspaceIds: proto.Proto3ToKeyspaceIds(request.KeyspaceIds),
+		TabletType:  topo.TabletType(request.TabletType),
+		Session:     proto.Proto3ToSession(request.Session),
+	}, reply)
+	return executeBatchResponse(reply, err), nil
+}
+
+// StreamExecute is part of the pb.VitessServer interface
+func (v *vtgateServer) StreamExecute(request *pb.ExecuteRequest, stream pb.Vitess_StreamExecuteServer) (err error) {
+	defer v.server.HandlePanic(&err)
+	ctx := callinfo.GRPCCallInfo(stream.Context())
+	bq, err := proto.Proto3ToBoundQuery(request.Query)
+	if err == nil {
+		err = v.server.StreamExecute(ctx, &proto.Query{
+			Sql:           bq.Sql,
+			BindVariables: bq.BindVariables,
+			TabletType:    topo.TabletType(request.TabletType),
+			Session:       proto.Proto3ToSession(request.Session),
+		}, sendReply(stream.Send))
+	}
+	if err != nil {
+		return stream.Send(&pb.StreamExecuteResponse{Error: rpcError(err, "")})
+	}
+	return nil
+}
+
+// StreamExecuteShard is part of the pb.VitessServer interface
+func (v *vtgateServer) StreamExecuteShard(request *pb.ExecuteShardRequest, stream pb.Vitess_StreamExecuteShardServer) (err error) {
+	defer v.server.HandlePanic(&err)
+	ctx := callinfo.GRPCCallInfo(stream.Context())
+	query, err := proto3ToQueryShard(request)
+	if err == nil {
+		err = v.server.StreamExecuteShard(ctx, query, sendReply(stream.Send))
+	}
+	if err != nil {
+		return stream.Send(&pb.StreamExecuteResponse{Error: rpcError(err, "")})
+	}
+	return nil
+}
+
+// StreamExecuteKeyspaceIds is part of the pb.VitessServer interface
+func (v *vtgateServer) StreamExecuteKeyspaceIds(request *pb.ExecuteKeyspaceIdsRequest, stream pb.Vitess_StreamExecuteKeyspaceIdsServer) (err error) {
+	defer v.server.HandlePanic(&err)
+	ctx := callinfo.GRPCCallInfo(stream.Context())
+	query, err := proto3ToKeyspaceIdQuery(request)
+	if err == nil {
+		err = v.server.StreamExecuteKeyspaceIds(ctx, query, sendReply(stream.Send))
+	}
+	if err != nil {
+		return stream.Send(&pb.StreamExecuteResponse{Error: rpcError(err, "")})
+	}
+	return nil
+}
+
+// StreamExecuteKeyRanges is part of the pb.VitessServer interface
+func (v *vtgateServer) StreamExecuteKeyRanges(request *pb.ExecuteKeyRangesRequest, stream pb.Vitess_StreamExecuteKeyRangesServer) (err error) {
+	defer v.server.HandlePanic(&err)
+	ctx := callinfo.GRPCCallInfo(stream.Context())
+	query, err := proto3ToKeyRangeQuery(request)
+	if err == nil {
+		err = v.server.StreamExecuteKeyRanges(ctx, query, sendReply(stream.Send))
+	}
+	if err != nil {
+		return stream.Send(&pb.StreamExecuteResponse{Error: rpcError(err, "")})
+	}
+	return nil
+}
+
+// Begin is part of the pb.VitessServer interface
+func (v *vtgateServer) Begin(ctx context.Context, request *pb.BeginRequest) (response *pb.BeginResponse, err error) {
+	defer v.server.HandlePanic(&err)
+	ctx = callinfo.GRPCCallInfo(ctx)
+	session := new(proto.Session)
+	if err := v.server.Begin(ctx, session); err != nil {
+		return &pb.BeginResponse{Error: rpcError(err, "")}, nil
+	}
+	return &pb.BeginResponse{Session: proto.SessionToProto3(session)}, nil
+}
+
+// Commit is part of the pb.VitessServer interface
+func (v *vtgateServer) Commit(ctx context.Context, request *pb.CommitRequest) (response *pb.CommitResponse, err error) {
+	defer v.server.HandlePanic(&err)
+	ctx = callinfo.GRPCCallInfo(ctx)
+	err = v.server.Commit(ctx, proto.Proto3ToSession(request.Session))
+	return &pb.CommitResponse{Error: rpcError(err, "")}, nil
+}
+
+// Rollback is part of the pb.VitessServer interface
+func (v *vtgateServer) Rollback(ctx context.Context, request *pb.RollbackRequest) (response *pb.RollbackResponse, err error) {
+	defer v.server.HandlePanic(&err)
+	ctx = callinfo.GRPCCallInfo(ctx)
+	err = v.server.Rollback(ctx, proto.Proto3ToSession(request.Session))
+	return &pb.RollbackResponse{Error: rpcError(err, "")}, nil
+}
+
+// SplitQuery is part of the pb.VitessServer interface
+func (v *vtgateServer) SplitQuery(ctx context.Context, request *pb.SplitQueryRequest) (response *pb.SplitQueryResponse, err error) {
+	defer v.server.HandlePanic(&err)
+	ctx = callinfo.GRPCCallInfo(ctx)
+	bq, err := proto.Proto3ToBoundQuery(request.Query)
+	if err != nil {
+		return &pb.SplitQueryResponse{Error: rpcError(err, "")}, nil
+	}
+	reply := new(proto.SplitQueryResult)
+	if err := v.server.SplitQuery(ctx, &proto.SplitQueryRequest{
+		Keyspace:   request.Keyspace,
+		Query:      *bq,
+		SplitCount: int(request.SplitCount),
+	}, reply); err != nil {
+		return &pb.SplitQueryResponse{Error: rpcError(err, "")}, nil
+	}
+	splits, err := proto.SplitQueryPartsToProto3(reply.Splits)
+	if err != nil {
+		return &pb.SplitQueryResponse{Error: rpcError(err, "")}, nil
+	}
+	return &pb.SplitQueryResponse{Splits: splits}, nil
+}
+
+// Explain is part of the pb.VitessServer interface
+func (v *vtgateServer) Explain(ctx context.Context, request *pb.ExecuteRequest) (response *pb.ExplainResponse, err error) {
+	defer v.server.HandlePanic(&err)
+	ctx = callinfo.GRPCCallInfo(ctx)
+	bq, err := proto.Proto3ToBoundQuery(request.Query)
+	if err != nil {
+		return &pb.ExplainResponse{Error: rpcError(err, "")}, nil
+	}
+	reply := new(proto.ExplainResult)
+	if err := v.server.Explain(ctx, &proto.Query{
+		Sql:           bq.Sql,
+		BindVariables: bq.BindVariables,
+		TabletType:    topo.TabletType(request.TabletType),
+		Session:       proto.Proto3ToSession(request.Session),
+	}, reply); err != nil {
+		return &pb.ExplainResponse{Error: rpcError(err, "")}, nil
+	}
+	return proto.ExplainResultToProto3(reply), nil
+}
+
+func proto3ToQueryShard(request *pb.ExecuteShardRequest) (*proto.QueryShard, error) {
+	bq, err := proto.Proto3ToBoundQuery(request.Query)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.QueryShard{
+		Sql:            bq.Sql,
+		BindVariables:  bq.BindVariables,
+		Keyspace:       request.Keyspace,
+		Shards:         proto.Proto3ToStrings(request.Shards),
+		TabletType:     topo.TabletType(request.TabletType),
+		Session:        proto.Proto3ToSession(request.Session),
+		FieldsOnly:     request.FieldsOnly,
+		IdempotencyKey: request.IdempotencyKey,
+	}, nil
+}
+
+func proto3ToKeyspaceIdQuery(request *pb.ExecuteKeyspaceIdsRequest) (*proto.KeyspaceIdQuery, error) {
+	bq, err := proto.Proto3ToBoundQuery(request.Query)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.KeyspaceIdQuery{
+		Sql:           bq.Sql,
+		BindVariables: bq.BindVariables,
+		Keyspace:      request.Keyspace,
+		KeyspaceIds:   proto.Proto3ToKeyspaceIds(request.KeyspaceIds),
+		TabletType:    topo.TabletType(request.TabletType),
+		Session:       proto.Proto3ToSession(request.Session),
+	}, nil
+}
+
+func proto3ToKeyRangeQuery(request *pb.ExecuteKeyRangesRequest) (*proto.KeyRangeQuery, error) {
+	bq, err := proto.Proto3ToBoundQuery(request.Query)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.KeyRangeQuery{
+		Sql:           bq.Sql,
+		BindVariables: bq.BindVariables,
+		Keyspace:      request.Keyspace,
+		KeyRanges:     proto.Proto3ToKeyRanges(request.KeyRanges),
+		TabletType:    topo.TabletType(request.TabletType),
+		Session:       proto.Proto3ToSession(request.Session),
+	}, nil
+}
+
+// New returns the gRPC vtgate service for the VTGateService
+// implementation.
+func New(server vtgateservice.VTGateService) pb.VitessServer {
+	return &vtgateServer{server}
+}
+
+// StartServer registers the vtgate service on the gRPC server.
+func StartServer(s *grpc.Server, server vtgateservice.VTGateService) {
+	pb.RegisterVitessServer(s, New(server))
+}
+
+func init() {
+	vtgate.RegisterVTGates = append(vtgate.RegisterVTGates, func(vtGate vtgateservice.VTGateService) {
+		if servenv.GRPCCheckServiceMap("vtgateservice") {
+			StartServer(servenv.GRPCServer, vtGate)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtgate
+
+import (
+	"flag"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/stats"
+	"golang.org/x/net/context"
+)
+
+var (
+	masterBufferWindow = flag.Duration("master_buffer_window", 0, "how long to hold the master queries of a shard while its master is failing over, 0 to disable buffering")
+	masterBufferSize   = flag.Int("master_buffer_size", 1000, "max number of master queries held per shard while its master is failing over")
+
+	// masterBufferRetryInterval is how often the held queries are
+	// retried, if no other query found the new master before.
+	masterBufferRetryInterval = 100 * time.Millisecond
+
+	masterBufferCounts = stats.NewCounters("VtgateMasterBuffer")
+)
+
+// masterBuffer holds the master queries of a shard while its master
+// is failing over, and lets them go when the new master is serving,
+// so applications don't see planned reparents.
+//
+// A failover starts with the first query that can't reach the master,
+// or fails with a retry error like NOT_MASTER, and lasts until a query
+// succeeds. Queries are held for at most window from the start of the
+// failover; after that they fail right away, until the master is back.
+type masterBuffer struct {
+	name    string
+	window  time.Duration
+	maxSize int
+
+	mu sync.Mutex
+	// start is the start of the current failover, or zero.
+	start time.Time
+	// waiting is the number of held queries.
+	waiting int
+	// done is closed when the current failover ends.
+	done chan struct{}
+}
+
+func newMasterBuffer(name string, window time.Duration, maxSize int) *masterBuffer {
+	return &masterBuffer{
+		name:    name,
+		window:  window,
+		maxSize: maxSize,
+	}
+}
+
+// wait holds a query that failed because of a failover, until the
+// failover ends or it is time to retry. It returns false if the
+// query should fail instead.
+func (mb *masterBuffer) wait(ctx context.Context) bool {
+	mb.mu.Lock()
+	now := time.Now()
+	if mb.start.IsZero() {
+		log.Infof("Master of %v is failing over, holding its queries for up to %v", mb.name, mb.window)
+		mb.start = now
+		mb.done = make(chan struct{})
+		masterBufferCounts.Add("Failovers", 1)
+	}
+	remaining := mb.start.Add(mb.window).Sub(now)
+	if remaining <= 0 {
+		mb.mu.Unlock()
+		masterBufferCounts.Add("WindowExceeded", 1)
+		return false
+	}
+	if mb.waiting >= mb.maxSize {
+		mb.mu.Unlock()
+		masterBufferCounts.Add("BufferFull", 1)
+		return false
+	}
+	mb.waiting++
+	done := mb.done
+	mb.mu.Unlock()
+	masterBufferCounts.Add("Buffered", 1)
+
+	defer func() {
+		mb.mu.Lock()
+		mb.waiting--
+		mb.mu.Unlock()
+	}()
+	if remaining > masterBufferRetryInterval {
+		remaining = masterBufferRetryInterval
+	}
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	case <-ctx.Done():
+		return false
+	}
+	return true
+}
+
+// stop ends the current failover, if any, and lets the held queries
+// go to the new master.
+func (mb *masterBuffer) stop() {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if mb.start.IsZero() {
+		return
+	}
+	log.Infof("Master of %v is serving again after %v, releasing %v queries", mb.name, time.Now().Sub(mb.start), mb.waiting)
+	close(mb.done)
+	mb.start = time.Time{}
+	mb.done = nil
+}
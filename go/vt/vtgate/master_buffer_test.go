@@ -0,0 +1,110 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtgate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
+)
+
+// This file uses the sandbox_test framework.
+
+func TestShardConnMasterBuffer(t *testing.T) {
+	defer func(window time.Duration, interval time.Duration) {
+		*masterBufferWindow = window
+		masterBufferRetryInterval = interval
+	}(*masterBufferWindow, masterBufferRetryInterval)
+	*masterBufferWindow = 10 * time.Second
+	masterBufferRetryInterval = time.Millisecond
+
+	s := createSandbox("TestShardConnMasterBuffer")
+	// the first round of retries fails, the second one succeeds
+	sbc := &sandboxConn{mustFailRetry: retryCount + 3}
+	s.MapTestConn("0", sbc)
+	sdc := NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnMasterBuffer", "0", topo.TYPE_MASTER, retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
+	buffered := masterBufferCounts.Counts()["Buffered"]
+	if _, err := sdc.Execute(context.Background(), "query", nil, 0, nil); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got := sbc.ExecCount.Get(); got != int64(retryCount+4) {
+		t.Errorf("want %v, got %v", retryCount+4, got)
+	}
+	if got := masterBufferCounts.Counts()["Buffered"] - buffered; got == 0 {
+		t.Errorf("the query was not buffered")
+	}
+	if !sdc.buffer.start.IsZero() {
+		t.Errorf("the failover was not ended by the successful query")
+	}
+
+	// queries in a transaction are not buffered
+	sbc.mustFailRetry = retryCount + 3
+	if _, err := sdc.Execute(context.Background(), "query", nil, 1, nil); err == nil {
+		t.Errorf("want error, got nil")
+	}
+
+	// replicas don't have a buffer
+	sdc = NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnMasterBuffer", "0", topo.TYPE_REPLICA, retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
+	if sdc.buffer != nil {
+		t.Errorf("replica ShardConn has a buffer")
+	}
+}
+
+func TestMasterBuffer(t *testing.T) {
+	defer func(interval time.Duration) {
+		masterBufferRetryInterval = interval
+	}(masterBufferRetryInterval)
+	masterBufferRetryInterval = 10 * time.Second
+
+	mb := newMasterBuffer("ks/0", 10*time.Second, 1)
+	result := make(chan bool)
+	go func() {
+		result <- mb.wait(context.Background())
+	}()
+	// wait for the query to be held
+	for {
+		mb.mu.Lock()
+		waiting := mb.waiting
+		mb.mu.Unlock()
+		if waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the buffer is full
+	if mb.wait(context.Background()) {
+		t.Errorf("query was held in a full buffer")
+	}
+
+	// the held query is released at the end of the failover
+	mb.stop()
+	select {
+	case retry := <-result:
+		if !retry {
+			t.Errorf("held query was not retried")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("held query was not released")
+	}
+
+	// canceled queries are not retried
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if mb.wait(ctx) {
+		t.Errorf("canceled query was retried")
+	}
+
+	// queries are not held after the window
+	mb.stop()
+	mb = newMasterBuffer("ks/0", time.Millisecond, 1)
+	mb.wait(context.Background())
+	time.Sleep(2 * time.Millisecond)
+	if mb.wait(context.Background()) {
+		t.Errorf("query was held after the window")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlvtgateservice
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// scrambleLength is the length of the random data of the
+// mysql_native_password authentication.
+const scrambleLength = 20
+
+// newScramble returns random printable bytes, without the NUL that
+// terminates them in the handshake.
+func newScramble() ([]byte, error) {
+	scramble := make([]byte, scrambleLength)
+	if _, err := rand.Read(scramble); err != nil {
+		return nil, err
+	}
+	for i, b := range scramble {
+		b &= 0x7f
+		if b == 0 || b == '$' {
+			b++
+		}
+		scramble[i] = b
+	}
+	return scramble, nil
+}
+
+// scramblePassword computes the mysql_native_password response of a
+// client: SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password))).
+func scramblePassword(scramble []byte, password string) []byte {
+	if password == "" {
+		return nil
+	}
+	hash := sha1.New()
+	hash.Write([]byte(password))
+	stage1 := hash.Sum(nil)
+
+	hash.Reset()
+	hash.Write(stage1)
+	stage2 := hash.Sum(nil)
+
+	hash.Reset()
+	hash.Write(scramble)
+	hash.Write(stage2)
+	result := hash.Sum(nil)
+	for i := range result {
+		result[i] ^= stage1[i]
+	}
+	return result
+}
+
+// authenticator checks the credentials of the clients.
+type authenticator struct {
+	// users maps the user names to their password.
+	users map[string]string
+}
+
+// loadAuthenticator reads the users of a JSON file mapping the user
+// names to their password.
+func loadAuthenticator(file string) (*authenticator, error) {
+	if file == "" {
+		return nil, fmt.Errorf("the MySQL protocol server needs a users file")
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[string]string)
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("cannot parse users file %v: %v", file, err)
+	}
+	return &authenticator{users: users}, nil
+}
+
+// check returns true if the auth response of the user matches its
+// password.
+func (a *authenticator) check(user string, scramble, response []byte) bool {
+	password, ok := a.users[user]
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare(scramblePassword(scramble, password), response) == 1
+}
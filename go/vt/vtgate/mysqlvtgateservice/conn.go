@@ -0,0 +1,395 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlvtgateservice
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/golang/glog"
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/callinfo"
+	"github.com/youtube/vitess/go/vt/sqlparser"
+	"github.com/youtube/vitess/go/vt/vtgate/proto"
+	"golang.org/x/net/context"
+)
+
+// conn is a client connection. Its session is the vtgate session of
+// the client, the transactions span its queries.
+type conn struct {
+	*packetConn
+	server  *Server
+	id      uint32
+	user    string
+	session *proto.Session
+
+	statements      map[uint32]*statement
+	nextStatementID uint32
+}
+
+func newConn(server *Server, netConn net.Conn, id uint32) *conn {
+	return &conn{
+		packetConn: newPacketConn(netConn, maxHandshakeSize),
+		server:     server,
+		id:         id,
+		session:    &proto.Session{},
+		statements: make(map[uint32]*statement),
+	}
+}
+
+// RemoteAddr, Username, Text and HTML implement callinfo.CallInfo.
+
+func (c *conn) RemoteAddr() string {
+	return c.conn.RemoteAddr().String()
+}
+
+func (c *conn) Username() string {
+	return c.user
+}
+
+func (c *conn) Text() string {
+	return fmt.Sprintf("%s@%s (mysql connection %v)", c.user, c.RemoteAddr(), c.id)
+}
+
+func (c *conn) HTML() template.HTML {
+	return template.HTML("<b>RemoteAddr:</b> " + template.HTMLEscapeString(c.RemoteAddr()) + "</br>\n" + "<b>Username:</b> " + template.HTMLEscapeString(c.user) + "</br>\n")
+}
+
+// serve runs the handshake and the commands of the connection until
+// the client quits or the connection fails.
+func (c *conn) serve() {
+	defer c.close()
+	// the clients that don't authenticate would hold their connection
+	// forever. The deadline errors are ignored, like net/http does.
+	if c.server.handshakeTimeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.server.handshakeTimeout))
+	}
+	if err := c.handshake(); err != nil {
+		log.Infof("mysql connection %v: handshake failed: %v", c.id, err)
+		return
+	}
+	c.conn.SetDeadline(time.Time{})
+	for {
+		c.seq = 0
+		data, err := c.readPacket()
+		if err != nil {
+			if err != io.EOF {
+				log.Infof("mysql connection %v: %v", c.id, err)
+			}
+			return
+		}
+		if len(data) == 0 || data[0] == comQuit {
+			return
+		}
+		if err := c.dispatch(data[0], data[1:]); err != nil {
+			log.Infof("mysql connection %v: %v", c.id, err)
+			return
+		}
+		if err := c.flush(); err != nil {
+			return
+		}
+	}
+}
+
+// refuse tells the client there are too many connections, and closes
+// the connection.
+func (c *conn) refuse() {
+	defer c.conn.Close()
+	if c.server.handshakeTimeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.server.handshakeTimeout))
+	}
+	c.writeError(errTooManyConns, sqlStateConnection, "Too many connections")
+	c.flush()
+}
+
+// close rolls back the transaction the client left open.
+func (c *conn) close() {
+	if c.session.InTransaction {
+		if err := c.server.vtgate.Rollback(c.context(), c.session); err != nil {
+			log.Warningf("mysql connection %v: rollback failed: %v", c.id, err)
+		}
+	}
+	c.conn.Close()
+}
+
+func (c *conn) context() context.Context {
+	return callinfo.NewContext(context.Background(), c)
+}
+
+// handshake sends the initial handshake packet, and authenticates the
+// handshake response of the client with mysql_native_password.
+func (c *conn) handshake() error {
+	scramble, err := newScramble()
+	if err != nil {
+		return err
+	}
+	b := []byte{protocolVersion}
+	b = appendNullString(b, serverVersion)
+	b = appendUint32(b, c.id)
+	b = append(b, scramble[:8]...)
+	b = append(b, 0)
+	b = appendUint16(b, uint16(serverCapabilities&0xffff))
+	b = append(b, charsetUTF8)
+	b = appendUint16(b, serverStatusAutocommit)
+	b = appendUint16(b, uint16(serverCapabilities>>16))
+	b = append(b, scrambleLength+1)
+	b = append(b, make([]byte, 10)...)
+	b = append(b, scramble[8:]...)
+	b = append(b, 0)
+	b = appendNullString(b, mysqlNativePassword)
+	if err := c.writePacket(b); err != nil {
+		return err
+	}
+	if err := c.flush(); err != nil {
+		return err
+	}
+
+	data, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	pr := &payloadReader{data: data}
+	capabilities := pr.uint32()
+	if pr.err == nil && capabilities&clientProtocol41 == 0 {
+		c.writeError(errUnknown, sqlStateGeneral, "the client must support the 4.1 protocol")
+		c.flush()
+		return fmt.Errorf("client without 4.1 protocol")
+	}
+	pr.uint32() // max packet size
+	collation := pr.uint8()
+	pr.bytes(23)
+	user := pr.nullString()
+	var authResponse []byte
+	switch {
+	case capabilities&clientPluginAuthLenencData != 0:
+		authResponse = pr.lenEncString()
+	case capabilities&clientSecureConnection != 0:
+		authResponse = pr.bytes(int(pr.uint8()))
+	default:
+		authResponse = []byte(pr.nullString())
+	}
+	if capabilities&clientConnectWithDB != 0 && len(pr.data) > 0 {
+		// the keyspaces come from the vschema, the database is
+		// ignored.
+		pr.nullString()
+	}
+	plugin := mysqlNativePassword
+	if capabilities&clientPluginAuth != 0 && len(pr.data) > 0 {
+		plugin = pr.nullString()
+	}
+	if pr.err != nil {
+		return pr.err
+	}
+
+	if plugin != mysqlNativePassword {
+		b := []byte{authSwitchPacket}
+		b = appendNullString(b, mysqlNativePassword)
+		b = append(b, scramble...)
+		b = append(b, 0)
+		if err := c.writePacket(b); err != nil {
+			return err
+		}
+		if err := c.flush(); err != nil {
+			return err
+		}
+		if authResponse, err = c.readPacket(); err != nil {
+			return err
+		}
+	}
+
+	if !c.server.auth.check(user, scramble, authResponse) {
+		c.writeError(errAccessDenied, sqlStateAccess, "Access denied for user '%v'", user)
+		c.flush()
+		return fmt.Errorf("access denied for user %v", user)
+	}
+	c.user = user
+	if charset, ok := collationCharsets[collation]; ok {
+		c.session.Charset = charset
+	}
+	c.maxSize = c.server.maxPacketSize
+	if err := c.writeOK(0, 0); err != nil {
+		return err
+	}
+	return c.flush()
+}
+
+// dispatch runs a command. It only returns the errors of the
+// connection, the others are sent to the client.
+func (c *conn) dispatch(command byte, data []byte) error {
+	switch command {
+	case comQuery:
+		qr, err := c.execute(string(data), nil)
+		if err != nil {
+			return c.writeExecuteError(err)
+		}
+		return c.writeResult(qr, false)
+	case comInitDB, comPing:
+		return c.writeOK(0, 0)
+	case comStmtPrepare:
+		return c.prepare(string(data))
+	case comStmtExecute:
+		return c.executeStatement(data)
+	case comStmtClose:
+		c.closeStatement(data)
+		return nil
+	case comStmtReset:
+		return c.resetStatement(data)
+	}
+	return c.writeError(errUnknownComError, sqlStateGeneral, "command %v is not supported", command)
+}
+
+// errnoRegexp extracts the MySQL error code of the tablet errors.
+var errnoRegexp = regexp.MustCompile(`errno (\d+)`)
+
+func (c *conn) writeExecuteError(err error) error {
+	code, sqlState := uint16(errUnknown), sqlStateGeneral
+	if match := errnoRegexp.FindStringSubmatch(err.Error()); match != nil {
+		if n, perr := strconv.ParseUint(match[1], 10, 16); perr == nil {
+			code = uint16(n)
+		}
+		if code == errDupEntry {
+			sqlState = sqlStateDupEntry
+		}
+	}
+	return c.writeError(code, sqlState, "%v", err)
+}
+
+// execute runs a query. The transaction and session statements are
+// run locally, the others by vtgate.
+func (c *conn) execute(sql string, bindVars map[string]interface{}) (qr *mproto.QueryResult, err error) {
+	defer c.server.vtgate.HandlePanic(&err)
+	if qr, ok, err := c.executeLocal(sql); ok {
+		return qr, err
+	}
+	reply := new(proto.QueryResult)
+	query := &proto.Query{
+		Sql:           sql,
+		BindVariables: bindVars,
+		TabletType:    c.server.tabletType,
+		Session:       c.session,
+	}
+	if err := c.server.vtgate.Execute(c.context(), query, reply); err != nil {
+		return nil, err
+	}
+	if reply.Session != nil {
+		c.session = reply.Session
+	}
+	if reply.Error != "" {
+		return nil, fmt.Errorf("%v", reply.Error)
+	}
+	return reply.Result, nil
+}
+
+// queryWords returns the lower case words of sql, without the comments
+// and the punctuation.
+func queryWords(sql string) []string {
+	tokenizer := sqlparser.NewStringTokenizer(sql)
+	var words []string
+	for {
+		typ, val := tokenizer.Scan()
+		if typ == 0 || typ == sqlparser.LEX_ERROR {
+			return words
+		}
+		if typ == sqlparser.COMMENT || len(val) == 0 {
+			continue
+		}
+		words = append(words, strings.ToLower(string(val)))
+	}
+}
+
+// executeLocal runs the statements vtgate doesn't know: the
+// transaction statements, the session variables the clients set when
+// they connect, and the version comment of the mysql command line
+// client. ok is false for the other statements.
+func (c *conn) executeLocal(sql string) (qr *mproto.QueryResult, ok bool, err error) {
+	words := queryWords(sql)
+	is := func(want ...string) bool {
+		if len(words) != len(want) {
+			return false
+		}
+		for i, word := range want {
+			if word != "" && words[i] != word {
+				return false
+			}
+		}
+		return true
+	}
+	switch {
+	case is("begin"), is("start", "transaction"):
+		if c.session.InTransaction {
+			// like MySQL, commit the current transaction
+			if err := c.server.vtgate.Commit(c.context(), c.session); err != nil {
+				return nil, true, err
+			}
+		}
+		return &mproto.QueryResult{}, true, c.server.vtgate.Begin(c.context(), c.session)
+	case is("commit"), is("commit", "work"):
+		if !c.session.InTransaction {
+			return &mproto.QueryResult{}, true, nil
+		}
+		return &mproto.QueryResult{}, true, c.server.vtgate.Commit(c.context(), c.session)
+	case is("rollback"), is("rollback", "work"):
+		if !c.session.InTransaction {
+			return &mproto.QueryResult{}, true, nil
+		}
+		return &mproto.QueryResult{}, true, c.server.vtgate.Rollback(c.context(), c.session)
+	case is("use", ""):
+		return &mproto.QueryResult{}, true, nil
+	case is("select", "@@version_comment", "limit", "1"):
+		return &mproto.QueryResult{
+			Fields:       []mproto.Field{{Name: "@@version_comment", Type: mproto.VT_VAR_STRING}},
+			RowsAffected: 1,
+			Rows:         [][]sqltypes.Value{{sqltypes.MakeString([]byte("Vitess"))}},
+		}, true, nil
+	case len(words) > 1 && words[0] == "set":
+		return c.executeSet(words[1:])
+	}
+	return nil, false, nil
+}
+
+// executeSet runs SET NAMES and SET AUTOCOMMIT.
+func (c *conn) executeSet(words []string) (qr *mproto.QueryResult, ok bool, err error) {
+	if words[0] == "@@session" || words[0] == "session" {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return nil, false, nil
+	}
+	switch strings.TrimPrefix(words[0], "@@") {
+	case "names", "character_set_client", "character_set_results":
+		if len(words) < 2 {
+			return nil, false, nil
+		}
+		charset := words[1]
+		if charset == "default" {
+			charset = ""
+		}
+		// the character set is checked by vtgate when it runs the
+		// queries
+		c.session.Charset = charset
+		return &mproto.QueryResult{}, true, nil
+	case "autocommit":
+		if len(words) != 2 {
+			return nil, false, nil
+		}
+		switch words[1] {
+		case "1", "on", "true":
+			if c.session.InTransaction {
+				return &mproto.QueryResult{}, true, c.server.vtgate.Commit(c.context(), c.session)
+			}
+			return &mproto.QueryResult{}, true, nil
+		case "0", "off", "false":
+			return nil, true, fmt.Errorf("autocommit can't be disabled, use BEGIN to start a transaction")
+		}
+	}
+	return nil, false, nil
+}
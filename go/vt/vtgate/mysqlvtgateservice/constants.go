@@ -0,0 +1,140 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlvtgateservice
+
+// The protocol version and the server version of the handshake. Some
+// clients parse the server version, it has to look like MySQL's.
+const (
+	protocolVersion = 10
+	serverVersion   = "5.5.10-Vitess"
+)
+
+// Capability flags.
+const (
+	clientLongPassword         = 1
+	clientFoundRows            = 1 << 1
+	clientLongFlag             = 1 << 2
+	clientConnectWithDB        = 1 << 3
+	clientProtocol41           = 1 << 9
+	clientTransactions         = 1 << 13
+	clientSecureConnection     = 1 << 15
+	clientMultiResults         = 1 << 17
+	clientPluginAuth           = 1 << 19
+	clientConnectAttrs         = 1 << 20
+	clientPluginAuthLenencData = 1 << 21
+)
+
+// serverCapabilities are the capabilities of the server. It has no
+// SSL and no CLIENT_DEPRECATE_EOF.
+const serverCapabilities uint32 = clientLongPassword | clientFoundRows | clientLongFlag | clientConnectWithDB |
+	clientProtocol41 | clientTransactions | clientSecureConnection | clientMultiResults |
+	clientPluginAuth | clientConnectAttrs | clientPluginAuthLenencData
+
+// Status flags.
+const (
+	serverStatusInTrans    = 1
+	serverStatusAutocommit = 2
+)
+
+// Commands.
+const (
+	comQuit        = 0x01
+	comInitDB      = 0x02
+	comQuery       = 0x03
+	comPing        = 0x0e
+	comStmtPrepare = 0x16
+	comStmtExecute = 0x17
+	comStmtClose   = 0x19
+	comStmtReset   = 0x1a
+)
+
+// Packet headers.
+const (
+	okPacket         = 0x00
+	eofPacket        = 0xfe
+	errPacket        = 0xff
+	authSwitchPacket = 0xfe
+)
+
+// Column types that the binary protocol encodes differently from
+// strings. They are the same as the mproto.VT_* values.
+const (
+	typeDecimal    = 0
+	typeTiny       = 1
+	typeShort      = 2
+	typeLong       = 3
+	typeFloat      = 4
+	typeDouble     = 5
+	typeNull       = 6
+	typeTimestamp  = 7
+	typeLongLong   = 8
+	typeInt24      = 9
+	typeDate       = 10
+	typeTime       = 11
+	typeDatetime   = 12
+	typeYear       = 13
+	typeNewDate    = 14
+	typeVarchar    = 15
+	typeBit        = 16
+	typeNewDecimal = 246
+	typeTinyBlob   = 249
+	typeMediumBlob = 250
+	typeLongBlob   = 251
+	typeBlob       = 252
+	typeVarString  = 253
+	typeString     = 254
+)
+
+// Character sets of the column definitions.
+const (
+	charsetUTF8   = 33
+	charsetBinary = 63
+)
+
+// collationCharsets maps the collation ids the clients send in their
+// handshake response to the character set of the session. The
+// collations that are not listed leave the default character set.
+var collationCharsets = map[uint8]string{
+	5:   "latin1",
+	8:   "latin1",
+	11:  "ascii",
+	15:  "latin1",
+	31:  "latin1",
+	33:  "utf8",
+	45:  "utf8mb4",
+	46:  "utf8mb4",
+	47:  "latin1",
+	48:  "latin1",
+	49:  "latin1",
+	63:  "binary",
+	65:  "ascii",
+	83:  "utf8",
+	94:  "latin1",
+	192: "utf8",
+	224: "utf8mb4",
+}
+
+// Column flags.
+const (
+	flagBinary   = 128
+	flagUnsigned = 32
+	flagNum      = 32768
+)
+
+// Error codes and SQL states.
+const (
+	errUnknown          = 1105
+	errAccessDenied     = 1045
+	errTooManyConns     = 1040
+	errUnknownComError  = 1047
+	errUnknownStmt      = 1243
+	errWrongArguments   = 1210
+	errDupEntry         = 1062
+	sqlStateGeneral     = "HY000"
+	sqlStateAccess      = "28000"
+	sqlStateDupEntry    = "23000"
+	sqlStateConnection  = "08004"
+	mysqlNativePassword = "mysql_native_password"
+)
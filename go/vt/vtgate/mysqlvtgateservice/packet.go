@@ -0,0 +1,236 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlvtgateservice
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// maxPacketSize is the largest payload of a packet. The larger ones
+// are split, and a payload of exactly that size is followed by an
+// empty packet.
+const maxPacketSize = 1<<24 - 1
+
+// maxHandshakeSize is the largest payload read before the client is
+// authenticated. The handshake response is much smaller.
+const maxHandshakeSize = 1 << 16
+
+// packetConn reads and writes the packets of a connection, and keeps
+// track of their sequence ids. The payloads it reads, once joined,
+// can't be larger than maxSize.
+type packetConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+	seq     uint8
+	maxSize int
+}
+
+func newPacketConn(conn net.Conn, maxSize int) *packetConn {
+	return &packetConn{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		writer:  bufio.NewWriter(conn),
+		maxSize: maxSize,
+	}
+}
+
+// readPacket returns the next payload, joining the split packets.
+func (pc *packetConn) readPacket() ([]byte, error) {
+	var payload []byte
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(pc.reader, header[:]); err != nil {
+			return nil, err
+		}
+		length := int(uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16)
+		if header[3] != pc.seq {
+			return nil, fmt.Errorf("packet out of order: got sequence id %v, want %v", header[3], pc.seq)
+		}
+		if len(payload)+length > pc.maxSize {
+			return nil, fmt.Errorf("packet is larger than %v bytes", pc.maxSize)
+		}
+		pc.seq++
+		data := make([]byte, length)
+		if _, err := io.ReadFull(pc.reader, data); err != nil {
+			return nil, err
+		}
+		if payload == nil {
+			payload = data
+		} else {
+			payload = append(payload, data...)
+		}
+		if length < maxPacketSize {
+			return payload, nil
+		}
+	}
+}
+
+// writePacket buffers a payload, splitting it if needed. flush sends
+// the buffered packets.
+func (pc *packetConn) writePacket(payload []byte) error {
+	for {
+		length := len(payload)
+		if length > maxPacketSize {
+			length = maxPacketSize
+		}
+		header := [4]byte{byte(length), byte(length >> 8), byte(length >> 16), pc.seq}
+		pc.seq++
+		if _, err := pc.writer.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err := pc.writer.Write(payload[:length]); err != nil {
+			return err
+		}
+		payload = payload[length:]
+		if length < maxPacketSize {
+			return nil
+		}
+	}
+}
+
+func (pc *packetConn) flush() error {
+	return pc.writer.Flush()
+}
+
+// The following functions append the protocol types to a payload.
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendLenEncInt(b []byte, v uint64) []byte {
+	switch {
+	case v < 251:
+		return append(b, byte(v))
+	case v < 1<<16:
+		return appendUint16(append(b, 0xfc), uint16(v))
+	case v < 1<<24:
+		return append(b, 0xfd, byte(v), byte(v>>8), byte(v>>16))
+	}
+	return appendUint64(append(b, 0xfe), v)
+}
+
+func appendLenEncString(b []byte, s []byte) []byte {
+	return append(appendLenEncInt(b, uint64(len(s))), s...)
+}
+
+func appendNullString(b []byte, s string) []byte {
+	return append(append(b, s...), 0)
+}
+
+// payloadReader reads the protocol types from a payload. The first
+// error is kept, and the following reads return zero values.
+type payloadReader struct {
+	data []byte
+	err  error
+}
+
+func (pr *payloadReader) fail() {
+	if pr.err == nil {
+		pr.err = fmt.Errorf("malformed packet")
+	}
+	pr.data = nil
+}
+
+func (pr *payloadReader) bytes(n int) []byte {
+	if n < 0 || len(pr.data) < n {
+		pr.fail()
+		return nil
+	}
+	b := pr.data[:n]
+	pr.data = pr.data[n:]
+	return b
+}
+
+func (pr *payloadReader) uint8() uint8 {
+	b := pr.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (pr *payloadReader) uint16() uint16 {
+	b := pr.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (pr *payloadReader) uint32() uint32 {
+	b := pr.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (pr *payloadReader) uint64() uint64 {
+	b := pr.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (pr *payloadReader) lenEncInt() uint64 {
+	switch first := pr.uint8(); first {
+	case 0xfc:
+		return uint64(pr.uint16())
+	case 0xfd:
+		b := pr.bytes(3)
+		if b == nil {
+			return 0
+		}
+		return uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16
+	case 0xfe:
+		return pr.uint64()
+	default:
+		return uint64(first)
+	}
+}
+
+func (pr *payloadReader) lenEncString() []byte {
+	n := pr.lenEncInt()
+	if n > uint64(len(pr.data)) {
+		pr.fail()
+		return nil
+	}
+	return pr.bytes(int(n))
+}
+
+func (pr *payloadReader) nullString() string {
+	for i, c := range pr.data {
+		if c == 0 {
+			s := string(pr.data[:i])
+			pr.data = pr.data[i+1:]
+			return s
+		}
+	}
+	pr.fail()
+	return ""
+}
+
+func (pr *payloadReader) rest() []byte {
+	b := pr.data
+	pr.data = nil
+	return b
+}
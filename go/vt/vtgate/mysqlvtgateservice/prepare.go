@@ -0,0 +1,273 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlvtgateservice
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+)
+
+// statement is a prepared statement. Vtgate has no prepared
+// statements: its placeholders are rewritten to bind variables, and
+// it runs as a query with their values.
+type statement struct {
+	sql        string
+	paramCount int
+	// paramTypes are the types of the last execution, the clients
+	// only send them when they change.
+	paramTypes []uint16
+}
+
+// paramField is the column definition of the parameters.
+var paramField = mproto.Field{Name: "?", Type: mproto.VT_VAR_STRING}
+
+// rewritePlaceholders replaces the '?' placeholders of sql that are
+// not in a string, a quoted identifier or a comment with the bind
+// variables :v1, :v2... It returns the number of placeholders.
+func rewritePlaceholders(sql string) (string, int) {
+	var buf bytes.Buffer
+	count := 0
+	for i := 0; i < len(sql); i++ {
+		ch := sql[i]
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			end := i + 1
+			for ; end < len(sql) && sql[end] != ch; end++ {
+				if sql[end] == '\\' && ch != '`' {
+					end++
+				}
+			}
+			if end >= len(sql) {
+				end = len(sql) - 1
+			}
+			buf.WriteString(sql[i : end+1])
+			i = end
+		case ch == '#' || (ch == '-' && i+2 < len(sql) && sql[i+1] == '-' && sql[i+2] == ' '):
+			end := i
+			for ; end < len(sql) && sql[end] != '\n'; end++ {
+			}
+			if end == len(sql) {
+				end--
+			}
+			buf.WriteString(sql[i : end+1])
+			i = end
+		case ch == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := i + 2
+			for ; end+1 < len(sql) && !(sql[end] == '*' && sql[end+1] == '/'); end++ {
+			}
+			if end+1 >= len(sql) {
+				end = len(sql) - 2
+			}
+			buf.WriteString(sql[i : end+2])
+			i = end + 1
+		case ch == '?':
+			count++
+			fmt.Fprintf(&buf, ":v%d", count)
+		default:
+			buf.WriteByte(ch)
+		}
+	}
+	return buf.String(), count
+}
+
+// prepare answers COM_STMT_PREPARE. The columns of the result are only
+// known when the statement runs, so none are announced.
+func (c *conn) prepare(sql string) error {
+	c.nextStatementID++
+	stmt := &statement{}
+	stmt.sql, stmt.paramCount = rewritePlaceholders(sql)
+	c.statements[c.nextStatementID] = stmt
+
+	b := []byte{okPacket}
+	b = appendUint32(b, c.nextStatementID)
+	b = appendUint16(b, 0) // columns
+	b = appendUint16(b, uint16(stmt.paramCount))
+	b = append(b, 0)
+	b = appendUint16(b, 0) // warnings
+	if err := c.writePacket(b); err != nil {
+		return err
+	}
+	if stmt.paramCount == 0 {
+		return nil
+	}
+	for i := 0; i < stmt.paramCount; i++ {
+		if err := c.writePacket(columnDefinition(paramField)); err != nil {
+			return err
+		}
+	}
+	return c.writeEOF()
+}
+
+// executeStatement answers COM_STMT_EXECUTE with a binary result set.
+func (c *conn) executeStatement(data []byte) error {
+	pr := &payloadReader{data: data}
+	id := pr.uint32()
+	stmt, ok := c.statements[id]
+	if !ok {
+		return c.writeError(errUnknownStmt, sqlStateGeneral, "unknown prepared statement %v", id)
+	}
+	bindVars, err := stmt.readParams(pr)
+	if err != nil {
+		return c.writeError(errWrongArguments, sqlStateGeneral, "%v", err)
+	}
+	qr, err := c.execute(stmt.sql, bindVars)
+	if err != nil {
+		return c.writeExecuteError(err)
+	}
+	return c.writeResult(qr, true)
+}
+
+func (c *conn) closeStatement(data []byte) {
+	pr := &payloadReader{data: data}
+	delete(c.statements, pr.uint32())
+}
+
+// resetStatement answers COM_STMT_RESET. The long data isn't supported,
+// so there is nothing to reset.
+func (c *conn) resetStatement(data []byte) error {
+	pr := &payloadReader{data: data}
+	if _, ok := c.statements[pr.uint32()]; !ok {
+		return c.writeError(errUnknownStmt, sqlStateGeneral, "unknown prepared statement")
+	}
+	return c.writeOK(0, 0)
+}
+
+// readParams decodes the parameters of COM_STMT_EXECUTE, after the
+// statement id.
+func (stmt *statement) readParams(pr *payloadReader) (map[string]interface{}, error) {
+	pr.uint8()  // flags
+	pr.uint32() // iteration count
+	if stmt.paramCount == 0 {
+		return nil, pr.err
+	}
+	nullBitmap := pr.bytes((stmt.paramCount + 7) / 8)
+	if pr.uint8() == 1 {
+		stmt.paramTypes = make([]uint16, stmt.paramCount)
+		for i := range stmt.paramTypes {
+			stmt.paramTypes[i] = pr.uint16()
+		}
+	}
+	if pr.err != nil {
+		return nil, pr.err
+	}
+	if stmt.paramTypes == nil {
+		return nil, fmt.Errorf("the parameter types are missing")
+	}
+	bindVars := make(map[string]interface{}, stmt.paramCount)
+	for i := 0; i < stmt.paramCount; i++ {
+		name := fmt.Sprintf("v%d", i+1)
+		if nullBitmap[i/8]&(1<<uint(i%8)) != 0 {
+			bindVars[name] = nil
+			continue
+		}
+		value, err := readBinaryValue(pr, stmt.paramTypes[i])
+		if err != nil {
+			return nil, fmt.Errorf("parameter %v: %v", i+1, err)
+		}
+		bindVars[name] = value
+	}
+	return bindVars, pr.err
+}
+
+// readBinaryValue decodes a parameter. Its type has the unsigned flag
+// in its high bit.
+func readBinaryValue(pr *payloadReader, paramType uint16) (interface{}, error) {
+	unsigned := paramType&0x8000 != 0
+	switch paramType & 0xff {
+	case typeNull:
+		return nil, nil
+	case typeTiny:
+		v := pr.uint8()
+		if unsigned {
+			return uint64(v), pr.err
+		}
+		return int64(int8(v)), pr.err
+	case typeShort, typeYear:
+		v := pr.uint16()
+		if unsigned {
+			return uint64(v), pr.err
+		}
+		return int64(int16(v)), pr.err
+	case typeLong, typeInt24:
+		v := pr.uint32()
+		if unsigned {
+			return uint64(v), pr.err
+		}
+		return int64(int32(v)), pr.err
+	case typeLongLong:
+		v := pr.uint64()
+		if unsigned {
+			return v, pr.err
+		}
+		return int64(v), pr.err
+	case typeFloat:
+		return float64(math.Float32frombits(pr.uint32())), pr.err
+	case typeDouble:
+		return math.Float64frombits(pr.uint64()), pr.err
+	case typeDate, typeDatetime, typeTimestamp, typeNewDate:
+		return readBinaryDatetime(pr)
+	case typeTime:
+		return readBinaryTime(pr)
+	case typeTinyBlob, typeMediumBlob, typeLongBlob, typeBlob:
+		return pr.lenEncString(), pr.err
+	case typeDecimal, typeNewDecimal, typeVarchar, typeBit, typeVarString, typeString:
+		return string(pr.lenEncString()), pr.err
+	}
+	return nil, fmt.Errorf("unsupported type %v", paramType&0xff)
+}
+
+// readBinaryDatetime decodes the 0, 4, 7 and 11 bytes forms of the
+// dates.
+func readBinaryDatetime(pr *payloadReader) (interface{}, error) {
+	length := pr.uint8()
+	var year uint16
+	var month, day, hour, minute, second uint8
+	var micro uint32
+	if length >= 4 {
+		year, month, day = pr.uint16(), pr.uint8(), pr.uint8()
+	}
+	if length >= 7 {
+		hour, minute, second = pr.uint8(), pr.uint8(), pr.uint8()
+	}
+	if length >= 11 {
+		micro = pr.uint32()
+	}
+	if pr.err != nil {
+		return nil, pr.err
+	}
+	s := fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", year, month, day, hour, minute, second)
+	if micro != 0 {
+		s += fmt.Sprintf(".%06d", micro)
+	}
+	return s, nil
+}
+
+// readBinaryTime decodes the 0, 8 and 12 bytes forms of the times.
+func readBinaryTime(pr *payloadReader) (interface{}, error) {
+	length := pr.uint8()
+	var negative, hour, minute, second uint8
+	var days, micro uint32
+	if length >= 8 {
+		negative, days = pr.uint8(), pr.uint32()
+		hour, minute, second = pr.uint8(), pr.uint8(), pr.uint8()
+	}
+	if length >= 12 {
+		micro = pr.uint32()
+	}
+	if pr.err != nil {
+		return nil, pr.err
+	}
+	s := fmt.Sprintf("%02d:%02d:%02d", days*24+uint32(hour), minute, second)
+	if micro != 0 {
+		s += fmt.Sprintf(".%06d", micro)
+	}
+	if negative == 1 {
+		s = "-" + s
+	}
+	return s, nil
+}
@@ -0,0 +1,297 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlvtgateservice
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+)
+
+// statusFlags returns the status flags of the OK and EOF packets.
+func (c *conn) statusFlags() uint16 {
+	if c.session.InTransaction {
+		return serverStatusInTrans
+	}
+	return serverStatusAutocommit
+}
+
+func (c *conn) writeOK(affectedRows, lastInsertID uint64) error {
+	b := []byte{okPacket}
+	b = appendLenEncInt(b, affectedRows)
+	b = appendLenEncInt(b, lastInsertID)
+	b = appendUint16(b, c.statusFlags())
+	b = appendUint16(b, 0)
+	return c.writePacket(b)
+}
+
+func (c *conn) writeEOF() error {
+	b := []byte{eofPacket}
+	b = appendUint16(b, 0)
+	b = appendUint16(b, c.statusFlags())
+	return c.writePacket(b)
+}
+
+func (c *conn) writeError(code uint16, sqlState string, format string, args ...interface{}) error {
+	b := []byte{errPacket}
+	b = appendUint16(b, code)
+	b = append(b, '#')
+	b = append(b, sqlState...)
+	b = append(b, fmt.Sprintf(format, args...)...)
+	return c.writePacket(b)
+}
+
+// fieldAttributes returns the character set, the flags, the length and
+// the decimals of the column definition of a field.
+func fieldAttributes(field mproto.Field) (charset uint16, flags uint16, length uint32, decimals byte) {
+	switch field.Type {
+	case typeTiny, typeShort, typeLong, typeInt24, typeLongLong, typeYear:
+		return charsetBinary, flagBinary | flagNum, 20, 0
+	case typeFloat, typeDouble:
+		return charsetBinary, flagBinary | flagNum, 22, 0x1f
+	case typeDecimal, typeNewDecimal:
+		return charsetBinary, flagBinary | flagNum, 65, 30
+	case typeTimestamp, typeDate, typeTime, typeDatetime, typeNewDate, typeBit, typeNull:
+		return charsetBinary, flagBinary, 26, 0
+	case typeTinyBlob, typeMediumBlob, typeLongBlob, typeBlob:
+		// TEXT columns are BLOBs without the binary flag
+		if field.Flags&mproto.VT_BINARY_FLAG != 0 {
+			return charsetBinary, flagBinary, math.MaxUint32, 0
+		}
+		return charsetUTF8, 0, math.MaxUint32, 0
+	}
+	if field.Flags&mproto.VT_BINARY_FLAG != 0 {
+		return charsetBinary, flagBinary, 65535, 0
+	}
+	return charsetUTF8, 0, 65535, 0
+}
+
+func columnDefinition(field mproto.Field) []byte {
+	charset, flags, length, decimals := fieldAttributes(field)
+	b := appendLenEncString(nil, []byte("def"))
+	b = appendLenEncString(b, nil) // schema
+	b = appendLenEncString(b, nil) // table
+	b = appendLenEncString(b, nil) // org_table
+	b = appendLenEncString(b, []byte(field.Name))
+	b = appendLenEncString(b, []byte(field.Name)) // org_name
+	b = append(b, 0x0c)
+	b = appendUint16(b, charset)
+	b = appendUint32(b, length)
+	b = append(b, byte(field.Type))
+	b = appendUint16(b, flags)
+	b = append(b, decimals)
+	return appendUint16(b, 0)
+}
+
+// writeResult sends a result, as an OK packet if it has no fields, or
+// else as a result set, in the binary protocol for the prepared
+// statements.
+func (c *conn) writeResult(qr *mproto.QueryResult, binary bool) error {
+	if len(qr.Fields) == 0 {
+		return c.writeOK(qr.RowsAffected, qr.InsertId)
+	}
+	if err := c.writePacket(appendLenEncInt(nil, uint64(len(qr.Fields)))); err != nil {
+		return err
+	}
+	for _, field := range qr.Fields {
+		if err := c.writePacket(columnDefinition(field)); err != nil {
+			return err
+		}
+	}
+	if err := c.writeEOF(); err != nil {
+		return err
+	}
+	for _, row := range qr.Rows {
+		var b []byte
+		if binary {
+			var err error
+			if b, err = binaryRow(qr.Fields, row); err != nil {
+				return c.writeError(errUnknown, sqlStateGeneral, "%v", err)
+			}
+		} else {
+			b = textRow(row)
+		}
+		if err := c.writePacket(b); err != nil {
+			return err
+		}
+	}
+	return c.writeEOF()
+}
+
+func textRow(row []sqltypes.Value) []byte {
+	var b []byte
+	for _, value := range row {
+		if value.IsNull() {
+			b = append(b, 0xfb)
+			continue
+		}
+		b = appendLenEncString(b, value.Raw())
+	}
+	return b
+}
+
+// binaryRow encodes a row in the binary protocol. The NULL values are
+// in a bitmap that starts at the third bit.
+func binaryRow(fields []mproto.Field, row []sqltypes.Value) ([]byte, error) {
+	nullBitmap := make([]byte, (len(row)+7+2)/8)
+	b := []byte{okPacket}
+	var values []byte
+	for i, value := range row {
+		if value.IsNull() {
+			nullBitmap[(i+2)/8] |= 1 << (uint(i+2) % 8)
+			continue
+		}
+		var err error
+		if values, err = appendBinaryValue(values, fields[i].Type, value.String()); err != nil {
+			return nil, fmt.Errorf("cannot encode column %v: %v", fields[i].Name, err)
+		}
+	}
+	b = append(b, nullBitmap...)
+	return append(b, values...), nil
+}
+
+// parseInteger parses the signed and unsigned integers, keeping the
+// bits of the unsigned ones.
+func parseInteger(s string) (uint64, error) {
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return uint64(v), nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func appendBinaryValue(b []byte, typ int64, s string) ([]byte, error) {
+	switch typ {
+	case typeTiny:
+		v, err := parseInteger(s)
+		return append(b, byte(v)), err
+	case typeShort, typeYear:
+		v, err := parseInteger(s)
+		return appendUint16(b, uint16(v)), err
+	case typeLong, typeInt24:
+		v, err := parseInteger(s)
+		return appendUint32(b, uint32(v)), err
+	case typeLongLong:
+		v, err := parseInteger(s)
+		return appendUint64(b, v), err
+	case typeFloat:
+		v, err := strconv.ParseFloat(s, 32)
+		return appendUint32(b, math.Float32bits(float32(v))), err
+	case typeDouble:
+		v, err := strconv.ParseFloat(s, 64)
+		return appendUint64(b, math.Float64bits(v)), err
+	case typeDate, typeDatetime, typeTimestamp, typeNewDate:
+		return appendBinaryDatetime(b, s)
+	case typeTime:
+		return appendBinaryTime(b, s)
+	}
+	return appendLenEncString(b, []byte(s)), nil
+}
+
+// splitFraction splits "12:34:56.789" into "12:34:56" and 789000.
+func splitFraction(s string) (string, uint32, error) {
+	i := strings.IndexByte(s, '.')
+	if i == -1 {
+		return s, 0, nil
+	}
+	fraction := (s[i+1:] + "000000")[:6]
+	micro, err := strconv.ParseUint(fraction, 10, 32)
+	return s[:i], uint32(micro), err
+}
+
+// splitNumbers parses the numbers of s separated by sep.
+func splitNumbers(s, sep string, count int) ([]uint64, error) {
+	parts := strings.Split(s, sep)
+	if len(parts) != count {
+		return nil, fmt.Errorf("invalid value %q", s)
+	}
+	numbers := make([]uint64, count)
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q", s)
+		}
+		numbers[i] = n
+	}
+	return numbers, nil
+}
+
+// appendBinaryDatetime encodes "YYYY-MM-DD[ HH:MM:SS[.ffffff]]" with
+// the shortest of the 0, 4, 7 and 11 bytes forms.
+func appendBinaryDatetime(b []byte, s string) ([]byte, error) {
+	datePart, timePart := s, "00:00:00"
+	if i := strings.IndexByte(s, ' '); i != -1 {
+		datePart, timePart = s[:i], s[i+1:]
+	}
+	date, err := splitNumbers(datePart, "-", 3)
+	if err != nil {
+		return nil, err
+	}
+	timePart, micro, err := splitFraction(timePart)
+	if err != nil {
+		return nil, err
+	}
+	clock, err := splitNumbers(timePart, ":", 3)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case micro != 0:
+		b = append(b, 11)
+	case clock[0] != 0 || clock[1] != 0 || clock[2] != 0:
+		b = append(b, 7)
+	case date[0] != 0 || date[1] != 0 || date[2] != 0:
+		b = append(b, 4)
+	default:
+		return append(b, 0), nil
+	}
+	length := b[len(b)-1]
+	b = appendUint16(b, uint16(date[0]))
+	b = append(b, byte(date[1]), byte(date[2]))
+	if length >= 7 {
+		b = append(b, byte(clock[0]), byte(clock[1]), byte(clock[2]))
+	}
+	if length == 11 {
+		b = appendUint32(b, micro)
+	}
+	return b, nil
+}
+
+// appendBinaryTime encodes "[-]HHH:MM:SS[.ffffff]" with the shortest
+// of the 0, 8 and 12 bytes forms.
+func appendBinaryTime(b []byte, s string) ([]byte, error) {
+	var negative byte
+	if strings.HasPrefix(s, "-") {
+		negative = 1
+		s = s[1:]
+	}
+	s, micro, err := splitFraction(s)
+	if err != nil {
+		return nil, err
+	}
+	clock, err := splitNumbers(s, ":", 3)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case micro != 0:
+		b = append(b, 12)
+	case clock[0] != 0 || clock[1] != 0 || clock[2] != 0:
+		b = append(b, 8)
+	default:
+		return append(b, 0), nil
+	}
+	length := b[len(b)-1]
+	b = append(b, negative)
+	b = appendUint32(b, uint32(clock[0]/24))
+	b = append(b, byte(clock[0]%24), byte(clock[1]), byte(clock[2]))
+	if length == 12 {
+		b = appendUint32(b, micro)
+	}
+	return b, nil
+}
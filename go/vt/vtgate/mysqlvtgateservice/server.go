@@ -0,0 +1,146 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mysqlvtgateservice serves the MySQL client/server protocol
+// in vtgate, so that the MySQL clients and connectors can query it
+// directly. The queries run through the vtgate execution API with the
+// V3 routing, in a vtgate session per connection.
+package mysqlvtgateservice
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/sync2"
+	"github.com/youtube/vitess/go/vt/servenv"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vtgate"
+	"github.com/youtube/vitess/go/vt/vtgate/vtgateservice"
+)
+
+var (
+	port          = flag.Int("mysql_server_port", 0, "port of the MySQL protocol listener of vtgate, it is disabled if 0")
+	usersFile     = flag.String("mysql_server_users_file", "", "JSON file mapping the user names of the MySQL protocol clients to their password, required with -mysql_server_port")
+	tabletType    = flag.String("mysql_server_tablet_type", string(topo.TYPE_MASTER), "tablet type of the queries of the MySQL protocol clients")
+	maxPacketFlag = flag.Int("mysql_server_max_packet_size", 1<<26, "largest command the MySQL protocol clients can send, in bytes")
+	maxConnsFlag  = flag.Int("mysql_server_max_connections", 1000, "maximum number of MySQL protocol connections, the others are refused. 0 means no limit")
+	handshakeFlag = flag.Duration("mysql_server_handshake_timeout", 10*time.Second, "time the MySQL protocol clients have to authenticate")
+
+	connCount   = stats.NewInt("MysqlServerConnections")
+	connAccept  = stats.NewInt("MysqlServerConnectionsAccepted")
+	connRefused = stats.NewInt("MysqlServerConnectionsRefused")
+)
+
+// Server accepts the MySQL protocol connections and runs their queries
+// on a VTGateService.
+type Server struct {
+	vtgate     vtgateservice.VTGateService
+	auth       *authenticator
+	tabletType topo.TabletType
+	connID     sync2.AtomicUint32
+	// maxPacketSize is the largest payload the authenticated
+	// clients can send.
+	maxPacketSize int
+	// maxConns is the maximum number of connections, 0 if there
+	// is no limit. conns is the current number.
+	maxConns int64
+	conns    sync2.AtomicInt64
+	// handshakeTimeout is the time the clients have to
+	// authenticate.
+	handshakeTimeout time.Duration
+}
+
+// NewServer returns a Server running the queries of usersFile's users
+// on tabletType tablets.
+func NewServer(vtGate vtgateservice.VTGateService, usersFile string, tabletType topo.TabletType) (*Server, error) {
+	if !topo.IsInServingGraph(tabletType) {
+		return nil, fmt.Errorf("invalid tablet type %v", tabletType)
+	}
+	auth, err := loadAuthenticator(usersFile)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		vtgate:           vtGate,
+		auth:             auth,
+		tabletType:       tabletType,
+		maxPacketSize:    *maxPacketFlag,
+		maxConns:         int64(*maxConnsFlag),
+		handshakeTimeout: *handshakeFlag,
+	}, nil
+}
+
+// Serve accepts the connections of l until it is closed. Like
+// net/http, it retries the temporary errors, such as running out of
+// file descriptors, after a delay.
+func (s *Server) Serve(l net.Listener) error {
+	var delay time.Duration
+	for {
+		netConn, err := l.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else {
+					delay *= 2
+				}
+				if max := 1 * time.Second; delay > max {
+					delay = max
+				}
+				log.Warningf("mysql server: accept error: %v; retrying in %v", err, delay)
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+		connAccept.Add(1)
+		c := newConn(s, netConn, s.connID.Add(1))
+		if s.maxConns > 0 && s.conns.Add(1) > s.maxConns {
+			s.conns.Add(-1)
+			connRefused.Add(1)
+			go c.refuse()
+			continue
+		}
+		if s.maxConns <= 0 {
+			s.conns.Add(1)
+		}
+		go func() {
+			connCount.Add(1)
+			defer connCount.Add(-1)
+			defer s.conns.Add(-1)
+			c.serve()
+		}()
+	}
+}
+
+func init() {
+	vtgate.RegisterVTGates = append(vtgate.RegisterVTGates, func(vtGate vtgateservice.VTGateService) {
+		if *port == 0 {
+			return
+		}
+		server, err := NewServer(vtGate, *usersFile, topo.TabletType(*tabletType))
+		if err != nil {
+			log.Fatalf("cannot start the MySQL protocol server: %v", err)
+		}
+		l, err := net.Listen("tcp", fmt.Sprintf(":%v", *port))
+		if err != nil {
+			log.Fatalf("cannot listen on the MySQL protocol port: %v", err)
+		}
+		var closing sync2.AtomicInt32
+		go func() {
+			if err := server.Serve(l); closing.Get() == 0 {
+				log.Fatalf("the MySQL protocol server stopped: %v", err)
+			}
+		}()
+		servenv.OnClose(func() {
+			closing.Set(1)
+			l.Close()
+		})
+	})
+}
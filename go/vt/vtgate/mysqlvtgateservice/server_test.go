@@ -0,0 +1,603 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mysqlvtgateservice
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vtgate/proto"
+	"github.com/youtube/vitess/go/vt/vtgate/vtgateservice"
+	"golang.org/x/net/context"
+)
+
+// fakeVTGate records the queries, and returns the same result to all
+// of them.
+type fakeVTGate struct {
+	vtgateservice.VTGateService
+	queries   []proto.Query
+	result    *mproto.QueryResult
+	err       error
+	commits   int
+	rollbacks int
+}
+
+func (f *fakeVTGate) Execute(ctx context.Context, query *proto.Query, reply *proto.QueryResult) error {
+	q := *query
+	session := *query.Session
+	q.Session = &session
+	f.queries = append(f.queries, q)
+	if f.err != nil {
+		reply.Error = f.err.Error()
+		return nil
+	}
+	reply.Result = f.result
+	reply.Session = query.Session
+	return nil
+}
+
+func (f *fakeVTGate) Begin(ctx context.Context, outSession *proto.Session) error {
+	outSession.InTransaction = true
+	return nil
+}
+
+func (f *fakeVTGate) Commit(ctx context.Context, inSession *proto.Session) error {
+	f.commits++
+	*inSession = proto.Session{Charset: inSession.Charset}
+	return nil
+}
+
+func (f *fakeVTGate) Rollback(ctx context.Context, inSession *proto.Session) error {
+	f.rollbacks++
+	*inSession = proto.Session{Charset: inSession.Charset}
+	return nil
+}
+
+func (f *fakeVTGate) HandlePanic(err *error) {
+	if x := recover(); x != nil {
+		*err = fmt.Errorf("uncaught panic: %v", x)
+	}
+}
+
+// testClient is a minimal client of the protocol.
+type testClient struct {
+	*packetConn
+	t *testing.T
+}
+
+// connect runs the handshake of a new connection to server, and
+// returns the client and the last packet of the handshake.
+func connect(t *testing.T, server *Server, user, password, plugin string) (*testClient, []byte) {
+	clientConn, serverConn := net.Pipe()
+	go newConn(server, serverConn, 1).serve()
+	client := &testClient{packetConn: newPacketConn(clientConn, 1<<30), t: t}
+
+	pr := &payloadReader{data: client.read()}
+	if version := pr.uint8(); version != protocolVersion {
+		t.Fatalf("protocol version = %v, want %v", version, protocolVersion)
+	}
+	pr.nullString() // server version
+	pr.uint32()     // connection id
+	scramble := append([]byte{}, pr.bytes(8)...)
+	pr.bytes(1 + 2 + 1 + 2 + 2 + 1 + 10)
+	scramble = append(scramble, pr.bytes(12)...)
+	pr.uint8()
+	if got := pr.nullString(); got != mysqlNativePassword || pr.err != nil {
+		t.Fatalf("auth plugin = %q, %v, want %v", got, pr.err, mysqlNativePassword)
+	}
+
+	response := scramblePassword(scramble, password)
+	if plugin != mysqlNativePassword {
+		response = []byte("other response")
+	}
+	b := appendUint32(nil, clientProtocol41|clientSecureConnection|clientPluginAuth|clientPluginAuthLenencData)
+	b = appendUint32(b, maxPacketSize)
+	b = append(b, charsetUTF8)
+	b = append(b, make([]byte, 23)...)
+	b = appendNullString(b, user)
+	b = appendLenEncString(b, response)
+	b = appendNullString(b, plugin)
+	client.write(b)
+
+	data := client.read()
+	if data[0] == authSwitchPacket {
+		pr := &payloadReader{data: data[1:]}
+		if got := pr.nullString(); got != mysqlNativePassword {
+			t.Fatalf("auth switch plugin = %q, want %v", got, mysqlNativePassword)
+		}
+		client.write(scramblePassword(pr.bytes(scrambleLength), password))
+		data = client.read()
+	}
+	return client, data
+}
+
+func (client *testClient) read() []byte {
+	data, err := client.readPacket()
+	if err != nil {
+		client.t.Fatalf("readPacket failed: %v", err)
+	}
+	return data
+}
+
+func (client *testClient) write(payload []byte) {
+	if err := client.writePacket(payload); err != nil {
+		client.t.Fatalf("writePacket failed: %v", err)
+	}
+	if err := client.flush(); err != nil {
+		client.t.Fatalf("flush failed: %v", err)
+	}
+}
+
+// command sends a command and returns the first packet of its
+// response.
+func (client *testClient) command(command byte, data []byte) []byte {
+	client.seq = 0
+	client.write(append([]byte{command}, data...))
+	return client.read()
+}
+
+// query runs sql, and returns its text rows, with "NULL" for the NULL
+// values.
+func (client *testClient) query(sql string) [][]string {
+	data := client.command(comQuery, []byte(sql))
+	return client.readRows(data, false)
+}
+
+// readRows reads the result set starting with data. It fails the test
+// if data is an error.
+func (client *testClient) readRows(data []byte, binary bool) [][]string {
+	switch data[0] {
+	case okPacket:
+		return nil
+	case errPacket:
+		client.t.Fatalf("query failed: %q", data)
+	}
+	pr := &payloadReader{data: data}
+	columns := int(pr.lenEncInt())
+	types := make([]int64, columns)
+	for i := range types {
+		pr := &payloadReader{data: client.read()}
+		pr.lenEncString()
+		pr.lenEncString()
+		pr.lenEncString()
+		pr.lenEncString()
+		pr.lenEncString()
+		pr.lenEncString()
+		pr.bytes(1 + 2 + 4)
+		types[i] = int64(pr.uint8())
+	}
+	if data := client.read(); data[0] != eofPacket {
+		client.t.Fatalf("want EOF after the columns, got %q", data)
+	}
+	var rows [][]string
+	for {
+		data := client.read()
+		if data[0] == eofPacket && len(data) < 9 {
+			return rows
+		}
+		pr := &payloadReader{data: data}
+		var nullBitmap []byte
+		if binary {
+			pr.uint8()
+			nullBitmap = pr.bytes((columns + 7 + 2) / 8)
+		}
+		row := make([]string, columns)
+		for i := range row {
+			switch {
+			case binary && nullBitmap[(i+2)/8]&(1<<(uint(i+2)%8)) != 0:
+				row[i] = "NULL"
+			case binary:
+				value, err := readBinaryValue(pr, uint16(types[i]))
+				if err != nil {
+					client.t.Fatalf("readBinaryValue failed: %v", err)
+				}
+				row[i] = fmt.Sprintf("%v", value)
+			case len(pr.data) > 0 && pr.data[0] == 0xfb:
+				pr.uint8()
+				row[i] = "NULL"
+			default:
+				row[i] = string(pr.lenEncString())
+			}
+		}
+		if pr.err != nil {
+			client.t.Fatalf("cannot read row %q: %v", data, pr.err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// testUsers has a user without a password.
+const testUsers = `{"user1": ""}`
+
+func newTestServer(t *testing.T, fake *fakeVTGate, users string) *Server {
+	f, err := ioutil.TempFile("", "mysql_server_users")
+	if err != nil {
+		t.Fatalf("TempFile failed: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.WriteString(users); err != nil {
+		t.Fatalf("WriteString failed: %v", err)
+	}
+	server, err := NewServer(fake, f.Name(), topo.TYPE_REPLICA)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	return server
+}
+
+func TestAuthentication(t *testing.T) {
+	server := newTestServer(t, &fakeVTGate{}, `{"user1": "password1"}`)
+
+	tcases := []struct {
+		user, password, plugin string
+		header                 byte
+	}{
+		{"user1", "password1", mysqlNativePassword, okPacket},
+		{"user1", "password1", "mysql_clear_password", okPacket},
+		{"user1", "wrong", mysqlNativePassword, errPacket},
+		{"user1", "", mysqlNativePassword, errPacket},
+		{"user2", "password1", mysqlNativePassword, errPacket},
+	}
+	for _, tcase := range tcases {
+		client, data := connect(t, server, tcase.user, tcase.password, tcase.plugin)
+		if data[0] != tcase.header {
+			t.Errorf("%v/%v/%v: got %q, want header %v", tcase.user, tcase.password, tcase.plugin, data, tcase.header)
+		}
+		if data[0] == errPacket {
+			pr := &payloadReader{data: data[1:]}
+			if code := pr.uint16(); code != errAccessDenied {
+				t.Errorf("error code = %v, want %v", code, errAccessDenied)
+			}
+		}
+		client.conn.Close()
+	}
+
+	if _, err := NewServer(&fakeVTGate{}, "", topo.TYPE_IDLE); err == nil {
+		t.Errorf("NewServer accepted a tablet type out of the serving graph")
+	}
+	if _, err := NewServer(&fakeVTGate{}, "", topo.TYPE_REPLICA); err == nil {
+		t.Errorf("NewServer accepted no users file")
+	}
+}
+
+func TestPacketSize(t *testing.T) {
+	server := newTestServer(t, &fakeVTGate{}, testUsers)
+
+	// before the authentication, the packets are small
+	clientConn, serverConn := net.Pipe()
+	go newConn(server, serverConn, 1).serve()
+	client := &testClient{packetConn: newPacketConn(clientConn, 1<<30), t: t}
+	client.read()
+	client.seq = 1
+	client.writePacket(make([]byte, maxPacketSize))
+	client.flush()
+	if data, err := client.readPacket(); err == nil {
+		t.Errorf("large handshake response: got %q, want the connection closed", data)
+	}
+	clientConn.Close()
+
+	// after, they can be as large as maxPacketSize
+	server.maxPacketSize = 1 << 20
+	client, _ = connect(t, server, "user1", "", mysqlNativePassword)
+	defer client.conn.Close()
+	if data := client.command(comPing, make([]byte, maxHandshakeSize)); data[0] != okPacket {
+		t.Errorf("ping: got %q", data)
+	}
+	client.seq = 0
+	client.writePacket(make([]byte, server.maxPacketSize+1))
+	client.flush()
+	if data, err := client.readPacket(); err == nil {
+		t.Errorf("large command: got %q, want the connection closed", data)
+	}
+}
+
+// temporaryError is a temporary net.Error.
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "temporary error" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+// fakeListener returns the connections and the errors of its channel,
+// then errListenerClosed.
+type fakeListener struct {
+	net.Listener
+	accepts chan interface{}
+}
+
+var errListenerClosed = errors.New("listener closed")
+
+func (l *fakeListener) Accept() (net.Conn, error) {
+	a, ok := <-l.accepts
+	if !ok {
+		return nil, errListenerClosed
+	}
+	if err, ok := a.(error); ok {
+		return nil, err
+	}
+	return a.(net.Conn), nil
+}
+
+func TestServe(t *testing.T) {
+	server := newTestServer(t, &fakeVTGate{}, testUsers)
+	server.maxConns = 1
+	l := &fakeListener{accepts: make(chan interface{}, 4)}
+	client1, server1 := net.Pipe()
+	defer client1.Close()
+	client2, server2 := net.Pipe()
+	defer client2.Close()
+
+	// the temporary errors are retried, the second connection is
+	// refused
+	l.accepts <- temporaryError{}
+	l.accepts <- server1
+	l.accepts <- temporaryError{}
+	l.accepts <- server2
+	close(l.accepts)
+	refused := connRefused.Get()
+	if err := server.Serve(l); err != errListenerClosed {
+		t.Errorf("Serve() = %v, want %v", err, errListenerClosed)
+	}
+
+	c1 := &testClient{packetConn: newPacketConn(client1, 1<<30), t: t}
+	if data := c1.read(); data[0] != protocolVersion {
+		t.Errorf("first connection: got %q, want a handshake", data)
+	}
+	c2 := &testClient{packetConn: newPacketConn(client2, 1<<30), t: t}
+	pr := &payloadReader{data: c2.read()}
+	if header, code := pr.uint8(), pr.uint16(); header != errPacket || code != errTooManyConns {
+		t.Errorf("second connection: got %q, want error %v", pr.data, errTooManyConns)
+	}
+	if got := connRefused.Get() - refused; got != 1 {
+		t.Errorf("%v connections refused, want 1", got)
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	server := newTestServer(t, &fakeVTGate{}, testUsers)
+	server.handshakeTimeout = 10 * time.Millisecond
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go newConn(server, serverConn, 1).serve()
+	client := &testClient{packetConn: newPacketConn(clientConn, 1<<30), t: t}
+	client.read()
+	if data, err := client.readPacket(); err == nil {
+		t.Errorf("got %q, want the connection closed after the timeout", data)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	fake := &fakeVTGate{
+		result: &mproto.QueryResult{
+			Fields: []mproto.Field{
+				{Name: "id", Type: mproto.VT_LONGLONG},
+				{Name: "name", Type: mproto.VT_VAR_STRING},
+			},
+			RowsAffected: 2,
+			Rows: [][]sqltypes.Value{
+				{sqltypes.MakeNumeric([]byte("1")), sqltypes.MakeString([]byte("a"))},
+				{sqltypes.MakeNumeric([]byte("2")), {}},
+			},
+		},
+	}
+	client, _ := connect(t, newTestServer(t, fake, testUsers), "user1", "", mysqlNativePassword)
+	defer client.conn.Close()
+
+	rows := client.query("select id, name from t")
+	if want := [][]string{{"1", "a"}, {"2", "NULL"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+	if got := fake.queries[0]; got.Sql != "select id, name from t" || got.TabletType != topo.TYPE_REPLICA {
+		t.Errorf("query = %+v", got)
+	}
+	// the character set of the handshake response
+	if got := fake.queries[0].Session.Charset; got != "utf8" {
+		t.Errorf("charset = %q, want utf8", got)
+	}
+
+	fake.result = &mproto.QueryResult{RowsAffected: 3, InsertId: 4}
+	pr := &payloadReader{data: client.command(comQuery, []byte("insert into t(name) values ('b')"))}
+	if header, affected, insertID := pr.uint8(), pr.lenEncInt(), pr.lenEncInt(); header != okPacket || affected != 3 || insertID != 4 {
+		t.Errorf("OK = %v, %v, %v, want 0, 3, 4", header, affected, insertID)
+	}
+
+	fake.err = errors.New("vttablet: error: Duplicate entry '1' for key 'PRIMARY' (errno 1062)")
+	pr = &payloadReader{data: client.command(comQuery, []byte("insert into t(id) values (1)"))}
+	if header, code := pr.uint8(), pr.uint16(); header != errPacket || code != errDupEntry {
+		t.Errorf("error = %v, %v, want %v, %v", header, code, errPacket, errDupEntry)
+	}
+	if state := string(pr.bytes(6)); state != "#"+sqlStateDupEntry {
+		t.Errorf("SQL state = %v, want %v", state, sqlStateDupEntry)
+	}
+
+	if data := client.command(comPing, nil); data[0] != okPacket {
+		t.Errorf("ping: got %q", data)
+	}
+	if data := client.command(0x1f, nil); data[0] != errPacket {
+		t.Errorf("unknown command: got %q", data)
+	}
+	rows = client.query("select @@version_comment limit 1")
+	if want := [][]string{{"Vitess"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("version comment = %v, want %v", rows, want)
+	}
+	if len(fake.queries) != 3 {
+		t.Errorf("want 3 queries, got %v", len(fake.queries))
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	fake := &fakeVTGate{result: &mproto.QueryResult{RowsAffected: 1}}
+	client, _ := connect(t, newTestServer(t, fake, testUsers), "user1", "", mysqlNativePassword)
+	defer client.conn.Close()
+
+	status := func(sql string) uint16 {
+		pr := &payloadReader{data: client.command(comQuery, []byte(sql))}
+		if header := pr.uint8(); header != okPacket {
+			t.Fatalf("%v: got %q", sql, pr.data)
+		}
+		pr.lenEncInt()
+		pr.lenEncInt()
+		return pr.uint16()
+	}
+
+	if got := status("set names latin1"); got != serverStatusAutocommit {
+		t.Errorf("set names: status = %v", got)
+	}
+	if got := status("begin"); got != serverStatusInTrans {
+		t.Errorf("begin: status = %v", got)
+	}
+	if got := status("update t set name = 'c'"); got != serverStatusInTrans {
+		t.Errorf("update: status = %v", got)
+	}
+	if got := fake.queries[0].Session; !got.InTransaction || got.Charset != "latin1" {
+		t.Errorf("session = %v, want a latin1 transaction", got)
+	}
+	if got := status("commit"); got != serverStatusAutocommit {
+		t.Errorf("commit: status = %v", got)
+	}
+	status("start transaction")
+	status("rollback")
+	status("rollback")
+	if fake.commits != 1 || fake.rollbacks != 1 {
+		t.Errorf("commits, rollbacks = %v, %v, want 1, 1", fake.commits, fake.rollbacks)
+	}
+
+	if data := client.command(comQuery, []byte("set autocommit = 0")); data[0] != errPacket {
+		t.Errorf("set autocommit = 0: got %q", data)
+	}
+	status("set autocommit = 1")
+	if len(fake.queries) != 1 {
+		t.Errorf("want 1 query, got %v", fake.queries)
+	}
+}
+
+func TestPreparedStatement(t *testing.T) {
+	fake := &fakeVTGate{
+		result: &mproto.QueryResult{
+			Fields: []mproto.Field{
+				{Name: "id", Type: mproto.VT_LONGLONG},
+				{Name: "d", Type: mproto.VT_DATETIME},
+				{Name: "f", Type: mproto.VT_DOUBLE},
+				{Name: "name", Type: mproto.VT_VAR_STRING},
+			},
+			RowsAffected: 1,
+			Rows: [][]sqltypes.Value{{
+				sqltypes.MakeNumeric([]byte("-5")),
+				sqltypes.MakeString([]byte("2015-06-01 10:20:30")),
+				sqltypes.MakeFractional([]byte("1.5")),
+				{},
+			}},
+		},
+	}
+	client, _ := connect(t, newTestServer(t, fake, testUsers), "user1", "", mysqlNativePassword)
+	defer client.conn.Close()
+
+	pr := &payloadReader{data: client.command(comStmtPrepare, []byte("select * from t where id = ? and name = '?' and d = ?"))}
+	if header := pr.uint8(); header != okPacket {
+		t.Fatalf("prepare: got %q", pr.data)
+	}
+	id := pr.uint32()
+	if columns, params := pr.uint16(), pr.uint16(); columns != 0 || params != 2 {
+		t.Errorf("columns, params = %v, %v, want 0, 2", columns, params)
+	}
+	client.read()
+	client.read()
+	if data := client.read(); data[0] != eofPacket {
+		t.Errorf("want EOF after the params, got %q", data)
+	}
+
+	b := appendUint32(nil, id)
+	b = append(b, 0)
+	b = appendUint32(b, 1)
+	b = append(b, 0, 1) // null bitmap, new params bound
+	b = appendUint16(b, typeLongLong)
+	b = appendUint16(b, typeDatetime)
+	b = appendUint64(b, 7)
+	b = append(b, 7)
+	b = appendUint16(b, 2015)
+	b = append(b, 6, 1, 10, 20, 30)
+	rows := client.readRows(client.command(comStmtExecute, b), true)
+	if want := [][]string{{"-5", "2015-06-01 10:20:30", "1.5", "NULL"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+	query := fake.queries[0]
+	if want := "select * from t where id = :v1 and name = '?' and d = :v2"; query.Sql != want {
+		t.Errorf("sql = %q, want %q", query.Sql, want)
+	}
+	if want := map[string]interface{}{"v1": int64(7), "v2": "2015-06-01 10:20:30"}; !reflect.DeepEqual(query.BindVariables, want) {
+		t.Errorf("bind variables = %v, want %v", query.BindVariables, want)
+	}
+
+	if data := client.command(comStmtReset, appendUint32(nil, id)); data[0] != okPacket {
+		t.Errorf("reset: got %q", data)
+	}
+	client.seq = 0
+	client.write(append([]byte{comStmtClose}, appendUint32(nil, id)...))
+	if data := client.command(comStmtExecute, b); data[0] != errPacket {
+		t.Errorf("execute of a closed statement: got %q", data)
+	}
+}
+
+func TestRewritePlaceholders(t *testing.T) {
+	tcases := []struct {
+		in, out string
+		count   int
+	}{
+		{"select ?", "select :v1", 1},
+		{"select ? from t where a = '?' and b = \"\\\"?\" and `?` = ?", "select :v1 from t where a = '?' and b = \"\\\"?\" and `?` = :v2", 2},
+		{"select /* ? */ ? -- ?\n, ? # ?", "select /* ? */ :v1 -- ?\n, :v2 # ?", 2},
+		{"select 'unterminated ?", "select 'unterminated ?", 0},
+		{"select /* ?", "select /* ?", 0},
+	}
+	for _, tcase := range tcases {
+		out, count := rewritePlaceholders(tcase.in)
+		if out != tcase.out || count != tcase.count {
+			t.Errorf("rewritePlaceholders(%q) = %q, %v, want %q, %v", tcase.in, out, count, tcase.out, tcase.count)
+		}
+	}
+}
+
+func TestBinaryTemporalValues(t *testing.T) {
+	tcases := []struct {
+		typ    int64
+		in     string
+		length byte
+		out    string
+	}{
+		{typeDatetime, "0000-00-00 00:00:00", 0, "0000-00-00 00:00:00"},
+		{typeDate, "2015-06-01", 4, "2015-06-01 00:00:00"},
+		{typeTimestamp, "2015-06-01 10:20:30.25", 11, "2015-06-01 10:20:30.250000"},
+		{typeTime, "00:00:00", 0, "00:00:00"},
+		{typeTime, "-838:59:59", 8, "-838:59:59"},
+		{typeTime, "10:20:30.000001", 12, "10:20:30.000001"},
+	}
+	for _, tcase := range tcases {
+		b, err := appendBinaryValue(nil, tcase.typ, tcase.in)
+		if err != nil {
+			t.Errorf("appendBinaryValue(%q) failed: %v", tcase.in, err)
+			continue
+		}
+		if b[0] != tcase.length {
+			t.Errorf("appendBinaryValue(%q) length = %v, want %v", tcase.in, b[0], tcase.length)
+		}
+		value, err := readBinaryValue(&payloadReader{data: b}, uint16(tcase.typ))
+		if err != nil || value != tcase.out {
+			t.Errorf("readBinaryValue(%q) = %v, %v, want %v", tcase.in, value, err, tcase.out)
+		}
+	}
+	if _, err := appendBinaryValue(nil, typeDatetime, "2015-06"); err == nil {
+		t.Errorf("appendBinaryValue accepted an invalid date")
+	}
+}
@@ -55,6 +55,15 @@ func buildInsertPlan(ins *sqlparser.Insert, schema *Schema) *Plan {
 	}
 	colVindexes := schema.Tables[tablename].ColVindexes
 	plan.ID = InsertSharded
+	// The auto-increment column is resolved first, because
+	// it can also be a vindex column.
+	if plan.Table.Autoinc != nil {
+		if err := buildAutoincPlan(ins, plan.Table.Autoinc, plan); err != nil {
+			plan.ID = NoPlan
+			plan.Reason = err.Error()
+			return plan
+		}
+	}
 	plan.Values = make([]interface{}, 0, len(colVindexes))
 	for _, index := range colVindexes {
 		if err := buildIndexPlan(ins, tablename, index, plan); err != nil {
@@ -68,18 +77,7 @@ func buildInsertPlan(ins *sqlparser.Insert, schema *Schema) *Plan {
 }
 
 func buildIndexPlan(ins *sqlparser.Insert, tablename string, colVindex *ColVindex, plan *Plan) error {
-	pos := -1
-	for i, column := range ins.Columns {
-		if colVindex.Col == sqlparser.GetColName(column.(*sqlparser.NonStarExpr).Expr) {
-			pos = i
-			break
-		}
-	}
-	if pos == -1 {
-		pos = len(ins.Columns)
-		ins.Columns = append(ins.Columns, &sqlparser.NonStarExpr{Expr: &sqlparser.ColName{Name: []byte(colVindex.Col)}})
-		ins.Rows.(sqlparser.Values)[0] = append(ins.Rows.(sqlparser.Values)[0].(sqlparser.ValTuple), &sqlparser.NullVal{})
-	}
+	pos := findOrAddColumn(ins, colVindex.Col)
 	row := ins.Rows.(sqlparser.Values)[0].(sqlparser.ValTuple)
 	val, err := asInterface(row[pos])
 	if err != nil {
@@ -89,3 +87,31 @@ func buildIndexPlan(ins *sqlparser.Insert, tablename string, colVindex *ColVinde
 	row[pos] = sqlparser.ValArg([]byte(fmt.Sprintf(":_%s", colVindex.Col)))
 	return nil
 }
+
+func buildAutoincPlan(ins *sqlparser.Insert, autoinc *Autoinc, plan *Plan) error {
+	pos := findOrAddColumn(ins, autoinc.Col)
+	row := ins.Rows.(sqlparser.Values)[0].(sqlparser.ValTuple)
+	val, err := asInterface(row[pos])
+	if err != nil {
+		return fmt.Errorf("could not convert val: %s, pos: %d: %v", sqlparser.String(row[pos]), pos, err)
+	}
+	plan.Generate = &Generate{
+		Sequence: autoinc.Sequence,
+		Value:    val,
+	}
+	row[pos] = sqlparser.ValArg([]byte(":" + SeqVarName))
+	return nil
+}
+
+// findOrAddColumn returns the position of the column in the insert.
+// If the column is not in the insert, it's added with a NULL value.
+func findOrAddColumn(ins *sqlparser.Insert, col string) int {
+	for i, column := range ins.Columns {
+		if col == sqlparser.GetColName(column.(*sqlparser.NonStarExpr).Expr) {
+			return i
+		}
+	}
+	ins.Columns = append(ins.Columns, &sqlparser.NonStarExpr{Expr: &sqlparser.ColName{Name: []byte(col)}})
+	ins.Rows.(sqlparser.Values)[0] = append(ins.Rows.(sqlparser.Values)[0].(sqlparser.ValTuple), &sqlparser.NullVal{})
+	return len(ins.Columns) - 1
+}
@@ -66,8 +66,25 @@ type Plan struct {
 	// Values is a single or a list of values that are used
 	// for making routing decisions.
 	Values interface{}
+	// Generate is set for InsertSharded if the table has an
+	// auto-increment column.
+	Generate *Generate
 }
 
+// Generate represents the instruction to fill in the value
+// of an auto-increment column.
+type Generate struct {
+	// Sequence is the sequence table to get the value from.
+	Sequence *Table
+	// Value is the value supplied by the insert. If it's
+	// nil, the value is fetched from the sequence.
+	Value interface{}
+}
+
+// SeqVarName is the bind variable that receives the value
+// of an auto-increment column.
+const SeqVarName = "__seq"
+
 // Size is defined so that Plan can be given to an LRUCache.
 func (pln *Plan) Size() int {
 	return 1
@@ -83,6 +100,16 @@ func (pln *Plan) MarshalJSON() ([]byte, error) {
 		vindexName = pln.ColVindex.Name
 		col = pln.ColVindex.Col
 	}
+	var generate interface{}
+	if pln.Generate != nil {
+		generate = struct {
+			Sequence string
+			Value    interface{}
+		}{
+			Sequence: pln.Generate.Sequence.Name,
+			Value:    pln.Generate.Value,
+		}
+	}
 	marshalPlan := struct {
 		ID        PlanID
 		Reason    string
@@ -93,6 +120,7 @@ func (pln *Plan) MarshalJSON() ([]byte, error) {
 		Vindex    string
 		Col       string
 		Values    interface{}
+		Generate  interface{} `json:",omitempty"`
 	}{
 		ID:        pln.ID,
 		Reason:    pln.Reason,
@@ -103,6 +131,7 @@ func (pln *Plan) MarshalJSON() ([]byte, error) {
 		Vindex:    vindexName,
 		Col:       col,
 		Values:    pln.Values,
+		Generate:  generate,
 	}
 	return json.Marshal(marshalPlan)
 }
@@ -117,12 +117,18 @@ var registry = make(map[string]NewVindexFunc)
 // Register registers a vindex under the specified vindexType.
 // A duplicate vindexType will generate a panic.
 // New vindexes will be created using these functions at the
-// time of schema loading.
+// time of schema loading. The vindex is also registered as a
+// sharding scheme with key.RegisterShardingScheme, valid if it
+// is Unique.
 func Register(vindexType string, newVindexFunc NewVindexFunc) {
 	if _, ok := registry[vindexType]; ok {
 		panic(fmt.Sprintf("%s is already registered", vindexType))
 	}
 	registry[vindexType] = newVindexFunc
+	key.RegisterShardingScheme(vindexType, func(params map[string]string) error {
+		_, err := CreateShardingScheme(vindexType, params)
+		return err
+	})
 }
 
 // CreateVindex creates a vindex of the specified type using the
@@ -134,3 +140,23 @@ func CreateVindex(vindexType string, params map[string]interface{}) (Vindex, err
 	}
 	return f(params)
 }
+
+// CreateShardingScheme creates the vindex that computes the keyspace
+// ids of the sharding column values of a keyspace, from the
+// ShardingScheme and ShardingSchemeParams of its record. The vindex
+// must be Unique.
+func CreateShardingScheme(scheme string, params map[string]string) (Unique, error) {
+	m := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		m[k] = v
+	}
+	vindex, err := CreateVindex(scheme, m)
+	if err != nil {
+		return nil, err
+	}
+	unique, ok := vindex.(Unique)
+	if !ok {
+		return nil, fmt.Errorf("sharding scheme %s is not a unique vindex", scheme)
+	}
+	return unique, nil
+}
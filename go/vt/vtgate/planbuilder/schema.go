@@ -24,8 +24,22 @@ type Table struct {
 	ColVindexes []*ColVindex
 	Ordered     []*ColVindex
 	Owned       []*ColVindex
+	Autoinc     *Autoinc
+	IsSequence  bool
 }
 
+// Autoinc contains the auto-increment info of a table:
+// the column is filled in from the sequence table if
+// an insert doesn't supply a value for it.
+type Autoinc struct {
+	Col      string
+	Sequence *Table
+}
+
+// sequenceClass is the class of the tables of unsharded keyspaces
+// that are sequences.
+const sequenceClass = "sequence"
+
 // Keyspace contains the keyspcae info for each Table.
 type Keyspace struct {
 	Name    string
@@ -44,6 +58,9 @@ type ColVindex struct {
 // BuildSchema builds a Schema from a SchemaFormal.
 func BuildSchema(source *SchemaFormal) (schema *Schema, err error) {
 	schema = &Schema{Tables: make(map[string]*Table)}
+	// Sequences can be in any keyspace, so autoincs are
+	// resolved after all tables are built.
+	autoincs := make(map[*Table]*AutoincFormal)
 	for ksname, ks := range source.Keyspaces {
 		keyspace := &Keyspace{
 			Name:    ksname,
@@ -72,6 +89,7 @@ func BuildSchema(source *SchemaFormal) (schema *Schema, err error) {
 				Keyspace: keyspace,
 			}
 			if !keyspace.Sharded {
+				t.IsSequence = cname == sequenceClass
 				schema.Tables[tname] = t
 				continue
 			}
@@ -115,9 +133,22 @@ func BuildSchema(source *SchemaFormal) (schema *Schema, err error) {
 				}
 			}
 			t.Ordered = colVindexSorted(t.ColVindexes)
+			if class.Autoinc != nil {
+				autoincs[t] = class.Autoinc
+			}
 			schema.Tables[tname] = t
 		}
 	}
+	for t, autoinc := range autoincs {
+		seq, ok := schema.Tables[autoinc.Sequence]
+		if !ok {
+			return nil, fmt.Errorf("sequence %s not found for table %s", autoinc.Sequence, t.Name)
+		}
+		if !seq.IsSequence {
+			return nil, fmt.Errorf("table %s is not a sequence, for table %s", autoinc.Sequence, t.Name)
+		}
+		t.Autoinc = &Autoinc{Col: autoinc.Col, Sequence: seq}
+	}
 	return schema, nil
 }
 
@@ -177,6 +208,15 @@ type VindexFormal struct {
 // the source.
 type ClassFormal struct {
 	ColVindexes []ColVindexFormal
+	Autoinc     *AutoincFormal
+}
+
+// AutoincFormal is the auto-increment info of a table class
+// as loaded from the source. Sequence is the name of a table
+// of class "sequence" in an unsharded keyspace.
+type AutoincFormal struct {
+	Col      string
+	Sequence string
 }
 
 // ColVindexFormal is the info for each indexed column
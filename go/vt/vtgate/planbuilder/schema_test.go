@@ -568,3 +568,124 @@ func TestBuildSchemaNonPrimaryLookupFail(t *testing.T) {
 		t.Errorf("BuildSchema: %v, want %v", err, want)
 	}
 }
+
+func TestBuildSchemaAutoinc(t *testing.T) {
+	good := SchemaFormal{
+		Keyspaces: map[string]KeyspaceFormal{
+			"sharded": {
+				Sharded: true,
+				Vindexes: map[string]VindexFormal{
+					"stfu": {
+						Type: "stfu",
+					},
+				},
+				Classes: map[string]ClassFormal{
+					"t1": {
+						ColVindexes: []ColVindexFormal{
+							{
+								Col:  "c1",
+								Name: "stfu",
+							},
+						},
+						Autoinc: &AutoincFormal{
+							Col:      "c1",
+							Sequence: "seq",
+						},
+					},
+				},
+				Tables: map[string]string{
+					"t1": "t1",
+				},
+			},
+			"unsharded": {
+				Tables: map[string]string{
+					"seq": "sequence",
+				},
+			},
+		},
+	}
+	got, err := BuildSchema(&good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq := got.Tables["seq"]
+	if !seq.IsSequence {
+		t.Errorf("seq.IsSequence: false, want true")
+	}
+	want := &Autoinc{Col: "c1", Sequence: seq}
+	if !reflect.DeepEqual(got.Tables["t1"].Autoinc, want) {
+		t.Errorf("t1.Autoinc: %+v, want %+v", got.Tables["t1"].Autoinc, want)
+	}
+}
+
+func TestBuildSchemaAutoincFail(t *testing.T) {
+	bad := SchemaFormal{
+		Keyspaces: map[string]KeyspaceFormal{
+			"sharded": {
+				Sharded: true,
+				Vindexes: map[string]VindexFormal{
+					"stfu": {
+						Type: "stfu",
+					},
+				},
+				Classes: map[string]ClassFormal{
+					"t1": {
+						ColVindexes: []ColVindexFormal{
+							{
+								Col:  "c1",
+								Name: "stfu",
+							},
+						},
+						Autoinc: &AutoincFormal{
+							Col:      "c1",
+							Sequence: "seq",
+						},
+					},
+				},
+				Tables: map[string]string{
+					"t1": "t1",
+				},
+			},
+			"unsharded": {
+				Tables: map[string]string{
+					"seq": "",
+				},
+			},
+		},
+	}
+	_, err := BuildSchema(&bad)
+	want := "table seq is not a sequence, for table t1"
+	if err == nil || err.Error() != want {
+		t.Errorf("BuildSchema: %v, want %v", err, want)
+	}
+
+	delete(bad.Keyspaces["unsharded"].Tables, "seq")
+	_, err = BuildSchema(&bad)
+	want = "sequence seq not found for table t1"
+	if err == nil || err.Error() != want {
+		t.Errorf("BuildSchema: %v, want %v", err, want)
+	}
+}
+
+func TestCreateShardingScheme(t *testing.T) {
+	scheme, err := CreateShardingScheme("stlu", map[string]string{"Table": "t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &stLU{Params: map[string]interface{}{"Table": "t"}}
+	if !reflect.DeepEqual(scheme, want) {
+		t.Errorf("CreateShardingScheme: %+v, want %+v", scheme, want)
+	}
+
+	_, err = CreateShardingScheme("stln", nil)
+	wantErr := "sharding scheme stln is not a unique vindex"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("CreateShardingScheme: %v, want %s", err, wantErr)
+	}
+
+	_, err = CreateShardingScheme("noexist", nil)
+	wantErr = "vindexType noexist not found"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("CreateShardingScheme: %v, want %s", err, wantErr)
+	}
+}
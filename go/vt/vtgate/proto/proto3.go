@@ -0,0 +1,321 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto
+
+import (
+	"github.com/youtube/vitess/go/vt/key"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/topo"
+
+	pbq "github.com/youtube/vitess/go/vt/proto/query"
+	pb "github.com/youtube/vitess/go/vt/proto/vtgate"
+)
+
+// This file contains the conversions between the vtgate structures
+// and their proto3 version, used by gRPC.
+// Like with bsonrpc, the lists and maps that come out of proto3 are
+// never nil: vtgate adds its own bind variables to the queries.
+
+// SessionToProto3 converts a Session, which may be nil.
+func SessionToProto3(session *Session) *pb.Session {
+	if session == nil {
+		return nil
+	}
+	result := &pb.Session{
+		InTransaction:      session.InTransaction,
+		ReadYourWrites:     session.ReadYourWrites,
+		LastWriteTime:      session.LastWriteTime,
+		MaxReplicationLag:  session.MaxReplicationLag,
+		TransactionOptions: tproto.TransactionOptionsToProto3(session.TransactionOptions),
+		Savepoints:         session.Savepoints,
+		LastInsertId:       session.LastInsertId,
+		RowCount:           session.RowCount,
+		Charset:            session.Charset,
+	}
+	if len(session.ShardSessions) > 0 {
+		result.ShardSessions = make([]*pb.ShardSession, len(session.ShardSessions))
+		for i, ss := range session.ShardSessions {
+			result.ShardSessions[i] = &pb.ShardSession{
+				Keyspace:      ss.Keyspace,
+				Shard:         ss.Shard,
+				TabletType:    string(ss.TabletType),
+				TransactionId: ss.TransactionId,
+			}
+		}
+	}
+	return result
+}
+
+// Proto3ToSession converts a proto3 Session, which may be nil.
+func Proto3ToSession(session *pb.Session) *Session {
+	if session == nil {
+		return nil
+	}
+	result := &Session{
+		InTransaction:      session.InTransaction,
+		ShardSessions:      make([]*ShardSession, len(session.ShardSessions)),
+		ReadYourWrites:     session.ReadYourWrites,
+		LastWriteTime:      session.LastWriteTime,
+		MaxReplicationLag:  session.MaxReplicationLag,
+		TransactionOptions: tproto.Proto3ToTransactionOptions(session.TransactionOptions),
+		Savepoints:         Proto3ToStrings(session.Savepoints),
+		LastInsertId:       session.LastInsertId,
+		RowCount:           session.RowCount,
+		Charset:            session.Charset,
+	}
+	for i, ss := range session.ShardSessions {
+		result.ShardSessions[i] = &ShardSession{
+			Keyspace:      ss.Keyspace,
+			Shard:         ss.Shard,
+			TabletType:    topo.TabletType(ss.TabletType),
+			TransactionId: ss.TransactionId,
+		}
+	}
+	return result
+}
+
+// Proto3ToStrings returns a proto3 list of strings, never nil.
+func Proto3ToStrings(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// BoundQueryToProto3 converts a query and its bind variables.
+func BoundQueryToProto3(sql string, bindVars map[string]interface{}) (*pbq.BoundQuery, error) {
+	return tproto.BoundQueryToProto3(&tproto.BoundQuery{
+		Sql:           sql,
+		BindVariables: bindVars,
+	})
+}
+
+// Proto3ToBoundQuery converts a proto3 BoundQuery. Its bind variables
+// are never nil.
+func Proto3ToBoundQuery(query *pbq.BoundQuery) (*tproto.BoundQuery, error) {
+	bq, err := tproto.Proto3ToBoundQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if bq.BindVariables == nil {
+		bq.BindVariables = make(map[string]interface{})
+	}
+	return bq, nil
+}
+
+// BoundQueriesToProto3 converts a list of BoundQuery.
+func BoundQueriesToProto3(queries []tproto.BoundQuery) ([]*pbq.BoundQuery, error) {
+	if len(queries) == 0 {
+		return nil, nil
+	}
+	result := make([]*pbq.BoundQuery, len(queries))
+	for i := range queries {
+		var err error
+		if result[i], err = tproto.BoundQueryToProto3(&queries[i]); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Proto3ToBoundQueries converts a proto3 list of BoundQuery.
+func Proto3ToBoundQueries(queries []*pbq.BoundQuery) ([]tproto.BoundQuery, error) {
+	result := make([]tproto.BoundQuery, len(queries))
+	for i, query := range queries {
+		bq, err := Proto3ToBoundQuery(query)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = *bq
+	}
+	return result, nil
+}
+
+// KeyspaceIdsToProto3 converts a list of keyspace ids.
+func KeyspaceIdsToProto3(ids []key.KeyspaceId) [][]byte {
+	if len(ids) == 0 {
+		return nil
+	}
+	result := make([][]byte, len(ids))
+	for i, id := range ids {
+		result[i] = []byte(id)
+	}
+	return result
+}
+
+// Proto3ToKeyspaceIds converts a proto3 list of keyspace ids.
+func Proto3ToKeyspaceIds(ids [][]byte) []key.KeyspaceId {
+	result := make([]key.KeyspaceId, len(ids))
+	for i, id := range ids {
+		result[i] = key.KeyspaceId(id)
+	}
+	return result
+}
+
+// KeyRangesToProto3 converts a list of KeyRange.
+func KeyRangesToProto3(keyRanges []key.KeyRange) []*pb.KeyRange {
+	if len(keyRanges) == 0 {
+		return nil
+	}
+	result := make([]*pb.KeyRange, len(keyRanges))
+	for i, kr := range keyRanges {
+		result[i] = &pb.KeyRange{
+			Start: []byte(kr.Start),
+			End:   []byte(kr.End),
+		}
+	}
+	return result
+}
+
+// Proto3ToKeyRanges converts a proto3 list of KeyRange.
+func Proto3ToKeyRanges(keyRanges []*pb.KeyRange) []key.KeyRange {
+	result := make([]key.KeyRange, len(keyRanges))
+	for i, kr := range keyRanges {
+		result[i] = key.KeyRange{
+			Start: key.KeyspaceId(kr.Start),
+			End:   key.KeyspaceId(kr.End),
+		}
+	}
+	return result
+}
+
+// EntityIdsToProto3 converts a list of EntityId.
+func EntityIdsToProto3(entityIds []EntityId) ([]*pb.EntityId, error) {
+	if len(entityIds) == 0 {
+		return nil, nil
+	}
+	result := make([]*pb.EntityId, len(entityIds))
+	for i, eid := range entityIds {
+		externalID, err := tproto.BindVariableToProto3(eid.ExternalID)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = &pb.EntityId{
+			ExternalId: externalID,
+			KeyspaceId: []byte(eid.KeyspaceID),
+		}
+	}
+	return result, nil
+}
+
+// Proto3ToEntityIds converts a proto3 list of EntityId.
+func Proto3ToEntityIds(entityIds []*pb.EntityId) ([]EntityId, error) {
+	result := make([]EntityId, len(entityIds))
+	for i, eid := range entityIds {
+		var externalID interface{}
+		if eid.ExternalId != nil {
+			var err error
+			if externalID, err = tproto.Proto3ToBindVariable(eid.ExternalId); err != nil {
+				return nil, err
+			}
+		}
+		result[i] = EntityId{
+			ExternalID: externalID,
+			KeyspaceID: key.KeyspaceId(eid.KeyspaceId),
+		}
+	}
+	return result, nil
+}
+
+// SplitQueryPartsToProto3 converts the result of a SplitQuery.
+func SplitQueryPartsToProto3(splits []SplitQueryPart) ([]*pb.SplitQueryPart, error) {
+	if len(splits) == 0 {
+		return nil, nil
+	}
+	result := make([]*pb.SplitQueryPart, len(splits))
+	for i, split := range splits {
+		part := &pb.SplitQueryPart{
+			Size: split.Size,
+		}
+		if split.Query != nil {
+			query, err := BoundQueryToProto3(split.Query.Sql, split.Query.BindVariables)
+			if err != nil {
+				return nil, err
+			}
+			part.Query = query
+			part.Keyspace = split.Query.Keyspace
+			part.KeyRanges = KeyRangesToProto3(split.Query.KeyRanges)
+			part.TabletType = string(split.Query.TabletType)
+		}
+		result[i] = part
+	}
+	return result, nil
+}
+
+// Proto3ToSplitQueryParts converts the proto3 result of a SplitQuery.
+func Proto3ToSplitQueryParts(splits []*pb.SplitQueryPart) ([]SplitQueryPart, error) {
+	result := make([]SplitQueryPart, len(splits))
+	for i, split := range splits {
+		bq, err := Proto3ToBoundQuery(split.Query)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = SplitQueryPart{
+			Query: &KeyRangeQuery{
+				Sql:           bq.Sql,
+				BindVariables: bq.BindVariables,
+				Keyspace:      split.Keyspace,
+				KeyRanges:     Proto3ToKeyRanges(split.KeyRanges),
+				TabletType:    topo.TabletType(split.TabletType),
+			},
+			Size: split.Size,
+		}
+	}
+	return result, nil
+}
+
+// ExplainResultToProto3 converts an ExplainResult.
+func ExplainResultToProto3(result *ExplainResult) *pb.ExplainResponse {
+	response := &pb.ExplainResponse{
+		PlanType:  result.PlanType,
+		Reason:    result.Reason,
+		Keyspace:  result.Keyspace,
+		Table:     result.Table,
+		Vindex:    result.Vindex,
+		Rewritten: result.Rewritten,
+		Shards:    result.Shards,
+	}
+	if tp := result.TabletPlan; tp != nil {
+		response.TabletPlan = &pbq.ExplainResponse{
+			PlanType:   tp.PlanType,
+			Reason:     tp.Reason,
+			TableName:  tp.TableName,
+			IndexUsed:  tp.IndexUsed,
+			PkValues:   tp.PKValues,
+			FieldQuery: tp.FieldQuery,
+			FullQuery:  tp.FullQuery,
+			OuterQuery: tp.OuterQuery,
+			Subquery:   tp.Subquery,
+		}
+	}
+	return response
+}
+
+// Proto3ToExplainResult converts a proto3 ExplainResponse.
+func Proto3ToExplainResult(response *pb.ExplainResponse) *ExplainResult {
+	result := &ExplainResult{
+		PlanType:  response.PlanType,
+		Reason:    response.Reason,
+		Keyspace:  response.Keyspace,
+		Table:     response.Table,
+		Vindex:    response.Vindex,
+		Rewritten: response.Rewritten,
+		Shards:    Proto3ToStrings(response.Shards),
+	}
+	if tp := response.TabletPlan; tp != nil {
+		result.TabletPlan = &tproto.ExplainResult{
+			PlanType:   tp.PlanType,
+			Reason:     tp.Reason,
+			TableName:  tp.TableName,
+			IndexUsed:  tp.IndexUsed,
+			PKValues:   Proto3ToStrings(tp.PkValues),
+			FieldQuery: tp.FieldQuery,
+			FullQuery:  tp.FullQuery,
+			OuterQuery: tp.OuterQuery,
+			Subquery:   tp.Subquery,
+		}
+	}
+	return result
+}
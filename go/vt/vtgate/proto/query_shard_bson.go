@@ -46,6 +46,8 @@ func (queryShard *QueryShard) MarshalBson(buf *bytes2.ChunkedWriter, key string)
 	} else {
 		(*queryShard.Session).MarshalBson(buf, "Session")
 	}
+	bson.EncodeBool(buf, "FieldsOnly", queryShard.FieldsOnly)
+	bson.EncodeString(buf, "IdempotencyKey", queryShard.IdempotencyKey)
 
 	lenWriter.Close()
 }
@@ -106,6 +108,10 @@ func (queryShard *QueryShard) UnmarshalBson(buf *bytes.Buffer, kind byte) {
 				queryShard.Session = new(Session)
 				(*queryShard.Session).UnmarshalBson(buf, kind)
 			}
+		case "FieldsOnly":
+			queryShard.FieldsOnly = bson.DecodeBool(buf, kind)
+		case "IdempotencyKey":
+			queryShard.IdempotencyKey = bson.DecodeString(buf, kind)
 		default:
 			bson.Skip(buf, kind)
 		}
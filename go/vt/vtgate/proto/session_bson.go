@@ -12,6 +12,7 @@ import (
 
 	"github.com/youtube/vitess/go/bson"
 	"github.com/youtube/vitess/go/bytes2"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
 )
 
 // MarshalBson bson-encodes Session.
@@ -34,6 +35,27 @@ func (session *Session) MarshalBson(buf *bytes2.ChunkedWriter, key string) {
 		}
 		lenWriter.Close()
 	}
+	bson.EncodeBool(buf, "ReadYourWrites", session.ReadYourWrites)
+	bson.EncodeInt64(buf, "LastWriteTime", session.LastWriteTime)
+	bson.EncodeInt64(buf, "MaxReplicationLag", session.MaxReplicationLag)
+	// *tproto.TransactionOptions
+	if session.TransactionOptions == nil {
+		bson.EncodePrefix(buf, bson.Null, "TransactionOptions")
+	} else {
+		(*session.TransactionOptions).MarshalBson(buf, "TransactionOptions")
+	}
+	// []string
+	{
+		bson.EncodePrefix(buf, bson.Array, "Savepoints")
+		lenWriter := bson.NewLenWriter(buf)
+		for _i, _v2 := range session.Savepoints {
+			bson.EncodeString(buf, bson.Itoa(_i), _v2)
+		}
+		lenWriter.Close()
+	}
+	bson.EncodeUint64(buf, "LastInsertId", session.LastInsertId)
+	bson.EncodeInt64(buf, "RowCount", session.RowCount)
+	bson.EncodeString(buf, "Charset", session.Charset)
 
 	lenWriter.Close()
 }
@@ -73,6 +95,39 @@ func (session *Session) UnmarshalBson(buf *bytes.Buffer, kind byte) {
 					session.ShardSessions = append(session.ShardSessions, _v1)
 				}
 			}
+		case "ReadYourWrites":
+			session.ReadYourWrites = bson.DecodeBool(buf, kind)
+		case "LastWriteTime":
+			session.LastWriteTime = bson.DecodeInt64(buf, kind)
+		case "MaxReplicationLag":
+			session.MaxReplicationLag = bson.DecodeInt64(buf, kind)
+		case "TransactionOptions":
+			// *tproto.TransactionOptions
+			if kind != bson.Null {
+				session.TransactionOptions = new(tproto.TransactionOptions)
+				(*session.TransactionOptions).UnmarshalBson(buf, kind)
+			}
+		case "Savepoints":
+			// []string
+			if kind != bson.Null {
+				if kind != bson.Array {
+					panic(bson.NewBsonError("unexpected kind %v for session.Savepoints", kind))
+				}
+				bson.Next(buf, 4)
+				session.Savepoints = make([]string, 0, 8)
+				for kind := bson.NextByte(buf); kind != bson.EOO; kind = bson.NextByte(buf) {
+					bson.SkipIndex(buf)
+					var _v2 string
+					_v2 = bson.DecodeString(buf, kind)
+					session.Savepoints = append(session.Savepoints, _v2)
+				}
+			}
+		case "LastInsertId":
+			session.LastInsertId = bson.DecodeUint64(buf, kind)
+		case "RowCount":
+			session.RowCount = bson.DecodeInt64(buf, kind)
+		case "Charset":
+			session.Charset = bson.DecodeString(buf, kind)
 		default:
 			bson.Skip(buf, kind)
 		}
@@ -19,12 +19,43 @@ import (
 type Session struct {
 	InTransaction bool
 	ShardSessions []*ShardSession
+	// ReadYourWrites is set by the client to send the replica
+	// and rdonly reads to the master for a while after a write.
+	ReadYourWrites bool
+	// LastWriteTime is the time of the last master query of the
+	// session, in nanoseconds since the epoch. It is set by vtgate
+	// in ReadYourWrites mode.
+	LastWriteTime int64
+	// MaxReplicationLag is the staleness the client tolerates for
+	// the replica and rdonly reads, in nanoseconds. If it is set,
+	// vtgate only sends them to the tablets that publish a lower
+	// replication lag, and to the master if there are none. 0 means
+	// any lag is fine.
+	MaxReplicationLag int64
+	// TransactionOptions are the options of the transactions vtgate
+	// begins on the shards, if the session is in a transaction. They
+	// are set by the client before the first query of a transaction.
+	TransactionOptions *tproto.TransactionOptions
+	// Savepoints are the names of the savepoints of the current
+	// transaction, oldest first. vtgate sets them on the shards
+	// that join the transaction after they were created.
+	Savepoints []string
+	// LastInsertId and RowCount are the values of LAST_INSERT_ID()
+	// and ROW_COUNT() for the session. vtgate sets them from the
+	// results of the queries, and answers the queries that select
+	// them, because the next query may run on another connection.
+	LastInsertId uint64
+	RowCount     int64
+	// Charset is the character set of the queries and results of
+	// the client, like "latin1". vtgate converts them from and to
+	// the character set of the keyspaces. Empty means no conversion.
+	Charset string
 }
 
 //go:generate bsongen -file $GOFILE -type Session -o session_bson.go
 
 func (session *Session) String() string {
-	return fmt.Sprintf("InTransaction: %v, ShardSession: %+v", session.InTransaction, session.ShardSessions)
+	return fmt.Sprintf("InTransaction: %v, ShardSession: %+v, ReadYourWrites: %v, LastWriteTime: %v, MaxReplicationLag: %v, TransactionOptions: %+v, Savepoints: %v, LastInsertId: %v, RowCount: %v, Charset: %v", session.InTransaction, session.ShardSessions, session.ReadYourWrites, session.LastWriteTime, session.MaxReplicationLag, session.TransactionOptions, session.Savepoints, session.LastInsertId, session.RowCount, session.Charset)
 }
 
 // ShardSession represents the session state for a shard.
@@ -60,6 +91,14 @@ type QueryShard struct {
 	Shards        []string
 	TabletType    topo.TabletType
 	Session       *Session
+	// FieldsOnly asks the vttablets for the fields of the result
+	// only, see the tabletserver Query.
+	FieldsOnly bool
+	// IdempotencyKey is sent with the write to the vttablets, see
+	// the tabletserver Query. vtgate then retries it after a
+	// connection error, even in a transaction. It is ignored by
+	// StreamExecuteShard.
+	IdempotencyKey string
 }
 
 //go:generate bsongen -file $GOFILE -type QueryShard -o query_shard_bson.go
@@ -168,3 +207,22 @@ type SplitQueryPart struct {
 type SplitQueryResult struct {
 	Splits []SplitQueryPart
 }
+
+// ExplainResult is the plan of a query, as returned by Explain.
+type ExplainResult struct {
+	// PlanType, Reason, Keyspace, Table and Vindex describe the
+	// V3 routing of the query.
+	PlanType string
+	Reason   string
+	Keyspace string
+	Table    string
+	Vindex   string
+	// Rewritten is the query sent to the shards.
+	Rewritten string
+	// Shards are the shards the query is routed to. They are
+	// not resolved for the sharded inserts, whose vindex values
+	// are only created when they run.
+	Shards []string
+	// TabletPlan is the plan of the query on the first shard.
+	TabletPlan *tproto.ExplainResult
+}
@@ -29,17 +29,39 @@ var commonSession = Session{
 		TabletType:    topo.TabletType("master"),
 		TransactionId: 2,
 	}},
+	Savepoints: []string{"a"},
+}
+
+type reflectTransactionOptions struct {
+	IsolationLevel string
+	ReadOnly       bool
 }
 
 type reflectSession struct {
-	InTransaction bool
-	ShardSessions []*ShardSession
+	InTransaction      bool
+	ShardSessions      []*ShardSession
+	ReadYourWrites     bool
+	LastWriteTime      int64
+	MaxReplicationLag  int64
+	TransactionOptions *reflectTransactionOptions
+	Savepoints         []string
+	LastInsertId       uint64
+	RowCount           int64
+	Charset            string
 }
 
 type extraSession struct {
-	Extra         int
-	InTransaction bool
-	ShardSessions []*ShardSession
+	Extra              int
+	InTransaction      bool
+	ShardSessions      []*ShardSession
+	ReadYourWrites     bool
+	LastWriteTime      int64
+	MaxReplicationLag  int64
+	TransactionOptions *reflectTransactionOptions
+	Savepoints         []string
+	LastInsertId       uint64
+	RowCount           int64
+	Charset            string
 }
 
 func TestSession(t *testing.T) {
@@ -56,6 +78,17 @@ func TestSession(t *testing.T) {
 			TabletType:    topo.TabletType("master"),
 			TransactionId: 2,
 		}},
+		ReadYourWrites:    true,
+		LastWriteTime:     3,
+		MaxReplicationLag: 4,
+		TransactionOptions: &reflectTransactionOptions{
+			IsolationLevel: tproto.IsolationReadCommitted,
+			ReadOnly:       true,
+		},
+		Savepoints:   []string{"a"},
+		LastInsertId: 5,
+		RowCount:     6,
+		Charset:      "latin1",
 	})
 	if err != nil {
 		t.Error(err)
@@ -63,6 +96,16 @@ func TestSession(t *testing.T) {
 	want := string(reflected)
 
 	custom := commonSession
+	custom.ReadYourWrites = true
+	custom.LastWriteTime = 3
+	custom.MaxReplicationLag = 4
+	custom.TransactionOptions = &tproto.TransactionOptions{
+		IsolationLevel: tproto.IsolationReadCommitted,
+		ReadOnly:       true,
+	}
+	custom.LastInsertId = 5
+	custom.RowCount = 6
+	custom.Charset = "latin1"
 	encoded, err := bson.Marshal(&custom)
 	if err != nil {
 		t.Error(err)
@@ -92,32 +135,38 @@ func TestSession(t *testing.T) {
 }
 
 type reflectQueryShard struct {
-	Sql           string
-	BindVariables map[string]interface{}
-	Keyspace      string
-	Shards        []string
-	TabletType    topo.TabletType
-	Session       *Session
+	Sql            string
+	BindVariables  map[string]interface{}
+	Keyspace       string
+	Shards         []string
+	TabletType     topo.TabletType
+	Session        *Session
+	FieldsOnly     bool
+	IdempotencyKey string
 }
 
 type extraQueryShard struct {
-	Extra         int
-	Sql           string
-	BindVariables map[string]interface{}
-	Keyspace      string
-	Shards        []string
-	TabletType    topo.TabletType
-	Session       *Session
+	Extra          int
+	Sql            string
+	BindVariables  map[string]interface{}
+	Keyspace       string
+	Shards         []string
+	TabletType     topo.TabletType
+	Session        *Session
+	FieldsOnly     bool
+	IdempotencyKey string
 }
 
 func TestQueryShard(t *testing.T) {
 	reflected, err := bson.Marshal(&reflectQueryShard{
-		Sql:           "query",
-		BindVariables: map[string]interface{}{"val": int64(1)},
-		Keyspace:      "keyspace",
-		Shards:        []string{"shard1", "shard2"},
-		TabletType:    topo.TabletType("replica"),
-		Session:       &commonSession,
+		Sql:            "query",
+		BindVariables:  map[string]interface{}{"val": int64(1)},
+		Keyspace:       "keyspace",
+		Shards:         []string{"shard1", "shard2"},
+		TabletType:     topo.TabletType("replica"),
+		Session:        &commonSession,
+		FieldsOnly:     true,
+		IdempotencyKey: "key",
 	})
 	if err != nil {
 		t.Error(err)
@@ -125,12 +174,14 @@ func TestQueryShard(t *testing.T) {
 	want := string(reflected)
 
 	custom := QueryShard{
-		Sql:           "query",
-		BindVariables: map[string]interface{}{"val": int64(1)},
-		Keyspace:      "keyspace",
-		Shards:        []string{"shard1", "shard2"},
-		TabletType:    topo.TabletType("replica"),
-		Session:       &commonSession,
+		Sql:            "query",
+		BindVariables:  map[string]interface{}{"val": int64(1)},
+		Keyspace:       "keyspace",
+		Shards:         []string{"shard1", "shard2"},
+		TabletType:     topo.TabletType("replica"),
+		Session:        &commonSession,
+		FieldsOnly:     true,
+		IdempotencyKey: "key",
 	}
 	encoded, err := bson.Marshal(&custom)
 	if err != nil {
@@ -163,12 +214,13 @@ func TestQueryShard(t *testing.T) {
 func TestQueryResult(t *testing.T) {
 	// We can't do the reflection test because bson
 	// doesn't do it correctly for embedded fields.
-	want := "|\x01\x00\x00" +
-		"\x03Result\x00\x85\x00\x00\x00" +
-		"\x04Fields\x00*\x00\x00\x00" +
-		"\x030\x00\"\x00\x00\x00" +
+	want := "2\x02\x00\x00" +
+		"\x03Result\x00\x94\x00\x00\x00" +
+		"\x04Fields\x009\x00\x00\x00" +
+		"\x030\x001\x00\x00\x00" +
 		"\x05Name\x00\x04\x00\x00\x00\x00name" +
-		"\x12Type\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+		"\x12Type\x00\x01\x00\x00\x00\x00\x00\x00\x00" +
+		"\x12Flags\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
 		"?RowsAffected\x00\x02\x00\x00\x00\x00\x00\x00\x00" +
 		"?InsertId\x00\x03\x00\x00\x00\x00\x00\x00\x00" +
 		"\x04Rows\x00 \x00\x00\x00" +
@@ -176,7 +228,7 @@ func TestQueryResult(t *testing.T) {
 		"\x050\x00\x01\x00\x00\x00" +
 		"\x001\x051\x00\x02\x00\x00\x00\x00aa" +
 		"\x00\x00\x00" +
-		"\x03Session\x00\xd0\x00\x00\x00" +
+		"\x03Session\x00w\x01\x00\x00" +
 		"\bInTransaction\x00\x01" +
 		"\x04ShardSessions\x00\xac\x00\x00\x00" +
 		"\x030\x00Q\x00\x00\x00" +
@@ -190,13 +242,24 @@ func TestQueryResult(t *testing.T) {
 		"\x05Shard\x00\x01\x00\x00\x00\x001" +
 		"\x05TabletType\x00\x06\x00\x00\x00\x00master" +
 		"\x12TransactionId\x00\x02\x00\x00\x00\x00\x00\x00\x00" +
-		"\x00\x00\x00" +
+		"\x00\x00" +
+		"\bReadYourWrites\x00\x00" +
+		"\x12LastWriteTime\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+		"\x12MaxReplicationLag\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+		"\nTransactionOptions\x00" +
+		"\x04Savepoints\x00\x0e\x00\x00\x00" +
+		"\x050\x00\x01\x00\x00\x00\x00a" +
+		"\x00" +
+		"?LastInsertId\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+		"\x12RowCount\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+		"\x05Charset\x00\x00\x00\x00\x00\x00" +
+		"\x00" +
 		"\x05Error\x00\x05\x00\x00\x00\x00error" +
 		"\x00"
 
 	custom := QueryResult{
 		Result: &mproto.QueryResult{
-			Fields:       []mproto.Field{{"name", 1}},
+			Fields:       []mproto.Field{{"name", 1, 0}},
 			RowsAffected: 2,
 			InsertId:     3,
 			Rows: [][]sqltypes.Value{
@@ -267,6 +330,7 @@ func TestBatchQueryShard(t *testing.T) {
 				TabletType:    topo.TabletType("master"),
 				TransactionId: 2,
 			}},
+			Savepoints: []string{"a"},
 		},
 	})
 	if err != nil {
@@ -348,7 +412,7 @@ type extraQueryResultList struct {
 func TestQueryResultList(t *testing.T) {
 	reflected, err := bson.Marshal(&reflectQueryResultList{
 		List: []mproto.QueryResult{{
-			Fields:       []mproto.Field{{"name", 1}},
+			Fields:       []mproto.Field{{"name", 1, 0}},
 			RowsAffected: 2,
 			InsertId:     3,
 			Rows: [][]sqltypes.Value{
@@ -365,7 +429,7 @@ func TestQueryResultList(t *testing.T) {
 
 	custom := QueryResultList{
 		List: []mproto.QueryResult{{
-			Fields:       []mproto.Field{{"name", 1}},
+			Fields:       []mproto.Field{{"name", 1, 0}},
 			RowsAffected: 2,
 			InsertId:     3,
 			Rows: [][]sqltypes.Value{
@@ -580,6 +644,7 @@ func TestKeyspaceIdBatchQuery(t *testing.T) {
 				TabletType:    topo.TabletType("master"),
 				TransactionId: 2,
 			}},
+			Savepoints: []string{"a"},
 		},
 	})
 	if err != nil {
@@ -0,0 +1,224 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtgate
+
+// This is a V3 file. Do not intermix with V2.
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/youtube/vitess/go/acl"
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/vt/vtgate/planbuilder"
+)
+
+var queryStatsLabels = []string{"Keyspace", "Table", "Plan"}
+
+// queryStats aggregates the V3 queries by keyspace, table and plan.
+// For each of them, it tracks the latencies, the errors, and the
+// total number of shards the queries were sent to.
+type queryStats struct {
+	timings *stats.MultiTimings
+	errors  *stats.MultiCounters
+	shards  *stats.MultiCounters
+}
+
+// newQueryStats creates a queryStats. If statsName is set, the stats
+// are exported as <statsName>QueryTimings, <statsName>QueryErrors and
+// <statsName>QueryShards.
+func newQueryStats(statsName string) *queryStats {
+	var timings, errors, shards string
+	if statsName != "" {
+		timings = statsName + "QueryTimings"
+		errors = statsName + "QueryErrors"
+		shards = statsName + "QueryShards"
+	}
+	return &queryStats{
+		timings: stats.NewMultiTimings(timings, queryStatsLabels),
+		errors:  stats.NewMultiCounters(errors, queryStatsLabels),
+		shards:  stats.NewMultiCounters(shards, queryStatsLabels),
+	}
+}
+
+// record adds a query that was executed with plan, and sent to
+// shardCount shards.
+func (qs *queryStats) record(plan *planbuilder.Plan, shardCount int, startTime time.Time, err error) {
+	keyspace, table := "", ""
+	if plan.Table != nil {
+		table = plan.Table.Name
+		if plan.Table.Keyspace != nil {
+			keyspace = plan.Table.Keyspace.Name
+		}
+	}
+	key := []string{keyspace, table, plan.ID.String()}
+	qs.timings.Record(key, startTime)
+	qs.shards.Add(key, int64(shardCount))
+	if err != nil {
+		qs.errors.Add(key, 1)
+	}
+}
+
+// queryStatsRow is one row of the queryz page.
+type queryStatsRow struct {
+	Keyspace string
+	Table    string
+	Plan     string
+	Count    int64
+	tm       time.Duration
+	Errors   int64
+	Shards   int64
+	Color    string
+}
+
+// Time returns the total time as a string.
+func (row *queryStatsRow) Time() string {
+	return fmt.Sprintf("%.6f", row.tm.Seconds())
+}
+
+func (row *queryStatsRow) timePQ() float64 {
+	return row.tm.Seconds() / float64(row.Count)
+}
+
+// TimePQ returns the time per query as a string.
+func (row *queryStatsRow) TimePQ() string {
+	return fmt.Sprintf("%.6f", row.timePQ())
+}
+
+// ErrorsPQ returns the error count per query as a string.
+func (row *queryStatsRow) ErrorsPQ() string {
+	return fmt.Sprintf("%.6f", float64(row.Errors)/float64(row.Count))
+}
+
+// ShardsPQ returns the shard count per query as a string.
+func (row *queryStatsRow) ShardsPQ() string {
+	return fmt.Sprintf("%.2f", float64(row.Shards)/float64(row.Count))
+}
+
+// rows returns the aggregated stats, the most expensive queries first.
+func (qs *queryStats) rows() []*queryStatsRow {
+	errors := qs.errors.Counts()
+	shards := qs.shards.Counts()
+	var rows []*queryStatsRow
+	for key, histogram := range qs.timings.Histograms() {
+		if histogram.Count() == 0 {
+			continue
+		}
+		names := strings.SplitN(key, ".", len(queryStatsLabels))
+		if len(names) != len(queryStatsLabels) {
+			continue
+		}
+		row := &queryStatsRow{
+			Keyspace: names[0],
+			Table:    names[1],
+			Plan:     names[2],
+			Count:    histogram.Count(),
+			tm:       time.Duration(histogram.Total()),
+			Errors:   errors[key],
+			Shards:   shards[key],
+		}
+		timepq := row.tm / time.Duration(row.Count)
+		if timepq < 10*time.Millisecond {
+			row.Color = "low"
+		} else if timepq < 100*time.Millisecond {
+			row.Color = "medium"
+		} else {
+			row.Color = "high"
+		}
+		rows = append(rows, row)
+	}
+	sort.Sort(queryStatsRows(rows))
+	return rows
+}
+
+type queryStatsRows []*queryStatsRow
+
+func (rows queryStatsRows) Len() int           { return len(rows) }
+func (rows queryStatsRows) Swap(i, j int)      { rows[i], rows[j] = rows[j], rows[i] }
+func (rows queryStatsRows) Less(i, j int) bool { return rows[i].tm > rows[j].tm }
+
+var queryzTmpl = template.Must(template.New("queryz").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<style type="text/css">
+	table.gridtable {
+		font-family: verdana,arial,sans-serif;
+		font-size: 11px;
+		border-width: 1px;
+		border-collapse: collapse;
+	}
+	table.gridtable th {
+		border-width: 1px;
+		padding: 8px;
+		border-style: solid;
+		background-color: #dedede;
+	}
+	table.gridtable td {
+		border-width: 1px;
+		padding: 4px;
+		border-style: solid;
+	}
+	table.gridtable tr.low {
+		background-color: #f0f0f0;
+	}
+	table.gridtable tr.medium {
+		background-color: #ffcc00;
+	}
+	table.gridtable tr.high {
+		background-color: #ff3300;
+	}
+</style>
+</head>
+<body>
+<table class="gridtable">
+	<thead>
+		<tr>
+			<th>Keyspace</th>
+			<th>Table</th>
+			<th>Plan</th>
+			<th>Count</th>
+			<th>Time</th>
+			<th>Errors</th>
+			<th>Shards</th>
+			<th>Time per query</th>
+			<th>Errors per query</th>
+			<th>Shards per query</th>
+		</tr>
+	</thead>
+	{{range .}}
+	<tr class="{{.Color}}">
+		<td>{{.Keyspace}}</td>
+		<td>{{.Table}}</td>
+		<td>{{.Plan}}</td>
+		<td>{{.Count}}</td>
+		<td>{{.Time}}</td>
+		<td>{{.Errors}}</td>
+		<td>{{.Shards}}</td>
+		<td>{{.TimePQ}}</td>
+		<td>{{.ErrorsPQ}}</td>
+		<td>{{.ShardsPQ}}</td>
+	</tr>
+	{{end}}
+</table>
+</body>
+</html>
+`))
+
+// ServeHTTP renders the queryz page, with the stats of each keyspace,
+// table and plan, sorted by total time.
+func (qs *queryStats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := acl.CheckAccessHTTP(r, acl.DEBUGGING); err != nil {
+		acl.SendError(w, err)
+		return
+	}
+	if err := queryzTmpl.Execute(w, qs.rows()); err != nil {
+		log.Errorf("queryz: couldn't execute template: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtgate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// This file uses the sandbox_test framework.
+
+func TestQueryStats(t *testing.T) {
+	router, sbc1, _, _ := createRouterEnv()
+
+	for i := 0; i < 2; i++ {
+		if _, err := routerExec(router, "select * from user where id = 1", nil); err != nil {
+			t.Error(err)
+		}
+	}
+	sbc1.mustFailServer = 1
+	if _, err := routerExec(router, "select * from user where id = 1", nil); err == nil {
+		t.Errorf("want error, got nil")
+	}
+	if _, err := routerExec(router, "select * from user where id in (1, 3)", nil); err != nil {
+		t.Error(err)
+	}
+
+	rows := router.queryStats.rows()
+	byPlan := make(map[string]*queryStatsRow)
+	for _, row := range rows {
+		if row.Keyspace != "TestRouter" || row.Table != "user" {
+			t.Errorf("unexpected row: %+v", row)
+		}
+		byPlan[row.Plan] = row
+	}
+	equal := byPlan["SelectEqual"]
+	if equal == nil || equal.Count != 3 || equal.Errors != 1 || equal.Shards != 3 {
+		t.Errorf("SelectEqual stats: %+v, want 3 queries, 1 error and 3 shards", equal)
+	}
+	in := byPlan["SelectIN"]
+	if in == nil || in.Count != 1 || in.Errors != 0 || in.Shards != 2 {
+		t.Errorf("SelectIN stats: %+v, want 1 query to 2 shards", in)
+	}
+
+	req, err := http.NewRequest("GET", "/queryz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.queryStats.ServeHTTP(w, req)
+	if body := w.Body.String(); !strings.Contains(body, "<td>SelectIN</td>") {
+		t.Errorf("queryz page doesn't have the SelectIN plan:\n%v", body)
+	}
+}
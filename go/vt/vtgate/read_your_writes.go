@@ -0,0 +1,41 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtgate
+
+import (
+	"flag"
+	"time"
+
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vtgate/proto"
+)
+
+var (
+	readYourWritesWindow = flag.Duration("read_your_writes_window", 5*time.Second, "how long the reads of a session in ReadYourWrites mode go to the master after a write")
+
+	readYourWritesRedirects = stats.NewCounters("VtgateReadYourWritesRedirects")
+)
+
+// readYourWritesTabletType returns the tablet type to send a query
+// to. For a session in ReadYourWrites mode, it remembers the time of
+// the master queries, which are the ones that can write, and sends
+// the other queries to the master too until the window has expired,
+// so they see the writes even if the replicas are lagging.
+func readYourWritesTabletType(session *proto.Session, tabletType topo.TabletType) topo.TabletType {
+	if session == nil || !session.ReadYourWrites {
+		return tabletType
+	}
+	now := time.Now().UnixNano()
+	if tabletType == topo.TYPE_MASTER {
+		session.LastWriteTime = now
+		return tabletType
+	}
+	if session.LastWriteTime != 0 && now < session.LastWriteTime+int64(*readYourWritesWindow) {
+		readYourWritesRedirects.Add(string(tabletType), 1)
+		return topo.TYPE_MASTER
+	}
+	return tabletType
+}
@@ -0,0 +1,55 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtgate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vtgate/proto"
+)
+
+func TestReadYourWritesTabletType(t *testing.T) {
+	// sessions not in ReadYourWrites mode are not changed
+	session := &proto.Session{}
+	readYourWritesTabletType(session, topo.TYPE_MASTER)
+	if got := readYourWritesTabletType(session, topo.TYPE_REPLICA); got != topo.TYPE_REPLICA {
+		t.Errorf("want %v, got %v", topo.TYPE_REPLICA, got)
+	}
+	if session.LastWriteTime != 0 {
+		t.Errorf("want 0, got %v", session.LastWriteTime)
+	}
+	if got := readYourWritesTabletType(nil, topo.TYPE_REPLICA); got != topo.TYPE_REPLICA {
+		t.Errorf("want %v, got %v", topo.TYPE_REPLICA, got)
+	}
+
+	// no write yet
+	session = &proto.Session{ReadYourWrites: true}
+	if got := readYourWritesTabletType(session, topo.TYPE_RDONLY); got != topo.TYPE_RDONLY {
+		t.Errorf("want %v, got %v", topo.TYPE_RDONLY, got)
+	}
+
+	// reads go to the master after a write
+	if got := readYourWritesTabletType(session, topo.TYPE_MASTER); got != topo.TYPE_MASTER {
+		t.Errorf("want %v, got %v", topo.TYPE_MASTER, got)
+	}
+	if session.LastWriteTime == 0 {
+		t.Errorf("the write time was not saved")
+	}
+	redirects := readYourWritesRedirects.Counts()[string(topo.TYPE_REPLICA)]
+	if got := readYourWritesTabletType(session, topo.TYPE_REPLICA); got != topo.TYPE_MASTER {
+		t.Errorf("want %v, got %v", topo.TYPE_MASTER, got)
+	}
+	if got := readYourWritesRedirects.Counts()[string(topo.TYPE_REPLICA)]; got != redirects+1 {
+		t.Errorf("want %v, got %v", redirects+1, got)
+	}
+
+	// and back to the replicas after the window
+	session.LastWriteTime -= int64(*readYourWritesWindow + time.Second)
+	if got := readYourWritesTabletType(session, topo.TYPE_REPLICA); got != topo.TYPE_REPLICA {
+		t.Errorf("want %v, got %v", topo.TYPE_REPLICA, got)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtgate
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
+)
+
+const defaultReplicationLagBounds = "1s,5s,10s,30s,1m,5m"
+
+var (
+	replicationLagBoundsFlag = flag.String("replication_lag_bounds", defaultReplicationLagBounds, "comma separated list of the replication lags the sessions can bound their reads to. A session's MaxReplicationLag is rounded down to one of them")
+
+	// replicationLagBounds are the sorted -replication_lag_bounds.
+	// Each of them has its own shard connections.
+	replicationLagBounds []time.Duration
+
+	replicationLagFallbacks = stats.NewCounters("VtgateReplicationLagFallbacks")
+)
+
+func init() {
+	if err := initReplicationLagBounds(defaultReplicationLagBounds); err != nil {
+		panic(err)
+	}
+}
+
+func initReplicationLagBounds(value string) error {
+	var bounds []time.Duration
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		bound, err := time.ParseDuration(entry)
+		if err != nil {
+			return err
+		}
+		if bound < time.Second || bound%time.Second != 0 {
+			return fmt.Errorf("invalid replication lag bound %v, want a whole number of seconds", entry)
+		}
+		bounds = append(bounds, bound)
+	}
+	sort.Sort(durations(bounds))
+	replicationLagBounds = bounds
+	return nil
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// replicationLagBound returns the largest -replication_lag_bounds
+// entry that is at most maxLag, or false if there is none.
+func replicationLagBound(maxLag time.Duration) (time.Duration, bool) {
+	for i := len(replicationLagBounds) - 1; i >= 0; i-- {
+		if replicationLagBounds[i] <= maxLag {
+			return replicationLagBounds[i], true
+		}
+	}
+	return 0, false
+}
+
+// endPointLagIsWithin returns true if the endpoint published a
+// replication lag of at most maxLag in its health. The tablets that
+// don't publish their lag may be arbitrarily late, so they never are.
+func endPointLagIsWithin(ep topo.EndPoint, maxLag time.Duration) bool {
+	seconds, err := strconv.ParseInt(ep.Health[topo.ReplicationLagSeconds], 10, 64)
+	if err != nil {
+		return false
+	}
+	return time.Duration(seconds)*time.Second <= maxLag
+}
+
+// filterLaggingServers returns the endpoints whose replication lag is
+// at most maxLag. Unlike filterUnhealthyServers, it may return none.
+func filterLaggingServers(endPoints *topo.EndPoints, maxLag time.Duration) *topo.EndPoints {
+	result := &topo.EndPoints{Entries: make([]topo.EndPoint, 0, len(endPoints.Entries))}
+	for _, ep := range endPoints.Entries {
+		if endPointLagIsWithin(ep, maxLag) {
+			result.Entries = append(result.Entries, ep)
+		}
+	}
+	return result
+}
+
+// lagBoundSrvTopoServer is a SrvTopoServer that only returns the
+// endpoints within a replication lag bound. It is used by the shard
+// connections of the sessions with a MaxReplicationLag.
+type lagBoundSrvTopoServer struct {
+	SrvTopoServer
+	maxLag time.Duration
+}
+
+// GetEndPoints is part of the SrvTopoServer interface.
+func (server *lagBoundSrvTopoServer) GetEndPoints(context context.Context, cell, keyspace, shard string, tabletType topo.TabletType) (*topo.EndPoints, error) {
+	endPoints, err := server.SrvTopoServer.GetEndPoints(context, cell, keyspace, shard, tabletType)
+	if err != nil {
+		return nil, err
+	}
+	return filterLaggingServers(endPoints, server.maxLag), nil
+}
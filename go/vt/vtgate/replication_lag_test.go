@@ -0,0 +1,145 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtgate
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vtgate/proto"
+	"golang.org/x/net/context"
+)
+
+func TestFilterLaggingServers(t *testing.T) {
+	endPoints := &topo.EndPoints{
+		Entries: []topo.EndPoint{
+			{Uid: 1, Health: map[string]string{topo.ReplicationLagSeconds: "0"}},
+			{Uid: 2, Health: map[string]string{topo.ReplicationLagSeconds: "10"}},
+			{Uid: 3, Health: map[string]string{topo.ReplicationLagSeconds: "20"}},
+			{Uid: 4},
+			{Uid: 5, Health: map[string]string{topo.ReplicationLag: topo.ReplicationLagHigh}},
+		},
+	}
+	table := []struct {
+		maxLag time.Duration
+		uids   []uint32
+	}{
+		{time.Hour, []uint32{1, 2, 3}},
+		{10 * time.Second, []uint32{1, 2}},
+		{9 * time.Second, []uint32{1}},
+		{time.Nanosecond, []uint32{1}},
+	}
+	for _, test := range table {
+		var uids []uint32
+		for _, ep := range filterLaggingServers(endPoints, test.maxLag).Entries {
+			uids = append(uids, ep.Uid)
+		}
+		if !reflect.DeepEqual(uids, test.uids) {
+			t.Errorf("filterLaggingServers(%v) = %v, want %v", test.maxLag, uids, test.uids)
+		}
+	}
+}
+
+func TestScatterConnReplicationLag(t *testing.T) {
+	s := createSandbox("TestScatterConnReplicationLag")
+	sbc := &sandboxConn{}
+	s.MapTestConn("0", sbc)
+	sbc.endPoint.Health = map[string]string{topo.ReplicationLagSeconds: "5"}
+	stc := NewScatterConn(new(sandboxTopo), "", "aa", 1*time.Millisecond, 3, 2*time.Millisecond, 1*time.Millisecond, 24*time.Hour)
+	execute := func(maxLag time.Duration) {
+		session := NewSafeSession(&proto.Session{MaxReplicationLag: int64(maxLag)})
+		if _, err := stc.Execute(context.Background(), "query", nil, "TestScatterConnReplicationLag", []string{"0"}, topo.TYPE_REPLICA, session, nil); err != nil {
+			t.Fatalf("Execute with a max lag of %v failed: %v", maxLag, err)
+		}
+	}
+	hasConnection := func(key string) bool {
+		stc.mu.Lock()
+		defer stc.mu.Unlock()
+		_, ok := stc.shardConns[key]
+		return ok
+	}
+
+	// the replica is within the bound
+	fallbacks := replicationLagFallbacks.Counts()[string(topo.TYPE_REPLICA)]
+	execute(10500 * time.Millisecond)
+	if !hasConnection("TestScatterConnReplicationLag.0.replica.10s") {
+		t.Errorf("no lag bound connection: %v", stc.shardConns)
+	}
+
+	// it is not, the query goes to the master
+	execute(time.Second)
+	if got := replicationLagFallbacks.Counts()[string(topo.TYPE_REPLICA)]; got != fallbacks+1 {
+		t.Errorf("want %v fallbacks, got %v", fallbacks+1, got)
+	}
+	if !hasConnection("TestScatterConnReplicationLag.0.master") {
+		t.Errorf("no master connection: %v", stc.shardConns)
+	}
+	if sbc.ExecCount.Get() != 2 {
+		t.Errorf("want 2, got %v", sbc.ExecCount.Get())
+	}
+}
+
+func TestReplicationLagBound(t *testing.T) {
+	defer initReplicationLagBounds(defaultReplicationLagBounds)
+	if err := initReplicationLagBounds("1m, 10s"); err != nil {
+		t.Fatalf("initReplicationLagBounds failed: %v", err)
+	}
+	table := []struct {
+		maxLag time.Duration
+		bound  time.Duration
+		ok     bool
+	}{
+		{time.Hour, time.Minute, true},
+		{time.Minute, time.Minute, true},
+		{59 * time.Second, 10 * time.Second, true},
+		{9 * time.Second, 0, false},
+	}
+	for _, test := range table {
+		bound, ok := replicationLagBound(test.maxLag)
+		if bound != test.bound || ok != test.ok {
+			t.Errorf("replicationLagBound(%v) = (%v, %v), want (%v, %v)", test.maxLag, bound, ok, test.bound, test.ok)
+		}
+	}
+
+	for _, value := range []string{"10", "500ms", "1.5s"} {
+		if err := initReplicationLagBounds(value); err == nil {
+			t.Errorf("initReplicationLagBounds(%q) worked, want an error", value)
+		}
+	}
+}
+
+func TestScatterConnReplicationLagChange(t *testing.T) {
+	s := createSandbox("TestScatterConnReplicationLagChange")
+	sbc0 := &sandboxConn{}
+	s.MapTestConn("0", sbc0)
+	sbc0.endPoint.Health = map[string]string{topo.ReplicationLagSeconds: "5"}
+	sbc1 := &sandboxConn{}
+	s.MapTestConn("0", sbc1)
+	sbc1.endPoint.NamedPortMap = map[string]int{"vt": 2}
+	sbc1.endPoint.Health = map[string]string{topo.ReplicationLagSeconds: "5"}
+	stc := NewScatterConn(new(sandboxTopo), "", "aa", 1*time.Millisecond, 3, 2*time.Millisecond, 1*time.Millisecond, 24*time.Hour)
+	execute := func() {
+		session := NewSafeSession(&proto.Session{MaxReplicationLag: int64(10 * time.Second)})
+		if _, err := stc.Execute(context.Background(), "query", nil, "TestScatterConnReplicationLagChange", []string{"0"}, topo.TYPE_REPLICA, session, nil); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+	}
+
+	execute()
+	used, other := sbc0, sbc1
+	if sbc1.ExecCount.Get() == 1 {
+		used, other = sbc1, sbc0
+	}
+
+	// the replica starts lagging, its connection isn't used anymore
+	used.endPoint.Health = map[string]string{topo.ReplicationLagSeconds: "20"}
+	execute()
+	execute()
+	if used.ExecCount.Get() != 1 || other.ExecCount.Get() != 2 {
+		t.Errorf("want 1 and 2 queries, got %v and %v", used.ExecCount.Get(), other.ExecCount.Get())
+	}
+}
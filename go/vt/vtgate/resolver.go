@@ -14,6 +14,7 @@ import (
 	"time"
 
 	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/vt/sqlparser"
 	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
 	"github.com/youtube/vitess/go/vt/tabletserver/tabletconn"
 	"github.com/youtube/vitess/go/vt/topo"
@@ -73,7 +74,7 @@ func (res *Resolver) ExecuteKeyspaceIds(ctx context.Context, query *proto.Keyspa
 			query.TabletType,
 			query.KeyspaceIds)
 	}
-	return res.Execute(ctx, query.Sql, query.BindVariables, query.Keyspace, query.TabletType, query.Session, mapToShards)
+	return res.Execute(ctx, query.Sql, query.BindVariables, query.Keyspace, query.TabletType, query.Session, nil, mapToShards)
 }
 
 // ExecuteKeyRanges executes a non-streaming query based on KeyRanges.
@@ -88,11 +89,12 @@ func (res *Resolver) ExecuteKeyRanges(ctx context.Context, query *proto.KeyRange
 			query.TabletType,
 			query.KeyRanges)
 	}
-	return res.Execute(ctx, query.Sql, query.BindVariables, query.Keyspace, query.TabletType, query.Session, mapToShards)
+	return res.Execute(ctx, query.Sql, query.BindVariables, query.Keyspace, query.TabletType, query.Session, nil, mapToShards)
 }
 
 // Execute executes a non-streaming query based on shards resolved by given func.
 // It retries query if new keyspace/shards are re-resolved after a retryable error.
+// The options may be nil.
 func (res *Resolver) Execute(
 	ctx context.Context,
 	sql string,
@@ -100,8 +102,17 @@ func (res *Resolver) Execute(
 	keyspace string,
 	tabletType topo.TabletType,
 	session *proto.Session,
+	options *tproto.ExecuteOptions,
 	mapToShards func(string) (string, []string, error),
 ) (*mproto.QueryResult, error) {
+	if err := sqlparser.ValidateBindVariables(bindVars); err != nil {
+		return nil, err
+	}
+	// Savepoints apply to the whole transaction, not to the shards
+	// of the query.
+	if savepoint := sqlparser.ParseSavepoint(sql); savepoint != nil {
+		return res.scatterConn.Savepoint(ctx, savepoint, NewSafeSession(session))
+	}
 	keyspace, shards, err := mapToShards(keyspace)
 	if err != nil {
 		return nil, err
@@ -114,7 +125,8 @@ func (res *Resolver) Execute(
 			keyspace,
 			shards,
 			tabletType,
-			NewSafeSession(session))
+			NewSafeSession(session),
+			options)
 		if connError, ok := err.(*ShardConnError); ok && connError.Code == tabletconn.ERR_RETRY {
 			resharding := false
 			newKeyspace, newShards, err := mapToShards(keyspace)
@@ -149,6 +161,9 @@ func (res *Resolver) ExecuteEntityIds(
 	ctx context.Context,
 	query *proto.EntityIdsQuery,
 ) (*mproto.QueryResult, error) {
+	if err := sqlparser.ValidateBindVariables(query.BindVariables); err != nil {
+		return nil, err
+	}
 	newKeyspace, shardIDMap, err := mapEntityIdsToShards(
 		ctx,
 		res.scatterConn.toposerv,
@@ -232,6 +247,11 @@ func (res *Resolver) ExecuteBatch(
 	session *proto.Session,
 	mapToShards func(string) (string, []string, error),
 ) (*tproto.QueryResultList, error) {
+	for _, query := range queries {
+		if err := sqlparser.ValidateBindVariables(query.BindVariables); err != nil {
+			return nil, err
+		}
+	}
 	keyspace, shards, err := mapToShards(keyspace)
 	if err != nil {
 		return nil, err
@@ -288,7 +308,7 @@ func (res *Resolver) StreamExecuteKeyspaceIds(ctx context.Context, query *proto.
 			query.TabletType,
 			query.KeyspaceIds)
 	}
-	return res.StreamExecute(ctx, query.Sql, query.BindVariables, query.Keyspace, query.TabletType, query.Session, mapToShards, sendReply)
+	return res.StreamExecute(ctx, query.Sql, query.BindVariables, query.Keyspace, query.TabletType, query.Session, nil, mapToShards, sendReply)
 }
 
 // StreamExecuteKeyRanges executes a streaming query on the specified KeyRanges.
@@ -307,13 +327,14 @@ func (res *Resolver) StreamExecuteKeyRanges(ctx context.Context, query *proto.Ke
 			query.TabletType,
 			query.KeyRanges)
 	}
-	return res.StreamExecute(ctx, query.Sql, query.BindVariables, query.Keyspace, query.TabletType, query.Session, mapToShards, sendReply)
+	return res.StreamExecute(ctx, query.Sql, query.BindVariables, query.Keyspace, query.TabletType, query.Session, nil, mapToShards, sendReply)
 }
 
 // StreamExecute executes a streaming query on shards resolved by given func.
 // This function currently temporarily enforces the restriction of executing on
 // one shard since it cannot merge-sort the results to guarantee ordering of
 // response which is needed for checkpointing.
+// The options may be nil.
 func (res *Resolver) StreamExecute(
 	ctx context.Context,
 	sql string,
@@ -321,9 +342,13 @@ func (res *Resolver) StreamExecute(
 	keyspace string,
 	tabletType topo.TabletType,
 	session *proto.Session,
+	options *tproto.ExecuteOptions,
 	mapToShards func(string) (string, []string, error),
 	sendReply func(*mproto.QueryResult) error,
 ) error {
+	if err := sqlparser.ValidateBindVariables(bindVars); err != nil {
+		return err
+	}
 	keyspace, shards, err := mapToShards(keyspace)
 	if err != nil {
 		return err
@@ -336,6 +361,7 @@ func (res *Resolver) StreamExecute(
 		shards,
 		tabletType,
 		NewSafeSession(session),
+		options,
 		sendReply)
 	return err
 }
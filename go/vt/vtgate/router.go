@@ -8,9 +8,12 @@ package vtgate
 
 import (
 	"fmt"
+	"sort"
+	"time"
 
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/vt/key"
+	"github.com/youtube/vitess/go/vt/sqlparser"
 	"github.com/youtube/vitess/go/vt/topo"
 	"github.com/youtube/vitess/go/vt/vtgate/planbuilder"
 	"github.com/youtube/vitess/go/vt/vtgate/proto"
@@ -29,6 +32,7 @@ type Router struct {
 	cell        string
 	planner     *Planner
 	scatterConn *ScatterConn
+	queryStats  *queryStats
 }
 
 type scatterParams struct {
@@ -55,17 +59,34 @@ func NewRouter(serv SrvTopoServer, cell string, schema *planbuilder.Schema, stat
 		cell:        cell,
 		planner:     NewPlanner(schema, 5000),
 		scatterConn: scatterConn,
+		queryStats:  newQueryStats(statsName),
 	}
 }
 
 // Execute routes a non-streaming query.
-func (rtr *Router) Execute(ctx context.Context, query *proto.Query) (*mproto.QueryResult, error) {
+func (rtr *Router) Execute(ctx context.Context, query *proto.Query) (qr *mproto.QueryResult, err error) {
+	if err := sqlparser.ValidateBindVariables(query.BindVariables); err != nil {
+		return nil, err
+	}
+	if savepoint := sqlparser.ParseSavepoint(query.Sql); savepoint != nil {
+		return rtr.scatterConn.Savepoint(ctx, savepoint, NewSafeSession(query.Session))
+	}
 	if query.BindVariables == nil {
 		query.BindVariables = make(map[string]interface{})
 	}
 	vcursor := newRequestContext(ctx, query, rtr)
 	plan := rtr.planner.GetPlan(string(query.Sql))
+	startTime := time.Now()
+	shardCount := 0
+	defer func() {
+		rtr.queryStats.record(plan, shardCount, startTime, err)
+	}()
 
+	switch plan.ID {
+	case planbuilder.UpdateEqual, planbuilder.DeleteEqual, planbuilder.InsertSharded:
+		// These go to a single shard.
+		shardCount = 1
+	}
 	switch plan.ID {
 	case planbuilder.UpdateEqual:
 		return rtr.execUpdateEqual(vcursor, plan)
@@ -75,7 +96,6 @@ func (rtr *Router) Execute(ctx context.Context, query *proto.Query) (*mproto.Que
 		return rtr.execInsertSharded(vcursor, plan)
 	}
 
-	var err error
 	var params *scatterParams
 	switch plan.ID {
 	case planbuilder.SelectUnsharded, planbuilder.UpdateUnsharded,
@@ -95,6 +115,7 @@ func (rtr *Router) Execute(ctx context.Context, query *proto.Query) (*mproto.Que
 	if err != nil {
 		return nil, err
 	}
+	shardCount = len(params.shardVars)
 	return rtr.scatterConn.ExecuteMulti(
 		ctx,
 		params.query,
@@ -106,14 +127,21 @@ func (rtr *Router) Execute(ctx context.Context, query *proto.Query) (*mproto.Que
 }
 
 // StreamExecute executes a streaming query.
-func (rtr *Router) StreamExecute(ctx context.Context, query *proto.Query, sendReply func(*mproto.QueryResult) error) error {
+func (rtr *Router) StreamExecute(ctx context.Context, query *proto.Query, sendReply func(*mproto.QueryResult) error) (err error) {
+	if err := sqlparser.ValidateBindVariables(query.BindVariables); err != nil {
+		return err
+	}
 	if query.BindVariables == nil {
 		query.BindVariables = make(map[string]interface{})
 	}
 	vcursor := newRequestContext(ctx, query, rtr)
 	plan := rtr.planner.GetPlan(string(query.Sql))
+	startTime := time.Now()
+	shardCount := 0
+	defer func() {
+		rtr.queryStats.record(plan, shardCount, startTime, err)
+	}()
 
-	var err error
 	var params *scatterParams
 	switch plan.ID {
 	case planbuilder.SelectUnsharded:
@@ -132,6 +160,7 @@ func (rtr *Router) StreamExecute(ctx context.Context, query *proto.Query, sendRe
 	if err != nil {
 		return err
 	}
+	shardCount = len(params.shardVars)
 	return rtr.scatterConn.StreamExecuteMulti(
 		ctx,
 		params.query,
@@ -143,6 +172,98 @@ func (rtr *Router) StreamExecute(ctx context.Context, query *proto.Query, sendRe
 	)
 }
 
+// Explain returns the routing of a query, and its plan on the first
+// shard it's routed to. The lookup vindexes are read to resolve the
+// shards, but the query is not executed.
+func (rtr *Router) Explain(ctx context.Context, query *proto.Query) (*proto.ExplainResult, error) {
+	if err := sqlparser.ValidateBindVariables(query.BindVariables); err != nil {
+		return nil, err
+	}
+	if query.BindVariables == nil {
+		query.BindVariables = make(map[string]interface{})
+	}
+	vcursor := newRequestContext(ctx, query, rtr)
+	plan := rtr.planner.GetPlan(string(query.Sql))
+	result := &proto.ExplainResult{
+		PlanType:  plan.ID.String(),
+		Reason:    plan.Reason,
+		Rewritten: plan.Rewritten,
+	}
+	if plan.Table != nil {
+		result.Keyspace = plan.Table.Keyspace.Name
+		result.Table = plan.Table.Name
+	}
+	if plan.ColVindex != nil {
+		result.Vindex = plan.ColVindex.Name
+	}
+
+	var params *scatterParams
+	var err error
+	switch plan.ID {
+	case planbuilder.SelectUnsharded, planbuilder.UpdateUnsharded,
+		planbuilder.DeleteUnsharded, planbuilder.InsertUnsharded:
+		params, err = rtr.paramsUnsharded(vcursor, plan)
+	case planbuilder.SelectEqual:
+		params, err = rtr.paramsSelectEqual(vcursor, plan)
+	case planbuilder.SelectIN:
+		params, err = rtr.paramsSelectIN(vcursor, plan)
+	case planbuilder.SelectKeyrange:
+		params, err = rtr.paramsSelectKeyrange(vcursor, plan)
+	case planbuilder.SelectScatter:
+		params, err = rtr.paramsSelectScatter(vcursor, plan)
+	case planbuilder.UpdateEqual, planbuilder.DeleteEqual:
+		params, err = rtr.paramsDMLEqual(vcursor, plan)
+	case planbuilder.InsertSharded:
+		return result, nil
+	default:
+		return nil, fmt.Errorf("cannot route query: %s: %s", query.Sql, plan.Reason)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if params == nil {
+		// The DML matches no keyspace id.
+		return result, nil
+	}
+	result.Keyspace = params.ks
+	result.Rewritten = params.query
+	for shard := range params.shardVars {
+		result.Shards = append(result.Shards, shard)
+	}
+	sort.Strings(result.Shards)
+	if len(result.Shards) == 0 {
+		return result, nil
+	}
+	shard := result.Shards[0]
+	result.TabletPlan, err = rtr.scatterConn.Explain(ctx, params.query, params.shardVars[shard], params.ks, shard, query.TabletType)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// paramsDMLEqual returns the routing of execUpdateEqual and
+// execDeleteEqual, or nil if no keyspace id matches.
+func (rtr *Router) paramsDMLEqual(vcursor *requestContext, plan *planbuilder.Plan) (*scatterParams, error) {
+	keys, err := rtr.resolveKeys([]interface{}{plan.Values}, vcursor.query.BindVariables)
+	if err != nil {
+		return nil, fmt.Errorf("paramsDMLEqual: %v", err)
+	}
+	ks, shard, ksid, err := rtr.resolveSingleShard(vcursor, keys[0], plan)
+	if err != nil {
+		return nil, fmt.Errorf("paramsDMLEqual: %v", err)
+	}
+	if ksid == key.MinKey {
+		return nil, nil
+	}
+	bindVars := make(map[string]interface{}, len(vcursor.query.BindVariables)+1)
+	for k, v := range vcursor.query.BindVariables {
+		bindVars[k] = v
+	}
+	bindVars[ksidName] = string(ksid)
+	return newScatterParams(plan.Rewritten+fmt.Sprintf(dmlPostfix, ksid), ks, bindVars, []string{shard}), nil
+}
+
 func (rtr *Router) paramsUnsharded(vcursor *requestContext, plan *planbuilder.Plan) (*scatterParams, error) {
 	ks, allShards, err := getKeyspaceShards(vcursor.ctx, rtr.serv, rtr.cell, plan.Table.Keyspace.Name, vcursor.query.TabletType)
 	if err != nil {
@@ -250,7 +371,8 @@ func (rtr *Router) execUpdateEqual(vcursor *requestContext, plan *planbuilder.Pl
 		ks,
 		[]string{shard},
 		vcursor.query.TabletType,
-		NewSafeSession(vcursor.query.Session))
+		NewSafeSession(vcursor.query.Session),
+		nil)
 }
 
 func (rtr *Router) execDeleteEqual(vcursor *requestContext, plan *planbuilder.Plan) (*mproto.QueryResult, error) {
@@ -280,10 +402,19 @@ func (rtr *Router) execDeleteEqual(vcursor *requestContext, plan *planbuilder.Pl
 		ks,
 		[]string{shard},
 		vcursor.query.TabletType,
-		NewSafeSession(vcursor.query.Session))
+		NewSafeSession(vcursor.query.Session),
+		nil)
 }
 
 func (rtr *Router) execInsertSharded(vcursor *requestContext, plan *planbuilder.Plan) (*mproto.QueryResult, error) {
+	var insertid int64
+	if plan.Generate != nil {
+		var err error
+		insertid, err = rtr.handleGenerate(vcursor, plan.Generate)
+		if err != nil {
+			return nil, fmt.Errorf("execInsertSharded: %v", err)
+		}
+	}
 	input := plan.Values.([]interface{})
 	keys, err := rtr.resolveKeys(input, vcursor.query.BindVariables)
 	if err != nil {
@@ -293,6 +424,12 @@ func (rtr *Router) execInsertSharded(vcursor *requestContext, plan *planbuilder.
 	if err != nil {
 		return nil, fmt.Errorf("execInsertSharded: %v", err)
 	}
+	if insertid != 0 {
+		if generated != 0 {
+			return nil, fmt.Errorf("sequence and vindex generated a value each for insert")
+		}
+		generated = insertid
+	}
 	ks, shard, err := rtr.getRouting(vcursor.ctx, plan.Table.Keyspace.Name, vcursor.query.TabletType, ksid)
 	if err != nil {
 		return nil, fmt.Errorf("execInsertSharded: %v", err)
@@ -318,7 +455,8 @@ func (rtr *Router) execInsertSharded(vcursor *requestContext, plan *planbuilder.
 		ks,
 		[]string{shard},
 		vcursor.query.TabletType,
-		NewSafeSession(vcursor.query.Session))
+		NewSafeSession(vcursor.query.Session),
+		nil)
 	if err != nil {
 		return nil, fmt.Errorf("execInsertSharded: %v", err)
 	}
@@ -331,6 +469,50 @@ func (rtr *Router) execInsertSharded(vcursor *requestContext, plan *planbuilder.
 	return result, nil
 }
 
+// handleGenerate sets the bind variable of the auto-increment column.
+// If the insert didn't supply a value, the next value of the sequence
+// is used, and returned as insertid.
+func (rtr *Router) handleGenerate(vcursor *requestContext, gen *planbuilder.Generate) (insertid int64, err error) {
+	keys, err := rtr.resolveKeys([]interface{}{gen.Value}, vcursor.query.BindVariables)
+	if err != nil {
+		return 0, err
+	}
+	if keys[0] != nil {
+		vcursor.query.BindVariables[planbuilder.SeqVarName] = keys[0]
+		return 0, nil
+	}
+	// Sequences are served by the master, outside of the
+	// transaction of the insert.
+	ks, allShards, err := getKeyspaceShards(vcursor.ctx, rtr.serv, rtr.cell, gen.Sequence.Keyspace.Name, topo.TYPE_MASTER)
+	if err != nil {
+		return 0, err
+	}
+	if len(allShards) != 1 {
+		return 0, fmt.Errorf("unsharded keyspace %s has multiple shards", ks)
+	}
+	result, err := rtr.scatterConn.Execute(
+		vcursor.ctx,
+		fmt.Sprintf("select nextval(1) from %s", gen.Sequence.Name),
+		nil,
+		ks,
+		[]string{allShards[0].Name},
+		topo.TYPE_MASTER,
+		NewSafeSession(nil),
+		nil)
+	if err != nil {
+		return 0, err
+	}
+	if len(result.Rows) != 1 || len(result.Rows[0]) != 1 {
+		return 0, fmt.Errorf("unexpected result from sequence %s: %+v", gen.Sequence.Name, result.Rows)
+	}
+	insertid, err = result.Rows[0][0].ParseInt64()
+	if err != nil {
+		return 0, fmt.Errorf("invalid value from sequence %s: %v", gen.Sequence.Name, err)
+	}
+	vcursor.query.BindVariables[planbuilder.SeqVarName] = insertid
+	return insertid, nil
+}
+
 func (rtr *Router) resolveKeys(vals []interface{}, bindVars map[string]interface{}) (keys []interface{}, err error) {
 	keys = make([]interface{}, 0, len(vals))
 	for _, val := range vals {
@@ -421,7 +603,8 @@ func (rtr *Router) deleteVindexEntries(vcursor *requestContext, plan *planbuilde
 		ks,
 		[]string{shard},
 		vcursor.query.TabletType,
-		NewSafeSession(vcursor.query.Session))
+		NewSafeSession(vcursor.query.Session),
+		nil)
 	if err != nil {
 		return err
 	}
@@ -120,8 +120,8 @@ func TestDeleteEqual(t *testing.T) {
 
 	sbc.setResults([]*mproto.QueryResult{&mproto.QueryResult{
 		Fields: []mproto.Field{
-			{"id", 3},
-			{"name", 253},
+			{"id", 3, 0},
+			{"name", 253, 0},
 		},
 		RowsAffected: 1,
 		InsertId:     0,
@@ -257,8 +257,8 @@ func TestDeleteVindexFail(t *testing.T) {
 
 	sbc.setResults([]*mproto.QueryResult{&mproto.QueryResult{
 		Fields: []mproto.Field{
-			{"id", 3},
-			{"name", 253},
+			{"id", 3, 0},
+			{"name", 253, 0},
 		},
 		RowsAffected: 1,
 		InsertId:     0,
@@ -403,6 +403,87 @@ func TestInsertGenerator(t *testing.T) {
 	}
 }
 
+func TestInsertSequence(t *testing.T) {
+	router, sbc1, sbc2, sbclookup := createRouterEnv()
+
+	sbclookup.setResults([]*mproto.QueryResult{&mproto.QueryResult{
+		Fields: []mproto.Field{{"nextval", 8, 0}},
+		Rows: [][]sqltypes.Value{{
+			{sqltypes.Numeric("1")},
+		}},
+		RowsAffected: 1,
+	}})
+	result, err := routerExec(router, "insert into seq_table(v) values (2)", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	wantQueries := []tproto.BoundQuery{{
+		Sql:           "select nextval(1) from user_seq",
+		BindVariables: map[string]interface{}{},
+	}}
+	if !reflect.DeepEqual(sbclookup.Queries, wantQueries) {
+		t.Errorf("sbclookup.Queries: %+v, want %+v\n", sbclookup.Queries, wantQueries)
+	}
+	wantQueries = []tproto.BoundQuery{{
+		Sql: "insert into seq_table(v, id) values (2, :_id) /* _routing keyspace_id:166b40b44aba4bd6 */",
+		BindVariables: map[string]interface{}{
+			"keyspace_id": "\x16k@\xb4J\xbaK\xd6",
+			"__seq":       int64(1),
+			"_id":         int64(1),
+		},
+	}}
+	if !reflect.DeepEqual(sbc1.Queries, wantQueries) {
+		t.Errorf("sbc1.Queries: %+v, want %+v\n", sbc1.Queries, wantQueries)
+	}
+	if sbc2.Queries != nil {
+		t.Errorf("sbc2.Queries: %+v, want nil\n", sbc2.Queries)
+	}
+	wantResult := *singleRowResult
+	wantResult.InsertId = 1
+	if !reflect.DeepEqual(result, &wantResult) {
+		t.Errorf("result: %+v, want %+v", result, &wantResult)
+	}
+
+	// A supplied value must not consume the sequence.
+	router, sbc1, _, sbclookup = createRouterEnv()
+	_, err = routerExec(router, "insert into seq_table(id, v) values (1, 2)", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if sbclookup.Queries != nil {
+		t.Errorf("sbclookup.Queries: %+v, want nil\n", sbclookup.Queries)
+	}
+	wantQueries = []tproto.BoundQuery{{
+		Sql: "insert into seq_table(id, v) values (:_id, 2) /* _routing keyspace_id:166b40b44aba4bd6 */",
+		BindVariables: map[string]interface{}{
+			"keyspace_id": "\x16k@\xb4J\xbaK\xd6",
+			"__seq":       int64(1),
+			"_id":         int64(1),
+		},
+	}}
+	if !reflect.DeepEqual(sbc1.Queries, wantQueries) {
+		t.Errorf("sbc1.Queries: %+v, want %+v\n", sbc1.Queries, wantQueries)
+	}
+}
+
+func TestInsertSequenceFail(t *testing.T) {
+	router, _, _, sbclookup := createRouterEnv()
+
+	sbclookup.mustFailServer = 1
+	_, err := routerExec(router, "insert into seq_table(v) values (2)", nil)
+	want := "execInsertSharded: "
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("routerExec: %v, want prefix %s", err, want)
+	}
+
+	sbclookup.setResults([]*mproto.QueryResult{&mproto.QueryResult{}})
+	_, err = routerExec(router, "insert into seq_table(v) values (2)", nil)
+	want = "execInsertSharded: "
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("routerExec: %v, want prefix %s", err, want)
+	}
+}
+
 func TestInsertLookupOwned(t *testing.T) {
 	router, sbc, _, sbclookup := createRouterEnv()
 
@@ -0,0 +1,116 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtgate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vtgate/proto"
+	"golang.org/x/net/context"
+)
+
+func routerExplain(router *Router, sql string) (*proto.ExplainResult, error) {
+	return router.Explain(context.Background(), &proto.Query{
+		Sql:        sql,
+		TabletType: topo.TYPE_MASTER,
+	})
+}
+
+func TestExplainSelect(t *testing.T) {
+	router, sbc1, _, _ := createRouterEnv()
+
+	result, err := routerExplain(router, "select * from user where id = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &proto.ExplainResult{
+		PlanType:  "SelectEqual",
+		Keyspace:  "TestRouter",
+		Table:     "user",
+		Vindex:    "user_index",
+		Rewritten: "select * from user where id = 1",
+		Shards:    []string{"-20"},
+		TabletPlan: &tproto.ExplainResult{
+			PlanType:  "PASS_SELECT",
+			FullQuery: "select * from user where id = 1",
+		},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Explain: %+v, want %+v", result, want)
+	}
+	if sbc1.ExecCount.Get() != 0 {
+		t.Errorf("sbc1.ExecCount: %v, want 0", sbc1.ExecCount.Get())
+	}
+
+	result, err = routerExplain(router, "select * from user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantShards := []string{"-20", "20-40", "40-60", "60-80", "80-a0", "a0-c0", "c0-e0", "e0-"}
+	if result.PlanType != "SelectScatter" || !reflect.DeepEqual(result.Shards, wantShards) {
+		t.Errorf("Explain: %+v, want a scatter to %v", result, wantShards)
+	}
+
+	result, err = routerExplain(router, "select * from music_user_map where id = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PlanType != "SelectUnsharded" || result.Keyspace != KsTestUnsharded || !reflect.DeepEqual(result.Shards, []string{"0"}) {
+		t.Errorf("Explain: %+v, want an unsharded select", result)
+	}
+	if result.Rewritten != "select * from music_user_map where id = 1" {
+		t.Errorf("Rewritten: %q, want the original query", result.Rewritten)
+	}
+
+	_, err = routerExplain(router, "select * from user join user_extra")
+	if err == nil || !strings.HasPrefix(err.Error(), "cannot route query") {
+		t.Errorf("Explain: %v, want cannot route query", err)
+	}
+}
+
+func TestExplainDML(t *testing.T) {
+	router, sbc1, _, _ := createRouterEnv()
+
+	result, err := routerExplain(router, "update user set a = 2 where id = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSql := "update user set a = 2 where id = 1 /* _routing keyspace_id:166b40b44aba4bd6 */"
+	want := &proto.ExplainResult{
+		PlanType:  "UpdateEqual",
+		Keyspace:  "TestRouter",
+		Table:     "user",
+		Vindex:    "user_index",
+		Rewritten: wantSql,
+		Shards:    []string{"-20"},
+		TabletPlan: &tproto.ExplainResult{
+			PlanType:  "PASS_SELECT",
+			FullQuery: wantSql,
+		},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Explain: %+v, want %+v", result, want)
+	}
+	wantVars := map[string]interface{}{"keyspace_id": "\x16k@\xb4J\xbaK\xd6"}
+	if !reflect.DeepEqual(sbc1.Queries[0].BindVariables, wantVars) {
+		t.Errorf("bind variables: %+v, want %+v", sbc1.Queries[0].BindVariables, wantVars)
+	}
+
+	// The shard of an insert depends on the vindex values it creates.
+	result, err = routerExplain(router, "insert into user(id, v, name) values (1, 2, 'myname')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PlanType != "InsertSharded" || result.Shards != nil || result.TabletPlan != nil {
+		t.Errorf("Explain: %+v, want an insert without shards", result)
+	}
+	if sbc1.ExecCount.Get() != 0 {
+		t.Errorf("sbc1.ExecCount: %v, want 0", sbc1.ExecCount.Get())
+	}
+}
@@ -158,6 +158,18 @@ var routerSchema = createTestSchema(`
               "Name": "keyspace_id"
             }
           ]
+        },
+        "seq_table": {
+          "ColVindexes": [
+            {
+              "Col": "id",
+              "Name": "idx_noauto"
+            }
+          ],
+          "Autoinc": {
+            "Col": "id",
+            "Sequence": "user_seq"
+          }
         }
       },
       "Tables": {
@@ -168,7 +180,8 @@ var routerSchema = createTestSchema(`
         "music_extra_reversed": "music_extra_reversed",
         "multi_autoinc_table": "multi_autoinc_table",
         "noauto_table": "noauto_table",
-        "ksid_table": "ksid_table"
+        "ksid_table": "ksid_table",
+        "seq_table": "seq_table"
       }
     },
     "TestBadSharding": {
@@ -184,7 +197,8 @@ var routerSchema = createTestSchema(`
         "music_user_map": "",
         "name_user_map": "",
         "idx1": "",
-        "idx2": ""
+        "idx2": "",
+        "user_seq": "sequence"
       }
     }
   }
@@ -615,3 +615,29 @@ func TestSelectScatterFail(t *testing.T) {
 		t.Errorf("routerExec: %v, want %v", err, want)
 	}
 }
+
+func TestSelectBadBindVar(t *testing.T) {
+	router, sbc1, _, _ := createRouterEnv()
+
+	_, err := routerExec(router, "select * from user where id = :id", map[string]interface{}{
+		"id": true,
+	})
+	want := "bind variable id: unsupported bind variable type bool: true"
+	if err == nil || err.Error() != want {
+		t.Errorf("routerExec: %v, want %v", err, want)
+	}
+	if sbc1.Queries != nil {
+		t.Errorf("sbc1.Queries: %+v, want nil\n", sbc1.Queries)
+	}
+
+	q := proto.Query{
+		Sql:           "select * from user where id in ::ids",
+		BindVariables: map[string]interface{}{"ids": []interface{}{}},
+		TabletType:    topo.TYPE_MASTER,
+	}
+	_, err = routerStream(router, &q)
+	want = "bind variable ids: empty list"
+	if err == nil || err.Error() != want {
+		t.Errorf("routerStream: %v, want %v", err, want)
+	}
+}
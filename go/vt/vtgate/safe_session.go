@@ -5,8 +5,12 @@
 package vtgate
 
 import (
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/youtube/vitess/go/vt/sqlparser"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
 	"github.com/youtube/vitess/go/vt/topo"
 	"github.com/youtube/vitess/go/vt/vtgate/proto"
 )
@@ -29,6 +33,93 @@ func (session *SafeSession) InTransaction() bool {
 	return session.Session.InTransaction
 }
 
+// MaxReplicationLag returns the replication lag the session tolerates
+// for the replica and rdonly queries, 0 if any lag is fine.
+func (session *SafeSession) MaxReplicationLag() time.Duration {
+	if session == nil || session.Session == nil {
+		return 0
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return time.Duration(session.Session.MaxReplicationLag)
+}
+
+// TransactionOptions returns the options of the transactions to begin
+// on the shards, nil if there are none.
+func (session *SafeSession) TransactionOptions() *tproto.TransactionOptions {
+	if session == nil || session.Session == nil {
+		return nil
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.Session.TransactionOptions
+}
+
+// Charset returns the character set of the client, "" if its queries
+// don't need to be converted.
+func (session *SafeSession) Charset() string {
+	if session == nil || session.Session == nil {
+		return ""
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.Session.Charset
+}
+
+// Savepoints returns a copy of the savepoint names of the transaction,
+// oldest first.
+func (session *SafeSession) Savepoints() []string {
+	if session == nil || session.Session == nil {
+		return nil
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return append([]string(nil), session.Session.Savepoints...)
+}
+
+// HasSavepoint returns true if the transaction has a savepoint of
+// that name. Like in MySQL, the names are case insensitive.
+func (session *SafeSession) HasSavepoint(name string) bool {
+	return savepointIndex(session.Savepoints(), name) != -1
+}
+
+// UpdateSavepoints records the effect of a savepoint statement that
+// was run on all the shards of the transaction.
+func (session *SafeSession) UpdateSavepoints(action, name string) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	savepoints := session.Session.Savepoints
+	i := savepointIndex(savepoints, name)
+	switch action {
+	case sqlparser.AST_SAVEPOINT:
+		// an existing savepoint of the same name is replaced
+		if i != -1 {
+			savepoints = append(savepoints[:i], savepoints[i+1:]...)
+		}
+		savepoints = append(savepoints, name)
+	case sqlparser.AST_ROLLBACK_TO:
+		// the later savepoints are dropped, this one is kept
+		if i != -1 {
+			savepoints = savepoints[:i+1]
+		}
+	case sqlparser.AST_RELEASE:
+		// this savepoint and the later ones are dropped
+		if i != -1 {
+			savepoints = savepoints[:i]
+		}
+	}
+	session.Session.Savepoints = savepoints
+}
+
+func savepointIndex(savepoints []string, name string) int {
+	for i, savepoint := range savepoints {
+		if strings.EqualFold(savepoint, name) {
+			return i
+		}
+	}
+	return -1
+}
+
 func (session *SafeSession) Find(keyspace, shard string, tabletType topo.TabletType) int64 {
 	if session == nil {
 		return 0
@@ -54,4 +145,5 @@ func (session *SafeSession) Reset() {
 	defer session.mu.Unlock()
 	session.Session.InTransaction = false
 	session.ShardSessions = nil
+	session.Session.Savepoints = nil
 }
@@ -17,6 +17,7 @@ import (
 	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
 	"github.com/youtube/vitess/go/vt/tabletserver/tabletconn"
 	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vterrors"
 	"golang.org/x/net/context"
 )
 
@@ -92,6 +93,9 @@ type sandbox struct {
 	// ShardSpec specifies the sharded keyranges
 	ShardSpec string
 
+	// ShardingScheme specifies the sharding scheme of the keyspace
+	ShardingScheme string
+
 	// SrvKeyspaceCallback specifies the callback function in GetSrvKeyspace
 	SrvKeyspaceCallback func()
 
@@ -111,6 +115,7 @@ func (s *sandbox) Reset() {
 	s.DialMustTimeout = 0
 	s.KeyspaceServedFrom = ""
 	s.ShardSpec = DefaultShardSpec
+	s.ShardingScheme = ""
 	s.SrvKeyspaceCallback = nil
 }
 
@@ -261,7 +266,12 @@ func (sct *sandboxTopo) GetSrvKeyspace(context context.Context, cell, keyspace s
 		return createUnshardedKeyspace()
 	}
 
-	return createShardedSrvKeyspace(sand.ShardSpec, sand.KeyspaceServedFrom)
+	srvKeyspace, err := createShardedSrvKeyspace(sand.ShardSpec, sand.KeyspaceServedFrom)
+	if err != nil {
+		return nil, err
+	}
+	srvKeyspace.ShardingScheme = sand.ShardingScheme
+	return srvKeyspace, nil
 }
 
 func (sct *sandboxTopo) GetSrvShard(context context.Context, cell, keyspace, shard string) (*topo.SrvShard, error) {
@@ -319,6 +329,10 @@ type sandboxConn struct {
 	mustFailNotTx  int
 	mustDelay      time.Duration
 
+	// mustFailNotMaster errors redirect to masterHint.
+	mustFailNotMaster int
+	masterHint        *topo.EndPoint
+
 	// A callback to tweak the behavior on each conn call
 	onConnUse func(*sandboxConn)
 
@@ -333,6 +347,13 @@ type sandboxConn struct {
 	// Queries stores the requests received.
 	Queries []tproto.BoundQuery
 
+	// BeginOptions stores the options of the last Begin.
+	BeginOptions *tproto.TransactionOptions
+
+	// ExecuteOptions stores the options of the last Execute or
+	// StreamExecute.
+	ExecuteOptions *tproto.ExecuteOptions
+
 	// results specifies the results to be returned.
 	// They're consumed as results are returned. If there are
 	// no results left, singleRowResult is returned.
@@ -348,11 +369,11 @@ func (sbc *sandboxConn) getError() error {
 	}
 	if sbc.mustFailRetry > 0 {
 		sbc.mustFailRetry--
-		return &tabletconn.ServerError{Code: tabletconn.ERR_RETRY, Err: "retry: err"}
+		return &tabletconn.ServerError{Code: tabletconn.ERR_RETRY, Err: "retry: err", ServerCode: vterrors.QueryNotServed}
 	}
 	if sbc.mustFailFatal > 0 {
 		sbc.mustFailFatal--
-		return &tabletconn.ServerError{Code: tabletconn.ERR_FATAL, Err: "fatal: err"}
+		return &tabletconn.ServerError{Code: tabletconn.ERR_FATAL, Err: "fatal: err", ServerCode: vterrors.InternalError}
 	}
 	if sbc.mustFailServer > 0 {
 		sbc.mustFailServer--
@@ -364,11 +385,15 @@ func (sbc *sandboxConn) getError() error {
 	}
 	if sbc.mustFailTxPool > 0 {
 		sbc.mustFailTxPool--
-		return &tabletconn.ServerError{Code: tabletconn.ERR_TX_POOL_FULL, Err: "tx_pool_full: err"}
+		return &tabletconn.ServerError{Code: tabletconn.ERR_TX_POOL_FULL, Err: "tx_pool_full: err", ServerCode: vterrors.ResourceExhausted}
 	}
 	if sbc.mustFailNotTx > 0 {
 		sbc.mustFailNotTx--
-		return &tabletconn.ServerError{Code: tabletconn.ERR_NOT_IN_TX, Err: "not_in_tx: err"}
+		return &tabletconn.ServerError{Code: tabletconn.ERR_NOT_IN_TX, Err: "not_in_tx: err", ServerCode: vterrors.NotInTx}
+	}
+	if sbc.mustFailNotMaster > 0 {
+		sbc.mustFailNotMaster--
+		return &tabletconn.ServerError{Code: tabletconn.ERR_RETRY, Err: "retry: read-only", ServerCode: vterrors.NotMaster, MasterHint: sbc.masterHint}
 	}
 	return nil
 }
@@ -377,8 +402,9 @@ func (sbc *sandboxConn) setResults(r []*mproto.QueryResult) {
 	sbc.results = r
 }
 
-func (sbc *sandboxConn) Execute(context context.Context, query string, bindVars map[string]interface{}, transactionID int64) (*mproto.QueryResult, error) {
+func (sbc *sandboxConn) Execute(context context.Context, query string, bindVars map[string]interface{}, transactionID int64, options *tproto.ExecuteOptions) (*mproto.QueryResult, error) {
 	sbc.ExecCount.Add(1)
+	sbc.ExecuteOptions = options
 	bv := make(map[string]interface{})
 	for k, v := range bindVars {
 		bv[k] = v
@@ -412,8 +438,9 @@ func (sbc *sandboxConn) ExecuteBatch(context context.Context, queries []tproto.B
 	return qrl, nil
 }
 
-func (sbc *sandboxConn) StreamExecute(context context.Context, query string, bindVars map[string]interface{}, transactionID int64) (<-chan *mproto.QueryResult, tabletconn.ErrFunc, error) {
+func (sbc *sandboxConn) StreamExecute(context context.Context, query string, bindVars map[string]interface{}, transactionID int64, options *tproto.ExecuteOptions) (<-chan *mproto.QueryResult, tabletconn.ErrFunc, error) {
 	sbc.ExecCount.Add(1)
+	sbc.ExecuteOptions = options
 	bv := make(map[string]interface{})
 	for k, v := range bindVars {
 		bv[k] = v
@@ -432,9 +459,10 @@ func (sbc *sandboxConn) StreamExecute(context context.Context, query string, bin
 	return ch, func() error { return err }, err
 }
 
-func (sbc *sandboxConn) Begin(context context.Context) (int64, error) {
+func (sbc *sandboxConn) Begin(context context.Context, options *tproto.TransactionOptions) (int64, error) {
 	sbc.ExecCount.Add(1)
 	sbc.BeginCount.Add(1)
+	sbc.BeginOptions = options
 	if sbc.mustDelay != 0 {
 		time.Sleep(sbc.mustDelay)
 	}
@@ -482,6 +510,42 @@ func (sbc *sandboxConn) SplitQuery(context context.Context, query tproto.BoundQu
 	return splits, nil
 }
 
+func (sbc *sandboxConn) Prepare(context context.Context, query string) (int64, error) {
+	return 0, fmt.Errorf("not implemented in test")
+}
+
+func (sbc *sandboxConn) ExecutePrepared(context context.Context, statementID int64, bindVars map[string]interface{}, transactionID int64) (*mproto.QueryResult, error) {
+	return nil, fmt.Errorf("not implemented in test")
+}
+
+func (sbc *sandboxConn) ClosePrepared(context context.Context, statementID int64) error {
+	return fmt.Errorf("not implemented in test")
+}
+
+// Explain records the query, and returns a pass through plan.
+func (sbc *sandboxConn) Explain(context context.Context, query string, bindVars map[string]interface{}) (*tproto.ExplainResult, error) {
+	sbc.Queries = append(sbc.Queries, tproto.BoundQuery{
+		Sql:           query,
+		BindVariables: bindVars,
+	})
+	if err := sbc.getError(); err != nil {
+		return nil, err
+	}
+	return &tproto.ExplainResult{PlanType: "PASS_SELECT", FullQuery: query}, nil
+}
+
+func (sbc *sandboxConn) MessageStream(context context.Context, name string) (<-chan *mproto.QueryResult, tabletconn.ErrFunc, error) {
+	return nil, nil, fmt.Errorf("not implemented in test")
+}
+
+func (sbc *sandboxConn) MessageAck(context context.Context, name string, ids []interface{}) (int64, error) {
+	return 0, fmt.Errorf("not implemented in test")
+}
+
+func (sbc *sandboxConn) ExportTable(context context.Context, req *tproto.ExportTableRequest) (<-chan *tproto.ExportChunk, tabletconn.ErrFunc, error) {
+	return nil, nil, fmt.Errorf("not implemented in test")
+}
+
 // Close does not change ExecCount
 func (sbc *sandboxConn) Close() {
 	sbc.CloseCount.Add(1)
@@ -506,8 +570,8 @@ func (sbc *sandboxConn) getNextResult() *mproto.QueryResult {
 
 var singleRowResult = &mproto.QueryResult{
 	Fields: []mproto.Field{
-		{"id", 3},
-		{"value", 253}},
+		{"id", 3, 0},
+		{"value", 253, 0}},
 	RowsAffected: 1,
 	InsertId:     0,
 	Rows: [][]sqltypes.Value{{
@@ -13,11 +13,15 @@ import (
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/stats"
 	"github.com/youtube/vitess/go/sync2"
+	"github.com/youtube/vitess/go/trace"
 	"github.com/youtube/vitess/go/vt/concurrency"
 	kproto "github.com/youtube/vitess/go/vt/key"
+	"github.com/youtube/vitess/go/vt/servenv"
+	"github.com/youtube/vitess/go/vt/sqlparser"
 	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
 	"github.com/youtube/vitess/go/vt/tabletserver/tabletconn"
 	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vterrors"
 	"github.com/youtube/vitess/go/vt/vtgate/proto"
 	"golang.org/x/net/context"
 )
@@ -44,10 +48,10 @@ type ScatterConn struct {
 
 // shardActionFunc defines the contract for a shard action. Every such function
 // executes the necessary action on conn, sends the results to sResults, and
-// return an error if any.
+// return an error if any. ctx has the span of the shard call.
 // multiGo is capable of executing multiple shardActionFunc actions in parallel
 // and consolidating the results and errors for the caller.
-type shardActionFunc func(conn *ShardConn, transactionId int64, sResults chan<- interface{}) error
+type shardActionFunc func(ctx context.Context, conn *ShardConn, transactionId int64, sResults chan<- interface{}) error
 
 // NewScatterConn creates a new ScatterConn. All input parameters are passed through
 // for creating the appropriate ShardConn.
@@ -119,24 +123,33 @@ func (stc *ScatterConn) InitializeConnections(ctx context.Context) error {
 }
 
 // Execute executes a non-streaming query on the specified shards.
+// The options may be nil.
 func (stc *ScatterConn) Execute(
-	context context.Context,
+	ctx context.Context,
 	query string,
 	bindVars map[string]interface{},
 	keyspace string,
 	shards []string,
 	tabletType topo.TabletType,
 	session *SafeSession,
+	options *tproto.ExecuteOptions,
 ) (*mproto.QueryResult, error) {
+	cc, err := newCharsetConverter(session, keyspace)
+	if err != nil {
+		return nil, err
+	}
+	if query, bindVars, err = cc.convertQuery(query, bindVars); err != nil {
+		return nil, err
+	}
 	results, allErrors := stc.multiGo(
-		context,
+		ctx,
 		"Execute",
 		keyspace,
 		shards,
 		tabletType,
 		session,
-		func(sdc *ShardConn, transactionId int64, sResults chan<- interface{}) error {
-			innerqr, err := sdc.Execute(context, query, bindVars, transactionId)
+		func(ctx context.Context, sdc *ShardConn, transactionId int64, sResults chan<- interface{}) error {
+			innerqr, err := sdc.Execute(ctx, query, bindVars, transactionId, options)
 			if err != nil {
 				return err
 			}
@@ -152,6 +165,7 @@ func (stc *ScatterConn) Execute(
 	if allErrors.HasErrors() {
 		return nil, allErrors.AggrError(stc.aggregateErrors)
 	}
+	cc.convertResult(qr)
 	return qr, nil
 }
 
@@ -159,22 +173,32 @@ func (stc *ScatterConn) Execute(
 // but each shard gets its own bindVars. If len(shards) is not equal to
 // len(bindVars), the function panics.
 func (stc *ScatterConn) ExecuteMulti(
-	context context.Context,
+	ctx context.Context,
 	query string,
 	keyspace string,
 	shardVars map[string]map[string]interface{},
 	tabletType topo.TabletType,
 	session *SafeSession,
 ) (*mproto.QueryResult, error) {
+	cc, err := newCharsetConverter(session, keyspace)
+	if err != nil {
+		return nil, err
+	}
+	if _, shardVars, err = cc.convertShardQueries(nil, shardVars); err != nil {
+		return nil, err
+	}
+	if query, _, err = cc.convertQuery(query, nil); err != nil {
+		return nil, err
+	}
 	results, allErrors := stc.multiGo(
-		context,
+		ctx,
 		"Execute",
 		keyspace,
 		getShards(shardVars),
 		tabletType,
 		session,
-		func(sdc *ShardConn, transactionId int64, sResults chan<- interface{}) error {
-			innerqr, err := sdc.Execute(context, query, shardVars[sdc.shard], transactionId)
+		func(ctx context.Context, sdc *ShardConn, transactionId int64, sResults chan<- interface{}) error {
+			innerqr, err := sdc.Execute(ctx, query, shardVars[sdc.shard], transactionId, nil)
 			if err != nil {
 				return err
 			}
@@ -190,12 +214,13 @@ func (stc *ScatterConn) ExecuteMulti(
 	if allErrors.HasErrors() {
 		return nil, allErrors.AggrError(stc.aggregateErrors)
 	}
+	cc.convertResult(qr)
 	return qr, nil
 }
 
 // ExecuteEntityIds executes queries that are shard specific.
 func (stc *ScatterConn) ExecuteEntityIds(
-	context context.Context,
+	ctx context.Context,
 	shards []string,
 	sqls map[string]string,
 	bindVars map[string]map[string]interface{},
@@ -203,18 +228,25 @@ func (stc *ScatterConn) ExecuteEntityIds(
 	tabletType topo.TabletType,
 	session *SafeSession,
 ) (*mproto.QueryResult, error) {
+	cc, err := newCharsetConverter(session, keyspace)
+	if err != nil {
+		return nil, err
+	}
+	if sqls, bindVars, err = cc.convertShardQueries(sqls, bindVars); err != nil {
+		return nil, err
+	}
 	results, allErrors := stc.multiGo(
-		context,
+		ctx,
 		"ExecuteEntityIds",
 		keyspace,
 		shards,
 		tabletType,
 		session,
-		func(sdc *ShardConn, transactionId int64, sResults chan<- interface{}) error {
+		func(ctx context.Context, sdc *ShardConn, transactionId int64, sResults chan<- interface{}) error {
 			shard := sdc.shard
 			sql := sqls[shard]
 			bindVar := bindVars[shard]
-			innerqr, err := sdc.Execute(context, sql, bindVar, transactionId)
+			innerqr, err := sdc.Execute(ctx, sql, bindVar, transactionId, nil)
 			if err != nil {
 				return err
 			}
@@ -230,27 +262,35 @@ func (stc *ScatterConn) ExecuteEntityIds(
 	if allErrors.HasErrors() {
 		return nil, allErrors.AggrError(stc.aggregateErrors)
 	}
+	cc.convertResult(qr)
 	return qr, nil
 }
 
 // ExecuteBatch executes a batch of non-streaming queries on the specified shards.
 func (stc *ScatterConn) ExecuteBatch(
-	context context.Context,
+	ctx context.Context,
 	queries []tproto.BoundQuery,
 	keyspace string,
 	shards []string,
 	tabletType topo.TabletType,
 	session *SafeSession,
 ) (qrs *tproto.QueryResultList, err error) {
+	cc, err := newCharsetConverter(session, keyspace)
+	if err != nil {
+		return nil, err
+	}
+	if queries, err = cc.convertBoundQueries(queries); err != nil {
+		return nil, err
+	}
 	results, allErrors := stc.multiGo(
-		context,
+		ctx,
 		"ExecuteBatch",
 		keyspace,
 		shards,
 		tabletType,
 		session,
-		func(sdc *ShardConn, transactionId int64, sResults chan<- interface{}) error {
-			innerqrs, err := sdc.ExecuteBatch(context, queries, transactionId)
+		func(ctx context.Context, sdc *ShardConn, transactionId int64, sResults chan<- interface{}) error {
+			innerqrs, err := sdc.ExecuteBatch(ctx, queries, transactionId)
 			if err != nil {
 				return err
 			}
@@ -269,29 +309,42 @@ func (stc *ScatterConn) ExecuteBatch(
 	if allErrors.HasErrors() {
 		return nil, allErrors.AggrError(stc.aggregateErrors)
 	}
+	for i := range qrs.List {
+		cc.convertResult(&qrs.List[i])
+	}
 	return qrs, nil
 }
 
 // StreamExecute executes a streaming query on vttablet. The retry rules are the same.
+// The options may be nil.
 func (stc *ScatterConn) StreamExecute(
-	context context.Context,
+	ctx context.Context,
 	query string,
 	bindVars map[string]interface{},
 	keyspace string,
 	shards []string,
 	tabletType topo.TabletType,
 	session *SafeSession,
+	options *tproto.ExecuteOptions,
 	sendReply func(reply *mproto.QueryResult) error,
 ) error {
+	cc, err := newCharsetConverter(session, keyspace)
+	if err != nil {
+		return err
+	}
+	if query, bindVars, err = cc.convertQuery(query, bindVars); err != nil {
+		return err
+	}
+	sendReply = cc.convertSendReply(sendReply)
 	results, allErrors := stc.multiGo(
-		context,
+		ctx,
 		"StreamExecute",
 		keyspace,
 		shards,
 		tabletType,
 		session,
-		func(sdc *ShardConn, transactionId int64, sResults chan<- interface{}) error {
-			sr, errFunc := sdc.StreamExecute(context, query, bindVars, transactionId)
+		func(ctx context.Context, sdc *ShardConn, transactionId int64, sResults chan<- interface{}) error {
+			sr, errFunc := sdc.StreamExecute(ctx, query, bindVars, transactionId, options)
 			if sr != nil {
 				for qr := range sr {
 					sResults <- qr
@@ -326,7 +379,7 @@ func (stc *ScatterConn) StreamExecute(
 // but each shard gets its own bindVars. If len(shards) is not equal to
 // len(bindVars), the function panics.
 func (stc *ScatterConn) StreamExecuteMulti(
-	context context.Context,
+	ctx context.Context,
 	query string,
 	keyspace string,
 	shardVars map[string]map[string]interface{},
@@ -334,15 +387,26 @@ func (stc *ScatterConn) StreamExecuteMulti(
 	session *SafeSession,
 	sendReply func(reply *mproto.QueryResult) error,
 ) error {
+	cc, err := newCharsetConverter(session, keyspace)
+	if err != nil {
+		return err
+	}
+	if _, shardVars, err = cc.convertShardQueries(nil, shardVars); err != nil {
+		return err
+	}
+	if query, _, err = cc.convertQuery(query, nil); err != nil {
+		return err
+	}
+	sendReply = cc.convertSendReply(sendReply)
 	results, allErrors := stc.multiGo(
-		context,
+		ctx,
 		"StreamExecute",
 		keyspace,
 		getShards(shardVars),
 		tabletType,
 		session,
-		func(sdc *ShardConn, transactionId int64, sResults chan<- interface{}) error {
-			sr, errFunc := sdc.StreamExecute(context, query, shardVars[sdc.shard], transactionId)
+		func(ctx context.Context, sdc *ShardConn, transactionId int64, sResults chan<- interface{}) error {
+			sr, errFunc := sdc.StreamExecute(ctx, query, shardVars[sdc.shard], transactionId, nil)
 			if sr != nil {
 				for qr := range sr {
 					sResults <- qr
@@ -409,12 +473,40 @@ func (stc *ScatterConn) Rollback(context context.Context, session *SafeSession)
 	return nil
 }
 
+// Savepoint runs a savepoint statement on all the shards of the
+// transaction, and records it in the session so that the shards that
+// join the transaction later get the same savepoints. If it fails
+// after it ran on some of the shards, the savepoints of the shards
+// differ, so the whole transaction is rolled back.
+func (stc *ScatterConn) Savepoint(context context.Context, savepoint *sqlparser.Savepoint, session *SafeSession) (*mproto.QueryResult, error) {
+	if !session.InTransaction() {
+		return nil, fmt.Errorf("savepoints are only allowed in transactions")
+	}
+	name := string(savepoint.Name)
+	if savepoint.Action != sqlparser.AST_SAVEPOINT && !session.HasSavepoint(name) {
+		return nil, fmt.Errorf("savepoint %s does not exist", name)
+	}
+	query := sqlparser.String(savepoint)
+	for i, shardSession := range session.ShardSessions {
+		sdc := stc.getConnection(context, shardSession.Keyspace, shardSession.Shard, shardSession.TabletType)
+		if _, err := sdc.Execute(context, query, nil, shardSession.TransactionId, nil); err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			stc.Rollback(context, session)
+			return nil, fmt.Errorf("%v failed on %v/%v after it ran on other shards, the transaction was rolled back: %v", query, shardSession.Keyspace, shardSession.Shard, err)
+		}
+	}
+	session.UpdateSavepoints(savepoint.Action, name)
+	return &mproto.QueryResult{}, nil
+}
+
 // SplitQuery scatters a SplitQuery request to all shards. For a set of
 // splits received from a shard, it construct a KeyRange queries by
 // appending that shard's keyrange to the splits. Aggregates all splits across
 // all shards in no specific order and returns.
 func (stc *ScatterConn) SplitQuery(ctx context.Context, query tproto.BoundQuery, splitCount int, keyRangeByShard map[string]kproto.KeyRange, keyspace string) ([]proto.SplitQueryPart, error) {
-	actionFunc := func(sdc *ShardConn, transactionID int64, results chan<- interface{}) error {
+	actionFunc := func(ctx context.Context, sdc *ShardConn, transactionID int64, results chan<- interface{}) error {
 		// Get all splits from this shard
 		queries, err := sdc.SplitQuery(ctx, query, splitCount)
 		if err != nil {
@@ -458,6 +550,12 @@ func (stc *ScatterConn) SplitQuery(ctx context.Context, query tproto.BoundQuery,
 	return splits, nil
 }
 
+// Explain returns the plan of a query on a shard.
+func (stc *ScatterConn) Explain(ctx context.Context, query string, bindVars map[string]interface{}, keyspace, shard string, tabletType topo.TabletType) (*tproto.ExplainResult, error) {
+	sdc := stc.getConnection(ctx, keyspace, shard, tabletType)
+	return sdc.Explain(ctx, query, bindVars)
+}
+
 // Close closes the underlying ShardConn connections.
 func (stc *ScatterConn) Close() error {
 	stc.mu.Lock()
@@ -482,18 +580,29 @@ func (stc *ScatterConn) aggregateErrors(errors []error) error {
 		}
 	}
 	var code int
+	var serverCode vterrors.Code
 	if allRetryableError {
 		code = tabletconn.ERR_RETRY
+		serverCode = vterrors.QueryNotServed
 	} else {
+		// keep the canonical code if all the errors agree on it
 		code = tabletconn.ERR_NORMAL
+		serverCode = vterrors.RecoverVtErrorCode(errors[0])
+		for _, e := range errors[1:] {
+			if vterrors.RecoverVtErrorCode(e) != serverCode {
+				serverCode = vterrors.UnknownError
+				break
+			}
+		}
 	}
 	errs := make([]string, 0, len(errors))
 	for _, e := range errors {
 		errs = append(errs, e.Error())
 	}
 	return &ShardConnError{
-		Code: code,
-		Err:  fmt.Sprintf("%v", strings.Join(errs, "\n")),
+		Code:       code,
+		Err:        fmt.Sprintf("%v", strings.Join(errs, "\n")),
+		ServerCode: serverCode,
 	}
 }
 
@@ -522,17 +631,33 @@ func (stc *ScatterConn) multiGo(
 		go func(shard string) {
 			statsKey := []string{name, keyspace, shard, string(tabletType)}
 			defer wg.Done()
+			defer func() {
+				// a panic only fails this shard
+				if x := recover(); x != nil {
+					servenv.RecordPanic("ScatterConn."+name, x)
+					allErrors.RecordError(fmt.Errorf("uncaught panic in ScatterConn.%v on %v/%v: %v", name, keyspace, shard, x))
+					stc.tabletCallErrorCount.Add(statsKey, 1)
+				}
+			}()
 			startTime := time.Now()
 			defer stc.timings.Record(statsKey, startTime)
 
-			sdc := stc.getConnection(context, keyspace, shard, tabletType)
-			transactionID, err := stc.updateSession(context, sdc, keyspace, shard, tabletType, session)
+			span := trace.NewSpanFromContext(context)
+			span.StartLocal("ScatterConn." + name)
+			span.Annotate("keyspace", keyspace)
+			span.Annotate("shard", shard)
+			span.Annotate("tablet_type", string(tabletType))
+			defer span.Finish()
+			context := trace.NewContext(context, span)
+
+			sdc, shardTabletType := stc.getSessionConnection(context, keyspace, shard, tabletType, session)
+			transactionID, err := stc.updateSession(context, sdc, keyspace, shard, shardTabletType, session)
 			if err != nil {
 				allErrors.RecordError(err)
 				stc.tabletCallErrorCount.Add(statsKey, 1)
 				return
 			}
-			err = action(sdc, transactionID, results)
+			err = action(context, sdc, transactionID, results)
 			if err != nil {
 				allErrors.RecordError(err)
 				stc.tabletCallErrorCount.Add(statsKey, 1)
@@ -558,6 +683,45 @@ func (stc *ScatterConn) multiGo(
 	return results, allErrors
 }
 
+// getSessionConnection returns the connection to use for a shard, and
+// its tablet type. If the session has a MaxReplicationLag, the replica
+// and rdonly queries go to the tablets whose published lag is within
+// it, rounded down to a -replication_lag_bounds entry, or to the master
+// if there are none. The lag is checked again on every query, so a
+// tablet that falls behind stops getting them.
+func (stc *ScatterConn) getSessionConnection(context context.Context, keyspace, shard string, tabletType topo.TabletType, session *SafeSession) (*ShardConn, topo.TabletType) {
+	maxLag := session.MaxReplicationLag()
+	if maxLag == 0 || (tabletType != topo.TYPE_REPLICA && tabletType != topo.TYPE_RDONLY) {
+		return stc.getConnection(context, keyspace, shard, tabletType), tabletType
+	}
+	bound, ok := replicationLagBound(maxLag)
+	if !ok {
+		replicationLagFallbacks.Add(string(tabletType), 1)
+		return stc.getConnection(context, keyspace, shard, topo.TYPE_MASTER), topo.TYPE_MASTER
+	}
+	endPoints, err := stc.toposerv.GetEndPoints(context, stc.cell, keyspace, shard, tabletType)
+	if err == nil {
+		endPoints = filterLaggingServers(endPoints, bound)
+		if len(endPoints.Entries) == 0 {
+			replicationLagFallbacks.Add(string(tabletType), 1)
+			return stc.getConnection(context, keyspace, shard, topo.TYPE_MASTER), topo.TYPE_MASTER
+		}
+	}
+
+	stc.mu.Lock()
+	key := fmt.Sprintf("%s.%s.%s.%v", keyspace, shard, tabletType, bound)
+	sdc, ok := stc.shardConns[key]
+	if !ok {
+		sdc = NewShardConn(context, &lagBoundSrvTopoServer{stc.toposerv, bound}, stc.cell, keyspace, shard, tabletType, stc.retryDelay, stc.retryCount, stc.connTimeoutTotal, stc.connTimeoutPerConn, stc.connLife, stc.tabletConnectTimings)
+		stc.shardConns[key] = sdc
+	}
+	stc.mu.Unlock()
+	if err == nil {
+		sdc.closeUnlessIn(endPoints)
+	}
+	return sdc, tabletType
+}
+
 func (stc *ScatterConn) getConnection(context context.Context, keyspace, shard string, tabletType topo.TabletType) *ShardConn {
 	stc.mu.Lock()
 	defer stc.mu.Unlock()
@@ -589,10 +753,19 @@ func (stc *ScatterConn) updateSession(
 	if transactionID != 0 {
 		return transactionID, nil
 	}
-	transactionID, err = sdc.Begin(context)
+	transactionID, err = sdc.Begin(context, session.TransactionOptions())
 	if err != nil {
 		return 0, err
 	}
+	// The shard joins the transaction with the savepoints it
+	// already has, so that rolling back to them undoes its work.
+	for _, name := range session.Savepoints() {
+		query := sqlparser.String(&sqlparser.Savepoint{Action: sqlparser.AST_SAVEPOINT, Name: []byte(name)})
+		if _, err = sdc.Execute(context, query, nil, transactionID, nil); err != nil {
+			sdc.Rollback(context, transactionID)
+			return 0, err
+		}
+	}
 	session.Append(&proto.ShardSession{
 		Keyspace:      keyspace,
 		TabletType:    tabletType,
@@ -12,6 +12,8 @@ import (
 
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/trace"
+	"github.com/youtube/vitess/go/vt/sqlparser"
 	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
 	"github.com/youtube/vitess/go/vt/vtgate/proto"
 	"golang.org/x/net/context"
@@ -22,7 +24,7 @@ import (
 func TestScatterConnExecute(t *testing.T) {
 	testScatterConnGeneric(t, "TestScatterConnExecute", func(shards []string) (*mproto.QueryResult, error) {
 		stc := NewScatterConn(new(sandboxTopo), "", "aa", 1*time.Millisecond, 3, 2*time.Millisecond, 1*time.Millisecond, 24*time.Hour)
-		return stc.Execute(context.Background(), "query", nil, "TestScatterConnExecute", shards, "", nil)
+		return stc.Execute(context.Background(), "query", nil, "TestScatterConnExecute", shards, "", nil, nil)
 	})
 }
 
@@ -53,7 +55,7 @@ func TestScatterConnStreamExecute(t *testing.T) {
 	testScatterConnGeneric(t, "TestScatterConnStreamExecute", func(shards []string) (*mproto.QueryResult, error) {
 		stc := NewScatterConn(new(sandboxTopo), "", "aa", 1*time.Millisecond, 3, 2*time.Millisecond, 1*time.Millisecond, 24*time.Hour)
 		qr := new(mproto.QueryResult)
-		err := stc.StreamExecute(context.Background(), "query", nil, "TestScatterConnStreamExecute", shards, "", nil, func(r *mproto.QueryResult) error {
+		err := stc.StreamExecute(context.Background(), "query", nil, "TestScatterConnStreamExecute", shards, "", nil, nil, func(r *mproto.QueryResult) error {
 			appendResult(qr, r)
 			return nil
 		})
@@ -93,7 +95,7 @@ func testScatterConnGeneric(t *testing.T, name string, f func(shards []string) (
 	sbc := &sandboxConn{mustFailServer: 1}
 	s.MapTestConn("0", sbc)
 	qr, err = f([]string{"0"})
-	want := fmt.Sprintf("shard, host: %v.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[]}, error: err", name)
+	want := fmt.Sprintf("shard, host: %v.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[] SchemaVersion: Tags:map[]}, error: err", name)
 	// Verify server error string.
 	if err == nil || err.Error() != want {
 		t.Errorf("want %s, got %v", want, err)
@@ -111,8 +113,8 @@ func testScatterConnGeneric(t *testing.T, name string, f func(shards []string) (
 	s.MapTestConn("1", sbc1)
 	_, err = f([]string{"0", "1"})
 	// Verify server errors are consolidated.
-	want1 := fmt.Sprintf("shard, host: %v.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[]}, error: err\nshard, host: %v.1., {Uid:0 Host:1 NamedPortMap:map[vt:1] Health:map[]}, error: err", name, name)
-	want2 := fmt.Sprintf("shard, host: %v.1., {Uid:0 Host:1 NamedPortMap:map[vt:1] Health:map[]}, error: err\nshard, host: %v.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[]}, error: err", name, name)
+	want1 := fmt.Sprintf("shard, host: %v.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[] SchemaVersion: Tags:map[]}, error: err\nshard, host: %v.1., {Uid:0 Host:1 NamedPortMap:map[vt:1] Health:map[] SchemaVersion: Tags:map[]}, error: err", name, name)
+	want2 := fmt.Sprintf("shard, host: %v.1., {Uid:0 Host:1 NamedPortMap:map[vt:1] Health:map[] SchemaVersion: Tags:map[]}, error: err\nshard, host: %v.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[] SchemaVersion: Tags:map[]}, error: err", name, name)
 	if err == nil || (err.Error() != want1 && err.Error() != want2) {
 		t.Errorf("\nwant\n%s\ngot\n%v", want1, err)
 	}
@@ -193,12 +195,72 @@ func TestMultiExecs(t *testing.T) {
 	}
 }
 
+// testSpan is a trace.Span that knows its parent.
+type testSpan struct {
+	parent *testSpan
+	label  string
+}
+
+func (s *testSpan) StartLocal(label string)                { s.label = label }
+func (s *testSpan) StartClient(label string)               { s.label = label }
+func (s *testSpan) StartServer(label string)               { s.label = label }
+func (s *testSpan) Finish()                                {}
+func (s *testSpan) Annotate(key string, value interface{}) {}
+
+type testSpanKey int
+
+type testSpanFactory struct{}
+
+func (testSpanFactory) New(parent trace.Span) trace.Span {
+	if parent == nil {
+		return &testSpan{}
+	}
+	return &testSpan{parent: parent.(*testSpan)}
+}
+
+func (testSpanFactory) FromContext(ctx context.Context) (trace.Span, bool) {
+	s, ok := ctx.Value(testSpanKey(0)).(trace.Span)
+	return s, ok
+}
+
+func (testSpanFactory) NewContext(parent context.Context, s trace.Span) context.Context {
+	return context.WithValue(parent, testSpanKey(0), s)
+}
+
+func TestScatterConnShardSpans(t *testing.T) {
+	// There is no way to restore the fake factory, but the test one
+	// only records the parent of the spans.
+	trace.RegisterSpanFactory(testSpanFactory{})
+
+	s := createSandbox("TestScatterConnShardSpans")
+	s.MapTestConn("0", &sandboxConn{})
+	s.MapTestConn("1", &sandboxConn{})
+	stc := NewScatterConn(new(sandboxTopo), "", "aa", 1*time.Millisecond, 3, 2*time.Millisecond, 1*time.Millisecond, 24*time.Hour)
+	root := &testSpan{label: "root"}
+	ctx := trace.NewContext(context.Background(), root)
+	results, allErrors := stc.multiGo(ctx, "Test", "TestScatterConnShardSpans", []string{"0", "1"}, "", nil, func(ctx context.Context, sdc *ShardConn, transactionID int64, sResults chan<- interface{}) error {
+		span, ok := trace.FromContext(ctx)
+		if !ok {
+			return fmt.Errorf("no span for shard %v", sdc.shard)
+		}
+		if s := span.(*testSpan); s.label != "ScatterConn.Test" || s.parent != root {
+			return fmt.Errorf("unexpected span for shard %v: %+v", sdc.shard, s)
+		}
+		return nil
+	})
+	for range results {
+	}
+	if allErrors.HasErrors() {
+		t.Errorf("multiGo failed: %v", allErrors.Error())
+	}
+}
+
 func TestScatterConnStreamExecuteSendError(t *testing.T) {
 	s := createSandbox("TestScatterConnStreamExecuteSendError")
 	sbc := &sandboxConn{}
 	s.MapTestConn("0", sbc)
 	stc := NewScatterConn(new(sandboxTopo), "", "aa", 1*time.Millisecond, 3, 2*time.Millisecond, 1*time.Millisecond, 24*time.Hour)
-	err := stc.StreamExecute(context.Background(), "query", nil, "TestScatterConnStreamExecuteSendError", []string{"0"}, "", nil, func(*mproto.QueryResult) error {
+	err := stc.StreamExecute(context.Background(), "query", nil, "TestScatterConnStreamExecuteSendError", []string{"0"}, "", nil, nil, func(*mproto.QueryResult) error {
 		return fmt.Errorf("send error")
 	})
 	want := "send error"
@@ -241,7 +303,7 @@ func TestScatterConnCommitSuccess(t *testing.T) {
 
 	// Sequence the executes to ensure commit order
 	session := NewSafeSession(&proto.Session{InTransaction: true})
-	stc.Execute(context.Background(), "query1", nil, "TestScatterConnCommitSuccess", []string{"0"}, "", session)
+	stc.Execute(context.Background(), "query1", nil, "TestScatterConnCommitSuccess", []string{"0"}, "", session, nil)
 	wantSession := proto.Session{
 		InTransaction: true,
 		ShardSessions: []*proto.ShardSession{{
@@ -254,7 +316,7 @@ func TestScatterConnCommitSuccess(t *testing.T) {
 	if !reflect.DeepEqual(wantSession, *session.Session) {
 		t.Errorf("want\n%+v, got\n%+v", wantSession, *session.Session)
 	}
-	stc.Execute(context.Background(), "query1", nil, "TestScatterConnCommitSuccess", []string{"0", "1"}, "", session)
+	stc.Execute(context.Background(), "query1", nil, "TestScatterConnCommitSuccess", []string{"0", "1"}, "", session, nil)
 	wantSession = proto.Session{
 		InTransaction: true,
 		ShardSessions: []*proto.ShardSession{{
@@ -289,6 +351,25 @@ func TestScatterConnCommitSuccess(t *testing.T) {
 	}
 }
 
+func TestScatterConnTransactionOptions(t *testing.T) {
+	s := createSandbox("TestScatterConnTransactionOptions")
+	sbc := &sandboxConn{}
+	s.MapTestConn("0", sbc)
+	stc := NewScatterConn(new(sandboxTopo), "", "aa", 1*time.Millisecond, 3, 2*time.Millisecond, 1*time.Millisecond, 24*time.Hour)
+
+	options := &tproto.TransactionOptions{
+		IsolationLevel: tproto.IsolationReadCommitted,
+		ReadOnly:       true,
+	}
+	session := NewSafeSession(&proto.Session{InTransaction: true, TransactionOptions: options})
+	if _, err := stc.Execute(context.Background(), "query1", nil, "TestScatterConnTransactionOptions", []string{"0"}, "", session, nil); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if sbc.BeginOptions != options {
+		t.Errorf("Begin options are %+v, want %+v", sbc.BeginOptions, options)
+	}
+}
+
 func TestScatterConnRollback(t *testing.T) {
 	s := createSandbox("TestScatterConnRollback")
 	sbc0 := &sandboxConn{}
@@ -299,8 +380,8 @@ func TestScatterConnRollback(t *testing.T) {
 
 	// Sequence the executes to ensure commit order
 	session := NewSafeSession(&proto.Session{InTransaction: true})
-	stc.Execute(context.Background(), "query1", nil, "TestScatterConnRollback", []string{"0"}, "", session)
-	stc.Execute(context.Background(), "query1", nil, "TestScatterConnRollback", []string{"0", "1"}, "", session)
+	stc.Execute(context.Background(), "query1", nil, "TestScatterConnRollback", []string{"0"}, "", session, nil)
+	stc.Execute(context.Background(), "query1", nil, "TestScatterConnRollback", []string{"0", "1"}, "", session, nil)
 	err := stc.Rollback(context.Background(), session)
 	if err != nil {
 		t.Errorf("want nil, got %v", err)
@@ -322,7 +403,7 @@ func TestScatterConnClose(t *testing.T) {
 	sbc := &sandboxConn{}
 	s.MapTestConn("0", sbc)
 	stc := NewScatterConn(new(sandboxTopo), "", "aa", 1*time.Millisecond, 3, 2*time.Millisecond, 1*time.Millisecond, 24*time.Hour)
-	stc.Execute(context.Background(), "query1", nil, "TestScatterConnClose", []string{"0"}, "", nil)
+	stc.Execute(context.Background(), "query1", nil, "TestScatterConnClose", []string{"0"}, "", nil, nil)
 	stc.Close()
 	time.Sleep(1)
 	if sbc.CloseCount != 1 {
@@ -378,3 +459,82 @@ func TestAppendResult(t *testing.T) {
 		t.Errorf("want 2, got %v", len(qr.Rows))
 	}
 }
+
+func TestScatterConnSavepoint(t *testing.T) {
+	s := createSandbox("TestScatterConnSavepoint")
+	sbc0 := &sandboxConn{}
+	s.MapTestConn("0", sbc0)
+	sbc1 := &sandboxConn{}
+	s.MapTestConn("1", sbc1)
+	stc := NewScatterConn(new(sandboxTopo), "", "aa", 1*time.Millisecond, 3, 2*time.Millisecond, 1*time.Millisecond, 24*time.Hour)
+	savepoint := func(sql string, session *SafeSession) error {
+		_, err := stc.Savepoint(context.Background(), sqlparser.ParseSavepoint(sql), session)
+		return err
+	}
+	sqls := func(sbc *sandboxConn) []string {
+		var result []string
+		for _, query := range sbc.Queries {
+			result = append(result, query.Sql)
+		}
+		return result
+	}
+
+	if err := savepoint("savepoint a", NewSafeSession(&proto.Session{})); err == nil {
+		t.Errorf("Savepoint outside of a transaction succeeded")
+	}
+
+	session := NewSafeSession(&proto.Session{InTransaction: true})
+	if _, err := stc.Execute(context.Background(), "query1", nil, "TestScatterConnSavepoint", []string{"0"}, "", session, nil); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if err := savepoint("savepoint a", session); err != nil {
+		t.Fatalf("Savepoint failed: %v", err)
+	}
+	if err := savepoint("savepoint b", session); err != nil {
+		t.Fatalf("Savepoint failed: %v", err)
+	}
+	// shard 1 joins the transaction with the existing savepoints
+	if _, err := stc.Execute(context.Background(), "query2", nil, "TestScatterConnSavepoint", []string{"1"}, "", session, nil); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if err := savepoint("rollback to savepoint A", session); err != nil {
+		t.Fatalf("Savepoint failed: %v", err)
+	}
+	if want := []string{"a"}; !reflect.DeepEqual(session.Savepoints(), want) {
+		t.Errorf("savepoints are %v, want %v", session.Savepoints(), want)
+	}
+	if err := savepoint("release savepoint b", session); err == nil {
+		t.Errorf("Release of a rolled back savepoint succeeded")
+	}
+	if err := savepoint("release savepoint a", session); err != nil {
+		t.Fatalf("Savepoint failed: %v", err)
+	}
+	if len(session.Savepoints()) != 0 {
+		t.Errorf("savepoints are %v, want none", session.Savepoints())
+	}
+
+	want0 := []string{"query1", "savepoint a", "savepoint b", "rollback to savepoint A", "release savepoint a"}
+	if got := sqls(sbc0); !reflect.DeepEqual(got, want0) {
+		t.Errorf("shard 0 queries are %v, want %v", got, want0)
+	}
+	want1 := []string{"savepoint a", "savepoint b", "query2", "rollback to savepoint A", "release savepoint a"}
+	if got := sqls(sbc1); !reflect.DeepEqual(got, want1) {
+		t.Errorf("shard 1 queries are %v, want %v", got, want1)
+	}
+
+	// a savepoint that fails on the second shard rolls back the
+	// transaction
+	if err := savepoint("savepoint c", session); err != nil {
+		t.Fatalf("Savepoint failed: %v", err)
+	}
+	sbc1.mustFailServer = 1
+	if err := savepoint("savepoint d", session); err == nil {
+		t.Errorf("Savepoint succeeded, want an error")
+	}
+	if session.InTransaction() || len(session.Savepoints()) != 0 {
+		t.Errorf("session = %+v, want no transaction", session.Session)
+	}
+	if sbc0.RollbackCount.Get() != 1 || sbc1.RollbackCount.Get() != 1 {
+		t.Errorf("want 1 rollback per shard, got %v and %v", sbc0.RollbackCount.Get(), sbc1.RollbackCount.Get())
+	}
+}
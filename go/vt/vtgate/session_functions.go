@@ -0,0 +1,70 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtgate
+
+import (
+	"errors"
+	"strconv"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/stats"
+	"github.com/youtube/vitess/go/vt/sqlparser"
+	"github.com/youtube/vitess/go/vt/vtgate/proto"
+)
+
+var sessionFunctionQueries = stats.NewCounters("VtgateSessionFunctionQueries")
+
+// errNoSession is returned for the session functions of the queries
+// without a session: their values can't be known.
+var errNoSession = errors.New("LAST_INSERT_ID() and ROW_COUNT() need a session, the queries outside of one may run on different connections")
+
+// sessionFunctionResult returns the result of sql if it only selects
+// LAST_INSERT_ID() or ROW_COUNT(), nil otherwise. The values come
+// from the session, the tablets can't know them because consecutive
+// queries may run on different connections. Without a session, it
+// returns an error rather than letting a tablet answer.
+func sessionFunctionResult(sql string, session *proto.Session) (*mproto.QueryResult, error) {
+	function := sqlparser.ParseSessionFunction(sql)
+	if function == nil {
+		return nil, nil
+	}
+	if session == nil {
+		return nil, errNoSession
+	}
+	var value string
+	switch function.Name {
+	case sqlparser.LastInsertIdFunc:
+		value = strconv.FormatUint(session.LastInsertId, 10)
+	case sqlparser.RowCountFunc:
+		value = strconv.FormatInt(session.RowCount, 10)
+	}
+	sessionFunctionQueries.Add(function.Name, 1)
+	// this is a query that returns rows
+	session.RowCount = -1
+	return &mproto.QueryResult{
+		Fields:       []mproto.Field{{Name: function.Column, Type: mproto.VT_LONGLONG}},
+		RowsAffected: 1,
+		Rows:         [][]sqltypes.Value{{sqltypes.MakeNumeric([]byte(value))}},
+	}, nil
+}
+
+// recordSessionFunctions updates the session functions of a session
+// with the result of one of its queries. Like in MySQL, the last
+// insert id is only changed by the queries that generate one, and the
+// row count is -1 after the queries that return rows.
+func recordSessionFunctions(session *proto.Session, qr *mproto.QueryResult) {
+	if session == nil || qr == nil {
+		return
+	}
+	if qr.InsertId != 0 {
+		session.LastInsertId = qr.InsertId
+	}
+	if len(qr.Fields) == 0 {
+		session.RowCount = int64(qr.RowsAffected)
+	} else {
+		session.RowCount = -1
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vtgate
+
+import (
+	"testing"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/vt/vtgate/proto"
+	"golang.org/x/net/context"
+)
+
+func TestVTGateSessionFunctions(t *testing.T) {
+	sandbox := createSandbox("TestVTGateSessionFunctions")
+	sbc := &sandboxConn{}
+	sandbox.MapTestConn("0", sbc)
+	execute := func(sql string, session *proto.Session) *mproto.QueryResult {
+		q := proto.QueryShard{
+			Sql:      sql,
+			Keyspace: "TestVTGateSessionFunctions",
+			Shards:   []string{"0"},
+			Session:  session,
+		}
+		qr := new(proto.QueryResult)
+		if err := rpcVTGate.ExecuteShard(context.Background(), &q, qr); err != nil || qr.Error != "" {
+			t.Fatalf("ExecuteShard(%v) failed: %v %v", sql, err, qr.Error)
+		}
+		return qr.Result
+	}
+	value := func(qr *mproto.QueryResult) string {
+		if len(qr.Rows) != 1 || len(qr.Rows[0]) != 1 {
+			t.Fatalf("want a single value, got %+v", qr)
+		}
+		return qr.Rows[0][0].String()
+	}
+
+	session := new(proto.Session)
+	sbc.setResults([]*mproto.QueryResult{
+		&mproto.QueryResult{RowsAffected: 2, InsertId: 5},
+		&mproto.QueryResult{RowsAffected: 1},
+	})
+	execute("insert into t values (null), (null)", session)
+	execute("update t set a = 1 where id = 5", session)
+
+	// the session functions don't go to the tablets
+	queries := len(sbc.Queries)
+	qr := execute("select last_insert_id() as id", session)
+	if got := value(qr); got != "5" {
+		t.Errorf("last_insert_id() = %v, want 5", got)
+	}
+	if len(qr.Fields) != 1 || qr.Fields[0].Name != "id" {
+		t.Errorf("want an id column, got %+v", qr.Fields)
+	}
+	// like in MySQL, selects set ROW_COUNT() to -1
+	if got := value(execute("select row_count()", session)); got != "-1" {
+		t.Errorf("row_count() after a select = %v, want -1", got)
+	}
+	if len(sbc.Queries) != queries {
+		t.Errorf("the session functions were sent to the tablet: %v", sbc.Queries[queries:])
+	}
+
+	sbc.setResults([]*mproto.QueryResult{&mproto.QueryResult{RowsAffected: 3}})
+	execute("delete from t", session)
+	if got := value(execute("select ROW_COUNT()", session)); got != "3" {
+		t.Errorf("row_count() = %v, want 3", got)
+	}
+	if got := value(execute("select last_insert_id()", session)); got != "5" {
+		t.Errorf("last_insert_id() = %v, want 5", got)
+	}
+
+	// without a session, the values are unknown
+	queries = len(sbc.Queries)
+	reply := new(proto.QueryResult)
+	if err := rpcVTGate.ExecuteShard(context.Background(), &proto.QueryShard{Sql: "select last_insert_id()", Keyspace: "TestVTGateSessionFunctions", Shards: []string{"0"}}, reply); err != nil || reply.Error != errNoSession.Error() {
+		t.Errorf("last_insert_id() without a session: got %v %v, want %v", err, reply.Error, errNoSession)
+	}
+	if len(sbc.Queries) != queries {
+		t.Errorf("the session function was sent to the tablet: %v", sbc.Queries[queries:])
+	}
+}
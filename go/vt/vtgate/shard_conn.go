@@ -18,6 +18,7 @@ import (
 	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
 	"github.com/youtube/vitess/go/vt/tabletserver/tabletconn"
 	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vterrors"
 	"golang.org/x/net/context"
 )
 
@@ -43,6 +44,13 @@ type ShardConn struct {
 	// conn needs a mutex because it can change during the lifetime of ShardConn.
 	mu   sync.Mutex
 	conn tabletconn.TabletConn
+	// masterHint is the master a vttablet redirected us to, that
+	// the next connection goes to. It's protected by mu too.
+	masterHint *topo.EndPoint
+
+	// buffer holds the queries during master failovers. It is
+	// only set for masters, if -master_buffer_window is set.
+	buffer *masterBuffer
 }
 
 // NewShardConn creates a new ShardConn. It creates a Balancer using
@@ -57,6 +65,12 @@ func NewShardConn(ctx context.Context, serv SrvTopoServer, cell, keyspace, shard
 		return endpoints, nil
 	}
 	blc := NewBalancer(getAddresses, retryDelay)
+	for _, fallbackCell := range BalancerFallbackCells(cell) {
+		fallbackCell := fallbackCell
+		blc.AddFallbackCell(fallbackCell, func() (*topo.EndPoints, error) {
+			return serv.GetEndPoints(ctx, fallbackCell, keyspace, shard, tabletType)
+		})
+	}
 	ticker := timer.NewRandTicker(connLife, connLife/2)
 	sdc := &ShardConn{
 		keyspace:           keyspace,
@@ -72,6 +86,9 @@ func NewShardConn(ctx context.Context, serv SrvTopoServer, cell, keyspace, shard
 		consolidator:       sync2.NewConsolidator(),
 		connectTimings:     tabletConnectTimings,
 	}
+	if tabletType == topo.TYPE_MASTER && *masterBufferWindow > 0 {
+		sdc.buffer = newMasterBuffer(keyspace+"/"+shard, *masterBufferWindow, *masterBufferSize)
+	}
 	go func() {
 		for range ticker.C {
 			sdc.closeCurrent()
@@ -86,6 +103,8 @@ type ShardConnError struct {
 	ShardIdentifier string
 	InTransaction   bool
 	Err             string
+	// ServerCode is the canonical code of the error.
+	ServerCode vterrors.Code
 }
 
 func (e *ShardConnError) Error() string {
@@ -95,24 +114,38 @@ func (e *ShardConnError) Error() string {
 	return fmt.Sprintf("shard, host: %s, %v", e.ShardIdentifier, e.Err)
 }
 
+// VtErrorCode is part of the vterrors.VtError interface.
+func (e *ShardConnError) VtErrorCode() vterrors.Code {
+	return e.ServerCode
+}
+
+// ErrorCode is part of the rpcplus.CodedError interface, so the code
+// is sent to the clients of vtgate.
+func (e *ShardConnError) ErrorCode() int64 {
+	return int64(e.ServerCode)
+}
+
 // Dial creates tablet connection and connects to the vttablet.
 // It is not necessary to call this function before serving queries,
 // but it would reduce connection overhead when serving the first query.
 func (sdc *ShardConn) Dial(ctx context.Context) error {
 	return sdc.withRetry(ctx, func(conn tabletconn.TabletConn) error {
 		return nil
-	}, 0, false)
+	}, 0, false, false)
 }
 
 // Execute executes a non-streaming query on vttablet. If there are connection errors,
 // it retries retryCount times before failing. It does not retry if the connection is in
-// the middle of a transaction.
-func (sdc *ShardConn) Execute(ctx context.Context, query string, bindVars map[string]interface{}, transactionID int64) (qr *mproto.QueryResult, err error) {
+// the middle of a transaction, unless the options have an IdempotencyKey.
+func (sdc *ShardConn) Execute(ctx context.Context, query string, bindVars map[string]interface{}, transactionID int64, options *tproto.ExecuteOptions) (qr *mproto.QueryResult, err error) {
+	// With an idempotency key, the vttablet applies the write once
+	// even if we send it again after a connection error.
+	idempotent := options != nil && options.IdempotencyKey != ""
 	err = sdc.withRetry(ctx, func(conn tabletconn.TabletConn) error {
 		var innerErr error
-		qr, innerErr = conn.Execute(ctx, query, bindVars, transactionID)
+		qr, innerErr = conn.Execute(ctx, query, bindVars, transactionID, options)
 		return innerErr
-	}, transactionID, false)
+	}, transactionID, false, idempotent)
 	return qr, err
 }
 
@@ -122,21 +155,21 @@ func (sdc *ShardConn) ExecuteBatch(ctx context.Context, queries []tproto.BoundQu
 		var innerErr error
 		qrs, innerErr = conn.ExecuteBatch(ctx, queries, transactionID)
 		return innerErr
-	}, transactionID, false)
+	}, transactionID, false, false)
 	return qrs, err
 }
 
 // StreamExecute executes a streaming query on vttablet. The retry rules are the same as Execute.
-func (sdc *ShardConn) StreamExecute(ctx context.Context, query string, bindVars map[string]interface{}, transactionID int64) (<-chan *mproto.QueryResult, tabletconn.ErrFunc) {
+func (sdc *ShardConn) StreamExecute(ctx context.Context, query string, bindVars map[string]interface{}, transactionID int64, options *tproto.ExecuteOptions) (<-chan *mproto.QueryResult, tabletconn.ErrFunc) {
 	var usedConn tabletconn.TabletConn
 	var erFunc tabletconn.ErrFunc
 	var results <-chan *mproto.QueryResult
 	err := sdc.withRetry(ctx, func(conn tabletconn.TabletConn) error {
 		var err error
-		results, erFunc, err = conn.StreamExecute(ctx, query, bindVars, transactionID)
+		results, erFunc, err = conn.StreamExecute(ctx, query, bindVars, transactionID, options)
 		usedConn = conn
 		return err
-	}, transactionID, true)
+	}, transactionID, true, false)
 	if err != nil {
 		return results, func() error { return err }
 	}
@@ -145,12 +178,12 @@ func (sdc *ShardConn) StreamExecute(ctx context.Context, query string, bindVars
 }
 
 // Begin begins a transaction. The retry rules are the same as Execute.
-func (sdc *ShardConn) Begin(ctx context.Context) (transactionID int64, err error) {
+func (sdc *ShardConn) Begin(ctx context.Context, options *tproto.TransactionOptions) (transactionID int64, err error) {
 	err = sdc.withRetry(ctx, func(conn tabletconn.TabletConn) error {
 		var innerErr error
-		transactionID, innerErr = conn.Begin(ctx)
+		transactionID, innerErr = conn.Begin(ctx, options)
 		return innerErr
-	}, 0, false)
+	}, 0, false, false)
 	return transactionID, err
 }
 
@@ -158,14 +191,14 @@ func (sdc *ShardConn) Begin(ctx context.Context) (transactionID int64, err error
 func (sdc *ShardConn) Commit(ctx context.Context, transactionID int64) (err error) {
 	return sdc.withRetry(ctx, func(conn tabletconn.TabletConn) error {
 		return conn.Commit(ctx, transactionID)
-	}, transactionID, false)
+	}, transactionID, false, false)
 }
 
 // Rollback rolls back the current transaction. The retry rules are the same as Execute.
 func (sdc *ShardConn) Rollback(ctx context.Context, transactionID int64) (err error) {
 	return sdc.withRetry(ctx, func(conn tabletconn.TabletConn) error {
 		return conn.Rollback(ctx, transactionID)
-	}, transactionID, false)
+	}, transactionID, false, false)
 }
 
 // SplitQuery splits a query into sub queries. The retry rules are the same as Execute.
@@ -174,7 +207,17 @@ func (sdc *ShardConn) SplitQuery(ctx context.Context, query tproto.BoundQuery, s
 		var innerErr error
 		queries, innerErr = conn.SplitQuery(ctx, query, splitCount)
 		return innerErr
-	}, 0, false)
+	}, 0, false, false)
+	return
+}
+
+// Explain returns the plan of a query. The retry rules are the same as Execute.
+func (sdc *ShardConn) Explain(ctx context.Context, query string, bindVars map[string]interface{}) (result *tproto.ExplainResult, err error) {
+	err = sdc.withRetry(ctx, func(conn tabletconn.TabletConn) error {
+		var innerErr error
+		result, innerErr = conn.Explain(ctx, query, bindVars)
+		return innerErr
+	}, 0, false, false)
 	return
 }
 
@@ -190,38 +233,91 @@ func (sdc *ShardConn) closeCurrent() {
 	if sdc.conn == nil {
 		return
 	}
+	sdc.balancer.Disconnected(sdc.conn.EndPoint())
+	go sdc.conn.Close()
+	sdc.conn = nil
+}
+
+// closeUnlessIn closes the current connection if its tablet is not
+// one of endPoints anymore, so the next query connects to one that is.
+func (sdc *ShardConn) closeUnlessIn(endPoints *topo.EndPoints) {
+	sdc.mu.Lock()
+	defer sdc.mu.Unlock()
+	if sdc.conn == nil {
+		return
+	}
+	current := sdc.conn.EndPoint()
+	for _, ep := range endPoints.Entries {
+		if endPointAddress(ep) == endPointAddress(current) {
+			return
+		}
+	}
+	log.Infof("End point %v left the serving set of %s.%s.%s, closing its connection", current, sdc.keyspace, sdc.shard, sdc.tabletType)
+	sdc.balancer.Disconnected(current)
 	go sdc.conn.Close()
 	sdc.conn = nil
 }
 
-// withRetry sets up the connection and executes the action. If there are connection errors,
+// withRetry executes the action with withRetryNoBuffering. For
+// masters with a buffer, the action is executed again when the new
+// master is serving, if it failed because of a failover.
+func (sdc *ShardConn) withRetry(ctx context.Context, action func(conn tabletconn.TabletConn) error, transactionID int64, isStreaming, idempotent bool) error {
+	for {
+		failover, err := sdc.withRetryNoBuffering(ctx, action, transactionID, isStreaming, idempotent)
+		if sdc.buffer == nil {
+			return err
+		}
+		if err == nil {
+			sdc.buffer.stop()
+			return nil
+		}
+		if !failover || !sdc.buffer.wait(ctx) {
+			return err
+		}
+	}
+}
+
+// withRetryNoBuffering sets up the connection and executes the action. If there are connection errors,
 // it retries retryCount times before failing. It does not retry if the connection is in
 // the middle of a transaction. While returning the error check if it maybe a result of
 // a resharding event, and set the re-resolve bit and let the upper layers
-// re-resolve and retry.
-func (sdc *ShardConn) withRetry(ctx context.Context, action func(conn tabletconn.TabletConn) error, transactionID int64, isStreaming bool) error {
+// re-resolve and retry. failover is true if the last error could be
+// caused by a master failover: no tablet could be reached, or the
+// tablet asked for a retry. If the action is idempotent, it is also
+// retried after a connection error, even in a transaction.
+func (sdc *ShardConn) withRetryNoBuffering(ctx context.Context, action func(conn tabletconn.TabletConn) error, transactionID int64, isStreaming, idempotent bool) (failover bool, err error) {
 	var conn tabletconn.TabletConn
 	var endPoint topo.EndPoint
-	var err error
 	var isTimeout bool
 	inTransaction := (transactionID != 0)
 	// execute the action at least once even without retrying
 	for i := 0; i < sdc.retryCount+1; i++ {
 		conn, endPoint, isTimeout, err = sdc.getConn(ctx)
 		if err != nil {
+			failover = !inTransaction
 			if isTimeout || i == sdc.retryCount {
 				break
 			}
 			time.Sleep(sdc.retryDelay)
 			continue
 		}
+		actionStartTime := time.Now()
 		err = action(conn)
-		if sdc.canRetry(ctx, err, transactionID, conn, isStreaming) {
+		if err == nil {
+			var latency time.Duration
+			if !isStreaming {
+				latency = time.Now().Sub(actionStartTime)
+			}
+			sdc.balancer.RecordSuccess(endPoint, latency)
+		}
+		failover = false
+		if sdc.canRetry(ctx, err, transactionID, conn, isStreaming, idempotent) {
+			failover = true
 			continue
 		}
 		break
 	}
-	return sdc.WrapError(err, endPoint, inTransaction)
+	return failover, sdc.WrapError(err, endPoint, inTransaction)
 }
 
 type connectResult struct {
@@ -265,6 +361,12 @@ func (sdc *ShardConn) getConn(ctx context.Context) (conn tabletconn.TabletConn,
 func (sdc *ShardConn) getNewConn(ctx context.Context) (conn tabletconn.TabletConn, endPoint topo.EndPoint, isTimeout bool, err error) {
 	startTime := time.Now()
 
+	// A vttablet told us where the master is: try it before
+	// the serving graph knows about it.
+	if conn, endPoint, err = sdc.dialMasterHint(ctx); err == nil {
+		return conn, endPoint, false, nil
+	}
+
 	endPoints, err := sdc.balancer.Get()
 	if err != nil {
 		// Error when getting endpoint
@@ -286,6 +388,7 @@ func (sdc *ShardConn) getNewConn(ctx context.Context) (conn tabletconn.TabletCon
 		conn, err = tabletconn.GetDialer()(ctx, endPoint, sdc.keyspace, sdc.shard, perConnTimeout)
 		if err == nil {
 			sdc.connectTimings.Record([]string{sdc.keyspace, sdc.shard, string(sdc.tabletType)}, perConnStartTime)
+			sdc.balancer.Connected(endPoint)
 			sdc.mu.Lock()
 			defer sdc.mu.Unlock()
 			sdc.conn = conn
@@ -303,6 +406,30 @@ func (sdc *ShardConn) getNewConn(ctx context.Context) (conn tabletconn.TabletCon
 	return nil, topo.EndPoint{}, false, allErrors.Error()
 }
 
+// dialMasterHint connects to the master hint, if there is one, and
+// uses the connection for the next requests. The hint is only used once.
+func (sdc *ShardConn) dialMasterHint(ctx context.Context) (tabletconn.TabletConn, topo.EndPoint, error) {
+	sdc.mu.Lock()
+	master := sdc.masterHint
+	sdc.masterHint = nil
+	sdc.mu.Unlock()
+	if master == nil {
+		return nil, topo.EndPoint{}, fmt.Errorf("no master hint")
+	}
+	startTime := time.Now()
+	conn, err := tabletconn.GetDialer()(ctx, *master, sdc.keyspace, sdc.shard, sdc.connTimeoutPerConn)
+	if err != nil {
+		log.Warningf("Cannot connect to the master hint %+v for %s.%s: %v", master, sdc.keyspace, sdc.shard, err)
+		return nil, topo.EndPoint{}, err
+	}
+	sdc.connectTimings.Record([]string{sdc.keyspace, sdc.shard, string(sdc.tabletType)}, startTime)
+	sdc.balancer.Connected(*master)
+	sdc.mu.Lock()
+	defer sdc.mu.Unlock()
+	sdc.conn = conn
+	return conn, *master, nil
+}
+
 // getConnTimeoutPerConn determines the appropriate timeout per connection.
 func (sdc *ShardConn) getConnTimeoutPerConn(endPointCount int) time.Duration {
 	if endPointCount <= 1 {
@@ -317,7 +444,8 @@ func (sdc *ShardConn) getConnTimeoutPerConn(endPointCount int) time.Duration {
 // canRetry determines whether a query can be retried or not.
 // OperationalErrors like retry/fatal cause a reconnect and retry if query is not in a txn.
 // TxPoolFull causes a retry and all other errors are non-retry.
-func (sdc *ShardConn) canRetry(ctx context.Context, err error, transactionID int64, conn tabletconn.TabletConn, isStreaming bool) bool {
+// Connection errors only cause a retry if the query is idempotent.
+func (sdc *ShardConn) canRetry(ctx context.Context, err error, transactionID int64, conn tabletconn.TabletConn, isStreaming, idempotent bool) bool {
 	if err == nil {
 		return false
 	}
@@ -342,6 +470,11 @@ func (sdc *ShardConn) canRetry(ctx context.Context, err error, transactionID int
 		case tabletconn.ERR_RETRY:
 			// Retry on RETRY and FATAL if not in a transaction.
 			inTransaction := (transactionID != 0)
+			if serverError.MasterHint != nil && sdc.tabletType == topo.TYPE_MASTER {
+				sdc.mu.Lock()
+				sdc.masterHint = serverError.MasterHint
+				sdc.mu.Unlock()
+			}
 			sdc.markDown(conn, err.Error())
 			return !inTransaction
 		default:
@@ -349,12 +482,14 @@ func (sdc *ShardConn) canRetry(ctx context.Context, err error, transactionID int
 			return false
 		}
 	}
-	// Do not retry on operational error.
+	// Do not retry on operational error: the vttablet may have
+	// applied the query before the connection broke. An idempotent
+	// query is safe to send again, the vttablet applies it once.
 	// TODO(liang): handle the case when VTGate is idle
 	// while vttablet is gracefully shutdown.
 	// We want to retry in that case.
 	sdc.markDown(conn, err.Error())
-	return false
+	return idempotent
 }
 
 // markDown closes conn and temporarily marks the associated
@@ -366,6 +501,7 @@ func (sdc *ShardConn) markDown(conn tabletconn.TabletConn, reason string) {
 		return
 	}
 	sdc.balancer.MarkDown(conn.EndPoint().Uid, reason)
+	sdc.balancer.Disconnected(conn.EndPoint())
 
 	go sdc.conn.Close()
 	sdc.conn = nil
@@ -391,6 +527,7 @@ func (sdc *ShardConn) WrapError(in error, endPoint topo.EndPoint, inTransaction
 		ShardIdentifier: shardIdentifier,
 		InTransaction:   inTransaction,
 		Err:             in.Error(),
+		ServerCode:      vterrors.RecoverVtErrorCode(in),
 	}
 	return shardConnErr
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/youtube/vitess/go/stats"
 	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
 	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vterrors"
 	"golang.org/x/net/context"
 )
 
@@ -29,12 +30,12 @@ var (
 func TestShardConnExecute(t *testing.T) {
 	testShardConnGeneric(t, "TestShardConnExecute", func() error {
 		sdc := NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnExecute", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
-		_, err := sdc.Execute(context.Background(), "query", nil, 0)
+		_, err := sdc.Execute(context.Background(), "query", nil, 0, nil)
 		return err
 	})
 	testShardConnTransact(t, "TestShardConnExecute", func() error {
 		sdc := NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnExecute", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
-		_, err := sdc.Execute(context.Background(), "query", nil, 1)
+		_, err := sdc.Execute(context.Background(), "query", nil, 1, nil)
 		return err
 	})
 }
@@ -57,12 +58,12 @@ func TestShardConnExecuteBatch(t *testing.T) {
 func TestShardConnExecuteStream(t *testing.T) {
 	testShardConnGeneric(t, "TestShardConnExecuteStream", func() error {
 		sdc := NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnExecuteStream", "0", "", 1*time.Millisecond, 3, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
-		_, errfunc := sdc.StreamExecute(context.Background(), "query", nil, 0)
+		_, errfunc := sdc.StreamExecute(context.Background(), "query", nil, 0, nil)
 		return errfunc()
 	})
 	testShardConnTransact(t, "TestShardConnExecuteStream", func() error {
 		sdc := NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnExecuteStream", "0", "", 1*time.Millisecond, 3, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
-		_, errfunc := sdc.StreamExecute(context.Background(), "query", nil, 1)
+		_, errfunc := sdc.StreamExecute(context.Background(), "query", nil, 1, nil)
 		return errfunc()
 	})
 }
@@ -70,7 +71,7 @@ func TestShardConnExecuteStream(t *testing.T) {
 func TestShardConnBegin(t *testing.T) {
 	testShardConnGeneric(t, "TestShardConnBegin", func() error {
 		sdc := NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnBegin", "0", "", 1*time.Millisecond, 3, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
-		_, err := sdc.Begin(context.Background())
+		_, err := sdc.Begin(context.Background(), nil)
 		return err
 	})
 }
@@ -94,7 +95,7 @@ func testShardConnGeneric(t *testing.T, name string, f func() error) {
 	s := createSandbox(name)
 	s.EndPointMustFail = retryCount + 1
 	err := f()
-	want := fmt.Sprintf("shard, host: %v.0., {Uid:0 Host: NamedPortMap:map[] Health:map[]}, endpoints fetch error: topo error", name)
+	want := fmt.Sprintf("shard, host: %v.0., {Uid:0 Host: NamedPortMap:map[] Health:map[] SchemaVersion: Tags:map[]}, endpoints fetch error: topo error", name)
 	if err == nil || err.Error() != want {
 		t.Errorf("want %s, got %v", want, err)
 	}
@@ -176,7 +177,7 @@ func testShardConnGeneric(t *testing.T, name string, f func() error) {
 	sbc = &sandboxConn{mustFailServer: 1}
 	s.MapTestConn("0", sbc)
 	err = f()
-	want = fmt.Sprintf("shard, host: %v.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[]}, error: err", name)
+	want = fmt.Sprintf("shard, host: %v.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[] SchemaVersion: Tags:map[]}, error: err", name)
 	if err == nil || err.Error() != want {
 		t.Errorf("want %s, got %v", want, err)
 	}
@@ -195,7 +196,7 @@ func testShardConnGeneric(t *testing.T, name string, f func() error) {
 	sbc = &sandboxConn{mustFailConn: 1}
 	s.MapTestConn("0", sbc)
 	err = f()
-	want = fmt.Sprintf("shard, host: %v.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[]}, error: conn", name)
+	want = fmt.Sprintf("shard, host: %v.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[] SchemaVersion: Tags:map[]}, error: conn", name)
 	if err == nil || err.Error() != want {
 		t.Errorf("want %v, got %v", want, err)
 	}
@@ -230,7 +231,7 @@ func testShardConnTransact(t *testing.T, name string, f func() error) {
 	sbc := &sandboxConn{mustFailRetry: 3}
 	s.MapTestConn("0", sbc)
 	err := f()
-	want := fmt.Sprintf("shard, host: %v.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[]}, retry: err", name)
+	want := fmt.Sprintf("shard, host: %v.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[] SchemaVersion: Tags:map[]}, retry: err", name)
 	if err == nil || err.Error() != want {
 		t.Errorf("want %s, got %v", want, err)
 	}
@@ -244,7 +245,7 @@ func testShardConnTransact(t *testing.T, name string, f func() error) {
 	sbc = &sandboxConn{mustFailConn: 3}
 	s.MapTestConn("0", sbc)
 	err = f()
-	want = fmt.Sprintf("shard, host: %v.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[]}, error: conn", name)
+	want = fmt.Sprintf("shard, host: %v.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[] SchemaVersion: Tags:map[]}, error: conn", name)
 	if err == nil || err.Error() != want {
 		t.Errorf("want %s, got %v", want, err)
 	}
@@ -254,14 +255,58 @@ func testShardConnTransact(t *testing.T, name string, f func() error) {
 	}
 }
 
+func TestShardConnExecuteIdempotencyKey(t *testing.T) {
+	s := createSandbox("TestShardConnExecuteIdempotencyKey")
+	options := &tproto.ExecuteOptions{IdempotencyKey: "key"}
+
+	// conn error in a transaction: the write is sent again
+	sbc := &sandboxConn{mustFailConn: 1}
+	s.MapTestConn("0", sbc)
+	sdc := NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnExecuteIdempotencyKey", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
+	if _, err := sdc.Execute(context.Background(), "query", nil, 1, options); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+	if sbc.ExecCount != 2 {
+		t.Errorf("want 2, got %v", sbc.ExecCount)
+	}
+	if sbc.ExecuteOptions != options {
+		t.Errorf("want %+v, got %+v", options, sbc.ExecuteOptions)
+	}
+
+	// retry error in a transaction: not retried, the vttablet
+	// may have lost the transaction
+	s.Reset()
+	sbc = &sandboxConn{mustFailRetry: 1}
+	s.MapTestConn("0", sbc)
+	sdc = NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnExecuteIdempotencyKey", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
+	if _, err := sdc.Execute(context.Background(), "query", nil, 1, options); err == nil {
+		t.Errorf("want error, got nil")
+	}
+	if sbc.ExecCount != 1 {
+		t.Errorf("want 1, got %v", sbc.ExecCount)
+	}
+
+	// conn errors are retried at most retryCount times
+	s.Reset()
+	sbc = &sandboxConn{mustFailConn: retryCount + 1}
+	s.MapTestConn("0", sbc)
+	sdc = NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnExecuteIdempotencyKey", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
+	if _, err := sdc.Execute(context.Background(), "query", nil, 1, options); err == nil {
+		t.Errorf("want error, got nil")
+	}
+	if count := sbc.ExecCount.Get(); count < 2 || count > int64(retryCount+1) {
+		t.Errorf("want between 2 and %v, got %v", retryCount+1, count)
+	}
+}
+
 func TestShardConnBeginOther(t *testing.T) {
 	// tx_pool_full
 	s := createSandbox("TestShardConnBeginOther")
 	sbc := &sandboxConn{mustFailTxPool: 1}
 	s.MapTestConn("0", sbc)
-	want := fmt.Sprintf("shard, host: TestShardConnBeginOther.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[]}, tx_pool_full: err")
+	want := fmt.Sprintf("shard, host: TestShardConnBeginOther.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[] SchemaVersion: Tags:map[]}, tx_pool_full: err")
 	sdc := NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnBeginOther", "0", "", 10*time.Millisecond, 3, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
-	_, err := sdc.Begin(context.Background())
+	_, err := sdc.Begin(context.Background(), nil)
 	if err == nil || err.Error() != want {
 		t.Errorf("want %v, got %v", want, err)
 	}
@@ -273,6 +318,40 @@ func TestShardConnBeginOther(t *testing.T) {
 	if sbc.ExecCount != 1 {
 		t.Errorf("want 1, got %v", sbc.ExecCount)
 	}
+	// The canonical code is kept.
+	if code := vterrors.RecoverVtErrorCode(err); code != vterrors.ResourceExhausted {
+		t.Errorf("want %v, got %v", vterrors.ResourceExhausted, code)
+	}
+}
+
+func TestShardConnMasterHint(t *testing.T) {
+	s := createSandbox("TestShardConnMasterHint")
+	oldMaster := &sandboxConn{}
+	s.MapTestConn("0", oldMaster)
+	sdc := NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnMasterHint", "0", topo.TYPE_MASTER, 10*time.Millisecond, 3, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
+	if _, err := sdc.Execute(context.Background(), "query", nil, 0, nil); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	// the old master is demoted, and redirects to the new one
+	newMaster := &sandboxConn{}
+	s.MapTestConn("0", newMaster)
+	oldMaster.mustFailNotMaster = 1
+	oldMaster.masterHint = &topo.EndPoint{Uid: 1, Host: "0", NamedPortMap: map[string]int{"vt": 1}}
+	endPointCounter := s.EndPointCounter
+	if _, err := sdc.Execute(context.Background(), "query", nil, 0, nil); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if oldMaster.ExecCount != 2 || newMaster.ExecCount != 1 {
+		t.Errorf("want 2 and 1 queries, got %v and %v", oldMaster.ExecCount, newMaster.ExecCount)
+	}
+	// The serving graph was not needed.
+	if s.EndPointCounter != endPointCounter {
+		t.Errorf("want %v, got %v", endPointCounter, s.EndPointCounter)
+	}
+	if s.DialCounter != 2 {
+		t.Errorf("want 2, got %v", s.DialCounter)
+	}
 }
 
 func TestShardConnStreamingRetry(t *testing.T) {
@@ -281,7 +360,7 @@ func TestShardConnStreamingRetry(t *testing.T) {
 	sbc := &sandboxConn{mustFailRetry: 1}
 	s.MapTestConn("0", sbc)
 	sdc := NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnStreamingRetry", "0", "", 10*time.Millisecond, 3, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
-	_, errfunc := sdc.StreamExecute(context.Background(), "query", nil, 0)
+	_, errfunc := sdc.StreamExecute(context.Background(), "query", nil, 0, nil)
 	err := errfunc()
 	if err != nil {
 		t.Errorf("want nil, got %v", err)
@@ -298,9 +377,9 @@ func TestShardConnStreamingRetry(t *testing.T) {
 	sbc = &sandboxConn{mustFailFatal: 1}
 	s.MapTestConn("0", sbc)
 	sdc = NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnStreamingRetry", "0", "", 10*time.Millisecond, 3, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
-	_, errfunc = sdc.StreamExecute(context.Background(), "query", nil, 0)
+	_, errfunc = sdc.StreamExecute(context.Background(), "query", nil, 0, nil)
 	err = errfunc()
-	want := "shard, host: TestShardConnStreamingRetry.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[]}, fatal: err"
+	want := "shard, host: TestShardConnStreamingRetry.0., {Uid:0 Host:0 NamedPortMap:map[vt:1] Health:map[] SchemaVersion: Tags:map[]}, fatal: err"
 	if err == nil || err.Error() != want {
 		t.Errorf("want %v, got %v", want, err)
 	}
@@ -320,7 +399,7 @@ func TestShardConnTimeout(t *testing.T) {
 	s.MapTestConn("0", sbc)
 	sdc := NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnTimeout", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
 	startTime := time.Now()
-	_, err := sdc.Execute(context.Background(), "query", nil, 0)
+	_, err := sdc.Execute(context.Background(), "query", nil, 0, nil)
 	execDuration := time.Now().Sub(startTime)
 	if execDuration < connTimeoutTotal {
 		t.Errorf("timeout too fast, want > %v, got %v", connTimeoutTotal, execDuration)
@@ -339,7 +418,7 @@ func TestShardConnTimeout(t *testing.T) {
 	s.MapTestConn("0", sbc)
 	sdc = NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnTimeout", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutTotal*3, 24*time.Hour, connectTimings)
 	startTime = time.Now()
-	_, err = sdc.Execute(context.Background(), "query", nil, 0)
+	_, err = sdc.Execute(context.Background(), "query", nil, 0, nil)
 	execDuration = time.Now().Sub(startTime)
 	if execDuration < connTimeoutTotal {
 		t.Errorf("timeout too fast, want > %v, got %v", connTimeoutTotal, execDuration)
@@ -361,7 +440,7 @@ func TestShardConnTimeout(t *testing.T) {
 	s.MapTestConn("0", sbc2)
 	sdc = NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnTimeout", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
 	startTime = time.Now()
-	_, err = sdc.Execute(context.Background(), "query", nil, 0)
+	_, err = sdc.Execute(context.Background(), "query", nil, 0, nil)
 	execDuration = time.Now().Sub(startTime)
 	if execDuration < connTimeoutPerConn {
 		t.Errorf("timeout too fast, want > %v, got %v", connTimeoutPerConn, execDuration)
@@ -383,7 +462,7 @@ func TestShardConnTimeout(t *testing.T) {
 	s.MapTestConn("0", sbc2)
 	sdc = NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnTimeout", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
 	startTime = time.Now()
-	_, err = sdc.Execute(context.Background(), "query", nil, 0)
+	_, err = sdc.Execute(context.Background(), "query", nil, 0, nil)
 	execDuration = time.Now().Sub(startTime)
 	if execDuration < connTimeoutTotal {
 		t.Errorf("timeout too fast, want > %v, got %v", connTimeoutTotal, execDuration)
@@ -403,7 +482,7 @@ func TestShardConnReconnect(t *testing.T) {
 	// case 1: resolved 0 endpoint, return error
 	sdc := NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnReconnect", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
 	startTime := time.Now()
-	_, err := sdc.Execute(context.Background(), "query", nil, 0)
+	_, err := sdc.Execute(context.Background(), "query", nil, 0, nil)
 	execDuration := time.Now().Sub(startTime)
 	if execDuration < (retryDelay * time.Duration(retryCount)) {
 		t.Errorf("retry too fast, want %v, got %v", retryDelay*time.Duration(retryCount), execDuration)
@@ -425,7 +504,7 @@ func TestShardConnReconnect(t *testing.T) {
 	s.MapTestConn("0", sbc)
 	sdc = NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnReconnect", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
 	timeStart := time.Now()
-	sdc.Execute(context.Background(), "query", nil, 0)
+	sdc.Execute(context.Background(), "query", nil, 0, nil)
 	timeDuration := time.Now().Sub(timeStart)
 	if timeDuration < retryDelay {
 		t.Errorf("want no spam delay %v, got %v", retryDelay, timeDuration)
@@ -443,7 +522,7 @@ func TestShardConnReconnect(t *testing.T) {
 	s.MapTestConn("0", sbc)
 	sdc = NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnReconnect", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
 	timeStart = time.Now()
-	sdc.Execute(context.Background(), "query", nil, 0)
+	sdc.Execute(context.Background(), "query", nil, 0, nil)
 	timeDuration = time.Now().Sub(timeStart)
 	if timeDuration < retryDelay {
 		t.Errorf("want no spam delay %v, got %v", retryDelay, timeDuration)
@@ -461,7 +540,7 @@ func TestShardConnReconnect(t *testing.T) {
 	s.MapTestConn("0", sbc)
 	sdc = NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnReconnect", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
 	timeStart = time.Now()
-	sdc.Execute(context.Background(), "query", nil, 0)
+	sdc.Execute(context.Background(), "query", nil, 0, nil)
 	timeDuration = time.Now().Sub(timeStart)
 	if timeDuration > retryDelay {
 		t.Errorf("want instant fail %v, got %v", retryDelay, timeDuration)
@@ -481,7 +560,7 @@ func TestShardConnReconnect(t *testing.T) {
 	s.MapTestConn("0", sbc2)
 	sdc = NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnReconnect", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
 	timeStart = time.Now()
-	sdc.Execute(context.Background(), "query", nil, 0)
+	sdc.Execute(context.Background(), "query", nil, 0, nil)
 	timeDuration = time.Now().Sub(timeStart)
 	if timeDuration >= retryDelay {
 		t.Errorf("want no delay, got %v", timeDuration)
@@ -510,7 +589,7 @@ func TestShardConnReconnect(t *testing.T) {
 	s.MapTestConn("0", sbc2)
 	sdc = NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnReconnect", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
 	timeStart = time.Now()
-	sdc.Execute(context.Background(), "query", nil, 0)
+	sdc.Execute(context.Background(), "query", nil, 0, nil)
 	timeDuration = time.Now().Sub(timeStart)
 	if timeDuration >= retryDelay {
 		t.Errorf("want no delay, got %v", timeDuration)
@@ -543,7 +622,7 @@ func TestShardConnReconnect(t *testing.T) {
 	s.MapTestConn("0", sbc2)
 	sdc = NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnReconnect", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
 	timeStart = time.Now()
-	sdc.Execute(context.Background(), "query", nil, 0)
+	sdc.Execute(context.Background(), "query", nil, 0, nil)
 	timeDuration = time.Now().Sub(timeStart)
 	if timeDuration >= retryDelay {
 		t.Errorf("want no delay, got %v", timeDuration)
@@ -576,7 +655,7 @@ func TestShardConnReconnect(t *testing.T) {
 	s.MapTestConn("0", sbc2)
 	sdc = NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnReconnect", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
 	timeStart = time.Now()
-	sdc.Execute(context.Background(), "query", nil, 0)
+	sdc.Execute(context.Background(), "query", nil, 0, nil)
 	timeDuration = time.Now().Sub(timeStart)
 	if timeDuration < retryDelay {
 		t.Errorf("want no spam delay %v, got %v", retryDelay, timeDuration)
@@ -623,7 +702,7 @@ func TestShardConnReconnect(t *testing.T) {
 	}
 	sdc = NewShardConn(context.Background(), &sandboxTopo{callbackGetEndPoints: onGetEndPoints}, "aa", "TestShardConnReconnect", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
 	timeStart = time.Now()
-	sdc.Execute(context.Background(), "query", nil, 0)
+	sdc.Execute(context.Background(), "query", nil, 0, nil)
 	timeDuration = time.Now().Sub(timeStart)
 	if timeDuration >= retryDelay {
 		t.Errorf("want no delay, got %v", timeDuration)
@@ -673,7 +752,7 @@ func TestShardConnReconnect(t *testing.T) {
 	}
 	sdc = NewShardConn(context.Background(), &sandboxTopo{callbackGetEndPoints: onGetEndPoints}, "aa", "TestShardConnReconnect", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
 	timeStart = time.Now()
-	sdc.Execute(context.Background(), "query", nil, 0)
+	sdc.Execute(context.Background(), "query", nil, 0, nil)
 	timeDuration = time.Now().Sub(timeStart)
 	if timeDuration < retryDelay {
 		t.Errorf("want no spam delay %v, got %v", retryDelay, timeDuration)
@@ -729,7 +808,7 @@ func TestShardConnReconnect(t *testing.T) {
 	}
 	sdc = NewShardConn(context.Background(), &sandboxTopo{callbackGetEndPoints: onGetEndPoints}, "aa", "TestShardConnReconnect", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 24*time.Hour, connectTimings)
 	timeStart = time.Now()
-	sdc.Execute(context.Background(), "query", nil, 0)
+	sdc.Execute(context.Background(), "query", nil, 0, nil)
 	timeDuration = time.Now().Sub(timeStart)
 	if timeDuration >= retryDelay {
 		t.Errorf("want no delay, got %v", timeDuration)
@@ -757,12 +836,12 @@ func TestShardConnLife(t *testing.T) {
 	sbc := &sandboxConn{}
 	s.MapTestConn("0", sbc)
 	sdc := NewShardConn(context.Background(), new(sandboxTopo), "aa", "TestShardConnReconnect", "0", "", retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, 10*time.Millisecond, connectTimings)
-	sdc.Execute(context.Background(), "query", nil, 0)
+	sdc.Execute(context.Background(), "query", nil, 0, nil)
 	if s.DialCounter != 1 {
 		t.Errorf("DialCounter: %d, want 1", s.DialCounter)
 	}
 	time.Sleep(20 * time.Millisecond)
-	sdc.Execute(context.Background(), "query", nil, 0)
+	sdc.Execute(context.Background(), "query", nil, 0, nil)
 	if s.DialCounter != 2 {
 		t.Errorf("DialCounter: %d, want 2", s.DialCounter)
 	}
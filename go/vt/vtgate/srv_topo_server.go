@@ -625,10 +625,15 @@ func (st *EndPointsCacheStatus) StatusAsHTML() template.HTML {
 	if st.Value != nil {
 		vl = len(st.Value.Entries)
 	}
-	// Assemble links to individual endpoints
+	// Assemble links to individual endpoints, with their schema
+	// version as title
 	epLinks := "{ "
+	schemaVersions := make(map[string]bool)
 	if ovl > 0 {
 		for _, ove := range st.OriginalValue.Entries {
+			if ove.SchemaVersion != "" {
+				schemaVersions[ove.SchemaVersion] = true
+			}
 			healthColor := "red"
 			vtPort := 0
 			if vl > 0 {
@@ -647,11 +652,15 @@ func (st *EndPointsCacheStatus) StatusAsHTML() template.HTML {
 				}
 			}
 			epLinks += fmt.Sprintf(
-				"<a href=\"http://%v:%d\" style=\"color:%v\">%v:%d</a> ",
-				ove.Host, vtPort, healthColor, ove.Host, vtPort)
+				"<a href=\"http://%v:%d\" style=\"color:%v\" title=\"schema version %v\">%v:%d</a> ",
+				ove.Host, vtPort, healthColor, ove.SchemaVersion, ove.Host, vtPort)
 		}
 	}
 	epLinks += "}"
+	if len(schemaVersions) > 1 {
+		// some endpoints haven't picked up a schema change yet
+		epLinks += fmt.Sprintf(", <b>%v different schema versions</b>", len(schemaVersions))
+	}
 	if ovl == vl {
 		if vl == 0 {
 			return template.HTML(fmt.Sprintf("<b>No healthy endpoints</b>, %v", epLinks))
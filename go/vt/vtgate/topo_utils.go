@@ -9,6 +9,7 @@ import (
 
 	"github.com/youtube/vitess/go/vt/key"
 	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/vtgate/planbuilder"
 	"github.com/youtube/vitess/go/vt/vtgate/proto"
 	"golang.org/x/net/context"
 )
@@ -84,6 +85,47 @@ func mapEntityIdsToShards(ctx context.Context, topoServ SrvTopoServer, cell, key
 	return keyspace, shards, nil
 }
 
+// computeEntityKeyspaceIds fills the missing keyspace ids of entityIds
+// by mapping their external ids with the sharding scheme of the
+// keyspace.
+func computeEntityKeyspaceIds(ctx context.Context, topoServ SrvTopoServer, cell, keyspace string, entityIds []proto.EntityId, vcursor planbuilder.VCursor) error {
+	var missing []int
+	for i, eid := range entityIds {
+		if eid.KeyspaceID == "" {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	srvKeyspace, err := topoServ.GetSrvKeyspace(ctx, cell, keyspace)
+	if err != nil {
+		return fmt.Errorf("keyspace %v fetch error: %v", keyspace, err)
+	}
+	if srvKeyspace.ShardingScheme == "" {
+		return fmt.Errorf("keyspace id of entity %v is missing and keyspace %v has no sharding scheme", entityIds[missing[0]].ExternalID, keyspace)
+	}
+	scheme, err := planbuilder.CreateShardingScheme(srvKeyspace.ShardingScheme, srvKeyspace.ShardingSchemeParams)
+	if err != nil {
+		return fmt.Errorf("keyspace %v: %v", keyspace, err)
+	}
+	ids := make([]interface{}, len(missing))
+	for i, index := range missing {
+		ids[i] = entityIds[index].ExternalID
+	}
+	ksids, err := scheme.Map(vcursor, ids)
+	if err != nil {
+		return err
+	}
+	for i, index := range missing {
+		if ksids[i] == "" {
+			return fmt.Errorf("sharding scheme %v has no keyspace id for entity %v", srvKeyspace.ShardingScheme, ids[i])
+		}
+		entityIds[index].KeyspaceID = ksids[i]
+	}
+	return nil
+}
+
 // This function implements the restriction of handling one keyrange
 // and one shard since streaming doesn't support merge sorting the results.
 // The input/output api is generic though.
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net/http"
 	"strings"
 	"time"
 
@@ -17,10 +18,10 @@ import (
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/stats"
 	"github.com/youtube/vitess/go/sync2"
-	"github.com/youtube/vitess/go/tb"
 	kproto "github.com/youtube/vitess/go/vt/key"
 	"github.com/youtube/vitess/go/vt/logutil"
 	"github.com/youtube/vitess/go/vt/servenv"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
 	"github.com/youtube/vitess/go/vt/topo"
 	"github.com/youtube/vitess/go/vt/vtgate/planbuilder"
 	"github.com/youtube/vitess/go/vt/vtgate/proto"
@@ -88,6 +89,12 @@ func Init(serv SrvTopoServer, schema *planbuilder.Schema, cell string, retryDela
 	if rpcVTGate != nil {
 		log.Fatalf("VTGate already initialized")
 	}
+	if err := initKeyspaceCharsets(*keyspaceCharsetsFlag); err != nil {
+		log.Fatalf("invalid -keyspace_charsets: %v", err)
+	}
+	if err := initReplicationLagBounds(*replicationLagBoundsFlag); err != nil {
+		log.Fatalf("invalid -replication_lag_bounds: %v", err)
+	}
 	rpcVTGate = &VTGate{
 		resolver:     NewResolver(serv, "VttabletCall", cell, retryDelay, retryCount, connTimeoutTotal, connTimeoutPerConn, connLife),
 		timings:      stats.NewMultiTimings("VtgateApi", []string{"Operation", "Keyspace", "DbType"}),
@@ -110,6 +117,7 @@ func Init(serv SrvTopoServer, schema *planbuilder.Schema, cell string, retryDela
 	}
 	// Resuse resolver's scatterConn.
 	rpcVTGate.router = NewRouter(serv, cell, schema, "VTGateRouter", rpcVTGate.resolver.scatterConn)
+	http.Handle("/queryz", rpcVTGate.router.queryStats)
 	normalErrors = stats.NewMultiCounters("VtgateApiErrorCounts", []string{"Operation", "Keyspace", "DbType"})
 	infoErrors = stats.NewCounters("VtgateInfoErrorCounts")
 	internalErrors = stats.NewCounters("VtgateInternalErrorCounts")
@@ -125,6 +133,22 @@ func Init(serv SrvTopoServer, schema *planbuilder.Schema, cell string, retryDela
 	for _, f := range RegisterVTGates {
 		f(rpcVTGate)
 	}
+	servenv.OnShutdown(servenv.ShutdownDrain, rpcVTGate.drain)
+}
+
+// drain waits until no request is in flight, or the context is done.
+func (vtg *VTGate) drain(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for vtg.inFlight.Get() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Warningf("Stopping with %v requests still in flight", vtg.inFlight.Get())
+			return
+		}
+	}
+	log.Infof("All in-flight requests finished")
 }
 
 // InitializeConnections pre-initializes VTGate by connecting to vttablets of all keyspace/shard/type.
@@ -145,10 +169,20 @@ func (vtg *VTGate) InitializeConnections(ctx context.Context) (err error) {
 
 // Execute executes a non-streaming query by routing based on the values in the query.
 func (vtg *VTGate) Execute(ctx context.Context, query *proto.Query, reply *proto.QueryResult) error {
+	query.TabletType = readYourWritesTabletType(query.Session, query.TabletType)
 	startTime := time.Now()
 	statsKey := []string{"Execute", "Any", string(query.TabletType)}
 	defer vtg.timings.Record(statsKey, startTime)
 
+	if qr, err := sessionFunctionResult(query.Sql, query.Session); qr != nil || err != nil {
+		reply.Result = qr
+		if err != nil {
+			reply.Error = err.Error()
+		}
+		reply.Session = query.Session
+		return nil
+	}
+
 	x := vtg.inFlight.Add(1)
 	defer vtg.inFlight.Add(-1)
 	if 0 < vtg.maxInFlight && vtg.maxInFlight < x {
@@ -159,6 +193,7 @@ func (vtg *VTGate) Execute(ctx context.Context, query *proto.Query, reply *proto
 	if err == nil {
 		reply.Result = qr
 		vtg.rowsReturned.Add(statsKey, int64(len(qr.Rows)))
+		recordSessionFunctions(query.Session, qr)
 	} else {
 		reply.Error = handleExecuteError(err, statsKey, query, vtg.logExecute)
 	}
@@ -168,10 +203,20 @@ func (vtg *VTGate) Execute(ctx context.Context, query *proto.Query, reply *proto
 
 // ExecuteShard executes a non-streaming query on the specified shards.
 func (vtg *VTGate) ExecuteShard(ctx context.Context, query *proto.QueryShard, reply *proto.QueryResult) error {
+	query.TabletType = readYourWritesTabletType(query.Session, query.TabletType)
 	startTime := time.Now()
 	statsKey := []string{"ExecuteShard", query.Keyspace, string(query.TabletType)}
 	defer vtg.timings.Record(statsKey, startTime)
 
+	if qr, err := sessionFunctionResult(query.Sql, query.Session); qr != nil || err != nil {
+		reply.Result = qr
+		if err != nil {
+			reply.Error = err.Error()
+		}
+		reply.Session = query.Session
+		return nil
+	}
+
 	x := vtg.inFlight.Add(1)
 	defer vtg.inFlight.Add(-1)
 	if 0 < vtg.maxInFlight && vtg.maxInFlight < x {
@@ -185,6 +230,7 @@ func (vtg *VTGate) ExecuteShard(ctx context.Context, query *proto.QueryShard, re
 		query.Keyspace,
 		query.TabletType,
 		query.Session,
+		&tproto.ExecuteOptions{FieldsOnly: query.FieldsOnly, IdempotencyKey: query.IdempotencyKey},
 		func(keyspace string) (string, []string, error) {
 			return query.Keyspace, query.Shards, nil
 		},
@@ -192,6 +238,7 @@ func (vtg *VTGate) ExecuteShard(ctx context.Context, query *proto.QueryShard, re
 	if err == nil {
 		reply.Result = qr
 		vtg.rowsReturned.Add(statsKey, int64(len(qr.Rows)))
+		recordSessionFunctions(query.Session, qr)
 	} else {
 		reply.Error = handleExecuteError(err, statsKey, query, vtg.logExecuteShard)
 	}
@@ -201,10 +248,20 @@ func (vtg *VTGate) ExecuteShard(ctx context.Context, query *proto.QueryShard, re
 
 // ExecuteKeyspaceIds executes a non-streaming query based on the specified keyspace ids.
 func (vtg *VTGate) ExecuteKeyspaceIds(ctx context.Context, query *proto.KeyspaceIdQuery, reply *proto.QueryResult) error {
+	query.TabletType = readYourWritesTabletType(query.Session, query.TabletType)
 	startTime := time.Now()
 	statsKey := []string{"ExecuteKeyspaceIds", query.Keyspace, string(query.TabletType)}
 	defer vtg.timings.Record(statsKey, startTime)
 
+	if qr, err := sessionFunctionResult(query.Sql, query.Session); qr != nil || err != nil {
+		reply.Result = qr
+		if err != nil {
+			reply.Error = err.Error()
+		}
+		reply.Session = query.Session
+		return nil
+	}
+
 	x := vtg.inFlight.Add(1)
 	defer vtg.inFlight.Add(-1)
 	if 0 < vtg.maxInFlight && vtg.maxInFlight < x {
@@ -215,6 +272,7 @@ func (vtg *VTGate) ExecuteKeyspaceIds(ctx context.Context, query *proto.Keyspace
 	if err == nil {
 		reply.Result = qr
 		vtg.rowsReturned.Add(statsKey, int64(len(qr.Rows)))
+		recordSessionFunctions(query.Session, qr)
 	} else {
 		reply.Error = handleExecuteError(err, statsKey, query, vtg.logExecuteKeyspaceIds)
 	}
@@ -224,10 +282,20 @@ func (vtg *VTGate) ExecuteKeyspaceIds(ctx context.Context, query *proto.Keyspace
 
 // ExecuteKeyRanges executes a non-streaming query based on the specified keyranges.
 func (vtg *VTGate) ExecuteKeyRanges(ctx context.Context, query *proto.KeyRangeQuery, reply *proto.QueryResult) error {
+	query.TabletType = readYourWritesTabletType(query.Session, query.TabletType)
 	startTime := time.Now()
 	statsKey := []string{"ExecuteKeyRanges", query.Keyspace, string(query.TabletType)}
 	defer vtg.timings.Record(statsKey, startTime)
 
+	if qr, err := sessionFunctionResult(query.Sql, query.Session); qr != nil || err != nil {
+		reply.Result = qr
+		if err != nil {
+			reply.Error = err.Error()
+		}
+		reply.Session = query.Session
+		return nil
+	}
+
 	x := vtg.inFlight.Add(1)
 	defer vtg.inFlight.Add(-1)
 	if 0 < vtg.maxInFlight && vtg.maxInFlight < x {
@@ -238,6 +306,7 @@ func (vtg *VTGate) ExecuteKeyRanges(ctx context.Context, query *proto.KeyRangeQu
 	if err == nil {
 		reply.Result = qr
 		vtg.rowsReturned.Add(statsKey, int64(len(qr.Rows)))
+		recordSessionFunctions(query.Session, qr)
 	} else {
 		reply.Error = handleExecuteError(err, statsKey, query, vtg.logExecuteKeyRanges)
 	}
@@ -247,20 +316,37 @@ func (vtg *VTGate) ExecuteKeyRanges(ctx context.Context, query *proto.KeyRangeQu
 
 // ExecuteEntityIds excutes a non-streaming query based on given KeyspaceId map.
 func (vtg *VTGate) ExecuteEntityIds(ctx context.Context, query *proto.EntityIdsQuery, reply *proto.QueryResult) error {
+	query.TabletType = readYourWritesTabletType(query.Session, query.TabletType)
 	startTime := time.Now()
 	statsKey := []string{"ExecuteEntityIds", query.Keyspace, string(query.TabletType)}
 	defer vtg.timings.Record(statsKey, startTime)
 
+	if qr, err := sessionFunctionResult(query.Sql, query.Session); qr != nil || err != nil {
+		reply.Result = qr
+		if err != nil {
+			reply.Error = err.Error()
+		}
+		reply.Session = query.Session
+		return nil
+	}
+
 	x := vtg.inFlight.Add(1)
 	defer vtg.inFlight.Add(-1)
 	if 0 < vtg.maxInFlight && vtg.maxInFlight < x {
 		return errTooManyInFlight
 	}
 
-	qr, err := vtg.resolver.ExecuteEntityIds(ctx, query)
+	// the lookups of the sharding scheme run in the session
+	vcursor := newRequestContext(ctx, &proto.Query{TabletType: query.TabletType, Session: query.Session}, vtg.router)
+	err := computeEntityKeyspaceIds(ctx, vtg.resolver.scatterConn.toposerv, vtg.resolver.scatterConn.cell, query.Keyspace, query.EntityKeyspaceIDs, vcursor)
+	var qr *mproto.QueryResult
+	if err == nil {
+		qr, err = vtg.resolver.ExecuteEntityIds(ctx, query)
+	}
 	if err == nil {
 		reply.Result = qr
 		vtg.rowsReturned.Add(statsKey, int64(len(qr.Rows)))
+		recordSessionFunctions(query.Session, qr)
 	} else {
 		reply.Error = handleExecuteError(err, statsKey, query, vtg.logExecuteEntityIds)
 	}
@@ -270,6 +356,7 @@ func (vtg *VTGate) ExecuteEntityIds(ctx context.Context, query *proto.EntityIdsQ
 
 // ExecuteBatchShard executes a group of queries on the specified shards.
 func (vtg *VTGate) ExecuteBatchShard(ctx context.Context, batchQuery *proto.BatchQueryShard, reply *proto.QueryResultList) error {
+	batchQuery.TabletType = readYourWritesTabletType(batchQuery.Session, batchQuery.TabletType)
 	startTime := time.Now()
 	statsKey := []string{"ExecuteBatchShard", batchQuery.Keyspace, string(batchQuery.TabletType)}
 	defer vtg.timings.Record(statsKey, startTime)
@@ -295,6 +382,7 @@ func (vtg *VTGate) ExecuteBatchShard(ctx context.Context, batchQuery *proto.Batc
 		var rowCount int64
 		for _, qr := range qrs.List {
 			rowCount += int64(len(qr.Rows))
+			recordSessionFunctions(batchQuery.Session, &qr)
 		}
 		vtg.rowsReturned.Add(statsKey, rowCount)
 	} else {
@@ -306,6 +394,7 @@ func (vtg *VTGate) ExecuteBatchShard(ctx context.Context, batchQuery *proto.Batc
 
 // ExecuteBatchKeyspaceIds executes a group of queries based on the specified keyspace ids.
 func (vtg *VTGate) ExecuteBatchKeyspaceIds(ctx context.Context, query *proto.KeyspaceIdBatchQuery, reply *proto.QueryResultList) error {
+	query.TabletType = readYourWritesTabletType(query.Session, query.TabletType)
 	startTime := time.Now()
 	statsKey := []string{"ExecuteBatchKeyspaceIds", query.Keyspace, string(query.TabletType)}
 	defer vtg.timings.Record(statsKey, startTime)
@@ -324,6 +413,7 @@ func (vtg *VTGate) ExecuteBatchKeyspaceIds(ctx context.Context, query *proto.Key
 		var rowCount int64
 		for _, qr := range qrs.List {
 			rowCount += int64(len(qr.Rows))
+			recordSessionFunctions(query.Session, &qr)
 		}
 		vtg.rowsReturned.Add(statsKey, rowCount)
 	} else {
@@ -335,6 +425,7 @@ func (vtg *VTGate) ExecuteBatchKeyspaceIds(ctx context.Context, query *proto.Key
 
 // StreamExecute executes a streaming query by routing based on the values in the query.
 func (vtg *VTGate) StreamExecute(ctx context.Context, query *proto.Query, sendReply func(*proto.QueryResult) error) error {
+	query.TabletType = readYourWritesTabletType(query.Session, query.TabletType)
 	startTime := time.Now()
 	statsKey := []string{"StreamExecute", "Any", string(query.TabletType)}
 	defer vtg.timings.Record(statsKey, startTime)
@@ -377,6 +468,7 @@ func (vtg *VTGate) StreamExecute(ctx context.Context, query *proto.Query, sendRe
 // response which is needed for checkpointing.
 // The api supports supplying multiple KeyspaceIds to make it future proof.
 func (vtg *VTGate) StreamExecuteKeyspaceIds(ctx context.Context, query *proto.KeyspaceIdQuery, sendReply func(*proto.QueryResult) error) error {
+	query.TabletType = readYourWritesTabletType(query.Session, query.TabletType)
 	startTime := time.Now()
 	statsKey := []string{"StreamExecuteKeyspaceIds", query.Keyspace, string(query.TabletType)}
 	defer vtg.timings.Record(statsKey, startTime)
@@ -419,6 +511,7 @@ func (vtg *VTGate) StreamExecuteKeyspaceIds(ctx context.Context, query *proto.Ke
 // response which is needed for checkpointing.
 // The api supports supplying multiple keyranges to make it future proof.
 func (vtg *VTGate) StreamExecuteKeyRanges(ctx context.Context, query *proto.KeyRangeQuery, sendReply func(*proto.QueryResult) error) error {
+	query.TabletType = readYourWritesTabletType(query.Session, query.TabletType)
 	startTime := time.Now()
 	statsKey := []string{"StreamExecuteKeyRanges", query.Keyspace, string(query.TabletType)}
 	defer vtg.timings.Record(statsKey, startTime)
@@ -456,6 +549,7 @@ func (vtg *VTGate) StreamExecuteKeyRanges(ctx context.Context, query *proto.KeyR
 
 // StreamExecuteShard executes a streaming query on the specified shards.
 func (vtg *VTGate) StreamExecuteShard(ctx context.Context, query *proto.QueryShard, sendReply func(*proto.QueryResult) error) error {
+	query.TabletType = readYourWritesTabletType(query.Session, query.TabletType)
 	startTime := time.Now()
 	statsKey := []string{"StreamExecuteShard", query.Keyspace, string(query.TabletType)}
 	defer vtg.timings.Record(statsKey, startTime)
@@ -474,6 +568,7 @@ func (vtg *VTGate) StreamExecuteShard(ctx context.Context, query *proto.QuerySha
 		query.Keyspace,
 		query.TabletType,
 		query.Session,
+		&tproto.ExecuteOptions{FieldsOnly: query.FieldsOnly},
 		func(keyspace string) (string, []string, error) {
 			return query.Keyspace, query.Shards, nil
 		},
@@ -521,11 +616,17 @@ func (vtg *VTGate) Rollback(ctx context.Context, inSession *proto.Session) error
 // greater than or equal to SplitQueryRequest.SplitCount, where N is the
 // number of shards.
 func (vtg *VTGate) SplitQuery(ctx context.Context, req *proto.SplitQueryRequest, reply *proto.SplitQueryResult) error {
+	if req.SplitCount < 1 {
+		return formatError(fmt.Errorf("SplitQuery: split count must be at least 1, got %v", req.SplitCount))
+	}
 	sc := vtg.resolver.scatterConn
 	keyspace, shards, err := getKeyspaceShards(ctx, sc.toposerv, sc.cell, req.Keyspace, topo.TYPE_RDONLY)
 	if err != nil {
 		return err
 	}
+	if len(shards) == 0 {
+		return formatError(fmt.Errorf("SplitQuery: keyspace %v has no rdonly shards", req.Keyspace))
+	}
 	keyRangeByShard := map[string]kproto.KeyRange{}
 	for _, shard := range shards {
 		keyRangeByShard[shard.Name] = shard.KeyRange
@@ -539,6 +640,17 @@ func (vtg *VTGate) SplitQuery(ctx context.Context, req *proto.SplitQueryRequest,
 	return nil
 }
 
+// Explain returns the plan of a query without executing it: its
+// routing, and its plan on the first shard it's routed to.
+func (vtg *VTGate) Explain(ctx context.Context, query *proto.Query, reply *proto.ExplainResult) error {
+	result, err := vtg.router.Explain(ctx, query)
+	if err != nil {
+		return formatError(err)
+	}
+	*reply = *result
+	return nil
+}
+
 func handleExecuteError(err error, statsKey []string, query interface{}, logger *logutil.ThrottledLogger) string {
 	errStr := err.Error() + ", vtgate: " + servenv.ListeningURL.String()
 	if strings.Contains(errStr, errDupKey) {
@@ -562,7 +674,7 @@ func formatError(err error) error {
 // HandlePanic recovers from panics, and logs / increment counters
 func (vtg *VTGate) HandlePanic(err *error) {
 	if x := recover(); x != nil {
-		log.Errorf("Uncaught panic:\n%v\n%s", x, tb.Stack(4))
+		servenv.RecordPanic("vtgate", x)
 		*err = fmt.Errorf("uncaught panic: %v, vtgate: %v", x, servenv.ListeningURL.String())
 		internalErrors.Add("Panic", 1)
 	}
@@ -7,6 +7,7 @@ package vtgate
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -72,6 +73,7 @@ func TestVTGateExecute(t *testing.T) {
 			TabletType:    topo.TYPE_MASTER,
 			TransactionId: 1,
 		}},
+		RowCount: -1,
 	}
 	if !reflect.DeepEqual(wantSession, q.Session) {
 		t.Errorf("want \n%+v, got \n%+v", wantSession, q.Session)
@@ -124,6 +126,7 @@ func TestVTGateExecuteShard(t *testing.T) {
 			Shard:         "0",
 			TransactionId: 1,
 		}},
+		RowCount: -1,
 	}
 	if !reflect.DeepEqual(wantSession, q.Session) {
 		t.Errorf("want \n%+v, got \n%+v", wantSession, q.Session)
@@ -147,6 +150,35 @@ func TestVTGateExecuteShard(t *testing.T) {
 	*/
 }
 
+func TestVTGateExecuteShardFieldsOnly(t *testing.T) {
+	sandbox := createSandbox("TestVTGateExecuteShardFieldsOnly")
+	sbc := &sandboxConn{}
+	sandbox.MapTestConn("0", sbc)
+	q := proto.QueryShard{
+		Sql:        "query",
+		Keyspace:   "TestVTGateExecuteShardFieldsOnly",
+		Shards:     []string{"0"},
+		FieldsOnly: true,
+	}
+	qr := new(proto.QueryResult)
+	if err := rpcVTGate.ExecuteShard(context.Background(), &q, qr); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+	if sbc.ExecuteOptions == nil || !sbc.ExecuteOptions.FieldsOnly {
+		t.Errorf("ExecuteShard options: want FieldsOnly, got %+v", sbc.ExecuteOptions)
+	}
+
+	err := rpcVTGate.StreamExecuteShard(context.Background(), &q, func(r *proto.QueryResult) error {
+		return nil
+	})
+	if err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+	if sbc.ExecuteOptions == nil || !sbc.ExecuteOptions.FieldsOnly {
+		t.Errorf("StreamExecuteShard options: want FieldsOnly, got %+v", sbc.ExecuteOptions)
+	}
+}
+
 func TestVTGateExecuteKeyspaceIds(t *testing.T) {
 	s := createSandbox("TestVTGateExecuteKeyspaceIds")
 	sbc1 := &sandboxConn{}
@@ -195,6 +227,7 @@ func TestVTGateExecuteKeyspaceIds(t *testing.T) {
 			TransactionId: 1,
 			TabletType:    topo.TYPE_MASTER,
 		}},
+		RowCount: -1,
 	}
 	if !reflect.DeepEqual(wantSession, q.Session) {
 		t.Errorf("want \n%+v, got \n%+v", wantSession, q.Session)
@@ -263,6 +296,7 @@ func TestVTGateExecuteKeyRanges(t *testing.T) {
 			TransactionId: 1,
 			TabletType:    topo.TYPE_MASTER,
 		}},
+		RowCount: -1,
 	}
 	if !reflect.DeepEqual(wantSession, q.Session) {
 		t.Errorf("want \n%+v, got \n%+v", wantSession, q.Session)
@@ -334,6 +368,7 @@ func TestVTGateExecuteEntityIds(t *testing.T) {
 			TransactionId: 1,
 			TabletType:    topo.TYPE_MASTER,
 		}},
+		RowCount: -1,
 	}
 	if !reflect.DeepEqual(wantSession, q.Session) {
 		t.Errorf("want \n%+v, got \n%+v", wantSession, q.Session)
@@ -354,6 +389,48 @@ func TestVTGateExecuteEntityIds(t *testing.T) {
 	}
 }
 
+func TestVTGateExecuteEntityIdsShardingScheme(t *testing.T) {
+	s := createSandbox("TestVTGateExecuteEntityIdsShardingScheme")
+	sbc1 := &sandboxConn{}
+	s.MapTestConn("-20", sbc1)
+	q := proto.EntityIdsQuery{
+		Sql:              "query",
+		Keyspace:         "TestVTGateExecuteEntityIdsShardingScheme",
+		EntityColumnName: "id",
+		EntityKeyspaceIDs: []proto.EntityId{
+			proto.EntityId{
+				ExternalID: int64(1),
+			},
+		},
+		TabletType: topo.TYPE_MASTER,
+	}
+
+	// Without a sharding scheme, the keyspace ids are required.
+	qr := new(proto.QueryResult)
+	if err := rpcVTGate.ExecuteEntityIds(context.Background(), &q, qr); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+	want := "keyspace id of entity 1 is missing and keyspace TestVTGateExecuteEntityIdsShardingScheme has no sharding scheme"
+	if !strings.Contains(qr.Error, want) {
+		t.Errorf("want %v, got %v", want, qr.Error)
+	}
+
+	s.ShardingScheme = "hash"
+	qr = new(proto.QueryResult)
+	if err := rpcVTGate.ExecuteEntityIds(context.Background(), &q, qr); err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+	if qr.Error != "" {
+		t.Fatalf("want no error, got %v", qr.Error)
+	}
+	if got := q.EntityKeyspaceIDs[0].KeyspaceID.Hex(); got != "166b40b44aba4bd6" {
+		t.Errorf("want 166b40b44aba4bd6, got %v", got)
+	}
+	if sbc1.ExecCount.Get() != 1 {
+		t.Errorf("want 1, got %v", sbc1.ExecCount.Get())
+	}
+}
+
 func TestVTGateExecuteBatchShard(t *testing.T) {
 	s := createSandbox("TestVTGateExecuteBatchShard")
 	s.MapTestConn("-20", &sandboxConn{})
@@ -733,3 +810,20 @@ func TestVTGateSplitQuery(t *testing.T) {
 		t.Errorf("splits contain the wrong sqls and/or keyranges, got: %v, want: %v", actualSqlsByKeyRange, expectedSqlsByKeyRange)
 	}
 }
+
+func TestVTGateSplitQueryInvalidSplitCount(t *testing.T) {
+	keyspace := "TestVTGateSplitQueryInvalidSplitCount"
+	createSandbox(keyspace)
+	req := proto.SplitQueryRequest{
+		Keyspace: keyspace,
+		Query: tproto.BoundQuery{
+			Sql: "select col1, col2 from table",
+		},
+		SplitCount: 0,
+	}
+	err := rpcVTGate.SplitQuery(context.Background(), &req, new(proto.SplitQueryResult))
+	want := "split count must be at least 1"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("SplitQuery with a split count of 0 returned %v, want %v", err, want)
+	}
+}
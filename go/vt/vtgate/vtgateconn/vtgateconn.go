@@ -59,6 +59,13 @@ type VTGateConn interface {
 
 	// Begin starts a transaction and returns a VTGateTX.
 	Begin(ctx context.Context) (VTGateTx, error)
+	// BeginWithOptions starts a transaction with the given isolation
+	// level and access mode, and returns a VTGateTX.
+	BeginWithOptions(ctx context.Context, options *tproto.TransactionOptions) (VTGateTx, error)
+
+	// NewSession returns a VTGateSession, whose queries outside of
+	// the transactions share a vtgate session.
+	NewSession(options SessionOptions) VTGateSession
 
 	// Close must be called for releasing resources.
 	Close()
@@ -66,6 +73,10 @@ type VTGateConn interface {
 	// SplitQuery splits a query into equally sized smaller queries by
 	// appending primary key range clauses to the original query
 	SplitQuery(ctx context.Context, keyspace string, query tproto.BoundQuery, splitCount int) ([]proto.SplitQueryPart, error)
+
+	// Explain returns the plan of a query without executing it: its
+	// routing, and its plan on the first shard it's routed to.
+	Explain(ctx context.Context, query string, bindVars map[string]interface{}, tabletType topo.TabletType) (*proto.ExplainResult, error)
 }
 
 // VTGateTx defines the interface for the transaction object created by Begin.
@@ -82,6 +93,36 @@ type VTGateTx interface {
 	Rollback(ctx context.Context) error
 }
 
+// SessionOptions are the options of a VTGateSession.
+type SessionOptions struct {
+	// ReadYourWrites sends the reads to the master for a while
+	// after a write, see -read_your_writes_window in vtgate.
+	ReadYourWrites bool
+	// MaxReplicationLag is the replication lag the reads from the
+	// replicas tolerate, 0 for no limit.
+	MaxReplicationLag time.Duration
+}
+
+// Session returns the vtgate session a VTGateSession starts with.
+func (options SessionOptions) Session() *proto.Session {
+	return &proto.Session{
+		ReadYourWrites:    options.ReadYourWrites,
+		MaxReplicationLag: int64(options.MaxReplicationLag),
+	}
+}
+
+// VTGateSession defines the interface for the queries that share a
+// vtgate session outside of the transactions. vtgate keeps the state
+// of the client in the session: the time of its last write in
+// ReadYourWrites mode, and the values of LAST_INSERT_ID() and
+// ROW_COUNT(). It should not be concurrently used across goroutines.
+type VTGateSession interface {
+	// Execute executes a non-streaming query on vtgate in the session.
+	Execute(ctx context.Context, query string, bindVars map[string]interface{}, tabletType topo.TabletType) (*mproto.QueryResult, error)
+	// ExecuteShard executes a non-streaming query for multiple shards on vtgate in the session.
+	ExecuteShard(ctx context.Context, query string, keyspace string, shards []string, bindVars map[string]interface{}, tabletType topo.TabletType) (*mproto.QueryResult, error)
+}
+
 // ErrFunc is used to check for streaming errors.
 type ErrFunc func() error
 
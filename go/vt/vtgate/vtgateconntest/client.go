@@ -12,6 +12,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/sqltypes"
@@ -204,6 +205,18 @@ func (f *fakeVTGateService) SplitQuery(ctx context.Context, req *proto.SplitQuer
 	return nil
 }
 
+// Explain is part of the VTGateService interface
+func (f *fakeVTGateService) Explain(ctx context.Context, query *proto.Query, reply *proto.ExplainResult) error {
+	if f.panics {
+		panic(fmt.Errorf("test forced panic"))
+	}
+	if !reflect.DeepEqual(query, explainQuery) {
+		f.t.Errorf("Explain has wrong input: got %#v wanted %#v", query, explainQuery)
+	}
+	*reply = *explainResult
+	return nil
+}
+
 // CreateFakeServer returns the fake server for the tests
 func CreateFakeServer(t *testing.T) vtgateservice.VTGateService {
 	return &fakeVTGateService{
@@ -225,8 +238,11 @@ func TestSuite(t *testing.T, conn vtgateconn.VTGateConn, fakeServer vtgateservic
 	testExecuteShard(t, conn)
 	testStreamExecute(t, conn)
 	testTxPass(t, conn)
+	testTxOptions(t, conn)
 	testTxFail(t, conn)
+	testSession(t, conn)
 	testSplitQuery(t, conn)
+	testExplain(t, conn)
 
 	// force a panic at every call, then test that works
 	fakeServer.(*fakeVTGateService).panics = true
@@ -235,6 +251,7 @@ func TestSuite(t *testing.T, conn vtgateconn.VTGateConn, fakeServer vtgateservic
 	testStreamExecutePanic(t, conn)
 	testBeginPanic(t, conn)
 	testSplitQueryPanic(t, conn)
+	testExplainPanic(t, conn)
 }
 
 func expectPanic(t *testing.T, err error) {
@@ -394,6 +411,23 @@ func testTxPass(t *testing.T, conn vtgateconn.VTGateConn) {
 	}
 }
 
+func testTxOptions(t *testing.T, conn vtgateconn.VTGateConn) {
+	ctx := context.Background()
+	tx, err := conn.BeginWithOptions(ctx, sessionWithOptions.TransactionOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	execCase := execMap["txOptionsRequest"]
+	_, err = tx.Execute(ctx, execCase.execQuery.Sql, execCase.execQuery.BindVariables, execCase.execQuery.TabletType)
+	if err != nil {
+		t.Error(err)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func testBeginPanic(t *testing.T, conn vtgateconn.VTGateConn) {
 	ctx := context.Background()
 	_, err := conn.Begin(ctx)
@@ -446,6 +480,25 @@ func testTxFail(t *testing.T, conn vtgateconn.VTGateConn) {
 	}
 }
 
+// testSession checks that the queries of a session send the session
+// they got from the previous ones.
+func testSession(t *testing.T, conn vtgateconn.VTGateConn) {
+	ctx := context.Background()
+	s := conn.NewSession(vtgateconn.SessionOptions{
+		ReadYourWrites:    true,
+		MaxReplicationLag: time.Second,
+	})
+	if _, err := s.Execute(ctx, "sessionWrite", nil, topo.TYPE_MASTER); err != nil {
+		t.Errorf("sessionWrite: %v", err)
+	}
+	if _, err := s.Execute(ctx, "sessionRead", nil, topo.TYPE_REPLICA); err != nil {
+		t.Errorf("sessionRead: %v", err)
+	}
+	if _, err := s.ExecuteShard(ctx, "sessionRead", "ks", []string{"1"}, nil, topo.TYPE_REPLICA); err != nil {
+		t.Errorf("sessionRead on a shard: %v", err)
+	}
+}
+
 func testSplitQuery(t *testing.T, conn vtgateconn.VTGateConn) {
 	ctx := context.Background()
 	qsl, err := conn.SplitQuery(ctx, splitQueryRequest.Keyspace, splitQueryRequest.Query, splitQueryRequest.SplitCount)
@@ -463,6 +516,23 @@ func testSplitQueryPanic(t *testing.T, conn vtgateconn.VTGateConn) {
 	expectPanic(t, err)
 }
 
+func testExplain(t *testing.T, conn vtgateconn.VTGateConn) {
+	ctx := context.Background()
+	result, err := conn.Explain(ctx, explainQuery.Sql, explainQuery.BindVariables, explainQuery.TabletType)
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if !reflect.DeepEqual(result, explainResult) {
+		t.Errorf("Explain returned wrong result: got %+v wanted %+v", result, explainResult)
+	}
+}
+
+func testExplainPanic(t *testing.T, conn vtgateconn.VTGateConn) {
+	ctx := context.Background()
+	_, err := conn.Explain(ctx, explainQuery.Sql, explainQuery.BindVariables, explainQuery.TabletType)
+	expectPanic(t, err)
+}
+
 var execMap = map[string]struct {
 	execQuery  *proto.Query
 	shardQuery *proto.QueryShard
@@ -536,6 +606,68 @@ var execMap = map[string]struct {
 			Error:   "",
 		},
 	},
+	"sessionWrite": {
+		execQuery: &proto.Query{
+			Sql:           "sessionWrite",
+			BindVariables: map[string]interface{}{},
+			TabletType:    topo.TYPE_MASTER,
+			Session:       sessionReadYourWrites,
+		},
+		reply: &proto.QueryResult{
+			Result:  &result1,
+			Session: sessionAfterWrite,
+		},
+	},
+	"sessionRead": {
+		execQuery: &proto.Query{
+			Sql:           "sessionRead",
+			BindVariables: map[string]interface{}{},
+			TabletType:    topo.TYPE_REPLICA,
+			Session:       sessionAfterWrite,
+		},
+		shardQuery: &proto.QueryShard{
+			Sql:           "sessionRead",
+			BindVariables: map[string]interface{}{},
+			Keyspace:      "ks",
+			Shards:        []string{"1"},
+			TabletType:    topo.TYPE_REPLICA,
+			Session:       sessionAfterWrite,
+		},
+		reply: &proto.QueryResult{
+			Result:  &result1,
+			Session: sessionAfterWrite,
+		},
+	},
+	"txOptionsRequest": {
+		execQuery: &proto.Query{
+			Sql:           "txOptionsRequest",
+			BindVariables: map[string]interface{}{},
+			TabletType:    "",
+			Session:       sessionWithOptions,
+		},
+		reply: &proto.QueryResult{
+			Result:  nil,
+			Session: session2,
+			Error:   "",
+		},
+	},
+}
+
+var sessionReadYourWrites = &proto.Session{
+	ShardSessions:     []*proto.ShardSession{},
+	ReadYourWrites:    true,
+	MaxReplicationLag: int64(time.Second),
+	Savepoints:        []string{},
+}
+
+var sessionAfterWrite = &proto.Session{
+	ShardSessions:     []*proto.ShardSession{},
+	ReadYourWrites:    true,
+	LastWriteTime:     1234,
+	MaxReplicationLag: int64(time.Second),
+	Savepoints:        []string{},
+	LastInsertId:      72,
+	RowCount:          123,
 }
 
 var result1 = mproto.QueryResult{
@@ -566,6 +698,17 @@ var result1 = mproto.QueryResult{
 var session1 = &proto.Session{
 	InTransaction: true,
 	ShardSessions: []*proto.ShardSession{},
+	Savepoints:    []string{},
+}
+
+var sessionWithOptions = &proto.Session{
+	InTransaction: true,
+	ShardSessions: []*proto.ShardSession{},
+	TransactionOptions: &tproto.TransactionOptions{
+		IsolationLevel: tproto.IsolationReadCommitted,
+		ReadOnly:       true,
+	},
+	Savepoints: []string{},
 }
 
 var session2 = &proto.Session{
@@ -578,6 +721,7 @@ var session2 = &proto.Session{
 			TransactionId: 1,
 		},
 	},
+	Savepoints: []string{},
 }
 
 var splitQueryRequest = &proto.SplitQueryRequest{
@@ -607,3 +751,28 @@ var splitQueryResult = &proto.SplitQueryResult{
 		},
 	},
 }
+
+var explainQuery = &proto.Query{
+	Sql: "in for Explain",
+	BindVariables: map[string]interface{}{
+		"bind1": int64(43),
+	},
+	TabletType: topo.TYPE_REPLICA,
+}
+
+var explainResult = &proto.ExplainResult{
+	PlanType:  "SelectEqual",
+	Reason:    "",
+	Keyspace:  "ks",
+	Table:     "user",
+	Vindex:    "user_index",
+	Rewritten: "out for Explain",
+	Shards:    []string{"-80"},
+	TabletPlan: &tproto.ExplainResult{
+		PlanType:  "PASS_SELECT",
+		Reason:    "NOCACHE",
+		TableName: "user",
+		PKValues:  []string{},
+		FullQuery: "out for Explain limit :#maxLimit",
+	},
+}
@@ -37,6 +37,9 @@ type VTGateService interface {
 	// Map Reduce support
 	SplitQuery(ctx context.Context, req *proto.SplitQueryRequest, reply *proto.SplitQueryResult) error
 
+	// Plan inspection
+	Explain(ctx context.Context, query *proto.Query, reply *proto.ExplainResult) error
+
 	// HandlePanic should be called with defer at the beginning of each
 	// RPC implementation method, before calling any of the previous methods
 	HandlePanic(err *error)
@@ -0,0 +1,127 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package vttls builds the TLS configurations used by the RPC servers
+// and clients, and provides the common flags to configure the
+// clients.
+package vttls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sync"
+)
+
+// loadCertPool returns a pool with all the certificates in caFile.
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ca file %v: %v", caFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no valid certificate in ca file %v", caFile)
+	}
+	return pool, nil
+}
+
+// ServerConfig returns the TLS configuration for a server using the
+// provided cert and key. If caFile is set, clients are required to
+// present a certificate signed by one of its certificate
+// authorities.
+func ServerConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	config := &tls.Config{}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load server cert %v / key %v: %v", certFile, keyFile, err)
+	}
+	config.Certificates = []tls.Certificate{cert}
+
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// ClientConfig returns the TLS configuration for a client. certFile
+// and keyFile are the optional client certificate, sent to servers
+// that verify clients. If caFile is set, the server certificate is
+// verified against its certificate authorities, and serverName (if
+// set) is used for the verification instead of the dialed host name.
+// If caFile is not set, the server certificate is not verified: the
+// traffic is encrypted, but the server is not authenticated.
+func ClientConfig(certFile, keyFile, caFile, serverName string) (*tls.Config, error) {
+	config := &tls.Config{}
+
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client cert %v / key %v: %v", certFile, keyFile, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+		config.ServerName = serverName
+	} else {
+		config.InsecureSkipVerify = true
+	}
+	return config, nil
+}
+
+// ClientFlags are the command line flags that configure the TLS
+// connections of an RPC client. Use RegisterClientFlags to create
+// them.
+type ClientFlags struct {
+	Encrypted  *bool
+	CertFile   *string
+	KeyFile    *string
+	CAFile     *string
+	ServerName *string
+
+	once   sync.Once
+	config *tls.Config
+	err    error
+}
+
+// RegisterClientFlags registers the flags '<prefix>-encrypted',
+// '<prefix>-cert', '<prefix>-key', '<prefix>-ca' and
+// '<prefix>-server-name'. It needs to be called before flags are
+// parsed, usually in an init function.
+func RegisterClientFlags(prefix, description string) *ClientFlags {
+	return &ClientFlags{
+		Encrypted:  flag.Bool(prefix+"-encrypted", false, "use encryption to talk to "+description),
+		CertFile:   flag.String(prefix+"-cert", "", "client cert file to use to talk to "+description+" (requires "+prefix+"-encrypted)"),
+		KeyFile:    flag.String(prefix+"-key", "", "client key file to use to talk to "+description+" (requires "+prefix+"-encrypted)"),
+		CAFile:     flag.String(prefix+"-ca", "", "ca file to verify the certificate of "+description+", if not set the certificate is not verified (requires "+prefix+"-encrypted)"),
+		ServerName: flag.String(prefix+"-server-name", "", "server name to verify the certificate of "+description+" against, instead of the host name (requires "+prefix+"-encrypted)"),
+	}
+}
+
+// Config returns the TLS configuration built from the flags, or nil
+// if encryption is not enabled. The configuration is only built
+// once, so the files are read on the first call.
+func (cf *ClientFlags) Config() (*tls.Config, error) {
+	if !*cf.Encrypted {
+		return nil, nil
+	}
+	cf.once.Do(func() {
+		cf.config, cf.err = ClientConfig(*cf.CertFile, *cf.KeyFile, *cf.CAFile, *cf.ServerName)
+	})
+	return cf.config, cf.err
+}
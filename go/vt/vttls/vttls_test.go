@@ -0,0 +1,172 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vttls
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+// createCert creates a key and a certificate signed by parent (or
+// self-signed if parent is nil), and writes them in dir.
+func createCert(t *testing.T, dir, name string, isCA bool, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if parent == nil {
+		parent = template
+		parentKey = key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := ioutil.WriteFile(path.Join(dir, name+"-cert.pem"), certPEM, 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(path.Join(dir, name+"-key.pem"), keyPEM, 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return cert, key
+}
+
+// handshake runs a TLS handshake between a client and a server with
+// the provided configs, and returns the client error, or the server
+// error if the client succeeded.
+func handshake(serverConfig, clientConfig *tls.Config) error {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	serverErr := make(chan error, 1)
+	server := tls.Server(serverConn, serverConfig)
+	go func() {
+		err := server.Handshake()
+		serverConn.Close()
+		serverErr <- err
+	}()
+	client := tls.Client(clientConn, clientConfig)
+	if err := client.Handshake(); err != nil {
+		return err
+	}
+	// the server may reject the client after the client is done
+	client.Read(make([]byte, 1))
+	return <-serverErr
+}
+
+func TestClientServerConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vttls")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	p := func(name string) string {
+		return path.Join(dir, name)
+	}
+
+	ca, caKey := createCert(t, dir, "ca", true, nil, nil)
+	createCert(t, dir, "server.vitess", false, ca, caKey)
+	createCert(t, dir, "client", false, ca, caKey)
+
+	// server that doesn't verify clients, client that verifies the server
+	serverConfig, err := ServerConfig(p("server.vitess-cert.pem"), p("server.vitess-key.pem"), "")
+	if err != nil {
+		t.Fatalf("ServerConfig failed: %v", err)
+	}
+	clientConfig, err := ClientConfig("", "", p("ca-cert.pem"), "server.vitess")
+	if err != nil {
+		t.Fatalf("ClientConfig failed: %v", err)
+	}
+	if err := handshake(serverConfig, clientConfig); err != nil {
+		t.Errorf("handshake failed: %v", err)
+	}
+
+	// the server name is verified
+	clientConfig, err = ClientConfig("", "", p("ca-cert.pem"), "other.vitess")
+	if err != nil {
+		t.Fatalf("ClientConfig failed: %v", err)
+	}
+	if err := handshake(serverConfig, clientConfig); err == nil {
+		t.Errorf("handshake with the wrong server name worked")
+	}
+
+	// server that verifies clients
+	serverConfig, err = ServerConfig(p("server.vitess-cert.pem"), p("server.vitess-key.pem"), p("ca-cert.pem"))
+	if err != nil {
+		t.Fatalf("ServerConfig failed: %v", err)
+	}
+	clientConfig, err = ClientConfig(p("client-cert.pem"), p("client-key.pem"), p("ca-cert.pem"), "server.vitess")
+	if err != nil {
+		t.Fatalf("ClientConfig failed: %v", err)
+	}
+	if err := handshake(serverConfig, clientConfig); err != nil {
+		t.Errorf("handshake with a client cert failed: %v", err)
+	}
+
+	// no ca: the client doesn't verify the server, but the server
+	// still rejects a client with no cert.
+	clientConfig, err = ClientConfig("", "", "", "")
+	if err != nil {
+		t.Fatalf("ClientConfig failed: %v", err)
+	}
+	if err := handshake(serverConfig, clientConfig); err == nil {
+		t.Errorf("handshake without a client cert worked")
+	}
+
+	// bad files
+	if _, err := ServerConfig(p("nonexistent"), p("nonexistent"), ""); err == nil {
+		t.Errorf("ServerConfig with missing files worked")
+	}
+	if _, err := ClientConfig("", "", p("client-key.pem"), ""); err == nil {
+		t.Errorf("ClientConfig with an invalid ca file worked")
+	}
+}
+
+func TestClientFlags(t *testing.T) {
+	cf := RegisterClientFlags("test-bson", "the test server")
+	config, err := cf.Config()
+	if config != nil || err != nil {
+		t.Errorf("Config() without encryption returned %v, %v", config, err)
+	}
+
+	*cf.Encrypted = true
+	config, err = cf.Config()
+	if err != nil {
+		t.Fatalf("Config() failed: %v", err)
+	}
+	if !config.InsecureSkipVerify {
+		t.Errorf("Config() without a ca should not verify the server")
+	}
+}
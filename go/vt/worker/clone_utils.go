@@ -108,6 +108,16 @@ func (ts *tableStatus) threadDone() {
 	ts.mu.Unlock()
 }
 
+// chunkSkipped is called for a chunk that was already copied by a
+// previous run.
+func (ts *tableStatus) chunkSkipped(copiedRows uint64) {
+	ts.mu.Lock()
+	ts.threadsStarted++
+	ts.threadsDone++
+	ts.mu.Unlock()
+	ts.addCopiedRows(int(copiedRows))
+}
+
 func (ts *tableStatus) addCopiedRows(copiedRows int) {
 	ts.mu.Lock()
 	ts.copiedRows += uint64(copiedRows)
@@ -381,19 +391,25 @@ func findChunks(ctx context.Context, wr *wrangler.Wrangler, ti *topo.TabletInfo,
 	return result, nil
 }
 
+// chunkClauses returns the conditions on the primary key that select
+// the rows of a chunk. It returns nil if the chunk is the whole table.
+func chunkClauses(td *myproto.TableDefinition, chunks []string, chunkIndex int) []string {
+	var clauses []string
+	if chunks[chunkIndex] != "" {
+		clauses = append(clauses, td.PrimaryKeyColumns[0]+">="+chunks[chunkIndex])
+	}
+	if chunks[chunkIndex+1] != "" {
+		clauses = append(clauses, td.PrimaryKeyColumns[0]+"<"+chunks[chunkIndex+1])
+	}
+	return clauses
+}
+
 // buildSQLFromChunks returns the SQL command to run to insert the data
 // using the chunks definitions into the provided table.
 func buildSQLFromChunks(wr *wrangler.Wrangler, td *myproto.TableDefinition, chunks []string, chunkIndex int, source string) string {
 	selectSQL := "SELECT " + strings.Join(td.Columns, ", ") + " FROM " + td.Name
-	if chunks[chunkIndex] != "" || chunks[chunkIndex+1] != "" {
+	if clauses := chunkClauses(td, chunks, chunkIndex); len(clauses) > 0 {
 		wr.Logger().Infof("Starting to stream all data from tablet %v table %v between '%v' and '%v'", source, td.Name, chunks[chunkIndex], chunks[chunkIndex+1])
-		clauses := make([]string, 0, 2)
-		if chunks[chunkIndex] != "" {
-			clauses = append(clauses, td.PrimaryKeyColumns[0]+">="+chunks[chunkIndex])
-		}
-		if chunks[chunkIndex+1] != "" {
-			clauses = append(clauses, td.PrimaryKeyColumns[0]+"<"+chunks[chunkIndex+1])
-		}
 		selectSQL += " WHERE " + strings.Join(clauses, " AND ")
 	} else {
 		wr.Logger().Infof("Starting to stream all data from tablet %v table %v", source, td.Name)
@@ -435,24 +451,29 @@ func makeValueString(fields []mproto.Field, rows [][]sqltypes.Value) string {
 }
 
 // executeFetchLoop loops over the provided insertChannel
-// and sends the commands to the provided tablet.
-func executeFetchLoop(ctx context.Context, wr *wrangler.Wrangler, r Resolver, shard string, insertChannel chan string) error {
+// and sends the commands to the provided tablet, at the pace
+// allowed by the throttler.
+func executeFetchLoop(ctx context.Context, wr *wrangler.Wrangler, r Resolver, shard string, insertChannel chan *insertCommand, throttler *CopyThrottler) error {
 	ti, err := r.GetDestinationMaster(shard)
 	if err != nil {
 		return fmt.Errorf("executeFetchLoop failed: %v", err)
 	}
 	for {
 		select {
-		case cmd, ok := <-insertChannel:
+		case insert, ok := <-insertChannel:
 			if !ok {
 				// no more to read, we're done
 				return nil
 			}
-			cmd = "INSERT INTO `" + ti.DbName() + "`." + cmd
+			if aborted := throttler.wait(ctx, shard); aborted {
+				return nil
+			}
+			cmd := "INSERT INTO `" + ti.DbName() + "`." + insert.sql
 			ti, err = executeFetchWithRetries(ctx, wr, ti, r, shard, cmd)
 			if err != nil {
 				return fmt.Errorf("ExecuteFetch failed: %v", err)
 			}
+			insert.chunk.insertExecuted()
 		case <-ctx.Done():
 			// Doesn't really matter if this select gets starved, because the other case
 			// will also return an error due to executeFetch's context being closed. This case
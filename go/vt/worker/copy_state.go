@@ -0,0 +1,459 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/key"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+)
+
+// insertCommand is an insert sent to the destination writers.
+type insertCommand struct {
+	sql string
+	// chunk is the table chunk the rows come from, may be nil.
+	chunk *chunkTracker
+}
+
+// sendInsert sends an insert to a destination channel. It returns
+// true if aborted.
+func sendInsert(insertChannel chan *insertCommand, sql string, chunk *chunkTracker, abort <-chan struct{}) bool {
+	chunk.insertSent()
+	select {
+	case insertChannel <- &insertCommand{sql: sql, chunk: chunk}:
+		return false
+	case <-abort:
+		return true
+	}
+}
+
+// chunkTracker follows the inserts of a table chunk. Once the chunk
+// was entirely read, and all its inserts were executed, it calls
+// its done function. All methods can be called on a nil
+// chunkTracker, and do nothing then.
+type chunkTracker struct {
+	mu       sync.Mutex
+	pending  int
+	rows     uint64
+	readDone bool
+	done     func(rows uint64)
+}
+
+func newChunkTracker(done func(rows uint64)) *chunkTracker {
+	return &chunkTracker{done: done}
+}
+
+// addRows counts rows read from the chunk.
+func (ct *chunkTracker) addRows(rows int) {
+	if ct == nil {
+		return
+	}
+	ct.mu.Lock()
+	ct.rows += uint64(rows)
+	ct.mu.Unlock()
+}
+
+// insertSent is called before an insert is sent.
+func (ct *chunkTracker) insertSent() {
+	if ct == nil {
+		return
+	}
+	ct.mu.Lock()
+	ct.pending++
+	ct.mu.Unlock()
+}
+
+// insertExecuted is called once an insert was executed.
+func (ct *chunkTracker) insertExecuted() {
+	if ct == nil {
+		return
+	}
+	ct.mu.Lock()
+	ct.pending--
+	ct.checkDoneLocked()
+}
+
+// allRead is called once the whole chunk was read and sent.
+func (ct *chunkTracker) allRead() {
+	if ct == nil {
+		return
+	}
+	ct.mu.Lock()
+	ct.readDone = true
+	ct.checkDoneLocked()
+}
+
+// checkDoneLocked calls done if the chunk is done. It unlocks
+// the mutex.
+func (ct *chunkTracker) checkDoneLocked() {
+	if !ct.readDone || ct.pending > 0 || ct.done == nil {
+		ct.mu.Unlock()
+		return
+	}
+	done := ct.done
+	ct.done = nil
+	rows := ct.rows
+	ct.mu.Unlock()
+	done(rows)
+}
+
+// copyStateQueryMaxRows is the maximum number of chunks we read
+// from the _vt.copy_state table.
+const copyStateQueryMaxRows = 1000000
+
+// createCopyState returns the statements to create the
+// _vt.copy_state table. It has one row per table chunk to copy from
+// a source shard. source_position is the replication position of
+// the source tablet when the chunk was copied.
+func createCopyState() []string {
+	return []string{
+		"CREATE DATABASE IF NOT EXISTS _vt",
+		`CREATE TABLE IF NOT EXISTS _vt.copy_state (
+  table_name VARBINARY(64) NOT NULL,
+  source_shard VARBINARY(64) NOT NULL,
+  chunk_index INT(10) UNSIGNED NOT NULL,
+  chunk_start VARBINARY(64) NOT NULL,
+  chunk_end VARBINARY(64) NOT NULL,
+  copied_rows BIGINT UNSIGNED NOT NULL,
+  done TINYINT(1) NOT NULL,
+  source_position VARBINARY(250) NOT NULL,
+  time_updated BIGINT UNSIGNED NOT NULL,
+  PRIMARY KEY (table_name, source_shard, chunk_index)) ENGINE=InnoDB`}
+}
+
+// recordCopyStateChunks returns a statement to record the chunks of
+// a table in the _vt.copy_state table. Chunks that are already
+// recorded are left alone.
+func recordCopyStateChunks(tableName, sourceShard string, chunks []string, timeUpdated int64) string {
+	values := make([]string, 0, len(chunks)-1)
+	for i := 0; i < len(chunks)-1; i++ {
+		values = append(values, fmt.Sprintf("(%v, %v, %v, %v, %v, 0, 0, '', %v)", encodeString(tableName), encodeString(sourceShard), i, encodeString(chunks[i]), encodeString(chunks[i+1]), timeUpdated))
+	}
+	return "INSERT IGNORE INTO _vt.copy_state " +
+		"(table_name, source_shard, chunk_index, chunk_start, chunk_end, copied_rows, done, source_position, time_updated) " +
+		"VALUES " + strings.Join(values, ", ")
+}
+
+// finishCopyStateChunk returns a statement to mark a chunk as
+// copied at a source position in the _vt.copy_state table.
+func finishCopyStateChunk(tableName, sourceShard string, chunkIndex int, copiedRows uint64, sourcePosition string, timeUpdated int64) string {
+	return fmt.Sprintf("UPDATE _vt.copy_state "+
+		"SET copied_rows=%v, done=1, source_position=%v, time_updated=%v "+
+		"WHERE table_name=%v AND source_shard=%v AND chunk_index=%v",
+		copiedRows, encodeString(sourcePosition), timeUpdated, encodeString(tableName), encodeString(sourceShard), chunkIndex)
+}
+
+// deleteCopyState returns a statement to delete the chunks copied
+// from a source shard from the _vt.copy_state table.
+func deleteCopyState(sourceShard string) string {
+	return fmt.Sprintf("DELETE FROM _vt.copy_state WHERE source_shard=%v", encodeString(sourceShard))
+}
+
+func encodeString(s string) string {
+	buf := bytes.Buffer{}
+	sqltypes.MakeString([]byte(s)).EncodeSql(&buf)
+	return buf.String()
+}
+
+// chunkState is the recorded state of a table chunk.
+type chunkState struct {
+	start      string
+	end        string
+	copiedRows uint64
+	done       bool
+	// position is the source position the chunk was copied at
+	position string
+}
+
+// copyState is the progress of a copy, as recorded in the
+// _vt.copy_state table of all its destination shards. It lets an
+// interrupted copy resume: the chunks of a table are the recorded
+// ones, and the chunks that are done on all destinations, at the
+// current position of the source, are not copied again.
+type copyState struct {
+	wr                *wrangler.Wrangler
+	r                 Resolver
+	destinationShards []string
+
+	// chunks are indexed by table name and source shard,
+	// read-only after loadCopyState
+	chunks map[string][]*chunkState
+}
+
+func copyStateKey(tableName, sourceShard string) string {
+	return tableName + "/" + sourceShard
+}
+
+// loadCopyState creates the _vt.copy_state table on the destination
+// shards if needed, and reads it.
+func loadCopyState(ctx context.Context, wr *wrangler.Wrangler, r Resolver, destinationShards []string) (*copyState, error) {
+	cs := &copyState{
+		wr:                wr,
+		r:                 r,
+		destinationShards: destinationShards,
+		chunks:            make(map[string][]*chunkState),
+	}
+	for i, shard := range destinationShards {
+		if err := runCheckpointCommands(ctx, wr, r, shard, createCopyState()); err != nil {
+			return nil, fmt.Errorf("cannot create copy_state table on shard %v: %v", shard, err)
+		}
+		ti, err := r.GetDestinationMaster(shard)
+		if err != nil {
+			return nil, err
+		}
+		shortCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+		qr, err := wr.TabletManagerClient().ExecuteFetchAsApp(shortCtx, ti, "SELECT table_name, source_shard, chunk_index, chunk_start, chunk_end, copied_rows, done, source_position FROM _vt.copy_state", copyStateQueryMaxRows, false)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read copy_state table on shard %v: %v", shard, err)
+		}
+		// shardChunks has the positions of the done chunks
+		shardChunks := make(map[string]string)
+		for _, row := range qr.Rows {
+			key := copyStateKey(row[0].String(), row[1].String())
+			chunkIndex, err := strconv.Atoi(row[2].String())
+			if err != nil {
+				return nil, fmt.Errorf("invalid chunk_index in copy_state on shard %v: %v", shard, err)
+			}
+			copiedRows, err := strconv.ParseUint(row[5].String(), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid copied_rows in copy_state on shard %v: %v", shard, err)
+			}
+			done := row[6].String() == "1"
+			if i == 0 {
+				// the first destination gives the chunks
+				for len(cs.chunks[key]) <= chunkIndex {
+					cs.chunks[key] = append(cs.chunks[key], nil)
+				}
+				cs.chunks[key][chunkIndex] = &chunkState{
+					start:      row[3].String(),
+					end:        row[4].String(),
+					copiedRows: copiedRows,
+					done:       done,
+					position:   row[7].String(),
+				}
+				continue
+			}
+			// the other ones can only say a chunk is not done
+			if done {
+				shardChunks[fmt.Sprintf("%v/%v", key, chunkIndex)] = row[7].String()
+			}
+		}
+		if i == 0 {
+			continue
+		}
+		for key, chunks := range cs.chunks {
+			for chunkIndex, cst := range chunks {
+				if cst == nil {
+					continue
+				}
+				if position, ok := shardChunks[fmt.Sprintf("%v/%v", key, chunkIndex)]; !ok || position != cst.position {
+					cst.done = false
+				}
+			}
+		}
+	}
+
+	// a table whose chunks are not all recorded is copied again
+	for key, chunks := range cs.chunks {
+		for _, cst := range chunks {
+			if cst == nil {
+				wr.Logger().Warningf("Incomplete copy_state for %v, copying it again", key)
+				delete(cs.chunks, key)
+				break
+			}
+		}
+	}
+	return cs, nil
+}
+
+// prepareChunks returns the chunks of a table to copy from a source
+// shard, and the copied rows of the ones that are already done,
+// by chunk index. Without a copy state, it finds the chunks on
+// the source tablet. With one, it reuses the recorded chunks, or
+// records the new ones, and deletes the rows of the chunks that
+// were only partly copied from the destinations. A chunk copied
+// at another position than sourcePosition may miss the later
+// changes of the source, so it's deleted and copied again too.
+// sourceFilter is the condition matching the rows of the source
+// shard, it's empty if the destinations only get rows from that
+// source.
+func prepareChunks(ctx context.Context, wr *wrangler.Wrangler, cs *copyState, ti *topo.TabletInfo, td *myproto.TableDefinition, sourceShard, sourcePosition, sourceFilter string, minTableSizeForSplit uint64, sourceReaderCount int) ([]string, map[int]uint64, error) {
+	doneRows := make(map[int]uint64)
+	if cs != nil {
+		if recorded, ok := cs.chunks[copyStateKey(td.Name, sourceShard)]; ok {
+			chunks := make([]string, 0, len(recorded)+1)
+			for i, cst := range recorded {
+				chunks = append(chunks, cst.start)
+				if i == len(recorded)-1 {
+					chunks = append(chunks, cst.end)
+				}
+			}
+			for i, cst := range recorded {
+				if cst.done && cst.position == sourcePosition {
+					doneRows[i] = cst.copiedRows
+					continue
+				}
+				if cst.done {
+					wr.Logger().Infof("Chunk %v of table %v was copied from %v at position %v, copying it again at %v", i, td.Name, sourceShard, cst.position, sourcePosition)
+				}
+				if err := cs.clearChunk(ctx, td, chunks, i, sourceFilter); err != nil {
+					return nil, nil, err
+				}
+			}
+			wr.Logger().Infof("Resuming the copy of table %v from %v: %v of %v chunks already copied", td.Name, sourceShard, len(doneRows), len(recorded))
+			return chunks, doneRows, nil
+		}
+	}
+
+	chunks, err := findChunks(ctx, wr, ti, td, minTableSizeForSplit, sourceReaderCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cs != nil {
+		query := recordCopyStateChunks(td.Name, sourceShard, chunks, time.Now().Unix())
+		for _, shard := range cs.destinationShards {
+			if err := runCheckpointCommands(ctx, wr, cs.r, shard, []string{query}); err != nil {
+				return nil, nil, fmt.Errorf("cannot record chunks of table %v on shard %v: %v", td.Name, shard, err)
+			}
+		}
+	}
+	return chunks, doneRows, nil
+}
+
+// clearChunk deletes the rows of a chunk copied from a source shard
+// from all the destinations. sourceFilter restricts the delete to
+// the rows of that source: in a merge, the other sources may have
+// already copied rows in the same primary key range.
+func (cs *copyState) clearChunk(ctx context.Context, td *myproto.TableDefinition, chunks []string, chunkIndex int, sourceFilter string) error {
+	for _, shard := range cs.destinationShards {
+		ti, err := cs.r.GetDestinationMaster(shard)
+		if err != nil {
+			return err
+		}
+		query := clearChunkQuery(ti.DbName(), td, chunks, chunkIndex, sourceFilter)
+		if _, err := executeFetchWithRetries(ctx, cs.wr, ti, cs.r, shard, query); err != nil {
+			return fmt.Errorf("cannot clear partly copied chunk of table %v on shard %v: %v", td.Name, shard, err)
+		}
+	}
+	return nil
+}
+
+// clearChunkQuery returns the statement to delete the rows of a
+// chunk copied from a source shard.
+func clearChunkQuery(dbName string, td *myproto.TableDefinition, chunks []string, chunkIndex int, sourceFilter string) string {
+	query := "DELETE FROM `" + dbName + "`." + td.Name
+	clauses := chunkClauses(td, chunks, chunkIndex)
+	if sourceFilter != "" {
+		clauses = append(clauses, sourceFilter)
+	}
+	if len(clauses) > 0 {
+		query += " WHERE " + strings.Join(clauses, " AND ")
+	}
+	return query
+}
+
+// keyRangeFilter returns the condition for the sharding column to be
+// in the keyrange, or an empty string if all rows are.
+func keyRangeFilter(column string, kit key.KeyspaceIdType, kr key.KeyRange) (string, error) {
+	var bound func(kid key.KeyspaceId) string
+	switch kit {
+	case key.KIT_UINT64:
+		bound = func(kid key.KeyspaceId) string {
+			var b [8]byte
+			copy(b[:], kid)
+			return fmt.Sprintf("%d", binary.BigEndian.Uint64(b[:]))
+		}
+	case key.KIT_BYTES:
+		column = fmt.Sprintf("HEX(%s)", column)
+		bound = func(kid key.KeyspaceId) string {
+			return fmt.Sprintf("'%s'", kid.Hex())
+		}
+	default:
+		return "", fmt.Errorf("invalid keyspace id type %q", kit)
+	}
+
+	var clauses []string
+	if kr.Start != key.MinKey {
+		clauses = append(clauses, fmt.Sprintf("%s>=%s", column, bound(kr.Start)))
+	}
+	if kr.End != key.MaxKey {
+		clauses = append(clauses, fmt.Sprintf("%s<%s", column, bound(kr.End)))
+	}
+	return strings.Join(clauses, " AND "), nil
+}
+
+// newTracker returns a chunkTracker that marks the chunk as done at
+// sourcePosition in the _vt.copy_state table of all the destinations
+// once it's copied. Errors are sent to processError.
+func (cs *copyState) newTracker(ctx context.Context, tableName, sourceShard, sourcePosition string, chunkIndex int, processError func(format string, args ...interface{})) *chunkTracker {
+	return newChunkTracker(func(rows uint64) {
+		query := finishCopyStateChunk(tableName, sourceShard, chunkIndex, rows, sourcePosition, time.Now().Unix())
+		for _, shard := range cs.destinationShards {
+			if err := runCheckpointCommands(ctx, cs.wr, cs.r, shard, []string{query}); err != nil {
+				processError("copy_state query failed: %v", err)
+				return
+			}
+		}
+	})
+}
+
+// clear deletes the chunks copied from the source shards from the
+// _vt.copy_state table of all the destinations. It's called once the
+// copy is complete, so a later copy into the same destinations
+// doesn't skip them.
+func (cs *copyState) clear(ctx context.Context, sourceShards []string) error {
+	queries := make([]string, 0, len(sourceShards))
+	for _, sourceShard := range sourceShards {
+		queries = append(queries, deleteCopyState(sourceShard))
+	}
+	for _, shard := range cs.destinationShards {
+		if err := runCheckpointCommands(ctx, cs.wr, cs.r, shard, queries); err != nil {
+			return fmt.Errorf("cannot clear copy_state table on shard %v: %v", shard, err)
+		}
+	}
+	return nil
+}
+
+// sourcePosition returns the replication position of a source
+// tablet. Its replication is stopped during the copy, so the
+// position doesn't change until the copy is done.
+func sourcePosition(ctx context.Context, wr *wrangler.Wrangler, ti *topo.TabletInfo) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+	status, err := wr.TabletManagerClient().SlaveStatus(ctx, ti)
+	if err != nil {
+		return "", fmt.Errorf("cannot get the position of source tablet %v: %v", ti.Alias, err)
+	}
+	return myproto.EncodeReplicationPosition(status.Position), nil
+}
+
+// runCheckpointCommands sends the copy_state statements to the
+// master of a destination shard.
+func runCheckpointCommands(ctx context.Context, wr *wrangler.Wrangler, r Resolver, shard string, commands []string) error {
+	ti, err := r.GetDestinationMaster(shard)
+	if err != nil {
+		return fmt.Errorf("runCheckpointCommands failed: %v", err)
+	}
+	for _, command := range commands {
+		ti, err = executeFetchWithRetries(ctx, wr, ti, r, shard, command)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"testing"
+
+	"github.com/youtube/vitess/go/vt/key"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+)
+
+func TestChunkTracker(t *testing.T) {
+	var doneRows uint64
+	doneCount := 0
+	ct := newChunkTracker(func(rows uint64) {
+		doneRows = rows
+		doneCount++
+	})
+
+	ct.insertSent()
+	ct.addRows(3)
+	ct.insertSent()
+	ct.addRows(2)
+	ct.insertExecuted()
+	ct.allRead()
+	if doneCount != 0 {
+		t.Fatalf("chunk should not be done with a pending insert")
+	}
+	ct.insertExecuted()
+	if doneCount != 1 || doneRows != 5 {
+		t.Errorf("chunk done %v times with %v rows, want once with 5 rows", doneCount, doneRows)
+	}
+
+	// a nil tracker does nothing
+	var nilTracker *chunkTracker
+	nilTracker.insertSent()
+	nilTracker.addRows(1)
+	nilTracker.insertExecuted()
+	nilTracker.allRead()
+}
+
+func TestCopyStateQueries(t *testing.T) {
+	got := recordCopyStateChunks("table1", "-80", []string{"", "100", ""}, 1234)
+	want := "INSERT IGNORE INTO _vt.copy_state " +
+		"(table_name, source_shard, chunk_index, chunk_start, chunk_end, copied_rows, done, source_position, time_updated) " +
+		"VALUES ('table1', '-80', 0, '', '100', 0, 0, '', 1234), ('table1', '-80', 1, '100', '', 0, 0, '', 1234)"
+	if got != want {
+		t.Errorf("recordCopyStateChunks:\ngot  %v\nwant %v", got, want)
+	}
+
+	got = finishCopyStateChunk("table1", "-80", 1, 10, "MariaDB/0-1-123", 1235)
+	want = "UPDATE _vt.copy_state " +
+		"SET copied_rows=10, done=1, source_position='MariaDB/0-1-123', time_updated=1235 " +
+		"WHERE table_name='table1' AND source_shard='-80' AND chunk_index=1"
+	if got != want {
+		t.Errorf("finishCopyStateChunk:\ngot  %v\nwant %v", got, want)
+	}
+
+	got = deleteCopyState("-80")
+	want = "DELETE FROM _vt.copy_state WHERE source_shard='-80'"
+	if got != want {
+		t.Errorf("deleteCopyState:\ngot  %v\nwant %v", got, want)
+	}
+}
+
+func TestClearChunkQueryMerge(t *testing.T) {
+	// -40 and 40-80 are merged into -80, and the copy is resumed
+	// with the chunks of 40-80 not done: the deletes must leave the
+	// rows copied from -40 alone.
+	td := &myproto.TableDefinition{
+		Name:              "table1",
+		PrimaryKeyColumns: []string{"id"},
+	}
+	kr, err := key.ParseShardingSpec("-40-80-")
+	if err != nil {
+		t.Fatalf("ParseShardingSpec failed: %v", err)
+	}
+	sourceFilter, err := keyRangeFilter("keyspace_id", key.KIT_UINT64, kr[1])
+	if err != nil {
+		t.Fatalf("keyRangeFilter failed: %v", err)
+	}
+
+	got := clearChunkQuery("vt_ks", td, []string{"", "100", ""}, 1, sourceFilter)
+	want := "DELETE FROM `vt_ks`.table1 WHERE id>=100 AND keyspace_id>=4611686018427387904 AND keyspace_id<9223372036854775808"
+	if got != want {
+		t.Errorf("clearChunkQuery:\ngot  %v\nwant %v", got, want)
+	}
+
+	// with a single chunk, only the rows of the source are deleted
+	got = clearChunkQuery("vt_ks", td, []string{"", ""}, 0, sourceFilter)
+	want = "DELETE FROM `vt_ks`.table1 WHERE keyspace_id>=4611686018427387904 AND keyspace_id<9223372036854775808"
+	if got != want {
+		t.Errorf("clearChunkQuery:\ngot  %v\nwant %v", got, want)
+	}
+
+	// a bytes keyspace id is compared in hex
+	sourceFilter, err = keyRangeFilter("keyspace_id", key.KIT_BYTES, kr[0])
+	if err != nil {
+		t.Fatalf("keyRangeFilter failed: %v", err)
+	}
+	if want := "HEX(keyspace_id)<'40'"; sourceFilter != want {
+		t.Errorf("keyRangeFilter: got %v, want %v", sourceFilter, want)
+	}
+
+	// a source with the whole keyrange has no filter
+	sourceFilter, err = keyRangeFilter("keyspace_id", key.KIT_UINT64, key.KeyRange{})
+	if err != nil || sourceFilter != "" {
+		t.Errorf("keyRangeFilter(full keyrange) = %q, %v, want an empty filter", sourceFilter, err)
+	}
+}
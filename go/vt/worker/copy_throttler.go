@@ -0,0 +1,287 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+)
+
+var (
+	// throttleCheckInterval is how often the throttler measures
+	// the destination shards.
+	throttleCheckInterval = 5 * time.Second
+
+	// throttleMinDelay is the first delay between inserts when a
+	// destination shard goes over a limit. The delay doubles for
+	// as long as the shard stays over the limit, and halves once
+	// it's back under.
+	throttleMinDelay = 10 * time.Millisecond
+
+	// throttleMaxDelay is the longest delay between inserts.
+	throttleMaxDelay = 10 * time.Second
+)
+
+// ThrottlerLimits are the limits a copy respects on its destination
+// shards. A zero value disables a limit.
+type ThrottlerLimits struct {
+	// MaxReplicationLag is the replication lag of the serving
+	// destination slaves above which the inserts slow down.
+	MaxReplicationLag time.Duration
+
+	// MaxThreadsRunning is the number of running threads of the
+	// destination master above which the inserts slow down.
+	MaxThreadsRunning int
+
+	// MaxInsertRate is the maximum number of inserts per second
+	// on each destination shard.
+	MaxInsertRate int
+}
+
+// Throttled is implemented by the workers whose copy can be
+// throttled while they run.
+type Throttled interface {
+	// Throttler returns the throttler of the copy.
+	Throttler() *CopyThrottler
+}
+
+// CopyThrottler paces the inserts of a copy on each destination
+// shard. It measures the replication lag and the load of the
+// shards, and adapts the delay between inserts to stay under
+// its limits. The limits can be changed while the copy runs.
+type CopyThrottler struct {
+	wr *wrangler.Wrangler
+
+	// all subsequent fields are protected by the mutex
+	mu     sync.Mutex
+	limits ThrottlerLimits
+	shards map[string]*shardThrottle
+}
+
+// shardThrottle is the throttling state of a destination shard.
+type shardThrottle struct {
+	// delay is the adaptive delay between inserts.
+	delay time.Duration
+	// next is when the next insert can be executed.
+	next time.Time
+
+	// last measurements, for display
+	lag            time.Duration
+	threadsRunning int
+}
+
+func newCopyThrottler(wr *wrangler.Wrangler) *CopyThrottler {
+	return &CopyThrottler{
+		wr:     wr,
+		shards: make(map[string]*shardThrottle),
+	}
+}
+
+// Limits returns the current limits.
+func (ct *CopyThrottler) Limits() ThrottlerLimits {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	return ct.limits
+}
+
+// SetLimits changes the limits. They apply to the next insert
+// and measurement.
+func (ct *CopyThrottler) SetLimits(limits ThrottlerLimits) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.limits = limits
+	if limits.MaxReplicationLag == 0 && limits.MaxThreadsRunning == 0 {
+		for _, st := range ct.shards {
+			st.delay = 0
+		}
+	}
+	ct.wr.Logger().Infof("Copy throttler limits are now: %+v", limits)
+}
+
+func (ct *CopyThrottler) shardLocked(shard string) *shardThrottle {
+	st, ok := ct.shards[shard]
+	if !ok {
+		st = &shardThrottle{}
+		ct.shards[shard] = st
+	}
+	return st
+}
+
+// wait blocks until the next insert on the shard can be executed.
+// It returns true if the context was canceled while waiting.
+func (ct *CopyThrottler) wait(ctx context.Context, shard string) bool {
+	ct.mu.Lock()
+	st := ct.shardLocked(shard)
+	interval := st.delay
+	if ct.limits.MaxInsertRate > 0 {
+		if rateInterval := time.Second / time.Duration(ct.limits.MaxInsertRate); rateInterval > interval {
+			interval = rateInterval
+		}
+	}
+	now := time.Now()
+	next := st.next
+	if next.Before(now) {
+		next = now
+	}
+	st.next = next.Add(interval)
+	ct.mu.Unlock()
+
+	d := next.Sub(now)
+	if d <= 0 {
+		return false
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return false
+	case <-ctx.Done():
+		return true
+	}
+}
+
+// adjust doubles the delay of a shard that is over its limits, and
+// halves it otherwise.
+func (ct *CopyThrottler) adjust(shard string, lag time.Duration, threadsRunning int) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	st := ct.shardLocked(shard)
+	st.lag = lag
+	st.threadsRunning = threadsRunning
+
+	over := (ct.limits.MaxReplicationLag > 0 && lag > ct.limits.MaxReplicationLag) ||
+		(ct.limits.MaxThreadsRunning > 0 && threadsRunning > ct.limits.MaxThreadsRunning)
+	if over {
+		st.delay *= 2
+		if st.delay < throttleMinDelay {
+			st.delay = throttleMinDelay
+		}
+		if st.delay > throttleMaxDelay {
+			st.delay = throttleMaxDelay
+		}
+		return
+	}
+	st.delay /= 2
+	if st.delay < throttleMinDelay {
+		st.delay = 0
+	}
+}
+
+// run measures the destination shards every throttleCheckInterval,
+// until the context is done.
+func (ct *CopyThrottler) run(ctx context.Context, r Resolver, keyspace string, shards []string) {
+	ticker := time.NewTicker(throttleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		limits := ct.Limits()
+		if limits.MaxReplicationLag == 0 && limits.MaxThreadsRunning == 0 {
+			continue
+		}
+		for _, shard := range shards {
+			ct.check(ctx, r, keyspace, shard, limits)
+		}
+	}
+}
+
+// check measures one destination shard, and adjusts its delay.
+// A measurement that fails is logged, and counts as zero.
+func (ct *CopyThrottler) check(ctx context.Context, r Resolver, keyspace, shard string, limits ThrottlerLimits) {
+	var lag time.Duration
+	if limits.MaxReplicationLag > 0 {
+		var err error
+		lag, err = ct.replicationLag(ctx, keyspace, shard)
+		if err != nil {
+			ct.wr.Logger().Warningf("Cannot get the replication lag of %v/%v: %v", keyspace, shard, err)
+		}
+	}
+	threadsRunning := 0
+	if limits.MaxThreadsRunning > 0 {
+		var err error
+		threadsRunning, err = ct.threadsRunning(ctx, r, shard)
+		if err != nil {
+			ct.wr.Logger().Warningf("Cannot get the threads running on the master of %v/%v: %v", keyspace, shard, err)
+		}
+	}
+	ct.adjust(shard, lag, threadsRunning)
+}
+
+// replicationLag returns the highest replication lag of the
+// serving slaves of a shard.
+func (ct *CopyThrottler) replicationLag(ctx context.Context, keyspace, shard string) (time.Duration, error) {
+	tabletMap, err := topo.GetTabletMapForShard(ctx, ct.wr.TopoServer(), keyspace, shard)
+	if err != nil && err != topo.ErrPartialResult {
+		return 0, err
+	}
+	var lag time.Duration
+	for _, ti := range tabletMap {
+		if ti.Type == topo.TYPE_MASTER || !ti.IsInServingGraph() {
+			continue
+		}
+		shortCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		status, err := ct.wr.TabletManagerClient().SlaveStatus(shortCtx, ti)
+		cancel()
+		if err != nil {
+			return 0, fmt.Errorf("SlaveStatus failed on %v: %v", ti.Alias, err)
+		}
+		if l := time.Duration(status.SecondsBehindMaster) * time.Second; l > lag {
+			lag = l
+		}
+	}
+	return lag, nil
+}
+
+// threadsRunning returns the number of running threads of the
+// master of a shard.
+func (ct *CopyThrottler) threadsRunning(ctx context.Context, r Resolver, shard string) (int, error) {
+	ti, err := r.GetDestinationMaster(shard)
+	if err != nil {
+		return 0, err
+	}
+	shortCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	qr, err := ct.wr.TabletManagerClient().ExecuteFetchAsApp(shortCtx, ti, "SHOW GLOBAL STATUS LIKE 'Threads_running'", 1, false)
+	cancel()
+	if err != nil {
+		return 0, err
+	}
+	if len(qr.Rows) != 1 || len(qr.Rows[0]) != 2 {
+		return 0, fmt.Errorf("unexpected result for Threads_running: %v", qr.Rows)
+	}
+	return strconv.Atoi(qr.Rows[0][1].String())
+}
+
+// String returns the limits and the state of each shard.
+func (ct *CopyThrottler) String() string {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	result := fmt.Sprintf("max replication lag %v, max threads running %v, max insert rate %v/s", ct.limits.MaxReplicationLag, ct.limits.MaxThreadsRunning, ct.limits.MaxInsertRate)
+	shards := make([]string, 0, len(ct.shards))
+	for shard := range ct.shards {
+		shards = append(shards, shard)
+	}
+	sort.Strings(shards)
+	parts := make([]string, 0, len(shards))
+	for _, shard := range shards {
+		st := ct.shards[shard]
+		parts = append(parts, fmt.Sprintf("%v: delay %v (lag %v, threads running %v)", shard, st.delay, st.lag, st.threadsRunning))
+	}
+	if len(parts) > 0 {
+		result += "; " + strings.Join(parts, ", ")
+	}
+	return result
+}
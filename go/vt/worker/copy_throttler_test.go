@@ -0,0 +1,102 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+)
+
+func newTestCopyThrottler(t *testing.T) *CopyThrottler {
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+	return newCopyThrottler(wr)
+}
+
+func TestCopyThrottlerAdjust(t *testing.T) {
+	ct := newTestCopyThrottler(t)
+	ct.SetLimits(ThrottlerLimits{
+		MaxReplicationLag: 10 * time.Second,
+		MaxThreadsRunning: 20,
+	})
+
+	checkDelay := func(want time.Duration) {
+		if got := ct.shards["-80"].delay; got != want {
+			t.Errorf("delay = %v, want %v", got, want)
+		}
+	}
+
+	// under the limits, no delay
+	ct.adjust("-80", 5*time.Second, 10)
+	checkDelay(0)
+
+	// over a limit, the delay doubles
+	ct.adjust("-80", 20*time.Second, 10)
+	checkDelay(throttleMinDelay)
+	ct.adjust("-80", 5*time.Second, 30)
+	checkDelay(2 * throttleMinDelay)
+	ct.adjust("-80", 20*time.Second, 30)
+	checkDelay(4 * throttleMinDelay)
+
+	// back under the limits, it halves, down to nothing
+	ct.adjust("-80", 5*time.Second, 10)
+	checkDelay(2 * throttleMinDelay)
+	ct.adjust("-80", 5*time.Second, 10)
+	checkDelay(throttleMinDelay)
+	ct.adjust("-80", 5*time.Second, 10)
+	checkDelay(0)
+
+	// it never goes over the max delay
+	for i := 0; i < 20; i++ {
+		ct.adjust("-80", 20*time.Second, 10)
+	}
+	checkDelay(throttleMaxDelay)
+
+	// removing the limits removes the delay
+	ct.SetLimits(ThrottlerLimits{})
+	checkDelay(0)
+}
+
+func TestCopyThrottlerWait(t *testing.T) {
+	ct := newTestCopyThrottler(t)
+	ctx := context.Background()
+
+	// no limits, no waiting
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if ct.wait(ctx, "-80") {
+			t.Fatalf("wait should not have been aborted")
+		}
+	}
+	if d := time.Now().Sub(start); d > 100*time.Millisecond {
+		t.Errorf("wait without limits took %v", d)
+	}
+
+	// 100 inserts per second: 6 inserts take at least 50ms
+	ct.SetLimits(ThrottlerLimits{MaxInsertRate: 100})
+	start = time.Now()
+	for i := 0; i < 6; i++ {
+		ct.wait(ctx, "80-")
+	}
+	if d := time.Now().Sub(start); d < 50*time.Millisecond {
+		t.Errorf("6 inserts at 100/s took only %v", d)
+	}
+
+	// a canceled context aborts the wait
+	ct.SetLimits(ThrottlerLimits{MaxInsertRate: 1})
+	ct.wait(ctx, "c0-")
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	if !ct.wait(cancelCtx, "c0-") {
+		t.Errorf("wait should have been aborted")
+	}
+}
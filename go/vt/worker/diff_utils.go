@@ -47,7 +47,7 @@ func NewQueryResultReaderForTablet(ctx context.Context, ts topo.Server, tabletAl
 		return nil, err
 	}
 
-	sr, clientErrFn, err := conn.StreamExecute(ctx, sql, make(map[string]interface{}), 0)
+	sr, clientErrFn, err := conn.StreamExecute(ctx, sql, make(map[string]interface{}), 0, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -223,6 +223,9 @@ type DiffReport struct {
 	extraRowsLeft  int
 	extraRowsRight int
 
+	// stats about the fixes, if any
+	fixedRows int
+
 	// QPS variables and stats
 	startingTime  time.Time
 	processingQPS int
@@ -241,6 +244,9 @@ func (dr *DiffReport) ComputeQPS() {
 }
 
 func (dr *DiffReport) String() string {
+	if dr.fixedRows > 0 {
+		return fmt.Sprintf("DiffReport{%v processed, %v matching, %v mismatched, %v extra left, %v extra right, %v fixed, %v q/s}", dr.processedRows, dr.matchingRows, dr.mismatchedRows, dr.extraRowsLeft, dr.extraRowsRight, dr.fixedRows, dr.processingQPS)
+	}
 	return fmt.Sprintf("DiffReport{%v processed, %v matching, %v mismatched, %v extra left, %v extra right, %v q/s}", dr.processedRows, dr.matchingRows, dr.mismatchedRows, dr.extraRowsLeft, dr.extraRowsRight, dr.processingQPS)
 }
 
@@ -310,8 +316,16 @@ type RowDiffer struct {
 	left         *RowReader
 	right        *RowReader
 	pkFieldCount int
+
+	// fix is called for each difference, if set.
+	fix RowFixFunc
 }
 
+// RowFixFunc makes the right side of a RowDiffer match the left side
+// for one row. right is nil if the row is missing on the right side,
+// left is nil if the row is extra on the right side.
+type RowFixFunc func(left, right []sqltypes.Value) error
+
 // NewRowDiffer returns a new RowDiffer
 func NewRowDiffer(left, right *QueryResultReader, tableDefinition *myproto.TableDefinition) (*RowDiffer, error) {
 	if len(left.Fields) != len(right.Fields) {
@@ -329,6 +343,50 @@ func NewRowDiffer(left, right *QueryResultReader, tableDefinition *myproto.Table
 	}, nil
 }
 
+// SetFixer makes the RowDiffer fix each difference it finds.
+func (rd *RowDiffer) SetFixer(fix RowFixFunc) {
+	rd.fix = fix
+}
+
+// fixRow calls the fixer, if any, and counts the fixed row.
+func (rd *RowDiffer) fixRow(dr *DiffReport, left, right []sqltypes.Value) error {
+	if rd.fix == nil {
+		return nil
+	}
+	if err := rd.fix(left, right); err != nil {
+		return err
+	}
+	dr.fixedRows++
+	return nil
+}
+
+// fixRemaining calls the fixer, if any, for all the remaining rows
+// of one side, and returns how many there were.
+func (rd *RowDiffer) fixRemaining(dr *DiffReport, rr *RowReader, isLeft bool) (int, error) {
+	if rd.fix == nil {
+		return rr.Drain()
+	}
+	count := 0
+	for {
+		row, err := rr.Next()
+		if err != nil {
+			return 0, err
+		}
+		if row == nil {
+			return count, nil
+		}
+		if isLeft {
+			err = rd.fixRow(dr, row, nil)
+		} else {
+			err = rd.fixRow(dr, nil, row)
+		}
+		if err != nil {
+			return 0, err
+		}
+		count++
+	}
+}
+
 // Go runs the diff. If there is no error, it will drain both sides.
 // If an error occurs, it will just return it and stop.
 func (rd *RowDiffer) Go(log logutil.Logger) (dr DiffReport, err error) {
@@ -364,7 +422,10 @@ func (rd *RowDiffer) Go(log logutil.Logger) (dr DiffReport, err error) {
 			}
 
 			// drain right, update count
-			if count, err := rd.right.Drain(); err != nil {
+			if err := rd.fixRow(&dr, nil, right); err != nil {
+				return dr, err
+			}
+			if count, err := rd.fixRemaining(&dr, rd.right, false); err != nil {
 				return dr, err
 			} else {
 				dr.extraRowsRight += 1 + count
@@ -374,7 +435,10 @@ func (rd *RowDiffer) Go(log logutil.Logger) (dr DiffReport, err error) {
 		if right == nil {
 			// no more rows from the right
 			// we know we have rows from left, drain, update count
-			if count, err := rd.left.Drain(); err != nil {
+			if err := rd.fixRow(&dr, left, nil); err != nil {
+				return dr, err
+			}
+			if count, err := rd.fixRemaining(&dr, rd.left, true); err != nil {
 				return dr, err
 			} else {
 				dr.extraRowsLeft += 1 + count
@@ -398,6 +462,9 @@ func (rd *RowDiffer) Go(log logutil.Logger) (dr DiffReport, err error) {
 				log.Errorf("Different content %v in same PK: %v != %v", dr.mismatchedRows, left, right)
 			}
 			dr.mismatchedRows++
+			if err := rd.fixRow(&dr, left, right); err != nil {
+				return dr, err
+			}
 			advanceLeft = true
 			advanceRight = true
 			continue
@@ -413,6 +480,9 @@ func (rd *RowDiffer) Go(log logutil.Logger) (dr DiffReport, err error) {
 				log.Errorf("Extra row %v on left: %v", dr.extraRowsLeft, left)
 			}
 			dr.extraRowsLeft++
+			if err := rd.fixRow(&dr, left, nil); err != nil {
+				return dr, err
+			}
 			advanceLeft = true
 			continue
 		} else if c > 0 {
@@ -420,6 +490,9 @@ func (rd *RowDiffer) Go(log logutil.Logger) (dr DiffReport, err error) {
 				log.Errorf("Extra row %v on right: %v", dr.extraRowsRight, right)
 			}
 			dr.extraRowsRight++
+			if err := rd.fixRow(&dr, nil, right); err != nil {
+				return dr, err
+			}
 			advanceRight = true
 			continue
 		}
@@ -431,6 +504,9 @@ func (rd *RowDiffer) Go(log logutil.Logger) (dr DiffReport, err error) {
 			log.Errorf("Different content %v in same PK: %v != %v", dr.mismatchedRows, left, right)
 		}
 		dr.mismatchedRows++
+		if err := rd.fixRow(&dr, left, right); err != nil {
+			return dr, err
+		}
 		advanceLeft = true
 		advanceRight = true
 	}
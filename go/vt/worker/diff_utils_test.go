@@ -6,11 +6,13 @@ package worker
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/sqltypes"
 	"github.com/youtube/vitess/go/vt/key"
+	"github.com/youtube/vitess/go/vt/logutil"
 	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
 )
 
@@ -124,3 +126,67 @@ func TestCompareRows(t *testing.T) {
 		}
 	}
 }
+
+func newTestQueryResultReader(fields []mproto.Field, rows [][]sqltypes.Value) *QueryResultReader {
+	output := make(chan *mproto.QueryResult, 1)
+	output <- &mproto.QueryResult{Fields: fields, Rows: rows}
+	close(output)
+	return &QueryResultReader{
+		Output:      output,
+		Fields:      fields,
+		clientErrFn: func() error { return nil },
+	}
+}
+
+func TestRowDifferFix(t *testing.T) {
+	fields := []mproto.Field{
+		{Name: "id", Type: mproto.VT_LONGLONG},
+		{Name: "msg", Type: mproto.VT_STRING},
+	}
+	row := func(id, msg string) []sqltypes.Value {
+		return []sqltypes.Value{sqltypes.MakeNumeric([]byte(id)), sqltypes.MakeString([]byte(msg))}
+	}
+	left := newTestQueryResultReader(fields, [][]sqltypes.Value{
+		row("1", "a"),
+		row("2", "b"),
+		row("4", "d"),
+		row("6", "f"),
+	})
+	right := newTestQueryResultReader(fields, [][]sqltypes.Value{
+		row("1", "a"),
+		row("2", "x"),
+		row("3", "c"),
+		row("4", "d"),
+		row("7", "g"),
+	})
+	td := &myproto.TableDefinition{
+		Name:              "t1",
+		Columns:           []string{"id", "msg"},
+		PrimaryKeyColumns: []string{"id"},
+	}
+	differ, err := NewRowDiffer(left, right, td)
+	if err != nil {
+		t.Fatalf("NewRowDiffer failed: %v", err)
+	}
+	var queries []string
+	differ.SetFixer(func(left, right []sqltypes.Value) error {
+		queries = append(queries, fixRowQuery("vt_db", td, left, right))
+		return nil
+	})
+	dr, err := differ.Go(logutil.NewConsoleLogger())
+	if err != nil {
+		t.Fatalf("Go failed: %v", err)
+	}
+	if dr.mismatchedRows != 1 || dr.extraRowsLeft != 1 || dr.extraRowsRight != 2 || dr.fixedRows != 4 {
+		t.Errorf("unexpected report: %v", dr.String())
+	}
+	want := []string{
+		"REPLACE INTO `vt_db`.`t1`(`id`, `msg`) VALUES (2, 'b')",
+		"DELETE FROM `vt_db`.`t1` WHERE `id`=3",
+		"REPLACE INTO `vt_db`.`t1`(`id`, `msg`) VALUES (6, 'f')",
+		"DELETE FROM `vt_db`.`t1` WHERE `id`=7",
+	}
+	if !reflect.DeepEqual(queries, want) {
+		t.Errorf("got queries:\n%v\nwant:\n%v", strings.Join(queries, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"sort"
+	"sync"
+)
+
+// Reporter is implemented by the workers that produce a structured
+// report of their results.
+type Reporter interface {
+	// Report returns the results of the worker. It will only be
+	// called after Run() has completed.
+	Report() *Report
+}
+
+// Report is the final report of a worker.
+type Report struct {
+	Worker   string
+	Keyspace string
+	Shard    string
+	Error    string
+
+	// Tables has the results for each table, sorted by name.
+	Tables []*TableReport
+}
+
+// TableReport has the results of a worker for one table.
+type TableReport struct {
+	Name           string
+	ProcessedRows  int
+	MatchingRows   int
+	MismatchedRows int
+	ExtraRowsLeft  int
+	ExtraRowsRight int
+	FixedRows      int
+	Error          string
+}
+
+// newTableReport returns the TableReport of a table diff.
+func newTableReport(name string, dr DiffReport, err error) *TableReport {
+	tr := &TableReport{
+		Name:           name,
+		ProcessedRows:  dr.processedRows,
+		MatchingRows:   dr.matchingRows,
+		MismatchedRows: dr.mismatchedRows,
+		ExtraRowsLeft:  dr.extraRowsLeft,
+		ExtraRowsRight: dr.extraRowsRight,
+		FixedRows:      dr.fixedRows,
+	}
+	if err != nil {
+		tr.Error = err.Error()
+	}
+	return tr
+}
+
+// tableReports collects the TableReports of the tables a worker
+// processes concurrently.
+type tableReports struct {
+	mu      sync.Mutex
+	reports []*TableReport
+}
+
+func (trs *tableReports) add(tr *TableReport) {
+	trs.mu.Lock()
+	trs.reports = append(trs.reports, tr)
+	trs.mu.Unlock()
+}
+
+// sorted returns the TableReports sorted by table name.
+func (trs *tableReports) sorted() []*TableReport {
+	trs.mu.Lock()
+	defer trs.mu.Unlock()
+	result := make([]*TableReport, len(trs.reports))
+	copy(result, trs.reports)
+	sort.Sort(tableReportsByName(result))
+	return result
+}
+
+type tableReportsByName []*TableReport
+
+func (l tableReportsByName) Len() int           { return len(l) }
+func (l tableReportsByName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l tableReportsByName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+
+// newReport returns the Report of a worker.
+func newReport(worker, keyspace, shard string, err error, tables *tableReports) *Report {
+	r := &Report{
+		Worker:   worker,
+		Keyspace: keyspace,
+		Shard:    shard,
+		Tables:   tables.sorted(),
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
@@ -71,16 +71,15 @@ func (rs *RowSplitter) Split(result [][][]sqltypes.Value, rows [][]sqltypes.Valu
 }
 
 // Send will send the rows to the list of channels. Returns true if aborted.
-func (rs *RowSplitter) Send(fields []mproto.Field, result [][][]sqltypes.Value, baseCmd string, insertChannels []chan string, abort <-chan struct{}) bool {
+// chunk tracks the inserts of the table chunk the rows come from.
+func (rs *RowSplitter) Send(fields []mproto.Field, result [][][]sqltypes.Value, baseCmd string, insertChannels []chan *insertCommand, chunk *chunkTracker, abort <-chan struct{}) bool {
 	for i, c := range insertChannels {
 		// one of the chunks might be empty, so no need
 		// to send data in that case
 		if len(result[i]) > 0 {
 			cmd := baseCmd + makeValueString(fields, result[i])
 			// also check on abort, so we don't wait forever
-			select {
-			case c <- cmd:
-			case <-abort:
+			if aborted := sendInsert(c, cmd, chunk, abort); aborted {
 				return true
 			}
 		}
@@ -51,6 +51,7 @@ type SplitCloneWorker struct {
 	minTableSizeForSplit   uint64
 	destinationWriterCount int
 	cleaner                *wrangler.Cleaner
+	throttler              *CopyThrottler
 	ctx                    context.Context
 	ctxCancel              context.CancelFunc
 
@@ -107,6 +108,7 @@ func NewSplitCloneWorker(wr *wrangler.Wrangler, cell, keyspace, shard string, ex
 		minTableSizeForSplit:   minTableSizeForSplit,
 		destinationWriterCount: destinationWriterCount,
 		cleaner:                &wrangler.Cleaner{},
+		throttler:              newCopyThrottler(wr),
 		ctx:                    ctx,
 		ctxCancel:              cancel,
 
@@ -162,6 +164,7 @@ func (scw *SplitCloneWorker) StatusAsHTML() template.HTML {
 		result += "<b>Copying from</b>: " + scw.formatSources() + "</br>\n"
 		statuses, eta := formatTableStatuses(scw.tableStatus, scw.startTime)
 		result += "<b>ETA</b>: " + eta.String() + "</br>\n"
+		result += "<b>Throttle</b>: " + scw.throttler.String() + "</br>\n"
 		result += strings.Join(statuses, "</br>\n")
 	case stateSCDone:
 		result += "<b>Success</b>:</br>\n"
@@ -186,6 +189,7 @@ func (scw *SplitCloneWorker) StatusAsText() string {
 		result += "Copying from: " + scw.formatSources() + "\n"
 		statuses, eta := formatTableStatuses(scw.tableStatus, scw.startTime)
 		result += "ETA: " + eta.String() + "\n"
+		result += "Throttle: " + scw.throttler.String() + "\n"
 		result += strings.Join(statuses, "\n")
 	case stateSCDone:
 		result += "Success:\n"
@@ -195,6 +199,11 @@ func (scw *SplitCloneWorker) StatusAsText() string {
 	return result
 }
 
+// Throttler is part of the Throttled interface
+func (scw *SplitCloneWorker) Throttler() *CopyThrottler {
+	return scw.throttler
+}
+
 // Cancel is part of the Worker interface
 func (scw *SplitCloneWorker) Cancel() {
 	scw.ctxCancel()
@@ -505,7 +514,28 @@ func (scw *SplitCloneWorker) copy() error {
 		mu.Unlock()
 	}
 
-	insertChannels := make([]chan string, len(scw.destinationShards))
+	destinationShardNames := make([]string, len(scw.destinationShards))
+	for shardIndex, si := range scw.destinationShards {
+		destinationShardNames[shardIndex] = si.ShardName()
+	}
+
+	// read the progress of a previous run if we record the copied chunks
+	var cs *copyState
+	if scw.strategy.CopyCheckpoints {
+		scw.wr.Logger().Infof("Reading copy_state table on shards %v", destinationShardNames)
+		var err error
+		cs, err = loadCopyState(scw.ctx, scw.wr, scw, destinationShardNames)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the throttler measures the destinations while we copy
+	throttleCtx, throttleCancel := context.WithCancel(scw.ctx)
+	defer throttleCancel()
+	go scw.throttler.run(throttleCtx, scw, scw.keyspace, destinationShardNames)
+
+	insertChannels := make([]chan *insertCommand, len(scw.destinationShards))
 	destinationWaitGroup := sync.WaitGroup{}
 	for shardIndex, si := range scw.destinationShards {
 		// we create one channel per destination tablet.  It
@@ -513,14 +543,14 @@ func (scw *SplitCloneWorker) copy() error {
 		// destinationWriterCount * 2 items, to hopefully
 		// always have data. We then have
 		// destinationWriterCount go routines reading from it.
-		insertChannels[shardIndex] = make(chan string, scw.destinationWriterCount*2)
+		insertChannels[shardIndex] = make(chan *insertCommand, scw.destinationWriterCount*2)
 
-		go func(shardName string, insertChannel chan string) {
+		go func(shardName string, insertChannel chan *insertCommand) {
 			for j := 0; j < scw.destinationWriterCount; j++ {
 				destinationWaitGroup.Add(1)
 				go func() {
 					defer destinationWaitGroup.Done()
-					if err := executeFetchLoop(scw.ctx, scw.wr, scw, shardName, insertChannel); err != nil {
+					if err := executeFetchLoop(scw.ctx, scw.wr, scw, shardName, insertChannel, scw.throttler); err != nil {
 						processError("executeFetchLoop failed: %v", err)
 					}
 				}()
@@ -533,6 +563,14 @@ func (scw *SplitCloneWorker) copy() error {
 	sourceWaitGroup := sync.WaitGroup{}
 	for shardIndex := range scw.sourceShards {
 		sema := sync2.NewSemaphore(scw.sourceReaderCount, 0)
+		var position string
+		if cs != nil {
+			var err error
+			position, err = sourcePosition(scw.ctx, scw.wr, scw.sourceTablets[shardIndex])
+			if err != nil {
+				return err
+			}
+		}
 		for tableIndex, td := range sourceSchemaDefinition.TableDefinitions {
 			if td.Type == myproto.TABLE_VIEW {
 				continue
@@ -540,15 +578,29 @@ func (scw *SplitCloneWorker) copy() error {
 
 			rowSplitter := NewRowSplitter(scw.destinationShards, scw.keyspaceInfo.ShardingColumnType, columnIndexes[tableIndex])
 
-			chunks, err := findChunks(scw.ctx, scw.wr, scw.sourceTablets[shardIndex], td, scw.minTableSizeForSplit, scw.sourceReaderCount)
+			sourceShardName := scw.sourceShards[shardIndex].ShardName()
+			sourceFilter, err := keyRangeFilter(scw.keyspaceInfo.ShardingColumnName, scw.keyspaceInfo.ShardingColumnType, scw.sourceShards[shardIndex].KeyRange)
+			if err != nil {
+				return err
+			}
+			chunks, doneRows, err := prepareChunks(scw.ctx, scw.wr, cs, scw.sourceTablets[shardIndex], td, sourceShardName, position, sourceFilter, scw.minTableSizeForSplit, scw.sourceReaderCount)
 			if err != nil {
 				return err
 			}
 			scw.tableStatus[tableIndex].setThreadCount(len(chunks) - 1)
 
 			for chunkIndex := 0; chunkIndex < len(chunks)-1; chunkIndex++ {
+				if rows, ok := doneRows[chunkIndex]; ok {
+					scw.tableStatus[tableIndex].chunkSkipped(rows)
+					continue
+				}
+				var chunk *chunkTracker
+				if cs != nil {
+					chunk = cs.newTracker(scw.ctx, td.Name, sourceShardName, position, chunkIndex, processError)
+				}
+
 				sourceWaitGroup.Add(1)
-				go func(td *myproto.TableDefinition, tableIndex, chunkIndex int) {
+				go func(td *myproto.TableDefinition, tableIndex, chunkIndex int, chunk *chunkTracker) {
 					defer sourceWaitGroup.Done()
 
 					sema.Acquire()
@@ -566,11 +618,13 @@ func (scw *SplitCloneWorker) copy() error {
 					defer qrr.Close()
 
 					// process the data
-					if err := scw.processData(td, tableIndex, qrr, rowSplitter, insertChannels, scw.destinationPackCount, scw.ctx.Done()); err != nil {
+					if err := scw.processData(td, tableIndex, qrr, rowSplitter, insertChannels, chunk, scw.destinationPackCount, scw.ctx.Done()); err != nil {
 						processError("processData failed: %v", err)
+					} else if scw.ctx.Err() == nil {
+						chunk.allRead()
 					}
 					scw.tableStatus[tableIndex].threadDone()
-				}(td, tableIndex, chunkIndex)
+				}(td, tableIndex, chunkIndex, chunk)
 			}
 		}
 	}
@@ -580,6 +634,7 @@ func (scw *SplitCloneWorker) copy() error {
 		close(insertChannels[shardIndex])
 	}
 	destinationWaitGroup.Wait()
+	throttleCancel()
 	if firstError != nil {
 		return firstError
 	}
@@ -621,6 +676,18 @@ func (scw *SplitCloneWorker) copy() error {
 		}
 	}
 
+	// the copy is complete, a later one must not skip its chunks
+	if cs != nil {
+		sourceShardNames := make([]string, len(scw.sourceShards))
+		for shardIndex, si := range scw.sourceShards {
+			sourceShardNames[shardIndex] = si.ShardName()
+		}
+		scw.wr.Logger().Infof("Clearing copy_state table on shards %v", destinationShardNames)
+		if err := cs.clear(scw.ctx, sourceShardNames); err != nil {
+			return err
+		}
+	}
+
 	// Now we're done with data copy, update the shard's source info.
 	// TODO(alainjobart) this is a superset, some shards may not
 	// overlap, have to deal with this better (for N -> M splits
@@ -667,7 +734,7 @@ func (scw *SplitCloneWorker) copy() error {
 
 // processData pumps the data out of the provided QueryResultReader.
 // It returns any error the source encounters.
-func (scw *SplitCloneWorker) processData(td *myproto.TableDefinition, tableIndex int, qrr *QueryResultReader, rowSplitter *RowSplitter, insertChannels []chan string, destinationPackCount int, abort <-chan struct{}) error {
+func (scw *SplitCloneWorker) processData(td *myproto.TableDefinition, tableIndex int, qrr *QueryResultReader, rowSplitter *RowSplitter, insertChannels []chan *insertCommand, chunk *chunkTracker, destinationPackCount int, abort <-chan struct{}) error {
 	baseCmd := td.Name + "(" + strings.Join(td.Columns, ", ") + ") VALUES "
 	sr := rowSplitter.StartSplit()
 	packCount := 0
@@ -686,7 +753,7 @@ func (scw *SplitCloneWorker) processData(td *myproto.TableDefinition, tableIndex
 				// the return value, we don't care
 				// here if we're aborted)
 				if packCount > 0 {
-					rowSplitter.Send(qrr.Fields, sr, baseCmd, insertChannels, abort)
+					rowSplitter.Send(qrr.Fields, sr, baseCmd, insertChannels, chunk, abort)
 				}
 				return nil
 			}
@@ -697,6 +764,7 @@ func (scw *SplitCloneWorker) processData(td *myproto.TableDefinition, tableIndex
 				return fmt.Errorf("RowSplitter failed for table %v: %v", td.Name, err)
 			}
 			scw.tableStatus[tableIndex].addCopiedRows(len(r.Rows))
+			chunk.addRows(len(r.Rows))
 
 			// see if we reach the destination pack count
 			packCount++
@@ -705,7 +773,7 @@ func (scw *SplitCloneWorker) processData(td *myproto.TableDefinition, tableIndex
 			}
 
 			// send the rows to be inserted
-			if aborted := rowSplitter.Send(qrr.Fields, sr, baseCmd, insertChannels, abort); aborted {
+			if aborted := rowSplitter.Send(qrr.Fields, sr, baseCmd, insertChannels, chunk, abort); aborted {
 				return nil
 			}
 
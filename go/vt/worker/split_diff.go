@@ -64,6 +64,7 @@ type SplitDiffWorker struct {
 	// populated during stateSDDiff
 	sourceSchemaDefinitions     []*myproto.SchemaDefinition
 	destinationSchemaDefinition *myproto.SchemaDefinition
+	tableReports                tableReports
 }
 
 // NewSplitDiffWorker returns a new SplitDiffWorker object.
@@ -176,6 +177,11 @@ func (sdw *SplitDiffWorker) Error() error {
 	return sdw.err
 }
 
+// Report is part of the Reporter interface.
+func (sdw *SplitDiffWorker) Report() *Report {
+	return newReport("SplitDiff", sdw.keyspace, sdw.shard, sdw.err, &sdw.tableReports)
+}
+
 func (sdw *SplitDiffWorker) run() error {
 	// first state: read what we need to do
 	if err := sdw.init(); err != nil {
@@ -460,11 +466,13 @@ func (sdw *SplitDiffWorker) diff() error {
 			overlap, err := key.KeyRangesOverlap(sdw.shardInfo.KeyRange, sdw.shardInfo.SourceShards[0].KeyRange)
 			if err != nil {
 				sdw.wr.Logger().Errorf("Source shard doesn't overlap with destination????: %v", err)
+				sdw.tableReports.add(newTableReport(tableDefinition.Name, DiffReport{}, err))
 				return
 			}
 			sourceQueryResultReader, err := TableScanByKeyRange(sdw.ctx, sdw.wr.Logger(), sdw.wr.TopoServer(), sdw.sourceAliases[0], tableDefinition, overlap, sdw.keyspaceInfo.ShardingColumnType)
 			if err != nil {
 				sdw.wr.Logger().Errorf("TableScanByKeyRange(source) failed: %v", err)
+				sdw.tableReports.add(newTableReport(tableDefinition.Name, DiffReport{}, err))
 				return
 			}
 			defer sourceQueryResultReader.Close()
@@ -472,6 +480,7 @@ func (sdw *SplitDiffWorker) diff() error {
 			destinationQueryResultReader, err := TableScanByKeyRange(sdw.ctx, sdw.wr.Logger(), sdw.wr.TopoServer(), sdw.destinationAlias, tableDefinition, key.KeyRange{}, sdw.keyspaceInfo.ShardingColumnType)
 			if err != nil {
 				sdw.wr.Logger().Errorf("TableScanByKeyRange(destination) failed: %v", err)
+				sdw.tableReports.add(newTableReport(tableDefinition.Name, DiffReport{}, err))
 				return
 			}
 			defer destinationQueryResultReader.Close()
@@ -479,10 +488,12 @@ func (sdw *SplitDiffWorker) diff() error {
 			differ, err := NewRowDiffer(sourceQueryResultReader, destinationQueryResultReader, tableDefinition)
 			if err != nil {
 				sdw.wr.Logger().Errorf("NewRowDiffer() failed: %v", err)
+				sdw.tableReports.add(newTableReport(tableDefinition.Name, DiffReport{}, err))
 				return
 			}
 
 			report, err := differ.Go(sdw.wr.Logger())
+			sdw.tableReports.add(newTableReport(tableDefinition.Name, report, err))
 			if err != nil {
 				sdw.wr.Logger().Errorf("Differ.Go failed: %v", err.Error())
 			} else {
@@ -0,0 +1,462 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+)
+
+// This file contains the workflow splitting a shard end to end: it
+// sequences the steps an operator would otherwise run one by one, and
+// saves its progress in the source shard so it can be run again after
+// an interruption, and resume where it stopped.
+
+const (
+	// all the states for the worker
+	stateSSNotSarted = "not started"
+	stateSSDone      = "done"
+	stateSSError     = "error"
+
+	stateSSInit = "initializing"
+)
+
+// The steps of the workflow, in order. The last completed step is
+// saved in topo.SplitShardState.Step.
+const (
+	splitShardStepCreateShards   = "create_shards"
+	splitShardStepCheckTablets   = "check_tablets"
+	splitShardStepClone          = "clone"
+	splitShardStepReplication    = "filtered_replication"
+	splitShardStepDiff           = "diff"
+	splitShardStepMigrateRdonly  = "migrate_rdonly"
+	splitShardStepMigrateReplica = "migrate_replica"
+	splitShardStepMigrateMaster  = "migrate_master"
+)
+
+// splitShardReplicationPollInterval is how often the filtered
+// replication lag of the destination masters is checked.
+const splitShardReplicationPollInterval = 10 * time.Second
+
+type splitShardStep struct {
+	name  string
+	state string
+	run   func() error
+}
+
+// SplitShardWorker splits a shard into destination shards: it creates
+// them, checks their tablets are provisioned, clones the data, waits
+// for filtered replication to catch up, diffs the destination shards,
+// and migrates the rdonly, replica and master traffic to them.
+type SplitShardWorker struct {
+	wr                          *wrangler.Wrangler
+	cell                        string
+	keyspace                    string
+	shard                       string
+	destinations                []string
+	excludeTables               []string
+	maxReplicationLag           time.Duration
+	filteredReplicationWaitTime time.Duration
+	cloneWorker                 Worker
+	ctx                         context.Context
+	ctxCancel                   context.CancelFunc
+
+	// all subsequent fields are protected by the mutex
+	mu    sync.Mutex
+	state string
+
+	// populated if state == stateSSError
+	err error
+
+	// the last completed step, populated during stateSSInit and
+	// updated after each step
+	completedStep string
+
+	// the worker running the current step, if any
+	current Worker
+}
+
+// NewSplitShardWorker returns a new SplitShardWorker object. The clone
+// parameters are the ones of NewSplitCloneWorker.
+func NewSplitShardWorker(wr *wrangler.Wrangler, cell, keyspace, shard string, destinations, excludeTables []string, strategy string, sourceReaderCount, destinationPackCount int, minTableSizeForSplit uint64, destinationWriterCount int, maxReplicationLag, filteredReplicationWaitTime time.Duration) (Worker, error) {
+	if err := topo.ValidateShardSplit(shard, destinations); err != nil {
+		return nil, err
+	}
+	cloneWorker, err := NewSplitCloneWorker(wr, cell, keyspace, shard, excludeTables, strategy, sourceReaderCount, destinationPackCount, minTableSizeForSplit, destinationWriterCount)
+	if err != nil {
+		return nil, err
+	}
+	sortedDestinations := make([]string, len(destinations))
+	copy(sortedDestinations, destinations)
+	sort.Strings(sortedDestinations)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SplitShardWorker{
+		wr:                          wr,
+		cell:                        cell,
+		keyspace:                    keyspace,
+		shard:                       shard,
+		destinations:                sortedDestinations,
+		excludeTables:               excludeTables,
+		maxReplicationLag:           maxReplicationLag,
+		filteredReplicationWaitTime: filteredReplicationWaitTime,
+		cloneWorker:                 cloneWorker,
+		ctx:                         ctx,
+		ctxCancel:                   cancel,
+
+		state: stateSSNotSarted,
+	}, nil
+}
+
+func (ssw *SplitShardWorker) setState(state string) {
+	ssw.mu.Lock()
+	ssw.state = state
+	statsState.Set(state)
+	ssw.mu.Unlock()
+}
+
+func (ssw *SplitShardWorker) recordError(err error) {
+	ssw.mu.Lock()
+	ssw.state = stateSSError
+	statsState.Set(stateSSError)
+	ssw.err = err
+	ssw.mu.Unlock()
+}
+
+// StatusAsHTML is part of the Worker interface.
+func (ssw *SplitShardWorker) StatusAsHTML() template.HTML {
+	ssw.mu.Lock()
+	defer ssw.mu.Unlock()
+	result := "<b>Splitting:</b> " + ssw.keyspace + "/" + ssw.shard + " into " + strings.Join(ssw.destinations, ", ") + "</br>\n"
+	result += "<b>State:</b> " + ssw.state + "</br>\n"
+	if ssw.completedStep != "" {
+		result += "<b>Last completed step:</b> " + ssw.completedStep + "</br>\n"
+	}
+	switch ssw.state {
+	case stateSSError:
+		result += "<b>Error</b>: " + ssw.err.Error() + "</br>\n"
+	case stateSSDone:
+		result += "<b>Success</b></br>\n"
+	}
+	if ssw.current != nil {
+		result += "</br>\n" + string(ssw.current.StatusAsHTML())
+	}
+	return template.HTML(result)
+}
+
+// StatusAsText is part of the Worker interface.
+func (ssw *SplitShardWorker) StatusAsText() string {
+	ssw.mu.Lock()
+	defer ssw.mu.Unlock()
+	result := "Splitting: " + ssw.keyspace + "/" + ssw.shard + " into " + strings.Join(ssw.destinations, ", ") + "\n"
+	result += "State: " + ssw.state + "\n"
+	if ssw.completedStep != "" {
+		result += "Last completed step: " + ssw.completedStep + "\n"
+	}
+	switch ssw.state {
+	case stateSSError:
+		result += "Error: " + ssw.err.Error() + "\n"
+	case stateSSDone:
+		result += "Success.\n"
+	}
+	if ssw.current != nil {
+		result += "\n" + ssw.current.StatusAsText()
+	}
+	return result
+}
+
+// Cancel is part of the Worker interface. The step that is running is
+// interrupted, the workflow can be run again to resume from it.
+func (ssw *SplitShardWorker) Cancel() {
+	ssw.ctxCancel()
+	ssw.mu.Lock()
+	if ssw.current != nil {
+		ssw.current.Cancel()
+	}
+	ssw.mu.Unlock()
+}
+
+func (ssw *SplitShardWorker) checkInterrupted() bool {
+	select {
+	case <-ssw.ctx.Done():
+		if ssw.ctx.Err() == context.DeadlineExceeded {
+			return false
+		}
+		ssw.recordError(topo.ErrInterrupted)
+		return true
+	default:
+	}
+	return false
+}
+
+// Run is part of the Worker interface.
+func (ssw *SplitShardWorker) Run() {
+	resetVars()
+	if err := ssw.run(); err != nil {
+		ssw.recordError(err)
+		return
+	}
+	ssw.setState(stateSSDone)
+}
+
+func (ssw *SplitShardWorker) Error() error {
+	return ssw.err
+}
+
+func (ssw *SplitShardWorker) steps() []splitShardStep {
+	return []splitShardStep{
+		{splitShardStepCreateShards, "creating the destination shards", ssw.createShards},
+		{splitShardStepCheckTablets, "checking the destination tablets", ssw.checkTablets},
+		{splitShardStepClone, "cloning the data", func() error {
+			return ssw.runWorker(ssw.cloneWorker)
+		}},
+		{splitShardStepReplication, "waiting for filtered replication", ssw.waitForFilteredReplication},
+		{splitShardStepDiff, "diffing the destination shards", ssw.diff},
+		{splitShardStepMigrateRdonly, "migrating the rdonly traffic", func() error {
+			return ssw.migrate(topo.TYPE_RDONLY)
+		}},
+		{splitShardStepMigrateReplica, "migrating the replica traffic", func() error {
+			return ssw.migrate(topo.TYPE_REPLICA)
+		}},
+		{splitShardStepMigrateMaster, "migrating the master traffic", func() error {
+			return ssw.migrate(topo.TYPE_MASTER)
+		}},
+	}
+}
+
+func (ssw *SplitShardWorker) run() error {
+	// first state: read the checkpoint
+	if err := ssw.init(); err != nil {
+		return fmt.Errorf("init() failed: %v", err)
+	}
+
+	// then run the steps after the last completed one
+	steps := ssw.steps()
+	next := 0
+	if ssw.completedStep != "" {
+		for i, step := range steps {
+			if step.name == ssw.completedStep {
+				next = i + 1
+				break
+			}
+		}
+		if next == 0 {
+			return fmt.Errorf("unknown step %v in the checkpoint of %v/%v", ssw.completedStep, ssw.keyspace, ssw.shard)
+		}
+		ssw.wr.Logger().Infof("Resuming the split of %v/%v after step %v", ssw.keyspace, ssw.shard, ssw.completedStep)
+	}
+	for _, step := range steps[next:] {
+		if ssw.checkInterrupted() {
+			return topo.ErrInterrupted
+		}
+		ssw.setState(step.state)
+		if err := step.run(); err != nil {
+			// A canceled context can appear to cause an application error
+			if ssw.checkInterrupted() {
+				return topo.ErrInterrupted
+			}
+			return fmt.Errorf("step %v failed: %v", step.name, err)
+		}
+		if err := ssw.checkpoint(step.name); err != nil {
+			return fmt.Errorf("cannot save the checkpoint after step %v: %v", step.name, err)
+		}
+	}
+
+	// the split is complete, the source shard doesn't serve anything
+	// anymore and can be deleted
+	if err := ssw.clearCheckpoint(); err != nil {
+		return fmt.Errorf("cannot clear the checkpoint: %v", err)
+	}
+	return nil
+}
+
+// init phase: read the checkpoint of the source shard, and check it is
+// for the same destination shards. If there is none, save an empty one
+// so another split of the shard can't start.
+func (ssw *SplitShardWorker) init() error {
+	ssw.setState(stateSSInit)
+
+	si, err := ssw.wr.TopoServer().GetShard(ssw.keyspace, ssw.shard)
+	if err != nil {
+		return fmt.Errorf("cannot read shard %v/%v: %v", ssw.keyspace, ssw.shard, err)
+	}
+	if si.SplitShard == nil {
+		if len(si.SourceShards) > 0 {
+			return fmt.Errorf("shard %v/%v is the destination of another split", ssw.keyspace, ssw.shard)
+		}
+		if len(si.ServedTypesMap) == 0 {
+			return fmt.Errorf("shard %v/%v doesn't serve any type, it was already split", ssw.keyspace, ssw.shard)
+		}
+		return ssw.checkpoint("")
+	}
+
+	destinations := make([]string, len(si.SplitShard.Destinations))
+	copy(destinations, si.SplitShard.Destinations)
+	sort.Strings(destinations)
+	if strings.Join(destinations, ",") != strings.Join(ssw.destinations, ",") {
+		return fmt.Errorf("shard %v/%v is already being split into %v", ssw.keyspace, ssw.shard, strings.Join(destinations, ","))
+	}
+	ssw.mu.Lock()
+	ssw.completedStep = si.SplitShard.Step
+	ssw.mu.Unlock()
+	return nil
+}
+
+// checkpoint saves step as the last completed step in the source shard.
+func (ssw *SplitShardWorker) checkpoint(step string) error {
+	si, err := ssw.wr.TopoServer().GetShard(ssw.keyspace, ssw.shard)
+	if err != nil {
+		return err
+	}
+	si.SplitShard = &topo.SplitShardState{
+		Destinations: ssw.destinations,
+		Step:         step,
+	}
+	if err := topo.UpdateShard(ssw.ctx, ssw.wr.TopoServer(), si); err != nil {
+		return err
+	}
+	ssw.mu.Lock()
+	ssw.completedStep = step
+	ssw.mu.Unlock()
+	return nil
+}
+
+// clearCheckpoint removes the checkpoint of the completed workflow
+// from the source shard.
+func (ssw *SplitShardWorker) clearCheckpoint() error {
+	si, err := ssw.wr.TopoServer().GetShard(ssw.keyspace, ssw.shard)
+	if err != nil {
+		return err
+	}
+	si.SplitShard = nil
+	return topo.UpdateShard(ssw.ctx, ssw.wr.TopoServer(), si)
+}
+
+// runWorker runs the worker of a step. Cancel also cancels it.
+func (ssw *SplitShardWorker) runWorker(wrk Worker) error {
+	ssw.mu.Lock()
+	ssw.current = wrk
+	ssw.mu.Unlock()
+	defer func() {
+		ssw.mu.Lock()
+		ssw.current = nil
+		ssw.mu.Unlock()
+	}()
+
+	// Cancel may have been called before current was set
+	if ssw.ctx.Err() != nil {
+		return topo.ErrInterrupted
+	}
+	wrk.Run()
+	return wrk.Error()
+}
+
+// createShards creates the destination shards. They overlap with the
+// source shard, so they don't serve any type.
+func (ssw *SplitShardWorker) createShards() error {
+	for _, shard := range ssw.destinations {
+		err := topo.CreateShard(ssw.wr.TopoServer(), ssw.keyspace, shard)
+		switch err {
+		case nil:
+			ssw.wr.Logger().Infof("Created shard %v/%v", ssw.keyspace, shard)
+		case topo.ErrNodeExists:
+			ssw.wr.Logger().Infof("Shard %v/%v already exists", ssw.keyspace, shard)
+		default:
+			return fmt.Errorf("cannot create shard %v/%v: %v", ssw.keyspace, shard, err)
+		}
+	}
+	return nil
+}
+
+// checkTablets checks the destination shards have a master, and that
+// all the shards have the rdonly tablets the clone and the diffs use.
+// The tablets are provisioned by the operator once the destination
+// shards exist, this step fails until they are.
+func (ssw *SplitShardWorker) checkTablets() error {
+	for _, shard := range ssw.destinations {
+		si, err := ssw.wr.TopoServer().GetShard(ssw.keyspace, shard)
+		if err != nil {
+			return fmt.Errorf("cannot read shard %v/%v: %v", ssw.keyspace, shard, err)
+		}
+		if si.MasterAlias.IsZero() {
+			return fmt.Errorf("shard %v/%v has no master, provision its tablets and run the workflow again", ssw.keyspace, shard)
+		}
+	}
+	for _, shard := range append([]string{ssw.shard}, ssw.destinations...) {
+		if _, err := findHealthyRdonlyEndPoint(ssw.wr, ssw.cell, ssw.keyspace, shard); err != nil {
+			return fmt.Errorf("shard %v/%v doesn't have enough rdonly tablets in cell %v: %v", ssw.keyspace, shard, ssw.cell, err)
+		}
+	}
+	return nil
+}
+
+// waitForFilteredReplication waits until the filtered replication lag
+// of all the destination masters is at most maxReplicationLag.
+func (ssw *SplitShardWorker) waitForFilteredReplication() error {
+	for {
+		report, err := ssw.wr.MigrateServedTypesDryRun(ssw.ctx, ssw.keyspace, ssw.shard, nil, topo.TYPE_RDONLY, false)
+		if err != nil {
+			return err
+		}
+		var lag int64
+		for _, shardLag := range report.FilteredReplicationLagSeconds {
+			if shardLag > lag {
+				lag = shardLag
+			}
+		}
+		if time.Duration(lag)*time.Second <= ssw.maxReplicationLag {
+			return nil
+		}
+		ssw.wr.Logger().Infof("Filtered replication of %v/%v is %vs behind, waiting", ssw.keyspace, ssw.shard, lag)
+
+		select {
+		case <-ssw.ctx.Done():
+			return ssw.ctx.Err()
+		case <-time.After(splitShardReplicationPollInterval):
+		}
+	}
+}
+
+// diff runs a SplitDiff on each destination shard.
+func (ssw *SplitShardWorker) diff() error {
+	for _, shard := range ssw.destinations {
+		if err := ssw.runWorker(NewSplitDiffWorker(ssw.wr, ssw.cell, ssw.keyspace, shard, ssw.excludeTables)); err != nil {
+			return fmt.Errorf("SplitDiff of %v/%v failed: %v", ssw.keyspace, shard, err)
+		}
+	}
+	return nil
+}
+
+// migrate migrates servedType from the source shard to the destination
+// shards, in all cells. If the destination shards already serve it,
+// the workflow was interrupted after the migration and before its
+// checkpoint, and there is nothing to do: MigrateServedTypes would
+// fail as the source shard doesn't serve it anymore.
+func (ssw *SplitShardWorker) migrate(servedType topo.TabletType) error {
+	migrated := true
+	for _, shard := range ssw.destinations {
+		si, err := ssw.wr.TopoServer().GetShard(ssw.keyspace, shard)
+		if err != nil {
+			return fmt.Errorf("cannot read shard %v/%v: %v", ssw.keyspace, shard, err)
+		}
+		if _, ok := si.ServedTypesMap[servedType]; !ok {
+			migrated = false
+		}
+	}
+	if migrated {
+		ssw.wr.Logger().Infof("The %v traffic of %v/%v was already migrated", servedType, ssw.keyspace, ssw.shard)
+		return nil
+	}
+	return ssw.wr.MigrateServedTypes(ssw.ctx, ssw.keyspace, ssw.shard, nil, servedType, false, false, ssw.filteredReplicationWaitTime)
+}
@@ -0,0 +1,155 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+	"github.com/youtube/vitess/go/vt/tabletmanager/faketmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+	"golang.org/x/net/context"
+)
+
+func newTestSplitShardWorker(t *testing.T, wr *wrangler.Wrangler, destinations []string) Worker {
+	wrk, err := NewSplitShardWorker(wr, "cell1", "ks", "0", destinations, nil, "", 10, 10, 1024, 10, time.Second, time.Second)
+	if err != nil {
+		t.Fatalf("NewSplitShardWorker failed: %v", err)
+	}
+	return wrk
+}
+
+func TestSplitShardResume(t *testing.T) {
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, faketmclient.NewFakeTabletManagerClient(), time.Second)
+	ctx := context.Background()
+
+	if err := ts.CreateKeyspace("ks", &topo.Keyspace{}); err != nil {
+		t.Fatalf("CreateKeyspace failed: %v", err)
+	}
+	if err := topo.CreateShard(ts, "ks", "0"); err != nil {
+		t.Fatalf("CreateShard failed: %v", err)
+	}
+
+	if _, err := NewSplitShardWorker(wr, "cell1", "ks", "0", []string{"-80", "c0-"}, nil, "", 10, 10, 1024, 10, time.Second, time.Second); err == nil {
+		t.Errorf("NewSplitShardWorker with a gap should have failed")
+	}
+
+	// the destination shards are created, then the workflow stops
+	// as they have no tablet
+	wrk := newTestSplitShardWorker(t, wr, []string{"80-", "-80"})
+	wrk.Run()
+	if err := wrk.Error(); err == nil || !strings.Contains(err.Error(), "step check_tablets failed: shard ks/-80 has no master") {
+		t.Errorf("Run returned %v, want a check_tablets error", err)
+	}
+	for _, shard := range []string{"-80", "80-"} {
+		si, err := ts.GetShard("ks", shard)
+		if err != nil {
+			t.Fatalf("GetShard(%v) failed: %v", shard, err)
+		}
+		if len(si.ServedTypesMap) != 0 {
+			t.Errorf("destination shard %v serves %v", shard, si.ServedTypesMap)
+		}
+	}
+	si, err := ts.GetShard("ks", "0")
+	if err != nil {
+		t.Fatalf("GetShard failed: %v", err)
+	}
+	want := &topo.SplitShardState{
+		Destinations: []string{"-80", "80-"},
+		Step:         splitShardStepCreateShards,
+	}
+	if !reflect.DeepEqual(si.SplitShard, want) {
+		t.Errorf("checkpoint is %+v, want %+v", si.SplitShard, want)
+	}
+
+	// running it again resumes at the tablet check
+	wrk = newTestSplitShardWorker(t, wr, []string{"-80", "80-"})
+	wrk.Run()
+	if err := wrk.Error(); err == nil || !strings.Contains(err.Error(), "step check_tablets failed") {
+		t.Errorf("Run returned %v, want a check_tablets error", err)
+	}
+
+	// another split of the shard is refused
+	wrk = newTestSplitShardWorker(t, wr, []string{"-40", "40-"})
+	wrk.Run()
+	if err := wrk.Error(); err == nil || !strings.Contains(err.Error(), "shard ks/0 is already being split into -80,80-") {
+		t.Errorf("Run returned %v, want an already being split error", err)
+	}
+
+	// a completed workflow has nothing left to do
+	si.SplitShard.Step = splitShardStepMigrateMaster
+	if err := topo.UpdateShard(ctx, ts, si); err != nil {
+		t.Fatalf("UpdateShard failed: %v", err)
+	}
+	wrk = newTestSplitShardWorker(t, wr, []string{"-80", "80-"})
+	wrk.Run()
+	if err := wrk.Error(); err != nil {
+		t.Errorf("Run of a completed workflow failed: %v", err)
+	}
+	if status := wrk.StatusAsText(); !strings.Contains(status, "Last completed step: migrate_master") {
+		t.Errorf("unexpected status: %v", status)
+	}
+	// and its checkpoint is cleared
+	if si, err = ts.GetShard("ks", "0"); err != nil {
+		t.Fatalf("GetShard failed: %v", err)
+	}
+	if si.SplitShard != nil {
+		t.Errorf("checkpoint is %+v, want none", si.SplitShard)
+	}
+}
+
+func TestSplitShardMigrateResume(t *testing.T) {
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, faketmclient.NewFakeTabletManagerClient(), time.Second)
+	ctx := context.Background()
+
+	if err := ts.CreateKeyspace("ks", &topo.Keyspace{}); err != nil {
+		t.Fatalf("CreateKeyspace failed: %v", err)
+	}
+	for _, shard := range []string{"0", "-80", "80-"} {
+		if err := topo.CreateShard(ts, "ks", shard); err != nil {
+			t.Fatalf("CreateShard(%v) failed: %v", shard, err)
+		}
+	}
+
+	// the workflow died after the rdonly migration and before its
+	// checkpoint: the destination shards serve rdonly
+	for _, shard := range []string{"-80", "80-"} {
+		si, err := ts.GetShard("ks", shard)
+		if err != nil {
+			t.Fatalf("GetShard(%v) failed: %v", shard, err)
+		}
+		si.SourceShards = []topo.SourceShard{{Keyspace: "ks", Shard: "0"}}
+		si.ServedTypesMap = map[topo.TabletType]*topo.ShardServedType{
+			topo.TYPE_RDONLY: &topo.ShardServedType{},
+		}
+		if err := topo.UpdateShard(ctx, ts, si); err != nil {
+			t.Fatalf("UpdateShard(%v) failed: %v", shard, err)
+		}
+	}
+	ssw := newTestSplitShardWorker(t, wr, []string{"-80", "80-"}).(*SplitShardWorker)
+	if err := ssw.migrate(topo.TYPE_RDONLY); err != nil {
+		t.Errorf("migrate of a migrated type failed: %v", err)
+	}
+	// the replicas weren't migrated yet, so MigrateServedTypes runs
+	if err := ssw.migrate(topo.TYPE_REPLICA); err != nil {
+		t.Fatalf("migrate of the replicas failed: %v", err)
+	}
+	for _, shard := range []string{"-80", "80-"} {
+		si, err := ts.GetShard("ks", shard)
+		if err != nil {
+			t.Fatalf("GetShard(%v) failed: %v", shard, err)
+		}
+		if _, ok := si.ServedTypesMap[topo.TYPE_REPLICA]; !ok {
+			t.Errorf("shard %v doesn't serve the replicas after migrate: %v", shard, si.ServedTypesMap)
+		}
+	}
+}
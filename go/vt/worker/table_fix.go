@@ -0,0 +1,451 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/youtube/vitess/go/sqltypes"
+	blproto "github.com/youtube/vitess/go/vt/binlog/proto"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+)
+
+// This file contains the code to fix the data of some tables of a
+// vertical split destination shard: the rows that differ from its
+// source shard are corrected on the destination master.
+
+const (
+	// all the states for the worker
+	stateTFNotSarted = "not started"
+	stateTFDone      = "done"
+	stateTFError     = "error"
+
+	stateTFInit                   = "initializing"
+	stateTFFindTargets            = "finding target instances"
+	stateTFSynchronizeReplication = "synchronizing replication"
+	stateTFFix                    = "fixing the tables"
+	stateTFCleanUp                = "cleaning up"
+)
+
+// TableFixWorker fixes the data of some tables of a destination shard
+// in a vertical split case. Filtered replication is stopped on the
+// destination master while the tables are fixed.
+type TableFixWorker struct {
+	wr        *wrangler.Wrangler
+	cell      string
+	keyspace  string
+	shard     string
+	tables    []string
+	cleaner   *wrangler.Cleaner
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+
+	// all subsequent fields are protected by the mutex
+	mu    sync.Mutex
+	state string
+
+	// populated if state == stateTFError
+	err error
+
+	// populated during stateTFInit, read-only after that
+	shardInfo *topo.ShardInfo
+
+	// populated during stateTFFindTargets, read-only after that
+	sourceAlias topo.TabletAlias
+	masterInfo  *topo.TabletInfo
+
+	// populated during stateTFFix
+	tableReports tableReports
+}
+
+// NewTableFixWorker returns a new TableFixWorker object.
+func NewTableFixWorker(wr *wrangler.Wrangler, cell, keyspace, shard string, tables []string) Worker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TableFixWorker{
+		wr:        wr,
+		cell:      cell,
+		keyspace:  keyspace,
+		shard:     shard,
+		tables:    tables,
+		cleaner:   &wrangler.Cleaner{},
+		ctx:       ctx,
+		ctxCancel: cancel,
+
+		state: stateTFNotSarted,
+	}
+}
+
+func (tfw *TableFixWorker) setState(state string) {
+	tfw.mu.Lock()
+	tfw.state = state
+	statsState.Set(state)
+	tfw.mu.Unlock()
+}
+
+func (tfw *TableFixWorker) recordError(err error) {
+	tfw.mu.Lock()
+	tfw.state = stateTFError
+	statsState.Set(stateTFError)
+	tfw.err = err
+	tfw.mu.Unlock()
+}
+
+// StatusAsHTML is part of the Worker interface.
+func (tfw *TableFixWorker) StatusAsHTML() template.HTML {
+	tfw.mu.Lock()
+	defer tfw.mu.Unlock()
+	result := "<b>Working on:</b> " + tfw.keyspace + "/" + tfw.shard + "</br>\n"
+	result += "<b>Tables:</b> " + strings.Join(tfw.tables, ", ") + "</br>\n"
+	result += "<b>State:</b> " + tfw.state + "</br>\n"
+	switch tfw.state {
+	case stateTFError:
+		result += "<b>Error</b>: " + tfw.err.Error() + "</br>\n"
+	case stateTFFix:
+		result += "<b>Running</b>:</br>\n"
+	case stateTFDone:
+		result += "<b>Success</b>:</br>\n"
+	}
+	for _, tr := range tfw.tableReports.sorted() {
+		result += fmt.Sprintf("%v: %v rows processed, %v fixed</br>\n", tr.Name, tr.ProcessedRows, tr.FixedRows)
+	}
+
+	return template.HTML(result)
+}
+
+// StatusAsText is part of the Worker interface.
+func (tfw *TableFixWorker) StatusAsText() string {
+	tfw.mu.Lock()
+	defer tfw.mu.Unlock()
+	result := "Working on: " + tfw.keyspace + "/" + tfw.shard + "\n"
+	result += "Tables: " + strings.Join(tfw.tables, ", ") + "\n"
+	result += "State: " + tfw.state + "\n"
+	switch tfw.state {
+	case stateTFError:
+		result += "Error: " + tfw.err.Error() + "\n"
+	case stateTFFix:
+		result += "Running...\n"
+	case stateTFDone:
+		result += "Success.\n"
+	}
+	for _, tr := range tfw.tableReports.sorted() {
+		result += fmt.Sprintf("%v: %v rows processed, %v fixed\n", tr.Name, tr.ProcessedRows, tr.FixedRows)
+	}
+	return result
+}
+
+// Cancel is part of the Worker interface
+func (tfw *TableFixWorker) Cancel() {
+	tfw.ctxCancel()
+}
+
+func (tfw *TableFixWorker) checkInterrupted() bool {
+	select {
+	case <-tfw.ctx.Done():
+		if tfw.ctx.Err() == context.DeadlineExceeded {
+			return false
+		}
+		tfw.recordError(topo.ErrInterrupted)
+		return true
+	default:
+	}
+	return false
+}
+
+// Run is mostly a wrapper to run the cleanup at the end.
+func (tfw *TableFixWorker) Run() {
+	resetVars()
+	err := tfw.run()
+
+	tfw.setState(stateTFCleanUp)
+	cerr := tfw.cleaner.CleanUp(tfw.wr)
+	if cerr != nil {
+		if err != nil {
+			tfw.wr.Logger().Errorf("CleanUp failed in addition to job error: %v", cerr)
+		} else {
+			err = cerr
+		}
+	}
+	if err != nil {
+		tfw.recordError(err)
+		return
+	}
+	tfw.setState(stateTFDone)
+}
+
+func (tfw *TableFixWorker) Error() error {
+	return tfw.err
+}
+
+// Report is part of the Reporter interface.
+func (tfw *TableFixWorker) Report() *Report {
+	return newReport("TableFix", tfw.keyspace, tfw.shard, tfw.err, &tfw.tableReports)
+}
+
+func (tfw *TableFixWorker) run() error {
+	// first state: read what we need to do
+	if err := tfw.init(); err != nil {
+		return fmt.Errorf("init() failed: %v", err)
+	}
+	if tfw.checkInterrupted() {
+		return topo.ErrInterrupted
+	}
+
+	// second state: find targets
+	if err := tfw.findTargets(); err != nil {
+		return fmt.Errorf("findTargets() failed: %v", err)
+	}
+	if tfw.checkInterrupted() {
+		return topo.ErrInterrupted
+	}
+
+	// third phase: synchronize replication
+	if err := tfw.synchronizeReplication(); err != nil {
+		if tfw.checkInterrupted() {
+			return topo.ErrInterrupted
+		}
+		return fmt.Errorf("synchronizeReplication() failed: %v", err)
+	}
+	if tfw.checkInterrupted() {
+		return topo.ErrInterrupted
+	}
+
+	// fourth phase: fix
+	if err := tfw.fix(); err != nil {
+		if tfw.checkInterrupted() {
+			return topo.ErrInterrupted
+		}
+		return fmt.Errorf("fix() failed: %v", err)
+	}
+
+	return nil
+}
+
+// init phase:
+// - read the shard info, make sure it has one source with tables
+func (tfw *TableFixWorker) init() error {
+	tfw.setState(stateTFInit)
+
+	if len(tfw.tables) == 0 {
+		return fmt.Errorf("no table to fix")
+	}
+
+	var err error
+	tfw.shardInfo, err = tfw.wr.TopoServer().GetShard(tfw.keyspace, tfw.shard)
+	if err != nil {
+		return fmt.Errorf("cannot read shard %v/%v: %v", tfw.keyspace, tfw.shard, err)
+	}
+	if len(tfw.shardInfo.SourceShards) != 1 {
+		return fmt.Errorf("shard %v/%v has bad number of source shards", tfw.keyspace, tfw.shard)
+	}
+	if len(tfw.shardInfo.SourceShards[0].Tables) == 0 {
+		return fmt.Errorf("shard %v/%v has no tables in source shard[0]", tfw.keyspace, tfw.shard)
+	}
+	if tfw.shardInfo.MasterAlias.IsZero() {
+		return fmt.Errorf("shard %v/%v has no master", tfw.keyspace, tfw.shard)
+	}
+
+	return nil
+}
+
+// findTargets phase:
+// - find one rdonly in the source shard, mark it as 'checker'
+// - read the destination master record
+func (tfw *TableFixWorker) findTargets() error {
+	tfw.setState(stateTFFindTargets)
+
+	ss := tfw.shardInfo.SourceShards[0]
+	var err error
+	tfw.sourceAlias, err = findChecker(tfw.ctx, tfw.wr, tfw.cleaner, tfw.cell, ss.Keyspace, ss.Shard)
+	if err != nil {
+		return fmt.Errorf("cannot find checker for %v/%v/%v: %v", tfw.cell, ss.Keyspace, ss.Shard, err)
+	}
+
+	tfw.masterInfo, err = tfw.wr.TopoServer().GetTablet(tfw.shardInfo.MasterAlias)
+	if err != nil {
+		return fmt.Errorf("cannot get Tablet record for master %v: %v", tfw.shardInfo.MasterAlias, err)
+	}
+
+	return nil
+}
+
+// synchronizeReplication phase:
+// 1 - ask the master of the destination shard to pause filtered
+//   replication, and return the source binlog position
+//   (add a cleanup task to restart filtered replication on master)
+// 2 - stop the source 'checker' at a binlog position higher than the
+//   destination master. Get that new position.
+//   (add a cleanup task to restart binlog replication on it, and change
+//    the existing ChangeSlaveType cleanup action to 'spare' type)
+// 3 - ask the master of the destination shard to run filtered
+//   replication up to the new position. It stays paused after that,
+//   until the cleanup task restarts it.
+// At this point, the source checker and the destination master are
+// stopped at the same point.
+func (tfw *TableFixWorker) synchronizeReplication() error {
+	tfw.setState(stateTFSynchronizeReplication)
+
+	// 1 - stop the master binlog replication, get its current position
+	tfw.wr.Logger().Infof("Stopping master binlog replication on %v", tfw.shardInfo.MasterAlias)
+	ctx, cancel := context.WithTimeout(tfw.ctx, 60*time.Second)
+	blpPositionList, err := tfw.wr.TabletManagerClient().StopBlp(ctx, tfw.masterInfo)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("StopBlp on master %v failed: %v", tfw.shardInfo.MasterAlias, err)
+	}
+	wrangler.RecordStartBlpAction(tfw.cleaner, tfw.masterInfo)
+
+	// 2 - stop the source 'checker' at a binlog position
+	//     higher than the destination master
+	ss := tfw.shardInfo.SourceShards[0]
+	pos, err := blpPositionList.FindBlpPositionById(ss.Uid)
+	if err != nil {
+		return fmt.Errorf("no binlog position on the master for Uid %v", ss.Uid)
+	}
+	tfw.wr.Logger().Infof("Stopping slave %v at a minimum of %v", tfw.sourceAlias, pos.Position)
+	sourceTablet, err := tfw.wr.TopoServer().GetTablet(tfw.sourceAlias)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithTimeout(tfw.ctx, 60*time.Second)
+	stoppedAt, err := tfw.wr.TabletManagerClient().StopSlaveMinimum(ctx, sourceTablet, pos.Position, 30*time.Second)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("cannot stop slave %v at right binlog position %v: %v", tfw.sourceAlias, pos.Position, err)
+	}
+	stopPositionList := blproto.BlpPositionList{
+		Entries: []blproto.BlpPosition{
+			blproto.BlpPosition{
+				Uid:      ss.Uid,
+				Position: stoppedAt.Position,
+			},
+		},
+	}
+
+	// change the cleaner actions from ChangeSlaveType(rdonly)
+	// to StartSlave() + ChangeSlaveType(spare)
+	wrangler.RecordStartSlaveAction(tfw.cleaner, sourceTablet)
+	action, err := wrangler.FindChangeSlaveTypeActionByTarget(tfw.cleaner, tfw.sourceAlias)
+	if err != nil {
+		return fmt.Errorf("cannot find ChangeSlaveType action for %v: %v", tfw.sourceAlias, err)
+	}
+	action.TabletType = topo.TYPE_SPARE
+
+	// 3 - ask the master of the destination shard to run filtered
+	//     replication up to the new position
+	tfw.wr.Logger().Infof("Running master %v filtered replication until it catches up to %v", tfw.shardInfo.MasterAlias, stopPositionList)
+	ctx, cancel = context.WithTimeout(tfw.ctx, 60*time.Second)
+	_, err = tfw.wr.TabletManagerClient().RunBlpUntil(ctx, tfw.masterInfo, &stopPositionList, 30*time.Second)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("RunBlpUntil on %v until %v failed: %v", tfw.shardInfo.MasterAlias, stopPositionList, err)
+	}
+
+	return nil
+}
+
+// fix phase:
+// - get the schema of the tables on the destination master
+// - for each table, diff the source checker and the destination
+//   master, and fix each difference on the destination master.
+func (tfw *TableFixWorker) fix() error {
+	tfw.setState(stateTFFix)
+
+	ctx, cancel := context.WithTimeout(tfw.ctx, 60*time.Second)
+	schemaDefinition, err := tfw.wr.GetSchema(ctx, tfw.shardInfo.MasterAlias, tfw.tables, nil /* excludeTables */, false /* includeViews */)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("cannot get schema from destination master %v: %v", tfw.shardInfo.MasterAlias, err)
+	}
+	if len(schemaDefinition.TableDefinitions) == 0 {
+		return fmt.Errorf("no table matching %v on destination master %v", tfw.tables, tfw.shardInfo.MasterAlias)
+	}
+
+	// the tables are fixed one at a time, to keep the load on
+	// the destination master low
+	for _, td := range schemaDefinition.TableDefinitions {
+		if tfw.checkInterrupted() {
+			return topo.ErrInterrupted
+		}
+		report, err := tfw.fixTable(td)
+		tfw.tableReports.add(newTableReport(td.Name, report, err))
+		if err != nil {
+			return fmt.Errorf("cannot fix table %v: %v", td.Name, err)
+		}
+		if report.HasDifferences() {
+			tfw.wr.Logger().Warningf("Table %v had differences: %v", td.Name, report.String())
+		} else {
+			tfw.wr.Logger().Infof("Table %v checks out (%v rows processed, %v qps)", td.Name, report.processedRows, report.processingQPS)
+		}
+	}
+
+	return nil
+}
+
+// fixTable diffs one table, and fixes the differences.
+func (tfw *TableFixWorker) fixTable(td *myproto.TableDefinition) (DiffReport, error) {
+	tfw.wr.Logger().Infof("Starting the fix of table %v", td.Name)
+	sourceQueryResultReader, err := TableScan(tfw.ctx, tfw.wr.Logger(), tfw.wr.TopoServer(), tfw.sourceAlias, td)
+	if err != nil {
+		return DiffReport{}, fmt.Errorf("TableScan(source) failed: %v", err)
+	}
+	defer sourceQueryResultReader.Close()
+
+	destinationQueryResultReader, err := TableScan(tfw.ctx, tfw.wr.Logger(), tfw.wr.TopoServer(), tfw.shardInfo.MasterAlias, td)
+	if err != nil {
+		return DiffReport{}, fmt.Errorf("TableScan(destination) failed: %v", err)
+	}
+	defer destinationQueryResultReader.Close()
+
+	differ, err := NewRowDiffer(sourceQueryResultReader, destinationQueryResultReader, td)
+	if err != nil {
+		return DiffReport{}, fmt.Errorf("NewRowDiffer() failed: %v", err)
+	}
+	differ.SetFixer(func(left, right []sqltypes.Value) error {
+		query := fixRowQuery(tfw.masterInfo.DbName(), td, left, right)
+		ctx, cancel := context.WithTimeout(tfw.ctx, 30*time.Second)
+		_, err := tfw.wr.TabletManagerClient().ExecuteFetchAsApp(ctx, tfw.masterInfo, query, 0, false)
+		cancel()
+		return err
+	})
+	return differ.Go(tfw.wr.Logger())
+}
+
+// fixRowQuery returns the statement that makes the destination row
+// match the source row. The rows have the columns returned by
+// TableScan. A missing source row is deleted from the destination,
+// otherwise the source row replaces the destination one.
+func fixRowQuery(dbName string, td *myproto.TableDefinition, source, destination []sqltypes.Value) string {
+	columns := orderedColumns(td)
+	buf := bytes.Buffer{}
+	if source == nil {
+		fmt.Fprintf(&buf, "DELETE FROM `%s`.`%s` WHERE ", dbName, td.Name)
+		for i, pk := range td.PrimaryKeyColumns {
+			if i > 0 {
+				buf.WriteString(" AND ")
+			}
+			fmt.Fprintf(&buf, "`%s`=", pk)
+			destination[i].EncodeSql(&buf)
+		}
+		return buf.String()
+	}
+	fmt.Fprintf(&buf, "REPLACE INTO `%s`.`%s`(`%s`) VALUES (", dbName, td.Name, strings.Join(columns, "`, `"))
+	for i, value := range source {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		value.EncodeSql(&buf)
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
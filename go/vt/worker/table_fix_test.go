@@ -0,0 +1,57 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"testing"
+
+	"github.com/youtube/vitess/go/sqltypes"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+)
+
+func TestFixRowQuery(t *testing.T) {
+	td := &myproto.TableDefinition{
+		Name:              "order",
+		Columns:           []string{"msg", "id", "key"},
+		PrimaryKeyColumns: []string{"id", "key"},
+	}
+	row := func(id, key, msg string) []sqltypes.Value {
+		return []sqltypes.Value{
+			sqltypes.MakeNumeric([]byte(id)),
+			sqltypes.MakeString([]byte(key)),
+			sqltypes.MakeString([]byte(msg)),
+		}
+	}
+	testCases := []struct {
+		desc        string
+		source      []sqltypes.Value
+		destination []sqltypes.Value
+		want        string
+	}{
+		{
+			desc:        "missing row",
+			source:      row("1", "a", "msg1"),
+			destination: nil,
+			want:        "REPLACE INTO `vt_ks`.`order`(`id`, `key`, `msg`) VALUES (1, 'a', 'msg1')",
+		},
+		{
+			desc:        "extra row",
+			source:      nil,
+			destination: row("2", "b", "msg2"),
+			want:        "DELETE FROM `vt_ks`.`order` WHERE `id`=2 AND `key`='b'",
+		},
+		{
+			desc:        "mismatched row",
+			source:      row("3", "c", "it's new"),
+			destination: row("3", "c", "old"),
+			want:        "REPLACE INTO `vt_ks`.`order`(`id`, `key`, `msg`) VALUES (3, 'c', 'it\\'s new')",
+		},
+	}
+	for _, tc := range testCases {
+		if got := fixRowQuery("vt_ks", td, tc.source, tc.destination); got != tc.want {
+			t.Errorf("fixRowQuery(%v) = %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
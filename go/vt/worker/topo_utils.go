@@ -10,6 +10,7 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/youtube/vitess/go/flagutil"
 	"github.com/youtube/vitess/go/vt/servenv"
 	"github.com/youtube/vitess/go/vt/topo"
 	"github.com/youtube/vitess/go/vt/wrangler"
@@ -18,11 +19,17 @@ import (
 
 var (
 	minHealthyEndPoints = flag.Int("min_healthy_rdonly_endpoints", 2, "minimum number of healthy rdonly endpoints required for checker")
+	rdonlyTags          flagutil.StringMapValue
 )
 
+func init() {
+	flag.Var(&rdonlyTags, "rdonly_tags", "comma separated list of key:value tags the rdonly endpoints used as checkers must have")
+}
+
 // findHealthyRdonlyEndPoint returns a random healthy endpoint.
 // Since we don't want to use them all, we require at least
-// minHealthyEndPoints servers to be healthy.
+// minHealthyEndPoints servers to be healthy. With -rdonly_tags,
+// only the endpoints that have all these tags are considered.
 func findHealthyRdonlyEndPoint(wr *wrangler.Wrangler, cell, keyspace, shard string) (topo.TabletAlias, error) {
 	endPoints, err := wr.TopoServer().GetEndPoints(cell, keyspace, shard, topo.TYPE_RDONLY)
 	if err != nil {
@@ -30,7 +37,7 @@ func findHealthyRdonlyEndPoint(wr *wrangler.Wrangler, cell, keyspace, shard stri
 	}
 	healthyEndpoints := make([]topo.EndPoint, 0, len(endPoints.Entries))
 	for _, entry := range endPoints.Entries {
-		if len(entry.Health) == 0 {
+		if len(entry.Health) == 0 && topo.MatchTags(entry.Tags, rdonlyTags) {
 			healthyEndpoints = append(healthyEndpoints, entry)
 		}
 	}
@@ -50,6 +50,7 @@ type VerticalSplitCloneWorker struct {
 	minTableSizeForSplit   uint64
 	destinationWriterCount int
 	cleaner                *wrangler.Cleaner
+	throttler              *CopyThrottler
 	ctx                    context.Context
 	ctxCancel              context.CancelFunc
 
@@ -102,6 +103,7 @@ func NewVerticalSplitCloneWorker(wr *wrangler.Wrangler, cell, destinationKeyspac
 		minTableSizeForSplit:   minTableSizeForSplit,
 		destinationWriterCount: destinationWriterCount,
 		cleaner:                &wrangler.Cleaner{},
+		throttler:              newCopyThrottler(wr),
 		ctx:                    ctx,
 		ctxCancel:              cancel,
 
@@ -149,6 +151,7 @@ func (vscw *VerticalSplitCloneWorker) StatusAsHTML() template.HTML {
 		result += "<b>Copying from</b>: " + vscw.sourceAlias.String() + "</br>\n"
 		statuses, eta := formatTableStatuses(vscw.tableStatus, vscw.startTime)
 		result += "<b>ETA</b>: " + eta.String() + "</br>\n"
+		result += "<b>Throttle</b>: " + vscw.throttler.String() + "</br>\n"
 		result += strings.Join(statuses, "</br>\n")
 	case stateVSCDone:
 		result += "<b>Success</b>:</br>\n"
@@ -173,6 +176,7 @@ func (vscw *VerticalSplitCloneWorker) StatusAsText() string {
 		result += "Copying from: " + vscw.sourceAlias.String() + "\n"
 		statuses, eta := formatTableStatuses(vscw.tableStatus, vscw.startTime)
 		result += "ETA: " + eta.String() + "\n"
+		result += "Throttle: " + vscw.throttler.String() + "\n"
 		result += strings.Join(statuses, "\n")
 	case stateVSCDone:
 		result += "Success:\n"
@@ -182,6 +186,11 @@ func (vscw *VerticalSplitCloneWorker) StatusAsText() string {
 	return result
 }
 
+// Throttler is part of the Throttled interface
+func (vscw *VerticalSplitCloneWorker) Throttler() *CopyThrottler {
+	return vscw.throttler
+}
+
 // Cancel is part of the Worker interface
 func (vscw *VerticalSplitCloneWorker) Cancel() {
 	vscw.ctxCancel()
@@ -440,6 +449,22 @@ func (vscw *VerticalSplitCloneWorker) copy() error {
 		mu.Unlock()
 	}
 
+	// read the progress of a previous run if we record the copied chunks
+	var cs *copyState
+	if vscw.strategy.CopyCheckpoints {
+		vscw.wr.Logger().Infof("Reading copy_state table")
+		var err error
+		cs, err = loadCopyState(vscw.ctx, vscw.wr, vscw, []string{vscw.destinationShard})
+		if err != nil {
+			return err
+		}
+	}
+
+	// the throttler measures the destination while we copy
+	throttleCtx, throttleCancel := context.WithCancel(vscw.ctx)
+	defer throttleCancel()
+	go vscw.throttler.run(throttleCtx, vscw, vscw.destinationKeyspace, []string{vscw.destinationShard})
+
 	destinationWaitGroup := sync.WaitGroup{}
 
 	// we create one channel for the destination tablet.  It
@@ -447,15 +472,15 @@ func (vscw *VerticalSplitCloneWorker) copy() error {
 	// destinationWriterCount * 2 items, to hopefully
 	// always have data. We then have
 	// destinationWriterCount go routines reading from it.
-	insertChannel := make(chan string, vscw.destinationWriterCount*2)
+	insertChannel := make(chan *insertCommand, vscw.destinationWriterCount*2)
 
-	go func(shardName string, insertChannel chan string) {
+	go func(shardName string, insertChannel chan *insertCommand) {
 		for j := 0; j < vscw.destinationWriterCount; j++ {
 			destinationWaitGroup.Add(1)
 			go func() {
 				defer destinationWaitGroup.Done()
 
-				if err := executeFetchLoop(vscw.ctx, vscw.wr, vscw, shardName, insertChannel); err != nil {
+				if err := executeFetchLoop(vscw.ctx, vscw.wr, vscw, shardName, insertChannel, vscw.throttler); err != nil {
 					processError("executeFetchLoop failed: %v", err)
 				}
 			}()
@@ -465,20 +490,39 @@ func (vscw *VerticalSplitCloneWorker) copy() error {
 	// Now for each table, read data chunks and send them to insertChannel
 	sourceWaitGroup := sync.WaitGroup{}
 	sema := sync2.NewSemaphore(vscw.sourceReaderCount, 0)
+	var position string
+	if cs != nil {
+		var err error
+		position, err = sourcePosition(vscw.ctx, vscw.wr, vscw.sourceTablet)
+		if err != nil {
+			return err
+		}
+	}
 	for tableIndex, td := range sourceSchemaDefinition.TableDefinitions {
 		if td.Type == myproto.TABLE_VIEW {
 			continue
 		}
 
-		chunks, err := findChunks(vscw.ctx, vscw.wr, vscw.sourceTablet, td, vscw.minTableSizeForSplit, vscw.sourceReaderCount)
+		// the destination only gets the tables from one source,
+		// so the chunks don't need a source filter
+		chunks, doneRows, err := prepareChunks(vscw.ctx, vscw.wr, cs, vscw.sourceTablet, td, vscw.sourceTablet.Shard, position, "", vscw.minTableSizeForSplit, vscw.sourceReaderCount)
 		if err != nil {
 			return err
 		}
 		vscw.tableStatus[tableIndex].setThreadCount(len(chunks) - 1)
 
 		for chunkIndex := 0; chunkIndex < len(chunks)-1; chunkIndex++ {
+			if rows, ok := doneRows[chunkIndex]; ok {
+				vscw.tableStatus[tableIndex].chunkSkipped(rows)
+				continue
+			}
+			var chunk *chunkTracker
+			if cs != nil {
+				chunk = cs.newTracker(vscw.ctx, td.Name, vscw.sourceTablet.Shard, position, chunkIndex, processError)
+			}
+
 			sourceWaitGroup.Add(1)
-			go func(td *myproto.TableDefinition, tableIndex, chunkIndex int) {
+			go func(td *myproto.TableDefinition, tableIndex, chunkIndex int, chunk *chunkTracker) {
 				defer sourceWaitGroup.Done()
 
 				sema.Acquire()
@@ -496,17 +540,20 @@ func (vscw *VerticalSplitCloneWorker) copy() error {
 				defer qrr.Close()
 
 				// process the data
-				if err := vscw.processData(td, tableIndex, qrr, insertChannel, vscw.destinationPackCount, vscw.ctx.Done()); err != nil {
+				if err := vscw.processData(td, tableIndex, qrr, insertChannel, chunk, vscw.destinationPackCount, vscw.ctx.Done()); err != nil {
 					processError("QueryResultReader failed: %v", err)
+				} else if vscw.ctx.Err() == nil {
+					chunk.allRead()
 				}
 				vscw.tableStatus[tableIndex].threadDone()
-			}(td, tableIndex, chunkIndex)
+			}(td, tableIndex, chunkIndex, chunk)
 		}
 	}
 	sourceWaitGroup.Wait()
 
 	close(insertChannel)
 	destinationWaitGroup.Wait()
+	throttleCancel()
 	if firstError != nil {
 		return firstError
 	}
@@ -542,6 +589,14 @@ func (vscw *VerticalSplitCloneWorker) copy() error {
 		}
 	}
 
+	// the copy is complete, a later one must not skip its chunks
+	if cs != nil {
+		vscw.wr.Logger().Infof("Clearing copy_state table")
+		if err := cs.clear(vscw.ctx, []string{vscw.sourceTablet.Shard}); err != nil {
+			return err
+		}
+	}
+
 	// Now we're done with data copy, update the shard's source info.
 	if vscw.strategy.SkipSetSourceShards {
 		vscw.wr.Logger().Infof("Skipping setting SourceShard on destination shard.")
@@ -581,7 +636,7 @@ func (vscw *VerticalSplitCloneWorker) copy() error {
 
 // processData pumps the data out of the provided QueryResultReader.
 // It returns any error the source encounters.
-func (vscw *VerticalSplitCloneWorker) processData(td *myproto.TableDefinition, tableIndex int, qrr *QueryResultReader, insertChannel chan string, destinationPackCount int, abort <-chan struct{}) error {
+func (vscw *VerticalSplitCloneWorker) processData(td *myproto.TableDefinition, tableIndex int, qrr *QueryResultReader, insertChannel chan *insertCommand, chunk *chunkTracker, destinationPackCount int, abort <-chan struct{}) error {
 	// process the data
 	baseCmd := td.Name + "(" + strings.Join(td.Columns, ", ") + ") VALUES "
 	var rows [][]sqltypes.Value
@@ -600,11 +655,7 @@ func (vscw *VerticalSplitCloneWorker) processData(td *myproto.TableDefinition, t
 				// send the remainder if any
 				if packCount > 0 {
 					cmd := baseCmd + makeValueString(qrr.Fields, rows)
-					select {
-					case insertChannel <- cmd:
-					case <-abort:
-						return nil
-					}
+					sendInsert(insertChannel, cmd, chunk, abort)
 				}
 				return nil
 			}
@@ -612,6 +663,7 @@ func (vscw *VerticalSplitCloneWorker) processData(td *myproto.TableDefinition, t
 			// add the rows to our current result
 			rows = append(rows, r.Rows...)
 			vscw.tableStatus[tableIndex].addCopiedRows(len(r.Rows))
+			chunk.addRows(len(r.Rows))
 
 			// see if we reach the destination pack count
 			packCount++
@@ -621,9 +673,7 @@ func (vscw *VerticalSplitCloneWorker) processData(td *myproto.TableDefinition, t
 
 			// send the rows to be inserted
 			cmd := baseCmd + makeValueString(qrr.Fields, rows)
-			select {
-			case insertChannel <- cmd:
-			case <-abort:
+			if aborted := sendInsert(insertChannel, cmd, chunk, abort); aborted {
 				return nil
 			}
 
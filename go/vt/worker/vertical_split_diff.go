@@ -64,6 +64,7 @@ type VerticalSplitDiffWorker struct {
 	// populated during stateVSDDiff
 	sourceSchemaDefinition      *myproto.SchemaDefinition
 	destinationSchemaDefinition *myproto.SchemaDefinition
+	tableReports                tableReports
 }
 
 // NewVerticalSplitDiffWorker returns a new VerticalSplitDiffWorker object.
@@ -176,6 +177,11 @@ func (vsdw *VerticalSplitDiffWorker) Error() error {
 	return vsdw.err
 }
 
+// Report is part of the Reporter interface.
+func (vsdw *VerticalSplitDiffWorker) Report() *Report {
+	return newReport("VerticalSplitDiff", vsdw.keyspace, vsdw.shard, vsdw.err, &vsdw.tableReports)
+}
+
 func (vsdw *VerticalSplitDiffWorker) run() error {
 	// first state: read what we need to do
 	if err := vsdw.init(); err != nil {
@@ -479,6 +485,7 @@ func (vsdw *VerticalSplitDiffWorker) diff() error {
 			sourceQueryResultReader, err := TableScan(vsdw.ctx, vsdw.wr.Logger(), vsdw.wr.TopoServer(), vsdw.sourceAlias, tableDefinition)
 			if err != nil {
 				vsdw.wr.Logger().Errorf("TableScan(source) failed: %v", err)
+				vsdw.tableReports.add(newTableReport(tableDefinition.Name, DiffReport{}, err))
 				return
 			}
 			defer sourceQueryResultReader.Close()
@@ -486,6 +493,7 @@ func (vsdw *VerticalSplitDiffWorker) diff() error {
 			destinationQueryResultReader, err := TableScan(vsdw.ctx, vsdw.wr.Logger(), vsdw.wr.TopoServer(), vsdw.destinationAlias, tableDefinition)
 			if err != nil {
 				vsdw.wr.Logger().Errorf("TableScan(destination) failed: %v", err)
+				vsdw.tableReports.add(newTableReport(tableDefinition.Name, DiffReport{}, err))
 				return
 			}
 			defer destinationQueryResultReader.Close()
@@ -493,10 +501,12 @@ func (vsdw *VerticalSplitDiffWorker) diff() error {
 			differ, err := NewRowDiffer(sourceQueryResultReader, destinationQueryResultReader, tableDefinition)
 			if err != nil {
 				vsdw.wr.Logger().Errorf("NewRowDiffer() failed: %v", err)
+				vsdw.tableReports.add(newTableReport(tableDefinition.Name, DiffReport{}, err))
 				return
 			}
 
 			report, err := differ.Go(vsdw.wr.Logger())
+			vsdw.tableReports.add(newTableReport(tableDefinition.Name, report, err))
 			if err != nil {
 				vsdw.wr.Logger().Errorf("Differ.Go failed: %v", err)
 			} else {
@@ -0,0 +1,90 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wrangler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/mysqlctl"
+	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
+)
+
+// DeleteSnapshot removes the snapshot with the provided manifest from
+// a tablet, if it is still there. A tablet that doesn't exist anymore
+// doesn't hold the snapshot either.
+func (wr *Wrangler) DeleteSnapshot(ctx context.Context, tabletAlias topo.TabletAlias, manifestPath string) error {
+	ti, err := wr.ts.GetTablet(tabletAlias)
+	if err != nil {
+		if err == topo.ErrNoNode {
+			return nil
+		}
+		return err
+	}
+	return wr.tmc.DeleteSnapshot(ctx, ti, &actionnode.DeleteSnapshotArgs{
+		ManifestPath: manifestPath,
+	})
+}
+
+// ListBackups returns the kept backups of a shard, oldest first.
+func (wr *Wrangler) ListBackups(ctx context.Context, keyspace, shard string) ([]*topo.ShardBackup, error) {
+	si, err := topo.GetShard(ctx, wr.ts, keyspace, shard)
+	if err != nil {
+		return nil, err
+	}
+	return si.Backups, nil
+}
+
+// verifyBackupTimeout is how long VerifyBackup waits for a manifest,
+// if the context has no earlier deadline.
+var verifyBackupTimeout = 30 * time.Second
+
+// VerifyBackup checks the tablet of a backup still serves the manifest
+// of its snapshot, and that the manifest is for the tablet database.
+// It fails if the snapshot was replaced by a more recent one.
+func (wr *Wrangler) VerifyBackup(ctx context.Context, backup *topo.ShardBackup) error {
+	if path.Base(backup.ManifestPath) == mysqlctl.SnapshotManifestFile {
+		return fmt.Errorf("manifest %v has no snapshot id, cannot tell if it is still the backup", backup.ManifestPath)
+	}
+	ti, err := wr.ts.GetTablet(backup.TabletAlias)
+	if err != nil {
+		return fmt.Errorf("cannot read tablet %v: %v", backup.TabletAlias, err)
+	}
+	client := &http.Client{Timeout: verifyBackupTimeout}
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := deadline.Sub(time.Now())
+		if timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+		if timeout < client.Timeout {
+			client.Timeout = timeout
+		}
+	}
+	murl := "http://" + ti.Addr() + backup.ManifestPath
+	resp, err := client.Get(murl)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error fetching url %v: %v", murl, resp.Status)
+	}
+	sm := new(mysqlctl.SnapshotManifest)
+	if err := json.NewDecoder(resp.Body).Decode(sm); err != nil {
+		return fmt.Errorf("invalid manifest %v: %v", murl, err)
+	}
+	if sm.DbName != ti.DbName() {
+		return fmt.Errorf("manifest %v is for database %v, not %v", murl, sm.DbName, ti.DbName())
+	}
+	if len(sm.Files) == 0 {
+		return fmt.Errorf("manifest %v has no files", murl)
+	}
+	return nil
+}
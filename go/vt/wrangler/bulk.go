@@ -0,0 +1,262 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wrangler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/youtube/vitess/go/sync2"
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
+)
+
+// This file contains helpers to run the same action on many tablets,
+// with bounded concurrency and a per-tablet timeout. The individual
+// outcome of each tablet is recorded, so callers can report partial
+// failures instead of just the first error.
+
+const (
+	// DefaultBulkConcurrency is the default number of tablets
+	// a bulk action will work on at the same time.
+	DefaultBulkConcurrency = 16
+
+	// DefaultBulkTabletTimeout is the default timeout for
+	// a bulk action on a single tablet.
+	DefaultBulkTabletTimeout = 30 * time.Second
+)
+
+// ErrTabletSkipped can be returned by a TabletAction to indicate
+// the tablet was deliberately not acted upon.
+var ErrTabletSkipped = errors.New("tablet skipped")
+
+// TabletAction is the function run on each tablet by RunOnTablets.
+type TabletAction func(ctx context.Context, ti *topo.TabletInfo) error
+
+// TabletActionState is the outcome of a TabletAction on one tablet.
+type TabletActionState string
+
+const (
+	// TabletActionSuccess means the action returned no error.
+	TabletActionSuccess = TabletActionState("success")
+
+	// TabletActionFailure means the action returned an error.
+	TabletActionFailure = TabletActionState("failure")
+
+	// TabletActionSkip means the action returned ErrTabletSkipped.
+	TabletActionSkip = TabletActionState("skip")
+)
+
+// TabletActionResult is the result of a TabletAction on one tablet.
+type TabletActionResult struct {
+	Alias    topo.TabletAlias
+	State    TabletActionState
+	Error    error
+	Duration time.Duration
+}
+
+// BulkResult aggregates the results of a TabletAction
+// run on multiple tablets.
+type BulkResult struct {
+	// Action is the name of the action, used for reporting.
+	Action string
+
+	// Results are sorted by tablet alias.
+	Results []*TabletActionResult
+}
+
+// Count returns how many tablets ended in the provided state.
+func (br *BulkResult) Count(state TabletActionState) int {
+	result := 0
+	for _, r := range br.Results {
+		if r.State == state {
+			result++
+		}
+	}
+	return result
+}
+
+// Failures returns the results of the tablets that failed.
+func (br *BulkResult) Failures() []*TabletActionResult {
+	var result []*TabletActionResult
+	for _, r := range br.Results {
+		if r.State == TabletActionFailure {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// Error returns nil if no tablet failed, or an error listing all
+// the failed tablets.
+func (br *BulkResult) Error() error {
+	failures := br.Failures()
+	if len(failures) == 0 {
+		return nil
+	}
+	errs := make([]string, len(failures))
+	for i, r := range failures {
+		errs[i] = fmt.Sprintf("%v: %v", r.Alias, r.Error)
+	}
+	return fmt.Errorf("%v failed on %v/%v tablets:\n%v", br.Action, len(failures), len(br.Results), strings.Join(errs, "\n"))
+}
+
+// String returns a human readable summary of the results, one
+// line per tablet.
+func (br *BulkResult) String() string {
+	lines := make([]string, 0, len(br.Results)+1)
+	lines = append(lines, fmt.Sprintf("%v: %v success, %v failure, %v skip", br.Action, br.Count(TabletActionSuccess), br.Count(TabletActionFailure), br.Count(TabletActionSkip)))
+	for _, r := range br.Results {
+		if r.Error != nil && r.State == TabletActionFailure {
+			lines = append(lines, fmt.Sprintf("  %v %v (%v): %v", r.Alias, r.State, r.Duration, r.Error))
+		} else {
+			lines = append(lines, fmt.Sprintf("  %v %v (%v)", r.Alias, r.State, r.Duration))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RunOnTablets runs the provided action on all the tablets, with at most
+// 'concurrency' actions in flight at any given time. Each action
+// is run with a context that expires after tabletTimeout. It returns
+// once all actions are done, with the result of each of them.
+// A concurrency <= 0 means DefaultBulkConcurrency, and a tabletTimeout
+// of 0 means no additional timeout is applied.
+func (wr *Wrangler) RunOnTablets(ctx context.Context, name string, tablets []*topo.TabletInfo, concurrency int, tabletTimeout time.Duration, action TabletAction) *BulkResult {
+	if concurrency <= 0 {
+		concurrency = DefaultBulkConcurrency
+	}
+	sema := sync2.NewSemaphore(concurrency, 0)
+
+	br := &BulkResult{
+		Action:  name,
+		Results: make([]*TabletActionResult, len(tablets)),
+	}
+	wg := sync.WaitGroup{}
+	for i, ti := range tablets {
+		wg.Add(1)
+		go func(i int, ti *topo.TabletInfo) {
+			defer wg.Done()
+			sema.Acquire()
+			defer sema.Release()
+
+			br.Results[i] = runTabletAction(ctx, ti, tabletTimeout, action)
+		}(i, ti)
+	}
+	wg.Wait()
+
+	sort.Sort(tabletActionResultList(br.Results))
+	return br
+}
+
+// runTabletAction runs a single action, and translates its
+// outcome into a TabletActionResult.
+func runTabletAction(ctx context.Context, ti *topo.TabletInfo, tabletTimeout time.Duration, action TabletAction) *TabletActionResult {
+	result := &TabletActionResult{
+		Alias: ti.Alias,
+	}
+	if err := ctx.Err(); err != nil {
+		// the global context is done, no need to try
+		result.State = TabletActionFailure
+		result.Error = err
+		return result
+	}
+
+	if tabletTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, tabletTimeout)
+		defer cancel()
+	}
+	start := time.Now()
+	err := action(ctx, ti)
+	result.Duration = time.Now().Sub(start)
+	switch err {
+	case nil:
+		result.State = TabletActionSuccess
+	case ErrTabletSkipped:
+		result.State = TabletActionSkip
+	default:
+		result.State = TabletActionFailure
+		result.Error = err
+	}
+	return result
+}
+
+// tabletActionResultList is used to sort results by alias.
+type tabletActionResultList []*TabletActionResult
+
+func (l tabletActionResultList) Len() int {
+	return len(l)
+}
+
+func (l tabletActionResultList) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+func (l tabletActionResultList) Less(i, j int) bool {
+	return l[i].Alias.String() < l[j].Alias.String()
+}
+
+// GetTabletsInShard returns all the tablets in the replication graph
+// of a shard. It may return topo.ErrPartialResult, along with the
+// tablets that could be read.
+func (wr *Wrangler) GetTabletsInShard(ctx context.Context, keyspace, shard string) ([]*topo.TabletInfo, error) {
+	tabletMap, err := topo.GetTabletMapForShard(ctx, wr.ts, keyspace, shard)
+	if err != nil && err != topo.ErrPartialResult {
+		return nil, err
+	}
+	return tabletMapToList(tabletMap), err
+}
+
+// GetTabletsInKeyspace returns all the tablets in the replication
+// graph of all the shards of a keyspace. It may return
+// topo.ErrPartialResult, along with the tablets that could be read.
+func (wr *Wrangler) GetTabletsInKeyspace(ctx context.Context, keyspace string) ([]*topo.TabletInfo, error) {
+	shards, err := wr.ts.GetShardNames(keyspace)
+	if err != nil {
+		return nil, err
+	}
+	var result []*topo.TabletInfo
+	var partialErr error
+	for _, shard := range shards {
+		tablets, err := wr.GetTabletsInShard(ctx, keyspace, shard)
+		switch err {
+		case nil:
+			// keep going
+		case topo.ErrPartialResult:
+			partialErr = err
+		default:
+			return nil, err
+		}
+		result = append(result, tablets...)
+	}
+	return result, partialErr
+}
+
+// GetTabletsInCell returns all the tablets in a cell. It may return
+// topo.ErrPartialResult, along with the tablets that could be read.
+func (wr *Wrangler) GetTabletsInCell(ctx context.Context, cell string) ([]*topo.TabletInfo, error) {
+	aliases, err := wr.ts.GetTabletsByCell(cell)
+	if err != nil {
+		return nil, err
+	}
+	tabletMap, err := topo.GetTabletMap(ctx, wr.ts, aliases)
+	if err != nil && err != topo.ErrPartialResult {
+		return nil, err
+	}
+	return tabletMapToList(tabletMap), err
+}
+
+func tabletMapToList(tabletMap map[topo.TabletAlias]*topo.TabletInfo) []*topo.TabletInfo {
+	result := make([]*topo.TabletInfo, 0, len(tabletMap))
+	for _, ti := range tabletMap {
+		result = append(result, ti)
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wrangler
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
+)
+
+func TestRunOnTablets(t *testing.T) {
+	wr := New(logutil.NewConsoleLogger(), nil, nil, time.Second)
+
+	var tablets []*topo.TabletInfo
+	for i := 0; i < 10; i++ {
+		tablets = append(tablets, topo.NewTabletInfo(&topo.Tablet{
+			Alias: topo.TabletAlias{Cell: "cell1", Uid: uint32(100 + i)},
+		}, 0))
+	}
+
+	mu := sync.Mutex{}
+	inFlight := 0
+	maxInFlight := 0
+	br := wr.RunOnTablets(context.Background(), "TestAction", tablets, 3, time.Second, func(ctx context.Context, ti *topo.TabletInfo) error {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		switch ti.Alias.Uid % 3 {
+		case 1:
+			return fmt.Errorf("failed on purpose")
+		case 2:
+			return ErrTabletSkipped
+		}
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	if maxInFlight > 3 {
+		t.Errorf("concurrency not respected: got %v in flight", maxInFlight)
+	}
+	if got := br.Count(TabletActionSuccess); got != 3 {
+		t.Errorf("unexpected success count: %v", got)
+	}
+	if got := br.Count(TabletActionFailure); got != 4 {
+		t.Errorf("unexpected failure count: %v", got)
+	}
+	if got := br.Count(TabletActionSkip); got != 3 {
+		t.Errorf("unexpected skip count: %v", got)
+	}
+	for i, r := range br.Results {
+		if r.Alias.Uid != uint32(100+i) {
+			t.Errorf("results not sorted: got %v at position %v", r.Alias, i)
+		}
+	}
+	if br.Error() == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestRunOnTabletsTimeout(t *testing.T) {
+	wr := New(logutil.NewConsoleLogger(), nil, nil, time.Second)
+	tablets := []*topo.TabletInfo{
+		topo.NewTabletInfo(&topo.Tablet{
+			Alias: topo.TabletAlias{Cell: "cell1", Uid: 100},
+		}, 0),
+	}
+
+	br := wr.RunOnTablets(context.Background(), "TestAction", tablets, 0, 10*time.Millisecond, func(ctx context.Context, ti *topo.TabletInfo) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if br.Count(TabletActionFailure) != 1 || br.Results[0].Error != context.DeadlineExceeded {
+		t.Errorf("unexpected result: %v", br)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wrangler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
+)
+
+// cell related methods for Wrangler
+
+// AddCell creates the topology of a new cell, checks its topology
+// server can be reached, and rebuilds the serving graph of all the
+// keyspaces in that cell. addrs are the addresses of the cell
+// topology servers, for the implementations that need them. If the
+// cell already exists, it is only checked and rebuilt.
+func (wr *Wrangler) AddCell(ctx context.Context, cell string, addrs []string) error {
+	cc, ok := wr.ts.(topo.CellCreator)
+	if !ok {
+		return fmt.Errorf("%T does not support creating cells", wr.ts)
+	}
+	switch err := cc.CreateCell(cell, addrs); err {
+	case nil:
+		wr.Logger().Infof("Created cell %v", cell)
+	case topo.ErrNodeExists:
+		wr.Logger().Infof("Cell %v already exists", cell)
+	default:
+		return fmt.Errorf("CreateCell(%v) failed: %v", cell, err)
+	}
+
+	// check the cell is known and reachable
+	cells, err := wr.ts.GetKnownCells()
+	if err != nil {
+		return err
+	}
+	if !topo.InCellList(cell, cells) {
+		return fmt.Errorf("cell %v is not in the known cells %v after its creation", cell, cells)
+	}
+	if _, err := wr.ts.GetTabletsByCell(cell); err != nil && err != topo.ErrNoNode {
+		return fmt.Errorf("cannot reach the topology server of cell %v: %v", cell, err)
+	}
+
+	// rebuild the serving graph there
+	keyspaces, err := wr.ts.GetKeyspaces()
+	if err != nil {
+		return err
+	}
+	for _, keyspace := range keyspaces {
+		if err := wr.RebuildKeyspaceGraph(ctx, keyspace, []string{cell}); err != nil {
+			return fmt.Errorf("RebuildKeyspaceGraph(%v) failed: %v", keyspace, err)
+		}
+	}
+	return nil
+}
+
+// RemoveCell decommissions a cell: it checks no tablet in the cell is
+// in the serving graph, removes the cell from all the shards (see
+// RemoveShardCell), and deletes the serving graph of the shards in
+// the cell. If force is set, a cell whose topology server cannot be
+// reached is removed anyway. The cell itself is not unregistered
+// from the topology implementation.
+func (wr *Wrangler) RemoveCell(ctx context.Context, cell string, force bool) error {
+	serving, err := wr.servingTablets(cell)
+	if err != nil {
+		if !force {
+			return err
+		}
+		wr.Logger().Warningf("%v, forcing the removal", err)
+	}
+	if len(serving) > 0 {
+		return fmt.Errorf("serving tablets remain in cell %v: %v", cell, strings.Join(serving, ", "))
+	}
+
+	keyspaces, err := wr.ts.GetKeyspaces()
+	if err != nil {
+		return err
+	}
+	for _, keyspace := range keyspaces {
+		shards, err := wr.ts.GetShardNames(keyspace)
+		if err != nil {
+			return err
+		}
+		for _, shard := range shards {
+			si, err := wr.ts.GetShard(keyspace, shard)
+			if err != nil {
+				return err
+			}
+			if !topo.InCellList(cell, si.Cells) {
+				continue
+			}
+			if err := wr.RemoveShardCell(ctx, keyspace, shard, cell, force); err != nil {
+				return fmt.Errorf("RemoveShardCell(%v/%v, %v) failed: %v", keyspace, shard, cell, err)
+			}
+			wr.deleteSrvShard(cell, keyspace, shard)
+		}
+	}
+	return nil
+}
+
+// servingTablets returns the tablets of the cell that are in the
+// serving graph.
+func (wr *Wrangler) servingTablets(cell string) ([]string, error) {
+	tabletAliases, err := wr.ts.GetTabletsByCell(cell)
+	switch err {
+	case nil:
+	case topo.ErrNoNode:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("cannot read the tablets of cell %v: %v", cell, err)
+	}
+
+	var serving []string
+	for _, tabletAlias := range tabletAliases {
+		ti, err := wr.ts.GetTablet(tabletAlias)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read tablet %v: %v", tabletAlias, err)
+		}
+		if ti.IsInServingGraph() {
+			serving = append(serving, fmt.Sprintf("%v (%v)", tabletAlias, ti.Type))
+		}
+	}
+	return serving, nil
+}
+
+// deleteSrvShard deletes the serving graph of a shard in a cell.
+// Errors are only logged, the cell may not be reachable.
+func (wr *Wrangler) deleteSrvShard(cell, keyspace, shard string) {
+	for _, t := range topo.AllTabletTypes {
+		if !topo.IsInServingGraph(t) {
+			continue
+		}
+
+		if err := wr.ts.DeleteEndPoints(cell, keyspace, shard, t); err != nil && err != topo.ErrNoNode {
+			wr.Logger().Warningf("Cannot delete EndPoints in cell %v for %v/%v/%v: %v", cell, keyspace, shard, t, err)
+		}
+	}
+
+	if err := wr.ts.DeleteSrvShard(cell, keyspace, shard); err != nil && err != topo.ErrNoNode {
+		wr.Logger().Warningf("Cannot delete SrvShard in cell %v for %v/%v: %v", cell, keyspace, shard, err)
+	}
+}
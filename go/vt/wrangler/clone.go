@@ -6,9 +6,12 @@ package wrangler
 
 import (
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/youtube/vitess/go/vt/concurrency"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
 	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
 	"github.com/youtube/vitess/go/vt/topo"
 	"golang.org/x/net/context"
@@ -106,7 +109,9 @@ func (wr *Wrangler) UnreserveForRestore(ctx context.Context, dstTabletAlias topo
 }
 
 // Restore actually performs the restore action on a tablet.
-func (wr *Wrangler) Restore(ctx context.Context, srcTabletAlias topo.TabletAlias, srcFilePath string, dstTabletAlias, parentAlias topo.TabletAlias, fetchConcurrency, fetchRetryCount int, wasReserved, dontWaitForSlaveStart bool) error {
+// fanOut is the number of tablets restoring from the same snapshot
+// at the same time, see actionnode.RestoreArgs.
+func (wr *Wrangler) Restore(ctx context.Context, srcTabletAlias topo.TabletAlias, srcFilePath string, dstTabletAlias, parentAlias topo.TabletAlias, fetchConcurrency, fetchRetryCount, fanOut int, wasReserved, dontWaitForSlaveStart bool) error {
 	// read our current tablet, verify its state before sending it
 	// to the tablet itself
 	tablet, err := wr.ts.GetTablet(dstTabletAlias)
@@ -145,6 +150,7 @@ func (wr *Wrangler) Restore(ctx context.Context, srcTabletAlias topo.TabletAlias
 		FetchRetryCount:       fetchRetryCount,
 		WasReserved:           wasReserved,
 		DontWaitForSlaveStart: dontWaitForSlaveStart,
+		FanOut:                fanOut,
 	}
 	logStream, errFunc, err := wr.tmc.Restore(ctx, tablet, args)
 	if err != nil {
@@ -163,6 +169,19 @@ func (wr *Wrangler) Restore(ctx context.Context, srcTabletAlias topo.TabletAlias
 	return nil
 }
 
+// CleanOrphans removes the files left behind on a tablet by failed
+// snapshots and restores, that weren't modified for maxAge.
+func (wr *Wrangler) CleanOrphans(ctx context.Context, tabletAlias topo.TabletAlias, maxAge time.Duration, dryRun bool) (*myproto.OrphanCleanupReport, error) {
+	ti, err := wr.ts.GetTablet(tabletAlias)
+	if err != nil {
+		return nil, err
+	}
+	return wr.tmc.CleanOrphans(ctx, ti, &actionnode.CleanOrphansArgs{
+		MaxAge: maxAge,
+		DryRun: dryRun,
+	})
+}
+
 // UnreserveForRestoreMulti calls UnreserveForRestore on all targets.
 func (wr *Wrangler) UnreserveForRestoreMulti(ctx context.Context, dstTabletAliases []topo.TabletAlias) {
 	for _, dstTabletAlias := range dstTabletAliases {
@@ -204,12 +223,14 @@ func (wr *Wrangler) Clone(ctx context.Context, srcTabletAlias topo.TabletAlias,
 	// try to restore the snapshot
 	// In serverMode, and in the case where we're replicating from
 	// the master, we can't wait for replication, as the master is down.
+	// All the destinations fetch the files at the same time, so
+	// the source sends each file once to all of them.
 	wg := sync.WaitGroup{}
 	rec := concurrency.FirstErrorRecorder{}
 	for _, dstTabletAlias := range dstTabletAliases {
 		wg.Add(1)
 		go func(dstTabletAlias topo.TabletAlias) {
-			e := wr.Restore(ctx, srcTabletAlias, sr.ManifestPath, dstTabletAlias, sr.ParentAlias, fetchConcurrency, fetchRetryCount, true, serverMode && originalType == topo.TYPE_MASTER)
+			e := wr.Restore(ctx, srcTabletAlias, sr.ManifestPath, dstTabletAlias, sr.ParentAlias, fetchConcurrency, fetchRetryCount, len(dstTabletAliases), true, serverMode && originalType == topo.TYPE_MASTER)
 			rec.RecordError(e)
 			wg.Done()
 		}(dstTabletAlias)
@@ -233,3 +254,94 @@ func (wr *Wrangler) Clone(ctx context.Context, srcTabletAlias topo.TabletAlias,
 
 	return err
 }
+
+// snapshotSource is a candidate source tablet for ChooseSnapshotSource.
+type snapshotSource struct {
+	ti       *topo.TabletInfo
+	rank     int
+	sameCell bool
+	lag      uint
+}
+
+// snapshotSourceList sorts the candidates, best one first.
+type snapshotSourceList []*snapshotSource
+
+func (l snapshotSourceList) Len() int {
+	return len(l)
+}
+
+func (l snapshotSourceList) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+func (l snapshotSourceList) Less(i, j int) bool {
+	if l[i].rank != l[j].rank {
+		return l[i].rank < l[j].rank
+	}
+	if l[i].sameCell != l[j].sameCell {
+		return l[i].sameCell
+	}
+	if l[i].lag != l[j].lag {
+		return l[i].lag < l[j].lag
+	}
+	return l[i].ti.Alias.String() < l[j].ti.Alias.String()
+}
+
+// snapshotSourceRanks are the tablet types that can be a snapshot
+// source, the preferred ones first. Tablets that are already
+// taking or serving a snapshot are backup or snapshot_source,
+// so they are never picked.
+var snapshotSourceRanks = map[topo.TabletType]int{
+	topo.TYPE_RDONLY:  0,
+	topo.TYPE_REPLICA: 1,
+}
+
+// ChooseSnapshotSource picks the best tablet of a shard to clone
+// from. It prefers rdonly tablets to replicas, then tablets in the
+// given cell, then the ones with the lowest replication lag.
+// Tablets that don't answer or don't replicate are skipped, and so
+// are the ones that don't have all the given tags (see MatchTags).
+// Snapshot changes the chosen tablet to backup, which takes it out
+// of the serving graph while the snapshot is taken or served.
+func (wr *Wrangler) ChooseSnapshotSource(ctx context.Context, keyspace, shard, cell string, tags map[string]string) (topo.TabletAlias, error) {
+	tablets, err := wr.GetTabletsInShard(ctx, keyspace, shard)
+	if err != nil && err != topo.ErrPartialResult {
+		return topo.TabletAlias{}, err
+	}
+
+	// ask the replication status of the candidates in parallel, so
+	// an unresponsive tablet doesn't hold up the choice
+	var mu sync.Mutex
+	var candidates snapshotSourceList
+	wr.RunOnTablets(ctx, "SlaveStatus", tablets, DefaultBulkConcurrency, DefaultBulkTabletTimeout, func(ctx context.Context, ti *topo.TabletInfo) error {
+		rank, ok := snapshotSourceRanks[ti.Type]
+		if !ok || !topo.MatchTags(ti.Tags, tags) {
+			return ErrTabletSkipped
+		}
+		status, err := wr.tmc.SlaveStatus(ctx, ti)
+		if err != nil {
+			wr.Logger().Warningf("Skipping snapshot source candidate %v: %v", ti.Alias, err)
+			return err
+		}
+		if !status.SlaveRunning() {
+			wr.Logger().Warningf("Skipping snapshot source candidate %v: replication is not running", ti.Alias)
+			return ErrTabletSkipped
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		candidates = append(candidates, &snapshotSource{
+			ti:       ti,
+			rank:     rank,
+			sameCell: ti.Alias.Cell == cell,
+			lag:      status.SecondsBehindMaster,
+		})
+		return nil
+	})
+	if len(candidates) == 0 {
+		return topo.TabletAlias{}, fmt.Errorf("no tablet in %v/%v can be a snapshot source", keyspace, shard)
+	}
+	sort.Sort(candidates)
+	best := candidates[0]
+	wr.Logger().Infof("Chose %v (%v, %v seconds behind master) as snapshot source for %v/%v", best.ti.Alias, best.ti.Type, best.lag, keyspace, shard)
+	return best.ti.Alias, nil
+}
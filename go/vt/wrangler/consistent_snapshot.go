@@ -0,0 +1,277 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wrangler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/youtube/vitess/go/vt/concurrency"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+// ConsistentSnapshotTag is the tag of the tablets holding a
+// consistent snapshot, its value is the name of the snapshot.
+const ConsistentSnapshotTag = "consistent_snapshot"
+
+// ConsistentSnapshotShard is the tablet holding a consistent snapshot
+// for a shard.
+type ConsistentSnapshotShard struct {
+	TabletAlias topo.TabletAlias
+
+	// Position is the replication position the tablet was stopped at.
+	Position myproto.ReplicationPosition
+}
+
+// ConsistentSnapshot is a point in time of all the shards of a
+// keyspace, held by one stopped rdonly tablet per shard.
+type ConsistentSnapshot struct {
+	Keyspace string
+	Name     string
+
+	// Shards maps the shard names to their tablet.
+	Shards map[string]*ConsistentSnapshotShard
+}
+
+// CreateConsistentSnapshot takes a consistent snapshot of a keyspace,
+// for analytics jobs that read all its shards as of the same point:
+// one rdonly tablet per shard is turned into a checker so it leaves
+// the serving graph, tagged with the name of the snapshot, and its
+// replication is stopped. Then the positions of all the masters are
+// read, and the replication of each tablet is restarted until exactly
+// the position of its master.
+//
+// As there is no global order of the transactions across shards, the
+// snapshot is only consistent in that each shard has all the
+// transactions committed before its master position was read, and
+// none after. The positions are read concurrently, so they are close
+// in time. The tablets stay stopped, and can be queried (with
+// ExportTable for instance), until ReleaseConsistentSnapshot is called.
+func (wr *Wrangler) CreateConsistentSnapshot(ctx context.Context, keyspace, name string, waitTime time.Duration) (*ConsistentSnapshot, error) {
+	actionNode := actionnode.CreateConsistentSnapshot(name)
+	lockPath, err := wr.lockKeyspace(ctx, keyspace, actionNode)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := wr.createConsistentSnapshot(ctx, keyspace, name, waitTime)
+	return snapshot, wr.unlockKeyspace(ctx, keyspace, actionNode, lockPath, err)
+}
+
+func (wr *Wrangler) createConsistentSnapshot(ctx context.Context, keyspace, name string, waitTime time.Duration) (snapshot *ConsistentSnapshot, err error) {
+	tablets, err := wr.findConsistentSnapshotTablets(ctx, keyspace, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(tablets) > 0 {
+		return nil, fmt.Errorf("consistent snapshot %v already exists in keyspace %v", name, keyspace)
+	}
+
+	shardNames, err := wr.ts.GetShardNames(keyspace)
+	if err != nil {
+		return nil, err
+	}
+	if len(shardNames) == 0 {
+		return nil, fmt.Errorf("keyspace %v has no shards", keyspace)
+	}
+	masters := make(map[string]*topo.TabletInfo)
+	rdonlys := make(map[string]*topo.TabletInfo)
+	for _, shard := range shardNames {
+		si, err := wr.ts.GetShard(keyspace, shard)
+		if err != nil {
+			return nil, err
+		}
+		if si.MasterAlias.IsZero() {
+			return nil, fmt.Errorf("shard %v/%v has no master", keyspace, shard)
+		}
+		if masters[shard], err = wr.ts.GetTablet(si.MasterAlias); err != nil {
+			return nil, fmt.Errorf("cannot read master tablet %v: %v", si.MasterAlias, err)
+		}
+		tablets, _, err := topo.FindTablets(ctx, wr.ts, &topo.TabletFilter{
+			Keyspace: keyspace,
+			Shard:    shard,
+			Types:    []topo.TabletType{topo.TYPE_RDONLY},
+		}, "", 0)
+		if err != nil && err != topo.ErrPartialResult {
+			return nil, err
+		}
+		if len(tablets) == 0 {
+			return nil, fmt.Errorf("shard %v/%v has no rdonly tablet", keyspace, shard)
+		}
+		rdonlys[shard] = tablets[0]
+	}
+
+	// If anything goes wrong, the tablets go back to serving.
+	cleaner := &Cleaner{}
+	defer func() {
+		if err != nil {
+			if cerr := cleaner.CleanUp(wr); cerr != nil {
+				wr.Logger().Errorf("cannot release the tablets of consistent snapshot %v: %v", name, cerr)
+			}
+		}
+	}()
+	for shard, ti := range rdonlys {
+		// The tag is only set once the tablet left the serving
+		// graph, and it is removed before it goes back to rdonly,
+		// so it's never published there.
+		wr.Logger().Infof("Changing tablet %v of shard %v to 'checker' for consistent snapshot %v", ti.Alias, shard, name)
+		if err := wr.ChangeType(ctx, ti.Alias, topo.TYPE_CHECKER, false /*force*/); err != nil {
+			return nil, err
+		}
+		RecordChangeSlaveTypeAction(cleaner, ti.Alias, topo.TYPE_RDONLY)
+		if err := wr.SetTabletTags(ctx, ti.Alias, map[string]string{ConsistentSnapshotTag: name}); err != nil {
+			return nil, err
+		}
+		RecordTabletTagAction(cleaner, ti.Alias, ConsistentSnapshotTag, "")
+	}
+
+	// Stop the tablets before reading the positions of their
+	// masters, so they are not past them.
+	for shard, ti := range rdonlys {
+		wr.Logger().Infof("Stopping the replication of tablet %v of shard %v", ti.Alias, shard)
+		if err := wr.tmc.StopSlave(ctx, ti); err != nil {
+			return nil, fmt.Errorf("StopSlave(%v) failed: %v", ti.Alias, err)
+		}
+		RecordStartSlaveAction(cleaner, ti)
+	}
+
+	// Read the positions of all the masters, then replicate until
+	// exactly them.
+	mu := sync.Mutex{}
+	positions := make(map[string]myproto.ReplicationPosition)
+	wg := sync.WaitGroup{}
+	rec := concurrency.AllErrorRecorder{}
+	for shard, ti := range masters {
+		wg.Add(1)
+		go func(shard string, ti *topo.TabletInfo) {
+			defer wg.Done()
+			pos, err := wr.tmc.MasterPosition(ctx, ti)
+			if err != nil {
+				rec.RecordError(fmt.Errorf("MasterPosition(%v) failed: %v", ti.Alias, err))
+				return
+			}
+			mu.Lock()
+			positions[shard] = pos
+			mu.Unlock()
+		}(shard, ti)
+	}
+	wg.Wait()
+	if rec.HasErrors() {
+		return nil, rec.Error()
+	}
+
+	snapshot = &ConsistentSnapshot{
+		Keyspace: keyspace,
+		Name:     name,
+		Shards:   make(map[string]*ConsistentSnapshotShard),
+	}
+	for shard, ti := range rdonlys {
+		wg.Add(1)
+		go func(shard string, ti *topo.TabletInfo) {
+			defer wg.Done()
+			wr.Logger().Infof("Replicating tablet %v of shard %v until %v", ti.Alias, shard, positions[shard])
+			if err := wr.tmc.StartSlaveUntilAfter(ctx, ti, positions[shard], waitTime); err != nil {
+				rec.RecordError(fmt.Errorf("StartSlaveUntilAfter(%v) failed: %v", ti.Alias, err))
+				return
+			}
+			mu.Lock()
+			snapshot.Shards[shard] = &ConsistentSnapshotShard{
+				TabletAlias: ti.Alias,
+				Position:    positions[shard],
+			}
+			mu.Unlock()
+		}(shard, ti)
+	}
+	wg.Wait()
+	if rec.HasErrors() {
+		return nil, rec.Error()
+	}
+	return snapshot, nil
+}
+
+// GetConsistentSnapshot returns the tablets of a consistent snapshot,
+// and the positions they are stopped at.
+func (wr *Wrangler) GetConsistentSnapshot(ctx context.Context, keyspace, name string) (*ConsistentSnapshot, error) {
+	tablets, err := wr.findConsistentSnapshotTablets(ctx, keyspace, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(tablets) == 0 {
+		return nil, fmt.Errorf("no consistent snapshot %v in keyspace %v", name, keyspace)
+	}
+
+	snapshot := &ConsistentSnapshot{
+		Keyspace: keyspace,
+		Name:     name,
+		Shards:   make(map[string]*ConsistentSnapshotShard),
+	}
+	for _, ti := range tablets {
+		status, err := wr.tmc.SlaveStatus(ctx, ti)
+		if err != nil {
+			return nil, fmt.Errorf("SlaveStatus(%v) failed: %v", ti.Alias, err)
+		}
+		snapshot.Shards[ti.Shard] = &ConsistentSnapshotShard{
+			TabletAlias: ti.Alias,
+			Position:    status.Position,
+		}
+	}
+	return snapshot, nil
+}
+
+// ReleaseConsistentSnapshot lets the tablets of a consistent snapshot
+// go back to serving: their replication is restarted, their tag is
+// removed, and they are changed back to rdonly.
+func (wr *Wrangler) ReleaseConsistentSnapshot(ctx context.Context, keyspace, name string) error {
+	actionNode := actionnode.ReleaseConsistentSnapshot(name)
+	lockPath, err := wr.lockKeyspace(ctx, keyspace, actionNode)
+	if err != nil {
+		return err
+	}
+
+	err = wr.releaseConsistentSnapshot(ctx, keyspace, name)
+	return wr.unlockKeyspace(ctx, keyspace, actionNode, lockPath, err)
+}
+
+func (wr *Wrangler) releaseConsistentSnapshot(ctx context.Context, keyspace, name string) error {
+	tablets, err := wr.findConsistentSnapshotTablets(ctx, keyspace, name)
+	if err != nil {
+		return err
+	}
+	if len(tablets) == 0 {
+		return fmt.Errorf("no consistent snapshot %v in keyspace %v", name, keyspace)
+	}
+
+	cleaner := &Cleaner{}
+	for _, ti := range tablets {
+		if ti.Type == topo.TYPE_CHECKER {
+			RecordChangeSlaveTypeAction(cleaner, ti.Alias, topo.TYPE_RDONLY)
+		}
+		RecordTabletTagAction(cleaner, ti.Alias, ConsistentSnapshotTag, "")
+		RecordStartSlaveAction(cleaner, ti)
+	}
+	return cleaner.CleanUp(wr)
+}
+
+// findConsistentSnapshotTablets returns the tablets of the keyspace
+// that have the tag of the consistent snapshot.
+func (wr *Wrangler) findConsistentSnapshotTablets(ctx context.Context, keyspace, name string) ([]*topo.TabletInfo, error) {
+	tablets, _, err := topo.FindTablets(ctx, wr.ts, &topo.TabletFilter{
+		Keyspace: keyspace,
+		Tags:     map[string]string{ConsistentSnapshotTag: name},
+	}, "", 0)
+	switch err {
+	case nil:
+	case topo.ErrPartialResult:
+		wr.Logger().Warningf("some tablets of keyspace %v couldn't be read, consistent snapshot %v may have more tablets", keyspace, name)
+	default:
+		return nil, fmt.Errorf("cannot find the tablets of consistent snapshot %v: %v", name, err)
+	}
+	return tablets, nil
+}
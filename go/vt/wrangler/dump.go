@@ -0,0 +1,447 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wrangler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/key"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
+	"github.com/youtube/vitess/go/vt/tabletserver/tabletconn"
+	"github.com/youtube/vitess/go/vt/topo"
+)
+
+// The formats of the table dumps.
+const (
+	// DumpFormatCSV dumps a table as CSV: a first line with the
+	// column names, then a line per row. The values are escaped
+	// like the ones of LOAD DATA: backslash, carriage return and
+	// newline are written as \\, \r and \n, and NULL as \N. So a
+	// field never spans lines, and a "\N" string is told from NULL.
+	DumpFormatCSV = "csv"
+
+	// DumpFormatSQL dumps a table as an INSERT statement per row.
+	DumpFormatSQL = "sql"
+)
+
+// csvNull is the value of the NULL fields in a CSV dump.
+const csvNull = `\N`
+
+// csvEscape escapes a value of a CSV dump.
+func csvEscape(value []byte) string {
+	buf := bytes.Buffer{}
+	for _, b := range value {
+		switch b {
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\n':
+			buf.WriteString(`\n`)
+		default:
+			buf.WriteByte(b)
+		}
+	}
+	return buf.String()
+}
+
+// csvUnescape reverses csvEscape. It returns nil for csvNull.
+func csvUnescape(field string) ([]byte, error) {
+	if field == csvNull {
+		return nil, nil
+	}
+	value := make([]byte, 0, len(field))
+	for i := 0; i < len(field); i++ {
+		if field[i] != '\\' {
+			value = append(value, field[i])
+			continue
+		}
+		i++
+		if i == len(field) {
+			return nil, fmt.Errorf("unterminated escape in %q", field)
+		}
+		switch field[i] {
+		case '\\':
+			value = append(value, '\\')
+		case 'r':
+			value = append(value, '\r')
+		case 'n':
+			value = append(value, '\n')
+		default:
+			return nil, fmt.Errorf("invalid escape \\%c in %q", field[i], field)
+		}
+	}
+	return value, nil
+}
+
+// DumpTable dumps the rows of a table of a shard, or only the ones in
+// a keyrange, from a rdonly tablet of the shard, or from the tablet
+// of the shard in a consistent snapshot if snapshot is set. The rows
+// are read with a single query, so the dump is consistent, and it is
+// at least at the returned replication position. It returns the
+// position and the number of dumped rows.
+func (wr *Wrangler) DumpTable(ctx context.Context, keyspace, shard, table, snapshot string, kr key.KeyRange, format string, w io.Writer) (myproto.ReplicationPosition, int, error) {
+	var position myproto.ReplicationPosition
+	dw, err := newDumpWriter(w, format, table)
+	if err != nil {
+		return position, 0, err
+	}
+	ti, err := wr.findDumpTablet(ctx, keyspace, shard, snapshot)
+	if err != nil {
+		return position, 0, err
+	}
+	req := &tproto.ExportTableRequest{
+		Table:    table,
+		KeyRange: kr,
+	}
+	if kr.IsPartial() {
+		ki, err := wr.ts.GetKeyspace(keyspace)
+		if err != nil {
+			return position, 0, err
+		}
+		if ki.ShardingColumnName == "" {
+			return position, 0, fmt.Errorf("keyspace %v has no sharding column, cannot dump a keyrange", keyspace)
+		}
+		req.KeyspaceIdColumn = ki.ShardingColumnName
+		req.KeyspaceIdType = ki.ShardingColumnType
+	}
+
+	endPoint, err := ti.EndPoint()
+	if err != nil {
+		return position, 0, err
+	}
+	conn, err := tabletconn.GetDialer()(ctx, *endPoint, ti.Keyspace, ti.Shard, 30*time.Second)
+	if err != nil {
+		return position, 0, err
+	}
+	defer conn.Close()
+	wr.Logger().Infof("Dumping table %v from tablet %v", table, ti.Alias)
+	chunks, errFunc, err := conn.ExportTable(ctx, req)
+	if err != nil {
+		return position, 0, err
+	}
+
+	rowCount := 0
+	for chunk := range chunks {
+		if chunk.Header != nil {
+			position = chunk.Header.Position
+			err = dw.writeHeader(chunk.Header)
+		} else {
+			var rows [][]sqltypes.Value
+			if rows, err = tproto.DecodeExportColumns(chunk); err == nil {
+				err = dw.writeRows(rows)
+				rowCount += len(rows)
+			}
+		}
+		if err != nil {
+			// Drain the stream, so the connection can be closed.
+			for _ = range chunks {
+			}
+			return position, rowCount, err
+		}
+	}
+	if err := errFunc(); err != nil {
+		return position, rowCount, err
+	}
+	return position, rowCount, dw.flush()
+}
+
+// findDumpTablet returns the tablet a shard is dumped from.
+func (wr *Wrangler) findDumpTablet(ctx context.Context, keyspace, shard, snapshot string) (*topo.TabletInfo, error) {
+	filter := &topo.TabletFilter{
+		Keyspace: keyspace,
+		Shard:    shard,
+		Types:    []topo.TabletType{topo.TYPE_RDONLY},
+	}
+	if snapshot != "" {
+		filter.Types = []topo.TabletType{topo.TYPE_CHECKER}
+		filter.Tags = map[string]string{ConsistentSnapshotTag: snapshot}
+	}
+	tablets, _, err := topo.FindTablets(ctx, wr.ts, filter, "", 0)
+	if err != nil && err != topo.ErrPartialResult {
+		return nil, err
+	}
+	if len(tablets) == 0 {
+		if snapshot != "" {
+			return nil, fmt.Errorf("shard %v/%v has no tablet in consistent snapshot %v", keyspace, shard, snapshot)
+		}
+		return nil, fmt.Errorf("shard %v/%v has no rdonly tablet", keyspace, shard)
+	}
+	return tablets[0], nil
+}
+
+// dumpWriter writes the rows of a table dump.
+type dumpWriter struct {
+	format string
+	table  string
+	w      *bufio.Writer
+	csv    *csv.Writer
+
+	fields []mproto.Field
+	// insert is the beginning of the INSERT statements of a SQL dump.
+	insert string
+}
+
+func newDumpWriter(w io.Writer, format, table string) (*dumpWriter, error) {
+	dw := &dumpWriter{
+		format: format,
+		table:  table,
+		w:      bufio.NewWriter(w),
+	}
+	switch format {
+	case DumpFormatCSV:
+		dw.csv = csv.NewWriter(dw.w)
+	case DumpFormatSQL:
+	default:
+		return nil, fmt.Errorf("unknown dump format %q, want %v or %v", format, DumpFormatCSV, DumpFormatSQL)
+	}
+	return dw, nil
+}
+
+func (dw *dumpWriter) writeHeader(header *tproto.ExportHeader) error {
+	dw.fields = header.Fields
+	names := make([]string, len(dw.fields))
+	for i, field := range dw.fields {
+		names[i] = field.Name
+	}
+	if dw.format == DumpFormatCSV {
+		return dw.csv.Write(names)
+	}
+	if _, err := fmt.Fprintf(dw.w, "-- position: %v\n", header.Position); err != nil {
+		return err
+	}
+	dw.insert = fmt.Sprintf("INSERT INTO `%v` (`%v`) VALUES ", dw.table, strings.Join(names, "`,`"))
+	return nil
+}
+
+func (dw *dumpWriter) writeRows(rows [][]sqltypes.Value) error {
+	if dw.format == DumpFormatCSV {
+		record := make([]string, len(dw.fields))
+		for _, row := range rows {
+			for i, value := range row {
+				if value.IsNull() {
+					record[i] = csvNull
+				} else {
+					record[i] = csvEscape(value.Raw())
+				}
+			}
+			if err := dw.csv.Write(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	buf := bytes.Buffer{}
+	for _, row := range rows {
+		buf.WriteString(dw.insert)
+		buf.WriteByte('(')
+		for i, value := range row {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			// the values are all strings, convert the numbers back
+			if !value.IsNull() {
+				switch dw.fields[i].Type {
+				case mproto.VT_TINY, mproto.VT_SHORT, mproto.VT_LONG, mproto.VT_LONGLONG, mproto.VT_INT24:
+					value = sqltypes.MakeNumeric(value.Raw())
+				case mproto.VT_FLOAT, mproto.VT_DOUBLE:
+					value = sqltypes.MakeFractional(value.Raw())
+				}
+			}
+			value.EncodeSql(&buf)
+		}
+		buf.WriteString(");\n")
+	}
+	_, err := dw.w.Write(buf.Bytes())
+	return err
+}
+
+func (dw *dumpWriter) flush() error {
+	if dw.csv != nil {
+		dw.csv.Flush()
+		if err := dw.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return dw.w.Flush()
+}
+
+// LoadTable loads a dump written by DumpTable into a table of a shard,
+// through the query service of its master, so the inserts follow its
+// query rules and invalidate its rowcache. The rows are inserted
+// batchSize rows at a time, a transaction per batch, and at most
+// maxRowsPerSecond rows per second if it is not 0. It returns the
+// number of loaded rows.
+func (wr *Wrangler) LoadTable(ctx context.Context, keyspace, shard, table, format string, r io.Reader, batchSize, maxRowsPerSecond int) (int, error) {
+	if batchSize < 1 {
+		return 0, fmt.Errorf("batch size must be at least 1")
+	}
+	si, err := wr.ts.GetShard(keyspace, shard)
+	if err != nil {
+		return 0, err
+	}
+	if si.MasterAlias.IsZero() {
+		return 0, fmt.Errorf("shard %v/%v has no master", keyspace, shard)
+	}
+	master, err := wr.ts.GetTablet(si.MasterAlias)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read master tablet %v: %v", si.MasterAlias, err)
+	}
+
+	endPoint, err := master.EndPoint()
+	if err != nil {
+		return 0, err
+	}
+	conn, err := tabletconn.GetDialer()(ctx, *endPoint, master.Keyspace, master.Shard, 30*time.Second)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	rowCount := 0
+	err = readDump(r, format, batchSize, func(columns string, values []string) error {
+		query := fmt.Sprintf("INSERT INTO `%v` (%v) VALUES %v", table, columns, strings.Join(values, ","))
+		if err := loadBatch(ctx, conn, query); err != nil {
+			return fmt.Errorf("cannot insert rows %v to %v: %v", rowCount, rowCount+len(values), err)
+		}
+		rowCount += len(values)
+		if maxRowsPerSecond > 0 {
+			next := start.Add(time.Duration(rowCount) * time.Second / time.Duration(maxRowsPerSecond))
+			if d := next.Sub(time.Now()); d > 0 {
+				t := time.NewTimer(d)
+				defer t.Stop()
+				select {
+				case <-t.C:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		return nil
+	})
+	return rowCount, err
+}
+
+// loadBatch runs the insert of a batch of rows in a transaction.
+func loadBatch(ctx context.Context, conn tabletconn.TabletConn, query string) error {
+	transactionID, err := conn.Begin(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Execute(ctx, query, nil, transactionID, nil); err != nil {
+		conn.Rollback(ctx, transactionID)
+		return err
+	}
+	return conn.Commit(ctx, transactionID)
+}
+
+// readDump reads a dump, and calls insert with the column list of
+// the rows, and their values as SQL tuples, batchSize rows at a time.
+func readDump(r io.Reader, format string, batchSize int, insert func(columns string, values []string) error) error {
+	var columns string
+	batch := make([]string, 0, batchSize)
+	add := func(values string) error {
+		batch = append(batch, values)
+		if len(batch) < batchSize {
+			return nil
+		}
+		err := insert(columns, batch)
+		batch = batch[:0]
+		return err
+	}
+
+	switch format {
+	case DumpFormatCSV:
+		cr := csv.NewReader(r)
+		names, err := cr.Read()
+		if err != nil {
+			return fmt.Errorf("cannot read the column names: %v", err)
+		}
+		columns = "`" + strings.Join(names, "`,`") + "`"
+		for line := 2; ; line++ {
+			record, err := cr.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			buf := bytes.Buffer{}
+			buf.WriteByte('(')
+			for i, field := range record {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				value, err := csvUnescape(field)
+				if err != nil {
+					return fmt.Errorf("line %v: %v", line, err)
+				}
+				if value == nil {
+					buf.WriteString("null")
+				} else {
+					sqltypes.MakeString(value).EncodeSql(&buf)
+				}
+			}
+			buf.WriteByte(')')
+			if err := add(buf.String()); err != nil {
+				return err
+			}
+		}
+	case DumpFormatSQL:
+		br := bufio.NewReader(r)
+		for line := 1; ; line++ {
+			statement, err := br.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return err
+			}
+			if statement == "" && err == io.EOF {
+				break
+			}
+			statement = strings.TrimSpace(statement)
+			if statement == "" || strings.HasPrefix(statement, "--") {
+				continue
+			}
+			// Each statement is "INSERT INTO `table` (columns)
+			// VALUES (values);", as written by DumpTable.
+			i := strings.Index(statement, " VALUES ")
+			j := strings.Index(statement, " (")
+			if !strings.HasPrefix(statement, "INSERT INTO `") || j == -1 || i < j || !strings.HasSuffix(statement, ";") {
+				return fmt.Errorf("line %v is not an INSERT statement of a dump", line)
+			}
+			c := strings.TrimSuffix(statement[j+len(" ("):i], ")")
+			if len(batch) > 0 && c != columns {
+				// flush the rows of the previous columns
+				if err := insert(columns, batch); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+			columns = c
+			if err := add(strings.TrimSuffix(statement[i+len(" VALUES "):], ";")); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown dump format %q, want %v or %v", format, DumpFormatCSV, DumpFormatSQL)
+	}
+
+	if len(batch) > 0 {
+		return insert(columns, batch)
+	}
+	return nil
+}
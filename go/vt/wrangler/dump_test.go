@@ -0,0 +1,147 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wrangler
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	tproto "github.com/youtube/vitess/go/vt/tabletserver/proto"
+)
+
+func TestDumpAndReadDump(t *testing.T) {
+	header := &tproto.ExportHeader{
+		Fields: []mproto.Field{
+			{Name: "id", Type: mproto.VT_LONGLONG},
+			{Name: "msg", Type: mproto.VT_VAR_STRING},
+		},
+		Position: myproto.MustParseReplicationPosition("MariaDB", "0-1-5"),
+	}
+	rows := [][]sqltypes.Value{
+		{sqltypes.MakeString([]byte("1")), sqltypes.MakeString([]byte("it's, here"))},
+		{sqltypes.MakeString([]byte("2")), sqltypes.Value{}},
+		{sqltypes.MakeString([]byte("3")), sqltypes.MakeString([]byte("last"))},
+	}
+
+	table := []struct {
+		format string
+		dump   string
+		values []string
+	}{
+		{
+			DumpFormatCSV,
+			"id,msg\n1,\"it's, here\"\n2,\\N\n3,last\n",
+			[]string{"('1','it\\'s, here')", "('2',null)", "('3','last')"},
+		},
+		{
+			DumpFormatSQL,
+			"-- position: 0-1-5\n" +
+				"INSERT INTO `t` (`id`,`msg`) VALUES (1,'it\\'s, here');\n" +
+				"INSERT INTO `t` (`id`,`msg`) VALUES (2,null);\n" +
+				"INSERT INTO `t` (`id`,`msg`) VALUES (3,'last');\n",
+			[]string{"(1,'it\\'s, here')", "(2,null)", "(3,'last')"},
+		},
+	}
+	for _, test := range table {
+		buf := &bytes.Buffer{}
+		dw, err := newDumpWriter(buf, test.format, "t")
+		if err != nil {
+			t.Fatalf("newDumpWriter(%v) failed: %v", test.format, err)
+		}
+		if err := dw.writeHeader(header); err != nil {
+			t.Fatalf("writeHeader failed: %v", err)
+		}
+		if err := dw.writeRows(rows); err != nil {
+			t.Fatalf("writeRows failed: %v", err)
+		}
+		if err := dw.flush(); err != nil {
+			t.Fatalf("flush failed: %v", err)
+		}
+		if got := buf.String(); got != test.dump {
+			t.Errorf("%v dump is:\n%v\nwant:\n%v", test.format, got, test.dump)
+		}
+
+		var batches [][]string
+		if err := readDump(strings.NewReader(test.dump), test.format, 2, func(columns string, values []string) error {
+			if columns != "`id`,`msg`" {
+				t.Errorf("%v dump columns are %v", test.format, columns)
+			}
+			batches = append(batches, append([]string(nil), values...))
+			return nil
+		}); err != nil {
+			t.Fatalf("readDump(%v) failed: %v", test.format, err)
+		}
+		want := [][]string{test.values[:2], test.values[2:]}
+		if !reflect.DeepEqual(batches, want) {
+			t.Errorf("readDump(%v) = %v, want %v", test.format, batches, want)
+		}
+	}
+
+	if _, err := newDumpWriter(&bytes.Buffer{}, "xml", "t"); err == nil {
+		t.Errorf("newDumpWriter(xml) worked")
+	}
+	if err := readDump(strings.NewReader("DELETE FROM `t`;\n"), DumpFormatSQL, 2, func(string, []string) error { return nil }); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("readDump of a DELETE returned %v", err)
+	}
+}
+
+func TestDumpCSVEscaping(t *testing.T) {
+	header := &tproto.ExportHeader{
+		Fields: []mproto.Field{
+			{Name: "v", Type: mproto.VT_BLOB},
+		},
+	}
+	values := []sqltypes.Value{
+		sqltypes.MakeString([]byte(`\N`)),
+		sqltypes.Value{},
+		sqltypes.MakeString([]byte("a\r\nb\rc")),
+		sqltypes.MakeString([]byte(`back\slash`)),
+	}
+	var rows [][]sqltypes.Value
+	for _, v := range values {
+		rows = append(rows, []sqltypes.Value{v})
+	}
+
+	buf := &bytes.Buffer{}
+	dw, err := newDumpWriter(buf, DumpFormatCSV, "t")
+	if err != nil {
+		t.Fatalf("newDumpWriter failed: %v", err)
+	}
+	if err := dw.writeHeader(header); err != nil {
+		t.Fatalf("writeHeader failed: %v", err)
+	}
+	if err := dw.writeRows(rows); err != nil {
+		t.Fatalf("writeRows failed: %v", err)
+	}
+	if err := dw.flush(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	want := "v\n\\\\N\n\\N\na\\r\\nb\\rc\nback\\\\slash\n"
+	if got := buf.String(); got != want {
+		t.Errorf("dump is %q, want %q", got, want)
+	}
+
+	// the values load back as they were, the "\N" string isn't NULL
+	var got []string
+	if err := readDump(strings.NewReader(buf.String()), DumpFormatCSV, 10, func(columns string, values []string) error {
+		got = append(got, values...)
+		return nil
+	}); err != nil {
+		t.Fatalf("readDump failed: %v", err)
+	}
+	wantValues := []string{`('\\N')`, "(null)", `('a\r\nb\rc')`, `('back\\slash')`}
+	if !reflect.DeepEqual(got, wantValues) {
+		t.Errorf("readDump = %v, want %v", got, wantValues)
+	}
+
+	if err := readDump(strings.NewReader("v\nbad\\x\n"), DumpFormatCSV, 10, func(string, []string) error { return nil }); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("readDump of an invalid escape returned %v", err)
+	}
+}
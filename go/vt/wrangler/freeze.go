@@ -0,0 +1,173 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wrangler
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/topotools"
+)
+
+// FreezeShard makes a shard read-only, before a risky maintenance or
+// an external repair of its data: the shard record and its serving
+// graph are marked read-only, all its tablets refuse writes, and the
+// MySQL of its master is made read-only.
+func (wr *Wrangler) FreezeShard(ctx context.Context, keyspace, shard string) error {
+	actionNode := actionnode.FreezeShard()
+	lockPath, err := wr.lockShard(ctx, keyspace, shard, actionNode)
+	if err != nil {
+		return err
+	}
+
+	err = wr.freezeShard(ctx, keyspace, shard)
+	return wr.unlockShard(ctx, keyspace, shard, actionNode, lockPath, err)
+}
+
+func (wr *Wrangler) freezeShard(ctx context.Context, keyspace, shard string) error {
+	si, err := wr.ts.GetShard(keyspace, shard)
+	if err != nil {
+		return err
+	}
+	if si.MasterAlias.IsZero() {
+		return fmt.Errorf("shard %v/%v has no master", keyspace, shard)
+	}
+	master, err := wr.ts.GetTablet(si.MasterAlias)
+	if err != nil {
+		return fmt.Errorf("cannot read master tablet %v: %v", si.MasterAlias, err)
+	}
+
+	if !si.ReadOnly {
+		si.ReadOnly = true
+		if err := topo.UpdateShard(ctx, wr.ts, si); err != nil {
+			return err
+		}
+	}
+	if err := wr.refreshShardTablets(ctx, si); err != nil {
+		return err
+	}
+
+	// The tablet servers refuse writes now, so the ones still
+	// going to MySQL were sent before.
+	wr.Logger().Infof("Making the MySQL of master %v read-only", master.Alias)
+	if err := wr.tmc.SetReadOnly(ctx, master); err != nil {
+		return fmt.Errorf("SetReadOnly(%v) failed: %v", master.Alias, err)
+	}
+	return nil
+}
+
+// UnfreezeShard makes a frozen shard accept writes again. It first
+// verifies that the shard is still in the state it was frozen in:
+// its master is a master tablet that doesn't replicate, and the other
+// reachable tablets of the shard replicate from it. It then makes
+// the MySQL of the master read-write, and unmarks the shard.
+func (wr *Wrangler) UnfreezeShard(ctx context.Context, keyspace, shard string) error {
+	actionNode := actionnode.UnfreezeShard()
+	lockPath, err := wr.lockShard(ctx, keyspace, shard, actionNode)
+	if err != nil {
+		return err
+	}
+
+	err = wr.unfreezeShard(ctx, keyspace, shard)
+	return wr.unlockShard(ctx, keyspace, shard, actionNode, lockPath, err)
+}
+
+func (wr *Wrangler) unfreezeShard(ctx context.Context, keyspace, shard string) error {
+	si, err := wr.ts.GetShard(keyspace, shard)
+	if err != nil {
+		return err
+	}
+	if !si.ReadOnly {
+		return fmt.Errorf("shard %v/%v is not frozen", keyspace, shard)
+	}
+	master, err := wr.verifyFrozenShard(ctx, si)
+	if err != nil {
+		return fmt.Errorf("cannot unfreeze shard %v/%v: %v", keyspace, shard, err)
+	}
+
+	wr.Logger().Infof("Making the MySQL of master %v read-write", master.Alias)
+	if err := wr.tmc.SetReadWrite(ctx, master); err != nil {
+		return fmt.Errorf("SetReadWrite(%v) failed: %v", master.Alias, err)
+	}
+	si.ReadOnly = false
+	if err := topo.UpdateShard(ctx, wr.ts, si); err != nil {
+		return err
+	}
+	return wr.refreshShardTablets(ctx, si)
+}
+
+// verifyFrozenShard checks the master of a frozen shard is still the
+// master of its MySQL replication, and returns it.
+func (wr *Wrangler) verifyFrozenShard(ctx context.Context, si *topo.ShardInfo) (*topo.TabletInfo, error) {
+	if si.MasterAlias.IsZero() {
+		return nil, fmt.Errorf("the shard has no master")
+	}
+	tabletMap, err := topo.GetTabletMapForShard(ctx, wr.ts, si.Keyspace(), si.ShardName())
+	if err != nil {
+		return nil, err
+	}
+	master, ok := tabletMap[si.MasterAlias]
+	if !ok {
+		return nil, fmt.Errorf("master %v doesn't exist", si.MasterAlias)
+	}
+	if master.Type != topo.TYPE_MASTER {
+		return nil, fmt.Errorf("master %v has type %v", master.Alias, master.Type)
+	}
+	if status, err := wr.tmc.SlaveStatus(ctx, master); err == nil {
+		return nil, fmt.Errorf("master %v replicates from %v", master.Alias, status.MasterAddr())
+	}
+
+	masterMysqlAddrs := map[string]bool{
+		normalizeAddr(master.MysqlAddr()):   true,
+		normalizeAddr(master.MysqlIPAddr()): true,
+	}
+	var slaves []*topo.TabletInfo
+	for _, ti := range tabletMap {
+		if ti.Alias != master.Alias && ti.IsSlaveType() {
+			slaves = append(slaves, ti)
+		}
+	}
+	br := wr.RunOnTablets(ctx, "SlaveStatus", slaves, DefaultBulkConcurrency, DefaultBulkTabletTimeout, func(ctx context.Context, ti *topo.TabletInfo) error {
+		status, err := wr.tmc.SlaveStatus(ctx, ti)
+		if err != nil {
+			wr.Logger().Warningf("cannot get the slave status of %v, not verifying it: %v", ti.Alias, err)
+			return ErrTabletSkipped
+		}
+		if addr := status.MasterAddr(); !masterMysqlAddrs[normalizeAddr(addr)] {
+			return fmt.Errorf("replicates from %v, not from master %v", addr, master.Alias)
+		}
+		return nil
+	})
+	if err := br.Error(); err != nil {
+		return nil, err
+	}
+	return master, nil
+}
+
+// refreshShardTablets rebuilds the serving graph of a shard, and
+// makes all its tablets reload their state from it.
+func (wr *Wrangler) refreshShardTablets(ctx context.Context, si *topo.ShardInfo) error {
+	if _, err := topotools.RebuildShard(ctx, wr.logger, wr.ts, si.Keyspace(), si.ShardName(), nil, wr.lockTimeout); err != nil {
+		return err
+	}
+	tabletMap, err := topo.GetTabletMapForShard(ctx, wr.ts, si.Keyspace(), si.ShardName())
+	switch err {
+	case nil:
+	case topo.ErrPartialResult:
+		wr.Logger().Warningf("got partial result for shard %v/%v, may not refresh all tablets everywhere", si.Keyspace(), si.ShardName())
+	default:
+		return err
+	}
+	br := wr.RunOnTablets(ctx, "RefreshState", tabletMapToList(tabletMap), DefaultBulkConcurrency, DefaultBulkTabletTimeout, func(ctx context.Context, ti *topo.TabletInfo) error {
+		return wr.tmc.RefreshState(ctx, ti)
+	})
+	for _, r := range br.Failures() {
+		wr.Logger().Warningf("failed to refresh %v: %v", r.Alias, r.Error)
+	}
+	return nil
+}
@@ -75,6 +75,61 @@ func (wr *Wrangler) setKeyspaceShardingInfo(keyspace, shardingColumnName string,
 	return topo.UpdateKeyspace(wr.ts, ki)
 }
 
+// SetKeyspaceShardingScheme locks a keyspace and sets its
+// ShardingScheme and ShardingSchemeParams. An empty scheme removes it.
+// The scheme must be registered with key.RegisterShardingScheme.
+func (wr *Wrangler) SetKeyspaceShardingScheme(ctx context.Context, keyspace, scheme string, params map[string]string, force bool) error {
+	if scheme != "" {
+		if err := key.ValidateShardingScheme(scheme, params); err != nil {
+			return fmt.Errorf("invalid sharding scheme: %v", err)
+		}
+	}
+
+	actionNode := actionnode.SetKeyspaceShardingScheme()
+	lockPath, err := wr.lockKeyspace(ctx, keyspace, actionNode)
+	if err != nil {
+		return err
+	}
+
+	err = wr.setKeyspaceShardingScheme(keyspace, scheme, params, force)
+	return wr.unlockKeyspace(ctx, keyspace, actionNode, lockPath, err)
+}
+
+func (wr *Wrangler) setKeyspaceShardingScheme(keyspace, scheme string, params map[string]string, force bool) error {
+	ki, err := wr.ts.GetKeyspace(keyspace)
+	if err != nil {
+		return err
+	}
+
+	// a new scheme, or new parameters, change the keyspace ids
+	// of the existing rows
+	if ki.ShardingScheme != "" && (ki.ShardingScheme != scheme || !sameShardingSchemeParams(ki.ShardingSchemeParams, params)) {
+		if force {
+			wr.Logger().Warningf("Forcing keyspace ShardingScheme change from %v %v to %v %v", ki.ShardingScheme, ki.ShardingSchemeParams, scheme, params)
+		} else {
+			return fmt.Errorf("Cannot change ShardingScheme from %v %v to %v %v (use -force to override)", ki.ShardingScheme, ki.ShardingSchemeParams, scheme, params)
+		}
+	}
+
+	ki.ShardingScheme = scheme
+	ki.ShardingSchemeParams = params
+	return topo.UpdateKeyspace(wr.ts, ki)
+}
+
+// sameShardingSchemeParams returns true if a and b have the same
+// parameters. A nil map is the same as an empty one.
+func sameShardingSchemeParams(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
 // MigrateServedTypes is used during horizontal splits to migrate a
 // served type from a list of shards to another.
 func (wr *Wrangler) MigrateServedTypes(ctx context.Context, keyspace, shard string, cells []string, servedType topo.TabletType, reverse, skipReFreshState bool, filteredReplicationWaitTime time.Duration) error {
@@ -90,46 +145,9 @@ func (wr *Wrangler) MigrateServedTypes(ctx context.Context, keyspace, shard stri
 		}
 	}
 
-	// find overlapping shards in this keyspace
-	wr.Logger().Infof("Finding the overlapping shards in keyspace %v", keyspace)
-	osList, err := topotools.FindOverlappingShards(wr.ts, keyspace)
+	sourceShards, destinationShards, err := wr.findMigrateServedTypesShards(keyspace, shard, cells, servedType, reverse)
 	if err != nil {
-		return fmt.Errorf("FindOverlappingShards failed: %v", err)
-	}
-
-	// find our shard in there
-	os := topotools.OverlappingShardsForShard(osList, shard)
-	if os == nil {
-		return fmt.Errorf("Shard %v is not involved in any overlapping shards", shard)
-	}
-
-	// find which list is which: the sources have no source
-	// shards, the destination have source shards. We check the
-	// first entry in the lists, then just check they're
-	// consistent
-	var sourceShards []*topo.ShardInfo
-	var destinationShards []*topo.ShardInfo
-	if len(os.Left[0].SourceShards) == 0 {
-		sourceShards = os.Left
-		destinationShards = os.Right
-	} else {
-		sourceShards = os.Right
-		destinationShards = os.Left
-	}
-
-	// Verify the sources has the type we're migrating (or not if reverse)
-	for _, si := range sourceShards {
-		if err := si.CheckServedTypesMigration(servedType, cells, !reverse); err != nil {
-			return err
-		}
-	}
-
-	// Verify the destinations do not have the type we're
-	// migrating (or do if reverse)
-	for _, si := range destinationShards {
-		if err := si.CheckServedTypesMigration(servedType, cells, reverse); err != nil {
-			return err
-		}
+		return err
 	}
 
 	// lock the shards: sources, then destinations
@@ -192,6 +210,53 @@ func (wr *Wrangler) MigrateServedTypes(ctx context.Context, keyspace, shard stri
 	return rec.Error()
 }
 
+// findMigrateServedTypesShards returns the source and destination
+// shards of the horizontal split shard is part of, and checks that
+// servedType can be migrated between them.
+func (wr *Wrangler) findMigrateServedTypesShards(keyspace, shard string, cells []string, servedType topo.TabletType, reverse bool) (sourceShards, destinationShards []*topo.ShardInfo, err error) {
+	// find overlapping shards in this keyspace
+	wr.Logger().Infof("Finding the overlapping shards in keyspace %v", keyspace)
+	osList, err := topotools.FindOverlappingShards(wr.ts, keyspace)
+	if err != nil {
+		return nil, nil, fmt.Errorf("FindOverlappingShards failed: %v", err)
+	}
+
+	// find our shard in there
+	os := topotools.OverlappingShardsForShard(osList, shard)
+	if os == nil {
+		return nil, nil, fmt.Errorf("Shard %v is not involved in any overlapping shards", shard)
+	}
+
+	// find which list is which: the sources have no source
+	// shards, the destination have source shards. We check the
+	// first entry in the lists, then just check they're
+	// consistent
+	if len(os.Left[0].SourceShards) == 0 {
+		sourceShards = os.Left
+		destinationShards = os.Right
+	} else {
+		sourceShards = os.Right
+		destinationShards = os.Left
+	}
+
+	// Verify the sources has the type we're migrating (or not if reverse)
+	for _, si := range sourceShards {
+		if err := si.CheckServedTypesMigration(servedType, cells, !reverse); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Verify the destinations do not have the type we're
+	// migrating (or do if reverse)
+	for _, si := range destinationShards {
+		if err := si.CheckServedTypesMigration(servedType, cells, reverse); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return sourceShards, destinationShards, nil
+}
+
 func removeType(tabletType topo.TabletType, types []topo.TabletType) ([]topo.TabletType, bool) {
 	result := make([]topo.TabletType, 0, len(types)-1)
 	found := false
@@ -724,28 +789,18 @@ func (wr *Wrangler) RefreshTablesByShard(ctx context.Context, si *topo.ShardInfo
 		return err
 	}
 
-	// ignore errors in this phase
-	wg := sync.WaitGroup{}
-	for _, ti := range tabletMap {
+	// ignore errors in this phase, they are only logged.
+	// Using 60 seconds because RefreshState should not take more than 30 seconds.
+	// (RefreshState will restart the tablet's QueryService and most time will be spent on the shutdown, i.e. waiting up to 30 seconds on transactions (see Config.TransactionTimeout)).
+	br := wr.RunOnTablets(ctx, "RefreshState", tabletMapToList(tabletMap), DefaultBulkConcurrency, 60*time.Second, func(ctx context.Context, ti *topo.TabletInfo) error {
 		if ti.Type != tabletType {
-			continue
+			return ErrTabletSkipped
 		}
-
-		wg.Add(1)
-		go func(ti *topo.TabletInfo) {
-			wr.Logger().Infof("Calling RefreshState on tablet %v", ti.Alias)
-			// Setting an upper bound timeout to fail faster in case of an error.
-			// Using 60 seconds because RefreshState should not take more than 30 seconds.
-			// (RefreshState will restart the tablet's QueryService and most time will be spent on the shutdown, i.e. waiting up to 30 seconds on transactions (see Config.TransactionTimeout)).
-			ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
-			if err := wr.tmc.RefreshState(ctx, ti); err != nil {
-				wr.Logger().Warningf("RefreshTablesByShard: failed to refresh %v: %v", ti.Alias, err)
-			}
-			cancel()
-			wg.Done()
-		}(ti)
+		wr.Logger().Infof("Calling RefreshState on tablet %v", ti.Alias)
+		return wr.tmc.RefreshState(ctx, ti)
+	})
+	for _, r := range br.Failures() {
+		wr.Logger().Warningf("RefreshTablesByShard: failed to refresh %v: %v", r.Alias, r.Error)
 	}
-	wg.Wait()
-
 	return nil
 }
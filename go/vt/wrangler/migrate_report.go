@@ -0,0 +1,237 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wrangler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/youtube/vitess/go/vt/binlog/binlogplayer"
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
+)
+
+// MigrateServedTypesReport describes what MigrateServedTypes would
+// change, without changing anything.
+type MigrateServedTypesReport struct {
+	Keyspace          string
+	ServedType        topo.TabletType
+	Reverse           bool
+	SourceShards      []string
+	DestinationShards []string
+
+	// Partitions are the ServedType partitions of the SrvKeyspace
+	// that get rewritten, per cell.
+	Partitions map[string]*PartitionChange
+
+	// Tablets are the ServedType tablets that stop or start serving.
+	Tablets []*TabletServingChange
+
+	// FilteredReplicationLagSeconds is the filtered replication lag of
+	// each destination master: the time since the source committed
+	// the last transaction it applied. It is overestimated when the
+	// source shards are idle.
+	FilteredReplicationLagSeconds map[string]int64
+
+	// EstimatedUnavailabilitySeconds estimates how long the writes are
+	// refused during a master migration: the source masters stop
+	// serving until the destination masters catch up with filtered
+	// replication, so it is the largest lag. It is only an estimate:
+	// it is too high with idle sources, and doesn't include the time
+	// to apply the writes received while the destinations catch up.
+	// The other types move over with the serving graph, without
+	// unavailability.
+	EstimatedUnavailabilitySeconds int64
+}
+
+// PartitionChange is the list of shards of a partition, before and
+// after a migration.
+type PartitionChange struct {
+	Before []string
+	After  []string
+}
+
+// TabletServingChange is a tablet that stops or starts serving in a
+// migration.
+type TabletServingChange struct {
+	Alias   topo.TabletAlias
+	Shard   string
+	Serving bool
+}
+
+// MigrateServedTypesDryRun returns what MigrateServedTypes would change
+// with the same parameters. It does the same checks, but doesn't lock
+// or change anything.
+func (wr *Wrangler) MigrateServedTypesDryRun(ctx context.Context, keyspace, shard string, cells []string, servedType topo.TabletType, reverse bool) (*MigrateServedTypesReport, error) {
+	if servedType == topo.TYPE_MASTER && reverse {
+		return nil, fmt.Errorf("Cannot migrate master back to %v/%v", keyspace, shard)
+	}
+	sourceShards, destinationShards, err := wr.findMigrateServedTypesShards(keyspace, shard, cells, servedType, reverse)
+	if err != nil {
+		return nil, err
+	}
+	report := &MigrateServedTypesReport{
+		Keyspace:          keyspace,
+		ServedType:        servedType,
+		Reverse:           reverse,
+		SourceShards:      shardNames(sourceShards),
+		DestinationShards: shardNames(destinationShards),
+	}
+
+	// the type moves from the sources to the destinations, or back
+	fromShards, toShards := sourceShards, destinationShards
+	if reverse {
+		fromShards, toShards = destinationShards, sourceShards
+	}
+
+	if report.Partitions, err = wr.partitionChanges(keyspace, fromShards, toShards, cells, servedType); err != nil {
+		return nil, err
+	}
+	for _, si := range fromShards {
+		changes, err := wr.tabletServingChanges(ctx, si, cells, servedType, false)
+		if err != nil {
+			return nil, err
+		}
+		report.Tablets = append(report.Tablets, changes...)
+	}
+	for _, si := range toShards {
+		changes, err := wr.tabletServingChanges(ctx, si, cells, servedType, true)
+		if err != nil {
+			return nil, err
+		}
+		report.Tablets = append(report.Tablets, changes...)
+	}
+
+	report.FilteredReplicationLagSeconds = make(map[string]int64)
+	for _, si := range destinationShards {
+		if len(si.SourceShards) == 0 || si.MasterAlias.IsZero() {
+			continue
+		}
+		lag, err := wr.filteredReplicationLag(ctx, si)
+		if err != nil {
+			return nil, err
+		}
+		report.FilteredReplicationLagSeconds[si.ShardName()] = lag
+		if servedType == topo.TYPE_MASTER && lag > report.EstimatedUnavailabilitySeconds {
+			report.EstimatedUnavailabilitySeconds = lag
+		}
+	}
+	return report, nil
+}
+
+func shardNames(shards []*topo.ShardInfo) []string {
+	names := make([]string, len(shards))
+	for i, si := range shards {
+		names[i] = si.ShardName()
+	}
+	return names
+}
+
+// partitionChanges returns the servedType partitions that are
+// rewritten when the type moves from fromShards to toShards, in the
+// cells of the shards.
+func (wr *Wrangler) partitionChanges(keyspace string, fromShards, toShards []*topo.ShardInfo, cells []string, servedType topo.TabletType) (map[string]*PartitionChange, error) {
+	cellSet := make(map[string]bool)
+	for _, si := range append(fromShards, toShards...) {
+		for _, cell := range si.Cells {
+			if topo.InCellList(cell, cells) {
+				cellSet[cell] = true
+			}
+		}
+	}
+	from := make(map[string]bool, len(fromShards))
+	for _, si := range fromShards {
+		from[si.ShardName()] = true
+	}
+
+	result := make(map[string]*PartitionChange, len(cellSet))
+	for cell := range cellSet {
+		var refs topo.ShardReferenceArray
+		srvKeyspace, err := wr.ts.GetSrvKeyspace(cell, keyspace)
+		switch err {
+		case nil:
+			if partition, ok := srvKeyspace.Partitions[servedType]; ok {
+				refs = partition.ShardReferences
+			}
+		case topo.ErrNoNode:
+		default:
+			return nil, fmt.Errorf("GetSrvKeyspace(%v, %v) failed: %v", cell, keyspace, err)
+		}
+
+		change := &PartitionChange{}
+		var after topo.ShardReferenceArray
+		for _, ref := range refs {
+			change.Before = append(change.Before, ref.Name)
+			if !from[ref.Name] {
+				after = append(after, ref)
+			}
+		}
+		for _, si := range toShards {
+			after = append(after, topo.ShardReference{Name: si.ShardName(), KeyRange: si.KeyRange})
+		}
+		after.Sort()
+		for _, ref := range after {
+			change.After = append(change.After, ref.Name)
+		}
+		result[cell] = change
+	}
+	return result, nil
+}
+
+// tabletServingChanges returns the servedType tablets of the shard in
+// cells, which start or stop serving.
+func (wr *Wrangler) tabletServingChanges(ctx context.Context, si *topo.ShardInfo, cells []string, servedType topo.TabletType, serving bool) ([]*TabletServingChange, error) {
+	tabletMap, err := topo.GetTabletMapForShardByCell(ctx, wr.ts, si.Keyspace(), si.ShardName(), cells)
+	if err != nil && err != topo.ErrPartialResult {
+		return nil, err
+	}
+	var aliases topo.TabletAliasList
+	for alias, ti := range tabletMap {
+		if ti.Type == servedType {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Sort(aliases)
+	changes := make([]*TabletServingChange, len(aliases))
+	for i, alias := range aliases {
+		changes[i] = &TabletServingChange{
+			Alias:   alias,
+			Shard:   si.ShardName(),
+			Serving: serving,
+		}
+	}
+	return changes, nil
+}
+
+// filteredReplicationLag returns the largest lag of the binlog players
+// of the destination shard master: the time since the source
+// committed the last transaction they applied, on the master clock.
+// The players that haven't applied a transaction yet are ignored.
+func (wr *Wrangler) filteredReplicationLag(ctx context.Context, si *topo.ShardInfo) (int64, error) {
+	ti, err := wr.ts.GetTablet(si.MasterAlias)
+	if err != nil {
+		return 0, err
+	}
+	qr, err := wr.tmc.ExecuteFetchAsDba(ctx, ti, binlogplayer.QueryBlpTransactionTimes(), len(si.SourceShards), false, false)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read the filtered replication checkpoints of %v: %v", si.MasterAlias, err)
+	}
+	var lag int64
+	for _, row := range qr.Rows {
+		now, err := strconv.ParseInt(row[0].String(), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		txTimestamp, err := strconv.ParseInt(row[1].String(), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		if txTimestamp != 0 && now-txTimestamp > lag {
+			lag = now - txTimestamp
+		}
+	}
+	return lag, nil
+}
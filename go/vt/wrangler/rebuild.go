@@ -9,7 +9,6 @@ import (
 	"sync"
 
 	"github.com/youtube/vitess/go/vt/concurrency"
-	"github.com/youtube/vitess/go/vt/key"
 	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
 	"github.com/youtube/vitess/go/vt/topo"
 	"github.com/youtube/vitess/go/vt/topotools"
@@ -36,6 +35,30 @@ func (wr *Wrangler) RebuildKeyspaceGraph(ctx context.Context, keyspace string, c
 	return wr.unlockKeyspace(ctx, keyspace, actionNode, lockPath, err)
 }
 
+// FixSrvKeyspace rebuilds the serving graph of a keyspace in the
+// cells where its SrvKeyspace partitions don't cover the keyrange
+// exactly once. The rebuild recomputes the partitions from the global
+// shard records: if those are inconsistent too (for instance after an
+// interrupted MigrateServedTypes), it fails without writing anything,
+// and the shard served types have to be fixed first.
+func (wr *Wrangler) FixSrvKeyspace(ctx context.Context, keyspace string) error {
+	badCells, err := wr.checkSrvKeyspaces(keyspace)
+	if err != nil {
+		return err
+	}
+	if len(badCells) == 0 {
+		wr.logger.Infof("SrvKeyspace %v is valid in all cells", keyspace)
+		return nil
+	}
+
+	cells := make([]string, 0, len(badCells))
+	for cell, err := range badCells {
+		wr.logger.Warningf("SrvKeyspace %v in cell %v is invalid, rebuilding it: %v", keyspace, cell, err)
+		cells = append(cells, cell)
+	}
+	return wr.RebuildKeyspaceGraph(ctx, keyspace, cells)
+}
+
 // findCellsForRebuild will find all the cells in the given keyspace
 // and create an entry if the map for them
 func (wr *Wrangler) findCellsForRebuild(ki *topo.KeyspaceInfo, shardMap map[string]*topo.ShardInfo, cells []string, srvKeyspaceMap map[string]*topo.SrvKeyspace) {
@@ -46,10 +69,12 @@ func (wr *Wrangler) findCellsForRebuild(ki *topo.KeyspaceInfo, shardMap map[stri
 			}
 			if _, ok := srvKeyspaceMap[cell]; !ok {
 				srvKeyspaceMap[cell] = &topo.SrvKeyspace{
-					ShardingColumnName: ki.ShardingColumnName,
-					ShardingColumnType: ki.ShardingColumnType,
-					ServedFrom:         ki.ComputeCellServedFrom(cell),
-					SplitShardCount:    ki.SplitShardCount,
+					ShardingColumnName:   ki.ShardingColumnName,
+					ShardingColumnType:   ki.ShardingColumnType,
+					ShardingScheme:       ki.ShardingScheme,
+					ShardingSchemeParams: ki.ShardingSchemeParams,
+					ServedFrom:           ki.ComputeCellServedFrom(cell),
+					SplitShardCount:      ki.SplitShardCount,
 				}
 			}
 		}
@@ -139,6 +164,7 @@ func (wr *Wrangler) rebuildKeyspace(ctx context.Context, keyspace string, cells
 					KeyRange:    si.KeyRange,
 					ServedTypes: servedTypes,
 					MasterCell:  si.MasterAlias.Cell,
+					ReadOnly:    si.ReadOnly,
 				}
 			default:
 				return err
@@ -193,18 +219,8 @@ func (wr *Wrangler) orderAndCheckPartitions(cell string, srvKeyspace *topo.SrvKe
 		topo.SrvShardArray(partition.Shards).Sort()
 		topo.ShardReferenceArray(partition.ShardReferences).Sort()
 
-		// check the first Start is MinKey, the last End is MaxKey,
-		// and the values in between match: End[i] == Start[i+1]
-		if partition.ShardReferences[0].KeyRange.Start != key.MinKey {
-			return fmt.Errorf("keyspace partition for %v in cell %v does not start with %v", tabletType, cell, key.MinKey)
-		}
-		if partition.ShardReferences[len(partition.ShardReferences)-1].KeyRange.End != key.MaxKey {
-			return fmt.Errorf("keyspace partition for %v in cell %v does not end with %v", tabletType, cell, key.MaxKey)
-		}
-		for i := range partition.ShardReferences[0 : len(partition.ShardReferences)-1] {
-			if partition.ShardReferences[i].KeyRange.End != partition.ShardReferences[i+1].KeyRange.Start {
-				return fmt.Errorf("non-contiguous KeyRange values for %v in cell %v at shard %v to %v: %v != %v", tabletType, cell, i, i+1, partition.ShardReferences[i].KeyRange.End.Hex(), partition.ShardReferences[i+1].KeyRange.Start.Hex())
-			}
+		if err := partition.CheckCoverage(); err != nil {
+			return fmt.Errorf("invalid keyspace partition for %v in cell %v: %v", tabletType, cell, err)
 		}
 	}
 
@@ -37,7 +37,7 @@ On X: (Promote Slave)
   RESET SLAVE;
   SHOW MASTER STATUS;
     replication file,position
-  INSERT INTO _vt.replication_log (time_created_ns, 'reparent check') VALUES (<time>);
+  INSERT INTO _vt.reparent_journal (time_created_ns, action_name, master_alias, replication_position) VALUES (<time>, ...);
   INSERT INTO _vt.reparent_log (time_created_ns, 'last post', 'new pos') VALUES ... ;
   SHOW MASTER STATUS;
     wait file,position
@@ -55,8 +55,9 @@ For all slaves in majority N:
     RESET SLAVE;
     CHANGE MASTER TO X;
     START SLAVE;
-    SELECT MASTER_POS_WAIT(file, pos, deadline)
-    SELECT time_created FROM _vt.replication_log WHERE time_created_ns = <time>;
+    SELECT action_name, master_alias, replication_position FROM _vt.reparent_journal WHERE time_created_ns = <time>;
+      (polled until the row was replicated, so the slave is known
+      to replicate from X)
 
 if no connection to N is available, ???
 
@@ -336,7 +336,7 @@ func (wr *Wrangler) finishReparent(ctx context.Context, si *topo.ShardInfo, mast
 	}
 
 	// save the new master in the shard info
-	si.MasterAlias = masterElect.Alias
+	si.SetMaster(masterElect.Alias)
 	if err := topo.UpdateShard(ctx, wr.ts, si); err != nil {
 		wr.logger.Errorf("Failed to save new master into shard: %v", err)
 		return err
@@ -0,0 +1,261 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wrangler
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/topotools"
+)
+
+// RepairReplicationGraph reconciles the replication graph of a shard
+// with the actual MySQL replication of its tablets, as reported by
+// their slave status. The master is the tablet all the slaves
+// replicate from: the shard record is pointed to it, and it becomes
+// the only tablet of type master, the other ones are made spare. The
+// entries of the replication graph whose tablet doesn't exist, is in
+// another shard or is scrapped are removed, and the tablets of the
+// shard missing from it are added.
+//
+// MySQL itself is never changed: slaves that replicate from another
+// host, or don't replicate, are only reported. With dryRun, nothing
+// is changed at all. It returns the problems it found.
+func (wr *Wrangler) RepairReplicationGraph(ctx context.Context, keyspace, shard string, dryRun bool) ([]string, error) {
+	if dryRun {
+		return wr.repairReplicationGraph(ctx, keyspace, shard, true)
+	}
+
+	actionNode := actionnode.RepairReplicationGraph()
+	lockPath, err := wr.lockShard(ctx, keyspace, shard, actionNode)
+	if err != nil {
+		return nil, err
+	}
+	problems, err := wr.repairReplicationGraph(ctx, keyspace, shard, false)
+	return problems, wr.unlockShard(ctx, keyspace, shard, actionNode, lockPath, err)
+}
+
+func (wr *Wrangler) repairReplicationGraph(ctx context.Context, keyspace, shard string, dryRun bool) ([]string, error) {
+	si, err := wr.ts.GetShard(keyspace, shard)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the replication graph, and all the tablets of the shard,
+	// in every cell of the shard. We can't work with partial
+	// results, as we would remove valid entries.
+	links := make(map[topo.TabletAlias]bool)
+	tablets := make(map[topo.TabletAlias]*topo.TabletInfo)
+	for _, cell := range si.Cells {
+		sri, err := wr.ts.GetShardReplication(cell, keyspace, shard)
+		switch err {
+		case nil:
+			for _, rl := range sri.ReplicationLinks {
+				links[rl.TabletAlias] = true
+			}
+		case topo.ErrNoNode:
+		default:
+			return nil, fmt.Errorf("GetShardReplication(%v, %v, %v) failed: %v", cell, keyspace, shard, err)
+		}
+
+		aliases, err := wr.ts.GetTabletsByCell(cell)
+		if err != nil {
+			return nil, fmt.Errorf("GetTabletsByCell(%v) failed: %v", cell, err)
+		}
+		tabletMap, err := topo.GetTabletMap(ctx, wr.ts, aliases)
+		if err != nil {
+			return nil, fmt.Errorf("GetTabletMap(%v) failed: %v", cell, err)
+		}
+		for alias, ti := range tabletMap {
+			if ti.Keyspace == keyspace && ti.Shard == shard && ti.IsInReplicationGraph() {
+				tablets[alias] = ti
+			}
+		}
+	}
+
+	// Ask every tablet what it replicates from. The ones that
+	// fail are either not slaves, or not reachable.
+	mu := sync.Mutex{}
+	masterAddrs := make(map[topo.TabletAlias]string)
+	br := wr.RunOnTablets(ctx, "SlaveStatus", tabletMapToList(tablets), DefaultBulkConcurrency, DefaultBulkTabletTimeout, func(ctx context.Context, ti *topo.TabletInfo) error {
+		status, err := wr.tmc.SlaveStatus(ctx, ti)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		masterAddrs[ti.Alias] = status.MasterAddr()
+		mu.Unlock()
+		return nil
+	})
+	master, err := findReplicationMaster(keyspace, shard, tablets, masterAddrs)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	var fixes []func() error
+	problem := func(fix func() error, format string, args ...interface{}) {
+		p := fmt.Sprintf(format, args...)
+		wr.Logger().Warningf("%v", p)
+		problems = append(problems, p)
+		if fix != nil {
+			fixes = append(fixes, fix)
+		}
+	}
+
+	// The tablet records: only the master has the master type.
+	var changed []*topo.TabletInfo
+	masterTerm := si.MasterTerm
+	if si.MasterAlias != master {
+		masterTerm++
+	}
+	for _, alias := range sortedTabletAliases(tablets) {
+		ti := tablets[alias]
+		switch {
+		case alias == master && ti.Type != topo.TYPE_MASTER:
+			problem(func() error {
+				ti.Type = topo.TYPE_MASTER
+				ti.Health = nil
+				ti.MasterTerm = masterTerm
+				changed = append(changed, ti)
+				return topo.UpdateTablet(ctx, wr.ts, ti)
+			}, "tablet %v is the master, but its type is %v", alias, ti.Type)
+		case alias != master && ti.Type == topo.TYPE_MASTER:
+			problem(func() error {
+				ti.Type = topo.TYPE_SPARE
+				changed = append(changed, ti)
+				return topo.UpdateTablet(ctx, wr.ts, ti)
+			}, "tablet %v has the master type, but the master is %v", alias, master)
+		}
+	}
+
+	// The shard record.
+	if si.MasterAlias != master {
+		problem(func() error {
+			si.SetMaster(master)
+			return topo.UpdateShard(ctx, wr.ts, si)
+		}, "the master of the shard is %v, but the slaves replicate from %v", si.MasterAlias, master)
+	}
+
+	// The replication graph.
+	for _, alias := range sortedAliases(links) {
+		if _, ok := tablets[alias]; ok {
+			continue
+		}
+		alias := alias
+		problem(func() error {
+			return topo.RemoveShardReplicationRecord(wr.ts, alias.Cell, keyspace, shard, alias)
+		}, "tablet %v is in the replication graph, but doesn't exist, is scrapped, or is in another shard", alias)
+	}
+	for _, alias := range sortedTabletAliases(tablets) {
+		if links[alias] {
+			continue
+		}
+		alias := alias
+		problem(func() error {
+			return topo.UpdateShardReplicationRecord(ctx, wr.ts, keyspace, shard, alias)
+		}, "tablet %v is missing from the replication graph", alias)
+	}
+
+	// What we cannot fix: MySQL replication itself.
+	masterMysqlAddrs := map[string]bool{
+		normalizeAddr(tablets[master].MysqlAddr()):   true,
+		normalizeAddr(tablets[master].MysqlIPAddr()): true,
+	}
+	for _, alias := range sortedTabletAliases(tablets) {
+		if addr, ok := masterAddrs[alias]; ok && !masterMysqlAddrs[normalizeAddr(addr)] {
+			problem(nil, "tablet %v replicates from %v, not from the master %v", alias, addr, master)
+		}
+	}
+	for _, r := range br.Failures() {
+		if r.Alias != master && tablets[r.Alias].IsSlaveType() {
+			problem(nil, "tablet %v is not replicating: %v", r.Alias, r.Error)
+		}
+	}
+
+	if dryRun || len(fixes) == 0 {
+		return problems, nil
+	}
+	for _, fix := range fixes {
+		if err := fix(); err != nil {
+			return problems, err
+		}
+	}
+	if _, err := topotools.RebuildShard(ctx, wr.logger, wr.ts, keyspace, shard, nil, wr.lockTimeout); err != nil {
+		return problems, err
+	}
+	for _, ti := range changed {
+		if err := wr.tmc.RefreshState(ctx, ti); err != nil {
+			wr.Logger().Warningf("RefreshState(%v) failed: %v", ti.Alias, err)
+		}
+	}
+	return problems, nil
+}
+
+// findReplicationMaster returns the tablet the slaves replicate from.
+// It fails if they replicate from different tablets, or if that
+// tablet is a slave itself.
+func findReplicationMaster(keyspace, shard string, tablets map[topo.TabletAlias]*topo.TabletInfo, masterAddrs map[topo.TabletAlias]string) (topo.TabletAlias, error) {
+	byAddr := make(map[string]topo.TabletAlias)
+	for alias, ti := range tablets {
+		byAddr[normalizeAddr(ti.MysqlAddr())] = alias
+		byAddr[normalizeAddr(ti.MysqlIPAddr())] = alias
+	}
+	masters := make(map[topo.TabletAlias]bool)
+	for _, addr := range masterAddrs {
+		if alias, ok := byAddr[normalizeAddr(addr)]; ok {
+			masters[alias] = true
+		}
+	}
+	switch len(masters) {
+	case 0:
+		return topo.TabletAlias{}, fmt.Errorf("no tablet of %v/%v replicates from another one, cannot find the master", keyspace, shard)
+	case 1:
+	default:
+		return topo.TabletAlias{}, fmt.Errorf("the tablets of %v/%v replicate from different masters %v, fix MySQL replication first", keyspace, shard, sortedAliases(masters))
+	}
+	var master topo.TabletAlias
+	for alias := range masters {
+		master = alias
+	}
+	if addr, ok := masterAddrs[master]; ok {
+		return topo.TabletAlias{}, fmt.Errorf("master %v of %v/%v replicates from %v, fix MySQL replication first", master, keyspace, shard, addr)
+	}
+	return master, nil
+}
+
+// normalizeAddr normalizes the host of a host:port address, see
+// normalizeIP.
+func normalizeAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return net.JoinHostPort(normalizeIP(host), port)
+}
+
+func sortedAliases(aliases map[topo.TabletAlias]bool) []topo.TabletAlias {
+	result := make([]topo.TabletAlias, 0, len(aliases))
+	for alias := range aliases {
+		result = append(result, alias)
+	}
+	sort.Sort(topo.TabletAliasList(result))
+	return result
+}
+
+func sortedTabletAliases(tablets map[topo.TabletAlias]*topo.TabletInfo) []topo.TabletAlias {
+	result := make([]topo.TabletAlias, 0, len(tablets))
+	for alias := range tablets {
+		result = append(result, alias)
+	}
+	sort.Sort(topo.TabletAliasList(result))
+	return result
+}
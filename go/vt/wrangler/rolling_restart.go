@@ -0,0 +1,203 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wrangler
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	hk "github.com/youtube/vitess/go/vt/hook"
+	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
+	"github.com/youtube/vitess/go/vt/topo"
+	"golang.org/x/net/context"
+)
+
+// RollingRestartOptions are the parameters for RollingRestartShard.
+type RollingRestartOptions struct {
+	// HookName is the hook run to restart each tablet. It is run
+	// locally, not on the tablet: the vttablet process can't
+	// restart itself. It gets the tablet alias and hostname as
+	// its --tablet_alias and --tablet_hostname parameters, and
+	// should only return once the tablet was restarted (usually
+	// through the process manager of its host).
+	HookName string
+
+	// MinServing is the minimum number of other tablets of the
+	// same type that must be healthy and serving in the shard
+	// while a tablet is restarted. If it cannot be satisfied, the
+	// rolling restart is aborted.
+	MinServing int
+
+	// LameDuckPeriod is passed to DrainTablet.
+	LameDuckPeriod time.Duration
+
+	// MaxReplicationLag is the replication lag a restarted tablet
+	// needs to catch up to before it is put back in service.
+	MaxReplicationLag time.Duration
+
+	// WaitTime is how long we wait for a restarted tablet to be
+	// healthy and caught up, and then serving.
+	WaitTime time.Duration
+
+	// HealthTimeout is how long we wait for the health report
+	// of a tablet. It should be above the -health_check_interval
+	// of the tablets.
+	HealthTimeout time.Duration
+}
+
+// RollingRestartShard restarts all the serving slaves of a shard, one
+// at a time. Each tablet is drained, restarted by running the
+// restart hook, waited on until it reports it is healthy and caught
+// up, and then put back in service with its original type, until it
+// reports it is serving. The tablets need to run the health check
+// (-target_tablet_type), as their health reports are used.
+// The master is not restarted. If any step fails, the rolling
+// restart is aborted, and the tablet that failed is left drained.
+func (wr *Wrangler) RollingRestartShard(ctx context.Context, keyspace, shard string, options *RollingRestartOptions) error {
+	tablets, err := wr.GetTabletsInShard(ctx, keyspace, shard)
+	if err != nil {
+		return err
+	}
+	sort.Sort(tabletInfoList(tablets))
+
+	for _, ti := range tablets {
+		if ti.Type == topo.TYPE_MASTER || !ti.IsInServingGraph() {
+			continue
+		}
+		if err := wr.rollingRestartTablet(ctx, ti, options); err != nil {
+			return fmt.Errorf("rolling restart of %v/%v aborted at tablet %v: %v", keyspace, shard, ti.Alias, err)
+		}
+	}
+	return nil
+}
+
+// rollingRestartTablet restarts a single tablet for
+// RollingRestartShard.
+func (wr *Wrangler) rollingRestartTablet(ctx context.Context, ti *topo.TabletInfo, options *RollingRestartOptions) error {
+	// re-read the shard tablets, to make sure we have enough
+	// healthy serving tablets left while this one is down.
+	tablets, err := wr.GetTabletsInShard(ctx, ti.Keyspace, ti.Shard)
+	if err != nil {
+		return err
+	}
+	var others []*topo.TabletInfo
+	for _, other := range tablets {
+		if other.Alias != ti.Alias && other.Type == ti.Type {
+			others = append(others, other)
+		}
+	}
+	br := wr.RunOnTablets(ctx, "HealthCheck", others, 0, options.HealthTimeout, func(ctx context.Context, other *topo.TabletInfo) error {
+		return wr.checkServingTablet(ctx, other, ti.Type, options.MaxReplicationLag)
+	})
+	if serving := br.Count(TabletActionSuccess); serving < options.MinServing {
+		return fmt.Errorf("only %v other %v tablets are healthy and serving, need at least %v:\n%v", serving, ti.Type, options.MinServing, br)
+	}
+
+	originalType := ti.Type
+	wr.Logger().Infof("restarting tablet %v of type %v", ti.Alias, originalType)
+	if err := wr.DrainTablet(ctx, ti.Alias, topo.TYPE_SPARE, options.LameDuckPeriod); err != nil {
+		return err
+	}
+
+	hr := hk.NewHook(options.HookName, []string{
+		"--tablet_alias=" + ti.Alias.String(),
+		"--tablet_hostname=" + ti.Hostname,
+	}).Execute()
+	if hr.ExitStatus != hk.HOOK_SUCCESS {
+		return fmt.Errorf("hook %v failed: %v", options.HookName, hr.String())
+	}
+
+	// wait until the restarted tablet is caught up, put it back
+	// in service, and wait until it serves
+	waitCtx, cancel := context.WithTimeout(ctx, options.WaitTime)
+	defer cancel()
+	if err := wr.waitForTablet(waitCtx, ti, options.HealthTimeout, func(ctx context.Context) error {
+		return wr.checkServingTablet(ctx, ti, "", options.MaxReplicationLag)
+	}); err != nil {
+		return err
+	}
+	if err := wr.ChangeType(ctx, ti.Alias, originalType, false); err != nil {
+		return err
+	}
+	if err := wr.waitForTablet(waitCtx, ti, options.HealthTimeout, func(ctx context.Context) error {
+		return wr.checkServingTablet(ctx, ti, originalType, options.MaxReplicationLag)
+	}); err != nil {
+		return err
+	}
+	wr.Logger().Infof("tablet %v is back in service as %v", ti.Alias, originalType)
+	return nil
+}
+
+// waitForTablet runs check until it succeeds, or ctx is done. Each
+// check is given healthTimeout.
+func (wr *Wrangler) waitForTablet(ctx context.Context, ti *topo.TabletInfo, healthTimeout time.Duration, check func(context.Context) error) error {
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, healthTimeout)
+		err := check(checkCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		wr.Logger().Infof("tablet %v is not ready yet: %v", ti.Alias, err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("tablet %v did not become ready: %v", ti.Alias, err)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// checkServingTablet waits for the next health report of the tablet,
+// and returns an error unless it is healthy, with a replication lag
+// no more than maxLag. If tabletType is set, the tablet must also
+// serve as that type.
+func (wr *Wrangler) checkServingTablet(ctx context.Context, ti *topo.TabletInfo, tabletType topo.TabletType, maxLag time.Duration) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c, errFunc, err := wr.tmc.HealthStream(ctx, ti)
+	if err != nil {
+		return err
+	}
+
+	var hsr *actionnode.HealthStreamReply
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("no health report: %v", ctx.Err())
+	case r, ok := <-c:
+		if !ok {
+			if err := errFunc(); err != nil {
+				return err
+			}
+			return fmt.Errorf("health stream closed")
+		}
+		hsr = r
+	}
+	switch {
+	case hsr.HealthError != "":
+		return fmt.Errorf("unhealthy: %v", hsr.HealthError)
+	case hsr.ReplicationDelay > maxLag:
+		return fmt.Errorf("replication lag is %v", hsr.ReplicationDelay)
+	case tabletType != "" && (hsr.Tablet == nil || hsr.Tablet.Type != tabletType):
+		return fmt.Errorf("not serving as %v", tabletType)
+	}
+	return nil
+}
+
+// tabletInfoList is used to sort tablets by alias.
+type tabletInfoList []*topo.TabletInfo
+
+func (l tabletInfoList) Len() int {
+	return len(l)
+}
+
+func (l tabletInfoList) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+func (l tabletInfoList) Less(i, j int) bool {
+	return l[i].Alias.String() < l[j].Alias.String()
+}
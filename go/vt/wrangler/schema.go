@@ -44,18 +44,24 @@ func (wr *Wrangler) ReloadSchema(ctx context.Context, tabletAlias topo.TabletAli
 	return wr.tmc.ReloadSchema(ctx, ti)
 }
 
-// helper method to asynchronously diff a schema
-func (wr *Wrangler) diffSchema(ctx context.Context, masterSchema *myproto.SchemaDefinition, masterTabletAlias, alias topo.TabletAlias, excludeTables []string, includeViews bool, wg *sync.WaitGroup, er concurrency.ErrorRecorder) {
-	defer wg.Done()
-	log.Infof("Gathering schema for %v", alias)
-	slaveSchema, err := wr.GetSchema(ctx, alias, nil, excludeTables, includeViews)
-	if err != nil {
-		er.RecordError(err)
-		return
-	}
+// diffSchemaAction returns a TabletAction that diffs the schema of a
+// tablet with the reference schema. Differences are recorded in er.
+func (wr *Wrangler) diffSchemaAction(referenceSchema *myproto.SchemaDefinition, referenceAlias topo.TabletAlias, excludeTables []string, includeViews bool, er concurrency.ErrorRecorder) TabletAction {
+	return func(ctx context.Context, ti *topo.TabletInfo) error {
+		if ti.Alias == referenceAlias {
+			return ErrTabletSkipped
+		}
+
+		log.Infof("Gathering schema for %v", ti.Alias)
+		slaveSchema, err := wr.tmc.GetSchema(ctx, ti, nil, excludeTables, includeViews)
+		if err != nil {
+			return err
+		}
 
-	log.Infof("Diffing schema for %v", alias)
-	myproto.DiffSchema(masterTabletAlias.String(), masterSchema, alias.String(), slaveSchema, er)
+		log.Infof("Diffing schema for %v", ti.Alias)
+		myproto.DiffSchema(referenceAlias.String(), referenceSchema, ti.Alias.String(), slaveSchema, er)
+		return nil
+	}
 }
 
 // ValidateSchemaShard will diff the schema from all the tablets in the shard.
@@ -75,34 +81,92 @@ func (wr *Wrangler) ValidateSchemaShard(ctx context.Context, keyspace, shard str
 		return err
 	}
 
-	// read all the aliases in the shard, that is all tablets that are
+	// read all the tablets in the shard, that is all tablets that are
 	// replicating from the master
-	aliases, err := topo.FindAllTabletAliasesInShard(ctx, wr.ts, keyspace, shard)
-	if err != nil {
+	er := concurrency.AllErrorRecorder{}
+	tablets, err := wr.GetTabletsInShard(ctx, keyspace, shard)
+	switch err {
+	case nil:
+		// keep going
+	case topo.ErrPartialResult:
+		er.RecordError(fmt.Errorf("Cannot read all tablets in shard %v/%v", keyspace, shard))
+	default:
 		return err
 	}
 
 	// then diff with all slaves
-	er := concurrency.AllErrorRecorder{}
-	wg := sync.WaitGroup{}
-	for _, alias := range aliases {
-		if alias == si.MasterAlias {
-			continue
-		}
-
-		wg.Add(1)
-		go wr.diffSchema(ctx, masterSchema, si.MasterAlias, alias, excludeTables, includeViews, &wg, &er)
-	}
-	wg.Wait()
+	br := wr.RunOnTablets(ctx, "ValidateSchemaShard", tablets, DefaultBulkConcurrency, DefaultBulkTabletTimeout, wr.diffSchemaAction(masterSchema, si.MasterAlias, excludeTables, includeViews, &er))
+	er.RecordError(br.Error())
 	if er.HasErrors() {
 		return fmt.Errorf("Schema diffs:\n%v", er.Error().Error())
 	}
 	return nil
 }
 
+// TabletSchemaVersion is the schema version a tablet published in
+// its record, see topo.Tablet.SchemaVersion.
+type TabletSchemaVersion struct {
+	Alias         topo.TabletAlias
+	Type          topo.TabletType
+	SchemaVersion string
+	// Stale is true if the version is not the one of the master of
+	// the shard, or is unknown.
+	Stale bool
+}
+
+// GetSchemaVersions returns the schema versions of the tablets of a
+// shard, sorted by alias. Unlike ValidateSchemaShard, it doesn't ask
+// the tablets for their schema: it reads what their health check
+// last published, so it's cheap to run on the whole keyspace after
+// a schema change.
+func (wr *Wrangler) GetSchemaVersions(ctx context.Context, keyspace, shard string) ([]*TabletSchemaVersion, error) {
+	si, err := wr.ts.GetShard(keyspace, shard)
+	if err != nil {
+		return nil, err
+	}
+	tablets, err := wr.GetTabletsInShard(ctx, keyspace, shard)
+	if err != nil && err != topo.ErrPartialResult {
+		return nil, err
+	}
+
+	masterVersion := ""
+	for _, ti := range tablets {
+		if ti.Alias == si.MasterAlias {
+			masterVersion = ti.SchemaVersion
+		}
+	}
+	result := make([]*TabletSchemaVersion, 0, len(tablets))
+	for _, ti := range tablets {
+		result = append(result, &TabletSchemaVersion{
+			Alias:         ti.Alias,
+			Type:          ti.Type,
+			SchemaVersion: ti.SchemaVersion,
+			Stale:         ti.SchemaVersion == "" || ti.SchemaVersion != masterVersion,
+		})
+	}
+	sort.Sort(tabletSchemaVersionList(result))
+	return result, err
+}
+
+type tabletSchemaVersionList []*TabletSchemaVersion
+
+func (l tabletSchemaVersionList) Len() int {
+	return len(l)
+}
+
+func (l tabletSchemaVersionList) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+func (l tabletSchemaVersionList) Less(i, j int) bool {
+	return l[i].Alias.String() < l[j].Alias.String()
+}
+
 // ValidateSchemaKeyspace will diff the schema from all the tablets in
-// the keyspace.
-func (wr *Wrangler) ValidateSchemaKeyspace(ctx context.Context, keyspace string, excludeTables []string, includeViews bool) error {
+// the keyspace. The reference schema is the one of the master of
+// referenceShard (or of the first shard if empty). If skipNonMaster is
+// set, only the shard masters are compared, and not their replicas.
+func (wr *Wrangler) ValidateSchemaKeyspace(ctx context.Context, keyspace, referenceShard string, excludeTables []string, includeViews, skipNonMaster bool) error {
 	// find all the shards
 	shards, err := wr.ts.GetShardNames(keyspace)
 	if err != nil {
@@ -114,17 +178,22 @@ func (wr *Wrangler) ValidateSchemaKeyspace(ctx context.Context, keyspace string,
 		return fmt.Errorf("No shards in keyspace %v", keyspace)
 	}
 	sort.Strings(shards)
-	if len(shards) == 1 {
+	if referenceShard == "" {
+		referenceShard = shards[0]
+	} else if i := sort.SearchStrings(shards, referenceShard); i == len(shards) || shards[i] != referenceShard {
+		return fmt.Errorf("Reference shard %v is not in keyspace %v", referenceShard, keyspace)
+	}
+	if len(shards) == 1 && !skipNonMaster {
 		return wr.ValidateSchemaShard(ctx, keyspace, shards[0], excludeTables, includeViews)
 	}
 
-	// find the reference schema using the first shard's master
-	si, err := wr.ts.GetShard(keyspace, shards[0])
+	// find the reference schema using the reference shard's master
+	si, err := wr.ts.GetShard(keyspace, referenceShard)
 	if err != nil {
 		return err
 	}
 	if si.MasterAlias.Uid == topo.NO_TABLET {
-		return fmt.Errorf("No master in shard %v/%v", keyspace, shards[0])
+		return fmt.Errorf("No master in shard %v/%v", keyspace, referenceShard)
 	}
 	referenceAlias := si.MasterAlias
 	log.Infof("Gathering schema for reference master %v", referenceAlias)
@@ -135,54 +204,70 @@ func (wr *Wrangler) ValidateSchemaKeyspace(ctx context.Context, keyspace string,
 
 	// then diff with all other tablets everywhere
 	er := concurrency.AllErrorRecorder{}
-	wg := sync.WaitGroup{}
-
-	// first diff the slaves in the reference shard 0
-	aliases, err := topo.FindAllTabletAliasesInShard(ctx, wr.ts, keyspace, shards[0])
-	if err != nil {
-		return err
-	}
-
-	for _, alias := range aliases {
-		if alias == si.MasterAlias {
-			continue
-		}
-
-		wg.Add(1)
-		go wr.diffSchema(ctx, referenceSchema, referenceAlias, alias, excludeTables, includeViews, &wg, &er)
-	}
-
-	// then diffs all tablets in the other shards
-	for _, shard := range shards[1:] {
+	var tablets []*topo.TabletInfo
+	for _, shard := range shards {
 		si, err := wr.ts.GetShard(keyspace, shard)
 		if err != nil {
 			er.RecordError(err)
 			continue
 		}
-
 		if si.MasterAlias.Uid == topo.NO_TABLET {
 			er.RecordError(fmt.Errorf("No master in shard %v/%v", keyspace, shard))
 			continue
 		}
 
-		aliases, err := topo.FindAllTabletAliasesInShard(ctx, wr.ts, keyspace, shard)
-		if err != nil {
-			er.RecordError(err)
+		if skipNonMaster {
+			ti, err := wr.ts.GetTablet(si.MasterAlias)
+			if err != nil {
+				er.RecordError(err)
+				continue
+			}
+			tablets = append(tablets, ti)
 			continue
 		}
 
-		for _, alias := range aliases {
-			wg.Add(1)
-			go wr.diffSchema(ctx, referenceSchema, referenceAlias, alias, excludeTables, includeViews, &wg, &er)
+		shardTablets, err := wr.GetTabletsInShard(ctx, keyspace, shard)
+		switch err {
+		case nil:
+			// keep going
+		case topo.ErrPartialResult:
+			er.RecordError(fmt.Errorf("Cannot read all tablets in shard %v/%v", keyspace, shard))
+		default:
+			er.RecordError(err)
+			continue
 		}
+		tablets = append(tablets, shardTablets...)
 	}
-	wg.Wait()
+
+	br := wr.RunOnTablets(ctx, "ValidateSchemaKeyspace", tablets, DefaultBulkConcurrency, DefaultBulkTabletTimeout, wr.diffSchemaAction(referenceSchema, referenceAlias, excludeTables, includeViews, &er))
+	er.RecordError(br.Error())
 	if er.HasErrors() {
 		return fmt.Errorf("Schema diffs:\n%v", er.Error().Error())
 	}
 	return nil
 }
 
+// ReloadSchemaShard asks all the tablets in a shard to reload their
+// schema, and returns the result for each tablet.
+func (wr *Wrangler) ReloadSchemaShard(ctx context.Context, keyspace, shard string, concurrency int) (*BulkResult, error) {
+	tablets, err := wr.GetTabletsInShard(ctx, keyspace, shard)
+	switch err {
+	case nil:
+		// keep going
+	case topo.ErrPartialResult:
+		wr.Logger().Warningf("ReloadSchemaShard: got partial result for shard %v/%v, may not reload all tablets", keyspace, shard)
+	default:
+		return nil, err
+	}
+
+	return wr.RunOnTablets(ctx, "ReloadSchema", tablets, concurrency, DefaultBulkTabletTimeout, func(ctx context.Context, ti *topo.TabletInfo) error {
+		if !ti.IsRunningQueryService() {
+			return ErrTabletSkipped
+		}
+		return wr.tmc.ReloadSchema(ctx, ti)
+	}), nil
+}
+
 // PreflightSchema will try a schema change on the remote tablet.
 func (wr *Wrangler) PreflightSchema(ctx context.Context, tabletAlias topo.TabletAlias, change string) (*myproto.SchemaChangeResult, error) {
 	ti, err := wr.ts.GetTablet(tabletAlias)
@@ -429,7 +514,9 @@ func (wr *Wrangler) applySchemaShardComplex(ctx context.Context, statusArray []*
 // first we will validate the Preflight works the same on all shard masters
 // and fail if not (unless force is specified)
 // if simple, we just do it on all masters.
-// if complex, we do the shell game in parallel on all shards
+// if complex, we do the shell game on all shards.
+// Shards are done one at a time, and we stop as soon as one shard doesn't
+// end up with the schema predicted by the preflight.
 func (wr *Wrangler) ApplySchemaKeyspace(ctx context.Context, keyspace string, change string, simple, force bool, waitSlaveTimeout time.Duration) (*myproto.SchemaChangeResult, error) {
 	actionNode := actionnode.ApplySchemaKeyspace(change, simple)
 	lockPath, err := wr.lockKeyspace(ctx, keyspace, actionNode)
@@ -453,7 +540,18 @@ func (wr *Wrangler) applySchemaKeyspace(ctx context.Context, keyspace string, ch
 	}
 	if len(shards) == 1 {
 		log.Infof("Only one shard in keyspace %v, using ApplySchemaShard", keyspace)
-		return wr.ApplySchemaShard(ctx, keyspace, shards[0], change, topo.TabletAlias{}, simple, force, waitSlaveTimeout)
+		scr, err := wr.ApplySchemaShard(ctx, keyspace, shards[0], change, topo.TabletAlias{}, simple, force, waitSlaveTimeout)
+		if err != nil {
+			return nil, err
+		}
+		shardInfo, err := wr.ts.GetShard(keyspace, shards[0])
+		if err != nil {
+			return nil, err
+		}
+		if err := wr.checkAppliedSchemaShard(ctx, shardInfo, scr.AfterSchema, simple, waitSlaveTimeout); err != nil {
+			return nil, fmt.Errorf("Schema change on shard %v/%v did not produce the expected schema: %v", keyspace, shards[0], err)
+		}
+		return scr, nil
 	}
 
 	// Get schema on all shard masters in parallel
@@ -514,40 +612,85 @@ func (wr *Wrangler) applySchemaKeyspace(ctx context.Context, keyspace string, ch
 		return nil, err
 	}
 
-	// for each shard, apply the change
-	log.Infof("Applying change on all shards")
-	var applyErr error
+	// for each shard, apply the change, one shard at a time. We then
+	// check all tablets in that shard have the expected schema before
+	// moving on to the next shard, and stop on the first divergence.
 	for i, shard := range shards {
-		wg.Add(1)
-		go func(i int, shard string) {
-			defer wg.Done()
+		log.Infof("Applying change on shard %v/%v", keyspace, shard)
+		if _, err := wr.lockAndApplySchemaShard(ctx, shardInfos[i], preflight, keyspace, shard, shardInfos[i].MasterAlias, change, topo.TabletAlias{}, simple, force, waitSlaveTimeout); err != nil {
+			return nil, fmt.Errorf("Applying schema change on shard %v/%v failed, stopping: %v", keyspace, shard, err)
+		}
 
-			_, err := wr.lockAndApplySchemaShard(ctx, shardInfos[i], preflight, keyspace, shard, shardInfos[i].MasterAlias, change, topo.TabletAlias{}, simple, force, waitSlaveTimeout)
-			if err != nil {
-				mu.Lock()
-				applyErr = err
-				mu.Unlock()
-				return
-			}
-		}(i, shard)
-	}
-	wg.Wait()
-	if applyErr != nil {
-		return nil, applyErr
+		if err := wr.checkAppliedSchemaShard(ctx, shardInfos[i], preflight.AfterSchema, simple, waitSlaveTimeout); err != nil {
+			return nil, fmt.Errorf("Schema change on shard %v/%v did not produce the expected schema, stopping: %v", keyspace, shard, err)
+		}
 	}
 
 	return &myproto.SchemaChangeResult{BeforeSchema: preflight.BeforeSchema, AfterSchema: preflight.AfterSchema}, nil
 }
 
+// checkAppliedSchemaShard makes sure all the tablets of a shard the
+// schema change was applied to have the expected schema. In complex
+// mode, the master is not changed.
+func (wr *Wrangler) checkAppliedSchemaShard(ctx context.Context, si *topo.ShardInfo, expected *myproto.SchemaDefinition, simple bool, waitSlaveTimeout time.Duration) error {
+	skipAlias := topo.TabletAlias{}
+	if !simple {
+		skipAlias = si.MasterAlias
+	}
+	return wr.checkSchemaShard(ctx, si.Keyspace(), si.ShardName(), expected, skipAlias, waitSlaveTimeout)
+}
+
+// checkSchemaShard makes sure all tablets in a shard (but skipAlias)
+// end up with the expected schema. Since the change may still be
+// replicating, each tablet is polled until it matches or waitTime expires.
+func (wr *Wrangler) checkSchemaShard(ctx context.Context, keyspace, shard string, expected *myproto.SchemaDefinition, skipAlias topo.TabletAlias, waitTime time.Duration) error {
+	tablets, err := wr.GetTabletsInShard(ctx, keyspace, shard)
+	if err != nil {
+		return err
+	}
+
+	br := wr.RunOnTablets(ctx, "CheckSchema", tablets, DefaultBulkConcurrency, waitTime, func(ctx context.Context, ti *topo.TabletInfo) error {
+		if ti.Alias == skipAlias || ti.Type == topo.TYPE_LAG || !ti.IsInReplicationGraph() {
+			// same tablets as the ones skipped by applySchemaShard
+			return ErrTabletSkipped
+		}
+
+		for {
+			sd, err := wr.tmc.GetSchema(ctx, ti, nil, nil, false)
+			if err != nil {
+				return err
+			}
+			diffs := myproto.DiffSchemaToArray("expected", expected, ti.Alias.String(), sd)
+			if len(diffs) == 0 {
+				return nil
+			}
+			if waitTime <= 0 {
+				return fmt.Errorf("schema differs: %v", strings.Join(diffs, "\n"))
+			}
+
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("schema still differs after %v: %v", waitTime, strings.Join(diffs, "\n"))
+			case <-time.After(time.Second):
+			}
+		}
+	})
+	return br.Error()
+}
+
 // CopySchemaShard copies the schema from a source tablet to the
 // specified shard.  The schema is applied directly on the master of
 // the destination shard, and is propogated to the replicas through
-// binlogs.
-func (wr *Wrangler) CopySchemaShard(ctx context.Context, srcTabletAlias topo.TabletAlias, tables, excludeTables []string, includeViews bool, keyspace, shard string) error {
+// binlogs. If stripAutoIncrement is set, the AUTO_INCREMENT table
+// option of the source tables is not copied.
+func (wr *Wrangler) CopySchemaShard(ctx context.Context, srcTabletAlias topo.TabletAlias, tables, excludeTables []string, includeViews, stripAutoIncrement bool, keyspace, shard string) error {
 	sd, err := wr.GetSchema(ctx, srcTabletAlias, tables, excludeTables, includeViews)
 	if err != nil {
 		return err
 	}
+	if stripAutoIncrement {
+		sd = sd.StripAutoIncrement()
+	}
 	shardInfo, err := wr.ts.GetShard(keyspace, shard)
 	if err != nil {
 		return err
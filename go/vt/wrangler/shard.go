@@ -66,7 +66,7 @@ func (wr *Wrangler) updateShardCellsAndMaster(ctx context.Context, si *topo.Shar
 		if !si.MasterAlias.IsZero() && !force {
 			return wr.unlockShard(ctx, keyspace, shard, actionNode, lockPath, fmt.Errorf("creating this tablet would override old master %v in shard %v/%v", si.MasterAlias, keyspace, shard))
 		}
-		si.MasterAlias = tabletAlias
+		si.SetMaster(tabletAlias)
 		wasUpdated = true
 	}
 
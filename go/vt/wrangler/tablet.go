@@ -6,6 +6,7 @@ package wrangler
 
 import (
 	"fmt"
+	"time"
 
 	mproto "github.com/youtube/vitess/go/mysql/proto"
 	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
@@ -56,6 +57,15 @@ func (wr *Wrangler) InitTablet(ctx context.Context, tablet *topo.Tablet, force,
 		if err := wr.updateShardCellsAndMaster(ctx, si, tablet.Alias, tablet.Type, force); err != nil {
 			return err
 		}
+
+		// a master is stamped with the master term it was set in
+		if tablet.Type == topo.TYPE_MASTER {
+			si, err = wr.ts.GetShard(tablet.Keyspace, tablet.Shard)
+			if err != nil {
+				return err
+			}
+			tablet.MasterTerm = si.MasterTerm
+		}
 	}
 
 	err := topo.CreateTablet(ctx, wr.ts, tablet)
@@ -230,6 +240,98 @@ func (wr *Wrangler) ChangeTypeNoRebuild(ctx context.Context, tabletAlias topo.Ta
 
 }
 
+// DrainTablet takes a serving tablet out of service gracefully:
+// - the tablet is first removed from the EndPoints of its type in the
+//   serving graph, so clients stop sending it new queries.
+// - we then wait for lameDuckPeriod, while the tablet keeps serving,
+//   so in-flight queries can finish and clients can notice the
+//   serving graph change.
+// - the tablet type is then changed to drainedType. This stops the
+//   query service on the tablet: new queries are rejected with a
+//   retryable error, and existing transactions and queries are
+//   waited on (bounded by the transaction and query timeouts).
+// - the shard serving graph is rebuilt for the tablet cell.
+// When DrainTablet returns without error, the tablet is fully drained.
+// It holds the shard lock all along, so it doesn't race with reparents
+// and the other type changes.
+func (wr *Wrangler) DrainTablet(ctx context.Context, tabletAlias topo.TabletAlias, drainedType topo.TabletType, lameDuckPeriod time.Duration) error {
+	ti, err := wr.ts.GetTablet(tabletAlias)
+	if err != nil {
+		return err
+	}
+
+	actionNode := actionnode.DrainTablet(tabletAlias)
+	lockPath, err := wr.lockShard(ctx, ti.Keyspace, ti.Shard, actionNode)
+	if err != nil {
+		return err
+	}
+
+	err = wr.drainTablet(ctx, tabletAlias, drainedType, lameDuckPeriod)
+	return wr.unlockShard(ctx, ti.Keyspace, ti.Shard, actionNode, lockPath, err)
+}
+
+// drainTablet does the work of DrainTablet, with the shard lock held.
+func (wr *Wrangler) drainTablet(ctx context.Context, tabletAlias topo.TabletAlias, drainedType topo.TabletType, lameDuckPeriod time.Duration) error {
+	// read the tablet again with the lock
+	ti, err := wr.ts.GetTablet(tabletAlias)
+	if err != nil {
+		return err
+	}
+	if ti.Type == topo.TYPE_MASTER {
+		return fmt.Errorf("cannot drain master tablet %v, reparent the shard first", tabletAlias)
+	}
+	if !ti.IsInServingGraph() {
+		wr.Logger().Infof("tablet %v is of type %v, not serving, nothing to drain", tabletAlias, ti.Type)
+		return nil
+	}
+	if topo.IsInServingGraph(drainedType) {
+		return fmt.Errorf("cannot drain tablet %v to serving type %v", tabletAlias, drainedType)
+	}
+	if err := topo.CheckTypeChange(ti.Type, drainedType); err != nil {
+		return fmt.Errorf("cannot drain tablet %v: %v", tabletAlias, err)
+	}
+
+	// remove the tablet from the serving graph
+	addrs, err := wr.ts.GetEndPoints(ti.Alias.Cell, ti.Keyspace, ti.Shard, ti.Type)
+	switch err {
+	case nil:
+		newAddrs := topo.NewEndPoints()
+		for _, ep := range addrs.Entries {
+			if ep.Uid != ti.Alias.Uid {
+				newAddrs.Entries = append(newAddrs.Entries, ep)
+			}
+		}
+		if len(newAddrs.Entries) != len(addrs.Entries) {
+			wr.Logger().Infof("removing tablet %v from the serving graph", tabletAlias)
+			if err := topo.UpdateEndPoints(ctx, wr.ts, ti.Alias.Cell, ti.Keyspace, ti.Shard, ti.Type, newAddrs); err != nil {
+				return fmt.Errorf("cannot remove tablet %v from the serving graph: %v", tabletAlias, err)
+			}
+		}
+	case topo.ErrNoNode:
+		// not in the serving graph already
+	default:
+		return fmt.Errorf("cannot read serving graph for tablet %v: %v", tabletAlias, err)
+	}
+
+	// lame duck period, the tablet keeps serving
+	if lameDuckPeriod > 0 {
+		wr.Logger().Infof("waiting %v for in-flight queries on tablet %v", lameDuckPeriod, tabletAlias)
+		select {
+		case <-time.After(lameDuckPeriod):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// and stop the query service, waiting for existing
+	// queries and transactions to finish.
+	if err := wr.changeTypeInternal(ctx, tabletAlias, drainedType); err != nil {
+		return err
+	}
+	wr.Logger().Infof("tablet %v is drained, now of type %v", tabletAlias, drainedType)
+	return nil
+}
+
 // same as ChangeType, but assume we already have the shard lock,
 // and do not have the option to force anything.
 func (wr *Wrangler) changeTypeInternal(ctx context.Context, tabletAlias topo.TabletAlias, dbType topo.TabletType) error {
@@ -268,6 +370,41 @@ func (wr *Wrangler) DeleteTablet(tabletAlias topo.TabletAlias) error {
 	return wr.TopoServer().DeleteTablet(tabletAlias)
 }
 
+// SetTabletTags sets the tags of a tablet. Tags with an empty value
+// are removed, the other tags of the tablet are kept. As the tags are
+// published in the serving graph, the tablet reloads its record, and
+// the serving graph of its shard is rebuilt in its cell.
+func (wr *Wrangler) SetTabletTags(ctx context.Context, tabletAlias topo.TabletAlias, tags map[string]string) error {
+	if err := wr.ts.UpdateTabletFields(tabletAlias, func(tablet *topo.Tablet) error {
+		for k, v := range tags {
+			if v == "" {
+				delete(tablet.Tags, k)
+				continue
+			}
+			if tablet.Tags == nil {
+				tablet.Tags = make(map[string]string)
+			}
+			tablet.Tags[k] = v
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	ti, err := wr.ts.GetTablet(tabletAlias)
+	if err != nil {
+		return err
+	}
+	if !ti.IsInServingGraph() {
+		return nil
+	}
+	if err := wr.tmc.RefreshState(ctx, ti); err != nil {
+		wr.Logger().Warningf("RefreshState(%v) failed: %v", tabletAlias, err)
+	}
+	_, err = wr.RebuildShardGraph(ctx, ti.Keyspace, ti.Shard, []string{ti.Alias.Cell})
+	return err
+}
+
 // ExecuteFetchAsDba executes a query remotely using the DBA pool
 func (wr *Wrangler) ExecuteFetchAsDba(ctx context.Context, tabletAlias topo.TabletAlias, query string, maxRows int, wantFields, disableBinlogs bool) (*mproto.QueryResult, error) {
 	ti, err := wr.ts.GetTablet(tabletAlias)
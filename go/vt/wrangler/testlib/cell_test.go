@@ -0,0 +1,72 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testlib
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+	"golang.org/x/net/context"
+)
+
+func TestAddRemoveCell(t *testing.T) {
+	ctx := context.Background()
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+
+	master := NewFakeTablet(t, wr, "cell1", 1, topo.TYPE_MASTER)
+
+	// add the second cell, twice to check it's idempotent
+	for i := 0; i < 2; i++ {
+		if err := wr.AddCell(ctx, "cell2", nil); err != nil {
+			t.Fatalf("AddCell failed: %v", err)
+		}
+	}
+	cells, err := ts.GetKnownCells()
+	if err != nil {
+		t.Fatalf("GetKnownCells failed: %v", err)
+	}
+	if !topo.InCellList("cell2", cells) {
+		t.Fatalf("cell2 not in known cells: %v", cells)
+	}
+
+	replica := NewFakeTablet(t, wr, "cell2", 2, topo.TYPE_REPLICA,
+		TabletParent(master.Tablet.Alias))
+	if _, err := wr.RebuildShardGraph(ctx, "test_keyspace", "0", nil); err != nil {
+		t.Fatalf("RebuildShardGraph failed: %v", err)
+	}
+
+	// the serving replica prevents the removal
+	if err := wr.RemoveCell(ctx, "cell2", false); err == nil || !strings.Contains(err.Error(), "serving tablets remain") {
+		t.Fatalf("RemoveCell with a serving tablet returned: %v", err)
+	}
+
+	// remove it, and then the cell
+	if err := wr.Scrap(ctx, replica.Tablet.Alias, true, false); err != nil {
+		t.Fatalf("Scrap failed: %v", err)
+	}
+	if err := wr.DeleteTablet(replica.Tablet.Alias); err != nil {
+		t.Fatalf("DeleteTablet failed: %v", err)
+	}
+	if err := wr.RemoveCell(ctx, "cell2", false); err != nil {
+		t.Fatalf("RemoveCell failed: %v", err)
+	}
+	si, err := ts.GetShard("test_keyspace", "0")
+	if err != nil {
+		t.Fatalf("GetShard failed: %v", err)
+	}
+	if topo.InCellList("cell2", si.Cells) {
+		t.Errorf("cell2 still in shard cells: %v", si.Cells)
+	}
+	if _, err := ts.GetEndPoints("cell2", "test_keyspace", "0", topo.TYPE_REPLICA); err != topo.ErrNoNode {
+		t.Errorf("EndPoints still in cell2: %v", err)
+	}
+}
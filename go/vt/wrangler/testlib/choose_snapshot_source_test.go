@@ -0,0 +1,86 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testlib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	_ "github.com/youtube/vitess/go/vt/tabletmanager/gorpctmclient"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+	"golang.org/x/net/context"
+)
+
+func TestChooseSnapshotSource(t *testing.T) {
+	ctx := context.Background()
+	ts := zktopo.NewTestServer(t, []string{"cell1", "cell2"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+
+	master := NewFakeTablet(t, wr, "cell1", 1, topo.TYPE_MASTER)
+	replica := NewFakeTablet(t, wr, "cell1", 2, topo.TYPE_REPLICA,
+		TabletParent(master.Tablet.Alias))
+	rdonlyOtherCell := NewFakeTablet(t, wr, "cell2", 3, topo.TYPE_RDONLY,
+		TabletParent(master.Tablet.Alias))
+	rdonlyLagging := NewFakeTablet(t, wr, "cell1", 4, topo.TYPE_RDONLY,
+		TabletParent(master.Tablet.Alias))
+	rdonly := NewFakeTablet(t, wr, "cell1", 5, topo.TYPE_RDONLY,
+		TabletParent(master.Tablet.Alias))
+	for _, ft := range []*FakeTablet{master, replica, rdonlyOtherCell, rdonlyLagging, rdonly} {
+		ft.FakeMysqlDaemon.CurrentSlaveStatus = &myproto.ReplicationStatus{
+			SlaveIORunning:  true,
+			SlaveSQLRunning: true,
+		}
+		ft.StartActionLoop(t, wr)
+		defer ft.StopActionLoop(t)
+	}
+	rdonlyLagging.FakeMysqlDaemon.CurrentSlaveStatus.SecondsBehindMaster = 10
+
+	checkSource := func(cell string, want *FakeTablet) {
+		got, err := wr.ChooseSnapshotSource(ctx, "test_keyspace", "0", cell, nil)
+		if err != nil {
+			t.Fatalf("ChooseSnapshotSource(%v) failed: %v", cell, err)
+		}
+		if got != want.Tablet.Alias {
+			t.Errorf("ChooseSnapshotSource(%v) = %v, want %v", cell, got, want.Tablet.Alias)
+		}
+	}
+
+	// the rdonly in the same cell with no lag wins
+	checkSource("cell1", rdonly)
+
+	// unless we want tags it doesn't have
+	tags := map[string]string{"rack": "r2"}
+	if err := wr.SetTabletTags(ctx, rdonlyOtherCell.Tablet.Alias, tags); err != nil {
+		t.Fatalf("SetTabletTags failed: %v", err)
+	}
+	if got, err := wr.ChooseSnapshotSource(ctx, "test_keyspace", "0", "cell1", tags); err != nil || got != rdonlyOtherCell.Tablet.Alias {
+		t.Errorf("ChooseSnapshotSource(cell1, %v) = (%v, %v), want %v", tags, got, err, rdonlyOtherCell.Tablet.Alias)
+	}
+
+	// then the lagging rdonly in the same cell
+	rdonly.FakeMysqlDaemon.CurrentSlaveStatus.SlaveSQLRunning = false
+	checkSource("cell1", rdonlyLagging)
+
+	// an rdonly in another cell is still better than a replica
+	if err := wr.ChangeType(ctx, rdonlyLagging.Tablet.Alias, topo.TYPE_BACKUP, false); err != nil {
+		t.Fatalf("ChangeType failed: %v", err)
+	}
+	checkSource("cell1", rdonlyOtherCell)
+
+	// and the replica is the last resort
+	rdonlyOtherCell.FakeMysqlDaemon.CurrentSlaveStatus.SlaveIORunning = false
+	checkSource("cell1", replica)
+
+	// without any candidate, it fails
+	replica.FakeMysqlDaemon.CurrentSlaveStatus.SlaveIORunning = false
+	if _, err := wr.ChooseSnapshotSource(ctx, "test_keyspace", "0", "cell1", nil); err == nil {
+		t.Errorf("ChooseSnapshotSource should have failed without candidates")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testlib
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+)
+
+// checkSnapshotTablet checks the type, tag and replication of a
+// rdonly tablet used by a consistent snapshot.
+func checkSnapshotTablet(t *testing.T, ts topo.Server, rdonly *FakeTablet, tabletType topo.TabletType, tag string) {
+	ti, err := ts.GetTablet(rdonly.Tablet.Alias)
+	if err != nil {
+		t.Fatalf("GetTablet failed: %v", err)
+	}
+	if ti.Type != tabletType {
+		t.Errorf("tablet %v is %v, want %v", ti.Alias, ti.Type, tabletType)
+	}
+	if got := ti.Tags[wrangler.ConsistentSnapshotTag]; got != tag {
+		t.Errorf("tablet %v has tag %q, want %q", ti.Alias, got, tag)
+	}
+	if replicating := tabletType != topo.TYPE_CHECKER; rdonly.FakeMysqlDaemon.Replicating != replicating {
+		t.Errorf("tablet %v replicating is %v, want %v", ti.Alias, rdonly.FakeMysqlDaemon.Replicating, replicating)
+	}
+}
+
+func TestConsistentSnapshot(t *testing.T) {
+	ctx := context.Background()
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+
+	var rdonlys []*FakeTablet
+	for i, shard := range []string{"-80", "80-"} {
+		master := NewFakeTablet(t, wr, "cell1", uint32(10*i), topo.TYPE_MASTER,
+			TabletKeyspaceShard(t, "test_keyspace", shard))
+		rdonly := NewFakeTablet(t, wr, "cell1", uint32(10*i+1), topo.TYPE_RDONLY,
+			TabletKeyspaceShard(t, "test_keyspace", shard),
+			TabletParent(master.Tablet.Alias))
+		for _, ft := range []*FakeTablet{master, rdonly} {
+			ft.StartActionLoop(t, wr)
+			defer ft.StopActionLoop(t)
+		}
+		master.FakeMysqlDaemon.CurrentMasterPosition = myproto.MustParseReplicationPosition("MariaDB", "0-1-5")
+		replicatesFrom(rdonly, master.Tablet)
+		rdonly.FakeMysqlDaemon.Replicating = true
+		rdonly.FakeMysqlDaemon.CurrentSlaveStatus.Position = myproto.MustParseReplicationPosition("MariaDB", "0-1-3")
+		rdonlys = append(rdonlys, rdonly)
+	}
+
+	// The second rdonly can't stop at the position of its master,
+	// the first one is released.
+	rdonlys[1].FakeMysqlDaemon.CurrentSlaveStatus.Position = myproto.MustParseReplicationPosition("MariaDB", "0-1-7")
+	if _, err := wr.CreateConsistentSnapshot(ctx, "test_keyspace", "snap", time.Second); err == nil || !strings.Contains(err.Error(), "StartSlaveUntilAfter") {
+		t.Errorf("CreateConsistentSnapshot with a lagging rdonly returned %v", err)
+	}
+	for _, rdonly := range rdonlys {
+		checkSnapshotTablet(t, ts, rdonly, topo.TYPE_RDONLY, "")
+	}
+	for _, rdonly := range rdonlys {
+		rdonly.FakeMysqlDaemon.CurrentSlaveStatus.Position = myproto.MustParseReplicationPosition("MariaDB", "0-1-3")
+	}
+
+	// The rdonlys stop exactly at the positions of their masters.
+	snapshot, err := wr.CreateConsistentSnapshot(ctx, "test_keyspace", "snap", time.Second)
+	if err != nil {
+		t.Fatalf("CreateConsistentSnapshot failed: %v", err)
+	}
+	for i, shard := range []string{"-80", "80-"} {
+		s, ok := snapshot.Shards[shard]
+		if !ok {
+			t.Errorf("snapshot has no shard %v: %v", shard, snapshot.Shards)
+			continue
+		}
+		if s.TabletAlias != rdonlys[i].Tablet.Alias || s.Position.String() != "0-1-5" {
+			t.Errorf("snapshot shard %v is %v at %v, want %v at 0-1-5", shard, s.TabletAlias, s.Position, rdonlys[i].Tablet.Alias)
+		}
+		if got := rdonlys[i].FakeMysqlDaemon.CurrentSlaveStatus.Position.String(); got != "0-1-5" {
+			t.Errorf("tablet %v stopped at %v, want 0-1-5", rdonlys[i].Tablet.Alias, got)
+		}
+		checkSnapshotTablet(t, ts, rdonlys[i], topo.TYPE_CHECKER, "snap")
+	}
+	if _, err := wr.CreateConsistentSnapshot(ctx, "test_keyspace", "snap", time.Second); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("CreateConsistentSnapshot of an existing snapshot returned %v", err)
+	}
+
+	got, err := wr.GetConsistentSnapshot(ctx, "test_keyspace", "snap")
+	if err != nil {
+		t.Fatalf("GetConsistentSnapshot failed: %v", err)
+	}
+	if len(got.Shards) != 2 || got.Shards["80-"].TabletAlias != rdonlys[1].Tablet.Alias {
+		t.Errorf("GetConsistentSnapshot returned %v, want %v", got.Shards, snapshot.Shards)
+	}
+
+	if err := wr.ReleaseConsistentSnapshot(ctx, "test_keyspace", "snap"); err != nil {
+		t.Fatalf("ReleaseConsistentSnapshot failed: %v", err)
+	}
+	for _, rdonly := range rdonlys {
+		checkSnapshotTablet(t, ts, rdonly, topo.TYPE_RDONLY, "")
+	}
+	if err := wr.ReleaseConsistentSnapshot(ctx, "test_keyspace", "snap"); err == nil || !strings.Contains(err.Error(), "no consistent snapshot") {
+		t.Errorf("ReleaseConsistentSnapshot of a released snapshot returned %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testlib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+	"github.com/youtube/vitess/go/vt/tabletmanager/actionnode"
+	_ "github.com/youtube/vitess/go/vt/tabletmanager/gorpctmclient"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+	"golang.org/x/net/context"
+)
+
+func TestDrainTablet(t *testing.T) {
+	ctx := context.Background()
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+
+	master := NewFakeTablet(t, wr, "cell1", 1, topo.TYPE_MASTER)
+	replica1 := NewFakeTablet(t, wr, "cell1", 2, topo.TYPE_REPLICA,
+		TabletParent(master.Tablet.Alias))
+	replica2 := NewFakeTablet(t, wr, "cell1", 3, topo.TYPE_REPLICA,
+		TabletParent(master.Tablet.Alias))
+	for _, ft := range []*FakeTablet{master, replica1, replica2} {
+		ft.StartActionLoop(t, wr)
+		defer ft.StopActionLoop(t)
+	}
+	if _, err := wr.RebuildShardGraph(ctx, "test_keyspace", "0", nil); err != nil {
+		t.Fatalf("RebuildShardGraph failed: %v", err)
+	}
+
+	// the master cannot be drained
+	if err := wr.DrainTablet(ctx, master.Tablet.Alias, topo.TYPE_SPARE, 0); err == nil {
+		t.Fatalf("DrainTablet(master) should have failed")
+	}
+
+	// cannot drain to a serving type
+	if err := wr.DrainTablet(ctx, replica1.Tablet.Alias, topo.TYPE_RDONLY, 0); err == nil {
+		t.Fatalf("DrainTablet(rdonly) should have failed")
+	}
+
+	// the drain waits for the shard lock, like the reparents
+	actionNode := actionnode.UpdateShard()
+	lockPath, err := actionNode.LockShard(ctx, ts, "test_keyspace", "0")
+	if err != nil {
+		t.Fatalf("LockShard failed: %v", err)
+	}
+	if err := wr.DrainTablet(ctx, replica1.Tablet.Alias, topo.TYPE_SPARE, 0); err == nil {
+		t.Fatalf("DrainTablet with the shard locked should have failed")
+	}
+	if err := actionNode.UnlockShard(ctx, ts, "test_keyspace", "0", lockPath, nil); err != nil {
+		t.Fatalf("UnlockShard failed: %v", err)
+	}
+
+	if err := wr.DrainTablet(ctx, replica1.Tablet.Alias, topo.TYPE_SPARE, 10*time.Millisecond); err != nil {
+		t.Fatalf("DrainTablet failed: %v", err)
+	}
+	ti, err := ts.GetTablet(replica1.Tablet.Alias)
+	if err != nil {
+		t.Fatalf("GetTablet failed: %v", err)
+	}
+	if ti.Type != topo.TYPE_SPARE {
+		t.Errorf("drained tablet has wrong type: %v", ti.Type)
+	}
+	addrs, err := ts.GetEndPoints("cell1", "test_keyspace", "0", topo.TYPE_REPLICA)
+	if err != nil {
+		t.Fatalf("GetEndPoints failed: %v", err)
+	}
+	if len(addrs.Entries) != 1 || addrs.Entries[0].Uid != replica2.Tablet.Alias.Uid {
+		t.Errorf("unexpected serving graph after drain: %v", addrs)
+	}
+
+	// draining again is a no-op
+	if err := wr.DrainTablet(ctx, replica1.Tablet.Alias, topo.TYPE_SPARE, 0); err != nil {
+		t.Errorf("second DrainTablet failed: %v", err)
+	}
+}
@@ -16,7 +16,7 @@ import (
 
 	"github.com/youtube/vitess/go/rpcplus"
 	"github.com/youtube/vitess/go/rpcwrap/bsonrpc"
-	"github.com/youtube/vitess/go/vt/mysqlctl"
+	"github.com/youtube/vitess/go/vt/mysqlctl/fakemysqldaemon"
 	"github.com/youtube/vitess/go/vt/tabletmanager"
 	"github.com/youtube/vitess/go/vt/tabletmanager/gorpctmserver"
 	"github.com/youtube/vitess/go/vt/topo"
@@ -35,7 +35,7 @@ import (
 type FakeTablet struct {
 	// Tablet and FakeMysqlDaemon are populated at NewFakeTablet time.
 	Tablet          *topo.Tablet
-	FakeMysqlDaemon *mysqlctl.FakeMysqlDaemon
+	FakeMysqlDaemon *fakemysqldaemon.FakeMysqlDaemon
 
 	// The following fields are created when we start the event loop for
 	// the tablet, and closed / cleared when we stop it.
@@ -100,7 +100,7 @@ func NewFakeTablet(t *testing.T, wr *wrangler.Wrangler, cell string, uid uint32,
 	}
 
 	// create a FakeMysqlDaemon with the right information by default
-	fakeMysqlDaemon := &mysqlctl.FakeMysqlDaemon{}
+	fakeMysqlDaemon := &fakemysqldaemon.FakeMysqlDaemon{}
 	if ok {
 		fakeMysqlDaemon.MasterAddr = fmt.Sprintf("%v.0.0.1:%v", 100+puid, 3300+puid)
 	}
@@ -0,0 +1,96 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testlib
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/tabletserver"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+)
+
+// checkFrozen checks the shard record, the serving graph, the
+// MySQL of the master and the row gc of the master agree on
+// the frozen state of the shard.
+func checkFrozen(t *testing.T, ts topo.Server, master *FakeTablet, frozen bool) {
+	si, err := ts.GetShard("test_keyspace", "0")
+	if err != nil {
+		t.Fatalf("GetShard failed: %v", err)
+	}
+	if si.ReadOnly != frozen {
+		t.Errorf("shard ReadOnly is %v, want %v", si.ReadOnly, frozen)
+	}
+	srvShard, err := ts.GetSrvShard("cell1", "test_keyspace", "0")
+	if err != nil {
+		t.Fatalf("GetSrvShard failed: %v", err)
+	}
+	if srvShard.ReadOnly != frozen {
+		t.Errorf("SrvShard ReadOnly is %v, want %v", srvShard.ReadOnly, frozen)
+	}
+	if master.FakeMysqlDaemon.ReadOnly != frozen {
+		t.Errorf("master MySQL read-only is %v, want %v", master.FakeMysqlDaemon.ReadOnly, frozen)
+	}
+	qsc := master.Agent.QueryServiceControl.(*tabletserver.TestQueryServiceControl)
+	if qsc.IsMaster == frozen {
+		t.Errorf("master row gc enabled is %v, want %v", qsc.IsMaster, !frozen)
+	}
+	if !qsc.IsHeartbeatWriter {
+		t.Errorf("the master stopped writing the heartbeat")
+	}
+}
+
+func TestFreezeShard(t *testing.T) {
+	ctx := context.Background()
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+
+	master := NewFakeTablet(t, wr, "cell1", 0, topo.TYPE_MASTER)
+	replica := NewFakeTablet(t, wr, "cell1", 1, topo.TYPE_REPLICA,
+		TabletParent(master.Tablet.Alias))
+	for _, ft := range []*FakeTablet{master, replica} {
+		ft.StartActionLoop(t, wr)
+		defer ft.StopActionLoop(t)
+	}
+	replicatesFrom(replica, master.Tablet)
+	if _, err := wr.RebuildShardGraph(ctx, "test_keyspace", "0", nil); err != nil {
+		t.Fatalf("RebuildShardGraph failed: %v", err)
+	}
+	checkFrozen(t, ts, master, false)
+
+	if err := wr.UnfreezeShard(ctx, "test_keyspace", "0"); err == nil || !strings.Contains(err.Error(), "is not frozen") {
+		t.Errorf("UnfreezeShard on a shard that isn't frozen returned %v", err)
+	}
+
+	if err := wr.FreezeShard(ctx, "test_keyspace", "0"); err != nil {
+		t.Fatalf("FreezeShard failed: %v", err)
+	}
+	checkFrozen(t, ts, master, true)
+
+	// Someone made the replica replicate from elsewhere while the
+	// shard was frozen, we don't unfreeze.
+	replica.FakeMysqlDaemon.CurrentSlaveStatus = &myproto.ReplicationStatus{
+		MasterHost: "1.2.3.4",
+		MasterPort: 3306,
+	}
+	if err := wr.UnfreezeShard(ctx, "test_keyspace", "0"); err == nil || !strings.Contains(err.Error(), "replicates from 1.2.3.4:3306") {
+		t.Errorf("UnfreezeShard with a stray replica returned %v", err)
+	}
+	checkFrozen(t, ts, master, true)
+
+	replicatesFrom(replica, master.Tablet)
+	if err := wr.UnfreezeShard(ctx, "test_keyspace", "0"); err != nil {
+		t.Fatalf("UnfreezeShard failed: %v", err)
+	}
+	checkFrozen(t, ts, master, false)
+}
@@ -0,0 +1,160 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testlib
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	mproto "github.com/youtube/vitess/go/mysql/proto"
+	"github.com/youtube/vitess/go/sqltypes"
+	"github.com/youtube/vitess/go/vt/binlog/binlogplayer"
+	"github.com/youtube/vitess/go/vt/dbconnpool"
+	"github.com/youtube/vitess/go/vt/logutil"
+	_ "github.com/youtube/vitess/go/vt/tabletmanager/gorpctmclient"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+	"golang.org/x/net/context"
+)
+
+// checkpointFactory returns connections that answer the filtered
+// replication transaction times query with the given times.
+func checkpointFactory(t *testing.T, now, txTimestamp string) func() (dbconnpool.PoolConnection, error) {
+	return func() (dbconnpool.PoolConnection, error) {
+		return &FakePoolConnection{
+			t: t,
+			ExpectedExecuteFetch: []ExpectedExecuteFetch{
+				ExpectedExecuteFetch{
+					Query: binlogplayer.QueryBlpTransactionTimes(),
+					QueryResult: &mproto.QueryResult{
+						Rows: [][]sqltypes.Value{{
+							sqltypes.MakeString([]byte(now)),
+							sqltypes.MakeString([]byte(txTimestamp)),
+						}},
+					},
+				},
+			},
+		}, nil
+	}
+}
+
+func TestMigrateServedTypesDryRun(t *testing.T) {
+	ctx := context.Background()
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+
+	sourceMaster := NewFakeTablet(t, wr, "cell1", 0,
+		topo.TYPE_MASTER, TabletKeyspaceShard(t, "ks", "0"))
+	sourceReplica := NewFakeTablet(t, wr, "cell1", 1,
+		topo.TYPE_REPLICA, TabletKeyspaceShard(t, "ks", "0"),
+		TabletParent(sourceMaster.Tablet.Alias))
+	dest1Master := NewFakeTablet(t, wr, "cell1", 10,
+		topo.TYPE_MASTER, TabletKeyspaceShard(t, "ks", "-80"))
+	dest1Replica := NewFakeTablet(t, wr, "cell1", 11,
+		topo.TYPE_REPLICA, TabletKeyspaceShard(t, "ks", "-80"),
+		TabletParent(dest1Master.Tablet.Alias))
+	dest2Master := NewFakeTablet(t, wr, "cell1", 20,
+		topo.TYPE_MASTER, TabletKeyspaceShard(t, "ks", "80-"))
+	for _, ft := range []*FakeTablet{sourceMaster, sourceReplica, dest1Master, dest1Replica, dest2Master} {
+		ft.StartActionLoop(t, wr)
+		defer ft.StopActionLoop(t)
+	}
+
+	// the destination shards replicate from the source shard
+	for _, shard := range []string{"-80", "80-"} {
+		si, err := ts.GetShard("ks", shard)
+		if err != nil {
+			t.Fatalf("GetShard(%v) failed: %v", shard, err)
+		}
+		si.SourceShards = []topo.SourceShard{{Uid: 0, Keyspace: "ks", Shard: "0"}}
+		if err := topo.UpdateShard(ctx, ts, si); err != nil {
+			t.Fatalf("UpdateShard(%v) failed: %v", shard, err)
+		}
+	}
+	if err := wr.RebuildKeyspaceGraph(ctx, "ks", nil); err != nil {
+		t.Fatalf("RebuildKeyspaceGraph failed: %v", err)
+	}
+	dest1Master.FakeMysqlDaemon.DbaConnectionFactory = checkpointFactory(t, "1000", "990")
+	dest2Master.FakeMysqlDaemon.DbaConnectionFactory = checkpointFactory(t, "1000", "0")
+
+	report, err := wr.MigrateServedTypesDryRun(ctx, "ks", "0", nil, topo.TYPE_REPLICA, false)
+	if err != nil {
+		t.Fatalf("MigrateServedTypesDryRun failed: %v", err)
+	}
+	want := &wrangler.MigrateServedTypesReport{
+		Keyspace:          "ks",
+		ServedType:        topo.TYPE_REPLICA,
+		SourceShards:      []string{"0"},
+		DestinationShards: []string{"-80", "80-"},
+		Partitions: map[string]*wrangler.PartitionChange{
+			"cell1": &wrangler.PartitionChange{
+				Before: []string{"0"},
+				After:  []string{"-80", "80-"},
+			},
+		},
+		Tablets: []*wrangler.TabletServingChange{
+			{Alias: sourceReplica.Tablet.Alias, Shard: "0", Serving: false},
+			{Alias: dest1Replica.Tablet.Alias, Shard: "-80", Serving: true},
+		},
+		FilteredReplicationLagSeconds: map[string]int64{
+			"-80": 10,
+			"80-": 0,
+		},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("MigrateServedTypesDryRun(replica) returned\n%#v, want\n%#v", report, want)
+	}
+
+	// nothing was changed
+	si, err := ts.GetShard("ks", "0")
+	if err != nil {
+		t.Fatalf("GetShard failed: %v", err)
+	}
+	if _, ok := si.ServedTypesMap[topo.TYPE_REPLICA]; !ok {
+		t.Errorf("the source shard doesn't serve replica anymore: %v", si.ServedTypesMap)
+	}
+
+	// the reverse migration is checked like MigrateServedTypes
+	if _, err := wr.MigrateServedTypesDryRun(ctx, "ks", "0", nil, topo.TYPE_REPLICA, true); err == nil {
+		t.Errorf("MigrateServedTypesDryRun(reverse) should have failed")
+	}
+
+	// move everything but the master to the destination shards, as
+	// MigrateServedTypes would
+	for _, shard := range []string{"0", "-80", "80-"} {
+		si, err := ts.GetShard("ks", shard)
+		if err != nil {
+			t.Fatalf("GetShard(%v) failed: %v", shard, err)
+		}
+		if si.ServedTypesMap == nil {
+			si.ServedTypesMap = make(map[topo.TabletType]*topo.ShardServedType)
+		}
+		for _, tt := range []topo.TabletType{topo.TYPE_REPLICA, topo.TYPE_RDONLY} {
+			if shard == "0" {
+				delete(si.ServedTypesMap, tt)
+			} else {
+				si.ServedTypesMap[tt] = &topo.ShardServedType{}
+			}
+		}
+		if err := topo.UpdateShard(ctx, ts, si); err != nil {
+			t.Fatalf("UpdateShard(%v) failed: %v", shard, err)
+		}
+	}
+
+	// a master migration waits for the filtered replication
+	report, err = wr.MigrateServedTypesDryRun(ctx, "ks", "-80", nil, topo.TYPE_MASTER, false)
+	if err != nil {
+		t.Fatalf("MigrateServedTypesDryRun failed: %v", err)
+	}
+	if report.EstimatedUnavailabilitySeconds != 10 {
+		t.Errorf("EstimatedUnavailabilitySeconds is %v, want 10", report.EstimatedUnavailabilitySeconds)
+	}
+	if len(report.Tablets) != 3 || report.Tablets[0].Alias != sourceMaster.Tablet.Alias || report.Tablets[0].Serving {
+		t.Errorf("unexpected tablet changes for a master migration: %v", report.Tablets)
+	}
+}
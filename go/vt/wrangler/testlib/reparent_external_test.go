@@ -6,6 +6,7 @@ package testlib
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -359,6 +360,59 @@ func testTabletExternallyReparentedFailedOldMaster(t *testing.T, fast bool) {
 	}
 }
 
+// TestTabletExternallyReparentedReadOnly makes sure a tablet whose
+// mysql is still read-only refuses to become the master.
+func TestTabletExternallyReparentedReadOnly(t *testing.T) {
+	testTabletExternallyReparentedReadOnly(t, false /* fast */)
+}
+
+func TestTabletExternallyReparentedReadOnlyFast(t *testing.T) {
+	testTabletExternallyReparentedReadOnly(t, true /* fast */)
+}
+
+func testTabletExternallyReparentedReadOnly(t *testing.T, fast bool) {
+	tabletmanager.SetReparentFlags(fast, time.Minute /* finalizeTimeout */)
+
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+
+	oldMaster := NewFakeTablet(t, wr, "cell1", 0, topo.TYPE_MASTER)
+	newMaster := NewFakeTablet(t, wr, "cell1", 1, topo.TYPE_REPLICA,
+		TabletParent(oldMaster.Tablet.Alias))
+
+	// The external tool didn't make the new master writable.
+	newMaster.FakeMysqlDaemon.MasterAddr = ""
+	newMaster.FakeMysqlDaemon.ReadOnly = true
+	newMaster.StartActionLoop(t, wr)
+	defer newMaster.StopActionLoop(t)
+
+	tmc := tmclient.NewTabletManagerClient()
+	ti, err := ts.GetTablet(newMaster.Tablet.Alias)
+	if err != nil {
+		t.Fatalf("GetTablet failed: %v", err)
+	}
+	want := "is read-only, it cannot be the master"
+	if err := tmc.TabletExternallyReparented(context.Background(), ti, ""); err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("TabletExternallyReparented(read-only replica) returned %v, want %v", err, want)
+	}
+
+	// Nothing changed in the topology.
+	si, err := ts.GetShard("test_keyspace", "0")
+	if err != nil {
+		t.Fatalf("GetShard failed: %v", err)
+	}
+	if si.MasterAlias != oldMaster.Tablet.Alias {
+		t.Errorf("shard master is %v, want %v", si.MasterAlias, oldMaster.Tablet.Alias)
+	}
+	tablet, err := ts.GetTablet(newMaster.Tablet.Alias)
+	if err != nil {
+		t.Fatalf("GetTablet(%v) failed: %v", newMaster.Tablet.Alias, err)
+	}
+	if tablet.Type != topo.TYPE_REPLICA {
+		t.Errorf("read-only tablet is %v, want %v", tablet.Type, topo.TYPE_REPLICA)
+	}
+}
+
 var externalReparents = make(map[string]chan struct{})
 
 // makeWaitID generates a unique externalID that can be passed to
@@ -0,0 +1,168 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testlib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+)
+
+// replicatesFrom makes the mysql of the tablet a slave of the
+// mysql of master. It must be called after StartActionLoop, which
+// changes the address of the tablets.
+func replicatesFrom(ft *FakeTablet, master *topo.Tablet) {
+	ft.FakeMysqlDaemon.CurrentSlaveStatus = &myproto.ReplicationStatus{
+		SlaveIORunning:  true,
+		SlaveSQLRunning: true,
+		MasterHost:      master.IPAddr,
+		MasterPort:      master.Portmap["mysql"],
+	}
+}
+
+func TestRepairReplicationGraph(t *testing.T) {
+	ctx := context.Background()
+	ts := zktopo.NewTestServer(t, []string{"cell1", "cell2"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+
+	// An external tool failed over from oldMaster to newMaster,
+	// and didn't tell us.
+	oldMaster := NewFakeTablet(t, wr, "cell1", 0, topo.TYPE_MASTER)
+	newMaster := NewFakeTablet(t, wr, "cell1", 1, topo.TYPE_REPLICA,
+		TabletParent(oldMaster.Tablet.Alias))
+	slave := NewFakeTablet(t, wr, "cell2", 2, topo.TYPE_REPLICA,
+		TabletParent(oldMaster.Tablet.Alias))
+	unlinked := NewFakeTablet(t, wr, "cell1", 3, topo.TYPE_RDONLY,
+		TabletParent(oldMaster.Tablet.Alias))
+	stray := NewFakeTablet(t, wr, "cell1", 4, topo.TYPE_REPLICA,
+		TabletParent(oldMaster.Tablet.Alias))
+	for _, ft := range []*FakeTablet{oldMaster, newMaster, slave, unlinked, stray} {
+		ft.StartActionLoop(t, wr)
+		defer ft.StopActionLoop(t)
+	}
+	replicatesFrom(oldMaster, newMaster.Tablet)
+	replicatesFrom(slave, newMaster.Tablet)
+	replicatesFrom(unlinked, newMaster.Tablet)
+	stray.FakeMysqlDaemon.CurrentSlaveStatus = &myproto.ReplicationStatus{
+		MasterHost: "1.2.3.4",
+		MasterPort: 3306,
+	}
+
+	// The replication graph misses a tablet, and has one that
+	// doesn't exist.
+	if err := topo.RemoveShardReplicationRecord(ts, "cell1", "test_keyspace", "0", unlinked.Tablet.Alias); err != nil {
+		t.Fatalf("RemoveShardReplicationRecord failed: %v", err)
+	}
+	missing := topo.TabletAlias{Cell: "cell1", Uid: 10}
+	if err := topo.UpdateShardReplicationRecord(ctx, ts, "test_keyspace", "0", missing); err != nil {
+		t.Fatalf("UpdateShardReplicationRecord failed: %v", err)
+	}
+
+	want := []string{
+		"tablet cell1-0000000000 has the master type, but the master is cell1-0000000001",
+		"tablet cell1-0000000001 is the master, but its type is replica",
+		"the master of the shard is cell1-0000000000, but the slaves replicate from cell1-0000000001",
+		"tablet cell1-0000000010 is in the replication graph, but doesn't exist, is scrapped, or is in another shard",
+		"tablet cell1-0000000003 is missing from the replication graph",
+		"tablet cell1-0000000004 replicates from 1.2.3.4:3306, not from the master cell1-0000000001",
+	}
+
+	// A dry run only reports the problems.
+	problems, err := wr.RepairReplicationGraph(ctx, "test_keyspace", "0", true)
+	if err != nil {
+		t.Fatalf("RepairReplicationGraph(dry run) failed: %v", err)
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("RepairReplicationGraph(dry run) returned:\n%q\nwant:\n%q", problems, want)
+	}
+	si, err := ts.GetShard("test_keyspace", "0")
+	if err != nil {
+		t.Fatalf("GetShard failed: %v", err)
+	}
+	if si.MasterAlias != oldMaster.Tablet.Alias {
+		t.Errorf("dry run changed the shard master to %v", si.MasterAlias)
+	}
+
+	problems, err = wr.RepairReplicationGraph(ctx, "test_keyspace", "0", false)
+	if err != nil {
+		t.Fatalf("RepairReplicationGraph failed: %v", err)
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("RepairReplicationGraph returned:\n%q\nwant:\n%q", problems, want)
+	}
+
+	si, err = ts.GetShard("test_keyspace", "0")
+	if err != nil {
+		t.Fatalf("GetShard failed: %v", err)
+	}
+	if si.MasterAlias != newMaster.Tablet.Alias {
+		t.Errorf("shard master is %v, want %v", si.MasterAlias, newMaster.Tablet.Alias)
+	}
+	for alias, wantType := range map[topo.TabletAlias]topo.TabletType{
+		oldMaster.Tablet.Alias: topo.TYPE_SPARE,
+		newMaster.Tablet.Alias: topo.TYPE_MASTER,
+	} {
+		ti, err := ts.GetTablet(alias)
+		if err != nil {
+			t.Fatalf("GetTablet(%v) failed: %v", alias, err)
+		}
+		if ti.Type != wantType {
+			t.Errorf("tablet %v is %v, want %v", alias, ti.Type, wantType)
+		}
+	}
+	sri, err := ts.GetShardReplication("cell1", "test_keyspace", "0")
+	if err != nil {
+		t.Fatalf("GetShardReplication failed: %v", err)
+	}
+	if _, err := sri.GetReplicationLink(unlinked.Tablet.Alias); err != nil {
+		t.Errorf("tablet %v is still missing from the replication graph", unlinked.Tablet.Alias)
+	}
+	if _, err := sri.GetReplicationLink(missing); err != topo.ErrNoNode {
+		t.Errorf("tablet %v is still in the replication graph", missing)
+	}
+
+	// Only the stray slave is left.
+	problems, err = wr.RepairReplicationGraph(ctx, "test_keyspace", "0", true)
+	if err != nil {
+		t.Fatalf("RepairReplicationGraph failed: %v", err)
+	}
+	if !reflect.DeepEqual(problems, want[5:]) {
+		t.Errorf("RepairReplicationGraph after repair returned:\n%q\nwant:\n%q", problems, want[5:])
+	}
+}
+
+func TestRepairReplicationGraphSplitBrain(t *testing.T) {
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+
+	master := NewFakeTablet(t, wr, "cell1", 0, topo.TYPE_MASTER)
+	other := NewFakeTablet(t, wr, "cell1", 1, topo.TYPE_REPLICA,
+		TabletParent(master.Tablet.Alias))
+	slave1 := NewFakeTablet(t, wr, "cell1", 2, topo.TYPE_REPLICA,
+		TabletParent(master.Tablet.Alias))
+	slave2 := NewFakeTablet(t, wr, "cell1", 3, topo.TYPE_REPLICA,
+		TabletParent(master.Tablet.Alias))
+	for _, ft := range []*FakeTablet{master, other, slave1, slave2} {
+		ft.StartActionLoop(t, wr)
+		defer ft.StopActionLoop(t)
+	}
+	replicatesFrom(slave1, master.Tablet)
+	replicatesFrom(slave2, other.Tablet)
+
+	want := "replicate from different masters"
+	if _, err := wr.RepairReplicationGraph(context.Background(), "test_keyspace", "0", false); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("RepairReplicationGraph returned %v, want %v", err, want)
+	}
+}
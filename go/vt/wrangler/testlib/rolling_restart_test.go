@@ -0,0 +1,118 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testlib
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	_ "github.com/youtube/vitess/go/vt/tabletmanager/gorpctmclient"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+	"golang.org/x/net/context"
+)
+
+// setupTestHook creates a VTROOT with a single hook in it that
+// succeeds, and returns a function to restore the original VTROOT.
+func setupTestHook(t *testing.T, name string) func() {
+	root, err := ioutil.TempDir("", "vtroot")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	if err := os.Mkdir(path.Join(root, "vthook"), 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if err := ioutil.WriteFile(path.Join(root, "vthook", name), []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	oldRoot := os.Getenv("VTROOT")
+	os.Setenv("VTROOT", root)
+	return func() {
+		os.Setenv("VTROOT", oldRoot)
+		os.RemoveAll(root)
+	}
+}
+
+func TestRollingRestartShard(t *testing.T) {
+	defer setupTestHook(t, "test_restart")()
+
+	ctx := context.Background()
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+
+	master := NewFakeTablet(t, wr, "cell1", 1, topo.TYPE_MASTER)
+	replica1 := NewFakeTablet(t, wr, "cell1", 2, topo.TYPE_REPLICA,
+		TabletParent(master.Tablet.Alias))
+	replica2 := NewFakeTablet(t, wr, "cell1", 3, topo.TYPE_REPLICA,
+		TabletParent(master.Tablet.Alias))
+	for _, ft := range []*FakeTablet{master, replica1, replica2} {
+		ft.FakeMysqlDaemon.CurrentSlaveStatus = &myproto.ReplicationStatus{
+			SlaveIORunning:  true,
+			SlaveSQLRunning: true,
+		}
+		ft.StartActionLoop(t, wr)
+		defer ft.StopActionLoop(t)
+	}
+	if _, err := wr.RebuildShardGraph(ctx, "test_keyspace", "0", nil); err != nil {
+		t.Fatalf("RebuildShardGraph failed: %v", err)
+	}
+
+	options := &wrangler.RollingRestartOptions{
+		HookName:          "test_restart",
+		MinServing:        2,
+		MaxReplicationLag: 10 * time.Second,
+		WaitTime:          5 * time.Second,
+	}
+
+	// not enough serving replicas, nothing should happen
+	if err := wr.RollingRestartShard(ctx, "test_keyspace", "0", options); err == nil {
+		t.Fatalf("RollingRestartShard should have failed with MinServing=2")
+	}
+	if ti, err := ts.GetTablet(replica1.Tablet.Alias); err != nil || ti.Type != topo.TYPE_REPLICA {
+		t.Fatalf("replica1 should not have been touched: %v %v", ti, err)
+	}
+
+	// this one should work
+	options.MinServing = 1
+	if err := wr.RollingRestartShard(ctx, "test_keyspace", "0", options); err != nil {
+		t.Fatalf("RollingRestartShard failed: %v", err)
+	}
+	for _, ft := range []*FakeTablet{replica1, replica2} {
+		ti, err := ts.GetTablet(ft.Tablet.Alias)
+		if err != nil {
+			t.Fatalf("GetTablet failed: %v", err)
+		}
+		if ti.Type != topo.TYPE_REPLICA {
+			t.Errorf("tablet %v was not put back in service: %v", ft.Tablet.Alias, ti.Type)
+		}
+	}
+	addrs, err := ts.GetEndPoints("cell1", "test_keyspace", "0", topo.TYPE_REPLICA)
+	if err != nil {
+		t.Fatalf("GetEndPoints failed: %v", err)
+	}
+	if len(addrs.Entries) != 2 {
+		t.Errorf("unexpected serving graph after rolling restart: %v", addrs)
+	}
+
+	// a lagging tablet aborts the process, and stays drained
+	replica1.FakeMysqlDaemon.CurrentSlaveStatus.SecondsBehindMaster = 100
+	options.WaitTime = 100 * time.Millisecond
+	if err := wr.RollingRestartShard(ctx, "test_keyspace", "0", options); err == nil {
+		t.Fatalf("RollingRestartShard should have failed with a lagging tablet")
+	}
+	if ti, err := ts.GetTablet(replica1.Tablet.Alias); err != nil || ti.Type != topo.TYPE_SPARE {
+		t.Errorf("lagging replica1 should be left drained: %v %v", ti, err)
+	}
+	if ti, err := ts.GetTablet(replica2.Tablet.Alias); err != nil || ti.Type != topo.TYPE_REPLICA {
+		t.Errorf("replica2 should not have been touched: %v %v", ti, err)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testlib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+	"golang.org/x/net/context"
+)
+
+func tabletSchemaVersion(version string) TabletOption {
+	return func(tablet *topo.Tablet) {
+		tablet.SchemaVersion = version
+	}
+}
+
+func TestGetSchemaVersions(t *testing.T) {
+	ctx := context.Background()
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+
+	master := NewFakeTablet(t, wr, "cell1", 1, topo.TYPE_MASTER,
+		tabletSchemaVersion("v2"))
+	upToDate := NewFakeTablet(t, wr, "cell1", 2, topo.TYPE_REPLICA,
+		TabletParent(master.Tablet.Alias), tabletSchemaVersion("v2"))
+	behind := NewFakeTablet(t, wr, "cell1", 3, topo.TYPE_REPLICA,
+		TabletParent(master.Tablet.Alias), tabletSchemaVersion("v1"))
+	unknown := NewFakeTablet(t, wr, "cell1", 4, topo.TYPE_RDONLY,
+		TabletParent(master.Tablet.Alias))
+
+	versions, err := wr.GetSchemaVersions(ctx, "test_keyspace", "0")
+	if err != nil {
+		t.Fatalf("GetSchemaVersions failed: %v", err)
+	}
+	want := []*wrangler.TabletSchemaVersion{
+		{Alias: master.Tablet.Alias, Type: topo.TYPE_MASTER, SchemaVersion: "v2"},
+		{Alias: upToDate.Tablet.Alias, Type: topo.TYPE_REPLICA, SchemaVersion: "v2"},
+		{Alias: behind.Tablet.Alias, Type: topo.TYPE_REPLICA, SchemaVersion: "v1", Stale: true},
+		{Alias: unknown.Tablet.Alias, Type: topo.TYPE_RDONLY, Stale: true},
+	}
+	if len(versions) != len(want) {
+		t.Fatalf("GetSchemaVersions returned %v tablets, want %v", len(versions), len(want))
+	}
+	for i, v := range versions {
+		if *v != *want[i] {
+			t.Errorf("GetSchemaVersions()[%v] = %+v, want %+v", i, *v, *want[i])
+		}
+	}
+}
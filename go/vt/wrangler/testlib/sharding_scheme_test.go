@@ -0,0 +1,56 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testlib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/key"
+	"github.com/youtube/vitess/go/vt/logutil"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+	"golang.org/x/net/context"
+)
+
+func TestSetKeyspaceShardingScheme(t *testing.T) {
+	ctx := context.Background()
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+	if err := ts.CreateKeyspace("ks", &topo.Keyspace{}); err != nil {
+		t.Fatalf("CreateKeyspace failed: %v", err)
+	}
+	key.RegisterShardingScheme("test_lookup", func(params map[string]string) error {
+		return nil
+	})
+
+	if err := wr.SetKeyspaceShardingScheme(ctx, "ks", "unknown_scheme", nil, false); err == nil {
+		t.Errorf("SetKeyspaceShardingScheme(unknown_scheme) worked")
+	}
+	if err := wr.SetKeyspaceShardingScheme(ctx, "ks", "test_lookup", map[string]string{"Table": "t1"}, false); err != nil {
+		t.Fatalf("SetKeyspaceShardingScheme failed: %v", err)
+	}
+	// setting the same scheme again is fine
+	if err := wr.SetKeyspaceShardingScheme(ctx, "ks", "test_lookup", map[string]string{"Table": "t1"}, false); err != nil {
+		t.Errorf("SetKeyspaceShardingScheme with the same params failed: %v", err)
+	}
+
+	// new params change the keyspace ids, like a new scheme
+	if err := wr.SetKeyspaceShardingScheme(ctx, "ks", "test_lookup", map[string]string{"Table": "t2"}, false); err == nil {
+		t.Errorf("SetKeyspaceShardingScheme with new params worked without -force")
+	}
+	if err := wr.SetKeyspaceShardingScheme(ctx, "ks", "test_lookup", map[string]string{"Table": "t2"}, true); err != nil {
+		t.Fatalf("SetKeyspaceShardingScheme with -force failed: %v", err)
+	}
+	ki, err := ts.GetKeyspace("ks")
+	if err != nil {
+		t.Fatalf("GetKeyspace failed: %v", err)
+	}
+	if ki.ShardingScheme != "test_lookup" || ki.ShardingSchemeParams["Table"] != "t2" {
+		t.Errorf("keyspace has scheme %v %v, want test_lookup with Table t2", ki.ShardingScheme, ki.ShardingSchemeParams)
+	}
+}
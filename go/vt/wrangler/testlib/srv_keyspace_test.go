@@ -0,0 +1,68 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testlib
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+	"golang.org/x/net/context"
+)
+
+func TestFixSrvKeyspace(t *testing.T) {
+	ctx := context.Background()
+	ts := zktopo.NewTestServer(t, []string{"cell1"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+
+	NewFakeTablet(t, wr, "cell1", 1, topo.TYPE_MASTER, TabletKeyspaceShard(t, "ks", "-80"))
+	NewFakeTablet(t, wr, "cell1", 2, topo.TYPE_MASTER, TabletKeyspaceShard(t, "ks", "80-"))
+	if err := wr.RebuildKeyspaceGraph(ctx, "ks", nil); err != nil {
+		t.Fatalf("RebuildKeyspaceGraph failed: %v", err)
+	}
+	if err := wr.ValidateKeyspace(ctx, "ks", false); err != nil {
+		t.Fatalf("ValidateKeyspace failed: %v", err)
+	}
+
+	// nothing to fix
+	if err := wr.FixSrvKeyspace(ctx, "ks"); err != nil {
+		t.Fatalf("FixSrvKeyspace failed: %v", err)
+	}
+
+	// add an overlapping shard to the master partition
+	srvKeyspace, err := ts.GetSrvKeyspace("cell1", "ks")
+	if err != nil {
+		t.Fatalf("GetSrvKeyspace failed: %v", err)
+	}
+	kp := srvKeyspace.Partitions[topo.TYPE_MASTER]
+	kp.Shards = append(kp.Shards, topo.SrvShard{Name: "0"})
+	kp.ShardReferences = append(kp.ShardReferences, topo.ShardReference{Name: "0"})
+	if err := ts.UpdateSrvKeyspace("cell1", "ks", srvKeyspace); err != nil {
+		t.Fatalf("UpdateSrvKeyspace failed: %v", err)
+	}
+	if err := wr.ValidateKeyspace(ctx, "ks", false); err == nil || !strings.Contains(err.Error(), "some validation errors") {
+		t.Fatalf("ValidateKeyspace with an overlap returned: %v", err)
+	}
+
+	// and fix it
+	if err := wr.FixSrvKeyspace(ctx, "ks"); err != nil {
+		t.Fatalf("FixSrvKeyspace failed: %v", err)
+	}
+	srvKeyspace, err = ts.GetSrvKeyspace("cell1", "ks")
+	if err != nil {
+		t.Fatalf("GetSrvKeyspace failed: %v", err)
+	}
+	if err := srvKeyspace.Partitions[topo.TYPE_MASTER].CheckCoverage(); err != nil {
+		t.Errorf("SrvKeyspace still invalid: %v", err)
+	}
+	if err := wr.ValidateKeyspace(ctx, "ks", false); err != nil {
+		t.Errorf("ValidateKeyspace after the fix failed: %v", err)
+	}
+}
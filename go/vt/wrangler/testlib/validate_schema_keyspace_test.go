@@ -0,0 +1,94 @@
+// Copyright 2015, Google Inc. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testlib
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/youtube/vitess/go/vt/logutil"
+	myproto "github.com/youtube/vitess/go/vt/mysqlctl/proto"
+	_ "github.com/youtube/vitess/go/vt/tabletmanager/gorpctmclient"
+	"github.com/youtube/vitess/go/vt/tabletmanager/tmclient"
+	"github.com/youtube/vitess/go/vt/topo"
+	"github.com/youtube/vitess/go/vt/wrangler"
+	"github.com/youtube/vitess/go/vt/zktopo"
+	"golang.org/x/net/context"
+)
+
+func newTestSchema(table1Schema string) *myproto.SchemaDefinition {
+	return &myproto.SchemaDefinition{
+		DatabaseSchema: "CREATE DATABASE `{{.DatabaseName}}` /*!40100 DEFAULT CHARACTER SET utf8 */",
+		TableDefinitions: []*myproto.TableDefinition{
+			&myproto.TableDefinition{
+				Name:   "table1",
+				Schema: table1Schema,
+				Type:   myproto.TABLE_BASE_TABLE,
+			},
+			&myproto.TableDefinition{
+				Name:   "_vt_internal",
+				Schema: "CREATE TABLE `_vt_internal` (\n  `id` bigint(20) NOT NULL\n) ENGINE=InnoDB",
+				Type:   myproto.TABLE_BASE_TABLE,
+			},
+		},
+	}
+}
+
+func TestValidateSchemaKeyspace(t *testing.T) {
+	ctx := context.Background()
+	ts := zktopo.NewTestServer(t, []string{"cell1", "cell2"})
+	wr := wrangler.New(logutil.NewConsoleLogger(), ts, tmclient.NewTabletManagerClient(), time.Second)
+
+	master1 := NewFakeTablet(t, wr, "cell1", 1,
+		topo.TYPE_MASTER, TabletKeyspaceShard(t, "ks", "-80"))
+	replica1 := NewFakeTablet(t, wr, "cell1", 2,
+		topo.TYPE_REPLICA, TabletKeyspaceShard(t, "ks", "-80"),
+		TabletParent(master1.Tablet.Alias))
+	master2 := NewFakeTablet(t, wr, "cell1", 10,
+		topo.TYPE_MASTER, TabletKeyspaceShard(t, "ks", "80-"))
+	replica2 := NewFakeTablet(t, wr, "cell1", 11,
+		topo.TYPE_REPLICA, TabletKeyspaceShard(t, "ks", "80-"),
+		TabletParent(master2.Tablet.Alias))
+
+	goodSchema := "CREATE TABLE `table1` (\n  `id` bigint(20) NOT NULL,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB"
+	for _, ft := range []*FakeTablet{master1, replica1, master2, replica2} {
+		ft.FakeMysqlDaemon.Schema = newTestSchema(goodSchema)
+		ft.StartActionLoop(t, wr)
+		defer ft.StopActionLoop(t)
+	}
+
+	// all the same
+	if err := wr.ValidateSchemaKeyspace(ctx, "ks", "", nil, false, false); err != nil {
+		t.Fatalf("ValidateSchemaKeyspace failed: %v", err)
+	}
+
+	// one replica diverges on table1
+	replica2.FakeMysqlDaemon.Schema = newTestSchema("CREATE TABLE `table1` (\n  `id` bigint(20) NOT NULL\n) ENGINE=InnoDB")
+	err := wr.ValidateSchemaKeyspace(ctx, "ks", "", nil, false, false)
+	if err == nil || !strings.Contains(err.Error(), "table1") || !strings.Contains(err.Error(), replica2.Tablet.Alias.String()) {
+		t.Fatalf("ValidateSchemaKeyspace should have found a diff for table1 on %v: %v", replica2.Tablet.Alias, err)
+	}
+
+	// only comparing masters, the replica is ignored
+	if err := wr.ValidateSchemaKeyspace(ctx, "ks", "80-", nil, false, true); err != nil {
+		t.Fatalf("ValidateSchemaKeyspace(skipNonMaster) failed: %v", err)
+	}
+
+	// a divergent internal table can be excluded
+	master2.FakeMysqlDaemon.Schema = newTestSchema(goodSchema)
+	master2.FakeMysqlDaemon.Schema.TableDefinitions[1].Schema = "CREATE TABLE `_vt_internal` (\n  `id` int(11) NOT NULL\n) ENGINE=InnoDB"
+	if err := wr.ValidateSchemaKeyspace(ctx, "ks", "", nil, false, true); err == nil {
+		t.Fatalf("ValidateSchemaKeyspace should have found a diff for _vt_internal")
+	}
+	if err := wr.ValidateSchemaKeyspace(ctx, "ks", "", []string{"_vt_.*"}, false, true); err != nil {
+		t.Fatalf("ValidateSchemaKeyspace(exclude_tables) failed: %v", err)
+	}
+
+	// unknown reference shard
+	if err := wr.ValidateSchemaKeyspace(ctx, "ks", "-40", nil, false, false); err == nil {
+		t.Fatalf("ValidateSchemaKeyspace should have failed with unknown reference shard")
+	}
+}
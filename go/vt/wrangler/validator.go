@@ -6,6 +6,7 @@ package wrangler
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
@@ -103,6 +104,54 @@ func (wr *Wrangler) validateKeyspace(ctx context.Context, keyspace string, pingT
 			wr.validateShard(ctx, keyspace, shard, pingTablets, wg, results)
 		}(shard)
 	}
+
+	// Validate the serving partitions in all cells.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		badCells, err := wr.checkSrvKeyspaces(keyspace)
+		if err != nil {
+			results <- err
+			return
+		}
+		for cell, err := range badCells {
+			results <- fmt.Errorf("SrvKeyspace %v in cell %v is invalid: %v", keyspace, cell, err)
+		}
+	}()
+}
+
+// checkSrvKeyspaces checks the partitions of the SrvKeyspace of a
+// keyspace in all the cells. It returns the cells that have invalid
+// partitions, with the problems found there.
+func (wr *Wrangler) checkSrvKeyspaces(keyspace string) (map[string]error, error) {
+	cells, err := wr.ts.GetKnownCells()
+	if err != nil {
+		return nil, fmt.Errorf("TopologyServer.GetKnownCells failed: %v", err)
+	}
+
+	badCells := make(map[string]error)
+	for _, cell := range cells {
+		srvKeyspace, err := wr.ts.GetSrvKeyspace(cell, keyspace)
+		switch err {
+		case nil:
+		case topo.ErrNoNode:
+			continue
+		default:
+			return nil, fmt.Errorf("TopologyServer.GetSrvKeyspace(%v, %v) failed: %v", cell, keyspace, err)
+		}
+
+		var problems []string
+		for tabletType, partition := range srvKeyspace.Partitions {
+			if err := partition.CheckCoverage(); err != nil {
+				problems = append(problems, fmt.Sprintf("%v: %v", tabletType, err))
+			}
+		}
+		if len(problems) > 0 {
+			sort.Strings(problems)
+			badCells[cell] = fmt.Errorf("%v", strings.Join(problems, "; "))
+		}
+	}
+	return badCells, nil
 }
 
 // FIXME(msolomon) This validate presumes the master is up and running.
@@ -216,16 +265,17 @@ func (wr *Wrangler) validateReplication(ctx context.Context, shardInfo *topo.Sha
 }
 
 func (wr *Wrangler) pingTablets(ctx context.Context, tabletMap map[topo.TabletAlias]*topo.TabletInfo, wg *sync.WaitGroup, results chan<- error) {
-	for tabletAlias, tabletInfo := range tabletMap {
-		wg.Add(1)
-		go func(tabletAlias topo.TabletAlias, tabletInfo *topo.TabletInfo) {
-			defer wg.Done()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
 
-			if err := wr.tmc.Ping(ctx, tabletInfo); err != nil {
-				results <- fmt.Errorf("Ping(%v) failed: %v %v", tabletAlias, err, tabletInfo.Hostname)
-			}
-		}(tabletAlias, tabletInfo)
-	}
+		br := wr.RunOnTablets(ctx, "Ping", tabletMapToList(tabletMap), DefaultBulkConcurrency, DefaultBulkTabletTimeout, func(ctx context.Context, ti *topo.TabletInfo) error {
+			return wr.tmc.Ping(ctx, ti)
+		})
+		for _, r := range br.Failures() {
+			results <- fmt.Errorf("Ping(%v) failed: %v %v", r.Alias, r.Error, tabletMap[r.Alias].Hostname)
+		}
+	}()
 }
 
 // Validate a whole TopologyServer tree
@@ -19,7 +19,7 @@ import (
 // ExportZkns exports addresses from the VT serving graph to a legacy zkns server.
 // Note these functions only work with a zktopo.
 func (wr *Wrangler) ExportZkns(cell string) error {
-	zkTopo, ok := wr.ts.(*zktopo.Server)
+	zkTopo, ok := topo.UnwrapServer(wr.ts).(*zktopo.Server)
 	if !ok {
 		return fmt.Errorf("ExportZkns only works with zktopo")
 	}
@@ -54,7 +54,7 @@ func (wr *Wrangler) ExportZkns(cell string) error {
 
 // ExportZknsForKeyspace exports addresses from the VT serving graph to a legacy zkns server.
 func (wr *Wrangler) ExportZknsForKeyspace(ctx context.Context, keyspace string) error {
-	zkTopo, ok := wr.ts.(*zktopo.Server)
+	zkTopo, ok := topo.UnwrapServer(wr.ts).(*zktopo.Server)
 	if !ok {
 		return fmt.Errorf("ExportZknsForKeyspace only works with zktopo")
 	}
@@ -5,9 +5,13 @@
 package zktopo
 
 import (
+	"fmt"
+	"path"
 	"sort"
 
+	"github.com/youtube/vitess/go/vt/topo"
 	"github.com/youtube/vitess/go/zk"
+	"launchpad.net/gozk/zookeeper"
 )
 
 /*
@@ -28,3 +32,26 @@ func (zkts *Server) GetKnownCells() ([]string, error) {
 	sort.Strings(cells)
 	return cells, nil
 }
+
+// CreateCell is part of the topo.CellCreator interface. The cell
+// needs to be in the zk client configuration already, addrs are
+// ignored.
+func (zkts *Server) CreateCell(cell string, addrs []string) error {
+	cells, err := zkts.GetKnownCells()
+	if err != nil {
+		return err
+	}
+	if !topo.InCellList(cell, cells) {
+		return fmt.Errorf("cell %v is not in the zk client configuration", cell)
+	}
+
+	cellPath := path.Join("/zk", cell, "vt")
+	if _, _, err := zkts.zconn.Get(cellPath); err == nil {
+		return topo.ErrNodeExists
+	}
+	_, err = zk.CreateRecursive(zkts.zconn, tabletDirectoryForCell(cell), "", 0, zookeeper.WorldACL(zookeeper.PERM_ALL))
+	if err != nil && !zookeeper.IsError(err, zookeeper.ZNODEEXISTS) {
+		return err
+	}
+	return nil
+}
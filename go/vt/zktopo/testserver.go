@@ -59,3 +59,17 @@ func (s *TestServer) SaveVSchema(vschema string) error {
 func (s *TestServer) GetVSchema() (string, error) {
 	return s.Server.(topo.Schemafier).GetVSchema()
 }
+
+// CreateCell has to be redefined here, as the test cells are not
+// in the zk client configuration.
+func (s *TestServer) CreateCell(cell string, addrs []string) error {
+	if topo.InCellList(cell, s.localCells) {
+		return topo.ErrNodeExists
+	}
+	zconn := s.Server.(*Server).zconn
+	if _, err := zk.CreateRecursive(zconn, tabletDirectoryForCell(cell), "", 0, zookeeper.WorldACL(zookeeper.PERM_ALL)); err != nil {
+		return err
+	}
+	s.localCells = append(s.localCells, cell)
+	return nil
+}
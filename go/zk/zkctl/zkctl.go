@@ -18,6 +18,7 @@ import (
 	"os/exec"
 	"path"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -93,14 +94,12 @@ func (zkd *Zkd) Start() error {
 			time.Sleep(time.Second)
 			continue
 		} else {
-			err = nil
-			conn.Write([]byte("ruok"))
-			reply := make([]byte, 4)
-			conn.Read(reply)
-			if string(reply) != "imok" {
+			conn.Close()
+			reply, cmdErr := fourLetterWord(zkAddr, "ruok")
+			err = cmdErr
+			if err == nil && reply != "imok" {
 				err = fmt.Errorf("local zk unhealthy: %v %v", zkAddr, reply)
 			}
-			conn.Close()
 			break
 		}
 	}
@@ -113,6 +112,65 @@ func (zkd *Zkd) Start() error {
 	return err
 }
 
+// fourLetterWord sends one of the ZooKeeper four letter commands
+// ('ruok', 'srvr', ...) to the server at addr, and returns the reply.
+func fourLetterWord(addr, command string) (string, error) {
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte(command)); err != nil {
+		return "", err
+	}
+	reply, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+	return string(reply), nil
+}
+
+// ServerMode returns the mode of the ZooKeeper server at addr, as
+// reported by the 'srvr' command: 'leader' or 'follower' if the
+// server is part of a quorum, or 'standalone'. It returns an error
+// if the server is not serving requests, for instance because the
+// quorum is not formed yet.
+func ServerMode(addr string) (string, error) {
+	reply, err := fourLetterWord(addr, "srvr")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(reply, "\n") {
+		if strings.HasPrefix(line, "Mode:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Mode:")), nil
+		}
+	}
+	return "", fmt.Errorf("zk server %v is not serving: %v", addr, strings.TrimSpace(reply))
+}
+
+// WaitForQuorum waits until the local server is serving requests,
+// which for a multi-server configuration means a quorum of the
+// servers is running and a leader was elected.
+func (zkd *Zkd) WaitForQuorum(waitTime time.Duration) error {
+	log.Infof("zkctl.WaitForQuorum")
+	addr := zkd.LocalClientAddr()
+	timeout := time.After(waitTime)
+	for {
+		mode, err := ServerMode(addr)
+		if err == nil {
+			log.Infof("zk server %v is serving as %v", addr, mode)
+			return nil
+		}
+		log.V(6).Infof("zk server %v not ready yet: %v", addr, err)
+		select {
+		case <-timeout:
+			return fmt.Errorf("zk server %v not serving after %v: %v", addr, waitTime, err)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
 func (zkd *Zkd) Shutdown() error {
 	log.Infof("zkctl.Shutdown")
 	pidData, err := ioutil.ReadFile(zkd.config.PidFile())
@@ -6,8 +6,10 @@ package zkctl
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path"
+	"strings"
 	"testing"
 )
 
@@ -75,3 +77,43 @@ func testLifeCycle(t *testing.T, config string, myID uint32) {
 		t.Fatalf("Teardown() err: %v", err)
 	}
 }
+
+// fakeZkServer replies to the 'srvr' command with the provided
+// reply, and returns its address.
+func fakeZkServer(t *testing.T, reply string) (string, func()) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 4)
+			conn.Read(buf)
+			if string(buf) == "srvr" {
+				conn.Write([]byte(reply))
+			}
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String(), func() { listener.Close() }
+}
+
+func TestServerMode(t *testing.T) {
+	addr, done := fakeZkServer(t, "Zookeeper version: 3.4.6\nLatency min/avg/max: 0/0/0\nMode: follower\nNode count: 4\n")
+	mode, err := ServerMode(addr)
+	done()
+	if err != nil || mode != "follower" {
+		t.Errorf("ServerMode() = %v, %v, want follower", mode, err)
+	}
+
+	addr, done = fakeZkServer(t, "This ZooKeeper instance is not currently serving requests\n")
+	_, err = ServerMode(addr)
+	done()
+	if err == nil || !strings.Contains(err.Error(), "not serving") {
+		t.Errorf("ServerMode() on a server without quorum returned %v", err)
+	}
+}